@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/dump"
+	"duchm1606/gocnn/internal/model"
+)
+
+// runEmbeddings writes the embedding of every sample and its label to the
+// -embeddings directory in the TensorBoard projector format, instead of evaluating
+func runEmbeddings(cfg *config.Config, cnn *model.TinyCNN, samples data.DatasetIterator, cache *data.TensorCache) error {
+    layer := *embeddingLayer
+    if layer == "" {
+        var err error
+        if layer, err = cnn.EmbeddingLayer(); err != nil {
+            return err
+        }
+    }
+
+    writer, err := dump.NewProjectorWriter(*embeddingsDir, "gocnn/"+layer, []string{"label", "index", "file"})
+    if err != nil {
+        return err
+    }
+
+    slog.Info("extracting embeddings", "layer", layer, "samples", *numSamples)
+    start := time.Now()
+    for i := 0; ; i++ {
+        image, label, err := samples.Next()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            writer.Close()
+            return fmt.Errorf("failed to load sample %d: %w", i, err)
+        }
+
+        embedding, err := cnn.Extract(context.Background(), image.Data, layer)
+        if err != nil {
+            writer.Close()
+            return fmt.Errorf("sample %d: %w", i, err)
+        }
+        class := labelClass(label)
+        if err := writer.Add(embedding, className(cfg, class), strconv.Itoa(i), data.SourcePath(samples, i)); err != nil {
+            writer.Close()
+            return err
+        }
+    }
+    if err := writer.Close(); err != nil {
+        return err
+    }
+
+    logCacheStats(cache)
+    slog.Info("embeddings saved", "dir", *embeddingsDir, "layer", layer, "samples", writer.Count(),
+        "dimensions", writer.Dims(), "duration", time.Since(start))
+    return nil
+}
+
+// labelClass returns the class of a one-hot label, or -1 if it has none
+func labelClass(label []int) int {
+    for class, v := range label {
+        if v != 0 {
+            return class
+        }
+    }
+    return -1
+}
+
+// className returns the configured name of class, or its index if it has none
+func className(cfg *config.Config, class int) string {
+    if class >= 0 && class < len(cfg.Model.ClassNames) {
+        return cfg.Model.ClassNames[class]
+    }
+    return strconv.Itoa(class)
+}
@@ -1,17 +1,35 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"duchm1606/gocnn/internal/config"
 	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/data/augment"
+	"duchm1606/gocnn/internal/dump"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/logging"
 	"duchm1606/gocnn/internal/metrics"
 	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/ops"
+	"duchm1606/gocnn/internal/runinfo"
+	"duchm1606/gocnn/internal/tensor"
 )
 
+// exitRegressed is the exit status when -baseline finds a regression
+const exitRegressed = 3
+
+// errRegressed is returned by runBenchmark when -baseline finds a regression
+var errRegressed = errors.New("performance regressed versus the baseline")
+
 // Version information
 const (
     AppName    = "gocnn-benchmark"
@@ -23,22 +41,77 @@ const (
 var (
     weightsPath = flag.String("weights", "", "Path to model weights directory (required)")
     imagesPath  = flag.String("images", "", "Path to test images directory (required)")
-    labelsPath  = flag.String("labels", "", "Path to test labels directory (required)")
+    labelsPath  = flag.String("labels", "", "Path to test labels directory or a filename,label CSV manifest (required)")
+    datasetPath = flag.String("dataset", "", "Directory with one folder of PNG/JPEG images per class, or a TFRecord file; replaces -images and -labels")
+    imageFormat = flag.String("image-format", "float32", "Image file encoding: float32 (values in [0, 1]) or uint8 (0-255)")
     configPath  = flag.String("config", "configs/cifar10.yaml", "Path to model configuration file")
     outputPath  = flag.String("output", "", "Path to save detailed results (optional)")
     
-    numSamples  = flag.Int("samples", 100, "Number of test samples to evaluate")
-    numWorkers  = flag.Int("workers", 4, "Number of parallel workers")
-    batchSize   = flag.Int("batch", 1, "Batch size for evaluation")
+    numSamples   = flag.Int("samples", 100, "Number of test samples to evaluate")
+    numWorkers   = flag.Int("workers", 4, "Number of parallel workers")
+    batchSize    = flag.Int("batch", 0, "Samples each worker predicts at once (default: inference.batch_size)")
+    sweepWorkers = flag.String("sweep-workers", "", "Rerun the evaluation at each comma-separated worker count, e.g. 1,2,4,8,16, and report throughput scaling")
     
     reportFormat = flag.String("format", "text", "Output format: text, csv, json")
     verbose      = flag.Bool("verbose", false, "Enable verbose output")
     quiet        = flag.Bool("quiet", false, "Suppress non-essential output")
+    logLevel     = flag.String("log-level", "", "Least severe log record written to stderr: debug, info, warn, error (default info, debug with -verbose, warn with -quiet)")
+    logFormat    = flag.String("log-format", "text", "Log record format on stderr: text or json")
     showMatrix   = flag.Bool("matrix", false, "Show confusion matrix")
     showTiming   = flag.Bool("timing", true, "Show detailed timing information")
+    topK         = flag.String("topk", "", "Comma-separated K values of the top-K accuracies to report, e.g. 1,3,5 (default: benchmark.report_top_k)")
+    averaging    = flag.String("average", "all", "Precision/recall/F1 averages to report: macro, micro, weighted or all, comma-separated")
+
+    latencyBuckets = flag.String("latency-buckets", "", "Latency histogram bucket width (e.g. 5ms) or increasing upper bounds (e.g. 1ms,5ms,20ms)")
+    latencyCDF     = flag.Bool("latency-cdf", false, "Also report the HDR-style cumulative latency distribution")
+
+    rooflineMode = flag.Bool("roofline", false, "Report per-layer FLOPs, arithmetic intensity and achieved GFLOP/s against the measured machine peak")
+    trackMemory  = flag.Bool("track-memory", false, "Report heap allocations per inference and per layer, GC cycles and peak heap")
+    memorySample = flag.Duration("memory-sample", 0, "Sample the live heap at this interval (e.g. 10ms) for the peak; implies -track-memory")
+
+    misclassifiedPath = flag.String("misclassified", "", "Write every misclassified sample to this .csv or .jsonl file")
+    misclassifiedDir  = flag.String("misclassified-images", "", "Copy misclassified images into one folder per true class under this directory")
     
     profileCPU = flag.String("cpuprofile", "", "Write CPU profile to file")
     profileMem = flag.String("memprofile", "", "Write memory profile to file")
+    pprofAddr  = flag.String("pprof-addr", "", "Serve net/http/pprof at this address (e.g. localhost:6060) while the benchmark runs")
+    
+    autotune  = flag.Bool("autotune", false, "Time convolution algorithms per layer and use the fastest")
+    tuneCache = flag.String("tune-cache", "", "JSON file caching -autotune choices between runs")
+
+    engineName    = flag.String("engine", "", "Convolution backend: auto, naive, tiled, parallel, gemm (default $GOCNN_ENGINE or auto)")
+    engineWorkers = flag.Int("engine-workers", -1, "Goroutines per convolution for the parallel backend (0 = one per CPU)")
+    enginePool    = flag.String("engine-pool", "", "Reuse intermediate buffers: on or off (default on)")
+    warmup        = flag.Bool("warmup", false, "Touch all weight pages and run a dummy inference before starting")
+    warmupSamples = flag.Int("warmup-samples", 0, "Time the first N samples separately as cold and leave them out of the timing metrics")
+
+    dumpDir       = flag.String("dump-activations", "", "Write every sample's per-layer activations to this directory")
+    dumpLayers    = flag.String("dump-layers", "", "Comma-separated layers to dump (default: all)")
+    dumpPrecision = flag.String("dump-precision", "float32", "Activation dump encoding: float32 or float16")
+    dumpCompress  = flag.String("dump-compress", "none", "Activation dump compression: none, zstd or gzip")
+    dumpFormat    = flag.String("dump-format", "raw", "Activation dump files: raw arrays or npy (NumPy, with a shape header)")
+    statsPath     = flag.String("activation-stats", "", "Write per-layer activation min/max/mean/std, dead channels and histograms to this JSON file")
+    statsBins     = flag.Int("activation-stats-bins", dump.DefaultStatsBins, "Histogram bins per layer in -activation-stats")
+
+    embeddingsDir  = flag.String("embeddings", "", "Instead of evaluating, write every sample's embedding and label to this directory for the TensorBoard projector")
+    embeddingLayer = flag.String("embedding-layer", "", "Layer whose channel means are the embedding (default: the layer before the classifier)")
+
+    prefetchDepth = flag.Int("prefetch", -1, "Samples to load ahead of evaluation, 0 to load on demand (default: data.prefetch)")
+    loaderWorkers = flag.Int("loader-workers", -1, "Goroutines decoding/preprocessing prefetched samples (default: data.loader_workers)")
+
+    cacheDir      = flag.String("cache-dir", "", "Reuse preprocessed samples stored in this directory between runs (default: data.cache_dir)")
+
+    augmentSpec = flag.String("augment", "", "Corrupt test images for robustness evaluation, e.g. noise=0.05,brightness=-0.1 (see -help)")
+    augmentSeed = flag.Uint64("augment-seed", 1, "Seed for random augmentations (hflip with p < 1, crop, cutout, noise)")
+
+    compareQuantized = flag.String("compare-quantized", "", "Also evaluate the quantized weights in this directory and report accuracy change and per-layer error")
+    compareWeights   = flag.String("compare", "", "Also evaluate the weights in this directory and report accuracy, latency and prediction differences")
+
+    baselinePath  = flag.String("baseline", "", "Fail with exit status 3 if accuracy or p95 latency regressed versus this JSON report")
+    maxRegression = flag.String("max-regression", "1%", "Largest accepted -baseline regression: accuracy points lost or relative p95 latency increase")
+
+    runManifest   = flag.String("run-manifest", "", "Write a run.json with version, commit, config/weights hashes, engine and host")
+    porcelainMode = flag.Bool("porcelain", false, "Print only stable tab-separated records for scripts")
     
     showVersion = flag.Bool("version", false, "Show version information")
     showHelp    = flag.Bool("help", false, "Show detailed help")
@@ -66,6 +139,20 @@ func main() {
         os.Exit(1)
     }
 
+    // The porcelain report replaces all other output
+    if *porcelainMode {
+        *quiet = true
+        *verbose = false
+        *reportFormat = "porcelain"
+    }
+
+    logger, err := newLogger()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        os.Exit(1)
+    }
+    slog.SetDefault(logger)
+
     // Set up profiling if requested
     if *profileCPU != "" {
         if err := startCPUProfile(*profileCPU); err != nil {
@@ -74,19 +161,34 @@ func main() {
         }
         defer stopCPUProfile()
     }
+    if *pprofAddr != "" {
+        closePprof, err := servePprof(*pprofAddr)
+        if err != nil {
+            errs.Fprint(os.Stderr, "Benchmark failed", err)
+            os.Exit(1)
+        }
+        defer closePprof()
+    }
 
-    // Run benchmark
-    if err := runBenchmark(); err != nil {
-        fmt.Fprintf(os.Stderr, "Benchmark failed: %v\n", err)
+    // Run benchmark; a regression still gets its memory profile written
+    err = runBenchmark()
+    if err != nil && !errors.Is(err, errRegressed) {
+        errs.Fprint(os.Stderr, "Benchmark failed", err)
         os.Exit(1)
     }
 
     // Write memory profile if requested
     if *profileMem != "" {
         if err := writeMemProfile(*profileMem); err != nil {
-            fmt.Fprintf(os.Stderr, "Failed to write memory profile: %v\n", err)
+            slog.Error("failed to write memory profile", "error", err)
         }
     }
+
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v (see the regression check)\n", err)
+        stopCPUProfile()
+        os.Exit(exitRegressed)
+    }
 }
 
 // validateArgs validates command line arguments
@@ -95,21 +197,36 @@ func validateArgs() error {
         return fmt.Errorf("weights path is required (use -weights)")
     }
 
-    if *imagesPath == "" {
-        return fmt.Errorf("images path is required (use -images)")
+    if *datasetPath != "" && (*imagesPath != "" || *labelsPath != "") {
+        return fmt.Errorf("-dataset replaces -images and -labels; use one or the other")
+    }
+
+    if *datasetPath == "" && *imagesPath == "" {
+        return fmt.Errorf("images path is required (use -images, or -dataset for a folder per class)")
     }
 
-    if *labelsPath == "" {
-        return fmt.Errorf("labels path is required (use -labels)")
+    if *datasetPath == "" && *labelsPath == "" {
+        return fmt.Errorf("labels path is required (use -labels, or -dataset for a folder per class)")
     }
 
     // Check if directories/files exist
     paths := map[string]string{
         "weights directory": *weightsPath,
-        "images directory":  *imagesPath,
-        "labels directory":  *labelsPath,
         "config file":       *configPath,
     }
+    if *datasetPath != "" {
+        paths["dataset"] = *datasetPath
+    } else {
+        paths["images directory"] = *imagesPath
+        paths["labels path"] = *labelsPath
+    }
+
+    if *compareQuantized != "" {
+        paths["quantized weights directory"] = *compareQuantized
+    }
+    if *compareWeights != "" {
+        paths["compared weights directory"] = *compareWeights
+    }
 
     for desc, path := range paths {
         if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -126,10 +243,34 @@ func validateArgs() error {
         return fmt.Errorf("number of workers must be positive, got %d", *numWorkers)
     }
 
-    if *batchSize <= 0 {
+    if *batchSize < 0 {
         return fmt.Errorf("batch size must be positive, got %d", *batchSize)
     }
 
+    if *warmupSamples < 0 {
+        return fmt.Errorf("warm-up samples must not be negative, got %d", *warmupSamples)
+    }
+
+    if _, err := parseTopK(*topK); err != nil {
+        return fmt.Errorf("-topk: %w", err)
+    }
+
+    if _, err := metrics.ParseAveraging(*averaging); err != nil {
+        return fmt.Errorf("-average: %w", err)
+    }
+
+    if _, err := metrics.ParseLatencyBuckets(*latencyBuckets); err != nil {
+        return fmt.Errorf("-latency-buckets: %w", err)
+    }
+
+    if *misclassifiedDir != "" && *misclassifiedPath == "" {
+        return fmt.Errorf("-misclassified-images requires -misclassified")
+    }
+
+    if *memorySample < 0 {
+        return fmt.Errorf("-memory-sample must not be negative")
+    }
+
     // Validate report format
     validFormats := map[string]bool{
         "text": true,
@@ -141,20 +282,163 @@ func validateArgs() error {
         return fmt.Errorf("invalid report format: %s (valid: text, csv, json)", *reportFormat)
     }
 
+    if *porcelainMode && *reportFormat != "text" {
+        return fmt.Errorf("-porcelain cannot be combined with -format %s", *reportFormat)
+    }
+
+    if *compareQuantized != "" && *compareWeights != "" {
+        return fmt.Errorf("-compare-quantized cannot be combined with -compare")
+    }
+
+    if *sweepWorkers != "" {
+        if _, err := metrics.ParseWorkerCounts(*sweepWorkers); err != nil {
+            return fmt.Errorf("-sweep-workers: %w", err)
+        }
+        if *compareQuantized != "" || *compareWeights != "" || *dumpDir != "" || *statsPath != "" {
+            return fmt.Errorf("-sweep-workers cannot be combined with -compare, -compare-quantized, -dump-activations or -activation-stats")
+        }
+    }
+
+    if _, err := metrics.ParseRegression(*maxRegression); err != nil {
+        return fmt.Errorf("-max-regression: %w", err)
+    }
+    if *baselinePath != "" && (*compareQuantized != "" || *compareWeights != "" || *sweepWorkers != "") {
+        return fmt.Errorf("-baseline cannot be combined with -compare, -compare-quantized or -sweep-workers")
+    }
+
+    if (*compareQuantized != "" || *compareWeights != "") && (*dumpDir != "" || *statsPath != "") {
+        return fmt.Errorf("-compare and -compare-quantized cannot be combined with -dump-activations or -activation-stats")
+    }
+
+    if *embeddingsDir != "" {
+        switch {
+        case *compareQuantized != "" || *compareWeights != "" || *sweepWorkers != "" || *baselinePath != "":
+            return fmt.Errorf("-embeddings cannot be combined with -compare, -compare-quantized, -sweep-workers or -baseline")
+        case *dumpDir != "" || *statsPath != "":
+            return fmt.Errorf("-embeddings cannot be combined with -dump-activations or -activation-stats")
+        }
+    } else if *embeddingLayer != "" {
+        return fmt.Errorf("-embedding-layer needs -embeddings")
+    }
+
+    if *statsBins < 2 {
+        return fmt.Errorf("-activation-stats-bins must be at least 2, got %d", *statsBins)
+    }
+
+    if _, err := data.ParseImageFormat(*imageFormat); err != nil {
+        return fmt.Errorf("-image-format: %w", err)
+    }
+
+    if _, err := augment.Parse(*augmentSpec, *augmentSeed); err != nil {
+        return fmt.Errorf("-augment: %w", err)
+    }
+
+    if _, err := resolveEngineOptions(); err != nil {
+        return err
+    }
+
+    if _, err := resolveDumpOptions(); err != nil {
+        return err
+    }
+
+    if _, err := newLogger(); err != nil {
+        return err
+    }
+
     return nil
 }
 
+// newLogger builds the logger of -log-level and -log-format, writing to stderr
+// Without -log-level, -verbose logs debug records too and -quiet only warnings and errors.
+func newLogger() (*slog.Logger, error) {
+    format, err := logging.ParseFormat(*logFormat)
+    if err != nil {
+        return nil, fmt.Errorf("-log-format: %w", err)
+    }
+
+    level := slog.LevelInfo
+    switch {
+    case *quiet:
+        level = slog.LevelWarn
+    case *verbose:
+        level = slog.LevelDebug
+    }
+    if *logLevel != "" {
+        if level, err = logging.ParseLevel(*logLevel); err != nil {
+            return nil, fmt.Errorf("-log-level: %w", err)
+        }
+    }
+    return logging.New(os.Stderr, logging.Options{Level: level, Format: format}), nil
+}
+
+// resolveDumpOptions builds the activation dump settings from the -dump-* flags
+func resolveDumpOptions() (dump.Options, error) {
+    opts := dump.Options{Dir: *dumpDir}
+
+    precision, err := tensor.ParsePrecision(*dumpPrecision)
+    if err != nil {
+        return opts, fmt.Errorf("-dump-precision: %w", err)
+    }
+    opts.Precision = precision
+
+    compression, err := dump.ParseCompression(*dumpCompress)
+    if err != nil {
+        return opts, fmt.Errorf("-dump-compress: %w", err)
+    }
+    opts.Compression = compression
+
+    format, err := dump.ParseFormat(*dumpFormat)
+    if err != nil {
+        return opts, fmt.Errorf("-dump-format: %w", err)
+    }
+    opts.Format = format
+
+    for _, name := range strings.Split(*dumpLayers, ",") {
+        if name = strings.TrimSpace(name); name != "" {
+            opts.Layers = append(opts.Layers, name)
+        }
+    }
+    return opts, nil
+}
+
+// resolveEngineOptions combines the defaults, GOCNN_ENGINE* variables and -engine* flags
+// Flags win over the environment
+func resolveEngineOptions() (ops.EngineOptions, error) {
+    opts, err := ops.EngineOptionsFromEnv(ops.DefaultEngineOptions())
+    if err != nil {
+        return opts, err
+    }
+
+    if *engineName != "" {
+        backend, err := ops.ParseBackend(*engineName)
+        if err != nil {
+            return opts, fmt.Errorf("-engine: %w", err)
+        }
+        opts.Backend = backend
+    }
+    if *engineWorkers >= 0 {
+        opts.Workers = *engineWorkers
+    }
+    if *enginePool != "" {
+        pooling, err := ops.ParsePooling(*enginePool)
+        if err != nil {
+            return opts, fmt.Errorf("-engine-pool: %w", err)
+        }
+        opts.Pooling = pooling
+    }
+
+    if *autotune && opts.Backend != ops.AlgoAuto {
+        return opts, fmt.Errorf("-autotune picks a backend per layer and cannot be combined with backend %q", opts.Backend)
+    }
+    return opts, nil
+}
+
 // runBenchmark executes the main benchmarking workflow
 func runBenchmark() error {
-    if !*quiet {
-        fmt.Printf("Starting %s v%s\n", AppName, AppVersion)
-        fmt.Printf("Evaluating %d samples with %d workers\n\n", *numSamples, *numWorkers)
-    }
+    slog.Info("starting "+AppName, "version", AppVersion, "samples", *numSamples, "workers", *numWorkers)
 
     // Load configuration
-    if *verbose {
-        fmt.Printf("Loading configuration from %s...\n", *configPath)
-    }
+    slog.Debug("loading configuration", "path", *configPath)
 
     cfg, err := config.Load(*configPath)
     if err != nil {
@@ -162,88 +446,463 @@ func runBenchmark() error {
     }
 
     // Load model
-    if !*quiet {
-        fmt.Printf("Loading CNN model from %s...\n", *weightsPath)
-    }
+    slog.Info("loading model", "weights", *weightsPath)
 
     start := time.Now()
-    cnn, err := model.NewTinyCNN(*weightsPath)
+    cnn, err := model.NewTinyCNNFromConfig(*weightsPath, cfg.Model)
     if err != nil {
         return fmt.Errorf("failed to load model: %w", err)
     }
     loadTime := time.Since(start)
 
-    if *verbose {
-        fmt.Printf("Model loaded in %v\n", loadTime)
-        printModelInfo(cnn)
+    engineOpts, err := resolveEngineOptions()
+    if err != nil {
+        return err
+    }
+    if err := cnn.ConfigureEngine(engineOpts); err != nil {
+        return fmt.Errorf("failed to configure engine: %w", err)
     }
 
-    // Load test data
-    if !*quiet {
-        fmt.Printf("Loading test data (%d samples)...\n", *numSamples)
+    slog.Debug("model loaded", "duration", loadTime, "model", cnn.Info())
+
+    if *autotune {
+        slog.Info("autotuning convolution algorithms")
+        results, err := cnn.Autotune(*tuneCache)
+        if err != nil {
+            return fmt.Errorf("autotune failed: %w", err)
+        }
+        for _, result := range results {
+            slog.Debug("autotuned layer", "layer", result.Layer, "shape", result.Shape, "algorithm", result.Algorithm)
+        }
     }
 
-    start = time.Now()
-    testData, err := loadTestData(cfg)
+    // Keep the first evaluated sample from paying for cold pages and empty buffer pools
+    if *warmup || cfg.Inference.Warmup {
+        stats, err := cnn.Warmup(cfg.Inference.WarmupInferences)
+        if err != nil {
+            return err
+        }
+        slog.Debug("warm-up done", "stats", stats)
+    }
+
+    // Open the test data; samples are read as evaluation consumes them
+    cache, err := openTensorCache(cfg)
+    if err != nil {
+        return err
+    }
+    testData, err := openTestData(cfg, cache)
     if err != nil {
         return fmt.Errorf("failed to load test data: %w", err)
     }
-    dataLoadTime := time.Since(start)
+    defer testData.Close()
 
-    if *verbose {
-        fmt.Printf("Test data loaded in %v\n", dataLoadTime)
-        fmt.Printf("Images: %d, Labels: %d\n", len(testData.Images), len(testData.Labels))
+    if *embeddingsDir != "" {
+        return runEmbeddings(cfg, cnn, testData, cache)
     }
 
     // Run evaluation
-    if !*quiet {
-        fmt.Printf("\nRunning evaluation...\n")
+    slog.Info("running evaluation")
+
+    var dumpWriter *dump.Writer
+    if *dumpDir != "" {
+        dumpOpts, err := resolveDumpOptions()
+        if err != nil {
+            return err
+        }
+        dumpWriter, err = dump.NewWriter(dumpOpts)
+        if err != nil {
+            return err
+        }
+        cnn.SetActivationDump(dumpWriter)
+    }
+
+    var statsCollector *dump.StatsCollector
+    if *statsPath != "" {
+        statsCollector = dump.NewStatsCollector(*statsBins)
+        cnn.SetActivationStats(statsCollector)
+    }
+
+    run, err := newRunManifest(cnn, *weightsPath)
+    if err != nil {
+        return err
+    }
+
+    evaluator := metrics.NewEvaluator(*numWorkers)
+    batch := cfg.Inference.BatchSize
+    if *batchSize > 0 {
+        batch = *batchSize
+    }
+    evaluator.SetBatchSize(batch)
+    ks, err := parseTopK(*topK)
+    if err != nil {
+        return err
+    }
+    if len(ks) == 0 {
+        ks = []int{cfg.Benchmark.ReportTopK}
+    }
+    evaluator.SetTopK(ks...)
+    evaluator.SetWarmup(*warmupSamples)
+    histogram, err := metrics.ParseLatencyBuckets(*latencyBuckets)
+    if err != nil {
+        return err
+    }
+    histogram.Cumulative = *latencyCDF
+    evaluator.SetLatencyHistogram(histogram)
+    // The bar redraws a line of stderr, which would break JSON log records
+    if format, _ := logging.ParseFormat(*logFormat); !*quiet && format != logging.FormatJSON {
+        evaluator.SetProgress(metrics.NewProgressBar(os.Stderr, "Evaluating"), *numSamples)
+    }
+    if *trackMemory || *memorySample > 0 {
+        evaluator.SetMemoryTracking(metrics.MemoryOptions{SampleInterval: *memorySample})
+    }
+    if *compareQuantized != "" || *compareWeights != "" {
+        return runComparison(cfg, cnn, engineOpts, testData, cache, run, evaluator)
+    }
+    if *sweepWorkers != "" {
+        return runSweep(cfg, cnn, testData, cache, evaluator)
+    }
+    if *misclassifiedPath != "" {
+        evaluator.SetMisclassifiedExport(metrics.MisclassifiedOptions{
+            Path:       *misclassifiedPath,
+            ImageDir:   *misclassifiedDir,
+            ClassNames: cfg.Model.ClassNames,
+        })
+    }
+
+    // Read the baseline first so a bad path fails before the evaluation
+    var baseline *metrics.EvaluationResult
+    if *baselinePath != "" {
+        if baseline, err = metrics.LoadBaseline(*baselinePath); err != nil {
+            return err
+        }
     }
 
-    evaluator := metrics.NewEvaluator(*numWorkers, *verbose)
     start = time.Now()
-    results, err := evaluator.EvaluateModel(cnn, testData.Images, testData.Labels)
+    results, err := evaluator.EvaluateIterator(cnn, testData)
     if err != nil {
         return fmt.Errorf("evaluation failed: %w", err)
     }
     evalTime := time.Since(start)
+    results.Run = run
+
+    if *rooflineMode {
+        slog.Info("measuring machine peak for the roofline")
+        engine := ops.NewConvolutionEngine()
+        if err := engine.Configure(engineOpts); err != nil {
+            return err
+        }
+        results.Roofline = metrics.ComputeRoofline(cnn.Info(), engine.MeasurePeak())
+    }
+
+    if *runManifest != "" {
+        if err := run.Write(*runManifest); err != nil {
+            return err
+        }
+        slog.Info("run manifest saved", "path", *runManifest)
+    }
+
+    if dumpWriter != nil {
+        cnn.SetActivationDump(nil)
+        manifest, err := dumpWriter.Close()
+        if err != nil {
+            return err
+        }
+        slog.Info("activations dumped", "dir", *dumpDir, "activations", len(manifest.Entries),
+            "bytes", manifest.Bytes, "ratio", manifest.Ratio())
+    }
+
+    if statsCollector != nil {
+        cnn.SetActivationStats(nil)
+        if err := statsCollector.Save(*statsPath); err != nil {
+            return err
+        }
+        report := statsCollector.Report()
+        for _, layer := range report.Layers {
+            if len(layer.DeadChannels) > 0 {
+                slog.Warn("channels never activated", "layer", layer.Layer,
+                    "dead", len(layer.DeadChannels), "channels", layer.Channels)
+            }
+            if layer.NonFinite > 0 {
+                slog.Warn("non-finite activations", "layer", layer.Layer, "values", layer.NonFinite)
+            }
+        }
+        slog.Info("activation statistics saved", "path", *statsPath, "layers", len(report.Layers),
+            "samples", report.Samples)
+    }
 
-    if !*quiet {
-        fmt.Printf("Evaluation completed in %v\n\n", evalTime)
+    logCacheStats(cache)
+    if *misclassifiedPath != "" {
+        slog.Info("misclassified samples saved", "path", *misclassifiedPath)
     }
+    slog.Info("evaluation completed", "duration", evalTime)
 
     // Generate and display report
     reporter := NewReporter(*reportFormat, cfg.Model.ClassNames)
-    return reporter.GenerateReport(results, evalTime, *outputPath)
+    modes, err := metrics.ParseAveraging(*averaging)
+    if err != nil {
+        return err
+    }
+    reporter.SetAveraging(modes)
+    var regression *metrics.RegressionReport
+    if baseline != nil {
+        limit, err := metrics.ParseRegression(*maxRegression)
+        if err != nil {
+            return err
+        }
+        regression = metrics.CheckRegression(baseline, results, limit)
+        reporter.SetRegression(regression)
+    }
+    if err := reporter.GenerateReport(results, evalTime, *outputPath); err != nil {
+        return err
+    }
+    if regression != nil && regression.Regressed() {
+        return errRegressed
+    }
+    return nil
 }
 
-// loadTestData loads test images and labels
-func loadTestData(cfg *config.Config) (*data.DataBatch, error) {
-    dataManager := data.NewDataManager("", data.BinaryFloat32, data.OneHotText)
-    
-    return dataManager.LoadTestBatch(
-        *imagesPath,
-        *labelsPath,
-        *numSamples,
-        cfg.Model.InputHeight,
-        cfg.Model.InputWidth,
-        cfg.Model.InputChannels,
-        cfg.Model.NumClasses,
-    )
+// runComparison evaluates cnn and the model loaded from -compare-quantized or -compare
+// on the same test data and reports how their accuracy, latency and predictions differ,
+// and for quantized weights how much layer precision quantization costs
+func runComparison(cfg *config.Config, cnn model.Predictor, engineOpts ops.EngineOptions, samples data.DatasetIterator,
+    cache *data.TensorCache, run *runinfo.Manifest, evaluator *metrics.Evaluator) error {
+
+    // Both models and the per-layer pass read every sample, so load them once
+    slog.Info("loading test data", "samples", *numSamples)
+    testData, err := data.Collect(samples)
+    if err != nil {
+        return fmt.Errorf("failed to load test data: %w", err)
+    }
+    logCacheStats(cache)
+
+    // The second model is the quantized one, or any other weights with -compare
+    secondDir, secondKind := *compareQuantized, "quantized"
+    if *compareWeights != "" {
+        secondDir, secondKind = *compareWeights, "compared"
+    }
+    slog.Info("loading "+secondKind+" model", "weights", secondDir)
+    second, err := model.NewTinyCNNFromConfig(secondDir, cfg.Model)
+    if err != nil {
+        return fmt.Errorf("failed to load %s model: %w", secondKind, err)
+    }
+    if err := second.ConfigureEngine(engineOpts); err != nil {
+        return fmt.Errorf("failed to configure engine: %w", err)
+    }
+    slog.Debug(secondKind+" model loaded", "model", second.Info())
+    if *warmup || cfg.Inference.Warmup {
+        if _, err := second.Warmup(cfg.Inference.WarmupInferences); err != nil {
+            return err
+        }
+    }
+
+    secondRun, err := newRunManifest(second, secondDir)
+    if err != nil {
+        return err
+    }
+
+    start := time.Now()
+    result, err := evaluator.CompareModels(cnn, second, testData.Images, testData.Labels)
+    if err != nil {
+        return fmt.Errorf("comparison failed: %w", err)
+    }
+    evalTime := time.Since(start)
+    result.A.Run = run
+    result.B.Run = secondRun
+
+    if *runManifest != "" {
+        if err := run.Write(*runManifest); err != nil {
+            return err
+        }
+        slog.Info("run manifest saved", "path", *runManifest)
+    }
+
+    slog.Info("comparison completed", "duration", evalTime)
+
+    reporter := NewReporter(*reportFormat, cfg.Model.ClassNames)
+    if *compareWeights != "" {
+        reporter.SetModelNames(comparedModelNames(*weightsPath, *compareWeights))
+    }
+    return reporter.GenerateComparisonReport(result, evalTime, *outputPath)
+}
+
+// comparedModelNames names two compared models after their weights directories,
+// or "Baseline" and "Candidate" when those end alike
+func comparedModelNames(first, second string) (string, string) {
+    firstName, secondName := filepath.Base(filepath.Clean(first)), filepath.Base(filepath.Clean(second))
+    if firstName == secondName {
+        return "Baseline", "Candidate"
+    }
+    return firstName, secondName
+}
+
+// runSweep evaluates cnn on the same test data at every -sweep-workers count and
+// reports how throughput scales with the workers
+func runSweep(cfg *config.Config, cnn model.Predictor, samples data.DatasetIterator, cache *data.TensorCache,
+    evaluator *metrics.Evaluator) error {
+
+    counts, err := metrics.ParseWorkerCounts(*sweepWorkers)
+    if err != nil {
+        return err
+    }
+
+    // Every run reads every sample, so load them once
+    slog.Info("loading test data", "samples", *numSamples)
+    testData, err := data.Collect(samples)
+    if err != nil {
+        return fmt.Errorf("failed to load test data: %w", err)
+    }
+    logCacheStats(cache)
+
+    start := time.Now()
+    points, err := evaluator.SweepWorkers(cnn, testData.Images, testData.Labels, counts)
+    if err != nil {
+        return fmt.Errorf("sweep failed: %w", err)
+    }
+    slog.Info("sweep completed", "worker_counts", len(counts), "duration", time.Since(start))
+
+    reporter := NewReporter(*reportFormat, cfg.Model.ClassNames)
+    return reporter.GenerateSweepReport(points, *outputPath)
+}
+
+// parseTopK parses a comma-separated list of positive K values; "" is none
+func parseTopK(list string) ([]int, error) {
+    var ks []int
+    for _, field := range strings.Split(list, ",") {
+        field = strings.TrimSpace(field)
+        if field == "" {
+            continue
+        }
+        k, err := strconv.Atoi(field)
+        if err != nil || k <= 0 {
+            return nil, fmt.Errorf("invalid K %q: must be a positive integer", field)
+        }
+        ks = append(ks, k)
+    }
+    return ks, nil
+}
+
+// newRunManifest records the binary, inputs, engine settings and host of a run
+// of cnn loaded from weights
+func newRunManifest(cnn model.Predictor, weights string) (*runinfo.Manifest, error) {
+    run := runinfo.New(AppName, AppVersion)
+    if err := run.SetConfig(*configPath); err != nil {
+        return nil, err
+    }
+    if err := run.SetWeights(weights); err != nil {
+        return nil, err
+    }
+
+    run.SetModel(cnn.Info())
+    if *augmentSpec != "" {
+        run.SetSeed("augment", *augmentSeed)
+    }
+    return run, nil
 }
 
-// printModelInfo displays model information
-func printModelInfo(cnn *model.TinyCNN) {
-    info := cnn.GetModelInfo()
+// openTestData opens the test images and labels selected by the flags as a stream
+// of preprocessed samples
+func openTestData(cfg *config.Config, cache *data.TensorCache) (data.DatasetIterator, error) {
+    format, err := data.ParseImageFormat(*imageFormat)
+    if err != nil {
+        return nil, err
+    }
+    preprocessor, err := data.NewPreprocessor(format, cfg.Data, cfg.Model)
+    if err != nil {
+        return nil, err
+    }
+    steps, err := augment.Parse(*augmentSpec, *augmentSeed)
+    if err != nil {
+        return nil, err
+    }
+    preprocessor.SetAugmentation(steps...)
+    if cache != nil {
+        preprocessor.SetCache(cache)
+    }
     
-    fmt.Printf("Model Information:\n")
-    fmt.Printf("  Input Size: %d×%d×%d\n",
-        info.Architecture.InputHeight,
-        info.Architecture.InputWidth,
-        info.Architecture.InputChannels)
-    fmt.Printf("  Output Classes: %d\n", info.Architecture.NumClasses)
-    fmt.Printf("  Total Parameters: %d\n", info.TotalParameters)
-    fmt.Printf("  Total Layers: %d\n", len(info.Architecture.Layers))
+    height, width := preprocessor.StoredSize()
+    var it data.DatasetIterator
+    switch {
+    case *datasetPath != "" && data.IsTFRecordFile(*datasetPath):
+        it, err = data.OpenTFRecord(*datasetPath, data.CIFAR10TFRecordSchema, *numSamples,
+            height, width, cfg.Model.InputChannels, cfg.Model.NumClasses)
+    case *datasetPath != "":
+        // Folder images are preprocessed as they are loaded
+        it, err = data.NewImageFolderIterator(*datasetPath, cfg.Model.ClassNames, *numSamples, preprocessor)
+        if err != nil {
+            return nil, err
+        }
+        return prefetch(cfg, it), nil
+    default:
+        // A CSV manifest names the images and labels them in one file
+        labelFormat := data.OneHotText
+        if strings.EqualFold(filepath.Ext(*labelsPath), ".csv") {
+            labelFormat = data.CSVManifest
+        }
+        dataManager := data.NewDataManager("", format, labelFormat)
+        dataManager.SetClassNames(cfg.Model.ClassNames)
+
+        it, err = dataManager.TestIterator(
+            *imagesPath,
+            *labelsPath,
+            *numSamples,
+            height,
+            width,
+            cfg.Model.InputChannels,
+            cfg.Model.NumClasses,
+        )
+    }
+    if err != nil {
+        return nil, err
+    }
+    return prefetch(cfg, data.Preprocessed(it, preprocessor)), nil
+}
+
+// openTensorCache opens the preprocessed sample cache named by -cache-dir or the
+// data config; it returns nil when caching is off
+func openTensorCache(cfg *config.Config) (*data.TensorCache, error) {
+    dir := cfg.Data.CacheDir
+    if *cacheDir != "" {
+        dir = *cacheDir
+    }
+    if dir == "" {
+        return nil, nil
+    }
+    if *augmentSpec != "" {
+        slog.Debug("tensor cache not used: -augment changes samples on every run")
+    }
+    return data.NewTensorCache(dir)
+}
+
+// logCacheStats logs how many samples came from the tensor cache
+func logCacheStats(cache *data.TensorCache) {
+    if cache == nil {
+        return
+    }
+    hits, misses := cache.Stats()
+    if hits+misses > 0 {
+        slog.Info("tensor cache", "dir", cache.Dir(), "hits", hits, "misses", misses)
+    }
+}
+
+// prefetch loads the samples of it ahead of evaluation as -prefetch and
+// -loader-workers (or the data config) ask
+func prefetch(cfg *config.Config, it data.DatasetIterator) data.DatasetIterator {
+    depth, workers := cfg.Data.Prefetch, cfg.Data.LoaderWorkers
+    if *prefetchDepth >= 0 {
+        depth = *prefetchDepth
+    }
+    if *loaderWorkers >= 0 {
+        workers = *loaderWorkers
+    }
+    if depth == 0 {
+        return it
+    }
+    // Random augmentations draw in loading order; one loader keeps -augment-seed reproducible
+    if *augmentSpec != "" {
+        workers = 1
+    }
+    slog.Debug("prefetching samples", "depth", depth, "loader_workers", max(workers, 1))
+    return data.NewPrefetcher(it, depth, workers)
 }
 
 // printVersion displays version information
@@ -257,26 +916,102 @@ func printHelp() {
     fmt.Printf("%s - %s\n\n", AppName, AppDesc)
     
     fmt.Println("USAGE:")
-    fmt.Printf("  %s -weights <path> -images <path> -labels <path> [options]\n\n", AppName)
+    fmt.Printf("  %s -weights <path> -images <path> -labels <path> [options]\n", AppName)
+    fmt.Printf("  %s -weights <path> -dataset <path> [options]\n\n", AppName)
     
     fmt.Println("REQUIRED:")
     fmt.Println("  -weights <path>    Path to directory containing model weights")
     fmt.Println("  -images <path>     Path to directory containing test images")
-    fmt.Println("  -labels <path>     Path to directory containing test labels")
+    fmt.Println("  -labels <path>     Path to directory containing test labels, or a .csv manifest of")
+    fmt.Println("                     filename,label rows (label: class index or name; filenames relative")
+    fmt.Println("                     to -images; -samples takes the first rows)")
+    fmt.Println("  -dataset <path>    Instead of -images/-labels: <path>/<class name>/*.png (or .jpg), labelled")
+    fmt.Println("                     by folder name through class_names; -samples takes images round-robin")
+    fmt.Println("                     across classes. A .tfrecord file (optionally .gz) of tf.train.Example")
+    fmt.Println("                     records with an image (raw HWC uint8 or PNG) and int64 label feature")
+    fmt.Println("                     is read directly; concatenate shards with cat")
     
     fmt.Println("\nOPTIONS:")
     fmt.Println("  -config <path>     Path to model configuration file (default: configs/cifar10.yaml)")
     fmt.Println("  -output <path>     Save detailed results to file")
+    fmt.Println("  -misclassified <file> Write misclassified samples (index, classes, confidence, source file,")
+    fmt.Println("                     probabilities) to a .csv or .jsonl file")
+    fmt.Println("  -misclassified-images <dir> Also copy their images to <dir>/<true class>/<index>_as_<predicted>_<file>")
     fmt.Println("  -samples <n>       Number of test samples to evaluate (default: 100)")
     fmt.Println("  -workers <n>       Number of parallel workers (default: 4)")
-    fmt.Println("  -batch <n>         Batch size for evaluation (default: 1)")
+    fmt.Println("  -sweep-workers <list> Evaluate the samples once per worker count, e.g. 1,2,4,8,16, and report")
+    fmt.Println("                     wall-clock throughput, speedup and efficiency to help pick -workers")
+    fmt.Println("  -batch <n>         Samples each worker predicts at once; every conv layer reads its")
+    fmt.Println("                     weights once per batch (default: inference.batch_size)")
     fmt.Println("  -format <fmt>      Output format: text, csv, json (default: text)")
+    fmt.Println("  -image-format <f>  Image file encoding: float32 (default) or uint8")
+    fmt.Println("  -porcelain         Print only stable tab-separated records (see PORCELAIN OUTPUT)")
     fmt.Println("  -verbose           Enable verbose output")
     fmt.Println("  -quiet             Suppress non-essential output")
+    fmt.Println("  -log-level <l>     Least severe log record on stderr: debug, info, warn, error")
+    fmt.Println("                     (default: info; debug with -verbose, warn with -quiet)")
+    fmt.Println("  -log-format <f>    Log records on stderr as text (default) or json, one per line")
     fmt.Println("  -matrix            Show confusion matrix")
     fmt.Println("  -timing            Show detailed timing information (default: true)")
+    fmt.Println("  -topk <list>       Top-K accuracies to report, e.g. 1,3,5 (default: benchmark.report_top_k)")
+    fmt.Println("  -average <list>    Precision/recall/F1 averages: macro, micro, weighted, all (default: all)")
+    fmt.Println("  -latency-buckets <spec> Latency histogram buckets: one width (5ms) or increasing upper")
+    fmt.Println("                     bounds (1ms,5ms,20ms); default: a width giving about 20 buckets")
+    fmt.Println("  -latency-cdf       Also report the HDR-style cumulative latency distribution")
+    fmt.Println("  -roofline          Report per-layer FLOPs, arithmetic intensity and achieved GFLOP/s")
+    fmt.Println("                     against the measured compute peak and memory bandwidth, marking")
+    fmt.Println("                     each layer compute- or memory-bound")
+    fmt.Println("  -track-memory      Report heap bytes and objects allocated per inference and per layer,")
+    fmt.Println("                     GC cycles and pauses, and the peak heap; per-inference figures are")
+    fmt.Println("                     exact only with -workers 1")
+    fmt.Println("  -memory-sample <d> Sample the live heap every d (e.g. 10ms) to find its peak; implies")
+    fmt.Println("                     -track-memory (default: only at the start and end)")
     fmt.Println("  -cpuprofile <file> Write CPU profile to file")
     fmt.Println("  -memprofile <file> Write memory profile to file")
+    fmt.Println("  -pprof-addr <addr> Serve /debug/pprof/ at addr (e.g. localhost:6060) while the benchmark runs,")
+    fmt.Println("                     for CPU, heap and goroutine profiles mid-run; CPU profiles can't be")
+    fmt.Println("                     taken there while -cpuprofile is writing one")
+    fmt.Println("  -autotune          Pick the fastest convolution algorithm per layer")
+    fmt.Println("  -tune-cache <file> Reuse/save -autotune choices in a JSON file")
+    fmt.Println("  -engine <name>     Convolution backend: auto, naive, tiled, parallel, gemm")
+    fmt.Println("  -engine-workers <n> Goroutines for the parallel backend (0 = one per CPU)")
+    fmt.Println("  -engine-pool <on|off> Reuse intermediate buffers between layers (default: on)")
+    fmt.Println("  -warmup            Touch weight pages and run a dummy inference before starting")
+    fmt.Println("  -warmup-samples <n> Evaluate the first n samples as warm-up: they count toward accuracy, but")
+    fmt.Println("                     their timings are reported separately as cold (page faults, empty")
+    fmt.Println("                     buffer pools, worker start-up) and left out of the timing metrics")
+    fmt.Println("  -dump-activations <dir> Write per-layer activations and a manifest.json to <dir>")
+    fmt.Println("  -dump-layers <list> Comma-separated layers to dump (default: all)")
+    fmt.Println("  -dump-precision <p> Dump encoding: float32 or float16 (default: float32)")
+    fmt.Println("  -dump-compress <c> Dump compression: none, zstd (.zst) or gzip (.gz) (default: none)")
+    fmt.Println("  -dump-format <f>   Dump files: raw or npy, loadable with numpy.load (default: raw)")
+    fmt.Println("  -activation-stats <file> Write each layer's activation min, max, mean, std, fraction of")
+    fmt.Println("                     zeros, dead channels (never positive) and histogram to a JSON file")
+    fmt.Println("  -activation-stats-bins <n> Histogram bins per layer (default: 64)")
+    fmt.Println("  -embeddings <dir>  Instead of evaluating, write each sample's embedding to <dir>/vectors.tsv")
+    fmt.Println("                     and its label to metadata.tsv for the TensorBoard Embedding Projector")
+    fmt.Println("                     (t-SNE, UMAP, PCA); open the TSV files at projector.tensorflow.org")
+    fmt.Println("  -embedding-layer <name> Layer whose per-channel means form the embedding")
+    fmt.Println("                     (default: the layer feeding the final conv layer, maxpool3 in TinyCNN)")
+    fmt.Println("  -baseline <file>   Compare with a JSON report (-format json) of an earlier run and exit with")
+    fmt.Println("                     status 3 if top-1 accuracy dropped or p95 latency rose beyond -max-regression")
+    fmt.Println("  -max-regression <r> Largest accepted regression, e.g. 1% or 0.02: accuracy points lost,")
+    fmt.Println("                     or relative p95 latency increase (default: 1%)")
+    fmt.Println("  -compare <dir>     Also evaluate the weights in <dir> on the same samples and report the")
+    fmt.Println("                     differences: per-class accuracy, latency, McNemar's test and which")
+    fmt.Println("                     predictions changed to what (the disagreement matrix)")
+    fmt.Println("  -compare-quantized <dir> Also evaluate the quantized weights in <dir> and report")
+    fmt.Println("                     per-class accuracy change, top-1 drop, per-layer output error and")
+    fmt.Println("                     McNemar's test (any weights of the same architecture can be compared)")
+    fmt.Println("  -prefetch <n>      Samples loaded ahead of evaluation, 0 to load on demand (default: data.prefetch)")
+    fmt.Println("  -loader-workers <n> Goroutines decoding and preprocessing them (default: data.loader_workers)")
+    fmt.Println("  -cache-dir <dir>   Store preprocessed samples in <dir>, keyed by content hash, and reuse")
+    fmt.Println("                     them on later runs (default: data.cache_dir)")
+    fmt.Println("  -augment <spec>    Corrupt images before normalization, comma-separated and in order:")
+    fmt.Println("                     hflip[=p], crop=<pad px>, cutout=<size px>, noise=<sigma>,")
+    fmt.Println("                     brightness=<delta>, contrast=<factor>")
+    fmt.Println("  -augment-seed <n>  Seed for the random augmentations (default: 1)")
+    fmt.Println("  -run-manifest <file> Write version, commit, config/weights hashes, engine and host to <file>")
     fmt.Println("  -version           Show version information")
     fmt.Println("  -help              Show this help message")
     
@@ -288,20 +1023,119 @@ func printHelp() {
     fmt.Printf("  %s -weights ./weights -images ./test_images -labels ./test_labels \\\n", AppName)
     fmt.Printf("    -samples 1000 -workers 8 -verbose -matrix -output results.json\n\n")
     
+    fmt.Printf("  # Evaluate a folder-per-class dataset (any image size; see data.resize in the config)\n")
+    fmt.Printf("  %s -weights ./weights -dataset ./cifar10/test -samples 1000\n\n", AppName)
+
+    fmt.Printf("  # Evaluate a TensorFlow CIFAR-10 TFRecord file\n")
+    fmt.Printf("  %s -weights ./weights -dataset ./cifar10-test.tfrecord -samples 1000\n\n", AppName)
+    
+    fmt.Printf("  # Error analysis: list the misclassified images and sort them by true class\n")
+    fmt.Printf("  %s -weights ./weights -dataset ./cifar10/test \\\n", AppName)
+    fmt.Printf("    -misclassified errors.csv -misclassified-images errors\n\n")
+    
+    fmt.Printf("  # Robustness to sensor noise and underexposure\n")
+    fmt.Printf("  %s -weights ./weights -images ./test_images -labels ./test_labels \\\n", AppName)
+    fmt.Printf("    -augment noise=0.05,brightness=-0.2\n\n")
+    
+    fmt.Printf("  # Catch memory regressions: allocations per inference and layer, peak heap\n")
+    fmt.Printf("  %s -weights ./weights -images ./test_images -labels ./test_labels \\\n", AppName)
+    fmt.Printf("    -workers 1 -track-memory -memory-sample 10ms\n\n")
+    
+    fmt.Printf("  # CI gate: fail if accuracy or p95 latency is more than 1%% worse than the stored run\n")
+    fmt.Printf("  %s -weights ./weights -images ./test_images -labels ./test_labels \\\n", AppName)
+    fmt.Printf("    -format json -output current.json -baseline baseline.json -max-regression 1%%\n\n")
+    
     fmt.Printf("  # Performance profiling\n")
     fmt.Printf("  %s -weights ./weights -images ./test_images -labels ./test_labels \\\n", AppName)
     fmt.Printf("    -cpuprofile cpu.prof -memprofile mem.prof\n\n")
     
+    fmt.Printf("  # Profile a long run while it is going (go tool pprof http://localhost:6060/debug/pprof/profile)\n")
+    fmt.Printf("  %s -weights ./weights -dataset ./cifar10/test -samples 10000 -pprof-addr localhost:6060\n\n", AppName)
+    
+    fmt.Printf("  # Compact activation dump of the first conv layers (samples follow image order with -workers 1)\n")
+    fmt.Printf("  %s -weights ./weights -images ./test_images -labels ./test_labels \\\n", AppName)
+    fmt.Printf("    -workers 1 -dump-activations dump -dump-layers conv1,conv2 -dump-precision float16 -dump-compress zstd\n\n")
+    
+    fmt.Printf("  # Activation ranges and dead ReLU channels over 1000 images\n")
+    fmt.Printf("  %s -weights ./weights -dataset ./cifar10/test -samples 1000 -activation-stats stats.json\n\n", AppName)
+    
+    fmt.Printf("  # Embeddings of 2000 test images for a t-SNE/UMAP view in the Embedding Projector\n")
+    fmt.Printf("  %s -weights ./weights -dataset ./cifar10/test -samples 2000 -embeddings ./projector\n\n", AppName)
+    
+    fmt.Printf("  # Find the worker count this machine scales to\n")
+    fmt.Printf("  %s -weights ./weights -images ./test_images -labels ./test_labels \\\n", AppName)
+    fmt.Printf("    -samples 200 -sweep-workers 1,2,4,8,16\n\n")
+    
+    fmt.Printf("  # Side-by-side diff of two trainings of the same model\n")
+    fmt.Printf("  %s -weights ./weights-v1 -images ./test_images -labels ./test_labels \\\n", AppName)
+    fmt.Printf("    -compare ./weights-v2 -samples 1000\n\n")
+    
+    fmt.Printf("  # Accuracy cost of an int8 bundle made by gocnn-quantize\n")
+    fmt.Printf("  %s -weights ./weights -images ./test_images -labels ./test_labels \\\n", AppName)
+    fmt.Printf("    -compare-quantized ./weights-int8 -format csv -output comparison.csv\n\n")
+    
     fmt.Println("METRICS COMPUTED:")
     fmt.Println("  - Top-1 Accuracy (primary metric)")
-    fmt.Println("  - Top-5 Accuracy")
-    fmt.Println("  - Per-class Accuracy")
+    fmt.Println("  - Top-K Accuracy for every K of -topk")
+    fmt.Println("  - Per-class Accuracy, Precision, Recall and F1")
+    fmt.Println("  - Macro, Micro and Support-weighted Averages of Precision, Recall and F1")
+    fmt.Println("  - Matthews Correlation Coefficient and Cohen's Kappa")
+    fmt.Println("  - One-vs-rest ROC Curves and AUC (per class, macro and micro averaged)")
+    fmt.Println("  - Calibration: expected/maximum calibration error and a reliability diagram")
     fmt.Println("  - Confusion Matrix")
-    fmt.Println("  - Inference Timing Statistics")
+    fmt.Println("  - Inference Timing Statistics (min/avg/max and p50/p90/p95/p99 latency)")
     fmt.Println("  - Throughput (samples/second)")
     
+    fmt.Println("\nENVIRONMENT:")
+    fmt.Println("  GOCNN_ENGINE          Default for -engine")
+    fmt.Println("  GOCNN_ENGINE_WORKERS  Default for -engine-workers")
+    fmt.Println("  GOCNN_ENGINE_POOL     Default for -engine-pool")
+    
     fmt.Println("\nOUTPUT FORMATS:")
     fmt.Println("  text - Human-readable console output")
     fmt.Println("  csv  - Comma-separated values for analysis")
     fmt.Println("  json - Structured JSON for programmatic use")
+    
+    fmt.Println("\nPORCELAIN OUTPUT (-porcelain, one tab-separated record per line, durations in ns):")
+    fmt.Println("  porcelain    <format version> <tool> <tool version>")
+    fmt.Println("  engine       <engine settings>")
+    fmt.Println("  run          <tool version> <git commit> <git dirty> <config hash> <weights hash>")
+    fmt.Println("  summary      <samples> <correct> <top-1 accuracy> <top-5 accuracy> <evaluation time>")
+    fmt.Println("  topk         <k> <top-k accuracy>  (one per -topk value)")
+    fmt.Println("  timing       <total> <average> <min> <max> <samples/sec> <p50> <p90> <p95> <p99>")
+    fmt.Println("  cold         <samples> <total> <average> <min> <max>  (with -warmup-samples)")
+    fmt.Println("  memory       <average bytes/inference> <max bytes/inference> <average objects/inference>")
+    fmt.Println("               <total bytes> <total objects> <gc cycles> <gc pause> <start heap> <peak heap>")
+    fmt.Println("               <heap samples>  (with -track-memory)")
+    fmt.Println("  peak         <gflop/s> <bandwidth GB/s> <ridge point flop/byte>  (with -roofline)")
+    fmt.Println("  roofline     <layer> <flops> <param bytes> <flop/byte> <average time> <gflop/s> <attainable gflop/s>")
+    fmt.Println("               <efficiency> <compute|memory>  (with -roofline)")
+    fmt.Println("  layer_alloc  <layer> <average bytes/inference> <average objects/inference>  (with -track-memory)")
+    fmt.Println("  average      <macro|micro|weighted> <precision> <recall> <f1>  (one per -average mode)")
+    fmt.Println("  agreement    <matthews correlation> <cohen's kappa>")
+    fmt.Println("  auc          <macro AUC> <micro AUC>")
+    fmt.Println("  calibration  <expected calibration error> <max calibration error>")
+    fmt.Println("  reliability  <bin lower> <bin upper> <samples> <accuracy> <mean confidence>")
+    fmt.Println("  class        <class> <class name> <accuracy> <precision> <recall> <f1> <auc>")
+    fmt.Println("  confusion    <true class> <count predicted as class 0> <... class 1> ...")
+    fmt.Println("  layer_time   <layer> <total time>")
+    fmt.Println("  baseline     <baseline samples> <samples>  (with -baseline)")
+    fmt.Println("  regression   <top1_accuracy|p95_latency> <baseline> <current> <regression> <limit> <regressed>")
+    fmt.Println("               (with -baseline; accuracy as a fraction, latency in seconds)")
+    fmt.Println("  With -compare or -compare-quantized, summary through layer_time are replaced by:")
+    fmt.Println("  comparison   <samples> <first top-1> <second top-1> <top-1 delta> <first top-5> <second top-5>")
+    fmt.Println("               <top-5 delta> <agreement> <evaluation time>  (deltas are second minus first)")
+    fmt.Println("  mcnemar      <second-only correct> <first-only correct> <chi-squared> <p-value> <exact>")
+    fmt.Println("  class_delta  <class> <class name> <first accuracy> <second accuracy> <delta> <wins> <losses>")
+    fmt.Println("  latency_delta <first average> <second average> <delta> <first p99> <second p99> <p99 delta>")
+    fmt.Println("  disagreement <first model's class> <count predicted as class 0 by the second> <... class 1> ...")
+    fmt.Println("  layer_error  <layer> <mse> <max abs error> <sqnr dB>")
+    fmt.Println("  With -sweep-workers, all records after the header are replaced by:")
+    fmt.Println("  sweep        <workers> <wall time> <samples/sec> <speedup> <efficiency> <average> <p99> <top-1>")
+    fmt.Println("  recommended_workers <workers>")
+
+    fmt.Println("\nEXIT STATUS:")
+    fmt.Println("  0  success")
+    fmt.Println("  1  error")
+    fmt.Printf("  %d  -baseline found a regression beyond -max-regression\n", exitRegressed)
 }
\ No newline at end of file
@@ -2,9 +2,15 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	httppprof "net/http/pprof"
 	"os"
 	"runtime"
 	"runtime/pprof"
+
+	"duchm1606/gocnn/internal/errs"
 )
 
 var cpuProfile *os.File
@@ -35,6 +41,27 @@ func stopCPUProfile() {
     }
 }
 
+// servePprof serves the net/http/pprof handlers under /debug/pprof/ at addr in the
+// background until the returned function is called
+func servePprof(addr string) (func(), error) {
+    listener, err := net.Listen("tcp", addr)
+    if err != nil {
+        return nil, errs.WithHint(fmt.Errorf("failed to serve pprof: %w", err),
+            "pick a free address with -pprof-addr, e.g. -pprof-addr localhost:6061")
+    }
+    mux := http.NewServeMux()
+    mux.HandleFunc("/debug/pprof/", httppprof.Index)
+    mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+    mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+    mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+    mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+    srv := &http.Server{Handler: mux}
+    go srv.Serve(listener)
+
+    slog.Info("serving pprof", "url", fmt.Sprintf("http://%s/debug/pprof/", listener.Addr()))
+    return func() { srv.Close() }, nil
+}
+
 // writeMemProfile writes memory profile to file
 func writeMemProfile(filename string) error {
     file, err := os.Create(filename)
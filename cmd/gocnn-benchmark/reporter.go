@@ -2,10 +2,15 @@ package main
 
 import (
 	"duchm1606/gocnn/internal/metrics"
+	"duchm1606/gocnn/internal/porcelain"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -13,6 +18,12 @@ import (
 type Reporter struct {
     format     string
     classNames []string
+    averaging  []metrics.Averaging
+    
+    comparison string    // What a comparison report compares, for its title
+    modelNames [2]string // The two models of a comparison report
+
+    regression *metrics.RegressionReport // Check against -baseline, shown after the metrics
 }
 
 // NewReporter creates a new reporter
@@ -20,9 +31,30 @@ func NewReporter(format string, classNames []string) *Reporter {
     return &Reporter{
         format:     format,
         classNames: classNames,
+        averaging:  metrics.AveragingModes,
+        comparison: "Quantization",
+        modelNames: [2]string{"Float", "Quantized"},
     }
 }
 
+// SetModelNames labels the first and second model of comparison reports, which
+// compare float and quantized weights otherwise
+func (r *Reporter) SetModelNames(first, second string) {
+    r.comparison = "Model"
+    r.modelNames = [2]string{first, second}
+}
+
+// SetRegression adds the outcome of a -baseline check to evaluation reports
+func (r *Reporter) SetRegression(report *metrics.RegressionReport) {
+    r.regression = report
+}
+
+// SetAveraging selects the averages of precision, recall and F1 the text, CSV and
+// porcelain reports show; JSON reports have them all
+func (r *Reporter) SetAveraging(modes []metrics.Averaging) {
+    r.averaging = modes
+}
+
 // GenerateReport generates and outputs the evaluation report
 func (r *Reporter) GenerateReport(result *metrics.EvaluationResult, evalTime time.Duration, outputPath string) error {
     switch r.format {
@@ -32,6 +64,8 @@ func (r *Reporter) GenerateReport(result *metrics.EvaluationResult, evalTime tim
         return r.generateCSVReport(result, outputPath)
     case "json":
         return r.generateJSONReport(result, outputPath)
+    case "porcelain":
+        return r.generatePorcelainReport(result, evalTime, outputPath)
     default:
         return fmt.Errorf("unsupported format: %s", r.format)
     }
@@ -56,14 +90,29 @@ func (r *Reporter) generateTextReport(result *metrics.EvaluationResult, evalTime
     fmt.Fprintf(output, "TinyCNN Evaluation Report\n")
     fmt.Fprintf(output, "=========================\n\n")
     fmt.Fprintf(output, "Generated: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-    fmt.Fprintf(output, "Evaluation Time: %v\n\n", evalTime)
+    fmt.Fprintf(output, "Evaluation Time: %v\n", evalTime)
+    fmt.Fprintf(output, "Engine: %s\n", result.Engine)
+    if result.Run != nil {
+        fmt.Fprintf(output, "Run: %s\n", result.Run.Summary())
+        fmt.Fprintf(output, "Host: %s\n", result.Run.HostSummary())
+    }
+    fmt.Fprintf(output, "\n")
     
     // Overall metrics
     fmt.Fprintf(output, "Overall Performance:\n")
     fmt.Fprintf(output, "  Total Samples: %d\n", result.TotalSamples)
     fmt.Fprintf(output, "  Correct Predictions: %d\n", result.CorrectPredictions)
     fmt.Fprintf(output, "  Top-1 Accuracy: %.4f (%.2f%%)\n", result.Top1Accuracy, result.Top1Accuracy*100)
-    fmt.Fprintf(output, "  Top-5 Accuracy: %.4f (%.2f%%)\n", result.Top5Accuracy, result.Top5Accuracy*100)
+    for _, topK := range result.TopKAccuracies {
+        if topK.K > 1 {
+            fmt.Fprintf(output, "  Top-%d Accuracy: %.4f (%.2f%%)\n", topK.K, topK.Accuracy, topK.Accuracy*100)
+        }
+    }
+    fmt.Fprintf(output, "  Matthews Correlation: %.4f\n", result.MCC)
+    fmt.Fprintf(output, "  Cohen's Kappa: %.4f\n", result.CohensKappa)
+    fmt.Fprintf(output, "  ROC AUC: %.4f macro, %.4f micro (one-vs-rest)\n", result.MacroAUC, result.MicroAUC)
+    fmt.Fprintf(output, "  Calibration Error: %.4f expected, %.4f maximum\n",
+        result.ExpectedCalibrationError, result.MaxCalibrationError)
     fmt.Fprintf(output, "\n")
     
     // Timing metrics
@@ -73,24 +122,46 @@ func (r *Reporter) generateTextReport(result *metrics.EvaluationResult, evalTime
         fmt.Fprintf(output, "  Average Inference Time: %v\n", result.AverageInferenceTime)
         fmt.Fprintf(output, "  Min Inference Time: %v\n", result.MinInferenceTime)
         fmt.Fprintf(output, "  Max Inference Time: %v\n", result.MaxInferenceTime)
+        fmt.Fprintf(output, "  Latency Percentiles: p50 %v, p90 %v, p95 %v, p99 %v\n",
+            result.P50InferenceTime, result.P90InferenceTime, result.P95InferenceTime, result.P99InferenceTime)
         fmt.Fprintf(output, "  Throughput: %.2f samples/second\n", result.Throughput)
+        if cold := result.Cold; cold != nil {
+            fmt.Fprintf(output, "  Cold Start (first %d samples, excluded above): average %v, min %v, max %v\n",
+                cold.Samples, cold.AverageInferenceTime, cold.MinInferenceTime, cold.MaxInferenceTime)
+        }
         fmt.Fprintf(output, "\n")
+        r.writeLatencyHistogram(output, result.LatencyHistogram)
+    }
+    if result.Memory != nil {
+        writeMemory(output, result.Memory)
+    }
+    if result.Roofline != nil {
+        writeRoofline(output, result.Roofline)
     }
     
     // Per-class metrics
     fmt.Fprintf(output, "Per-Class Performance:\n")
-    fmt.Fprintf(output, "  Class           Accuracy  Precision   Recall     F1-Score\n")
-    fmt.Fprintf(output, "  --------------------------------------------------------\n")
+    fmt.Fprintf(output, "  Class           Accuracy  Precision   Recall     F1-Score   AUC\n")
+    fmt.Fprintf(output, "  --------------------------------------------------------------\n")
     
-    for i, className := range r.classNames {
-        if i < len(result.ClassAccuracies) {
-            fmt.Fprintf(output, "  %-13s   %.4f     %.4f     %.4f     %.4f\n",
-                className,
-                result.ClassAccuracies[i],
-                result.ClassPrecisions[i],
-                result.ClassRecalls[i],
-                result.ClassF1Scores[i])
-        }
+    for i := range result.ClassAccuracies {
+        fmt.Fprintf(output, "  %-13s   %.4f     %.4f     %.4f     %.4f     %s\n",
+            r.displayName(i),
+            result.ClassAccuracies[i],
+            result.ClassPrecisions[i],
+            result.ClassRecalls[i],
+            result.ClassF1Scores[i],
+            aucString(result.ROCCurves[i], "%.4f"))
+    }
+    fmt.Fprintf(output, "\n")
+    
+    // Averaged metrics
+    fmt.Fprintf(output, "Averaged Metrics:\n")
+    fmt.Fprintf(output, "  Average         Precision   Recall     F1-Score\n")
+    fmt.Fprintf(output, "  ----------------------------------------------\n")
+    for _, mode := range r.averaging {
+        avg := result.Averaged(mode)
+        fmt.Fprintf(output, "  %-13s   %.4f      %.4f     %.4f\n", mode, avg.Precision, avg.Recall, avg.F1)
     }
     fmt.Fprintf(output, "\n")
     
@@ -101,26 +172,35 @@ func (r *Reporter) generateTextReport(result *metrics.EvaluationResult, evalTime
         fmt.Fprintf(output, "T ↓   ")
         
         // Header
-        for i := 0; i < len(r.classNames) && i < len(result.ConfusionMatrix); i++ {
+        for i := range result.ConfusionMatrix {
             fmt.Fprintf(output, "%8s", fmt.Sprintf("C%d", i))
         }
         fmt.Fprintf(output, "\n")
         
         // Matrix rows
         for i, row := range result.ConfusionMatrix {
-            if i < len(r.classNames) {
-                fmt.Fprintf(output, "C%-2d ", i)
-                for j, count := range row {
-                    if j < len(r.classNames) {
-                        fmt.Fprintf(output, "%8d", count)
-                    }
-                }
-                fmt.Fprintf(output, "  (%s)\n", r.classNames[i])
+            fmt.Fprintf(output, "C%-2d ", i)
+            for _, count := range row {
+                fmt.Fprintf(output, "%8d", count)
             }
+            fmt.Fprintf(output, "  (%s)\n", r.displayName(i))
         }
         fmt.Fprintf(output, "\n")
     }
     
+    // Reliability diagram
+    fmt.Fprintf(output, "Reliability (top-1 confidence bins):\n")
+    fmt.Fprintf(output, "  Confidence      Samples  Accuracy  Mean Conf.      Gap\n")
+    fmt.Fprintf(output, "  -----------------------------------------------------\n")
+    for _, bin := range result.Reliability {
+        if bin.Count == 0 {
+            continue
+        }
+        fmt.Fprintf(output, "  %.2f - %.2f   %9d    %.4f      %.4f  %+.4f\n",
+            bin.Lower, bin.Upper, bin.Count, bin.Accuracy, bin.Confidence, bin.Accuracy-bin.Confidence)
+    }
+    fmt.Fprintf(output, "\n")
+    
     // Summary statistics
     avgAccuracy := 0.0
     for _, acc := range result.ClassAccuracies {
@@ -132,6 +212,11 @@ func (r *Reporter) generateTextReport(result *metrics.EvaluationResult, evalTime
     fmt.Fprintf(output, "  Average Class Accuracy: %.4f (%.2f%%)\n", avgAccuracy, avgAccuracy*100)
     fmt.Fprintf(output, "  Standard Deviation: %.4f\n", r.computeStdDev(result.ClassAccuracies, avgAccuracy))
     
+    if r.regression != nil {
+        fmt.Fprintf(output, "\n")
+        writeRegression(output, r.regression)
+    }
+    
     if outputPath != "" {
         fmt.Printf("Text report saved to: %s\n", outputPath)
     }
@@ -159,21 +244,132 @@ func (r *Reporter) generateCSVReport(result *metrics.EvaluationResult, outputPat
     writer.Write([]string{"Total Samples", fmt.Sprintf("%d", result.TotalSamples)})
     writer.Write([]string{"Correct Predictions", fmt.Sprintf("%d", result.CorrectPredictions)})
     writer.Write([]string{"Top-1 Accuracy", fmt.Sprintf("%.6f", result.Top1Accuracy)})
-    writer.Write([]string{"Top-5 Accuracy", fmt.Sprintf("%.6f", result.Top5Accuracy)})
+    for _, topK := range result.TopKAccuracies {
+        if topK.K > 1 {
+            writer.Write([]string{fmt.Sprintf("Top-%d Accuracy", topK.K), fmt.Sprintf("%.6f", topK.Accuracy)})
+        }
+    }
+    writer.Write([]string{"MCC", fmt.Sprintf("%.6f", result.MCC)})
+    writer.Write([]string{"Cohen's Kappa", fmt.Sprintf("%.6f", result.CohensKappa)})
+    writer.Write([]string{"Macro AUC", fmt.Sprintf("%.6f", result.MacroAUC)})
+    writer.Write([]string{"Micro AUC", fmt.Sprintf("%.6f", result.MicroAUC)})
+    writer.Write([]string{"Expected Calibration Error", fmt.Sprintf("%.6f", result.ExpectedCalibrationError)})
+    writer.Write([]string{"Max Calibration Error", fmt.Sprintf("%.6f", result.MaxCalibrationError)})
     writer.Write([]string{"Throughput", fmt.Sprintf("%.6f", result.Throughput)})
+    writer.Write([]string{"Average Inference Time (ms)", durationMs(result.AverageInferenceTime)})
+    writer.Write([]string{"P50 Inference Time (ms)", durationMs(result.P50InferenceTime)})
+    writer.Write([]string{"P90 Inference Time (ms)", durationMs(result.P90InferenceTime)})
+    writer.Write([]string{"P95 Inference Time (ms)", durationMs(result.P95InferenceTime)})
+    writer.Write([]string{"P99 Inference Time (ms)", durationMs(result.P99InferenceTime)})
+    if cold := result.Cold; cold != nil {
+        writer.Write([]string{"Cold Samples", fmt.Sprintf("%d", cold.Samples)})
+        writer.Write([]string{"Cold Average Inference Time (ms)", durationMs(cold.AverageInferenceTime)})
+        writer.Write([]string{"Cold Max Inference Time (ms)", durationMs(cold.MaxInferenceTime)})
+    }
+    if mem := result.Memory; mem != nil {
+        writer.Write([]string{"Average Alloc Bytes per Inference", fmt.Sprintf("%d", mem.AverageAllocBytes)})
+        writer.Write([]string{"Max Alloc Bytes per Inference", fmt.Sprintf("%d", mem.MaxAllocBytes)})
+        writer.Write([]string{"Average Allocs per Inference", fmt.Sprintf("%d", mem.AverageAllocs)})
+        writer.Write([]string{"Total Alloc Bytes", fmt.Sprintf("%d", mem.TotalAllocBytes)})
+        writer.Write([]string{"GC Cycles", fmt.Sprintf("%d", mem.GCCycles)})
+        writer.Write([]string{"GC Pause (ms)", durationMs(mem.GCPause)})
+        writer.Write([]string{"Peak Heap Bytes", fmt.Sprintf("%d", mem.PeakHeap)})
+    }
+    writer.Write([]string{"Engine", result.Engine.String()})
+    if run := result.Run; run != nil {
+        writer.Write([]string{"Version", run.Tool + " " + run.Version})
+        writer.Write([]string{"Git Commit", run.ShortCommit()})
+        writer.Write([]string{"Config Hash", run.ConfigHash})
+        writer.Write([]string{"Weights Hash", run.WeightsHash})
+        writer.Write([]string{"Host", run.HostSummary()})
+    }
     writer.Write([]string{""}) // Empty row
     
+    if roofline := result.Roofline; roofline != nil {
+        writer.Write([]string{"Peak GFLOP/s", fmt.Sprintf("%.6f", roofline.Peak.GFLOPS)})
+        writer.Write([]string{"Peak Bandwidth (GB/s)", fmt.Sprintf("%.6f", roofline.Peak.BandwidthGBs)})
+        writer.Write([]string{"Ridge Point (FLOP/byte)", fmt.Sprintf("%.6f", roofline.RidgePoint)})
+        writer.Write([]string{"Layer", "FLOPs", "Param Bytes", "FLOP/byte", "Average Time (ms)", "GFLOP/s",
+            "Attainable GFLOP/s", "Efficiency", "Bound"})
+        for _, layer := range roofline.Layers {
+            writer.Write([]string{layer.Layer, fmt.Sprintf("%d", layer.FLOPs), fmt.Sprintf("%d", layer.ParamBytes),
+                fmt.Sprintf("%.6f", layer.ArithmeticIntensity), durationMs(layer.AverageTime), fmt.Sprintf("%.6f", layer.GFLOPS),
+                fmt.Sprintf("%.6f", layer.AttainableGFLOPS), fmt.Sprintf("%.6f", layer.Efficiency), boundName(layer)})
+        }
+        writer.Write([]string{""}) // Empty row
+    }
+    
+    if regression := r.regression; regression != nil {
+        writer.Write([]string{"Regression Metric", "Baseline", "Current", "Regression", "Limit", "Regressed"})
+        for _, check := range regression.Checks {
+            writer.Write([]string{check.Metric, fmt.Sprintf("%.6f", check.Baseline), fmt.Sprintf("%.6f", check.Current),
+                fmt.Sprintf("%.6f", check.Regression), fmt.Sprintf("%.6f", check.Limit), fmt.Sprintf("%t", check.Regressed)})
+        }
+        writer.Write([]string{""}) // Empty row
+    }
+    
     // Write per-class metrics
-    writer.Write([]string{"Class", "Accuracy", "Precision", "Recall", "F1-Score"})
-    for i, className := range r.classNames {
-        if i < len(result.ClassAccuracies) {
-            writer.Write([]string{
-                className,
-                fmt.Sprintf("%.6f", result.ClassAccuracies[i]),
-                fmt.Sprintf("%.6f", result.ClassPrecisions[i]),
-                fmt.Sprintf("%.6f", result.ClassRecalls[i]),
-                fmt.Sprintf("%.6f", result.ClassF1Scores[i]),
-            })
+    writer.Write([]string{"Class", "Accuracy", "Precision", "Recall", "F1-Score", "AUC"})
+    for i := range result.ClassAccuracies {
+        writer.Write([]string{
+            r.displayName(i),
+            fmt.Sprintf("%.6f", result.ClassAccuracies[i]),
+            fmt.Sprintf("%.6f", result.ClassPrecisions[i]),
+            fmt.Sprintf("%.6f", result.ClassRecalls[i]),
+            fmt.Sprintf("%.6f", result.ClassF1Scores[i]),
+            aucString(result.ROCCurves[i], "%.6f"),
+        })
+    }
+    writer.Write([]string{""}) // Empty row
+    
+    // Write the averaged metrics
+    writer.Write([]string{"Average", "Precision", "Recall", "F1-Score"})
+    for _, mode := range r.averaging {
+        avg := result.Averaged(mode)
+        writer.Write([]string{
+            string(mode),
+            fmt.Sprintf("%.6f", avg.Precision),
+            fmt.Sprintf("%.6f", avg.Recall),
+            fmt.Sprintf("%.6f", avg.F1),
+        })
+    }
+    writer.Write([]string{""}) // Empty row
+    
+    // Write the ROC curves, the micro-averaged one as class "micro"
+    writer.Write([]string{"ROC Class", "FPR", "TPR", "Threshold"})
+    for i, curve := range result.ROCCurves {
+        r.writeROCPoints(writer, r.displayName(i), curve.Points)
+    }
+    r.writeROCPoints(writer, "micro", result.MicroROC)
+    writer.Write([]string{""}) // Empty row
+    
+    // Write the reliability diagram
+    writer.Write([]string{"Bin Lower", "Bin Upper", "Samples", "Accuracy", "Mean Confidence"})
+    for _, bin := range result.Reliability {
+        writer.Write([]string{
+            fmt.Sprintf("%.6f", bin.Lower),
+            fmt.Sprintf("%.6f", bin.Upper),
+            fmt.Sprintf("%d", bin.Count),
+            fmt.Sprintf("%.6f", bin.Accuracy),
+            fmt.Sprintf("%.6f", bin.Confidence),
+        })
+    }
+    writer.Write([]string{""}) // Empty row
+    
+    // Write the latency histogram and cumulative distribution
+    writer.Write([]string{"Bucket Lower (ms)", "Bucket Upper (ms)", "Samples"})
+    for _, bucket := range result.LatencyHistogram.Buckets {
+        upper := ""
+        if bucket.Upper > 0 {
+            upper = durationMs(bucket.Upper)
+        }
+        writer.Write([]string{durationMs(bucket.Lower), upper, fmt.Sprintf("%d", bucket.Count)})
+    }
+    if distribution := result.LatencyHistogram.Distribution; len(distribution) > 0 {
+        writer.Write([]string{""}) // Empty row
+        writer.Write([]string{"Percentile", "Latency (ms)", "Samples"})
+        for _, q := range distribution {
+            writer.Write([]string{fmt.Sprintf("%.6f", q.Percentile), durationMs(q.Latency), fmt.Sprintf("%d", q.Count)})
         }
     }
     
@@ -195,8 +391,10 @@ func (r *Reporter) generateJSONReport(result *metrics.EvaluationResult, outputPa
             ClassNames  []string  `json:"class_names"`
             Format      string    `json:"format"`
         } `json:"metadata"`
+        Regression *metrics.RegressionReport `json:"regression,omitempty"`
     }{
         EvaluationResult: result,
+        Regression:       r.regression,
     }
     
     enhancedResult.Metadata.GeneratedAt = time.Now()
@@ -221,6 +419,736 @@ func (r *Reporter) generateJSONReport(result *metrics.EvaluationResult, outputPa
     return nil
 }
 
+// generatePorcelainReport writes the results as stable tab-separated records (see printHelp)
+func (r *Reporter) generatePorcelainReport(result *metrics.EvaluationResult, evalTime time.Duration, outputPath string) error {
+    output := os.Stdout
+    if outputPath != "" {
+        file, err := os.Create(outputPath)
+        if err != nil {
+            return fmt.Errorf("failed to create output file: %w", err)
+        }
+        defer file.Close()
+        output = file
+    }
+    
+    records := porcelain.NewWriter(output)
+    records.Begin(AppName, AppVersion)
+    records.Record("engine", result.Engine)
+    if run := result.Run; run != nil {
+        records.Record("run", run.Version, run.GitCommit, run.GitDirty, run.ConfigHash, run.WeightsHash)
+    }
+    records.Record("summary", result.TotalSamples, result.CorrectPredictions, result.Top1Accuracy, result.Top5Accuracy, evalTime)
+    for _, topK := range result.TopKAccuracies {
+        records.Record("topk", topK.K, topK.Accuracy)
+    }
+    records.Record("timing", result.TotalInferenceTime, result.AverageInferenceTime,
+        result.MinInferenceTime, result.MaxInferenceTime, result.Throughput,
+        result.P50InferenceTime, result.P90InferenceTime, result.P95InferenceTime, result.P99InferenceTime)
+    if cold := result.Cold; cold != nil {
+        records.Record("cold", cold.Samples, cold.TotalInferenceTime, cold.AverageInferenceTime,
+            cold.MinInferenceTime, cold.MaxInferenceTime)
+    }
+    if mem := result.Memory; mem != nil {
+        records.Record("memory", mem.AverageAllocBytes, mem.MaxAllocBytes, mem.AverageAllocs,
+            mem.TotalAllocBytes, mem.Mallocs, mem.GCCycles, mem.GCPause, mem.HeapStart, mem.PeakHeap, mem.HeapSamples)
+        for _, layer := range mem.SortedLayerAllocs() {
+            records.Record("layer_alloc", layer, mem.LayerAllocs[layer].Bytes, mem.LayerAllocs[layer].Objects)
+        }
+    }
+    if roofline := result.Roofline; roofline != nil {
+        records.Record("peak", roofline.Peak.GFLOPS, roofline.Peak.BandwidthGBs, roofline.RidgePoint)
+        for _, layer := range roofline.Layers {
+            records.Record("roofline", layer.Layer, layer.FLOPs, layer.ParamBytes, layer.ArithmeticIntensity,
+                layer.AverageTime, layer.GFLOPS, layer.AttainableGFLOPS, layer.Efficiency, boundName(layer))
+        }
+    }
+    
+    for _, mode := range r.averaging {
+        avg := result.Averaged(mode)
+        records.Record("average", string(mode), avg.Precision, avg.Recall, avg.F1)
+    }
+    records.Record("agreement", result.MCC, result.CohensKappa)
+    records.Record("auc", result.MacroAUC, result.MicroAUC)
+    records.Record("calibration", result.ExpectedCalibrationError, result.MaxCalibrationError)
+    for _, bin := range result.Reliability {
+        records.Record("reliability", bin.Lower, bin.Upper, bin.Count, bin.Accuracy, bin.Confidence)
+    }
+    for i := range result.ClassAccuracies {
+        records.Record("class", i, r.className(i), result.ClassAccuracies[i],
+            result.ClassPrecisions[i], result.ClassRecalls[i], result.ClassF1Scores[i], result.ROCCurves[i].AUC)
+    }
+    
+    for i, row := range result.ConfusionMatrix {
+        fields := make([]any, 0, len(row)+1)
+        fields = append(fields, i)
+        for _, count := range row {
+            fields = append(fields, count)
+        }
+        records.Record("confusion", fields...)
+    }
+    
+    layers := make([]string, 0, len(result.LayerTimings))
+    for layer := range result.LayerTimings {
+        layers = append(layers, layer)
+    }
+    sort.Strings(layers)
+    for _, layer := range layers {
+        records.Record("layer_time", layer, result.LayerTimings[layer])
+    }
+    
+    if regression := r.regression; regression != nil {
+        records.Record("baseline", regression.BaselineSamples, regression.CurrentSamples)
+        for _, check := range regression.Checks {
+            records.Record("regression", check.Metric, check.Baseline, check.Current, check.Regression, check.Limit, check.Regressed)
+        }
+    }
+    
+    return records.Flush()
+}
+
+// GenerateComparisonReport generates and outputs the report comparing two models
+func (r *Reporter) GenerateComparisonReport(result *metrics.ComparisonResult, evalTime time.Duration, outputPath string) error {
+    switch r.format {
+    case "text":
+        return r.generateComparisonTextReport(result, evalTime, outputPath)
+    case "csv":
+        return r.generateComparisonCSVReport(result, outputPath)
+    case "json":
+        return r.generateComparisonJSONReport(result, outputPath)
+    case "porcelain":
+        return r.generateComparisonPorcelainReport(result, evalTime, outputPath)
+    default:
+        return fmt.Errorf("unsupported format: %s", r.format)
+    }
+}
+
+// generateComparisonTextReport generates a human-readable comparison report
+func (r *Reporter) generateComparisonTextReport(result *metrics.ComparisonResult, evalTime time.Duration, outputPath string) error {
+    var output *os.File
+    var err error
+    
+    if outputPath != "" {
+        output, err = os.Create(outputPath)
+        if err != nil {
+            return fmt.Errorf("failed to create output file: %w", err)
+        }
+        defer output.Close()
+    } else {
+        output = os.Stdout
+    }
+    
+    a, b := result.A, result.B
+    first, second := r.modelNames[0], r.modelNames[1]
+    
+    title := fmt.Sprintf("TinyCNN %s Comparison Report", r.comparison)
+    fmt.Fprintf(output, "%s\n%s\n\n", title, strings.Repeat("=", len(title)))
+    fmt.Fprintf(output, "Generated: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+    fmt.Fprintf(output, "Evaluation Time: %v\n", evalTime)
+    fmt.Fprintf(output, "Engine: %s\n", a.Engine)
+    if a.Run != nil {
+        fmt.Fprintf(output, "%s Run: %s\n", first, a.Run.Summary())
+    }
+    if b.Run != nil {
+        fmt.Fprintf(output, "%s Run: %s\n", second, b.Run.Summary())
+        fmt.Fprintf(output, "Host: %s\n", b.Run.HostSummary())
+    }
+    fmt.Fprintf(output, "\n")
+    
+    fmt.Fprintf(output, "Overall Accuracy (%d samples):\n", a.TotalSamples)
+    fmt.Fprintf(output, "                  %10s  %10s      Change\n", first, second)
+    fmt.Fprintf(output, "  Top-1 Accuracy      %.4f      %.4f   %+6.2f pts\n",
+        a.Top1Accuracy, b.Top1Accuracy, result.Top1Delta*100)
+    fmt.Fprintf(output, "  Top-5 Accuracy      %.4f      %.4f   %+6.2f pts\n",
+        a.Top5Accuracy, b.Top5Accuracy, result.Top5Delta*100)
+    fmt.Fprintf(output, "  Prediction Agreement: %.4f (%.2f%%)\n", result.Agreement, result.Agreement*100)
+    fmt.Fprintf(output, "  McNemar's Test: %d %s-only correct, %d %s-only correct, %s\n",
+        result.McNemar.Wins, second, result.McNemar.Losses, first, mcnemarString(result.McNemar))
+    if *showTiming {
+        fmt.Fprintf(output, "  Average Inference Time: %v %s, %v %s (%+v)\n",
+            a.AverageInferenceTime, first, b.AverageInferenceTime, second, result.LatencyDelta)
+        fmt.Fprintf(output, "  P99 Inference Time: %v %s, %v %s (%+v)\n",
+            a.P99InferenceTime, first, b.P99InferenceTime, second, result.P99LatencyDelta)
+        if a.Cold != nil && b.Cold != nil {
+            fmt.Fprintf(output, "  Cold Start (first %d samples): %v %s, %v %s\n",
+                a.Cold.Samples, a.Cold.AverageInferenceTime, first, b.Cold.AverageInferenceTime, second)
+        }
+    }
+    fmt.Fprintf(output, "\n")
+    
+    fmt.Fprintf(output, "Per-Class Accuracy:\n")
+    fmt.Fprintf(output, "  Class       %10s  %10s     Delta    Wins  Losses\n", first, second)
+    fmt.Fprintf(output, "  ------------------------------------------------------------\n")
+    for i, delta := range result.ClassAccuracyDeltas {
+        fmt.Fprintf(output, "  %-13s   %.4f      %.4f   %+.4f  %6d  %6d\n",
+            r.className(i), a.ClassAccuracies[i], b.ClassAccuracies[i], delta,
+            result.ClassWins[i], result.ClassLosses[i])
+    }
+    fmt.Fprintf(output, "\n")
+    
+    fmt.Fprintf(output, "Disagreements (%s prediction → %s prediction):\n", first, second)
+    r.writeDisagreements(output, result.DisagreementMatrix)
+    fmt.Fprintf(output, "\n")
+    
+    fmt.Fprintf(output, "Per-Layer Output Error (each model fed its own previous output):\n")
+    fmt.Fprintf(output, "  Layer                    MSE    Max |Error|   SQNR (dB)\n")
+    fmt.Fprintf(output, "  -------------------------------------------------------\n")
+    for _, layer := range result.LayerErrors {
+        sqnr := "exact"
+        if layer.MSE > 0 {
+            sqnr = fmt.Sprintf("%.1f", layer.SQNR)
+        }
+        fmt.Fprintf(output, "  %-16s %11.4e    %11.4e   %9s\n", layer.Layer, layer.MSE, layer.MaxAbsError, sqnr)
+    }
+    
+    if outputPath != "" {
+        fmt.Printf("Text report saved to: %s\n", outputPath)
+    }
+    
+    return nil
+}
+
+// generateComparisonCSVReport generates a CSV comparison report for analysis
+func (r *Reporter) generateComparisonCSVReport(result *metrics.ComparisonResult, outputPath string) error {
+    if outputPath == "" {
+        outputPath = strings.ToLower(r.comparison) + "_comparison.csv"
+    }
+    
+    file, err := os.Create(outputPath)
+    if err != nil {
+        return fmt.Errorf("failed to create CSV file: %w", err)
+    }
+    defer file.Close()
+    
+    writer := csv.NewWriter(file)
+    defer writer.Flush()
+    
+    a, b := result.A, result.B
+    first, second := r.modelNames[0], r.modelNames[1]
+    
+    // Write overall metrics
+    writer.Write([]string{"Metric", first, second, "Change"})
+    writer.Write([]string{"Total Samples", fmt.Sprintf("%d", a.TotalSamples), fmt.Sprintf("%d", b.TotalSamples), ""})
+    writer.Write([]string{"Top-1 Accuracy", fmt.Sprintf("%.6f", a.Top1Accuracy),
+        fmt.Sprintf("%.6f", b.Top1Accuracy), fmt.Sprintf("%.6f", result.Top1Delta)})
+    writer.Write([]string{"Top-5 Accuracy", fmt.Sprintf("%.6f", a.Top5Accuracy),
+        fmt.Sprintf("%.6f", b.Top5Accuracy), fmt.Sprintf("%.6f", result.Top5Delta)})
+    writer.Write([]string{"Throughput", fmt.Sprintf("%.6f", a.Throughput), fmt.Sprintf("%.6f", b.Throughput), ""})
+    writer.Write([]string{"Average Inference Time (ms)", durationMs(a.AverageInferenceTime),
+        durationMs(b.AverageInferenceTime), durationMs(result.LatencyDelta)})
+    writer.Write([]string{"P99 Inference Time (ms)", durationMs(a.P99InferenceTime),
+        durationMs(b.P99InferenceTime), durationMs(result.P99LatencyDelta)})
+    writer.Write([]string{"Prediction Agreement", "", "", fmt.Sprintf("%.6f", result.Agreement)})
+    writer.Write([]string{"McNemar Wins", "", "", fmt.Sprintf("%d", result.McNemar.Wins)})
+    writer.Write([]string{"McNemar Losses", "", "", fmt.Sprintf("%d", result.McNemar.Losses)})
+    writer.Write([]string{"McNemar Statistic", "", "", fmt.Sprintf("%.6f", result.McNemar.Statistic)})
+    writer.Write([]string{"McNemar P-Value", "", "", fmt.Sprintf("%.6g", result.McNemar.PValue)})
+    if a.Run != nil && b.Run != nil {
+        writer.Write([]string{"Weights Hash", a.Run.WeightsHash, b.Run.WeightsHash, ""})
+    }
+    writer.Write([]string{""}) // Empty row
+    
+    // Write per-class accuracy
+    writer.Write([]string{"Class", first + " Accuracy", second + " Accuracy", "Delta", "Wins", "Losses"})
+    for i, delta := range result.ClassAccuracyDeltas {
+        writer.Write([]string{
+            r.className(i),
+            fmt.Sprintf("%.6f", a.ClassAccuracies[i]),
+            fmt.Sprintf("%.6f", b.ClassAccuracies[i]),
+            fmt.Sprintf("%.6f", delta),
+            fmt.Sprintf("%d", result.ClassWins[i]),
+            fmt.Sprintf("%d", result.ClassLosses[i]),
+        })
+    }
+    writer.Write([]string{""}) // Empty row
+    
+    // Write the disagreement matrix: first model's prediction by row, second's by column
+    header := []string{first + " \\ " + second}
+    for j := range result.DisagreementMatrix {
+        header = append(header, r.displayName(j))
+    }
+    writer.Write(header)
+    for i, row := range result.DisagreementMatrix {
+        record := []string{r.displayName(i)}
+        for _, count := range row {
+            record = append(record, fmt.Sprintf("%d", count))
+        }
+        writer.Write(record)
+    }
+    writer.Write([]string{""}) // Empty row
+    
+    // Write per-layer error
+    writer.Write([]string{"Layer", "MSE", "Max Abs Error", "SQNR (dB)"})
+    for _, layer := range result.LayerErrors {
+        writer.Write([]string{
+            layer.Layer,
+            fmt.Sprintf("%.6e", layer.MSE),
+            fmt.Sprintf("%.6e", layer.MaxAbsError),
+            fmt.Sprintf("%.3f", layer.SQNR),
+        })
+    }
+    
+    fmt.Printf("CSV report saved to: %s\n", outputPath)
+    return nil
+}
+
+// generateComparisonJSONReport generates a JSON comparison report for programmatic use
+func (r *Reporter) generateComparisonJSONReport(result *metrics.ComparisonResult, outputPath string) error {
+    if outputPath == "" {
+        outputPath = strings.ToLower(r.comparison) + "_comparison.json"
+    }
+    
+    enhancedResult := struct {
+        *metrics.ComparisonResult
+        Metadata struct {
+            GeneratedAt time.Time `json:"generated_at"`
+            ClassNames  []string  `json:"class_names"`
+            ModelNames  [2]string `json:"model_names"` // Of the "a" and "b" results
+            Format      string    `json:"format"`
+        } `json:"metadata"`
+    }{
+        ComparisonResult: result,
+    }
+    
+    enhancedResult.Metadata.GeneratedAt = time.Now()
+    enhancedResult.Metadata.ClassNames = r.classNames
+    enhancedResult.Metadata.ModelNames = r.modelNames
+    enhancedResult.Metadata.Format = fmt.Sprintf("TinyCNN %s Comparison v1.0", r.comparison)
+    
+    file, err := os.Create(outputPath)
+    if err != nil {
+        return fmt.Errorf("failed to create JSON file: %w", err)
+    }
+    defer file.Close()
+    
+    encoder := json.NewEncoder(file)
+    encoder.SetIndent("", "  ")
+    
+    if err := encoder.Encode(enhancedResult); err != nil {
+        return fmt.Errorf("failed to encode JSON: %w", err)
+    }
+    
+    fmt.Printf("JSON report saved to: %s\n", outputPath)
+    return nil
+}
+
+// generateComparisonPorcelainReport writes the comparison as stable tab-separated records (see printHelp)
+func (r *Reporter) generateComparisonPorcelainReport(result *metrics.ComparisonResult, evalTime time.Duration, 
+    outputPath string) error {
+    
+    output := os.Stdout
+    if outputPath != "" {
+        file, err := os.Create(outputPath)
+        if err != nil {
+            return fmt.Errorf("failed to create output file: %w", err)
+        }
+        defer file.Close()
+        output = file
+    }
+    
+    a, b := result.A, result.B
+    
+    records := porcelain.NewWriter(output)
+    records.Begin(AppName, AppVersion)
+    records.Record("engine", a.Engine)
+    records.Record("comparison", a.TotalSamples, a.Top1Accuracy, b.Top1Accuracy, result.Top1Delta,
+        a.Top5Accuracy, b.Top5Accuracy, result.Top5Delta, result.Agreement, evalTime)
+    records.Record("mcnemar", result.McNemar.Wins, result.McNemar.Losses, result.McNemar.Statistic,
+        result.McNemar.PValue, result.McNemar.Exact)
+    for i, delta := range result.ClassAccuracyDeltas {
+        records.Record("class_delta", i, r.className(i), a.ClassAccuracies[i], b.ClassAccuracies[i], delta,
+            result.ClassWins[i], result.ClassLosses[i])
+    }
+    records.Record("latency_delta", a.AverageInferenceTime, b.AverageInferenceTime, result.LatencyDelta,
+        a.P99InferenceTime, b.P99InferenceTime, result.P99LatencyDelta)
+    for i, row := range result.DisagreementMatrix {
+        fields := []any{i}
+        for _, count := range row {
+            fields = append(fields, count)
+        }
+        records.Record("disagreement", fields...)
+    }
+    for _, layer := range result.LayerErrors {
+        records.Record("layer_error", layer.Layer, layer.MSE, layer.MaxAbsError, layer.SQNR)
+    }
+    
+    return records.Flush()
+}
+
+// GenerateSweepReport generates and outputs the worker-count throughput sweep report
+func (r *Reporter) GenerateSweepReport(points []metrics.SweepPoint, outputPath string) error {
+    switch r.format {
+    case "text":
+        return r.generateSweepTextReport(points, outputPath)
+    case "csv":
+        return r.generateSweepCSVReport(points, outputPath)
+    case "json":
+        return r.generateSweepJSONReport(points, outputPath)
+    case "porcelain":
+        return r.generateSweepPorcelainReport(points, outputPath)
+    default:
+        return fmt.Errorf("unsupported format: %s", r.format)
+    }
+}
+
+// generateSweepTextReport writes the sweep as a table with the most efficient fast count marked
+func (r *Reporter) generateSweepTextReport(points []metrics.SweepPoint, outputPath string) error {
+    output := os.Stdout
+    if outputPath != "" {
+        file, err := os.Create(outputPath)
+        if err != nil {
+            return fmt.Errorf("failed to create output file: %w", err)
+        }
+        defer file.Close()
+        output = file
+    }
+    
+    fmt.Fprintf(output, "TinyCNN Worker Sweep Report\n")
+    fmt.Fprintf(output, "===========================\n\n")
+    fmt.Fprintf(output, "Generated: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+    fmt.Fprintf(output, "CPUs: %d (GOMAXPROCS=%d)\n\n", runtime.NumCPU(), runtime.GOMAXPROCS(0))
+    
+    fmt.Fprintf(output, "  Workers   Wall Time   Throughput   Speedup   Efficiency   Avg Latency   P99 Latency\n")
+    fmt.Fprintf(output, "  -----------------------------------------------------------------------------------\n")
+    best := recommendedWorkers(points)
+    for _, point := range points {
+        marker := ""
+        if point.Workers == best {
+            marker = "  ← recommended"
+        }
+        fmt.Fprintf(output, "  %7d  %10v  %9.2f/s  %7.2fx  %10.0f%%  %12v  %12v%s\n",
+            point.Workers, point.WallTime.Round(time.Millisecond), point.Throughput, point.Speedup,
+            point.Efficiency*100, point.AverageInferenceTime.Round(time.Microsecond),
+            point.P99InferenceTime.Round(time.Microsecond), marker)
+    }
+    fmt.Fprintf(output, "\nRecommended: -workers %d, the fewest workers within 5%% of the best throughput\n", best)
+    
+    if outputPath != "" {
+        fmt.Printf("Text report saved to: %s\n", outputPath)
+    }
+    return nil
+}
+
+// generateSweepCSVReport writes one row per worker count
+func (r *Reporter) generateSweepCSVReport(points []metrics.SweepPoint, outputPath string) error {
+    if outputPath == "" {
+        outputPath = "worker_sweep.csv"
+    }
+    
+    file, err := os.Create(outputPath)
+    if err != nil {
+        return fmt.Errorf("failed to create CSV file: %w", err)
+    }
+    defer file.Close()
+    
+    writer := csv.NewWriter(file)
+    defer writer.Flush()
+    
+    writer.Write([]string{"Workers", "Wall Time (ms)", "Throughput", "Speedup", "Efficiency",
+        "Average Inference Time (ms)", "P99 Inference Time (ms)", "Top-1 Accuracy"})
+    for _, point := range points {
+        writer.Write([]string{
+            fmt.Sprintf("%d", point.Workers),
+            durationMs(point.WallTime),
+            fmt.Sprintf("%.6f", point.Throughput),
+            fmt.Sprintf("%.6f", point.Speedup),
+            fmt.Sprintf("%.6f", point.Efficiency),
+            durationMs(point.AverageInferenceTime),
+            durationMs(point.P99InferenceTime),
+            fmt.Sprintf("%.6f", point.Top1Accuracy),
+        })
+    }
+    
+    fmt.Printf("CSV report saved to: %s\n", outputPath)
+    return nil
+}
+
+// generateSweepJSONReport writes the sweep with the recommended worker count
+func (r *Reporter) generateSweepJSONReport(points []metrics.SweepPoint, outputPath string) error {
+    if outputPath == "" {
+        outputPath = "worker_sweep.json"
+    }
+    
+    report := struct {
+        Points             []metrics.SweepPoint `json:"points"`
+        RecommendedWorkers int                  `json:"recommended_workers"`
+        Metadata           struct {
+            GeneratedAt time.Time `json:"generated_at"`
+            CPUs        int       `json:"cpus"`
+            GOMAXPROCS  int       `json:"gomaxprocs"`
+            Format      string    `json:"format"`
+        } `json:"metadata"`
+    }{
+        Points:             points,
+        RecommendedWorkers: recommendedWorkers(points),
+    }
+    report.Metadata.GeneratedAt = time.Now()
+    report.Metadata.CPUs = runtime.NumCPU()
+    report.Metadata.GOMAXPROCS = runtime.GOMAXPROCS(0)
+    report.Metadata.Format = "TinyCNN Worker Sweep v1.0"
+    
+    file, err := os.Create(outputPath)
+    if err != nil {
+        return fmt.Errorf("failed to create JSON file: %w", err)
+    }
+    defer file.Close()
+    
+    encoder := json.NewEncoder(file)
+    encoder.SetIndent("", "  ")
+    if err := encoder.Encode(report); err != nil {
+        return fmt.Errorf("failed to encode JSON: %w", err)
+    }
+    
+    fmt.Printf("JSON report saved to: %s\n", outputPath)
+    return nil
+}
+
+// generateSweepPorcelainReport writes the sweep as stable tab-separated records (see printHelp)
+func (r *Reporter) generateSweepPorcelainReport(points []metrics.SweepPoint, outputPath string) error {
+    output := os.Stdout
+    if outputPath != "" {
+        file, err := os.Create(outputPath)
+        if err != nil {
+            return fmt.Errorf("failed to create output file: %w", err)
+        }
+        defer file.Close()
+        output = file
+    }
+    
+    records := porcelain.NewWriter(output)
+    records.Begin(AppName, AppVersion)
+    for _, point := range points {
+        records.Record("sweep", point.Workers, point.WallTime, point.Throughput, point.Speedup, point.Efficiency,
+            point.AverageInferenceTime, point.P99InferenceTime, point.Top1Accuracy)
+    }
+    records.Record("recommended_workers", recommendedWorkers(points))
+    return records.Flush()
+}
+
+// sweepTolerance is how far below the best throughput a smaller worker count may be and still be recommended
+const sweepTolerance = 0.05
+
+// recommendedWorkers returns the smallest worker count whose throughput is within
+// sweepTolerance of the best, as more workers past it only add contention
+func recommendedWorkers(points []metrics.SweepPoint) int {
+    best := 0.0
+    for _, point := range points {
+        best = max(best, point.Throughput)
+    }
+    for _, point := range points {
+        if point.Throughput >= best*(1-sweepTolerance) {
+            return point.Workers
+        }
+    }
+    return 0
+}
+
+// maxListedDisagreements is the number of prediction pairs the text comparison report lists
+const maxListedDisagreements = 10
+
+// writeDisagreements lists the most frequent off-diagonal cells of the disagreement matrix
+func (r *Reporter) writeDisagreements(output io.Writer, matrix [][]int) {
+    type cell struct{ row, column, count int }
+    var cells []cell
+    for i, row := range matrix {
+        for j, count := range row {
+            if i != j && count > 0 {
+                cells = append(cells, cell{i, j, count})
+            }
+        }
+    }
+    if len(cells) == 0 {
+        fmt.Fprintf(output, "  none: both models predict every sample alike\n")
+        return
+    }
+    sort.SliceStable(cells, func(a, b int) bool { return cells[a].count > cells[b].count })
+    for _, c := range cells[:min(len(cells), maxListedDisagreements)] {
+        fmt.Fprintf(output, "  %-13s → %-13s %6d\n", r.displayName(c.row), r.displayName(c.column), c.count)
+    }
+    if len(cells) > maxListedDisagreements {
+        fmt.Fprintf(output, "  ... and %d more pairs (see the CSV or JSON report)\n", len(cells)-maxListedDisagreements)
+    }
+}
+
+// mcnemarString describes the outcome of McNemar's test, such as "χ² = 4.17, p = 0.0412 (significant)"
+func mcnemarString(test metrics.McNemarTest) string {
+    verdict := "not significant"
+    if test.PValue < 0.05 {
+        verdict = "significant"
+    }
+    if test.Exact {
+        return fmt.Sprintf("exact p = %.4g (%s)", test.PValue, verdict)
+    }
+    return fmt.Sprintf("χ² = %.2f, p = %.4g (%s)", test.Statistic, test.PValue, verdict)
+}
+
+// className returns the configured name of class i, or "" if there is none
+func (r *Reporter) className(i int) string {
+    if i < len(r.classNames) {
+        return r.classNames[i]
+    }
+    return ""
+}
+
+// writeROCPoints writes the points of one ROC curve as CSV rows labelled class
+func (r *Reporter) writeROCPoints(writer *csv.Writer, class string, points []metrics.ROCPoint) {
+    for _, point := range points {
+        writer.Write([]string{
+            class,
+            fmt.Sprintf("%.6f", point.FPR),
+            fmt.Sprintf("%.6f", point.TPR),
+            fmt.Sprintf("%.6f", point.Threshold),
+        })
+    }
+}
+
+// aucString formats the AUC of curve with format, or "n/a" when the class had no curve
+func aucString(curve metrics.ROCCurve, format string) string {
+    if len(curve.Points) == 0 {
+        return "n/a"
+    }
+    return fmt.Sprintf(format, curve.AUC)
+}
+
+// histogramBarWidth is the length of the fullest bucket's bar in the text report
+const histogramBarWidth = 40
+
+// writeLatencyHistogram writes histogram as one bar per bucket, then its cumulative
+// distribution if it has one
+func (r *Reporter) writeLatencyHistogram(output io.Writer, histogram metrics.LatencyHistogram) {
+    if len(histogram.Buckets) == 0 {
+        return
+    }
+    fullest, total := 0, 0
+    for _, bucket := range histogram.Buckets {
+        fullest = max(fullest, bucket.Count)
+        total += bucket.Count
+    }
+
+    fmt.Fprintf(output, "Latency Histogram:\n")
+    for _, bucket := range histogram.Buckets {
+        upper := "∞"
+        if bucket.Upper > 0 {
+            upper = bucket.Upper.String()
+        }
+        bar := strings.Repeat("█", (bucket.Count*histogramBarWidth+fullest-1)/max(fullest, 1))
+        line := fmt.Sprintf("  [%10v, %10s) %7d %6.2f%%  %s", bucket.Lower, upper, bucket.Count,
+            float64(bucket.Count)/float64(total)*100, bar)
+        fmt.Fprintln(output, strings.TrimRight(line, " "))
+    }
+    fmt.Fprintf(output, "\n")
+
+    if len(histogram.Distribution) == 0 {
+        return
+    }
+    fmt.Fprintf(output, "Latency Distribution (cumulative):\n")
+    fmt.Fprintf(output, "  Percentile        Latency   Samples   1/(1-Percentile)\n")
+    for _, q := range histogram.Distribution {
+        inverse := "∞"
+        if q.Percentile < 100 {
+            inverse = fmt.Sprintf("%.2f", 100/(100-q.Percentile))
+        }
+        fmt.Fprintf(output, "  %9.5f%%  %13v  %8d   %s\n", q.Percentile, q.Latency, q.Count, inverse)
+    }
+    fmt.Fprintf(output, "\n")
+}
+
+// writeRegression writes the checks of an evaluation against its baseline
+func writeRegression(output io.Writer, report *metrics.RegressionReport) {
+    fmt.Fprintf(output, "Regression Check:\n")
+    if report.BaselineSamples != report.CurrentSamples {
+        fmt.Fprintf(output, "  Warning: the baseline evaluated %d samples, this run %d\n",
+            report.BaselineSamples, report.CurrentSamples)
+    }
+    for _, check := range report.Checks {
+        status := "ok"
+        if check.Regressed {
+            status = "REGRESSED"
+        }
+        switch check.Metric {
+        case metrics.MetricTop1Accuracy:
+            fmt.Fprintf(output, "  Top-1 Accuracy: %.4f -> %.4f, %+.2f points (limit -%.2f)  %s\n",
+                check.Baseline, check.Current, (check.Current-check.Baseline)*100, check.Limit*100, status)
+        case metrics.MetricP95Latency:
+            fmt.Fprintf(output, "  P95 Latency: %v -> %v, %+.2f%% (limit +%.2f%%)  %s\n",
+                secondsDuration(check.Baseline), secondsDuration(check.Current), check.Regression*100, check.Limit*100, status)
+        }
+    }
+}
+
+// secondsDuration converts seconds to a duration for printing
+func secondsDuration(seconds float64) time.Duration {
+    return time.Duration(seconds * float64(time.Second))
+}
+
+// writeRoofline writes the achieved throughput of every layer against the machine's peak
+func writeRoofline(output io.Writer, roofline *metrics.Roofline) {
+    fmt.Fprintf(output, "Roofline (peak %.1f GFLOP/s, %.1f GB/s, ridge point %.1f FLOP/byte):\n",
+        roofline.Peak.GFLOPS, roofline.Peak.BandwidthGBs, roofline.RidgePoint)
+    fmt.Fprintf(output, "  Layer           MFLOPs  Params KB  FLOP/byte  Avg Time     GFLOP/s  Attainable  Efficiency  Bound\n")
+    for _, layer := range roofline.Layers {
+        fmt.Fprintf(output, "  %-14s %7.2f  %9.1f  %9.2f  %-11v %8.2f  %10.2f  %9.1f%%  %s\n",
+            layer.Layer, float64(layer.FLOPs)/1e6, float64(layer.ParamBytes)/1024, layer.ArithmeticIntensity,
+            layer.AverageTime.Round(time.Microsecond), layer.GFLOPS, layer.AttainableGFLOPS, layer.Efficiency*100, boundName(layer))
+    }
+    fmt.Fprintf(output, "\n")
+}
+
+// boundName names the resource that limits a layer
+func boundName(layer metrics.LayerRoofline) string {
+    if layer.ComputeBound {
+        return "compute"
+    }
+    return "memory"
+}
+
+// writeMemory writes the allocations of an evaluation next to its timings
+func writeMemory(output io.Writer, mem *metrics.MemoryStats) {
+    fmt.Fprintf(output, "Memory:\n")
+    fmt.Fprintf(output, "  Allocated per Inference: average %s (%d objects), max %s\n",
+        formatBytes(mem.AverageAllocBytes), mem.AverageAllocs, formatBytes(mem.MaxAllocBytes))
+    fmt.Fprintf(output, "  Allocated in Total: %s (%d objects)\n", formatBytes(mem.TotalAllocBytes), mem.Mallocs)
+    fmt.Fprintf(output, "  Garbage Collection: %d cycles, %v paused\n", mem.GCCycles, mem.GCPause)
+    if mem.HeapSamples > 0 {
+        fmt.Fprintf(output, "  Peak Heap: %s (from %s, %d samples)\n",
+            formatBytes(mem.PeakHeap), formatBytes(mem.HeapStart), mem.HeapSamples)
+    } else {
+        fmt.Fprintf(output, "  Peak Heap: %s (from %s, start and end only)\n", formatBytes(mem.PeakHeap), formatBytes(mem.HeapStart))
+    }
+    if len(mem.LayerAllocs) > 0 {
+        fmt.Fprintf(output, "  Layer           Bytes/Inference   Objects/Inference\n")
+        for _, layer := range mem.SortedLayerAllocs() {
+            counters := mem.LayerAllocs[layer]
+            fmt.Fprintf(output, "  %-14s  %15s   %17d\n", layer, formatBytes(counters.Bytes), counters.Objects)
+        }
+    }
+    fmt.Fprintf(output, "\n")
+}
+
+// formatBytes formats a byte count in B, KB or MB
+func formatBytes(n uint64) string {
+    switch {
+    case n >= 1<<20:
+        return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+    case n >= 1<<10:
+        return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+    default:
+        return fmt.Sprintf("%d B", n)
+    }
+}
+
+// durationMs formats d in milliseconds for the CSV report
+func durationMs(d time.Duration) string {
+    return fmt.Sprintf("%.6f", float64(d)/float64(time.Millisecond))
+}
+
+// displayName returns the configured name of class i, or "class <i>" if there is none
+func (r *Reporter) displayName(i int) string {
+    if name := r.className(i); name != "" {
+        return name
+    }
+    return fmt.Sprintf("class %d", i)
+}
+
 // computeStdDev computes standard deviation
 func (r *Reporter) computeStdDev(values []float64, mean float64) float64 {
     if len(values) <= 1 {
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/model"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"duchm1606/gocnn/internal/config"
+)
+
+/**
+* Batch directory mode
+
+-dir classifies every image of a folder in one run, loading the model once:
+```
+find images   dir/*.bin, *.png, *.jpg, *.jpeg  (-pattern, -recursive)
+    │
+    ├── worker 1: load → predict ─┐
+    ├── worker 2: load → predict ─┼── results in file order
+    └── worker n: load → predict ─┘
+    │
+write         text table, CSV or JSON (-format) to stdout or -output
+```
+An image that fails to load or predict is reported with its error and the
+others carry on; the run fails at the end if any image did.
+*/
+
+// defaultBatchPatterns are the file names -dir processes unless -pattern is set
+var defaultBatchPatterns = []string{"*.bin", "*.png", "*.jpg", "*.jpeg"}
+
+// BatchResult is the prediction for one image of a batch
+type BatchResult struct {
+    Path           string        `json:"path"`
+    PredictedClass int           `json:"predicted_class"`
+    ClassName      string        `json:"class_name"`
+    Confidence     float32       `json:"confidence"`
+    InferenceTime  time.Duration `json:"inference_time"`
+    Error          string        `json:"error,omitempty"` // Set when the image could not be classified
+}
+
+// BatchSummary is the outcome of processing a directory
+type BatchSummary struct {
+    Directory    string        `json:"directory"`
+    Images       int           `json:"images"`
+    Failed       int           `json:"failed"`
+    TotalTime    time.Duration `json:"total_time"`
+    ImagesPerSec float64       `json:"images_per_sec"`
+    Results      []BatchResult `json:"results"`
+}
+
+// BatchProcessor handles batch processing of multiple images
+type BatchProcessor struct {
+    cnn       model.Predictor
+    config    *config.Config
+    patterns  []string
+    recursive bool
+    workers   int
+    logger    *slog.Logger // Receives progress records; nil for none
+}
+
+// NewBatchProcessor creates a new batch processor
+// It matches defaultBatchPatterns in the top directory only, with one worker.
+func NewBatchProcessor(cnn model.Predictor, cfg *config.Config) *BatchProcessor {
+    return &BatchProcessor{
+        cnn:      cnn,
+        config:   cfg,
+        patterns: defaultBatchPatterns,
+        workers:  1,
+    }
+}
+
+// SetPatterns sets the glob patterns file names must match, case-insensitively
+func (bp *BatchProcessor) SetPatterns(patterns []string) {
+    bp.patterns = patterns
+}
+
+// SetRecursive makes the processor descend into subdirectories
+func (bp *BatchProcessor) SetRecursive(recursive bool) {
+    bp.recursive = recursive
+}
+
+// SetWorkers sets the number of images loaded and predicted in parallel
+func (bp *BatchProcessor) SetWorkers(workers int) {
+    bp.workers = max(workers, 1)
+}
+
+// SetLogger makes the processor log its progress to logger
+func (bp *BatchProcessor) SetLogger(logger *slog.Logger) {
+    bp.logger = logger
+}
+
+// parseBatchPatterns splits a comma-separated -pattern value and checks each glob
+func parseBatchPatterns(s string) ([]string, error) {
+    var patterns []string
+    for _, pattern := range strings.Split(s, ",") {
+        pattern = strings.TrimSpace(pattern)
+        if pattern == "" {
+            continue
+        }
+        if _, err := filepath.Match(pattern, ""); err != nil {
+            return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+        }
+        patterns = append(patterns, pattern)
+    }
+    if len(patterns) == 0 {
+        return nil, fmt.Errorf("no pattern given")
+    }
+    return patterns, nil
+}
+
+// matches reports whether a file name matches one of the patterns
+func (bp *BatchProcessor) matches(name string) bool {
+    name = strings.ToLower(name)
+    for _, pattern := range bp.patterns {
+        if ok, _ := filepath.Match(strings.ToLower(pattern), name); ok {
+            return true
+        }
+    }
+    return false
+}
+
+// FindImages returns the matching files of dirPath in lexical order, including
+// those of its subdirectories when recursive
+func (bp *BatchProcessor) FindImages(dirPath string) ([]string, error) {
+    var files []string
+    err := filepath.WalkDir(dirPath, func(path string, entry fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if entry.IsDir() {
+            if path != dirPath && !bp.recursive {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        if bp.matches(entry.Name()) {
+            files = append(files, path)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to find image files: %w", err)
+    }
+    return files, nil
+}
+
+// ProcessDirectory classifies all images in a directory
+// Images that fail are recorded in their result; the error is for the directory as a whole.
+func (bp *BatchProcessor) ProcessDirectory(dirPath string) (*BatchSummary, error) {
+    files, err := bp.FindImages(dirPath)
+    if err != nil {
+        return nil, err
+    }
+    if len(files) == 0 {
+        return nil, fmt.Errorf("no files matching %s found in directory: %s", strings.Join(bp.patterns, ", "), dirPath)
+    }
+
+    if bp.logger != nil {
+        bp.logger.Info("processing directory", "dir", dirPath, "images", len(files), "workers", bp.workers)
+    }
+
+    summary := &BatchSummary{Directory: dirPath, Images: len(files), Results: make([]BatchResult, len(files))}
+    indices := make(chan int)
+    var wg sync.WaitGroup
+    var mu sync.Mutex
+    done := 0
+
+    totalStart := time.Now()
+    for w := 0; w < min(bp.workers, len(files)); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range indices {
+                summary.Results[i] = bp.processImage(files[i])
+
+                mu.Lock()
+                done++
+                if bp.logger != nil && (done%10 == 0 || done == len(files)) {
+                    bp.logger.Info("batch progress", "done", done, "images", len(files))
+                }
+                mu.Unlock()
+            }
+        }()
+    }
+    for i := range files {
+        indices <- i
+    }
+    close(indices)
+    wg.Wait()
+
+    summary.TotalTime = time.Since(totalStart)
+    summary.ImagesPerSec = float64(len(files)) / summary.TotalTime.Seconds()
+    for _, result := range summary.Results {
+        if result.Error != "" {
+            summary.Failed++
+        }
+    }
+    return summary, nil
+}
+
+// processImage loads and classifies one image
+func (bp *BatchProcessor) processImage(path string) BatchResult {
+    result := BatchResult{Path: path, PredictedClass: -1}
+    imageData, err := loadImage(path, bp.config)
+    if err != nil {
+        result.Error = err.Error()
+        return result
+    }
+
+    start := time.Now()
+    prediction, err := bp.cnn.Predict(context.Background(), imageData)
+    if err != nil {
+        result.Error = err.Error()
+        return result
+    }
+    result.InferenceTime = time.Since(start)
+    result.PredictedClass = prediction.PredictedClass
+    result.ClassName = getClassName(prediction.PredictedClass, bp.config.Model.ClassNames)
+    result.Confidence = prediction.Confidence
+    return result
+}
+
+// writeBatchText writes a summary as an aligned table followed by totals
+func writeBatchText(w io.Writer, summary *BatchSummary) error {
+    fmt.Fprintf(w, "%-40s %5s  %-12s %10s  %s\n", "Image", "Class", "Name", "Confidence", "Time")
+    for _, r := range summary.Results {
+        if r.Error != "" {
+            fmt.Fprintf(w, "%-40s error: %s\n", r.Path, r.Error)
+            continue
+        }
+        fmt.Fprintf(w, "%-40s %5d  %-12s %10.4f  %v\n", r.Path, r.PredictedClass, r.ClassName, r.Confidence, r.InferenceTime)
+    }
+
+    fmt.Fprintf(w, "\nBatch processing completed:\n")
+    fmt.Fprintf(w, "  Total images: %d (%d failed)\n", summary.Images, summary.Failed)
+    fmt.Fprintf(w, "  Total time: %v\n", summary.TotalTime)
+    fmt.Fprintf(w, "  Average time per image: %v\n", summary.TotalTime/time.Duration(summary.Images))
+    _, err := fmt.Fprintf(w, "  Throughput: %.2f images/sec\n", summary.ImagesPerSec)
+    return err
+}
+
+// batchCSVHeader names the columns of batchCSVRow
+var batchCSVHeader = []string{"path", "predicted_class", "class_name", "confidence", "inference_time_ns", "error"}
+
+// batchCSVRow returns the CSV fields of a result; the inference time is in nanoseconds
+func batchCSVRow(r BatchResult) []string {
+    return []string{
+        r.Path,
+        strconv.Itoa(r.PredictedClass),
+        r.ClassName,
+        strconv.FormatFloat(float64(r.Confidence), 'f', 6, 32),
+        strconv.FormatInt(r.InferenceTime.Nanoseconds(), 10),
+        r.Error,
+    }
+}
+
+// writeBatchCSV writes one row per image
+func writeBatchCSV(w io.Writer, summary *BatchSummary) error {
+    cw := csv.NewWriter(w)
+    cw.Write(batchCSVHeader)
+    for _, r := range summary.Results {
+        cw.Write(batchCSVRow(r))
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// writeBatchJSON writes the summary, results included, as one indented JSON object
+func writeBatchJSON(w io.Writer, summary *BatchSummary) error {
+    encoder := json.NewEncoder(w)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(summary)
+}
+
+// writeBatchRecords prints a summary as porcelain records:
+// batch_result <path> <class> <class name> <confidence> <time ns> <error>, one per image
+// batch <images> <failed> <total ns> <images/sec>
+func writeBatchRecords(summary *BatchSummary) {
+    for _, r := range summary.Results {
+        records.Record("batch_result", r.Path, r.PredictedClass, r.ClassName, r.Confidence, r.InferenceTime, r.Error)
+    }
+    records.Record("batch", summary.Images, summary.Failed, summary.TotalTime, summary.ImagesPerSec)
+}
+
+// runBatch classifies the images of -dir and writes them in -format to -output or stdout
+func runBatch(cnn model.Predictor, cfg *config.Config) error {
+    processor := NewBatchProcessor(cnn, cfg)
+    if *batchPattern != "" {
+        patterns, err := parseBatchPatterns(*batchPattern)
+        if err != nil {
+            return fmt.Errorf("-pattern: %w", err)
+        }
+        processor.SetPatterns(patterns)
+    }
+    processor.SetRecursive(*recursive)
+    processor.SetWorkers(*batchWorkers)
+    processor.SetLogger(slog.Default())
+
+    summary, err := processor.ProcessDirectory(*batchDir)
+    if err != nil {
+        return err
+    }
+
+    if records != nil {
+        writeBatchRecords(summary)
+    } else {
+        out := io.Writer(os.Stdout)
+        if *outputPath != "" {
+            file, err := os.Create(*outputPath)
+            if err != nil {
+                return fmt.Errorf("failed to create output file: %w", err)
+            }
+            defer file.Close()
+            out = file
+        }
+
+        switch *outputFormat {
+        case "csv":
+            err = writeBatchCSV(out, summary)
+        case "json":
+            err = writeBatchJSON(out, summary)
+        default:
+            err = writeBatchText(out, summary)
+        }
+        if err != nil {
+            return fmt.Errorf("failed to write results: %w", err)
+        }
+        if *outputPath != "" {
+            slog.Info("results saved", "path", *outputPath)
+        }
+    }
+
+    if summary.Failed > 0 {
+        return fmt.Errorf("%d of %d images could not be classified", summary.Failed, summary.Images)
+    }
+    return nil
+}
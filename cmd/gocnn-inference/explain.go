@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/explain"
+	"duchm1606/gocnn/internal/model"
+	"log/slog"
+
+	"duchm1606/gocnn/internal/config"
+)
+
+// writeGradCAM saves the Grad-CAM heatmap of class, or of the predicted class if
+// negative, overlaid on imageData to the -gradcam PNG file
+func writeGradCAM(cnn *model.TinyCNN, imageData []float32, class int, cfg *config.Config) error {
+    heatmap, err := explain.GradCAM(cnn, imageData, class, *gradCAMLayer)
+    if err != nil {
+        return err
+    }
+    if err := writeOverlay(cnn, imageData, heatmap, *gradCAMPath); err != nil {
+        return err
+    }
+
+    className := getClassName(heatmap.Class, cfg.Model.ClassNames)
+    if records != nil {
+        records.Record("gradcam", *gradCAMPath, heatmap.Class, className, heatmap.Layer)
+    }
+    slog.Info("Grad-CAM heatmap saved", "path", *gradCAMPath, "class", className, "layer", heatmap.Layer)
+    return nil
+}
+
+// writeSaliency saves the saliency map of class, or of the predicted class if
+// negative, overlaid on imageData to the -saliency PNG file
+func writeSaliency(cnn *model.TinyCNN, imageData []float32, class int, cfg *config.Config) error {
+    saliency, err := explain.Saliency(cnn, imageData, class, explain.SaliencyOptions{Samples: *smoothGrad})
+    if err != nil {
+        return err
+    }
+    if err := writeOverlay(cnn, imageData, &saliency.Heatmap, *saliencyPath); err != nil {
+        return err
+    }
+
+    className := getClassName(saliency.Class, cfg.Model.ClassNames)
+    if records != nil {
+        records.Record("saliency", *saliencyPath, saliency.Class, className, *smoothGrad)
+    }
+    slog.Info("saliency map saved", "path", *saliencyPath, "class", className, "smoothgrad_samples", *smoothGrad)
+    return nil
+}
+
+// writeOcclusion saves the occlusion sensitivity of class, or of the predicted class
+// if negative, overlaid on imageData to the -occlusion PNG file
+func writeOcclusion(cnn *model.TinyCNN, imageData []float32, class int, cfg *config.Config) error {
+    occlusion, err := explain.Occlusion(context.Background(), cnn, imageData, class, explain.OcclusionOptions{
+        Patch:  *occlusionPatch,
+        Stride: *occlusionStride,
+    })
+    if err != nil {
+        return err
+    }
+    if err := writeOverlay(cnn, imageData, &occlusion.Heatmap, *occlusionPath); err != nil {
+        return err
+    }
+
+    className := getClassName(occlusion.Class, cfg.Model.ClassNames)
+    if records != nil {
+        records.Record("occlusion", *occlusionPath, occlusion.Class, className,
+            occlusion.Baseline, occlusion.MaxDrop, occlusion.Patches)
+    }
+    slog.Info("occlusion map saved", "path", *occlusionPath, "class", className,
+        "baseline", occlusion.Baseline, "max_drop", occlusion.MaxDrop, "patches", occlusion.Patches)
+    return nil
+}
+
+// writeOverlay draws heatmap over imageData and saves it as a PNG file
+func writeOverlay(cnn *model.TinyCNN, imageData []float32, heatmap *explain.Heatmap, path string) error {
+    arch := cnn.Info().Architecture
+    img, err := explain.Overlay(imageData, arch.InputHeight, arch.InputWidth, arch.InputChannels,
+        heatmap, explain.OverlayOptions{})
+    if err != nil {
+        return err
+    }
+    return explain.SavePNG(path, img)
+}
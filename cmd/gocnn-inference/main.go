@@ -1,16 +1,43 @@
 package main
 
 import (
+	"context"
 	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/dump"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/logging"
 	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/ops"
+	"duchm1606/gocnn/internal/porcelain"
+	"duchm1606/gocnn/internal/runinfo"
+	"duchm1606/gocnn/internal/startup"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"duchm1606/gocnn/internal/config"
 )
 
+// Exit statuses of the -min-confidence and -expect-class acceptance checks
+const (
+    exitLowConfidence = 2
+    exitWrongClass    = 3
+)
+
+// Errors of the acceptance checks, returned by runInference
+var (
+    errLowConfidence = errors.New("prediction below -min-confidence")
+    errWrongClass    = errors.New("prediction differs from -expect-class")
+)
+
 // Version information
 const (
     AppName    = "gocnn-inference"
@@ -21,18 +48,67 @@ const (
 // Command line flags
 var (
     weightsPath = flag.String("weights", "", "Path to model weights directory (required)")
-    imagePath   = flag.String("image", "", "Path to input image file (required)")
+    imagePath   = flag.String("image", "", "Path to input image file; more can follow the flags as arguments")
+    imageFormat = flag.String("image-format", "float32", "Image file encoding: float32 (values in [0, 1]) or uint8 (0-255)")
     configPath  = flag.String("config", "configs/cifar10.yaml", "Path to model configuration file")
     outputPath  = flag.String("output", "", "Path to save detailed results (optional)")
     verbose     = flag.Bool("verbose", false, "Enable verbose output")
     quiet       = flag.Bool("quiet", false, "Suppress non-essential output")
+    logLevelName = flag.String("log-level", "", "Least severe log record written to stderr: debug, info, warn, error (default info, debug with -verbose, warn with -quiet)")
+    logFormat    = flag.String("log-format", "text", "Log record format on stderr: text or json")
     showVersion = flag.Bool("version", false, "Show version information")
     showHelp    = flag.Bool("help", false, "Show detailed help")
     benchmark   = flag.Bool("benchmark", false, "Run in benchmark mode (multiple iterations)")
     iterations  = flag.Int("iterations", 10, "Number of iterations for benchmark mode")
+    warmupSamples = flag.Int("warmup-samples", 0, "Time the first N benchmark iterations separately as cold and leave them out of the timing")
+    batchDir     = flag.String("dir", "", "Classify every image in a directory instead of -image")
+    batchPattern = flag.String("pattern", "", "Comma-separated globs of the -dir or -watch files to classify (default *.bin,*.png,*.jpg,*.jpeg)")
+    recursive    = flag.Bool("recursive", false, "Also classify the images in subdirectories of -dir or -watch")
+    batchWorkers = flag.Int("workers", 4, "Images classified in parallel with -dir")
+    outputFormat = flag.String("format", "text", "Output format of the prediction or -dir results: text, csv, json")
+
+    watchDir      = flag.String("watch", "", "Classify images as they appear in a directory, appending CSV rows to -output or stdout")
+    watchInterval = flag.Duration("watch-interval", time.Second, "How often -watch looks for new images")
+
+    stdinMode  = flag.Bool("stdin", false, "Classify images streamed on stdin, printing one JSON result per line")
+    stdinInput = flag.String("stdin-input", stdinRaw, "What -stdin reads: raw (consecutive fixed-size image blobs) or paths (one file per line)")
+
+    interactive = flag.Bool("interactive", false, "Start a shell for predicting several images, reloading weights and more (no -image needed)")
+    autotune    = flag.Bool("autotune", false, "Time convolution algorithms per layer and use the fastest")
+    tuneCache   = flag.String("tune-cache", "", "JSON file caching -autotune choices between runs")
+
+    engineName    = flag.String("engine", "", "Convolution backend: auto, naive, tiled, parallel, gemm (default $GOCNN_ENGINE or auto)")
+    engineWorkers = flag.Int("engine-workers", -1, "Goroutines per convolution for the parallel backend (0 = one per CPU)")
+    enginePool    = flag.String("engine-pool", "", "Reuse intermediate buffers: on or off (default on)")
+    
+    warmup        = flag.Bool("warmup", false, "Touch all weight pages and run a dummy inference before starting")
+    startupReport = flag.Bool("startup-report", false, "Print a breakdown of start-up time (init, config, weights, first inference)")
+    runManifest   = flag.String("run-manifest", "", "Write a run.json with version, commit, config/weights hashes, engine and host")
+    topK          = flag.Int("topk", 0, "Print the N most probable classes with their names and probabilities")
+    minConfidence = flag.Float64("min-confidence", 0, "Exit with status 2 if the prediction's confidence is below this (0-1)")
+    expectClass   = flag.String("expect-class", "", "Exit with status 3 unless the prediction is this class (index or name)")
+    porcelainMode = flag.Bool("porcelain", false, "Print only stable tab-separated records for scripts")
+
+    dumpDir    = flag.String("dump-activations", "", "Save every layer's output for the image to this directory")
+    dumpFormat = flag.String("dump-format", "npy", "Activation dump files: npy (NumPy, with a shape header) or raw arrays")
+
+    gradCAMPath  = flag.String("gradcam", "", "Save a Grad-CAM heatmap of the predicted class over the image as a PNG file")
+    gradCAMLayer = flag.String("gradcam-layer", "", "Conv layer for -gradcam (default: the last conv layer with a ReLU)")
+    saliencyPath = flag.String("saliency", "", "Save the input-gradient saliency map of the predicted class over the image as a PNG file")
+    smoothGrad   = flag.Int("smoothgrad", 0, "Average -saliency over this many noisy copies of the image (SmoothGrad; 0 = plain gradient)")
+    explainClass = flag.String("explain-class", "", "Class -gradcam, -saliency and -occlusion explain, index or name (default: the predicted class)")
+
+    occlusionPath   = flag.String("occlusion", "", "Save the confidence drop when patches of the image are masked, over the image, as a PNG file")
+    occlusionPatch  = flag.Int("occlusion-patch", 8, "Side of the -occlusion patch in pixels")
+    occlusionStride = flag.Int("occlusion-stride", 0, "Step between -occlusion patches (default: half the patch)")
 )
 
+// records receives the output in -porcelain mode and is nil otherwise
+var records *porcelain.Writer
+
 func main() {
+    report := startup.NewReport()
+
     // Parse command line flags
     flag.Parse()
 
@@ -56,10 +132,34 @@ func main() {
 
     // Set log level based on flags
     logLevel := getLogLevel()
+    logger, err := newLogger(logLevel)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        os.Exit(1)
+    }
+    slog.SetDefault(logger)
+    if *porcelainMode {
+        records = porcelain.NewWriter(os.Stdout)
+        records.Begin(AppName, AppVersion)
+    }
+    report.Mark("flag parsing")
 
     // Run the inference
-    if err := runInference(logLevel); err != nil {
-        fmt.Fprintf(os.Stderr, "Inference failed: %v\n", err)
+    err = runInference(logLevel, report)
+    if records != nil {
+        if flushErr := records.Flush(); err == nil {
+            err = flushErr
+        }
+    }
+    switch {
+    case errors.Is(err, errWrongClass):
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        os.Exit(exitWrongClass)
+    case errors.Is(err, errLowConfidence):
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        os.Exit(exitLowConfidence)
+    case err != nil:
+        errs.Fprint(os.Stderr, "Inference failed", err)
         os.Exit(1)
     }
 }
@@ -70,8 +170,132 @@ func validateArgs() error {
         return fmt.Errorf("weights path is required (use -weights)")
     }
 
-    if *imagePath == "" {
-        return fmt.Errorf("image path is required (use -image)")
+    images := imagePaths()
+    switch {
+    case *stdinMode:
+        if len(images) > 0 || *batchDir != "" || *interactive {
+            return fmt.Errorf("-stdin cannot be combined with -image, -dir or -interactive")
+        }
+        if *benchmark || *porcelainMode || *startupReport || *outputPath != "" || *outputFormat != "text" {
+            return fmt.Errorf("-stdin prints JSON lines and cannot be combined with -benchmark, -porcelain, -startup-report, -output or -format")
+        }
+        if *stdinInput != stdinRaw && *stdinInput != stdinPaths {
+            return fmt.Errorf("-stdin-input must be %s or %s, got %q", stdinRaw, stdinPaths, *stdinInput)
+        }
+    case *watchDir != "":
+        if len(images) > 0 || *batchDir != "" || *interactive {
+            return fmt.Errorf("-watch cannot be combined with images, -dir or -interactive")
+        }
+        if *benchmark || *porcelainMode || *startupReport || *outputFormat == "json" {
+            return fmt.Errorf("-watch writes CSV and cannot be combined with -benchmark, -porcelain, -startup-report or -format json")
+        }
+        if *minConfidence > 0 || *expectClass != "" {
+            return fmt.Errorf("-min-confidence and -expect-class cannot be combined with -watch")
+        }
+        if info, err := os.Stat(*watchDir); err != nil || !info.IsDir() {
+            return fmt.Errorf("-watch is not a directory: %s", *watchDir)
+        }
+        if *watchInterval <= 0 {
+            return fmt.Errorf("-watch-interval must be positive, got %v", *watchInterval)
+        }
+        if *batchPattern != "" {
+            if _, err := parseBatchPatterns(*batchPattern); err != nil {
+                return fmt.Errorf("-pattern: %w", err)
+            }
+        }
+    case *interactive:
+        if *benchmark || *porcelainMode || *startupReport || *batchDir != "" || len(images) > 0 {
+            return fmt.Errorf("-interactive cannot be combined with images, -benchmark, -porcelain, -startup-report or -dir")
+        }
+    case *batchDir != "":
+        if len(images) > 0 {
+            return fmt.Errorf("-dir and -image cannot be combined")
+        }
+        if *benchmark || *startupReport {
+            return fmt.Errorf("-dir cannot be combined with -benchmark or -startup-report")
+        }
+        if info, err := os.Stat(*batchDir); err != nil || !info.IsDir() {
+            return fmt.Errorf("-dir is not a directory: %s", *batchDir)
+        }
+        if *batchWorkers <= 0 {
+            return fmt.Errorf("-workers must be positive, got %d", *batchWorkers)
+        }
+        if *batchPattern != "" {
+            if _, err := parseBatchPatterns(*batchPattern); err != nil {
+                return fmt.Errorf("-pattern: %w", err)
+            }
+        }
+    case len(images) == 0:
+        return fmt.Errorf("image path is required (use -image, image arguments, -dir or -interactive)")
+    case len(images) > 1:
+        if *benchmark || *startupReport {
+            return fmt.Errorf("-benchmark and -startup-report take a single image")
+        }
+    }
+
+    if *topK < 0 {
+        return fmt.Errorf("-topk must not be negative, got %d", *topK)
+    }
+    if *topK > 0 && (len(images) == 0 || *benchmark) {
+        return fmt.Errorf("-topk applies to the predictions of -image or image arguments")
+    }
+
+    if *dumpDir != "" {
+        if len(images) != 1 || *benchmark {
+            return fmt.Errorf("-dump-activations takes a single image and cannot be combined with -benchmark")
+        }
+        if _, err := dump.ParseFormat(*dumpFormat); err != nil {
+            return fmt.Errorf("-dump-format: %w", err)
+        }
+    }
+    if *gradCAMPath != "" && (len(images) != 1 || *benchmark) {
+        return fmt.Errorf("-gradcam takes a single image and cannot be combined with -benchmark")
+    }
+    if *gradCAMLayer != "" && *gradCAMPath == "" {
+        return fmt.Errorf("-gradcam-layer needs -gradcam")
+    }
+    if *saliencyPath != "" && (len(images) != 1 || *benchmark) {
+        return fmt.Errorf("-saliency takes a single image and cannot be combined with -benchmark")
+    }
+    if *smoothGrad < 0 {
+        return fmt.Errorf("-smoothgrad must not be negative, got %d", *smoothGrad)
+    }
+    if *smoothGrad > 0 && *saliencyPath == "" {
+        return fmt.Errorf("-smoothgrad needs -saliency")
+    }
+    if *occlusionPath != "" && (len(images) != 1 || *benchmark) {
+        return fmt.Errorf("-occlusion takes a single image and cannot be combined with -benchmark")
+    }
+    if *occlusionPatch <= 0 || *occlusionStride < 0 {
+        return fmt.Errorf("-occlusion-patch must be positive and -occlusion-stride not negative")
+    }
+    if *explainClass != "" && *gradCAMPath == "" && *saliencyPath == "" && *occlusionPath == "" {
+        return fmt.Errorf("-explain-class needs -gradcam, -saliency or -occlusion")
+    }
+
+    if *minConfidence < 0 || *minConfidence > 1 {
+        return fmt.Errorf("-min-confidence must be between 0 and 1, got %g", *minConfidence)
+    }
+    if (*minConfidence > 0 || *expectClass != "") && len(images) == 0 {
+        return fmt.Errorf("-min-confidence and -expect-class check the predictions of -image or image arguments")
+    }
+    if (*minConfidence > 0 || *expectClass != "") && *benchmark {
+        return fmt.Errorf("-min-confidence and -expect-class cannot be combined with -benchmark")
+    }
+    if *warmupSamples < 0 {
+        return fmt.Errorf("-warmup-samples must not be negative, got %d", *warmupSamples)
+    }
+    if *warmupSamples > 0 && !*benchmark {
+        return fmt.Errorf("-warmup-samples needs -benchmark")
+    }
+
+    switch *outputFormat {
+    case "text", "csv", "json":
+    default:
+        return fmt.Errorf("-format must be text, csv or json, got %q", *outputFormat)
+    }
+    if *outputFormat != "text" && (*benchmark || *interactive || *porcelainMode) {
+        return fmt.Errorf("-format %s cannot be combined with -benchmark, -interactive or -porcelain", *outputFormat)
     }
 
     // Check if weights directory exists
@@ -79,9 +303,11 @@ func validateArgs() error {
         return fmt.Errorf("weights directory does not exist: %s", *weightsPath)
     }
 
-    // Check if image file exists
-    if _, err := os.Stat(*imagePath); os.IsNotExist(err) {
-        return fmt.Errorf("image file does not exist: %s", *imagePath)
+    // Check if the image files exist
+    for _, image := range images {
+        if _, err := os.Stat(image); os.IsNotExist(err) {
+            return fmt.Errorf("image file does not exist: %s", image)
+        }
     }
 
     // Check if config file exists
@@ -89,9 +315,62 @@ func validateArgs() error {
         return fmt.Errorf("config file does not exist: %s", *configPath)
     }
 
+    if _, err := data.ParseImageFormat(*imageFormat); err != nil {
+        return fmt.Errorf("-image-format: %w", err)
+    }
+
+    if _, err := resolveEngineOptions(); err != nil {
+        return err
+    }
+
+    if _, err := newLogger(LogNormal); err != nil {
+        return err
+    }
+
     return nil
 }
 
+// imagePaths returns the images to classify: -image, if set, then the arguments
+func imagePaths() []string {
+    var images []string
+    if *imagePath != "" {
+        images = append(images, *imagePath)
+    }
+    return append(images, flag.Args()...)
+}
+
+// resolveEngineOptions combines the defaults, GOCNN_ENGINE* variables and -engine* flags
+// Flags win over the environment
+func resolveEngineOptions() (ops.EngineOptions, error) {
+    opts, err := ops.EngineOptionsFromEnv(ops.DefaultEngineOptions())
+    if err != nil {
+        return opts, err
+    }
+
+    if *engineName != "" {
+        backend, err := ops.ParseBackend(*engineName)
+        if err != nil {
+            return opts, fmt.Errorf("-engine: %w", err)
+        }
+        opts.Backend = backend
+    }
+    if *engineWorkers >= 0 {
+        opts.Workers = *engineWorkers
+    }
+    if *enginePool != "" {
+        pooling, err := ops.ParsePooling(*enginePool)
+        if err != nil {
+            return opts, fmt.Errorf("-engine-pool: %w", err)
+        }
+        opts.Pooling = pooling
+    }
+
+    if *autotune && opts.Backend != ops.AlgoAuto {
+        return opts, fmt.Errorf("-autotune picks a backend per layer and cannot be combined with backend %q", opts.Backend)
+    }
+    return opts, nil
+}
+
 // LogLevel defines the verbosity of output
 type LogLevel int
 
@@ -102,8 +381,11 @@ const (
 )
 
 // getLogLevel determines the appropriate log level
+// -porcelain implies quiet: the records replace all other output. So do CSV and JSON
+// written to stdout, -stdin's and -watch's included, which must not be mixed with messages.
 func getLogLevel() LogLevel {
-    if *quiet {
+    if *quiet || *porcelainMode || *stdinMode || (*outputFormat != "text" && *outputPath == "") ||
+        (*watchDir != "" && *outputPath == "") {
         return LogQuiet
     }
     if *verbose {
@@ -112,104 +394,387 @@ func getLogLevel() LogLevel {
     return LogNormal
 }
 
+// newLogger builds the logger of -log-level and -log-format, writing to stderr
+// Without -log-level, verbose output logs debug records too and quiet output only
+// warnings and errors.
+func newLogger(logLevel LogLevel) (*slog.Logger, error) {
+    format, err := logging.ParseFormat(*logFormat)
+    if err != nil {
+        return nil, fmt.Errorf("-log-format: %w", err)
+    }
+
+    level := slog.LevelInfo
+    switch logLevel {
+    case LogVerbose:
+        level = slog.LevelDebug
+    case LogQuiet:
+        level = slog.LevelWarn
+    }
+    if *logLevelName != "" {
+        if level, err = logging.ParseLevel(*logLevelName); err != nil {
+            return nil, fmt.Errorf("-log-level: %w", err)
+        }
+    }
+    return logging.New(os.Stderr, logging.Options{Level: level, Format: format}), nil
+}
+
 // runInference performs the main inference workflow
-func runInference(logLevel LogLevel) error {
+// report collects start-up timings and is printed at the end when -startup-report is set
+func runInference(logLevel LogLevel, report *startup.Report) error {
     // Load configuration
-    if logLevel >= LogVerbose {
-        fmt.Printf("Loading configuration from %s...\n", *configPath)
-    }
+    slog.Debug("loading configuration", "path", *configPath)
 
     cfg, err := config.Load(*configPath)
     if err != nil {
         return fmt.Errorf("failed to load configuration: %w", err)
     }
+    report.Mark("config parse")
 
-    // Create and load model
-    if logLevel >= LogNormal {
-        fmt.Printf("Loading CNN model from %s...\n", *weightsPath)
+    expected := -1
+    if *expectClass != "" {
+        expected, err = resolveClass(*expectClass, cfg.Model.ClassNames)
+        if err != nil {
+            return fmt.Errorf("-expect-class: %w", err)
+        }
     }
+    explained := -1
+    if *explainClass != "" {
+        explained, err = resolveClass(*explainClass, cfg.Model.ClassNames)
+        if err != nil {
+            return fmt.Errorf("-explain-class: %w", err)
+        }
+    }
+
+    // Create and load model
+    slog.Info("loading model", "weights", *weightsPath)
 
     start := time.Now()
-    cnn, err := model.NewTinyCNN(*weightsPath)
+    cnn, err := model.NewTinyCNNFromConfig(*weightsPath, cfg.Model)
     if err != nil {
         return fmt.Errorf("failed to load model: %w", err)
     }
     loadTime := time.Since(start)
+    report.Mark("weight load", weightLoadPhases(cnn)...)
 
-    if logLevel >= LogVerbose {
-        fmt.Printf("Model loaded in %v\n", loadTime)
-        
-        // Print model information
-        modelInfo := cnn.GetModelInfo()
-        fmt.Printf("Model Information:\n")
-        fmt.Printf("  Total Parameters: %d\n", modelInfo.TotalParameters)
-        fmt.Printf("  Input Size: %d×%d×%d\n", 
-            modelInfo.Architecture.InputHeight,
-            modelInfo.Architecture.InputWidth, 
-            modelInfo.Architecture.InputChannels)
-        fmt.Printf("  Output Classes: %d\n", modelInfo.Architecture.NumClasses)
+    engineOpts, err := resolveEngineOptions()
+    if err != nil {
+        return err
+    }
+    if err := cnn.ConfigureEngine(engineOpts); err != nil {
+        return fmt.Errorf("failed to configure engine: %w", err)
     }
+    report.Mark("engine setup")
 
-    // Load and preprocess image
-    if logLevel >= LogVerbose {
-        fmt.Printf("Loading image from %s...\n", *imagePath)
+    slog.Debug("model loaded", "duration", loadTime, "model", cnn.GetModelInfo())
+    report.Skip()
+
+    if *autotune {
+        if err := runAutotune(cnn); err != nil {
+            return err
+        }
+        report.Mark("autotune")
+    }
+
+    if *warmup || cfg.Inference.Warmup {
+        if err := runWarmup(cnn, cfg); err != nil {
+            return err
+        }
+        report.Mark("warm-up")
+    }
+
+    // Record how this run was produced for run.json and the saved results
+    var run *runinfo.Manifest
+    if *runManifest != "" || *outputPath != "" {
+        run, err = newRunManifest(cnn)
+        if err != nil {
+            return err
+        }
+        if *runManifest != "" {
+            if err := run.Write(*runManifest); err != nil {
+                return err
+            }
+            slog.Debug("run manifest saved", "path", *runManifest)
+        }
+        report.Mark("run manifest")
+    }
+
+    if *interactive {
+        return runInteractiveMode(cnn, cfg, *weightsPath)
+    }
+    if *batchDir != "" {
+        return runBatch(cnn, cfg)
+    }
+    if *stdinMode {
+        return runStdin(cnn, cfg)
+    }
+    if *watchDir != "" {
+        return runWatch(cnn, cfg)
+    }
+
+    images := imagePaths()
+    if len(images) > 1 {
+        return runMultipleInference(cnn, images, cfg, run, expected, logLevel)
     }
+    image := images[0]
 
-    imageData, err := loadImage(*imagePath, cfg)
+    // Load and preprocess image
+    slog.Debug("loading image", "path", image)
+
+    imageData, err := loadImage(image, cfg)
     if err != nil {
         return fmt.Errorf("failed to load image: %w", err)
     }
+    report.Mark("image load")
+
+    // The first prediction pays for lazy allocation and cold caches; time it on its own
+    var steady time.Duration
+    if *startupReport {
+        if _, err := cnn.Predict(context.Background(), imageData); err != nil {
+            return fmt.Errorf("warm-up inference failed: %w", err)
+        }
+        report.Mark("first inference")
+
+        steadyStart := time.Now()
+        if _, err := cnn.Predict(context.Background(), imageData); err != nil {
+            return fmt.Errorf("inference failed: %w", err)
+        }
+        steady = time.Since(steadyStart)
+    }
+
+    // Only the reported inference is dumped, not the -startup-report ones above
+    var dumpWriter *dump.Writer
+    if *dumpDir != "" {
+        format, err := dump.ParseFormat(*dumpFormat)
+        if err != nil {
+            return fmt.Errorf("-dump-format: %w", err)
+        }
+        dumpWriter, err = dump.NewWriter(dump.Options{Dir: *dumpDir, Format: format})
+        if err != nil {
+            return err
+        }
+        cnn.SetActivationDump(dumpWriter)
+    }
 
     // Run inference
+    var result *model.PredictionResult
     if *benchmark {
-        return runBenchmark(cnn, imageData, cfg, logLevel)
+        err = runBenchmark(cnn, imageData, cfg)
     } else {
-        return runSingleInference(cnn, imageData, cfg, logLevel)
+        result, err = runSingleInference(cnn, image, imageData, cfg, run, logLevel)
     }
+    if err != nil {
+        return err
+    }
+
+    if dumpWriter != nil {
+        cnn.SetActivationDump(nil)
+        manifest, err := dumpWriter.Close()
+        if err != nil {
+            return err
+        }
+        if records != nil {
+            records.Record("activation_dump", *dumpDir, len(manifest.Entries), manifest.Bytes)
+        }
+        slog.Info("activations dumped", "dir", *dumpDir, "layers", len(manifest.Entries), "bytes", manifest.Bytes)
+    }
+
+    if *gradCAMPath != "" {
+        if err := writeGradCAM(cnn, imageData, explained, cfg); err != nil {
+            return fmt.Errorf("Grad-CAM failed: %w", err)
+        }
+    }
+    if *saliencyPath != "" {
+        if err := writeSaliency(cnn, imageData, explained, cfg); err != nil {
+            return fmt.Errorf("saliency map failed: %w", err)
+        }
+    }
+    if *occlusionPath != "" {
+        if err := writeOcclusion(cnn, imageData, explained, cfg); err != nil {
+            return fmt.Errorf("occlusion map failed: %w", err)
+        }
+    }
+
+    if *startupReport {
+        if records != nil {
+            writeStartupRecords(report, steady)
+        } else {
+            fmt.Println()
+            report.Write(os.Stdout)
+            fmt.Printf("  Steady-state inference: %v\n", steady)
+        }
+    }
+
+    if result != nil {
+        return checkPrediction(result, expected, cfg)
+    }
+    return nil
 }
 
-// loadImage loads and preprocesses an image file
-func loadImage(imagePath string, cfg *config.Config) ([]float32, error) {
-    imageLoader := data.NewImageLoader(data.BinaryFloat32)
-    
-    // Load image
-    fm, err := imageLoader.LoadImage(imagePath, cfg.Model.InputHeight, cfg.Model.InputWidth, cfg.Model.InputChannels)
+// resolveClass converts a class index or name, ignoring case, to the class index
+func resolveClass(class string, classNames []string) (int, error) {
+    if index, err := strconv.Atoi(class); err == nil {
+        if index < 0 || index >= len(classNames) {
+            return 0, fmt.Errorf("class %d out of range [0, %d)", index, len(classNames))
+        }
+        return index, nil
+    }
+    for i, name := range classNames {
+        if strings.EqualFold(name, class) {
+            return i, nil
+        }
+    }
+    return 0, errs.WithHint(fmt.Errorf("unknown class %q", class), "classes are %s", strings.Join(classNames, ", "))
+}
+
+// checkPrediction applies -expect-class, unless expected is negative, and -min-confidence
+// A wrong class is reported before a low confidence.
+func checkPrediction(result *model.PredictionResult, expected int, cfg *config.Config) error {
+    if expected >= 0 && result.PredictedClass != expected {
+        return fmt.Errorf("%w: predicted %d (%s), expected %d (%s)", errWrongClass,
+            result.PredictedClass, getClassName(result.PredictedClass, cfg.Model.ClassNames),
+            expected, getClassName(expected, cfg.Model.ClassNames))
+    }
+    if float64(result.Confidence) < *minConfidence {
+        return fmt.Errorf("%w: confidence %.4f is below %.4f", errLowConfidence, result.Confidence, *minConfidence)
+    }
+    return nil
+}
+
+// writeStartupRecords prints the start-up report as porcelain records:
+// startup <phase> <ns>, startup_detail <phase> <detail> <ns>, then the steady-state inference time
+func writeStartupRecords(report *startup.Report, steady time.Duration) {
+    for _, phase := range report.Phases {
+        records.Record("startup", phase.Name, phase.Duration)
+        for _, detail := range phase.Details {
+            records.Record("startup_detail", phase.Name, detail.Name, detail.Duration)
+        }
+    }
+    records.Record("startup", "steady-state inference", steady)
+}
+
+// newRunManifest records the binary, inputs, engine settings and host of this run
+func newRunManifest(cnn model.Predictor) (*runinfo.Manifest, error) {
+    run := runinfo.New(AppName, AppVersion)
+    if err := run.SetConfig(*configPath); err != nil {
+        return nil, err
+    }
+    if err := run.SetWeights(*weightsPath); err != nil {
+        return nil, err
+    }
+
+    run.SetModel(cnn.Info())
+    return run, nil
+}
+
+// weightLoadPhases turns the model's per-layer weight load times into report details
+func weightLoadPhases(cnn *model.TinyCNN) []startup.Phase {
+    loadTimes := cnn.WeightLoadTimes()
+    phases := make([]startup.Phase, len(loadTimes))
+    for i, lt := range loadTimes {
+        name := fmt.Sprintf("%s (%.1f KB)", lt.Layer, float64(lt.Bytes)/1024)
+        if *porcelainMode {
+            name = lt.Layer
+        }
+        phases[i] = startup.Phase{Name: name, Duration: lt.Duration}
+    }
+    return phases
+}
+
+// runAutotune selects the fastest convolution algorithm for each layer
+func runAutotune(cnn *model.TinyCNN) error {
+    slog.Info("autotuning convolution algorithms")
+
+    results, err := cnn.Autotune(*tuneCache)
     if err != nil {
-        return nil, fmt.Errorf("failed to load image: %w", err)
+        return fmt.Errorf("autotune failed: %w", err)
     }
 
-    // Validate image dimensions
-    if fm.Height != cfg.Model.InputHeight || fm.Width != cfg.Model.InputWidth || fm.Channels != cfg.Model.InputChannels {
-        return nil, fmt.Errorf("image dimensions (%d×%d×%d) don't match expected (%d×%d×%d)",
-            fm.Height, fm.Width, fm.Channels,
-            cfg.Model.InputHeight, cfg.Model.InputWidth, cfg.Model.InputChannels)
+    for _, result := range results {
+        source := "measured"
+        if result.Cached {
+            source = "cached"
+        }
+        slog.Debug("autotuned layer", "layer", result.Layer, "shape", result.Shape,
+            "algorithm", result.Algorithm, "source", source)
     }
 
-    return fm.Data, nil
+    return nil
 }
 
-// runSingleInference performs a single inference
-func runSingleInference(cnn *model.TinyCNN, imageData []float32, cfg *config.Config, logLevel LogLevel) error {
-    if logLevel >= LogNormal {
-        fmt.Println("Running inference...")
+// runWarmup touches the weights, autotunes and runs dummy inferences so the real one starts hot
+func runWarmup(cnn *model.TinyCNN, cfg *config.Config) error {
+    stats, err := cnn.Warmup(cfg.Inference.WarmupInferences)
+    if err != nil {
+        return err
     }
 
+    slog.Debug("warm-up done", "stats", stats)
+    return nil
+}
+
+// loadImage loads an image file and applies the config's preprocessing pipeline
+func loadImage(imagePath string, cfg *config.Config) ([]float32, error) {
+    format, err := data.ParseImageFormat(*imageFormat)
+    if err != nil {
+        return nil, err
+    }
+    preprocessor, err := data.NewPreprocessor(format, cfg.Data, cfg.Model)
+    if err != nil {
+        return nil, err
+    }
+    
+    fm, err := preprocessor.Load(imagePath)
+    if err != nil {
+        return nil, err
+    }
+
+    return fm.Data, nil
+}
+
+// runSingleInference performs a single inference on image
+// It returns the prediction for the acceptance checks.
+func runSingleInference(cnn model.Predictor, image string, imageData []float32, cfg *config.Config,
+    run *runinfo.Manifest, logLevel LogLevel) (*model.PredictionResult, error) {
+    slog.Info("running inference", "image", image)
+
     start := time.Now()
-    result, err := cnn.Predict(imageData)
+    result, err := cnn.Predict(context.Background(), imageData)
     if err != nil {
-        return fmt.Errorf("inference failed: %w", err)
+        return nil, fmt.Errorf("inference failed: %w", err)
     }
     totalTime := time.Since(start)
 
+    return result, reportPrediction(image, result, cfg, totalTime, run, *outputPath, logLevel)
+}
+
+// reportPrediction prints the prediction of image as porcelain records, in -format, or
+// as text, and saves it to outputPath unless that is empty
+func reportPrediction(image string, result *model.PredictionResult, cfg *config.Config, totalTime time.Duration,
+    run *runinfo.Manifest, outputPath string, logLevel LogLevel) error {
+
+    if records != nil {
+        writePredictionRecords(image, result, cfg, totalTime)
+    }
+
+    // CSV and JSON replace the text results, on stdout or in outputPath
+    if records == nil && *outputFormat != "text" {
+        return writeInferenceOutput([]*InferenceOutput{newInferenceOutput(image, result, cfg, totalTime, run)}, outputPath)
+    }
+
     // Display results
-    if logLevel >= LogQuiet {
+    if records == nil {
         fmt.Println("\nPrediction Results:")
+        fmt.Printf("  Image: %s\n", image)
         fmt.Printf("  Predicted Class: %d (%s)\n", 
             result.PredictedClass, 
             getClassName(result.PredictedClass, cfg.Model.ClassNames))
         fmt.Printf("  Confidence: %.4f (%.2f%%)\n", 
             result.Confidence, 
             result.Confidence*100)
+        if *topK > 0 {
+            fmt.Printf("  Top %d:\n", *topK)
+            printTopK(os.Stdout, result.TopK(*topK), cfg.Model.ClassNames, "    ")
+        }
     }
 
     if logLevel >= LogVerbose {
@@ -227,60 +792,193 @@ func runSingleInference(cnn *model.TinyCNN, imageData []float32, cfg *config.Con
         }
     }
 
-    // Save detailed results if output path is specified
-    if *outputPath != "" {
-        err := saveDetailedResults(result, *outputPath, cfg)
+    // Save detailed results if an output path is given
+    if outputPath != "" {
+        err := saveDetailedResults(result, outputPath, cfg, run)
         if err != nil {
             return fmt.Errorf("failed to save results: %w", err)
         }
         
-        if logLevel >= LogNormal {
-            fmt.Printf("\nDetailed results saved to: %s\n", *outputPath)
+        slog.Info("detailed results saved", "path", outputPath)
+    }
+
+    return nil
+}
+
+// runMultipleInference classifies several images with the model loaded once
+// Results are printed per image; with -output, which is then a directory, each is
+// saved to a file named after its image. CSV and JSON on stdout are collected into
+// one table or array. An image that fails does not stop the others.
+func runMultipleInference(cnn model.Predictor, images []string, cfg *config.Config, run *runinfo.Manifest,
+    expected int, logLevel LogLevel) error {
+
+    var outputFiles map[string]string
+    if *outputPath != "" {
+        var err error
+        if outputFiles, err = resultFileNames(images, *outputPath); err != nil {
+            return err
+        }
+        if err := os.MkdirAll(*outputPath, 0755); err != nil {
+            return fmt.Errorf("failed to create output directory: %w", err)
         }
     }
+    slog.Info("running inference", "images", len(images))
 
+    var collected []*InferenceOutput
+    var checkErrs []error
+    failed := 0
+    for _, image := range images {
+        imageData, err := loadImage(image, cfg)
+        if err != nil {
+            slog.Error("failed to load image", "image", image, "error", err)
+            failed++
+            continue
+        }
+        start := time.Now()
+        result, err := cnn.Predict(context.Background(), imageData)
+        if err != nil {
+            slog.Error("inference failed", "image", image, "error", err)
+            failed++
+            continue
+        }
+        totalTime := time.Since(start)
+
+        if records == nil && *outputFormat != "text" && outputFiles == nil {
+            collected = append(collected, newInferenceOutput(image, result, cfg, totalTime, run))
+        } else if err := reportPrediction(image, result, cfg, totalTime, run, outputFiles[image], logLevel); err != nil {
+            return err
+        }
+
+        if err := checkPrediction(result, expected, cfg); err != nil {
+            checkErrs = append(checkErrs, fmt.Errorf("%s: %w", image, err))
+        }
+    }
+
+    if len(collected) > 0 {
+        if err := writeInferenceOutput(collected, ""); err != nil {
+            return err
+        }
+    }
+    if failed > 0 {
+        return fmt.Errorf("%d of %d images could not be classified", failed, len(images))
+    }
+    return errors.Join(checkErrs...)
+}
+
+// resultFileNames maps each image to the file in dir its result is saved to: its
+// base name with the extension of -format
+func resultFileNames(images []string, dir string) (map[string]string, error) {
+    ext := map[string]string{"text": ".txt", "csv": ".csv", "json": ".json"}[*outputFormat]
+    files := make(map[string]string, len(images))
+    owners := make(map[string]string, len(images))
+    for _, image := range images {
+        base := filepath.Base(image)
+        name := strings.TrimSuffix(base, filepath.Ext(base)) + ext
+        if other, ok := owners[name]; ok && other != image {
+            return nil, fmt.Errorf("%s and %s would both be saved to %s", other, image, filepath.Join(dir, name))
+        }
+        owners[name] = image
+        files[image] = filepath.Join(dir, name)
+    }
+    return files, nil
+}
+
+// writeInferenceOutput writes outs in -format to outputPath, or to stdout if it is empty
+// JSON is an object for a single prediction and an array for several.
+func writeInferenceOutput(outs []*InferenceOutput, outputPath string) error {
+    w := io.Writer(os.Stdout)
+    if outputPath != "" {
+        file, err := os.Create(outputPath)
+        if err != nil {
+            return fmt.Errorf("failed to create output file: %w", err)
+        }
+        defer file.Close()
+        w = file
+    }
+
+    var err error
+    switch {
+    case *outputFormat == "csv":
+        err = writeInferenceCSV(w, outs)
+    case len(outs) == 1:
+        err = writeInferenceJSON(w, outs[0])
+    default:
+        err = writeInferenceJSON(w, outs)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to write results: %w", err)
+    }
+    if outputPath != "" {
+        slog.Info("results saved", "path", outputPath)
+    }
     return nil
 }
 
-// runBenchmark performs multiple inference iterations for benchmarking
-func runBenchmark(cnn *model.TinyCNN, imageData []float32, cfg *config.Config, logLevel LogLevel) error {
-    if logLevel >= LogNormal {
-        fmt.Printf("Running benchmark with %d iterations...\n", *iterations)
+// writePredictionRecords prints a prediction as porcelain records:
+// engine <engine>
+// prediction <image> <class> <class name> <confidence> <time ns>
+// probability <class> <class name> <probability>, one per class
+// top <rank> <class> <class name> <probability>, the -topk most probable classes from rank 1
+// layer_time <layer> <ns>, sorted by layer
+func writePredictionRecords(image string, result *model.PredictionResult, cfg *config.Config, totalTime time.Duration) {
+    records.Record("engine", result.Engine)
+    records.Record("prediction", image, result.PredictedClass,
+        getClassName(result.PredictedClass, cfg.Model.ClassNames), result.Confidence, totalTime)
+    for i, prob := range result.Probabilities {
+        records.Record("probability", i, getClassName(i, cfg.Model.ClassNames), prob)
+    }
+    if *topK > 0 {
+        for i, class := range result.TopK(*topK) {
+            records.Record("top", i+1, class.Class, getClassName(class.Class, cfg.Model.ClassNames), class.Probability)
+        }
     }
+    writeLayerTimeRecords(result.LayerTimes)
+}
+
+// writeLayerTimeRecords prints layer_time <layer> <ns> records sorted by layer name
+func writeLayerTimeRecords(layerTimes map[string]time.Duration) {
+    layers := make([]string, 0, len(layerTimes))
+    for layer := range layerTimes {
+        layers = append(layers, layer)
+    }
+    sort.Strings(layers)
+    for _, layer := range layers {
+        records.Record("layer_time", layer, layerTimes[layer])
+    }
+}
 
-    var totalTime time.Duration
+// runBenchmark performs multiple inference iterations for benchmarking
+// The first -warmup-samples iterations are timed separately as cold; at least one stays warm
+func runBenchmark(cnn model.Predictor, imageData []float32, cfg *config.Config) error {
+    slog.Info("running benchmark", "iterations", *iterations, "warmup_samples", *warmupSamples)
+
+    cold := max(min(*warmupSamples, *iterations-1), 0)
+    var totalTime, coldTime time.Duration
     var results []*model.PredictionResult
 
     for i := 0; i < *iterations; i++ {
         start := time.Now()
-        result, err := cnn.Predict(imageData)
+        result, err := cnn.Predict(context.Background(), imageData)
         if err != nil {
             return fmt.Errorf("benchmark iteration %d failed: %w", i+1, err)
         }
         
         iterTime := time.Since(start)
-        totalTime += iterTime
+        if i < cold {
+            coldTime += iterTime
+        } else {
+            totalTime += iterTime
+        }
         results = append(results, result)
 
-        if logLevel >= LogVerbose {
-            fmt.Printf("  Iteration %d: %v (class: %d, confidence: %.4f)\n", 
-                i+1, iterTime, result.PredictedClass, result.Confidence)
-        }
+        slog.Debug("benchmark iteration", "iteration", i+1, "cold", i < cold, "duration", iterTime,
+            "class", result.PredictedClass, "confidence", result.Confidence)
     }
 
-    // Calculate statistics
-    avgTime := totalTime / time.Duration(*iterations)
-    minTime := results[0].TotalTime
-    maxTime := results[0].TotalTime
-
-    for _, result := range results[1:] {
-        if result.TotalTime < minTime {
-            minTime = result.TotalTime
-        }
-        if result.TotalTime > maxTime {
-            maxTime = result.TotalTime
-        }
-    }
+    // Calculate statistics over the warm iterations
+    warm := *iterations - cold
+    avgTime := totalTime / time.Duration(warm)
+    minTime, maxTime := timeRange(results[*iterations-warm:])
 
     // Check consistency
     firstPrediction := results[0].PredictedClass
@@ -292,14 +990,33 @@ func runBenchmark(cnn *model.TinyCNN, imageData []float32, cfg *config.Config, l
         }
     }
 
+    // benchmark <iterations> <total ns> <average ns> <min ns> <max ns> <images/sec> <consistent>
+    // cold <samples> <total ns> <average ns> <min ns> <max ns>
+    if records != nil {
+        records.Record("engine", cnn.Info().Engine)
+        records.Record("benchmark", warm, totalTime, avgTime, minTime, maxTime,
+            float64(warm)/totalTime.Seconds(), consistent)
+        if cold > 0 {
+            coldMin, coldMax := timeRange(results[:cold])
+            records.Record("cold", cold, coldTime, coldTime/time.Duration(cold), coldMin, coldMax)
+        }
+        return nil
+    }
+
     // Display benchmark results
     fmt.Println("\nBenchmark Results:")
-    fmt.Printf("  Iterations: %d\n", *iterations)
+    fmt.Printf("  Engine: %s\n", cnn.Info().Engine)
+    fmt.Printf("  Iterations: %d\n", warm)
     fmt.Printf("  Total Time: %v\n", totalTime)
     fmt.Printf("  Average Time: %v\n", avgTime)
     fmt.Printf("  Min Time: %v\n", minTime)
     fmt.Printf("  Max Time: %v\n", maxTime)
-    fmt.Printf("  Throughput: %.2f images/sec\n", float64(*iterations)/totalTime.Seconds())
+    fmt.Printf("  Throughput: %.2f images/sec\n", float64(warm)/totalTime.Seconds())
+    if cold > 0 {
+        coldMin, coldMax := timeRange(results[:cold])
+        fmt.Printf("  Cold Start (first %d iterations, excluded above): average %v, min %v, max %v\n",
+            cold, coldTime/time.Duration(cold), coldMin, coldMax)
+    }
     fmt.Printf("  Predictions Consistent: %v\n", consistent)
 
     if !consistent {
@@ -309,8 +1026,19 @@ func runBenchmark(cnn *model.TinyCNN, imageData []float32, cfg *config.Config, l
     return nil
 }
 
+// timeRange returns the shortest and longest inference time of results
+func timeRange(results []*model.PredictionResult) (time.Duration, time.Duration) {
+    minTime, maxTime := results[0].TotalTime, results[0].TotalTime
+    for _, result := range results[1:] {
+        minTime = min(minTime, result.TotalTime)
+        maxTime = max(maxTime, result.TotalTime)
+    }
+    return minTime, maxTime
+}
+
 // saveDetailedResults saves comprehensive results to a file
-func saveDetailedResults(result *model.PredictionResult, outputPath string, cfg *config.Config) error {
+// run, if not nil, is embedded so the file records how it was produced
+func saveDetailedResults(result *model.PredictionResult, outputPath string, cfg *config.Config, run *runinfo.Manifest) error {
     file, err := os.Create(outputPath)
     if err != nil {
         return fmt.Errorf("failed to create output file: %w", err)
@@ -324,7 +1052,13 @@ func saveDetailedResults(result *model.PredictionResult, outputPath string, cfg
         result.PredictedClass, 
         getClassName(result.PredictedClass, cfg.Model.ClassNames))
     fmt.Fprintf(file, "Confidence: %.6f\n", result.Confidence)
-    fmt.Fprintf(file, "Total Inference Time: %v\n\n", result.TotalTime)
+    fmt.Fprintf(file, "Total Inference Time: %v\n", result.TotalTime)
+    fmt.Fprintf(file, "Engine: %s\n", result.Engine)
+    if run != nil {
+        fmt.Fprintf(file, "Run: %s\n", run.Summary())
+        fmt.Fprintf(file, "Host: %s\n", run.HostSummary())
+    }
+    fmt.Fprintf(file, "\n")
 
     fmt.Fprintf(file, "All Class Probabilities:\n")
     for i, prob := range result.Probabilities {
@@ -359,19 +1093,62 @@ func printHelp() {
     fmt.Printf("%s - %s\n\n", AppName, AppDesc)
     
     fmt.Println("USAGE:")
-    fmt.Printf("  %s -weights <path> -image <path> [options]\n\n", AppName)
+    fmt.Printf("  %s -weights <path> -image <path> [options]\n", AppName)
+    fmt.Printf("  %s -weights <path> [options] <image>...   (flags before the images)\n", AppName)
+    fmt.Printf("  %s -weights <path> -dir <path> [options]\n", AppName)
+    fmt.Printf("  %s -weights <path> -stdin [-stdin-input raw|paths] [options]\n", AppName)
+    fmt.Printf("  %s -weights <path> -watch <dir> [-output results.csv] [options]\n", AppName)
+    fmt.Printf("  %s -weights <path> -interactive [options]\n\n", AppName)
     
     fmt.Println("REQUIRED:")
     fmt.Println("  -weights <path>    Path to directory containing model weights")
-    fmt.Println("  -image <path>      Path to input image file (32x32x3 binary format)")
+    fmt.Println("  -image <path>      Path to input image file (32x32x3 binary format, or any-size PNG/JPEG)")
     
     fmt.Println("\nOPTIONS:")
     fmt.Println("  -config <path>     Path to model configuration file (default: configs/cifar10.yaml)")
     fmt.Println("  -output <path>     Save detailed results to file")
+    fmt.Println("  -image-format <f>  Image file encoding: float32 (default) or uint8")
     fmt.Println("  -verbose           Enable verbose output")
     fmt.Println("  -quiet             Suppress non-essential output")
+    fmt.Println("  -log-level <l>     Least severe log record on stderr: debug, info, warn, error")
+    fmt.Println("                     (default: info; debug with -verbose, warn with -quiet)")
+    fmt.Println("  -log-format <f>    Log records on stderr as text (default) or json, one per line")
     fmt.Println("  -benchmark         Run in benchmark mode")
     fmt.Println("  -iterations <n>    Number of iterations for benchmark (default: 10)")
+    fmt.Println("  -warmup-samples <n> Time the first n -benchmark iterations separately as cold (page faults,")
+    fmt.Println("                     empty buffer pools, worker start-up) and leave them out of the timing")
+    fmt.Println("  -dir <path>        Classify every image in a directory instead of -image")
+    fmt.Println("  -pattern <globs>   Comma-separated file globs for -dir and -watch (default: *.bin,*.png,*.jpg,*.jpeg)")
+    fmt.Println("  -recursive         Include the subdirectories of -dir and -watch")
+    fmt.Println("  -workers <n>       Images classified in parallel with -dir (default: 4)")
+    fmt.Println("  -format <f>        Output format of the prediction or -dir results: text (default), csv, json")
+    fmt.Println("  -watch <dir>       Classify images as they appear in <dir>, appending CSV rows to -output or stdout")
+    fmt.Println("  -watch-interval <d> How often -watch polls for new images (default: 1s)")
+    fmt.Println("  -stdin             Classify images read from stdin, one JSON result per line on stdout")
+    fmt.Println("  -stdin-input <in>  raw: consecutive fixed-size image blobs (default); paths: one file per line")
+    fmt.Println("  -interactive       Shell for predict, topk, benchmark and reload commands (-image not needed)")
+    fmt.Println("  -autotune          Pick the fastest convolution algorithm per layer")
+    fmt.Println("  -tune-cache <file> Reuse/save -autotune choices in a JSON file")
+    fmt.Println("  -engine <name>     Convolution backend: auto, naive, tiled, parallel, gemm")
+    fmt.Println("  -engine-workers <n> Goroutines for the parallel backend (0 = one per CPU)")
+    fmt.Println("  -engine-pool <on|off> Reuse intermediate buffers between layers (default: on)")
+    fmt.Println("  -warmup            Touch weight pages and run a dummy inference before starting")
+    fmt.Println("  -startup-report    Break down start-up time: init, config, weights per layer, first inference")
+    fmt.Println("  -run-manifest <file> Write version, commit, config/weights hashes, engine and host to <file>")
+    fmt.Println("  -topk <n>          Print the n most probable classes with names and probabilities")
+    fmt.Println("  -min-confidence <p> Exit with status 2 if the confidence is below p (0-1)")
+    fmt.Println("  -expect-class <c>  Exit with status 3 unless the predicted class is c (index or name)")
+    fmt.Println("  -dump-activations <dir> Save every layer's output for -image to <dir>, with a manifest.json")
+    fmt.Println("  -dump-format <f>   Dump files: npy, loadable with numpy.load (default), or raw float32 arrays")
+    fmt.Println("  -gradcam <file>    Save a PNG of -image with a Grad-CAM heatmap of the predicted class")
+    fmt.Println("  -gradcam-layer <l> Conv layer the heatmap is computed on (default: the last one with a ReLU)")
+    fmt.Println("  -saliency <file>   Save a PNG of -image with the per-pixel gradient of the predicted class")
+    fmt.Println("  -smoothgrad <n>    Average -saliency over n noisy copies of the image (default: 0, plain gradient)")
+    fmt.Println("  -occlusion <file>  Save a PNG of -image showing where masking a patch lowers the confidence most")
+    fmt.Println("  -occlusion-patch <n> Side of the masked patch in pixels (default: 8)")
+    fmt.Println("  -occlusion-stride <n> Step between patches (default: half the patch)")
+    fmt.Println("  -explain-class <c> Explain class c (index or name) instead of the predicted one")
+    fmt.Println("  -porcelain         Print only stable tab-separated records (see PORCELAIN OUTPUT)")
     fmt.Println("  -version           Show version information")
     fmt.Println("  -help              Show this help message")
     
@@ -384,7 +1161,69 @@ func printHelp() {
     
     fmt.Printf("  # Benchmark mode\n")
     fmt.Printf("  %s -weights ./weights -image ./test.bin -benchmark -iterations 100\n\n", AppName)
+
+    fmt.Printf("  # Several images with the model loaded once, one result file each in ./results\n")
+    fmt.Printf("  %s -weights ./weights -output ./results img1.bin img2.png img3.png\n\n", AppName)
+
+    fmt.Printf("  # Prediction, probabilities and layer timings as JSON for scripts\n")
+    fmt.Printf("  %s -weights ./weights -image ./test.bin -format json | jq .class_name\n\n", AppName)
+
+    fmt.Printf("  # Classify a folder tree into a CSV file\n")
+    fmt.Printf("  %s -weights ./weights -dir ./photos -recursive -workers 8 -format csv -output results.csv\n\n", AppName)
+
+    fmt.Printf("  # Edge pipeline: classify whatever the camera drops into ./incoming until Ctrl-C\n")
+    fmt.Printf("  %s -weights ./weights -watch ./incoming -pattern '*.jpg' -output results.csv\n\n", AppName)
+
+    fmt.Printf("  # Stream file paths from another tool, one JSON line per image\n")
+    fmt.Printf("  find ./photos -name '*.png' | %s -weights ./weights -stdin -stdin-input paths\n\n", AppName)
+
+    fmt.Printf("  # Interactive shell: Tab completes paths, arrows recall history\n")
+    fmt.Printf("  %s -weights ./weights -interactive\n\n", AppName)
     
+    fmt.Printf("  # Per-layer outputs to diff against a Python reference: np.load('dump/sample-000000/conv1.float32.npy')\n")
+    fmt.Printf("  %s -weights ./weights -image ./test.bin -dump-activations ./dump\n\n", AppName)
+
+    fmt.Printf("  # Which part of the image made the model say truck? Red is most important\n")
+    fmt.Printf("  %s -weights ./weights -image ./test.bin -gradcam cam.png\n\n", AppName)
+
+    fmt.Printf("  # Why not airplane? The pixels the airplane score depends on, averaged over 32 noisy copies\n")
+    fmt.Printf("  %s -weights ./weights -image ./test.bin -saliency why.png -smoothgrad 32 -explain-class airplane\n\n", AppName)
+
+    fmt.Printf("  # Acceptance check in a script: the exit status says whether the model still gets it right\n")
+    fmt.Printf("  %s -weights ./weights -image ./cat.bin -expect-class cat -min-confidence 0.8 -quiet\n\n", AppName)
+
+    fmt.Println("ENVIRONMENT:")
+    fmt.Println("  GOCNN_ENGINE          Default for -engine")
+    fmt.Println("  GOCNN_ENGINE_WORKERS  Default for -engine-workers")
+    fmt.Println("  GOCNN_ENGINE_POOL     Default for -engine-pool")
+    fmt.Println("  GOCNN_HISTORY         -interactive command history file (default: ~/.gocnn_history)")
+    fmt.Println()
+    
+    fmt.Println("PORCELAIN OUTPUT (-porcelain, one tab-separated record per line, durations in ns):")
+    fmt.Println("  porcelain    <format version> <tool> <tool version>")
+    fmt.Println("  engine       <engine settings>")
+    fmt.Println("  prediction   <image> <class> <class name> <confidence> <time>")
+    fmt.Println("  probability  <class> <class name> <probability>")
+    fmt.Println("  top          <rank> <class> <class name> <probability>   (-topk)")
+    fmt.Println("  layer_time   <layer> <time>")
+    fmt.Println("  benchmark    <iterations> <total> <average> <min> <max> <images/sec> <consistent>")
+    fmt.Println("  cold         <iterations> <total> <average> <min> <max>  (-warmup-samples)")
+    fmt.Println("  startup      <phase> <time>      startup_detail <phase> <detail> <time>")
+    fmt.Println("  batch_result <image> <class> <class name> <confidence> <time> <error>   (-dir, one per image)")
+    fmt.Println("  batch        <images> <failed> <total> <images/sec>                     (-dir)")
+    fmt.Println("  activation_dump <dir> <activations> <bytes>                             (-dump-activations)")
+    fmt.Println("  gradcam      <file> <class> <class name> <layer>                        (-gradcam)")
+    fmt.Println("  saliency     <file> <class> <class name> <smoothgrad samples>           (-saliency)")
+    fmt.Println("  occlusion    <file> <class> <class name> <baseline> <max drop> <patches> (-occlusion)")
+    fmt.Println()
+    
+    fmt.Println("EXIT STATUS:")
+    fmt.Println("  0  success")
+    fmt.Println("  1  error")
+    fmt.Printf("  %d  the confidence is below -min-confidence\n", exitLowConfidence)
+    fmt.Printf("  %d  the predicted class is not -expect-class\n", exitWrongClass)
+    fmt.Println()
+
     fmt.Println("SUPPORTED IMAGE FORMAT:")
     fmt.Println("  Binary files containing 32×32×3 float32 values (12,288 bytes)")
     fmt.Println("  Data order: Height × Width × Channels (HWC)")
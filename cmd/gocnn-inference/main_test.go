@@ -1,10 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/porcelain"
 	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func createTestConfig(t *testing.T, dir string) string {
@@ -85,6 +98,64 @@ func TestValidateArgs(t *testing.T) {
     }
 }
 
+func TestValidateArgsInteractive(t *testing.T) {
+    origWeights, origImage, origConfig := *weightsPath, *imagePath, *configPath
+    origInteractive, origBenchmark := *interactive, *benchmark
+    defer func() {
+        *weightsPath, *imagePath, *configPath = origWeights, origImage, origConfig
+        *interactive, *benchmark = origInteractive, origBenchmark
+    }()
+
+    tempDir := t.TempDir()
+    *weightsPath = tempDir
+    *imagePath = ""
+    *configPath = createTestConfig(t, tempDir)
+    *interactive = true
+
+    // -interactive needs no image
+    if err := validateArgs(); err != nil {
+        t.Errorf("Unexpected error for -interactive without -image: %v", err)
+    }
+
+    *benchmark = true
+    if err := validateArgs(); err == nil {
+        t.Error("Expected error for -interactive with -benchmark")
+    }
+}
+
+func TestCompleteInteractive(t *testing.T) {
+    tempDir := t.TempDir()
+    for _, name := range []string{"cat.bin", "car.bin", ".hidden.bin"} {
+        if err := os.WriteFile(filepath.Join(tempDir, name), nil, 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := os.Mkdir(filepath.Join(tempDir, "cars"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    dir := tempDir + string(filepath.Separator)
+
+    tests := []struct {
+        line string
+        want []string
+    }{
+        {"to", []string{"topk "}},
+        {"re", []string{"reload "}},
+        {"h", []string{"help ", "history "}},
+        {"predict " + dir + "ca", []string{"predict " + dir + "car.bin", "predict " + dir + "cars/", "predict " + dir + "cat.bin"}},
+        {"topk " + dir + "cat", []string{"topk " + dir + "cat.bin"}},
+        {"topk " + dir + ".h", []string{"topk " + dir + ".hidden.bin"}},
+        {"predict " + dir + "dog", nil},
+    }
+
+    for _, tt := range tests {
+        got := completeInteractive(tt.line)
+        if !slices.Equal(got, tt.want) {
+            t.Errorf("completeInteractive(%q) = %q, want %q", tt.line, got, tt.want)
+        }
+    }
+}
+
 func TestGetClassName(t *testing.T) {
     classNames := []string{"Class0", "Class1", "Class2"}
     
@@ -147,6 +218,16 @@ func TestGetLogLevel(t *testing.T) {
     if getLogLevel() != LogQuiet {
         t.Error("Expected LogQuiet when both flags are set")
     }
+    
+    // Test porcelain implies quiet
+    origPorcelain := *porcelainMode
+    defer func() { *porcelainMode = origPorcelain }()
+    *quiet = false
+    *verbose = true
+    *porcelainMode = true
+    if getLogLevel() != LogQuiet {
+        t.Error("Expected LogQuiet with -porcelain")
+    }
 }
 
 func TestValidateImageFile(t *testing.T) {
@@ -211,4 +292,414 @@ func TestInferenceIntegration(t *testing.T) {
     // This test would require actual model weights and test data
     // For now, we just test that the application can be instantiated
     t.Log("Integration test placeholder - requires full model setup")
-}
\ No newline at end of file
+}
+
+// fixedPredictor predicts class 1 with confidence 0.75 for every image
+type fixedPredictor struct{}
+
+func (fixedPredictor) Predict(ctx context.Context, imageData []float32) (*model.PredictionResult, error) {
+    return &model.PredictionResult{PredictedClass: 1, Confidence: 0.75}, nil
+}
+
+func (p fixedPredictor) PredictBatch(ctx context.Context, images [][]float32) ([]*model.PredictionResult, error) {
+    results := make([]*model.PredictionResult, len(images))
+    for i, image := range images {
+        results[i], _ = p.Predict(ctx, image)
+    }
+    return results, nil
+}
+
+func (fixedPredictor) Info() *model.ModelInfo {
+    return &model.ModelInfo{}
+}
+
+// slowStartPredictor reports a 100ms inference for its first cold calls and 1ms after
+type slowStartPredictor struct {
+    fixedPredictor
+    cold  int
+    calls int
+}
+
+func (p *slowStartPredictor) Predict(ctx context.Context, imageData []float32) (*model.PredictionResult, error) {
+    result, _ := p.fixedPredictor.Predict(ctx, imageData)
+    result.TotalTime = time.Millisecond
+    if p.calls < p.cold {
+        result.TotalTime = 100 * time.Millisecond
+    }
+    p.calls++
+    return result, nil
+}
+
+func TestRunBenchmarkWarmupSamples(t *testing.T) {
+    origIterations, origWarmupSamples, origRecords := *iterations, *warmupSamples, records
+    defer func() {
+        *iterations, *warmupSamples, records = origIterations, origWarmupSamples, origRecords
+    }()
+
+    run := func(n, warmup int) map[string][]string {
+        var buf bytes.Buffer
+        records = porcelain.NewWriter(&buf)
+        *iterations, *warmupSamples = n, warmup
+        if err := runBenchmark(&slowStartPredictor{cold: 2}, nil, &config.Config{}); err != nil {
+            t.Fatalf("runBenchmark failed: %v", err)
+        }
+        records.Flush()
+        lines := make(map[string][]string)
+        for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+            fields := strings.Split(line, "\t")
+            lines[fields[0]] = fields[1:]
+        }
+        return lines
+    }
+
+    // Without warm-up samples the slow start sets the maximum
+    lines := run(5, 0)
+    if lines["benchmark"][0] != "5" || lines["benchmark"][4] != "100000000" {
+        t.Errorf("benchmark record without warm-up = %q", lines["benchmark"])
+    }
+    if _, ok := lines["cold"]; ok {
+        t.Errorf("Unexpected cold record without -warmup-samples: %q", lines["cold"])
+    }
+
+    lines = run(5, 2)
+    if lines["benchmark"][0] != "3" || lines["benchmark"][3] != "1000000" || lines["benchmark"][4] != "1000000" {
+        t.Errorf("benchmark record should cover the 3 warm iterations of 1ms, got %q", lines["benchmark"])
+    }
+    if cold := lines["cold"]; len(cold) != 5 || cold[0] != "2" || cold[3] != "100000000" || cold[4] != "100000000" {
+        t.Errorf("cold record should cover the 2 iterations of 100ms, got %q", cold)
+    }
+
+    // At least one iteration stays warm
+    if lines = run(2, 5); lines["benchmark"][0] != "1" || lines["cold"][0] != "1" {
+        t.Errorf("Expected 1 warm and 1 cold iteration, got %q and %q", lines["benchmark"], lines["cold"])
+    }
+}
+
+func TestBatchProcessor(t *testing.T) {
+    tempDir := t.TempDir()
+    cfg := &config.Config{Model: config.ModelConfig{
+        Name: "test", InputHeight: 32, InputWidth: 32, InputChannels: 3, NumClasses: 3,
+        ClassNames: []string{"Airplane", "Automobile", "Bird"},
+    }}
+    cfg.ApplyDefaults()
+
+    imageDir := filepath.Join(tempDir, "images")
+    if err := os.MkdirAll(filepath.Join(imageDir, "sub"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    image := createTestImage(t, tempDir)
+    for _, name := range []string{"b.bin", "a.BIN", "sub/c.bin"} {
+        raw, _ := os.ReadFile(image)
+        if err := os.WriteFile(filepath.Join(imageDir, name), raw, 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    os.WriteFile(filepath.Join(imageDir, "broken.bin"), []byte("short"), 0644)
+    os.WriteFile(filepath.Join(imageDir, "notes.txt"), []byte("not an image"), 0644)
+
+    bp := NewBatchProcessor(fixedPredictor{}, cfg)
+    bp.SetWorkers(3)
+
+    files, err := bp.FindImages(imageDir)
+    if err != nil {
+        t.Fatalf("FindImages failed: %v", err)
+    }
+    want := []string{"a.BIN", "b.bin", "broken.bin"}
+    if len(files) != len(want) {
+        t.Fatalf("FindImages found %q, want %q", files, want)
+    }
+    for i, name := range want {
+        if filepath.Base(files[i]) != name {
+            t.Errorf("file %d = %s, want %s", i, files[i], name)
+        }
+    }
+
+    bp.SetRecursive(true)
+    summary, err := bp.ProcessDirectory(imageDir)
+    if err != nil {
+        t.Fatalf("ProcessDirectory failed: %v", err)
+    }
+    if summary.Images != 4 || summary.Failed != 1 {
+        t.Errorf("got %d images with %d failed, want 4 with 1 failed", summary.Images, summary.Failed)
+    }
+    for _, r := range summary.Results {
+        broken := filepath.Base(r.Path) == "broken.bin"
+        if broken != (r.Error != "") {
+            t.Errorf("%s: error %q", r.Path, r.Error)
+        }
+        if !broken && (r.PredictedClass != 1 || r.ClassName != "Automobile" || r.Confidence != 0.75) {
+            t.Errorf("%s: got %+v", r.Path, r)
+        }
+    }
+
+    var buf bytes.Buffer
+    if err := writeBatchCSV(&buf, summary); err != nil {
+        t.Fatalf("writeBatchCSV failed: %v", err)
+    }
+    if lines := strings.Count(buf.String(), "\n"); lines != 5 {
+        t.Errorf("CSV has %d lines, want a header and 4 rows:\n%s", lines, buf.String())
+    }
+
+    bp.SetPatterns([]string{"*.txt"})
+    bp.SetRecursive(false)
+    if _, err := bp.ProcessDirectory(imageDir); err != nil {
+        t.Errorf("*.txt: %v", err)
+    }
+    bp.SetPatterns([]string{"*.png"})
+    if _, err := bp.ProcessDirectory(imageDir); err == nil {
+        t.Error("Expected error for a directory without matching files")
+    }
+}
+
+func TestParseBatchPatterns(t *testing.T) {
+    patterns, err := parseBatchPatterns("*.bin, img_*.png,,")
+    if err != nil || !slices.Equal(patterns, []string{"*.bin", "img_*.png"}) {
+        t.Errorf("got %q, %v", patterns, err)
+    }
+    if _, err := parseBatchPatterns("[a-"); err == nil {
+        t.Error("Expected error for a malformed pattern")
+    }
+    if _, err := parseBatchPatterns(" , "); err == nil {
+        t.Error("Expected error for an empty pattern list")
+    }
+}
+
+func TestInferenceOutput(t *testing.T) {
+    cfg := &config.Config{Model: config.ModelConfig{ClassNames: []string{"Airplane", "Automobile"}}}
+    result := &model.PredictionResult{
+        Probabilities:  []float32{0.25, 0.75},
+        PredictedClass: 1,
+        Confidence:     0.75,
+        LayerTimes:     map[string]time.Duration{"conv2": 2000, "conv1": 1000},
+    }
+    out := newInferenceOutput("cat.bin", result, cfg, 5000, nil)
+
+    var buf bytes.Buffer
+    if err := writeInferenceJSON(&buf, out); err != nil {
+        t.Fatalf("writeInferenceJSON failed: %v", err)
+    }
+    var decoded InferenceOutput
+    if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+        t.Fatalf("JSON output does not decode: %v\n%s", err, buf.String())
+    }
+    if decoded.ClassName != "Automobile" || len(decoded.Probabilities) != 2 ||
+        decoded.Probabilities[0].Name != "Airplane" || decoded.LayerTimes["conv2"] != 2000 {
+        t.Errorf("JSON output decoded to %+v", decoded)
+    }
+
+    buf.Reset()
+    if err := writeInferenceCSV(&buf, []*InferenceOutput{out, out}); err != nil {
+        t.Fatalf("writeInferenceCSV failed: %v", err)
+    }
+    rows, err := csv.NewReader(&buf).ReadAll()
+    if err != nil || len(rows) != 3 {
+        t.Fatalf("CSV output: %q, %v", rows, err)
+    }
+    wantHeader := []string{"image", "predicted_class", "class_name", "confidence", "inference_time_ns", "engine",
+        "probability_Airplane", "probability_Automobile", "layer_conv1_ns", "layer_conv2_ns"}
+    if !slices.Equal(rows[0], wantHeader) {
+        t.Errorf("CSV header = %q, want %q", rows[0], wantHeader)
+    }
+    if rows[1][2] != "Automobile" || rows[1][4] != "5000" || rows[1][8] != "1000" {
+        t.Errorf("CSV row = %q", rows[1])
+    }
+}
+
+func TestStreamInference(t *testing.T) {
+    tempDir := t.TempDir()
+    cfg := &config.Config{Model: config.ModelConfig{
+        Name: "test", InputHeight: 32, InputWidth: 32, InputChannels: 3, NumClasses: 3,
+        ClassNames: []string{"Airplane", "Automobile", "Bird"},
+    }}
+    cfg.ApplyDefaults()
+    preprocessor, err := data.NewPreprocessor(data.BinaryFloat32, cfg.Data, cfg.Model)
+    if err != nil {
+        t.Fatalf("NewPreprocessor failed: %v", err)
+    }
+    imagePath := createTestImage(t, tempDir)
+    blob, _ := os.ReadFile(imagePath)
+
+    decode := func(out *bytes.Buffer) []StreamResult {
+        var results []StreamResult
+        for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+            var r StreamResult
+            if err := json.Unmarshal([]byte(line), &r); err != nil {
+                t.Fatalf("line %q is not JSON: %v", line, err)
+            }
+            results = append(results, r)
+        }
+        return results
+    }
+
+    // Two blobs, then a truncated one that ends the stream
+    in := bytes.NewReader(append(append(append([]byte{}, blob...), blob...), blob[:100]...))
+    var out bytes.Buffer
+    images, failed, err := streamInference(fixedPredictor{}, preprocessor, cfg, in, &out, stdinRaw)
+    if err != nil || images != 3 || failed != 1 {
+        t.Fatalf("raw: got %d images, %d failed, %v; want 3, 1, nil", images, failed, err)
+    }
+    results := decode(&out)
+    if len(results) != 3 || results[1].Index != 1 || results[1].ClassName != "Automobile" || results[2].Error == "" {
+        t.Errorf("raw results: %+v", results)
+    }
+
+    // Paths, skipping blank lines and carrying on past a missing file
+    out.Reset()
+    in = bytes.NewReader([]byte(imagePath + "\n\n" + filepath.Join(tempDir, "missing.bin") + "\n" + imagePath + "\n"))
+    images, failed, err = streamInference(fixedPredictor{}, preprocessor, cfg, in, &out, stdinPaths)
+    if err != nil || images != 3 || failed != 1 {
+        t.Fatalf("paths: got %d images, %d failed, %v; want 3, 1, nil", images, failed, err)
+    }
+    results = decode(&out)
+    if results[0].Image != imagePath || results[1].Error == "" || results[2].Index != 2 || results[2].Confidence != 0.75 {
+        t.Errorf("paths results: %+v", results)
+    }
+}
+
+func TestResolveClass(t *testing.T) {
+    classNames := []string{"airplane", "automobile", "bird"}
+    for input, want := range map[string]int{"0": 0, "2": 2, "bird": 2, "Automobile": 1} {
+        got, err := resolveClass(input, classNames)
+        if err != nil || got != want {
+            t.Errorf("resolveClass(%q) = %d, %v; want %d", input, got, err, want)
+        }
+    }
+    for _, input := range []string{"3", "-1", "truck"} {
+        if _, err := resolveClass(input, classNames); err == nil {
+            t.Errorf("resolveClass(%q): expected an error", input)
+        }
+    }
+}
+
+func TestCheckPrediction(t *testing.T) {
+    origMin := *minConfidence
+    defer func() { *minConfidence = origMin }()
+
+    cfg := &config.Config{Model: config.ModelConfig{ClassNames: []string{"airplane", "automobile"}}}
+    result := &model.PredictionResult{PredictedClass: 1, Confidence: 0.6}
+
+    *minConfidence = 0.5
+    if err := checkPrediction(result, 1, cfg); err != nil {
+        t.Errorf("Unexpected error for a passing prediction: %v", err)
+    }
+    if err := checkPrediction(result, -1, cfg); err != nil {
+        t.Errorf("Unexpected error without -expect-class: %v", err)
+    }
+
+    *minConfidence = 0.7
+    if err := checkPrediction(result, 1, cfg); !errors.Is(err, errLowConfidence) {
+        t.Errorf("got %v, want errLowConfidence", err)
+    }
+    // A wrong class wins over a low confidence
+    if err := checkPrediction(result, 0, cfg); !errors.Is(err, errWrongClass) {
+        t.Errorf("got %v, want errWrongClass", err)
+    }
+}
+
+func TestImagePaths(t *testing.T) {
+    origImage := *imagePath
+    defer func() {
+        *imagePath = origImage
+        flag.CommandLine.Parse(nil)
+    }()
+
+    *imagePath = "first.bin"
+    flag.CommandLine.Parse([]string{"second.png", "third.png"})
+    if got, want := imagePaths(), []string{"first.bin", "second.png", "third.png"}; !slices.Equal(got, want) {
+        t.Errorf("imagePaths() = %q, want %q", got, want)
+    }
+
+    *imagePath = ""
+    if got := imagePaths(); len(got) != 2 {
+        t.Errorf("imagePaths() without -image = %q, want the 2 arguments", got)
+    }
+}
+
+func TestResultFileNames(t *testing.T) {
+    origFormat := *outputFormat
+    defer func() { *outputFormat = origFormat }()
+
+    *outputFormat = "json"
+    files, err := resultFileNames([]string{"a/cat.bin", "b/dog.png", "a/cat.bin"}, "out")
+    if err != nil {
+        t.Fatalf("resultFileNames failed: %v", err)
+    }
+    if files["a/cat.bin"] != filepath.Join("out", "cat.json") || files["b/dog.png"] != filepath.Join("out", "dog.json") {
+        t.Errorf("resultFileNames = %v", files)
+    }
+
+    // Two images with the same base name would overwrite each other's results
+    if _, err := resultFileNames([]string{"a/cat.bin", "b/cat.png"}, "out"); err == nil {
+        t.Error("Expected error for images saved to the same file")
+    }
+}
+
+func TestWatcher(t *testing.T) {
+    dir := t.TempDir()
+    write := func(name, content string) {
+        if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    write("old.bin", "old")
+
+    watcher := NewWatcher(NewBatchProcessor(fixedPredictor{}, &config.Config{}), dir)
+    if err := watcher.Prime(); err != nil {
+        t.Fatalf("Prime failed: %v", err)
+    }
+
+    poll := func() []string {
+        ready, err := watcher.Poll()
+        if err != nil {
+            t.Fatalf("Poll failed: %v", err)
+        }
+        names := make([]string, len(ready))
+        for i, path := range ready {
+            names[i] = filepath.Base(path)
+        }
+        return names
+    }
+
+    // Files present at Prime are skipped; new ones are ready once their size holds
+    write("new.bin", "partial")
+    write("notes.txt", "ignored")
+    if got := poll(); len(got) != 0 {
+        t.Errorf("first poll = %q, want nothing until the size is stable", got)
+    }
+    write("new.bin", "partial, now complete")
+    if got := poll(); len(got) != 0 {
+        t.Errorf("poll after growth = %q, want nothing", got)
+    }
+    if got := poll(); !slices.Equal(got, []string{"new.bin"}) {
+        t.Errorf("poll = %q, want [new.bin]", got)
+    }
+    if got := poll(); len(got) != 0 {
+        t.Errorf("poll after handing out new.bin = %q, want nothing", got)
+    }
+}
+
+func TestTopKOutput(t *testing.T) {
+    origTopK := *topK
+    defer func() { *topK = origTopK }()
+
+    classNames := []string{"airplane", "automobile", "bird"}
+    result := &model.PredictionResult{Probabilities: []float32{0.2, 0.1, 0.7}, PredictedClass: 2, Confidence: 0.7}
+
+    var buf bytes.Buffer
+    printTopK(&buf, result.TopK(2), classNames, "  ")
+    lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+    if len(lines) != 2 || !strings.Contains(lines[0], "1. 2 bird") || !strings.Contains(lines[1], "2. 0 airplane") {
+        t.Errorf("printTopK wrote:\n%s", buf.String())
+    }
+
+    *topK = 2
+    out := newInferenceOutput("x.bin", result, &config.Config{Model: config.ModelConfig{ClassNames: classNames}}, 0, nil)
+    if len(out.TopK) != 2 || out.TopK[0].Name != "bird" || out.TopK[1].Class != 0 {
+        t.Errorf("TopK = %+v", out.TopK)
+    }
+
+    *topK = 0
+    if out := newInferenceOutput("x.bin", result, &config.Config{}, 0, nil); out.TopK != nil {
+        t.Errorf("TopK without -topk = %+v, want none", out.TopK)
+    }
+}
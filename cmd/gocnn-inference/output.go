@@ -0,0 +1,111 @@
+package main
+
+import (
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/runinfo"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"duchm1606/gocnn/internal/config"
+)
+
+// ClassOutput is the probability of one class in -format json output
+type ClassOutput struct {
+    Class       int     `json:"class"`
+    Name        string  `json:"name"`
+    Probability float32 `json:"probability"`
+}
+
+// InferenceOutput is a single prediction as -format json writes it
+type InferenceOutput struct {
+    Image          string                   `json:"image"`
+    PredictedClass int                      `json:"predicted_class"`
+    ClassName      string                   `json:"class_name"`
+    Confidence     float32                  `json:"confidence"`
+    InferenceTime  time.Duration            `json:"inference_time"`
+    Engine         string                   `json:"engine"`
+    Probabilities  []ClassOutput            `json:"probabilities"`
+    TopK           []ClassOutput            `json:"top_k,omitempty"` // The -topk most probable classes, most probable first
+    LayerTimes     map[string]time.Duration `json:"layer_times"`
+    Run            *runinfo.Manifest        `json:"run,omitempty"`
+}
+
+// newInferenceOutput collects a prediction of imagePath, taking totalTime to run
+func newInferenceOutput(imagePath string, result *model.PredictionResult, cfg *config.Config,
+    totalTime time.Duration, run *runinfo.Manifest) *InferenceOutput {
+
+    out := &InferenceOutput{
+        Image:          imagePath,
+        PredictedClass: result.PredictedClass,
+        ClassName:      getClassName(result.PredictedClass, cfg.Model.ClassNames),
+        Confidence:     result.Confidence,
+        InferenceTime:  totalTime,
+        Engine:         result.Engine.String(),
+        Probabilities:  make([]ClassOutput, len(result.Probabilities)),
+        LayerTimes:     result.LayerTimes,
+        Run:            run,
+    }
+    for i, prob := range result.Probabilities {
+        out.Probabilities[i] = ClassOutput{Class: i, Name: getClassName(i, cfg.Model.ClassNames), Probability: prob}
+    }
+    if *topK > 0 {
+        for _, class := range result.TopK(*topK) {
+            out.TopK = append(out.TopK, out.Probabilities[class.Class])
+        }
+    }
+    return out
+}
+
+// writeInferenceJSON writes a prediction, or a slice of them, as indented JSON
+func writeInferenceJSON(w io.Writer, out any) error {
+    encoder := json.NewEncoder(w)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(out)
+}
+
+// writeInferenceCSV writes predictions as a header and one row each: the prediction,
+// then a probability_<class name> column per class and a layer_<name>_ns column
+// per layer, sorted by layer; times are in nanoseconds. The columns are those of
+// the first prediction.
+func writeInferenceCSV(w io.Writer, outs []*InferenceOutput) error {
+    first := outs[0]
+    header := []string{"image", "predicted_class", "class_name", "confidence", "inference_time_ns", "engine"}
+    for _, class := range first.Probabilities {
+        header = append(header, fmt.Sprintf("probability_%s", class.Name))
+    }
+    layers := make([]string, 0, len(first.LayerTimes))
+    for layer := range first.LayerTimes {
+        layers = append(layers, layer)
+    }
+    sort.Strings(layers)
+    for _, layer := range layers {
+        header = append(header, fmt.Sprintf("layer_%s_ns", layer))
+    }
+
+    cw := csv.NewWriter(w)
+    cw.Write(header)
+    for _, out := range outs {
+        row := []string{
+            out.Image,
+            strconv.Itoa(out.PredictedClass),
+            out.ClassName,
+            strconv.FormatFloat(float64(out.Confidence), 'f', 6, 32),
+            strconv.FormatInt(out.InferenceTime.Nanoseconds(), 10),
+            out.Engine,
+        }
+        for _, class := range out.Probabilities {
+            row = append(row, strconv.FormatFloat(float64(class.Probability), 'f', 8, 32))
+        }
+        for _, layer := range layers {
+            row = append(row, strconv.FormatInt(out.LayerTimes[layer].Nanoseconds(), 10))
+        }
+        cw.Write(row)
+    }
+    cw.Flush()
+    return cw.Error()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"duchm1606/gocnn/internal/config"
+)
+
+/**
+* Stdin streaming
+
+-stdin turns gocnn-inference into a filter: images go in on stdin, one JSON
+result per line comes out on stdout as soon as each is classified, so it
+pipes from and into other tools without a model load per image:
+```
+cat img1.bin img2.bin | gocnn-inference -weights w -stdin               (raw, fixed-size blobs)
+find photos -name '*.png' | gocnn-inference -weights w -stdin -stdin-input paths
+  → {"index":0,"image":"photos/a.png","predicted_class":3,"class_name":"cat",...}
+```
+Raw blobs have the stored size in -image-format (12288 bytes for 32×32×3
+float32). An image that fails gets a line with its error and the stream
+carries on, except for a truncated raw blob, which ends it.
+*/
+
+// Stdin input kinds of -stdin-input
+const (
+    stdinRaw   = "raw"
+    stdinPaths = "paths"
+)
+
+// StreamResult is one line of -stdin output
+type StreamResult struct {
+    Index          int           `json:"index"`           // Position in the input, from 0
+    Image          string        `json:"image,omitempty"` // Path, with -stdin-input paths
+    PredictedClass int           `json:"predicted_class"`
+    ClassName      string        `json:"class_name,omitempty"`
+    Confidence     float32       `json:"confidence"`
+    Probabilities  []float32     `json:"probabilities,omitempty"`
+    InferenceTime  time.Duration `json:"inference_time"`
+    Error          string        `json:"error,omitempty"` // Set when the image could not be classified
+}
+
+// streamInference classifies the images of in, raw blobs or paths per input, and
+// writes one StreamResult per line to out; it returns the images read and failed
+func streamInference(cnn model.Predictor, preprocessor *data.Preprocessor, cfg *config.Config,
+    in io.Reader, out io.Writer, input string) (images, failed int, err error) {
+
+    encoder := json.NewEncoder(out)
+    emit := func(result StreamResult) error {
+        images++
+        if result.Error != "" {
+            failed++
+        }
+        return encoder.Encode(result)
+    }
+
+    if input == stdinPaths {
+        scanner := bufio.NewScanner(in)
+        for scanner.Scan() {
+            path := strings.TrimSpace(scanner.Text())
+            if path == "" {
+                continue
+            }
+            result := StreamResult{Index: images, Image: path}
+            fm, err := preprocessor.Load(path)
+            if err == nil {
+                err = predictStreamImage(cnn, fm.Data, cfg, &result)
+            }
+            if err != nil {
+                result.PredictedClass, result.Error = -1, err.Error()
+            }
+            if err := emit(result); err != nil {
+                return images, failed, err
+            }
+        }
+        return images, failed, scanner.Err()
+    }
+
+    reader := bufio.NewReader(in)
+    blob := make([]byte, preprocessor.ImageBytes())
+    for {
+        n, err := io.ReadFull(reader, blob)
+        if err == io.EOF {
+            return images, failed, nil
+        }
+
+        result := StreamResult{Index: images}
+        if errors.Is(err, io.ErrUnexpectedEOF) {
+            result.PredictedClass = -1
+            result.Error = fmt.Sprintf("truncated image: got %d of %d bytes", n, len(blob))
+            return images + 1, failed + 1, encoder.Encode(result)
+        }
+        if err != nil {
+            return images, failed, err
+        }
+
+        fm, err := preprocessor.Decode(blob)
+        if err == nil {
+            err = predictStreamImage(cnn, fm.Data, cfg, &result)
+        }
+        if err != nil {
+            result.PredictedClass, result.Error = -1, err.Error()
+        }
+        if err := emit(result); err != nil {
+            return images, failed, err
+        }
+    }
+}
+
+// predictStreamImage classifies imageData into result
+func predictStreamImage(cnn model.Predictor, imageData []float32, cfg *config.Config, result *StreamResult) error {
+    start := time.Now()
+    prediction, err := cnn.Predict(context.Background(), imageData)
+    if err != nil {
+        return err
+    }
+    result.InferenceTime = time.Since(start)
+    result.PredictedClass = prediction.PredictedClass
+    result.ClassName = getClassName(prediction.PredictedClass, cfg.Model.ClassNames)
+    result.Confidence = prediction.Confidence
+    result.Probabilities = prediction.Probabilities
+    return nil
+}
+
+// runStdin classifies the images streamed on stdin, writing JSON lines to stdout
+func runStdin(cnn model.Predictor, cfg *config.Config) error {
+    format, err := data.ParseImageFormat(*imageFormat)
+    if err != nil {
+        return err
+    }
+    preprocessor, err := data.NewPreprocessor(format, cfg.Data, cfg.Model)
+    if err != nil {
+        return err
+    }
+
+    images, failed, err := streamInference(cnn, preprocessor, cfg, os.Stdin, os.Stdout, *stdinInput)
+    if err != nil {
+        return fmt.Errorf("stdin stream: %w", err)
+    }
+    if failed > 0 {
+        return fmt.Errorf("%d of %d images could not be classified", failed, images)
+    }
+    return nil
+}
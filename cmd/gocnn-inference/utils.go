@@ -1,9 +1,12 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"duchm1606/gocnn/internal/lineedit"
 	"duchm1606/gocnn/internal/model"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,35 +15,66 @@ import (
 	"duchm1606/gocnn/internal/config"
 )
 
-// InteractiveMode provides an interactive shell for multiple predictions
-func runInteractiveMode(cnn *model.TinyCNN, cfg *config.Config) error {
+// Interactive mode settings
+const (
+    interactivePrompt = "gocnn> "
+    defaultTopK       = 5
+)
+
+// interactiveCommands are the commands of interactive mode, completed on Tab
+var interactiveCommands = []string{"benchmark", "help", "history", "info", "predict", "quit", "reload", "topk"}
+
+// weightReloader is a Predictor whose weights can be swapped while it runs
+type weightReloader interface {
+    ReloadWeights(path string) error
+}
+
+// runInteractiveMode provides an interactive shell for multiple predictions
+// weights is the directory the model was loaded from, the default of reload.
+func runInteractiveMode(cnn model.Predictor, cfg *config.Config, weights string) error {
     fmt.Println("Entering interactive mode. Type 'help' for commands, 'quit' to exit.")
-    
-    scanner := bufio.NewScanner(os.Stdin)
-    
+
+    editor := lineedit.New(os.Stdin, os.Stdout)
+    editor.SetCompleter(completeInteractive)
+    historyPath := interactiveHistoryPath()
+    if historyPath != "" {
+        if f, err := os.Open(historyPath); err == nil {
+            editor.ReadHistory(f)
+            f.Close()
+        }
+        defer saveInteractiveHistory(editor, historyPath)
+    }
+
     for {
-        fmt.Print("gocnn> ")
-        
-        if !scanner.Scan() {
-            break
+        line, err := editor.ReadLine(interactivePrompt)
+        if errors.Is(err, lineedit.ErrInterrupted) {
+            continue
+        }
+        if err == io.EOF {
+            fmt.Println("Goodbye!")
+            return nil
+        }
+        if err != nil {
+            return err
         }
-        
-        line := strings.TrimSpace(scanner.Text())
+
+        line = strings.TrimSpace(line)
         if line == "" {
             continue
         }
-        
+        editor.AddHistory(line)
+
         parts := strings.Fields(line)
         command := parts[0]
-        
+
         switch command {
         case "help", "h":
             printInteractiveHelp()
-            
+
         case "quit", "exit", "q":
             fmt.Println("Goodbye!")
             return nil
-            
+
         case "predict", "p":
             if len(parts) < 2 {
                 fmt.Println("Usage: predict <image_path>")
@@ -50,10 +84,24 @@ func runInteractiveMode(cnn *model.TinyCNN, cfg *config.Config) error {
             if err != nil {
                 fmt.Printf("Prediction failed: %v\n", err)
             }
-            
+
+        case "topk", "t":
+            if len(parts) < 2 {
+                fmt.Println("Usage: topk <image_path> [k]")
+                continue
+            }
+            k := defaultTopK
+            if len(parts) >= 3 {
+                fmt.Sscanf(parts[2], "%d", &k)
+            }
+            err := runInteractiveTopK(cnn, parts[1], k, cfg)
+            if err != nil {
+                fmt.Printf("Prediction failed: %v\n", err)
+            }
+
         case "info", "i":
             printModelInfo(cnn)
-            
+
         case "benchmark", "b":
             if len(parts) < 2 {
                 fmt.Println("Usage: benchmark <image_path> [iterations]")
@@ -67,27 +115,121 @@ func runInteractiveMode(cnn *model.TinyCNN, cfg *config.Config) error {
             if err != nil {
                 fmt.Printf("Benchmark failed: %v\n", err)
             }
-            
+
+        case "history":
+            for i, entry := range editor.History() {
+                fmt.Printf("%5d  %s\n", i+1, entry)
+            }
+
+        case "reload", "r":
+            path := weights
+            if len(parts) >= 2 {
+                path = parts[1]
+            }
+            if err := runInteractiveReload(cnn, path); err != nil {
+                fmt.Printf("Reload failed: %v\n", err)
+                continue
+            }
+            weights = path
+
         default:
             fmt.Printf("Unknown command: %s. Type 'help' for available commands.\n", command)
         }
     }
-    
-    return scanner.Err()
 }
 
 // printInteractiveHelp shows help for interactive mode
 func printInteractiveHelp() {
     fmt.Println("Available commands:")
-    fmt.Println("  predict <image>     Run inference on an image")
-    fmt.Println("  benchmark <image>   Run benchmark on an image")
-    fmt.Println("  info               Show model information")
-    fmt.Println("  help               Show this help")
-    fmt.Println("  quit               Exit interactive mode")
+    fmt.Println("  predict <image>          Run inference on an image")
+    fmt.Println("  topk <image> [k]         Show the k most probable classes (default 5)")
+    fmt.Println("  benchmark <image> [n]    Run benchmark on an image (default 10 iterations)")
+    fmt.Println("  reload [weights]         Swap in new weights without restarting (default: the loaded directory)")
+    fmt.Println("  info                     Show model information")
+    fmt.Println("  history                  List the commands entered")
+    fmt.Println("  help                     Show this help")
+    fmt.Println("  quit                     Exit interactive mode")
+    fmt.Println("Tab completes commands and file paths; the arrow keys edit the line and recall history.")
+}
+
+// interactiveHistoryPath returns the file keeping the command history between
+// sessions: $GOCNN_HISTORY, or .gocnn_history in the home directory
+func interactiveHistoryPath() string {
+    if path := os.Getenv("GOCNN_HISTORY"); path != "" {
+        return path
+    }
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return ""
+    }
+    return filepath.Join(home, ".gocnn_history")
+}
+
+// saveInteractiveHistory writes the history of editor to path
+func saveInteractiveHistory(editor *lineedit.Editor, path string) {
+    f, err := os.Create(path)
+    if err == nil {
+        err = editor.WriteHistory(f)
+        if closeErr := f.Close(); err == nil {
+            err = closeErr
+        }
+    }
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Warning: could not save command history: %v\n", err)
+    }
+}
+
+// completeInteractive completes a command name, or the file path being typed as
+// an argument, returning whole lines
+func completeInteractive(line string) []string {
+    start := strings.LastIndexAny(line, " \t") + 1
+    head, word := line[:start], line[start:]
+
+    var candidates []string
+    if strings.TrimSpace(head) == "" {
+        for _, command := range interactiveCommands {
+            if strings.HasPrefix(command, word) {
+                candidates = append(candidates, head+command+" ")
+            }
+        }
+        return candidates
+    }
+
+    for _, path := range completePath(word) {
+        candidates = append(candidates, head+path)
+    }
+    return candidates
+}
+
+// completePath returns the files and directories starting with prefix, directories
+// ending with a separator; hidden ones only when prefix names them
+func completePath(prefix string) []string {
+    dir, base := filepath.Split(prefix)
+    readDir := dir
+    if readDir == "" {
+        readDir = "."
+    }
+    entries, err := os.ReadDir(readDir)
+    if err != nil {
+        return nil
+    }
+
+    var paths []string
+    for _, entry := range entries {
+        name := entry.Name()
+        if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+            continue
+        }
+        if entry.IsDir() {
+            name += string(filepath.Separator)
+        }
+        paths = append(paths, dir+name)
+    }
+    return paths
 }
 
 // runInteractivePrediction runs a single prediction in interactive mode
-func runInteractivePrediction(cnn *model.TinyCNN, imagePath string, cfg *config.Config) error {
+func runInteractivePrediction(cnn model.Predictor, imagePath string, cfg *config.Config) error {
     // Check if file exists
     if _, err := os.Stat(imagePath); os.IsNotExist(err) {
         return fmt.Errorf("image file does not exist: %s", imagePath)
@@ -101,7 +243,7 @@ func runInteractivePrediction(cnn *model.TinyCNN, imagePath string, cfg *config.
     
     // Run prediction
     start := time.Now()
-    result, err := cnn.Predict(imageData)
+    result, err := cnn.Predict(context.Background(), imageData)
     if err != nil {
         return err
     }
@@ -118,8 +260,54 @@ func runInteractivePrediction(cnn *model.TinyCNN, imagePath string, cfg *config.
     return nil
 }
 
+// runInteractiveTopK shows the k most probable classes of an image
+func runInteractiveTopK(cnn model.Predictor, imagePath string, k int, cfg *config.Config) error {
+    if k <= 0 {
+        return fmt.Errorf("k must be positive, got %d", k)
+    }
+    imageData, err := loadImage(imagePath, cfg)
+    if err != nil {
+        return err
+    }
+
+    start := time.Now()
+    result, err := cnn.Predict(context.Background(), imageData)
+    if err != nil {
+        return err
+    }
+    elapsed := time.Since(start)
+
+    fmt.Printf("Image: %s\n", filepath.Base(imagePath))
+    printTopK(os.Stdout, result.TopK(k), cfg.Model.ClassNames, "  ")
+    fmt.Printf("Inference time: %v\n\n", elapsed)
+    return nil
+}
+
+// printTopK writes one ranked line per class of top, each starting with indent
+func printTopK(w io.Writer, top []model.ClassProbability, classNames []string, indent string) {
+    for i, class := range top {
+        fmt.Fprintf(w, "%s%d. %d %-12s %.4f (%.2f%%)\n", indent, i+1, class.Class,
+            getClassName(class.Class, classNames), class.Probability, class.Probability*100)
+    }
+}
+
+// runInteractiveReload swaps the weights in path into cnn
+func runInteractiveReload(cnn model.Predictor, path string) error {
+    reloader, ok := cnn.(weightReloader)
+    if !ok {
+        return fmt.Errorf("this model cannot reload its weights")
+    }
+
+    start := time.Now()
+    if err := reloader.ReloadWeights(path); err != nil {
+        return err
+    }
+    fmt.Printf("Reloaded weights from %s in %v\n\n", path, time.Since(start))
+    return nil
+}
+
 // runInteractiveBenchmark runs a benchmark in interactive mode
-func runInteractiveBenchmark(cnn *model.TinyCNN, imagePath string, iterations int, cfg *config.Config) error {
+func runInteractiveBenchmark(cnn model.Predictor, imagePath string, iterations int, cfg *config.Config) error {
     // Load image
     imageData, err := loadImage(imagePath, cfg)
     if err != nil {
@@ -130,7 +318,7 @@ func runInteractiveBenchmark(cnn *model.TinyCNN, imagePath string, iterations in
     
     start := time.Now()
     for i := 0; i < iterations; i++ {
-        _, err := cnn.Predict(imageData)
+        _, err := cnn.Predict(context.Background(), imageData)
         if err != nil {
             return fmt.Errorf("iteration %d failed: %w", i+1, err)
         }
@@ -149,8 +337,8 @@ func runInteractiveBenchmark(cnn *model.TinyCNN, imagePath string, iterations in
 }
 
 // printModelInfo displays detailed model information
-func printModelInfo(cnn *model.TinyCNN) {
-    info := cnn.GetModelInfo()
+func printModelInfo(cnn model.Predictor) {
+    info := cnn.Info()
     
     fmt.Println("Model Information:")
     fmt.Printf("  Architecture: TinyCNN for CIFAR-10\n")
@@ -172,97 +360,6 @@ func printModelInfo(cnn *model.TinyCNN) {
     fmt.Println()
 }
 
-// BatchProcessor handles batch processing of multiple images
-type BatchProcessor struct {
-    cnn    *model.TinyCNN
-    config *config.Config
-}
-
-// NewBatchProcessor creates a new batch processor
-func NewBatchProcessor(cnn *model.TinyCNN, cfg *config.Config) *BatchProcessor {
-    return &BatchProcessor{
-        cnn:    cnn,
-        config: cfg,
-    }
-}
-
-// ProcessDirectory processes all images in a directory
-func (bp *BatchProcessor) ProcessDirectory(dirPath, outputPath string) error {
-    // Find all image files
-    files, err := filepath.Glob(filepath.Join(dirPath, "*.bin"))
-    if err != nil {
-        return fmt.Errorf("failed to find image files: %w", err)
-    }
-    
-    if len(files) == 0 {
-        return fmt.Errorf("no .bin files found in directory: %s", dirPath)
-    }
-    
-    fmt.Printf("Processing %d images from %s...\n", len(files), dirPath)
-    
-    // Create output file
-    var outputFile *os.File
-    if outputPath != "" {
-        outputFile, err = os.Create(outputPath)
-        if err != nil {
-            return fmt.Errorf("failed to create output file: %w", err)
-        }
-        defer outputFile.Close()
-        
-        // Write header
-        fmt.Fprintf(outputFile, "Filename,PredictedClass,ClassName,Confidence,InferenceTime\n")
-    }
-    
-    // Process each image
-    totalStart := time.Now()
-    // correct := 0
-    
-    for i, file := range files {
-        // Load and predict
-        imageData, err := loadImage(file, bp.config)
-        if err != nil {
-            fmt.Printf("Failed to load %s: %v\n", filepath.Base(file), err)
-            continue
-        }
-        
-        start := time.Now()
-        result, err := bp.cnn.Predict(imageData)
-        if err != nil {
-            fmt.Printf("Failed to predict %s: %v\n", filepath.Base(file), err)
-            continue
-        }
-        inferenceTime := time.Since(start)
-        
-        // Display progress
-        if (i+1)%10 == 0 || i == len(files)-1 {
-            fmt.Printf("  Processed %d/%d images\n", i+1, len(files))
-        }
-        
-        // Write to output file
-        if outputFile != nil {
-            filename := filepath.Base(file)
-            className := getClassName(result.PredictedClass, bp.config.Model.ClassNames)
-            fmt.Fprintf(outputFile, "%s,%d,%s,%.6f,%v\n",
-                filename, result.PredictedClass, className, 
-                result.Confidence, inferenceTime)
-        }
-    }
-    
-    totalTime := time.Since(totalStart)
-    
-    fmt.Printf("\nBatch processing completed:\n")
-    fmt.Printf("  Total images: %d\n", len(files))
-    fmt.Printf("  Total time: %v\n", totalTime)
-    fmt.Printf("  Average time per image: %v\n", totalTime/time.Duration(len(files)))
-    fmt.Printf("  Throughput: %.2f images/sec\n", float64(len(files))/totalTime.Seconds())
-    
-    if outputPath != "" {
-        fmt.Printf("  Results saved to: %s\n", outputPath)
-    }
-    
-    return nil
-}
-
 // ValidateImageFile checks if a file is a valid image for the model
 func ValidateImageFile(imagePath string, expectedSize int64) error {
     info, err := os.Stat(imagePath)
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/model"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"duchm1606/gocnn/internal/config"
+)
+
+/**
+* Watch-folder mode
+
+-watch keeps the model loaded and classifies images as they are dropped
+into a folder, by a camera, a scanner or another program, appending one CSV
+row per image to -output (or stdout) until interrupted:
+```
+poll every -watch-interval:
+  new file matching -pattern    → pending, remember its size
+  pending, same size as before  → classify, append row, flush
+```
+Waiting for a file's size to hold still over one interval keeps a file that
+is still being copied from being read half-written. Files already in the
+folder at start are skipped; the run only handles what arrives after it.
+Polling needs no platform notification API and works on network shares.
+*/
+
+// Watcher finds the images that appear in a directory
+type Watcher struct {
+    processor *BatchProcessor // Selects the files, through its patterns and recursion
+    dir       string
+    seen      map[string]bool  // Files handed out by Poll, or there at Prime
+    pending   map[string]int64 // New files and their size at the last poll
+}
+
+// NewWatcher creates a watcher of the images processor would find in dir
+func NewWatcher(processor *BatchProcessor, dir string) *Watcher {
+    return &Watcher{
+        processor: processor,
+        dir:       dir,
+        seen:      make(map[string]bool),
+        pending:   make(map[string]int64),
+    }
+}
+
+// Prime marks the images already in the directory as seen, so Poll skips them
+func (w *Watcher) Prime() error {
+    files, err := w.processor.FindImages(w.dir)
+    if err != nil {
+        return err
+    }
+    for _, file := range files {
+        w.seen[file] = true
+    }
+    return nil
+}
+
+// Poll returns the new images whose size has not changed since the previous poll,
+// in lexical order; each image is returned once
+func (w *Watcher) Poll() ([]string, error) {
+    files, err := w.processor.FindImages(w.dir)
+    if err != nil {
+        return nil, err
+    }
+
+    var ready []string
+    for _, file := range files {
+        if w.seen[file] {
+            continue
+        }
+        info, err := os.Stat(file)
+        if err != nil {
+            continue // Removed since it was listed
+        }
+
+        size := info.Size()
+        if previous, ok := w.pending[file]; ok && previous == size && size > 0 {
+            delete(w.pending, file)
+            w.seen[file] = true
+            ready = append(ready, file)
+            continue
+        }
+        w.pending[file] = size
+    }
+    return ready, nil
+}
+
+// runWatch classifies the images appearing in -watch until interrupted, appending a
+// CSV row for each to -output, or writing them to stdout
+func runWatch(cnn model.Predictor, cfg *config.Config) error {
+    processor := NewBatchProcessor(cnn, cfg)
+    if *batchPattern != "" {
+        patterns, err := parseBatchPatterns(*batchPattern)
+        if err != nil {
+            return fmt.Errorf("-pattern: %w", err)
+        }
+        processor.SetPatterns(patterns)
+    }
+    processor.SetRecursive(*recursive)
+
+    watcher := NewWatcher(processor, *watchDir)
+    if err := watcher.Prime(); err != nil {
+        return err
+    }
+
+    out, header, err := openWatchOutput(*outputPath)
+    if err != nil {
+        return err
+    }
+    defer out.Close()
+    cw := csv.NewWriter(out)
+    if header {
+        cw.Write(batchCSVHeader)
+        cw.Flush()
+    }
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+    slog.Info("watching for new images (Ctrl-C to stop)", "dir", *watchDir, "interval", *watchInterval)
+
+    ticker := time.NewTicker(*watchInterval)
+    defer ticker.Stop()
+    images, failed := 0, 0
+    for {
+        select {
+        case <-ctx.Done():
+            slog.Info("stopped watching", "images", images, "failed", failed)
+            return nil
+        case <-ticker.C:
+        }
+
+        ready, err := watcher.Poll()
+        if err != nil {
+            return err
+        }
+        for _, file := range ready {
+            result := processor.processImage(file)
+            images++
+            if result.Error != "" {
+                failed++
+            }
+            cw.Write(batchCSVRow(result))
+            cw.Flush()
+            if err := cw.Error(); err != nil {
+                return fmt.Errorf("failed to write results: %w", err)
+            }
+
+            if result.Error != "" {
+                slog.Error("classification failed", "image", file, "error", result.Error)
+            } else {
+                slog.Info("classified", "image", file, "class", result.PredictedClass,
+                    "class_name", result.ClassName, "confidence", result.Confidence)
+            }
+        }
+    }
+}
+
+// openWatchOutput opens path for appending, or stdout if it is empty; header
+// reports whether the output is new and needs a CSV header
+func openWatchOutput(path string) (out io.WriteCloser, header bool, err error) {
+    if path == "" {
+        return nopCloser{os.Stdout}, true, nil
+    }
+    file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to open output file: %w", err)
+    }
+    info, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return nil, false, fmt.Errorf("failed to open output file: %w", err)
+    }
+    return file, info.Size() == 0, nil
+}
+
+// nopCloser is a writer whose Close does nothing, for stdout
+type nopCloser struct {
+    io.Writer
+}
+
+// Close does nothing
+func (nopCloser) Close() error {
+    return nil
+}
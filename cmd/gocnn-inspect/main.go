@@ -0,0 +1,313 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/explain"
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/porcelain"
+)
+
+// Version information
+const (
+    AppName    = "gocnn-inspect"
+    AppVersion = "1.0.0"
+    AppDesc    = "Architecture graphs, weight statistics and filter visualization for TinyCNN"
+)
+
+// Command line flags
+var (
+    weightsPath = flag.String("weights", "", "Path to model weights directory (required)")
+    configPath  = flag.String("config", "configs/cifar10.yaml", "Path to model configuration file")
+    visualize   = flag.String("visualize", "", "Write first-layer filter tiles (PNG) and weight statistics plots (SVG) to this directory")
+    tileScale   = flag.Int("tile-scale", 16, "Pixels per weight in the filter tiles")
+    perFilter   = flag.Bool("per-filter", false, "Stretch each filter tile to full contrast instead of one scale for all")
+    bins        = flag.Int("bins", 40, "Histogram bins in the weight statistics plots")
+    dotPath     = flag.String("dot", "", "Write the config's architecture as a Graphviz file with shapes, parameters and FLOPs")
+    quiet       = flag.Bool("quiet", false, "Suppress non-essential output")
+    showVersion = flag.Bool("version", false, "Show version information")
+    showHelp    = flag.Bool("help", false, "Show detailed help")
+
+    porcelainMode = flag.Bool("porcelain", false, "Print only stable tab-separated records for scripts")
+)
+
+func main() {
+    flag.Parse()
+
+    if *showVersion {
+        printVersion()
+        return
+    }
+
+    if *showHelp {
+        printHelp()
+        return
+    }
+
+    if err := validateArgs(); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        fmt.Fprintf(os.Stderr, "Use -help for usage information\n")
+        os.Exit(1)
+    }
+
+    // The porcelain records replace all other output
+    if *porcelainMode {
+        *quiet = true
+    }
+
+    if err := runInspect(); err != nil {
+        errs.Fprint(os.Stderr, "Inspection failed", err)
+        os.Exit(1)
+    }
+}
+
+// validateArgs validates command line arguments
+func validateArgs() error {
+    // The architecture graph comes from the config alone
+    if *weightsPath == "" && *dotPath == "" {
+        return fmt.Errorf("weights path is required (use -weights), unless only writing -dot")
+    }
+    if *weightsPath == "" && *visualize != "" {
+        return fmt.Errorf("-visualize needs -weights")
+    }
+
+    paths := map[string]string{
+        "config file": *configPath,
+    }
+    if *weightsPath != "" {
+        paths["weights directory"] = *weightsPath
+    }
+
+    for desc, path := range paths {
+        if _, err := os.Stat(path); os.IsNotExist(err) {
+            return fmt.Errorf("%s does not exist: %s", desc, path)
+        }
+    }
+
+    if *tileScale <= 0 {
+        return fmt.Errorf("-tile-scale must be positive, got %d", *tileScale)
+    }
+
+    if *bins <= 0 {
+        return fmt.Errorf("-bins must be positive, got %d", *bins)
+    }
+
+    return nil
+}
+
+// runInspect loads the model, reports the statistics of every conv layer and
+// writes the -visualize files
+func runInspect() error {
+    cfg, err := config.Load(*configPath)
+    if err != nil {
+        return fmt.Errorf("failed to load configuration: %w", err)
+    }
+
+    var files [][2]string // Kind and path of every file written
+    if *dotPath != "" {
+        if err := writeDOT(*dotPath, cfg); err != nil {
+            return err
+        }
+        files = append(files, [2]string{"graph", *dotPath})
+    }
+    if *weightsPath == "" {
+        return writeFiles(files)
+    }
+
+    if !*quiet {
+        fmt.Printf("Loading model from %s...\n", *weightsPath)
+    }
+    cnn, err := model.NewTinyCNNFromConfig(*weightsPath, cfg.Model)
+    if err != nil {
+        return fmt.Errorf("failed to load model: %w", err)
+    }
+
+    var stats []*explain.WeightStats
+    var firstLayer string
+    for _, layer := range cnn.Info().Architecture.Layers {
+        if layer.Type != model.ConvolutionLayer {
+            continue
+        }
+        kernel, err := cnn.ConvKernel(layer.Name)
+        if err != nil {
+            return err
+        }
+        if firstLayer == "" {
+            firstLayer = layer.Name
+        }
+        stats = append(stats, explain.KernelStats(layer.Name, kernel, *bins))
+    }
+    if len(stats) == 0 {
+        return fmt.Errorf("model has no conv layers")
+    }
+
+    if *visualize != "" {
+        if err := os.MkdirAll(*visualize, 0755); err != nil {
+            return fmt.Errorf("failed to create output directory: %w", err)
+        }
+
+        kernel, err := cnn.ConvKernel(firstLayer)
+        if err != nil {
+            return err
+        }
+        tiles, err := explain.FilterTiles(kernel, explain.TileOptions{Scale: *tileScale, PerFilter: *perFilter})
+        if err != nil {
+            return fmt.Errorf("%s: %w", firstLayer, err)
+        }
+        tilesPath := filepath.Join(*visualize, firstLayer+"_filters.png")
+        if err := explain.SavePNG(tilesPath, tiles); err != nil {
+            return err
+        }
+        files = append(files, [2]string{"filters", tilesPath})
+
+        statsPath := filepath.Join(*visualize, "weight_stats.svg")
+        if err := writeStatsPlot(statsPath, stats); err != nil {
+            return err
+        }
+        files = append(files, [2]string{"stats_plot", statsPath})
+    }
+
+    if *porcelainMode {
+        return writePorcelain(os.Stdout, stats, files)
+    }
+
+    writeReport(os.Stdout, stats)
+    return writeFiles(files)
+}
+
+// writeFiles reports the files written, as porcelain records in -porcelain mode
+func writeFiles(files [][2]string) error {
+    if *porcelainMode {
+        return writePorcelain(os.Stdout, nil, files)
+    }
+    if !*quiet {
+        for _, file := range files {
+            fmt.Printf("%s saved to: %s\n", fileDescriptions[file[0]], file[1])
+        }
+    }
+    return nil
+}
+
+// writeDOT saves the architecture of cfg to path as a Graphviz digraph
+func writeDOT(path string, cfg *config.Config) error {
+    arch, err := model.ArchitectureFromConfig(cfg.Model)
+    if err != nil {
+        return fmt.Errorf("invalid architecture in config: %w", err)
+    }
+    dot, err := arch.ToDOT()
+    if err != nil {
+        return err
+    }
+    if err := os.WriteFile(path, []byte(dot), 0644); err != nil {
+        return fmt.Errorf("failed to write %s: %w", path, err)
+    }
+    return nil
+}
+
+// fileDescriptions names the -visualize files in the report
+var fileDescriptions = map[string]string{
+    "graph":      "Architecture graph",
+    "filters":    "Filter tiles",
+    "stats_plot": "Weight statistics plots",
+}
+
+// writeStatsPlot saves the weight statistics plots to path as SVG
+func writeStatsPlot(path string, stats []*explain.WeightStats) error {
+    file, err := os.Create(path)
+    if err != nil {
+        return fmt.Errorf("failed to create %s: %w", path, err)
+    }
+    if err := explain.WriteStatsSVG(file, stats); err != nil {
+        file.Close()
+        return fmt.Errorf("failed to write %s: %w", path, err)
+    }
+    return file.Close()
+}
+
+// writeReport prints the statistics of every conv layer
+func writeReport(w io.Writer, stats []*explain.WeightStats) {
+    fmt.Fprintf(w, "\nWeight Statistics:\n")
+    fmt.Fprintf(w, "  %-10s %-14s %11s %11s %11s %11s %9s %6s\n",
+        "Layer", "Shape", "Mean", "Std", "Min", "Max", "Sparsity", "Dead")
+    for _, s := range stats {
+        fmt.Fprintf(w, "  %-10s %-14s %11.4e %11.4e %11.4e %11.4e %8.1f%% %6d\n",
+            s.Layer, fmt.Sprintf("%dx%dx%dx%d", s.Size, s.Size, s.Channels, s.Filters),
+            s.Mean, s.Std, s.Min, s.Max, 100*s.Sparsity, s.DeadFilters)
+    }
+    fmt.Fprintln(w)
+}
+
+// writePorcelain prints the statistics and files written as stable tab-separated
+// records (see printHelp)
+func writePorcelain(w io.Writer, stats []*explain.WeightStats, files [][2]string) error {
+    records := porcelain.NewWriter(w)
+    records.Begin(AppName, AppVersion)
+    for _, s := range stats {
+        records.Record("layer", s.Layer, s.Size, s.Channels, s.Filters,
+            s.Mean, s.Std, s.Min, s.Max, s.Sparsity, s.DeadFilters)
+    }
+    for _, file := range files {
+        records.Record("file", file[0], file[1])
+    }
+    return records.Flush()
+}
+
+// printVersion displays version information
+func printVersion() {
+    fmt.Printf("%s version %s\n", AppName, AppVersion)
+    fmt.Printf("%s\n", AppDesc)
+}
+
+// printHelp displays detailed help information
+func printHelp() {
+    fmt.Printf("%s - %s\n\n", AppName, AppDesc)
+
+    fmt.Println("USAGE:")
+    fmt.Printf("  %s -weights <path> [options]\n", AppName)
+    fmt.Printf("  %s -dot <file> [-config <path>]\n\n", AppName)
+
+    fmt.Println("REQUIRED:")
+    fmt.Println("  -weights <path>    Path to directory containing model weights (not needed for -dot alone)")
+
+    fmt.Println("\nOPTIONS:")
+    fmt.Println("  -config <path>     Path to model configuration file (default: configs/cifar10.yaml)")
+    fmt.Println("  -dot <file>        Write the config's architecture as a Graphviz graph (see OUTPUT)")
+    fmt.Println("  -visualize <dir>   Write filter tiles and weight statistics plots to <dir> (see OUTPUT)")
+    fmt.Println("  -tile-scale <n>    Pixels per weight in the filter tiles (default: 16)")
+    fmt.Println("  -per-filter        Stretch each filter tile to full contrast instead of one scale for all")
+    fmt.Println("  -bins <n>          Histogram bins in the statistics plots (default: 40)")
+    fmt.Println("  -quiet             Suppress non-essential output")
+    fmt.Println("  -porcelain         Print only stable tab-separated records (see PORCELAIN OUTPUT)")
+    fmt.Println("  -version           Show version information")
+    fmt.Println("  -help              Show this help message")
+
+    fmt.Println("\nOUTPUT:")
+    fmt.Println("  Mean, standard deviation, range, sparsity (|w| below 1% of the largest) and dead")
+    fmt.Println("  filters (L2 norm below 1% of the largest) of every conv layer, and with -visualize:")
+    fmt.Println("  <dir>/<first conv>_filters.png   First-layer filters as RGB tiles; gray is 0")
+    fmt.Println("  <dir>/weight_stats.svg           Per layer: weight histogram and L2 norm of every")
+    fmt.Println("                                   filter, dead filters in red")
+    fmt.Println("  With -dot, a node per layer with its hyperparameters, output shape, parameters and")
+    fmt.Println("  FLOPs; render it with: dot -Tsvg model.dot -o model.svg")
+
+    fmt.Println("\nPORCELAIN OUTPUT (-porcelain, one tab-separated record per line):")
+    fmt.Println("  porcelain  <format version> <tool> <tool version>")
+    fmt.Println("  layer      <layer> <size> <channels> <filters> <mean> <std> <min> <max> <sparsity> <dead filters>")
+    fmt.Println("  file       <graph|filters|stats_plot> <path>                            (-dot, -visualize)")
+
+    fmt.Println("\nEXAMPLES:")
+    fmt.Printf("  # Weight statistics of every conv layer\n")
+    fmt.Printf("  %s -weights ./weights\n\n", AppName)
+
+    fmt.Printf("  # Filter tiles and statistics plots to open in a browser\n")
+    fmt.Printf("  %s -weights ./weights -visualize ./inspect -per-filter\n\n", AppName)
+
+    fmt.Printf("  # Review an architecture defined in a config before training it\n")
+    fmt.Printf("  %s -config ./configs/custom.yaml -dot model.dot && dot -Tsvg model.dot -o model.svg\n", AppName)
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/porcelain"
+	"duchm1606/gocnn/internal/quant"
+	"duchm1606/gocnn/internal/runinfo"
+)
+
+// Version information
+const (
+    AppName    = "gocnn-quantize"
+    AppVersion = "1.0.0"
+    AppDesc    = "Int8 weight quantization and activation calibration for TinyCNN"
+)
+
+// Command line flags
+var (
+    weightsPath = flag.String("weights", "", "Path to float model weights directory (required)")
+    imagesPath  = flag.String("images", "", "Directory of calibration images (*.bin) (required)")
+    imageFormat = flag.String("image-format", "float32", "Image file encoding: float32 (values in [0, 1]) or uint8 (0-255)")
+    outputPath  = flag.String("output", "", "Directory to write the quantized weight bundle to (required)")
+    configPath  = flag.String("config", "configs/cifar10.yaml", "Path to model configuration file")
+    granularity = flag.String("granularity", "per-channel", "Weight scales: per-channel or per-tensor")
+    numSamples  = flag.Int("samples", 100, "Maximum number of calibration images")
+    reportPath  = flag.String("report", "", "Also save the per-layer scale report to this file")
+    verbose     = flag.Bool("verbose", false, "Enable verbose output")
+    quiet       = flag.Bool("quiet", false, "Suppress non-essential output")
+    showVersion = flag.Bool("version", false, "Show version information")
+    showHelp    = flag.Bool("help", false, "Show detailed help")
+
+    porcelainMode = flag.Bool("porcelain", false, "Print only stable tab-separated records for scripts")
+)
+
+func main() {
+    flag.Parse()
+
+    if *showVersion {
+        printVersion()
+        return
+    }
+
+    if *showHelp {
+        printHelp()
+        return
+    }
+
+    if err := validateArgs(); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        fmt.Fprintf(os.Stderr, "Use -help for usage information\n")
+        os.Exit(1)
+    }
+
+    // The porcelain records replace all other output
+    if *porcelainMode {
+        *quiet = true
+        *verbose = false
+    }
+
+    if err := runQuantize(); err != nil {
+        errs.Fprint(os.Stderr, "Quantization failed", err)
+        os.Exit(1)
+    }
+}
+
+// validateArgs validates command line arguments
+func validateArgs() error {
+    if *weightsPath == "" {
+        return fmt.Errorf("weights path is required (use -weights)")
+    }
+
+    if *imagesPath == "" {
+        return fmt.Errorf("calibration images path is required (use -images)")
+    }
+
+    if *outputPath == "" {
+        return fmt.Errorf("output directory is required (use -output)")
+    }
+
+    paths := map[string]string{
+        "weights directory": *weightsPath,
+        "images directory":  *imagesPath,
+        "config file":       *configPath,
+    }
+
+    for desc, path := range paths {
+        if _, err := os.Stat(path); os.IsNotExist(err) {
+            return fmt.Errorf("%s does not exist: %s", desc, path)
+        }
+    }
+
+    if abs(*outputPath) == abs(*weightsPath) {
+        return fmt.Errorf("output directory must differ from the weights directory")
+    }
+
+    if *numSamples <= 0 {
+        return fmt.Errorf("number of samples must be positive, got %d", *numSamples)
+    }
+
+    if _, err := data.ParseImageFormat(*imageFormat); err != nil {
+        return fmt.Errorf("-image-format: %w", err)
+    }
+
+    g, err := quant.ParseGranularity(*granularity)
+    if err != nil {
+        return fmt.Errorf("-granularity: %w", err)
+    }
+    if g == quant.None {
+        return fmt.Errorf("-granularity must be per-channel or per-tensor")
+    }
+
+    return nil
+}
+
+// runQuantize calibrates the float model and writes the quantized bundle
+func runQuantize() error {
+    cfg, err := config.Load(*configPath)
+    if err != nil {
+        return fmt.Errorf("failed to load configuration: %w", err)
+    }
+
+    // Always start from float32 weights, whatever precision the config asks for at inference time
+    arch, err := model.ArchitectureFromConfig(cfg.Model)
+    if err != nil {
+        return fmt.Errorf("invalid architecture in config: %w", err)
+    }
+
+    if !*quiet {
+        fmt.Printf("Loading float model from %s...\n", *weightsPath)
+    }
+    cnn, err := model.NewTinyCNNWithArchitecture(*weightsPath, arch)
+    if err != nil {
+        return fmt.Errorf("failed to load model: %w", err)
+    }
+
+    images, err := listImages(*imagesPath, *numSamples)
+    if err != nil {
+        return err
+    }
+
+    if !*quiet {
+        fmt.Printf("Calibrating activation ranges on %d images...\n", len(images))
+    }
+    start := time.Now()
+    calibrator, err := calibrate(cnn, arch, cfg, images)
+    if err != nil {
+        return err
+    }
+    if *verbose {
+        fmt.Printf("Calibration finished in %v\n", time.Since(start))
+    }
+
+    g, _ := quant.ParseGranularity(*granularity)
+    bundle := quant.NewBundle(g)
+    bundle.CalibrationImages = calibrator.Samples()
+    bundle.Activations = calibrator.Ranges()
+    bundle.SourceWeights, _, err = runinfo.HashWeights(*weightsPath)
+    if err != nil {
+        return fmt.Errorf("failed to hash weights: %w", err)
+    }
+
+    if err := os.MkdirAll(*outputPath, 0755); err != nil {
+        return fmt.Errorf("failed to create output directory: %w", err)
+    }
+
+    var convLayers []string
+    for _, layer := range arch.Layers {
+        if layer.Type != model.ConvolutionLayer {
+            continue
+        }
+        kernel, err := cnn.ConvKernel(layer.Name)
+        if err != nil {
+            return err
+        }
+        if _, err := bundle.AddKernel(*outputPath, layer.Name, kernel); err != nil {
+            return err
+        }
+        convLayers = append(convLayers, layer.Name)
+    }
+
+    copied, err := copyFloatWeights(*weightsPath, *outputPath, convLayers)
+    if err != nil {
+        return err
+    }
+
+    if err := bundle.Write(*outputPath); err != nil {
+        return err
+    }
+
+    if !*quiet {
+        fmt.Printf("Wrote %d int8 kernels and %d float files to %s\n\n", len(bundle.Layers), copied, *outputPath)
+    }
+    if *porcelainMode {
+        if err := writePorcelain(os.Stdout, bundle, copied); err != nil {
+            return err
+        }
+    } else {
+        writeReport(os.Stdout, bundle)
+    }
+
+    if *reportPath != "" {
+        file, err := os.Create(*reportPath)
+        if err != nil {
+            return fmt.Errorf("failed to create report: %w", err)
+        }
+        defer file.Close()
+        writeReport(file, bundle)
+
+        if !*quiet {
+            fmt.Printf("\nReport saved to: %s\n", *reportPath)
+        }
+    }
+
+    return nil
+}
+
+// listImages returns up to limit *.bin files from dir in name order
+func listImages(dir string, limit int) ([]string, error) {
+    matches, err := filepath.Glob(filepath.Join(dir, "*.bin"))
+    if err != nil {
+        return nil, err
+    }
+    if len(matches) == 0 {
+        return nil, fmt.Errorf("no calibration images (*.bin) found in %s", dir)
+    }
+    sort.Strings(matches)
+
+    if len(matches) > limit {
+        matches = matches[:limit]
+    }
+    return matches, nil
+}
+
+// calibrate runs every image through the model layer by layer, recording each layer's output range
+func calibrate(cnn *model.TinyCNN, arch *model.TinyCNNArchitecture, cfg *config.Config,
+    images []string) (*quant.Calibrator, error) {
+
+    format, err := data.ParseImageFormat(*imageFormat)
+    if err != nil {
+        return nil, err
+    }
+    preprocessor, err := data.NewPreprocessor(format, cfg.Data, cfg.Model)
+    if err != nil {
+        return nil, err
+    }
+    calibrator := quant.NewCalibrator()
+
+    for i, path := range images {
+        current, err := preprocessor.Load(path)
+        if err != nil {
+            return nil, fmt.Errorf("failed to load calibration image: %w", err)
+        }
+        calibrator.ObserveFeatureMap("input", current)
+
+        for _, layer := range arch.Layers {
+            next, err := cnn.RunLayer(layer.Name, current)
+            if err != nil {
+                return nil, fmt.Errorf("calibration image %s: %w", path, err)
+            }
+            calibrator.ObserveFeatureMap(layer.Name, next)
+            current = next
+        }
+        calibrator.AddSample()
+
+        if *verbose && (i+1)%25 == 0 {
+            fmt.Printf("  %d/%d images\n", i+1, len(images))
+        }
+    }
+
+    return calibrator, nil
+}
+
+// copyFloatWeights copies the float32 arrays that stay unquantized (biases, batch norm,
+// custom layers) into the bundle, skipping the conv weight files replaced by int8 kernels
+func copyFloatWeights(src, dst string, convLayers []string) (int, error) {
+    quantized := make(map[string]bool, len(convLayers))
+    for _, layer := range convLayers {
+        quantized[layer] = true
+    }
+
+    copied := 0
+    err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if d.IsDir() || filepath.Ext(path) != ".bin" {
+            return nil
+        }
+
+        rel, err := filepath.Rel(src, path)
+        if err != nil {
+            return err
+        }
+        layer := filepath.Base(filepath.Dir(path))
+        if quantized[layer] && strings.HasSuffix(d.Name(), "_weight.bin") {
+            return nil
+        }
+
+        if err := copyFile(path, filepath.Join(dst, rel)); err != nil {
+            return fmt.Errorf("failed to copy %s: %w", rel, err)
+        }
+        copied++
+        return nil
+    })
+    return copied, err
+}
+
+// copyFile copies src to dst, creating dst's directory
+func copyFile(src, dst string) error {
+    if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+        return err
+    }
+
+    in, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+
+    out, err := os.Create(dst)
+    if err != nil {
+        return err
+    }
+    if _, err := io.Copy(out, in); err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
+
+// writeReport prints the per-layer weight scales and activation ranges
+func writeReport(w io.Writer, bundle *quant.Bundle) {
+    fmt.Fprintf(w, "Quantization Report (%s, %d calibration images):\n", bundle.Granularity, bundle.CalibrationImages)
+    fmt.Fprintf(w, "  Weights:\n")
+    fmt.Fprintf(w, "    %-10s %-14s %7s %11s %11s %11s %11s %11s\n",
+        "Layer", "Shape", "Scales", "Min scale", "Max scale", "Mean scale", "MSE", "Max error")
+    for _, layer := range bundle.Layers {
+        minScale, maxScale, meanScale := scaleStats(layer.Scales)
+        fmt.Fprintf(w, "    %-10s %-14s %7d %11.4e %11.4e %11.4e %11.4e %11.4e\n",
+            layer.Layer,
+            fmt.Sprintf("%dx%dx%dx%d", layer.Size, layer.Size, layer.Channels, layer.Filters),
+            len(layer.Scales), minScale, maxScale, meanScale, layer.MSE, layer.MaxError)
+    }
+
+    fmt.Fprintf(w, "  Activations:\n")
+    fmt.Fprintf(w, "    %-16s %12s %12s %12s\n", "Layer", "Min", "Max", "Scale")
+    for _, r := range bundle.Activations {
+        fmt.Fprintf(w, "    %-16s %12.5f %12.5f %12.4e\n", r.Layer, r.Min, r.Max, r.Scale)
+    }
+}
+
+// writePorcelain prints the bundle summary, weight scales and activation ranges as
+// stable tab-separated records (see printHelp)
+func writePorcelain(w io.Writer, bundle *quant.Bundle, copied int) error {
+    records := porcelain.NewWriter(w)
+    records.Begin(AppName, AppVersion)
+    records.Record("bundle", *outputPath, bundle.Granularity, bundle.CalibrationImages, len(bundle.Layers), copied)
+    for _, layer := range bundle.Layers {
+        minScale, maxScale, meanScale := scaleStats(layer.Scales)
+        records.Record("weight", layer.Layer, layer.Size, layer.Channels, layer.Filters,
+            len(layer.Scales), minScale, maxScale, meanScale, layer.MSE, layer.MaxError)
+    }
+    for _, r := range bundle.Activations {
+        records.Record("activation", r.Layer, r.Min, r.Max, r.Scale)
+    }
+    return records.Flush()
+}
+
+// scaleStats returns the smallest, largest and mean scale
+func scaleStats(scales []float32) (float32, float32, float32) {
+    if len(scales) == 0 {
+        return 0, 0, 0
+    }
+    minScale, maxScale := scales[0], scales[0]
+    var sum float32
+    for _, s := range scales {
+        if s < minScale {
+            minScale = s
+        }
+        if s > maxScale {
+            maxScale = s
+        }
+        sum += s
+    }
+    return minScale, maxScale, sum / float32(len(scales))
+}
+
+// abs returns the cleaned absolute form of path, or path itself if that fails
+func abs(path string) string {
+    if absolute, err := filepath.Abs(path); err == nil {
+        return absolute
+    }
+    return filepath.Clean(path)
+}
+
+// printVersion displays version information
+func printVersion() {
+    fmt.Printf("%s version %s\n", AppName, AppVersion)
+    fmt.Printf("%s\n", AppDesc)
+}
+
+// printHelp displays detailed help information
+func printHelp() {
+    fmt.Printf("%s - %s\n\n", AppName, AppDesc)
+
+    fmt.Println("USAGE:")
+    fmt.Printf("  %s -weights <path> -images <path> -output <path> [options]\n\n", AppName)
+
+    fmt.Println("REQUIRED:")
+    fmt.Println("  -weights <path>    Path to directory containing float model weights")
+    fmt.Println("  -images <path>     Directory of calibration images (*.bin, as for gocnn-inference)")
+    fmt.Println("  -output <path>     Directory to write the quantized weight bundle to")
+
+    fmt.Println("\nOPTIONS:")
+    fmt.Println("  -config <path>     Path to model configuration file (default: configs/cifar10.yaml)")
+    fmt.Println("  -granularity <g>   Weight scales: per-channel or per-tensor (default: per-channel)")
+    fmt.Println("  -samples <n>       Maximum number of calibration images (default: 100)")
+    fmt.Println("  -image-format <f>  Image file encoding: float32 (default) or uint8")
+    fmt.Println("  -report <file>     Also save the per-layer scale report to <file>")
+    fmt.Println("  -verbose           Enable verbose output")
+    fmt.Println("  -quiet             Suppress non-essential output")
+    fmt.Println("  -porcelain         Print only stable tab-separated records (see PORCELAIN OUTPUT)")
+    fmt.Println("  -version           Show version information")
+    fmt.Println("  -help              Show this help message")
+
+    fmt.Println("\nOUTPUT:")
+    fmt.Println("  <output>/quantization.json          Granularity, per-layer weight scales and errors,")
+    fmt.Println("                                      calibrated activation ranges")
+    fmt.Println("  <output>/<conv>/<conv>_weight.bin   int8 kernel with its scales in a quantized file header")
+    fmt.Println("  Biases, batch norm and custom layer arrays are copied unchanged as float32, so")
+    fmt.Println("  <output> can be passed to -weights of gocnn-inference and gocnn-benchmark")
+
+    fmt.Println("\nPORCELAIN OUTPUT (-porcelain, one tab-separated record per line):")
+    fmt.Println("  porcelain   <format version> <tool> <tool version>")
+    fmt.Println("  bundle      <output> <granularity> <calibration images> <int8 kernels> <float files copied>")
+    fmt.Println("  weight      <layer> <size> <channels> <filters> <scales> <min scale> <max scale> <mean scale> <mse> <max error>")
+    fmt.Println("  activation  <layer> <min> <max> <scale>")
+
+    fmt.Println("\nEXAMPLES:")
+    fmt.Printf("  # Quantize with per-channel scales, calibrating on 200 test images\n")
+    fmt.Printf("  %s -weights ./weights -images ./testdata/test_images -output ./weights-int8 -samples 200\n\n", AppName)
+
+    fmt.Printf("  # Compare against a single per-tensor scale and keep the report\n")
+    fmt.Printf("  %s -weights ./weights -images ./testdata/test_images -output ./weights-int8-pt \\\n", AppName)
+    fmt.Printf("    -granularity per-tensor -report per-tensor.txt\n")
+}
@@ -0,0 +1,642 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/ops"
+	"duchm1606/gocnn/internal/runinfo"
+	"duchm1606/gocnn/internal/server"
+	inferencegrpc "duchm1606/gocnn/internal/server/grpc"
+	"duchm1606/gocnn/internal/server/grpc/inferencepb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Version information
+const (
+    AppName    = "gocnn-serve"
+    AppVersion = "1.0.0"
+    AppDesc    = "HTTP and gRPC inference server for one or more TinyCNN models"
+)
+
+// defaultModelName names the model served from -weights when the config lists none
+const defaultModelName = "default"
+
+// defaultQueuePerSlot sizes the wait queue when serving.max_queue is unset
+const defaultQueuePerSlot = 4
+
+// shutdownTimeout bounds how long requests in flight may finish after a signal
+const shutdownTimeout = 10 * time.Second
+
+// Command line flags
+var (
+    configPath  = flag.String("config", "configs/cifar10.yaml", "Path to the configuration file (models under serving.models)")
+    weightsPath = flag.String("weights", "", "Weights directory of the single model served when the config lists none")
+    addr        = flag.String("addr", "", "Listen address (default serving.address or :8080)")
+    grpcAddr    = flag.String("grpc-addr", "", "gRPC listen address; empty serves no gRPC (default serving.grpc_address)")
+    imageFormat = flag.String("image-format", "float32", "Encoding of binary request bodies: float32 (values in [0, 1]) or uint8 (0-255)")
+    maxBatch    = flag.Int("max-batch", -1, "Requests coalesced per forward pass, 0 or 1 disables batching (default serving.max_batch)")
+    maxInFlight = flag.Int("max-inflight", -1, "Predictions running at once, 0 is unlimited (default serving.max_inflight)")
+    rateLimit   = flag.Float64("rate-limit", -1, "Requests per second per client, 0 is unlimited (default serving.rate_limit)")
+    tlsCert     = flag.String("tls-cert", "", "PEM certificate to serve HTTPS with (default inference.tls_cert_file)")
+    tlsKey      = flag.String("tls-key", "", "PEM private key of -tls-cert (default inference.tls_key_file)")
+    apiKeysFile = flag.String("api-keys-file", "", "File of accepted API keys, one per line (default inference.api_keys_file)")
+    auditLog    = flag.String("audit-log", "", "JSONL file every prediction is logged to (default serving.audit_log)")
+    runManifest = flag.String("run-manifest", "", "Write a run.json with version, commit, config/weights hashes, engine and host")
+    verbose     = flag.Bool("verbose", false, "Enable verbose output")
+    quiet       = flag.Bool("quiet", false, "Suppress non-essential output")
+    showVersion = flag.Bool("version", false, "Show version information")
+    showHelp    = flag.Bool("help", false, "Show detailed help")
+
+    engineName    = flag.String("engine", "", "Convolution backend: auto, naive, tiled, parallel, gemm (default $GOCNN_ENGINE or auto)")
+    engineWorkers = flag.Int("engine-workers", -1, "Goroutines per convolution for the parallel backend (0 = one per CPU)")
+    enginePool    = flag.String("engine-pool", "", "Reuse intermediate buffers: on or off (default on)")
+)
+
+func main() {
+    flag.Parse()
+
+    if *showVersion {
+        printVersion()
+        return
+    }
+
+    if *showHelp {
+        printHelp()
+        return
+    }
+
+    if err := validateArgs(); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        fmt.Fprintf(os.Stderr, "Use -help for usage information\n")
+        os.Exit(1)
+    }
+
+    if err := runServer(); err != nil {
+        errs.Fprint(os.Stderr, "Server failed", err)
+        os.Exit(1)
+    }
+}
+
+// validateArgs validates command line arguments
+func validateArgs() error {
+    if _, err := os.Stat(*configPath); os.IsNotExist(err) {
+        return fmt.Errorf("config file does not exist: %s", *configPath)
+    }
+
+    if *weightsPath != "" {
+        if _, err := os.Stat(*weightsPath); os.IsNotExist(err) {
+            return fmt.Errorf("weights directory does not exist: %s", *weightsPath)
+        }
+    }
+
+    if (*tlsCert == "") != (*tlsKey == "") {
+        return fmt.Errorf("-tls-cert and -tls-key must be given together")
+    }
+
+    if _, err := data.ParseImageFormat(*imageFormat); err != nil {
+        return fmt.Errorf("-image-format: %w", err)
+    }
+
+    if _, err := resolveEngineOptions(); err != nil {
+        return err
+    }
+
+    return nil
+}
+
+// resolveEngineOptions combines the defaults, GOCNN_ENGINE* variables and -engine* flags
+// Flags win over the environment
+func resolveEngineOptions() (ops.EngineOptions, error) {
+    opts, err := ops.EngineOptionsFromEnv(ops.DefaultEngineOptions())
+    if err != nil {
+        return opts, err
+    }
+
+    if *engineName != "" {
+        backend, err := ops.ParseBackend(*engineName)
+        if err != nil {
+            return opts, fmt.Errorf("-engine: %w", err)
+        }
+        opts.Backend = backend
+    }
+    if *engineWorkers >= 0 {
+        opts.Workers = *engineWorkers
+    }
+    if *enginePool != "" {
+        pooling, err := ops.ParsePooling(*enginePool)
+        if err != nil {
+            return opts, fmt.Errorf("-engine-pool: %w", err)
+        }
+        opts.Pooling = pooling
+    }
+    return opts, nil
+}
+
+// runServer loads every configured model and serves them until interrupted
+func runServer() error {
+    cfg, err := config.Load(*configPath)
+    if err != nil {
+        return fmt.Errorf("failed to load configuration: %w", err)
+    }
+
+    if *addr != "" {
+        cfg.Serving.Address = *addr
+    }
+    if *grpcAddr != "" {
+        cfg.Serving.GRPCAddress = *grpcAddr
+    }
+    if *maxBatch >= 0 {
+        cfg.Serving.MaxBatch = *maxBatch
+    }
+    if *maxInFlight >= 0 {
+        cfg.Serving.MaxInFlight = *maxInFlight
+    }
+    if *rateLimit >= 0 {
+        cfg.Serving.RateLimit = *rateLimit
+    }
+    if cfg.Serving.MaxInFlight > 0 && cfg.Serving.MaxQueue == 0 {
+        cfg.Serving.MaxQueue = defaultQueuePerSlot * cfg.Serving.MaxInFlight
+    }
+    if *tlsCert != "" {
+        cfg.Inference.TLSCertFile, cfg.Inference.TLSKeyFile = *tlsCert, *tlsKey
+    }
+    if *apiKeysFile != "" {
+        cfg.Inference.APIKeysFile = *apiKeysFile
+    }
+    if *auditLog != "" {
+        cfg.Serving.AuditLog = *auditLog
+    }
+
+    served := cfg.Serving.Models
+    if len(served) == 0 {
+        if *weightsPath == "" {
+            return errs.WithHint(fmt.Errorf("no models to serve"),
+                "list models under serving.models in %s, or serve one with -weights", *configPath)
+        }
+        served = []config.ServedModelConfig{{Name: defaultModelName, Weights: *weightsPath}}
+    }
+
+    if !*quiet {
+        fmt.Printf("Starting %s v%s\n", AppName, AppVersion)
+    }
+
+    metrics := server.NewMetrics()
+    registry, closeModels, err := loadModels(cfg, served, metrics)
+    if err != nil {
+        return err
+    }
+    defer closeModels()
+    if *runManifest != "" {
+        if err := writeRunManifest(served[0], registry.Models()[0].Model); err != nil {
+            return err
+        }
+    }
+
+    listener, err := net.Listen("tcp", cfg.Serving.Address)
+    if err != nil {
+        return errs.WithHint(fmt.Errorf("failed to listen: %w", err),
+            "pick a free address with -addr, e.g. -addr :8081")
+    }
+
+    limiter, err := server.NewLimiter(server.LimitOptions{
+        MaxInFlight:  cfg.Serving.MaxInFlight,
+        MaxQueue:     cfg.Serving.MaxQueue,
+        QueueTimeout: cfg.Serving.QueueTimeout,
+        Rate:         cfg.Serving.RateLimit,
+        Burst:        cfg.Serving.RateBurst,
+    }, metrics)
+    if err != nil {
+        listener.Close()
+        return err
+    }
+    api := server.NewAPI(registry)
+    api.SetLimiter(limiter)
+    if cfg.Serving.AuditLog != "" {
+        audit, err := server.NewAuditLog(server.AuditOptions{
+            Path:     cfg.Serving.AuditLog,
+            MaxBytes: int64(cfg.Serving.AuditMaxMB) << 20,
+            MaxFiles: cfg.Serving.AuditMaxFiles,
+        }, metrics)
+        if err != nil {
+            listener.Close()
+            return errs.WithHint(err, "create the directory of %s or pick another -audit-log", cfg.Serving.AuditLog)
+        }
+        defer audit.Close()
+        api.SetAuditLog(audit)
+        if !*quiet {
+            fmt.Printf("Auditing predictions to %s\n", cfg.Serving.AuditLog)
+        }
+    }
+
+    mux := http.NewServeMux()
+    api.Register(mux)
+    server.NewRegistryHealth(registry, 0).Register(mux)
+    mux.Handle("/metrics", metrics)
+    auth, err := apiKeys(cfg)
+    if err != nil {
+        listener.Close()
+        return err
+    }
+    var handler http.Handler = mux
+    if auth != nil {
+        handler = auth.Wrap(mux)
+    }
+    srv := &http.Server{
+        Handler:           handler,
+        ReadHeaderTimeout: 10 * time.Second,
+        TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
+    }
+
+    scheme := "http"
+    useTLS := cfg.Inference.TLSCertFile != ""
+    if useTLS {
+        scheme = "https"
+    }
+
+    var grpcServer *grpc.Server
+    var grpcListener net.Listener
+    if cfg.Serving.GRPCAddress != "" {
+        grpcServer, grpcListener, err = newGRPCServer(cfg, registry, limiter, auth)
+        if err != nil {
+            listener.Close()
+            return err
+        }
+    }
+    if !*quiet && (cfg.Serving.MaxInFlight > 0 || cfg.Serving.RateLimit > 0) {
+        fmt.Printf("Limits: %s in flight (%d queued, %v queue timeout), %s requests/s per client\n",
+            limitString(float64(cfg.Serving.MaxInFlight)), cfg.Serving.MaxQueue, cfg.Serving.QueueTimeout,
+            limitString(cfg.Serving.RateLimit))
+    }
+    if !*quiet {
+        fmt.Printf("Serving %v on %s://%s (default model %q)\n", registry.Names(), scheme, listener.Addr(), registry.Names()[0])
+        fmt.Printf("  POST /v1/models/<name>/predict, POST /v1/predict?model=<name>, GET /v1/models\n")
+        fmt.Printf("  WebSocket /v1/models/<name>/stream, /v1/stream?model=<name>\n")
+        fmt.Printf("  GET /metrics, /healthz, /readyz\n")
+        if grpcServer != nil {
+            fmt.Printf("Serving gRPC gocnn.inference.v1.Inference on %s\n", grpcListener.Addr())
+        }
+    }
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    serveErr := make(chan error, 2)
+    go func() {
+        if useTLS {
+            serveErr <- srv.ServeTLS(listener, cfg.Inference.TLSCertFile, cfg.Inference.TLSKeyFile)
+            return
+        }
+        serveErr <- srv.Serve(listener)
+    }()
+    if grpcServer != nil {
+        go func() {
+            serveErr <- grpcServer.Serve(grpcListener)
+        }()
+    }
+
+    select {
+    case err := <-serveErr:
+        return fmt.Errorf("server stopped: %w", err)
+    case <-ctx.Done():
+    }
+
+    if !*quiet {
+        fmt.Println("\nShutting down, finishing requests in flight...")
+    }
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+    defer cancel()
+    if grpcServer != nil {
+        stopped := make(chan struct{})
+        go func() {
+            grpcServer.GracefulStop()
+            close(stopped)
+        }()
+        defer func() {
+            select {
+            case <-stopped:
+            case <-shutdownCtx.Done():
+                grpcServer.Stop()
+            }
+        }()
+    }
+    if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+        return fmt.Errorf("failed to shut down: %w", err)
+    }
+    return nil
+}
+
+// newGRPCServer listens on serving.grpc_address and serves the Inference service
+// there, with the HTTP API's TLS certificate, limits and API keys
+func newGRPCServer(cfg *config.Config, registry *server.Registry, limiter *server.Limiter,
+    auth *server.APIKeys) (*grpc.Server, net.Listener, error) {
+
+    var options []grpc.ServerOption
+    if cfg.Inference.TLSCertFile != "" {
+        creds, err := credentials.NewServerTLSFromFile(cfg.Inference.TLSCertFile, cfg.Inference.TLSKeyFile)
+        if err != nil {
+            return nil, nil, fmt.Errorf("failed to load TLS certificate for gRPC: %w", err)
+        }
+        options = append(options, grpc.Creds(creds))
+    }
+
+    listener, err := net.Listen("tcp", cfg.Serving.GRPCAddress)
+    if err != nil {
+        return nil, nil, errs.WithHint(fmt.Errorf("failed to listen for gRPC: %w", err),
+            "pick a free address with -grpc-addr, e.g. -grpc-addr :9091")
+    }
+    service := inferencegrpc.NewServer(registry)
+    service.SetLimiter(limiter)
+    if auth != nil {
+        service.SetAPIKeys(auth)
+    }
+    grpcServer := grpc.NewServer(options...)
+    inferencepb.RegisterInferenceServer(grpcServer, service)
+    return grpcServer, listener, nil
+}
+
+// limitString formats a limit, where 0 is unlimited
+func limitString(limit float64) string {
+    if limit == 0 {
+        return "unlimited"
+    }
+    return strconv.FormatFloat(limit, 'g', -1, 64)
+}
+
+// apiKeys returns the configured API keys every request must present, or nil when there are none
+func apiKeys(cfg *config.Config) (*server.APIKeys, error) {
+    keys := cfg.Inference.APIKeys
+    if cfg.Inference.APIKeysFile != "" {
+        fileKeys, err := server.ReadAPIKeys(cfg.Inference.APIKeysFile)
+        if err != nil {
+            return nil, err
+        }
+        keys = append(keys, fileKeys...)
+    }
+    if len(keys) == 0 {
+        return nil, nil
+    }
+
+    auth, err := server.NewAPIKeys(keys)
+    if err != nil {
+        return nil, errs.WithHint(err, "remove api_keys or list at least one non-blank key")
+    }
+    if !*quiet {
+        fmt.Printf("Requiring an API key (Authorization: Bearer <key>) except on /healthz and /readyz\n")
+        if cfg.Inference.TLSCertFile == "" {
+            fmt.Printf("Warning: API keys travel in clear text without TLS (use -tls-cert and -tls-key)\n")
+        }
+    }
+    return auth, nil
+}
+
+// modelSetup is a config file's model and data sections, shared by the models that use it
+type modelSetup struct {
+    cfg          *config.Config
+    preprocessor *data.Preprocessor
+}
+
+// loadModels loads every served model into a registry, each instrumented by metrics
+// and batched when serving.max_batch is above 1. The returned function stops the batchers.
+func loadModels(cfg *config.Config, served []config.ServedModelConfig, metrics *server.Metrics) (*server.Registry, func(), error) {
+    format, err := data.ParseImageFormat(*imageFormat)
+    if err != nil {
+        return nil, nil, err
+    }
+    engineOpts, err := resolveEngineOptions()
+    if err != nil {
+        return nil, nil, err
+    }
+
+    registry := server.NewRegistry()
+    var batchers []*server.Batcher
+    closeModels := func() {
+        for _, b := range batchers {
+            b.Close()
+        }
+    }
+
+    setups := map[string]*modelSetup{}
+    for _, m := range served {
+        setup, err := loadSetup(setups, cfg, m.Config, format)
+        if err != nil {
+            closeModels()
+            return nil, nil, fmt.Errorf("model %q: %w", m.Name, err)
+        }
+
+        if !*quiet {
+            fmt.Printf("Loading model %q from %s...\n", m.Name, m.Weights)
+        }
+        start := time.Now()
+        cnn, err := model.NewTinyCNNFromConfig(m.Weights, setup.cfg.Model)
+        if err != nil {
+            closeModels()
+            return nil, nil, fmt.Errorf("failed to load model %q: %w", m.Name, err)
+        }
+        if err := cnn.ConfigureEngine(engineOpts); err != nil {
+            closeModels()
+            return nil, nil, fmt.Errorf("failed to configure engine of model %q: %w", m.Name, err)
+        }
+        if setup.cfg.Inference.Warmup {
+            if _, err := cnn.Warmup(setup.cfg.Inference.WarmupInferences); err != nil {
+                closeModels()
+                return nil, nil, fmt.Errorf("failed to warm up model %q: %w", m.Name, err)
+            }
+        }
+
+        var predictor model.Predictor = cnn
+        if cfg.Serving.MaxBatch > 1 {
+            batcher, err := server.NewBatcher(cnn, server.BatcherOptions{
+                Window:   cfg.Serving.BatchWindow,
+                MaxBatch: cfg.Serving.MaxBatch,
+            })
+            if err != nil {
+                closeModels()
+                return nil, nil, err
+            }
+            batchers = append(batchers, batcher)
+            predictor = batcher
+        }
+
+        err = registry.Add(&server.ServedModel{
+            Name:         m.Name,
+            Model:        cnn,
+            Predictor:    metrics.Instrument(predictor),
+            Preprocessor: setup.preprocessor,
+            ClassNames:   setup.cfg.Model.ClassNames,
+        })
+        if err != nil {
+            closeModels()
+            return nil, nil, err
+        }
+
+        if *verbose {
+            fmt.Printf("  %s loaded in %v: %s, engine %s\n", m.Name, time.Since(start).Round(time.Millisecond),
+                setup.cfg.Model.Name, cnn.EngineOptions())
+            fmt.Printf("  Preprocessing: %s\n", setup.preprocessor.Pipeline())
+        }
+    }
+    return registry, closeModels, nil
+}
+
+// writeRunManifest saves the -run-manifest of the server, describing its default model m
+func writeRunManifest(m config.ServedModelConfig, cnn model.Predictor) error {
+    run := runinfo.New(AppName, AppVersion)
+    configFile := m.Config
+    if configFile == "" {
+        configFile = *configPath
+    }
+    if err := run.SetConfig(configFile); err != nil {
+        return err
+    }
+    if err := run.SetWeights(m.Weights); err != nil {
+        return err
+    }
+    run.SetModel(cnn.Info())
+
+    if err := run.Write(*runManifest); err != nil {
+        return err
+    }
+    if *verbose {
+        fmt.Printf("Run manifest saved to: %s\n", *runManifest)
+    }
+    return nil
+}
+
+// loadSetup returns the model and data sections of the config file at path, loading
+// each file once; an empty path is the main config
+func loadSetup(setups map[string]*modelSetup, main *config.Config, path string, format data.ImageFormat) (*modelSetup, error) {
+    if setup, ok := setups[path]; ok {
+        return setup, nil
+    }
+
+    cfg := main
+    if path != "" {
+        var err error
+        cfg, err = config.Load(path)
+        if err != nil {
+            return nil, fmt.Errorf("failed to load configuration: %w", err)
+        }
+    }
+    preprocessor, err := data.NewPreprocessor(format, cfg.Data, cfg.Model)
+    if err != nil {
+        return nil, err
+    }
+
+    setup := &modelSetup{cfg: cfg, preprocessor: preprocessor}
+    setups[path] = setup
+    return setup, nil
+}
+
+// printVersion displays version information
+func printVersion() {
+    fmt.Printf("%s version %s\n", AppName, AppVersion)
+    fmt.Printf("%s\n", AppDesc)
+}
+
+// printHelp displays detailed help information
+func printHelp() {
+    fmt.Printf("%s - %s\n\n", AppName, AppDesc)
+
+    fmt.Println("USAGE:")
+    fmt.Printf("  %s [-config <path>] [-weights <path>] [options]\n\n", AppName)
+
+    fmt.Println("MODELS:")
+    fmt.Println("  The models listed under serving.models in the config are served by name; each")
+    fmt.Println("  names its weights directory and optionally a config file with its own model and")
+    fmt.Println("  data sections. Models sharing a config share its preprocessing. Without a list,")
+    fmt.Printf("  the model of the config is served as %q from -weights.\n", defaultModelName)
+
+    fmt.Println("\nOPTIONS:")
+    fmt.Println("  -config <path>     Path to configuration file (default: configs/cifar10.yaml)")
+    fmt.Println("  -weights <path>    Weights of the single model served when the config lists none")
+    fmt.Println("  -addr <address>    Listen address (default: serving.address or :8080)")
+    fmt.Println("  -grpc-addr <addr>  Also serve the gRPC Inference service here (default: serving.grpc_address)")
+    fmt.Println("  -image-format <f>  Encoding of binary request bodies: float32 (default) or uint8")
+    fmt.Println("  -max-batch <n>     Requests coalesced per forward pass; 0 or 1 disables batching")
+    fmt.Println("  -max-inflight <n>  Predictions running at once; more wait in a queue (0: unlimited)")
+    fmt.Println("  -rate-limit <r>    Requests per second per client (0: unlimited)")
+    fmt.Println("  -tls-cert <file>   Serve HTTPS with this PEM certificate (needs -tls-key)")
+    fmt.Println("  -tls-key <file>    PEM private key of -tls-cert")
+    fmt.Println("  -api-keys-file <f> Require one of the API keys in <f> (one per line)")
+    fmt.Println("  -audit-log <file>  Append one JSON line per prediction to <file>")
+    fmt.Println("  -run-manifest <file> Write version, commit, config/weights hashes, engine and host of the")
+    fmt.Println("                     default model to <file>")
+    fmt.Println("  -engine <name>     Convolution backend: auto, naive, tiled, parallel, gemm")
+    fmt.Println("  -engine-workers <n> Goroutines for the parallel backend (0 = one per CPU)")
+    fmt.Println("  -engine-pool <on|off> Reuse intermediate buffers between layers (default: on)")
+    fmt.Println("  -verbose           Enable verbose output")
+    fmt.Println("  -quiet             Suppress non-essential output")
+    fmt.Println("  -version           Show version information")
+    fmt.Println("  -help              Show this help message")
+
+    fmt.Println("\nENDPOINTS:")
+    fmt.Println("  GET  /v1/models                  List the served models, the default first")
+    fmt.Println("  POST /v1/models/<name>/predict   Classify the request body with model <name>")
+    fmt.Println("  POST /v1/predict?model=<name>    The same; without ?model= the default model")
+    fmt.Println("  GET  /v1/models/<name>/stream    WebSocket: send images as binary messages, receive one")
+    fmt.Println("  GET  /v1/stream?model=<name>     JSON answer per message tagged with its \"frame\" number")
+    fmt.Println("  GET  /metrics                    Prometheus metrics")
+    fmt.Println("  GET  /healthz, /readyz           Liveness and readiness probes of every model")
+    fmt.Println("  The body is PNG or JPEG data, or a binary image in -image-format. Add ?topk=N")
+    fmt.Println("  for the N most probable classes.")
+
+    fmt.Println("\nGRPC:")
+    fmt.Println("  With -grpc-addr set, gocnn.inference.v1.Inference (internal/server/grpc/inference.proto)")
+    fmt.Println("  answers Predict, PredictBatch, PredictStream and GetModelInfo for the same models,")
+    fmt.Println("  taking preprocessed CHW float32 images. It shares the TLS certificate, the limits")
+    fmt.Println("  and the API keys, passed as \"authorization: Bearer <key>\" or \"x-api-key\" metadata.")
+
+    fmt.Println("\nAUTHENTICATION:")
+    fmt.Println("  With inference.api_keys, inference.api_keys_file or -api-keys-file set, every")
+    fmt.Println("  request but the probes needs \"Authorization: Bearer <key>\" or \"X-API-Key: <key>\";")
+    fmt.Println("  WebSocket clients may pass ?access_token=<key>. Others get 401.")
+
+    fmt.Println("\nBACKPRESSURE:")
+    fmt.Println("  With serving.max_inflight (or -max-inflight) set, further requests wait in a queue of")
+    fmt.Println("  serving.max_queue (default 4 per slot) for up to serving.queue_timeout; a full queue,")
+    fmt.Println("  a timed-out wait or a client over serving.rate_limit gets 429 with Retry-After. Queue")
+    fmt.Println("  times and rejections appear on /metrics as gocnn_request_queue_seconds and")
+    fmt.Println("  gocnn_requests_rejected_total.")
+
+    fmt.Println("\nAUDIT LOG:")
+    fmt.Println("  With serving.audit_log (or -audit-log) set, every prediction and stream frame is")
+    fmt.Println("  logged as JSON: request ID, model, client, SHA-256 of the input, status, predicted")
+    fmt.Println("  class, confidence and latency. The request ID is the client's X-Request-ID or a")
+    fmt.Println("  generated one, echoed in the response. The file rotates to <file>.1, <file>.2, ...")
+    fmt.Println("  past serving.audit_max_mb (default 100), keeping serving.audit_max_files (default 5).")
+
+    fmt.Println("\nENVIRONMENT:")
+    fmt.Println("  GOCNN_ENGINE          Default for -engine")
+    fmt.Println("  GOCNN_ENGINE_WORKERS  Default for -engine-workers")
+    fmt.Println("  GOCNN_ENGINE_POOL     Default for -engine-pool")
+
+    fmt.Println("\nEXAMPLES:")
+    fmt.Printf("  # Serve the bundled weights and classify a test image\n")
+    fmt.Printf("  %s -weights ./weights &\n", AppName)
+    fmt.Printf("  curl --data-binary @testdata/test_images/test_img_0.bin 'localhost:8080/v1/predict?topk=3'\n\n")
+
+    fmt.Printf("  # Serve the models of serving.models, batching concurrent requests\n")
+    fmt.Printf("  %s -config configs/serve.yaml -max-batch 16\n", AppName)
+    fmt.Printf("  curl --data-binary @cat.png localhost:8080/v1/models/cifar10-int8/predict\n\n")
+
+    fmt.Printf("  # Expose the server publicly over HTTPS with API keys\n")
+    fmt.Printf("  %s -weights ./weights -addr :8443 -tls-cert cert.pem -tls-key key.pem -api-keys-file keys.txt\n", AppName)
+    fmt.Printf("  curl -H 'Authorization: Bearer <key>' --data-binary @cat.png https://host:8443/v1/predict\n")
+}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/porcelain"
+	"duchm1606/gocnn/internal/runinfo"
+	"duchm1606/gocnn/internal/server"
+	"duchm1606/gocnn/internal/soak"
+)
+
+// Version information
+const (
+    AppName    = "gocnn-soak"
+    AppVersion = "1.0.0"
+    AppDesc    = "Long-running TinyCNN inference soak test with leak detection"
+)
+
+// exitGrowing is the exit status when a metric is flagged as growing
+const exitGrowing = 3
+
+// warmupFraction is the share of the run whose samples are left out of the growth analysis
+const warmupFraction = 0.1
+
+// Command line flags
+var (
+    weightsPath = flag.String("weights", "", "Path to model weights directory (required)")
+    imagesPath  = flag.String("images", "", "Directory of images (*.bin) to cycle through (required)")
+    imageFormat = flag.String("image-format", "float32", "Image file encoding: float32 (values in [0, 1]) or uint8 (0-255)")
+    configPath  = flag.String("config", "configs/cifar10.yaml", "Path to model configuration file")
+    numImages   = flag.Int("samples", 100, "Maximum number of images to cycle through")
+    hours       = flag.Float64("hours", 4, "Duration of the run in hours (fractions allowed)")
+    interval    = flag.Duration("interval", 30*time.Second, "Time between process samples")
+    csvPath     = flag.String("csv", "", "Also save every sample to this CSV file")
+    reportPath  = flag.String("report", "", "Also save the stability report to this file")
+    metricsAddr = flag.String("metrics-addr", "", "Serve Prometheus /metrics and /healthz, /readyz probes at this address (e.g. :9090)")
+    runManifest = flag.String("run-manifest", "", "Write a run.json with version, commit, config/weights hashes, engine and host")
+    verbose     = flag.Bool("verbose", false, "Enable verbose output")
+    quiet       = flag.Bool("quiet", false, "Suppress non-essential output")
+    showVersion = flag.Bool("version", false, "Show version information")
+    showHelp    = flag.Bool("help", false, "Show detailed help")
+
+    porcelainMode = flag.Bool("porcelain", false, "Print only stable tab-separated records for scripts")
+)
+
+func main() {
+    flag.Parse()
+
+    if *showVersion {
+        printVersion()
+        return
+    }
+
+    if *showHelp {
+        printHelp()
+        return
+    }
+
+    if err := validateArgs(); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        fmt.Fprintf(os.Stderr, "Use -help for usage information\n")
+        os.Exit(1)
+    }
+
+    // The porcelain records replace all other output
+    if *porcelainMode {
+        *quiet = true
+        *verbose = false
+    }
+
+    report, err := runSoak()
+    if err != nil {
+        errs.Fprint(os.Stderr, "Soak test failed", err)
+        os.Exit(1)
+    }
+    if len(report.Growing()) > 0 {
+        os.Exit(exitGrowing)
+    }
+}
+
+// validateArgs validates command line arguments
+func validateArgs() error {
+    if *weightsPath == "" {
+        return fmt.Errorf("weights path is required (use -weights)")
+    }
+
+    if *imagesPath == "" {
+        return fmt.Errorf("images path is required (use -images)")
+    }
+
+    paths := map[string]string{
+        "weights directory": *weightsPath,
+        "images directory":  *imagesPath,
+        "config file":       *configPath,
+    }
+
+    for desc, path := range paths {
+        if _, err := os.Stat(path); os.IsNotExist(err) {
+            return fmt.Errorf("%s does not exist: %s", desc, path)
+        }
+    }
+
+    if *numImages <= 0 {
+        return fmt.Errorf("number of samples must be positive, got %d", *numImages)
+    }
+
+    if !(*hours > 0) {
+        return fmt.Errorf("-hours must be positive, got %g", *hours)
+    }
+
+    if *interval <= 0 {
+        return fmt.Errorf("-interval must be positive, got %v", *interval)
+    }
+
+    if _, err := data.ParseImageFormat(*imageFormat); err != nil {
+        return fmt.Errorf("-image-format: %w", err)
+    }
+
+    return nil
+}
+
+// soakRun is the raw outcome of the inference loop
+type soakRun struct {
+    samples     []soak.Sample
+    changed     int64 // Inferences whose class differed from the image's first prediction
+    interrupted bool  // Stopped by a signal before the deadline
+}
+
+// runSoak loads the model and images, runs the soak loop and prints the stability report
+func runSoak() (*soak.Report, error) {
+    cfg, err := config.Load(*configPath)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load configuration: %w", err)
+    }
+
+    if !*quiet {
+        fmt.Printf("Starting %s v%s\n", AppName, AppVersion)
+        fmt.Printf("Loading CNN model from %s...\n", *weightsPath)
+    }
+    cnn, err := model.NewTinyCNNFromConfig(*weightsPath, cfg.Model)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load model: %w", err)
+    }
+    if _, err := cnn.Warmup(cfg.Inference.WarmupInferences); err != nil {
+        return nil, err
+    }
+    if *runManifest != "" {
+        if err := writeRunManifest(cnn); err != nil {
+            return nil, err
+        }
+    }
+
+    images, err := loadImages(cfg)
+    if err != nil {
+        return nil, err
+    }
+
+    duration := time.Duration(*hours * float64(time.Hour))
+    if !*quiet {
+        fmt.Printf("Running inference on %d images for %v, sampling every %v (Ctrl-C stops early)\n\n",
+            len(images), duration, *interval)
+    }
+
+    var predictor model.Predictor = cnn
+    if *metricsAddr != "" {
+        metrics := server.NewMetrics()
+        predictor = metrics.Instrument(cnn)
+        closeMetrics, err := serveEndpoints(*metricsAddr, metrics, server.NewHealth(cnn, 0))
+        if err != nil {
+            return nil, err
+        }
+        defer closeMetrics()
+    }
+
+    // A signal ends the run early but still produces the report
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    run, err := soakLoop(ctx, predictor, images, duration)
+    if err != nil {
+        return nil, err
+    }
+
+    warmup := 0
+    for _, s := range run.samples {
+        if s.Elapsed.Seconds() < warmupFraction*duration.Seconds() {
+            warmup++
+        }
+    }
+    report := soak.Analyze(run.samples, warmup)
+
+    if *porcelainMode {
+        if err := writePorcelain(os.Stdout, report, run); err != nil {
+            return nil, err
+        }
+    } else {
+        if !*quiet {
+            fmt.Println()
+        }
+        writeReport(os.Stdout, report, run)
+    }
+
+    if *csvPath != "" {
+        if err := writeSamplesCSV(*csvPath, run.samples); err != nil {
+            return nil, err
+        }
+        if !*quiet {
+            fmt.Printf("\nSamples saved to: %s\n", *csvPath)
+        }
+    }
+
+    if *reportPath != "" {
+        file, err := os.Create(*reportPath)
+        if err != nil {
+            return nil, fmt.Errorf("failed to create report: %w", err)
+        }
+        defer file.Close()
+        writeReport(file, report, run)
+
+        if !*quiet {
+            fmt.Printf("\nReport saved to: %s\n", *reportPath)
+        }
+    }
+
+    return report, nil
+}
+
+// writeRunManifest saves the -run-manifest of a soak test of cnn
+func writeRunManifest(cnn model.Predictor) error {
+    run := runinfo.New(AppName, AppVersion)
+    if err := run.SetConfig(*configPath); err != nil {
+        return err
+    }
+    if err := run.SetWeights(*weightsPath); err != nil {
+        return err
+    }
+    run.SetModel(cnn.Info())
+    return run.Write(*runManifest)
+}
+
+// loadImages reads up to -samples images from the images directory in name order
+func loadImages(cfg *config.Config) ([][]float32, error) {
+    matches, err := filepath.Glob(filepath.Join(*imagesPath, "*.bin"))
+    if err != nil {
+        return nil, err
+    }
+    if len(matches) == 0 {
+        return nil, fmt.Errorf("no images (*.bin) found in %s", *imagesPath)
+    }
+    sort.Strings(matches)
+    if len(matches) > *numImages {
+        matches = matches[:*numImages]
+    }
+
+    format, err := data.ParseImageFormat(*imageFormat)
+    if err != nil {
+        return nil, err
+    }
+    preprocessor, err := data.NewPreprocessor(format, cfg.Data, cfg.Model)
+    if err != nil {
+        return nil, err
+    }
+
+    images := make([][]float32, len(matches))
+    for i, path := range matches {
+        fm, err := preprocessor.Load(path)
+        if err != nil {
+            return nil, fmt.Errorf("failed to load image: %w", err)
+        }
+        images[i] = fm.Data
+    }
+    return images, nil
+}
+
+// serveEndpoints serves metrics on /metrics and the health probes on /healthz and
+// /readyz at addr in the background until the returned function is called
+func serveEndpoints(addr string, metrics *server.Metrics, health *server.Health) (func(), error) {
+    listener, err := net.Listen("tcp", addr)
+    if err != nil {
+        return nil, errs.WithHint(fmt.Errorf("failed to serve metrics: %w", err),
+            "pick a free address with -metrics-addr, e.g. -metrics-addr :9091")
+    }
+    mux := http.NewServeMux()
+    mux.Handle("/metrics", metrics)
+    health.Register(mux)
+    srv := &http.Server{Handler: mux}
+    go srv.Serve(listener)
+
+    if !*quiet {
+        fmt.Printf("Serving metrics on http://%s/metrics\n", listener.Addr())
+    }
+    return func() { srv.Close() }, nil
+}
+
+// soakLoop cycles through images until duration has passed or ctx is cancelled,
+// sampling the process every -interval and once more at the end
+func soakLoop(ctx context.Context, cnn model.Predictor, images [][]float32, duration time.Duration) (*soakRun, error) {
+    run := &soakRun{}
+    firstClass := make([]int, len(images))
+
+    start := time.Now()
+    deadline := start.Add(duration)
+    nextSample := start.Add(*interval)
+    run.samples = append(run.samples, soak.TakeSample(0, 0))
+
+    var inferences int64
+    for i := 0; ; i++ {
+        now := time.Now()
+        done := !now.Before(deadline)
+        if ctx.Err() != nil {
+            run.interrupted = true
+            done = true
+        }
+
+        if done || !now.Before(nextSample) {
+            sample := soak.TakeSample(now.Sub(start), inferences)
+            run.samples = append(run.samples, sample)
+            printProgress(sample)
+
+            // A slow sample must not trigger a burst of catch-up samples
+            for !nextSample.After(now) {
+                nextSample = nextSample.Add(*interval)
+            }
+        }
+        if done {
+            return run, nil
+        }
+
+        idx := i % len(images)
+        result, err := cnn.Predict(ctx, images[idx])
+        if err != nil && ctx.Err() != nil {
+            // Interrupted mid-inference; the next pass records the final sample
+            continue
+        }
+        if err != nil {
+            return nil, fmt.Errorf("inference %d failed: %w", inferences+1, err)
+        }
+        inferences++
+
+        if i < len(images) {
+            firstClass[idx] = result.PredictedClass
+        } else if result.PredictedClass != firstClass[idx] {
+            run.changed++
+        }
+    }
+}
+
+// printProgress prints one line per sample unless quiet
+func printProgress(s soak.Sample) {
+    if *quiet {
+        return
+    }
+    line := fmt.Sprintf("[%s] %d inferences, heap %s, goroutines %d",
+        formatElapsed(s.Elapsed), s.Inferences, formatBytes(float64(s.HeapAlloc)), s.Goroutines)
+    if s.RSS > 0 {
+        line += ", RSS " + formatBytes(float64(s.RSS))
+    }
+    if *verbose {
+        line += fmt.Sprintf(", %d heap objects, %d GCs", s.HeapObjects, s.NumGC)
+    }
+    fmt.Println(line)
+}
+
+// writeReport prints the stability report
+func writeReport(w io.Writer, report *soak.Report, run *soakRun) {
+    fmt.Fprintf(w, "Soak Test Report:\n")
+    stopped := ""
+    if run.interrupted {
+        stopped = " (stopped early)"
+    }
+    fmt.Fprintf(w, "  Duration:    %s%s\n", formatElapsed(report.Duration), stopped)
+    rate := 0.0
+    if report.Duration > 0 {
+        rate = float64(report.Inferences) / report.Duration.Seconds()
+    }
+    fmt.Fprintf(w, "  Inferences:  %d (%.1f/s)\n", report.Inferences, rate)
+    fmt.Fprintf(w, "  Samples:     %d (%d warm-up samples not analyzed)\n", report.Samples, report.WarmupSamples)
+    if run.changed == 0 {
+        fmt.Fprintf(w, "  Predictions: consistent\n")
+    } else {
+        fmt.Fprintf(w, "  Predictions: %d inferences changed class for the same image\n", run.changed)
+    }
+
+    fmt.Fprintf(w, "\n  %-13s %12s %12s %12s %12s %12s %12s  %s\n",
+        "Metric", "First", "Last", "Min", "Max", "Growth", "Per hour", "Status")
+    for _, trend := range report.Trends {
+        if !trend.Available {
+            fmt.Fprintf(w, "  %-13s %12s %12s %12s %12s %12s %12s  %s\n", trend.Metric, "-", "-", "-", "-", "-", "-", "unavailable")
+            continue
+        }
+        status := "ok"
+        switch {
+        case trend.Growing:
+            status = "GROWING"
+        case !report.Judged:
+            status = "not judged"
+        }
+        fmt.Fprintf(w, "  %-13s %12s %12s %12s %12s %12s %12s  %s\n", trend.Metric,
+            formatValue(trend.Unit, trend.First), formatValue(trend.Unit, trend.Last),
+            formatValue(trend.Unit, trend.Min), formatValue(trend.Unit, trend.Max),
+            formatValue(trend.Unit, trend.Growth), formatValue(trend.Unit, trend.SlopePerHour), status)
+    }
+
+    fmt.Fprintf(w, "\nVerdict: %s\n", report.Verdict())
+    switch report.Verdict() {
+    case "inconclusive":
+        fmt.Fprintf(w, "  Too few samples to judge growth (need %d after warm-up); run longer or lower -interval\n",
+            soak.MinSamples)
+    case "growing":
+        fmt.Fprintf(w, "  Each of the %d windows of the run started higher than the last for: %v\n",
+            soak.Windows, report.Growing())
+    }
+}
+
+// writePorcelain prints the report and every sample as stable tab-separated records (see printHelp)
+func writePorcelain(w io.Writer, report *soak.Report, run *soakRun) error {
+    records := porcelain.NewWriter(w)
+    records.Begin(AppName, AppVersion)
+    records.Record("soak", report.Duration, report.Inferences, report.Samples, report.WarmupSamples,
+        run.changed, run.interrupted, report.Verdict())
+    for _, trend := range report.Trends {
+        records.Record("metric", trend.Metric, trend.Unit, trend.Available, trend.First, trend.Last,
+            trend.Min, trend.Max, trend.Growth, trend.SlopePerHour, trend.Growing)
+    }
+    for _, s := range run.samples {
+        records.Record("sample", s.Elapsed, s.Inferences, int64(s.HeapAlloc), int64(s.HeapObjects),
+            s.Goroutines, int64(s.RSS), int64(s.NumGC))
+    }
+    return records.Flush()
+}
+
+// writeSamplesCSV saves every sample with one row per sample
+func writeSamplesCSV(path string, samples []soak.Sample) error {
+    file, err := os.Create(path)
+    if err != nil {
+        return fmt.Errorf("failed to create CSV file: %w", err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"elapsed_seconds", "inferences", "heap_bytes", "heap_objects", "goroutines", "rss_bytes", "num_gc"})
+    for _, s := range samples {
+        writer.Write([]string{
+            strconv.FormatFloat(s.Elapsed.Seconds(), 'f', 3, 64),
+            strconv.FormatInt(s.Inferences, 10),
+            strconv.FormatUint(s.HeapAlloc, 10),
+            strconv.FormatUint(s.HeapObjects, 10),
+            strconv.Itoa(s.Goroutines),
+            strconv.FormatUint(s.RSS, 10),
+            strconv.FormatUint(uint64(s.NumGC), 10),
+        })
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("failed to write CSV file: %w", err)
+    }
+    return nil
+}
+
+// formatValue formats a metric value in its unit
+func formatValue(unit string, v float64) string {
+    if unit == "bytes" {
+        return formatBytes(v)
+    }
+    return strconv.FormatFloat(math.Round(v)+0, 'f', 0, 64) // +0 turns -0 into 0
+}
+
+// formatBytes formats a byte count in KB or MB
+func formatBytes(v float64) string {
+    if v >= 1<<20 || v <= -(1<<20) {
+        return fmt.Sprintf("%.1f MB", v/(1<<20))
+    }
+    return fmt.Sprintf("%.1f KB", v/(1<<10))
+}
+
+// formatElapsed formats a duration as h:mm:ss
+func formatElapsed(d time.Duration) string {
+    seconds := int64(d.Round(time.Second).Seconds())
+    return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// printVersion displays version information
+func printVersion() {
+    fmt.Printf("%s version %s\n", AppName, AppVersion)
+    fmt.Printf("%s\n", AppDesc)
+}
+
+// printHelp displays detailed help information
+func printHelp() {
+    fmt.Printf("%s - %s\n\n", AppName, AppDesc)
+
+    fmt.Println("USAGE:")
+    fmt.Printf("  %s -weights <path> -images <path> [options]\n\n", AppName)
+
+    fmt.Println("REQUIRED:")
+    fmt.Println("  -weights <path>    Path to directory containing model weights")
+    fmt.Println("  -images <path>     Directory of images (*.bin) to cycle through")
+
+    fmt.Println("\nOPTIONS:")
+    fmt.Println("  -hours <h>         Duration of the run in hours, fractions allowed (default: 4)")
+    fmt.Println("  -interval <d>      Time between process samples (default: 30s)")
+    fmt.Println("  -config <path>     Path to model configuration file (default: configs/cifar10.yaml)")
+    fmt.Println("  -samples <n>       Maximum number of images to cycle through (default: 100)")
+    fmt.Println("  -image-format <f>  Image file encoding: float32 (default) or uint8")
+    fmt.Println("  -csv <file>        Also save every sample to <file>")
+    fmt.Println("  -report <file>     Also save the stability report to <file>")
+    fmt.Println("  -metrics-addr <a>  Serve Prometheus metrics on http://<a>/metrics and health probes on")
+    fmt.Println("                     /healthz and /readyz during the run")
+    fmt.Println("  -run-manifest <file> Write version, commit, config/weights hashes, engine and host to <file>")
+    fmt.Println("  -verbose           Enable verbose output")
+    fmt.Println("  -quiet             Suppress non-essential output")
+    fmt.Println("  -porcelain         Print only stable tab-separated records (see PORCELAIN OUTPUT)")
+    fmt.Println("  -version           Show version information")
+    fmt.Println("  -help              Show this help message")
+
+    fmt.Println("\nLEAK DETECTION:")
+    fmt.Println("  Every sample forces a GC and records the live heap, heap objects, goroutines and")
+    fmt.Println("  RSS (Linux only). Samples from the first 10% of the run are warm-up. The rest are")
+    fmt.Printf("  split into %d windows; a metric is flagged as growing when every window's minimum\n", soak.Windows)
+    fmt.Println("  is above the previous one and the total rise exceeds its tolerance (heap: 1 MB")
+    fmt.Println("  and 10%, heap objects: 1000 and 10%, goroutines: 1, RSS: 4 MB and 10%).")
+    fmt.Printf("  At least %d samples after warm-up are needed for a verdict.\n", soak.MinSamples)
+
+    fmt.Println("\nEXIT STATUS:")
+    fmt.Println("  0  stable, or too short to judge")
+    fmt.Println("  1  error")
+    fmt.Printf("  %d  a metric was flagged as growing\n", exitGrowing)
+
+    fmt.Println("\nPORCELAIN OUTPUT (-porcelain, one tab-separated record per line):")
+    fmt.Println("  porcelain  <format version> <tool> <tool version>")
+    fmt.Println("  soak       <duration ns> <inferences> <samples> <warm-up samples> <changed predictions> <interrupted> <verdict>")
+    fmt.Println("  metric     <name> <unit> <available> <first> <last> <min> <max> <growth> <per hour> <growing>")
+    fmt.Println("  sample     <elapsed ns> <inferences> <heap bytes> <heap objects> <goroutines> <rss bytes> <gcs>")
+
+    fmt.Println("\nEXAMPLES:")
+    fmt.Printf("  # Four hours on the test images, keeping the samples for plotting\n")
+    fmt.Printf("  %s -weights ./weights -images ./testdata/test_images -hours 4 -csv soak.csv\n\n", AppName)
+
+    fmt.Printf("  # A quick ten-minute check of the int8 bundle\n")
+    fmt.Printf("  %s -weights ./weights-int8 -images ./testdata/test_images -hours 0.17 -interval 10s\n", AppName)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/metrics"
+	"duchm1606/gocnn/internal/model"
+)
+
+// crossValidationFileName is the report -folds writes to the output directory
+const crossValidationFileName = "crossval.json"
+
+// crossValidate trains a model per -folds fold of set on the other folds, into
+// <output>/foldN, evaluates it on the fold it did not see and reports the
+// mean and spread of the fold results
+func crossValidate(ctx context.Context, set *data.CIFAR10Set) error {
+    n := set.Len()
+    if *limit > 0 && *limit < n {
+        n = *limit
+    }
+    folds, err := metrics.KFold(n, *numFolds, *seed)
+    if err != nil {
+        return err
+    }
+    if err := writeRunManifest(*seed); err != nil {
+        return err
+    }
+
+    cfg, err := config.Load(*configPath)
+    if err != nil {
+        return fmt.Errorf("failed to load configuration: %w", err)
+    }
+    plain, err := data.NewPreprocessor(data.BinaryFloat32, cfg.Data, cfg.Model)
+    if err != nil {
+        return err
+    }
+
+    start := time.Now()
+    evaluator := metrics.NewEvaluator(*numWorkers)
+    report, err := evaluator.CrossValidate(set, data.CIFAR10Classes, plain, folds,
+        func(fold metrics.Fold) (model.Predictor, error) {
+            slog.Info("training fold", "fold", fold.Index+1, "folds", len(folds), "images", len(fold.Train))
+            t, err := newTrainer(data.Subset(set, fold.Train), filepath.Join(*outputPath, foldName(fold)))
+            if err != nil {
+                return nil, err
+            }
+            foldStart := time.Now()
+            interrupted, err := t.run(ctx)
+            if err != nil {
+                return nil, err
+            }
+            record("trained", t.opt.Steps(), time.Since(foldStart), t.output, interrupted)
+            if interrupted {
+                return nil, fmt.Errorf("interrupted; a cross-validation starts over, the fold's checkpoint is in %s",
+                    t.output)
+            }
+            return model.NewTinyCNNWithWeights(t.arch, t.weights)
+        })
+    if err != nil {
+        return err
+    }
+
+    path := filepath.Join(*outputPath, crossValidationFileName)
+    if err := writeCrossValidation(path, report); err != nil {
+        return err
+    }
+    if records != nil {
+        recordCrossValidation(report, n, time.Since(start), path)
+        return nil
+    }
+    printCrossValidation(report)
+    fmt.Printf("Cross-validated %d folds of %d images in %s, report saved to: %s\n", len(folds), n,
+        time.Since(start).Round(time.Second), path)
+    return nil
+}
+
+// foldName names the checkpoint directory of fold
+func foldName(fold metrics.Fold) string {
+    return fmt.Sprintf("fold%d", fold.Index+1)
+}
+
+// writeCrossValidation saves report as indented JSON
+func writeCrossValidation(path string, report *metrics.CrossValidationReport) error {
+    raw, err := json.MarshalIndent(report, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.WriteFile(path, append(raw, '\n'), 0644); err != nil {
+        return fmt.Errorf("failed to save the cross-validation report: %w", err)
+    }
+    return nil
+}
+
+// printCrossValidation prints the fold results and their summary as a table
+func printCrossValidation(report *metrics.CrossValidationReport) {
+    fmt.Printf("\n%-6s %7s %6s %8s %8s %9s %7s %7s\n", "fold", "train", "test", "top-1", "top-5", "macro F1", "MCC", "ECE")
+    for _, fold := range report.Folds {
+        fmt.Printf("%-6d %7d %6d %7.2f%% %7.2f%% %9.4f %7.4f %7.4f\n", fold.Fold, fold.TrainSamples,
+            fold.TestSamples, fold.Top1Accuracy*100, fold.Top5Accuracy*100, fold.MacroF1, fold.MCC,
+            fold.ExpectedCalibrationError)
+    }
+    fmt.Printf("\nTop-1 accuracy:  %.2f%% ± %.2f%%\n", report.Top1Accuracy.Mean*100, report.Top1Accuracy.Std*100)
+    fmt.Printf("Top-5 accuracy:  %.2f%% ± %.2f%%\n", report.Top5Accuracy.Mean*100, report.Top5Accuracy.Std*100)
+    fmt.Printf("Macro F1:        %.4f ± %.4f\n", report.MacroF1.Mean, report.MacroF1.Std)
+    fmt.Printf("MCC:             %.4f ± %.4f\n", report.MCC.Mean, report.MCC.Std)
+    fmt.Printf("ECE:             %.4f ± %.4f\n\n", report.ExpectedCalibrationError.Mean,
+        report.ExpectedCalibrationError.Std)
+}
+
+// recordCrossValidation prints the fold results and their summary as porcelain records
+func recordCrossValidation(report *metrics.CrossValidationReport, images int, elapsed time.Duration, path string) {
+    for _, fold := range report.Folds {
+        record("fold", fold.Fold, fold.TrainSamples, fold.TestSamples, fold.Top1Accuracy, fold.Top5Accuracy,
+            fold.MacroF1, fold.MCC, fold.ExpectedCalibrationError)
+    }
+    record("crossval", len(report.Folds), images, report.Top1Accuracy.Mean, report.Top1Accuracy.Std,
+        report.Top5Accuracy.Mean, report.Top5Accuracy.Std, report.MacroF1.Mean, report.MacroF1.Std,
+        report.MCC.Mean, report.MCC.Std, report.ExpectedCalibrationError.Mean,
+        report.ExpectedCalibrationError.Std, elapsed, path)
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"duchm1606/gocnn/internal/data/augment"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/logging"
+	"duchm1606/gocnn/internal/porcelain"
+	"duchm1606/gocnn/internal/runinfo"
+)
+
+// Version information
+const (
+    AppName    = "gocnn-train"
+    AppVersion = "1.0.0"
+    AppDesc    = "Train or fine-tune TinyCNN on CIFAR-10 batch files"
+)
+
+// Command line flags
+var (
+    trainPath     = flag.String("train", "", "CIFAR-10 batch files to train on: comma-separated files, or a directory of data_batch_*.bin (required)")
+    evalPath      = flag.String("eval", "", "CIFAR-10 batch file to evaluate on, e.g. test_batch.bin")
+    outputPath    = flag.String("output", "", "Directory to write checkpoints to (required)")
+    weightsPath   = flag.String("weights", "", "Weights directory to fine-tune from (default: train from scratch)")
+    configPath    = flag.String("config", "configs/cifar10.yaml", "Path to model configuration file")
+    optimizerName = flag.String("optimizer", "sgd", "Optimizer: sgd, adam or adamw")
+    learningRate  = flag.Float64("lr", 0, "Learning rate (default: 0.01 for sgd, 0.001 for adam and adamw)")
+    momentum      = flag.Float64("momentum", 0.9, "SGD momentum")
+    nesterov      = flag.Bool("nesterov", false, "Use Nesterov momentum with sgd")
+    weightDecay   = flag.Float64("weight-decay", 5e-4, "Weight decay of the conv kernels")
+    epochs        = flag.Int("epochs", 10, "Passes over the training set")
+    batchSize     = flag.Int("batch-size", 64, "Images per training step")
+    bnMomentum    = flag.Float64("bn-momentum", 0.99, "Weight of the old batch norm moving statistics per step")
+    freezeBN      = flag.Bool("freeze-bn", false, "Normalize with the moving statistics and keep them fixed (fine-tuning)")
+    freezeLayers  = flag.String("freeze", "", "Comma-separated conv layers to keep as they are, e.g. conv1,conv2")
+    headOnly      = flag.Bool("head-only", false, "Freeze every conv layer but the last (the classifier)")
+    trainAffine   = flag.Bool("train-frozen-bn", false, "Still train the batch norm scale and shift of frozen layers")
+    reinitHead    = flag.Bool("reinit-head", false, "Start the last conv layer from fresh weights, for a new label set")
+    augmentSpec   = flag.String("augment", "", "Augment training images, e.g. hflip=0.5,crop=4,cutout=8 (see gocnn-benchmark -help)")
+    seed          = flag.Uint64("seed", 1, "Seed of the initial weights, the shuffling and the augmentations")
+    limit         = flag.Int("limit", 0, "Train on only the first n images (0 = all)")
+    evalSamples   = flag.Int("eval-samples", 0, "Evaluate on only the first n images (0 = all)")
+    evalEvery     = flag.Int("eval-every", 0, "Evaluate every n steps (default: at the end of every epoch)")
+    saveEvery     = flag.Int("checkpoint-every", 0, "Save a checkpoint every n steps (default: at the end of every epoch)")
+    logEvery      = flag.Int("log-every", 50, "Log the running loss and accuracy every n steps")
+    resumePath    = flag.String("resume", "", "Checkpoint, or the -output directory of an earlier run, to continue from")
+    numFolds      = flag.Int("folds", 0, "Cross-validate: train a model per fold of the training set on the others and report mean ± std")
+    numWorkers    = flag.Int("workers", 4, "Images processed in parallel")
+    verbose       = flag.Bool("verbose", false, "Enable verbose output")
+    quiet         = flag.Bool("quiet", false, "Suppress non-essential output")
+    logLevel      = flag.String("log-level", "", "Least severe log record written: debug, info, warn or error")
+    logFormat     = flag.String("log-format", "text", "Log record format on stderr: text or json")
+    runManifest   = flag.String("run-manifest", "", "Write a run.json with version, commit, config/weights hashes, seeds and host")
+    porcelainMode = flag.Bool("porcelain", false, "Print only stable tab-separated records for scripts")
+    showVersion   = flag.Bool("version", false, "Show version information")
+    showHelp      = flag.Bool("help", false, "Show detailed help")
+)
+
+// records receives the output in -porcelain mode and is nil otherwise
+var records *porcelain.Writer
+
+func main() {
+    flag.Parse()
+
+    if *showVersion {
+        printVersion()
+        return
+    }
+
+    if *showHelp {
+        printHelp()
+        return
+    }
+
+    if err := validateArgs(); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        fmt.Fprintf(os.Stderr, "Use -help for usage information\n")
+        os.Exit(1)
+    }
+
+    // The porcelain records replace all other output
+    if *porcelainMode {
+        *quiet = true
+        *verbose = false
+    }
+
+    logger, err := newLogger()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        os.Exit(1)
+    }
+    slog.SetDefault(logger)
+    if *porcelainMode {
+        records = porcelain.NewWriter(os.Stdout)
+        records.Begin(AppName, AppVersion)
+    }
+
+    err = runTraining()
+    if records != nil {
+        if flushErr := records.Flush(); err == nil {
+            err = flushErr
+        }
+    }
+    if err != nil {
+        errs.Fprint(os.Stderr, "Training failed", err)
+        os.Exit(1)
+    }
+}
+
+// record prints a porcelain record right away, so scripts can follow a long run;
+// without -porcelain it does nothing
+func record(kind string, fields ...any) {
+    if records == nil {
+        return
+    }
+    records.Record(kind, fields...)
+    records.Flush()
+}
+
+// writeRunManifest saves the -run-manifest of a run that shuffles, augments and
+// initializes fresh weights from seed
+func writeRunManifest(seed uint64) error {
+    if *runManifest == "" {
+        return nil
+    }
+
+    run := runinfo.New(AppName, AppVersion)
+    if err := run.SetConfig(*configPath); err != nil {
+        return err
+    }
+    // The weights the run starts from: the checkpoint it resumes or the weights it fine-tunes
+    start := *weightsPath
+    if *resumePath != "" {
+        start = *resumePath
+    }
+    if start != "" {
+        if err := run.SetWeights(start); err != nil {
+            return err
+        }
+    }
+
+    run.SetSeed("shuffle", seed)
+    if *augmentSpec != "" {
+        run.SetSeed("augment", seed)
+    }
+    if start == "" || *reinitHead {
+        run.SetSeed("init", seed)
+    }
+    if *numFolds > 0 {
+        run.SetSeed("folds", seed)
+    }
+
+    if err := run.Write(*runManifest); err != nil {
+        return err
+    }
+    slog.Info("run manifest saved", "path", *runManifest)
+    return nil
+}
+
+// validateArgs validates command line arguments
+func validateArgs() error {
+    if *trainPath == "" {
+        return fmt.Errorf("training data is required (use -train)")
+    }
+
+    if *outputPath == "" {
+        return fmt.Errorf("output directory is required (use -output)")
+    }
+
+    paths := map[string]string{
+        "config file": *configPath,
+    }
+    if *weightsPath != "" {
+        paths["weights directory"] = *weightsPath
+    }
+    if *resumePath != "" {
+        paths["checkpoint"] = *resumePath
+    }
+    if *evalPath != "" {
+        paths["evaluation file"] = *evalPath
+    }
+
+    for desc, path := range paths {
+        if _, err := os.Stat(path); os.IsNotExist(err) {
+            return fmt.Errorf("%s does not exist: %s", desc, path)
+        }
+    }
+
+    if *resumePath != "" && *weightsPath != "" {
+        return fmt.Errorf("-resume continues with the weights of its checkpoint; drop -weights")
+    }
+
+    switch *optimizerName {
+    case "sgd", "adam", "adamw":
+    default:
+        return fmt.Errorf("unknown optimizer %q (use sgd, adam or adamw)", *optimizerName)
+    }
+
+    if *learningRate < 0 || *weightDecay < 0 {
+        return fmt.Errorf("-lr and -weight-decay must not be negative")
+    }
+
+    if *epochs <= 0 || *batchSize <= 0 || *numWorkers <= 0 {
+        return fmt.Errorf("-epochs, -batch-size and -workers must be positive")
+    }
+
+    if *limit < 0 || *evalSamples < 0 || *evalEvery < 0 || *saveEvery < 0 || *logEvery < 0 {
+        return fmt.Errorf("-limit, -eval-samples, -eval-every, -checkpoint-every and -log-every must not be negative")
+    }
+
+    if *headOnly && *freezeLayers != "" {
+        return fmt.Errorf("-head-only already freezes every conv layer but the last; drop -freeze")
+    }
+
+    if *trainAffine && !*headOnly && *freezeLayers == "" {
+        return fmt.Errorf("-train-frozen-bn needs frozen layers (use -head-only or -freeze)")
+    }
+
+    if *reinitHead && *weightsPath == "" {
+        return fmt.Errorf("-reinit-head needs -weights; without them every layer starts fresh")
+    }
+
+    if *numFolds != 0 && *numFolds < 2 {
+        return fmt.Errorf("-folds must be at least 2")
+    }
+
+    if *numFolds > 0 && *resumePath != "" {
+        return fmt.Errorf("-folds trains a fresh run per fold and cannot -resume")
+    }
+
+    if *reinitHead && *resumePath != "" {
+        return fmt.Errorf("-reinit-head would discard the head of the checkpoint -resume continues")
+    }
+
+    if *evalEvery > 0 && *evalPath == "" {
+        return fmt.Errorf("-eval-every needs -eval")
+    }
+
+    if _, err := augment.Parse(*augmentSpec, *seed); err != nil {
+        return fmt.Errorf("-augment: %w", err)
+    }
+
+    return nil
+}
+
+// newLogger builds the logger of -log-level and -log-format, writing to stderr
+// Without -log-level, -verbose logs debug records too and -quiet only warnings and errors.
+func newLogger() (*slog.Logger, error) {
+    format, err := logging.ParseFormat(*logFormat)
+    if err != nil {
+        return nil, fmt.Errorf("-log-format: %w", err)
+    }
+
+    level := slog.LevelInfo
+    switch {
+    case *quiet:
+        level = slog.LevelWarn
+    case *verbose:
+        level = slog.LevelDebug
+    }
+    if *logLevel != "" {
+        if level, err = logging.ParseLevel(*logLevel); err != nil {
+            return nil, fmt.Errorf("-log-level: %w", err)
+        }
+    }
+    return logging.New(os.Stderr, logging.Options{Level: level, Format: format}), nil
+}
+
+// trainFiles returns the batch files of -train: the listed files, or the
+// data_batch_*.bin files of a directory in name order
+func trainFiles() ([]string, error) {
+    if info, err := os.Stat(*trainPath); err == nil && info.IsDir() {
+        files, err := filepath.Glob(filepath.Join(*trainPath, "data_batch_*.bin"))
+        if err != nil {
+            return nil, err
+        }
+        if len(files) == 0 {
+            err := fmt.Errorf("no data_batch_*.bin files in %s", *trainPath)
+            return nil, errs.WithHint(err, "extract cifar-10-binary.tar.gz and pass its cifar-10-batches-bin directory")
+        }
+        sort.Strings(files)
+        return files, nil
+    }
+
+    var files []string
+    for _, file := range strings.Split(*trainPath, ",") {
+        if file = strings.TrimSpace(file); file != "" {
+            files = append(files, file)
+        }
+    }
+    return files, nil
+}
+
+// printVersion displays version information
+func printVersion() {
+    fmt.Printf("%s version %s\n", AppName, AppVersion)
+    fmt.Printf("%s\n", AppDesc)
+}
+
+// printHelp displays detailed help information
+func printHelp() {
+    fmt.Printf("%s - %s\n\n", AppName, AppDesc)
+
+    fmt.Println("USAGE:")
+    fmt.Printf("  %s -train <files|dir> -output <dir> [options]\n\n", AppName)
+
+    fmt.Println("REQUIRED:")
+    fmt.Println("  -train <files|dir>   CIFAR-10 binary batch files, comma-separated, or the directory holding")
+    fmt.Println("                       data_batch_1.bin ... data_batch_5.bin")
+    fmt.Println("  -output <dir>        Directory to write checkpoints to")
+
+    fmt.Println("\nMODEL:")
+    fmt.Println("  -config <path>       Path to model configuration file (default: configs/cifar10.yaml)")
+    fmt.Println("  -weights <dir>       Fine-tune these weights instead of training from scratch")
+    fmt.Println("  -resume <path>       Continue a run from its checkpoint: the -output directory of the run, or")
+    fmt.Println("                       its training.ckpt. Given the run's other flags (-epochs may grow), the")
+    fmt.Println("                       resumed run takes exactly the steps the uninterrupted one would have")
+    fmt.Println("  -seed <n>            Seed of the initial weights, shuffling and augmentation (default: 1;")
+    fmt.Println("                       a resumed run keeps the seed of its checkpoint)")
+
+    fmt.Println("\nOPTIMIZATION:")
+    fmt.Println("  -optimizer <name>    sgd (default), adam or adamw")
+    fmt.Println("  -lr <rate>           Learning rate (default: 0.01 for sgd, 0.001 for adam and adamw)")
+    fmt.Println("  -momentum <m>        SGD momentum (default: 0.9)")
+    fmt.Println("  -nesterov            Use Nesterov momentum with sgd")
+    fmt.Println("  -weight-decay <d>    Weight decay of the conv kernels (default: 5e-4)")
+    fmt.Println("  -epochs <n>          Passes over the training set (default: 10)")
+    fmt.Println("  -batch-size <n>      Images per step (default: 64)")
+    fmt.Println("  -bn-momentum <m>     Weight of the old batch norm moving statistics per step (default: 0.99)")
+    fmt.Println("  -freeze-bn           Keep batch norm at its moving statistics, for fine-tuning on small batches")
+    fmt.Println("  -augment <spec>      Augment training images as they are drawn, e.g. hflip=0.5,crop=4,cutout=8:")
+    fmt.Println("                       flip, shift within zero padding, blank a square. Every epoch reshuffles")
+    fmt.Println("                       the images; -seed fixes both the order and the augmentations")
+    fmt.Println("  -limit <n>           Train on only the first n images")
+
+    fmt.Println("\nFINE-TUNING:")
+    fmt.Println("  -head-only           Freeze every conv layer but the last, the classifier: the backward pass")
+    fmt.Println("                       stops there, so training is a fraction of the cost of a full run")
+    fmt.Println("  -freeze <layers>     Comma-separated conv layers to freeze instead, e.g. conv1,conv2,conv3")
+    fmt.Println("  -train-frozen-bn     Still train the batch norm scale and shift of the frozen layers")
+    fmt.Println("  -reinit-head         Start the last conv layer from fresh weights, for a new label set")
+    fmt.Println("  Frozen layers keep their kernel, bias and batch norm (moving statistics included). For a")
+    fmt.Println("  different 10-way problem, write its labels 0-9 into the batch files and its class names into")
+    fmt.Println("  a copy of the config's class_names.")
+
+    fmt.Println("\nEVALUATION AND CHECKPOINTS:")
+    fmt.Println("  -eval <file>         CIFAR-10 batch file to evaluate on, e.g. test_batch.bin")
+    fmt.Println("  -eval-samples <n>    Evaluate on only the first n images")
+    fmt.Println("  -eval-every <n>      Evaluate every n steps (default: at the end of every epoch)")
+    fmt.Println("  -checkpoint-every <n> Save a checkpoint every n steps (default: at the end of every epoch)")
+    fmt.Println("  -log-every <n>       Log the running loss and accuracy every n steps (default: 50; 0 = epochs only)")
+    fmt.Println("  -workers <n>         Images processed in parallel (default: 4)")
+
+    fmt.Println("\nCROSS-VALIDATION:")
+    fmt.Println("  -folds <k>           Shuffle the training images with -seed and split them into k folds; train")
+    fmt.Println("                       a model per fold on the other k-1, from the same start, into <output>/foldN,")
+    fmt.Println("                       evaluate it on the fold it did not see, and report every fold and the")
+    fmt.Println("                       mean ± standard deviation of accuracy, macro F1, MCC and calibration error")
+    fmt.Println("                       (also saved to <output>/crossval.json)")
+
+    fmt.Println("\nOUTPUT:")
+    fmt.Println("  -verbose             Enable verbose output")
+    fmt.Println("  -quiet               Suppress non-essential output")
+    fmt.Println("  -log-level <level>   Least severe log record written: debug, info, warn or error")
+    fmt.Println("  -log-format <f>      Log records on stderr as text (default) or json, one per line")
+    fmt.Println("  -run-manifest <file> Write version, commit, config and starting weights hashes, the seeds")
+    fmt.Println("                       of the shuffling, augmentation and initialization, and host to <file>")
+    fmt.Println("  -porcelain           Print only stable tab-separated records (see PORCELAIN OUTPUT)")
+    fmt.Println("  -version             Show version information")
+    fmt.Println("  -help                Show this help message")
+
+    fmt.Println("\nCHECKPOINTS:")
+    fmt.Println("  <output>/convN/, <output>/batchnormN/   Weights in the layout of the exported weights, ready")
+    fmt.Println("                                          for -weights of every other tool")
+    fmt.Println("  <output>/training.ckpt                  One file with the weights, optimizer state, augmentation")
+    fmt.Println("                                          random state and position in the run, for -resume")
+    fmt.Println("  Batch norm moving statistics are updated while training, so checkpoints run as they are.")
+    fmt.Println("  Ctrl-C stops after the step in progress and saves a checkpoint first.")
+
+    fmt.Println("\nPORCELAIN OUTPUT (-porcelain, one tab-separated record per line, durations in ns):")
+    fmt.Println("  porcelain   <format version> <tool> <tool version>")
+    fmt.Println("  start       <output> <seed> <training images> <steps per epoch> <epochs>")
+    fmt.Println("  epoch       <epoch> <steps> <loss> <accuracy> <elapsed>")
+    fmt.Println("  evaluation  <epoch> <step> <samples> <top-1>")
+    fmt.Println("  checkpoint  <epoch> <epoch step> <steps> <output>")
+    fmt.Println("  trained     <steps> <duration> <output> <interrupted>")
+    fmt.Println("  With -folds, start through trained are printed for every fold, followed by:")
+    fmt.Println("  fold        <fold> <train samples> <test samples> <top-1> <top-5> <macro f1> <mcc> <ece>")
+    fmt.Println("  crossval    <folds> <images> <top-1 mean> <top-1 std> <top-5 mean> <top-5 std> <macro f1 mean>")
+    fmt.Println("              <macro f1 std> <mcc mean> <mcc std> <ece mean> <ece std> <duration> <report>")
+
+    fmt.Println("\nEXAMPLES:")
+    fmt.Printf("  # Train from scratch with SGD, evaluating on the test set after every epoch\n")
+    fmt.Printf("  %s -train ./cifar-10-batches-bin -eval ./cifar-10-batches-bin/test_batch.bin \\\n", AppName)
+    fmt.Printf("    -output ./weights-trained -epochs 30 -augment hflip=0.5,crop=4,cutout=8\n\n")
+
+    fmt.Printf("  # Fine-tune the bundled weights with AdamW and frozen batch norm\n")
+    fmt.Printf("  %s -weights ./weights -train ./cifar-10-batches-bin/data_batch_1.bin -output ./weights-ft \\\n", AppName)
+    fmt.Printf("    -optimizer adamw -lr 1e-4 -freeze-bn -epochs 2\n\n")
+
+    fmt.Printf("  # Repurpose the bundled weights for another 10-way problem, training only the classifier\n")
+    fmt.Printf("  %s -weights ./weights -config ./configs/mine.yaml -train ./mine/data_batch_1.bin \\\n", AppName)
+    fmt.Printf("    -output ./weights-mine -head-only -reinit-head -optimizer adam -epochs 5\n\n")
+
+    fmt.Printf("  # 5-fold cross-validation of a training recipe on the first 10,000 images\n")
+    fmt.Printf("  %s -train ./cifar-10-batches-bin -output ./weights-cv -folds 5 -limit 10000 -epochs 10\n\n", AppName)
+
+    fmt.Printf("  # Record the seeds and hashes of a run, and follow its epochs from a script\n")
+    fmt.Printf("  %s -train ./cifar-10-batches-bin -output ./weights-trained -epochs 30 \\\n", AppName)
+    fmt.Printf("    -run-manifest ./run.json -porcelain | awk -F'\\t' '$1 == \"epoch\"'\n\n")
+
+    fmt.Printf("  # Continue an interrupted run from its last checkpoint\n")
+    fmt.Printf("  %s -resume ./weights-trained -train ./cifar-10-batches-bin -eval ./cifar-10-batches-bin/test_batch.bin \\\n", AppName)
+    fmt.Printf("    -output ./weights-trained -epochs 30 -augment hflip=0.5,crop=4,cutout=8\n")
+}
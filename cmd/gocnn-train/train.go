@@ -0,0 +1,459 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/data/augment"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/metrics"
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/train"
+)
+
+// optimizer is what the training loop needs of train.SGD and train.Adam
+type optimizer interface {
+    train.Stateful
+    LearningRate() float32
+    Steps() int
+}
+
+// trainer holds everything one training run shares between its steps
+type trainer struct {
+    cfg       *config.Config
+    arch      *model.TinyCNNArchitecture
+    weights   *data.ModelWeights
+    network   *train.Network
+    opt       optimizer
+    grads     *train.Gradients
+    output    string             // Directory of the checkpoints
+    evalSet   *data.CIFAR10Set
+    loader    *data.TrainingLoader
+    plain     *data.Preprocessor // Evaluation images
+    rng       *augment.Rand      // Source of the training augmentations
+    seed      uint64             // Seed of the shuffling and augmentations
+    perEpoch  int                // Steps per epoch
+    epoch     int                // Epochs completed
+    epochStep int                // Steps taken into the current epoch
+}
+
+// runTraining executes the main training workflow
+func runTraining() error {
+    slog.Info("starting "+AppName, "version", AppVersion, "optimizer", *optimizerName, "epochs", *epochs,
+        "batch_size", *batchSize)
+
+    set, err := loadTrainSet()
+    if err != nil {
+        return err
+    }
+
+    // Ctrl-C stops after the step in progress, with a checkpoint to resume from
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    if *numFolds > 0 {
+        return crossValidate(ctx, set)
+    }
+
+    t, err := newTrainer(set, *outputPath)
+    if err != nil {
+        return err
+    }
+    if err := writeRunManifest(t.seed); err != nil {
+        return err
+    }
+
+    if t.epoch >= *epochs {
+        slog.Warn("checkpoint has already trained for the requested epochs", "epochs", t.epoch)
+        record("trained", t.opt.Steps(), time.Duration(0), t.output, false)
+        return nil
+    }
+
+    start := time.Now()
+    interrupted, err := t.run(ctx)
+    if err != nil {
+        return err
+    }
+    record("trained", t.opt.Steps(), time.Since(start), t.output, interrupted)
+    if records != nil {
+        return nil
+    }
+    if interrupted {
+        fmt.Printf("Interrupted at epoch %d, step %d; continue with -resume %s\n", t.epoch+1, t.opt.Steps(),
+            t.output)
+        return nil
+    }
+
+    fmt.Printf("Trained %d steps in %s, weights saved to: %s\n", t.opt.Steps(),
+        time.Since(start).Round(time.Second), t.output)
+    return nil
+}
+
+// run trains up to -epochs and reports whether ctx stopped it first, after
+// saving a checkpoint
+func (t *trainer) run(ctx context.Context) (bool, error) {
+    record("start", t.output, t.seed, t.loader.Size(), t.perEpoch, *epochs)
+    start := time.Now()
+    for ; t.epoch < *epochs; t.epoch++ {
+        epoch := t.epoch
+        var running, total train.BatchResult
+        for ; t.epochStep < t.perEpoch; t.epochStep++ {
+            batch := t.epochStep
+            result, err := t.step(epoch, batch)
+            if err != nil {
+                return false, fmt.Errorf("epoch %d, step %d: %w", epoch+1, t.opt.Steps()+1, err)
+            }
+            accumulate(&running, result)
+            accumulate(&total, result)
+
+            step := t.opt.Steps()
+            if *logEvery > 0 && step%*logEvery == 0 {
+                slog.Info("step", "epoch", epoch+1, "step", step, "loss", running.Loss/float64(running.Size),
+                    "accuracy", accuracy(running), "lr", t.opt.LearningRate())
+                running = train.BatchResult{}
+            }
+            if *evalEvery > 0 && step%*evalEvery == 0 {
+                if err := t.evaluate(epoch, step); err != nil {
+                    return false, err
+                }
+            }
+            if *saveEvery > 0 && step%*saveEvery == 0 {
+                if err := t.checkpoint(batch + 1); err != nil {
+                    return false, err
+                }
+            }
+            if ctx.Err() != nil {
+                return true, t.checkpoint(batch + 1)
+            }
+        }
+        t.epochStep = 0
+
+        slog.Info("epoch done", "epoch", epoch+1, "loss", total.Loss/float64(total.Size),
+            "accuracy", accuracy(total), "elapsed", time.Since(start).Round(time.Second))
+        record("epoch", epoch+1, t.opt.Steps(), total.Loss/float64(total.Size), accuracy(total), time.Since(start))
+        if *evalEvery == 0 && t.evalSet != nil {
+            if err := t.evaluate(epoch, t.opt.Steps()); err != nil {
+                return false, err
+            }
+        }
+        if *saveEvery == 0 || epoch == *epochs-1 {
+            if err := t.checkpoint(t.perEpoch); err != nil {
+                return false, err
+            }
+        }
+    }
+    return false, nil
+}
+
+// newTrainer loads the model, the evaluation data and the optimizer the flags
+// select, to train on set and checkpoint into output
+func newTrainer(set data.LabeledSet, output string) (*trainer, error) {
+    cfg, err := config.Load(*configPath)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load configuration: %w", err)
+    }
+    arch, err := model.ArchitectureFromConfig(cfg.Model)
+    if err != nil {
+        return nil, err
+    }
+    if arch.NumClasses != data.CIFAR10Classes {
+        return nil, fmt.Errorf("CIFAR-10 has %d classes, the model in %s %d", data.CIFAR10Classes,
+            *configPath, arch.NumClasses)
+    }
+
+    t := &trainer{cfg: cfg, arch: arch, output: output, seed: *seed}
+    var resumed *train.Checkpoint
+    if *resumePath != "" {
+        if resumed, err = loadCheckpoint(); err != nil {
+            return nil, err
+        }
+        t.weights, t.seed, t.epoch, t.epochStep = resumed.Weights, resumed.Seed, resumed.Epoch, resumed.EpochStep
+        slog.Info("resuming", "epoch", t.epoch+1, "epoch_step", t.epochStep, "steps", resumed.Optimizer.Steps)
+    } else if *weightsPath != "" {
+        slog.Info("loading weights", "path", *weightsPath)
+        t.weights, err = data.NewDataManager(*weightsPath, data.BinaryFloat32, data.OneHotText).LoadModelWeights()
+        if err != nil {
+            return nil, fmt.Errorf("failed to load weights: %w", err)
+        }
+    } else {
+        slog.Info("initializing weights", "seed", t.seed)
+        if t.weights, err = train.InitWeights(arch, t.seed); err != nil {
+            return nil, err
+        }
+    }
+
+    if *reinitHead {
+        if err := t.reinitHead(); err != nil {
+            return nil, err
+        }
+    }
+
+    frozen, err := frozenLayers(arch)
+    if err != nil {
+        return nil, err
+    }
+    if len(frozen) > 0 {
+        slog.Info("freezing layers", "layers", strings.Join(frozen, ","), "train_bn_affine", *trainAffine)
+    }
+    t.network, err = train.NewNetwork(arch, t.weights, train.NetworkOptions{
+        BatchNormMomentum: float32(*bnMomentum),
+        FreezeBatchNorm:   *freezeBN,
+        Frozen:            frozen,
+        TrainFrozenAffine: *trainAffine,
+        Workers:           *numWorkers,
+    })
+    if err != nil {
+        return nil, err
+    }
+    t.grads = t.network.NewGradients()
+
+    if t.opt, err = newOptimizer(); err != nil {
+        return nil, err
+    }
+    if err := t.loadData(set); err != nil {
+        return nil, err
+    }
+
+    if resumed != nil {
+        if err := t.opt.Restore(resumed.Optimizer); err != nil {
+            return nil, errs.WithHint(err, "resume with the -optimizer of the interrupted run")
+        }
+        if resumed.Random != nil {
+            if err := t.rng.UnmarshalBinary(resumed.Random); err != nil {
+                return nil, fmt.Errorf("failed to restore the augmentation random source: %w", err)
+            }
+        }
+        if t.epochStep > t.perEpoch {
+            return nil, fmt.Errorf("checkpoint is %d steps into an epoch of %d; resume with its -batch-size and -limit",
+                t.epochStep, t.perEpoch)
+        }
+    }
+    return t, nil
+}
+
+// loadCheckpoint reads the checkpoint of -resume: a checkpoint file, or the
+// output directory of an earlier run holding one
+func loadCheckpoint() (*train.Checkpoint, error) {
+    path := *resumePath
+    if info, err := os.Stat(path); err == nil && info.IsDir() {
+        path = filepath.Join(path, train.CheckpointFileName)
+    }
+    checkpoint, err := train.LoadCheckpoint(path)
+    if errors.Is(err, fs.ErrNotExist) {
+        err = errs.WithHint(err, "-resume takes the -output directory of an earlier %s run, or its %s",
+            AppName, train.CheckpointFileName)
+    }
+    return checkpoint, err
+}
+
+// frozenLayers returns the conv layers -freeze or -head-only select
+func frozenLayers(arch *model.TinyCNNArchitecture) ([]string, error) {
+    if *freezeLayers != "" {
+        var layers []string
+        for _, name := range strings.Split(*freezeLayers, ",") {
+            if name = strings.TrimSpace(name); name != "" {
+                layers = append(layers, name)
+            }
+        }
+        return layers, nil
+    }
+    if !*headOnly {
+        return nil, nil
+    }
+
+    var convs []string
+    for _, layer := range arch.Layers {
+        if layer.Type == model.ConvolutionLayer {
+            convs = append(convs, layer.Name)
+        }
+    }
+    if len(convs) < 2 {
+        return nil, fmt.Errorf("-head-only needs a conv layer before the classifier, the model has %d conv layers", len(convs))
+    }
+    return convs[:len(convs)-1], nil
+}
+
+// reinitHead replaces the weights of the last conv layer by freshly initialized ones
+func (t *trainer) reinitHead() error {
+    fresh, err := train.InitWeights(t.arch, *seed)
+    if err != nil {
+        return err
+    }
+    last := len(fresh.Kernels) - 1
+    if last >= len(t.weights.Kernels) || last >= len(t.weights.Biases) {
+        return fmt.Errorf("the weights hold %d conv layers, the model has %d", len(t.weights.Kernels), last+1)
+    }
+    t.weights.Kernels[last], t.weights.Biases[last] = fresh.Kernels[last], fresh.Biases[last]
+    if last < len(t.weights.BatchNorms) && last < len(fresh.BatchNorms) && fresh.BatchNorms[last] != nil {
+        t.weights.BatchNorms[last] = fresh.BatchNorms[last]
+    }
+    slog.Info("reinitialized the last conv layer", "seed", *seed)
+    return nil
+}
+
+// newOptimizer creates the optimizer of -optimizer
+func newOptimizer() (optimizer, error) {
+    lr := float32(*learningRate)
+    if *optimizerName == "sgd" {
+        if lr == 0 {
+            lr = 0.01
+        }
+        return train.NewSGD(train.SGDOptions{
+            LearningRate: lr,
+            Momentum:     float32(*momentum),
+            WeightDecay:  float32(*weightDecay),
+            Nesterov:     *nesterov,
+        })
+    }
+
+    opts := train.DefaultAdamOptions()
+    if lr != 0 {
+        opts.LearningRate = lr
+    }
+    opts.WeightDecay = float32(*weightDecay)
+    opts.Decoupled = *optimizerName == "adamw"
+    return train.NewAdam(opts)
+}
+
+// loadTrainSet reads the CIFAR-10 batch files of -train
+func loadTrainSet() (*data.CIFAR10Set, error) {
+    files, err := trainFiles()
+    if err != nil {
+        return nil, err
+    }
+    slog.Info("loading training data", "files", len(files))
+    return data.LoadCIFAR10(files...)
+}
+
+// loadData reads the evaluation batch file and sets up the preprocessing of
+// both sets and the shuffled, augmented batches of set
+func (t *trainer) loadData(set data.LabeledSet) error {
+    var err error
+    if *evalPath != "" {
+        if t.evalSet, err = data.LoadCIFAR10(*evalPath); err != nil {
+            return err
+        }
+    }
+
+    // The batch files hold decoded pixels, so the image format is never used
+    if t.plain, err = data.NewPreprocessor(data.BinaryFloat32, t.cfg.Data, t.cfg.Model); err != nil {
+        return err
+    }
+    augmented, err := data.NewPreprocessor(data.BinaryFloat32, t.cfg.Data, t.cfg.Model)
+    if err != nil {
+        return err
+    }
+    t.rng = augment.NewRand(t.seed)
+    steps, err := augment.ParseWithRand(*augmentSpec, t.rng)
+    if err != nil {
+        return err
+    }
+    augmented.SetAugmentation(steps...)
+    if len(steps) > 0 {
+        slog.Info("augmenting training images", "pipeline", augmented.Pipeline())
+    }
+
+    t.loader, err = data.NewTrainingLoader(set, augmented, data.TrainingLoaderOptions{
+        BatchSize: *batchSize,
+        Classes:   data.CIFAR10Classes,
+        Limit:     *limit,
+        Seed:      t.seed,
+    })
+    if err != nil {
+        return err
+    }
+    t.perEpoch = t.loader.StepsPerEpoch()
+    slog.Info("training data loaded", "images", t.loader.Size(), "steps_per_epoch", t.perEpoch)
+    return nil
+}
+
+// step trains on batch step of epoch and updates the weights
+func (t *trainer) step(epoch, step int) (train.BatchResult, error) {
+    images, labels, err := t.loader.Batch(epoch, step)
+    if err != nil {
+        return train.BatchResult{}, err
+    }
+
+    t.grads.Zero()
+    result, err := t.network.TrainBatch(images, labels, t.grads)
+    if err != nil {
+        return result, err
+    }
+    return result, t.opt.Step(t.weights, t.grads)
+}
+
+// evaluate measures the current weights on the evaluation set
+func (t *trainer) evaluate(epoch, step int) error {
+    // A fresh model, so nothing derived from earlier weights is reused
+    cnn, err := model.NewTinyCNNWithWeights(t.arch, t.weights)
+    if err != nil {
+        return err
+    }
+    evaluator := metrics.NewEvaluator(*numWorkers)
+    results, err := evaluator.EvaluateIterator(cnn, data.Preprocessed(t.evalSet.Iterator(*evalSamples), t.plain))
+    if err != nil {
+        return fmt.Errorf("evaluation failed: %w", err)
+    }
+    slog.Info("evaluation", "epoch", epoch+1, "step", step, "accuracy", results.Top1Accuracy,
+        "samples", results.TotalSamples)
+    record("evaluation", epoch+1, step, results.TotalSamples, results.Top1Accuracy)
+    return nil
+}
+
+// checkpoint saves the weights to -output, in the layout every tool reads, and
+// everything the run needs to continue to its CheckpointFileName, as of
+// epochStep steps into the current epoch
+func (t *trainer) checkpoint(epochStep int) error {
+    if err := data.SaveModelWeights(t.output, t.weights); err != nil {
+        return fmt.Errorf("failed to save checkpoint: %w", err)
+    }
+
+    random, err := t.rng.MarshalBinary()
+    if err != nil {
+        return fmt.Errorf("failed to save checkpoint: %w", err)
+    }
+    checkpoint := &train.Checkpoint{
+        Epoch:     t.epoch,
+        EpochStep: epochStep,
+        Seed:      t.seed,
+        Random:    random,
+        Weights:   t.weights,
+        Optimizer: t.opt.State(),
+    }
+    if epochStep == t.perEpoch {
+        checkpoint.Epoch, checkpoint.EpochStep = t.epoch+1, 0
+    }
+    if err := train.SaveCheckpoint(filepath.Join(t.output, train.CheckpointFileName), checkpoint); err != nil {
+        return fmt.Errorf("failed to save checkpoint: %w", err)
+    }
+    slog.Info("checkpoint saved", "epoch", checkpoint.Epoch, "epoch_step", checkpoint.EpochStep,
+        "steps", t.opt.Steps(), "path", t.output)
+    record("checkpoint", checkpoint.Epoch, checkpoint.EpochStep, t.opt.Steps(), t.output)
+    return nil
+}
+
+// accumulate adds result to sum, weighting the loss by the batch size
+func accumulate(sum *train.BatchResult, result train.BatchResult) {
+    sum.Loss += result.Loss * float64(result.Size)
+    sum.Correct += result.Correct
+    sum.Size += result.Size
+}
+
+// accuracy returns the fraction of correct predictions in sum
+func accuracy(sum train.BatchResult) float64 {
+    if sum.Size == 0 {
+        return 0
+    }
+    return float64(sum.Correct) / float64(sum.Size)
+}
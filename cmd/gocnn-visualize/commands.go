@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"duchm1606/gocnn/internal/explain"
+)
+
+// overlayFlags registers the flags of the subcommands drawing a heatmap over the image
+func overlayFlags(fs *flag.FlagSet) *explain.OverlayOptions {
+    opts := &explain.OverlayOptions{}
+    fs.IntVar(&opts.Scale, "scale", 8, "Output pixels per image pixel")
+    fs.Float64Var(&opts.Alpha, "alpha", 0.5, "Weight of the heatmap over the image, in (0, 1]")
+    return opts
+}
+
+// saveOverlay draws heatmap over the image and saves it to -output
+func saveOverlay(l *loaded, heatmap *explain.Heatmap, opts *commonOptions, overlay *explain.OverlayOptions) error {
+    arch := l.cnn.Info().Architecture
+    img, err := explain.Overlay(l.imageData, arch.InputHeight, arch.InputWidth, arch.InputChannels, heatmap, *overlay)
+    if err != nil {
+        return err
+    }
+    return explain.SavePNG(opts.outputPath, img)
+}
+
+// runGradCAM implements the gradcam subcommand
+func runGradCAM(args []string) error {
+    fs, opts := newFlagSet("gradcam", "PNG file to write")
+    addClassFlag(fs, opts)
+    overlay := overlayFlags(fs)
+    layer := fs.String("layer", "", "Conv layer to compute the heatmap on (default: the last conv layer with a ReLU)")
+    if err := parse(fs, opts, args); err != nil {
+        return err
+    }
+
+    l, err := load(opts)
+    if err != nil {
+        return err
+    }
+    heatmap, err := explain.GradCAM(l.cnn, l.imageData, l.class, *layer)
+    if err != nil {
+        return err
+    }
+    if err := saveOverlay(l, heatmap, opts, overlay); err != nil {
+        return err
+    }
+
+    fmt.Printf("Grad-CAM of class %d (%s) on %s, logit %.4f, saved to: %s\n",
+        heatmap.Class, l.className(heatmap.Class), heatmap.Layer, heatmap.Score, opts.outputPath)
+    return nil
+}
+
+// runCAM implements the cam subcommand
+func runCAM(args []string) error {
+    fs, opts := newFlagSet("cam", "PNG file to write")
+    addClassFlag(fs, opts)
+    overlay := overlayFlags(fs)
+    if err := parse(fs, opts, args); err != nil {
+        return err
+    }
+
+    l, err := load(opts)
+    if err != nil {
+        return err
+    }
+    heatmap, err := explain.CAM(l.cnn, l.imageData, l.class)
+    if err != nil {
+        return err
+    }
+    if err := saveOverlay(l, heatmap, opts, overlay); err != nil {
+        return err
+    }
+
+    fmt.Printf("CAM of class %d (%s) on %s, logit %.4f, saved to: %s\n",
+        heatmap.Class, l.className(heatmap.Class), heatmap.Layer, heatmap.Score, opts.outputPath)
+    return nil
+}
+
+// runSaliency implements the saliency subcommand
+func runSaliency(args []string) error {
+    fs, opts := newFlagSet("saliency", "PNG file to write")
+    addClassFlag(fs, opts)
+    overlay := overlayFlags(fs)
+    saliencyOpts := explain.SaliencyOptions{}
+    fs.IntVar(&saliencyOpts.Samples, "samples", 0, "Average over this many noisy copies of the image (SmoothGrad; 0 = plain gradient)")
+    fs.Float64Var(&saliencyOpts.Noise, "noise", 0.15, "SmoothGrad noise standard deviation, relative to the image's value range")
+    fs.Int64Var(&saliencyOpts.Seed, "seed", 0, "Seed of the SmoothGrad noise")
+    if err := parse(fs, opts, args); err != nil {
+        return err
+    }
+    if saliencyOpts.Samples < 0 || saliencyOpts.Noise <= 0 {
+        fmt.Fprintf(os.Stderr, "Error: -samples must not be negative and -noise must be positive\n")
+        return errUsage
+    }
+    if saliencyOpts.Samples > 0 {
+        opts.seeds = map[string]uint64{"smoothgrad": uint64(saliencyOpts.Seed)}
+    }
+
+    l, err := load(opts)
+    if err != nil {
+        return err
+    }
+    saliency, err := explain.Saliency(l.cnn, l.imageData, l.class, saliencyOpts)
+    if err != nil {
+        return err
+    }
+    if err := saveOverlay(l, &saliency.Heatmap, opts, overlay); err != nil {
+        return err
+    }
+
+    method := "gradient"
+    if saliencyOpts.Samples > 0 {
+        method = fmt.Sprintf("SmoothGrad, %d samples", saliencyOpts.Samples)
+    }
+    fmt.Printf("Saliency of class %d (%s), %s, saved to: %s\n",
+        saliency.Class, l.className(saliency.Class), method, opts.outputPath)
+    return nil
+}
+
+// runOcclusion implements the occlusion subcommand
+func runOcclusion(args []string) error {
+    fs, opts := newFlagSet("occlusion", "PNG file to write")
+    addClassFlag(fs, opts)
+    overlay := overlayFlags(fs)
+    occlusionOpts := explain.OcclusionOptions{}
+    fs.IntVar(&occlusionOpts.Patch, "patch", 8, "Side of the masked patch in pixels")
+    fs.IntVar(&occlusionOpts.Stride, "stride", 0, "Step between patches (default: half the patch)")
+    fill := fs.Float64("fill", 0, "Value the patch replaces the (preprocessed) image with")
+    if err := parse(fs, opts, args); err != nil {
+        return err
+    }
+    if occlusionOpts.Patch <= 0 || occlusionOpts.Stride < 0 {
+        fmt.Fprintf(os.Stderr, "Error: -patch must be positive and -stride not negative\n")
+        return errUsage
+    }
+    occlusionOpts.Fill = float32(*fill)
+
+    l, err := load(opts)
+    if err != nil {
+        return err
+    }
+    occlusion, err := explain.Occlusion(context.Background(), l.cnn, l.imageData, l.class, occlusionOpts)
+    if err != nil {
+        return err
+    }
+    if err := saveOverlay(l, &occlusion.Heatmap, opts, overlay); err != nil {
+        return err
+    }
+
+    fmt.Printf("Occlusion of class %d (%s): probability %.4f, largest drop %.4f over %d patches, saved to: %s\n",
+        occlusion.Class, l.className(occlusion.Class), occlusion.Baseline, occlusion.MaxDrop, occlusion.Patches,
+        opts.outputPath)
+    return nil
+}
+
+// runFeatureMaps implements the featuremaps subcommand
+func runFeatureMaps(args []string) error {
+    fs, opts := newFlagSet("featuremaps", "Directory to write one <layer>.png per layer to")
+    tileOpts := explain.FeatureMapOptions{}
+    fs.IntVar(&tileOpts.Scale, "scale", 4, "Output pixels per activation")
+    fs.BoolVar(&tileOpts.PerChannel, "per-channel", false, "Stretch each channel to full contrast instead of the layer's range")
+    layers := fs.String("layers", "", "Comma-separated layers to draw (default: every layer with a spatial output)")
+    if err := parse(fs, opts, args); err != nil {
+        return err
+    }
+    if tileOpts.Scale <= 0 {
+        fmt.Fprintf(os.Stderr, "Error: -scale must be positive\n")
+        return errUsage
+    }
+
+    l, err := load(opts)
+    if err != nil {
+        return err
+    }
+    names, maps, err := explain.FeatureMaps(l.cnn, l.imageData)
+    if err != nil {
+        return err
+    }
+    if *layers != "" {
+        names = nil
+        for _, name := range strings.Split(*layers, ",") {
+            name = strings.TrimSpace(name)
+            if _, ok := maps[name]; !ok {
+                return fmt.Errorf("no layer with a spatial output named %q", name)
+            }
+            names = append(names, name)
+        }
+    }
+
+    if err := os.MkdirAll(opts.outputPath, 0755); err != nil {
+        return fmt.Errorf("failed to create output directory: %w", err)
+    }
+    for _, name := range names {
+        fm := maps[name]
+        path := filepath.Join(opts.outputPath, name+".png")
+        if err := explain.SavePNG(path, explain.FeatureMapTiles(fm, tileOpts)); err != nil {
+            return err
+        }
+        if !opts.quiet {
+            fmt.Printf("  %-16s %dx%dx%d -> %s\n", name, fm.Height, fm.Width, fm.Channels, path)
+        }
+    }
+    fmt.Printf("Feature maps of %d layers saved to: %s\n", len(names), opts.outputPath)
+    return nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/runinfo"
+)
+
+// Version information
+const (
+    AppName    = "gocnn-visualize"
+    AppVersion = "1.0.0"
+    AppDesc    = "Grad-CAM, CAM, saliency, occlusion and feature map images for TinyCNN"
+)
+
+// command is one subcommand: its flags are parsed from args, after the subcommand name
+type command struct {
+    name    string
+    summary string
+    run     func(args []string) error
+}
+
+var commands = []command{
+    {"gradcam", "Grad-CAM heatmap of a class over the image", runGradCAM},
+    {"cam", "Class activation map from a global-average-pooling head, without gradients", runCAM},
+    {"saliency", "Input-gradient (or SmoothGrad) saliency map of a class over the image", runSaliency},
+    {"occlusion", "Confidence drop when patches of the image are masked, over the image", runOcclusion},
+    {"featuremaps", "Every channel of every layer's output as grayscale tiles, one PNG per layer", runFeatureMaps},
+}
+
+func main() {
+    if len(os.Args) < 2 {
+        printHelp()
+        os.Exit(1)
+    }
+
+    switch name := os.Args[1]; name {
+    case "-version", "--version", "version":
+        printVersion()
+        return
+    case "-help", "--help", "-h", "help":
+        printHelp()
+        return
+    default:
+        for _, cmd := range commands {
+            if cmd.name != name {
+                continue
+            }
+            err := cmd.run(os.Args[2:])
+            if errors.Is(err, flag.ErrHelp) {
+                return
+            }
+            if errors.Is(err, errUsage) {
+                os.Exit(1)
+            }
+            if err != nil {
+                errs.Fprint(os.Stderr, name+" failed", err)
+                os.Exit(1)
+            }
+            return
+        }
+        fmt.Fprintf(os.Stderr, "Error: unknown command %q\n", name)
+        fmt.Fprintf(os.Stderr, "Use -help for usage information\n")
+        os.Exit(1)
+    }
+}
+
+// errUsage reports invalid arguments, after they have been explained on stderr
+var errUsage = errors.New("invalid arguments")
+
+// commonOptions are the flags every subcommand takes
+type commonOptions struct {
+    weightsPath string
+    configPath  string
+    imageFormat string
+    outputPath  string
+    class       string
+    runManifest string
+    quiet       bool
+    imagePath   string            // The positional argument
+    seeds       map[string]uint64 // Seeds the subcommand draws random numbers from, by purpose
+}
+
+// newFlagSet returns the flag set of the named subcommand with the common flags
+// registered; output describes what -output names
+func newFlagSet(name, output string) (*flag.FlagSet, *commonOptions) {
+    fs := flag.NewFlagSet(name, flag.ContinueOnError)
+    opts := &commonOptions{}
+    fs.StringVar(&opts.weightsPath, "weights", "", "Path to model weights directory (required)")
+    fs.StringVar(&opts.configPath, "config", "configs/cifar10.yaml", "Path to model configuration file")
+    fs.StringVar(&opts.imageFormat, "image-format", "float32", "Binary image encoding: float32 (values in [0, 1]) or uint8 (0-255)")
+    fs.StringVar(&opts.outputPath, "output", "", output+" (required)")
+    fs.StringVar(&opts.runManifest, "run-manifest", "", "Write a run.json with version, commit, config/weights hashes, engine and host")
+    fs.BoolVar(&opts.quiet, "quiet", false, "Suppress non-essential output")
+    fs.Usage = func() {
+        fmt.Fprintf(fs.Output(), "USAGE:\n  %s %s [options] <image>\n\nOPTIONS:\n", AppName, name)
+        fs.PrintDefaults()
+    }
+    return fs, opts
+}
+
+// addClassFlag registers -class on a subcommand that explains one class
+func addClassFlag(fs *flag.FlagSet, opts *commonOptions) {
+    fs.StringVar(&opts.class, "class", "", "Class to explain, index or name (default: the predicted class)")
+}
+
+// parse parses args into fs and checks the common options
+// Errors have been printed with the usage; return them as they are.
+func parse(fs *flag.FlagSet, opts *commonOptions, args []string) error {
+    if err := fs.Parse(args); err != nil {
+        if errors.Is(err, flag.ErrHelp) {
+            return err
+        }
+        return errUsage
+    }
+
+    var problem string
+    switch {
+    case fs.NArg() != 1:
+        problem = fmt.Sprintf("expected one image argument after the options, got %d", fs.NArg())
+    case opts.weightsPath == "":
+        problem = "weights path is required (use -weights)"
+    case opts.outputPath == "":
+        problem = "output path is required (use -output)"
+    }
+    if problem == "" {
+        opts.imagePath = fs.Arg(0)
+        paths := map[string]string{
+            "weights directory": opts.weightsPath,
+            "config file":       opts.configPath,
+            "image":             opts.imagePath,
+        }
+        for desc, path := range paths {
+            if _, err := os.Stat(path); os.IsNotExist(err) {
+                problem = fmt.Sprintf("%s does not exist: %s", desc, path)
+            }
+        }
+    }
+    if problem == "" {
+        if _, err := data.ParseImageFormat(opts.imageFormat); err != nil {
+            problem = fmt.Sprintf("-image-format: %v", err)
+        }
+    }
+
+    if problem != "" {
+        fmt.Fprintf(os.Stderr, "Error: %s\n", problem)
+        fmt.Fprintf(os.Stderr, "Use %s %s -help for usage information\n", AppName, fs.Name())
+        return errUsage
+    }
+    return nil
+}
+
+// loaded is what every subcommand works on
+type loaded struct {
+    cfg       *config.Config
+    cnn       *model.TinyCNN
+    imageData []float32 // Preprocessed, in CHW order
+    class     int       // -class, or -1 for the predicted class
+}
+
+// load reads the configuration, the model and the image of opts
+func load(opts *commonOptions) (*loaded, error) {
+    cfg, err := config.Load(opts.configPath)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load configuration: %w", err)
+    }
+
+    class := -1
+    if opts.class != "" {
+        class, err = resolveClass(opts.class, cfg.Model.ClassNames)
+        if err != nil {
+            return nil, fmt.Errorf("-class: %w", err)
+        }
+    }
+
+    if !opts.quiet {
+        fmt.Printf("Loading model from %s...\n", opts.weightsPath)
+    }
+    cnn, err := model.NewTinyCNNFromConfig(opts.weightsPath, cfg.Model)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load model: %w", err)
+    }
+    if opts.runManifest != "" {
+        if err := writeRunManifest(opts, cnn); err != nil {
+            return nil, err
+        }
+    }
+
+    format, err := data.ParseImageFormat(opts.imageFormat)
+    if err != nil {
+        return nil, err
+    }
+    preprocessor, err := data.NewPreprocessor(format, cfg.Data, cfg.Model)
+    if err != nil {
+        return nil, err
+    }
+    fm, err := preprocessor.Load(opts.imagePath)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load image: %w", err)
+    }
+
+    return &loaded{cfg: cfg, cnn: cnn, imageData: fm.Data, class: class}, nil
+}
+
+// writeRunManifest saves the -run-manifest of a run explaining with cnn
+func writeRunManifest(opts *commonOptions, cnn *model.TinyCNN) error {
+    run := runinfo.New(AppName, AppVersion)
+    if err := run.SetConfig(opts.configPath); err != nil {
+        return err
+    }
+    if err := run.SetWeights(opts.weightsPath); err != nil {
+        return err
+    }
+    run.SetModel(cnn.Info())
+    for purpose, seed := range opts.seeds {
+        run.SetSeed(purpose, seed)
+    }
+    return run.Write(opts.runManifest)
+}
+
+// className returns the configured name of class, or its index if it has none
+func (l *loaded) className(class int) string {
+    if class >= 0 && class < len(l.cfg.Model.ClassNames) {
+        return l.cfg.Model.ClassNames[class]
+    }
+    return strconv.Itoa(class)
+}
+
+// resolveClass converts a class index or name, ignoring case, to the class index
+func resolveClass(class string, classNames []string) (int, error) {
+    if index, err := strconv.Atoi(class); err == nil {
+        if index < 0 || index >= len(classNames) {
+            return 0, fmt.Errorf("class %d out of range [0, %d)", index, len(classNames))
+        }
+        return index, nil
+    }
+    for i, name := range classNames {
+        if strings.EqualFold(name, class) {
+            return i, nil
+        }
+    }
+    return 0, errs.WithHint(fmt.Errorf("unknown class %q", class), "classes are %s", strings.Join(classNames, ", "))
+}
+
+// printVersion displays version information
+func printVersion() {
+    fmt.Printf("%s version %s\n", AppName, AppVersion)
+    fmt.Printf("%s\n", AppDesc)
+}
+
+// printHelp displays detailed help information
+func printHelp() {
+    fmt.Printf("%s - %s\n\n", AppName, AppDesc)
+
+    fmt.Println("USAGE:")
+    fmt.Printf("  %s <command> [options] <image>\n\n", AppName)
+
+    fmt.Println("COMMANDS:")
+    for _, cmd := range commands {
+        fmt.Printf("  %-12s %s\n", cmd.name, cmd.summary)
+    }
+
+    fmt.Println("\nCOMMON OPTIONS:")
+    fmt.Println("  -weights <path>    Path to directory containing model weights (required)")
+    fmt.Println("  -output <path>     PNG file to write; a directory for featuremaps (required)")
+    fmt.Println("  -config <path>     Path to model configuration file (default: configs/cifar10.yaml)")
+    fmt.Println("  -image-format <f>  Binary image encoding: float32 (default) or uint8")
+    fmt.Println("  -class <c>         Class to explain, index or name (default: the predicted class)")
+    fmt.Println("  -run-manifest <file> Write version, commit, config/weights hashes, engine, host and the")
+    fmt.Println("                     SmoothGrad seed to <file>")
+    fmt.Println("  -quiet             Suppress non-essential output")
+    fmt.Printf("  Run %s <command> -help for the options of a command.\n", AppName)
+
+    fmt.Println("\nEXAMPLES:")
+    fmt.Printf("  # Where the model looks for the class it predicts\n")
+    fmt.Printf("  %s gradcam -weights ./weights -output cam.png ./test.bin\n\n", AppName)
+
+    fmt.Printf("  # Classic CAM, for a config whose classifier is followed by global_average_pooling\n")
+    fmt.Printf("  %s cam -weights ./weights-gap -config ./configs/gap.yaml -output cam.png ./test.bin\n\n", AppName)
+
+    fmt.Printf("  # Why not airplane? The pixels the airplane score depends on, averaged over 32 noisy copies\n")
+    fmt.Printf("  %s saliency -weights ./weights -output why.png -class airplane -samples 32 ./test.bin\n\n", AppName)
+
+    fmt.Printf("  # Regions the prediction depends on, with forward passes only\n")
+    fmt.Printf("  %s occlusion -weights ./weights -output occlusion.png -patch 6 ./test.bin\n\n", AppName)
+
+    fmt.Printf("  # What conv1 and conv2 respond to, each channel at full contrast\n")
+    fmt.Printf("  %s featuremaps -weights ./weights -output ./maps -layers conv1,conv2 -per-channel ./test.bin\n", AppName)
+}
@@ -0,0 +1,161 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/cmplx"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// encodeWAV16 builds a 16-bit PCM WAV stream
+func encodeWAV16(t *testing.T, samples []float32, sampleRate, channels int) []byte {
+    var buf bytes.Buffer
+    dataSize := len(samples) * 2
+    
+    buf.WriteString("RIFF")
+    binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+    buf.WriteString("WAVE")
+    
+    buf.WriteString("fmt ")
+    binary.Write(&buf, binary.LittleEndian, uint32(16))
+    binary.Write(&buf, binary.LittleEndian, uint16(1))
+    binary.Write(&buf, binary.LittleEndian, uint16(channels))
+    binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+    binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*channels*2))
+    binary.Write(&buf, binary.LittleEndian, uint16(channels*2))
+    binary.Write(&buf, binary.LittleEndian, uint16(16))
+    
+    buf.WriteString("data")
+    binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+    for _, s := range samples {
+        binary.Write(&buf, binary.LittleEndian, int16(s*32767))
+    }
+    
+    return buf.Bytes()
+}
+
+func sineWave(freq float64, sampleRate, numSamples int) []float32 {
+    samples := make([]float32, numSamples)
+    for i := range samples {
+        samples[i] = float32(0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+    }
+    return samples
+}
+
+func TestDecodeWAV(t *testing.T) {
+    // Stereo: left = 0.5, right = -0.5 averages to silence
+    interleaved := []float32{0.5, -0.5, 0.5, -0.5, 0.25, 0.25}
+    wav, err := DecodeWAV(bytes.NewReader(encodeWAV16(t, interleaved, 8000, 2)))
+    if err != nil {
+        t.Fatalf("DecodeWAV failed: %v", err)
+    }
+    
+    if wav.SampleRate != 8000 || wav.Channels != 2 {
+        t.Errorf("Expected 8000 Hz stereo, got %d Hz, %d channels", wav.SampleRate, wav.Channels)
+    }
+    
+    if len(wav.Samples) != 3 {
+        t.Fatalf("Expected 3 mono samples, got %d", len(wav.Samples))
+    }
+    
+    if math.Abs(float64(wav.Samples[0])) > 1e-3 || math.Abs(float64(wav.Samples[2]-0.25)) > 1e-3 {
+        t.Errorf("Unexpected downmix: %v", wav.Samples)
+    }
+}
+
+func TestDecodeWAVRejectsGarbage(t *testing.T) {
+    if _, err := DecodeWAV(bytes.NewReader([]byte("not a wav file at all"))); err == nil {
+        t.Error("Expected error for non-WAV input")
+    }
+}
+
+func TestFFTMatchesDFT(t *testing.T) {
+    n := 16
+    x := make([]complex128, n)
+    for i := range x {
+        x[i] = complex(math.Sin(float64(i))+0.3*float64(i%3), 0)
+    }
+    
+    // Naive DFT for reference
+    expected := make([]complex128, n)
+    for k := 0; k < n; k++ {
+        for i := 0; i < n; i++ {
+            expected[k] += x[i] * cmplx.Exp(complex(0, -2*math.Pi*float64(k*i)/float64(n)))
+        }
+    }
+    
+    fft(x)
+    for k := range x {
+        if cmplx.Abs(x[k]-expected[k]) > 1e-9 {
+            t.Errorf("Bin %d: expected %v, got %v", k, expected[k], x[k])
+        }
+    }
+}
+
+func TestFrontendSineWavePeak(t *testing.T) {
+    cfg := DefaultSpectrogramConfig()
+    frontend, err := NewFrontend(cfg)
+    if err != nil {
+        t.Fatalf("NewFrontend failed: %v", err)
+    }
+    
+    // Write a 1 kHz tone to disk and run it through the full path
+    path := filepath.Join(t.TempDir(), "tone.wav")
+    tone := sineWave(1000, cfg.SampleRate, cfg.SampleRate)
+    if err := os.WriteFile(path, encodeWAV16(t, tone, cfg.SampleRate, 1), 0644); err != nil {
+        t.Fatalf("Failed to write WAV: %v", err)
+    }
+    
+    fm, err := frontend.LoadFeatureMap(path)
+    if err != nil {
+        t.Fatalf("LoadFeatureMap failed: %v", err)
+    }
+    
+    if fm.Height != cfg.NumMelBins || fm.Width != cfg.NumFrames || fm.Channels != 1 {
+        t.Fatalf("Expected shape (%d,%d,1), got (%d,%d,%d)", 
+            cfg.NumMelBins, cfg.NumFrames, fm.Height, fm.Width, fm.Channels)
+    }
+    
+    // The loudest mel bin in a middle frame should be the one covering 1 kHz
+    frame := cfg.NumFrames / 2
+    peak := 0
+    for m := 1; m < fm.Height; m++ {
+        if fm.Get(0, m, frame) > fm.Get(0, peak, frame) {
+            peak = m
+        }
+    }
+    
+    expectedMel := hzToMel(1000)
+    minMel, maxMel := hzToMel(cfg.MinFreq), hzToMel(float64(cfg.SampleRate)/2)
+    expectedBin := int(math.Round((expectedMel-minMel)/(maxMel-minMel)*float64(cfg.NumMelBins+1))) - 1
+    if peak < expectedBin-1 || peak > expectedBin+1 {
+        t.Errorf("Expected peak near mel bin %d, got %d", expectedBin, peak)
+    }
+}
+
+func TestFrontendPadsShortClips(t *testing.T) {
+    cfg := DefaultSpectrogramConfig()
+    frontend, err := NewFrontend(cfg)
+    if err != nil {
+        t.Fatalf("NewFrontend failed: %v", err)
+    }
+    
+    // 0.1 s clip at a different rate is resampled and padded to NumFrames
+    wav := &WAV{SampleRate: 8000, Channels: 1, Samples: sineWave(440, 8000, 800)}
+    seq := frontend.FeatureMap1D(wav)
+    
+    if seq.Length != cfg.NumFrames || seq.Channels != cfg.NumMelBins {
+        t.Errorf("Expected shape (%d,%d), got (%d,%d)", cfg.NumFrames, cfg.NumMelBins, seq.Length, seq.Channels)
+    }
+}
+
+func TestSpectrogramConfigValidate(t *testing.T) {
+    cfg := DefaultSpectrogramConfig()
+    cfg.FFTSize = 300
+    if err := cfg.Validate(); err == nil {
+        t.Error("Expected error for non power-of-two FFT size")
+    }
+}
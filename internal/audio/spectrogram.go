@@ -0,0 +1,291 @@
+package audio
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+/**
+* Log-mel spectrogram
+
+1. Slice the waveform into overlapping frames (FrameLength samples, HopLength apart)
+2. Multiply each frame by a Hann window and take the FFT magnitude squared (power spectrum)
+3. Project the power spectrum onto NumMelBins triangular filters spaced evenly on the mel scale
+   mel(f) = 2595 * log10(1 + f/700)
+4. Take log(energy + epsilon) to compress the dynamic range
+
+The result is an image-like [mel bin][frame] grid that the 2D conv path can consume
+directly, or the 1D path can treat as a sequence with one channel per mel bin.
+*/
+
+// SpectrogramConfig holds configuration for log-mel spectrogram extraction
+type SpectrogramConfig struct {
+    SampleRate  int     // Expected sample rate; input is resampled if it differs
+    FrameLength int     // Samples per analysis window
+    HopLength   int     // Samples between successive frames
+    FFTSize     int     // FFT size (power of two, >= FrameLength)
+    NumMelBins  int     // Number of mel filters (output height)
+    NumFrames   int     // Output width; frames are zero-padded or cropped (0 = keep all)
+    MinFreq     float64 // Lowest filter edge in Hz
+    MaxFreq     float64 // Highest filter edge in Hz (0 = Nyquist)
+    LogEpsilon  float32 // Added before taking log to avoid log(0)
+}
+
+// DefaultSpectrogramConfig returns settings common for small audio-event classifiers:
+// 16 kHz audio, 25 ms windows, 10 ms hop, 40 mel bins, ~1 s of context
+func DefaultSpectrogramConfig() SpectrogramConfig {
+    return SpectrogramConfig{
+        SampleRate:  16000,
+        FrameLength: 400,
+        HopLength:   160,
+        FFTSize:     512,
+        NumMelBins:  40,
+        NumFrames:   98,
+        MinFreq:     20,
+        MaxFreq:     0,
+        LogEpsilon:  1e-6,
+    }
+}
+
+// Validate checks if the spectrogram configuration is usable
+func (cfg SpectrogramConfig) Validate() error {
+    if cfg.SampleRate <= 0 {
+        return fmt.Errorf("sample rate must be positive, got %d", cfg.SampleRate)
+    }
+    if cfg.FrameLength <= 0 || cfg.HopLength <= 0 {
+        return fmt.Errorf("frame length and hop length must be positive, got %d and %d", 
+            cfg.FrameLength, cfg.HopLength)
+    }
+    if cfg.FFTSize < cfg.FrameLength || cfg.FFTSize&(cfg.FFTSize-1) != 0 {
+        return fmt.Errorf("FFT size must be a power of two >= frame length, got %d", cfg.FFTSize)
+    }
+    if cfg.NumMelBins <= 0 {
+        return fmt.Errorf("number of mel bins must be positive, got %d", cfg.NumMelBins)
+    }
+    if cfg.NumFrames < 0 {
+        return fmt.Errorf("number of frames must be non-negative, got %d", cfg.NumFrames)
+    }
+    if cfg.maxFreq() <= cfg.MinFreq {
+        return fmt.Errorf("invalid frequency range: [%.1f, %.1f] Hz", cfg.MinFreq, cfg.maxFreq())
+    }
+    return nil
+}
+
+// maxFreq returns the upper filter edge, defaulting to the Nyquist frequency
+func (cfg SpectrogramConfig) maxFreq() float64 {
+    nyquist := float64(cfg.SampleRate) / 2
+    if cfg.MaxFreq <= 0 || cfg.MaxFreq > nyquist {
+        return nyquist
+    }
+    return cfg.MaxFreq
+}
+
+// Frontend converts audio into model-ready log-mel feature maps
+// The mel filterbank and window are computed once and reused across clips
+type Frontend struct {
+    config     SpectrogramConfig
+    window     []float64
+    filterbank [][]float64 // [mel bin][FFT bin]
+}
+
+// NewFrontend creates a new audio frontend
+func NewFrontend(cfg SpectrogramConfig) (*Frontend, error) {
+    if err := cfg.Validate(); err != nil {
+        return nil, fmt.Errorf("invalid spectrogram config: %w", err)
+    }
+    
+    return &Frontend{
+        config:     cfg,
+        window:     hannWindow(cfg.FrameLength),
+        filterbank: melFilterbank(cfg.NumMelBins, cfg.FFTSize, cfg.SampleRate, cfg.MinFreq, cfg.maxFreq()),
+    }, nil
+}
+
+// Config returns the frontend configuration
+func (fe *Frontend) Config() SpectrogramConfig {
+    return fe.config
+}
+
+// LoadFeatureMap reads a WAV file and returns its log-mel spectrogram
+func (fe *Frontend) LoadFeatureMap(filename string) (*tensor.FeatureMap, error) {
+    wav, err := LoadWAV(filename)
+    if err != nil {
+        return nil, err
+    }
+    return fe.FeatureMap(wav), nil
+}
+
+// FeatureMap computes the log-mel spectrogram of a clip
+// Output shape: Height = NumMelBins, Width = frames, Channels = 1
+func (fe *Frontend) FeatureMap(wav *WAV) *tensor.FeatureMap {
+    samples := Resample(wav.Samples, wav.SampleRate, fe.config.SampleRate)
+    melFrames := fe.melFrames(samples)
+    
+    fm := tensor.NewFeatureMap(fe.config.NumMelBins, len(melFrames), 1)
+    for t, frame := range melFrames {
+        for m, energy := range frame {
+            fm.SetUnsafe(0, m, t, energy)
+        }
+    }
+    
+    return fm
+}
+
+// FeatureMap1D computes the log-mel spectrogram as a sequence for 1D convolution
+// Output shape: Length = frames, Channels = NumMelBins
+func (fe *Frontend) FeatureMap1D(wav *WAV) *tensor.FeatureMap1D {
+    samples := Resample(wav.Samples, wav.SampleRate, fe.config.SampleRate)
+    melFrames := fe.melFrames(samples)
+    
+    fm := tensor.NewFeatureMap1D(len(melFrames), fe.config.NumMelBins)
+    for t, frame := range melFrames {
+        for m, energy := range frame {
+            fm.SetUnsafe(m, t, energy)
+        }
+    }
+    
+    return fm
+}
+
+// melFrames computes per-frame log-mel energies, padded or cropped to NumFrames
+func (fe *Frontend) melFrames(samples []float32) [][]float32 {
+    cfg := fe.config
+    
+    numFrames := 1
+    if len(samples) > cfg.FrameLength {
+        numFrames = 1 + (len(samples)-cfg.FrameLength)/cfg.HopLength
+    }
+    if cfg.NumFrames > 0 {
+        numFrames = cfg.NumFrames
+    }
+    
+    frames := make([][]float32, numFrames)
+    buffer := make([]complex128, cfg.FFTSize)
+    power := make([]float64, cfg.FFTSize/2+1)
+    logFloor := float32(math.Log(float64(cfg.LogEpsilon)))
+    
+    for t := 0; t < numFrames; t++ {
+        frames[t] = make([]float32, cfg.NumMelBins)
+        start := t * cfg.HopLength
+        
+        // Frames past the end of the clip are silence
+        if start >= len(samples) {
+            for m := range frames[t] {
+                frames[t][m] = logFloor
+            }
+            continue
+        }
+        
+        // Windowed frame, zero-padded to the FFT size
+        for i := range buffer {
+            buffer[i] = 0
+        }
+        for i := 0; i < cfg.FrameLength && start+i < len(samples); i++ {
+            buffer[i] = complex(float64(samples[start+i])*fe.window[i], 0)
+        }
+        
+        fft(buffer)
+        for k := range power {
+            mag := cmplx.Abs(buffer[k])
+            power[k] = mag * mag
+        }
+        
+        for m, filter := range fe.filterbank {
+            var energy float64
+            for k, weight := range filter {
+                energy += weight * power[k]
+            }
+            frames[t][m] = float32(math.Log(energy + float64(cfg.LogEpsilon)))
+        }
+    }
+    
+    return frames
+}
+
+// hannWindow returns a periodic Hann window of the given length
+func hannWindow(length int) []float64 {
+    window := make([]float64, length)
+    for i := range window {
+        window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(length))
+    }
+    return window
+}
+
+// hzToMel converts a frequency in Hz to the mel scale
+func hzToMel(hz float64) float64 {
+    return 2595 * math.Log10(1+hz/700)
+}
+
+// melToHz converts a mel value back to Hz
+func melToHz(mel float64) float64 {
+    return 700 * (math.Pow(10, mel/2595) - 1)
+}
+
+// melFilterbank builds triangular filters evenly spaced on the mel scale
+func melFilterbank(numBins, fftSize, sampleRate int, minFreq, maxFreq float64) [][]float64 {
+    numFFTBins := fftSize/2 + 1
+    
+    // numBins+2 edges: each filter spans [edge[m], edge[m+2]] and peaks at edge[m+1]
+    minMel := hzToMel(minFreq)
+    maxMel := hzToMel(maxFreq)
+    edges := make([]float64, numBins+2)
+    for i := range edges {
+        mel := minMel + (maxMel-minMel)*float64(i)/float64(numBins+1)
+        edges[i] = melToHz(mel)
+    }
+    
+    binHz := float64(sampleRate) / float64(fftSize)
+    filters := make([][]float64, numBins)
+    
+    for m := 0; m < numBins; m++ {
+        filters[m] = make([]float64, numFFTBins)
+        left, center, right := edges[m], edges[m+1], edges[m+2]
+        
+        for k := 0; k < numFFTBins; k++ {
+            freq := float64(k) * binHz
+            switch {
+            case freq > left && freq <= center:
+                filters[m][k] = (freq - left) / (center - left)
+            case freq > center && freq < right:
+                filters[m][k] = (right - freq) / (right - center)
+            }
+        }
+    }
+    
+    return filters
+}
+
+// fft computes an in-place iterative radix-2 Cooley-Tukey FFT
+// len(x) must be a power of two
+func fft(x []complex128) {
+    n := len(x)
+    
+    // Bit-reversal permutation
+    for i, j := 1, 0; i < n; i++ {
+        bit := n >> 1
+        for ; j&bit != 0; bit >>= 1 {
+            j ^= bit
+        }
+        j ^= bit
+        if i < j {
+            x[i], x[j] = x[j], x[i]
+        }
+    }
+    
+    // Butterflies
+    for size := 2; size <= n; size <<= 1 {
+        step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+        for start := 0; start < n; start += size {
+            w := complex(1, 0)
+            for k := 0; k < size/2; k++ {
+                even := x[start+k]
+                odd := x[start+k+size/2] * w
+                x[start+k] = even + odd
+                x[start+k+size/2] = even - odd
+                w *= step
+            }
+        }
+    }
+}
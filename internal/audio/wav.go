@@ -0,0 +1,206 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// WAV holds decoded audio as mono float32 samples in [-1, 1]
+type WAV struct {
+    SampleRate int       // Samples per second
+    Channels   int       // Channel count in the source file (samples are downmixed)
+    Samples    []float32 // Mono samples
+}
+
+// Duration returns the clip length in seconds
+func (w *WAV) Duration() float64 {
+    if w.SampleRate == 0 {
+        return 0
+    }
+    return float64(len(w.Samples)) / float64(w.SampleRate)
+}
+
+// WAVE format codes
+const (
+    wavFormatPCM   = 1
+    wavFormatFloat = 3
+)
+
+// LoadWAV reads and decodes a WAV file
+func LoadWAV(filename string) (*WAV, error) {
+    file, err := os.Open(filename)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open WAV file %s: %w", filename, err)
+    }
+    defer file.Close()
+    
+    wav, err := DecodeWAV(file)
+    if err != nil {
+        return nil, fmt.Errorf("failed to decode WAV file %s: %w", filename, err)
+    }
+    
+    return wav, nil
+}
+
+// DecodeWAV decodes a RIFF/WAVE stream
+// Supports integer PCM (8, 16, 24, 32 bit) and 32-bit IEEE float; multi-channel audio is averaged to mono
+func DecodeWAV(r io.Reader) (*WAV, error) {
+    var riffHeader [12]byte
+    if _, err := io.ReadFull(r, riffHeader[:]); err != nil {
+        return nil, fmt.Errorf("failed to read RIFF header: %w", err)
+    }
+    
+    if string(riffHeader[0:4]) != "RIFF" || string(riffHeader[8:12]) != "WAVE" {
+        return nil, fmt.Errorf("not a RIFF/WAVE stream")
+    }
+    
+    var (
+        format        uint16
+        channels      int
+        sampleRate    int
+        bitsPerSample int
+        haveFormat    bool
+    )
+    
+    // Walk chunks until the data chunk is found
+    for {
+        var chunkHeader [8]byte
+        if _, err := io.ReadFull(r, chunkHeader[:]); err != nil {
+            return nil, fmt.Errorf("missing data chunk: %w", err)
+        }
+        
+        chunkID := string(chunkHeader[0:4])
+        chunkSize := int64(binary.LittleEndian.Uint32(chunkHeader[4:8]))
+        
+        switch chunkID {
+        case "fmt ":
+            if chunkSize < 16 {
+                return nil, fmt.Errorf("fmt chunk too small: %d bytes", chunkSize)
+            }
+            buf := make([]byte, chunkSize)
+            if _, err := io.ReadFull(r, buf); err != nil {
+                return nil, fmt.Errorf("failed to read fmt chunk: %w", err)
+            }
+            
+            format = binary.LittleEndian.Uint16(buf[0:2])
+            channels = int(binary.LittleEndian.Uint16(buf[2:4]))
+            sampleRate = int(binary.LittleEndian.Uint32(buf[4:8]))
+            bitsPerSample = int(binary.LittleEndian.Uint16(buf[14:16]))
+            
+            // WAVE_FORMAT_EXTENSIBLE stores the real format code in the sub-format GUID
+            if format == 0xFFFE && chunkSize >= 26 {
+                format = binary.LittleEndian.Uint16(buf[24:26])
+            }
+            haveFormat = true
+            
+        case "data":
+            if !haveFormat {
+                return nil, fmt.Errorf("data chunk precedes fmt chunk")
+            }
+            buf := make([]byte, chunkSize)
+            if _, err := io.ReadFull(r, buf); err != nil {
+                return nil, fmt.Errorf("failed to read data chunk: %w", err)
+            }
+            
+            samples, err := decodeSamples(buf, format, channels, bitsPerSample)
+            if err != nil {
+                return nil, err
+            }
+            
+            return &WAV{
+                SampleRate: sampleRate,
+                Channels:   channels,
+                Samples:    samples,
+            }, nil
+            
+        default:
+            // Skip unknown chunks (LIST, fact, ...); chunks are padded to even sizes
+            skip := chunkSize + chunkSize%2
+            if _, err := io.CopyN(io.Discard, r, skip); err != nil {
+                return nil, fmt.Errorf("failed to skip %q chunk: %w", chunkID, err)
+            }
+        }
+    }
+}
+
+// decodeSamples converts interleaved raw sample bytes to mono float32
+func decodeSamples(buf []byte, format uint16, channels, bitsPerSample int) ([]float32, error) {
+    if channels <= 0 {
+        return nil, fmt.Errorf("invalid channel count: %d", channels)
+    }
+    
+    bytesPerSample := bitsPerSample / 8
+    switch {
+    case format == wavFormatPCM && (bitsPerSample == 8 || bitsPerSample == 16 || bitsPerSample == 24 || bitsPerSample == 32):
+    case format == wavFormatFloat && bitsPerSample == 32:
+    default:
+        return nil, fmt.Errorf("unsupported WAV encoding: format=%d, bits=%d", format, bitsPerSample)
+    }
+    
+    frameSize := bytesPerSample * channels
+    numFrames := len(buf) / frameSize
+    samples := make([]float32, numFrames)
+    
+    for i := 0; i < numFrames; i++ {
+        var sum float32
+        for c := 0; c < channels; c++ {
+            offset := i*frameSize + c*bytesPerSample
+            sum += decodeSample(buf[offset:offset+bytesPerSample], format, bitsPerSample)
+        }
+        samples[i] = sum / float32(channels)
+    }
+    
+    return samples, nil
+}
+
+// decodeSample converts one little-endian sample to float32 in [-1, 1]
+func decodeSample(b []byte, format uint16, bitsPerSample int) float32 {
+    if format == wavFormatFloat {
+        return math.Float32frombits(binary.LittleEndian.Uint32(b))
+    }
+    
+    switch bitsPerSample {
+    case 8:
+        // 8-bit PCM is unsigned with a 128 offset
+        return (float32(b[0]) - 128) / 128
+    case 16:
+        return float32(int16(binary.LittleEndian.Uint16(b))) / 32768
+    case 24:
+        v := int32(b[0]) | int32(b[1])<<8 | int32(b[2])<<16
+        if v&0x800000 != 0 {
+            v -= 1 << 24
+        }
+        return float32(v) / 8388608
+    default:
+        return float32(int32(binary.LittleEndian.Uint32(b))) / 2147483648
+    }
+}
+
+// Resample converts samples to a new rate using linear interpolation
+// Good enough for feeding small classifiers; not a band-limited resampler
+func Resample(samples []float32, fromRate, toRate int) []float32 {
+    if fromRate == toRate || fromRate <= 0 || toRate <= 0 || len(samples) == 0 {
+        return samples
+    }
+    
+    outLength := int(int64(len(samples)) * int64(toRate) / int64(fromRate))
+    result := make([]float32, outLength)
+    ratio := float64(fromRate) / float64(toRate)
+    
+    for i := range result {
+        pos := float64(i) * ratio
+        idx := int(pos)
+        frac := float32(pos - float64(idx))
+        
+        if idx+1 < len(samples) {
+            result[i] = samples[idx]*(1-frac) + samples[idx+1]*frac
+        } else {
+            result[i] = samples[len(samples)-1]
+        }
+    }
+    
+    return result
+}
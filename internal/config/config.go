@@ -1,9 +1,13 @@
 package config
 
 import (
+	"duchm1606/gocnn/internal/quant"
+	"duchm1606/gocnn/internal/tensor"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,19 +19,24 @@ type Config struct {
     Data      DataConfig      `yaml:"data"`
     Inference InferenceConfig `yaml:"inference"`
     Benchmark BenchmarkConfig `yaml:"benchmark"`
+    Serving   ServingConfig   `yaml:"serving,omitempty"`
 }
 
 // ModelConfig defines model-specific settings
 type ModelConfig struct {
-    Name          string        `yaml:"name"`
-    Architecture  string        `yaml:"architecture"`
-    WeightsPath   string        `yaml:"weights_path"`
-    InputHeight   int           `yaml:"input_height"`
-    InputWidth    int           `yaml:"input_width"`
-    InputChannels int           `yaml:"input_channels"`
-    NumClasses    int           `yaml:"num_classes"`
-    ClassNames    []string      `yaml:"class_names"`
-    Layers        []LayerConfig `yaml:"layers"`
+    Name                   string        `yaml:"name"`
+    Architecture           string        `yaml:"architecture"`
+    WeightsPath            string        `yaml:"weights_path"`
+    InputHeight            int           `yaml:"input_height"`
+    InputWidth             int           `yaml:"input_width"`
+    InputChannels          int           `yaml:"input_channels"`
+    InputDepth             int           `yaml:"input_depth,omitempty"` // frames of a 3D input, for convolution_3d layers
+    NumClasses             int           `yaml:"num_classes"`
+    ClassNames             []string      `yaml:"class_names"`
+    Layers                 []LayerConfig `yaml:"layers"`
+    Precision              string        `yaml:"precision,omitempty"` // float32 (default), float16 or float64
+    WeightQuantization     string        `yaml:"weight_quantization,omitempty"` // none (default), per-tensor or per-channel int8
+    ActivationQuantization string        `yaml:"activation_quantization,omitempty"` // static (default) or dynamic int8 conv inputs
 }
 
 // LayerConfig defines configuration for individual layers
@@ -42,6 +51,10 @@ type LayerConfig struct {
     PoolStride      int    `yaml:"pool_stride,omitempty"`
     ApplyBatchNorm  bool   `yaml:"apply_batch_norm,omitempty"`
     ApplyActivation bool   `yaml:"apply_activation,omitempty"`
+    
+    // Custom layers (type: custom) name a registered op and pass it numeric params
+    Op              string             `yaml:"op,omitempty"`
+    Params          map[string]float64 `yaml:"params,omitempty"`
 }
 
 // DataConfig defines data loading settings
@@ -51,6 +64,20 @@ type DataConfig struct {
     Normalize   bool   `yaml:"normalize"`
     MeanValues  []float32 `yaml:"mean_values,omitempty"`
     StdValues   []float32 `yaml:"std_values,omitempty"`
+    
+    // Stored image size when it differs from the model input (default: the input size)
+    ImageHeight   int     `yaml:"image_height,omitempty"`
+    ImageWidth    int     `yaml:"image_width,omitempty"`
+    Resize        string  `yaml:"resize,omitempty"`         // stretch (default), center-crop or letterbox
+    LetterboxFill float32 `yaml:"letterbox_fill,omitempty"` // Padding value for letterbox
+    
+    // Background loading: samples decoded ahead of the evaluator (0 disables) and
+    // goroutines decoding them (default 1)
+    Prefetch      int `yaml:"prefetch,omitempty"`
+    LoaderWorkers int `yaml:"loader_workers,omitempty"`
+    
+    // Directory of preprocessed samples reused between runs (empty disables)
+    CacheDir string `yaml:"cache_dir,omitempty"`
 }
 
 // InferenceConfig defines inference-specific settings
@@ -58,9 +85,18 @@ type InferenceConfig struct {
     BatchSize     int    `yaml:"batch_size"`
     UseParallel   bool   `yaml:"use_parallel"`
     NumWorkers    int    `yaml:"num_workers"`
+    Warmup        bool   `yaml:"warmup"` // Touch weight pages, autotune and run dummy inferences before serving
+    WarmupInferences int `yaml:"warmup_inferences,omitempty"` // Dummy inferences run by the warm-up (default 3)
     OutputFormat  string `yaml:"output_format"`
     SaveResults   bool   `yaml:"save_results"`
     OutputPath    string `yaml:"output_path"`
+    
+    // Exposing gocnn-serve beyond localhost: TLS certificate and key (PEM files, both
+    // or neither) and the API keys accepted as bearer tokens (none disables auth)
+    TLSCertFile string   `yaml:"tls_cert_file,omitempty"`
+    TLSKeyFile  string   `yaml:"tls_key_file,omitempty"`
+    APIKeys     []string `yaml:"api_keys,omitempty"`
+    APIKeysFile string   `yaml:"api_keys_file,omitempty"` // One key per line, read at start-up
 }
 
 // BenchmarkConfig defines benchmarking settings
@@ -74,6 +110,39 @@ type BenchmarkConfig struct {
     Tolerance       float32 `yaml:"tolerance"`
 }
 
+// ServingConfig defines the models gocnn-serve loads and how it answers requests
+type ServingConfig struct {
+    Address     string              `yaml:"address,omitempty"`      // Listen address (default ":8080")
+    GRPCAddress string              `yaml:"grpc_address,omitempty"` // gRPC listen address (empty serves no gRPC)
+    Models      []ServedModelConfig `yaml:"models,omitempty"`       // Empty serves the model above as "default"
+    BatchWindow time.Duration       `yaml:"batch_window,omitempty"` // How long a request waits for others to batch with (default 2ms)
+    MaxBatch    int                 `yaml:"max_batch,omitempty"`    // Requests coalesced per forward pass (0 or 1 disables batching)
+    
+    // Backpressure: predictions running at once (0 is unlimited), requests queued for
+    // one before 429s (default 4 per slot), and the longest wait in that queue (0 waits
+    // for the client)
+    MaxInFlight  int           `yaml:"max_inflight,omitempty"`
+    MaxQueue     int           `yaml:"max_queue,omitempty"`
+    QueueTimeout time.Duration `yaml:"queue_timeout,omitempty"`
+    
+    // Requests per second per client (validated API key or address; 0 is unlimited) and burst
+    RateLimit float64 `yaml:"rate_limit,omitempty"`
+    RateBurst int     `yaml:"rate_burst,omitempty"`
+    
+    // JSONL file every prediction is appended to (empty disables), rotated past
+    // audit_max_mb (default 100) keeping audit_max_files old files (default 5)
+    AuditLog      string `yaml:"audit_log,omitempty"`
+    AuditMaxMB    int    `yaml:"audit_max_mb,omitempty"`
+    AuditMaxFiles int    `yaml:"audit_max_files,omitempty"`
+}
+
+// ServedModelConfig names one model served by gocnn-serve
+type ServedModelConfig struct {
+    Name    string `yaml:"name"`             // Selects the model: /v1/models/<name>/predict or ?model=<name>
+    Weights string `yaml:"weights"`          // Weights directory
+    Config  string `yaml:"config,omitempty"` // Config file with its model and data sections (default: this file)
+}
+
 // Load reads and parses a YAML configuration file
 func Load(configPath string) (*Config, error) {
     // Check if file exists
@@ -117,6 +186,9 @@ func (c *Config) Validate() error {
         return fmt.Errorf("invalid input dimensions: %dx%dx%d", 
             c.Model.InputHeight, c.Model.InputWidth, c.Model.InputChannels)
     }
+    if c.Model.InputDepth < 0 {
+        return fmt.Errorf("invalid input depth: %d", c.Model.InputDepth)
+    }
     
     if c.Model.NumClasses <= 0 {
         return fmt.Errorf("number of classes must be positive")
@@ -132,6 +204,24 @@ func (c *Config) Validate() error {
         return fmt.Errorf("weights path is required")
     }
     
+    if _, err := tensor.ParsePrecision(c.Model.Precision); err != nil {
+        return err
+    }
+    
+    if _, err := quant.ParseGranularity(c.Model.WeightQuantization); err != nil {
+        return err
+    }
+    
+    if _, err := quant.ParseActivationMode(c.Model.ActivationQuantization); err != nil {
+        return err
+    }
+    
+    for i, layer := range c.Model.Layers {
+        if layer.Type == "custom" && layer.Op == "" {
+            return fmt.Errorf("layer %d (%s): custom layers require an op", i, layer.Name)
+        }
+    }
+    
     // Validate inference config
     if c.Inference.BatchSize <= 0 {
         c.Inference.BatchSize = 1 // Default
@@ -141,7 +231,65 @@ func (c *Config) Validate() error {
         c.Inference.NumWorkers = 1 // Default
     }
     
+    if c.Inference.WarmupInferences <= 0 {
+        c.Inference.WarmupInferences = 3 // Default
+    }
+    
     // Validate data config
+    if c.Data.ImageHeight < 0 || c.Data.ImageWidth < 0 {
+        return fmt.Errorf("image size must not be negative, got %dx%d", c.Data.ImageHeight, c.Data.ImageWidth)
+    }
+    
+    if c.Data.Prefetch < 0 || c.Data.LoaderWorkers < 0 {
+        return fmt.Errorf("prefetch and loader_workers must not be negative, got %d and %d",
+            c.Data.Prefetch, c.Data.LoaderWorkers)
+    }
+    
+    if c.Data.Normalize {
+        if len(c.Data.MeanValues) != c.Model.InputChannels || len(c.Data.StdValues) != c.Model.InputChannels {
+            return fmt.Errorf("normalize needs one mean and std per input channel (%d), got %d and %d",
+                c.Model.InputChannels, len(c.Data.MeanValues), len(c.Data.StdValues))
+        }
+        for i, std := range c.Data.StdValues {
+            if std <= 0 {
+                return fmt.Errorf("std_values[%d] must be positive, got %g", i, std)
+            }
+        }
+    }
+    
+    if (c.Inference.TLSCertFile == "") != (c.Inference.TLSKeyFile == "") {
+        return fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+    }
+    
+    // Validate serving config
+    if c.Serving.BatchWindow < 0 || c.Serving.MaxBatch < 0 {
+        return fmt.Errorf("serving batch_window and max_batch must not be negative, got %v and %d",
+            c.Serving.BatchWindow, c.Serving.MaxBatch)
+    }
+    
+    if c.Serving.MaxInFlight < 0 || c.Serving.MaxQueue < 0 || c.Serving.QueueTimeout < 0 ||
+        c.Serving.RateLimit < 0 || c.Serving.RateBurst < 0 {
+        return fmt.Errorf("serving limits must not be negative")
+    }
+    
+    if c.Serving.AuditMaxMB < 0 || c.Serving.AuditMaxFiles < 0 {
+        return fmt.Errorf("serving audit_max_mb and audit_max_files must not be negative")
+    }
+    
+    served := make(map[string]bool)
+    for i, m := range c.Serving.Models {
+        if m.Name == "" || m.Weights == "" {
+            return fmt.Errorf("serving model %d: name and weights are required", i)
+        }
+        if strings.ContainsAny(m.Name, "/?#") {
+            return fmt.Errorf("serving model %q: names must not contain '/', '?' or '#'", m.Name)
+        }
+        if served[m.Name] {
+            return fmt.Errorf("serving model %q is listed twice", m.Name)
+        }
+        served[m.Name] = true
+    }
+    
     if c.Data.Format == "" {
         c.Data.Format = "binary" // Default
     }
@@ -178,10 +326,23 @@ func (c *Config) ApplyDefaults() {
         c.Inference.NumWorkers = 1
     }
     
+    if c.Inference.WarmupInferences <= 0 {
+        c.Inference.WarmupInferences = 3
+    }
+    
     if c.Inference.OutputFormat == "" {
         c.Inference.OutputFormat = "json"
     }
     
+    // Serving defaults
+    if c.Serving.Address == "" {
+        c.Serving.Address = ":8080"
+    }
+    
+    if c.Serving.BatchWindow == 0 {
+        c.Serving.BatchWindow = 2 * time.Millisecond
+    }
+    
     // Benchmark defaults
     if c.Benchmark.ReportTopK <= 0 {
         c.Benchmark.ReportTopK = 5
@@ -0,0 +1,356 @@
+package augment
+
+import (
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+* Augmentation and corruption
+
+The same image transforms serve two purposes:
+  - Robustness evaluation: measure how accuracy degrades when the test set
+    is perturbed (noise, lighting changes, shifts, mirror images), as in
+    the CIFAR-10-C benchmark. Fixed-strength corruptions give comparable
+    numbers across models.
+  - Training: random flips and padded crops are the standard CIFAR-10
+    augmentation, often with cutout; they multiply the effective size of
+    the training set.
+
+Every transform is a data.Step, so it runs inside the preprocessing
+pipeline (data.Preprocessor.SetAugmentation) after the image has the model
+input size and before normalization, on [0, 1] pixels. Outputs are clamped
+back to [0, 1].
+
+Random transforms draw from a seeded generator, so a run can be repeated
+exactly; the generator is locked, so a step may be shared by goroutines
+(their draw order, and so the exact result, then depends on scheduling).
+*/
+
+// Rand is a seeded random source that steps may share across goroutines
+type Rand struct {
+    mu  sync.Mutex
+    src *rand.PCG
+    r   *rand.Rand
+}
+
+// NewRand creates a random source; the same seed gives the same sequence
+func NewRand(seed uint64) *Rand {
+    src := rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)
+    return &Rand{src: src, r: rand.New(src)}
+}
+
+// MarshalBinary returns the position of the source in its sequence, so a
+// training checkpoint can continue the sequence with UnmarshalBinary
+func (r *Rand) MarshalBinary() ([]byte, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    return r.src.MarshalBinary()
+}
+
+// UnmarshalBinary moves the source to a position returned by MarshalBinary
+func (r *Rand) UnmarshalBinary(state []byte) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    return r.src.UnmarshalBinary(state)
+}
+
+// Float64 returns a uniform value in [0, 1)
+func (r *Rand) Float64() float64 {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    return r.r.Float64()
+}
+
+// IntN returns a uniform value in [0, n)
+func (r *Rand) IntN(n int) int {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    return r.r.IntN(n)
+}
+
+// NormFloat64s fills values with standard normal samples
+func (r *Rand) NormFloat64s(values []float32) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for i := range values {
+        values[i] = float32(r.r.NormFloat64())
+    }
+}
+
+// clamp01 limits v to the [0, 1] pixel range
+func clamp01(v float32) float32 {
+    return min(max(v, 0), 1)
+}
+
+// flipStep mirrors images left to right
+type flipStep struct {
+    probability float64
+    rng         *Rand
+}
+
+// HorizontalFlip returns a step that mirrors an image left to right with the given
+// probability; 1 flips every image
+func HorizontalFlip(probability float64, rng *Rand) data.Step {
+    return flipStep{probability: probability, rng: rng}
+}
+
+func (s flipStep) String() string {
+    return fmt.Sprintf("hflip(p %g)", s.probability)
+}
+
+func (s flipStep) Apply(fm *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    if s.probability < 1 && s.rng.Float64() >= s.probability {
+        return fm.Clone(), nil
+    }
+
+    output := tensor.NewFeatureMapWithLayout(fm.Height, fm.Width, fm.Channels, fm.Layout)
+    for c := 0; c < fm.Channels; c++ {
+        for h := 0; h < fm.Height; h++ {
+            for w := 0; w < fm.Width; w++ {
+                output.SetUnsafe(c, h, w, fm.GetUnsafe(c, h, fm.Width-1-w))
+            }
+        }
+    }
+    return output, nil
+}
+
+// cropStep pads an image and crops it back to its size at a random offset
+type cropStep struct {
+    padding int
+    fill    float32
+    rng     *Rand
+}
+
+// RandomCrop returns a step that pads an image by padding pixels of fill on every
+// side and crops a window of the original size at a random position, shifting the
+// content by up to padding pixels in each direction
+func RandomCrop(padding int, fill float32, rng *Rand) data.Step {
+    return cropStep{padding: padding, fill: fill, rng: rng}
+}
+
+func (s cropStep) String() string {
+    return fmt.Sprintf("random-crop(pad %d)", s.padding)
+}
+
+func (s cropStep) Apply(fm *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    if s.padding < 0 {
+        return nil, fmt.Errorf("negative padding %d", s.padding)
+    }
+    dy := s.rng.IntN(2*s.padding+1) - s.padding
+    dx := s.rng.IntN(2*s.padding+1) - s.padding
+
+    output := tensor.NewFeatureMapWithLayout(fm.Height, fm.Width, fm.Channels, fm.Layout)
+    for c := 0; c < fm.Channels; c++ {
+        for h := 0; h < fm.Height; h++ {
+            for w := 0; w < fm.Width; w++ {
+                sh, sw := h+dy, w+dx
+                value := s.fill
+                if sh >= 0 && sh < fm.Height && sw >= 0 && sw < fm.Width {
+                    value = fm.GetUnsafe(c, sh, sw)
+                }
+                output.SetUnsafe(c, h, w, value)
+            }
+        }
+    }
+    return output, nil
+}
+
+// cutoutStep masks a random square of an image
+type cutoutStep struct {
+    size int
+    fill float32
+    rng  *Rand
+}
+
+// Cutout returns a step that sets a size×size square of every channel to fill,
+// centered on a random pixel (DeVries and Taylor, 2017). Squares are clipped at
+// the border, so a corner center masks only a quarter of the square.
+func Cutout(size int, fill float32, rng *Rand) data.Step {
+    return cutoutStep{size: size, fill: fill, rng: rng}
+}
+
+func (s cutoutStep) String() string {
+    return fmt.Sprintf("cutout(%d)", s.size)
+}
+
+func (s cutoutStep) Apply(fm *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    if s.size < 0 {
+        return nil, fmt.Errorf("negative cutout size %d", s.size)
+    }
+    top := s.rng.IntN(fm.Height) - s.size/2
+    left := s.rng.IntN(fm.Width) - s.size/2
+
+    output := fm.Clone()
+    for c := 0; c < fm.Channels; c++ {
+        for h := max(top, 0); h < min(top+s.size, fm.Height); h++ {
+            for w := max(left, 0); w < min(left+s.size, fm.Width); w++ {
+                output.SetUnsafe(c, h, w, s.fill)
+            }
+        }
+    }
+    return output, nil
+}
+
+// noiseStep adds Gaussian noise to every pixel
+type noiseStep struct {
+    sigma float32
+    rng   *Rand
+}
+
+// GaussianNoise returns a step that adds noise of standard deviation sigma to every
+// pixel value
+func GaussianNoise(sigma float32, rng *Rand) data.Step {
+    return noiseStep{sigma: sigma, rng: rng}
+}
+
+func (s noiseStep) String() string {
+    return fmt.Sprintf("noise(sigma %g)", s.sigma)
+}
+
+func (s noiseStep) Apply(fm *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    output := fm.Clone()
+    noise := make([]float32, len(output.Data))
+    s.rng.NormFloat64s(noise)
+    for i, v := range output.Data {
+        output.Data[i] = clamp01(v + s.sigma*noise[i])
+    }
+    return output, nil
+}
+
+// brightnessStep shifts every pixel value
+type brightnessStep struct {
+    delta float32
+}
+
+// Brightness returns a step that adds delta to every pixel value; negative darkens
+func Brightness(delta float32) data.Step {
+    return brightnessStep{delta: delta}
+}
+
+func (s brightnessStep) String() string {
+    return fmt.Sprintf("brightness(%+g)", s.delta)
+}
+
+func (s brightnessStep) Apply(fm *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    output := fm.Clone()
+    for i, v := range output.Data {
+        output.Data[i] = clamp01(v + s.delta)
+    }
+    return output, nil
+}
+
+// contrastStep scales pixel values around the image mean
+type contrastStep struct {
+    factor float32
+}
+
+// Contrast returns a step that scales every pixel's distance from the image mean by
+// factor; below 1 flattens the image, above 1 sharpens it
+func Contrast(factor float32) data.Step {
+    return contrastStep{factor: factor}
+}
+
+func (s contrastStep) String() string {
+    return fmt.Sprintf("contrast(x%g)", s.factor)
+}
+
+func (s contrastStep) Apply(fm *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    if s.factor < 0 {
+        return nil, fmt.Errorf("negative contrast factor %g", s.factor)
+    }
+    output := fm.Clone()
+    if len(output.Data) == 0 {
+        return output, nil
+    }
+
+    var sum float64
+    for _, v := range output.Data {
+        sum += float64(v)
+    }
+    mean := float32(sum / float64(len(output.Data)))
+    for i, v := range output.Data {
+        output.Data[i] = clamp01(mean + (v-mean)*s.factor)
+    }
+    return output, nil
+}
+
+// Parse builds the steps of a comma-separated spec, in order, such as
+// "hflip,crop=4,noise=0.05". Random steps draw from one source seeded with seed.
+func Parse(spec string, seed uint64) ([]data.Step, error) {
+    return ParseWithRand(spec, NewRand(seed))
+}
+
+// ParseWithRand is Parse with random steps drawing from rng, whose state the
+// caller can then save and restore:
+//
+//	hflip[=p]          mirror with probability p (default 1)
+//	crop=n             pad n pixels of 0 and crop back at a random offset
+//	cutout=n           set a random n×n square to 0
+//	noise=sigma        add Gaussian noise
+//	brightness=delta   add delta to every pixel
+//	contrast=factor    scale distances from the image mean
+func ParseWithRand(spec string, rng *Rand) ([]data.Step, error) {
+    var steps []data.Step
+    for _, item := range strings.Split(spec, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+
+        name, arg, hasArg := strings.Cut(item, "=")
+        name = strings.ToLower(strings.TrimSpace(name))
+        var value float64
+        if hasArg {
+            var err error
+            value, err = strconv.ParseFloat(strings.TrimSpace(arg), 64)
+            if err != nil {
+                return nil, fmt.Errorf("augmentation %q: invalid value %q", name, arg)
+            }
+        } else if name != "hflip" && name != "flip" {
+            return nil, fmt.Errorf("augmentation %q needs a value (e.g. %s=0.1)", name, name)
+        }
+
+        switch name {
+        case "hflip", "flip":
+            probability := 1.0
+            if hasArg {
+                probability = value
+            }
+            if probability < 0 || probability > 1 {
+                return nil, fmt.Errorf("hflip probability %g is outside [0, 1]", probability)
+            }
+            steps = append(steps, HorizontalFlip(probability, rng))
+        case "crop":
+            if value < 0 || value != float64(int(value)) {
+                return nil, fmt.Errorf("crop padding must be a whole number of pixels, got %g", value)
+            }
+            steps = append(steps, RandomCrop(int(value), 0, rng))
+        case "cutout":
+            if value < 0 || value != float64(int(value)) {
+                return nil, fmt.Errorf("cutout size must be a whole number of pixels, got %g", value)
+            }
+            steps = append(steps, Cutout(int(value), 0, rng))
+        case "noise":
+            if value < 0 {
+                return nil, fmt.Errorf("noise sigma must not be negative, got %g", value)
+            }
+            steps = append(steps, GaussianNoise(float32(value), rng))
+        case "brightness":
+            steps = append(steps, Brightness(float32(value)))
+        case "contrast":
+            if value < 0 {
+                return nil, fmt.Errorf("contrast factor must not be negative, got %g", value)
+            }
+            steps = append(steps, Contrast(float32(value)))
+        default:
+            return nil, fmt.Errorf("unknown augmentation %q (use hflip, crop, cutout, noise, brightness or contrast)", name)
+        }
+    }
+    return steps, nil
+}
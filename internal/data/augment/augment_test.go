@@ -0,0 +1,243 @@
+package augment
+
+import (
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/tensor"
+	"math"
+	"strings"
+	"testing"
+)
+
+// gradient returns a 3×4 single-channel image whose pixel (h, w) is (4h + w) / 12
+func gradient() *tensor.FeatureMap {
+    fm := tensor.NewFeatureMap(3, 4, 1)
+    for h := 0; h < 3; h++ {
+        for w := 0; w < 4; w++ {
+            fm.SetUnsafe(0, h, w, float32(4*h+w)/12)
+        }
+    }
+    return fm
+}
+
+func TestHorizontalFlip(t *testing.T) {
+    input := gradient()
+    output, err := HorizontalFlip(1, NewRand(1)).Apply(input)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for h := 0; h < 3; h++ {
+        for w := 0; w < 4; w++ {
+            if output.GetUnsafe(0, h, w) != input.GetUnsafe(0, h, 3-w) {
+                t.Fatalf("Pixel (%d, %d) is not mirrored", h, w)
+            }
+        }
+    }
+
+    // Layout is preserved
+    hwc := input.ToLayout(tensor.LayoutHWC)
+    flipped, _ := HorizontalFlip(1, NewRand(1)).Apply(hwc)
+    if flipped.Layout != tensor.LayoutHWC || flipped.GetUnsafe(0, 2, 0) != input.GetUnsafe(0, 2, 3) {
+        t.Error("HWC image was not flipped in its own layout")
+    }
+
+    // Probability 0 never flips
+    same, _ := HorizontalFlip(0, NewRand(1)).Apply(input)
+    if same.GetUnsafe(0, 0, 0) != input.GetUnsafe(0, 0, 0) {
+        t.Error("Flip with probability 0 changed the image")
+    }
+}
+
+func TestRandomCrop(t *testing.T) {
+    input := gradient()
+    crop := RandomCrop(1, 0, NewRand(7))
+    for trial := 0; trial < 20; trial++ {
+        output, err := crop.Apply(input)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if output.Height != 3 || output.Width != 4 {
+            t.Fatalf("Crop changed the size to %s", output)
+        }
+
+        // The center pixel moves by at most one in each direction
+        center := output.GetUnsafe(0, 1, 1)
+        found := false
+        for dy := -1; dy <= 1; dy++ {
+            for dx := -1; dx <= 1; dx++ {
+                if input.GetUnsafe(0, 1+dy, 1+dx) == center {
+                    found = true
+                }
+            }
+        }
+        if !found {
+            t.Fatalf("Center pixel %f is not a neighbour of the input center", center)
+        }
+    }
+
+    // Padding 0 is the identity
+    output, _ := RandomCrop(0, 0, NewRand(7)).Apply(input)
+    for i := range input.Data {
+        if output.Data[i] != input.Data[i] {
+            t.Fatal("Crop without padding changed the image")
+        }
+    }
+}
+
+func TestCutout(t *testing.T) {
+    input := tensor.NewFeatureMap(8, 8, 2)
+    input.Fill(1)
+    step := Cutout(4, 0, NewRand(2))
+    whole := false
+    for i := 0; i < 50; i++ {
+        output, err := step.Apply(input)
+        if err != nil {
+            t.Fatal(err)
+        }
+        masked := make([]int, 2)
+        for c := 0; c < 2; c++ {
+            for h := 0; h < 8; h++ {
+                for w := 0; w < 8; w++ {
+                    if output.GetUnsafe(c, h, w) == 0 {
+                        masked[c]++
+                    }
+                }
+            }
+        }
+        if masked[0] != masked[1] || masked[0] == 0 || masked[0] > 16 {
+            t.Fatalf("Expected the same clipped 4x4 square in both channels, masked %v pixels", masked)
+        }
+        whole = whole || masked[0] == 16
+    }
+    if !whole {
+        t.Error("Expected some squares to lie wholly inside the image")
+    }
+    if input.Data[0] != 1 {
+        t.Error("Cutout modified its input")
+    }
+    if _, err := Cutout(-1, 0, NewRand(1)).Apply(input); err == nil {
+        t.Error("Expected an error for a negative size")
+    }
+}
+
+func TestGaussianNoise(t *testing.T) {
+    input := tensor.NewFeatureMap(32, 32, 3)
+    input.Fill(0.5)
+    output, err := GaussianNoise(0.1, NewRand(3)).Apply(input)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var sum, sumSquares float64
+    for _, v := range output.Data {
+        d := float64(v) - 0.5
+        sum += d
+        sumSquares += d * d
+    }
+    n := float64(len(output.Data))
+    if mean, std := sum/n, math.Sqrt(sumSquares/n); math.Abs(mean) > 0.01 || math.Abs(std-0.1) > 0.01 {
+        t.Errorf("Expected noise with mean 0 and std 0.1, got %f and %f", mean, std)
+    }
+    if input.Data[0] != 0.5 {
+        t.Error("Noise modified its input")
+    }
+
+    // The same seed gives the same noise
+    again, _ := GaussianNoise(0.1, NewRand(3)).Apply(input)
+    for i := range output.Data {
+        if again.Data[i] != output.Data[i] {
+            t.Fatal("Noise is not reproducible from its seed")
+        }
+    }
+}
+
+func TestRandState(t *testing.T) {
+    rng := NewRand(5)
+    rng.Float64()
+    state, err := rng.MarshalBinary()
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := []int{rng.IntN(1000), rng.IntN(1000), rng.IntN(1000)}
+
+    resumed := NewRand(9)
+    if err := resumed.UnmarshalBinary(state); err != nil {
+        t.Fatal(err)
+    }
+    for i, w := range want {
+        if got := resumed.IntN(1000); got != w {
+            t.Fatalf("Draw %d after restoring: got %d, expected %d", i, got, w)
+        }
+    }
+}
+
+func TestBrightnessContrast(t *testing.T) {
+    input := gradient()
+
+    bright, _ := Brightness(0.5).Apply(input)
+    if got := bright.GetUnsafe(0, 0, 0); got != 0.5 {
+        t.Errorf("Expected 0 + 0.5, got %f", got)
+    }
+    if got := bright.GetUnsafe(0, 2, 3); got != 1 {
+        t.Errorf("Expected brightened pixel clamped to 1, got %f", got)
+    }
+
+    // The gradient's mean is 11/24; contrast 0 flattens every pixel to it
+    flat, err := Contrast(0).Apply(input)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, v := range flat.Data {
+        if math.Abs(float64(v)-11.0/24) > 1e-6 {
+            t.Fatalf("Expected every pixel at the mean, got %f", v)
+        }
+    }
+    if _, err := Contrast(-1).Apply(input); err == nil {
+        t.Error("Expected an error for a negative contrast factor")
+    }
+}
+
+func TestParse(t *testing.T) {
+    steps, err := Parse("hflip, crop=4,cutout=8,noise=0.05,brightness=-0.1,contrast=0.8", 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    pipeline := data.NewPipeline(steps...)
+    want := "hflip(p 1) -> random-crop(pad 4) -> cutout(8) -> noise(sigma 0.05) -> brightness(-0.1) -> contrast(x0.8)"
+    if pipeline.String() != want {
+        t.Errorf("Expected %q, got %q", want, pipeline.String())
+    }
+
+    if steps, err := Parse("", 1); err != nil || len(steps) != 0 {
+        t.Errorf("Expected no steps for an empty spec, got %v (%v)", steps, err)
+    }
+    for _, spec := range []string{"blur=1", "noise", "crop=1.5", "cutout=-2", "hflip=2", "contrast=x"} {
+        if _, err := Parse(spec, 1); err == nil {
+            t.Errorf("Expected an error for %q", spec)
+        }
+    }
+}
+
+func TestPreprocessorAugmentation(t *testing.T) {
+    mc := config.ModelConfig{InputHeight: 4, InputWidth: 4, InputChannels: 1}
+    dc := config.DataConfig{Normalize: true, MeanValues: []float32{0.5}, StdValues: []float32{0.5}}
+    preprocessor, err := data.NewPreprocessor(data.BinaryFloat32, dc, mc)
+    if err != nil {
+        t.Fatal(err)
+    }
+    preprocessor.SetAugmentation(Brightness(0.25))
+
+    // Augmentation runs on pixels, before normalization: (0.25 + 0.25 - 0.5) / 0.5 = 0
+    input := tensor.NewFeatureMap(4, 4, 1)
+    input.Fill(0.25)
+    output, err := preprocessor.Apply(input)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if output.Data[0] != 0 {
+        t.Errorf("Expected brightness before normalization, got %f", output.Data[0])
+    }
+    if !strings.Contains(preprocessor.Pipeline().String(), "brightness(+0.25) -> normalize") {
+        t.Errorf("Unexpected pipeline %s", preprocessor.Pipeline())
+    }
+}
@@ -0,0 +1,171 @@
+package data
+
+import (
+	"crypto/sha256"
+	"duchm1606/gocnn/internal/tensor"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+/**
+* Preprocessed tensor cache
+
+Decoding a PNG and resizing it costs more than a quantized inference, and a
+benchmark run repeats exactly the same work on every image each time. The
+cache stores each preprocessed sample under the SHA-256 of everything that
+determines it:
+```
+key = sha256(settings || stored bytes of the sample)
+```
+where the stored bytes are the image file (plus the label source: label
+file, manifest label or class folder) or the TFRecord record, and settings
+describe the preprocessing (stored size, channels, pipeline steps). A
+changed image, label or config gives a different key, so entries never go
+stale; they are only ever added, and deleting the directory is always safe.
+
+Entries are flat little-endian files, sharded by the first two hex digits
+of the key and written to a temporary file first, so a run that is killed
+midway or a second run sharing the directory never reads half an entry:
+```
+<dir>/ab/abcdef...bin:
+  magic "GTC1"
+  uint32 height, width, channels, layout, number of label values
+  int32  label values
+  float32 pixels (height*width*channels, in layout order)
+```
+Hashing still reads every file, but that is a small part of decoding it.
+*/
+
+// tensorCacheMagic starts every cache entry
+var tensorCacheMagic = [4]byte{'G', 'T', 'C', '1'}
+
+// TensorCache stores preprocessed samples on disk keyed by content hash
+// It is safe for concurrent use.
+type TensorCache struct {
+    dir    string
+    hits   atomic.Int64
+    misses atomic.Int64
+}
+
+// NewTensorCache opens (creating if needed) a cache in dir
+func NewTensorCache(dir string) (*TensorCache, error) {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, fmt.Errorf("failed to create tensor cache directory: %w", err)
+    }
+    return &TensorCache{dir: dir}, nil
+}
+
+// Dir returns the cache directory
+func (c *TensorCache) Dir() string {
+    return c.dir
+}
+
+// Key returns the cache key of a sample's stored bytes under the given settings
+func (c *TensorCache) Key(settings string, content []byte) string {
+    hash := sha256.New()
+    hash.Write([]byte(settings))
+    hash.Write([]byte{0})
+    hash.Write(content)
+    return hex.EncodeToString(hash.Sum(nil))
+}
+
+// path returns the entry file of key
+func (c *TensorCache) path(key string) string {
+    return filepath.Join(c.dir, key[:2], key+".bin")
+}
+
+// Get returns the sample stored under key; a missing or unreadable entry is a miss
+func (c *TensorCache) Get(key string) (*tensor.FeatureMap, []int, bool) {
+    raw, err := os.ReadFile(c.path(key))
+    if err != nil {
+        c.misses.Add(1)
+        return nil, nil, false
+    }
+    fm, label, err := decodeCacheEntry(raw)
+    if err != nil {
+        c.misses.Add(1)
+        return nil, nil, false
+    }
+    c.hits.Add(1)
+    return fm, label, true
+}
+
+// Put stores a sample under key
+func (c *TensorCache) Put(key string, fm *tensor.FeatureMap, label []int) error {
+    shard := filepath.Dir(c.path(key))
+    if err := os.MkdirAll(shard, 0755); err != nil {
+        return fmt.Errorf("failed to create tensor cache shard: %w", err)
+    }
+
+    file, err := os.CreateTemp(shard, key+".*.tmp")
+    if err != nil {
+        return fmt.Errorf("failed to write tensor cache entry: %w", err)
+    }
+    _, err = file.Write(encodeCacheEntry(fm, label))
+    if closeErr := file.Close(); err == nil {
+        err = closeErr
+    }
+    if err == nil {
+        err = os.Rename(file.Name(), c.path(key))
+    }
+    if err != nil {
+        os.Remove(file.Name())
+        return fmt.Errorf("failed to write tensor cache entry: %w", err)
+    }
+    return nil
+}
+
+// Stats returns the number of hits and misses since the cache was opened
+func (c *TensorCache) Stats() (hits, misses int64) {
+    return c.hits.Load(), c.misses.Load()
+}
+
+// encodeCacheEntry serializes a sample in the entry format
+func encodeCacheEntry(fm *tensor.FeatureMap, label []int) []byte {
+    buf := make([]byte, 0, 24+4*len(label)+4*len(fm.Data))
+    buf = append(buf, tensorCacheMagic[:]...)
+    for _, v := range []int{fm.Height, fm.Width, fm.Channels, int(fm.Layout), len(label)} {
+        buf = binary.LittleEndian.AppendUint32(buf, uint32(v))
+    }
+    for _, v := range label {
+        buf = binary.LittleEndian.AppendUint32(buf, uint32(int32(v)))
+    }
+    for _, v := range fm.Data {
+        buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+    }
+    return buf
+}
+
+// decodeCacheEntry parses an entry written by encodeCacheEntry
+func decodeCacheEntry(raw []byte) (*tensor.FeatureMap, []int, error) {
+    if len(raw) < 24 || [4]byte(raw[:4]) != tensorCacheMagic {
+        return nil, nil, fmt.Errorf("not a tensor cache entry")
+    }
+    header := make([]int, 5)
+    for i := range header {
+        header[i] = int(binary.LittleEndian.Uint32(raw[4+4*i:]))
+    }
+    height, width, channels, layout, labels := header[0], header[1], header[2], tensor.Layout(header[3]), header[4]
+    if len(raw) != 24+4*labels+4*height*width*channels {
+        return nil, nil, fmt.Errorf("tensor cache entry has %d bytes, expected %d",
+            len(raw), 24+4*labels+4*height*width*channels)
+    }
+
+    offset := 24
+    label := make([]int, labels)
+    for i := range label {
+        label[i] = int(int32(binary.LittleEndian.Uint32(raw[offset:])))
+        offset += 4
+    }
+    fm := tensor.NewFeatureMapWithLayout(height, width, channels, layout)
+    for i := range fm.Data {
+        fm.Data[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[offset:]))
+        offset += 4
+    }
+    return fm, label, nil
+}
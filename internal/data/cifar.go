@@ -0,0 +1,162 @@
+package data
+
+import (
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/**
+* CIFAR-10 batch files
+
+The binary version of CIFAR-10 (cifar-10-binary.tar.gz) ships the training
+set as five files of 10,000 images, data_batch_1.bin to data_batch_5.bin, and
+the test set as test_batch.bin. Every file is a plain sequence of records:
+```
+byte label             class index, 0-9
+byte red[32][32]       row-major, then the green plane, then the blue one
+byte green[32][32]
+byte blue[32][32]
+```
+The planes are already in the CHW order of a feature map. A whole file is
+30 MB, a quarter of its size as float32 images, so a CIFAR10Set keeps the
+raw records and decodes an image (scaled to [0, 1]) only when it is asked
+for, in any order: training draws them shuffled.
+*/
+
+// CIFAR-10 image size and record layout
+const (
+    CIFAR10Height     = 32
+    CIFAR10Width      = 32
+    CIFAR10Channels   = 3
+    CIFAR10Classes    = 10
+    CIFAR10RecordSize = 1 + CIFAR10Height*CIFAR10Width*CIFAR10Channels
+)
+
+// CIFAR10Set holds the records of one or more CIFAR-10 batch files
+type CIFAR10Set struct {
+    records []byte
+    paths   []string // Source file of every starts entry
+    starts  []int    // Index of the first record of every file
+}
+
+// IsCIFAR10BatchFile reports whether path is named like a CIFAR-10 batch file
+func IsCIFAR10BatchFile(path string) bool {
+    name := strings.ToLower(filepath.Base(path))
+    return strings.HasSuffix(name, ".bin") &&
+        (strings.HasPrefix(name, "data_batch") || strings.HasPrefix(name, "test_batch"))
+}
+
+// LoadCIFAR10 reads the records of the CIFAR-10 batch files at paths, in order
+func LoadCIFAR10(paths ...string) (*CIFAR10Set, error) {
+    if len(paths) == 0 {
+        return nil, fmt.Errorf("no CIFAR-10 batch files given")
+    }
+
+    set := &CIFAR10Set{}
+    for _, path := range paths {
+        raw, err := os.ReadFile(path)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read CIFAR-10 batch file: %w", err)
+        }
+        if len(raw) == 0 || len(raw)%CIFAR10RecordSize != 0 {
+            err := fmt.Errorf("%s has %d bytes, not a whole number of %d-byte CIFAR-10 records",
+                path, len(raw), CIFAR10RecordSize)
+            return nil, errs.WithHint(err, "use the files of the binary version (cifar-10-binary.tar.gz), "+
+                "not the Python pickles")
+        }
+        for i := 0; i < len(raw); i += CIFAR10RecordSize {
+            if label := raw[i]; label >= CIFAR10Classes {
+                return nil, fmt.Errorf("%s, record %d: label %d out of range [0, %d)",
+                    path, i/CIFAR10RecordSize, label, CIFAR10Classes)
+            }
+        }
+        set.paths = append(set.paths, path)
+        set.starts = append(set.starts, set.Len())
+        set.records = append(set.records, raw...)
+    }
+    return set, nil
+}
+
+// Len returns the number of images
+func (s *CIFAR10Set) Len() int {
+    return len(s.records) / CIFAR10RecordSize
+}
+
+// Label returns the class index of image i
+func (s *CIFAR10Set) Label(i int) int {
+    return int(s.records[i*CIFAR10RecordSize])
+}
+
+// Image decodes image i, with pixels in [0, 1]
+func (s *CIFAR10Set) Image(i int) *tensor.FeatureMap {
+    pixels := s.records[i*CIFAR10RecordSize+1 : (i+1)*CIFAR10RecordSize]
+    fm := tensor.NewFeatureMap(CIFAR10Height, CIFAR10Width, CIFAR10Channels)
+    for c := 0; c < CIFAR10Channels; c++ {
+        for h := 0; h < CIFAR10Height; h++ {
+            for w := 0; w < CIFAR10Width; w++ {
+                fm.SetUnsafe(c, h, w, float32(pixels[(c*CIFAR10Height+h)*CIFAR10Width+w])/255)
+            }
+        }
+    }
+    return fm
+}
+
+// Source returns the batch file image i was read from
+func (s *CIFAR10Set) Source(i int) string {
+    file := 0
+    for file+1 < len(s.starts) && s.starts[file+1] <= i {
+        file++
+    }
+    return s.paths[file]
+}
+
+// Iterator streams the first limit images (all when limit is 0) in order
+func (s *CIFAR10Set) Iterator(limit int) DatasetIterator {
+    n := s.Len()
+    if limit > 0 && limit < n {
+        n = limit
+    }
+    return &cifar10Iterator{set: s, n: n}
+}
+
+// cifar10Iterator streams the images of a CIFAR10Set
+type cifar10Iterator struct {
+    set  *CIFAR10Set
+    n    int
+    next int
+}
+
+func (it *cifar10Iterator) Next() (*tensor.FeatureMap, []int, error) {
+    return nextFrom(it)
+}
+
+// nextDeferred hands out the next record; decoding it is the deferred work
+func (it *cifar10Iterator) nextDeferred() (deferredSample, error) {
+    if it.next >= it.n {
+        return deferredSample{}, io.EOF
+    }
+    i := it.next
+    it.next++
+
+    return deferredSample{
+        load: func() (*tensor.FeatureMap, []int, error) {
+            return it.set.Image(i), ConvertClassIndexToOneHot(it.set.Label(i), CIFAR10Classes), nil
+        },
+        content: func() ([]byte, error) {
+            return it.set.records[i*CIFAR10RecordSize : (i+1)*CIFAR10RecordSize], nil
+        },
+    }, nil
+}
+
+func (it *cifar10Iterator) SourcePath(i int) string {
+    return it.set.Source(i)
+}
+
+func (it *cifar10Iterator) Close() error {
+    return nil
+}
@@ -1,9 +1,23 @@
 package data
 
 import (
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/quant"
+	"duchm1606/gocnn/internal/tensor"
+	"bytes"
+	"compress/gzip"
 	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -97,6 +111,91 @@ func TestWeightLoader(t *testing.T) {
     }
 }
 
+func TestWeightLoaderBFloat16(t *testing.T) {
+    tempDir := t.TempDir()
+    
+    // Same kernel as createTestWeightFile, but as bfloat16 (values up to 2123 round to 8 significant bits)
+    file, err := os.Create(filepath.Join(tempDir, "bf16_weight.bin"))
+    if err != nil {
+        t.Fatalf("Failed to create test file: %v", err)
+    }
+    for h := 0; h < 3; h++ {
+        for w := 0; w < 3; w++ {
+            for c := 0; c < 2; c++ {
+                for f := 0; f < 4; f++ {
+                    value := tensor.BFloat16FromFloat32(float32(h*1000 + w*100 + c*10 + f))
+                    binary.Write(file, binary.LittleEndian, uint16(value))
+                }
+            }
+        }
+    }
+    file.Close()
+    
+    loader := NewWeightLoader(tempDir)
+    kernel, err := loader.LoadKernel("bf16_weight.bin", 3, 2, 4)
+    if err != nil {
+        t.Fatalf("Failed to load bfloat16 kernel: %v", err)
+    }
+    
+    // (f=3, c=1, h=2, w=1) = 2113, which bfloat16 stores as 2112
+    if got := kernel.GetWeight(3, 1, 2, 1); got != 2112 {
+        t.Errorf("Wrong bfloat16 weight: got %f, expected 2112", got)
+    }
+    if got := kernel.GetWeight(1, 1, 0, 0); got != 11 {
+        t.Errorf("Wrong bfloat16 weight: got %f, expected 11", got)
+    }
+    
+    // Forcing float32 rejects the half-size file
+    loader.SetFormat(WeightFormatFloat32)
+    if _, err := loader.LoadKernel("bf16_weight.bin", 3, 2, 4); err == nil {
+        t.Error("Expected size error when forcing float32 on a bfloat16 file")
+    }
+}
+
+func TestWeightLoaderQuantized(t *testing.T) {
+    tempDir := t.TempDir()
+    
+    // Quantize the kernel of createTestWeightFile and store it in its place
+    weightFile := filepath.Join(tempDir, "test_weight.bin")
+    createTestWeightFile(t, weightFile, 3, 2, 4)
+    loader := NewWeightLoader(tempDir)
+    kernel, err := loader.LoadKernel("test_weight.bin", 3, 2, 4)
+    if err != nil {
+        t.Fatalf("Failed to load kernel: %v", err)
+    }
+    qk, err := quant.QuantizeKernel(kernel, quant.PerChannel)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(weightFile, quant.EncodeKernelFile(qk), 0644); err != nil {
+        t.Fatal(err)
+    }
+    
+    // LoadConvKernel returns it as stored
+    floatKernel, loaded, err := loader.LoadConvKernel("test_weight.bin", 3, 2, 4)
+    if err != nil {
+        t.Fatalf("Failed to load quantized kernel: %v", err)
+    }
+    if floatKernel != nil || loaded == nil || loaded.Granularity != quant.PerChannel {
+        t.Fatalf("Expected only a per-channel quantized kernel, got %v and %+v", floatKernel, loaded)
+    }
+    
+    // LoadKernel dequantizes it
+    restored, err := loader.LoadKernel("test_weight.bin", 3, 2, 4)
+    if err != nil {
+        t.Fatalf("Failed to load dequantized kernel: %v", err)
+    }
+    expected := kernel.GetWeight(3, 1, 2, 2)
+    if diff := restored.GetWeight(3, 1, 2, 2) - expected; diff > qk.Scale(3) || diff < -qk.Scale(3) {
+        t.Errorf("Dequantized weight %f too far from %f", restored.GetWeight(3, 1, 2, 2), expected)
+    }
+    
+    // Only kernels may be quantized
+    if _, err := loader.LoadBias("test_weight.bin", 4); err == nil || errs.Hint(err) == "" {
+        t.Errorf("Expected a hinted error loading a quantized file as a bias, got %v", err)
+    }
+}
+
 func TestImageLoader(t *testing.T) {
     // Create temporary directory
     tempDir := t.TempDir()
@@ -128,6 +227,81 @@ func TestImageLoader(t *testing.T) {
     }
 }
 
+func TestImageLoaderSizeHint(t *testing.T) {
+    tempDir := t.TempDir()
+    
+    // A uint8 image read as float32 is a quarter of the expected size
+    imageFile := filepath.Join(tempDir, "uint8.bin")
+    if err := os.WriteFile(imageFile, make([]byte, 4*4*3), 0644); err != nil {
+        t.Fatal(err)
+    }
+    
+    _, err := NewImageLoader(BinaryFloat32).LoadImage(imageFile, 4, 4, 3)
+    if err == nil {
+        t.Fatal("Expected a size error")
+    }
+    if hint := errs.Hint(err); !strings.Contains(hint, "uint8") {
+        t.Errorf("Hint should point at uint8 data, got %q", hint)
+    }
+    
+    if _, err := NewImageLoader(BinaryUint8).LoadImage(imageFile, 4, 4, 3); err != nil {
+        t.Errorf("Loading as uint8 should succeed: %v", err)
+    }
+}
+
+func TestParseImageFormat(t *testing.T) {
+    for name, expected := range map[string]ImageFormat{"": BinaryFloat32, "float32": BinaryFloat32, "UINT8": BinaryUint8} {
+        format, err := ParseImageFormat(name)
+        if err != nil || format != expected {
+            t.Errorf("ParseImageFormat(%q) = %v, %v", name, format, err)
+        }
+    }
+    if _, err := ParseImageFormat("png"); err == nil {
+        t.Error("Expected an error for an unknown format")
+    }
+}
+
+func TestCheckPixelRange(t *testing.T) {
+    fm := tensor.NewFeatureMap(2, 2, 1)
+    copy(fm.Data, []float32{0, 0.25, 0.5, 1})
+    if err := CheckPixelRange(fm); err != nil {
+        t.Errorf("Values in [0, 1] should pass: %v", err)
+    }
+    
+    copy(fm.Data, []float32{0, 64, 128, 255})
+    err := CheckPixelRange(fm)
+    if err == nil || !strings.Contains(errs.Hint(err), "255") {
+        t.Errorf("0-255 values should be reported with a divide-by-255 hint, got %v (%q)", err, errs.Hint(err))
+    }
+    
+    copy(fm.Data, []float32{-1.9, 0, 0.5, 2.1})
+    err = CheckPixelRange(fm)
+    if err == nil || !strings.Contains(errs.Hint(err), "normalization") {
+        t.Errorf("Negative values should be reported with a normalization hint, got %v (%q)", err, errs.Hint(err))
+    }
+}
+
+func TestBatchNormMissingHint(t *testing.T) {
+    tempDir := t.TempDir()
+    bnDir := filepath.Join(tempDir, "batchnorm1")
+    if err := os.MkdirAll(bnDir, 0755); err != nil {
+        t.Fatal(err)
+    }
+    for _, name := range []string{"moving_mean", "moving_variance", "gamma"} {
+        if err := os.WriteFile(filepath.Join(bnDir, "bn1_"+name+".bin"), make([]byte, 4*4), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    
+    _, err := NewWeightLoader(tempDir).LoadBatchNormParams("batchnorm1/bn1", 4)
+    if err == nil {
+        t.Fatal("Expected an error for the missing beta file")
+    }
+    if hint := errs.Hint(err); !strings.Contains(hint, "batchnorm1/bn1_{gamma") {
+        t.Errorf("Hint should name the expected files, got %q", hint)
+    }
+}
+
 func TestLabelLoader(t *testing.T) {
     // Create temporary directory
     tempDir := t.TempDir()
@@ -235,4 +409,991 @@ func BenchmarkLoadImage(b *testing.B) {
             b.Fatalf("Failed to load image: %v", err)
         }
     }
-}
\ No newline at end of file
+}
+
+// rampImage returns a single-channel image whose pixel (h, w) is h*width + w
+func rampImage(height, width int) *tensor.FeatureMap {
+    fm := tensor.NewFeatureMap(height, width, 1)
+    for i := range fm.Data {
+        fm.Data[i] = float32(i)
+    }
+    return fm
+}
+
+func TestResize(t *testing.T) {
+    // A 2x downscale averages each 2x2 block
+    out, err := Resize(2, 2).Apply(rampImage(4, 4))
+    if err != nil {
+        t.Fatal(err)
+    }
+    expected := []float32{2.5, 4.5, 10.5, 12.5}
+    for i, v := range expected {
+        if out.Data[i] != v {
+            t.Errorf("Pixel %d: expected %g, got %g", i, v, out.Data[i])
+        }
+    }
+    
+    // Upscaling keeps the corners and the layout of the input
+    hwc := rampImage(2, 2).ToLayout(tensor.LayoutHWC)
+    out, err = Resize(4, 4).Apply(hwc)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if out.Layout != tensor.LayoutHWC || out.GetUnsafe(0, 0, 0) != 0 || out.GetUnsafe(0, 3, 3) != 3 {
+        t.Errorf("Unexpected upscale %v (layout %s)", out.Data, out.Layout)
+    }
+    
+    if _, err := Resize(0, 4).Apply(hwc); err == nil {
+        t.Error("Expected an error for an empty target size")
+    }
+}
+
+func TestCenterCrop(t *testing.T) {
+    out, err := CenterCrop(2, 2).Apply(rampImage(4, 4))
+    if err != nil {
+        t.Fatal(err)
+    }
+    expected := []float32{5, 6, 9, 10}
+    for i, v := range expected {
+        if out.Data[i] != v {
+            t.Errorf("Pixel %d: expected %g, got %g", i, v, out.Data[i])
+        }
+    }
+    
+    if _, err := CenterCrop(5, 4).Apply(rampImage(4, 4)); err == nil {
+        t.Error("Expected an error for a crop larger than the image")
+    }
+}
+
+func TestLetterbox(t *testing.T) {
+    // A 2x4 image fits a 4x4 canvas as-is, centered with a row of padding above and below
+    out, err := Letterbox(4, 4, -1).Apply(rampImage(2, 4))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for w := 0; w < 4; w++ {
+        if out.GetUnsafe(0, 0, w) != -1 || out.GetUnsafe(0, 3, w) != -1 {
+            t.Errorf("Column %d: expected padding in the first and last rows", w)
+        }
+        if out.GetUnsafe(0, 1, w) != float32(w) || out.GetUnsafe(0, 2, w) != float32(4+w) {
+            t.Errorf("Column %d: image rows were not copied unchanged", w)
+        }
+    }
+}
+
+func TestNormalizeStep(t *testing.T) {
+    fm := tensor.NewFeatureMap(1, 2, 2)
+    copy(fm.Data, []float32{0.5, 1, 0.25, 0.75})
+    out, err := Normalize([]float32{0.5, 0.25}, []float32{0.5, 0.25}).Apply(fm)
+    if err != nil {
+        t.Fatal(err)
+    }
+    expected := []float32{0, 1, 0, 2}
+    for i, v := range expected {
+        if out.Data[i] != v {
+            t.Errorf("Value %d: expected %g, got %g", i, v, out.Data[i])
+        }
+    }
+    if fm.Data[0] != 0.5 {
+        t.Error("Normalize modified its input")
+    }
+    
+    if _, err := Normalize([]float32{0}, []float32{1}).Apply(fm); err == nil {
+        t.Error("Expected an error for a mean/std count that doesn't match the channels")
+    }
+}
+
+func TestPreprocessConfigPipeline(t *testing.T) {
+    cases := []struct {
+        resize   string
+        expected string
+    }{
+        {"", "resize(32x32) -> normalize(mean [0.5], std [0.25])"},
+        {"center-crop", "resize(32x48) -> center_crop(32x32) -> normalize(mean [0.5], std [0.25])"},
+        {"letterbox", "letterbox(32x32, fill 0) -> normalize(mean [0.5], std [0.25])"},
+    }
+    for _, tc := range cases {
+        pc, err := PreprocessConfigFromData(config.DataConfig{
+            Resize: tc.resize, Normalize: true, MeanValues: []float32{0.5}, StdValues: []float32{0.25},
+        })
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := pc.Pipeline(40, 60, 32, 32).String(); got != tc.expected {
+            t.Errorf("Resize %q: expected %s, got %s", tc.resize, tc.expected, got)
+        }
+    }
+    
+    if got := (PreprocessConfig{}).Pipeline(32, 32, 32, 32).String(); got != "none" {
+        t.Errorf("Expected an empty pipeline for matching sizes, got %s", got)
+    }
+    if _, err := ParseResizeMode("squash"); err == nil {
+        t.Error("Expected an error for an unknown resize mode")
+    }
+}
+
+func TestPreprocessor(t *testing.T) {
+    tempDir := t.TempDir()
+    stored := tensor.NewFeatureMap(8, 8, 3)
+    stored.Fill(0.5)
+    path := filepath.Join(tempDir, "image.bin")
+    if err := NewImageLoader(BinaryFloat32).SaveImage(stored, path); err != nil {
+        t.Fatal(err)
+    }
+    
+    dc := config.DataConfig{ImageHeight: 8, ImageWidth: 8, Resize: "center-crop"}
+    mc := config.ModelConfig{InputHeight: 4, InputWidth: 4, InputChannels: 3}
+    preprocessor, err := NewPreprocessor(BinaryFloat32, dc, mc)
+    if err != nil {
+        t.Fatal(err)
+    }
+    fm, err := preprocessor.Load(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if fm.Height != 4 || fm.Width != 4 || fm.Channels != 3 || fm.Data[0] != 0.5 {
+        t.Errorf("Unexpected preprocessed image %s", fm)
+    }
+    
+    // The pixel range is checked on the stored image, before normalization
+    stored.Fill(255)
+    NewImageLoader(BinaryFloat32).SaveImage(stored, path)
+    if _, err := preprocessor.Load(path); err == nil {
+        t.Error("Expected an error for 0-255 pixels")
+    }
+}
+
+// writeTestPNG writes a width×height PNG filled with c
+func writeTestPNG(t *testing.T, path string, width, height int, c color.RGBA) {
+    img := image.NewRGBA(image.Rect(0, 0, width, height))
+    for y := 0; y < height; y++ {
+        for x := 0; x < width; x++ {
+            img.SetRGBA(x, y, c)
+        }
+    }
+    
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        t.Fatal(err)
+    }
+    file, err := os.Create(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer file.Close()
+    if err := png.Encode(file, img); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestDecodeImageFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "red.png")
+    writeTestPNG(t, path, 3, 2, color.RGBA{R: 255, G: 51, B: 0, A: 255})
+    
+    fm, err := DecodeImageFile(path, 3)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if fm.Height != 2 || fm.Width != 3 || fm.Channels != 3 {
+        t.Fatalf("Unexpected shape %s", fm)
+    }
+    if fm.Get(0, 1, 2) != 1 || fm.Get(1, 1, 2) != 0.2 || fm.Get(2, 1, 2) != 0 {
+        t.Errorf("Expected RGB (1, 0.2, 0), got (%g, %g, %g)", fm.Get(0, 1, 2), fm.Get(1, 1, 2), fm.Get(2, 1, 2))
+    }
+    
+    gray, err := DecodeImageFile(path, 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if v := gray.Get(0, 0, 0); v < 0.41 || v > 0.42 {
+        t.Errorf("Expected luminance 0.299 + 0.587*0.2, got %g", v)
+    }
+    
+    if _, err := DecodeImageFile(path, 4); err == nil {
+        t.Error("Expected an error for 4 channels")
+    }
+}
+
+func TestPreprocessorDecode(t *testing.T) {
+    dir := t.TempDir()
+    pngPath := filepath.Join(dir, "red.png")
+    binPath := filepath.Join(dir, "image.bin")
+    writeTestPNG(t, pngPath, 4, 4, color.RGBA{R: 255, G: 51, B: 0, A: 255})
+    var pixels []byte
+    for i := 0; i < 4*4*3; i++ {
+        pixels = binary.LittleEndian.AppendUint32(pixels, math.Float32bits(float32(i)/48))
+    }
+    if err := os.WriteFile(binPath, pixels, 0644); err != nil {
+        t.Fatal(err)
+    }
+    
+    mc := config.ModelConfig{InputHeight: 4, InputWidth: 4, InputChannels: 3}
+    preprocessor, err := NewPreprocessor(BinaryFloat32, config.DataConfig{}, mc)
+    if err != nil {
+        t.Fatal(err)
+    }
+    
+    // Bytes in memory decode exactly as the same bytes in a file
+    for _, path := range []string{pngPath, binPath} {
+        raw, err := os.ReadFile(path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        expected, err := preprocessor.Load(path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        decoded, err := preprocessor.Decode(raw)
+        if err != nil {
+            t.Fatalf("Decode of %s failed: %v", filepath.Base(path), err)
+        }
+        for i := range expected.Data {
+            if decoded.Data[i] != expected.Data[i] {
+                t.Fatalf("%s: value %d is %g in memory, %g from the file", filepath.Base(path), i, decoded.Data[i], expected.Data[i])
+            }
+        }
+    }
+    
+    if _, err := preprocessor.Decode([]byte{1, 2, 3}); err == nil || !strings.Contains(err.Error(), "image data has wrong size") {
+        t.Errorf("Expected a size error for 3 bytes, got %v", err)
+    }
+}
+
+func TestScanImageFolder(t *testing.T) {
+    root := t.TempDir()
+    classNames := []string{"cat", "dog", "frog"}
+    writeTestPNG(t, filepath.Join(root, "cat", "b.png"), 2, 2, color.RGBA{A: 255})
+    writeTestPNG(t, filepath.Join(root, "cat", "a.png"), 2, 2, color.RGBA{A: 255})
+    writeTestPNG(t, filepath.Join(root, "Frog", "a.png"), 2, 2, color.RGBA{A: 255})
+    writeTestPNG(t, filepath.Join(root, ".thumbnails", "a.png"), 2, 2, color.RGBA{A: 255})
+    os.WriteFile(filepath.Join(root, "cat", "notes.txt"), []byte("skip"), 0644)
+    
+    samples, err := ScanImageFolder(root, classNames)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Round-robin across classes, by name within a class
+    expected := []FolderSample{
+        {filepath.Join(root, "cat", "a.png"), 0},
+        {filepath.Join(root, "Frog", "a.png"), 2},
+        {filepath.Join(root, "cat", "b.png"), 0},
+    }
+    if len(samples) != len(expected) {
+        t.Fatalf("Expected %d samples, got %v", len(expected), samples)
+    }
+    for i, sample := range samples {
+        if sample != expected[i] {
+            t.Errorf("Sample %d: expected %v, got %v", i, expected[i], sample)
+        }
+    }
+    
+    writeTestPNG(t, filepath.Join(root, "zebra", "a.png"), 2, 2, color.RGBA{A: 255})
+    _, err = ScanImageFolder(root, classNames)
+    if err == nil || !strings.Contains(errs.Hint(err), "cat, dog, frog") {
+        t.Errorf("Expected an unknown class error listing the class names, got %v", err)
+    }
+    
+    if _, err := ScanImageFolder(t.TempDir(), classNames); err == nil {
+        t.Error("Expected an error for a dataset without images")
+    }
+}
+
+func TestLoadImageFolder(t *testing.T) {
+    root := t.TempDir()
+    classNames := []string{"dark", "light"}
+    writeTestPNG(t, filepath.Join(root, "dark", "a.png"), 8, 8, color.RGBA{A: 255})
+    writeTestPNG(t, filepath.Join(root, "dark", "b.png"), 8, 8, color.RGBA{A: 255})
+    writeTestPNG(t, filepath.Join(root, "light", "a.png"), 16, 12, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+    
+    mc := config.ModelConfig{InputHeight: 4, InputWidth: 4, InputChannels: 3}
+    preprocessor, err := NewPreprocessor(BinaryFloat32, config.DataConfig{Resize: "letterbox"}, mc)
+    if err != nil {
+        t.Fatal(err)
+    }
+    
+    batch, samples, err := LoadImageFolder(root, classNames, 2, preprocessor)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if batch.Size != 2 || len(samples) != 2 {
+        t.Fatalf("Expected the limit of 2 samples, got %d", batch.Size)
+    }
+    if ConvertOneHotToClassIndex(batch.Labels[0]) != 0 || ConvertOneHotToClassIndex(batch.Labels[1]) != 1 {
+        t.Errorf("Unexpected labels %v", batch.Labels)
+    }
+    for i, image := range batch.Images {
+        if image.Height != 4 || image.Width != 4 || image.Channels != 3 {
+            t.Errorf("Image %d was not brought to the input size: %s", i, image)
+        }
+    }
+    // The 16 wide, 12 high image is letterboxed to 4×3: three white rows, then a row of padding
+    if light := batch.Images[1]; light.Get(0, 0, 0) != 1 || light.Get(0, 3, 0) != 0 {
+        t.Errorf("Unexpected letterbox of the light image: %v", light.Data[:16])
+    }
+}
+
+func TestLoadLabelManifest(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "labels.csv")
+    manifest := "filename,label\nimg_0.bin,2\n# skipped\nsub/img_1.png, Cat\n"
+    if err := os.WriteFile(path, []byte(manifest), 0644); err != nil {
+        t.Fatal(err)
+    }
+    
+    loader := NewLabelLoader(CSVManifest)
+    if _, err := loader.LoadLabelManifest(path, 3); err == nil {
+        t.Error("Expected class names to be rejected without SetClassNames")
+    }
+    
+    loader.SetClassNames([]string{"bird", "cat", "dog"})
+    entries, err := loader.LoadLabelManifest(path, 3)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(entries) != 2 || entries[0].Filename != "img_0.bin" || entries[1].Filename != "sub/img_1.png" {
+        t.Fatalf("Unexpected entries %v", entries)
+    }
+    if ConvertOneHotToClassIndex(entries[0].Label) != 2 || ConvertOneHotToClassIndex(entries[1].Label) != 1 {
+        t.Errorf("Unexpected labels %v, %v", entries[0].Label, entries[1].Label)
+    }
+    
+    invalid := map[string]string{
+        "out of range": "img_0.bin,3\n",
+        "duplicate":    "img_0.bin,1\nimg_0.bin,2\n",
+        "unknown name": "img_0.bin,zebra\n",
+        "extra field":  "img_0.bin,1,2\n",
+        "empty":        "filename,label\n",
+    }
+    for name, manifest := range invalid {
+        if err := os.WriteFile(path, []byte(manifest), 0644); err != nil {
+            t.Fatal(err)
+        }
+        if _, err := loader.LoadLabelManifest(path, 3); err == nil {
+            t.Errorf("%s: expected an error", name)
+        }
+    }
+}
+
+func TestDataManagerManifest(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestImageFile(t, filepath.Join(tempDir, "a.bin"), 4, 4, 3)
+    writeTestPNG(t, filepath.Join(tempDir, "png", "b.png"), 6, 5, color.RGBA{G: 255, A: 255})
+    manifest := filepath.Join(tempDir, "labels.csv")
+    if err := os.WriteFile(manifest, []byte("png/b.png,0\na.bin,1\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    
+    dm := NewDataManager("", BinaryFloat32, CSVManifest)
+    batch, err := dm.LoadTestBatch(tempDir, manifest, 10, 4, 4, 3, 2)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if batch.Size != 2 || len(batch.Images) != 2 {
+        t.Fatalf("Expected both manifest rows, got %d", batch.Size)
+    }
+    if png := batch.Images[0]; png.Height != 5 || png.Width != 6 || png.Get(1, 0, 0) != 1 {
+        t.Errorf("PNG rows should be decoded at their own size, got %s", png)
+    }
+    if ConvertOneHotToClassIndex(batch.Labels[0]) != 0 || ConvertOneHotToClassIndex(batch.Labels[1]) != 1 {
+        t.Errorf("Unexpected labels %v", batch.Labels)
+    }
+    
+    // The batch size limits the rows read
+    batch, err = dm.LoadTestBatch(tempDir, manifest, 1, 4, 4, 3, 2)
+    if err != nil || batch.Size != 1 {
+        t.Errorf("Expected one row, got %v (%v)", batch, err)
+    }
+    
+    if err := os.WriteFile(manifest, []byte("missing.bin,0\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := dm.LoadTestBatch(tempDir, manifest, 10, 4, 4, 3, 2); err == nil {
+        t.Error("Expected an error for an image missing from the directory")
+    }
+}
+
+// protoField appends a length-delimited protobuf field
+func protoField(buf []byte, field int, value []byte) []byte {
+    buf = binary.AppendUvarint(buf, uint64(field<<3|2))
+    buf = binary.AppendUvarint(buf, uint64(len(value)))
+    return append(buf, value...)
+}
+
+// tfExample serializes a tf.train.Example with the given features
+func tfExample(features map[string][]byte) []byte {
+    var entries []byte
+    for name, feature := range features {
+        entry := protoField(nil, 1, []byte(name))
+        entry = protoField(entry, 2, feature)
+        entries = protoField(entries, 1, entry)
+    }
+    return protoField(nil, 1, entries)
+}
+
+// tfBytesFeature and tfInt64Feature serialize single-value Feature messages
+func tfBytesFeature(value []byte) []byte {
+    return protoField(nil, 1, protoField(nil, 1, value))
+}
+
+func tfInt64Feature(value int64) []byte {
+    return protoField(nil, 3, protoField(nil, 1, binary.AppendUvarint(nil, uint64(value))))
+}
+
+// appendTFRecord frames record as TFRecord
+func appendTFRecord(buf, record []byte) []byte {
+    length := binary.LittleEndian.AppendUint64(nil, uint64(len(record)))
+    buf = append(buf, length...)
+    buf = binary.LittleEndian.AppendUint32(buf, maskedCRC(length))
+    buf = append(buf, record...)
+    return binary.LittleEndian.AppendUint32(buf, maskedCRC(record))
+}
+
+func TestParseTFExample(t *testing.T) {
+    // An unpacked float list (wire type 5) next to a packed int64 list
+    floats := binary.AppendUvarint(nil, 1<<3|5)
+    floats = binary.LittleEndian.AppendUint32(floats, 0x3fc00000)
+    int64s := protoField(nil, 1, binary.AppendUvarint(binary.AppendUvarint(nil, 7), 300))
+    
+    record := tfExample(map[string][]byte{
+        "scale": protoField(nil, 2, floats),
+        "ids":   protoField(nil, 3, int64s),
+        "image": tfBytesFeature([]byte("abc")),
+    })
+    features, err := ParseTFExample(record)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if f := features["scale"].Floats; len(f) != 1 || f[0] != 1.5 {
+        t.Errorf("Expected float feature [1.5], got %v", f)
+    }
+    if ids := features["ids"].Int64s; len(ids) != 2 || ids[0] != 7 || ids[1] != 300 {
+        t.Errorf("Expected int64 feature [7 300], got %v", ids)
+    }
+    if b := features["image"].Bytes; len(b) != 1 || string(b[0]) != "abc" {
+        t.Errorf("Expected bytes feature abc, got %q", b)
+    }
+    
+    if _, err := ParseTFExample(record[:len(record)-2]); err == nil {
+        t.Error("Expected an error for a truncated Example")
+    }
+}
+
+func TestTFRecordReader(t *testing.T) {
+    stream := appendTFRecord(nil, []byte("first"))
+    stream = appendTFRecord(stream, []byte{})
+    
+    reader := NewTFRecordReader(bytes.NewReader(stream))
+    for _, want := range []string{"first", ""} {
+        record, err := reader.Next()
+        if err != nil || string(record) != want {
+            t.Fatalf("Expected record %q, got %q (%v)", want, record, err)
+        }
+    }
+    if _, err := reader.Next(); err != io.EOF {
+        t.Errorf("Expected io.EOF after the last record, got %v", err)
+    }
+    
+    corrupt := append([]byte(nil), stream...)
+    corrupt[14] ^= 1
+    if _, err := NewTFRecordReader(bytes.NewReader(corrupt)).Next(); err == nil ||
+        !strings.Contains(err.Error(), "checksum") {
+        t.Errorf("Expected a checksum error, got %v", err)
+    }
+    if _, err := NewTFRecordReader(bytes.NewReader(stream[:20])).Next(); err == nil {
+        t.Error("Expected an error for a truncated record")
+    }
+}
+
+func TestLoadTFRecord(t *testing.T) {
+    tempDir := t.TempDir()
+    
+    // Record 0: raw 2×2×3 HWC pixels; record 1: a PNG of another size
+    raw := make([]byte, 12)
+    for i := range raw {
+        raw[i] = byte(i * 20)
+    }
+    pngPath := filepath.Join(tempDir, "blue.png")
+    writeTestPNG(t, pngPath, 3, 5, color.RGBA{B: 255, A: 255})
+    encoded, err := os.ReadFile(pngPath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    
+    var stream []byte
+    stream = appendTFRecord(stream, tfExample(map[string][]byte{
+        "image": tfBytesFeature(raw), "label": tfInt64Feature(3),
+    }))
+    stream = appendTFRecord(stream, tfExample(map[string][]byte{
+        "image": tfBytesFeature(encoded), "label": tfInt64Feature(1), "id": tfBytesFeature([]byte("x")),
+    }))
+    path := filepath.Join(tempDir, "test.tfrecord")
+    if err := os.WriteFile(path, stream, 0644); err != nil {
+        t.Fatal(err)
+    }
+    
+    batch, err := LoadTFRecord(path, CIFAR10TFRecordSchema, 0, 2, 2, 3, 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if batch.Size != 2 {
+        t.Fatalf("Expected 2 records, got %d", batch.Size)
+    }
+    // HWC byte (h=1, w=0, c=2) is index 8
+    if got := batch.Images[0].Get(2, 1, 0); got != float32(160)/255 {
+        t.Errorf("Expected raw pixel 160/255, got %f", got)
+    }
+    if png := batch.Images[1]; png.Height != 5 || png.Width != 3 || png.Get(2, 0, 0) != 1 {
+        t.Errorf("PNG records should be decoded at their own size, got %s", png)
+    }
+    if ConvertOneHotToClassIndex(batch.Labels[0]) != 3 || ConvertOneHotToClassIndex(batch.Labels[1]) != 1 {
+        t.Errorf("Unexpected labels %v", batch.Labels)
+    }
+    
+    // The limit stops reading early, and gzipped files are read transparently
+    var gz bytes.Buffer
+    writer := gzip.NewWriter(&gz)
+    writer.Write(stream)
+    writer.Close()
+    gzPath := path + ".gz"
+    if err := os.WriteFile(gzPath, gz.Bytes(), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if !IsTFRecordFile(gzPath) || !IsTFRecordFile("cifar10-test.tfrecord-00000-of-00001") || IsTFRecordFile(tempDir) {
+        t.Error("IsTFRecordFile misclassified a path")
+    }
+    batch, err = LoadTFRecord(gzPath, CIFAR10TFRecordSchema, 1, 2, 2, 3, 10)
+    if err != nil || batch.Size != 1 {
+        t.Errorf("Expected one record from the gzipped file, got %v (%v)", batch, err)
+    }
+    
+    // A schema naming missing features lists the ones present
+    _, err = LoadTFRecord(path, TFRecordSchema{ImageKey: "image/encoded", LabelKey: "label"}, 0, 2, 2, 3, 10)
+    if err == nil || !strings.Contains(errs.Hint(err), "image, label") {
+        t.Errorf("Expected a hint listing the features, got %v", err)
+    }
+    _, err = LoadTFRecord(path, CIFAR10TFRecordSchema, 0, 2, 2, 3, 2)
+    if err == nil || !strings.Contains(err.Error(), "out of range") {
+        t.Errorf("Expected an out of range label error, got %v", err)
+    }
+}
+
+func TestLoadCIFAR10(t *testing.T) {
+    tempDir := t.TempDir()
+    
+    // Two files of 2 and 1 records; pixel values encode channel, row and column
+    record := func(label byte) []byte {
+        r := make([]byte, CIFAR10RecordSize)
+        r[0] = label
+        for c := 0; c < 3; c++ {
+            for h := 0; h < 32; h++ {
+                r[1+(c*32+h)*32+h%32] = byte(c*80 + h)
+            }
+        }
+        return r
+    }
+    first := filepath.Join(tempDir, "data_batch_1.bin")
+    second := filepath.Join(tempDir, "data_batch_2.bin")
+    if err := os.WriteFile(first, append(record(3), record(9)...), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(second, record(0), 0644); err != nil {
+        t.Fatal(err)
+    }
+    
+    set, err := LoadCIFAR10(first, second)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if set.Len() != 3 || set.Label(0) != 3 || set.Label(1) != 9 || set.Label(2) != 0 {
+        t.Fatalf("Unexpected records: %d, labels %d %d %d", set.Len(), set.Label(0), set.Label(1), set.Label(2))
+    }
+    // Channel 2, row 5 holds 165 on the diagonal
+    if got := set.Image(1).Get(2, 5, 5); got != float32(165)/255 {
+        t.Errorf("Expected pixel 165/255, got %f", got)
+    }
+    if set.Source(1) != first || set.Source(2) != second {
+        t.Errorf("Unexpected sources %s, %s", set.Source(1), set.Source(2))
+    }
+    if !IsCIFAR10BatchFile(first) || !IsCIFAR10BatchFile("test_batch.bin") || IsCIFAR10BatchFile("batches.meta.txt") {
+        t.Error("IsCIFAR10BatchFile misclassified a path")
+    }
+    
+    batch, err := Collect(set.Iterator(2))
+    if err != nil || batch.Size != 2 || ConvertOneHotToClassIndex(batch.Labels[1]) != 9 {
+        t.Errorf("Expected 2 samples from the iterator, got %v (%v)", batch, err)
+    }
+    
+    // Truncated files and out of range labels are rejected
+    if err := os.WriteFile(second, record(0)[:100], 0644); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := LoadCIFAR10(second); err == nil || errs.Hint(err) == "" {
+        t.Errorf("Expected a hinted error for a truncated file, got %v", err)
+    }
+    if err := os.WriteFile(second, record(10), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := LoadCIFAR10(second); err == nil || !strings.Contains(err.Error(), "out of range") {
+        t.Errorf("Expected an out of range label error, got %v", err)
+    }
+}
+
+// labeledImages is a LabeledSet of 1x1 images whose pixel and label are their index
+type labeledImages int
+
+func (s labeledImages) Len() int            { return int(s) }
+func (s labeledImages) Label(i int) int     { return i % 10 }
+func (s labeledImages) Source(i int) string { return "memory" }
+func (s labeledImages) Image(i int) *tensor.FeatureMap {
+    image := tensor.NewFeatureMap(1, 1, 1)
+    image.Fill(float32(i) / 100)
+    return image
+}
+
+func TestTrainingLoader(t *testing.T) {
+    mc := config.ModelConfig{InputHeight: 1, InputWidth: 1, InputChannels: 1}
+    preprocessor, err := NewPreprocessor(BinaryFloat32, config.DataConfig{}, mc)
+    if err != nil {
+        t.Fatal(err)
+    }
+    opts := TrainingLoaderOptions{BatchSize: 4, Classes: 10, Limit: 18, Seed: 7}
+    loader, err := NewTrainingLoader(labeledImages(20), preprocessor, opts)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if loader.Size() != 18 || loader.StepsPerEpoch() != 4 {
+        t.Fatalf("Expected 18 images in 4 steps, got %d in %d", loader.Size(), loader.StepsPerEpoch())
+    }
+
+    // The order is a permutation of the first Limit images, repeated for the same seed and epoch
+    first := slices.Clone(loader.Order(0))
+    sorted := slices.Sorted(slices.Values(first))
+    for i, index := range sorted {
+        if index != i {
+            t.Fatalf("Expected a permutation of 0-17, got %v", first)
+        }
+    }
+    again, _ := NewTrainingLoader(labeledImages(20), preprocessor, opts)
+    if !slices.Equal(again.Order(0), first) {
+        t.Error("Expected the same order for the same seed and epoch")
+    }
+    if slices.Equal(loader.Order(1), first) {
+        t.Error("Expected a new order every epoch")
+    }
+
+    // A batch holds the images of its slice of the order, with their labels
+    images, labels, err := loader.Batch(0, 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for b, image := range images {
+        index := first[4+b]
+        if image.Data[0] != float32(index)/100 || ConvertOneHotToClassIndex(labels[b]) != index%10 {
+            t.Errorf("Batch image %d: expected image %d, got pixel %f and label %v", b, index, image.Data[0], labels[b])
+        }
+    }
+    if _, _, err := loader.Batch(0, 4); err == nil {
+        t.Error("Expected an error for a step past the epoch")
+    }
+
+    if _, err := NewTrainingLoader(labeledImages(3), preprocessor, opts); err == nil {
+        t.Error("Expected an error for fewer images than a batch")
+    }
+    opts.Classes = 5
+    loader, _ = NewTrainingLoader(labeledImages(20), preprocessor, opts)
+    // Labels 5-9 turn up somewhere in the epoch
+    for step := 0; step < loader.StepsPerEpoch() && err == nil; step++ {
+        _, _, err = loader.Batch(0, step)
+    }
+    if err == nil || !strings.Contains(err.Error(), "outside the 5 classes") {
+        t.Errorf("Expected an error for labels outside the classes, got %v", err)
+    }
+}
+
+func TestSaveModelWeights(t *testing.T) {
+    // Weights of the TinyCNN shapes LoadModelWeights reads, with distinct values
+    shapes := [][3]int{{3, 3, 32}, {3, 32, 32}, {3, 32, 64}, {3, 64, 64}, {3, 64, 128}, {3, 128, 128}, {1, 128, 10}}
+    weights := &ModelWeights{}
+    for i, shape := range shapes {
+        kernel := tensor.NewKernel(shape[0], shape[1], shape[2])
+        for j := range kernel.Weights {
+            kernel.Weights[j] = float32(j%97) / 97
+        }
+        weights.Kernels = append(weights.Kernels, kernel)
+        bias := make([]float32, shape[2])
+        bias[0] = float32(i)
+        weights.Biases = append(weights.Biases, bias)
+        if i < len(shapes)-1 {
+            bn := &BatchNormParams{Mean: make([]float32, shape[2]), Variance: make([]float32, shape[2]),
+                Scale: make([]float32, shape[2]), Shift: make([]float32, shape[2]), Epsilon: 1e-5}
+            bn.Mean[1], bn.Variance[1], bn.Scale[1], bn.Shift[1] = 1, 2, 3, 4
+            weights.BatchNorms = append(weights.BatchNorms, bn)
+        }
+    }
+    
+    dir := t.TempDir()
+    if err := SaveModelWeights(dir, weights); err != nil {
+        t.Fatalf("SaveModelWeights failed: %v", err)
+    }
+    loaded, err := NewDataManager(dir, BinaryFloat32, OneHotText).LoadModelWeights()
+    if err != nil {
+        t.Fatalf("Loading the saved weights failed: %v", err)
+    }
+    for i := range shapes {
+        for j, w := range weights.Kernels[i].Weights {
+            if loaded.Kernels[i].Weights[j] != w {
+                t.Fatalf("conv%d kernel[%d]: saved %f, loaded %f", i+1, j, w, loaded.Kernels[i].Weights[j])
+            }
+        }
+        if loaded.Biases[i][0] != float32(i) {
+            t.Errorf("conv%d bias: expected %d, got %f", i+1, i, loaded.Biases[i][0])
+        }
+    }
+    if bn := loaded.BatchNorms[5]; bn.Mean[1] != 1 || bn.Variance[1] != 2 || bn.Scale[1] != 3 || bn.Shift[1] != 4 {
+        t.Errorf("Unexpected batch norm after loading: %+v", bn)
+    }
+}
+
+func TestDataManagerTestIterator(t *testing.T) {
+    tempDir := t.TempDir()
+    for i := 0; i < 3; i++ {
+        createTestImageFile(t, filepath.Join(tempDir, fmt.Sprintf("test_img_%d.bin", i)), 4, 4, 3)
+        createTestLabelFile(t, filepath.Join(tempDir, fmt.Sprintf("label_test_%d.txt", i)), i, 10)
+    }
+    
+    dm := NewDataManager("", BinaryFloat32, OneHotText)
+    it, err := dm.TestIterator(tempDir, tempDir, 3, 4, 4, 3, 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 3; i++ {
+        image, label, err := it.Next()
+        if err != nil {
+            t.Fatalf("Sample %d: %v", i, err)
+        }
+        if image.Height != 4 || ConvertOneHotToClassIndex(label) != i {
+            t.Errorf("Sample %d: unexpected image %s or label %v", i, image, label)
+        }
+        if want := filepath.Join(tempDir, fmt.Sprintf("test_img_%d.bin", i)); SourcePath(it, i) != want {
+            t.Errorf("Sample %d: expected source %s, got %q", i, want, SourcePath(it, i))
+        }
+    }
+    if _, _, err := it.Next(); err != io.EOF {
+        t.Errorf("Expected io.EOF after the last sample, got %v", err)
+    }
+    it.Close()
+    
+    // A missing file fails only when the stream reaches it
+    it, err = dm.TestIterator(tempDir, tempDir, 4, 4, 4, 3, 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    batch, err := Collect(it)
+    if err == nil || !strings.Contains(err.Error(), "image 3") {
+        t.Errorf("Expected an error for the fourth image, got %v (%v)", err, batch)
+    }
+    if _, err := dm.TestIterator(tempDir, tempDir, 0, 4, 4, 3, 10); err == nil {
+        t.Error("Expected an error for numbered files without a sample count")
+    }
+}
+
+func TestPreprocessedIterator(t *testing.T) {
+    small := tensor.NewFeatureMap(2, 2, 3)
+    small.Fill(0.25)
+    bright := tensor.NewFeatureMap(2, 2, 3)
+    bright.Fill(255)
+    batch := &DataBatch{
+        Images: []*tensor.FeatureMap{small, bright},
+        Labels: [][]int{ConvertClassIndexToOneHot(1, 10), ConvertClassIndexToOneHot(2, 10)},
+        Size:   2,
+    }
+    
+    mc := config.ModelConfig{InputHeight: 4, InputWidth: 4, InputChannels: 3}
+    preprocessor, err := NewPreprocessor(BinaryFloat32, config.DataConfig{}, mc)
+    if err != nil {
+        t.Fatal(err)
+    }
+    it := Preprocessed(NewBatchIterator(batch), preprocessor)
+    image, label, err := it.Next()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if image.Height != 4 || image.Width != 4 || image.Data[0] != 0.25 || ConvertOneHotToClassIndex(label) != 1 {
+        t.Errorf("Expected the 2x2 image resized to 4x4, got %s", image)
+    }
+    if _, _, err := it.Next(); err == nil || !strings.Contains(err.Error(), "image 1") {
+        t.Errorf("Expected a pixel range error for image 1, got %v", err)
+    }
+}
+
+func TestPrefetcher(t *testing.T) {
+    tempDir := t.TempDir()
+    for i := 0; i < 20; i++ {
+        createTestImageFile(t, filepath.Join(tempDir, fmt.Sprintf("test_img_%d.bin", i)), 2, 2, 1)
+        createTestLabelFile(t, filepath.Join(tempDir, fmt.Sprintf("label_test_%d.txt", i)), i%10, 10)
+    }
+    dm := NewDataManager("", BinaryFloat32, OneHotText)
+    
+    // Samples come out in source order whatever order the workers finish in
+    source, err := dm.TestIterator(tempDir, tempDir, 20, 2, 2, 1, 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    prefetcher := NewPrefetcher(source, 4, 3)
+    batch, err := Collect(prefetcher)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if batch.Size != 20 {
+        t.Fatalf("Expected 20 samples, got %d", batch.Size)
+    }
+    for i, label := range batch.Labels {
+        if ConvertOneHotToClassIndex(label) != i%10 {
+            t.Fatalf("Sample %d out of order: label %d", i, ConvertOneHotToClassIndex(label))
+        }
+    }
+    
+    // A source that can't defer its loading is read ahead as a whole
+    prefetcher = NewPrefetcher(NewBatchIterator(batch), 2, 2)
+    again, err := Collect(prefetcher)
+    if err != nil || again.Size != 20 || again.Images[7] != batch.Images[7] {
+        t.Errorf("Expected the batch back in order, got %v (%v)", again, err)
+    }
+    
+    // The first error ends the stream, after the samples before it
+    source, err = dm.TestIterator(tempDir, tempDir, 25, 2, 2, 1, 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    prefetcher = NewPrefetcher(source, 8, 4)
+    loaded := 0
+    for {
+        _, _, err = prefetcher.Next()
+        if err != nil {
+            break
+        }
+        loaded++
+    }
+    if loaded != 20 || err == io.EOF || !strings.Contains(err.Error(), "image 20") {
+        t.Errorf("Expected 20 samples then the error for image 20, got %d and %v", loaded, err)
+    }
+    if _, _, again := prefetcher.Next(); again != err {
+        t.Errorf("Expected the error to repeat, got %v", again)
+    }
+    prefetcher.Close()
+    
+    // Closing before the stream is read stops the loaders
+    source, _ = dm.TestIterator(tempDir, tempDir, 20, 2, 2, 1, 10)
+    prefetcher = NewPrefetcher(source, 2, 2)
+    prefetcher.Next()
+    if err := prefetcher.Close(); err != nil {
+        t.Error(err)
+    }
+}
+
+func TestTensorCache(t *testing.T) {
+    cache, err := NewTensorCache(filepath.Join(t.TempDir(), "cache"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    
+    fm := tensor.NewFeatureMapWithLayout(2, 3, 2, tensor.LayoutHWC)
+    for i := range fm.Data {
+        fm.Data[i] = float32(i) / 7
+    }
+    key := cache.Key("settings", []byte("image bytes"))
+    if key == cache.Key("other settings", []byte("image bytes")) || key == cache.Key("settings", []byte("image byte")) {
+        t.Error("Keys should depend on both the settings and the content")
+    }
+    
+    if _, _, ok := cache.Get(key); ok {
+        t.Error("Expected a miss on an empty cache")
+    }
+    if err := cache.Put(key, fm, []int{0, 1, 0}); err != nil {
+        t.Fatal(err)
+    }
+    got, label, ok := cache.Get(key)
+    if !ok {
+        t.Fatal("Expected a hit after Put")
+    }
+    if got.Height != 2 || got.Width != 3 || got.Channels != 2 || got.Layout != tensor.LayoutHWC {
+        t.Errorf("Unexpected cached shape %s", got)
+    }
+    for i := range fm.Data {
+        if got.Data[i] != fm.Data[i] {
+            t.Fatalf("Cached value %d is %f, expected %f", i, got.Data[i], fm.Data[i])
+        }
+    }
+    if ConvertOneHotToClassIndex(label) != 1 || len(label) != 3 {
+        t.Errorf("Unexpected cached label %v", label)
+    }
+    
+    // A truncated entry is a miss, not an error
+    if err := os.WriteFile(cache.path(key), []byte("GTC1"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if _, _, ok := cache.Get(key); ok {
+        t.Error("Expected a miss for a truncated entry")
+    }
+    if hits, misses := cache.Stats(); hits != 1 || misses != 2 {
+        t.Errorf("Expected 1 hit and 2 misses, got %d and %d", hits, misses)
+    }
+}
+
+func TestPreprocessorCache(t *testing.T) {
+    tempDir := t.TempDir()
+    root := filepath.Join(tempDir, "dataset")
+    writeTestPNG(t, filepath.Join(root, "cat", "a.png"), 8, 8, color.RGBA{R: 255, A: 255})
+    writeTestPNG(t, filepath.Join(root, "dog", "a.png"), 6, 4, color.RGBA{G: 255, A: 255})
+    classes := []string{"cat", "dog"}
+    
+    cache, err := NewTensorCache(filepath.Join(tempDir, "cache"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    mc := config.ModelConfig{InputHeight: 4, InputWidth: 4, InputChannels: 3}
+    preprocessor, err := NewPreprocessor(BinaryFloat32, config.DataConfig{}, mc)
+    if err != nil {
+        t.Fatal(err)
+    }
+    preprocessor.SetCache(cache)
+    
+    load := func() *DataBatch {
+        it, err := NewImageFolderIterator(root, classes, 0, preprocessor)
+        if err != nil {
+            t.Fatal(err)
+        }
+        batch, err := Collect(it)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return batch
+    }
+    
+    first := load()
+    second := load()
+    if hits, misses := cache.Stats(); hits != 2 || misses != 2 {
+        t.Fatalf("Expected the second run to hit, got %d hits and %d misses", hits, misses)
+    }
+    for i := range first.Images {
+        if second.Images[i].Height != 4 || second.Images[i].Data[0] != first.Images[i].Data[0] ||
+            ConvertOneHotToClassIndex(second.Labels[i]) != ConvertOneHotToClassIndex(first.Labels[i]) {
+            t.Errorf("Cached sample %d differs from the loaded one", i)
+        }
+    }
+    
+    // A changed image is a new entry
+    writeTestPNG(t, filepath.Join(root, "cat", "a.png"), 8, 8, color.RGBA{B: 255, A: 255})
+    changed := load()
+    if hits, misses := cache.Stats(); hits != 3 || misses != 3 {
+        t.Errorf("Expected only the changed image to miss, got %d hits and %d misses", hits, misses)
+    }
+    if changed.Images[0].Get(2, 0, 0) != 1 {
+        t.Error("Changed image was served from the cache")
+    }
+    
+    // Augmented samples bypass the cache
+    preprocessor.SetAugmentation(Normalize([]float32{0, 0, 0}, []float32{1, 1, 1}))
+    load()
+    if hits, misses := cache.Stats(); hits != 3 || misses != 3 {
+        t.Errorf("Expected no cache lookups with augmentation, got %d hits and %d misses", hits, misses)
+    }
+}
@@ -0,0 +1,196 @@
+package data
+
+import (
+	"bytes"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/**
+* Directory-as-class datasets
+
+Most image datasets are shipped as one folder per class rather than as
+numbered images with separate label files:
+```
+root/
+  airplane/   0001.png 0002.png ...
+  automobile/ 0001.png ...
+  ...
+```
+The label of an image is the index of its folder name in the config's
+class_names. PNG and JPEG files are decoded with the standard library and
+scaled to [0, 1]; they may have any size, as the preprocessing pipeline
+brings them to the model input (see preprocess.go).
+
+Images are listed round-robin across classes (the first image of every
+class, then the second, ...), so evaluating only the first N samples still
+covers every class instead of just the first few folders.
+*/
+
+// FolderSample is one image of a directory-as-class dataset
+type FolderSample struct {
+    Path  string
+    Class int
+}
+
+// IsEncodedImage reports whether path names a PNG or JPEG file
+func IsEncodedImage(path string) bool {
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".png", ".jpg", ".jpeg":
+        return true
+    }
+    return false
+}
+
+// DecodeImageFile decodes a PNG or JPEG file into a feature map with values in [0, 1]
+// channels selects RGB (3) or luminance (1).
+func DecodeImageFile(path string, channels int) (*tensor.FeatureMap, error) {
+    if channels != 1 && channels != 3 {
+        return nil, fmt.Errorf("cannot decode %s into %d channels (use 1 or 3)", path, channels)
+    }
+
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open image file %s: %w", path, err)
+    }
+    defer file.Close()
+
+    img, _, err := image.Decode(file)
+    if err != nil {
+        return nil, fmt.Errorf("failed to decode image %s: %w", path, err)
+    }
+    return imageToFeatureMap(img, channels), nil
+}
+
+// IsEncodedImageData reports whether raw starts like a PNG or JPEG file
+func IsEncodedImageData(raw []byte) bool {
+    return bytes.HasPrefix(raw, []byte("\x89PNG\r\n\x1a\n")) || bytes.HasPrefix(raw, []byte{0xff, 0xd8, 0xff})
+}
+
+// DecodeImageData decodes PNG or JPEG data held in memory, as DecodeImageFile does a file
+func DecodeImageData(raw []byte, channels int) (*tensor.FeatureMap, error) {
+    if channels != 1 && channels != 3 {
+        return nil, fmt.Errorf("cannot decode an image into %d channels (use 1 or 3)", channels)
+    }
+
+    img, _, err := image.Decode(bytes.NewReader(raw))
+    if err != nil {
+        return nil, fmt.Errorf("failed to decode image: %w", err)
+    }
+    return imageToFeatureMap(img, channels), nil
+}
+
+// imageToFeatureMap converts a decoded image to a feature map with values in [0, 1]
+func imageToFeatureMap(img image.Image, channels int) *tensor.FeatureMap {
+    bounds := img.Bounds()
+    fm := tensor.NewFeatureMap(bounds.Dy(), bounds.Dx(), channels)
+    for h := 0; h < fm.Height; h++ {
+        for w := 0; w < fm.Width; w++ {
+            r, g, b, _ := img.At(bounds.Min.X+w, bounds.Min.Y+h).RGBA()
+            red, green, blue := float32(r)/0xffff, float32(g)/0xffff, float32(b)/0xffff
+            if channels == 1 {
+                fm.SetUnsafe(0, h, w, 0.299*red+0.587*green+0.114*blue)
+                continue
+            }
+            fm.SetUnsafe(0, h, w, red)
+            fm.SetUnsafe(1, h, w, green)
+            fm.SetUnsafe(2, h, w, blue)
+        }
+    }
+    return fm
+}
+
+// ScanImageFolder lists the images in root/<class name>/ and labels each with the
+// index of its folder in classNames. Folder names match case-insensitively and a
+// folder that names no class is an error; hidden folders and files that are not
+// PNG or JPEG are skipped. Samples are interleaved across classes and sorted by
+// file name within a class.
+func ScanImageFolder(root string, classNames []string) ([]FolderSample, error) {
+    entries, err := os.ReadDir(root)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read dataset directory %s: %w", root, err)
+    }
+
+    classIndex := make(map[string]int, len(classNames))
+    for i, name := range classNames {
+        classIndex[strings.ToLower(name)] = i
+    }
+
+    perClass := make([][]string, len(classNames))
+    for _, entry := range entries {
+        if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+            continue
+        }
+        class, ok := classIndex[strings.ToLower(entry.Name())]
+        if !ok {
+            err := fmt.Errorf("dataset folder %s does not name a class", filepath.Join(root, entry.Name()))
+            return nil, errs.WithHint(err, "folder names must match class_names in the config: %s",
+                strings.Join(classNames, ", "))
+        }
+
+        files, err := os.ReadDir(filepath.Join(root, entry.Name()))
+        if err != nil {
+            return nil, fmt.Errorf("failed to read class directory: %w", err)
+        }
+        for _, file := range files {
+            if !file.IsDir() && IsEncodedImage(file.Name()) {
+                perClass[class] = append(perClass[class], filepath.Join(root, entry.Name(), file.Name()))
+            }
+        }
+        sort.Strings(perClass[class])
+    }
+
+    var samples []FolderSample
+    for round := 0; ; round++ {
+        added := false
+        for class, paths := range perClass {
+            if round < len(paths) {
+                samples = append(samples, FolderSample{Path: paths[round], Class: class})
+                added = true
+            }
+        }
+        if !added {
+            break
+        }
+    }
+
+    if len(samples) == 0 {
+        err := fmt.Errorf("no PNG or JPEG images found in the class folders of %s", root)
+        return nil, errs.WithHint(err, "expected %s/<class name>/*.png, with folders named after class_names", root)
+    }
+    return samples, nil
+}
+
+// LoadImageFolder loads up to limit images (all when limit is 0) of a
+// directory-as-class dataset through preprocessor, with one-hot labels
+func LoadImageFolder(root string, classNames []string, limit int, preprocessor *Preprocessor) (*DataBatch, []FolderSample, error) {
+    samples, err := ScanImageFolder(root, classNames)
+    if err != nil {
+        return nil, nil, err
+    }
+    if limit > 0 && len(samples) > limit {
+        samples = samples[:limit]
+    }
+
+    batch := &DataBatch{
+        Images: make([]*tensor.FeatureMap, len(samples)),
+        Labels: make([][]int, len(samples)),
+        Size:   len(samples),
+    }
+    for i, sample := range samples {
+        batch.Images[i], err = preprocessor.Load(sample.Path)
+        if err != nil {
+            return nil, nil, err
+        }
+        batch.Labels[i] = ConvertClassIndexToOneHot(sample.Class, len(classNames))
+    }
+    return batch, samples, nil
+}
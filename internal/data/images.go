@@ -1,9 +1,12 @@
 package data
 
 import (
+	"bytes"
+	"duchm1606/gocnn/internal/errs"
 	"duchm1606/gocnn/internal/tensor"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -23,6 +26,38 @@ const (
     BinaryUint8                      // 32x32x3 uint8 values (0-255)
 )
 
+// String returns the flag name of the format
+func (f ImageFormat) String() string {
+    switch f {
+    case BinaryFloat32:
+        return "float32"
+    case BinaryUint8:
+        return "uint8"
+    default:
+        return fmt.Sprintf("ImageFormat(%d)", int(f))
+    }
+}
+
+// BytesPerValue returns the size of one stored pixel value
+func (f ImageFormat) BytesPerValue() int {
+    if f == BinaryUint8 {
+        return 1
+    }
+    return 4
+}
+
+// ParseImageFormat converts a flag name such as "uint8" to an ImageFormat
+// An empty name selects float32
+func ParseImageFormat(name string) (ImageFormat, error) {
+    switch strings.ToLower(strings.TrimSpace(name)) {
+    case "", "float32", "f32":
+        return BinaryFloat32, nil
+    case "uint8", "u8":
+        return BinaryUint8, nil
+    }
+    return BinaryFloat32, fmt.Errorf("unknown image format %q (use float32 or uint8)", name)
+}
+
 // NewImageLoader creates a new image loader
 func NewImageLoader(format ImageFormat) *ImageLoader {
     return &ImageLoader{
@@ -45,6 +80,16 @@ func (il *ImageLoader) LoadImage(filename string, height, width, channels int) (
         return nil, fmt.Errorf("failed to get file info for %s: %w", filename, err)
     }
     
+    return il.readImage(file, fileInfo.Size(), "image file "+filename, height, width, channels)
+}
+
+// DecodeImage reads a binary image held in memory, as LoadImage reads it from a file
+func (il *ImageLoader) DecodeImage(raw []byte, height, width, channels int) (*tensor.FeatureMap, error) {
+    return il.readImage(bytes.NewReader(raw), int64(len(raw)), "image data", height, width, channels)
+}
+
+// readImage reads a binary image of size bytes from r; source names it in errors
+func (il *ImageLoader) readImage(r io.Reader, size int64, source string, height, width, channels int) (*tensor.FeatureMap, error) {
     var expectedBytes int64
     switch il.imageFormat {
     case BinaryFloat32:
@@ -55,24 +100,26 @@ func (il *ImageLoader) LoadImage(filename string, height, width, channels int) (
         return nil, fmt.Errorf("unsupported image format: %d", il.imageFormat)
     }
     
-    if fileInfo.Size() != expectedBytes {
-        return nil, fmt.Errorf("image file %s has wrong size: expected %d bytes, got %d bytes", 
-            filename, expectedBytes, fileInfo.Size())
+    if size != expectedBytes {
+        err := fmt.Errorf("%s has wrong size: expected %d bytes, got %d bytes", 
+            source, expectedBytes, size)
+        return nil, errs.WithHint(err, "%s", imageSizeHint(size, height, width, channels))
     }
     
     // Create feature map
     fm := tensor.NewFeatureMap(height, width, channels)
     
     // Load data based on format
+    var err error
     switch il.imageFormat {
     case BinaryFloat32:
-        err = il.loadFloat32Image(file, fm)
+        err = il.loadFloat32Image(r, fm)
     case BinaryUint8:
-        err = il.loadUint8Image(file, fm)
+        err = il.loadUint8Image(r, fm)
     }
     
     if err != nil {
-        return nil, fmt.Errorf("failed to load image data from %s: %w", filename, err)
+        return nil, fmt.Errorf("failed to load image data from %s: %w", source, err)
     }
     
     // Validate loaded image
@@ -84,14 +131,62 @@ func (il *ImageLoader) LoadImage(filename string, height, width, channels int) (
     return fm, nil
 }
 
+// imageSizeHint guesses what a wrongly sized image file holds
+func imageSizeHint(fileBytes int64, height, width, channels int) string {
+    values := int64(height * width * channels)
+    switch fileBytes {
+    case values:
+        return fmt.Sprintf("file is %d bytes — looks like uint8 data; pass -image-format uint8", fileBytes)
+    case values * 4:
+        return fmt.Sprintf("file is %d bytes — looks like float32 data; pass -image-format float32", fileBytes)
+    case values * 8:
+        return fmt.Sprintf("file is %d bytes — looks like float64 data; re-save it as float32 (e.g. numpy .astype(np.float32))", fileBytes)
+    }
+    return fmt.Sprintf("expected a %d×%d×%d image in HWC order (%d values); check input_height, input_width and input_channels "+
+        "in the config, or set data.image_height and data.image_width for images stored at another size",
+        height, width, channels, values)
+}
+
+// CheckPixelRange reports an error if fm holds values outside [0, 1], the range the model was trained on
+// Small overshoots from rounding are tolerated.
+func CheckPixelRange(fm *tensor.FeatureMap) error {
+    const tolerance = 1e-3
+    
+    if len(fm.Data) == 0 {
+        return nil
+    }
+    minValue, maxValue := fm.Data[0], fm.Data[0]
+    for _, v := range fm.Data {
+        if v < minValue {
+            minValue = v
+        }
+        if v > maxValue {
+            maxValue = v
+        }
+    }
+    
+    if minValue >= -tolerance && maxValue <= 1+tolerance {
+        return nil
+    }
+    
+    err := fmt.Errorf("pixel values range from %g to %g, expected [0, 1]", minValue, maxValue)
+    switch {
+    case minValue < -tolerance:
+        return errs.WithHint(err, "negative values suggest mean/std normalization; the model expects raw pixels scaled to [0, 1]")
+    case maxValue <= 255+tolerance:
+        return errs.WithHint(err, "values look like 0-255 pixels; divide by 255 before saving, or save uint8 bytes and pass -image-format uint8")
+    }
+    return errs.WithHint(err, "the image does not look like normalized pixel data; check how it was exported")
+}
+
 // loadFloat32Image loads image data as float32 values
-func (il *ImageLoader) loadFloat32Image(file *os.File, fm *tensor.FeatureMap) error {
+func (il *ImageLoader) loadFloat32Image(r io.Reader, fm *tensor.FeatureMap) error {
     // Read data in HWC order (height, width, channels)
     for h := 0; h < fm.Height; h++ {
         for w := 0; w < fm.Width; w++ {
             for c := 0; c < fm.Channels; c++ {
                 var pixel float32
-                err := binary.Read(file, il.byteOrder, &pixel)
+                err := binary.Read(r, il.byteOrder, &pixel)
                 if err != nil {
                     return fmt.Errorf("failed to read pixel at (%d,%d,%d): %w", h, w, c, err)
                 }
@@ -105,13 +200,13 @@ func (il *ImageLoader) loadFloat32Image(file *os.File, fm *tensor.FeatureMap) er
 }
 
 // loadUint8Image loads image data as uint8 values and converts to float32
-func (il *ImageLoader) loadUint8Image(file *os.File, fm *tensor.FeatureMap) error {
+func (il *ImageLoader) loadUint8Image(r io.Reader, fm *tensor.FeatureMap) error {
     // Read data in HWC order
     for h := 0; h < fm.Height; h++ {
         for w := 0; w < fm.Width; w++ {
             for c := 0; c < fm.Channels; c++ {
                 var pixel uint8
-                err := binary.Read(file, il.byteOrder, &pixel)
+                err := binary.Read(r, il.byteOrder, &pixel)
                 if err != nil {
                     return fmt.Errorf("failed to read pixel at (%d,%d,%d): %w", h, w, c, err)
                 }
@@ -144,33 +239,34 @@ func (il *ImageLoader) LoadImageBatch(imageDir string, numImages, height, width,
     return images, nil
 }
 
-// PreprocessImage applies common preprocessing operations
+// PreprocessImage applies the normalization of config to a copy of fm
+// It panics when the mean and std don't match the channels; see Pipeline for resizing.
 func (il *ImageLoader) PreprocessImage(fm *tensor.FeatureMap, config PreprocessConfig) *tensor.FeatureMap {
     result := fm.Clone()
     
     // Apply normalization
     if config.Normalize {
-        il.normalizeImage(result, config.Mean, config.Std)
+        if len(config.Mean) != fm.Channels || len(config.Std) != fm.Channels {
+            panic("Mean and std arrays must match number of channels")
+        }
+        normalizeChannels(result, config.Mean, config.Std)
     }
     
-    // Apply other preprocessing as needed
-    
     return result
 }
 
 // PreprocessConfig holds image preprocessing configuration
 type PreprocessConfig struct {
-    Normalize bool        // Whether to apply normalization
-    Mean      []float32   // Mean values for each channel
-    Std       []float32   // Standard deviation for each channel
+    Normalize     bool       // Whether to apply normalization
+    Mean          []float32  // Mean values for each channel
+    Std           []float32  // Standard deviation for each channel
+    Resize        ResizeMode // How images of another size reach the input size
+    LetterboxFill float32    // Padding value for ResizeLetterbox
+    Augment       []Step     // Augmentation or corruption applied at the input size
 }
 
-// normalizeImage applies per-channel normalization: (pixel - mean) / std
-func (il *ImageLoader) normalizeImage(fm *tensor.FeatureMap, mean, std []float32) {
-    if len(mean) != fm.Channels || len(std) != fm.Channels {
-        panic("Mean and std arrays must match number of channels")
-    }
-    
+// normalizeChannels applies per-channel normalization in place: (pixel - mean) / std
+func normalizeChannels(fm *tensor.FeatureMap, mean, std []float32) {
     for c := 0; c < fm.Channels; c++ {
         channelMean := mean[c]
         channelStd := std[c]
@@ -0,0 +1,348 @@
+package data
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/**
+* Streaming datasets
+
+Loading a whole test set before evaluating it costs its full size in
+memory: 50,000 CIFAR-10 images are 600 MB as float32 feature maps, and
+larger inputs grow quadratically. A DatasetIterator hands out one sample at
+a time instead, reading (and decoding, and preprocessing) it only when the
+consumer asks, so memory stays at a few images no matter how long the set:
+```
+it, _ := dm.TestIterator(imageDir, labelDir, 50000, 32, 32, 3, 10)
+defer it.Close()
+for {
+    image, label, err := it.Next()
+    if err == io.EOF { break }
+    ...
+}
+```
+Every source (numbered files, CSV manifest, folder per class, TFRecord,
+CIFAR-10 batch files) has an iterator; the batch loaders remain for
+callers that need random access, and Collect turns any iterator back into
+a DataBatch.
+*/
+
+// DatasetIterator streams the samples of a dataset in order
+type DatasetIterator interface {
+    // Next returns the next image and its one-hot label, or io.EOF after the last sample
+    Next() (*tensor.FeatureMap, []int, error)
+    // Close releases the files held by the iterator
+    Close() error
+}
+
+// SourceNamer is implemented by iterators whose samples are read from files
+type SourceNamer interface {
+    // SourcePath returns the file the i-th sample returned by Next came from, or ""
+    SourcePath(i int) string
+}
+
+// SourcePath returns the file the i-th sample of it came from, or "" when it
+// doesn't know
+func SourcePath(it DatasetIterator, i int) string {
+    if namer, ok := it.(SourceNamer); ok {
+        return namer.SourcePath(i)
+    }
+    return ""
+}
+
+// loadFunc does the work of loading one sample
+type loadFunc func() (*tensor.FeatureMap, []int, error)
+
+// deferredSample is the work of loading one sample, split from reading it
+type deferredSample struct {
+    load    loadFunc
+    content func() ([]byte, error) // Stored bytes that determine the sample, for a TensorCache; nil if unknown
+}
+
+// deferredIterator is implemented by iterators that can hand out the work of loading
+// their next sample without doing it, so a Prefetcher can run that work on several
+// goroutines. nextDeferred itself is cheap and is called from one goroutine at a time.
+type deferredIterator interface {
+    nextDeferred() (deferredSample, error)
+}
+
+// nextFrom loads the next sample of it in the calling goroutine
+func nextFrom(it deferredIterator) (*tensor.FeatureMap, []int, error) {
+    sample, err := it.nextDeferred()
+    if err != nil {
+        return nil, nil, err
+    }
+    return sample.load()
+}
+
+// fileContent returns the bytes of the files at paths followed by extra, which
+// together identify a sample stored in files
+func fileContent(extra []byte, paths ...string) ([]byte, error) {
+    var content []byte
+    for _, path := range paths {
+        raw, err := os.ReadFile(path)
+        if err != nil {
+            return nil, err
+        }
+        content = append(content, raw...)
+    }
+    return append(content, extra...), nil
+}
+
+// Collect reads every remaining sample of it into a batch and closes it
+func Collect(it DatasetIterator) (*DataBatch, error) {
+    defer it.Close()
+
+    batch := &DataBatch{}
+    for {
+        image, label, err := it.Next()
+        if errors.Is(err, io.EOF) {
+            return batch, nil
+        }
+        if err != nil {
+            return nil, err
+        }
+        batch.Images = append(batch.Images, image)
+        batch.Labels = append(batch.Labels, label)
+        batch.Size++
+    }
+}
+
+// batchIterator streams a batch that is already in memory
+type batchIterator struct {
+    batch *DataBatch
+    next  int
+}
+
+// NewBatchIterator returns an iterator over the samples of batch
+func NewBatchIterator(batch *DataBatch) DatasetIterator {
+    return &batchIterator{batch: batch}
+}
+
+func (it *batchIterator) Next() (*tensor.FeatureMap, []int, error) {
+    if it.next >= len(it.batch.Images) {
+        return nil, nil, io.EOF
+    }
+    i := it.next
+    it.next++
+    return it.batch.Images[i], it.batch.Labels[i], nil
+}
+
+func (it *batchIterator) Close() error {
+    return nil
+}
+
+// fileIterator reads the images of a dataset file by file, as LoadTestBatch does
+type fileIterator struct {
+    count   int
+    next    int
+    load    func(i int) (*tensor.FeatureMap, []int, error)
+    content func(i int) ([]byte, error)
+    path    func(i int) string // Image file of sample i
+}
+
+func (it *fileIterator) Next() (*tensor.FeatureMap, []int, error) {
+    return nextFrom(it)
+}
+
+func (it *fileIterator) nextDeferred() (deferredSample, error) {
+    if it.next >= it.count {
+        return deferredSample{}, io.EOF
+    }
+    i := it.next
+    it.next++
+    sample := deferredSample{load: func() (*tensor.FeatureMap, []int, error) { return it.load(i) }}
+    if it.content != nil {
+        sample.content = func() ([]byte, error) { return it.content(i) }
+    }
+    return sample, nil
+}
+
+func (it *fileIterator) SourcePath(i int) string {
+    if it.path == nil || i < 0 || i >= it.count {
+        return ""
+    }
+    return it.path(i)
+}
+
+func (it *fileIterator) Close() error {
+    return nil
+}
+
+// TestIterator streams the samples LoadTestBatch would load for limit samples
+// With the numbered file layout limit must be positive, as the directory holds no
+// count; with the CSVManifest format 0 streams every row of the manifest.
+func (dm *DataManager) TestIterator(imageDir, labelDir string, limit, height, width, channels,
+    numClasses int) (DatasetIterator, error) {
+
+    if dm.labelLoader.labelFormat == CSVManifest {
+        // The manifest itself is small; only the images are streamed
+        entries, err := dm.labelLoader.LoadLabelManifest(labelDir, numClasses)
+        if err != nil {
+            return nil, fmt.Errorf("failed to load labels: %w", err)
+        }
+        if limit > 0 && len(entries) > limit {
+            entries = entries[:limit]
+        }
+        imagePath := func(i int) string {
+            return filepath.Join(imageDir, filepath.FromSlash(entries[i].Filename))
+        }
+        return &fileIterator{
+            count: len(entries),
+            load: func(i int) (*tensor.FeatureMap, []int, error) {
+                path := imagePath(i)
+                // PNG and JPEG images are decoded at their own size
+                var image *tensor.FeatureMap
+                var err error
+                if IsEncodedImage(path) {
+                    image, err = DecodeImageFile(path, channels)
+                } else {
+                    image, err = dm.imageLoader.LoadImage(path, height, width, channels)
+                }
+                if err != nil {
+                    return nil, nil, fmt.Errorf("failed to load images: %w", err)
+                }
+                return image, entries[i].Label, nil
+            },
+            content: func(i int) ([]byte, error) {
+                return fileContent(fmt.Appendf(nil, "label %d", ConvertOneHotToClassIndex(entries[i].Label)), imagePath(i))
+            },
+            path: imagePath,
+        }, nil
+    }
+
+    if limit <= 0 {
+        return nil, fmt.Errorf("numbered test files need a sample count, got %d", limit)
+    }
+    imagePath := func(i int) string { return filepath.Join(imageDir, fmt.Sprintf("test_img_%d.bin", i)) }
+    labelPath := func(i int) string { return filepath.Join(labelDir, fmt.Sprintf("label_test_%d.txt", i)) }
+    return &fileIterator{
+        count: limit,
+        load: func(i int) (*tensor.FeatureMap, []int, error) {
+            image, err := dm.imageLoader.LoadImage(imagePath(i), height, width, channels)
+            if err != nil {
+                return nil, nil, fmt.Errorf("failed to load image %d: %w", i, err)
+            }
+            label, err := dm.labelLoader.LoadLabel(labelPath(i), numClasses)
+            if err != nil {
+                return nil, nil, fmt.Errorf("failed to load label %d: %w", i, err)
+            }
+            return image, label, nil
+        },
+        content: func(i int) ([]byte, error) {
+            return fileContent(nil, imagePath(i), labelPath(i))
+        },
+        path: imagePath,
+    }, nil
+}
+
+// NewImageFolderIterator streams up to limit images (all when limit is 0) of a
+// directory-as-class dataset in ScanImageFolder order, through preprocessor
+func NewImageFolderIterator(root string, classNames []string, limit int, preprocessor *Preprocessor) (DatasetIterator, error) {
+    samples, err := ScanImageFolder(root, classNames)
+    if err != nil {
+        return nil, err
+    }
+    if limit > 0 && len(samples) > limit {
+        samples = samples[:limit]
+    }
+
+    source := &fileIterator{
+        count: len(samples),
+        load: func(i int) (*tensor.FeatureMap, []int, error) {
+            image, err := DecodeImageFile(samples[i].Path, preprocessor.channels)
+            if err != nil {
+                return nil, nil, err
+            }
+            return image, ConvertClassIndexToOneHot(samples[i].Class, len(classNames)), nil
+        },
+        content: func(i int) ([]byte, error) {
+            return fileContent(fmt.Appendf(nil, "class %d of %d", samples[i].Class, len(classNames)), samples[i].Path)
+        },
+        path: func(i int) string { return samples[i].Path },
+    }
+    return Preprocessed(source, preprocessor), nil
+}
+
+// preprocessedIterator applies a preprocessor to the images of another iterator
+type preprocessedIterator struct {
+    source       DatasetIterator
+    preprocessor *Preprocessor
+    next         int
+}
+
+// Preprocessed returns an iterator applying preprocessor to every image of source
+func Preprocessed(source DatasetIterator, preprocessor *Preprocessor) DatasetIterator {
+    return &preprocessedIterator{source: source, preprocessor: preprocessor}
+}
+
+func (it *preprocessedIterator) Next() (*tensor.FeatureMap, []int, error) {
+    return nextFrom(it)
+}
+
+// nextDeferred defers loading when the source can, and preprocessing always
+// With a tensor cache on the preprocessor, samples whose content is known are
+// looked up before loading them and stored after preprocessing.
+func (it *preprocessedIterator) nextDeferred() (deferredSample, error) {
+    var sample deferredSample
+    if source, ok := it.source.(deferredIterator); ok {
+        var err error
+        if sample, err = source.nextDeferred(); err != nil {
+            return deferredSample{}, err
+        }
+    } else {
+        image, label, err := it.source.Next()
+        if err != nil {
+            return deferredSample{}, err
+        }
+        sample.load = func() (*tensor.FeatureMap, []int, error) { return image, label, nil }
+    }
+    i := it.next
+    it.next++
+
+    cache := it.preprocessor.activeCache()
+    if sample.content == nil {
+        cache = nil
+    }
+    return deferredSample{load: func() (*tensor.FeatureMap, []int, error) {
+        var key string
+        if cache != nil {
+            content, err := sample.content()
+            if err != nil {
+                return nil, nil, fmt.Errorf("image %d: %w", i, err)
+            }
+            key = cache.Key(it.preprocessor.cacheSettings(), content)
+            if image, label, ok := cache.Get(key); ok {
+                return image, label, nil
+            }
+        }
+
+        image, label, err := sample.load()
+        if err != nil {
+            return nil, nil, err
+        }
+        image, err = it.preprocessor.Apply(image)
+        if err != nil {
+            return nil, nil, fmt.Errorf("image %d: %w", i, err)
+        }
+        if cache != nil {
+            if err := cache.Put(key, image, label); err != nil {
+                return nil, nil, err
+            }
+        }
+        return image, label, nil
+    }}, nil
+}
+
+func (it *preprocessedIterator) SourcePath(i int) string {
+    return SourcePath(it.source, i)
+}
+
+func (it *preprocessedIterator) Close() error {
+    return it.source.Close()
+}
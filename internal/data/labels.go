@@ -3,7 +3,10 @@ package data
 import (
 	"bufio"
 	"encoding/binary"
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -13,6 +16,7 @@ import (
 // LabelLoader handles loading of label data
 type LabelLoader struct {
     labelFormat LabelFormat
+    classNames  []string // Accepted as labels in CSV manifests besides class indices
 }
 
 // LabelFormat specifies the format of label files
@@ -22,6 +26,7 @@ const (
     OneHotText   LabelFormat = iota // Text files with one-hot encoded labels
     ClassIndex                      // Text files with class indices
     BinaryOneHot                    // Binary files with one-hot encoded labels
+    CSVManifest                     // One CSV file of filename,label rows for the whole dataset
 )
 
 // NewLabelLoader creates a new label loader
@@ -31,9 +36,16 @@ func NewLabelLoader(format LabelFormat) *LabelLoader {
     }
 }
 
+// SetClassNames lets CSV manifests name classes instead of giving their index
+func (ll *LabelLoader) SetClassNames(names []string) {
+    ll.classNames = names
+}
+
 // LoadLabel loads a single label from a file
 func (ll *LabelLoader) LoadLabel(filename string, numClasses int) ([]int, error) {
     switch ll.labelFormat {
+    case CSVManifest:
+        return nil, fmt.Errorf("a CSV manifest holds every label; read it with LoadLabelManifest")
     case OneHotText:
         return ll.loadOneHotText(filename, numClasses)
     case ClassIndex:
@@ -173,6 +185,88 @@ func (ll *LabelLoader) LoadLabelBatch(labelDir string, numLabels, numClasses int
     return labels, nil
 }
 
+// LabelEntry is one row of a CSV label manifest
+type LabelEntry struct {
+    Filename string // As written in the manifest, relative to the image directory
+    Label    []int  // One-hot
+}
+
+// LoadLabelManifest reads a CSV file of filename,label rows in file order
+// The label is a class index or, after SetClassNames, a class name (matched
+// case-insensitively). A first row of "filename,label" is taken as a header and
+// skipped; a filename may appear only once.
+func (ll *LabelLoader) LoadLabelManifest(path string, numClasses int) ([]LabelEntry, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open label manifest %s: %w", path, err)
+    }
+    defer file.Close()
+    
+    reader := csv.NewReader(file)
+    reader.FieldsPerRecord = 2
+    reader.TrimLeadingSpace = true
+    reader.Comment = '#'
+    
+    var entries []LabelEntry
+    seen := make(map[string]int)
+    for row := 1; ; row++ {
+        record, err := reader.Read()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            return nil, fmt.Errorf("label manifest %s: %w", path, err)
+        }
+        
+        filename, value := strings.TrimSpace(record[0]), strings.TrimSpace(record[1])
+        if row == 1 && strings.EqualFold(filename, "filename") && strings.EqualFold(value, "label") {
+            continue
+        }
+        line, _ := reader.FieldPos(0)
+        if filename == "" {
+            return nil, fmt.Errorf("label manifest %s, line %d: empty filename", path, line)
+        }
+        if first, ok := seen[filename]; ok {
+            return nil, fmt.Errorf("label manifest %s, line %d: %s is already labelled on line %d", path, line, filename, first)
+        }
+        seen[filename] = line
+        
+        classIndex, err := ll.parseManifestLabel(value, numClasses)
+        if err != nil {
+            return nil, fmt.Errorf("label manifest %s, line %d: %w", path, line, err)
+        }
+        entries = append(entries, LabelEntry{
+            Filename: filename,
+            Label:    ConvertClassIndexToOneHot(classIndex, numClasses),
+        })
+    }
+    
+    if len(entries) == 0 {
+        return nil, fmt.Errorf("label manifest %s has no labels", path)
+    }
+    return entries, nil
+}
+
+// parseManifestLabel converts a manifest label, a class index or name, to a class index
+func (ll *LabelLoader) parseManifestLabel(value string, numClasses int) (int, error) {
+    if classIndex, err := strconv.Atoi(value); err == nil {
+        if classIndex < 0 || classIndex >= numClasses {
+            return 0, fmt.Errorf("class index %d out of range [0, %d)", classIndex, numClasses)
+        }
+        return classIndex, nil
+    }
+    
+    for i, name := range ll.classNames {
+        if strings.EqualFold(name, value) && i < numClasses {
+            return i, nil
+        }
+    }
+    if len(ll.classNames) == 0 {
+        return 0, fmt.Errorf("label %q is not a class index", value)
+    }
+    return 0, fmt.Errorf("label %q is neither a class index nor one of %s", value, strings.Join(ll.classNames, ", "))
+}
+
 // ConvertOneHotToClassIndex converts one-hot encoded label to class index
 func ConvertOneHotToClassIndex(oneHot []int) int {
     for i, val := range oneHot {
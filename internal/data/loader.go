@@ -0,0 +1,171 @@
+package data
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"io"
+	"math/rand/v2"
+)
+
+/**
+* Training batches
+
+Training visits the training set once per epoch, in a new random order
+every time, and augments every image as it is drawn, so no two epochs see
+quite the same data:
+```
+epoch e   order = shuffle(seed, e)
+step k    order[k·size : (k+1)·size] → image → Preprocessor (augmentation, normalization) → batch
+```
+The order depends only on the seed and the epoch, so any batch can be drawn
+again, as resuming from a checkpoint needs. The images of a batch are
+preprocessed in order, so augmentations drawing from one seeded source
+repeat exactly too. Images that do not fill a last batch sit that epoch
+out; the next shuffle gives them their turn.
+*/
+
+// LabeledSet is a set of images with one class index each, such as a CIFAR10Set
+type LabeledSet interface {
+    Len() int
+    Image(i int) *tensor.FeatureMap
+    Label(i int) int
+    Source(i int) string
+}
+
+// TrainingLoaderOptions configures a TrainingLoader
+type TrainingLoaderOptions struct {
+    BatchSize int
+    Classes   int    // Length of the one-hot labels
+    Limit     int    // Train on only the first Limit images (0 = all)
+    Seed      uint64 // Seed of the per-epoch shuffle
+}
+
+// TrainingLoader draws shuffled, preprocessed batches of a LabeledSet
+type TrainingLoader struct {
+    set          LabeledSet
+    preprocessor *Preprocessor
+    opts         TrainingLoaderOptions
+    size         int   // Images shuffled every epoch
+    epoch        int   // Epoch of order
+    order        []int // Shuffled image indices of epoch; nil before the first batch
+}
+
+// NewTrainingLoader creates a loader drawing batches of set, every image run
+// through preprocessor (whose augmentation makes the training augmentation)
+func NewTrainingLoader(set LabeledSet, preprocessor *Preprocessor, opts TrainingLoaderOptions) (*TrainingLoader, error) {
+    if opts.BatchSize <= 0 || opts.Classes <= 0 || opts.Limit < 0 {
+        return nil, fmt.Errorf("invalid training loader options: batch size %d, %d classes, limit %d",
+            opts.BatchSize, opts.Classes, opts.Limit)
+    }
+    size := set.Len()
+    if opts.Limit > 0 && opts.Limit < size {
+        size = opts.Limit
+    }
+    if size < opts.BatchSize {
+        return nil, fmt.Errorf("%d training images do not fill a batch of %d", size, opts.BatchSize)
+    }
+    return &TrainingLoader{set: set, preprocessor: preprocessor, opts: opts, size: size}, nil
+}
+
+// Size returns the number of images shuffled every epoch
+func (l *TrainingLoader) Size() int {
+    return l.size
+}
+
+// StepsPerEpoch returns the number of full batches in an epoch
+func (l *TrainingLoader) StepsPerEpoch() int {
+    return l.size / l.opts.BatchSize
+}
+
+// Order returns the order epoch visits the images in
+func (l *TrainingLoader) Order(epoch int) []int {
+    if l.order == nil || l.epoch != epoch {
+        rng := rand.New(rand.NewPCG(l.opts.Seed, uint64(epoch)))
+        l.order, l.epoch = rng.Perm(l.size), epoch
+    }
+    return l.order
+}
+
+// Batch preprocesses batch step of epoch and returns its images with one-hot labels
+func (l *TrainingLoader) Batch(epoch, step int) ([]*tensor.FeatureMap, [][]int, error) {
+    if step < 0 || step >= l.StepsPerEpoch() {
+        return nil, nil, fmt.Errorf("step %d is outside an epoch of %d steps", step, l.StepsPerEpoch())
+    }
+    indices := l.Order(epoch)[step*l.opts.BatchSize : (step+1)*l.opts.BatchSize]
+
+    images := make([]*tensor.FeatureMap, len(indices))
+    labels := make([][]int, len(indices))
+    for b, i := range indices {
+        image, err := l.preprocessor.Apply(l.set.Image(i))
+        if err != nil {
+            return nil, nil, fmt.Errorf("image %d of %s: %w", i, l.set.Source(i), err)
+        }
+        label := l.set.Label(i)
+        if label < 0 || label >= l.opts.Classes {
+            return nil, nil, fmt.Errorf("image %d of %s: label %d is outside the %d classes", i, l.set.Source(i),
+                label, l.opts.Classes)
+        }
+        images[b], labels[b] = image, ConvertClassIndexToOneHot(label, l.opts.Classes)
+    }
+    return images, labels, nil
+}
+
+// Subset returns the images of set at indices, in the order of indices
+func Subset(set LabeledSet, indices []int) LabeledSet {
+    return &subset{set: set, indices: indices}
+}
+
+// subset is a LabeledSet of some images of another
+type subset struct {
+    set     LabeledSet
+    indices []int
+}
+
+func (s *subset) Len() int                      { return len(s.indices) }
+func (s *subset) Image(i int) *tensor.FeatureMap { return s.set.Image(s.indices[i]) }
+func (s *subset) Label(i int) int               { return s.set.Label(s.indices[i]) }
+func (s *subset) Source(i int) string           { return s.set.Source(s.indices[i]) }
+
+// LabeledIterator streams the images of set in order, with one-hot labels among classes
+func LabeledIterator(set LabeledSet, classes int) DatasetIterator {
+    return &labeledIterator{set: set, classes: classes}
+}
+
+// labeledIterator streams the images of a LabeledSet
+type labeledIterator struct {
+    set     LabeledSet
+    classes int
+    next    int
+}
+
+func (it *labeledIterator) Next() (*tensor.FeatureMap, []int, error) {
+    return nextFrom(it)
+}
+
+// nextDeferred hands out the next image; decoding it is the deferred work
+func (it *labeledIterator) nextDeferred() (deferredSample, error) {
+    if it.next >= it.set.Len() {
+        return deferredSample{}, io.EOF
+    }
+    i := it.next
+    it.next++
+
+    return deferredSample{
+        load: func() (*tensor.FeatureMap, []int, error) {
+            label := it.set.Label(i)
+            if label < 0 || label >= it.classes {
+                return nil, nil, fmt.Errorf("image %d of %s: label %d is outside the %d classes", i,
+                    it.set.Source(i), label, it.classes)
+            }
+            return it.set.Image(i), ConvertClassIndexToOneHot(label, it.classes), nil
+        },
+    }, nil
+}
+
+func (it *labeledIterator) SourcePath(i int) string {
+    return it.set.Source(i)
+}
+
+func (it *labeledIterator) Close() error {
+    return nil
+}
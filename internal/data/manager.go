@@ -1,8 +1,10 @@
 package data
 
 import (
+	"duchm1606/gocnn/internal/quant"
 	"duchm1606/gocnn/internal/tensor"
 	"fmt"
+	"time"
 )
 
 // DataManager provides a unified interface for all data loading operations
@@ -29,10 +31,52 @@ type DataBatch struct {
 }
 
 // ModelWeights holds all the weights for the CNN model
+// A conv layer whose kernel is stored as int8 has a nil entry in Kernels and
+// its kernel in QuantKernels at the same index; QuantKernels is nil when no
+// kernel is quantized.
 type ModelWeights struct {
-    Kernels    []*tensor.Kernel
-    Biases     [][]float32
-    BatchNorms []*BatchNormParams
+    Kernels      []*tensor.Kernel
+    QuantKernels []*quant.QuantizedKernel
+    Biases       [][]float32
+    BatchNorms   []*BatchNormParams
+    ConvND       map[string]*ConvNDWeights // 1D and 3D conv layers by layer name
+    LoadTimes    []LayerLoadTime // Time spent reading each layer, in load order
+}
+
+// ConvNDWeights holds the kernel and bias of a 1D or 3D conv layer
+// Exactly one of Kernel1D and Kernel3D is set. Their shapes depend on the
+// architecture, so they are read per layer rather than by LoadModelWeights.
+type ConvNDWeights struct {
+    Kernel1D *tensor.Kernel1D
+    Kernel3D *tensor.Kernel3D
+    Bias     []float32
+}
+
+// IsQuantized reports whether conv kernel i is stored as int8
+func (mw *ModelWeights) IsQuantized(i int) bool {
+    return mw.QuantKernels != nil && mw.QuantKernels[i] != nil
+}
+
+// SetQuantKernel stores conv kernel i as int8, replacing its float32 kernel;
+// a nil qk leaves the slot empty
+func (mw *ModelWeights) SetQuantKernel(i int, qk *quant.QuantizedKernel) {
+    if mw.QuantKernels == nil {
+        if qk == nil {
+            return
+        }
+        mw.QuantKernels = make([]*quant.QuantizedKernel, len(mw.Kernels))
+    }
+    mw.QuantKernels[i] = qk
+    if qk != nil {
+        mw.Kernels[i] = nil
+    }
+}
+
+// LayerLoadTime records how long one layer's weight files took to read
+type LayerLoadTime struct {
+    Layer    string
+    Duration time.Duration
+    Bytes    int64 // Total size of the layer's files
 }
 
 // LoadModelWeights loads all model weights from the weights directory
@@ -60,14 +104,25 @@ func (dm *DataManager) LoadModelWeights() (*ModelWeights, error) {
         {"conv7", 1, 128, 10},
     }
     
+    quantKernels := make([]*quant.QuantizedKernel, len(layerConfigs))
+    quantized := false
+    
     for i, config := range layerConfigs {
+        layerStart := time.Now()
+        
         // Load kernel
         kernelFile := fmt.Sprintf("%s/%s_weight.bin", config.name, config.name)
-        kernel, err := dm.weightLoader.LoadKernel(kernelFile, config.size, config.channels, config.filters)
+        kernel, qk, err := dm.weightLoader.LoadConvKernel(kernelFile, config.size, config.channels, config.filters)
         if err != nil {
             return nil, fmt.Errorf("failed to load kernel for %s: %w", config.name, err)
         }
         weights.Kernels = append(weights.Kernels, kernel)
+        kernelBytes := int64(config.size*config.size*config.channels*config.filters) * 4
+        if qk != nil {
+            quantKernels[i] = qk
+            quantized = true
+            kernelBytes = qk.Bytes()
+        }
         
         // Load bias
         biasFile := fmt.Sprintf("%s/%s_bias.bin", config.name, config.name)
@@ -86,13 +141,43 @@ func (dm *DataManager) LoadModelWeights() (*ModelWeights, error) {
             }
             weights.BatchNorms = append(weights.BatchNorms, bn)
         }
+        
+        // Kernel + bias, plus mean/variance/scale/shift for batch-normalised layers
+        values := config.filters
+        if i < len(layerConfigs)-1 {
+            values += 4 * config.filters
+        }
+        weights.LoadTimes = append(weights.LoadTimes, LayerLoadTime{
+            Layer:    config.name,
+            Duration: time.Since(layerStart),
+            Bytes:    kernelBytes + int64(values)*4,
+        })
     }
     
+    if quantized {
+        weights.QuantKernels = quantKernels
+    }
     return weights, nil
 }
 
+// SetClassNames lets CSV label manifests name classes instead of giving their index
+func (dm *DataManager) SetClassNames(names []string) {
+    dm.labelLoader.SetClassNames(names)
+}
+
 // LoadTestBatch loads a batch of test data
+// With the CSVManifest label format, labelDir is the manifest file and the batch
+// holds its first batchSize rows (fewer if it is shorter), each image read from
+// imageDir under the row's filename.
 func (dm *DataManager) LoadTestBatch(imageDir, labelDir string, batchSize, height, width, channels, numClasses int) (*DataBatch, error) {
+    if dm.labelLoader.labelFormat == CSVManifest {
+        it, err := dm.TestIterator(imageDir, labelDir, batchSize, height, width, channels, numClasses)
+        if err != nil {
+            return nil, err
+        }
+        return Collect(it)
+    }
+    
     // Load images
     images, err := dm.imageLoader.LoadImageBatch(imageDir, batchSize, height, width, channels)
     if err != nil {
@@ -0,0 +1,163 @@
+package data
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"errors"
+	"io"
+	"sync"
+)
+
+/**
+* Prefetching
+
+Reading, decoding and preprocessing an image takes about as long as a
+quantized inference, so an evaluator that loads each sample only when it
+needs it spends much of its time waiting on I/O and PNG decoding. A
+Prefetcher loads the next samples on background goroutines while the
+current ones are evaluated:
+```
+source ──> dispatcher ──> jobs ──> loader workers (decode + preprocess)
+               │                          │
+               └──> pending (depth) <─────┘ results, in source order
+                       │
+                   Next() ──> evaluator
+```
+The dispatcher takes the cheap part of each sample from the source (a file
+name, a TFRecord record) in order and queues the expensive part for the
+workers. The pending queue holds one result slot per sample in source
+order, so Next returns samples in order however the workers finish, and
+its capacity bounds how many samples are loaded ahead: memory stays at
+depth images whatever the dataset size.
+
+Sources that can't split off the loading work (an in-memory batch) are
+read by the dispatcher alone, which still overlaps loading with evaluation.
+*/
+
+// prefetchResult is one loaded sample, or the error loading it
+type prefetchResult struct {
+    image *tensor.FeatureMap
+    label []int
+    err   error
+}
+
+// Prefetcher loads the samples of a source ahead of the consumer
+type Prefetcher struct {
+    source  DatasetIterator
+    pending chan chan prefetchResult
+    done    chan struct{}
+    wg      sync.WaitGroup
+    closed  bool
+    failed  error
+}
+
+// NewPrefetcher starts loading up to depth samples of source ahead of Next on
+// workers goroutines. Samples come out in source order. Close stops loading and
+// closes source.
+func NewPrefetcher(source DatasetIterator, depth, workers int) *Prefetcher {
+    depth = max(depth, 1)
+    workers = max(workers, 1)
+
+    p := &Prefetcher{
+        source:  source,
+        pending: make(chan chan prefetchResult, depth),
+        done:    make(chan struct{}),
+    }
+
+    type job struct {
+        load   loadFunc
+        result chan prefetchResult
+    }
+    jobs := make(chan job, depth)
+
+    for w := 0; w < workers; w++ {
+        p.wg.Add(1)
+        go func() {
+            defer p.wg.Done()
+            for j := range jobs {
+                image, label, err := j.load()
+                j.result <- prefetchResult{image: image, label: label, err: err}
+            }
+        }()
+    }
+
+    p.wg.Add(1)
+    go func() {
+        defer p.wg.Done()
+        defer close(p.pending)
+        defer close(jobs)
+
+        deferred, canDefer := source.(deferredIterator)
+        for {
+            // Result slots have room for one value, so workers never block on them
+            result := make(chan prefetchResult, 1)
+            var load loadFunc
+            var err error
+            if canDefer {
+                var sample deferredSample
+                sample, err = deferred.nextDeferred()
+                load = sample.load
+            } else {
+                var image *tensor.FeatureMap
+                var label []int
+                image, label, err = source.Next()
+                load = func() (*tensor.FeatureMap, []int, error) { return image, label, nil }
+            }
+            if errors.Is(err, io.EOF) {
+                return
+            }
+            if err != nil {
+                result <- prefetchResult{err: err}
+            }
+
+            select {
+            case p.pending <- result:
+            case <-p.done:
+                return
+            }
+            if err != nil {
+                return
+            }
+            select {
+            case jobs <- job{load: load, result: result}:
+            case <-p.done:
+                return
+            }
+        }
+    }()
+
+    return p
+}
+
+// Next returns the next sample in source order, or io.EOF after the last one
+// The first error ends the stream; later calls return it again.
+func (p *Prefetcher) Next() (*tensor.FeatureMap, []int, error) {
+    if p.failed != nil {
+        return nil, nil, p.failed
+    }
+    result, ok := <-p.pending
+    if !ok {
+        return nil, nil, io.EOF
+    }
+    r := <-result
+    if r.err != nil {
+        p.failed = r.err
+        return nil, nil, r.err
+    }
+    return r.image, r.label, nil
+}
+
+// SourcePath returns the file the i-th sample came from, if the source knows it
+func (p *Prefetcher) SourcePath(i int) string {
+    return SourcePath(p.source, i)
+}
+
+// Close stops loading, waits for the background goroutines and closes the source
+func (p *Prefetcher) Close() error {
+    if p.closed {
+        return nil
+    }
+    p.closed = true
+    close(p.done)
+    p.wg.Wait()
+    return p.source.Close()
+}
@@ -0,0 +1,449 @@
+package data
+
+import (
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"math"
+	"strings"
+)
+
+/**
+* Preprocessing pipeline
+
+Stored images rarely match the model input exactly. A pipeline is a list of
+steps applied in order, each producing a new feature map:
+  - Resize stretches the image to the target size (bilinear); the aspect
+    ratio changes when source and target ratios differ
+  - CenterCrop cuts the middle of the image out; combined with a resize that
+    scales the shorter side to the target, it keeps the aspect ratio and
+    drops the edges of the longer side
+  - Letterbox scales the longer side to the target and pads the rest with a
+    constant; it keeps the aspect ratio and the whole image
+  - Normalize computes (pixel - mean[c]) / std[c] per channel, for models
+    trained on standardized inputs
+
+Bilinear sampling uses pixel centers: output pixel i reads source position
+(i + 0.5) * scale - 0.5, so a 2x downscale averages pixel pairs instead of
+picking every other pixel.
+*/
+
+// Step is one preprocessing operation; it returns a new feature map and leaves its input unchanged
+type Step interface {
+    Apply(fm *tensor.FeatureMap) (*tensor.FeatureMap, error)
+    String() string
+}
+
+// Pipeline applies its steps in order
+type Pipeline struct {
+    Steps []Step
+}
+
+// NewPipeline creates a pipeline from steps
+func NewPipeline(steps ...Step) *Pipeline {
+    return &Pipeline{Steps: steps}
+}
+
+// Apply runs every step on fm; an empty pipeline returns fm itself
+func (p *Pipeline) Apply(fm *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    var err error
+    for _, step := range p.Steps {
+        fm, err = step.Apply(fm)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", step, err)
+        }
+    }
+    return fm, nil
+}
+
+// String lists the steps, or "none" for an empty pipeline
+func (p *Pipeline) String() string {
+    if len(p.Steps) == 0 {
+        return "none"
+    }
+    names := make([]string, len(p.Steps))
+    for i, step := range p.Steps {
+        names[i] = step.String()
+    }
+    return strings.Join(names, " -> ")
+}
+
+// resizeStep scales an image to a fixed size with bilinear sampling
+type resizeStep struct {
+    height, width int
+}
+
+// Resize returns a step that scales images to height×width
+func Resize(height, width int) Step {
+    return resizeStep{height: height, width: width}
+}
+
+func (s resizeStep) String() string {
+    return fmt.Sprintf("resize(%dx%d)", s.height, s.width)
+}
+
+func (s resizeStep) Apply(fm *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    if s.height <= 0 || s.width <= 0 {
+        return nil, fmt.Errorf("target size must be positive, got %dx%d", s.height, s.width)
+    }
+    if fm.Height == s.height && fm.Width == s.width {
+        return fm.Clone(), nil
+    }
+
+    output := tensor.NewFeatureMapWithLayout(s.height, s.width, fm.Channels, fm.Layout)
+    scaleY := float64(fm.Height) / float64(s.height)
+    scaleX := float64(fm.Width) / float64(s.width)
+
+    for i := 0; i < s.height; i++ {
+        y0, y1, fy := samplePoints(i, scaleY, fm.Height)
+        for j := 0; j < s.width; j++ {
+            x0, x1, fx := samplePoints(j, scaleX, fm.Width)
+            for c := 0; c < fm.Channels; c++ {
+                top := lerp(fm.GetUnsafe(c, y0, x0), fm.GetUnsafe(c, y0, x1), fx)
+                bottom := lerp(fm.GetUnsafe(c, y1, x0), fm.GetUnsafe(c, y1, x1), fx)
+                output.SetUnsafe(c, i, j, lerp(top, bottom, fy))
+            }
+        }
+    }
+    return output, nil
+}
+
+// samplePoints returns the two source indices around output index i and the
+// weight of the second one
+func samplePoints(i int, scale float64, size int) (int, int, float32) {
+    position := (float64(i)+0.5)*scale - 0.5
+    position = math.Max(0, math.Min(position, float64(size-1)))
+    low := int(position)
+    high := min(low+1, size-1)
+    return low, high, float32(position - float64(low))
+}
+
+// lerp interpolates between a and b
+func lerp(a, b, t float32) float32 {
+    return a + (b-a)*t
+}
+
+// centerCropStep cuts the middle of an image out
+type centerCropStep struct {
+    height, width int
+}
+
+// CenterCrop returns a step that keeps the centered height×width region of images
+// Images smaller than the crop are rejected.
+func CenterCrop(height, width int) Step {
+    return centerCropStep{height: height, width: width}
+}
+
+func (s centerCropStep) String() string {
+    return fmt.Sprintf("center_crop(%dx%d)", s.height, s.width)
+}
+
+func (s centerCropStep) Apply(fm *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    if s.height <= 0 || s.width <= 0 {
+        return nil, fmt.Errorf("crop size must be positive, got %dx%d", s.height, s.width)
+    }
+    if s.height > fm.Height || s.width > fm.Width {
+        return nil, fmt.Errorf("cannot crop %dx%d from a %dx%d image", s.height, s.width, fm.Height, fm.Width)
+    }
+
+    output := tensor.NewFeatureMapWithLayout(s.height, s.width, fm.Channels, fm.Layout)
+    top := (fm.Height - s.height) / 2
+    left := (fm.Width - s.width) / 2
+    for c := 0; c < fm.Channels; c++ {
+        for i := 0; i < s.height; i++ {
+            for j := 0; j < s.width; j++ {
+                output.SetUnsafe(c, i, j, fm.GetUnsafe(c, top+i, left+j))
+            }
+        }
+    }
+    return output, nil
+}
+
+// letterboxStep fits an image inside a fixed size and pads the remainder
+type letterboxStep struct {
+    height, width int
+    fill          float32
+}
+
+// Letterbox returns a step that scales images to fit height×width without
+// changing their aspect ratio and centers them on a canvas filled with fill
+func Letterbox(height, width int, fill float32) Step {
+    return letterboxStep{height: height, width: width, fill: fill}
+}
+
+func (s letterboxStep) String() string {
+    return fmt.Sprintf("letterbox(%dx%d, fill %g)", s.height, s.width, s.fill)
+}
+
+func (s letterboxStep) Apply(fm *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    if s.height <= 0 || s.width <= 0 {
+        return nil, fmt.Errorf("target size must be positive, got %dx%d", s.height, s.width)
+    }
+
+    scale := math.Min(float64(s.height)/float64(fm.Height), float64(s.width)/float64(fm.Width))
+    fitHeight := min(s.height, max(1, int(math.Round(float64(fm.Height)*scale))))
+    fitWidth := min(s.width, max(1, int(math.Round(float64(fm.Width)*scale))))
+    fitted, err := Resize(fitHeight, fitWidth).Apply(fm)
+    if err != nil {
+        return nil, err
+    }
+
+    output := tensor.NewFeatureMapWithLayout(s.height, s.width, fm.Channels, fm.Layout)
+    output.Fill(s.fill)
+    top := (s.height - fitHeight) / 2
+    left := (s.width - fitWidth) / 2
+    for c := 0; c < fm.Channels; c++ {
+        for i := 0; i < fitHeight; i++ {
+            for j := 0; j < fitWidth; j++ {
+                output.SetUnsafe(c, top+i, left+j, fitted.GetUnsafe(c, i, j))
+            }
+        }
+    }
+    return output, nil
+}
+
+// normalizeStep standardizes every channel
+type normalizeStep struct {
+    mean, std []float32
+}
+
+// Normalize returns a step that computes (pixel - mean[c]) / std[c] for every channel c
+func Normalize(mean, std []float32) Step {
+    return normalizeStep{mean: mean, std: std}
+}
+
+func (s normalizeStep) String() string {
+    return fmt.Sprintf("normalize(mean %v, std %v)", s.mean, s.std)
+}
+
+func (s normalizeStep) Apply(fm *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    if len(s.mean) != fm.Channels || len(s.std) != fm.Channels {
+        return nil, fmt.Errorf("got %d means and %d stds for %d channels", len(s.mean), len(s.std), fm.Channels)
+    }
+    for c, std := range s.std {
+        if std == 0 {
+            return nil, fmt.Errorf("std of channel %d is 0", c)
+        }
+    }
+
+    output := fm.Clone()
+    normalizeChannels(output, s.mean, s.std)
+    return output, nil
+}
+
+// ResizeMode selects how stored images are brought to the model input size
+type ResizeMode int
+
+const (
+    ResizeStretch    ResizeMode = iota // Scale both sides to the target (aspect ratio may change)
+    ResizeCenterCrop                   // Scale the shorter side to the target, then crop the center
+    ResizeLetterbox                    // Scale the longer side to the target, then pad
+)
+
+// String returns the config name of the mode
+func (m ResizeMode) String() string {
+    switch m {
+    case ResizeStretch:
+        return "stretch"
+    case ResizeCenterCrop:
+        return "center-crop"
+    case ResizeLetterbox:
+        return "letterbox"
+    default:
+        return fmt.Sprintf("ResizeMode(%d)", int(m))
+    }
+}
+
+// ParseResizeMode converts a config name such as "letterbox" to a ResizeMode
+// An empty name selects stretch
+func ParseResizeMode(name string) (ResizeMode, error) {
+    switch strings.ToLower(strings.TrimSpace(name)) {
+    case "", "stretch", "resize":
+        return ResizeStretch, nil
+    case "center-crop", "center_crop", "crop":
+        return ResizeCenterCrop, nil
+    case "letterbox", "pad":
+        return ResizeLetterbox, nil
+    }
+    return ResizeStretch, fmt.Errorf("unknown resize mode %q (use stretch, center-crop or letterbox)", name)
+}
+
+// PreprocessConfigFromData reads the preprocessing settings of a data config
+func PreprocessConfigFromData(dc config.DataConfig) (PreprocessConfig, error) {
+    resize, err := ParseResizeMode(dc.Resize)
+    if err != nil {
+        return PreprocessConfig{}, err
+    }
+    return PreprocessConfig{
+        Normalize:     dc.Normalize,
+        Mean:          dc.MeanValues,
+        Std:           dc.StdValues,
+        Resize:        resize,
+        LetterboxFill: dc.LetterboxFill,
+    }, nil
+}
+
+// Pipeline returns the steps that turn a srcHeight×srcWidth image into a
+// height×width model input; no resize step is added when the sizes match, and
+// the augmentation steps run between resizing and normalization
+func (pc PreprocessConfig) Pipeline(srcHeight, srcWidth, height, width int) *Pipeline {
+    pipeline := NewPipeline()
+    if srcHeight != height || srcWidth != width {
+        switch pc.Resize {
+        case ResizeCenterCrop:
+            scale := math.Max(float64(height)/float64(srcHeight), float64(width)/float64(srcWidth))
+            pipeline.Steps = append(pipeline.Steps,
+                Resize(max(height, int(math.Round(float64(srcHeight)*scale))),
+                    max(width, int(math.Round(float64(srcWidth)*scale)))),
+                CenterCrop(height, width))
+        case ResizeLetterbox:
+            pipeline.Steps = append(pipeline.Steps, Letterbox(height, width, pc.LetterboxFill))
+        default:
+            pipeline.Steps = append(pipeline.Steps, Resize(height, width))
+        }
+    }
+    pipeline.Steps = append(pipeline.Steps, pc.Augment...)
+    if pc.Normalize {
+        pipeline.Steps = append(pipeline.Steps, Normalize(pc.Mean, pc.Std))
+    }
+    return pipeline
+}
+
+// Preprocessor loads stored images and turns them into model inputs
+type Preprocessor struct {
+    loader      *ImageLoader
+    config      PreprocessConfig
+    pipeline    *Pipeline // Steps for images of the stored size
+    height      int       // Stored image size
+    width       int
+    channels    int
+    inputHeight int // Model input size
+    inputWidth  int
+    cache       *TensorCache // Preprocessed samples from earlier runs; nil when off
+}
+
+// NewPreprocessor creates a preprocessor for images stored in format, sized and
+// processed as dc describes, for a model that takes mc's input size
+func NewPreprocessor(format ImageFormat, dc config.DataConfig, mc config.ModelConfig) (*Preprocessor, error) {
+    pc, err := PreprocessConfigFromData(dc)
+    if err != nil {
+        return nil, err
+    }
+
+    height, width := dc.ImageHeight, dc.ImageWidth
+    if height == 0 {
+        height = mc.InputHeight
+    }
+    if width == 0 {
+        width = mc.InputWidth
+    }
+
+    return &Preprocessor{
+        loader:      NewImageLoader(format),
+        config:      pc,
+        pipeline:    pc.Pipeline(height, width, mc.InputHeight, mc.InputWidth),
+        height:      height,
+        width:       width,
+        channels:    mc.InputChannels,
+        inputHeight: mc.InputHeight,
+        inputWidth:  mc.InputWidth,
+    }, nil
+}
+
+// StoredSize returns the height and width of the binary image files
+func (p *Preprocessor) StoredSize() (int, int) {
+    return p.height, p.width
+}
+
+// ImageBytes returns the size of one binary image of the stored size
+func (p *Preprocessor) ImageBytes() int {
+    return p.height * p.width * p.channels * p.loader.imageFormat.BytesPerValue()
+}
+
+// Pipeline returns the steps applied to images of the stored size
+func (p *Preprocessor) Pipeline() *Pipeline {
+    return p.pipeline
+}
+
+// SetCache stores preprocessed samples in cache and reuses them on later runs; nil turns
+// caching off. Samples are only cached while no augmentation is set, as random
+// augmentations must differ between runs.
+func (p *Preprocessor) SetCache(cache *TensorCache) {
+    p.cache = cache
+}
+
+// activeCache returns the cache if samples may be cached
+func (p *Preprocessor) activeCache() *TensorCache {
+    if len(p.config.Augment) > 0 {
+        return nil
+    }
+    return p.cache
+}
+
+// cacheSettings describes everything besides a sample's stored bytes that
+// determines its preprocessed image
+func (p *Preprocessor) cacheSettings() string {
+    return fmt.Sprintf("format %d; stored %dx%dx%d; input %dx%d; resize %s, fill %g; pipeline %s",
+        p.loader.imageFormat, p.height, p.width, p.channels, p.inputHeight, p.inputWidth,
+        p.config.Resize, p.config.LetterboxFill, p.pipeline)
+}
+
+// SetAugmentation runs steps (see package augment) on every image once it has the
+// input size, before normalization; no steps turns augmentation off
+func (p *Preprocessor) SetAugmentation(steps ...Step) {
+    p.config.Augment = steps
+    p.pipeline = p.config.Pipeline(p.height, p.width, p.inputHeight, p.inputWidth)
+}
+
+// Load reads an image file and preprocesses it
+// PNG and JPEG files are decoded at whatever size they have; other files are
+// read as binary images of the stored size.
+func (p *Preprocessor) Load(path string) (*tensor.FeatureMap, error) {
+    var fm *tensor.FeatureMap
+    var err error
+    if IsEncodedImage(path) {
+        fm, err = DecodeImageFile(path, p.channels)
+    } else {
+        fm, err = p.loader.LoadImage(path, p.height, p.width, p.channels)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    processed, err := p.Apply(fm)
+    if err != nil {
+        return nil, fmt.Errorf("image %s: %w", path, err)
+    }
+    return processed, nil
+}
+
+// Decode preprocesses an image held in memory, such as a request body
+// PNG and JPEG data is decoded at whatever size it has; anything else is read as a
+// binary image of the stored size.
+func (p *Preprocessor) Decode(raw []byte) (*tensor.FeatureMap, error) {
+    var fm *tensor.FeatureMap
+    var err error
+    if IsEncodedImageData(raw) {
+        fm, err = DecodeImageData(raw, p.channels)
+    } else {
+        fm, err = p.loader.DecodeImage(raw, p.height, p.width, p.channels)
+    }
+    if err != nil {
+        return nil, err
+    }
+    return p.Apply(fm)
+}
+
+// Apply checks that a loaded image holds [0, 1] pixels and runs the pipeline on it
+// Images that don't have the stored size get a pipeline built for their own size.
+func (p *Preprocessor) Apply(fm *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    if err := CheckPixelRange(fm); err != nil {
+        return nil, err
+    }
+
+    pipeline := p.pipeline
+    if fm.Height != p.height || fm.Width != p.width {
+        pipeline = p.config.Pipeline(fm.Height, fm.Width, p.inputHeight, p.inputWidth)
+    }
+    return pipeline.Apply(fm)
+}
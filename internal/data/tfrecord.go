@@ -0,0 +1,412 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/tensor"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+/**
+* TFRecord datasets
+
+A TFRecord file is a sequence of length-prefixed records:
+```
+uint64 length          little-endian
+uint32 masked_crc32c(length)
+byte   data[length]
+uint32 masked_crc32c(data)
+```
+where masked_crc = ((crc >> 15) | (crc << 17)) + 0xa282ead8, with the
+Castagnoli polynomial. Because every record carries its own length, shards
+can simply be concatenated. Files written with GZIP compression are the same
+stream gzipped as a whole.
+
+Image datasets store one tf.train.Example protobuf per record, a map from
+feature name to a list of bytes, floats or int64s. Only the handful of
+protobuf wire rules that Example uses are decoded here, so no protobuf
+dependency is needed:
+```
+Example   { Features features = 1 }
+Features  { map<string, Feature> feature = 1 }    (entries: key = 1, value = 2)
+Feature   { BytesList = 1 | FloatList = 2 | Int64List = 3 }
+*List     { repeated value = 1 }                  (numbers usually packed)
+```
+The CIFAR-10 records written by TensorFlow's tutorials and by
+tensorflow_datasets hold an "image" (raw 32x32x3 uint8 pixels in HWC order,
+or an encoded PNG) and an int64 "label".
+*/
+
+// tfrecordMaskDelta is added to the rotated CRC of every length and record
+const tfrecordMaskDelta = 0xa282ead8
+
+// crc32c is the Castagnoli table TFRecord checksums use
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// maskedCRC returns the TFRecord checksum of data
+func maskedCRC(data []byte) uint32 {
+    crc := crc32.Checksum(data, crc32c)
+    return (crc>>15 | crc<<17) + tfrecordMaskDelta
+}
+
+// TFRecordReader reads the records of a TFRecord stream
+type TFRecordReader struct {
+    r      *bufio.Reader
+    header [12]byte
+    footer [4]byte
+}
+
+// NewTFRecordReader creates a reader over an uncompressed TFRecord stream
+func NewTFRecordReader(r io.Reader) *TFRecordReader {
+    return &TFRecordReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next record, verifying both checksums, or io.EOF after the last one
+// Every record is a new slice, which the caller may keep.
+func (tr *TFRecordReader) Next() ([]byte, error) {
+    if _, err := io.ReadFull(tr.r, tr.header[:]); err != nil {
+        if errors.Is(err, io.ErrUnexpectedEOF) {
+            return nil, fmt.Errorf("truncated record header")
+        }
+        return nil, err
+    }
+
+    length := binary.LittleEndian.Uint64(tr.header[:8])
+    if maskedCRC(tr.header[:8]) != binary.LittleEndian.Uint32(tr.header[8:]) {
+        return nil, fmt.Errorf("corrupt record header (length checksum mismatch)")
+    }
+    if length > math.MaxInt32 {
+        return nil, fmt.Errorf("record length %d is too large", length)
+    }
+
+    record := make([]byte, length)
+    if _, err := io.ReadFull(tr.r, record); err != nil {
+        return nil, fmt.Errorf("truncated record of %d bytes: %w", length, err)
+    }
+    if _, err := io.ReadFull(tr.r, tr.footer[:]); err != nil {
+        return nil, fmt.Errorf("truncated record checksum: %w", err)
+    }
+    if maskedCRC(record) != binary.LittleEndian.Uint32(tr.footer[:]) {
+        return nil, fmt.Errorf("corrupt record of %d bytes (data checksum mismatch)", length)
+    }
+    return record, nil
+}
+
+// TFFeature is one feature of a tf.train.Example; only the list it was stored as is set
+type TFFeature struct {
+    Bytes  [][]byte
+    Floats []float32
+    Int64s []int64
+}
+
+// ParseTFExample decodes a serialized tf.train.Example into its features by name
+func ParseTFExample(record []byte) (map[string]TFFeature, error) {
+    features := make(map[string]TFFeature)
+    err := forEachField(record, func(field int, value []byte, _ uint64) error {
+        if field != 1 {
+            return nil
+        }
+        // Features: repeated map entries
+        return forEachField(value, func(field int, entry []byte, _ uint64) error {
+            if field != 1 {
+                return nil
+            }
+            var name string
+            var feature TFFeature
+            err := forEachField(entry, func(field int, value []byte, _ uint64) error {
+                switch field {
+                case 1:
+                    name = string(value)
+                case 2:
+                    return parseTFFeature(value, &feature)
+                }
+                return nil
+            })
+            if err != nil {
+                return err
+            }
+            features[name] = feature
+            return nil
+        })
+    })
+    if err != nil {
+        return nil, fmt.Errorf("invalid tf.train.Example: %w", err)
+    }
+    return features, nil
+}
+
+// parseTFFeature decodes one Feature message into feature
+func parseTFFeature(data []byte, feature *TFFeature) error {
+    return forEachField(data, func(kind int, list []byte, _ uint64) error {
+        return forEachField(list, func(field int, value []byte, scalar uint64) error {
+            if field != 1 {
+                return nil
+            }
+            switch kind {
+            case 1:
+                feature.Bytes = append(feature.Bytes, value)
+            case 2:
+                if value == nil {
+                    feature.Floats = append(feature.Floats, math.Float32frombits(uint32(scalar)))
+                    return nil
+                }
+                if len(value)%4 != 0 {
+                    return fmt.Errorf("packed float list of %d bytes", len(value))
+                }
+                for i := 0; i < len(value); i += 4 {
+                    feature.Floats = append(feature.Floats, math.Float32frombits(binary.LittleEndian.Uint32(value[i:])))
+                }
+            case 3:
+                if value == nil {
+                    feature.Int64s = append(feature.Int64s, int64(scalar))
+                    return nil
+                }
+                for len(value) > 0 {
+                    v, n := binary.Uvarint(value)
+                    if n <= 0 {
+                        return fmt.Errorf("invalid packed int64 list")
+                    }
+                    feature.Int64s = append(feature.Int64s, int64(v))
+                    value = value[n:]
+                }
+            }
+            return nil
+        })
+    })
+}
+
+// forEachField calls fn for every field of a protobuf message
+// Length-delimited fields pass their bytes; varint and fixed-size fields pass
+// a nil slice and their value as scalar.
+func forEachField(data []byte, fn func(field int, value []byte, scalar uint64) error) error {
+    for len(data) > 0 {
+        tag, n := binary.Uvarint(data)
+        if n <= 0 {
+            return fmt.Errorf("invalid field tag")
+        }
+        data = data[n:]
+        field := int(tag >> 3)
+
+        var value []byte
+        var scalar uint64
+        switch tag & 7 {
+        case 0: // varint
+            scalar, n = binary.Uvarint(data)
+            if n <= 0 {
+                return fmt.Errorf("invalid varint in field %d", field)
+            }
+            data = data[n:]
+        case 1: // 64-bit
+            if len(data) < 8 {
+                return fmt.Errorf("truncated field %d", field)
+            }
+            scalar = binary.LittleEndian.Uint64(data)
+            data = data[8:]
+        case 2: // length-delimited
+            length, n := binary.Uvarint(data)
+            if n <= 0 || length > uint64(len(data)-n) {
+                return fmt.Errorf("truncated field %d", field)
+            }
+            value = data[n : n+int(length)]
+            if value == nil {
+                value = []byte{}
+            }
+            data = data[n+int(length):]
+        case 5: // 32-bit
+            if len(data) < 4 {
+                return fmt.Errorf("truncated field %d", field)
+            }
+            scalar = uint64(binary.LittleEndian.Uint32(data))
+            data = data[4:]
+        default:
+            return fmt.Errorf("unsupported wire type %d in field %d", tag&7, field)
+        }
+
+        if err := fn(field, value, scalar); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// TFRecordSchema names the Example features holding the image and its label
+type TFRecordSchema struct {
+    ImageKey string // bytes: raw uint8 pixels in HWC order, or an encoded PNG/JPEG
+    LabelKey string // int64: class index
+}
+
+// CIFAR10TFRecordSchema is the feature layout of TensorFlow's CIFAR-10 records
+var CIFAR10TFRecordSchema = TFRecordSchema{ImageKey: "image", LabelKey: "label"}
+
+// IsTFRecordFile reports whether path names a TFRecord file, optionally gzipped
+// TensorFlow shards are named like name.tfrecord-00000-of-00004.
+func IsTFRecordFile(path string) bool {
+    name := strings.ToLower(strings.TrimSuffix(path, ".gz"))
+    return strings.HasSuffix(name, ".tfrecord") || strings.HasSuffix(name, ".tfrecords") ||
+        strings.Contains(name, ".tfrecord-")
+}
+
+// tfrecordIterator streams the examples of a TFRecord file
+type tfrecordIterator struct {
+    path       string
+    file       *os.File
+    gz         *gzip.Reader
+    reader     *TFRecordReader
+    schema     TFRecordSchema
+    limit      int
+    next       int
+    height     int
+    width      int
+    channels   int
+    numClasses int
+}
+
+// OpenTFRecord streams up to limit examples (all when limit is 0) of a TFRecord
+// file; files ending in .gz are decompressed. Raw pixel images must hold
+// height×width×channels bytes; encoded images are decoded at their own size and
+// are brought to the input size by the preprocessing pipeline.
+func OpenTFRecord(path string, schema TFRecordSchema, limit, height, width, channels, numClasses int) (DatasetIterator, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open TFRecord file %s: %w", path, err)
+    }
+
+    it := &tfrecordIterator{path: path, file: file, schema: schema, limit: limit,
+        height: height, width: width, channels: channels, numClasses: numClasses}
+    var stream io.Reader = file
+    if strings.HasSuffix(strings.ToLower(path), ".gz") {
+        it.gz, err = gzip.NewReader(file)
+        if err != nil {
+            file.Close()
+            return nil, fmt.Errorf("failed to open gzipped TFRecord file %s: %w", path, err)
+        }
+        stream = it.gz
+    }
+    it.reader = NewTFRecordReader(stream)
+    return it, nil
+}
+
+func (it *tfrecordIterator) Next() (*tensor.FeatureMap, []int, error) {
+    return nextFrom(it)
+}
+
+// nextDeferred reads the next record; decoding it is the deferred work
+func (it *tfrecordIterator) nextDeferred() (deferredSample, error) {
+    if it.limit > 0 && it.next >= it.limit {
+        return deferredSample{}, io.EOF
+    }
+
+    record, err := it.reader.Next()
+    if errors.Is(err, io.EOF) {
+        if it.next == 0 {
+            return deferredSample{}, fmt.Errorf("TFRecord file %s holds no records", it.path)
+        }
+        return deferredSample{}, io.EOF
+    }
+    if err != nil {
+        return deferredSample{}, fmt.Errorf("TFRecord file %s, record %d: %w", it.path, it.next, err)
+    }
+    i := it.next
+    it.next++
+
+    return deferredSample{
+        load: func() (*tensor.FeatureMap, []int, error) {
+            img, label, err := decodeTFExample(record, it.schema, it.height, it.width, it.channels, it.numClasses)
+            if err != nil {
+                return nil, nil, fmt.Errorf("TFRecord file %s, record %d: %w", it.path, i, err)
+            }
+            return img, label, nil
+        },
+        content: func() ([]byte, error) {
+            // The schema picks what is read from the record
+            return fmt.Appendf(nil, "%s\x00%s\x00%d\x00%s", it.schema.ImageKey, it.schema.LabelKey, it.numClasses, record), nil
+        },
+    }, nil
+}
+
+func (it *tfrecordIterator) Close() error {
+    if it.gz != nil {
+        it.gz.Close()
+    }
+    return it.file.Close()
+}
+
+// LoadTFRecord reads up to limit examples (all when limit is 0) of a TFRecord
+// file into a batch; see OpenTFRecord
+func LoadTFRecord(path string, schema TFRecordSchema, limit, height, width, channels, numClasses int) (*DataBatch, error) {
+    it, err := OpenTFRecord(path, schema, limit, height, width, channels, numClasses)
+    if err != nil {
+        return nil, err
+    }
+    return Collect(it)
+}
+
+// decodeTFExample extracts the image and one-hot label of one serialized Example
+func decodeTFExample(record []byte, schema TFRecordSchema, height, width, channels,
+    numClasses int) (*tensor.FeatureMap, []int, error) {
+
+    features, err := ParseTFExample(record)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    imageFeature, ok := features[schema.ImageKey]
+    if !ok || len(imageFeature.Bytes) != 1 {
+        err := fmt.Errorf("no bytes feature %q holding one image", schema.ImageKey)
+        return nil, nil, errs.WithHint(err, "the records have features %s; name the image and label features of this dataset",
+            featureNames(features))
+    }
+    labelFeature, ok := features[schema.LabelKey]
+    if !ok || len(labelFeature.Int64s) != 1 {
+        err := fmt.Errorf("no int64 feature %q holding one label", schema.LabelKey)
+        return nil, nil, errs.WithHint(err, "the records have features %s; name the image and label features of this dataset",
+            featureNames(features))
+    }
+
+    classIndex := labelFeature.Int64s[0]
+    if classIndex < 0 || classIndex >= int64(numClasses) {
+        return nil, nil, fmt.Errorf("label %d out of range [0, %d)", classIndex, numClasses)
+    }
+    label := ConvertClassIndexToOneHot(int(classIndex), numClasses)
+
+    pixels := imageFeature.Bytes[0]
+    if len(pixels) == height*width*channels {
+        fm := tensor.NewFeatureMap(height, width, channels)
+        for h := 0; h < height; h++ {
+            for w := 0; w < width; w++ {
+                for c := 0; c < channels; c++ {
+                    fm.SetUnsafe(c, h, w, float32(pixels[(h*width+w)*channels+c])/255)
+                }
+            }
+        }
+        return fm, label, nil
+    }
+
+    img, _, err := image.Decode(bytes.NewReader(pixels))
+    if err != nil {
+        return nil, nil, fmt.Errorf("image of %d bytes is neither %d×%d×%d raw pixels nor a PNG/JPEG: %w",
+            len(pixels), height, width, channels, err)
+    }
+    return imageToFeatureMap(img, channels), label, nil
+}
+
+// featureNames lists the feature names of an Example in sorted order
+func featureNames(features map[string]TFFeature) string {
+    names := make([]string, 0, len(features))
+    for name := range features {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return strings.Join(names, ", ")
+}
@@ -1,70 +1,123 @@
 package data
 
 import (
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/quant"
 	"duchm1606/gocnn/internal/tensor"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
 )
 
+// WeightFormat is the element encoding of weight files
+type WeightFormat int
+
+const (
+    WeightFormatAuto     WeightFormat = iota // Decide per file from its size
+    WeightFormatFloat32                      // 4-byte IEEE floats
+    WeightFormatBFloat16                     // 2-byte bfloat16, as exported by TPU / mixed-precision training
+)
+
+// String returns the name of the format
+func (wf WeightFormat) String() string {
+    switch wf {
+    case WeightFormatAuto:
+        return "auto"
+    case WeightFormatFloat32:
+        return "float32"
+    case WeightFormatBFloat16:
+        return "bfloat16"
+    default:
+        return fmt.Sprintf("WeightFormat(%d)", int(wf))
+    }
+}
+
+// ParseWeightFormat converts a name such as "bfloat16" to a WeightFormat
+func ParseWeightFormat(name string) (WeightFormat, error) {
+    switch name {
+    case "", "auto":
+        return WeightFormatAuto, nil
+    case "float32", "fp32":
+        return WeightFormatFloat32, nil
+    case "bfloat16", "bf16":
+        return WeightFormatBFloat16, nil
+    }
+    return WeightFormatAuto, fmt.Errorf("unknown weight format %q (use auto, float32 or bfloat16)", name)
+}
+
 // WeightLoader handles loading of model weights from files
 type WeightLoader struct {
     weightsPath string
     byteOrder   binary.ByteOrder
+    format      WeightFormat
 }
 
 // NewWeightLoader creates a new weight loader
+// Files may hold float32 or bfloat16 values; the encoding is detected from each file's size.
+// Convolution kernels may also be quantized int8 files, recognised by their header.
 func NewWeightLoader(weightsPath string) *WeightLoader {
     return &WeightLoader{
         weightsPath: weightsPath,
         byteOrder:   binary.LittleEndian, // Match original C implementation
+        format:      WeightFormatAuto,
     }
 }
 
+// SetFormat forces the encoding expected in weight files instead of detecting it
+func (wl *WeightLoader) SetFormat(format WeightFormat) {
+    wl.format = format
+}
+
 // LoadKernel loads convolution kernel weights from a binary file
+// File order is [size][size][channels][filters], matching the original C implementation.
+// Quantized files are dequantized to float32.
 func (wl *WeightLoader) LoadKernel(filename string, size, channels, filters int) (*tensor.Kernel, error) {
-    fullPath := filepath.Join(wl.weightsPath, filename)
-    
-    // Open file
-    file, err := os.Open(fullPath)
+    kernel, qk, err := wl.LoadConvKernel(filename, size, channels, filters)
     if err != nil {
-        return nil, fmt.Errorf("failed to open kernel file %s: %w", fullPath, err)
+        return nil, err
     }
-    defer file.Close()
-    
-    // Get file size
-    fileInfo, err := file.Stat()
+    if qk != nil {
+        return qk.ToKernel(), nil
+    }
+    return kernel, nil
+}
+
+// LoadConvKernel loads a convolution kernel as it is stored: float32 and bfloat16
+// files give a Kernel, quantized files (see quant.DecodeKernelFile) a QuantizedKernel.
+// Exactly one of the two results is non-nil when the error is nil.
+func (wl *WeightLoader) LoadConvKernel(filename string, size, channels, filters int) (*tensor.Kernel, 
+    *quant.QuantizedKernel, error) {
+    raw, err := wl.readFile(filename)
     if err != nil {
-        return nil, fmt.Errorf("failed to get file info for %s: %w", fullPath, err)
+        return nil, nil, fmt.Errorf("failed to load kernel: %w", err)
     }
     
-    // Validate file size
-    expectedElements := size * size * channels * filters
-    expectedBytes := int64(expectedElements * 4) // 4 bytes per float32
+    if quant.IsQuantizedFile(raw) {
+        qk, err := quant.DecodeKernelFile(raw, size, channels, filters)
+        if err != nil {
+            return nil, nil, fmt.Errorf("failed to load quantized kernel %s: %w", filename, err)
+        }
+        return nil, qk, nil
+    }
     
-    if fileInfo.Size() != expectedBytes {
-        return nil, fmt.Errorf("kernel file %s has wrong size: expected %d bytes, got %d bytes", 
-            filename, expectedBytes, fileInfo.Size())
+    values, err := wl.decodeFloatArray(filename, raw, size*size*channels*filters)
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to load kernel: %w", err)
     }
     
-    // Create kernel
+    // Reorder to the kernel's [filters][channels][size][size] layout
     kernel := tensor.NewKernel(size, channels, filters)
-    
-    // Read weights in the correct order: [size][size][channels][filters]
-    // This matches the original C implementation's file format
+    idx := 0
     for h := 0; h < size; h++ {
         for w := 0; w < size; w++ {
             for c := 0; c < channels; c++ {
                 for f := 0; f < filters; f++ {
-                    var weight float32
-                    err := binary.Read(file, wl.byteOrder, &weight)
-                    if err != nil {
-                        return nil, fmt.Errorf("failed to read weight at (%d,%d,%d,%d) from %s: %w", 
-                            h, w, c, f, filename, err)
-                    }
-                    
-                    kernel.SetWeight(f, c, h, w, weight)
+                    kernel.SetWeight(f, c, h, w, values[idx])
+                    idx++
                 }
             }
         }
@@ -73,41 +126,66 @@ func (wl *WeightLoader) LoadKernel(filename string, size, channels, filters int)
     // Validate loaded kernel
     err = tensor.ValidateKernel(kernel)
     if err != nil {
-        return nil, fmt.Errorf("loaded kernel failed validation: %w", err)
+        return nil, nil, fmt.Errorf("loaded kernel failed validation: %w", err)
     }
     
-    return kernel, nil
+    return kernel, nil, nil
 }
 
-// LoadBias loads bias values from a binary file
-func (wl *WeightLoader) LoadBias(filename string, filters int) ([]float32, error) {
-    fullPath := filepath.Join(wl.weightsPath, filename)
-    
-    file, err := os.Open(fullPath)
+// LoadKernel1D loads 1D convolution kernel weights from a binary file
+// File order is [size][channels][filters], the 1D analogue of LoadKernel
+func (wl *WeightLoader) LoadKernel1D(filename string, size, channels, filters int) (*tensor.Kernel1D, error) {
+    raw, err := wl.loadFloatArray(filename, size*channels*filters)
     if err != nil {
-        return nil, fmt.Errorf("failed to open bias file %s: %w", fullPath, err)
+        return nil, fmt.Errorf("failed to load 1D kernel: %w", err)
+    }
+    
+    kernel := tensor.NewKernel1D(size, channels, filters)
+    idx := 0
+    for k := 0; k < size; k++ {
+        for c := 0; c < channels; c++ {
+            for f := 0; f < filters; f++ {
+                kernel.SetWeightUnsafe(f, c, k, raw[idx])
+                idx++
+            }
+        }
     }
-    defer file.Close()
     
-    // Validate file size
-    fileInfo, err := file.Stat()
+    return kernel, nil
+}
+
+// LoadKernel3D loads 3D convolution kernel weights from a binary file
+// File order is [depth][height][width][channels][filters]
+func (wl *WeightLoader) LoadKernel3D(filename string, size, channels, filters int) (*tensor.Kernel3D, error) {
+    raw, err := wl.loadFloatArray(filename, size*size*size*channels*filters)
     if err != nil {
-        return nil, fmt.Errorf("failed to get file info for %s: %w", fullPath, err)
+        return nil, fmt.Errorf("failed to load 3D kernel: %w", err)
     }
     
-    expectedBytes := int64(filters * 4) // 4 bytes per float32
-    if fileInfo.Size() != expectedBytes {
-        return nil, fmt.Errorf("bias file %s has wrong size: expected %d bytes, got %d bytes", 
-            filename, expectedBytes, fileInfo.Size())
+    kernel := tensor.NewKernel3D(size, channels, filters)
+    idx := 0
+    for d := 0; d < size; d++ {
+        for h := 0; h < size; h++ {
+            for w := 0; w < size; w++ {
+                for c := 0; c < channels; c++ {
+                    for f := 0; f < filters; f++ {
+                        kernel.SetWeightUnsafe(f, c, d, h, w, raw[idx])
+                        idx++
+                    }
+                }
+            }
+        }
     }
     
-    // Load bias values
-    bias := make([]float32, filters)
-    err = binary.Read(file, wl.byteOrder, bias)
+    return kernel, nil
+}
+
+// LoadBias loads bias values from a binary file
+func (wl *WeightLoader) LoadBias(filename string, filters int) ([]float32, error) {
+    bias, err := wl.loadFloatArray(filename, filters)
     if err != nil {
-        return nil, fmt.Errorf("failed to read bias from %s: %w", filename, err)
+        return nil, fmt.Errorf("failed to load bias: %w", err)
     }
-    
     return bias, nil
 }
 
@@ -125,7 +203,7 @@ func (wl *WeightLoader) LoadBatchNormParams(layerName string, channels int) (*Ba
     meanFile := fmt.Sprintf("%s_moving_mean.bin", layerName)
     mean, err := wl.loadFloatArray(meanFile, channels)
     if err != nil {
-        return nil, fmt.Errorf("failed to load mean for %s: %w", layerName, err)
+        return nil, batchNormError(fmt.Errorf("failed to load mean for %s: %w", layerName, err), layerName)
     }
     copy(params.Mean, mean)
     
@@ -133,7 +211,7 @@ func (wl *WeightLoader) LoadBatchNormParams(layerName string, channels int) (*Ba
     varianceFile := fmt.Sprintf("%s_moving_variance.bin", layerName)
     variance, err := wl.loadFloatArray(varianceFile, channels)
     if err != nil {
-        return nil, fmt.Errorf("failed to load variance for %s: %w", layerName, err)
+        return nil, batchNormError(fmt.Errorf("failed to load variance for %s: %w", layerName, err), layerName)
     }
     copy(params.Variance, variance)
     
@@ -141,7 +219,7 @@ func (wl *WeightLoader) LoadBatchNormParams(layerName string, channels int) (*Ba
     scaleFile := fmt.Sprintf("%s_gamma.bin", layerName)
     scale, err := wl.loadFloatArray(scaleFile, channels)
     if err != nil {
-        return nil, fmt.Errorf("failed to load scale for %s: %w", layerName, err)
+        return nil, batchNormError(fmt.Errorf("failed to load scale for %s: %w", layerName, err), layerName)
     }
     copy(params.Scale, scale)
     
@@ -149,45 +227,137 @@ func (wl *WeightLoader) LoadBatchNormParams(layerName string, channels int) (*Ba
     shiftFile := fmt.Sprintf("%s_beta.bin", layerName)
     shift, err := wl.loadFloatArray(shiftFile, channels)
     if err != nil {
-        return nil, fmt.Errorf("failed to load shift for %s: %w", layerName, err)
+        return nil, batchNormError(fmt.Errorf("failed to load shift for %s: %w", layerName, err), layerName)
     }
     copy(params.Shift, shift)
     
     return params, nil
 }
 
+// batchNormError adds an export hint when a batch norm file is missing
+func batchNormError(err error, layerName string) error {
+    if !errors.Is(err, fs.ErrNotExist) {
+        return err
+    }
+    return errs.WithHint(err, "export the Keras BatchNormalization layer's gamma, beta, moving_mean and moving_variance "+
+        "as %s_{gamma,beta,moving_mean,moving_variance}.bin (float32)", layerName)
+}
+
+// LoadLayerArray loads a named float32 array stored as <layer>/<layer>_<name>.bin,
+// the per-layer layout used by the conv weights
+func (wl *WeightLoader) LoadLayerArray(layerName, arrayName string, size int) ([]float32, error) {
+    filename := filepath.Join(layerName, fmt.Sprintf("%s_%s.bin", layerName, arrayName))
+    values, err := wl.loadFloatArray(filename, size)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load %s for %s: %w", arrayName, layerName, err)
+    }
+    return values, nil
+}
+
 // loadFloatArray is a helper function to load an array of floats
+// bfloat16 files are expanded to float32 as they are read
 func (wl *WeightLoader) loadFloatArray(filename string, size int) ([]float32, error) {
-    fullPath := filepath.Join(wl.weightsPath, filename)
-    
-    file, err := os.Open(fullPath)
+    raw, err := wl.readFile(filename)
     if err != nil {
-        return nil, fmt.Errorf("failed to open file %s: %w", fullPath, err)
+        return nil, err
     }
-    defer file.Close()
+    return wl.decodeFloatArray(filename, raw, size)
+}
+
+// readFile reads a weight file relative to the weights directory
+func (wl *WeightLoader) readFile(filename string) ([]byte, error) {
+    fullPath := filepath.Join(wl.weightsPath, filename)
     
-    // Validate file size
-    fileInfo, err := file.Stat()
+    raw, err := os.ReadFile(fullPath)
     if err != nil {
-        return nil, fmt.Errorf("failed to get file info for %s: %w", fullPath, err)
+        err = fmt.Errorf("failed to open file %s: %w", fullPath, err)
+        if errors.Is(err, fs.ErrNotExist) {
+            err = errs.WithHint(err, "check that -weights points at the exported weights directory, with one subdirectory per layer")
+        }
+        return nil, err
+    }
+    return raw, nil
+}
+
+// decodeFloatArray decodes size float32 or bfloat16 values from a weight file's bytes
+func (wl *WeightLoader) decodeFloatArray(filename string, raw []byte, size int) ([]float32, error) {
+    if quant.IsQuantizedFile(raw) {
+        err := fmt.Errorf("file %s is a quantized weight file", filename)
+        return nil, errs.WithHint(err, "only convolution kernels (convN_weight.bin) may be quantized; "+
+            "keep biases and batch norm parameters as float32")
     }
     
-    expectedBytes := int64(size * 4)
-    if fileInfo.Size() != expectedBytes {
-        return nil, fmt.Errorf("file %s has wrong size: expected %d bytes, got %d bytes", 
-            filename, expectedBytes, fileInfo.Size())
+    format, err := wl.detectFormat(filename, int64(len(raw)), size)
+    if err != nil {
+        return nil, err
     }
     
-    // Load data
+    // Decode in one pass over the bytes already in memory
     data := make([]float32, size)
-    err = binary.Read(file, wl.byteOrder, data)
-    if err != nil {
-        return nil, fmt.Errorf("failed to read data from %s: %w", filename, err)
+    switch format {
+    case WeightFormatBFloat16:
+        for i := range data {
+            data[i] = tensor.BFloat16(wl.byteOrder.Uint16(raw[2*i:])).Float32()
+        }
+    default:
+        for i := range data {
+            data[i] = math.Float32frombits(wl.byteOrder.Uint32(raw[4*i:]))
+        }
     }
     
     return data, nil
 }
 
+// detectFormat checks a file's size against size elements and returns its encoding
+func (wl *WeightLoader) detectFormat(filename string, fileBytes int64, size int) (WeightFormat, error) {
+    float32Bytes := int64(size) * 4
+    bfloat16Bytes := int64(size) * 2
+    
+    switch wl.format {
+    case WeightFormatFloat32:
+        if fileBytes == float32Bytes {
+            return WeightFormatFloat32, nil
+        }
+        err := fmt.Errorf("file %s has wrong size: expected %d bytes, got %d bytes", 
+            filename, float32Bytes, fileBytes)
+        return 0, errs.WithHint(err, "%s", weightSizeHint(fileBytes, size))
+        
+    case WeightFormatBFloat16:
+        if fileBytes == bfloat16Bytes {
+            return WeightFormatBFloat16, nil
+        }
+        err := fmt.Errorf("file %s has wrong size: expected %d bytes of bfloat16, got %d bytes", 
+            filename, bfloat16Bytes, fileBytes)
+        return 0, errs.WithHint(err, "%s", weightSizeHint(fileBytes, size))
+    }
+    
+    switch fileBytes {
+    case float32Bytes:
+        return WeightFormatFloat32, nil
+    case bfloat16Bytes:
+        return WeightFormatBFloat16, nil
+    }
+    err := fmt.Errorf("file %s has wrong size: expected %d bytes (float32) or %d bytes (bfloat16), got %d bytes", 
+        filename, float32Bytes, bfloat16Bytes, fileBytes)
+    return 0, errs.WithHint(err, "%s", weightSizeHint(fileBytes, size))
+}
+
+// weightSizeHint guesses why a weight file has the wrong size
+func weightSizeHint(fileBytes int64, size int) string {
+    switch {
+    case fileBytes == int64(size)*8:
+        return "the file looks like float64 values; re-export it as float32 (e.g. numpy .astype(np.float32))"
+    case fileBytes == int64(size)*2:
+        return "the file looks like bfloat16 or float16 values; bfloat16 is read automatically unless a float32 format is forced"
+    case fileBytes == int64(size)*4:
+        return "the file looks like float32 values; do not force the bfloat16 format for it"
+    case fileBytes%4 == 0:
+        return fmt.Sprintf("the file holds %d float32 values but the layer needs %d; "+
+            "the weights were probably exported from a different architecture than the config describes", fileBytes/4, size)
+    }
+    return "the file size is not a whole number of float32 values; the export may be truncated or corrupt"
+}
+
 // BatchNormParams holds batch normalization parameters
 type BatchNormParams struct {
     Mean     []float32
@@ -247,4 +417,84 @@ func GetWeightFilesInfo(weightsPath string) (map[string]int64, error) {
     })
     
     return files, err
-}
\ No newline at end of file
+}
+
+// SaveModelWeights writes weights to dir in the layout LoadModelWeights reads:
+// convN/convN_{weight,bias}.bin and batchnormN/bnN_{gamma,beta,moving_mean,
+// moving_variance}.bin for every conv layer N with batch norm, as float32.
+// Kernels are written in file order [size][size][channels][filters]; int8
+// kernels are written as quantized files.
+func SaveModelWeights(dir string, weights *ModelWeights) error {
+    write := func(name string, raw []byte) error {
+        path := filepath.Join(dir, name)
+        if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+            return fmt.Errorf("failed to create weights directory: %w", err)
+        }
+        if err := os.WriteFile(path, raw, 0644); err != nil {
+            return fmt.Errorf("failed to write weight file: %w", err)
+        }
+        return nil
+    }
+
+    for i := range weights.Kernels {
+        layer := fmt.Sprintf("conv%d", i+1)
+        var kernelFile []byte
+        if weights.IsQuantized(i) {
+            kernelFile = quant.EncodeKernelFile(weights.QuantKernels[i])
+        } else {
+            kernel := weights.Kernels[i]
+            if kernel == nil {
+                return fmt.Errorf("%s has no kernel to save", layer)
+            }
+            values := make([]float32, 0, len(kernel.Weights))
+            for h := 0; h < kernel.Size; h++ {
+                for w := 0; w < kernel.Size; w++ {
+                    for c := 0; c < kernel.Channels; c++ {
+                        for f := 0; f < kernel.Filters; f++ {
+                            values = append(values, kernel.GetWeightUnsafe(f, c, h, w))
+                        }
+                    }
+                }
+            }
+            kernelFile = encodeFloatArray(values)
+        }
+        if err := write(filepath.Join(layer, layer+"_weight.bin"), kernelFile); err != nil {
+            return err
+        }
+        if i < len(weights.Biases) {
+            if err := write(filepath.Join(layer, layer+"_bias.bin"), encodeFloatArray(weights.Biases[i])); err != nil {
+                return err
+            }
+        }
+
+        if i >= len(weights.BatchNorms) || weights.BatchNorms[i] == nil {
+            continue
+        }
+        bn := weights.BatchNorms[i]
+        bnDir := fmt.Sprintf("batchnorm%d", i+1)
+        for _, array := range []struct {
+            name   string
+            values []float32
+        }{
+            {"gamma", bn.Scale},
+            {"beta", bn.Shift},
+            {"moving_mean", bn.Mean},
+            {"moving_variance", bn.Variance},
+        } {
+            name := filepath.Join(bnDir, fmt.Sprintf("bn%d_%s.bin", i+1, array.name))
+            if err := write(name, encodeFloatArray(array.values)); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// encodeFloatArray returns values as little-endian float32
+func encodeFloatArray(values []float32) []byte {
+    raw := make([]byte, 4*len(values))
+    for i, v := range values {
+        binary.LittleEndian.PutUint32(raw[4*i:], math.Float32bits(v))
+    }
+    return raw
+}
@@ -0,0 +1,484 @@
+package dump
+
+import (
+	"compress/gzip"
+	"duchm1606/gocnn/internal/tensor"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/**
+* Activation dumps
+
+Dumping every layer's output is the quickest way to find where two builds (or
+a Go run and the Keras reference) start to disagree, but float32 dumps grow
+fast: conv1-conv6 of TinyCNN hold about 450 KB per image, so a few hundred
+images take over 100 MB and the full 10,000-image CIFAR-10 test set reaches
+4.5 GB.
+
+A Writer stores each activation as a headerless little-endian array, either
+float32 or float16 (half the size, ~3 significant digits, which is plenty to
+spot a diverging layer), optionally compressed with zstd (.zst) or gzip
+(.gz). Post-ReLU activations are mostly zeros and compress well; zstd
+compresses them several times faster than gzip at a similar ratio, so it
+keeps up with inference on large runs. The shape, layout, encoding and file of
+every array is recorded in manifest.json so dumps can be read back without
+knowing the model.
+
+With FormatNPY each file is instead a NumPy .npy array: a short header with
+the dtype and shape in the activation's layout, then the same data, so
+np.load can read a layer directly to diff it against a Python reference:
+```
+sample-000000/conv1.float32.npy   '<f4', shape (32, 32, 32) for HWC
+```
+*/
+
+// ManifestName is the file name of the manifest inside a dump directory
+const ManifestName = "manifest.json"
+
+// manifestVersion is bumped whenever the on-disk format changes
+const manifestVersion = 1
+
+// Compression selects how activation files are compressed
+type Compression int
+
+const (
+    CompressionNone Compression = iota // Raw arrays
+    CompressionGzip                    // gzip (DEFLATE) at default level
+    CompressionZstd                    // Zstandard at default level
+)
+
+// String returns the flag name of the compression
+func (c Compression) String() string {
+    switch c {
+    case CompressionNone:
+        return "none"
+    case CompressionGzip:
+        return "gzip"
+    case CompressionZstd:
+        return "zstd"
+    default:
+        return fmt.Sprintf("Compression(%d)", int(c))
+    }
+}
+
+// extension returns the file suffix for compressed files
+func (c Compression) extension() string {
+    switch c {
+    case CompressionGzip:
+        return ".gz"
+    case CompressionZstd:
+        return ".zst"
+    }
+    return ""
+}
+
+// ParseCompression converts a flag name such as "gzip" to a Compression
+// An empty name selects no compression
+func ParseCompression(name string) (Compression, error) {
+    switch strings.ToLower(strings.TrimSpace(name)) {
+    case "", "none", "off":
+        return CompressionNone, nil
+    case "gzip", "gz":
+        return CompressionGzip, nil
+    case "zstd", "zst":
+        return CompressionZstd, nil
+    }
+    return CompressionNone, fmt.Errorf("unknown compression %q (use none, zstd or gzip)", name)
+}
+
+// Format selects the file format of dumped activations
+type Format int
+
+const (
+    FormatRaw Format = iota // Headerless little-endian arrays
+    FormatNPY               // NumPy .npy arrays, readable with np.load
+)
+
+// String returns the flag name of the format
+func (f Format) String() string {
+    switch f {
+    case FormatRaw:
+        return "raw"
+    case FormatNPY:
+        return "npy"
+    default:
+        return fmt.Sprintf("Format(%d)", int(f))
+    }
+}
+
+// extension returns the file suffix of the format, before any compression suffix
+func (f Format) extension() string {
+    if f == FormatNPY {
+        return ".npy"
+    }
+    return ""
+}
+
+// ParseFormat converts a flag name such as "npy" to a Format
+// An empty name selects raw arrays
+func ParseFormat(name string) (Format, error) {
+    switch strings.ToLower(strings.TrimSpace(name)) {
+    case "", "raw":
+        return FormatRaw, nil
+    case "npy":
+        return FormatNPY, nil
+    }
+    return FormatRaw, fmt.Errorf("unknown dump format %q (use raw or npy)", name)
+}
+
+// Options configures a Writer
+type Options struct {
+    Dir         string           // Output directory, created if missing
+    Layers      []string         // Layer names to dump; empty dumps every layer
+    Precision   tensor.Precision // Value encoding on disk
+    Compression Compression      // File compression
+    Format      Format           // File format
+}
+
+// Entry describes one dumped activation
+type Entry struct {
+    Sample   int    `json:"sample"`
+    Layer    string `json:"layer"`
+    File     string `json:"file"` // Relative to the dump directory
+    Height   int    `json:"height"`
+    Width    int    `json:"width"`
+    Channels int    `json:"channels"`
+    Layout   string `json:"layout"`
+    Bytes    int64  `json:"bytes"`     // Size on disk
+    RawBytes int64  `json:"raw_bytes"` // Size as uncompressed float32
+}
+
+// Manifest lists every activation in a dump directory
+type Manifest struct {
+    Version     int     `json:"version"`
+    Precision   string  `json:"precision"`
+    Compression string  `json:"compression"`
+    Format      string  `json:"format,omitempty"` // Empty in dumps written before formats, which are raw
+    Bytes       int64   `json:"bytes"`
+    RawBytes    int64   `json:"raw_bytes"`
+    Entries     []Entry `json:"entries"`
+}
+
+// Ratio returns how many times smaller the dump is than raw float32
+func (m *Manifest) Ratio() float64 {
+    if m.Bytes == 0 {
+        return 0
+    }
+    return float64(m.RawBytes) / float64(m.Bytes)
+}
+
+// Writer writes activations to a dump directory
+// It is safe for concurrent use, so it can be shared by parallel Predict calls.
+type Writer struct {
+    opts     Options
+    layers   map[string]bool
+
+    mu       sync.Mutex
+    samples  int
+    manifest Manifest
+}
+
+// NewWriter creates the dump directory and returns a Writer for it
+func NewWriter(opts Options) (*Writer, error) {
+    if opts.Dir == "" {
+        return nil, fmt.Errorf("dump directory is required")
+    }
+    if opts.Precision != tensor.PrecisionFloat32 && opts.Precision != tensor.PrecisionFloat16 {
+        return nil, fmt.Errorf("unsupported dump precision: %s", opts.Precision)
+    }
+    if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+        return nil, fmt.Errorf("failed to create dump directory: %w", err)
+    }
+
+    w := &Writer{
+        opts: opts,
+        manifest: Manifest{
+            Version:     manifestVersion,
+            Precision:   opts.Precision.String(),
+            Compression: opts.Compression.String(),
+            Format:      opts.Format.String(),
+        },
+    }
+    if len(opts.Layers) > 0 {
+        w.layers = make(map[string]bool, len(opts.Layers))
+        for _, name := range opts.Layers {
+            w.layers[name] = true
+        }
+    }
+    return w, nil
+}
+
+// Wants reports whether the named layer should be dumped
+func (w *Writer) Wants(layer string) bool {
+    return w.layers == nil || w.layers[layer]
+}
+
+// NextSample reserves the next sample number
+// Samples are numbered in the order their inference started.
+func (w *Writer) NextSample() int {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    sample := w.samples
+    w.samples++
+    return sample
+}
+
+// Write stores one activation of a sample
+func (w *Writer) Write(sample int, layer string, fm *tensor.FeatureMap) error {
+    name := filepath.Join(fmt.Sprintf("sample-%06d", sample),
+        layer+"."+w.opts.Precision.String()+w.opts.Format.extension()+w.opts.Compression.extension())
+    path := filepath.Join(w.opts.Dir, name)
+
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        return fmt.Errorf("failed to create sample directory: %w", err)
+    }
+
+    var header []byte
+    if w.opts.Format == FormatNPY {
+        header = npyHeader(fm, w.opts.Precision)
+    }
+    size, err := writeArray(path, header, fm.Data, w.opts.Precision, w.opts.Compression)
+    if err != nil {
+        return fmt.Errorf("failed to dump %s: %w", layer, err)
+    }
+
+    entry := Entry{
+        Sample:   sample,
+        Layer:    layer,
+        File:     filepath.ToSlash(name),
+        Height:   fm.Height,
+        Width:    fm.Width,
+        Channels: fm.Channels,
+        Layout:   fm.Layout.String(),
+        Bytes:    size,
+        RawBytes: int64(len(fm.Data)) * 4,
+    }
+
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    w.manifest.Entries = append(w.manifest.Entries, entry)
+    w.manifest.Bytes += entry.Bytes
+    w.manifest.RawBytes += entry.RawBytes
+    return nil
+}
+
+// Close writes the manifest and returns it
+// Entries are sorted by sample; within a sample they keep layer order.
+func (w *Writer) Close() (*Manifest, error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    sort.SliceStable(w.manifest.Entries, func(i, j int) bool {
+        return w.manifest.Entries[i].Sample < w.manifest.Entries[j].Sample
+    })
+
+    encoded, err := json.MarshalIndent(&w.manifest, "", "  ")
+    if err != nil {
+        return nil, err
+    }
+    if err := os.WriteFile(filepath.Join(w.opts.Dir, ManifestName), encoded, 0644); err != nil {
+        return nil, fmt.Errorf("failed to write dump manifest: %w", err)
+    }
+
+    manifest := w.manifest
+    return &manifest, nil
+}
+
+// npyMagic starts every .npy file, followed by the format version 1.0
+const npyMagic = "\x93NUMPY\x01\x00"
+
+// npyHeader returns the .npy version 1.0 header of an activation: the magic, the
+// header length and a Python dict literal giving the dtype and the shape in the
+// activation's layout, padded with spaces to a multiple of 64 bytes
+func npyHeader(fm *tensor.FeatureMap, precision tensor.Precision) []byte {
+    descr := "<f4"
+    if precision == tensor.PrecisionFloat16 {
+        descr = "<f2"
+    }
+    shape := fmt.Sprintf("(%d, %d, %d)", fm.Channels, fm.Height, fm.Width)
+    if fm.Layout == tensor.LayoutHWC {
+        shape = fmt.Sprintf("(%d, %d, %d)", fm.Height, fm.Width, fm.Channels)
+    }
+    dict := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': %s, }", descr, shape)
+
+    // The dict ends with a newline; magic, length, dict and padding fill whole 64-byte blocks
+    prefix := len(npyMagic) + 2
+    padding := 63 - (prefix+len(dict)+1+63)%64
+    dict += strings.Repeat(" ", padding) + "\n"
+
+    header := make([]byte, prefix, prefix+len(dict))
+    copy(header, npyMagic)
+    binary.LittleEndian.PutUint16(header[len(npyMagic):], uint16(len(dict)))
+    return append(header, dict...)
+}
+
+// skipNPYHeader reads past the .npy header at the start of in
+func skipNPYHeader(in io.Reader) error {
+    prefix := make([]byte, len(npyMagic)+2)
+    if _, err := io.ReadFull(in, prefix); err != nil {
+        return err
+    }
+    if string(prefix[:len(npyMagic)]) != npyMagic {
+        return fmt.Errorf("not a version 1.0 .npy file")
+    }
+    _, err := io.CopyN(io.Discard, in, int64(binary.LittleEndian.Uint16(prefix[len(npyMagic):])))
+    return err
+}
+
+// writeArray encodes values after header into path and returns the number of bytes written
+func writeArray(path string, header []byte, values []float32, precision tensor.Precision, compression Compression) (int64, error) {
+    buf := header
+    if precision == tensor.PrecisionFloat16 {
+        for _, v := range values {
+            buf = binary.LittleEndian.AppendUint16(buf, uint16(tensor.Float16FromFloat32(v)))
+        }
+    } else {
+        for _, v := range values {
+            buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+        }
+    }
+
+    file, err := os.Create(path)
+    if err != nil {
+        return 0, err
+    }
+
+    var out io.Writer = file
+    var zw io.WriteCloser
+    switch compression {
+    case CompressionGzip:
+        zw = gzip.NewWriter(file)
+    case CompressionZstd:
+        if zw, err = zstd.NewWriter(file); err != nil {
+            file.Close()
+            return 0, err
+        }
+    }
+    if zw != nil {
+        out = zw
+    }
+
+    if _, err := out.Write(buf); err != nil {
+        file.Close()
+        return 0, err
+    }
+    if zw != nil {
+        if err := zw.Close(); err != nil {
+            file.Close()
+            return 0, err
+        }
+    }
+
+    info, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return 0, err
+    }
+    return info.Size(), file.Close()
+}
+
+// ReadManifest loads the manifest of a dump directory
+func ReadManifest(dir string) (*Manifest, error) {
+    encoded, err := os.ReadFile(filepath.Join(dir, ManifestName))
+    if err != nil {
+        return nil, fmt.Errorf("failed to read dump manifest: %w", err)
+    }
+
+    var manifest Manifest
+    if err := json.Unmarshal(encoded, &manifest); err != nil {
+        return nil, fmt.Errorf("failed to parse dump manifest: %w", err)
+    }
+    if manifest.Version != manifestVersion {
+        return nil, fmt.Errorf("unsupported dump manifest version %d", manifest.Version)
+    }
+    return &manifest, nil
+}
+
+// Find returns the entry for a sample's layer
+func (m *Manifest) Find(sample int, layer string) (Entry, bool) {
+    for _, entry := range m.Entries {
+        if entry.Sample == sample && entry.Layer == layer {
+            return entry, true
+        }
+    }
+    return Entry{}, false
+}
+
+// ReadActivation loads one dumped activation as a float32 feature map
+func ReadActivation(dir string, manifest *Manifest, entry Entry) (*tensor.FeatureMap, error) {
+    precision, err := tensor.ParsePrecision(manifest.Precision)
+    if err != nil {
+        return nil, err
+    }
+    compression, err := ParseCompression(manifest.Compression)
+    if err != nil {
+        return nil, err
+    }
+    format, err := ParseFormat(manifest.Format)
+    if err != nil {
+        return nil, err
+    }
+    layout, err := tensor.ParseLayout(entry.Layout)
+    if err != nil {
+        return nil, err
+    }
+
+    file, err := os.Open(filepath.Join(dir, filepath.FromSlash(entry.File)))
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    var in io.Reader = file
+    switch compression {
+    case CompressionGzip:
+        zr, err := gzip.NewReader(file)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", entry.File, err)
+        }
+        defer zr.Close()
+        in = zr
+    case CompressionZstd:
+        zr, err := zstd.NewReader(file)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", entry.File, err)
+        }
+        defer zr.Close()
+        in = zr
+    }
+    if format == FormatNPY {
+        if err := skipNPYHeader(in); err != nil {
+            return nil, fmt.Errorf("%s: %w", entry.File, err)
+        }
+    }
+
+    fm := tensor.NewFeatureMap(entry.Height, entry.Width, entry.Channels)
+    fm.Layout = layout
+
+    buf := make([]byte, len(fm.Data)*precision.BytesPerValue())
+    if _, err := io.ReadFull(in, buf); err != nil {
+        return nil, fmt.Errorf("%s: %w", entry.File, err)
+    }
+
+    for i := range fm.Data {
+        if precision == tensor.PrecisionFloat16 {
+            fm.Data[i] = tensor.Float16(binary.LittleEndian.Uint16(buf[i*2:])).Float32()
+        } else {
+            fm.Data[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+        }
+    }
+    return fm, nil
+}
@@ -0,0 +1,190 @@
+package dump
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sparseActivation returns a post-ReLU-like feature map with many zeros
+func sparseActivation(layout tensor.Layout) *tensor.FeatureMap {
+    fm := tensor.NewFeatureMapWithLayout(16, 16, 8, layout)
+    for i := range fm.Data {
+        if i%3 == 0 {
+            fm.Data[i] = float32(i%97) * 0.0137
+        }
+    }
+    return fm
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+    tests := []struct {
+        precision   tensor.Precision
+        compression Compression
+        format      Format
+    }{
+        {tensor.PrecisionFloat32, CompressionNone, FormatRaw},
+        {tensor.PrecisionFloat32, CompressionGzip, FormatRaw},
+        {tensor.PrecisionFloat16, CompressionNone, FormatRaw},
+        {tensor.PrecisionFloat16, CompressionGzip, FormatRaw},
+        {tensor.PrecisionFloat16, CompressionZstd, FormatRaw},
+        {tensor.PrecisionFloat32, CompressionNone, FormatNPY},
+        {tensor.PrecisionFloat16, CompressionGzip, FormatNPY},
+        {tensor.PrecisionFloat32, CompressionZstd, FormatNPY},
+    }
+    
+    for _, tt := range tests {
+        t.Run(tt.precision.String()+"/"+tt.compression.String()+"/"+tt.format.String(), func(t *testing.T) {
+            dir := t.TempDir()
+            writer, err := NewWriter(Options{Dir: dir, Precision: tt.precision, Compression: tt.compression, Format: tt.format})
+            if err != nil {
+                t.Fatalf("NewWriter failed: %v", err)
+            }
+            
+            original := sparseActivation(tensor.LayoutHWC)
+            sample := writer.NextSample()
+            if err := writer.Write(sample, "conv1", original); err != nil {
+                t.Fatalf("Write failed: %v", err)
+            }
+            if _, err := writer.Close(); err != nil {
+                t.Fatalf("Close failed: %v", err)
+            }
+            
+            manifest, err := ReadManifest(dir)
+            if err != nil {
+                t.Fatalf("ReadManifest failed: %v", err)
+            }
+            entry, ok := manifest.Find(sample, "conv1")
+            if !ok {
+                t.Fatal("Entry missing from manifest")
+            }
+            if !strings.HasSuffix(entry.File, tt.format.extension()+tt.compression.extension()) {
+                t.Errorf("Unexpected file name %s", entry.File)
+            }
+            
+            info, err := os.Stat(filepath.Join(dir, entry.File))
+            if err != nil || info.Size() != entry.Bytes {
+                t.Fatalf("Manifest size %d does not match file: %v", entry.Bytes, err)
+            }
+            if entry.RawBytes != int64(len(original.Data))*4 {
+                t.Errorf("Expected raw size %d, got %d", len(original.Data)*4, entry.RawBytes)
+            }
+            
+            restored, err := ReadActivation(dir, manifest, entry)
+            if err != nil {
+                t.Fatalf("ReadActivation failed: %v", err)
+            }
+            if restored.Layout != tensor.LayoutHWC || restored.Height != 16 || restored.Channels != 8 {
+                t.Fatalf("Shape or layout not restored: %v %s", restored, restored.Layout)
+            }
+            
+            expected := original.Clone()
+            tt.precision.Round(expected.Data)
+            for i, v := range expected.Data {
+                if restored.Data[i] != v {
+                    t.Fatalf("Value %d: expected %f, got %f", i, v, restored.Data[i])
+                }
+            }
+        })
+    }
+}
+
+func TestNPYHeader(t *testing.T) {
+    dir := t.TempDir()
+    writer, err := NewWriter(Options{Dir: dir, Format: FormatNPY})
+    if err != nil {
+        t.Fatalf("NewWriter failed: %v", err)
+    }
+    if err := writer.Write(writer.NextSample(), "conv1", sparseActivation(tensor.LayoutCHW)); err != nil {
+        t.Fatalf("Write failed: %v", err)
+    }
+    manifest, err := writer.Close()
+    if err != nil {
+        t.Fatalf("Close failed: %v", err)
+    }
+    
+    if manifest.Entries[0].File != "sample-000000/conv1.float32.npy" {
+        t.Errorf("Unexpected file name %s", manifest.Entries[0].File)
+    }
+    content, err := os.ReadFile(filepath.Join(dir, manifest.Entries[0].File))
+    if err != nil {
+        t.Fatalf("ReadFile failed: %v", err)
+    }
+    
+    headerLen := len(content) - 16*16*8*4
+    if headerLen%64 != 0 || content[headerLen-1] != '\n' {
+        t.Fatalf("Header of %d bytes is not newline-terminated 64-byte blocks", headerLen)
+    }
+    if !strings.HasPrefix(string(content), npyMagic) {
+        t.Error("Missing .npy magic")
+    }
+    if dict := string(content[10:headerLen]); !strings.Contains(dict, "'descr': '<f4'") || !strings.Contains(dict, "'shape': (8, 16, 16)") {
+        t.Errorf("Unexpected header %q", dict)
+    }
+}
+
+func TestWriterShrinksDump(t *testing.T) {
+    dir := t.TempDir()
+    writer, err := NewWriter(Options{Dir: dir, Precision: tensor.PrecisionFloat16, Compression: CompressionGzip})
+    if err != nil {
+        t.Fatalf("NewWriter failed: %v", err)
+    }
+    for i := 0; i < 3; i++ {
+        if err := writer.Write(writer.NextSample(), "conv1", sparseActivation(tensor.LayoutCHW)); err != nil {
+            t.Fatalf("Write failed: %v", err)
+        }
+    }
+    manifest, err := writer.Close()
+    if err != nil {
+        t.Fatalf("Close failed: %v", err)
+    }
+    
+    // float16 alone halves the size; zeros compress well on top of that
+    if manifest.Ratio() <= 2 {
+        t.Errorf("Expected better than 2x reduction, got %.2fx", manifest.Ratio())
+    }
+}
+
+func TestWriterLayerFilter(t *testing.T) {
+    writer, err := NewWriter(Options{Dir: t.TempDir(), Layers: []string{"conv2"}})
+    if err != nil {
+        t.Fatalf("NewWriter failed: %v", err)
+    }
+    if writer.Wants("conv1") || !writer.Wants("conv2") {
+        t.Error("Only conv2 should be wanted")
+    }
+    
+    all, _ := NewWriter(Options{Dir: t.TempDir()})
+    if !all.Wants("anything") {
+        t.Error("An empty layer list should dump every layer")
+    }
+}
+
+func TestParseFormat(t *testing.T) {
+    for name, expected := range map[string]Format{"": FormatRaw, "raw": FormatRaw, "NPY": FormatNPY} {
+        got, err := ParseFormat(name)
+        if err != nil || got != expected {
+            t.Errorf("ParseFormat(%q) = %v, %v; want %v", name, got, err, expected)
+        }
+    }
+    if _, err := ParseFormat("npz"); err == nil {
+        t.Error("ParseFormat(\"npz\") should fail")
+    }
+}
+
+func TestParseCompression(t *testing.T) {
+    for name, expected := range map[string]Compression{"": CompressionNone, "none": CompressionNone, "GZIP": CompressionGzip,
+        "zstd": CompressionZstd} {
+        got, err := ParseCompression(name)
+        if err != nil || got != expected {
+            t.Errorf("ParseCompression(%q) = %v, %v; want %v", name, got, err, expected)
+        }
+    }
+    for _, name := range []string{"brotli", "lz4"} {
+        if _, err := ParseCompression(name); err == nil {
+            t.Errorf("ParseCompression(%q) should fail", name)
+        }
+    }
+}
@@ -0,0 +1,157 @@
+package dump
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/**
+* Embedding projector export
+
+The TensorBoard Embedding Projector (also at projector.tensorflow.org)
+shows high-dimensional vectors with PCA, t-SNE or UMAP, colored by any
+metadata column. It reads two TSV files, one line per point in the same
+order, and a config naming them:
+```
+vectors.tsv              0.0132	1.204	0.5	...           one vector per line
+metadata.tsv             label	file                   header, then one row per line
+                         cat	test/cat/0001.png
+projector_config.pbtxt   embeddings { tensor_path: "vectors.tsv" ... }
+```
+Loading the directory as a TensorBoard log dir picks up the config; the
+standalone projector takes the two TSV files through its "Load" button.
+*/
+
+// Projector file names inside the output directory
+const (
+    ProjectorVectorsName  = "vectors.tsv"
+    ProjectorMetadataName = "metadata.tsv"
+    ProjectorConfigName   = "projector_config.pbtxt"
+)
+
+// ProjectorWriter streams embeddings and their metadata to a projector directory
+type ProjectorWriter struct {
+    dir      string
+    name     string
+    columns  []string
+    dims     int
+    count    int
+    files    []*os.File
+    vectors  *bufio.Writer
+    metadata *bufio.Writer
+}
+
+// NewProjectorWriter creates dir and starts the projector files of the tensor
+// called name, whose points carry one metadata field per column
+func NewProjectorWriter(dir, name string, columns []string) (*ProjectorWriter, error) {
+    if len(columns) == 0 {
+        return nil, fmt.Errorf("projector metadata needs at least one column")
+    }
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, fmt.Errorf("failed to create projector directory: %w", err)
+    }
+
+    p := &ProjectorWriter{dir: dir, name: name, columns: columns}
+    for _, fileName := range []string{ProjectorVectorsName, ProjectorMetadataName} {
+        file, err := os.Create(filepath.Join(dir, fileName))
+        if err != nil {
+            p.closeFiles()
+            return nil, fmt.Errorf("failed to create %s: %w", fileName, err)
+        }
+        p.files = append(p.files, file)
+    }
+    p.vectors = bufio.NewWriter(p.files[0])
+    p.metadata = bufio.NewWriter(p.files[1])
+
+    // A single column has no header line in the projector's format
+    if len(columns) > 1 {
+        p.writeRow(columns)
+    }
+    return p, nil
+}
+
+// Add appends one point: its vector and a metadata field per column
+// Every vector must have the length of the first.
+func (p *ProjectorWriter) Add(vector []float32, fields ...string) error {
+    if len(fields) != len(p.columns) {
+        return fmt.Errorf("expected %d metadata fields, got %d", len(p.columns), len(fields))
+    }
+    if p.count == 0 {
+        p.dims = len(vector)
+    }
+    if len(vector) != p.dims || p.dims == 0 {
+        return fmt.Errorf("embedding %d has %d dimensions, expected %d", p.count, len(vector), p.dims)
+    }
+
+    for i, v := range vector {
+        if i > 0 {
+            p.vectors.WriteByte('\t')
+        }
+        p.vectors.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+    }
+    p.vectors.WriteByte('\n')
+    p.writeRow(fields)
+    p.count++
+    return nil
+}
+
+// writeRow writes fields as one metadata line, replacing the tabs and line
+// breaks they contain by spaces
+func (p *ProjectorWriter) writeRow(fields []string) {
+    for i, field := range fields {
+        if i > 0 {
+            p.metadata.WriteByte('\t')
+        }
+        p.metadata.WriteString(strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(field))
+    }
+    p.metadata.WriteByte('\n')
+}
+
+// Count returns the number of points added
+func (p *ProjectorWriter) Count() int {
+    return p.count
+}
+
+// Dims returns the length of the vectors, 0 before the first Add
+func (p *ProjectorWriter) Dims() int {
+    return p.dims
+}
+
+// Close flushes the TSV files and writes the projector config
+func (p *ProjectorWriter) Close() error {
+    var firstErr error
+    for _, w := range []*bufio.Writer{p.vectors, p.metadata} {
+        if err := w.Flush(); err != nil && firstErr == nil {
+            firstErr = err
+        }
+    }
+    if err := p.closeFiles(); err != nil && firstErr == nil {
+        firstErr = err
+    }
+    if firstErr != nil {
+        return fmt.Errorf("failed to write embeddings: %w", firstErr)
+    }
+
+    config := fmt.Sprintf("embeddings {\n  tensor_name: %q\n  tensor_path: %q\n  metadata_path: %q\n}\n",
+        p.name, ProjectorVectorsName, ProjectorMetadataName)
+    if err := os.WriteFile(filepath.Join(p.dir, ProjectorConfigName), []byte(config), 0644); err != nil {
+        return fmt.Errorf("failed to write projector config: %w", err)
+    }
+    return nil
+}
+
+// closeFiles closes every file opened so far, returning the first error
+func (p *ProjectorWriter) closeFiles() error {
+    var firstErr error
+    for _, file := range p.files {
+        if err := file.Close(); err != nil && firstErr == nil {
+            firstErr = err
+        }
+    }
+    p.files = nil
+    return firstErr
+}
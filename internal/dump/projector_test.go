@@ -0,0 +1,74 @@
+package dump
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProjectorWriter(t *testing.T) {
+    dir := filepath.Join(t.TempDir(), "projector")
+    writer, err := NewProjectorWriter(dir, "gocnn/maxpool3", []string{"label", "file"})
+    if err != nil {
+        t.Fatalf("NewProjectorWriter failed: %v", err)
+    }
+    if err := writer.Add([]float32{0.5, -1.25, 3}, "cat", "a\tb.png"); err != nil {
+        t.Fatalf("Add failed: %v", err)
+    }
+    if err := writer.Add([]float32{1, 2, 0}, "dog", "c.png"); err != nil {
+        t.Fatalf("Add failed: %v", err)
+    }
+    if err := writer.Add([]float32{1, 2}, "dog", "d.png"); err == nil {
+        t.Error("Expected error for a vector of another length")
+    }
+    if err := writer.Add([]float32{1, 2, 3}, "dog"); err == nil {
+        t.Error("Expected error for a missing metadata field")
+    }
+    if err := writer.Close(); err != nil {
+        t.Fatalf("Close failed: %v", err)
+    }
+    if writer.Count() != 2 || writer.Dims() != 3 {
+        t.Errorf("Expected 2 points of 3 dimensions, got %d of %d", writer.Count(), writer.Dims())
+    }
+
+    expected := map[string]string{
+        ProjectorVectorsName:  "0.5\t-1.25\t3\n1\t2\t0\n",
+        ProjectorMetadataName: "label\tfile\ncat\ta b.png\ndog\tc.png\n",
+    }
+    for name, want := range expected {
+        got, err := os.ReadFile(filepath.Join(dir, name))
+        if err != nil {
+            t.Fatalf("Failed to read %s: %v", name, err)
+        }
+        if string(got) != want {
+            t.Errorf("%s: expected %q, got %q", name, want, got)
+        }
+    }
+    config, err := os.ReadFile(filepath.Join(dir, ProjectorConfigName))
+    if err != nil {
+        t.Fatalf("Failed to read projector config: %v", err)
+    }
+    if !strings.Contains(string(config), `tensor_path: "vectors.tsv"`) ||
+        !strings.Contains(string(config), `tensor_name: "gocnn/maxpool3"`) {
+        t.Errorf("Unexpected projector config:\n%s", config)
+    }
+}
+
+func TestProjectorWriterSingleColumn(t *testing.T) {
+    dir := t.TempDir()
+    writer, err := NewProjectorWriter(dir, "embedding", []string{"label"})
+    if err != nil {
+        t.Fatalf("NewProjectorWriter failed: %v", err)
+    }
+    writer.Add([]float32{1}, "cat")
+    if err := writer.Close(); err != nil {
+        t.Fatalf("Close failed: %v", err)
+    }
+
+    // The projector reads a single column without a header
+    metadata, _ := os.ReadFile(filepath.Join(dir, ProjectorMetadataName))
+    if string(metadata) != "cat\n" {
+        t.Errorf("Expected no header line, got %q", metadata)
+    }
+}
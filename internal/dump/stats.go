@@ -0,0 +1,291 @@
+package dump
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+)
+
+/**
+* Activation statistics
+
+A dump keeps every value; statistics keep a summary per layer that stays a
+few kilobytes however many images run: range, mean, standard deviation, the
+fraction of exact zeros and a histogram. That is enough to spot a layer
+whose ReLUs never fire (a channel that is never positive is listed as dead)
+and to choose int8 activation ranges from more images than a dump could hold.
+
+The histogram range is not known before the first image, so it grows as
+values arrive. It starts on the first image's range; a value outside it
+doubles the bin width, merging neighbouring bins pairwise, until the range
+covers it:
+```
+[0, 4)  4 bins of 1:  | 3 | 1 | 0 | 2 |     value 6 arrives
+[0, 8)  4 bins of 2:  |   4   |   2   |   0   |   0   |
+```
+Counts are never split, so the histogram is exact at its final resolution.
+NaN and ±Inf have no bin and would stretch the range without end, so they
+are left out of the range, moments and histogram and counted in NonFinite.
+*/
+
+// DefaultStatsBins is the number of histogram bins of NewStatsCollector(0)
+const DefaultStatsBins = 64
+
+// Histogram counts values in equal-width bins starting at Min
+type Histogram struct {
+    Min    float64 `json:"min"`   // Lower edge of the first bin
+    Width  float64 `json:"width"` // Width of every bin
+    Counts []int64 `json:"counts"`
+}
+
+// Max returns the upper edge of the last bin
+func (h *Histogram) Max() float64 {
+    return h.Min + float64(len(h.Counts))*h.Width
+}
+
+// cover doubles the bin width until [lo, hi] lies inside the histogram
+// Growing upwards keeps Min; growing downwards keeps the upper edge. Non-finite
+// bounds are ignored.
+func (h *Histogram) cover(lo, hi float64) {
+    if !isFinite(lo) || !isFinite(hi) {
+        return
+    }
+    n := len(h.Counts)
+    for lo < h.Min || hi > h.Max() {
+        merged := make([]int64, n)
+        offset := 0
+        if lo < h.Min {
+            offset = n / 2
+            h.Min -= float64(n) * h.Width
+        }
+        for i, count := range h.Counts {
+            merged[offset+i/2] += count
+        }
+        h.Counts = merged
+        h.Width *= 2
+    }
+}
+
+// add counts v, which must lie inside the histogram; NaN and ±Inf are skipped
+func (h *Histogram) add(v float64) {
+    if !isFinite(v) {
+        return
+    }
+    i := int((v - h.Min) / h.Width)
+    if i >= len(h.Counts) {
+        i = len(h.Counts) - 1 // v on the upper edge
+    }
+    h.Counts[i]++
+}
+
+// LayerStats summarizes the outputs of one layer over every sample
+type LayerStats struct {
+    Layer        string    `json:"layer"`
+    Height       int       `json:"height"`
+    Width        int       `json:"width"`
+    Channels     int       `json:"channels"`
+    Samples      int64     `json:"samples"` // Feature maps observed
+    Count        int64     `json:"count"`   // Finite values observed
+    NonFinite    int64     `json:"non_finite"` // NaN and ±Inf values, left out of every other field
+    Min          float64   `json:"min"`
+    Max          float64   `json:"max"`
+    Mean         float64   `json:"mean"`
+    Std          float64   `json:"std"`
+    ZeroFraction float64   `json:"zero_fraction"` // Fraction of values exactly 0
+    DeadChannels []int     `json:"dead_channels"` // Channels never positive in any sample
+    Histogram    Histogram `json:"histogram"`
+}
+
+// StatsReport is the JSON file written by StatsCollector.Save
+type StatsReport struct {
+    Version int          `json:"version"`
+    Samples int64        `json:"samples"` // Largest number of samples of any layer
+    Bins    int          `json:"bins"`
+    Layers  []LayerStats `json:"layers"` // In the order layers were first observed
+}
+
+// statsReportVersion is bumped whenever the JSON format changes
+const statsReportVersion = 1
+
+// layerAccumulator holds the running sums of one layer
+type layerAccumulator struct {
+    stats      LayerStats
+    sum        float64
+    sumSquares float64
+    zeros      int64
+    channelMax []float32
+}
+
+// StatsCollector accumulates per-layer activation statistics
+// Observe may be called from several goroutines at once.
+type StatsCollector struct {
+    bins   int
+    mu     sync.Mutex
+    order  []string
+    layers map[string]*layerAccumulator
+}
+
+// NewStatsCollector creates a collector whose histograms have bins bins, rounded
+// up to an even number (0 selects DefaultStatsBins)
+func NewStatsCollector(bins int) *StatsCollector {
+    if bins <= 0 {
+        bins = DefaultStatsBins
+    }
+    bins += bins % 2 // Growing merges bins pairwise
+    return &StatsCollector{bins: bins, layers: make(map[string]*layerAccumulator)}
+}
+
+// Observe adds the values of fm, one sample's output of the named layer
+func (c *StatsCollector) Observe(layer string, fm *tensor.FeatureMap) {
+    if len(fm.Data) == 0 {
+        return
+    }
+
+    // Everything but the histogram is summed before taking the lock
+    lo, hi := math.Inf(1), math.Inf(-1)
+    var sum, sumSquares float64
+    var zeros, nonFinite int64
+    for _, v := range fm.Data {
+        if !isFinite(float64(v)) {
+            nonFinite++
+            continue
+        }
+        lo = min(lo, float64(v))
+        hi = max(hi, float64(v))
+        sum += float64(v)
+        sumSquares += float64(v) * float64(v)
+        if v == 0 {
+            zeros++
+        }
+    }
+    channelMax := make([]float32, fm.Channels)
+    for ch := range channelMax {
+        channelMax[ch] = float32(math.Inf(-1))
+        for h := 0; h < fm.Height; h++ {
+            for w := 0; w < fm.Width; w++ {
+                if v := fm.GetUnsafe(ch, h, w); isFinite(float64(v)) {
+                    channelMax[ch] = max(channelMax[ch], v)
+                }
+            }
+        }
+    }
+
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    acc, ok := c.layers[layer]
+    if !ok {
+        acc = &layerAccumulator{
+            stats: LayerStats{
+                Layer: layer, Height: fm.Height, Width: fm.Width, Channels: fm.Channels,
+                Min: math.Inf(1), Max: math.Inf(-1),
+            },
+            channelMax: channelMax,
+        }
+        c.layers[layer] = acc
+        c.order = append(c.order, layer)
+    } else if len(acc.channelMax) != len(channelMax) {
+        return // A different shape under the same name; keep the first
+    }
+
+    s := &acc.stats
+    s.Samples++
+    s.Count += int64(len(fm.Data)) - nonFinite
+    s.NonFinite += nonFinite
+    if s.Count == 0 {
+        return // No finite value yet to range the histogram on
+    }
+    s.Min = math.Min(s.Min, lo)
+    s.Max = math.Max(s.Max, hi)
+    acc.sum += sum
+    acc.sumSquares += sumSquares
+    acc.zeros += zeros
+    for ch, v := range channelMax {
+        acc.channelMax[ch] = max(acc.channelMax[ch], v)
+    }
+
+    // The histogram starts on the first finite range
+    if s.Histogram.Counts == nil {
+        s.Histogram = Histogram{Min: lo, Width: binWidth(lo, hi, c.bins), Counts: make([]int64, c.bins)}
+    }
+    s.Histogram.cover(lo, hi)
+    for _, v := range fm.Data {
+        s.Histogram.add(float64(v))
+    }
+}
+
+// isFinite reports whether v is neither NaN nor ±Inf
+func isFinite(v float64) bool {
+    return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// binWidth returns the initial bin width for values in [lo, hi]
+// A constant first sample still needs a positive width to grow from.
+func binWidth(lo, hi float64, bins int) float64 {
+    width := (hi - lo) / float64(bins)
+    if floor := 1e-6 * math.Max(1, math.Abs(lo)); width < floor {
+        width = floor
+    }
+    return width
+}
+
+// Report returns the statistics of every layer observed so far
+func (c *StatsCollector) Report() *StatsReport {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    report := &StatsReport{Version: statsReportVersion, Bins: c.bins, Layers: make([]LayerStats, len(c.order))}
+    for i, layer := range c.order {
+        acc := c.layers[layer]
+        s := acc.stats
+        s.Histogram.Counts = append([]int64(nil), s.Histogram.Counts...)
+        if s.Count > 0 {
+            n := float64(s.Count)
+            s.Mean = acc.sum / n
+            s.Std = math.Sqrt(math.Max(0, acc.sumSquares/n-s.Mean*s.Mean))
+            s.ZeroFraction = float64(acc.zeros) / n
+        } else {
+            s.Min, s.Max = 0, 0 // JSON has no infinities
+        }
+        s.DeadChannels = []int{}
+        for ch, v := range acc.channelMax {
+            if v <= 0 {
+                s.DeadChannels = append(s.DeadChannels, ch)
+            }
+        }
+        report.Layers[i] = s
+        report.Samples = max(report.Samples, s.Samples)
+    }
+    return report
+}
+
+// Save writes the report of every layer observed so far to path as JSON
+func (c *StatsCollector) Save(path string) error {
+    data, err := json.MarshalIndent(c.Report(), "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode activation statistics: %w", err)
+    }
+    if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+        return fmt.Errorf("failed to write activation statistics: %w", err)
+    }
+    return nil
+}
+
+// ReadStats reads a report written by StatsCollector.Save
+func ReadStats(path string) (*StatsReport, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read activation statistics: %w", err)
+    }
+    var report StatsReport
+    if err := json.Unmarshal(data, &report); err != nil {
+        return nil, fmt.Errorf("failed to parse activation statistics %s: %w", path, err)
+    }
+    if report.Version != statsReportVersion {
+        return nil, fmt.Errorf("activation statistics %s: unsupported version %d", path, report.Version)
+    }
+    return &report, nil
+}
@@ -0,0 +1,151 @@
+package dump
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestHistogramCover(t *testing.T) {
+    h := Histogram{Min: 0, Width: 1, Counts: []int64{3, 1, 0, 2}}
+    h.cover(0, 6)
+    if h.Min != 0 || h.Width != 2 || h.Counts[0] != 4 || h.Counts[1] != 2 || h.Counts[2] != 0 {
+        t.Errorf("Growing up: got min %v width %v counts %v", h.Min, h.Width, h.Counts)
+    }
+
+    h = Histogram{Min: 0, Width: 1, Counts: []int64{3, 1, 0, 2}}
+    h.cover(-1, 2)
+    if h.Min != -4 || h.Max() != 4 || h.Counts[2] != 4 || h.Counts[3] != 2 {
+        t.Errorf("Growing down: got min %v width %v counts %v", h.Min, h.Width, h.Counts)
+    }
+}
+
+func TestStatsCollector(t *testing.T) {
+    collector := NewStatsCollector(7)
+    if collector.bins != 8 {
+        t.Fatalf("Expected bins rounded up to 8, got %d", collector.bins)
+    }
+
+    // Channel 1 is never positive; the second sample stretches the range
+    var values []float32
+    var wg sync.WaitGroup
+    for sample := 0; sample < 2; sample++ {
+        fm := tensor.NewFeatureMapWithLayout(2, 2, 3, tensor.LayoutHWC)
+        for h := 0; h < 2; h++ {
+            for w := 0; w < 2; w++ {
+                fm.Set(0, h, w, float32(h*2+w)*float32(sample*9+1))
+                fm.Set(2, h, w, -float32(w))
+            }
+        }
+        values = append(values, fm.Data...)
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            collector.Observe("relu", fm)
+        }()
+    }
+    wg.Wait()
+
+    var sum, sumSquares float64
+    lo, hi := math.Inf(1), math.Inf(-1)
+    zeros := 0
+    for _, v := range values {
+        sum += float64(v)
+        sumSquares += float64(v) * float64(v)
+        lo, hi = math.Min(lo, float64(v)), math.Max(hi, float64(v))
+        if v == 0 {
+            zeros++
+        }
+    }
+    mean := sum / float64(len(values))
+    std := math.Sqrt(sumSquares/float64(len(values)) - mean*mean)
+
+    report := collector.Report()
+    if report.Samples != 2 || len(report.Layers) != 1 {
+        t.Fatalf("Expected 2 samples of 1 layer, got %d of %d", report.Samples, len(report.Layers))
+    }
+    s := report.Layers[0]
+    if s.Min != lo || s.Max != hi || math.Abs(s.Mean-mean) > 1e-9 || math.Abs(s.Std-std) > 1e-9 {
+        t.Errorf("Got min %v max %v mean %v std %v, expected %v %v %v %v", s.Min, s.Max, s.Mean, s.Std, lo, hi, mean, std)
+    }
+    if s.ZeroFraction != float64(zeros)/float64(len(values)) {
+        t.Errorf("Expected zero fraction %v, got %v", float64(zeros)/float64(len(values)), s.ZeroFraction)
+    }
+    if len(s.DeadChannels) != 2 || s.DeadChannels[0] != 1 || s.DeadChannels[1] != 2 {
+        t.Errorf("Expected dead channels [1 2], got %v", s.DeadChannels)
+    }
+
+    // Every value lands in the bin covering it
+    expected := make([]int64, len(s.Histogram.Counts))
+    for _, v := range values {
+        i := min(int((float64(v)-s.Histogram.Min)/s.Histogram.Width), len(expected)-1)
+        expected[i]++
+    }
+    for i := range expected {
+        if s.Histogram.Counts[i] != expected[i] {
+            t.Fatalf("Histogram %v, expected %v", s.Histogram.Counts, expected)
+        }
+    }
+    if s.Histogram.Min > lo || s.Histogram.Max() < hi {
+        t.Errorf("Histogram [%v, %v) does not cover [%v, %v]", s.Histogram.Min, s.Histogram.Max(), lo, hi)
+    }
+
+    path := filepath.Join(t.TempDir(), "stats.json")
+    if err := collector.Save(path); err != nil {
+        t.Fatalf("Save failed: %v", err)
+    }
+    loaded, err := ReadStats(path)
+    if err != nil {
+        t.Fatalf("ReadStats failed: %v", err)
+    }
+    if loaded.Layers[0].Max != s.Max || len(loaded.Layers[0].Histogram.Counts) != 8 {
+        t.Errorf("Round trip changed the report: %+v", loaded.Layers[0])
+    }
+}
+
+func TestStatsCollectorConstantLayer(t *testing.T) {
+    collector := NewStatsCollector(0)
+    collector.Observe("zeros", tensor.NewFeatureMap(4, 4, 2))
+    s := collector.Report().Layers[0]
+    if s.ZeroFraction != 1 || s.Std != 0 || s.Histogram.Width <= 0 || s.Histogram.Counts[0] != 32 {
+        t.Errorf("Unexpected statistics of an all-zero layer: %+v", s)
+    }
+}
+
+func TestStatsCollectorNonFinite(t *testing.T) {
+    collector := NewStatsCollector(4)
+    fm := tensor.NewFeatureMap(2, 2, 2)
+    copy(fm.Data, []float32{1, 2, float32(math.NaN()), 3, float32(math.Inf(1)), 0, float32(math.Inf(-1)), 4})
+    collector.Observe("logits", fm)
+
+    // A map with nothing finite counts, but leaves the range alone
+    nan := tensor.NewFeatureMap(1, 1, 2)
+    nan.Data[0], nan.Data[1] = float32(math.NaN()), float32(math.NaN())
+    collector.Observe("logits", nan)
+    collector.Observe("empty", nan)
+
+    report := collector.Report()
+    s := report.Layers[0]
+    if s.Count != 5 || s.NonFinite != 5 || s.Samples != 2 {
+        t.Errorf("Expected 5 finite and 5 non-finite values in 2 samples, got %d, %d in %d", s.Count, s.NonFinite, s.Samples)
+    }
+    if s.Min != 0 || s.Max != 4 || s.Mean != 2 {
+        t.Errorf("Expected min 0, max 4 and mean 2 over the finite values, got %v, %v, %v", s.Min, s.Max, s.Mean)
+    }
+    var histogrammed int64
+    for _, count := range s.Histogram.Counts {
+        histogrammed += count
+    }
+    if histogrammed != 5 || math.IsInf(s.Histogram.Max(), 0) {
+        t.Errorf("Expected the 5 finite values in a finite histogram, got %v", s.Histogram)
+    }
+
+    if empty := report.Layers[1]; empty.Count != 0 || empty.NonFinite != 2 || empty.Min != 0 || empty.Max != 0 {
+        t.Errorf("Unexpected statistics of an all-NaN layer: %+v", empty)
+    }
+    if err := collector.Save(filepath.Join(t.TempDir(), "stats.json")); err != nil {
+        t.Errorf("Save failed: %v", err)
+    }
+}
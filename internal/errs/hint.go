@@ -0,0 +1,62 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+/**
+* Errors with remediation hints
+
+Most first-run failures are the same handful of mistakes: an image saved as
+uint8 bytes or 0-255 floats, weights exported from a different model than
+the config describes, a batch norm file left out of the export. The raw
+error ("expected 12288 bytes, got 3072 bytes") is accurate but leaves the
+user to work out what it means.
+
+A HintError keeps the original error text (and its wrapped causes for
+errors.Is/As) and adds one sentence saying what to do about it. The CLIs
+print the hint on its own line under the error.
+*/
+
+// HintError is an error carrying a suggestion for how to fix it
+type HintError struct {
+    Err  error
+    Hint string
+}
+
+// Error returns the message of the wrapped error
+func (e *HintError) Error() string {
+    return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *HintError) Unwrap() error {
+    return e.Err
+}
+
+// WithHint attaches a formatted remediation hint to err; a nil err stays nil
+func WithHint(err error, format string, args ...any) error {
+    if err == nil {
+        return nil
+    }
+    return &HintError{Err: err, Hint: fmt.Sprintf(format, args...)}
+}
+
+// Hint returns the outermost hint in err's chain, or "" if there is none
+func Hint(err error) string {
+    var hintErr *HintError
+    if errors.As(err, &hintErr) {
+        return hintErr.Hint
+    }
+    return ""
+}
+
+// Fprint writes "prefix: err" and, if the error carries one, the hint on the next line
+func Fprint(w io.Writer, prefix string, err error) {
+    fmt.Fprintf(w, "%s: %v\n", prefix, err)
+    if hint := Hint(err); hint != "" {
+        fmt.Fprintf(w, "  Hint: %s\n", hint)
+    }
+}
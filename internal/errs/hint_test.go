@@ -0,0 +1,46 @@
+package errs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+func TestWithHint(t *testing.T) {
+    if WithHint(nil, "ignored") != nil {
+        t.Error("A nil error should stay nil")
+    }
+    
+    base := fmt.Errorf("open weights/conv1: %w", fs.ErrNotExist)
+    err := fmt.Errorf("failed to load model: %w", WithHint(base, "check -weights (%s)", "weights"))
+    
+    if err.Error() != "failed to load model: open weights/conv1: file does not exist" {
+        t.Errorf("Hint should not change the message, got %q", err.Error())
+    }
+    if !errors.Is(err, fs.ErrNotExist) {
+        t.Error("The wrapped cause should still match errors.Is")
+    }
+    if got := Hint(err); got != "check -weights (weights)" {
+        t.Errorf("Wrong hint: %q", got)
+    }
+    if Hint(base) != "" {
+        t.Error("An error without a hint should report none")
+    }
+}
+
+func TestFprint(t *testing.T) {
+    var buf bytes.Buffer
+    Fprint(&buf, "Inference failed", WithHint(errors.New("bad size"), "pass -image-format uint8"))
+    expected := "Inference failed: bad size\n  Hint: pass -image-format uint8\n"
+    if buf.String() != expected {
+        t.Errorf("Got %q, expected %q", buf.String(), expected)
+    }
+    
+    buf.Reset()
+    Fprint(&buf, "Inference failed", errors.New("bad size"))
+    if buf.String() != "Inference failed: bad size\n" {
+        t.Errorf("Got %q without a hint", buf.String())
+    }
+}
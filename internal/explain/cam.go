@@ -0,0 +1,111 @@
+package explain
+
+import (
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/model"
+	"fmt"
+)
+
+/**
+* Class Activation Mapping
+
+CAM (Zhou et al., 2016) came before Grad-CAM and needs no gradient, but only
+works with one kind of head: a 1×1 conv classifier followed by global
+average pooling. The logit of class c is then the mean over positions of a
+weighted sum of the features f entering the classifier, so the weighted sum
+itself says how much each position contributed:
+```
+logit_c = mean over (i,j) of Σ_k w_ck · f_k[i,j] + b_c    w, b: conv7's kernel and bias
+cam_c   = Σ_k w_ck · f_k + b_c                          4×4 for maxpool3 of TinyCNN
+```
+The map comes from the forward activations and the classifier weights alone.
+The bundled TinyCNN ends in global max pooling instead, where one position
+decides each logit; CAM needs "global_average_pooling" as the layer after
+the classifier (and weights trained that way), Grad-CAM works with either.
+Like Grad-CAM's, the map keeps the positive evidence and is normalized to
+[0,1].
+*/
+
+// CAM computes the class activation map of class for imageData, in CHW order,
+// from the model's global-average-pooling head. A negative class explains the
+// predicted class. The map has the resolution of the classifier's input.
+func CAM(cnn *model.TinyCNN, imageData []float32, class int) (*Heatmap, error) {
+    t, err := forward(cnn, imageData)
+    if err != nil {
+        return nil, err
+    }
+    head, err := camHead(cnn, t)
+    if err != nil {
+        return nil, err
+    }
+    scores := t.scores()
+    if class < 0 {
+        class = t.predicted()
+    }
+    if class >= len(scores) {
+        return nil, fmt.Errorf("class %d out of range [0,%d)", class, len(scores))
+    }
+
+    kernel, err := cnn.ConvKernel(t.layers[head].Name)
+    if err != nil {
+        return nil, err
+    }
+    bias, err := cnn.ConvBias(t.layers[head].Name)
+    if err != nil {
+        return nil, err
+    }
+    features := t.inputs[head]
+    layer := "input"
+    if head > 0 {
+        layer = t.layers[head-1].Name
+    }
+
+    heatmap := &Heatmap{
+        Layer:  layer,
+        Class:  class,
+        Score:  scores[class],
+        Height: features.Height,
+        Width:  features.Width,
+        Values: make([]float32, features.Height*features.Width),
+    }
+    for i := range heatmap.Values {
+        heatmap.Values[i] = bias[class]
+    }
+    for k := 0; k < features.Channels; k++ {
+        weight := kernel.GetWeightUnsafe(class, k, 0, 0)
+        for i := 0; i < features.Height; i++ {
+            for j := 0; j < features.Width; j++ {
+                heatmap.Values[i*features.Width+j] += weight * features.GetUnsafe(k, i, j)
+            }
+        }
+    }
+    normalizePositive(heatmap.Values)
+    return heatmap, nil
+}
+
+// camHead returns the index of the 1×1 conv classifier whose output the logits
+// average, or an error if the model's head is not one CAM can read
+func camHead(cnn *model.TinyCNN, t *trace) (int, error) {
+    pooling := t.layers[t.logits]
+    if pooling.Type != model.GlobalAveragePoolingLayer {
+        err := fmt.Errorf("CAM needs a global average pooling head, %s is not one", pooling.Name)
+        return -1, errs.WithHint(err, "use Grad-CAM, which works with any head")
+    }
+    head := t.logits - 1
+    if head < 0 || t.layers[head].Type != model.ConvolutionLayer {
+        return -1, fmt.Errorf("CAM needs a conv classifier before %s", pooling.Name)
+    }
+    conv := t.layers[head]
+    if conv.KernelSize != 1 || conv.Stride != 1 || conv.Padding != 0 {
+        return -1, fmt.Errorf("CAM needs a 1×1 conv classifier, %s is %d×%d with stride %d and padding %d",
+            conv.Name, conv.KernelSize, conv.KernelSize, conv.Stride, conv.Padding)
+    }
+    bn, applyReLU, err := cnn.ConvEpilogue(conv.Name)
+    if err != nil {
+        return -1, err
+    }
+    if bn != nil || applyReLU {
+        return -1, fmt.Errorf("CAM needs a linear classifier, %s has batch norm or ReLU", conv.Name)
+    }
+    return head, nil
+}
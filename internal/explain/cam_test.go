@@ -0,0 +1,118 @@
+package explain
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/model"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// loadGAPModel loads the pretrained weights with the global max pool of the
+// head replaced by global average pooling
+func loadGAPModel(t *testing.T) *model.TinyCNN {
+    t.Helper()
+    cfg, err := config.Load(filepath.Join("..", "..", "configs", "cifar10.yaml"))
+    if err != nil {
+        t.Fatalf("Failed to load config: %v", err)
+    }
+    for i, layer := range cfg.Model.Layers {
+        if layer.Type == "global_max_pooling" {
+            cfg.Model.Layers[i].Type = "global_average_pooling"
+        }
+    }
+    cnn, err := model.NewTinyCNNFromConfig(filepath.Join("..", "..", "weights"), cfg.Model)
+    if err != nil {
+        t.Fatalf("Failed to load weights: %v", err)
+    }
+    return cnn
+}
+
+func TestCAM(t *testing.T) {
+    _, imageData := loadTestModel(t)
+    cnn := loadGAPModel(t)
+
+    heatmap, err := CAM(cnn, imageData, -1)
+    if err != nil {
+        t.Fatalf("CAM failed: %v", err)
+    }
+    if heatmap.Layer != "maxpool3" || heatmap.Height != 4 || heatmap.Width != 4 {
+        t.Errorf("Expected a 4x4 map on maxpool3, got %dx%d on %s", heatmap.Height, heatmap.Width, heatmap.Layer)
+    }
+
+    // Before clipping and normalization the map averages to the logit
+    tr, err := forward(cnn, imageData)
+    if err != nil {
+        t.Fatalf("forward failed: %v", err)
+    }
+    result, err := cnn.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Predict failed: %v", err)
+    }
+    if heatmap.Class != result.PredictedClass || heatmap.Class != tr.predicted() {
+        t.Errorf("Expected the predicted class %d, got %d", result.PredictedClass, heatmap.Class)
+    }
+    kernel, err := cnn.ConvKernel("conv7")
+    if err != nil {
+        t.Fatalf("ConvKernel failed: %v", err)
+    }
+    bias, err := cnn.ConvBias("conv7")
+    if err != nil {
+        t.Fatalf("ConvBias failed: %v", err)
+    }
+    features := tr.inputs[len(tr.inputs)-2]
+    raw := make([]float64, features.Height*features.Width)
+    var mean, maxValue float64
+    for i := range raw {
+        raw[i] = float64(bias[heatmap.Class])
+        for k := 0; k < features.Channels; k++ {
+            raw[i] += float64(kernel.GetWeightUnsafe(heatmap.Class, k, 0, 0)) *
+                float64(features.GetUnsafe(k, i/features.Width, i%features.Width))
+        }
+        mean += raw[i] / float64(len(raw))
+        maxValue = math.Max(maxValue, raw[i])
+    }
+    if math.Abs(mean-float64(heatmap.Score)) > 1e-4*math.Max(1, math.Abs(mean)) {
+        t.Errorf("Map averages to %f, expected the logit %f", mean, heatmap.Score)
+    }
+    for i, v := range heatmap.Values {
+        want := math.Max(raw[i], 0) / maxValue
+        if math.Abs(float64(v)-want) > 1e-5 {
+            t.Fatalf("Value %d: expected %f, got %f", i, want, v)
+        }
+    }
+
+    // The global max pool of the bundled head is not linear in the features
+    maxHead, _ := loadTestModel(t)
+    if _, err := CAM(maxHead, imageData, -1); err == nil {
+        t.Error("Expected an error for a global max pooling head")
+    }
+    if _, err := CAM(cnn, imageData, 10); err == nil {
+        t.Error("Expected an error for a class out of range")
+    }
+}
+
+func TestGradCAMThroughAveragePooling(t *testing.T) {
+    _, imageData := loadTestModel(t)
+    cnn := loadGAPModel(t)
+
+    // With a GAP head and a linear classifier, Grad-CAM on the classifier's input
+    // matches CAM up to scale: the gradient is w_ck / area at every position
+    tr, err := forward(cnn, imageData)
+    if err != nil {
+        t.Fatalf("forward failed: %v", err)
+    }
+    grad, err := tr.backward(cnn, 3, len(tr.layers)-4)
+    if err != nil {
+        t.Fatalf("backward failed: %v", err)
+    }
+    kernel, _ := cnn.ConvKernel("conv7")
+    area := float32(grad.Height * grad.Width)
+    for k := 0; k < grad.Channels; k++ {
+        want := kernel.GetWeightUnsafe(3, k, 0, 0) / area
+        if got := grad.GetUnsafe(k, 1, 2); math.Abs(float64(got-want)) > 1e-6 {
+            t.Fatalf("Channel %d: gradient %f, expected %f", k, got, want)
+        }
+    }
+}
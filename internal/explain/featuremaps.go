@@ -0,0 +1,97 @@
+package explain
+
+import (
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+/**
+* Feature map tiles
+
+Looking at a layer's outputs shows what it responds to: early channels light
+up on edges and colors of the image, later ones on larger parts, and
+channels that stay black for every image are dead. Every channel of a
+feature map is drawn as a grayscale tile, laid out like the filter tiles:
+```
+conv1 32×32×32, Scale 2:   6×6 grid of 64×64 tiles      black = min, white = max
+```
+Values are stretched between the smallest and largest of the whole layer,
+so channels can be compared, or of each channel with PerChannel.
+*/
+
+// FeatureMapOptions configures FeatureMapTiles
+type FeatureMapOptions struct {
+    Scale      int  // Output pixels per activation; 0 means 4
+    PerChannel bool // Stretch each channel to full contrast instead of the layer's range
+}
+
+// FeatureMapTiles draws every channel of fm as a grayscale tile in a grid
+func FeatureMapTiles(fm *tensor.FeatureMap, opts FeatureMapOptions) *image.RGBA {
+    if opts.Scale <= 0 {
+        opts.Scale = 4
+    }
+
+    img, tileOrigin := newTileGrid(fm.Channels, fm.Height, fm.Width, opts.Scale)
+    layerMin, layerMax := channelRange(fm, 0, fm.Channels)
+    for c := 0; c < fm.Channels; c++ {
+        low, high := layerMin, layerMax
+        if opts.PerChannel {
+            low, high = channelRange(fm, c, c+1)
+        }
+        valueRange := high - low
+        if valueRange == 0 {
+            valueRange = 1
+        }
+
+        left, top := tileOrigin(c)
+        for h := 0; h < fm.Height; h++ {
+            for w := 0; w < fm.Width; w++ {
+                v := uint8(math.Round(255 * float64((fm.GetUnsafe(c, h, w)-low)/valueRange)))
+                fillBlock(img, left+w*opts.Scale, top+h*opts.Scale, opts.Scale, color.RGBA{R: v, G: v, B: v, A: 255})
+            }
+        }
+    }
+    return img
+}
+
+// FeatureMaps runs imageData, in CHW order, through cnn and returns the output of
+// every layer with a spatial output, in architecture order, keyed by layer name
+// Global pooling and softmax outputs are one value per channel and left out.
+func FeatureMaps(cnn *model.TinyCNN, imageData []float32) ([]string, map[string]*tensor.FeatureMap, error) {
+    t, err := forward(cnn, imageData)
+    if err != nil {
+        return nil, nil, err
+    }
+    var names []string
+    maps := make(map[string]*tensor.FeatureMap)
+    for i, output := range t.outputs {
+        if output.Height*output.Width <= 1 {
+            continue
+        }
+        names = append(names, t.layers[i].Name)
+        maps[t.layers[i].Name] = output
+    }
+    if len(names) == 0 {
+        return nil, nil, fmt.Errorf("model has no layer with a spatial output")
+    }
+    return names, maps, nil
+}
+
+// channelRange returns the smallest and largest value of channels [from, to)
+func channelRange(fm *tensor.FeatureMap, from, to int) (float32, float32) {
+    low, high := float32(math.Inf(1)), float32(math.Inf(-1))
+    for c := from; c < to; c++ {
+        for h := 0; h < fm.Height; h++ {
+            for w := 0; w < fm.Width; w++ {
+                v := fm.GetUnsafe(c, h, w)
+                low = min(low, v)
+                high = max(high, v)
+            }
+        }
+    }
+    return low, high
+}
@@ -0,0 +1,51 @@
+package explain
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"testing"
+)
+
+func TestFeatureMapTiles(t *testing.T) {
+    // Channel 0 ramps from 0 to 3, channel 1 is constant 1
+    fm := tensor.NewFeatureMap(2, 2, 2)
+    for i := 0; i < 4; i++ {
+        fm.Set(0, i/2, i%2, float32(i))
+        fm.Set(1, i/2, i%2, 1)
+    }
+
+    img := FeatureMapTiles(fm, FeatureMapOptions{Scale: 4})
+    // 2 columns × 1 row of 8-pixel tiles with 1-pixel gaps
+    if bounds := img.Bounds(); bounds.Dx() != 2*9+1 || bounds.Dy() != 9+1 {
+        t.Fatalf("Expected a 19x10 image, got %v", bounds)
+    }
+    if black, white := img.RGBAAt(1, 1), img.RGBAAt(8, 8); black.R != 0 || white.R != 255 {
+        t.Errorf("Expected channel 0 from black to white, got %v and %v", black, white)
+    }
+    if gray := img.RGBAAt(10, 1); gray.R != 85 {
+        t.Errorf("Expected channel 1 at a third of the layer's range, got %v", gray)
+    }
+
+    img = FeatureMapTiles(fm, FeatureMapOptions{Scale: 4, PerChannel: true})
+    if flat := img.RGBAAt(10, 1); flat.R != 0 {
+        t.Errorf("Expected a constant channel black with PerChannel, got %v", flat)
+    }
+}
+
+func TestFeatureMaps(t *testing.T) {
+    cnn, imageData := loadTestModel(t)
+    names, maps, err := FeatureMaps(cnn, imageData)
+    if err != nil {
+        t.Fatalf("FeatureMaps failed: %v", err)
+    }
+    if len(names) != len(maps) || names[0] != "conv1" {
+        t.Fatalf("Expected conv1 first and a map per name, got %v", names)
+    }
+    if fm := maps["conv1"]; fm.Height != 32 || fm.Channels != 32 {
+        t.Errorf("Expected conv1 32x32x32, got %dx%dx%d", fm.Height, fm.Width, fm.Channels)
+    }
+    for _, name := range names {
+        if maps[name].Height*maps[name].Width <= 1 {
+            t.Errorf("Layer %s has no spatial output and should be left out", name)
+        }
+    }
+}
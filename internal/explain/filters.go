@@ -0,0 +1,113 @@
+package explain
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+/**
+* Filter tiles
+
+First-layer filters look at pixels directly, so their 3×3×3 weights can be
+drawn as tiny RGB images: edge detectors show up as stripes, color detectors
+as flat tinted squares, and dead or untrained filters as flat gray. Filters
+are laid out in a near-square grid, each weight scaled up to Scale×Scale
+pixels:
+```
+conv1, 32 filters:   6 columns × 6 rows of 3×3 tiles     value 0 → gray (128)
+pixel = 0.5 + 0.5 · w / max|w|                            per kernel, or per filter
+```
+A shared scale keeps the filters comparable (weak filters look washed out);
+PerFilter stretches each filter to full contrast to show its pattern.
+Kernels with one input channel are drawn in grayscale. Deeper filters have
+dozens of channels and no visual meaning; see WeightStats for those.
+*/
+
+// TileOptions configures FilterTiles
+type TileOptions struct {
+    Scale     int  // Output pixels per weight; 0 means 16
+    PerFilter bool // Normalize each filter by its own largest weight instead of the kernel's
+}
+
+// FilterTiles draws every filter of kernel, which must have one or three input
+// channels, as a tile in a grid
+func FilterTiles(kernel *tensor.Kernel, opts TileOptions) (*image.RGBA, error) {
+    if kernel.Channels != 1 && kernel.Channels != 3 {
+        return nil, fmt.Errorf("cannot draw filters with %d input channels, need 1 or 3", kernel.Channels)
+    }
+    if opts.Scale <= 0 {
+        opts.Scale = 16
+    }
+
+    img, tileOrigin := newTileGrid(kernel.Filters, kernel.Size, kernel.Size, opts.Scale)
+    kernelMax := maxAbsWeight(kernel, 0, kernel.Filters)
+    for f := 0; f < kernel.Filters; f++ {
+        scale := kernelMax
+        if opts.PerFilter {
+            scale = maxAbsWeight(kernel, f, f+1)
+        }
+        if scale == 0 {
+            scale = 1
+        }
+
+        left, top := tileOrigin(f)
+        for m := 0; m < kernel.Size; m++ {
+            for n := 0; n < kernel.Size; n++ {
+                var rgb [3]uint8
+                for c := range rgb {
+                    w := kernel.GetWeightUnsafe(f, c%kernel.Channels, m, n)
+                    rgb[c] = uint8(math.Round(255 * (0.5 + 0.5*float64(w)/scale)))
+                }
+                pixel := color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 255}
+                fillBlock(img, left+n*opts.Scale, top+m*opts.Scale, opts.Scale, pixel)
+            }
+        }
+    }
+    return img, nil
+}
+
+// newTileGrid returns an image for count tiles of height×width values, each drawn
+// as scale×scale pixels, in a near-square grid with gaps, and the top-left pixel of
+// every tile
+func newTileGrid(count, height, width, scale int) (*image.RGBA, func(i int) (int, int)) {
+    columns := int(math.Ceil(math.Sqrt(float64(count))))
+    rows := (count + columns - 1) / columns
+    gap := max(scale/4, 1)
+    tileWidth, tileHeight := width*scale, height*scale
+
+    img := image.NewRGBA(image.Rect(0, 0, columns*(tileWidth+gap)+gap, rows*(tileHeight+gap)+gap))
+    background := color.RGBA{R: 32, G: 32, B: 32, A: 255}
+    draw.Draw(img, img.Bounds(), &image.Uniform{C: background}, image.Point{}, draw.Src)
+
+    return img, func(i int) (int, int) {
+        return gap + (i%columns)*(tileWidth+gap), gap + (i/columns)*(tileHeight+gap)
+    }
+}
+
+// fillBlock paints the scale×scale block of pixel (left, top)
+func fillBlock(img *image.RGBA, left, top, scale int, pixel color.RGBA) {
+    for y := 0; y < scale; y++ {
+        for x := 0; x < scale; x++ {
+            img.SetRGBA(left+x, top+y, pixel)
+        }
+    }
+}
+
+// maxAbsWeight returns the largest |w| of filters [from, to)
+func maxAbsWeight(kernel *tensor.Kernel, from, to int) float64 {
+    var largest float64
+    for f := from; f < to; f++ {
+        for c := 0; c < kernel.Channels; c++ {
+            for m := 0; m < kernel.Size; m++ {
+                for n := 0; n < kernel.Size; n++ {
+                    largest = max(largest, math.Abs(float64(kernel.GetWeightUnsafe(f, c, m, n))))
+                }
+            }
+        }
+    }
+    return largest
+}
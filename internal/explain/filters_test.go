@@ -0,0 +1,52 @@
+package explain
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"testing"
+)
+
+func TestFilterTiles(t *testing.T) {
+    // Five 3×3 RGB filters: a red one, a zero one and three copies of a blue one at half strength
+    kernel := tensor.NewKernel(3, 3, 5)
+    for m := 0; m < 3; m++ {
+        for n := 0; n < 3; n++ {
+            kernel.SetWeight(0, 0, m, n, 1)
+            for f := 2; f < 5; f++ {
+                kernel.SetWeight(f, 2, m, n, 0.5)
+            }
+        }
+    }
+
+    img, err := FilterTiles(kernel, TileOptions{Scale: 4})
+    if err != nil {
+        t.Fatalf("FilterTiles failed: %v", err)
+    }
+    // 3 columns × 2 rows of 12-pixel tiles with 1-pixel gaps
+    if bounds := img.Bounds(); bounds.Dx() != 3*13+1 || bounds.Dy() != 2*13+1 {
+        t.Fatalf("Expected a 40x27 image, got %v", bounds)
+    }
+    if red := img.RGBAAt(1, 1); red.R != 255 || red.G != 128 || red.B != 128 {
+        t.Errorf("Expected filter 0 red, got %v", red)
+    }
+    if gray := img.RGBAAt(14, 1); gray.R != 128 || gray.G != 128 || gray.B != 128 {
+        t.Errorf("Expected filter 1 gray, got %v", gray)
+    }
+    if blue := img.RGBAAt(27, 1); blue.B != 191 {
+        t.Errorf("Expected filter 2 at half the kernel's strength, got %v", blue)
+    }
+    if gap := img.RGBAAt(0, 0); gap.R != 32 {
+        t.Errorf("Expected the background in the gaps, got %v", gap)
+    }
+
+    img, err = FilterTiles(kernel, TileOptions{Scale: 4, PerFilter: true})
+    if err != nil {
+        t.Fatalf("FilterTiles failed: %v", err)
+    }
+    if blue := img.RGBAAt(27, 1); blue.B != 255 {
+        t.Errorf("Expected filter 2 at full contrast with PerFilter, got %v", blue)
+    }
+
+    if _, err := FilterTiles(tensor.NewKernel(3, 32, 4), TileOptions{}); err == nil {
+        t.Error("FilterTiles of a 32-channel kernel should fail")
+    }
+}
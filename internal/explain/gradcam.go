@@ -0,0 +1,134 @@
+package explain
+
+import (
+	"duchm1606/gocnn/internal/model"
+	"fmt"
+)
+
+/**
+* Grad-CAM
+
+Grad-CAM (Selvaraju et al., 2017) shows which parts of an image a class
+score depends on. It weights each channel k of a conv layer's activations A
+by how much the score changes with it, the mean of its gradient, and keeps
+the positive evidence:
+```
+alpha_k = mean over (i,j) of d score / d A_k[i,j]
+cam     = ReLU(Σ_k alpha_k · A_k)           8×8 for conv6 of TinyCNN
+```
+The layer defaults to the last conv layer with a ReLU, conv6 in TinyCNN: the
+one before the 1×1 classifier, so the gradient flows back through conv7,
+maxpool3 and the global max pool. The map is normalized to [0,1] and has the
+layer's resolution; Overlay upsamples it onto the input image.
+*/
+
+// Heatmap is a class activation map, how much each position of a layer
+// contributed to a class score
+type Heatmap struct {
+    Layer  string    // Layer the map was computed on
+    Class  int       // Class explained
+    Score  float32   // Logit of Class; its probability for occlusion maps
+    Height int
+    Width  int
+    Values []float32 // Row-major, in [0,1]
+}
+
+// At returns the value at row y, column x
+func (h *Heatmap) At(y, x int) float32 {
+    return h.Values[y*h.Width+x]
+}
+
+// GradCAM computes the Grad-CAM heatmap of class for imageData, in CHW order,
+// on the named conv layer. A negative class explains the predicted class and an
+// empty layer selects the last conv layer with a ReLU.
+func GradCAM(cnn *model.TinyCNN, imageData []float32, class int, layer string) (*Heatmap, error) {
+    t, err := forward(cnn, imageData)
+    if err != nil {
+        return nil, err
+    }
+    if class < 0 {
+        class = t.predicted()
+    }
+    if layer == "" {
+        if layer, err = defaultCAMLayer(cnn, t); err != nil {
+            return nil, err
+        }
+    }
+    idx, err := t.layerIndex(layer)
+    if err != nil {
+        return nil, err
+    }
+    if t.layers[idx].Type != model.ConvolutionLayer {
+        return nil, fmt.Errorf("Grad-CAM needs a conv layer, %s is not one", layer)
+    }
+
+    grad, err := t.backward(cnn, class, idx)
+    if err != nil {
+        return nil, fmt.Errorf("Grad-CAM of class %d on %s: %w", class, layer, err)
+    }
+    activations := t.outputs[idx]
+
+    heatmap := &Heatmap{
+        Layer:  layer,
+        Class:  class,
+        Score:  t.scores()[class],
+        Height: activations.Height,
+        Width:  activations.Width,
+        Values: make([]float32, activations.Height*activations.Width),
+    }
+    area := float32(activations.Height * activations.Width)
+    for c := 0; c < activations.Channels; c++ {
+        var alpha float32
+        for i := 0; i < activations.Height; i++ {
+            for j := 0; j < activations.Width; j++ {
+                alpha += grad.GetUnsafe(c, i, j)
+            }
+        }
+        alpha /= area
+        if alpha == 0 {
+            continue
+        }
+        for i := 0; i < activations.Height; i++ {
+            for j := 0; j < activations.Width; j++ {
+                heatmap.Values[i*activations.Width+j] += alpha * activations.GetUnsafe(c, i, j)
+            }
+        }
+    }
+
+    normalizePositive(heatmap.Values)
+    return heatmap, nil
+}
+
+// normalizePositive clips negative values to 0 and scales the rest to [0,1]
+func normalizePositive(values []float32) {
+    var maxValue float32
+    for i, v := range values {
+        if v < 0 {
+            values[i] = 0
+        } else if v > maxValue {
+            maxValue = v
+        }
+    }
+    if maxValue > 0 {
+        for i := range values {
+            values[i] /= maxValue
+        }
+    }
+}
+
+// defaultCAMLayer returns the last conv layer before the logits whose output goes through ReLU
+func defaultCAMLayer(cnn *model.TinyCNN, t *trace) (string, error) {
+    for i := t.logits; i >= 0; i-- {
+        if t.layers[i].Type != model.ConvolutionLayer {
+            continue
+        }
+        _, applyReLU, err := cnn.ConvEpilogue(t.layers[i].Name)
+        if err != nil {
+            return "", err
+        }
+        if applyReLU {
+            return t.layers[i].Name, nil
+        }
+    }
+    return "", fmt.Errorf("model has no conv layer with a ReLU for Grad-CAM")
+}
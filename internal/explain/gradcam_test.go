@@ -0,0 +1,150 @@
+package explain
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/model"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// loadTestModel loads the pretrained weights and the airplane test image, CHW float32
+func loadTestModel(t *testing.T) (*model.TinyCNN, []float32) {
+    t.Helper()
+    cnn, err := model.NewTinyCNN(filepath.Join("..", "..", "weights"))
+    if err != nil {
+        t.Fatalf("Failed to load weights: %v", err)
+    }
+    raw, err := os.ReadFile(filepath.Join("..", "..", "testdata", "airplane.bin"))
+    if err != nil {
+        t.Fatalf("Failed to read test image: %v", err)
+    }
+    imageData := make([]float32, len(raw)/4)
+    for i := range imageData {
+        imageData[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:]))
+    }
+    return cnn, imageData
+}
+
+func TestGradCAM(t *testing.T) {
+    cnn, imageData := loadTestModel(t)
+    result, err := cnn.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Predict failed: %v", err)
+    }
+
+    heatmap, err := GradCAM(cnn, imageData, -1, "")
+    if err != nil {
+        t.Fatalf("GradCAM failed: %v", err)
+    }
+    if heatmap.Layer != "conv6" || heatmap.Class != result.PredictedClass {
+        t.Errorf("Expected class %d on conv6, got class %d on %s", result.PredictedClass, heatmap.Class, heatmap.Layer)
+    }
+    if heatmap.Height != 8 || heatmap.Width != 8 || len(heatmap.Values) != 64 {
+        t.Fatalf("Expected an 8x8 heatmap, got %dx%d with %d values", heatmap.Height, heatmap.Width, len(heatmap.Values))
+    }
+
+    var maxValue float32
+    for _, v := range heatmap.Values {
+        if v < 0 || v > 1 {
+            t.Fatalf("Heatmap value %f outside [0,1]", v)
+        }
+        maxValue = max(maxValue, v)
+    }
+    if maxValue != 1 {
+        t.Errorf("Expected the heatmap normalized to a maximum of 1, got %f", maxValue)
+    }
+
+    other, err := GradCAM(cnn, imageData, 0, "conv4")
+    if err != nil {
+        t.Fatalf("GradCAM on conv4 failed: %v", err)
+    }
+    if other.Class != 0 || other.Height != 16 {
+        t.Errorf("Expected class 0 on a 16x16 map, got class %d on %dx%d", other.Class, other.Height, other.Width)
+    }
+
+    if _, err := GradCAM(cnn, imageData, -1, "maxpool3"); err == nil {
+        t.Error("GradCAM on a pooling layer should fail")
+    }
+    if _, err := GradCAM(cnn, imageData, 10, ""); err == nil {
+        t.Error("GradCAM of class 10 should fail")
+    }
+}
+
+func TestTraceMatchesPredict(t *testing.T) {
+    cnn, imageData := loadTestModel(t)
+    result, err := cnn.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Predict failed: %v", err)
+    }
+    tr, err := forward(cnn, imageData)
+    if err != nil {
+        t.Fatalf("forward failed: %v", err)
+    }
+
+    scores := tr.scores()
+    var sum float64
+    for _, score := range scores {
+        sum += math.Exp(float64(score - scores[tr.predicted()]))
+    }
+    for class, score := range scores {
+        prob := math.Exp(float64(score-scores[tr.predicted()])) / sum
+        if math.Abs(prob-float64(result.Probabilities[class])) > 1e-4 {
+            t.Errorf("Class %d: softmax of the traced logits gives %f, Predict %f", class, prob, result.Probabilities[class])
+        }
+    }
+}
+
+func TestBackwardFiniteDifferences(t *testing.T) {
+    cnn, imageData := loadTestModel(t)
+    tr, err := forward(cnn, imageData)
+    if err != nil {
+        t.Fatalf("forward failed: %v", err)
+    }
+    class := tr.predicted()
+    idx, err := tr.layerIndex("conv6")
+    if err != nil {
+        t.Fatal(err)
+    }
+    grad, err := tr.backward(cnn, class, idx)
+    if err != nil {
+        t.Fatalf("backward failed: %v", err)
+    }
+
+    // score runs the layers after conv6 on activations
+    activations := tr.outputs[idx].Clone()
+    score := func() float64 {
+        fm := activations
+        for _, layer := range tr.layers[idx+1 : tr.logits+1] {
+            if fm, err = cnn.RunLayer(layer.Name, fm); err != nil {
+                t.Fatalf("RunLayer %s failed: %v", layer.Name, err)
+            }
+        }
+        return float64(fm.Data[class])
+    }
+
+    // Positive activations only: zeros tie in the max pools, where the score has a kink
+    const eps = 1e-3
+    checked := 0
+    for i, v := range activations.Data {
+        if v <= 0 {
+            continue
+        }
+        activations.Data[i] = v + eps
+        plus := score()
+        activations.Data[i] = v - eps
+        minus := score()
+        activations.Data[i] = v
+
+        expected := (plus - minus) / (2 * eps)
+        if math.Abs(expected-float64(grad.Data[i])) > 1e-2*max(1, math.Abs(expected)) {
+            t.Errorf("Grad %d = %f, finite differences give %f", i, grad.Data[i], expected)
+        }
+        checked++
+    }
+    if checked == 0 {
+        t.Fatal("No positive conv6 activations to check")
+    }
+}
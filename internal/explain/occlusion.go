@@ -0,0 +1,154 @@
+package explain
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/model"
+	"fmt"
+)
+
+/**
+* Occlusion sensitivity
+
+Occlusion needs no gradients at all: it covers part of the image with a
+gray patch, classifies it again and records how much the class probability
+drops. Sliding the patch over the whole image shows which regions the model
+relies on, for any Predictor and any layer types:
+```
+patch 8×8, stride 4 on 32×32:   7×7 = 49 occluded copies, classified in batches
+pixel = mean drop of the patches covering it        negative drops count as 0
+```
+The last patch of each row and column is moved flush with the border so
+every pixel is covered. The fill value defaults to 0, the dataset mean of
+normalized images.
+*/
+
+// OcclusionOptions configures Occlusion
+type OcclusionOptions struct {
+    Patch     int     // Side of the square patch in pixels; 0 means 8
+    Stride    int     // Step between patches; 0 means half the patch
+    Fill      float32 // Value the patch replaces every channel with
+    BatchSize int     // Occluded images per PredictBatch call; 0 means 64
+}
+
+// OcclusionMap is how much covering each pixel lowers a class's probability
+type OcclusionMap struct {
+    Heatmap          // Per-pixel mean probability drop normalized to [0,1], on layer "input"; Score is Baseline
+    Baseline float32 // Probability of Class on the unoccluded image
+    MaxDrop  float32 // Largest drop of a single patch, before normalization
+    Patches  int     // Occluded images classified
+}
+
+// Occlusion computes the occlusion sensitivity of class for imageData, in CHW
+// order. A negative class explains the predicted class.
+func Occlusion(ctx context.Context, predictor model.Predictor, imageData []float32, class int,
+    opts OcclusionOptions) (*OcclusionMap, error) {
+
+    arch := predictor.Info().Architecture
+    height, width, channels := arch.InputHeight, arch.InputWidth, arch.InputChannels
+    if arch.InputDepth > 0 {
+        return nil, fmt.Errorf("explanations support 2D images only, the model takes %d frames", arch.InputDepth)
+    }
+    if len(imageData) != height*width*channels {
+        return nil, fmt.Errorf("image data size mismatch: expected %d, got %d", height*width*channels, len(imageData))
+    }
+    if opts.Patch <= 0 {
+        opts.Patch = 8
+    }
+    if opts.Stride <= 0 {
+        opts.Stride = max(opts.Patch/2, 1)
+    }
+    if opts.BatchSize <= 0 {
+        opts.BatchSize = 64
+    }
+    if opts.Patch > height || opts.Patch > width {
+        return nil, fmt.Errorf("patch of %d pixels larger than the %dx%d image", opts.Patch, height, width)
+    }
+
+    baseline, err := predictor.Predict(ctx, imageData)
+    if err != nil {
+        return nil, err
+    }
+    if class < 0 {
+        class = baseline.PredictedClass
+    }
+    if class >= len(baseline.Probabilities) {
+        return nil, fmt.Errorf("class %d out of range [0,%d)", class, len(baseline.Probabilities))
+    }
+
+    // Top-left corners of every patch
+    var corners [][2]int
+    for _, y := range patchOffsets(height, opts.Patch, opts.Stride) {
+        for _, x := range patchOffsets(width, opts.Patch, opts.Stride) {
+            corners = append(corners, [2]int{y, x})
+        }
+    }
+
+    occlusion := &OcclusionMap{
+        Heatmap: Heatmap{
+            Layer:  "input",
+            Class:  class,
+            Score:  baseline.Probabilities[class],
+            Height: height,
+            Width:  width,
+            Values: make([]float32, height*width),
+        },
+        Baseline: baseline.Probabilities[class],
+        Patches:  len(corners),
+    }
+    coverage := make([]int, height*width)
+
+    for start := 0; start < len(corners); start += opts.BatchSize {
+        batch := corners[start:min(start+opts.BatchSize, len(corners))]
+        images := make([][]float32, len(batch))
+        for b, corner := range batch {
+            images[b] = append([]float32(nil), imageData...)
+            for c := 0; c < channels; c++ {
+                for y := corner[0]; y < corner[0]+opts.Patch; y++ {
+                    for x := corner[1]; x < corner[1]+opts.Patch; x++ {
+                        images[b][(c*height+y)*width+x] = opts.Fill
+                    }
+                }
+            }
+        }
+
+        results, err := predictor.PredictBatch(ctx, images)
+        if err != nil {
+            return nil, err
+        }
+        for b, corner := range batch {
+            drop := max(occlusion.Baseline-results[b].Probabilities[class], 0)
+            occlusion.MaxDrop = max(occlusion.MaxDrop, drop)
+            for y := corner[0]; y < corner[0]+opts.Patch; y++ {
+                for x := corner[1]; x < corner[1]+opts.Patch; x++ {
+                    occlusion.Values[y*width+x] += drop
+                    coverage[y*width+x]++
+                }
+            }
+        }
+    }
+
+    var maxValue float32
+    for i, n := range coverage {
+        occlusion.Values[i] /= float32(n)
+        maxValue = max(maxValue, occlusion.Values[i])
+    }
+    if maxValue > 0 {
+        for i := range occlusion.Values {
+            occlusion.Values[i] /= maxValue
+        }
+    }
+    return occlusion, nil
+}
+
+// patchOffsets returns the offsets of patches along a side of size pixels, the
+// last one flush with the border
+func patchOffsets(size, patch, stride int) []int {
+    var offsets []int
+    for offset := 0; offset+patch <= size; offset += stride {
+        offsets = append(offsets, offset)
+    }
+    if last := offsets[len(offsets)-1]; last+patch < size {
+        offsets = append(offsets, size-patch)
+    }
+    return offsets
+}
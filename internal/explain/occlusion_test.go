@@ -0,0 +1,64 @@
+package explain
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPatchOffsets(t *testing.T) {
+    for _, tc := range []struct {
+        size, patch, stride int
+        expected            []int
+    }{
+        {32, 8, 4, []int{0, 4, 8, 12, 16, 20, 24}},
+        {32, 8, 8, []int{0, 8, 16, 24}},
+        {10, 4, 4, []int{0, 4, 6}},
+        {4, 4, 2, []int{0}},
+    } {
+        if got := patchOffsets(tc.size, tc.patch, tc.stride); !reflect.DeepEqual(got, tc.expected) {
+            t.Errorf("patchOffsets(%d, %d, %d) = %v, expected %v", tc.size, tc.patch, tc.stride, got, tc.expected)
+        }
+    }
+}
+
+func TestOcclusion(t *testing.T) {
+    cnn, imageData := loadTestModel(t)
+    result, err := cnn.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Predict failed: %v", err)
+    }
+
+    occlusion, err := Occlusion(context.Background(), cnn, imageData, -1, OcclusionOptions{BatchSize: 10})
+    if err != nil {
+        t.Fatalf("Occlusion failed: %v", err)
+    }
+    if occlusion.Class != result.PredictedClass || occlusion.Baseline != result.Confidence {
+        t.Errorf("Expected class %d at %f, got class %d at %f",
+            result.PredictedClass, result.Confidence, occlusion.Class, occlusion.Baseline)
+    }
+    if occlusion.Patches != 49 || occlusion.Height != 32 || occlusion.Width != 32 {
+        t.Errorf("Expected 49 patches over 32x32, got %d over %dx%d", occlusion.Patches, occlusion.Height, occlusion.Width)
+    }
+    if occlusion.MaxDrop <= 0 || occlusion.MaxDrop > occlusion.Baseline {
+        t.Errorf("Max drop %f outside (0, %f]", occlusion.MaxDrop, occlusion.Baseline)
+    }
+
+    var maxValue float32
+    for _, v := range occlusion.Values {
+        if v < 0 || v > 1 {
+            t.Fatalf("Occlusion value %f outside [0,1]", v)
+        }
+        maxValue = max(maxValue, v)
+    }
+    if maxValue != 1 {
+        t.Errorf("Expected the map normalized to a maximum of 1, got %f", maxValue)
+    }
+
+    if _, err := Occlusion(context.Background(), cnn, imageData, -1, OcclusionOptions{Patch: 40}); err == nil {
+        t.Error("A patch larger than the image should fail")
+    }
+    if _, err := Occlusion(context.Background(), cnn, imageData[:10], -1, OcclusionOptions{}); err == nil {
+        t.Error("A short image should fail")
+    }
+}
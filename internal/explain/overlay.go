@@ -0,0 +1,121 @@
+package explain
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+)
+
+/**
+* Heatmap overlays
+
+A heatmap on its own is a grid of numbers (8×8 for Grad-CAM on conv6); it
+only means something on top of the image it explains. Overlay stretches the input image to its own [0,1] range
+(the model sees normalized pixels, not the original colors), upsamples the
+heatmap bilinearly to the image size, colors it with the jet colormap (blue
+for 0, red for 1) and blends the two:
+```
+pixel = (1-alpha)·image + alpha·jet(heatmap)       then scaled up Scale times
+```
+CIFAR-10 images are 32×32, so the default scale of 8 gives a 256×256 PNG.
+*/
+
+// OverlayOptions configures Overlay
+type OverlayOptions struct {
+    Scale int     // Output pixels per image pixel; 0 means 8
+    Alpha float64 // Weight of the heatmap in the blend; 0 means 0.5
+}
+
+// Overlay draws heatmap over imageData, a height×width image in CHW order with one
+// (grayscale) or three (RGB) channels
+func Overlay(imageData []float32, height, width, channels int, heatmap *Heatmap, opts OverlayOptions) (*image.RGBA, error) {
+    if channels != 1 && channels != 3 {
+        return nil, fmt.Errorf("cannot draw a %d-channel image, need 1 or 3", channels)
+    }
+    if len(imageData) != height*width*channels {
+        return nil, fmt.Errorf("image data size mismatch: expected %d, got %d", height*width*channels, len(imageData))
+    }
+    if opts.Scale <= 0 {
+        opts.Scale = 8
+    }
+    if opts.Alpha <= 0 {
+        opts.Alpha = 0.5
+    }
+
+    minValue, maxValue := float32(math.Inf(1)), float32(math.Inf(-1))
+    for _, v := range imageData {
+        minValue = min(minValue, v)
+        maxValue = max(maxValue, v)
+    }
+    valueRange := maxValue - minValue
+    if valueRange == 0 {
+        valueRange = 1
+    }
+
+    img := image.NewRGBA(image.Rect(0, 0, width*opts.Scale, height*opts.Scale))
+    for y := 0; y < height*opts.Scale; y++ {
+        for x := 0; x < width*opts.Scale; x++ {
+            // Sample both at the center of the output pixel
+            py, px := y/opts.Scale, x/opts.Scale
+            var pixel [3]float64
+            for c := range pixel {
+                v := imageData[(c%channels)*height*width+py*width+px]
+                pixel[c] = float64((v - minValue) / valueRange)
+            }
+
+            heat := heatmap.sample(
+                (float64(y)+0.5)/float64(height*opts.Scale),
+                (float64(x)+0.5)/float64(width*opts.Scale))
+            r, g, b := jet(heat)
+            img.Set(x, y, color.RGBA{
+                R: blend(pixel[0], r, opts.Alpha),
+                G: blend(pixel[1], g, opts.Alpha),
+                B: blend(pixel[2], b, opts.Alpha),
+                A: 255,
+            })
+        }
+    }
+    return img, nil
+}
+
+// SavePNG writes img to path as a PNG file
+func SavePNG(path string, img image.Image) error {
+    f, err := os.Create(path)
+    if err != nil {
+        return fmt.Errorf("failed to create %s: %w", path, err)
+    }
+    if err := png.Encode(f, img); err != nil {
+        f.Close()
+        return fmt.Errorf("failed to encode %s: %w", path, err)
+    }
+    return f.Close()
+}
+
+// sample interpolates the heatmap bilinearly at relative position (v, u) in [0,1]²
+func (h *Heatmap) sample(v, u float64) float64 {
+    y := min(max(v*float64(h.Height)-0.5, 0), float64(h.Height-1))
+    x := min(max(u*float64(h.Width)-0.5, 0), float64(h.Width-1))
+    y0, x0 := int(y), int(x)
+    y1, x1 := min(y0+1, h.Height-1), min(x0+1, h.Width-1)
+    fy, fx := y-float64(y0), x-float64(x0)
+
+    top := float64(h.At(y0, x0))*(1-fx) + float64(h.At(y0, x1))*fx
+    bottom := float64(h.At(y1, x0))*(1-fx) + float64(h.At(y1, x1))*fx
+    return top*(1-fy) + bottom*fy
+}
+
+// jet maps v in [0,1] to the jet colormap: blue, cyan, yellow, red
+func jet(v float64) (r, g, b float64) {
+    channel := func(center float64) float64 {
+        return min(max(1.5-math.Abs(4*v-center), 0), 1)
+    }
+    return channel(3), channel(2), channel(1)
+}
+
+// blend mixes an image value and a heatmap color, both in [0,1], into a byte
+func blend(pixel, heat, alpha float64) uint8 {
+    return uint8(math.Round(255 * ((1-alpha)*pixel + alpha*heat)))
+}
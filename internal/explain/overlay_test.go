@@ -0,0 +1,45 @@
+package explain
+
+import (
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+    // A gray 4×4 image, hot in the top-left quadrant of a 2×2 heatmap
+    imageData := make([]float32, 4*4*3)
+    heatmap := &Heatmap{Height: 2, Width: 2, Values: []float32{1, 0, 0, 0}}
+
+    img, err := Overlay(imageData, 4, 4, 3, heatmap, OverlayOptions{Scale: 2})
+    if err != nil {
+        t.Fatalf("Overlay failed: %v", err)
+    }
+    if bounds := img.Bounds(); bounds.Dx() != 8 || bounds.Dy() != 8 {
+        t.Fatalf("Expected an 8x8 image, got %v", bounds)
+    }
+    if hot := img.RGBAAt(0, 0); hot.R <= hot.B {
+        t.Errorf("Expected red where the heatmap is 1, got %v", hot)
+    }
+    if cold := img.RGBAAt(7, 7); cold.B <= cold.R {
+        t.Errorf("Expected blue where the heatmap is 0, got %v", cold)
+    }
+
+    if _, err := Overlay(imageData, 4, 4, 2, heatmap, OverlayOptions{}); err == nil {
+        t.Error("Overlay of a 2-channel image should fail")
+    }
+
+    path := filepath.Join(t.TempDir(), "cam.png")
+    if err := SavePNG(path, img); err != nil {
+        t.Fatalf("SavePNG failed: %v", err)
+    }
+    f, err := os.Open(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    if _, err := png.Decode(f); err != nil {
+        t.Errorf("Saved file is not a PNG: %v", err)
+    }
+}
@@ -0,0 +1,123 @@
+package explain
+
+import (
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+/**
+* Saliency maps
+
+A saliency map asks which pixels the class score is most sensitive to: the
+gradient of the score with respect to the input image, taken all the way
+back through conv1. Unlike Grad-CAM it has the image's full resolution, which
+helps when a misclassification hinges on a few pixels (a watermark, a border,
+the sky behind a "ship").
+
+Vanilla gradients are noisy, so SmoothGrad (Smilkov et al., 2017) averages
+the gradients of copies of the image with Gaussian noise added:
+```
+grad   = 1/n Σ d score / d (image + N(0, σ²))      σ = Noise · (max - min of the image)
+pixel  = max over channels of |grad|                normalized to [0,1]
+```
+With Samples 0 the gradient of the image itself is used. The class score is
+always the logit on the clean image.
+*/
+
+// SaliencyOptions configures Saliency
+type SaliencyOptions struct {
+    Samples int     // Noisy copies averaged by SmoothGrad; 0 computes the vanilla gradient
+    Noise   float64 // Noise standard deviation relative to the image's value range; 0 means 0.15
+    Seed    int64   // Seed of the noise, so maps can be reproduced
+}
+
+// SaliencyMap is the importance of every input pixel for a class
+type SaliencyMap struct {
+    Heatmap                     // Per-pixel importance in [0,1], on layer "input"
+    Gradient *tensor.FeatureMap // Gradient of the score for every input value, averaged over samples
+}
+
+// Saliency computes the saliency map of class for imageData, in CHW order. A
+// negative class explains the predicted class.
+func Saliency(cnn *model.TinyCNN, imageData []float32, class int, opts SaliencyOptions) (*SaliencyMap, error) {
+    if opts.Samples < 0 {
+        return nil, fmt.Errorf("SmoothGrad samples must not be negative, got %d", opts.Samples)
+    }
+    if opts.Noise <= 0 {
+        opts.Noise = 0.15
+    }
+
+    t, err := forward(cnn, imageData)
+    if err != nil {
+        return nil, err
+    }
+    if class < 0 {
+        class = t.predicted()
+    }
+    gradient, err := t.backward(cnn, class, -1)
+    if err != nil {
+        return nil, fmt.Errorf("saliency of class %d: %w", class, err)
+    }
+    score := t.scores()[class]
+
+    if opts.Samples > 0 {
+        minValue, maxValue := float32(math.Inf(1)), float32(math.Inf(-1))
+        for _, v := range imageData {
+            minValue = min(minValue, v)
+            maxValue = max(maxValue, v)
+        }
+        sigma := opts.Noise * float64(maxValue-minValue)
+        rng := rand.New(rand.NewSource(opts.Seed))
+
+        gradient.Fill(0)
+        noisy := make([]float32, len(imageData))
+        for n := 0; n < opts.Samples; n++ {
+            for i, v := range imageData {
+                noisy[i] = v + float32(rng.NormFloat64()*sigma)
+            }
+            nt, err := forward(cnn, noisy)
+            if err != nil {
+                return nil, err
+            }
+            grad, err := nt.backward(cnn, class, -1)
+            if err != nil {
+                return nil, fmt.Errorf("saliency of class %d: %w", class, err)
+            }
+            for i, g := range grad.Data {
+                gradient.Data[i] += g / float32(opts.Samples)
+            }
+        }
+    }
+
+    saliency := &SaliencyMap{
+        Heatmap: Heatmap{
+            Layer:  "input",
+            Class:  class,
+            Score:  score,
+            Height: gradient.Height,
+            Width:  gradient.Width,
+            Values: make([]float32, gradient.Height*gradient.Width),
+        },
+        Gradient: gradient,
+    }
+    var maxValue float32
+    for i := 0; i < gradient.Height; i++ {
+        for j := 0; j < gradient.Width; j++ {
+            var importance float32
+            for c := 0; c < gradient.Channels; c++ {
+                importance = max(importance, float32(math.Abs(float64(gradient.GetUnsafe(c, i, j)))))
+            }
+            saliency.Values[i*gradient.Width+j] = importance
+            maxValue = max(maxValue, importance)
+        }
+    }
+    if maxValue > 0 {
+        for i := range saliency.Values {
+            saliency.Values[i] /= maxValue
+        }
+    }
+    return saliency, nil
+}
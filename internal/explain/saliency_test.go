@@ -0,0 +1,101 @@
+package explain
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestSaliencyGradient(t *testing.T) {
+    cnn, imageData := loadTestModel(t)
+    saliency, err := Saliency(cnn, imageData, -1, SaliencyOptions{})
+    if err != nil {
+        t.Fatalf("Saliency failed: %v", err)
+    }
+    if saliency.Height != 32 || saliency.Width != 32 || saliency.Gradient.Channels != 3 {
+        t.Fatalf("Expected a 32x32 map of a 3-channel gradient, got %dx%d of %d channels",
+            saliency.Height, saliency.Width, saliency.Gradient.Channels)
+    }
+    tr, err := forward(cnn, imageData)
+    if err != nil {
+        t.Fatalf("forward failed: %v", err)
+    }
+    if saliency.Class != tr.predicted() || saliency.Score != tr.scores()[tr.predicted()] {
+        t.Errorf("Expected class %d with score %f, got class %d with %f",
+            tr.predicted(), tr.scores()[tr.predicted()], saliency.Class, saliency.Score)
+    }
+
+    // The most important pixels are the least likely to sit on a ReLU or max pool kink
+    order := make([]int, len(imageData))
+    for i := range order {
+        order[i] = i
+    }
+    sort.Slice(order, func(a, b int) bool {
+        return math.Abs(float64(saliency.Gradient.Data[order[a]])) > math.Abs(float64(saliency.Gradient.Data[order[b]]))
+    })
+
+    const eps = 1e-4
+    image := append([]float32(nil), imageData...)
+    score := func() float64 {
+        tr, err := forward(cnn, image)
+        if err != nil {
+            t.Fatalf("forward failed: %v", err)
+        }
+        return float64(tr.scores()[saliency.Class])
+    }
+    for _, i := range order[:10] {
+        v := image[i]
+        image[i] = v + eps
+        plus := score()
+        image[i] = v - eps
+        minus := score()
+        image[i] = v
+
+        expected := (plus - minus) / (2 * eps)
+        got := float64(saliency.Gradient.Data[i])
+        if math.Abs(expected-got) > 0.05*math.Abs(got) {
+            t.Errorf("Grad %d = %f, finite differences give %f", i, got, expected)
+        }
+    }
+}
+
+func TestSmoothGrad(t *testing.T) {
+    cnn, imageData := loadTestModel(t)
+    opts := SaliencyOptions{Samples: 4, Noise: 0.1, Seed: 7}
+    first, err := Saliency(cnn, imageData, 3, opts)
+    if err != nil {
+        t.Fatalf("Saliency failed: %v", err)
+    }
+    second, err := Saliency(cnn, imageData, 3, opts)
+    if err != nil {
+        t.Fatalf("Saliency failed: %v", err)
+    }
+    vanilla, err := Saliency(cnn, imageData, 3, SaliencyOptions{})
+    if err != nil {
+        t.Fatalf("Saliency failed: %v", err)
+    }
+
+    var maxValue float32
+    same, differs := true, false
+    for i, v := range first.Values {
+        if v < 0 || v > 1 {
+            t.Fatalf("Saliency value %f outside [0,1]", v)
+        }
+        maxValue = max(maxValue, v)
+        same = same && v == second.Values[i]
+        differs = differs || v != vanilla.Values[i]
+    }
+    if first.Class != 3 || maxValue != 1 {
+        t.Errorf("Expected class 3 normalized to a maximum of 1, got class %d with maximum %f", first.Class, maxValue)
+    }
+    if !same {
+        t.Error("SmoothGrad with the same seed should give the same map")
+    }
+    if !differs {
+        t.Error("SmoothGrad should differ from the vanilla gradient")
+    }
+
+    if _, err := Saliency(cnn, imageData, -1, SaliencyOptions{Samples: -1}); err == nil {
+        t.Error("Negative samples should fail")
+    }
+}
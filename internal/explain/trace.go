@@ -0,0 +1,140 @@
+package explain
+
+import (
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/ops"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+)
+
+/**
+* Forward trace and backward pass
+
+Explanations need every layer's input and output of one image, which Predict
+doesn't keep, so the image is run again layer by layer with RunLayer. The
+class score is the logit: the output of the last layer before softmax, since
+the softmax gradient would mix in every other class.
+
+The backward pass starts from a one-hot gradient on the logits and walks the
+trace in reverse, down to the output of a chosen layer (Grad-CAM) or the
+input image itself (saliency):
+```
+logits ← global max pool ← conv7 ← maxpool3 ← conv6 (BN, ReLU) ← ... ← image
+```
+Conv, max pooling and global max or average pooling layers are supported; a
+custom layer or a softmax in the middle of the network stops the pass with an
+error.
+*/
+
+// trace is one image's forward pass, the input and output of every layer
+type trace struct {
+    layers  []model.LayerConfig
+    inputs  []*tensor.FeatureMap
+    outputs []*tensor.FeatureMap
+    logits  int // Index of the layer whose output holds the logits
+}
+
+// forward runs imageData, in CHW order, through cnn one layer at a time
+func forward(cnn *model.TinyCNN, imageData []float32) (*trace, error) {
+    arch := cnn.Info().Architecture
+    if arch.InputDepth > 0 {
+        return nil, fmt.Errorf("explanations support 2D images only, the model takes %d frames", arch.InputDepth)
+    }
+    input, err := tensor.NewFeatureMapFromData(imageData, arch.InputHeight, arch.InputWidth, arch.InputChannels)
+    if err != nil {
+        return nil, fmt.Errorf("image: %w", err)
+    }
+
+    t := &trace{layers: arch.Layers, logits: len(arch.Layers) - 1}
+    if last := arch.Layers[t.logits]; last.Type == model.SoftmaxLayer {
+        t.logits--
+    }
+    if t.logits < 0 {
+        return nil, fmt.Errorf("model has no layer before softmax")
+    }
+    for _, layer := range arch.Layers[:t.logits+1] {
+        output, err := cnn.RunLayer(layer.Name, input)
+        if err != nil {
+            return nil, err
+        }
+        t.inputs = append(t.inputs, input)
+        t.outputs = append(t.outputs, output)
+        input = output
+    }
+    return t, nil
+}
+
+// scores returns the logit of every class
+func (t *trace) scores() []float32 {
+    return t.outputs[t.logits].Data
+}
+
+// predicted returns the class with the highest logit
+func (t *trace) predicted() int {
+    best := 0
+    for class, score := range t.scores() {
+        if score > t.scores()[best] {
+            best = class
+        }
+    }
+    return best
+}
+
+// layerIndex returns the position of the named layer in the trace
+func (t *trace) layerIndex(name string) (int, error) {
+    for i, layer := range t.layers[:t.logits+1] {
+        if layer.Name == name {
+            return i, nil
+        }
+    }
+    return -1, fmt.Errorf("no layer named %q before the logits", name)
+}
+
+// backward returns the gradient of class's logit with respect to the output of
+// layer stop, or with respect to the input image if stop is -1
+func (t *trace) backward(cnn *model.TinyCNN, class, stop int) (*tensor.FeatureMap, error) {
+    scores := t.scores()
+    if class < 0 || class >= len(scores) {
+        return nil, fmt.Errorf("class %d out of range [0,%d)", class, len(scores))
+    }
+    logits := t.outputs[t.logits]
+    if logits.Height != 1 || logits.Width != 1 {
+        return nil, fmt.Errorf("layer %s gives %dx%d maps, not one logit per class",
+            t.layers[t.logits].Name, logits.Height, logits.Width)
+    }
+    grad := tensor.NewFeatureMapWithLayout(logits.Height, logits.Width, logits.Channels, logits.Layout)
+    grad.Data[class] = 1
+
+    for i := t.logits; i > stop; i-- {
+        layer, input, output := t.layers[i], t.inputs[i], t.outputs[i]
+        switch layer.Type {
+        case model.ConvolutionLayer:
+            kernel, err := cnn.ConvKernel(layer.Name)
+            if err != nil {
+                return nil, err
+            }
+            bn, applyReLU, err := cnn.ConvEpilogue(layer.Name)
+            if err != nil {
+                return nil, err
+            }
+            grad = ops.BatchNormReLUBackward(grad, output, bn, applyReLU)
+            grad, _, _ = ops.Conv2DBackward(grad, input, kernel, ops.Conv2DConfig{
+                Padding: layer.Padding,
+                Stride:  layer.Stride,
+            })
+
+        case model.MaxPoolingLayer:
+            grad = ops.MaxPooling2DBackward(grad, input, layer.PoolSize, layer.PoolStride)
+
+        case model.GlobalMaxPoolingLayer:
+            grad = ops.GlobalMaxPoolingBackward(grad.Data, input)
+
+        case model.GlobalAveragePoolingLayer:
+            grad = ops.GlobalAvgPoolingBackward(grad.Data, input)
+
+        default:
+            return nil, fmt.Errorf("layer %s: no backward pass for layer type %s", layer.Name, layer.Type)
+        }
+    }
+    return grad, nil
+}
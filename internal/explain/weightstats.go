@@ -0,0 +1,196 @@
+package explain
+
+import (
+	"bufio"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"html"
+	"io"
+	"math"
+)
+
+/**
+* Weight statistics
+
+Deeper filters can't be looked at, but their statistics show training
+problems at a glance: a layer whose weights collapsed towards zero, a few
+exploding filters, or dead filters that no longer respond to anything.
+KernelStats summarizes a conv kernel; WriteStatsSVG plots one row per layer,
+the weight histogram on the left and the L2 norm of every filter on the
+right:
+```
+conv3  3×3×32×64  std 0.041      ▁▂▅█▅▂▁        ▆▇▅▆▁▇▆▅▆▇  ← filter 4 is nearly dead
+```
+A filter counts as dead when its norm is below 1% of the layer's largest.
+SVG keeps the labels as text with nothing beyond the standard library, and
+opens in any browser.
+*/
+
+// deadFilterRatio is the fraction of the largest filter norm below which a filter counts as dead
+const deadFilterRatio = 0.01
+
+// WeightStats summarizes the weights of one conv layer
+type WeightStats struct {
+    Layer       string
+    Size        int
+    Channels    int
+    Filters     int
+    Mean        float64
+    Std         float64
+    Min         float64
+    Max         float64
+    Sparsity    float64   // Fraction of weights with |w| below 1% of the largest |w|
+    Histogram   []int     // Weight counts in equal bins over [Min, Max]
+    FilterNorms []float64 // L2 norm of every filter
+    DeadFilters int       // Filters whose norm is below 1% of the largest
+}
+
+// KernelStats computes the statistics of kernel, the weights of the named layer,
+// with a histogram of bins bins (at least one)
+func KernelStats(layer string, kernel *tensor.Kernel, bins int) *WeightStats {
+    bins = max(bins, 1)
+    stats := &WeightStats{
+        Layer:       layer,
+        Size:        kernel.Size,
+        Channels:    kernel.Channels,
+        Filters:     kernel.Filters,
+        Min:         math.Inf(1),
+        Max:         math.Inf(-1),
+        Histogram:   make([]int, bins),
+        FilterNorms: make([]float64, kernel.Filters),
+    }
+
+    var sum, sumSquares, largest float64
+    for _, w := range kernel.Weights {
+        v := float64(w)
+        sum += v
+        sumSquares += v * v
+        stats.Min = min(stats.Min, v)
+        stats.Max = max(stats.Max, v)
+        largest = max(largest, math.Abs(v))
+    }
+    count := float64(len(kernel.Weights))
+    stats.Mean = sum / count
+    stats.Std = math.Sqrt(max(sumSquares/count-stats.Mean*stats.Mean, 0))
+
+    width := (stats.Max - stats.Min) / float64(bins)
+    var small int
+    for _, w := range kernel.Weights {
+        v := float64(w)
+        bin := bins - 1
+        if width > 0 {
+            bin = min(int((v-stats.Min)/width), bins-1)
+        }
+        stats.Histogram[bin]++
+        if math.Abs(v) < deadFilterRatio*largest {
+            small++
+        }
+    }
+    stats.Sparsity = float64(small) / count
+
+    var largestNorm float64
+    for f := 0; f < kernel.Filters; f++ {
+        var squares float64
+        for c := 0; c < kernel.Channels; c++ {
+            for m := 0; m < kernel.Size; m++ {
+                for n := 0; n < kernel.Size; n++ {
+                    w := float64(kernel.GetWeightUnsafe(f, c, m, n))
+                    squares += w * w
+                }
+            }
+        }
+        stats.FilterNorms[f] = math.Sqrt(squares)
+        largestNorm = max(largestNorm, stats.FilterNorms[f])
+    }
+    for _, norm := range stats.FilterNorms {
+        if norm < deadFilterRatio*largestNorm {
+            stats.DeadFilters++
+        }
+    }
+    return stats
+}
+
+// SVG plot geometry, in pixels
+const (
+    svgWidth     = 900
+    svgRowHeight = 150
+    svgMargin    = 20
+    svgTitle     = 30 // Height of the layer title above each row
+    svgPanelGap  = 40
+)
+
+// WriteStatsSVG plots the histogram and filter norms of every layer to w as an SVG image
+func WriteStatsSVG(w io.Writer, stats []*WeightStats) error {
+    out := bufio.NewWriter(w)
+    height := svgMargin*2 + len(stats)*svgRowHeight
+    panelWidth := float64(svgWidth-2*svgMargin-svgPanelGap) / 2
+    panelHeight := float64(svgRowHeight - svgTitle - svgMargin)
+
+    fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="11">`+"\n",
+        svgWidth, height)
+    fmt.Fprintf(out, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+
+    for row, s := range stats {
+        top := float64(svgMargin + row*svgRowHeight)
+        fmt.Fprintf(out, `<text x="%d" y="%.1f" font-size="13" font-weight="bold">%s</text>`+"\n",
+            svgMargin, top+14, html.EscapeString(s.Layer))
+        fmt.Fprintf(out, `<text x="%d" y="%.1f">%d×%d×%d×%d   mean %.4g   std %.4g   sparsity %.1f%%   dead filters %d</text>`+"\n",
+            svgMargin+80, top+14, s.Size, s.Size, s.Channels, s.Filters, s.Mean, s.Std, 100*s.Sparsity, s.DeadFilters)
+
+        panelTop := top + svgTitle
+        left := float64(svgMargin)
+        counts := make([]float64, len(s.Histogram))
+        for i, n := range s.Histogram {
+            counts[i] = float64(n)
+        }
+        writeBars(out, counts, left, panelTop, panelWidth, panelHeight, "#4c72b0", nil)
+        fmt.Fprintf(out, `<text x="%.1f" y="%.1f">%.3g</text>`+"\n", left, panelTop+panelHeight+13, s.Min)
+        fmt.Fprintf(out, `<text x="%.1f" y="%.1f" text-anchor="middle">weights</text>`+"\n",
+            left+panelWidth/2, panelTop+panelHeight+13)
+        fmt.Fprintf(out, `<text x="%.1f" y="%.1f" text-anchor="end">%.3g</text>`+"\n",
+            left+panelWidth, panelTop+panelHeight+13, s.Max)
+
+        left += panelWidth + svgPanelGap
+        var largestNorm float64
+        for _, norm := range s.FilterNorms {
+            largestNorm = max(largestNorm, norm)
+        }
+        dead := func(norm float64) bool { return norm < deadFilterRatio*largestNorm }
+        writeBars(out, s.FilterNorms, left, panelTop, panelWidth, panelHeight, "#55a868", dead)
+        fmt.Fprintf(out, `<text x="%.1f" y="%.1f">filter 0</text>`+"\n", left, panelTop+panelHeight+13)
+        fmt.Fprintf(out, `<text x="%.1f" y="%.1f" text-anchor="middle">L2 norm per filter (max %.3g)</text>`+"\n",
+            left+panelWidth/2, panelTop+panelHeight+13, largestNorm)
+        fmt.Fprintf(out, `<text x="%.1f" y="%.1f" text-anchor="end">%d</text>`+"\n",
+            left+panelWidth, panelTop+panelHeight+13, len(s.FilterNorms)-1)
+    }
+
+    fmt.Fprintln(out, "</svg>")
+    return out.Flush()
+}
+
+// writeBars draws values as a bar chart filling the given box, with an axis line
+// Bars for which highlight returns true are drawn in red.
+func writeBars(w io.Writer, values []float64, left, top, width, height float64, fill string,
+    highlight func(float64) bool) {
+
+    var largest float64
+    for _, v := range values {
+        largest = max(largest, v)
+    }
+    if largest == 0 {
+        largest = 1
+    }
+    barWidth := width / float64(len(values))
+    for i, v := range values {
+        barHeight := height * v / largest
+        color := fill
+        if highlight != nil && highlight(v) {
+            color = "#c44e52"
+            barHeight = max(barHeight, 2) // Keep dead filters visible
+        }
+        fmt.Fprintf(w, `<rect x="%.1f" y="%.1f" width="%.2f" height="%.1f" fill="%s"/>`+"\n",
+            left+float64(i)*barWidth, top+height-barHeight, max(barWidth-1, 0.5), barHeight, color)
+    }
+    fmt.Fprintf(w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="black"/>`+"\n",
+        left, top+height, left+width, top+height)
+}
@@ -0,0 +1,60 @@
+package explain
+
+import (
+	"bytes"
+	"duchm1606/gocnn/internal/tensor"
+	"encoding/xml"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestKernelStats(t *testing.T) {
+    // Two 1×1 filters over two channels: weights 1, 3 and a dead filter of zeros
+    kernel := tensor.NewKernel(1, 2, 2)
+    kernel.SetWeight(0, 0, 0, 0, 1)
+    kernel.SetWeight(0, 1, 0, 0, 3)
+
+    stats := KernelStats("conv1", kernel, 3)
+    if stats.Mean != 1 || stats.Min != 0 || stats.Max != 3 {
+        t.Errorf("Expected mean 1 in [0,3], got %f in [%f,%f]", stats.Mean, stats.Min, stats.Max)
+    }
+    if math.Abs(stats.Std-math.Sqrt(2.5-1)) > 1e-9 {
+        t.Errorf("Expected std %f, got %f", math.Sqrt(1.5), stats.Std)
+    }
+    if stats.Sparsity != 0.5 || stats.DeadFilters != 1 {
+        t.Errorf("Expected sparsity 0.5 and 1 dead filter, got %f and %d", stats.Sparsity, stats.DeadFilters)
+    }
+    if h := stats.Histogram; h[0] != 2 || h[1] != 1 || h[2] != 1 {
+        t.Errorf("Expected histogram [2 1 1], got %v", h)
+    }
+    if math.Abs(stats.FilterNorms[0]-math.Sqrt(10)) > 1e-9 || stats.FilterNorms[1] != 0 {
+        t.Errorf("Expected filter norms [%f 0], got %v", math.Sqrt(10), stats.FilterNorms)
+    }
+}
+
+func TestWriteStatsSVG(t *testing.T) {
+    kernel := tensor.NewKernel(3, 2, 4)
+    for i := range kernel.Weights {
+        kernel.Weights[i] = float32(i%7) - 3
+    }
+    stats := []*WeightStats{KernelStats("conv<1>", kernel, 10), KernelStats("conv2", kernel, 10)}
+
+    var buf bytes.Buffer
+    if err := WriteStatsSVG(&buf, stats); err != nil {
+        t.Fatalf("WriteStatsSVG failed: %v", err)
+    }
+    out := buf.String()
+    decoder := xml.NewDecoder(strings.NewReader(out))
+    for {
+        if _, err := decoder.Token(); err == io.EOF {
+            break
+        } else if err != nil {
+            t.Fatalf("Output is not well-formed XML: %v", err)
+        }
+    }
+    if !strings.Contains(out, "conv&lt;1&gt;") {
+        t.Error("Expected the layer name escaped in the plot")
+    }
+}
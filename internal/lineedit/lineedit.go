@@ -0,0 +1,331 @@
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+/**
+* Line editing
+
+An interactive shell is painful without the keys every other shell has:
+arrows to fix a typo or recall the last command, Tab to finish a long file
+name. Editor reads one line at a time and, when its input is a terminal,
+puts the terminal in raw mode for the duration of the read so it sees every
+key press:
+```
+← → Home End Ctrl-A Ctrl-E   move the cursor
+Backspace Delete Ctrl-U      erase
+↑ ↓                          walk the history
+Tab                          complete; a second Tab lists the candidates
+Ctrl-C                       abandon the line (ErrInterrupted)
+Ctrl-D                       end of input on an empty line
+```
+The terminal is back in its normal mode between reads, so output and
+Ctrl-C behave as usual while a command runs. When the input is a pipe or a
+file, or raw mode is not available on the platform, lines are read as they
+come without editing.
+*/
+
+// ErrInterrupted is returned by ReadLine when the user presses Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// HistoryLimit is the number of lines the history keeps
+const HistoryLimit = 1000
+
+// Completer returns the candidates to replace line, the text left of the cursor, with
+type Completer func(line string) []string
+
+// Key codes read in raw mode
+const (
+    keyCtrlA     = 1
+    keyCtrlC     = 3
+    keyCtrlD     = 4
+    keyCtrlE     = 5
+    keyBackspace = 8
+    keyTab       = 9
+    keyNewline   = 10
+    keyEnter     = 13
+    keyCtrlU     = 21
+    keyEscape    = 27
+    keyDelete    = 127
+)
+
+// Editor reads lines with editing, history and completion
+type Editor struct {
+    in       *bufio.Reader
+    out      io.Writer
+    fd       int  // Terminal to put in raw mode, -1 if the input is not one
+    editing  bool // Keys are read one by one; set when in is a terminal
+    complete Completer
+    history  []string
+
+    // State of the line being edited
+    line     []rune
+    pos      int    // Cursor position in line
+    browsing int    // History entry shown, len(history) for the line being typed
+    draft    []rune // The line being typed, while browsing the history
+    lastTab  bool   // The previous key was a Tab
+}
+
+// New creates an editor reading from in and echoing to out
+// Editing is enabled when in is a terminal.
+func New(in io.Reader, out io.Writer) *Editor {
+    e := &Editor{in: bufio.NewReader(in), out: out, fd: -1}
+    if f, ok := in.(*os.File); ok && isTerminal(f) {
+        e.fd = int(f.Fd())
+        e.editing = true
+    }
+    return e
+}
+
+// SetCompleter sets the function completing the line on Tab
+func (e *Editor) SetCompleter(complete Completer) {
+    e.complete = complete
+}
+
+// isTerminal reports whether f is a character device such as a terminal
+func isTerminal(f *os.File) bool {
+    info, err := f.Stat()
+    return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ReadLine prints prompt and returns the line entered, without its newline
+// It returns io.EOF at the end of the input and ErrInterrupted on Ctrl-C.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+    if e.editing {
+        restore, err := makeRaw(e.fd)
+        if err == nil {
+            defer restore()
+            return e.edit(prompt)
+        }
+        // Not a terminal after all (e.g. /dev/null), or no raw mode on this platform
+        e.editing = false
+    }
+
+    fmt.Fprint(e.out, prompt)
+    line, err := e.in.ReadString('\n')
+    if err != nil && (err != io.EOF || line == "") {
+        return "", err
+    }
+    return strings.TrimRight(line, "\r\n"), nil
+}
+
+// edit reads keys until Enter, redrawing the line after each
+func (e *Editor) edit(prompt string) (string, error) {
+    e.line, e.pos, e.browsing, e.draft, e.lastTab = nil, 0, len(e.history), nil, false
+    fmt.Fprint(e.out, prompt)
+
+    for {
+        r, _, err := e.in.ReadRune()
+        if err != nil {
+            fmt.Fprint(e.out, "\r\n")
+            return "", err
+        }
+
+        tab := false
+        switch r {
+        case keyEnter, keyNewline:
+            fmt.Fprint(e.out, "\r\n")
+            return string(e.line), nil
+        case keyCtrlC:
+            fmt.Fprint(e.out, "^C\r\n")
+            return "", ErrInterrupted
+        case keyCtrlD:
+            if len(e.line) == 0 {
+                fmt.Fprint(e.out, "\r\n")
+                return "", io.EOF
+            }
+            e.deleteAt(e.pos)
+        case keyCtrlA:
+            e.pos = 0
+        case keyCtrlE:
+            e.pos = len(e.line)
+        case keyCtrlU:
+            e.line = append([]rune(nil), e.line[e.pos:]...)
+            e.pos = 0
+        case keyBackspace, keyDelete:
+            if e.pos > 0 {
+                e.pos--
+                e.deleteAt(e.pos)
+            }
+        case keyTab:
+            tab = true
+            e.completeLine()
+        case keyEscape:
+            e.escape()
+        default:
+            if r >= ' ' && r != utf8.RuneError {
+                e.insert(r)
+            }
+        }
+        e.lastTab = tab
+        e.redraw(prompt)
+    }
+}
+
+// escape handles the escape sequences of the arrow, Home, End and Delete keys
+func (e *Editor) escape() {
+    next, _, err := e.in.ReadRune()
+    if err != nil || (next != '[' && next != 'O') {
+        return
+    }
+    key, _, err := e.in.ReadRune()
+    if err != nil {
+        return
+    }
+
+    switch key {
+    case 'A':
+        e.recall(e.browsing - 1)
+    case 'B':
+        e.recall(e.browsing + 1)
+    case 'C':
+        e.pos = min(e.pos+1, len(e.line))
+    case 'D':
+        e.pos = max(e.pos-1, 0)
+    case 'H':
+        e.pos = 0
+    case 'F':
+        e.pos = len(e.line)
+    case '1', '3', '4', '7', '8':
+        // Home (1~, 7~), Delete (3~) and End (4~, 8~)
+        if tilde, _, err := e.in.ReadRune(); err != nil || tilde != '~' {
+            return
+        }
+        switch key {
+        case '1', '7':
+            e.pos = 0
+        case '4', '8':
+            e.pos = len(e.line)
+        case '3':
+            e.deleteAt(e.pos)
+        }
+    }
+}
+
+// insert adds r at the cursor
+func (e *Editor) insert(r rune) {
+    e.line = append(e.line, 0)
+    copy(e.line[e.pos+1:], e.line[e.pos:])
+    e.line[e.pos] = r
+    e.pos++
+}
+
+// deleteAt removes the rune at i, if any
+func (e *Editor) deleteAt(i int) {
+    if i < len(e.line) {
+        e.line = append(e.line[:i], e.line[i+1:]...)
+    }
+}
+
+// recall shows history entry i; len(history) is the line that was being typed
+func (e *Editor) recall(i int) {
+    if i < 0 || i > len(e.history) || i == e.browsing {
+        return
+    }
+    if e.browsing == len(e.history) {
+        e.draft = append([]rune(nil), e.line...)
+    }
+    e.browsing = i
+    if i == len(e.history) {
+        e.line = append([]rune(nil), e.draft...)
+    } else {
+        e.line = []rune(e.history[i])
+    }
+    e.pos = len(e.line)
+}
+
+// completeLine replaces the text left of the cursor with its only candidate or
+// their common prefix; a second Tab with nothing left to add lists them
+func (e *Editor) completeLine() {
+    if e.complete == nil {
+        return
+    }
+    head := string(e.line[:e.pos])
+    candidates := e.complete(head)
+    if len(candidates) == 0 {
+        return
+    }
+
+    prefix := commonPrefix(candidates)
+    if len(candidates) == 1 || len(prefix) > len(head) {
+        e.replaceHead(prefix)
+        return
+    }
+    if e.lastTab {
+        fmt.Fprint(e.out, "\r\n")
+        for _, c := range candidates {
+            fmt.Fprintf(e.out, "%s\r\n", c)
+        }
+    }
+}
+
+// replaceHead replaces the text left of the cursor with head
+func (e *Editor) replaceHead(head string) {
+    tail := e.line[e.pos:]
+    e.line = append([]rune(head), tail...)
+    e.pos = len(e.line) - len(tail)
+}
+
+// commonPrefix returns the longest prefix shared by all of candidates
+func commonPrefix(candidates []string) string {
+    prefix := candidates[0]
+    for _, c := range candidates[1:] {
+        for !strings.HasPrefix(c, prefix) {
+            _, size := utf8.DecodeLastRuneInString(prefix)
+            prefix = prefix[:len(prefix)-size]
+        }
+    }
+    return prefix
+}
+
+// redraw rewrites the current line and puts the cursor back in place
+func (e *Editor) redraw(prompt string) {
+    fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(e.line))
+    if back := len(e.line) - e.pos; back > 0 {
+        fmt.Fprintf(e.out, "\x1b[%dD", back)
+    }
+}
+
+// AddHistory appends line to the history, unless it is empty or repeats the last entry
+func (e *Editor) AddHistory(line string) {
+    if strings.TrimSpace(line) == "" {
+        return
+    }
+    if n := len(e.history); n > 0 && e.history[n-1] == line {
+        return
+    }
+    e.history = append(e.history, line)
+    if len(e.history) > HistoryLimit {
+        e.history = e.history[len(e.history)-HistoryLimit:]
+    }
+}
+
+// History returns the history, oldest line first
+func (e *Editor) History() []string {
+    return e.history
+}
+
+// ReadHistory appends the lines of r to the history
+func (e *Editor) ReadHistory(r io.Reader) error {
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        e.AddHistory(scanner.Text())
+    }
+    return scanner.Err()
+}
+
+// WriteHistory writes the history to w, one line per entry
+func (e *Editor) WriteHistory(w io.Writer) error {
+    bw := bufio.NewWriter(w)
+    for _, line := range e.history {
+        fmt.Fprintln(bw, line)
+    }
+    return bw.Flush()
+}
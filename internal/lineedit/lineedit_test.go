@@ -0,0 +1,142 @@
+package lineedit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// newTestEditor returns an editor reading keys from input as if from a terminal
+func newTestEditor(input string) (*Editor, *bytes.Buffer) {
+    out := &bytes.Buffer{}
+    e := New(strings.NewReader(input), out)
+    e.editing = true
+    return e, out
+}
+
+func TestEditorKeys(t *testing.T) {
+    tests := []struct {
+        name  string
+        input string
+        want  string
+    }{
+        {"plain", "predict\r", "predict"},
+        {"backspace", "predcit\x7f\x7f\x7fict\r", "predict"},
+        {"insert after left", "prdict\x1b[D\x1b[D\x1b[D\x1b[De\r", "predict"},
+        {"home and end", "redic\x01p\x05t\r", "predict"},
+        {"home and end sequences", "redic\x1b[Hp\x1b[Ft\r", "predict"},
+        {"delete", "prXedict\x01\x1b[C\x1b[C\x1b[3~\r", "predict"},
+        {"kill to start", "junk\x15predict\r", "predict"},
+        {"unicode", "ảnh\x7fh\r", "ảnh"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            e, _ := newTestEditor(tt.input)
+            got, err := e.edit("> ")
+            if err != nil {
+                t.Fatalf("edit failed: %v", err)
+            }
+            if got != tt.want {
+                t.Errorf("got %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestEditorEndOfInput(t *testing.T) {
+    e, _ := newTestEditor("\x04")
+    if _, err := e.edit("> "); err != io.EOF {
+        t.Errorf("Ctrl-D on an empty line: got %v, want io.EOF", err)
+    }
+
+    e, _ = newTestEditor("abc\x03")
+    if _, err := e.edit("> "); !errors.Is(err, ErrInterrupted) {
+        t.Errorf("Ctrl-C: got %v, want ErrInterrupted", err)
+    }
+}
+
+func TestEditorHistory(t *testing.T) {
+    e, _ := newTestEditor("\x1b[A\x1b[A\r" + "draft\x1b[A\x1b[B\r")
+    e.AddHistory("info")
+    e.AddHistory("predict a.bin")
+    e.AddHistory("predict a.bin") // Repeats are kept once
+    e.AddHistory("   ")
+
+    if got := e.History(); len(got) != 2 {
+        t.Fatalf("history = %q, want 2 entries", got)
+    }
+
+    got, _ := e.edit("> ")
+    if got != "info" {
+        t.Errorf("up twice: got %q, want %q", got, "info")
+    }
+    got, _ = e.edit("> ")
+    if got != "draft" {
+        t.Errorf("up then down: got %q, want the line being typed %q", got, "draft")
+    }
+}
+
+func TestEditorReadWriteHistory(t *testing.T) {
+    e := New(strings.NewReader(""), io.Discard)
+    if err := e.ReadHistory(strings.NewReader("info\npredict a.bin\n\nquit\n")); err != nil {
+        t.Fatalf("ReadHistory failed: %v", err)
+    }
+
+    var buf bytes.Buffer
+    if err := e.WriteHistory(&buf); err != nil {
+        t.Fatalf("WriteHistory failed: %v", err)
+    }
+    if want := "info\npredict a.bin\nquit\n"; buf.String() != want {
+        t.Errorf("WriteHistory wrote %q, want %q", buf.String(), want)
+    }
+}
+
+func TestEditorCompletion(t *testing.T) {
+    complete := func(line string) []string {
+        var matches []string
+        for _, c := range []string{"predict images/cat.bin", "predict images/car.bin", "predict images/dog.bin"} {
+            if strings.HasPrefix(c, line) {
+                matches = append(matches, c)
+            }
+        }
+        return matches
+    }
+
+    // One candidate is completed in full, keeping the text right of the cursor
+    e, _ := newTestEditor("predict images/d\t\r")
+    e.SetCompleter(complete)
+    if got, _ := e.edit("> "); got != "predict images/dog.bin" {
+        t.Errorf("single candidate: got %q", got)
+    }
+
+    // Several are completed to their common prefix, and listed on the second Tab
+    e, out := newTestEditor("predict images/c\t\t\r")
+    e.SetCompleter(complete)
+    if got, _ := e.edit("> "); got != "predict images/ca" {
+        t.Errorf("common prefix: got %q, want %q", got, "predict images/ca")
+    }
+    if !strings.Contains(out.String(), "predict images/cat.bin\r\n") {
+        t.Errorf("second Tab did not list the candidates: %q", out.String())
+    }
+}
+
+func TestEditorWithoutTerminal(t *testing.T) {
+    var out bytes.Buffer
+    e := New(strings.NewReader("info\r\nquit"), &out)
+
+    for _, want := range []string{"info", "quit"} {
+        got, err := e.ReadLine("> ")
+        if err != nil || got != want {
+            t.Errorf("ReadLine = %q, %v; want %q", got, err, want)
+        }
+    }
+    if _, err := e.ReadLine("> "); err != io.EOF {
+        t.Errorf("ReadLine at the end = %v, want io.EOF", err)
+    }
+    if out.String() != "> > > " {
+        t.Errorf("prompts written: %q", out.String())
+    }
+}
@@ -0,0 +1,37 @@
+//go:build linux
+
+package lineedit
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// makeRaw puts the terminal fd in raw mode: no echo, no line buffering and no
+// signals for Ctrl-C, so every key reaches the editor. restore undoes it.
+func makeRaw(fd int) (restore func(), err error) {
+    var saved syscall.Termios
+    if err := ioctlTermios(fd, syscall.TCGETS, &saved); err != nil {
+        return nil, err
+    }
+
+    raw := saved
+    raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+    raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
+    raw.Cflag |= syscall.CS8
+    raw.Cc[syscall.VMIN] = 1
+    raw.Cc[syscall.VTIME] = 0
+    if err := ioctlTermios(fd, syscall.TCSETS, &raw); err != nil {
+        return nil, err
+    }
+    return func() { ioctlTermios(fd, syscall.TCSETS, &saved) }, nil
+}
+
+// ioctlTermios gets or sets the terminal attributes of fd
+func ioctlTermios(fd int, request uintptr, termios *syscall.Termios) error {
+    _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(termios)))
+    if errno != 0 {
+        return errno
+    }
+    return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package lineedit
+
+import (
+	"errors"
+)
+
+// makeRaw is only implemented for Linux; elsewhere lines are read without editing
+func makeRaw(fd int) (restore func(), err error) {
+    return nil, errors.New("raw terminal mode is not supported on this platform")
+}
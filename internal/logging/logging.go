@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+/**
+* Structured logging
+
+The CLIs report what they are doing (loading weights, autotuning, saving
+results) through log/slog, on stderr, so stdout carries only results and
+both can be captured separately in automation:
+```
+-log-format text   time=2025-10-03T14:02:11.482+07:00 level=INFO msg="loading model" weights=./weights
+-log-format json   {"time":"2025-10-03T14:02:11.482+07:00","level":"INFO","msg":"loading model","weights":"./weights"}
+```
+Timestamps have millisecond precision and a numeric zone offset in both
+formats; durations are written the way time.Duration prints them, so
+"1.25ms" reads the same in text and JSON.
+
+-log-level picks the least severe level written: debug, info, warn or
+error. Without it the CLIs map -verbose to debug and -quiet to warn.
+*/
+
+// TimeFormat is the layout of the time of every log record
+const TimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// Format selects how log records are written
+type Format int
+
+const (
+    FormatText Format = iota // key=value pairs, one record per line
+    FormatJSON               // One JSON object per line
+)
+
+// String returns the flag name of the format
+func (f Format) String() string {
+    switch f {
+    case FormatText:
+        return "text"
+    case FormatJSON:
+        return "json"
+    default:
+        return fmt.Sprintf("Format(%d)", int(f))
+    }
+}
+
+// ParseFormat converts a flag name such as "json" to a Format
+// An empty name selects text
+func ParseFormat(name string) (Format, error) {
+    switch strings.ToLower(strings.TrimSpace(name)) {
+    case "", "text":
+        return FormatText, nil
+    case "json":
+        return FormatJSON, nil
+    }
+    return FormatText, fmt.Errorf("unknown log format %q (use text or json)", name)
+}
+
+// ParseLevel converts a flag name such as "debug" to a level
+func ParseLevel(name string) (slog.Level, error) {
+    switch strings.ToLower(strings.TrimSpace(name)) {
+    case "debug":
+        return slog.LevelDebug, nil
+    case "info":
+        return slog.LevelInfo, nil
+    case "warn", "warning":
+        return slog.LevelWarn, nil
+    case "error":
+        return slog.LevelError, nil
+    }
+    return slog.LevelInfo, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", name)
+}
+
+// Options configures a logger
+type Options struct {
+    Level  slog.Level // Least severe level written
+    Format Format
+}
+
+// New returns a logger writing records of opts.Level and above to w
+func New(w io.Writer, opts Options) *slog.Logger {
+    handlerOpts := &slog.HandlerOptions{Level: opts.Level, ReplaceAttr: replaceAttr}
+    if opts.Format == FormatJSON {
+        return slog.New(slog.NewJSONHandler(w, handlerOpts))
+    }
+    return slog.New(slog.NewTextHandler(w, handlerOpts))
+}
+
+// replaceAttr formats times with TimeFormat and durations as strings, alike in both formats
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+    switch a.Value.Kind() {
+    case slog.KindTime:
+        return slog.String(a.Key, a.Value.Time().Format(TimeFormat))
+    case slog.KindDuration:
+        return slog.String(a.Key, a.Value.Duration().String())
+    }
+    return a
+}
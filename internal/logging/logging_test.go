@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONRecords(t *testing.T) {
+    var buf bytes.Buffer
+    logger := New(&buf, Options{Level: slog.LevelInfo, Format: FormatJSON})
+    logger.Info("model loaded", "weights", "./weights", "duration", 1250*time.Microsecond)
+    logger.Debug("not written")
+
+    lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+    if len(lines) != 1 {
+        t.Fatalf("Expected one record, got %q", buf.String())
+    }
+
+    var record map[string]any
+    if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+        t.Fatalf("Record is not JSON: %v", err)
+    }
+    if record["msg"] != "model loaded" || record["level"] != "INFO" || record["weights"] != "./weights" {
+        t.Errorf("Unexpected record %v", record)
+    }
+    if record["duration"] != "1.25ms" {
+        t.Errorf("Expected duration 1.25ms, got %v", record["duration"])
+    }
+    if _, err := time.Parse(TimeFormat, record["time"].(string)); err != nil {
+        t.Errorf("Time %v not in TimeFormat: %v", record["time"], err)
+    }
+}
+
+func TestTextRecords(t *testing.T) {
+    var buf bytes.Buffer
+    logger := New(&buf, Options{Level: slog.LevelDebug})
+    logger.Debug("autotuned", "layer", "conv1", "took", 2*time.Second)
+
+    out := buf.String()
+    for _, want := range []string{"level=DEBUG", `msg=autotuned`, "layer=conv1", "took=2s"} {
+        if !strings.Contains(out, want) {
+            t.Errorf("Expected %q in %q", want, out)
+        }
+    }
+}
+
+func TestParseLevel(t *testing.T) {
+    for name, expected := range map[string]slog.Level{
+        "debug": slog.LevelDebug, "INFO": slog.LevelInfo, "warning": slog.LevelWarn, "error": slog.LevelError,
+    } {
+        got, err := ParseLevel(name)
+        if err != nil || got != expected {
+            t.Errorf("ParseLevel(%q) = %v, %v; want %v", name, got, err, expected)
+        }
+    }
+    if _, err := ParseLevel("trace"); err == nil {
+        t.Error("ParseLevel(\"trace\") should fail")
+    }
+}
+
+func TestParseFormat(t *testing.T) {
+    for name, expected := range map[string]Format{"": FormatText, "text": FormatText, "JSON": FormatJSON} {
+        got, err := ParseFormat(name)
+        if err != nil || got != expected {
+            t.Errorf("ParseFormat(%q) = %v, %v; want %v", name, got, err, expected)
+        }
+    }
+    if _, err := ParseFormat("logfmt"); err == nil {
+        t.Error("ParseFormat(\"logfmt\") should fail")
+    }
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"math"
+)
+
+/**
+* Matthews correlation coefficient and Cohen's kappa
+
+On an evaluation set that is 90% one class, a model that always predicts
+that class scores 90% accuracy. Both metrics here correct for that by
+comparing the predictions with what chance alone would get right, given
+how often each class occurs and is predicted. From the confusion matrix,
+with s samples, c of them correct, t_k samples of class k and p_k
+predictions of class k:
+```
+MCC   = (c·s - Σ p_k·t_k) / sqrt((s² - Σ p_k²) · (s² - Σ t_k²))
+
+p_o   = c / s                  (observed agreement, the accuracy)
+p_e   = Σ p_k·t_k / s²         (agreement expected by chance)
+kappa = (p_o - p_e) / (1 - p_e)
+```
+Both are 1 for perfect predictions and about 0 for chance level; MCC
+reaches down to -1 for systematic disagreement. The constant predictor
+above gets 0 for both. When a denominator is 0 (only one class labelled
+or predicted) the metric is undefined and reported as 0.
+*/
+
+// computeAgreement fills the Matthews correlation coefficient and Cohen's
+// kappa of result from its confusion matrix
+func computeAgreement(result *EvaluationResult) {
+    matrix := result.ConfusionMatrix
+    var samples, correct, chance, predictedSquares, trueSquares float64
+    for k := range matrix {
+        var trueK, predictedK float64
+        for other := range matrix {
+            trueK += float64(matrix[k][other])
+            predictedK += float64(matrix[other][k])
+        }
+        samples += trueK
+        correct += float64(matrix[k][k])
+        chance += predictedK * trueK
+        predictedSquares += predictedK * predictedK
+        trueSquares += trueK * trueK
+    }
+
+    result.MCC, result.CohensKappa = 0, 0
+    if samples == 0 {
+        return
+    }
+    squared := samples * samples
+    if denominator := math.Sqrt((squared - predictedSquares) * (squared - trueSquares)); denominator > 0 {
+        result.MCC = (correct*samples - chance) / denominator
+    }
+    observed, expected := correct/samples, chance/squared
+    if expected < 1 {
+        result.CohensKappa = (observed - expected) / (1 - expected)
+    }
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+/**
+* Averaged precision, recall and F1
+
+Per-class scores are read one class at a time; comparing two models needs
+one number. How the classes are combined changes what that number says,
+so three averages are reported, named and computed as scikit-learn does:
+```
+macro     mean of the per-class scores          every class counts the same,
+                                                so rare classes weigh in fully
+micro     scores of the pooled TP, FP and FN    every sample counts the same;
+          over all classes                      for single-label data precision,
+                                                recall and F1 all equal accuracy
+weighted  per-class scores weighted by support  like macro, but a class counts
+          (its number of true samples)          as often as it occurs
+```
+Macro F1 is the mean of the per-class F1 scores, not the F1 of the macro
+precision and recall. Classes that neither occur in the labels nor are
+ever predicted are left out, so a 100-class model tested on 10 classes is
+not dragged down by 90 empty ones.
+*/
+
+// Averaging selects how per-class metrics are combined
+type Averaging string
+
+// Averaging modes
+const (
+    MacroAveraging    Averaging = "macro"
+    MicroAveraging    Averaging = "micro"
+    WeightedAveraging Averaging = "weighted"
+)
+
+// AveragingModes lists every averaging mode in report order
+var AveragingModes = []Averaging{MacroAveraging, MicroAveraging, WeightedAveraging}
+
+// ParseAveraging converts a comma-separated list of modes, or "all", to Averagings
+// in the order given, each once
+func ParseAveraging(spec string) ([]Averaging, error) {
+    var modes []Averaging
+    for _, name := range strings.Split(spec, ",") {
+        name = strings.ToLower(strings.TrimSpace(name))
+        named := []Averaging{Averaging(name)}
+        switch Averaging(name) {
+        case MacroAveraging, MicroAveraging, WeightedAveraging:
+        default:
+            if name != "all" {
+                return nil, fmt.Errorf("unknown averaging %q (available: macro, micro, weighted, all)", name)
+            }
+            named = AveragingModes
+        }
+        for _, mode := range named {
+            if !slices.Contains(modes, mode) {
+                modes = append(modes, mode)
+            }
+        }
+    }
+    return modes, nil
+}
+
+// AveragedMetrics is precision, recall and F1 combined over the classes
+type AveragedMetrics struct {
+    Precision float64 `json:"precision"`
+    Recall    float64 `json:"recall"`
+    F1        float64 `json:"f1"`
+}
+
+// Averaged returns the metrics of result averaged by mode
+func (r *EvaluationResult) Averaged(mode Averaging) AveragedMetrics {
+    switch mode {
+    case MicroAveraging:
+        return r.MicroAverage
+    case WeightedAveraging:
+        return r.WeightedAverage
+    default:
+        return r.MacroAverage
+    }
+}
+
+// computeAveragedMetrics fills the macro, micro and weighted averages of result
+// from its confusion matrix and per-class scores
+func computeAveragedMetrics(result *EvaluationResult) {
+    matrix := result.ConfusionMatrix
+    var macro, weighted AveragedMetrics
+    var tp, fp, fn, support, counted int
+    for c := range matrix {
+        classSupport, predicted := 0, 0
+        for other := range matrix {
+            classSupport += matrix[c][other]
+            predicted += matrix[other][c]
+        }
+        if classSupport == 0 && predicted == 0 {
+            continue
+        }
+        counted++
+        tp += matrix[c][c]
+        fp += predicted - matrix[c][c]
+        fn += classSupport - matrix[c][c]
+        support += classSupport
+
+        macro.Precision += result.ClassPrecisions[c]
+        macro.Recall += result.ClassRecalls[c]
+        macro.F1 += result.ClassF1Scores[c]
+        weighted.Precision += float64(classSupport) * result.ClassPrecisions[c]
+        weighted.Recall += float64(classSupport) * result.ClassRecalls[c]
+        weighted.F1 += float64(classSupport) * result.ClassF1Scores[c]
+    }
+
+    result.MacroAverage, result.MicroAverage, result.WeightedAverage = AveragedMetrics{}, AveragedMetrics{}, AveragedMetrics{}
+    if counted > 0 {
+        result.MacroAverage = AveragedMetrics{
+            Precision: macro.Precision / float64(counted),
+            Recall:    macro.Recall / float64(counted),
+            F1:        macro.F1 / float64(counted),
+        }
+    }
+    if support > 0 {
+        result.WeightedAverage = AveragedMetrics{
+            Precision: weighted.Precision / float64(support),
+            Recall:    weighted.Recall / float64(support),
+            F1:        weighted.F1 / float64(support),
+        }
+    }
+    micro := &result.MicroAverage
+    if tp+fp > 0 {
+        micro.Precision = float64(tp) / float64(tp+fp)
+    }
+    if tp+fn > 0 {
+        micro.Recall = float64(tp) / float64(tp+fn)
+    }
+    if micro.Precision+micro.Recall > 0 {
+        micro.F1 = 2 * micro.Precision * micro.Recall / (micro.Precision + micro.Recall)
+    }
+}
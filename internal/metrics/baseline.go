@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/**
+* Regression gate
+
+A CI job stores the JSON report of a known-good build as the baseline and
+evaluates every later build on the same samples against it. The build fails
+when either gated metric got worse by more than the allowed regression:
+```
+metric           regression                          example, limit 1%
+top-1 accuracy   baseline - current (absolute)       0.912 → 0.899   1.3 points   ✗
+p95 latency      current / baseline - 1 (relative)   8.1ms → 8.15ms  +0.6%        ✓
+```
+Accuracy is compared in absolute percentage points because a relative
+drop would depend on how good the model already is; latency is compared
+relatively because its scale depends on the machine. Improvements never
+fail the gate. Latency is only comparable on the same machine and engine
+settings, and accuracy on the same samples, so a baseline that evaluated a
+different number of samples is reported alongside the checks.
+*/
+
+// Gated metrics
+const (
+    MetricTop1Accuracy = "top1_accuracy"
+    MetricP95Latency   = "p95_latency"
+)
+
+// regressionTolerance keeps a regression of exactly the limit from failing on rounding
+const regressionTolerance = 1e-9
+
+// RegressionCheck compares one metric with its baseline
+type RegressionCheck struct {
+    Metric     string  `json:"metric"`
+    Baseline   float64 `json:"baseline"`   // Accuracy as a fraction, latency in seconds
+    Current    float64 `json:"current"`
+    Regression float64 `json:"regression"` // How much worse as a fraction, negative for an improvement
+    Limit      float64 `json:"limit"`
+    Regressed  bool    `json:"regressed"`
+}
+
+// RegressionReport is the outcome of checking an evaluation against a baseline
+type RegressionReport struct {
+    BaselineSamples int               `json:"baseline_samples"`
+    CurrentSamples  int               `json:"current_samples"`
+    Checks          []RegressionCheck `json:"checks"`
+}
+
+// Regressed reports whether any metric regressed beyond its limit
+func (r *RegressionReport) Regressed() bool {
+    for _, check := range r.Checks {
+        if check.Regressed {
+            return true
+        }
+    }
+    return false
+}
+
+// ParseRegression converts a regression limit such as "1%" or "0.01" to a fraction
+func ParseRegression(s string) (float64, error) {
+    s = strings.TrimSpace(s)
+    scale := 1.0
+    if trimmed, ok := strings.CutSuffix(s, "%"); ok {
+        s, scale = strings.TrimSpace(trimmed), 0.01
+    }
+    v, err := strconv.ParseFloat(s, 64)
+    if err != nil || v < 0 {
+        return 0, fmt.Errorf("invalid regression %q: must be a non-negative fraction or percentage such as 1%%", s)
+    }
+    return v * scale, nil
+}
+
+// LoadBaseline reads the evaluation results of a JSON report written with -format json
+func LoadBaseline(path string) (*EvaluationResult, error) {
+    content, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read baseline: %w", err)
+    }
+    var baseline EvaluationResult
+    if err := json.Unmarshal(content, &baseline); err != nil {
+        return nil, fmt.Errorf("failed to parse baseline %s: %w", path, err)
+    }
+    if baseline.TotalSamples == 0 {
+        return nil, fmt.Errorf("baseline %s holds no evaluated samples; is it a JSON report?", path)
+    }
+    return &baseline, nil
+}
+
+// CheckRegression compares the top-1 accuracy and p95 latency of current with baseline,
+// allowing each to get worse by at most maxRegression
+func CheckRegression(baseline, current *EvaluationResult, maxRegression float64) *RegressionReport {
+    report := &RegressionReport{
+        BaselineSamples: baseline.TotalSamples,
+        CurrentSamples:  current.TotalSamples,
+    }
+
+    accuracy := RegressionCheck{
+        Metric:     MetricTop1Accuracy,
+        Baseline:   baseline.Top1Accuracy,
+        Current:    current.Top1Accuracy,
+        Regression: baseline.Top1Accuracy - current.Top1Accuracy,
+        Limit:      maxRegression,
+    }
+
+    latency := RegressionCheck{
+        Metric:   MetricP95Latency,
+        Baseline: baseline.P95InferenceTime.Seconds(),
+        Current:  current.P95InferenceTime.Seconds(),
+        Limit:    maxRegression,
+    }
+    if latency.Baseline > 0 {
+        latency.Regression = latency.Current/latency.Baseline - 1
+    }
+
+    for _, check := range []RegressionCheck{accuracy, latency} {
+        check.Regressed = check.Regression > check.Limit+regressionTolerance
+        report.Checks = append(report.Checks, check)
+    }
+    return report
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"math"
+)
+
+/**
+* Calibration: ECE, MCE and the reliability diagram
+
+A softmax output of 0.95 reads like "right 95% of the time", but networks
+trained with cross-entropy are usually overconfident: among the samples
+predicted at 0.95, far fewer than 95% may be correct. Calibration measures
+that gap. Samples are grouped by the confidence of their top prediction
+into equal-width bins, and each bin's accuracy is set against its mean
+confidence:
+```
+accuracy
+  1 ┤              ▁▆   ideal: bars reach the diagonal
+    │          ▁▄ ▆██   below it: overconfident
+    │      ▂▄ ▆██▇███
+    │  ▁▃ ▆██▇███████
+  0 ┼──────────────── confidence
+    0               1
+
+ECE = Σ_bins (n_b / N) · |accuracy_b - confidence_b|   (average gap, by sample)
+MCE = max_bins |accuracy_b - confidence_b|             (worst bin)
+```
+Both are 0 for a perfectly calibrated model. The bins are the data of the
+reliability diagram above; empty bins are kept, with zero counts, so every
+report has the same rows.
+*/
+
+// calibrationBins is the number of equal-width confidence bins
+const calibrationBins = 15
+
+// CalibrationBin is one bar of the reliability diagram
+type CalibrationBin struct {
+    Lower      float64 `json:"lower"`      // Confidences in [Lower, Upper); the last bin includes 1
+    Upper      float64 `json:"upper"`
+    Count      int     `json:"count"`
+    Accuracy   float64 `json:"accuracy"`   // Fraction of the bin's samples predicted correctly
+    Confidence float64 `json:"confidence"` // Mean top-1 confidence of the bin's samples
+}
+
+// computeCalibration fills the expected and maximum calibration error and the
+// reliability bins of result; failed predictions are left out
+func computeCalibration(result *EvaluationResult) {
+    bins := make([]CalibrationBin, calibrationBins)
+    for i := range bins {
+        bins[i].Lower = float64(i) / calibrationBins
+        bins[i].Upper = float64(i+1) / calibrationBins
+    }
+
+    total := 0
+    for _, pred := range result.Predictions {
+        if pred.PredictedClass < 0 {
+            continue
+        }
+        confidence := float64(pred.Confidence)
+        i := min(int(confidence*calibrationBins), calibrationBins-1)
+        i = max(i, 0)
+        bins[i].Count++
+        bins[i].Confidence += confidence
+        if pred.Correct {
+            bins[i].Accuracy++
+        }
+        total++
+    }
+
+    result.ExpectedCalibrationError, result.MaxCalibrationError = 0, 0
+    for i := range bins {
+        b := &bins[i]
+        if b.Count == 0 {
+            continue
+        }
+        b.Accuracy /= float64(b.Count)
+        b.Confidence /= float64(b.Count)
+        gap := math.Abs(b.Accuracy - b.Confidence)
+        result.ExpectedCalibrationError += float64(b.Count) / float64(total) * gap
+        result.MaxCalibrationError = math.Max(result.MaxCalibrationError, gap)
+    }
+    result.Reliability = bins
+}
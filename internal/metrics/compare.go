@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+/**
+* Model accuracy comparison
+
+Top-1 accuracy alone hides what changed between two models: a 0.5% drop
+after quantization may be spread evenly or concentrated in one class, and a
+layer whose int8 scale is badly calibrated shows up long before the final
+prediction flips. The comparison evaluates models A and B on the same batch,
+then runs both layer by layer, each on its own previous output, and
+measures how far B's activations have drifted from A's at every layer:
+```
+MSE  = mean((b - a)²)
+SQNR = 10·log10(mean(a²) / MSE)      (dB; higher is better)
+```
+Because each model feeds itself, the error at a layer includes everything
+accumulated before it, which is what the classifier finally sees.
+
+Nothing assumes either model is quantized: any two bundles of the same
+architecture, such as float and int8 weights or weights before and after
+an update, can be compared, and McNemar's test (mcnemar.go) says whether
+the accuracy difference between them is significant.
+*/
+
+// LayerError is the drift of one layer's output in model B from the output in model A
+type LayerError struct {
+    Layer       string  `json:"layer"`
+    MSE         float64 `json:"mse"`           // Mean squared error over all samples
+    MaxAbsError float64 `json:"max_abs_error"` // Largest absolute difference seen
+    SQNR        float64 `json:"sqnr_db"`       // Signal to noise ratio of B against A in dB; 0 when MSE is 0
+}
+
+// ComparisonResult holds the evaluation of two models, A and B, on the same samples
+type ComparisonResult struct {
+    A *EvaluationResult `json:"a"`
+    B *EvaluationResult `json:"b"`
+
+    Top1Delta           float64       `json:"top1_delta"`            // B minus A top-1 accuracy
+    Top5Delta           float64       `json:"top5_delta"`            // B minus A top-5 accuracy
+    ClassAccuracyDeltas []float64     `json:"class_accuracy_deltas"` // B minus A accuracy per class
+    Agreement           float64       `json:"agreement"`             // Fraction of samples both models predict alike
+    McNemar             McNemarTest   `json:"mcnemar"`               // Whether the accuracy difference is significant
+    ClassWins           []int         `json:"class_wins"`            // Samples per true class only B gets right
+    ClassLosses         []int         `json:"class_losses"`          // Samples per true class only A gets right
+    DisagreementMatrix  [][]int       `json:"disagreement_matrix"`   // Samples by A's (row) and B's (column) prediction
+    LatencyDelta        time.Duration `json:"latency_delta"`         // B minus A average inference time
+    P99LatencyDelta     time.Duration `json:"p99_latency_delta"`     // B minus A p99 inference time
+    LayerErrors         []LayerError  `json:"layer_errors"`
+}
+
+// layerRunner is a Predictor that can also run its layers one at a time
+type layerRunner interface {
+    model.Predictor
+    RunLayer(name string, input *tensor.FeatureMap) (*tensor.FeatureMap, error)
+}
+
+// CompareModels evaluates models a and b on the same images and labels and, when
+// both can run single layers, measures how far each layer's output in b is from
+// the output in a; LayerErrors is nil otherwise. Layer by layer comparison requires
+// both models to share the architecture's layer names and shapes. Deltas, wins and
+// losses are those of b against a.
+func (e *Evaluator) CompareModels(a, b model.Predictor, images []*tensor.FeatureMap,
+    labels [][]int) (*ComparisonResult, error) {
+
+    slog.Debug("evaluating model A")
+    aResult, err := e.EvaluateModel(a, images, labels)
+    if err != nil {
+        return nil, fmt.Errorf("model A: %w", err)
+    }
+
+    slog.Debug("evaluating model B")
+    bResult, err := e.EvaluateModel(b, images, labels)
+    if err != nil {
+        return nil, fmt.Errorf("model B: %w", err)
+    }
+
+    result := &ComparisonResult{
+        A:                   aResult,
+        B:                   bResult,
+        Top1Delta:           bResult.Top1Accuracy - aResult.Top1Accuracy,
+        Top5Delta:           bResult.Top5Accuracy - aResult.Top5Accuracy,
+        ClassAccuracyDeltas: make([]float64, min(len(aResult.ClassAccuracies), len(bResult.ClassAccuracies))),
+    }
+    for i := range result.ClassAccuracyDeltas {
+        result.ClassAccuracyDeltas[i] = bResult.ClassAccuracies[i] - aResult.ClassAccuracies[i]
+    }
+
+    agree := 0
+    numClasses := max(len(aResult.ConfusionMatrix), len(bResult.ConfusionMatrix))
+    result.DisagreementMatrix = newConfusionMatrix(numClasses)
+    for i, pred := range aResult.Predictions {
+        other := bResult.Predictions[i].PredictedClass
+        if pred.PredictedClass == other {
+            agree++
+        }
+        if pred.PredictedClass >= 0 && other >= 0 {
+            result.DisagreementMatrix[pred.PredictedClass][other]++
+        }
+    }
+    result.LatencyDelta = bResult.AverageInferenceTime - aResult.AverageInferenceTime
+    result.P99LatencyDelta = bResult.P99InferenceTime - aResult.P99InferenceTime
+    if len(images) > 0 {
+        result.Agreement = float64(agree) / float64(len(images))
+    }
+    result.McNemar, result.ClassWins, result.ClassLosses = computeMcNemar(aResult.Predictions,
+        bResult.Predictions, len(result.ClassAccuracyDeltas))
+
+    aLayers, aOK := a.(layerRunner)
+    bLayers, bOK := b.(layerRunner)
+    if !aOK || !bOK {
+        return result, nil
+    }
+    slog.Debug("measuring per-layer output error")
+    result.LayerErrors, err = compareLayers(aLayers, bLayers, images)
+    if err != nil {
+        return nil, err
+    }
+
+    return result, nil
+}
+
+// layerErrorSums accumulates the error statistics of one layer over samples
+type layerErrorSums struct {
+    squaredError float64
+    signal       float64
+    count        int
+    maxAbs       float64
+}
+
+// compareLayers runs every image through both models one layer at a time and
+// returns the output error of each layer in architecture order
+func compareLayers(a, b layerRunner, images []*tensor.FeatureMap) ([]LayerError, error) {
+    layers := a.Info().Architecture.Layers
+    sums := make([]layerErrorSums, len(layers))
+
+    for sample, image := range images {
+        aCurrent, bCurrent := image, image
+        for i, layer := range layers {
+            aNext, err := a.RunLayer(layer.Name, aCurrent)
+            if err != nil {
+                return nil, fmt.Errorf("sample %d, model A: %w", sample, err)
+            }
+            bNext, err := b.RunLayer(layer.Name, bCurrent)
+            if err != nil {
+                return nil, fmt.Errorf("sample %d, model B: %w", sample, err)
+            }
+            if len(aNext.Data) != len(bNext.Data) {
+                return nil, fmt.Errorf("layer %s: model A output has %d values, model B %d",
+                    layer.Name, len(aNext.Data), len(bNext.Data))
+            }
+
+            // The models may run in different layouts; compare in model A's
+            bAligned := bNext
+            if bNext.Layout != aNext.Layout {
+                bAligned = bNext.ToLayout(aNext.Layout)
+            }
+
+            s := &sums[i]
+            for j, f := range aNext.Data {
+                diff := float64(bAligned.Data[j] - f)
+                s.squaredError += diff * diff
+                s.signal += float64(f) * float64(f)
+                s.maxAbs = math.Max(s.maxAbs, math.Abs(diff))
+            }
+            s.count += len(aNext.Data)
+
+            aCurrent, bCurrent = aNext, bNext
+        }
+    }
+
+    errors := make([]LayerError, len(layers))
+    for i, layer := range layers {
+        s := sums[i]
+        errors[i] = LayerError{Layer: layer.Name, MaxAbsError: s.maxAbs}
+        if s.count == 0 {
+            continue
+        }
+        errors[i].MSE = s.squaredError / float64(s.count)
+        if s.squaredError > 0 {
+            errors[i].SQNR = 10 * math.Log10(s.signal/s.squaredError)
+        }
+    }
+    return errors, nil
+}
@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/model"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+/**
+* K-fold cross-validation
+
+One train/test split gives one accuracy, and on a small dataset that number
+moves by points with the split alone. Cross-validation shuffles the samples
+once, cuts them into k folds, and holds every fold out in turn:
+```
+fold 1   test ■□□□□   train on the other four
+fold 2   test □■□□□
+...
+fold 5   test □□□□■
+```
+Every sample is tested exactly once, and the spread of the k scores shows
+how much of a difference between two models is noise. The report gives the
+mean and the sample standard deviation (n-1) of every metric over the folds.
+
+A fold's model is whatever the caller's FoldModel returns: one trained on
+the fold's Train samples, or one of k weight sets trained elsewhere on the
+same split (ProvidedModels).
+*/
+
+// Fold is one split of a k-fold cross-validation
+type Fold struct {
+    Index int   // 0-based
+    Train []int // Indices of the samples to train on, increasing
+    Test  []int // Indices of the held-out samples, increasing
+}
+
+// FoldModel returns the model to evaluate on the held-out samples of fold
+type FoldModel func(fold Fold) (model.Predictor, error)
+
+// MeanStd is a metric summarized over the folds
+type MeanStd struct {
+    Mean float64 `json:"mean"`
+    Std  float64 `json:"std"` // Sample standard deviation, 0 for a single fold
+}
+
+// FoldResult is the evaluation of one fold's model on its held-out samples
+type FoldResult struct {
+    Fold                     int           `json:"fold"` // 1-based, as reported
+    TrainSamples             int           `json:"train_samples"`
+    TestSamples              int           `json:"test_samples"`
+    Top1Accuracy             float64       `json:"top1_accuracy"`
+    Top5Accuracy             float64       `json:"top5_accuracy"`
+    MacroF1                  float64       `json:"macro_f1"`
+    MCC                      float64       `json:"mcc"`
+    ExpectedCalibrationError float64       `json:"expected_calibration_error"`
+    AverageInferenceTime     time.Duration `json:"average_inference_time"`
+}
+
+// CrossValidationReport holds the fold results and their mean and spread
+type CrossValidationReport struct {
+    Folds                    []FoldResult `json:"folds"`
+    Top1Accuracy             MeanStd      `json:"top1_accuracy"`
+    Top5Accuracy             MeanStd      `json:"top5_accuracy"`
+    MacroF1                  MeanStd      `json:"macro_f1"`
+    MCC                      MeanStd      `json:"mcc"`
+    ExpectedCalibrationError MeanStd      `json:"expected_calibration_error"`
+}
+
+// KFold shuffles n samples with seed and splits them into k folds whose sizes
+// differ by at most one
+func KFold(n, k int, seed uint64) ([]Fold, error) {
+    if k < 2 || k > n {
+        return nil, fmt.Errorf("cannot split %d samples into %d folds: need 2 to %d folds", n, k, n)
+    }
+    order := rand.New(rand.NewPCG(seed, 0)).Perm(n)
+
+    folds := make([]Fold, k)
+    start := 0
+    for i := range folds {
+        size := n / k
+        if i < n%k {
+            size++
+        }
+        test := slices.Clone(order[start : start+size])
+        train := append(slices.Clone(order[:start]), order[start+size:]...)
+        slices.Sort(test)
+        slices.Sort(train)
+        folds[i] = Fold{Index: i, Train: train, Test: test}
+        start += size
+    }
+    return folds, nil
+}
+
+// ProvidedModels evaluates models[i] on fold i, for weight sets trained elsewhere
+// on the folds KFold returns for the same samples and seed
+func ProvidedModels(models ...model.Predictor) FoldModel {
+    return func(fold Fold) (model.Predictor, error) {
+        if fold.Index >= len(models) {
+            return nil, fmt.Errorf("fold %d has no model: %d given", fold.Index+1, len(models))
+        }
+        return models[fold.Index], nil
+    }
+}
+
+// CrossValidate evaluates the model build returns for every fold on the fold's
+// held-out samples of set, run through preprocessor (none when nil), and
+// summarizes the results; the evaluator's settings apply to every fold
+func (e *Evaluator) CrossValidate(set data.LabeledSet, classes int, preprocessor *data.Preprocessor,
+    folds []Fold, build FoldModel) (*CrossValidationReport, error) {
+
+    report := &CrossValidationReport{}
+    for _, fold := range folds {
+        slog.Debug("cross-validating", "fold", fold.Index+1, "folds", len(folds),
+            "train_samples", len(fold.Train), "test_samples", len(fold.Test))
+        cnn, err := build(fold)
+        if err != nil {
+            return nil, fmt.Errorf("fold %d: %w", fold.Index+1, err)
+        }
+
+        var test data.DatasetIterator = data.LabeledIterator(data.Subset(set, fold.Test), classes)
+        if preprocessor != nil {
+            test = data.Preprocessed(test, preprocessor)
+        }
+        result, err := e.EvaluateIterator(cnn, test)
+        if err != nil {
+            return nil, fmt.Errorf("fold %d: %w", fold.Index+1, err)
+        }
+        report.Folds = append(report.Folds, FoldResult{
+            Fold:                     fold.Index + 1,
+            TrainSamples:             len(fold.Train),
+            TestSamples:              result.TotalSamples,
+            Top1Accuracy:             result.Top1Accuracy,
+            Top5Accuracy:             result.Top5Accuracy,
+            MacroF1:                  result.MacroAverage.F1,
+            MCC:                      result.MCC,
+            ExpectedCalibrationError: result.ExpectedCalibrationError,
+            AverageInferenceTime:     result.AverageInferenceTime,
+        })
+    }
+
+    summarize := func(metric func(FoldResult) float64) MeanStd {
+        values := make([]float64, len(report.Folds))
+        for i, fold := range report.Folds {
+            values[i] = metric(fold)
+        }
+        return meanStd(values)
+    }
+    report.Top1Accuracy = summarize(func(f FoldResult) float64 { return f.Top1Accuracy })
+    report.Top5Accuracy = summarize(func(f FoldResult) float64 { return f.Top5Accuracy })
+    report.MacroF1 = summarize(func(f FoldResult) float64 { return f.MacroF1 })
+    report.MCC = summarize(func(f FoldResult) float64 { return f.MCC })
+    report.ExpectedCalibrationError = summarize(func(f FoldResult) float64 { return f.ExpectedCalibrationError })
+    return report, nil
+}
+
+// meanStd returns the mean and sample standard deviation of values
+func meanStd(values []float64) MeanStd {
+    if len(values) == 0 {
+        return MeanStd{}
+    }
+    var sum float64
+    for _, v := range values {
+        sum += v
+    }
+    mean := sum / float64(len(values))
+    if len(values) == 1 {
+        return MeanStd{Mean: mean}
+    }
+
+    var squares float64
+    for _, v := range values {
+        squares += (v - mean) * (v - mean)
+    }
+    return MeanStd{Mean: mean, Std: math.Sqrt(squares / float64(len(values)-1))}
+}
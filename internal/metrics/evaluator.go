@@ -1,9 +1,17 @@
 package metrics
 
 import (
+	"context"
+	"duchm1606/gocnn/internal/data"
 	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/ops"
+	"duchm1606/gocnn/internal/runinfo"
 	"duchm1606/gocnn/internal/tensor"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"slices"
 	"sync"
 	"time"
 )
@@ -11,17 +19,44 @@ import (
 // Evaluator performs comprehensive model evaluation
 type Evaluator struct {
     numWorkers int
-    verbose    bool
+    batchSize  int
+    topK       []int
+    warmup     int
+    histogram  HistogramOptions
+    
+    misclassified *MisclassifiedOptions // Set by SetMisclassifiedExport
+    memory        *MemoryOptions        // Set by SetMemoryTracking
+    progress      ProgressReporter      // Set by SetProgress
+    progressTotal int
 }
 
 // NewEvaluator creates a new evaluator
-func NewEvaluator(numWorkers int, verbose bool) *Evaluator {
+func NewEvaluator(numWorkers int) *Evaluator {
     return &Evaluator{
         numWorkers: numWorkers,
-        verbose:    verbose,
+        batchSize:  1,
+        topK:       []int{5},
     }
 }
 
+// SetTopK selects the K values of the top-K accuracies reported in TopKAccuracies,
+// such as the config's benchmark.report_top_k; values below 1 are ignored
+func (e *Evaluator) SetTopK(ks ...int) {
+    e.topK = nil
+    for _, k := range ks {
+        if k > 0 && !slices.Contains(e.topK, k) {
+            e.topK = append(e.topK, k)
+        }
+    }
+    slices.Sort(e.topK)
+}
+
+// SetBatchSize makes every worker predict up to n samples at once with
+// model.PredictBatch; each sample's inference time is its share of the batch's
+func (e *Evaluator) SetBatchSize(n int) {
+    e.batchSize = max(n, 1)
+}
+
 // EvaluationResult holds comprehensive evaluation results
 type EvaluationResult struct {
     // Basic metrics
@@ -29,6 +64,7 @@ type EvaluationResult struct {
     CorrectPredictions int     `json:"correct_predictions"`
     Top1Accuracy       float64 `json:"top1_accuracy"`
     Top5Accuracy       float64 `json:"top5_accuracy"`
+    TopKAccuracies     []TopKAccuracy `json:"top_k_accuracies"` // For each K selected with SetTopK
     
     // Per-class metrics
     ClassAccuracies    []float64 `json:"class_accuracies"`
@@ -36,6 +72,26 @@ type EvaluationResult struct {
     ClassRecalls       []float64 `json:"class_recalls"`
     ClassF1Scores      []float64 `json:"class_f1_scores"`
     
+    // Per-class metrics averaged over the classes (see Averaging)
+    MacroAverage       AveragedMetrics `json:"macro_average"`
+    MicroAverage       AveragedMetrics `json:"micro_average"`
+    WeightedAverage    AveragedMetrics `json:"weighted_average"`
+    
+    // Chance-corrected agreement of predictions and labels
+    MCC                float64 `json:"mcc"`
+    CohensKappa        float64 `json:"cohens_kappa"`
+    
+    // One-vs-rest ROC curves per class, their mean AUC, and the pooled micro-averaged curve
+    ROCCurves          []ROCCurve `json:"roc_curves"`
+    MacroAUC           float64    `json:"macro_auc"`
+    MicroAUC           float64    `json:"micro_auc"`
+    MicroROC           []ROCPoint `json:"micro_roc"`
+    
+    // Calibration of the top-1 confidences and the reliability diagram's bins
+    ExpectedCalibrationError float64          `json:"expected_calibration_error"`
+    MaxCalibrationError      float64          `json:"max_calibration_error"`
+    Reliability              []CalibrationBin `json:"reliability"`
+    
     // Confusion matrix
     ConfusionMatrix    [][]int   `json:"confusion_matrix"`
     
@@ -44,15 +100,35 @@ type EvaluationResult struct {
     AverageInferenceTime time.Duration          `json:"average_inference_time"`
     MinInferenceTime   time.Duration            `json:"min_inference_time"`
     MaxInferenceTime   time.Duration            `json:"max_inference_time"`
+    P50InferenceTime   time.Duration            `json:"p50_inference_time"`
+    P90InferenceTime   time.Duration            `json:"p90_inference_time"`
+    P95InferenceTime   time.Duration            `json:"p95_inference_time"`
+    P99InferenceTime   time.Duration            `json:"p99_inference_time"`
+    LatencyHistogram   LatencyHistogram         `json:"latency_histogram"`
     LayerTimings       map[string]time.Duration `json:"layer_timings"`
+    Cold               *ColdTiming              `json:"cold,omitempty"` // Timings of the SetWarmup samples, left out of the above
+    Memory             *MemoryStats             `json:"memory,omitempty"` // With SetMemoryTracking
+    Roofline           *Roofline                `json:"roofline,omitempty"` // Per-layer achieved throughput; set by the caller
     
     // Throughput metrics
     Throughput         float64 `json:"throughput"` // samples per second
     
+    // Engine settings the timings were measured with
+    Engine             ops.EngineOptions `json:"engine"`
+    
+    // How the run was produced (binary, config, weights, host); set by the caller
+    Run                *runinfo.Manifest `json:"run,omitempty"`
+    
     // Individual predictions (for detailed analysis)
     Predictions        []PredictionDetail `json:"predictions,omitempty"`
 }
 
+// TopKAccuracy is the fraction of samples whose true class is among the K most probable
+type TopKAccuracy struct {
+    K        int     `json:"k"`
+    Accuracy float64 `json:"accuracy"`
+}
+
 // PredictionDetail holds information about a single prediction
 type PredictionDetail struct {
     SampleIndex    int           `json:"sample_index"`
@@ -62,30 +138,51 @@ type PredictionDetail struct {
     Probabilities  []float32     `json:"probabilities"`
     InferenceTime  time.Duration `json:"inference_time"`
     Correct        bool          `json:"correct"`
+    Source         string        `json:"source,omitempty"` // File the sample was read from, if known
+    AllocBytes     uint64        `json:"alloc_bytes,omitempty"` // Heap bytes allocated by the inference; with SetMemoryTracking
+    Allocs         uint64        `json:"allocs,omitempty"`      // Heap objects allocated by the inference
+
+    layerAllocs map[string]model.AllocCounters // The model's per-layer allocations, summarized in MemoryStats
 }
 
 // EvaluateModel performs comprehensive evaluation of the model
-func (e *Evaluator) EvaluateModel(cnn *model.TinyCNN, images []*tensor.FeatureMap, labels [][]int) (*EvaluationResult, error) {
-    numSamples := len(images)
-    if numSamples != len(labels) {
-        return nil, fmt.Errorf("number of images (%d) doesn't match number of labels (%d)", numSamples, len(labels))
+func (e *Evaluator) EvaluateModel(cnn model.Predictor, images []*tensor.FeatureMap, labels [][]int) (*EvaluationResult, error) {
+    if len(images) != len(labels) {
+        return nil, fmt.Errorf("number of images (%d) doesn't match number of labels (%d)", len(images), len(labels))
     }
+    return e.EvaluateIterator(cnn, data.NewBatchIterator(&data.DataBatch{Images: images, Labels: labels, Size: len(images)}))
+}
+
+// sample is one image handed from the dataset reader to the workers
+type sample struct {
+    index  int
+    image  *tensor.FeatureMap
+    label  []int
+    source string
+}
+
+// EvaluateIterator evaluates the model on every sample of it, reading samples only as
+// fast as the workers consume them so at most a few images are in memory at once.
+// The iterator is closed when evaluation ends.
+func (e *Evaluator) EvaluateIterator(cnn model.Predictor, it data.DatasetIterator) (*EvaluationResult, error) {
+    defer it.Close()
 
     // Initialize result
+    info := cnn.Info()
     result := &EvaluationResult{
-        TotalSamples:    numSamples,
-        ConfusionMatrix: make([][]int, 10),
-        LayerTimings:    make(map[string]time.Duration),
-        Predictions:     make([]PredictionDetail, numSamples),
+        LayerTimings: make(map[string]time.Duration),
+        Engine:       info.Engine,
     }
 
-    for i := range result.ConfusionMatrix {
-        result.ConfusionMatrix[i] = make([]int, 10)
+    var memory *memoryRun
+    if e.memory != nil {
+        memory = startMemoryRun(cnn, *e.memory)
     }
 
-    // Create work channels
-    jobs := make(chan int, numSamples)
-    results := make(chan PredictionDetail, numSamples)
+    // Create work channels; the job buffer bounds how far reading runs ahead
+    // Every job is a batch of up to batchSize consecutive samples
+    jobs := make(chan []sample, 2*e.numWorkers)
+    results := make(chan PredictionDetail, 2*e.numWorkers*e.batchSize)
 
     // Start workers
     var wg sync.WaitGroup
@@ -93,19 +190,43 @@ func (e *Evaluator) EvaluateModel(cnn *model.TinyCNN, images []*tensor.FeatureMa
         wg.Add(1)
         go func() {
             defer wg.Done()
-            for sampleIdx := range jobs {
-                detail := e.evaluateSample(cnn, images[sampleIdx], labels[sampleIdx], sampleIdx)
-                results <- detail
+            for job := range jobs {
+                if len(job) == 1 {
+                    detail := e.evaluateSample(cnn, job[0].image, job[0].label, job[0].index)
+                    detail.Source = job[0].source
+                    results <- detail
+                    continue
+                }
+                for _, detail := range e.evaluateBatch(cnn, job) {
+                    results <- detail
+                }
             }
         }()
     }
 
-    // Send jobs
+    // Send jobs as they are read; a read error stops the stream
+    var readErr error
     go func() {
-        for i := 0; i < numSamples; i++ {
-            jobs <- i
+        defer close(jobs)
+        var batch []sample
+        for i := 0; ; i++ {
+            image, label, err := it.Next()
+            if errors.Is(err, io.EOF) {
+                break
+            }
+            if err != nil {
+                readErr = fmt.Errorf("sample %d: %w", i, err)
+                return
+            }
+            batch = append(batch, sample{index: i, image: image, label: label, source: data.SourcePath(it, i)})
+            if len(batch) == e.batchSize {
+                jobs <- batch
+                batch = nil
+            }
+        }
+        if len(batch) > 0 {
+            jobs <- batch
         }
-        close(jobs)
     }()
 
     // Wait for workers to complete
@@ -115,29 +236,72 @@ func (e *Evaluator) EvaluateModel(cnn *model.TinyCNN, images []*tensor.FeatureMa
     }()
 
     // Collect results
+    start := time.Now()
+    progress := Progress{Total: e.progressTotal}
     for detail := range results {
+        for len(result.Predictions) <= detail.SampleIndex {
+            result.Predictions = append(result.Predictions, PredictionDetail{})
+        }
         result.Predictions[detail.SampleIndex] = detail
+        result.TotalSamples++
         
-        if e.verbose && detail.SampleIndex%10 == 0 {
-            fmt.Printf("  Processed %d/%d samples\n", detail.SampleIndex+1, numSamples)
+        if e.progress != nil {
+            progress.Done++
+            if detail.Correct {
+                progress.Correct++
+            }
+            progress.Elapsed = time.Since(start)
+            e.progress.Update(progress)
         }
     }
+    if e.progress != nil {
+        if readErr == nil {
+            progress.Total = progress.Done // The dataset may hold fewer samples than expected
+        }
+        progress.Elapsed = time.Since(start)
+        e.progress.Finish(progress)
+    }
+
+    if memory != nil {
+        result.Memory = memory.stop()
+    }
+    if readErr != nil {
+        return nil, readErr
+    }
+    if result.TotalSamples == 0 {
+        return nil, fmt.Errorf("the dataset holds no samples")
+    }
 
     // Compute aggregate metrics
+    result.ConfusionMatrix = newConfusionMatrix(classCount(info.Architecture.NumClasses, result.Predictions))
     e.computeAggregateMetrics(result)
 
+    if e.misclassified != nil {
+        if _, err := ExportMisclassified(result.Predictions, *e.misclassified); err != nil {
+            return nil, err
+        }
+    }
+
     return result, nil
 }
 
 // evaluateSample evaluates a single sample
-func (e *Evaluator) evaluateSample(cnn *model.TinyCNN, image *tensor.FeatureMap, label []int, sampleIdx int) PredictionDetail {
+func (e *Evaluator) evaluateSample(cnn model.Predictor, image *tensor.FeatureMap, label []int, sampleIdx int) PredictionDetail {
     // Convert feature map to flat array
     imageData := image.Data
 
     // Run inference
+    var allocsBefore model.AllocCounters
+    if e.memory != nil {
+        allocsBefore = model.ReadAllocCounters()
+    }
     start := time.Now()
-    prediction, err := cnn.Predict(imageData)
+    prediction, err := cnn.Predict(context.Background(), imageData)
     inferenceTime := time.Since(start)
+    var allocs model.AllocCounters
+    if e.memory != nil {
+        allocs = model.ReadAllocCounters().Sub(allocsBefore)
+    }
 
     if err != nil {
         // Handle error case
@@ -148,6 +312,8 @@ func (e *Evaluator) evaluateSample(cnn *model.TinyCNN, image *tensor.FeatureMap,
             Confidence:    0,
             InferenceTime: inferenceTime,
             Correct:       false,
+            AllocBytes:    allocs.Bytes,
+            Allocs:        allocs.Objects,
         }
     }
 
@@ -162,18 +328,91 @@ func (e *Evaluator) evaluateSample(cnn *model.TinyCNN, image *tensor.FeatureMap,
         Probabilities:  prediction.Probabilities,
         InferenceTime:  inferenceTime,
         Correct:        correct,
+        AllocBytes:     allocs.Bytes,
+        Allocs:         allocs.Objects,
+        layerAllocs:    prediction.LayerAllocs,
     }
 }
 
+// evaluateBatch evaluates the samples of a batch with one PredictBatch call
+func (e *Evaluator) evaluateBatch(cnn model.Predictor, batch []sample) []PredictionDetail {
+    images := make([][]float32, len(batch))
+    for i, s := range batch {
+        images[i] = s.image.Data
+    }
+
+    var allocsBefore model.AllocCounters
+    if e.memory != nil {
+        allocsBefore = model.ReadAllocCounters()
+    }
+    start := time.Now()
+    predictions, err := cnn.PredictBatch(context.Background(), images)
+    inferenceTime := time.Since(start) / time.Duration(len(batch))
+    var allocs model.AllocCounters
+    if e.memory != nil {
+        total := model.ReadAllocCounters().Sub(allocsBefore)
+        n := uint64(len(batch))
+        allocs = model.AllocCounters{Bytes: total.Bytes / n, Objects: total.Objects / n}
+    }
+
+    details := make([]PredictionDetail, len(batch))
+    for i, s := range batch {
+        trueClass := argmaxInt(s.label)
+        if err != nil {
+            details[i] = PredictionDetail{
+                SampleIndex:    s.index,
+                TrueClass:      trueClass,
+                PredictedClass: -1,
+                InferenceTime:  inferenceTime,
+                Source:         s.source,
+                AllocBytes:     allocs.Bytes,
+                Allocs:         allocs.Objects,
+            }
+            continue
+        }
+
+        prediction := predictions[i]
+        details[i] = PredictionDetail{
+            SampleIndex:    s.index,
+            TrueClass:      trueClass,
+            PredictedClass: prediction.PredictedClass,
+            Confidence:     prediction.Confidence,
+            Probabilities:  prediction.Probabilities,
+            InferenceTime:  inferenceTime,
+            Correct:        prediction.PredictedClass == trueClass,
+            Source:         s.source,
+            AllocBytes:     allocs.Bytes,
+            Allocs:         allocs.Objects,
+            layerAllocs:    prediction.LayerAllocs,
+        }
+    }
+    return details
+}
+
+// classCount returns the number of classes of an evaluation: the model's, widened
+// to every class that appears as a label or a prediction so none is dropped
+func classCount(modelClasses int, predictions []PredictionDetail) int {
+    n := modelClasses
+    for _, pred := range predictions {
+        n = max(n, pred.TrueClass+1, pred.PredictedClass+1, len(pred.Probabilities))
+    }
+    return n
+}
+
+// newConfusionMatrix returns a zero numClasses×numClasses matrix
+func newConfusionMatrix(numClasses int) [][]int {
+    matrix := make([][]int, numClasses)
+    for i := range matrix {
+        matrix[i] = make([]int, numClasses)
+    }
+    return matrix
+}
+
 // computeAggregateMetrics computes all aggregate metrics from individual predictions
+// into result, whose ConfusionMatrix must be sized for every class
 func (e *Evaluator) computeAggregateMetrics(result *EvaluationResult) {
     numClasses := len(result.ConfusionMatrix)
     
-    // Initialize timing metrics
-    result.MinInferenceTime = result.Predictions[0].InferenceTime
-    result.MaxInferenceTime = result.Predictions[0].InferenceTime
-    var totalTime time.Duration
-
     // Count correct predictions and build confusion matrix
     for _, pred := range result.Predictions {
         if pred.Correct {
@@ -185,50 +424,114 @@ func (e *Evaluator) computeAggregateMetrics(result *EvaluationResult) {
            pred.PredictedClass >= 0 && pred.PredictedClass < numClasses {
             result.ConfusionMatrix[pred.TrueClass][pred.PredictedClass]++
         }
-
-        // Update timing metrics
-        totalTime += pred.InferenceTime
-        if pred.InferenceTime < result.MinInferenceTime {
-            result.MinInferenceTime = pred.InferenceTime
-        }
-        if pred.InferenceTime > result.MaxInferenceTime {
-            result.MaxInferenceTime = pred.InferenceTime
-        }
     }
 
     // Compute accuracy metrics
     result.Top1Accuracy = float64(result.CorrectPredictions) / float64(result.TotalSamples)
-    result.Top5Accuracy = e.computeTop5Accuracy(result.Predictions)
+    accuracies := e.computeTopKAccuracies(result.Predictions, append([]int{5}, e.topK...))
+    result.Top5Accuracy = accuracies[0]
+    result.TopKAccuracies = make([]TopKAccuracy, len(e.topK))
+    for i, k := range e.topK {
+        result.TopKAccuracies[i] = TopKAccuracy{K: k, Accuracy: accuracies[i+1]}
+    }
 
-    // Compute timing metrics
+    // Compute timing metrics over the warm samples
+    cold, warm := e.splitWarmup(result.Predictions)
+    result.Cold = computeColdTiming(cold)
+    result.MinInferenceTime = warm[0].InferenceTime
+    result.MaxInferenceTime = warm[0].InferenceTime
+    var totalTime time.Duration
+    for _, pred := range warm {
+        totalTime += pred.InferenceTime
+        result.MinInferenceTime = min(result.MinInferenceTime, pred.InferenceTime)
+        result.MaxInferenceTime = max(result.MaxInferenceTime, pred.InferenceTime)
+    }
     result.TotalInferenceTime = totalTime
-    result.AverageInferenceTime = totalTime / time.Duration(result.TotalSamples)
-    result.Throughput = float64(result.TotalSamples) / totalTime.Seconds()
+    result.AverageInferenceTime = totalTime / time.Duration(len(warm))
+    result.Throughput = float64(len(warm)) / totalTime.Seconds()
+    computeLatencyPercentiles(result, warm)
+    result.LatencyHistogram = computeLatencyHistogram(warm, e.histogram)
+    if result.Memory != nil {
+        computeAllocStats(result.Memory, warm)
+    }
 
     // Compute per-class metrics
     result.ClassAccuracies = e.computeClassAccuracies(result.ConfusionMatrix)
     result.ClassPrecisions = e.computeClassPrecisions(result.ConfusionMatrix)
     result.ClassRecalls = e.computeClassRecalls(result.ConfusionMatrix)
     result.ClassF1Scores = e.computeClassF1Scores(result.ClassPrecisions, result.ClassRecalls)
+    computeAveragedMetrics(result)
+    computeAgreement(result)
+
+    // Compute threshold-independent metrics
+    computeROC(result)
+    computeCalibration(result)
 }
 
-// computeTop5Accuracy computes top-5 accuracy
-func (e *Evaluator) computeTop5Accuracy(predictions []PredictionDetail) float64 {
-    correct := 0
+// computeLatencyPercentiles computes the median and tail inference times of predictions
+func computeLatencyPercentiles(result *EvaluationResult, predictions []PredictionDetail) {
+    times := make([]time.Duration, len(predictions))
+    for i, pred := range predictions {
+        times[i] = pred.InferenceTime
+    }
+    slices.Sort(times)
+
+    result.P50InferenceTime = percentile(times, 50)
+    result.P90InferenceTime = percentile(times, 90)
+    result.P95InferenceTime = percentile(times, 95)
+    result.P99InferenceTime = percentile(times, 99)
+}
+
+// percentile returns the p-th percentile of sorted, interpolating linearly
+// between the two closest ranks
+func percentile(sorted []time.Duration, p float64) time.Duration {
+    if len(sorted) == 0 {
+        return 0
+    }
+    rank := p / 100 * float64(len(sorted)-1)
+    lower := int(rank)
+    if lower+1 >= len(sorted) {
+        return sorted[len(sorted)-1]
+    }
+    fraction := rank - float64(lower)
+    return sorted[lower] + time.Duration(math.Round(fraction*float64(sorted[lower+1]-sorted[lower])))
+}
+
+// computeTopKAccuracies computes the top-k accuracy for every k of ks in one pass
+func (e *Evaluator) computeTopKAccuracies(predictions []PredictionDetail, ks []int) []float64 {
+    correct := make([]int, len(ks))
     
     for _, pred := range predictions {
-        if len(pred.Probabilities) >= 5 {
-            top5 := getTop5Indices(pred.Probabilities)
-            for _, idx := range top5 {
-                if idx == pred.TrueClass {
-                    correct++
-                    break
-                }
+        rank := trueClassRank(pred.Probabilities, pred.TrueClass)
+        for i, k := range ks {
+            if rank < k {
+                correct[i]++
             }
         }
     }
     
-    return float64(correct) / float64(len(predictions))
+    accuracies := make([]float64, len(ks))
+    for i := range ks {
+        accuracies[i] = float64(correct[i]) / float64(len(predictions))
+    }
+    return accuracies
+}
+
+// trueClassRank returns the position of class in ops.ArgmaxTopK's order of probabilities
+// without ranking the others: the classes more probable than it, plus the equally
+// probable ones before it. A class without a probability is never in the top K.
+func trueClassRank(probabilities []float32, class int) int {
+    if class < 0 || class >= len(probabilities) {
+        return math.MaxInt
+    }
+    p := probabilities[class]
+    rank := 0
+    for i, q := range probabilities {
+        if q > p || (q == p && i < class) {
+            rank++
+        }
+    }
+    return rank
 }
 
 // computeClassAccuracies computes per-class accuracy
@@ -302,33 +605,3 @@ func argmaxInt(slice []int) int {
     
     return maxIdx
 }
-
-func getTop5Indices(probabilities []float32) []int {
-    type IndexValue struct {
-        Index int
-        Value float32
-    }
-    
-    // Create index-value pairs
-    pairs := make([]IndexValue, len(probabilities))
-    for i, val := range probabilities {
-        pairs[i] = IndexValue{i, val}
-    }
-    
-    // Sort by value (descending)
-    for i := 0; i < len(pairs); i++ {
-        for j := i + 1; j < len(pairs); j++ {
-            if pairs[j].Value > pairs[i].Value {
-                pairs[i], pairs[j] = pairs[j], pairs[i]
-            }
-        }
-    }
-    
-    // Return top 5 indices
-    top5 := make([]int, 5)
-    for i := 0; i < 5 && i < len(pairs); i++ {
-        top5[i] = pairs[i].Index
-    }
-    
-    return top5
-}
\ No newline at end of file
@@ -0,0 +1,842 @@
+package metrics
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/ops"
+	"duchm1606/gocnn/internal/tensor"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoPredictor predicts the class stored in an image's first value, with a
+// probability vector as long as its architecture's class count
+type echoPredictor struct {
+    numClasses int
+}
+
+func (p *echoPredictor) Predict(ctx context.Context, imageData []float32) (*model.PredictionResult, error) {
+    class := int(imageData[0])
+    probabilities := make([]float32, p.numClasses)
+    if class < p.numClasses {
+        probabilities[class] = 1
+    }
+    return &model.PredictionResult{Probabilities: probabilities, PredictedClass: class, Confidence: 1}, nil
+}
+
+func (p *echoPredictor) PredictBatch(ctx context.Context, images [][]float32) ([]*model.PredictionResult, error) {
+    results := make([]*model.PredictionResult, len(images))
+    for i, image := range images {
+        results[i], _ = p.Predict(ctx, image)
+    }
+    return results, nil
+}
+
+func (p *echoPredictor) Info() *model.ModelInfo {
+    arch := model.GetTinyCNNArchitecture()
+    arch.NumClasses = p.numClasses
+    return &model.ModelInfo{Architecture: arch}
+}
+
+// samples returns images predicted as predicted[i], labelled one-hot as labels[i] among numLabels classes
+func samples(predicted, labels []int, numLabels int) ([]*tensor.FeatureMap, [][]int) {
+    images := make([]*tensor.FeatureMap, len(predicted))
+    oneHot := make([][]int, len(labels))
+    for i := range predicted {
+        images[i] = &tensor.FeatureMap{Data: []float32{float32(predicted[i])}}
+        oneHot[i] = make([]int, numLabels)
+        oneHot[i][labels[i]] = 1
+    }
+    return images, oneHot
+}
+
+func TestEvaluatorClassCount(t *testing.T) {
+    evaluator := NewEvaluator(2)
+
+    // A 100-class model: the matrix follows the architecture
+    images, labels := samples([]int{57, 99, 3}, []int{57, 98, 3}, 100)
+    result, err := evaluator.EvaluateModel(&echoPredictor{numClasses: 100}, images, labels)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(result.ConfusionMatrix) != 100 || len(result.ClassAccuracies) != 100 {
+        t.Fatalf("Expected 100 classes, got a %d-row matrix and %d accuracies",
+            len(result.ConfusionMatrix), len(result.ClassAccuracies))
+    }
+    if result.ConfusionMatrix[57][57] != 1 || result.ConfusionMatrix[98][99] != 1 || result.CorrectPredictions != 2 {
+        t.Errorf("Samples of classes past 10 were dropped: %d correct, [57][57]=%d, [98][99]=%d",
+            result.CorrectPredictions, result.ConfusionMatrix[57][57], result.ConfusionMatrix[98][99])
+    }
+
+    // Labels and predictions outside the model's classes widen the matrix
+    images, labels = samples([]int{12, 1}, []int{14, 1}, 15)
+    result, err = evaluator.EvaluateModel(&echoPredictor{numClasses: 10}, images, labels)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(result.ConfusionMatrix) != 15 || result.ConfusionMatrix[14][12] != 1 || result.ConfusionMatrix[1][1] != 1 {
+        t.Errorf("Expected a 15-class matrix counting both samples, got %v", result.ConfusionMatrix)
+    }
+}
+
+func TestROC(t *testing.T) {
+    // 3 of the 4 positive/negative pairs are ranked correctly
+    curve := rocCurve(0, []scoredSample{{0.3, false}, {0.9, true}, {0.8, false}, {0.6, true}})
+    if math.Abs(curve.AUC-0.75) > 1e-9 {
+        t.Errorf("Expected AUC 0.75, got %v", curve.AUC)
+    }
+    first, last := curve.Points[0], curve.Points[len(curve.Points)-1]
+    if first.FPR != 0 || first.TPR != 0 || last.FPR != 1 || last.TPR != 1 || len(curve.Points) != 5 {
+        t.Errorf("Expected 5 points from (0,0) to (1,1), got %+v", curve.Points)
+    }
+
+    // Tied scores give a diagonal, as good as chance
+    curve = rocCurve(0, []scoredSample{{0.5, true}, {0.5, false}})
+    if curve.AUC != 0.5 || len(curve.Points) != 2 {
+        t.Errorf("Expected a single diagonal with AUC 0.5, got %v %+v", curve.AUC, curve.Points)
+    }
+
+    if curve = rocCurve(0, []scoredSample{{0.5, true}}); len(curve.Points) != 0 {
+        t.Errorf("A class without negatives should have no curve, got %+v", curve.Points)
+    }
+
+    points := make([]ROCPoint, 1000)
+    for i := range points {
+        points[i].FPR = float64(i)
+    }
+    thinned := thinPoints(points, maxROCPoints)
+    if len(thinned) != maxROCPoints || thinned[0].FPR != 0 || thinned[len(thinned)-1].FPR != 999 {
+        t.Errorf("Thinning should keep %d points with both ends, got %d from %v to %v",
+            maxROCPoints, len(thinned), thinned[0].FPR, thinned[len(thinned)-1].FPR)
+    }
+
+    // A perfect ranking; class 2 never occurs, so it has no curve and no say in the macro AUC
+    images, labels := samples([]int{0, 1, 1, 0}, []int{0, 1, 1, 0}, 3)
+    result, err := NewEvaluator(1).EvaluateModel(&echoPredictor{numClasses: 3}, images, labels)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if result.MacroAUC != 1 || result.MicroAUC != 1 || len(result.ROCCurves) != 3 || len(result.ROCCurves[2].Points) != 0 {
+        t.Errorf("Expected perfect macro and micro AUC without a class 2 curve, got %v, %v, %+v",
+            result.MacroAUC, result.MicroAUC, result.ROCCurves)
+    }
+}
+
+func TestCalibration(t *testing.T) {
+    result := &EvaluationResult{Predictions: []PredictionDetail{
+        {Confidence: 0.95, Correct: true},
+        {Confidence: 0.95, Correct: false, PredictedClass: 1},
+        {Confidence: 0.5, Correct: true},
+        {Confidence: 0.5, Correct: true},
+        {Confidence: 0.9, PredictedClass: -1}, // Failed, not counted
+    }}
+    computeCalibration(result)
+
+    // Bin 0.93-1: accuracy 0.5 at 0.95; bin 0.47-0.53: accuracy 1 at 0.5
+    if math.Abs(result.ExpectedCalibrationError-0.475) > 1e-6 || math.Abs(result.MaxCalibrationError-0.5) > 1e-6 {
+        t.Errorf("Expected ECE 0.475 and MCE 0.5, got %v and %v", result.ExpectedCalibrationError, result.MaxCalibrationError)
+    }
+    if len(result.Reliability) != calibrationBins {
+        t.Fatalf("Expected %d bins, got %d", calibrationBins, len(result.Reliability))
+    }
+    last := result.Reliability[calibrationBins-1]
+    if last.Count != 2 || last.Accuracy != 0.5 || math.Abs(last.Confidence-0.95) > 1e-6 || last.Upper != 1 {
+        t.Errorf("Unexpected top bin %+v", last)
+    }
+
+    // A confidence of exactly 1 falls in the last bin
+    result = &EvaluationResult{Predictions: []PredictionDetail{{Confidence: 1, Correct: true}}}
+    computeCalibration(result)
+    if result.Reliability[calibrationBins-1].Count != 1 || result.ExpectedCalibrationError != 0 {
+        t.Errorf("Expected a perfectly calibrated sample in the top bin, got %+v", result.Reliability[calibrationBins-1])
+    }
+}
+
+func TestLatencyPercentiles(t *testing.T) {
+    // 1ms to 100ms, one sample each, in shuffled order
+    result := &EvaluationResult{}
+    for i := 100; i >= 1; i-- {
+        result.Predictions = append(result.Predictions, PredictionDetail{InferenceTime: time.Duration(i) * time.Millisecond})
+    }
+    computeLatencyPercentiles(result, result.Predictions)
+
+    for _, tc := range []struct {
+        name string
+        got  time.Duration
+        want time.Duration
+    }{
+        {"p50", result.P50InferenceTime, 50500 * time.Microsecond},
+        {"p90", result.P90InferenceTime, 90100 * time.Microsecond},
+        {"p95", result.P95InferenceTime, 95050 * time.Microsecond},
+        {"p99", result.P99InferenceTime, 99010 * time.Microsecond},
+    } {
+        if tc.got != tc.want {
+            t.Errorf("%s: expected %v, got %v", tc.name, tc.want, tc.got)
+        }
+    }
+
+    if got := percentile([]time.Duration{7 * time.Millisecond}, 99); got != 7*time.Millisecond {
+        t.Errorf("A single sample is every percentile, got %v", got)
+    }
+}
+
+func TestAveragedMetrics(t *testing.T) {
+    // Class 0: 3 samples, 2 found; class 1: 1 sample, found, plus one class 0 sample
+    // predicted as 1; class 2 never occurs and is left out
+    e := NewEvaluator(1)
+    result := &EvaluationResult{ConfusionMatrix: [][]int{{2, 1, 0}, {0, 1, 0}, {0, 0, 0}}}
+    result.ClassPrecisions = e.computeClassPrecisions(result.ConfusionMatrix)
+    result.ClassRecalls = e.computeClassRecalls(result.ConfusionMatrix)
+    result.ClassF1Scores = e.computeClassF1Scores(result.ClassPrecisions, result.ClassRecalls)
+    computeAveragedMetrics(result)
+
+    for _, tc := range []struct {
+        mode Averaging
+        want AveragedMetrics
+    }{
+        {MacroAveraging, AveragedMetrics{Precision: 0.75, Recall: 5.0 / 6, F1: (0.8 + 2.0/3) / 2}},
+        {MicroAveraging, AveragedMetrics{Precision: 0.75, Recall: 0.75, F1: 0.75}},
+        {WeightedAveraging, AveragedMetrics{Precision: 0.875, Recall: 0.75, F1: (3*0.8 + 2.0/3) / 4}},
+    } {
+        got := result.Averaged(tc.mode)
+        if math.Abs(got.Precision-tc.want.Precision) > 1e-9 || math.Abs(got.Recall-tc.want.Recall) > 1e-9 ||
+            math.Abs(got.F1-tc.want.F1) > 1e-9 {
+            t.Errorf("%s: expected %+v, got %+v", tc.mode, tc.want, got)
+        }
+    }
+
+    modes, err := ParseAveraging("Weighted, all")
+    if err != nil || len(modes) != 3 || modes[0] != WeightedAveraging || modes[1] != MacroAveraging {
+        t.Errorf("Unexpected parse of \"Weighted, all\": %v, %v", modes, err)
+    }
+    if _, err := ParseAveraging("samples"); err == nil {
+        t.Error("Unknown averaging should be rejected")
+    }
+}
+
+func TestAgreement(t *testing.T) {
+    for _, tc := range []struct {
+        name   string
+        matrix [][]int
+        mcc    float64
+        kappa  float64
+    }{
+        {"perfect", [][]int{{3, 0}, {0, 2}}, 1, 1},
+        {"majority class only", [][]int{{9, 0}, {1, 0}}, 0, 0},
+        {"always wrong", [][]int{{0, 2}, {2, 0}}, -1, -1},
+        // (TP·TN - FP·FN) / sqrt((TP+FP)(TP+FN)(TN+FP)(TN+FN)) = (16 - 1) / 25
+        {"binary", [][]int{{4, 1}, {1, 4}}, 0.6, 0.6},
+    } {
+        result := &EvaluationResult{ConfusionMatrix: tc.matrix}
+        computeAgreement(result)
+        if math.Abs(result.MCC-tc.mcc) > 1e-9 || math.Abs(result.CohensKappa-tc.kappa) > 1e-9 {
+            t.Errorf("%s: expected MCC %v and kappa %v, got %v and %v", tc.name, tc.mcc, tc.kappa, result.MCC, result.CohensKappa)
+        }
+    }
+}
+
+func TestTopKAccuracy(t *testing.T) {
+    e := NewEvaluator(1)
+    e.SetTopK(3, 1, 3, 0)
+    if len(e.topK) != 2 || e.topK[0] != 1 || e.topK[1] != 3 {
+        t.Fatalf("Expected K values [1 3], got %v", e.topK)
+    }
+
+    predictions := []PredictionDetail{
+        {TrueClass: 0, Probabilities: []float32{0.7, 0.2, 0.1, 0, 0, 0}},        // Rank 0
+        {TrueClass: 2, Probabilities: []float32{0.5, 0.3, 0.2, 0, 0, 0}},        // Rank 2
+        {TrueClass: 3, Probabilities: []float32{0.25, 0.25, 0.25, 0.25, 0, 0}}, // Rank 3: ties go by index
+        {TrueClass: 1},                                                          // Failed prediction
+    }
+    accuracies := e.computeTopKAccuracies(predictions, []int{1, 3, 5})
+    for i, want := range []float64{0.25, 0.5, 0.75} {
+        if accuracies[i] != want {
+            t.Errorf("Top-%d: expected %v, got %v", []int{1, 3, 5}[i], want, accuracies[i])
+        }
+    }
+
+    // The rank agrees with the order of ops.ArgmaxTopK
+    for _, pred := range predictions[:3] {
+        top := ops.ArgmaxTopK(pred.Probabilities, len(pred.Probabilities))
+        if rank := trueClassRank(pred.Probabilities, pred.TrueClass); top[rank] != pred.TrueClass {
+            t.Errorf("Rank %d of class %d disagrees with %v", rank, pred.TrueClass, top)
+        }
+    }
+}
+
+func TestExportMisclassified(t *testing.T) {
+    dir := t.TempDir()
+    source := filepath.Join(dir, "0001.png")
+    if err := os.WriteFile(source, []byte("png"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    predictions := []PredictionDetail{
+        {SampleIndex: 0, TrueClass: 0, PredictedClass: 0, Probabilities: []float32{1, 0}, Correct: true},
+        {SampleIndex: 1, TrueClass: 1, PredictedClass: 0, Confidence: 0.75, Probabilities: []float32{0.75, 0.25}, Source: source},
+        {SampleIndex: 2, TrueClass: 0, PredictedClass: -1},
+    }
+    options := MisclassifiedOptions{
+        Path:       filepath.Join(dir, "errors.csv"),
+        ImageDir:   filepath.Join(dir, "images"),
+        ClassNames: []string{"cat", "dog"},
+    }
+
+    n, err := ExportMisclassified(predictions, options)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if n != 2 {
+        t.Errorf("Expected 2 misclassified samples, got %d", n)
+    }
+    content, err := os.ReadFile(options.Path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := "index,true_class,true_name,predicted_class,predicted_name,confidence,source,prob_0,prob_1\n" +
+        "1,1,dog,0,cat,0.75," + source + ",0.75,0.25\n" +
+        "2,0,cat,-1,,0,,,\n"
+    if string(content) != want {
+        t.Errorf("Expected CSV\n%s\ngot\n%s", want, content)
+    }
+    if _, err := os.Stat(filepath.Join(options.ImageDir, "dog", "1_as_cat_0001.png")); err != nil {
+        t.Errorf("Misclassified image was not copied: %v", err)
+    }
+
+    options.Path = filepath.Join(dir, "errors.jsonl")
+    options.ImageDir = ""
+    if _, err := ExportMisclassified(predictions, options); err != nil {
+        t.Fatal(err)
+    }
+    content, err = os.ReadFile(options.Path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+    if len(lines) != 2 || !strings.Contains(lines[0], `"true_name":"dog"`) || !strings.Contains(lines[0], `"source":`) {
+        t.Errorf("Unexpected JSON lines export:\n%s", content)
+    }
+}
+
+func TestMcNemar(t *testing.T) {
+    predictions := func(correct ...bool) []PredictionDetail {
+        details := make([]PredictionDetail, len(correct))
+        for i, c := range correct {
+            details[i] = PredictionDetail{TrueClass: i % 2, Correct: c}
+        }
+        return details
+    }
+
+    // 4 wins of the second model, 1 loss: exact two-sided binomial p = 2·6/32
+    first := predictions(false, false, false, false, true, true)
+    second := predictions(true, true, true, true, false, true)
+    test, wins, losses := computeMcNemar(first, second, 2)
+    if test.Wins != 4 || test.Losses != 1 || !test.Exact || math.Abs(test.PValue-0.375) > 1e-9 {
+        t.Errorf("Expected 4 wins, 1 loss and exact p 0.375, got %+v", test)
+    }
+    if math.Abs(test.Statistic-0.8) > 1e-9 {
+        t.Errorf("Expected chi-squared (|4-1|-1)²/5 = 0.8, got %f", test.Statistic)
+    }
+    if wins[0] != 2 || wins[1] != 2 || losses[0] != 1 || losses[1] != 0 {
+        t.Errorf("Unexpected class wins %v and losses %v", wins, losses)
+    }
+
+    // 30 wins, 10 losses: chi-squared 361/40 with p = erfc(sqrt(9.025/2))
+    first, second = nil, nil
+    for i := 0; i < 40; i++ {
+        first = append(first, PredictionDetail{Correct: i >= 30})
+        second = append(second, PredictionDetail{Correct: i < 30})
+    }
+    test, _, _ = computeMcNemar(first, second, 1)
+    if test.Exact || math.Abs(test.Statistic-9.025) > 1e-9 || math.Abs(test.PValue-math.Erfc(math.Sqrt(9.025/2))) > 1e-12 {
+        t.Errorf("Unexpected chi-squared test %+v", test)
+    }
+    if test.PValue > 0.01 {
+        t.Errorf("30 to 10 should be significant, got p = %f", test.PValue)
+    }
+
+    // Identical models are indistinguishable
+    test, _, _ = computeMcNemar(first, first, 1)
+    if test.Wins != 0 || test.Losses != 0 || test.PValue != 1 {
+        t.Errorf("Expected p = 1 without disagreements, got %+v", test)
+    }
+}
+
+// slowStartPredictor is an echoPredictor whose first calls take longer
+type slowStartPredictor struct {
+    echoPredictor
+    calls int
+}
+
+func (p *slowStartPredictor) Predict(ctx context.Context, imageData []float32) (*model.PredictionResult, error) {
+    p.calls++
+    if p.calls <= 2 {
+        time.Sleep(20 * time.Millisecond)
+    }
+    return p.echoPredictor.Predict(ctx, imageData)
+}
+
+func TestEvaluatorWarmup(t *testing.T) {
+    images, labels := samples([]int{0, 1, 1, 0, 1}, []int{0, 1, 0, 0, 1}, 2)
+    evaluator := NewEvaluator(1)
+    evaluator.SetWarmup(2)
+    result, err := evaluator.EvaluateModel(&slowStartPredictor{echoPredictor: echoPredictor{numClasses: 2}}, images, labels)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Warm-up samples still count toward accuracy
+    if result.TotalSamples != 5 || result.CorrectPredictions != 4 {
+        t.Errorf("Expected 4 of 5 correct, got %d of %d", result.CorrectPredictions, result.TotalSamples)
+    }
+    if result.Cold == nil || result.Cold.Samples != 2 || result.Cold.MinInferenceTime < 20*time.Millisecond {
+        t.Fatalf("Expected the 2 slow samples as cold timings, got %+v", result.Cold)
+    }
+    if result.MaxInferenceTime >= 20*time.Millisecond {
+        t.Errorf("Warm timings include a warm-up sample: max %v", result.MaxInferenceTime)
+    }
+    if result.TotalInferenceTime+result.Cold.TotalInferenceTime != sumInferenceTimes(result.Predictions) {
+        t.Error("Cold and warm totals don't add up to the sample times")
+    }
+
+    // At least one sample stays warm
+    evaluator.SetWarmup(10)
+    result, err = evaluator.EvaluateModel(&echoPredictor{numClasses: 2}, images, labels)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if result.Cold == nil || result.Cold.Samples != 4 {
+        t.Errorf("Expected 4 cold samples out of 5, got %+v", result.Cold)
+    }
+}
+
+func sumInferenceTimes(predictions []PredictionDetail) time.Duration {
+    var total time.Duration
+    for _, pred := range predictions {
+        total += pred.InferenceTime
+    }
+    return total
+}
+
+func TestLatencyHistogram(t *testing.T) {
+    var predictions []PredictionDetail
+    for _, ms := range []int{3, 4, 4, 7, 12, 30} {
+        predictions = append(predictions, PredictionDetail{InferenceTime: time.Duration(ms) * time.Millisecond})
+    }
+
+    // Fixed width: buckets from the smallest to the largest time
+    histogram := computeLatencyHistogram(predictions, HistogramOptions{Width: 5 * time.Millisecond})
+    if len(histogram.Buckets) != 7 || histogram.Buckets[0].Lower != 0 || histogram.Buckets[6].Upper != 35*time.Millisecond {
+        t.Fatalf("Expected 7 buckets of 5ms from 0 to 35ms, got %+v", histogram.Buckets)
+    }
+    for i, want := range []int{3, 1, 1, 0, 0, 0, 1} {
+        if histogram.Buckets[i].Count != want {
+            t.Errorf("Bucket %d: expected %d samples, got %d", i, want, histogram.Buckets[i].Count)
+        }
+    }
+    if histogram.Distribution != nil {
+        t.Error("The cumulative distribution was computed without being asked for")
+    }
+
+    // Explicit bounds with an unbounded last bucket, and the cumulative distribution
+    options, err := ParseLatencyBuckets("4ms, 10ms")
+    if err != nil {
+        t.Fatal(err)
+    }
+    options.Cumulative = true
+    histogram = computeLatencyHistogram(predictions, options)
+    if len(histogram.Buckets) != 3 || histogram.Buckets[0].Count != 1 || histogram.Buckets[1].Count != 3 ||
+        histogram.Buckets[2].Count != 2 || histogram.Buckets[2].Upper != 0 {
+        t.Errorf("Unexpected bounded buckets %+v", histogram.Buckets)
+    }
+    wantDistribution := []LatencyQuantile{
+        {0, 3 * time.Millisecond, 1},
+        {50, 4 * time.Millisecond, 3},
+        {75, 12 * time.Millisecond, 5},
+        {100, 30 * time.Millisecond, 6},
+    }
+    if len(histogram.Distribution) != len(wantDistribution) {
+        t.Fatalf("Expected %v, got %v", wantDistribution, histogram.Distribution)
+    }
+    for i, want := range wantDistribution {
+        if histogram.Distribution[i] != want {
+            t.Errorf("Row %d: expected %+v, got %+v", i, want, histogram.Distribution[i])
+        }
+    }
+
+    // The automatic width aims for about 20 buckets
+    histogram = computeLatencyHistogram(predictions, HistogramOptions{})
+    if n := len(histogram.Buckets); n < 10 || n > 30 {
+        t.Errorf("Expected about 20 automatic buckets, got %d", n)
+    }
+
+    for _, spec := range []string{"5", "-1ms", "5ms,2ms"} {
+        if _, err := ParseLatencyBuckets(spec); err == nil {
+            t.Errorf("Expected an error for %q", spec)
+        }
+    }
+}
+
+// shiftPredictor is an echoPredictor that predicts the class after the stored one
+type shiftPredictor struct {
+    echoPredictor
+}
+
+func (p *shiftPredictor) Predict(ctx context.Context, imageData []float32) (*model.PredictionResult, error) {
+    return p.echoPredictor.Predict(ctx, []float32{float32((int(imageData[0]) + 1) % p.numClasses)})
+}
+
+func TestCompareModelsDisagreement(t *testing.T) {
+    images, labels := samples([]int{0, 1, 2, 2}, []int{0, 1, 2, 0}, 3)
+    result, err := NewEvaluator(1).CompareModels(&echoPredictor{numClasses: 3},
+        &shiftPredictor{echoPredictor{numClasses: 3}}, images, labels)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Every prediction moves one class on: 0→1, 1→2 and twice 2→0
+    want := [][]int{{0, 1, 0}, {0, 0, 1}, {2, 0, 0}}
+    for i := range want {
+        for j := range want[i] {
+            if result.DisagreementMatrix[i][j] != want[i][j] {
+                t.Fatalf("Expected disagreement matrix %v, got %v", want, result.DisagreementMatrix)
+            }
+        }
+    }
+    if result.Agreement != 0 {
+        t.Errorf("Expected no agreement, got %f", result.Agreement)
+    }
+    // Sample 3 (labelled 0, predicted 2 then 0) is the second model's only win
+    if result.McNemar.Wins != 1 || result.McNemar.Losses != 3 {
+        t.Errorf("Expected 1 win and 3 losses, got %+v", result.McNemar)
+    }
+    // A gets samples 0, 1 and 2 right, B only sample 3
+    if math.Abs(result.Top1Delta+0.5) > 1e-9 {
+        t.Errorf("Expected a top-1 delta of -0.5, got %f", result.Top1Delta)
+    }
+    if result.LatencyDelta != result.B.AverageInferenceTime-result.A.AverageInferenceTime {
+        t.Errorf("Latency delta %v doesn't match the averages", result.LatencyDelta)
+    }
+}
+
+func TestSweepWorkers(t *testing.T) {
+    counts, err := ParseWorkerCounts("4, 1,2,4")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(counts) != 3 || counts[0] != 1 || counts[1] != 2 || counts[2] != 4 {
+        t.Fatalf("Expected [1 2 4], got %v", counts)
+    }
+    for _, list := range []string{"", "0", "two"} {
+        if _, err := ParseWorkerCounts(list); err == nil {
+            t.Errorf("Expected an error for %q", list)
+        }
+    }
+
+    images, labels := samples([]int{0, 1, 1, 0, 1, 0}, []int{0, 1, 0, 0, 1, 0}, 2)
+    evaluator := NewEvaluator(3)
+    points, err := evaluator.SweepWorkers(&echoPredictor{numClasses: 2}, images, labels, counts)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(points) != 3 || evaluator.numWorkers != 3 {
+        t.Fatalf("Expected 3 points and the worker count restored, got %d points and %d workers",
+            len(points), evaluator.numWorkers)
+    }
+    if points[0].Speedup != 1 || points[0].Efficiency != 1 {
+        t.Errorf("The first point is the baseline, got %+v", points[0])
+    }
+    for _, point := range points {
+        if point.Top1Accuracy != 5.0/6 || point.Throughput <= 0 {
+            t.Errorf("Unexpected point %+v", point)
+        }
+        if want := point.Throughput / points[0].Throughput / float64(point.Workers); math.Abs(point.Efficiency-want) > 1e-9 {
+            t.Errorf("%d workers: expected efficiency %f, got %f", point.Workers, want, point.Efficiency)
+        }
+    }
+}
+
+// echoSet is a LabeledSet of images predicted as predicted[i] by an echoPredictor
+type echoSet struct {
+    predicted, labels []int
+}
+
+func (s echoSet) Len() int            { return len(s.labels) }
+func (s echoSet) Label(i int) int     { return s.labels[i] }
+func (s echoSet) Source(i int) string { return "" }
+func (s echoSet) Image(i int) *tensor.FeatureMap {
+    return &tensor.FeatureMap{Data: []float32{float32(s.predicted[i])}, Height: 1, Width: 1, Channels: 1}
+}
+
+func TestKFold(t *testing.T) {
+    folds, err := KFold(11, 3, 5)
+    if err != nil {
+        t.Fatal(err)
+    }
+    seen := make([]int, 11)
+    for i, fold := range folds {
+        if fold.Index != i || len(fold.Test) != []int{4, 4, 3}[i] || len(fold.Train)+len(fold.Test) != 11 {
+            t.Errorf("Fold %d: unexpected split of %d train and %d test samples", i, len(fold.Train), len(fold.Test))
+        }
+        for _, index := range fold.Test {
+            seen[index]++
+            if slices.Contains(fold.Train, index) {
+                t.Errorf("Fold %d trains on its test sample %d", i, index)
+            }
+        }
+    }
+    for index, count := range seen {
+        if count != 1 {
+            t.Errorf("Sample %d is tested %d times", index, count)
+        }
+    }
+
+    again, _ := KFold(11, 3, 5)
+    if !slices.Equal(again[1].Test, folds[1].Test) {
+        t.Error("Expected the same folds for the same seed")
+    }
+    for _, k := range []int{1, 12} {
+        if _, err := KFold(11, k, 5); err == nil {
+            t.Errorf("Expected an error for %d folds of 11 samples", k)
+        }
+    }
+}
+
+func TestCrossValidate(t *testing.T) {
+    set := echoSet{
+        predicted: []int{0, 1, 1, 0, 1, 0, 2, 2},
+        labels:    []int{0, 1, 0, 0, 1, 1, 2, 2},
+    }
+    folds, err := KFold(set.Len(), 2, 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Every fold's accuracy is that of the predictions on its test samples
+    evaluator := NewEvaluator(2)
+    model := &echoPredictor{numClasses: 3}
+    report, err := evaluator.CrossValidate(set, 3, nil, folds, ProvidedModels(model, model))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var accuracies []float64
+    for i, fold := range folds {
+        correct := 0
+        for _, index := range fold.Test {
+            if set.predicted[index] == set.labels[index] {
+                correct++
+            }
+        }
+        accuracies = append(accuracies, float64(correct)/float64(len(fold.Test)))
+        if got := report.Folds[i]; got.Fold != i+1 || got.TestSamples != 4 || got.TrainSamples != 4 ||
+            got.Top1Accuracy != accuracies[i] {
+            t.Errorf("Fold %d: expected accuracy %f on 4 samples, got %+v", i+1, accuracies[i], got)
+        }
+    }
+    mean := (accuracies[0] + accuracies[1]) / 2
+    std := math.Abs(accuracies[0]-accuracies[1]) / math.Sqrt2
+    if math.Abs(report.Top1Accuracy.Mean-mean) > 1e-12 || math.Abs(report.Top1Accuracy.Std-std) > 1e-12 {
+        t.Errorf("Expected %f ± %f, got %+v", mean, std, report.Top1Accuracy)
+    }
+
+    // A fold without a model fails
+    if _, err := evaluator.CrossValidate(set, 3, nil, folds, ProvidedModels(model)); err == nil ||
+        !strings.Contains(err.Error(), "fold 2") {
+        t.Errorf("Expected an error for fold 2 without a model, got %v", err)
+    }
+}
+
+// allocatingPredictor allocates a 1 MiB buffer per prediction and reports it as its only layer
+type allocatingPredictor struct {
+    echoPredictor
+    tracking bool
+    sink     []byte
+}
+
+func (p *allocatingPredictor) SetMemoryTracking(on bool) {
+    p.tracking = on
+}
+
+func (p *allocatingPredictor) Predict(ctx context.Context, imageData []float32) (*model.PredictionResult, error) {
+    before := model.ReadAllocCounters()
+    p.sink = make([]byte, 1<<20)
+    allocs := model.ReadAllocCounters().Sub(before)
+    result, err := p.echoPredictor.Predict(ctx, imageData)
+    if p.tracking {
+        result.LayerAllocs = map[string]model.AllocCounters{"buffer": allocs}
+    }
+    return result, err
+}
+
+func TestEvaluatorMemoryTracking(t *testing.T) {
+    images, labels := samples([]int{0, 1, 1, 0}, []int{0, 1, 1, 0}, 2)
+    predictor := &allocatingPredictor{echoPredictor: echoPredictor{numClasses: 2}}
+
+    // Without tracking there are no memory statistics
+    evaluator := NewEvaluator(1)
+    result, err := evaluator.EvaluateModel(predictor, images, labels)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if result.Memory != nil || result.Predictions[0].AllocBytes != 0 {
+        t.Fatalf("Expected no memory statistics, got %+v", result.Memory)
+    }
+
+    evaluator.SetMemoryTracking(MemoryOptions{SampleInterval: time.Millisecond})
+    result, err = evaluator.EvaluateModel(predictor, images, labels)
+    if err != nil {
+        t.Fatal(err)
+    }
+    mem := result.Memory
+    if mem == nil {
+        t.Fatal("Expected memory statistics")
+    }
+    if mem.AverageAllocBytes < 1<<20 || mem.MaxAllocBytes < mem.AverageAllocBytes || mem.AverageAllocs < 1 {
+        t.Errorf("Expected at least 1 MiB in 1 object per inference, got %+v", mem)
+    }
+    if mem.TotalAllocBytes < 4<<20 || mem.PeakHeap < mem.HeapStart {
+        t.Errorf("Implausible whole-run statistics: %+v", mem)
+    }
+    if layer := mem.LayerAllocs["buffer"]; layer.Bytes < 1<<20 {
+        t.Errorf("Expected the layer's 1 MiB per inference, got %+v", mem.LayerAllocs)
+    }
+    if predictor.tracking {
+        t.Error("The model's memory tracking was left on")
+    }
+}
+
+func TestCheckRegression(t *testing.T) {
+    for _, tc := range []struct {
+        spec  string
+        limit float64
+    }{{"1%", 0.01}, {" 2.5 % ", 0.025}, {"0.02", 0.02}, {"0", 0}} {
+        if limit, err := ParseRegression(tc.spec); err != nil || math.Abs(limit-tc.limit) > 1e-12 {
+            t.Errorf("ParseRegression(%q) = %v, %v; expected %v", tc.spec, limit, err, tc.limit)
+        }
+    }
+    for _, spec := range []string{"", "%", "-1%", "fast"} {
+        if _, err := ParseRegression(spec); err == nil {
+            t.Errorf("ParseRegression(%q) should fail", spec)
+        }
+    }
+
+    // The baseline is read back from a JSON report
+    path := filepath.Join(t.TempDir(), "baseline.json")
+    content, err := json.Marshal(&EvaluationResult{TotalSamples: 100, Top1Accuracy: 0.9, P95InferenceTime: 10 * time.Millisecond})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(path, content, 0o644); err != nil {
+        t.Fatal(err)
+    }
+    baseline, err := LoadBaseline(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // A regression of exactly the limit passes; latency is compared relatively
+    current := &EvaluationResult{TotalSamples: 100, Top1Accuracy: 0.89, P95InferenceTime: 10100 * time.Microsecond}
+    report := CheckRegression(baseline, current, 0.01)
+    if report.Regressed() {
+        t.Errorf("Expected no regression within 1%%, got %+v", report.Checks)
+    }
+    report = CheckRegression(baseline, current, 0.005)
+    if !report.Checks[0].Regressed || !report.Checks[1].Regressed || math.Abs(report.Checks[1].Regression-0.01) > 1e-9 {
+        t.Errorf("Expected both metrics to regress beyond 0.5%%, got %+v", report.Checks)
+    }
+
+    // Improvements never fail
+    current = &EvaluationResult{TotalSamples: 100, Top1Accuracy: 0.95, P95InferenceTime: 5 * time.Millisecond}
+    if report := CheckRegression(baseline, current, 0); report.Regressed() {
+        t.Errorf("An improvement was flagged: %+v", report.Checks)
+    }
+
+    if err := os.WriteFile(path, []byte(`{"metadata": {}}`), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := LoadBaseline(path); err == nil {
+        t.Error("A report without samples should not load as a baseline")
+    }
+}
+
+func TestComputeRoofline(t *testing.T) {
+    info := &model.ModelInfo{
+        Layers: []model.LayerCost{
+            {Name: "conv", FLOPs: 2e9, ParamBytes: 1000, ArithmeticIntensity: 100},
+            {Name: "pool", FLOPs: 1e6, ArithmeticIntensity: 0.25},
+            {Name: "untimed", FLOPs: 1e6, ArithmeticIntensity: 1},
+            {Name: "custom", ArithmeticIntensity: 0},
+        },
+        AverageLayerTimes: map[string]time.Duration{"conv": time.Second, "pool": time.Millisecond, "custom": time.Millisecond},
+    }
+    peak := ops.MachinePeak{GFLOPS: 10, BandwidthGBs: 4}
+
+    roofline := ComputeRoofline(info, peak)
+    if roofline.RidgePoint != 2.5 {
+        t.Errorf("Expected a ridge point of 2.5 FLOP/byte, got %v", roofline.RidgePoint)
+    }
+    if len(roofline.Layers) != 2 {
+        t.Fatalf("Expected the two timed layers doing arithmetic, got %+v", roofline.Layers)
+    }
+
+    conv, pool := roofline.Layers[0], roofline.Layers[1]
+    if !conv.ComputeBound || conv.GFLOPS != 2 || conv.AttainableGFLOPS != 10 || math.Abs(conv.Efficiency-0.2) > 1e-12 {
+        t.Errorf("Unexpected conv roofline: %+v", conv)
+    }
+    if pool.ComputeBound || pool.AttainableGFLOPS != 1 || math.Abs(pool.GFLOPS-1) > 1e-12 {
+        t.Errorf("Unexpected pool roofline: %+v", pool)
+    }
+}
+
+// recordingProgress keeps every progress update
+type recordingProgress struct {
+    updates []Progress
+    final   []Progress
+}
+
+func (r *recordingProgress) Update(p Progress) { r.updates = append(r.updates, p) }
+func (r *recordingProgress) Finish(p Progress) { r.final = append(r.final, p) }
+
+func TestEvaluatorProgress(t *testing.T) {
+    images, labels := samples([]int{0, 1, 1, 0, 1}, []int{0, 1, 0, 0, 1}, 2)
+    recorder := &recordingProgress{}
+    evaluator := NewEvaluator(2)
+    evaluator.SetProgress(recorder, 10)
+    if _, err := evaluator.EvaluateModel(&echoPredictor{numClasses: 2}, images, labels); err != nil {
+        t.Fatal(err)
+    }
+
+    if len(recorder.updates) != 5 || len(recorder.final) != 1 {
+        t.Fatalf("Expected 5 updates and 1 finish, got %d and %d", len(recorder.updates), len(recorder.final))
+    }
+    if last := recorder.updates[4]; last.Done != 5 || last.Total != 10 || last.Correct != 4 {
+        t.Errorf("Unexpected last update: %+v", last)
+    }
+    // The dataset held fewer samples than expected
+    if final := recorder.final[0]; final.Done != 5 || final.Total != 5 || final.Accuracy() != 0.8 {
+        t.Errorf("Unexpected final progress: %+v", final)
+    }
+
+    p := Progress{Done: 25, Total: 100, Elapsed: 5 * time.Second}
+    if eta, ok := p.ETA(); !ok || p.Rate() != 5 || eta != 15*time.Second {
+        t.Errorf("Expected 5/s and an ETA of 15s, got %v/s and %v", p.Rate(), eta)
+    }
+    if _, ok := (Progress{Done: 3, Elapsed: time.Second}).ETA(); ok {
+        t.Error("An ETA without a total")
+    }
+
+    // Redirected output gets plain lines, without carriage returns
+    var out strings.Builder
+    bar := NewProgressBar(&out, "Evaluating")
+    bar.Update(Progress{Done: 1, Total: 4, Correct: 1, Elapsed: time.Second})
+    bar.Finish(Progress{Done: 4, Total: 4, Correct: 3, Elapsed: 2 * time.Second})
+    expected := "Evaluating [####################] 100%  4/4  2.0/s  accuracy 75.00%\n"
+    if out.String() != expected {
+        t.Errorf("Expected %q, got %q", expected, out.String())
+    }
+}
@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+/**
+* Latency histogram and cumulative distribution
+
+Min, average, max and a few percentiles hide the shape of the latency
+distribution: a bimodal model (most samples fast, some hitting a slow
+path) has a fine average and a bad p99, and only the histogram shows the
+second hump. The warm inference times are counted into buckets, either of
+one fixed width or between explicit bounds:
+```
+-latency-buckets 2ms          [4ms,6ms) [6ms,8ms) [8ms,10ms) ...    (fixed width)
+-latency-buckets 1ms,5ms,20ms [0,1ms) [1ms,5ms) [5ms,20ms) [20ms,∞)
+(none)                        a 1/2/5 width giving about 20 buckets
+```
+The HDR-style cumulative distribution (as printed by HdrHistogram) lists
+the latency at percentiles that halve the remaining tail each step, so the
+tail gets as many rows as the body:
+```
+percentile   0%   50%   75%   87.5%   93.75%   96.875%   ...   100%
+```
+It stops once a step would cover less than one sample, then adds 100%.
+*/
+
+// Histogram limits
+const (
+    autoLatencyBuckets   = 20   // Buckets the automatic width aims for
+    maxLatencyBuckets    = 1000 // A fixed width is widened to stay below this
+    maxDistributionTicks = 30   // Halvings of the tail in the cumulative distribution
+)
+
+// HistogramOptions configures the latency histogram
+type HistogramOptions struct {
+    Width      time.Duration   // Fixed bucket width; 0 picks one giving about 20 buckets
+    Bounds     []time.Duration // Increasing bucket upper bounds, used instead of Width if set
+    Cumulative bool            // Also compute the HDR-style cumulative distribution
+}
+
+// LatencyBucket counts the inference times in [Lower, Upper)
+type LatencyBucket struct {
+    Lower time.Duration `json:"lower"`
+    Upper time.Duration `json:"upper,omitempty"` // 0 for the unbounded last bucket of explicit bounds
+    Count int           `json:"count"`
+}
+
+// LatencyQuantile is one row of the cumulative distribution
+type LatencyQuantile struct {
+    Percentile float64       `json:"percentile"`
+    Latency    time.Duration `json:"latency"`
+    Count      int           `json:"count"` // Samples at or below Latency
+}
+
+// LatencyHistogram is the distribution of the warm inference times
+type LatencyHistogram struct {
+    Buckets      []LatencyBucket   `json:"buckets"`
+    Distribution []LatencyQuantile `json:"distribution,omitempty"` // With HistogramOptions.Cumulative
+}
+
+// ParseLatencyBuckets converts a bucket spec to HistogramOptions: "" for the automatic
+// width, a single duration such as "2ms" for a fixed width, or a comma-separated list
+// of increasing upper bounds such as "1ms,5ms,20ms"
+func ParseLatencyBuckets(spec string) (HistogramOptions, error) {
+    var durations []time.Duration
+    for _, field := range strings.Split(spec, ",") {
+        field = strings.TrimSpace(field)
+        if field == "" {
+            continue
+        }
+        d, err := time.ParseDuration(field)
+        if err != nil || d <= 0 {
+            return HistogramOptions{}, fmt.Errorf("invalid bucket %q: must be a positive duration such as 5ms", field)
+        }
+        if len(durations) > 0 && d <= durations[len(durations)-1] {
+            return HistogramOptions{}, fmt.Errorf("bucket bounds must increase, got %s after %s", d, durations[len(durations)-1])
+        }
+        durations = append(durations, d)
+    }
+    switch len(durations) {
+    case 0:
+        return HistogramOptions{}, nil
+    case 1:
+        return HistogramOptions{Width: durations[0]}, nil
+    default:
+        return HistogramOptions{Bounds: durations}, nil
+    }
+}
+
+// SetLatencyHistogram selects the buckets of the latency histogram and whether the
+// cumulative distribution is computed
+func (e *Evaluator) SetLatencyHistogram(options HistogramOptions) {
+    e.histogram = options
+}
+
+// computeLatencyHistogram counts the inference times of predictions into the buckets
+// options select
+func computeLatencyHistogram(predictions []PredictionDetail, options HistogramOptions) LatencyHistogram {
+    times := make([]time.Duration, len(predictions))
+    for i, pred := range predictions {
+        times[i] = pred.InferenceTime
+    }
+    slices.Sort(times)
+
+    histogram := LatencyHistogram{}
+    if len(times) == 0 {
+        return histogram
+    }
+    if len(options.Bounds) > 0 {
+        histogram.Buckets = boundedBuckets(times, options.Bounds)
+    } else {
+        histogram.Buckets = fixedWidthBuckets(times, options.Width)
+    }
+    if options.Cumulative {
+        histogram.Distribution = cumulativeDistribution(times)
+    }
+    return histogram
+}
+
+// boundedBuckets counts sorted times between 0, bounds and an unbounded last bucket
+func boundedBuckets(sorted []time.Duration, bounds []time.Duration) []LatencyBucket {
+    buckets := make([]LatencyBucket, len(bounds)+1)
+    lower := time.Duration(0)
+    for i, upper := range bounds {
+        buckets[i] = LatencyBucket{Lower: lower, Upper: upper}
+        lower = upper
+    }
+    buckets[len(bounds)] = LatencyBucket{Lower: lower}
+
+    i := 0
+    for _, t := range sorted {
+        for i < len(bounds) && t >= bounds[i] {
+            i++
+        }
+        buckets[i].Count++
+    }
+    return buckets
+}
+
+// fixedWidthBuckets counts sorted times into buckets of width covering the smallest
+// to the largest, picking the width when it is 0 and widening it past maxLatencyBuckets
+func fixedWidthBuckets(sorted []time.Duration, width time.Duration) []LatencyBucket {
+    lowest, highest := sorted[0], sorted[len(sorted)-1]
+    if width <= 0 {
+        width = niceWidth((highest - lowest) / autoLatencyBuckets)
+    }
+    start := lowest / width * width
+    if n := (highest-start)/width + 1; n > maxLatencyBuckets {
+        width = (highest - start + maxLatencyBuckets - 1) / maxLatencyBuckets
+        width = max(width, 1)
+        start = lowest / width * width
+    }
+
+    buckets := make([]LatencyBucket, (highest-start)/width+1)
+    for i := range buckets {
+        buckets[i].Lower = start + time.Duration(i)*width
+        buckets[i].Upper = buckets[i].Lower + width
+    }
+    for _, t := range sorted {
+        buckets[(t-start)/width].Count++
+    }
+    return buckets
+}
+
+// niceWidth rounds d up to 1, 2 or 5 times a power of ten, at least 1µs
+func niceWidth(d time.Duration) time.Duration {
+    width := time.Microsecond
+    for {
+        for _, step := range []time.Duration{1, 2, 5} {
+            if step*width >= d {
+                return step * width
+            }
+        }
+        width *= 10
+    }
+}
+
+// cumulativeDistribution returns the latency of sorted at percentiles halving the
+// remaining tail each step, then at 100%
+func cumulativeDistribution(sorted []time.Duration) []LatencyQuantile {
+    n := len(sorted)
+    var distribution []LatencyQuantile
+    for k := 0; k < maxDistributionTicks; k++ {
+        tail := math.Pow(2, -float64(k)) // Fraction of samples above the percentile
+        if k > 0 && tail*float64(n) < 1 {
+            break
+        }
+        distribution = append(distribution, quantileAt(sorted, 100*(1-tail)))
+    }
+    return append(distribution, quantileAt(sorted, 100))
+}
+
+// quantileAt returns the nearest-rank latency of sorted at percentile p
+func quantileAt(sorted []time.Duration, p float64) LatencyQuantile {
+    rank := max(int(math.Ceil(p/100*float64(len(sorted)))), 1)
+    latency := sorted[rank-1]
+    count, _ := slices.BinarySearch(sorted, latency+1)
+    return LatencyQuantile{Percentile: p, Latency: latency, Count: count}
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"math"
+)
+
+/**
+* McNemar's test
+
+Two models evaluated on the same samples are paired: what matters is not
+their accuracies but the samples on which they disagree about correctness.
+With the first model A (say the current weights) and the second B (the
+update), every sample falls into one cell:
+```
+                 B correct   B wrong
+   A correct        n11        n10 = losses
+   A wrong     n01 = wins      n00
+```
+If the models were equally good, wins and losses would be equally likely,
+so McNemar's test asks how surprising their split is:
+```
+χ² = (|wins - losses| - 1)² / (wins + losses)     (1 degree of freedom,
+p  = P(χ²₁ ≥ χ²) = erfc(sqrt(χ²/2))                with continuity correction)
+```
+Below 25 disagreements the χ² approximation is poor, and p comes from the
+exact two-sided binomial test instead: P(min(wins, losses) or fewer of n
+fair coin flips land one way), doubled. A small p (conventionally < 0.05)
+means the accuracy difference is unlikely to be noise; a large p means the
+test set can't tell the models apart, however the accuracies compare.
+*/
+
+// mcnemarExactBelow is the number of disagreements below which the exact binomial test is used
+const mcnemarExactBelow = 25
+
+// McNemarTest is McNemar's test of two models on the same samples
+type McNemarTest struct {
+    Wins      int     `json:"wins"`      // Samples only the second model predicts correctly
+    Losses    int     `json:"losses"`    // Samples only the first model predicts correctly
+    Statistic float64 `json:"statistic"` // Chi-squared with continuity correction; 0 without disagreements
+    PValue    float64 `json:"p_value"`
+    Exact     bool    `json:"exact"`     // PValue is from the exact binomial test
+}
+
+// computeMcNemar tests the predictions of a second model against a first one on the
+// same samples and counts the wins and losses of the second per true class
+func computeMcNemar(first, second []PredictionDetail, numClasses int) (McNemarTest, []int, []int) {
+    test := McNemarTest{}
+    classWins, classLosses := make([]int, numClasses), make([]int, numClasses)
+    for i := range min(len(first), len(second)) {
+        a, b := first[i], second[i]
+        if a.Correct == b.Correct {
+            continue
+        }
+        class := a.TrueClass
+        inRange := class >= 0 && class < numClasses
+        if b.Correct {
+            test.Wins++
+            if inRange {
+                classWins[class]++
+            }
+        } else {
+            test.Losses++
+            if inRange {
+                classLosses[class]++
+            }
+        }
+    }
+
+    n := test.Wins + test.Losses
+    test.PValue = 1
+    if n == 0 {
+        return test, classWins, classLosses
+    }
+    diff := math.Max(math.Abs(float64(test.Wins-test.Losses))-1, 0)
+    test.Statistic = diff * diff / float64(n)
+    if n < mcnemarExactBelow {
+        test.Exact = true
+        test.PValue = math.Min(1, 2*binomialCDF(min(test.Wins, test.Losses), n))
+    } else {
+        test.PValue = math.Erfc(math.Sqrt(test.Statistic / 2))
+    }
+    return test, classWins, classLosses
+}
+
+// binomialCDF returns P(X ≤ k) for X the number of heads in n fair coin flips
+func binomialCDF(k, n int) float64 {
+    lgammaN, _ := math.Lgamma(float64(n + 1))
+    sum := 0.0
+    for i := 0; i <= k; i++ {
+        lgammaI, _ := math.Lgamma(float64(i + 1))
+        lgammaRest, _ := math.Lgamma(float64(n - i + 1))
+        sum += math.Exp(lgammaN - lgammaI - lgammaRest - float64(n)*math.Ln2)
+    }
+    return sum
+}
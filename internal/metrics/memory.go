@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"cmp"
+	"duchm1606/gocnn/internal/model"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+)
+
+/**
+* Memory tracking
+
+Timing alone misses memory regressions: an extra buffer per inference costs
+little latency on an idle benchmark machine, but in production it becomes
+GC cycles and a larger heap. With memory tracking on, the evaluation records
+three things:
+```
+per inference   allocation counters read around Predict    → AverageAllocBytes, MaxAllocBytes
+per layer       the model's own counters around each layer  → LayerAllocs
+whole run       runtime.MemStats before and after           → TotalAllocBytes, GCCycles, GCPause
+                ReadMemStats every SampleInterval           → PeakHeap
+```
+The allocation counters are process-wide, so with several workers an
+inference's numbers include its neighbours' allocations: use -workers 1 when
+the per-inference and per-layer figures must be exact. The averages cover
+the warm samples only, like the timings. ReadMemStats briefly stops the
+world, so sampling the heap too often slows the run it measures; without
+sampling, PeakHeap is only the larger of the heap at the start and the end.
+*/
+
+// MemoryOptions configures memory tracking
+type MemoryOptions struct {
+    SampleInterval time.Duration // How often the heap is sampled for PeakHeap; 0 disables sampling
+}
+
+// MemoryStats are the allocations and heap usage of an evaluation
+type MemoryStats struct {
+    AverageAllocBytes uint64        `json:"average_alloc_bytes"` // Heap bytes allocated per warm inference
+    MaxAllocBytes     uint64        `json:"max_alloc_bytes"`
+    AverageAllocs     uint64        `json:"average_allocs"` // Heap objects allocated per warm inference
+
+    // Average allocations of each layer per warm inference; only for models with SetMemoryTracking
+    LayerAllocs       map[string]model.AllocCounters `json:"layer_allocs,omitempty"`
+
+    // Whole run, reading and evaluation included
+    TotalAllocBytes   uint64        `json:"total_alloc_bytes"`
+    Mallocs           uint64        `json:"mallocs"`
+    GCCycles          uint32        `json:"gc_cycles"`
+    GCPause           time.Duration `json:"gc_pause"`
+    HeapStart         uint64        `json:"heap_start"`   // Live heap when the evaluation started
+    PeakHeap          uint64        `json:"peak_heap"`    // Largest live heap seen
+    HeapSamples       int           `json:"heap_samples"` // Heap samples PeakHeap was taken from
+}
+
+// memoryTracker is implemented by models that can measure their layers' allocations
+type memoryTracker interface {
+    SetMemoryTracking(on bool)
+}
+
+// SetMemoryTracking makes every evaluation record its allocations and heap usage in
+// EvaluationResult.Memory
+func (e *Evaluator) SetMemoryTracking(options MemoryOptions) {
+    e.memory = &options
+}
+
+// memoryRun tracks the heap over one evaluation
+type memoryRun struct {
+    tracker  memoryTracker // The model, if it measures its layers
+    start    runtime.MemStats
+    done     chan struct{}
+    wg       sync.WaitGroup
+    peak     uint64
+    samples  int
+}
+
+// startMemoryRun starts tracking memory for an evaluation of cnn
+func startMemoryRun(cnn model.Predictor, options MemoryOptions) *memoryRun {
+    run := &memoryRun{done: make(chan struct{})}
+    if tracker, ok := cnn.(memoryTracker); ok {
+        run.tracker = tracker
+        tracker.SetMemoryTracking(true)
+    }
+
+    runtime.ReadMemStats(&run.start)
+    run.peak = run.start.HeapAlloc
+    if options.SampleInterval > 0 {
+        run.wg.Add(1)
+        go run.sample(options.SampleInterval)
+    }
+    return run
+}
+
+// sample records the live heap every interval until the run stops
+func (run *memoryRun) sample(interval time.Duration) {
+    defer run.wg.Done()
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    var stats runtime.MemStats
+    for {
+        select {
+        case <-run.done:
+            return
+        case <-ticker.C:
+            runtime.ReadMemStats(&stats)
+            run.peak = max(run.peak, stats.HeapAlloc)
+            run.samples++
+        }
+    }
+}
+
+// stop ends tracking and returns the whole-run statistics
+func (run *memoryRun) stop() *MemoryStats {
+    close(run.done)
+    run.wg.Wait()
+    if run.tracker != nil {
+        run.tracker.SetMemoryTracking(false)
+    }
+
+    var end runtime.MemStats
+    runtime.ReadMemStats(&end)
+    return &MemoryStats{
+        TotalAllocBytes: end.TotalAlloc - run.start.TotalAlloc,
+        Mallocs:         end.Mallocs - run.start.Mallocs,
+        GCCycles:        end.NumGC - run.start.NumGC,
+        GCPause:         time.Duration(end.PauseTotalNs - run.start.PauseTotalNs),
+        HeapStart:       run.start.HeapAlloc,
+        PeakHeap:        max(run.peak, end.HeapAlloc),
+        HeapSamples:     run.samples,
+    }
+}
+
+// computeAllocStats fills the per-inference allocations of stats from the warm predictions
+func computeAllocStats(stats *MemoryStats, warm []PredictionDetail) {
+    var totalBytes, totalObjects uint64
+    layers := make(map[string]model.AllocCounters)
+    for _, pred := range warm {
+        totalBytes += pred.AllocBytes
+        totalObjects += pred.Allocs
+        stats.MaxAllocBytes = max(stats.MaxAllocBytes, pred.AllocBytes)
+        for layer, counters := range pred.layerAllocs {
+            layers[layer] = layers[layer].Add(counters)
+        }
+    }
+
+    n := uint64(len(warm))
+    stats.AverageAllocBytes = totalBytes / n
+    stats.AverageAllocs = totalObjects / n
+    if len(layers) > 0 {
+        stats.LayerAllocs = make(map[string]model.AllocCounters, len(layers))
+        for layer, counters := range layers {
+            stats.LayerAllocs[layer] = model.AllocCounters{Bytes: counters.Bytes / n, Objects: counters.Objects / n}
+        }
+    }
+}
+
+// SortedLayerAllocs returns the layers of stats from the most to the least bytes allocated
+func (stats *MemoryStats) SortedLayerAllocs() []string {
+    layers := make([]string, 0, len(stats.LayerAllocs))
+    for layer := range stats.LayerAllocs {
+        layers = append(layers, layer)
+    }
+    slices.SortFunc(layers, func(a, b string) int {
+        if c := cmp.Compare(stats.LayerAllocs[b].Bytes, stats.LayerAllocs[a].Bytes); c != 0 {
+            return c
+        }
+        return cmp.Compare(a, b)
+    })
+    return layers
+}
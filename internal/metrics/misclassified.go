@@ -0,0 +1,211 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/**
+* Misclassification export
+
+Aggregate metrics say how often the model is wrong, not on what. For error
+analysis every misclassified sample is written out, one row each, with the
+file it came from so the image can be looked at:
+```
+index,true_class,true_name,predicted_class,predicted_name,confidence,source,prob_0,...,prob_9
+17,3,cat,5,dog,0.8123,test/cat/0017.png,0.0012,...
+```
+A .jsonl path writes the same fields as one JSON object per line instead.
+With ImageDir set, the source images are also copied into one folder per
+true class, named after the index and the predicted class, so the confusions
+of a class can be browsed together:
+```
+ImageDir/cat/17_as_dog_0017.png
+```
+Sources are known for the numbered files, CSV manifests and image folders;
+TFRecord samples have none and are only listed.
+*/
+
+// MisclassifiedOptions configures the export of misclassified samples
+type MisclassifiedOptions struct {
+    Path       string   // CSV file, or JSON lines when it ends in .jsonl
+    ImageDir   string   // If set, source images are copied into ImageDir/<true class>/
+    ClassNames []string // Names classes in the file and folders; class numbers otherwise
+}
+
+// misclassifiedRecord is one line of a JSON lines export
+type misclassifiedRecord struct {
+    Index          int       `json:"index"`
+    TrueClass      int       `json:"true_class"`
+    TrueName       string    `json:"true_name,omitempty"`
+    PredictedClass int       `json:"predicted_class"`
+    PredictedName  string    `json:"predicted_name,omitempty"`
+    Confidence     float32   `json:"confidence"`
+    Source         string    `json:"source,omitempty"`
+    Probabilities  []float32 `json:"probabilities"`
+}
+
+// SetMisclassifiedExport makes every evaluation write its misclassified samples as options say
+func (e *Evaluator) SetMisclassifiedExport(options MisclassifiedOptions) {
+    e.misclassified = &options
+}
+
+// ExportMisclassified writes the wrong predictions among predictions as options say
+// and returns how many there were
+func ExportMisclassified(predictions []PredictionDetail, options MisclassifiedOptions) (int, error) {
+    var wrong []PredictionDetail
+    for _, pred := range predictions {
+        if !pred.Correct {
+            wrong = append(wrong, pred)
+        }
+    }
+
+    file, err := os.Create(options.Path)
+    if err != nil {
+        return 0, fmt.Errorf("failed to create misclassification file: %w", err)
+    }
+    if strings.EqualFold(filepath.Ext(options.Path), ".jsonl") {
+        err = writeMisclassifiedJSONL(file, wrong, options.ClassNames)
+    } else {
+        err = writeMisclassifiedCSV(file, wrong, options.ClassNames)
+    }
+    if closeErr := file.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return 0, fmt.Errorf("failed to write %s: %w", options.Path, err)
+    }
+
+    if options.ImageDir != "" {
+        if err := copyMisclassified(wrong, options); err != nil {
+            return 0, err
+        }
+    }
+    return len(wrong), nil
+}
+
+// writeMisclassifiedCSV writes one row per prediction with a column per class probability
+func writeMisclassifiedCSV(w io.Writer, wrong []PredictionDetail, classNames []string) error {
+    numClasses := 0
+    for _, pred := range wrong {
+        numClasses = max(numClasses, len(pred.Probabilities))
+    }
+
+    writer := csv.NewWriter(w)
+    header := []string{"index", "true_class", "true_name", "predicted_class", "predicted_name", "confidence", "source"}
+    for c := 0; c < numClasses; c++ {
+        header = append(header, fmt.Sprintf("prob_%d", c))
+    }
+    writer.Write(header)
+
+    for _, pred := range wrong {
+        row := []string{
+            strconv.Itoa(pred.SampleIndex),
+            strconv.Itoa(pred.TrueClass),
+            classLabel(classNames, pred.TrueClass),
+            strconv.Itoa(pred.PredictedClass),
+            classLabel(classNames, pred.PredictedClass),
+            strconv.FormatFloat(float64(pred.Confidence), 'g', -1, 32),
+            pred.Source,
+        }
+        for c := 0; c < numClasses; c++ {
+            value := ""
+            if c < len(pred.Probabilities) {
+                value = strconv.FormatFloat(float64(pred.Probabilities[c]), 'g', -1, 32)
+            }
+            row = append(row, value)
+        }
+        writer.Write(row)
+    }
+    writer.Flush()
+    return writer.Error()
+}
+
+// writeMisclassifiedJSONL writes one JSON object per prediction
+func writeMisclassifiedJSONL(w io.Writer, wrong []PredictionDetail, classNames []string) error {
+    encoder := json.NewEncoder(w)
+    for _, pred := range wrong {
+        err := encoder.Encode(misclassifiedRecord{
+            Index:          pred.SampleIndex,
+            TrueClass:      pred.TrueClass,
+            TrueName:       classLabel(classNames, pred.TrueClass),
+            PredictedClass: pred.PredictedClass,
+            PredictedName:  classLabel(classNames, pred.PredictedClass),
+            Confidence:     pred.Confidence,
+            Source:         pred.Source,
+            Probabilities:  pred.Probabilities,
+        })
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// copyMisclassified copies the source image of every prediction into a folder of its true class
+func copyMisclassified(wrong []PredictionDetail, options MisclassifiedOptions) error {
+    for _, pred := range wrong {
+        if pred.Source == "" {
+            continue
+        }
+        dir := filepath.Join(options.ImageDir, folderName(options.ClassNames, pred.TrueClass))
+        if err := os.MkdirAll(dir, 0755); err != nil {
+            return fmt.Errorf("failed to create %s: %w", dir, err)
+        }
+        name := fmt.Sprintf("%d_as_%s_%s", pred.SampleIndex, folderName(options.ClassNames, pred.PredictedClass),
+            filepath.Base(pred.Source))
+        if err := copyFile(pred.Source, filepath.Join(dir, name)); err != nil {
+            return fmt.Errorf("failed to copy misclassified sample %d: %w", pred.SampleIndex, err)
+        }
+    }
+    return nil
+}
+
+// copyFile copies the file at src to dst
+func copyFile(src, dst string) error {
+    in, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+
+    out, err := os.Create(dst)
+    if err != nil {
+        return err
+    }
+    if _, err := io.Copy(out, in); err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
+
+// classLabel returns the name of class, or "" without one
+func classLabel(classNames []string, class int) string {
+    if class >= 0 && class < len(classNames) {
+        return classNames[class]
+    }
+    return ""
+}
+
+// folderName returns a file name part for class: its name, or its number
+func folderName(classNames []string, class int) string {
+    if name := classLabel(classNames, class); name != "" {
+        return strings.Map(func(r rune) rune {
+            if r == '/' || r == '\\' || r == filepath.Separator {
+                return '_'
+            }
+            return r
+        }, name)
+    }
+    if class < 0 {
+        return "failed"
+    }
+    return strconv.Itoa(class)
+}
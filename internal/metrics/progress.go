@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+/**
+* Evaluation progress
+
+A long evaluation should say how far along it is, how fast it goes and,
+since accuracy is what most runs are for, what the accuracy looks like so
+far. The evaluator hands a Progress to its ProgressReporter after every
+sample; what to show and how often is up to the reporter.
+
+ProgressBar redraws one line in place when it writes to a terminal:
+```
+[##########..........]  50%  500/1000  41.2/s  ETA 12s  accuracy 91.40%
+```
+Redirected to a file or a CI log, carriage returns would pile up into one
+huge line, so it prints a plain line every few seconds instead.
+*/
+
+// Progress is the state of an evaluation after a sample
+type Progress struct {
+    Done    int           // Samples evaluated
+    Total   int           // Samples expected, 0 when unknown
+    Correct int           // Correct top-1 predictions among Done
+    Elapsed time.Duration // Since the evaluation started
+}
+
+// Rate returns the samples evaluated per second
+func (p Progress) Rate() float64 {
+    if p.Elapsed <= 0 {
+        return 0
+    }
+    return float64(p.Done) / p.Elapsed.Seconds()
+}
+
+// ETA returns the expected time until the last sample, or false if it is unknown
+func (p Progress) ETA() (time.Duration, bool) {
+    rate := p.Rate()
+    if p.Total <= 0 || p.Done >= p.Total || rate == 0 {
+        return 0, false
+    }
+    return time.Duration(float64(p.Total-p.Done) / rate * float64(time.Second)), true
+}
+
+// Accuracy returns the top-1 accuracy of the samples evaluated so far
+func (p Progress) Accuracy() float64 {
+    if p.Done == 0 {
+        return 0
+    }
+    return float64(p.Correct) / float64(p.Done)
+}
+
+// ProgressReporter receives the progress of evaluations
+type ProgressReporter interface {
+    // Update is called after every sample, from a single goroutine
+    Update(p Progress)
+    // Finish is called once when an evaluation ends, even if it failed
+    Finish(p Progress)
+}
+
+// SetProgress makes every evaluation report its progress to reporter; total is the
+// number of samples expected, 0 if unknown
+func (e *Evaluator) SetProgress(reporter ProgressReporter, total int) {
+    e.progress = reporter
+    e.progressTotal = total
+}
+
+// Progress bar settings
+const (
+    progressBarWidth       = 20
+    progressRedrawInterval = 100 * time.Millisecond // On a terminal
+    progressLineInterval   = 5 * time.Second        // Otherwise
+)
+
+// ProgressBar is a ProgressReporter drawing a bar on a terminal and printing
+// periodic lines elsewhere
+type ProgressBar struct {
+    w        io.Writer
+    label    string
+    terminal bool
+    last     time.Time // When the last update was shown
+    width    int       // Length of the line last drawn on the terminal
+}
+
+// NewProgressBar creates a progress bar writing to w, with lines starting with label
+func NewProgressBar(w io.Writer, label string) *ProgressBar {
+    return &ProgressBar{w: w, label: label, terminal: isTerminal(w)}
+}
+
+// isTerminal reports whether w is a character device such as a terminal
+func isTerminal(w io.Writer) bool {
+    f, ok := w.(*os.File)
+    if !ok {
+        return false
+    }
+    info, err := f.Stat()
+    return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Update shows p if enough time has passed since the last update shown
+func (b *ProgressBar) Update(p Progress) {
+    interval := progressLineInterval
+    if b.terminal {
+        interval = progressRedrawInterval
+    }
+    now := time.Now()
+    if b.last.IsZero() {
+        b.last = now // The first line waits a full interval, so short runs stay quiet
+        return
+    }
+    if now.Sub(b.last) < interval {
+        return
+    }
+    b.last = now
+    b.show(p)
+}
+
+// Finish shows the final state and ends the line
+func (b *ProgressBar) Finish(p Progress) {
+    if b.terminal || !b.last.IsZero() {
+        b.show(p)
+    }
+    if b.terminal {
+        fmt.Fprintln(b.w)
+    }
+    b.last = time.Time{}
+    b.width = 0
+}
+
+// show writes one progress line, in place on a terminal
+func (b *ProgressBar) show(p Progress) {
+    line := b.format(p)
+    if !b.terminal {
+        fmt.Fprintln(b.w, line)
+        return
+    }
+    padding := max(b.width-len(line), 0) // Clear what is left of a longer previous line
+    fmt.Fprintf(b.w, "\r%s%s", line, strings.Repeat(" ", padding))
+    b.width = len(line)
+}
+
+// format renders p as a single line
+func (b *ProgressBar) format(p Progress) string {
+    var line strings.Builder
+    if b.label != "" {
+        line.WriteString(b.label + " ")
+    }
+    if p.Total > 0 {
+        fraction := min(float64(p.Done)/float64(p.Total), 1)
+        filled := int(fraction * progressBarWidth)
+        fmt.Fprintf(&line, "[%s%s] %3.0f%%  %d/%d", strings.Repeat("#", filled),
+            strings.Repeat(".", progressBarWidth-filled), fraction*100, p.Done, p.Total)
+    } else {
+        fmt.Fprintf(&line, "%d samples", p.Done)
+    }
+    fmt.Fprintf(&line, "  %.1f/s", p.Rate())
+    if eta, ok := p.ETA(); ok {
+        fmt.Fprintf(&line, "  ETA %v", eta.Round(time.Second))
+    }
+    fmt.Fprintf(&line, "  accuracy %.2f%%", p.Accuracy()*100)
+    return line.String()
+}
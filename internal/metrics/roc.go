@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"sort"
+)
+
+/**
+* ROC curves and AUC
+
+Accuracy only looks at the argmax. A deployment that acts on "cat" only
+above some confidence needs to know how the model trades missed cats for
+false alarms as that threshold moves. Each class is treated one-vs-rest:
+its probability scores every sample, samples of the class are positives,
+and sweeping the threshold from high to low traces the ROC curve:
+```
+TPR = TP / positives      (cats found)
+FPR = FP / negatives      (non-cats called cats)
+
+TPR 1 ┤      ___----
+      │   _-‾
+      │  /          AUC = area under the curve; 1 is a perfect ranking,
+      │ /                 0.5 is no better than chance
+    0 ┼─────────── FPR
+      0           1
+```
+The macro AUC averages the classes' AUCs, weighting every class the same;
+the micro AUC pools all (sample, class) scores into one curve, weighting
+every sample the same. A class with no positive or no negative samples
+has no curve and is left out of the macro average.
+
+Curves keep one point per distinct threshold, thinned to maxROCPoints for
+the reports; the AUC is always computed on the full curve.
+*/
+
+// maxROCPoints bounds the points kept of each curve
+const maxROCPoints = 200
+
+// ROCPoint is one threshold of a ROC curve
+type ROCPoint struct {
+    FPR       float64 `json:"fpr"`
+    TPR       float64 `json:"tpr"`
+    Threshold float64 `json:"threshold"` // Scores at or above it are positive; above them all at (0, 0)
+}
+
+// ROCCurve is the one-vs-rest ROC curve of a class
+type ROCCurve struct {
+    Class  int        `json:"class"`
+    AUC    float64    `json:"auc"`
+    Points []ROCPoint `json:"points"` // Empty when the class has no positives or no negatives
+}
+
+// scoredSample is one score and whether it belongs to a positive
+type scoredSample struct {
+    score    float32
+    positive bool
+}
+
+// computeROC fills the per-class and micro-averaged ROC curves and AUCs of result
+// from the probabilities of its predictions; failed predictions are left out
+func computeROC(result *EvaluationResult) {
+    numClasses := len(result.ConfusionMatrix)
+    perClass := make([][]scoredSample, numClasses)
+    var pooled []scoredSample
+    for _, pred := range result.Predictions {
+        if len(pred.Probabilities) == 0 {
+            continue
+        }
+        for c := 0; c < numClasses && c < len(pred.Probabilities); c++ {
+            s := scoredSample{score: pred.Probabilities[c], positive: pred.TrueClass == c}
+            perClass[c] = append(perClass[c], s)
+            pooled = append(pooled, s)
+        }
+    }
+
+    result.ROCCurves = make([]ROCCurve, numClasses)
+    var aucSum float64
+    defined := 0
+    for c := range perClass {
+        result.ROCCurves[c] = rocCurve(c, perClass[c])
+        if len(result.ROCCurves[c].Points) > 0 {
+            aucSum += result.ROCCurves[c].AUC
+            defined++
+        }
+    }
+    if defined > 0 {
+        result.MacroAUC = aucSum / float64(defined)
+    }
+
+    micro := rocCurve(-1, pooled)
+    result.MicroROC = micro.Points
+    result.MicroAUC = micro.AUC
+}
+
+// rocCurve sweeps the threshold over samples from the highest score down
+func rocCurve(class int, samples []scoredSample) ROCCurve {
+    curve := ROCCurve{Class: class}
+    positives := 0
+    for _, s := range samples {
+        if s.positive {
+            positives++
+        }
+    }
+    negatives := len(samples) - positives
+    if positives == 0 || negatives == 0 {
+        return curve
+    }
+
+    sort.Slice(samples, func(i, j int) bool { return samples[i].score > samples[j].score })
+
+    // Samples with equal scores cross the threshold together, so a point is
+    // only taken where the score changes; ties add a diagonal segment
+    points := []ROCPoint{{FPR: 0, TPR: 0, Threshold: float64(samples[0].score) + 1}}
+    tp, fp := 0, 0
+    for i, s := range samples {
+        if s.positive {
+            tp++
+        } else {
+            fp++
+        }
+        if i+1 < len(samples) && samples[i+1].score == s.score {
+            continue
+        }
+        point := ROCPoint{FPR: float64(fp) / float64(negatives), TPR: float64(tp) / float64(positives), Threshold: float64(s.score)}
+        last := points[len(points)-1]
+        curve.AUC += (point.FPR - last.FPR) * (point.TPR + last.TPR) / 2
+        points = append(points, point)
+    }
+    curve.Points = thinPoints(points, maxROCPoints)
+    return curve
+}
+
+// thinPoints keeps at most n points, evenly spaced along points and always
+// including the first and last
+func thinPoints(points []ROCPoint, n int) []ROCPoint {
+    if len(points) <= n {
+        return points
+    }
+    thinned := make([]ROCPoint, n)
+    for i := range thinned {
+        thinned[i] = points[i*(len(points)-1)/(n-1)]
+    }
+    return thinned
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/ops"
+	"time"
+)
+
+/**
+* Roofline analysis
+
+Per-layer times say where an inference spends its time, not whether that
+time is well spent. Dividing each layer's FLOPs by its average time gives
+the throughput it achieved, which the roofline compares with what the
+machine allows at the layer's arithmetic intensity:
+```
+layer    FLOP/byte   GFLOP/s   attainable   efficiency   bound
+conv6        180.2      24.1        31.0          78%   compute
+maxpool2       0.2       0.4         2.9          14%   memory
+```
+A compute-bound layer far below the peak has a slow kernel (try another
+-engine or -autotune); a memory-bound one can only get faster by moving
+less data, through a smaller layout, fusion or lower precision.
+*/
+
+// LayerRoofline is the achieved and attainable throughput of one layer
+type LayerRoofline struct {
+    Layer               string        `json:"layer"`
+    FLOPs               int64         `json:"flops"`
+    ParamBytes          int64         `json:"param_bytes"`
+    ArithmeticIntensity float64       `json:"arithmetic_intensity"` // FLOPs per byte moved
+    AverageTime         time.Duration `json:"average_time"`
+    GFLOPS              float64       `json:"gflops"`             // Achieved
+    AttainableGFLOPS    float64       `json:"attainable_gflops"`  // The roofline at the layer's intensity
+    Efficiency          float64       `json:"efficiency"`         // Achieved over attainable
+    ComputeBound        bool          `json:"compute_bound"`      // Intensity at or above the ridge point
+}
+
+// Roofline is the roofline analysis of a model's layers
+type Roofline struct {
+    Peak       ops.MachinePeak `json:"peak"`
+    RidgePoint float64         `json:"ridge_point"` // FLOPs per byte where memory stops being the limit
+    Layers     []LayerRoofline `json:"layers"`
+}
+
+// ComputeRoofline compares the average layer times of info with the FLOPs and
+// arithmetic intensity of its layers and the machine's peak; layers that were
+// never timed or do no arithmetic are left out
+func ComputeRoofline(info *model.ModelInfo, peak ops.MachinePeak) *Roofline {
+    roofline := &Roofline{Peak: peak, RidgePoint: peak.RidgePoint()}
+    for _, cost := range info.Layers {
+        avg := info.AverageLayerTimes[cost.Name]
+        if avg <= 0 || cost.FLOPs == 0 {
+            continue
+        }
+
+        layer := LayerRoofline{
+            Layer:               cost.Name,
+            FLOPs:               cost.FLOPs,
+            ParamBytes:          cost.ParamBytes,
+            ArithmeticIntensity: cost.ArithmeticIntensity,
+            AverageTime:         avg,
+            GFLOPS:              float64(cost.FLOPs) / avg.Seconds() / 1e9,
+            AttainableGFLOPS:    peak.Attainable(cost.ArithmeticIntensity),
+            ComputeBound:        cost.ArithmeticIntensity >= roofline.RidgePoint,
+        }
+        if layer.AttainableGFLOPS > 0 {
+            layer.Efficiency = layer.GFLOPS / layer.AttainableGFLOPS
+        }
+        roofline.Layers = append(roofline.Layers, layer)
+    }
+    return roofline
+}
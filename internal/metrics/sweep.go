@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+* Worker-count throughput sweep
+
+More evaluation workers only help while there are idle cores and memory
+bandwidth to feed them; past that, they queue for the same resources and
+every inference gets slower. The sweep evaluates the same samples once per
+worker count and measures wall-clock throughput, which is what a batch job
+sees, rather than the per-sample times the other metrics add up:
+```
+workers  throughput  speedup  efficiency
+      1     10.0/s     1.00x     100%
+      2     19.6/s     1.96x      98%
+      4     36.0/s     3.60x      90%
+      8     38.4/s     3.84x      48%     ← past the core count
+```
+Speedup is relative to the first (smallest) count, and efficiency is the
+speedup divided by the growth in workers: the fraction of the added workers
+that turned into added throughput. The knee where efficiency collapses is
+usually the -workers to pick.
+*/
+
+// SweepPoint is the evaluation of the samples at one worker count
+type SweepPoint struct {
+    Workers              int           `json:"workers"`
+    WallTime             time.Duration `json:"wall_time"`  // From the first sample read to the last result
+    Throughput           float64       `json:"throughput"` // Samples per wall-clock second
+    Speedup              float64       `json:"speedup"`    // Throughput relative to the first point
+    Efficiency           float64       `json:"efficiency"` // Speedup per added worker, 1 for perfect scaling
+    AverageInferenceTime time.Duration `json:"average_inference_time"`
+    P99InferenceTime     time.Duration `json:"p99_inference_time"`
+    Top1Accuracy         float64       `json:"top1_accuracy"` // The same at every count, as a sanity check
+}
+
+// ParseWorkerCounts converts a comma-separated list of worker counts to increasing
+// distinct values, such as "1,2,4,8,16"
+func ParseWorkerCounts(list string) ([]int, error) {
+    var counts []int
+    for _, field := range strings.Split(list, ",") {
+        field = strings.TrimSpace(field)
+        if field == "" {
+            continue
+        }
+        n, err := strconv.Atoi(field)
+        if err != nil || n <= 0 {
+            return nil, fmt.Errorf("invalid worker count %q: must be a positive integer", field)
+        }
+        counts = append(counts, n)
+    }
+    if len(counts) == 0 {
+        return nil, fmt.Errorf("no worker counts given")
+    }
+    slices.Sort(counts)
+    return slices.Compact(counts), nil
+}
+
+// SweepWorkers evaluates cnn on images and labels once for every worker count and
+// measures the throughput at each; the evaluator's other settings apply to every run
+func (e *Evaluator) SweepWorkers(cnn model.Predictor, images []*tensor.FeatureMap, labels [][]int,
+    counts []int) ([]SweepPoint, error) {
+
+    defer func(workers int) { e.numWorkers = workers }(e.numWorkers)
+
+    points := make([]SweepPoint, 0, len(counts))
+    for _, workers := range counts {
+        slog.Debug("evaluating", "workers", workers)
+        e.numWorkers = workers
+        start := time.Now()
+        result, err := e.EvaluateModel(cnn, images, labels)
+        if err != nil {
+            return nil, fmt.Errorf("%d workers: %w", workers, err)
+        }
+        wall := time.Since(start)
+
+        point := SweepPoint{
+            Workers:              workers,
+            WallTime:             wall,
+            Throughput:           float64(result.TotalSamples) / wall.Seconds(),
+            AverageInferenceTime: result.AverageInferenceTime,
+            P99InferenceTime:     result.P99InferenceTime,
+            Top1Accuracy:         result.Top1Accuracy,
+        }
+        base := point
+        if len(points) > 0 {
+            base = points[0]
+        }
+        point.Speedup = point.Throughput / base.Throughput
+        point.Efficiency = point.Speedup / (float64(workers) / float64(base.Workers))
+        points = append(points, point)
+    }
+    return points, nil
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"time"
+)
+
+/**
+* Warm-up samples: cold versus warm timings
+
+The first inferences of a run are slower than the rest for reasons that
+have nothing to do with the model: weight pages fault in on first touch,
+the buffer pools start empty, and the worker goroutines and their stacks
+are still being set up. Averaged into a short run, they inflate the mean
+and dominate the maximum:
+```
+latency
+   │█
+   │█▆
+   │██▃
+   │███▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁
+   └──────────────────────── sample
+    cold│ warm
+```
+With SetWarmup(n) the first n samples are evaluated as usual and count
+toward every accuracy metric, but their timings are kept out of the timing
+metrics (total, average, min, max, percentiles, throughput) and summarized
+separately in Cold, so both the steady-state and the start-up cost can be
+read. Per-layer timings come from the model's counters and still include
+them. At least one sample always stays warm.
+*/
+
+// ColdTiming summarizes the inference times of the warm-up samples
+type ColdTiming struct {
+    Samples              int           `json:"samples"`
+    TotalInferenceTime   time.Duration `json:"total_inference_time"`
+    AverageInferenceTime time.Duration `json:"average_inference_time"`
+    MinInferenceTime     time.Duration `json:"min_inference_time"`
+    MaxInferenceTime     time.Duration `json:"max_inference_time"`
+}
+
+// SetWarmup makes the first n samples of every evaluation warm-up samples, whose
+// timings are reported in Cold instead of the timing metrics
+func (e *Evaluator) SetWarmup(n int) {
+    e.warmup = max(n, 0)
+}
+
+// splitWarmup returns the warm-up samples of predictions and the rest; at least one
+// sample is left warm
+func (e *Evaluator) splitWarmup(predictions []PredictionDetail) ([]PredictionDetail, []PredictionDetail) {
+    n := min(e.warmup, len(predictions)-1)
+    if n <= 0 {
+        return nil, predictions
+    }
+    return predictions[:n], predictions[n:]
+}
+
+// computeColdTiming summarizes the inference times of the warm-up samples in cold
+func computeColdTiming(cold []PredictionDetail) *ColdTiming {
+    if len(cold) == 0 {
+        return nil
+    }
+    timing := &ColdTiming{
+        Samples:          len(cold),
+        MinInferenceTime: cold[0].InferenceTime,
+        MaxInferenceTime: cold[0].InferenceTime,
+    }
+    for _, pred := range cold {
+        timing.TotalInferenceTime += pred.InferenceTime
+        timing.MinInferenceTime = min(timing.MinInferenceTime, pred.InferenceTime)
+        timing.MaxInferenceTime = max(timing.MaxInferenceTime, pred.InferenceTime)
+    }
+    timing.AverageInferenceTime = timing.TotalInferenceTime / time.Duration(len(cold))
+    return timing
+}
@@ -1,6 +1,9 @@
 package model
 
-import "fmt"
+import (
+	"duchm1606/gocnn/internal/ops"
+	"fmt"
+)
 
 // LayerType defines the type of neural network layer
 type LayerType int
@@ -11,8 +14,17 @@ const (
     GlobalMaxPoolingLayer
     SoftmaxLayer
     BatchNormLayer
+    Convolution1DLayer // 1D convolution over the width axis (height must be 1)
+    Convolution3DLayer // 3D convolution over depth, height and width
+    CustomLayer        // Operation registered with ops.RegisterCustomLayer
+    GlobalAveragePoolingLayer
 )
 
+// String returns the config name of the layer type, such as "max_pooling"
+func (t LayerType) String() string {
+    return layerTypeName(t)
+}
+
 // LayerConfig defines configuration for a single layer
 type LayerConfig struct {
     Type       LayerType
@@ -31,10 +43,15 @@ type LayerConfig struct {
     // Other parameters
     ApplyBatchNorm bool
     ApplyActivation bool
+    
+    // Custom layer parameters
+    CustomOp     string             // Name passed to ops.RegisterCustomLayer
+    CustomParams map[string]float64 // Passed through to the forward function
 }
 
 // TinyCNNArchitecture defines the complete network architecture
 type TinyCNNArchitecture struct {
+    InputDepth    int // Depth (frames) for 3D inputs, 0 for 2D images
     InputHeight   int
     InputWidth    int
     InputChannels int
@@ -178,7 +195,10 @@ func (arch *TinyCNNArchitecture) ValidateArchitecture() error {
 
 func validateLayerConfig(layer LayerConfig) error {
     switch layer.Type {
-    case ConvolutionLayer:
+    case ConvolutionLayer, Convolution1DLayer, Convolution3DLayer:
+        if layer.Type != ConvolutionLayer && layer.ApplyBatchNorm {
+            return fmt.Errorf("batch norm is fused into 2D convolutions only")
+        }
         if layer.KernelSize <= 0 {
             return fmt.Errorf("invalid kernel size: %d", layer.KernelSize)
         }
@@ -200,27 +220,45 @@ func validateLayerConfig(layer LayerConfig) error {
             return fmt.Errorf("invalid pool stride: %d", layer.PoolStride)
         }
         
-    case GlobalMaxPoolingLayer, SoftmaxLayer:
+    case GlobalMaxPoolingLayer, GlobalAveragePoolingLayer, SoftmaxLayer:
         // No specific validation needed
         
+    case CustomLayer:
+        if layer.CustomOp == "" {
+            return fmt.Errorf("custom layer has no op")
+        }
+        if _, ok := ops.LookupCustomLayer(layer.CustomOp); !ok {
+            return fmt.Errorf("custom op %q is not registered", layer.CustomOp)
+        }
+        
     default:
-        return fmt.Errorf("unknown layer type: %d", layer.Type)
+        return fmt.Errorf("unsupported layer type: %s", layer.Type)
     }
     
     return nil
 }
 
 // GetOutputDimensions calculates the output dimensions after each layer
+// Each entry is [height, width, channels]; architectures with a 3D input
+// (InputDepth > 0) get a fourth entry holding the depth
 func (arch *TinyCNNArchitecture) GetOutputDimensions() ([][]int, error) {
     dimensions := make([][]int, len(arch.Layers)+1)
     
     // Input dimensions
+    currentD := arch.InputDepth
     currentH := arch.InputHeight
     currentW := arch.InputWidth
     currentC := arch.InputChannels
-    dimensions[0] = []int{currentH, currentW, currentC}
+    dimensions[0] = arch.dimensionEntry(currentD, currentH, currentW, currentC)
     
     for i, layer := range arch.Layers {
+        // Frames are stacked along the height (see conv_nd.go); 2D windows would mix them
+        if currentD > 1 && (layer.Type == ConvolutionLayer || layer.Type == MaxPoolingLayer || 
+            layer.Type == Convolution1DLayer) {
+            return nil, fmt.Errorf("layer %d (%s): only 3D convolution and global pooling can take %d frames", 
+                i, layer.Name, currentD)
+        }
+        
         switch layer.Type {
         case ConvolutionLayer:
             // Apply padding, then convolution
@@ -231,22 +269,110 @@ func (arch *TinyCNNArchitecture) GetOutputDimensions() ([][]int, error) {
             currentW = (paddedW-layer.KernelSize)/layer.Stride + 1
             currentC = layer.Filters
             
+        case Convolution1DLayer:
+            if currentH != 1 {
+                return nil, fmt.Errorf("layer %d (%s): 1D convolution requires height 1, got %d", 
+                    i, layer.Name, currentH)
+            }
+            currentW = (currentW+2*layer.Padding-layer.KernelSize)/layer.Stride + 1
+            currentC = layer.Filters
+            
+        case Convolution3DLayer:
+            if currentD <= 0 {
+                return nil, fmt.Errorf("layer %d (%s): 3D convolution requires InputDepth > 0", 
+                    i, layer.Name)
+            }
+            currentD = (currentD+2*layer.Padding-layer.KernelSize)/layer.Stride + 1
+            currentH = (currentH+2*layer.Padding-layer.KernelSize)/layer.Stride + 1
+            currentW = (currentW+2*layer.Padding-layer.KernelSize)/layer.Stride + 1
+            currentC = layer.Filters
+            
         case MaxPoolingLayer:
             currentH = (currentH-layer.PoolSize)/layer.PoolStride + 1
             currentW = (currentW-layer.PoolSize)/layer.PoolStride + 1
             // Channels unchanged
             
-        case GlobalMaxPoolingLayer:
+        case GlobalMaxPoolingLayer, GlobalAveragePoolingLayer:
             currentH = 1
             currentW = 1
+            if currentD > 0 {
+                currentD = 1
+            }
             // Channels unchanged
             
         case SoftmaxLayer:
             // Dimensions unchanged (applied to flattened vector)
+            
+        case CustomLayer:
+            custom, ok := ops.LookupCustomLayer(layer.CustomOp)
+            if !ok {
+                return nil, fmt.Errorf("layer %d (%s): custom op %q is not registered", 
+                    i, layer.Name, layer.CustomOp)
+            }
+            currentH, currentW, currentC = custom.OutputDims(currentH, currentW, currentC, layer.CustomParams)
         }
         
-        dimensions[i+1] = []int{currentH, currentW, currentC}
+        dimensions[i+1] = arch.dimensionEntry(currentD, currentH, currentW, currentC)
     }
     
     return dimensions, nil
-}
\ No newline at end of file
+}
+
+// hasLayer reports whether any layer is of type layerType
+func (arch *TinyCNNArchitecture) hasLayer(layerType LayerType) bool {
+    for _, layer := range arch.Layers {
+        if layer.Type == layerType {
+            return true
+        }
+    }
+    return false
+}
+
+// InputSize returns the number of values of one input: depth×height×width×channels
+// for 3D inputs, height×width×channels otherwise
+func (arch *TinyCNNArchitecture) InputSize() int {
+    return arch.stackedInputHeight() * arch.InputWidth * arch.InputChannels
+}
+
+// stackedInputHeight returns the input height as Predict carries it, with the
+// frames of a 3D input stacked along the height
+func (arch *TinyCNNArchitecture) stackedInputHeight() int {
+    return max(arch.InputDepth, 1) * arch.InputHeight
+}
+
+// dimensionEntry builds one GetOutputDimensions entry, appending depth for 3D inputs
+func (arch *TinyCNNArchitecture) dimensionEntry(depth, height, width, channels int) []int {
+    if arch.InputDepth > 0 {
+        return []int{height, width, channels, depth}
+    }
+    return []int{height, width, channels}
+}
+
+// ConvShapes lists the 2D convolution layers with their input shapes, for ops.ConvolutionEngine.Autotune
+func (arch *TinyCNNArchitecture) ConvShapes() ([]ops.ConvShape, error) {
+    dimensions, err := arch.GetOutputDimensions()
+    if err != nil {
+        return nil, err
+    }
+    
+    var shapes []ops.ConvShape
+    for i, layer := range arch.Layers {
+        if layer.Type != ConvolutionLayer {
+            continue
+        }
+        
+        input := dimensions[i]
+        shapes = append(shapes, ops.ConvShape{
+            Name:       layer.Name,
+            Height:     input[0],
+            Width:      input[1],
+            Channels:   input[2],
+            KernelSize: layer.KernelSize,
+            Filters:    layer.Filters,
+            Padding:    layer.Padding,
+            Stride:     layer.Stride,
+        })
+    }
+    
+    return shapes, nil
+}
@@ -0,0 +1,231 @@
+package model
+
+import (
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/ops"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"math"
+	"time"
+)
+
+/**
+* 1D and 3D convolution layers
+
+Predict carries every activation as a tensor.FeatureMap. A 1D activation is a
+feature map of height 1, and a 3D one stacks its frames along the height, so
+both have the data order of tensor.FeatureMap1D and tensor.FeatureMap3D and
+are viewed as those without copying:
+```
+FeatureMap1D{Length: W, Channels: C}               ≡  FeatureMap{Height: 1,   Width: W, Channels: C}
+FeatureMap3D{Depth: D, Height: H, Width: W, ...}   ≡  FeatureMap{Height: D*H, Width: W, Channels: C}
+```
+Global pooling over the stacked map pools every frame at once, as 3D global
+pooling would. 2D convolution and max pooling would mix neighbouring frames,
+so GetOutputDimensions rejects them while the depth is above 1.
+
+Each layer reads its kernel and bias from <name>/<name>_weight.bin and
+<name>/<name>_bias.bin, in the file order of data.LoadKernel1D and
+LoadKernel3D. The layers run ops.Conv1DOf and ops.Conv3DOf, at float32 or in
+double precision, followed by ReLU when ApplyActivation is set; batch norm
+is fused into 2D convolutions only.
+*/
+
+// loadConvNDWeights loads the kernel and bias of every 1D and 3D conv layer, keyed by
+// layer name, along with the time each layer took to read
+func loadConvNDWeights(weightsPath string, arch *TinyCNNArchitecture) (map[string]*data.ConvNDWeights,
+    []data.LayerLoadTime, error) {
+
+    convND := make(map[string]*data.ConvNDWeights)
+    var loadTimes []data.LayerLoadTime
+
+    var dimensions [][]int
+    loader := data.NewWeightLoader(weightsPath)
+
+    for i, layer := range arch.Layers {
+        if layer.Type != Convolution1DLayer && layer.Type != Convolution3DLayer {
+            continue
+        }
+
+        // The kernel's channel count is the layer's input channel count
+        if dimensions == nil {
+            var err error
+            dimensions, err = arch.GetOutputDimensions()
+            if err != nil {
+                return nil, nil, err
+            }
+        }
+        inputChannels := dimensions[i][2]
+
+        layerStart := time.Now()
+        kernelFile := fmt.Sprintf("%s/%s_weight.bin", layer.Name, layer.Name)
+        weights := &data.ConvNDWeights{}
+        var err error
+        if layer.Type == Convolution1DLayer {
+            weights.Kernel1D, err = loader.LoadKernel1D(kernelFile, layer.KernelSize, inputChannels, layer.Filters)
+        } else {
+            weights.Kernel3D, err = loader.LoadKernel3D(kernelFile, layer.KernelSize, inputChannels, layer.Filters)
+        }
+        if err != nil {
+            return nil, nil, fmt.Errorf("failed to load kernel for %s: %w", layer.Name, err)
+        }
+
+        biasFile := fmt.Sprintf("%s/%s_bias.bin", layer.Name, layer.Name)
+        weights.Bias, err = loader.LoadBias(biasFile, layer.Filters)
+        if err != nil {
+            return nil, nil, fmt.Errorf("failed to load bias for %s: %w", layer.Name, err)
+        }
+        convND[layer.Name] = weights
+
+        values := convKernelVolume(layer)*int64(inputChannels*layer.Filters) + int64(layer.Filters)
+        loadTimes = append(loadTimes, data.LayerLoadTime{
+            Layer:    layer.Name,
+            Duration: time.Since(layerStart),
+            Bytes:    values * 4,
+        })
+    }
+
+    return convND, loadTimes, nil
+}
+
+// checkConvNDShape reports a 1D or 3D conv layer whose weights are missing or do not fit
+// the architecture
+func checkConvNDShape(layer LayerConfig, inputChannels int, weights *data.ConvNDWeights) error {
+    dims := "1D"
+    if layer.Type == Convolution3DLayer {
+        dims = "3D"
+    }
+
+    var size, channels, filters int
+    switch {
+    case weights == nil:
+    case layer.Type == Convolution1DLayer && weights.Kernel1D != nil:
+        size, channels, filters = weights.Kernel1D.Size, weights.Kernel1D.Channels, weights.Kernel1D.Filters
+    case layer.Type == Convolution3DLayer && weights.Kernel3D != nil:
+        size, channels, filters = weights.Kernel3D.Size, weights.Kernel3D.Channels, weights.Kernel3D.Filters
+    }
+    if filters == 0 {
+        err := fmt.Errorf("layer %s has no %s kernel loaded", layer.Name, dims)
+        return errs.WithHint(err, "export %s/%s_weight.bin and %s_bias.bin with the model", layer.Name, layer.Name, layer.Name)
+    }
+
+    if channels != inputChannels {
+        err := fmt.Errorf("layer %s expects %d input channels, but its weights have %d",
+            layer.Name, inputChannels, channels)
+        return errs.WithHint(err, "the config's layers do not match the weights; check input_channels and the "+
+            "filters of the layer before %s against the exported model", layer.Name)
+    }
+    if filters != layer.Filters || size != layer.KernelSize {
+        err := fmt.Errorf("layer %s is configured as %d %s filters of size %d, but its weights have %d of size %d",
+            layer.Name, layer.Filters, dims, layer.KernelSize, filters, size)
+        return errs.WithHint(err, "set filters: %d and kernel_size: %d for %s in the config, or use weights exported "+
+            "from the configured model", filters, size, layer.Name)
+    }
+    if len(weights.Bias) != filters {
+        return fmt.Errorf("layer %s has %d filters but %d biases", layer.Name, filters, len(weights.Bias))
+    }
+    return nil
+}
+
+// validateConvNDValues reports a NaN or infinite weight or bias of a 1D or 3D conv layer
+func validateConvNDValues(weights *data.ConvNDWeights) error {
+    var kernel []float32
+    if weights.Kernel1D != nil {
+        kernel = weights.Kernel1D.Weights
+    } else if weights.Kernel3D != nil {
+        kernel = weights.Kernel3D.Weights
+    }
+    for name, values := range map[string][]float32{"weight": kernel, "bias": weights.Bias} {
+        for i, v := range values {
+            if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+                return fmt.Errorf("invalid %s at index %d: %f", name, i, v)
+            }
+        }
+    }
+    return nil
+}
+
+// processConvolutionNDLayer runs a 1D or 3D conv layer on input, whose frames are
+// stacked along its height when depth > 0, and returns the output and its depth
+func (cnn *TinyCNN) processConvolutionNDLayer(input *tensor.FeatureMap, config LayerConfig,
+    depth int) (*tensor.FeatureMap, int, error) {
+    
+    // The ops read the channel-major order
+    if input.Layout != tensor.LayoutCHW {
+        input = input.ToLayout(tensor.LayoutCHW)
+    }
+    output, depth, err := convolutionNDOf(&tensor.FeatureMapOf[float32]{
+        Height:   input.Height,
+        Width:    input.Width,
+        Channels: input.Channels,
+        Data:     input.Data,
+    }, cnn.weights.ConvND[config.Name], config, depth)
+    if err != nil {
+        return nil, 0, err
+    }
+    return &tensor.FeatureMap{Height: output.Height, Width: output.Width, Channels: output.Channels, 
+        Data: output.Data}, depth, nil
+}
+
+// convolutionNDOf is processConvolutionNDLayer over a CHW feature map of T, which
+// ops.Conv1DOf and Conv3DOf view as [channel][length] or [channel][depth][height][width]
+func convolutionNDOf[T tensor.Float](input *tensor.FeatureMapOf[T], weights *data.ConvNDWeights, 
+    config LayerConfig, depth int) (*tensor.FeatureMapOf[T], int, error) {
+    
+    if weights == nil {
+        return nil, 0, fmt.Errorf("no weights loaded for %s", config.Name)
+    }
+    if input.Layout != tensor.LayoutCHW {
+        return nil, 0, fmt.Errorf("1D and 3D convolutions take %s feature maps, got %s", tensor.LayoutCHW, input.Layout)
+    }
+    convConfig := ops.Conv2DConfig{
+        Padding: config.Padding,
+        Stride:  config.Stride,
+    }
+    
+    output := &tensor.FeatureMapOf[T]{Height: 1, Channels: config.Filters}
+    switch config.Type {
+    case Convolution1DLayer:
+        if input.Height != 1 || weights.Kernel1D == nil || input.Channels != weights.Kernel1D.Channels {
+            return nil, 0, fmt.Errorf("1D convolution needs a 1D kernel and an input of height 1 with its "+
+                "channels, got %d×%d×%d", input.Height, input.Width, input.Channels)
+        }
+        output.Data, output.Width = ops.Conv1DOf(input.Data, input.Width, weights.Kernel1D, weights.Bias, convConfig)
+        
+    case Convolution3DLayer:
+        if depth <= 0 || input.Height%depth != 0 || weights.Kernel3D == nil || 
+            input.Channels != weights.Kernel3D.Channels {
+            return nil, 0, fmt.Errorf("3D convolution needs a 3D kernel and an input of %d stacked frames with "+
+                "its channels, got %d×%d×%d", depth, input.Height, input.Width, input.Channels)
+        }
+        var height int
+        output.Data, depth, height, output.Width = ops.Conv3DOf(input.Data, depth, input.Height/depth, input.Width,
+            weights.Kernel3D, weights.Bias, convConfig)
+        output.Height = depth * height
+        
+    default:
+        return nil, 0, fmt.Errorf("layer type %s is not a 1D or 3D convolution", config.Type)
+    }
+    
+    if config.ApplyActivation {
+        for i, v := range output.Data {
+            output.Data[i] = max(v, 0)
+        }
+    }
+    return output, depth, nil
+}
+
+// convNDChannels returns the input channel count of a 1D or 3D conv layer's kernel
+func (cnn *TinyCNN) convNDChannels(name string) int {
+    weights := cnn.weights.ConvND[name]
+    switch {
+    case weights == nil:
+        return 0
+    case weights.Kernel1D != nil:
+        return weights.Kernel1D.Channels
+    case weights.Kernel3D != nil:
+        return weights.Kernel3D.Channels
+    }
+    return 0
+}
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"duchm1606/gocnn/internal/ops"
+)
+
+/**
+* Layer cost
+
+The work of a layer follows from the architecture alone, independent of
+the weights' values:
+```
+conv      2 × outputs × input channels × kernel volume   (a multiply-accumulate is two FLOPs)
+          + 1 per output each for bias, batch norm scale/shift (2) and ReLU
+max pool  pool size² comparisons per output
+global    one comparison per input value
+softmax   an exponential, a sum and a division per value
+custom    unknown, counted as 0
+```
+Bytes moved are the layer's parameters plus its input and output feature
+maps, each read or written once; arithmetic intensity is FLOPs per byte
+moved. Real kernels re-read data that falls out of cache, so the intensity
+is an upper bound, but it ranks layers correctly: the network's late, wide
+convolutions are far more compute-heavy per byte than its pooling layers.
+*/
+
+// activationBytes is the size of an activation value; feature maps stay float32
+// even when the weights are stored at a lower precision
+const activationBytes = 4
+
+// LayerCost is the work and data of one layer for one inference
+type LayerCost struct {
+    Name                string
+    Type                LayerType
+    FLOPs               int64
+    ParamBytes          int64   // Weights, biases and batch norm as stored
+    ActivationBytes     int64   // Input plus output feature map
+    ArithmeticIntensity float64 // FLOPs per byte of parameters and activations
+}
+
+// setParamBytes replaces the parameter size and updates the arithmetic intensity
+func (c *LayerCost) setParamBytes(n int64) {
+    c.ParamBytes = n
+    c.ArithmeticIntensity = 0
+    if moved := c.ParamBytes + c.ActivationBytes; moved > 0 {
+        c.ArithmeticIntensity = float64(c.FLOPs) / float64(moved)
+    }
+}
+
+// LayerCosts returns the cost of every layer of arch, with float32 parameters
+func (arch *TinyCNNArchitecture) LayerCosts() ([]LayerCost, error) {
+    dimensions, err := arch.GetOutputDimensions()
+    if err != nil {
+        return nil, err
+    }
+
+    costs := make([]LayerCost, len(arch.Layers))
+    for i, layer := range arch.Layers {
+        input, output := dimensions[i], dimensions[i+1]
+        inputs, outputs := elementCount(input), elementCount(output)
+        cost := LayerCost{
+            Name:            layer.Name,
+            Type:            layer.Type,
+            ActivationBytes: (inputs + outputs) * activationBytes,
+        }
+
+        var params int64
+        switch layer.Type {
+        case ConvolutionLayer, Convolution1DLayer, Convolution3DLayer:
+            volume := convKernelVolume(layer)
+            channels := int64(input[2])
+            filters := int64(layer.Filters)
+            params = volume*channels*filters + filters
+
+            // Bias, then the optional batch norm and ReLU, per output value
+            perOutput := int64(1)
+            if layer.ApplyBatchNorm {
+                params += 4 * filters
+                perOutput += 2
+            }
+            if layer.ApplyActivation {
+                perOutput++
+            }
+            macs := outputs * channels * volume
+            if layer.Type == ConvolutionLayer {
+                macs = ops.ConvolutionMACs(input[0], input[1], input[2], layer.KernelSize, layer.Filters,
+                    layer.Padding, layer.Stride)
+            }
+            cost.FLOPs = 2*macs + outputs*perOutput
+
+        case MaxPoolingLayer:
+            cost.FLOPs = outputs * int64(layer.PoolSize*layer.PoolSize)
+
+        case GlobalMaxPoolingLayer, GlobalAveragePoolingLayer:
+            cost.FLOPs = inputs
+            
+        case SoftmaxLayer:
+            cost.FLOPs = 3 * inputs
+        }
+
+        cost.setParamBytes(params * 4)
+        costs[i] = cost
+    }
+    return costs, nil
+}
+
+// convKernelVolume returns the number of kernel weights per input channel and filter
+func convKernelVolume(layer LayerConfig) int64 {
+    k := int64(layer.KernelSize)
+    switch layer.Type {
+    case Convolution1DLayer:
+        return k
+    case Convolution3DLayer:
+        return k * k * k
+    default:
+        return k * k
+    }
+}
+
+// elementCount returns the number of values of a GetOutputDimensions entry
+func elementCount(dims []int) int64 {
+    n := int64(1)
+    for _, d := range dims {
+        n *= int64(d)
+    }
+    return n
+}
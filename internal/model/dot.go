@@ -0,0 +1,159 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+/**
+* Graphviz export
+
+Architectures defined in a config file are easiest to review as a picture:
+ToDOT renders the layer stack as a Graphviz digraph, one node per layer
+annotated from the architecture alone (no weights needed):
+```
+┌──────────────────────────────┐
+│ conv3                        │   type and hyperparameters
+│ convolution 3×3, 64, s1 p1   │
+│ batch norm + ReLU            │   epilogue, for conv layers
+│ 16×16×64                     │   output shape (H×W×C, D×H×W×C for 3D)
+│ 18.8K params · 9.5M FLOPs    │   as LayerCosts counts them
+└──────────────────────────────┘
+          │ 16×16×64
+          ▼
+```
+Edges carry the shape flowing between layers, and a note sums parameters and
+FLOPs. Render with `dot -Tsvg model.dot -o model.svg`.
+*/
+
+// dotColors is the fill color of each layer type's nodes
+var dotColors = map[LayerType]string{
+    ConvolutionLayer:          "#c6dbef",
+    Convolution1DLayer:        "#c6dbef",
+    Convolution3DLayer:        "#c6dbef",
+    MaxPoolingLayer:           "#fdd0a2",
+    GlobalMaxPoolingLayer:     "#fdae6b",
+    GlobalAveragePoolingLayer: "#fdae6b",
+    SoftmaxLayer:              "#c7e9c0",
+    BatchNormLayer:            "#dadaeb",
+    CustomLayer:               "#d9d9d9",
+}
+
+// ToDOT returns the architecture as a Graphviz digraph with the type, output shape,
+// parameter count and FLOPs of every layer
+func (arch *TinyCNNArchitecture) ToDOT() (string, error) {
+    dimensions, err := arch.GetOutputDimensions()
+    if err != nil {
+        return "", err
+    }
+    costs, err := arch.LayerCosts()
+    if err != nil {
+        return "", err
+    }
+
+    var b strings.Builder
+    b.WriteString("digraph TinyCNN {\n")
+    b.WriteString("    rankdir=TB;\n")
+    b.WriteString("    node [shape=record, style=\"rounded,filled\", fontname=\"Helvetica\", fontsize=10];\n")
+    b.WriteString("    edge [fontname=\"Helvetica\", fontsize=9];\n\n")
+
+    fmt.Fprintf(&b, "    n0 [label=\"{input|%s}\", fillcolor=\"#ffffff\"];\n", dotShape(dimensions[0]))
+
+    var totalParams, totalFLOPs int64
+    for i, layer := range arch.Layers {
+        params := costs[i].ParamBytes / 4
+        totalParams += params
+        totalFLOPs += costs[i].FLOPs
+
+        fields := []string{dotEscape(layer.Name), dotEscape(layerDescription(layer))}
+        if epilogue := layerEpilogue(layer); epilogue != "" {
+            fields = append(fields, epilogue)
+        }
+        fields = append(fields,
+            dotShape(dimensions[i+1]),
+            fmt.Sprintf("%s params · %s FLOPs", formatCount(params), formatCount(costs[i].FLOPs)))
+
+        color, ok := dotColors[layer.Type]
+        if !ok {
+            color = "#ffffff"
+        }
+        fmt.Fprintf(&b, "    n%d [label=\"{%s}\", fillcolor=\"%s\"];\n", i+1, strings.Join(fields, "|"), color)
+        fmt.Fprintf(&b, "    n%d -> n%d [label=\" %s\"];\n", i, i+1, dotShape(dimensions[i]))
+    }
+
+    fmt.Fprintf(&b, "\n    total [shape=note, style=filled, fillcolor=\"#ffffcc\", label=\"%d layers\\n%s params\\n%s FLOPs per image\"];\n",
+        len(arch.Layers), formatCount(totalParams), formatCount(totalFLOPs))
+    b.WriteString("}\n")
+    return b.String(), nil
+}
+
+// layerDescription returns the type of layer with its hyperparameters
+func layerDescription(layer LayerConfig) string {
+    switch layer.Type {
+    case ConvolutionLayer, Convolution3DLayer:
+        kernel := fmt.Sprintf("%d×%d", layer.KernelSize, layer.KernelSize)
+        if layer.Type == Convolution3DLayer {
+            kernel += fmt.Sprintf("×%d", layer.KernelSize)
+        }
+        return fmt.Sprintf("%s %s, %d filters, stride %d, pad %d",
+            layerTypeName(layer.Type), kernel, layer.Filters, layer.Stride, layer.Padding)
+    case Convolution1DLayer:
+        return fmt.Sprintf("%s %d, %d filters, stride %d, pad %d",
+            layerTypeName(layer.Type), layer.KernelSize, layer.Filters, layer.Stride, layer.Padding)
+    case MaxPoolingLayer:
+        return fmt.Sprintf("%s %d×%d, stride %d", layerTypeName(layer.Type), layer.PoolSize, layer.PoolSize, layer.PoolStride)
+    case CustomLayer:
+        return fmt.Sprintf("custom op %s", layer.CustomOp)
+    default:
+        return layerTypeName(layer.Type)
+    }
+}
+
+// layerEpilogue describes the batch norm and activation after a conv layer, if any
+// Batch norm always ends in ReLU, as in convEpilogue.
+func layerEpilogue(layer LayerConfig) string {
+    switch {
+    case layer.ApplyBatchNorm:
+        return "batch norm + ReLU"
+    case layer.ApplyActivation:
+        return "ReLU"
+    }
+    return ""
+}
+
+// layerTypeName returns the config name of a layer type, such as "max_pooling", or
+// LayerType(n) for a type configs cannot name
+func layerTypeName(layerType LayerType) string {
+    for name, t := range layerTypeNames {
+        if t == layerType {
+            return name
+        }
+    }
+    return fmt.Sprintf("LayerType(%d)", int(layerType))
+}
+
+// dotShape formats a GetOutputDimensions entry as H×W×C, or D×H×W×C for 3D inputs
+func dotShape(dims []int) string {
+    if len(dims) == 4 {
+        return fmt.Sprintf("%d×%d×%d×%d", dims[3], dims[0], dims[1], dims[2])
+    }
+    return fmt.Sprintf("%d×%d×%d", dims[0], dims[1], dims[2])
+}
+
+// dotEscape escapes the characters that structure record labels
+func dotEscape(s string) string {
+    return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`).Replace(s)
+}
+
+// formatCount abbreviates n with a K, M or G suffix
+func formatCount(n int64) string {
+    switch {
+    case n >= 1e9:
+        return fmt.Sprintf("%.1fG", float64(n)/1e9)
+    case n >= 1e6:
+        return fmt.Sprintf("%.1fM", float64(n)/1e6)
+    case n >= 1e3:
+        return fmt.Sprintf("%.1fK", float64(n)/1e3)
+    }
+    return fmt.Sprintf("%d", n)
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+)
+
+/**
+* Embeddings
+
+The classifier of TinyCNN is its last conv layer, a 1×1 convolution from
+the features of maxpool3 to one channel per class. Whatever that layer
+separates linearly is what the network has learned to see, so the output
+of the layer before it makes a natural embedding of the image. Extract
+averages it over space, one value per channel:
+```
+image → conv1 ... conv6 → maxpool3 (4×4×128) → mean over 4×4 → 128 values
+                                     │
+                                     └→ conv7 (classifier) → global max pool → softmax
+```
+Any other layer with a spatial output may be named instead; a conv layer's
+output is taken after its batch norm and ReLU.
+*/
+
+// EmbeddingLayer returns the name of the layer Extract uses by default: the
+// last layer before the final conv layer
+func (cnn *TinyCNN) EmbeddingLayer() (string, error) {
+    layers := cnn.architecture.Layers
+    for i := len(layers) - 1; i > 0; i-- {
+        if layers[i].Type == ConvolutionLayer {
+            return layers[i-1].Name, nil
+        }
+    }
+    return "", fmt.Errorf("model has no layer before its last conv layer to take embeddings from")
+}
+
+// Extract runs imageData, in CHW order, up to the named layer and returns that
+// layer's output averaged over space, one value per channel
+// An empty layer selects EmbeddingLayer. ctx is checked before every layer.
+func (cnn *TinyCNN) Extract(ctx context.Context, imageData []float32, layer string) ([]float32, error) {
+    if layer == "" {
+        var err error
+        if layer, err = cnn.EmbeddingLayer(); err != nil {
+            return nil, err
+        }
+    }
+
+    arch := cnn.architecture
+    if arch.InputDepth > 0 {
+        return nil, fmt.Errorf("embeddings support 2D images only, the model takes %d frames", arch.InputDepth)
+    }
+    current, err := tensor.NewFeatureMapFromData(imageData, arch.InputHeight, arch.InputWidth, arch.InputChannels)
+    if err != nil {
+        return nil, fmt.Errorf("input size mismatch: %w", err)
+    }
+
+    for _, layerConfig := range arch.Layers {
+        if err := ctx.Err(); err != nil {
+            return nil, err
+        }
+        if current, err = cnn.RunLayer(layerConfig.Name, current); err != nil {
+            return nil, err
+        }
+        if layerConfig.Name == layer {
+            return channelMeans(current), nil
+        }
+    }
+    return nil, fmt.Errorf("no layer named %q", layer)
+}
+
+// channelMeans returns the mean of every channel of fm over its height and width
+func channelMeans(fm *tensor.FeatureMap) []float32 {
+    means := make([]float32, fm.Channels)
+    for c := range means {
+        var sum float64
+        for h := 0; h < fm.Height; h++ {
+            for w := 0; w < fm.Width; w++ {
+                sum += float64(fm.GetUnsafe(c, h, w))
+            }
+        }
+        means[c] = float32(sum / float64(fm.Height*fm.Width))
+    }
+    return means
+}
@@ -1,7 +1,11 @@
 package model
 
 import (
+	"context"
 	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/quant"
+	"duchm1606/gocnn/internal/tensor"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -124,13 +128,13 @@ func CreateConfiguredModel(config ModelConfig) (*TinyCNN, error) {
 // performExtendedValidation performs thorough model validation
 func performExtendedValidation(model *TinyCNN) error {
     // Test with a dummy input
-    dummyInput := make([]float32, 32*32*3)
+    dummyInput := make([]float32, model.architecture.InputSize())
     for i := range dummyInput {
         dummyInput[i] = 0.5 // Neutral test input
     }
     
     // Perform test inference
-    _, err := model.Predict(dummyInput)
+    _, err := model.Predict(context.Background(), dummyInput)
     if err != nil {
         return fmt.Errorf("test inference failed: %w", err)
     }
@@ -138,6 +142,143 @@ func performExtendedValidation(model *TinyCNN) error {
     return nil
 }
 
+// layerTypeNames maps the layer type strings used in YAML configs to LayerType
+// batch_norm and a misplaced softmax parse but are rejected by checkConfigLayerPlacement
+var layerTypeNames = map[string]LayerType{
+    "convolution":            ConvolutionLayer,
+    "convolution_1d":         Convolution1DLayer,
+    "convolution_3d":         Convolution3DLayer,
+    "max_pooling":            MaxPoolingLayer,
+    "global_max_pooling":     GlobalMaxPoolingLayer,
+    "global_average_pooling": GlobalAveragePoolingLayer,
+    "softmax":                SoftmaxLayer,
+    "batch_norm":             BatchNormLayer,
+    "custom":                 CustomLayer,
+}
+
+// ParseLayerType converts a config layer type string to a LayerType
+func ParseLayerType(name string) (LayerType, error) {
+    layerType, ok := layerTypeNames[name]
+    if !ok {
+        return 0, fmt.Errorf("unknown layer type: %q", name)
+    }
+    return layerType, nil
+}
+
+// ArchitectureFromConfig builds an architecture from the model section of a config file
+// Configs without layers get the standard TinyCNN layer stack
+func ArchitectureFromConfig(mc config.ModelConfig) (*TinyCNNArchitecture, error) {
+    arch := GetTinyCNNArchitecture()
+    if mc.InputHeight > 0 {
+        arch.InputHeight = mc.InputHeight
+    }
+    if mc.InputWidth > 0 {
+        arch.InputWidth = mc.InputWidth
+    }
+    if mc.InputChannels > 0 {
+        arch.InputChannels = mc.InputChannels
+    }
+    if mc.NumClasses > 0 {
+        arch.NumClasses = mc.NumClasses
+    }
+    arch.InputDepth = mc.InputDepth
+    
+    if len(mc.Layers) == 0 {
+        return arch, nil
+    }
+    
+    arch.Layers = make([]LayerConfig, len(mc.Layers))
+    for i, layer := range mc.Layers {
+        layerType, err := ParseLayerType(layer.Type)
+        if err != nil {
+            return nil, fmt.Errorf("layer %d (%s): %w", i, layer.Name, err)
+        }
+        if err := checkConfigLayerPlacement(mc.Layers, i, layerType); err != nil {
+            return nil, fmt.Errorf("layer %d (%s): %w", i, layer.Name, err)
+        }
+        
+        arch.Layers[i] = LayerConfig{
+            Type:            layerType,
+            Name:            layer.Name,
+            KernelSize:      layer.KernelSize,
+            Filters:         layer.Filters,
+            Stride:          layer.Stride,
+            Padding:         layer.Padding,
+            PoolSize:        layer.PoolSize,
+            PoolStride:      layer.PoolStride,
+            ApplyBatchNorm:  layer.ApplyBatchNorm,
+            ApplyActivation: layer.ApplyActivation,
+            CustomOp:        layer.Op,
+            CustomParams:    layer.Params,
+        }
+    }
+    
+    return arch, nil
+}
+
+// checkConfigLayerPlacement rejects the layer types Predict has no step for where
+// layers[i] puts them: batch_norm anywhere, and softmax other than last after global pooling
+func checkConfigLayerPlacement(layers []config.LayerConfig, i int, layerType LayerType) error {
+    switch layerType {
+    case BatchNormLayer:
+        err := fmt.Errorf("batch_norm is not a layer of its own; it is fused into convolution via apply_batch_norm")
+        return errs.WithHint(err, "remove the layer and set apply_batch_norm: true on the convolution before it")
+        
+    case SoftmaxLayer:
+        previous := ""
+        if i > 0 {
+            previous = layers[i-1].Type
+        }
+        if i != len(layers)-1 || (previous != "global_max_pooling" && previous != "global_average_pooling") {
+            err := fmt.Errorf("softmax can only be the last layer, directly after global pooling")
+            return errs.WithHint(err, "Predict applies softmax to the global pooling output itself; "+
+                "move the layer to the end after global_max_pooling or global_average_pooling, or remove it")
+        }
+    }
+    return nil
+}
+
+// NewTinyCNNFromConfig creates a model whose architecture comes from the config file
+func NewTinyCNNFromConfig(weightsPath string, mc config.ModelConfig) (*TinyCNN, error) {
+    arch, err := ArchitectureFromConfig(mc)
+    if err != nil {
+        return nil, fmt.Errorf("invalid architecture in config: %w", err)
+    }
+    
+    precision, err := tensor.ParsePrecision(mc.Precision)
+    if err != nil {
+        return nil, fmt.Errorf("invalid precision in config: %w", err)
+    }
+    
+    granularity, err := quant.ParseGranularity(mc.WeightQuantization)
+    if err != nil {
+        return nil, fmt.Errorf("invalid weight quantization in config: %w", err)
+    }
+    
+    activationMode, err := quant.ParseActivationMode(mc.ActivationQuantization)
+    if err != nil {
+        return nil, fmt.Errorf("invalid activation quantization in config: %w", err)
+    }
+    
+    cnn, err := NewTinyCNNWithArchitecture(weightsPath, arch)
+    if err != nil {
+        return nil, err
+    }
+    if err := cnn.SetPrecision(precision); err != nil {
+        return nil, err
+    }
+    // Weights loaded from quantized files stay int8 unless the config asks for a granularity
+    if granularity != quant.None {
+        if err := cnn.SetWeightQuantization(granularity); err != nil {
+            return nil, err
+        }
+    }
+    if err := cnn.SetActivationQuantization(activationMode); err != nil {
+        return nil, err
+    }
+    return cnn, nil
+}
+
 // GetSupportedArchitectures returns a list of supported model architectures
 func GetSupportedArchitectures() []string {
     return []string{
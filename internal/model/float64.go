@@ -0,0 +1,186 @@
+package model
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/ops"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"time"
+)
+
+/**
+* Double-precision inference
+
+With tensor.PrecisionFloat64 the weights stay as loaded, but Predict widens
+the input and every layer's kernel to float64 and runs the generic reference
+ops (ops.Conv2DFusedOf and friends) instead of the convolution engine:
+```
+image ──float64──▶ conv ▶ pool ▶ ... ▶ global pool ▶ softmax ──float32──▶ PredictionResult
+         exact      every sum, BN fold and average in float64   rounded once
+```
+Comparing its logits or activation dumps with a float32 run of the same
+weights shows how much of a discrepancy is float32 accumulation error. It
+is a debugging mode: the reference ops are unoptimized, batches run one
+image at a time and custom layers, which only take float32, are not
+supported. Activation dumps and statistics see each layer's output rounded
+to float32.
+*/
+
+// predictFloat64 is Predict in double precision; the caller holds weightsMu
+func (cnn *TinyCNN) predictFloat64(ctx context.Context, imageData []float32, layerTimes map[string]time.Duration,
+    allocs *layerAllocTracker, startTime time.Time) (*PredictionResult, error) {
+
+    arch := cnn.architecture
+    current := tensor.FeatureMapAs[float64](&tensor.FeatureMap{
+        Height:   arch.stackedInputHeight(),
+        Width:    arch.InputWidth,
+        Channels: arch.InputChannels,
+        Data:     imageData,
+    })
+
+    sample := 0
+    if cnn.activationDump != nil {
+        sample = cnn.activationDump.NextSample()
+    }
+
+    convLayerIdx := 0
+    depth := arch.InputDepth // Frames stacked in current, for 3D convolutions
+    for i, layerConfig := range arch.Layers {
+        if err := ctx.Err(); err != nil {
+            return nil, err
+        }
+
+        layerStart := time.Now()
+        allocs.begin()
+
+        switch layerConfig.Type {
+        case ConvolutionLayer:
+            var err error
+            current, err = cnn.processConvolutionLayerFloat64(current, layerConfig, convLayerIdx)
+            if err != nil {
+                return nil, fmt.Errorf("failed at layer %d (%s): %w", i, layerConfig.Name, err)
+            }
+            convLayerIdx++
+
+        case Convolution1DLayer, Convolution3DLayer:
+            var err error
+            current, depth, err = convolutionNDOf(current, cnn.weights.ConvND[layerConfig.Name], layerConfig, depth)
+            if err != nil {
+                return nil, fmt.Errorf("failed at layer %d (%s): %w", i, layerConfig.Name, err)
+            }
+
+        case MaxPoolingLayer:
+            current = ops.Pooling2DOf(current, ops.PoolingConfig{
+                KernelSize: layerConfig.PoolSize,
+                Stride:     layerConfig.PoolStride,
+                Type:       ops.MaxPooling,
+            })
+
+        case GlobalMaxPoolingLayer, GlobalAveragePoolingLayer:
+            logits := globalPoolingFloat64(current, layerConfig)
+            allocs.end(layerConfig.Name)
+            err := cnn.observeVectors([]int{sample}, layerConfig.Name, [][]float32{tensor.ConvertSlice[float32](logits)})
+            if err != nil {
+                return nil, err
+            }
+
+            softmaxStart := time.Now()
+            allocs.begin()
+            probabilities := tensor.ConvertSlice[float32](ops.SoftmaxOf(logits))
+            layerTimes["softmax"] = time.Since(softmaxStart)
+            allocs.end("softmax")
+            if err := cnn.observeVectors([]int{sample}, cnn.softmaxName(), [][]float32{probabilities}); err != nil {
+                return nil, err
+            }
+            return cnn.newPredictionResult(probabilities, layerTimes, allocs, startTime), nil
+
+        default:
+            return nil, fmt.Errorf("layer %d (%s): %s precision supports conv, max pooling and global pooling layers only",
+                i, layerConfig.Name, tensor.PrecisionFloat64)
+        }
+
+        layerTimes[layerConfig.Name] = time.Since(layerStart)
+        allocs.end(layerConfig.Name)
+
+        if err := cnn.observeFloat64(sample, layerConfig.Name, current); err != nil {
+            return nil, err
+        }
+    }
+
+    return nil, fmt.Errorf("model did not reach final layer")
+}
+
+// processConvolutionLayerFloat64 is processConvolutionLayer in double precision
+func (cnn *TinyCNN) processConvolutionLayerFloat64(input *tensor.FeatureMapOf[float64], config LayerConfig,
+    layerIdx int) (*tensor.FeatureMapOf[float64], error) {
+
+    if layerIdx >= len(cnn.weights.Kernels) {
+        return nil, fmt.Errorf("kernel index %d out of range", layerIdx)
+    }
+    bn, applyReLU := cnn.convEpilogue(config, layerIdx)
+    convConfig := ops.Conv2DConfig{
+        Padding: config.Padding,
+        Stride:  config.Stride,
+    }
+    kernel := tensor.KernelAs[float64](cnn.weights.Kernels[layerIdx])
+    return ops.Conv2DFusedOf(input, kernel, cnn.weights.Biases[layerIdx], bn, applyReLU, convConfig), nil
+}
+
+// globalPoolingFloat64 is processGlobalPoolingLayer in double precision
+func globalPoolingFloat64(input *tensor.FeatureMapOf[float64], layerConfig LayerConfig) []float64 {
+    if layerConfig.Type == GlobalAveragePoolingLayer {
+        return ops.GlobalAvgPoolingOf(input)
+    }
+    return ops.GlobalMaxPoolingOf(input)
+}
+
+// observeFloat64 is observe for a double-precision output, which is rounded to
+// float32 only when the dump or statistics want it
+func (cnn *TinyCNN) observeFloat64(sample int, layer string, output *tensor.FeatureMapOf[float64]) error {
+    if cnn.activationStats == nil && (cnn.activationDump == nil || !cnn.activationDump.Wants(layer)) {
+        return nil
+    }
+    return cnn.observe(sample, layer, output.FeatureMap())
+}
+
+// runLayerFloat64 is RunLayer in double precision: input is widened, and the
+// output rounded back to float32 once; depth is the frame count of a 3D conv input
+func (cnn *TinyCNN) runLayerFloat64(layerConfig LayerConfig, convIdx, depth int, 
+    input *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    
+    wide := tensor.FeatureMapAs[float64](input)
+
+    switch layerConfig.Type {
+    case ConvolutionLayer:
+        output, err := cnn.processConvolutionLayerFloat64(wide, layerConfig, convIdx)
+        if err != nil {
+            return nil, err
+        }
+        return output.FeatureMap(), nil
+
+    case Convolution1DLayer, Convolution3DLayer:
+        if wide.Layout != tensor.LayoutCHW {
+            wide = tensor.FeatureMapAs[float64](input.ToLayout(tensor.LayoutCHW))
+        }
+        output, _, err := convolutionNDOf(wide, cnn.weights.ConvND[layerConfig.Name], layerConfig, depth)
+        if err != nil {
+            return nil, err
+        }
+        return output.FeatureMap(), nil
+
+    case MaxPoolingLayer:
+        return ops.Pooling2DOf(wide, ops.PoolingConfig{
+            KernelSize: layerConfig.PoolSize,
+            Stride:     layerConfig.PoolStride,
+            Type:       ops.MaxPooling,
+        }).FeatureMap(), nil
+
+    case GlobalMaxPoolingLayer, GlobalAveragePoolingLayer:
+        return vectorFeatureMap(tensor.ConvertSlice[float32](globalPoolingFloat64(wide, layerConfig))), nil
+
+    case SoftmaxLayer:
+        return vectorFeatureMap(tensor.ConvertSlice[float32](ops.SoftmaxOf(wide.Data))), nil
+    }
+    return nil, fmt.Errorf("%s precision supports conv, max pooling, global pooling and softmax layers only",
+        tensor.PrecisionFloat64)
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"runtime/metrics"
+)
+
+/**
+* Allocation tracking
+
+Inference should allocate little once the engine's buffer pools are warm;
+a change that makes a layer allocate its output every call shows up as GC
+time long before it shows up as latency. With memory tracking on, Predict
+reads the process's cumulative heap allocation counters around every layer
+and reports the difference:
+```
+conv1   allocs  [before ─── layer ─── after]  → LayerAllocs["conv1"] = after - before
+```
+The counters come from runtime/metrics, which unlike runtime.ReadMemStats
+doesn't stop the world, so reading them per layer is cheap. They are
+process-wide: with several predictions running at once, each one's numbers
+include what the others allocated meanwhile, so exact per-layer figures
+need a single worker. The runtime also counts small objects a span at a
+time, so deltas of a few kilobytes are coarse.
+*/
+
+// Cumulative heap allocation metrics of the runtime
+var allocMetrics = []string{"/gc/heap/allocs:bytes", "/gc/heap/allocs:objects"}
+
+// AllocCounters are heap allocation counts, in bytes and objects
+type AllocCounters struct {
+    Bytes   uint64 `json:"bytes"`
+    Objects uint64 `json:"objects"`
+}
+
+// ReadAllocCounters returns the process's cumulative heap allocations so far
+func ReadAllocCounters() AllocCounters {
+    samples := []metrics.Sample{{Name: allocMetrics[0]}, {Name: allocMetrics[1]}}
+    metrics.Read(samples)
+    var counters AllocCounters
+    if samples[0].Value.Kind() == metrics.KindUint64 {
+        counters.Bytes = samples[0].Value.Uint64()
+    }
+    if samples[1].Value.Kind() == metrics.KindUint64 {
+        counters.Objects = samples[1].Value.Uint64()
+    }
+    return counters
+}
+
+// Sub returns the allocations between earlier and a
+func (a AllocCounters) Sub(earlier AllocCounters) AllocCounters {
+    return AllocCounters{Bytes: a.Bytes - earlier.Bytes, Objects: a.Objects - earlier.Objects}
+}
+
+// Add returns the sum of a and other
+func (a AllocCounters) Add(other AllocCounters) AllocCounters {
+    return AllocCounters{Bytes: a.Bytes + other.Bytes, Objects: a.Objects + other.Objects}
+}
+
+// SetMemoryTracking makes every prediction report the heap allocations of each of
+// its layers in PredictionResult.LayerAllocs
+func (cnn *TinyCNN) SetMemoryTracking(on bool) {
+    cnn.trackMemory.Store(on)
+}
+
+// layerAllocTracker measures the allocations of one prediction's layers; the zero
+// value, used when tracking is off, measures nothing
+type layerAllocTracker struct {
+    allocs map[string]AllocCounters
+    start  AllocCounters
+}
+
+// newLayerAllocTracker returns a tracker that measures if cnn tracks memory
+func (cnn *TinyCNN) newLayerAllocTracker() *layerAllocTracker {
+    if !cnn.trackMemory.Load() {
+        return &layerAllocTracker{}
+    }
+    return &layerAllocTracker{allocs: make(map[string]AllocCounters)}
+}
+
+// begin marks the start of a layer
+func (t *layerAllocTracker) begin() {
+    if t.allocs != nil {
+        t.start = ReadAllocCounters()
+    }
+}
+
+// end records the allocations of layer since begin
+func (t *layerAllocTracker) end(layer string) {
+    if t.allocs != nil {
+        t.allocs[layer] = ReadAllocCounters().Sub(t.start)
+    }
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"context"
+)
+
+/**
+* Predictor
+
+The evaluator, the CLIs and the soak test only ever classify images and
+describe the model that did it. Predictor is that much of TinyCNN, so a
+different architecture or a model backed by something else plugs into all
+of them by implementing three methods:
+```
+Predict(ctx, image)        one CHW float32 image   -> *PredictionResult
+PredictBatch(ctx, images)  several images at once  -> one result per image
+Info()                     architecture, parameters, precision, engine, counters
+```
+Anything beyond that (loading, autotuning, warm-up, running single layers)
+stays on the concrete type; code that needs it asks for it with a type
+assertion, as metrics.CompareModels does for RunLayer.
+*/
+
+// Predictor is a model that classifies images
+// Implementations must allow Predict and PredictBatch from several goroutines at once.
+type Predictor interface {
+    // Predict classifies one image given in CHW order
+    Predict(ctx context.Context, imageData []float32) (*PredictionResult, error)
+    // PredictBatch classifies several images, returning one result per image in order
+    PredictBatch(ctx context.Context, images [][]float32) ([]*PredictionResult, error)
+    // Info describes the model and its performance counters
+    Info() *ModelInfo
+}
+
+// TinyCNN is a Predictor
+var _ Predictor = (*TinyCNN)(nil)
+
+// Info returns the model information of GetModelInfo
+func (cnn *TinyCNN) Info() *ModelInfo {
+    return cnn.GetModelInfo()
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"duchm1606/gocnn/internal/quant"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+)
+
+/**
+* Hot weight reload
+
+A service that runs for days should pick up a retrained model without
+restarting, which would drop its warm buffer pools, autotuned algorithms and
+in-flight requests. ReloadWeights loads the new weight set next to the old
+one and swaps it in:
+```
+ReloadWeights:  load + convert + validate new set     (no lock; Predict keeps running)
+                weightsMu.Lock()   ── waits for in-flight predictions on the old set
+                swap weights, half kernels, custom arrays, activation scales
+                weightsMu.Unlock() ── predictions that were waiting run on the new set
+```
+Every prediction holds weightsMu for reading from its first layer to its
+last, so it never sees half of one set and half of the other. The write lock
+is held only for the swap itself; a prediction started while the reload waits
+is delayed by at most the one in flight before it.
+
+The new set is converted to the model's current precision and weight
+quantization before the swap, and takes the activation scales of its own
+bundle (calibrated ranges of the old weights would be wrong for the new ones).
+The engine, its tuning and the architecture are kept: the new kernels must
+have the shapes the architecture declares.
+*/
+
+// ReloadWeights loads the weights in path and swaps them in for the current ones
+// Predictions already running finish on the old weights; later ones use the new.
+// Predict, PredictBatch, PredictTopK, RunLayer, GetModelInfo and WeightQuantization
+// may run concurrently with it. On error the model keeps its current weights.
+func (cnn *TinyCNN) ReloadWeights(path string) error {
+    fresh, err := NewTinyCNNWithArchitecture(path, cnn.architecture)
+    if err != nil {
+        return fmt.Errorf("failed to reload weights: %w", err)
+    }
+
+    // Bring the new set to the representation the model runs in
+    cnn.weightsMu.RLock()
+    precision, granularity := cnn.precision, cnn.weightQuantization()
+    ready := cnn.ready.Load()
+    cnn.weightsMu.RUnlock()
+
+    if granularity != quant.None && fresh.WeightQuantization() == quant.None {
+        if err := fresh.SetWeightQuantization(granularity); err != nil {
+            return fmt.Errorf("failed to reload weights: %w", err)
+        }
+    }
+    if precision != tensor.PrecisionFloat32 {
+        if err := fresh.SetPrecision(precision); err != nil {
+            return fmt.Errorf("failed to reload weights: %w", err)
+        }
+    }
+    if err := fresh.ValidateModel(); err != nil {
+        return fmt.Errorf("reloaded weights are invalid: %w", err)
+    }
+
+    // A warmed-up model stays warm: fault the new pages in before serving from them
+    if ready {
+        fresh.touchWeightPages(&WarmupStats{})
+    }
+
+    cnn.weightsMu.Lock()
+    defer cnn.weightsMu.Unlock()
+    cnn.weights = fresh.weights
+    cnn.customWeights = fresh.customWeights
+    cnn.halfKernels = fresh.halfKernels
+    cnn.convInputScales = fresh.convInputScales
+    return nil
+}
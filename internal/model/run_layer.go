@@ -0,0 +1,147 @@
+package model
+
+import (
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/ops"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+)
+
+// RunLayer executes exactly one configured layer, with its loaded weights, on input
+// The input may have any spatial size but must have the channel count the layer's
+// weights expect. Global pooling and softmax layers return a 1×1×C feature map, and
+// 3D conv layers take the number of frames stacked in input from the architecture.
+// The result is a new tensor owned by the caller and input is left unmodified. In
+// float16 mode input and output are rounded as they would be inside Predict; in
+// float64 mode the layer runs in double precision and its output is rounded to float32.
+func (cnn *TinyCNN) RunLayer(name string, input *tensor.FeatureMap) (*tensor.FeatureMap, error) {
+    if input == nil {
+        return nil, fmt.Errorf("layer %s: input is nil", name)
+    }
+
+    layerIdx, convIdx := -1, 0
+    for i, layer := range cnn.architecture.Layers {
+        if layer.Name == name {
+            layerIdx = i
+            break
+        }
+        if layer.Type == ConvolutionLayer {
+            convIdx++
+        }
+    }
+    if layerIdx < 0 {
+        return nil, fmt.Errorf("no layer named %q", name)
+    }
+    layerConfig := cnn.architecture.Layers[layerIdx]
+
+    cnn.weightsMu.RLock()
+    defer cnn.weightsMu.RUnlock()
+
+    channels := -1
+    switch layerConfig.Type {
+    case ConvolutionLayer:
+        channels = cnn.kernelChannels(convIdx)
+    case Convolution1DLayer, Convolution3DLayer:
+        channels = cnn.convNDChannels(name)
+    }
+    if channels >= 0 && input.Channels != channels {
+        err := fmt.Errorf("layer %s expects %d input channels, got %d", name, channels, input.Channels)
+        return nil, errs.WithHint(err, "feed %s the output of the layer before it in the architecture", name)
+    }
+
+    depth := 0
+    if layerConfig.Type == Convolution3DLayer {
+        dimensions, err := cnn.architecture.GetOutputDimensions()
+        if err != nil {
+            return nil, fmt.Errorf("layer %s: %w", name, err)
+        }
+        depth = dimensions[layerIdx][3]
+    }
+
+    if cnn.precision == tensor.PrecisionFloat64 {
+        output, err := cnn.runLayerFloat64(layerConfig, convIdx, depth, input)
+        if err != nil {
+            return nil, fmt.Errorf("layer %s: %w", name, err)
+        }
+        return output, nil
+    }
+    if cnn.precision != tensor.PrecisionFloat32 {
+        input = input.Clone()
+        cnn.precision.Round(input.Data)
+    }
+
+    var output *tensor.FeatureMap
+
+    switch layerConfig.Type {
+    case ConvolutionLayer:
+        pooled, err := cnn.processConvolutionLayer(input, layerConfig, convIdx)
+        if err != nil {
+            return nil, fmt.Errorf("layer %s: %w", name, err)
+        }
+
+        // The engine's output buffer is recycled; hand the caller its own copy
+        output = pooled.Clone()
+        cnn.convEngine.Release(pooled)
+
+    case Convolution1DLayer, Convolution3DLayer:
+        var err error
+        output, _, err = cnn.processConvolutionNDLayer(input, layerConfig, depth)
+        if err != nil {
+            return nil, fmt.Errorf("layer %s: %w", name, err)
+        }
+
+    case MaxPoolingLayer:
+        var err error
+        output, err = cnn.processMaxPoolingLayer(input, layerConfig)
+        if err != nil {
+            return nil, fmt.Errorf("layer %s: %w", name, err)
+        }
+
+    case CustomLayer:
+        var err error
+        output, err = cnn.processCustomLayer(input, layerConfig)
+        if err != nil {
+            return nil, fmt.Errorf("layer %s: %w", name, err)
+        }
+        if output == input {
+            output = input.Clone()
+        }
+
+    case GlobalMaxPoolingLayer, GlobalAveragePoolingLayer:
+        values, err := cnn.processGlobalPoolingLayer(input, layerConfig)
+        if err != nil {
+            return nil, fmt.Errorf("layer %s: %w", name, err)
+        }
+        output = vectorFeatureMap(values)
+
+    case SoftmaxLayer:
+        output = vectorFeatureMap(ops.Softmax(input.Data))
+
+    default:
+        return nil, fmt.Errorf("layer %s: unsupported layer type: %s", name, layerConfig.Type)
+    }
+
+    cnn.precision.Round(output.Data)
+    return output, nil
+}
+
+// kernelChannels returns the input channel count of the conv layer's kernel
+func (cnn *TinyCNN) kernelChannels(convIdx int) int {
+    if convIdx >= len(cnn.weights.Kernels) {
+        return 0
+    }
+    if kernel := cnn.weights.Kernels[convIdx]; kernel != nil {
+        return kernel.Channels
+    }
+    if cnn.halfKernels != nil {
+        return cnn.halfKernels[convIdx].Channels
+    }
+    return cnn.weights.QuantKernels[convIdx].Channels
+}
+
+// vectorFeatureMap wraps per-channel values in a 1×1×C feature map
+func vectorFeatureMap(values []float32) *tensor.FeatureMap {
+    fm := tensor.NewFeatureMap(1, 1, len(values))
+    copy(fm.Data, values)
+    return fm
+}
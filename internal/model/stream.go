@@ -0,0 +1,106 @@
+package model
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+/**
+* Streaming prediction
+
+Camera feeds and queue consumers produce images one at a time and want
+results as soon as possible, in the order the images came. Calling Predict
+in a loop uses one core; spreading the calls over goroutines means
+re-writing the same worker pool and re-ordering in every consumer.
+PredictStream does both:
+```
+in ──> dispatcher ──> jobs ──> workers (Predict)
+           │                       │
+           └──> pending <──────────┘ one result slot per image, in input order
+                   │
+                collector ──> out
+```
+The pending queue works like the data.Prefetcher's: each image gets a
+result slot when it is read, the collector waits on the slots in order, and
+the queue's capacity bounds how many images are in flight. A slow image
+holds back the results behind it but not the predictions.
+
+A failed image still yields a result, with Err set and PredictedClass -1, so
+the i-th result always belongs to the i-th image. Once ctx is done no more
+images are read, predictions in flight stop at their next layer, and out is
+closed; results not yet delivered are dropped.
+*/
+
+// PredictStream classifies every image received on in with a pool of workers and
+// sends the results on the returned channel in input order. The channel is closed
+// after in is closed and every result is delivered, or once ctx is done. The
+// caller must read it until it is closed or cancel ctx, or the workers never exit.
+func (cnn *TinyCNN) PredictStream(ctx context.Context, in <-chan []float32) <-chan *PredictionResult {
+    workers := runtime.GOMAXPROCS(0)
+    out := make(chan *PredictionResult, workers)
+    pending := make(chan chan *PredictionResult, workers)
+
+    type job struct {
+        image  []float32
+        result chan *PredictionResult
+    }
+    jobs := make(chan job, workers)
+
+    var wg sync.WaitGroup
+    for w := 0; w < workers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for j := range jobs {
+                result, err := cnn.Predict(ctx, j.image)
+                if err != nil {
+                    result = &PredictionResult{PredictedClass: -1, Err: err}
+                }
+                j.result <- result
+            }
+        }()
+    }
+
+    go func() {
+        defer close(pending)
+        defer close(jobs)
+        for {
+            var image []float32
+            select {
+            case next, ok := <-in:
+                if !ok {
+                    return
+                }
+                image = next
+            case <-ctx.Done():
+                return
+            }
+
+            // Result slots have room for one value, so workers never block on them
+            result := make(chan *PredictionResult, 1)
+            select {
+            case pending <- result:
+            case <-ctx.Done():
+                return
+            }
+            jobs <- job{image: image, result: result}
+        }
+    }()
+
+    go func() {
+        defer close(out)
+        defer wg.Wait()
+        for slot := range pending {
+            // Workers finish quickly once ctx is done, so waiting on the slot is bounded
+            result := <-slot
+            select {
+            case out <- result:
+            case <-ctx.Done():
+                return
+            }
+        }
+    }()
+
+    return out
+}
@@ -1,22 +1,88 @@
 package model
 
 import (
+	"context"
 	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/dump"
+	"duchm1606/gocnn/internal/errs"
 	"duchm1606/gocnn/internal/ops"
+	"duchm1606/gocnn/internal/quant"
 	"duchm1606/gocnn/internal/tensor"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // TinyCNN represents the complete CNN model
+// Predict may be called from several goroutines at once; the Set* methods that
+// change weights, precision or layout may not run concurrently with it, while
+// ReloadWeights may.
 type TinyCNN struct {
     architecture  *TinyCNNArchitecture
+    weightsMu     sync.RWMutex // Held for reading by every prediction; ReloadWeights swaps the weights under it
     weights       *data.ModelWeights
+    customWeights map[string]map[string][]float32 // Custom layer arrays by layer then array name
     convEngine    *ops.ConvolutionEngine
+    layout        tensor.Layout // Memory layout of intermediate feature maps
+    precision     tensor.Precision    // Storage precision of weights and activations
+    halfKernels   []*tensor.HalfKernel // Conv kernels in float16 mode (weights.Kernels entries are nil then)
+    convInputScales []float32          // Static int8 scale of each conv layer's input (0 = dequantize the kernel instead)
+    activationMode quant.ActivationMode // Where int8 conv layers take their input scale from
+    activationDump *dump.Writer        // Receives per-layer outputs during Predict when set
+    activationStats *dump.StatsCollector // Summarizes per-layer outputs during Predict when set
     
     // Performance tracking
-    layerTimes    map[string]time.Duration
-    totalInferences int64
+    stats         performanceStats
+    ready         atomic.Bool // Set once Warmup has run
+    trackMemory   atomic.Bool // Whether predictions measure their layers' allocations
+}
+
+// performanceStats accumulates layer times over the predictions of a model
+// Every Predict times its layers in its own map and merges it here once at the end,
+// so the lock is taken once per inference, not once per layer.
+type performanceStats struct {
+    mu          sync.Mutex
+    inferences  int64
+    layerTimes  map[string]time.Duration
+}
+
+// record adds the layer times of one prediction
+func (s *performanceStats) record(layerTimes map[string]time.Duration) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.layerTimes == nil {
+        s.layerTimes = make(map[string]time.Duration)
+    }
+    s.inferences++
+    for layerName, layerTime := range layerTimes {
+        s.layerTimes[layerName] += layerTime
+    }
+}
+
+// averages returns the number of predictions recorded and the average time of each layer
+func (s *performanceStats) averages() (int64, map[string]time.Duration) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    avgTimes := make(map[string]time.Duration)
+    if s.inferences == 0 {
+        return 0, avgTimes
+    }
+    for layerName, totalTime := range s.layerTimes {
+        avgTimes[layerName] = time.Duration(int64(totalTime) / s.inferences)
+    }
+    return s.inferences, avgTimes
+}
+
+// reset forgets every recorded prediction
+func (s *performanceStats) reset() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.inferences = 0
+    s.layerTimes = nil
 }
 
 // PredictionResult holds the result of a single inference
@@ -26,22 +92,68 @@ type PredictionResult struct {
     Confidence       float32           // Confidence score (max probability)
     LayerTimes       map[string]time.Duration // Time spent in each layer type
     TotalTime        time.Duration     // Total inference time
+    LayerAllocs      map[string]AllocCounters // Heap allocations of each layer; only with SetMemoryTracking
+    Engine           ops.EngineOptions // Convolution engine settings that produced the timings
+    Err              error             // Why the image could not be classified; only set by PredictStream
+}
+
+// ClassProbability is one class of a prediction and its probability
+type ClassProbability struct {
+    Class       int
+    Probability float32
+}
+
+// TopK returns the k most probable classes, most probable first
+// Classes of equal probability keep index order; k beyond the class count returns all.
+func (r *PredictionResult) TopK(k int) []ClassProbability {
+    indices := ops.ArgmaxTopK(r.Probabilities, k)
+    top := make([]ClassProbability, len(indices))
+    for i, class := range indices {
+        top[i] = ClassProbability{Class: class, Probability: r.Probabilities[class]}
+    }
+    return top
 }
 
 // NewTinyCNN creates a new TinyCNN model
 func NewTinyCNN(weightsPath string) (*TinyCNN, error) {
-    // Load architecture
-    arch := GetTinyCNNArchitecture()
+    return NewTinyCNNWithArchitecture(weightsPath, GetTinyCNNArchitecture())
+}
+
+// NewTinyCNNWithArchitecture creates a model for a custom layer stack
+// Convolution weights are loaded as for TinyCNN; custom layers load the arrays
+// declared in their ops.CustomLayerSpec from their own layer directory
+func NewTinyCNNWithArchitecture(weightsPath string, arch *TinyCNNArchitecture) (*TinyCNN, error) {
+    // Validate architecture
     err := arch.ValidateArchitecture()
     if err != nil {
         return nil, fmt.Errorf("invalid architecture: %w", err)
     }
     
-    // Load model weights
-    dataManager := data.NewDataManager(weightsPath, data.BinaryFloat32, data.OneHotText)
-    weights, err := dataManager.LoadModelWeights()
+    // Load model weights; models without 2D conv layers, such as 1D audio models, have no conv1..conv7
+    weights := &data.ModelWeights{}
+    if arch.hasLayer(ConvolutionLayer) {
+        dataManager := data.NewDataManager(weightsPath, data.BinaryFloat32, data.OneHotText)
+        weights, err = dataManager.LoadModelWeights()
+        if err != nil {
+            return nil, fmt.Errorf("failed to load model weights: %w", err)
+        }
+    }
+    
+    customWeights, customTimes, err := loadCustomWeights(weightsPath, arch)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load custom layer weights: %w", err)
+    }
+    weights.LoadTimes = append(weights.LoadTimes, customTimes...)
+    
+    convND, convNDTimes, err := loadConvNDWeights(weightsPath, arch)
     if err != nil {
-        return nil, fmt.Errorf("failed to load model weights: %w", err)
+        return nil, fmt.Errorf("failed to load 1D and 3D conv weights: %w", err)
+    }
+    weights.ConvND = convND
+    weights.LoadTimes = append(weights.LoadTimes, convNDTimes...)
+    
+    if err := checkKernelShapes(arch, weights); err != nil {
+        return nil, err
     }
     
     // Create convolution engine
@@ -50,72 +162,595 @@ func NewTinyCNN(weightsPath string) (*TinyCNN, error) {
     model := &TinyCNN{
         architecture:    arch,
         weights:         weights,
+        customWeights:   customWeights,
         convEngine:      convEngine,
-        layerTimes:      make(map[string]time.Duration),
-        totalInferences: 0,
     }
     
+    // A quantized bundle carries the calibrated activation ranges for the int8 conv path
+    ranges, err := readActivationRanges(weightsPath)
+    if err != nil {
+        return nil, err
+    }
+    model.SetActivationScales(ranges)
+    
     return model, nil
 }
 
+// NewTinyCNNWithWeights creates a model for arch that runs on weights held in
+// memory, such as weights being trained. The model shares weights rather than
+// copying them: updates made between predictions are seen by the next one.
+// Architectures with custom layers need NewTinyCNNWithArchitecture.
+func NewTinyCNNWithWeights(arch *TinyCNNArchitecture, weights *data.ModelWeights) (*TinyCNN, error) {
+    if err := arch.ValidateArchitecture(); err != nil {
+        return nil, fmt.Errorf("invalid architecture: %w", err)
+    }
+    for _, layer := range arch.Layers {
+        if layer.Type == CustomLayer {
+            return nil, fmt.Errorf("layer %s: custom layers load their weights from a directory", layer.Name)
+        }
+    }
+    if err := checkKernelShapes(arch, weights); err != nil {
+        return nil, err
+    }
+    
+    return &TinyCNN{
+        architecture:  arch,
+        weights:       weights,
+        customWeights: make(map[string]map[string][]float32),
+        convEngine:    ops.NewConvolutionEngine(),
+    }, nil
+}
+
+// readActivationRanges returns the calibrated activation ranges of a quantized bundle
+// directory, or nil when weightsPath has no bundle manifest
+func readActivationRanges(weightsPath string) ([]quant.ActivationRange, error) {
+    if _, err := os.Stat(filepath.Join(weightsPath, quant.BundleManifestName)); os.IsNotExist(err) {
+        return nil, nil
+    }
+    
+    bundle, err := quant.ReadBundle(weightsPath)
+    if err != nil {
+        return nil, errs.WithHint(err, "recreate the bundle with gocnn-quantize, or remove %s to run "+
+            "without calibrated activation scales", quant.BundleManifestName)
+    }
+    return bundle.Activations, nil
+}
+
+// checkKernelShapes reports the first conv layer whose loaded kernel does not fit the architecture
+func checkKernelShapes(arch *TinyCNNArchitecture, weights *data.ModelWeights) error {
+    dimensions, err := arch.GetOutputDimensions()
+    if err != nil {
+        return fmt.Errorf("invalid architecture: %w", err)
+    }
+    
+    convIdx := 0
+    for i, layer := range arch.Layers {
+        if layer.Type == Convolution1DLayer || layer.Type == Convolution3DLayer {
+            if err := checkConvNDShape(layer, dimensions[i][2], weights.ConvND[layer.Name]); err != nil {
+                return err
+            }
+            continue
+        }
+        if layer.Type != ConvolutionLayer || convIdx >= len(weights.Kernels) {
+            continue
+        }
+        kernel := weights.Kernels[convIdx]
+        if weights.IsQuantized(convIdx) {
+            qk := weights.QuantKernels[convIdx]
+            kernel = &tensor.Kernel{Size: qk.Size, Channels: qk.Channels, Filters: qk.Filters}
+        }
+        convIdx++
+        
+        inputChannels := dimensions[i][2]
+        
+        if kernel.Channels != inputChannels {
+            err := fmt.Errorf("layer %s expects %d input channels, but its weights have %d", 
+                layer.Name, inputChannels, kernel.Channels)
+            return errs.WithHint(err, "the config's layers do not match the weights; check input_channels and the "+
+                "filters of the layer before %s against the exported model", layer.Name)
+        }
+        if kernel.Filters != layer.Filters || kernel.Size != layer.KernelSize {
+            err := fmt.Errorf("layer %s is configured as %d %d×%d filters, but its weights have %d %d×%d filters", 
+                layer.Name, layer.Filters, layer.KernelSize, layer.KernelSize, kernel.Filters, kernel.Size, kernel.Size)
+            return errs.WithHint(err, "set filters: %d and kernel_size: %d for %s in the config, or use weights exported "+
+                "from the configured model", kernel.Filters, kernel.Size, layer.Name)
+        }
+    }
+    return nil
+}
+
+// loadCustomWeights loads the declared weight arrays of every custom layer, keyed by layer name,
+// along with the time each layer took to read
+func loadCustomWeights(weightsPath string, arch *TinyCNNArchitecture) (map[string]map[string][]float32, 
+    []data.LayerLoadTime, error) {
+    
+    customWeights := make(map[string]map[string][]float32)
+    var loadTimes []data.LayerLoadTime
+    
+    var dimensions [][]int
+    loader := data.NewWeightLoader(weightsPath)
+    
+    for i, layer := range arch.Layers {
+        if layer.Type != CustomLayer {
+            continue
+        }
+        
+        custom, ok := ops.LookupCustomLayer(layer.CustomOp)
+        if !ok {
+            return nil, nil, fmt.Errorf("custom op %q is not registered", layer.CustomOp)
+        }
+        
+        // Per-channel weights need the layer's input channel count
+        if dimensions == nil {
+            var err error
+            dimensions, err = arch.GetOutputDimensions()
+            if err != nil {
+                return nil, nil, err
+            }
+        }
+        inputChannels := dimensions[i][2]
+        
+        layerStart := time.Now()
+        var layerBytes int64
+        layerWeights := make(map[string][]float32, len(custom.Spec.Weights))
+        for _, spec := range custom.Spec.Weights {
+            values, err := loader.LoadLayerArray(layer.Name, spec.Name, spec.Elements(inputChannels))
+            if err != nil {
+                return nil, nil, err
+            }
+            layerWeights[spec.Name] = values
+            layerBytes += int64(len(values)) * 4
+        }
+        customWeights[layer.Name] = layerWeights
+        
+        if len(custom.Spec.Weights) > 0 {
+            loadTimes = append(loadTimes, data.LayerLoadTime{
+                Layer:    layer.Name,
+                Duration: time.Since(layerStart),
+                Bytes:    layerBytes,
+            })
+        }
+    }
+    
+    return customWeights, loadTimes, nil
+}
+
+// WeightLoadTimes returns how long each layer's weights took to read when the model was created
+func (cnn *TinyCNN) WeightLoadTimes() []data.LayerLoadTime {
+    cnn.weightsMu.RLock()
+    defer cnn.weightsMu.RUnlock()
+    return cnn.weights.LoadTimes
+}
+
+// SetLayout selects the memory layout used for feature maps during Predict
+// Input images are always given in CHW order; LayoutHWC reorders them once up front
+func (cnn *TinyCNN) SetLayout(layout tensor.Layout) {
+    cnn.layout = layout
+}
+
+// Layout returns the memory layout used for feature maps during Predict
+func (cnn *TinyCNN) Layout() tensor.Layout {
+    return cnn.layout
+}
+
+// ConfigureEngine selects the convolution backend, worker count and buffer pooling
+func (cnn *TinyCNN) ConfigureEngine(opts ops.EngineOptions) error {
+    return cnn.convEngine.Configure(opts)
+}
+
+// EngineOptions returns the current convolution engine settings
+func (cnn *TinyCNN) EngineOptions() ops.EngineOptions {
+    return cnn.convEngine.Options()
+}
+
+// SetPrecision selects the storage precision of conv weights and activations
+// In float16 mode the float32 kernels are replaced by half precision copies that are
+// widened one layer at a time during Predict, and activations are rounded to float16
+// after every layer; arithmetic still accumulates in float32. Switching back to
+// float32 widens the stored kernels, so the rounding of a float16 round trip remains.
+// In float64 mode the kernels stay float32 and Predict runs in double precision
+// (see float64.go). It must not be called concurrently with Predict.
+func (cnn *TinyCNN) SetPrecision(precision tensor.Precision) error {
+    if precision != tensor.PrecisionFloat32 && cnn.weights.QuantKernels != nil {
+        return fmt.Errorf("precision %s cannot be combined with int8 weight quantization", precision)
+    }
+    if precision == tensor.PrecisionFloat64 {
+        for _, layer := range cnn.architecture.Layers {
+            if layer.Type == CustomLayer {
+                return fmt.Errorf("precision %s does not support custom layer %s", precision, layer.Name)
+            }
+        }
+    }
+    
+    switch precision {
+    case tensor.PrecisionFloat32, tensor.PrecisionFloat64:
+        for i, hk := range cnn.halfKernels {
+            cnn.weights.Kernels[i] = hk.ToKernel()
+        }
+        cnn.halfKernels = nil
+        
+    case tensor.PrecisionFloat16:
+        if cnn.halfKernels == nil {
+            cnn.halfKernels = make([]*tensor.HalfKernel, len(cnn.weights.Kernels))
+            for i, kernel := range cnn.weights.Kernels {
+                cnn.halfKernels[i] = tensor.NewHalfKernel(kernel)
+                cnn.weights.Kernels[i] = nil
+            }
+        }
+        
+    default:
+        return fmt.Errorf("unsupported precision: %s", precision)
+    }
+    
+    cnn.precision = precision
+    return nil
+}
+
+// Precision returns the storage precision of weights and activations
+func (cnn *TinyCNN) Precision() tensor.Precision {
+    return cnn.precision
+}
+
+// SetWeightQuantization stores the conv kernels as symmetric int8 with one scale per
+// kernel (quant.PerTensor) or per output channel (quant.PerChannel); quant.None goes
+// back to float32. Kernels are dequantized one layer at a time during Predict, so
+// activations and arithmetic stay float32, unless activation scales move the layers
+// onto the int8 GEMM (see SetActivationScales and SetActivationQuantization). Every change requantizes the current
+// kernels, so the rounding of earlier quantization (including int8 kernels loaded
+// from quantized weight files) remains. It requires float32 precision and must not
+// be called concurrently with Predict.
+func (cnn *TinyCNN) SetWeightQuantization(granularity quant.Granularity) error {
+    if granularity != quant.None && cnn.precision != tensor.PrecisionFloat32 {
+        return fmt.Errorf("int8 weight quantization requires float32 precision, model is %s", cnn.precision)
+    }
+    
+    // Back to float32 first, then requantize if asked
+    for i, qk := range cnn.weights.QuantKernels {
+        if qk != nil {
+            cnn.weights.Kernels[i] = qk.ToKernel()
+        }
+    }
+    cnn.weights.QuantKernels = nil
+    
+    if granularity == quant.None {
+        return nil
+    }
+    
+    quantKernels := make([]*quant.QuantizedKernel, len(cnn.weights.Kernels))
+    for i, kernel := range cnn.weights.Kernels {
+        qk, err := quant.QuantizeKernel(kernel, granularity)
+        if err != nil {
+            return fmt.Errorf("kernel %d: %w", i, err)
+        }
+        quantKernels[i] = qk
+    }
+    for i, qk := range quantKernels {
+        cnn.weights.SetQuantKernel(i, qk)
+    }
+    return nil
+}
+
+// WeightQuantization returns how the conv kernels are quantized (quant.None for float)
+// When only some kernels are int8, as loaded from a mixed weights directory, it
+// returns the granularity of the first of them.
+func (cnn *TinyCNN) WeightQuantization() quant.Granularity {
+    cnn.weightsMu.RLock()
+    defer cnn.weightsMu.RUnlock()
+    return cnn.weightQuantization()
+}
+
+// weightQuantization is WeightQuantization for callers already holding weightsMu
+func (cnn *TinyCNN) weightQuantization() quant.Granularity {
+    for _, qk := range cnn.weights.QuantKernels {
+        if qk != nil {
+            return qk.Granularity
+        }
+    }
+    return quant.None
+}
+
+// SetActivationScales sets the static int8 scales of the layer outputs, as calibrated
+// by gocnn-quantize; the range named "input" describes the image. A conv layer with an
+// int8 kernel whose input has a scale quantizes that input and runs on the int8 GEMM
+// (ops.Conv2DInt8); the other layers dequantize their kernel as before. Bundles loaded
+// by NewTinyCNN set these automatically; nil clears them. It must not be called
+// concurrently with Predict.
+func (cnn *TinyCNN) SetActivationScales(ranges []quant.ActivationRange) {
+    cnn.convInputScales = nil
+    if len(ranges) == 0 {
+        return
+    }
+    
+    scales := make(map[string]float32, len(ranges))
+    for _, r := range ranges {
+        scales[r.Layer] = r.Scale
+    }
+    
+    previous := "input"
+    for _, layer := range cnn.architecture.Layers {
+        if layer.Type == ConvolutionLayer {
+            cnn.convInputScales = append(cnn.convInputScales, scales[previous])
+        }
+        previous = layer.Name
+    }
+}
+
+// SetActivationQuantization selects where int8 conv layers take their input scale from.
+// quant.StaticActivations (the default) uses the calibrated scales of SetActivationScales;
+// quant.DynamicActivations measures the range of every input during Predict, so every
+// conv layer with an int8 kernel runs on the int8 GEMM without calibration data.
+// It must not be called concurrently with Predict.
+func (cnn *TinyCNN) SetActivationQuantization(mode quant.ActivationMode) error {
+    if mode != quant.StaticActivations && mode != quant.DynamicActivations {
+        return fmt.Errorf("unsupported activation quantization: %s", mode)
+    }
+    cnn.activationMode = mode
+    return nil
+}
+
+// ActivationQuantization returns where int8 conv layers take their input scale from
+func (cnn *TinyCNN) ActivationQuantization() quant.ActivationMode {
+    return cnn.activationMode
+}
+
+// runsInt8 reports whether conv layer i runs on the int8 GEMM
+func (cnn *TinyCNN) runsInt8(i int) bool {
+    if !cnn.weights.IsQuantized(i) {
+        return false
+    }
+    if cnn.activationMode == quant.DynamicActivations {
+        return true
+    }
+    return i < len(cnn.convInputScales) && cnn.convInputScales[i] > 0
+}
+
+// int8InputScale returns the activation scale conv layer i quantizes input with,
+// or 0 when the layer does not run on the int8 GEMM
+func (cnn *TinyCNN) int8InputScale(i int, input *tensor.FeatureMap) float32 {
+    if !cnn.runsInt8(i) {
+        return 0
+    }
+    if cnn.activationMode == quant.DynamicActivations {
+        return quant.DynamicScale(input.Data)
+    }
+    return cnn.convInputScales[i]
+}
+
+// Int8ConvLayers returns the number of conv layers that run on the int8 GEMM
+func (cnn *TinyCNN) Int8ConvLayers() int {
+    count := 0
+    for i := range cnn.weights.Kernels {
+        if cnn.runsInt8(i) {
+            count++
+        }
+    }
+    return count
+}
+
+// SetActivationDump makes Predict write the output of every layer the writer wants
+// Each Predict call becomes one sample of the dump; pass nil to stop dumping.
+// It must not be called concurrently with Predict.
+func (cnn *TinyCNN) SetActivationDump(w *dump.Writer) {
+    cnn.activationDump = w
+}
+
+// SetActivationStats makes Predict and PredictBatch add the output of every layer
+// to c; pass nil to stop. It must not be called concurrently with Predict.
+func (cnn *TinyCNN) SetActivationStats(c *dump.StatsCollector) {
+    cnn.activationStats = c
+}
+
+// observe hands a layer's output to the activation dump and statistics when they are set
+func (cnn *TinyCNN) observe(sample int, layer string, output *tensor.FeatureMap) error {
+    if cnn.activationDump != nil && cnn.activationDump.Wants(layer) {
+        if err := cnn.activationDump.Write(sample, layer, output); err != nil {
+            return err
+        }
+    }
+    if cnn.activationStats != nil {
+        cnn.activationStats.Observe(layer, output)
+    }
+    return nil
+}
+
+// observeVectors is observe for the per-channel outputs of global pooling and softmax,
+// one per sample, each handed over as a 1×1×C map
+func (cnn *TinyCNN) observeVectors(samples []int, layer string, values [][]float32) error {
+    if cnn.activationStats == nil && (cnn.activationDump == nil || !cnn.activationDump.Wants(layer)) {
+        return nil
+    }
+    for b, v := range values {
+        if err := cnn.observe(samples[b], layer, vectorFeatureMap(v)); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// softmaxName returns the name softmax outputs are observed under: the architecture's
+// softmax layer, or "softmax" as in the layer times when it has none
+func (cnn *TinyCNN) softmaxName() string {
+    for _, layer := range cnn.architecture.Layers {
+        if layer.Type == SoftmaxLayer {
+            return layer.Name
+        }
+    }
+    return "softmax"
+}
+
+// Autotune times the convolution algorithms for every conv layer and uses the fastest
+// cachePath, if not empty, is a JSON file whose choices are reused on later runs
+func (cnn *TinyCNN) Autotune(cachePath string) ([]ops.AutotuneResult, error) {
+    cnn.convEngine.TuningCachePath = cachePath
+    return cnn.convEngine.Autotune(cnn.architecture)
+}
+
 // Predict performs inference on a single image
-func (cnn *TinyCNN) Predict(imageData []float32) (*PredictionResult, error) {
+// ctx is checked before every layer; once it is done, Predict stops and returns ctx.Err().
+func (cnn *TinyCNN) Predict(ctx context.Context, imageData []float32) (*PredictionResult, error) {
     startTime := time.Now()
     layerTimes := make(map[string]time.Duration)
+    allocs := cnn.newLayerAllocTracker()
     
     // Validate input
-    expectedSize := cnn.architecture.InputHeight * cnn.architecture.InputWidth * cnn.architecture.InputChannels
+    expectedSize := cnn.architecture.InputSize()
     if len(imageData) != expectedSize {
         return nil, fmt.Errorf("input size mismatch: expected %d, got %d", expectedSize, len(imageData))
     }
     
-    // Convert input to feature map
-    input, err := tensor.NewFeatureMapFromData(imageData, 
-        cnn.architecture.InputHeight, 
-        cnn.architecture.InputWidth, 
-        cnn.architecture.InputChannels)
-    if err != nil {
-        return nil, fmt.Errorf("failed to create input feature map: %w", err)
+    cnn.weightsMu.RLock()
+    defer cnn.weightsMu.RUnlock()
+    
+    if cnn.precision == tensor.PrecisionFloat64 {
+        return cnn.predictFloat64(ctx, imageData, layerTimes, allocs, startTime)
     }
+    input := cnn.inputFeatureMap(imageData)
     
-    // Process through all layers
+    sample := 0
+    if cnn.activationDump != nil {
+        sample = cnn.activationDump.NextSample()
+    }
+    
+    // Process through all layers. Intermediate feature maps owned by the engine's
+    // pool are released as soon as the next layer has consumed them.
     current := input
+    pooled := true
     convLayerIdx := 0
+    depth := cnn.architecture.InputDepth // Frames stacked in current, for 3D convolutions
+    
+    // Every early return hands the current feature map back to the pool
+    fail := func(err error) (*PredictionResult, error) {
+        if pooled {
+            cnn.convEngine.Release(current)
+        }
+        return nil, err
+    }
     
     for i, layerConfig := range cnn.architecture.Layers {
+        if err := ctx.Err(); err != nil {
+            return fail(err)
+        }
+        
         layerStart := time.Now()
+        allocs.begin()
+        previous := current
         
         switch layerConfig.Type {
         case ConvolutionLayer:
-            current, err = cnn.processConvolutionLayer(current, layerConfig, convLayerIdx)
+            output, err := cnn.processConvolutionLayer(current, layerConfig, convLayerIdx)
             if err != nil {
-                return nil, fmt.Errorf("failed at layer %d (%s): %w", i, layerConfig.Name, err)
+                return fail(fmt.Errorf("failed at layer %d (%s): %w", i, layerConfig.Name, err))
             }
+            current = output
+            cnn.precision.Round(current.Data)
             convLayerIdx++
             
+            if pooled {
+                cnn.convEngine.Release(previous)
+            }
+            pooled = true
+            
         case MaxPoolingLayer:
-            current, err = cnn.processMaxPoolingLayer(current, layerConfig)
+            output, err := cnn.processMaxPoolingLayer(current, layerConfig)
+            if err != nil {
+                return fail(fmt.Errorf("failed at layer %d (%s): %w", i, layerConfig.Name, err))
+            }
+            current = output
+            
+            if pooled {
+                cnn.convEngine.Release(previous)
+            }
+            pooled = false
+            
+        case Convolution1DLayer, Convolution3DLayer:
+            output, outputDepth, err := cnn.processConvolutionNDLayer(current, layerConfig, depth)
+            if err != nil {
+                return fail(fmt.Errorf("failed at layer %d (%s): %w", i, layerConfig.Name, err))
+            }
+            current, depth = output, outputDepth
+            cnn.precision.Round(current.Data)
+            
+            if pooled {
+                cnn.convEngine.Release(previous)
+            }
+            pooled = false
+            
+        case CustomLayer:
+            output, err := cnn.processCustomLayer(current, layerConfig)
             if err != nil {
-                return nil, fmt.Errorf("failed at layer %d (%s): %w", i, layerConfig.Name, err)
+                return fail(fmt.Errorf("failed at layer %d (%s): %w", i, layerConfig.Name, err))
             }
+            current = output
             
-        case GlobalMaxPoolingLayer:
-            result, err := cnn.processGlobalMaxPoolingLayer(current)
+            // A custom op may hand back its input unchanged
+            if current != previous {
+                cnn.precision.Round(current.Data)
+                if pooled {
+                    cnn.convEngine.Release(previous)
+                }
+                pooled = false
+            }
+            
+        case GlobalMaxPoolingLayer, GlobalAveragePoolingLayer:
+            result, err := cnn.processGlobalPoolingLayer(current, layerConfig)
             if err != nil {
-                return nil, fmt.Errorf("failed at layer %d (%s): %w", i, layerConfig.Name, err)
+                return fail(fmt.Errorf("failed at layer %d (%s): %w", i, layerConfig.Name, err))
+            }
+            allocs.end(layerConfig.Name)
+            if err := cnn.observeVectors([]int{sample}, layerConfig.Name, [][]float32{result}); err != nil {
+                return fail(err)
+            }
+            
+            if pooled {
+                cnn.convEngine.Release(current)
             }
             
             // Apply softmax and return result
-            return cnn.finalizePrediction(result, layerTimes, startTime)
+            prediction, err := cnn.finalizePrediction(result, layerTimes, allocs, startTime)
+            if err != nil {
+                return nil, err
+            }
+            err = cnn.observeVectors([]int{sample}, cnn.softmaxName(), [][]float32{prediction.Probabilities})
+            if err != nil {
+                return nil, err
+            }
+            return prediction, nil
             
         default:
-            return nil, fmt.Errorf("unsupported layer type: %d", layerConfig.Type)
+            return fail(fmt.Errorf("unsupported layer type: %s", layerConfig.Type))
         }
         
         layerTimes[layerConfig.Name] = time.Since(layerStart)
+        allocs.end(layerConfig.Name)
+        
+        if err := cnn.observe(sample, layerConfig.Name, current); err != nil {
+            return fail(err)
+        }
     }
     
-    return nil, fmt.Errorf("model did not reach final layer")
+    return fail(fmt.Errorf("model did not reach final layer"))
+}
+
+// inputFeatureMap converts a CHW image to a feature map in the model's layout and
+// precision, reusing a buffer from the engine's pool
+func (cnn *TinyCNN) inputFeatureMap(imageData []float32) *tensor.FeatureMap {
+    input := cnn.convEngine.Buffers().Get(
+        cnn.architecture.stackedInputHeight(), 
+        cnn.architecture.InputWidth, 
+        cnn.architecture.InputChannels)
+    input.Layout = cnn.layout
+    if cnn.layout == tensor.LayoutCHW {
+        copy(input.Data, imageData)
+    } else {
+        tensor.CopyFeatureMapLayout(input, &tensor.FeatureMap{
+            Height:   input.Height,
+            Width:    input.Width,
+            Channels: input.Channels,
+            Data:     imageData,
+        })
+    }
+    cnn.precision.Round(input.Data)
+    return input
 }
 
 // processConvolutionLayer handles convolution + batch norm + activation
@@ -124,33 +759,96 @@ func (cnn *TinyCNN) processConvolutionLayer(input *tensor.FeatureMap, config Lay
         return nil, fmt.Errorf("kernel index %d out of range", layerIdx)
     }
     
-    kernel := cnn.weights.Kernels[layerIdx]
+    inputScale := cnn.int8InputScale(layerIdx, input)
+    kernel, release := cnn.layerKernel(layerIdx, inputScale > 0)
+    defer release()
     bias := cnn.weights.Biases[layerIdx]
+    bn, applyReLU := cnn.convEpilogue(config, layerIdx)
+    convConfig := ops.Conv2DConfig{
+        Padding: config.Padding,
+        Stride:  config.Stride,
+    }
+    
+    // Convolution, batch norm and activation in a single pass over the output
+    if inputScale > 0 {
+        quantKernel := cnn.weights.QuantKernels[layerIdx]
+        return cnn.convEngine.Conv2DInt8(input, inputScale, quantKernel, bias, bn, applyReLU, convConfig), nil
+    }
+    output := cnn.convEngine.Conv2DFused(input, kernel, bias, bn, applyReLU, convConfig)
+    
+    return output, nil
+}
+
+// processConvolutionLayerBatch is processConvolutionLayer for every image of a batch,
+// with one matrix product per layer for the whole batch
+func (cnn *TinyCNN) processConvolutionLayerBatch(inputs []*tensor.FeatureMap, config LayerConfig, 
+    layerIdx int) ([]*tensor.FeatureMap, error) {
     
-    // Perform convolution
+    if layerIdx >= len(cnn.weights.Kernels) {
+        return nil, fmt.Errorf("kernel index %d out of range", layerIdx)
+    }
+    
+    // Dynamic activation scales differ per image; a layer runs in int8 for all of them or none
+    inputScales := make([]float32, len(inputs))
+    for b, input := range inputs {
+        inputScales[b] = cnn.int8InputScale(layerIdx, input)
+    }
+    runsInt8 := cnn.runsInt8(layerIdx)
+    kernel, release := cnn.layerKernel(layerIdx, runsInt8)
+    defer release()
+    bias := cnn.weights.Biases[layerIdx]
+    bn, applyReLU := cnn.convEpilogue(config, layerIdx)
     convConfig := ops.Conv2DConfig{
         Padding: config.Padding,
         Stride:  config.Stride,
     }
     
-    output := cnn.convEngine.Conv2DOptimized(input, kernel, bias, convConfig)
+    if runsInt8 {
+        quantKernel := cnn.weights.QuantKernels[layerIdx]
+        return cnn.convEngine.Conv2DInt8Batch(inputs, inputScales, quantKernel, bias, bn, applyReLU, convConfig), nil
+    }
+    return cnn.convEngine.Conv2DFusedBatch(inputs, kernel, bias, bn, applyReLU, convConfig), nil
+}
+
+// layerKernel returns conv layer layerIdx's kernel in float32, or nil when the layer
+// runs in int8. Float16 kernels, and int8 kernels of layers that don't run in int8,
+// are widened into a pooled scratch buffer for this layer only; call release once
+// the convolution is done.
+func (cnn *TinyCNN) layerKernel(layerIdx int, runsInt8 bool) (*tensor.Kernel, func()) {
+    kernel := cnn.weights.Kernels[layerIdx]
+    buffers := cnn.convEngine.Buffers()
+    
+    if kernel == nil && cnn.halfKernels != nil {
+        halfKernel := cnn.halfKernels[layerIdx]
+        scratch := buffers.GetSlice(halfKernel.TotalWeights())
+        return halfKernel.ExpandInto(scratch), func() { buffers.PutSlice(scratch) }
+    }
+    
+    if kernel == nil && cnn.weights.IsQuantized(layerIdx) && !runsInt8 {
+        quantKernel := cnn.weights.QuantKernels[layerIdx]
+        scratch := buffers.GetSlice(quantKernel.TotalWeights())
+        return quantKernel.ExpandInto(scratch), func() { buffers.PutSlice(scratch) }
+    }
     
-    // Apply batch normalization (if enabled and available)
+    return kernel, func() {}
+}
+
+// convEpilogue returns the batch norm and ReLU that follow conv layer layerIdx
+// Batch normalization (if enabled and available) always ends in ReLU; without it,
+// ReLU follows ApplyActivation.
+func (cnn *TinyCNN) convEpilogue(config LayerConfig, layerIdx int) (*ops.BatchNormParams, bool) {
+    var bn *ops.BatchNormParams
     if config.ApplyBatchNorm && layerIdx < len(cnn.weights.BatchNorms) {
         batchNorm := cnn.weights.BatchNorms[layerIdx]
-        ops.BatchNormalizeInPlace(output, &ops.BatchNormParams{
+        bn = &ops.BatchNormParams{
             Mean:     batchNorm.Mean,
             Variance: batchNorm.Variance,
             Scale:    batchNorm.Scale,
             Shift:    batchNorm.Shift,
             Epsilon:  batchNorm.Epsilon,
-        })
-    } else if config.ApplyActivation {
-        // Apply ReLU activation if no batch norm
-        ops.ReLUInPlace(output.Data)
+        }
     }
-    
-    return output, nil
+    return bn, bn != nil || config.ApplyActivation
 }
 
 // processMaxPoolingLayer handles max pooling operations
@@ -159,18 +857,49 @@ func (cnn *TinyCNN) processMaxPoolingLayer(input *tensor.FeatureMap, config Laye
     return output, nil
 }
 
-// processGlobalMaxPoolingLayer handles global max pooling
-func (cnn *TinyCNN) processGlobalMaxPoolingLayer(input *tensor.FeatureMap) ([]float32, error) {
-    result := ops.GlobalMaxPooling(input)
-    return result, nil
+// processCustomLayer runs a registered custom op with its loaded weights
+func (cnn *TinyCNN) processCustomLayer(input *tensor.FeatureMap, config LayerConfig) (*tensor.FeatureMap, error) {
+    custom, ok := ops.LookupCustomLayer(config.CustomOp)
+    if !ok {
+        return nil, fmt.Errorf("custom op %q is not registered", config.CustomOp)
+    }
+    
+    output, err := custom.Forward(input, cnn.customWeights[config.Name], config.CustomParams)
+    if err != nil {
+        return nil, fmt.Errorf("custom op %q failed: %w", config.CustomOp, err)
+    }
+    if output == nil {
+        return nil, fmt.Errorf("custom op %q returned no output", config.CustomOp)
+    }
+    
+    return output, nil
+}
+
+// processGlobalPoolingLayer handles global max and global average pooling
+func (cnn *TinyCNN) processGlobalPoolingLayer(input *tensor.FeatureMap, layerConfig LayerConfig) ([]float32, error) {
+    if layerConfig.Type == GlobalAveragePoolingLayer {
+        return ops.GlobalAvgPooling(input), nil
+    }
+    return ops.GlobalMaxPooling(input), nil
 }
 
 // finalizePrediction applies softmax and creates the final result
-func (cnn *TinyCNN) finalizePrediction(logits []float32, layerTimes map[string]time.Duration, startTime time.Time) (*PredictionResult, error) {
+func (cnn *TinyCNN) finalizePrediction(logits []float32, layerTimes map[string]time.Duration, 
+    allocs *layerAllocTracker, startTime time.Time) (*PredictionResult, error) {
     // Apply softmax
     softmaxStart := time.Now()
+    allocs.begin()
     probabilities := ops.Softmax(logits)
     layerTimes["softmax"] = time.Since(softmaxStart)
+    allocs.end("softmax")
+    
+    return cnn.newPredictionResult(probabilities, layerTimes, allocs, startTime), nil
+}
+
+// newPredictionResult creates the result of a prediction from its probabilities
+// and records its layer times
+func (cnn *TinyCNN) newPredictionResult(probabilities []float32, layerTimes map[string]time.Duration, 
+    allocs *layerAllocTracker, startTime time.Time) *PredictionResult {
     
     // Find predicted class and confidence
     predictedClass := ops.Argmax(probabilities)
@@ -178,12 +907,7 @@ func (cnn *TinyCNN) finalizePrediction(logits []float32, layerTimes map[string]t
     
     // Update performance tracking
     totalTime := time.Since(startTime)
-    cnn.totalInferences++
-    
-    // Accumulate layer times for performance analysis
-    for layerName, layerTime := range layerTimes {
-        cnn.layerTimes[layerName] += layerTime
-    }
+    cnn.stats.record(layerTimes)
     
     return &PredictionResult{
         Probabilities:  probabilities,
@@ -191,32 +915,269 @@ func (cnn *TinyCNN) finalizePrediction(logits []float32, layerTimes map[string]t
         Confidence:     confidence,
         LayerTimes:     layerTimes,
         TotalTime:      totalTime,
-    }, nil
+        LayerAllocs:    allocs.allocs,
+        Engine:         cnn.convEngine.Options(),
+    }
+}
+
+// PredictTopK performs inference on a single image and returns its k most probable
+// classes, most probable first
+func (cnn *TinyCNN) PredictTopK(ctx context.Context, imageData []float32, k int) ([]ClassProbability, error) {
+    if k <= 0 {
+        return nil, fmt.Errorf("k must be positive, got %d", k)
+    }
+    result, err := cnn.Predict(ctx, imageData)
+    if err != nil {
+        return nil, err
+    }
+    return result.TopK(k), nil
 }
 
 // PredictBatch performs inference on multiple images
-func (cnn *TinyCNN) PredictBatch(images [][]float32) ([]*PredictionResult, error) {
-    results := make([]*PredictionResult, len(images))
+// The images go through the network together, one layer at a time: each conv layer
+// is a single matrix product for the whole batch (see ops.Conv2DFusedBatch), so its
+// weights are read once per batch rather than once per image. The results match
+// Predict with the gemm backend. Each result's LayerTimes and TotalTime are an equal
+// share of the batch's, and so are their LayerAllocs. Cancelling ctx stops the batch between layers and returns
+// ctx.Err(). In float64 precision the images are predicted one at a time.
+func (cnn *TinyCNN) PredictBatch(ctx context.Context, images [][]float32) ([]*PredictionResult, error) {
+    if len(images) <= 1 || cnn.precision == tensor.PrecisionFloat64 {
+        results := make([]*PredictionResult, len(images))
+        for i, image := range images {
+            result, err := cnn.Predict(ctx, image)
+            if err != nil {
+                if ctxErr := ctx.Err(); ctxErr != nil {
+                    return nil, ctxErr
+                }
+                return nil, fmt.Errorf("failed to predict image %d: %w", i, err)
+            }
+            results[i] = result
+        }
+        return results, nil
+    }
+    
+    startTime := time.Now()
+    layerTimes := make(map[string]time.Duration)
+    allocs := cnn.newLayerAllocTracker()
     
+    expectedSize := cnn.architecture.InputSize()
     for i, image := range images {
-        result, err := cnn.Predict(image)
-        if err != nil {
-            return nil, fmt.Errorf("failed to predict image %d: %w", i, err)
+        if len(image) != expectedSize {
+            return nil, fmt.Errorf("image %d: input size mismatch: expected %d, got %d", i, expectedSize, len(image))
+        }
+    }
+    
+    cnn.weightsMu.RLock()
+    defer cnn.weightsMu.RUnlock()
+    
+    current := make([]*tensor.FeatureMap, len(images))
+    pooled := make([]bool, len(images)) // Whether current[b] came from the engine's pool
+    samples := make([]int, len(images))
+    for b, image := range images {
+        current[b] = cnn.inputFeatureMap(image)
+        pooled[b] = true
+        if cnn.activationDump != nil {
+            samples[b] = cnn.activationDump.NextSample()
+        }
+    }
+    release := func() {
+        for b, fm := range current {
+            if pooled[b] {
+                cnn.convEngine.Release(fm)
+            }
+        }
+    }
+    // Every early return hands the current feature maps back to the pool
+    fail := func(err error) ([]*PredictionResult, error) {
+        release()
+        return nil, err
+    }
+    
+    convLayerIdx := 0
+    depth := cnn.architecture.InputDepth // Frames stacked in each feature map, for 3D convolutions
+    for i, layerConfig := range cnn.architecture.Layers {
+        if err := ctx.Err(); err != nil {
+            return fail(err)
+        }
+        
+        layerStart := time.Now()
+        allocs.begin()
+        
+        switch layerConfig.Type {
+        case ConvolutionLayer:
+            outputs, err := cnn.processConvolutionLayerBatch(current, layerConfig, convLayerIdx)
+            if err != nil {
+                return fail(fmt.Errorf("failed at layer %d (%s): %w", i, layerConfig.Name, err))
+            }
+            convLayerIdx++
+            
+            release()
+            for b, output := range outputs {
+                cnn.precision.Round(output.Data)
+                current[b], pooled[b] = output, true
+            }
+            
+        case MaxPoolingLayer:
+            for b, input := range current {
+                output, err := cnn.processMaxPoolingLayer(input, layerConfig)
+                if err != nil {
+                    return fail(fmt.Errorf("failed at layer %d (%s) on image %d: %w", i, layerConfig.Name, b, err))
+                }
+                if pooled[b] {
+                    cnn.convEngine.Release(input)
+                }
+                current[b], pooled[b] = output, false
+            }
+            
+        case Convolution1DLayer, Convolution3DLayer:
+            outputDepth := depth
+            for b, input := range current {
+                output, outDepth, err := cnn.processConvolutionNDLayer(input, layerConfig, depth)
+                if err != nil {
+                    return fail(fmt.Errorf("failed at layer %d (%s) on image %d: %w", i, layerConfig.Name, b, err))
+                }
+                cnn.precision.Round(output.Data)
+                if pooled[b] {
+                    cnn.convEngine.Release(input)
+                }
+                current[b], pooled[b] = output, false
+                outputDepth = outDepth
+            }
+            depth = outputDepth
+            
+        case CustomLayer:
+            for b, input := range current {
+                output, err := cnn.processCustomLayer(input, layerConfig)
+                if err != nil {
+                    return fail(fmt.Errorf("failed at layer %d (%s) on image %d: %w", i, layerConfig.Name, b, err))
+                }
+                
+                // A custom op may hand back its input unchanged
+                if output != input {
+                    cnn.precision.Round(output.Data)
+                    if pooled[b] {
+                        cnn.convEngine.Release(input)
+                    }
+                    current[b], pooled[b] = output, false
+                }
+            }
+            
+        case GlobalMaxPoolingLayer, GlobalAveragePoolingLayer:
+            logits := make([][]float32, len(current))
+            for b, input := range current {
+                result, err := cnn.processGlobalPoolingLayer(input, layerConfig)
+                if err != nil {
+                    return fail(fmt.Errorf("failed at layer %d (%s) on image %d: %w", i, layerConfig.Name, b, err))
+                }
+                logits[b] = result
+            }
+            allocs.end(layerConfig.Name)
+            if err := cnn.observeVectors(samples, layerConfig.Name, logits); err != nil {
+                return fail(err)
+            }
+            release()
+            
+            // Apply softmax and return results
+            results := cnn.finalizeBatch(logits, layerTimes, allocs, startTime)
+            probabilities := make([][]float32, len(results))
+            for b, result := range results {
+                probabilities[b] = result.Probabilities
+            }
+            if err := cnn.observeVectors(samples, cnn.softmaxName(), probabilities); err != nil {
+                return nil, err
+            }
+            return results, nil
+            
+        default:
+            return fail(fmt.Errorf("unsupported layer type: %s", layerConfig.Type))
+        }
+        
+        layerTimes[layerConfig.Name] = time.Since(layerStart)
+        allocs.end(layerConfig.Name)
+        
+        for b, fm := range current {
+            if err := cnn.observe(samples[b], layerConfig.Name, fm); err != nil {
+                return fail(err)
+            }
         }
-        results[i] = result
     }
     
-    return results, nil
+    return fail(fmt.Errorf("model did not reach final layer"))
+}
+
+// finalizeBatch applies softmax to the logits of every image of a batch and creates
+// their results, each carrying an equal share of the batch's times
+func (cnn *TinyCNN) finalizeBatch(logits [][]float32, layerTimes map[string]time.Duration, 
+    allocs *layerAllocTracker, startTime time.Time) []*PredictionResult {
+    
+    softmaxStart := time.Now()
+    allocs.begin()
+    probabilities := make([][]float32, len(logits))
+    for b, values := range logits {
+        probabilities[b] = ops.Softmax(values)
+    }
+    layerTimes["softmax"] = time.Since(softmaxStart)
+    allocs.end("softmax")
+    
+    n := time.Duration(len(logits))
+    totalTime := time.Since(startTime) / n
+    engine := cnn.convEngine.Options()
+    
+    results := make([]*PredictionResult, len(logits))
+    for b, probs := range probabilities {
+        share := make(map[string]time.Duration, len(layerTimes))
+        for layerName, layerTime := range layerTimes {
+            share[layerName] = layerTime / n
+        }
+        cnn.stats.record(share)
+        
+        var allocShare map[string]AllocCounters
+        if allocs.allocs != nil {
+            allocShare = make(map[string]AllocCounters, len(allocs.allocs))
+            for layerName, counters := range allocs.allocs {
+                allocShare[layerName] = AllocCounters{Bytes: counters.Bytes / uint64(n), Objects: counters.Objects / uint64(n)}
+            }
+        }
+        
+        predictedClass := ops.Argmax(probs)
+        results[b] = &PredictionResult{
+            Probabilities:  probs,
+            PredictedClass: predictedClass,
+            Confidence:     probs[predictedClass],
+            LayerTimes:     share,
+            TotalTime:      totalTime,
+            LayerAllocs:    allocShare,
+            Engine:         engine,
+        }
+    }
+    return results
 }
 
 // GetModelInfo returns information about the model
 func (cnn *TinyCNN) GetModelInfo() *ModelInfo {
+    cnn.weightsMu.RLock()
+    defer cnn.weightsMu.RUnlock()
+    
     totalParams := int64(0)
+    kernelBytes := int64(0)
+    layerKernelBytes := make([]int64, len(cnn.weights.Kernels)) // Storage of each conv layer's kernel
     
     // Count parameters in kernels
-    for _, kernel := range cnn.weights.Kernels {
-        totalParams += int64(kernel.TotalWeights())
+    for i, kernel := range cnn.weights.Kernels {
+        switch {
+        case kernel != nil:
+            totalParams += int64(kernel.TotalWeights())
+            layerKernelBytes[i] = int64(kernel.TotalWeights()) * 4
+        case cnn.halfKernels != nil:
+            totalParams += int64(cnn.halfKernels[i].TotalWeights())
+            layerKernelBytes[i] = int64(cnn.halfKernels[i].TotalWeights()) * 2
+        default:
+            totalParams += int64(cnn.weights.QuantKernels[i].TotalWeights())
+            layerKernelBytes[i] = cnn.weights.QuantKernels[i].Bytes()
+        }
+        kernelBytes += layerKernelBytes[i]
     }
+    kernelParams := totalParams
     
     // Count parameters in biases
     for _, bias := range cnn.weights.Biases {
@@ -225,39 +1186,100 @@ func (cnn *TinyCNN) GetModelInfo() *ModelInfo {
     
     // Count parameters in batch norm
     for _, bn := range cnn.weights.BatchNorms {
+        if bn == nil {
+            continue
+        }
         totalParams += int64(len(bn.Mean) + len(bn.Variance) + len(bn.Scale) + len(bn.Shift))
     }
     
+    // Count parameters in 1D and 3D conv layers, which stay float32
+    for _, convND := range cnn.weights.ConvND {
+        if convND.Kernel1D != nil {
+            totalParams += int64(len(convND.Kernel1D.Weights))
+        }
+        if convND.Kernel3D != nil {
+            totalParams += int64(len(convND.Kernel3D.Weights))
+        }
+        totalParams += int64(len(convND.Bias))
+    }
+    
+    // Count parameters in custom layers
+    for _, layerWeights := range cnn.customWeights {
+        for _, values := range layerWeights {
+            totalParams += int64(len(values))
+        }
+    }
+    
+    // Kernels are stored at the model's precision (or as int8); biases and batch norm stay float32
+    weightBytes := kernelBytes + (totalParams-kernelParams)*4
+    inferences, avgTimes := cnn.stats.averages()
+    layers, totalFLOPs := cnn.layerCosts(layerKernelBytes)
+    
     return &ModelInfo{
-        Architecture:     cnn.architecture,
-        TotalParameters:  totalParams,
-        TotalInferences:  cnn.totalInferences,
-        AverageLayerTimes: cnn.getAverageLayerTimes(),
+        Architecture:           cnn.architecture,
+        TotalParameters:        totalParams,
+        Precision:              cnn.precision,
+        WeightQuantization:     cnn.weightQuantization(),
+        Int8ConvLayers:         cnn.Int8ConvLayers(),
+        ActivationQuantization: cnn.activationMode,
+        WeightBytes:            weightBytes,
+        Engine:                 cnn.convEngine.Options(),
+        TotalInferences:        inferences,
+        AverageLayerTimes:      avgTimes,
+        Layers:                 layers,
+        TotalFLOPs:             totalFLOPs,
     }
 }
 
-// getAverageLayerTimes calculates average time per layer type
-func (cnn *TinyCNN) getAverageLayerTimes() map[string]time.Duration {
-    if cnn.totalInferences == 0 {
-        return make(map[string]time.Duration)
+// layerCosts returns the cost of every layer with the parameters as stored, given the
+// storage of each conv layer's kernel, and the FLOPs of the whole network
+func (cnn *TinyCNN) layerCosts(kernelBytes []int64) ([]LayerCost, int64) {
+    costs, err := cnn.architecture.LayerCosts()
+    if err != nil {
+        return nil, 0 // A loaded model's architecture has already been validated
     }
     
-    avgTimes := make(map[string]time.Duration)
-    for layerName, totalTime := range cnn.layerTimes {
-        avgTimes[layerName] = time.Duration(int64(totalTime) / cnn.totalInferences)
+    var total int64
+    convLayerIdx := 0
+    for i, layer := range cnn.architecture.Layers {
+        switch layer.Type {
+        case ConvolutionLayer:
+            if convLayerIdx < len(kernelBytes) {
+                // Biases and batch norm stay float32 at every precision
+                floatParams := int64(0)
+                if convLayerIdx < len(cnn.weights.Biases) {
+                    floatParams += int64(len(cnn.weights.Biases[convLayerIdx]))
+                }
+                if convLayerIdx < len(cnn.weights.BatchNorms) {
+                    if bn := cnn.weights.BatchNorms[convLayerIdx]; bn != nil {
+                        floatParams += int64(len(bn.Mean) + len(bn.Variance) + len(bn.Scale) + len(bn.Shift))
+                    }
+                }
+                costs[i].setParamBytes(kernelBytes[convLayerIdx] + floatParams*4)
+            }
+            convLayerIdx++
+        case CustomLayer:
+            params := int64(0)
+            for _, values := range cnn.customWeights[layer.Name] {
+                params += int64(len(values))
+            }
+            costs[i].setParamBytes(params * 4)
+        }
+        total += costs[i].FLOPs
     }
-    
-    return avgTimes
+    return costs, total
 }
 
 // ResetPerformanceCounters resets all performance tracking
 func (cnn *TinyCNN) ResetPerformanceCounters() {
-    cnn.layerTimes = make(map[string]time.Duration)
-    cnn.totalInferences = 0
+    cnn.stats.reset()
 }
 
 // ValidateModel performs basic validation on the loaded model
 func (cnn *TinyCNN) ValidateModel() error {
+    cnn.weightsMu.RLock()
+    defer cnn.weightsMu.RUnlock()
+    
     // Check that we have the right number of layers
     convLayers := 0
     for _, layer := range cnn.architecture.Layers {
@@ -274,23 +1296,132 @@ func (cnn *TinyCNN) ValidateModel() error {
         return fmt.Errorf("bias count mismatch: expected %d, got %d", convLayers, len(cnn.weights.Biases))
     }
     
-    // Validate each kernel
-    for i, kernel := range cnn.weights.Kernels {
-        err := tensor.ValidateKernel(kernel)
+    // Validate each kernel; int8 kernels are checked as stored and as the float32 values Predict uses
+    for i := range cnn.weights.Kernels {
+        if cnn.weights.IsQuantized(i) {
+            if err := cnn.weights.QuantKernels[i].Validate(); err != nil {
+                return fmt.Errorf("kernel %d validation failed: %w", i, err)
+            }
+        }
+        err := tensor.ValidateKernel(cnn.floatKernel(i))
         if err != nil {
             return fmt.Errorf("kernel %d validation failed: %w", i, err)
         }
     }
     
+    // 1D and 3D kernels are keyed by layer; each must fit the layer that runs it
+    if !cnn.architecture.hasLayer(Convolution1DLayer) && !cnn.architecture.hasLayer(Convolution3DLayer) {
+        return nil
+    }
+    dimensions, err := cnn.architecture.GetOutputDimensions()
+    if err != nil {
+        return fmt.Errorf("invalid architecture: %w", err)
+    }
+    for i, layer := range cnn.architecture.Layers {
+        if layer.Type != Convolution1DLayer && layer.Type != Convolution3DLayer {
+            continue
+        }
+        weights := cnn.weights.ConvND[layer.Name]
+        if err := checkConvNDShape(layer, dimensions[i][2], weights); err != nil {
+            return fmt.Errorf("layer %s validation failed: %w", layer.Name, err)
+        }
+        if err := validateConvNDValues(weights); err != nil {
+            return fmt.Errorf("layer %s validation failed: %w", layer.Name, err)
+        }
+    }
+    
     return nil
 }
 
+// ConvKernel returns a float32 copy of the named conv layer's kernel
+// Half precision and int8 kernels are widened, so the copy holds the values Predict uses.
+func (cnn *TinyCNN) ConvKernel(name string) (*tensor.Kernel, error) {
+    cnn.weightsMu.RLock()
+    defer cnn.weightsMu.RUnlock()
+    
+    convIdx := 0
+    for _, layer := range cnn.architecture.Layers {
+        if layer.Type != ConvolutionLayer {
+            continue
+        }
+        if layer.Name == name {
+            if convIdx >= len(cnn.weights.Kernels) {
+                return nil, fmt.Errorf("conv layer %s has no kernel", name)
+            }
+            return cnn.floatKernel(convIdx).Clone(), nil
+        }
+        convIdx++
+    }
+    return nil, fmt.Errorf("no conv layer named %q", name)
+}
+
+// ConvBias returns a copy of the named conv layer's bias, one value per filter
+func (cnn *TinyCNN) ConvBias(name string) ([]float32, error) {
+    cnn.weightsMu.RLock()
+    defer cnn.weightsMu.RUnlock()
+    
+    convIdx := 0
+    for _, layer := range cnn.architecture.Layers {
+        if layer.Type != ConvolutionLayer {
+            continue
+        }
+        if layer.Name == name {
+            if convIdx >= len(cnn.weights.Biases) {
+                return nil, fmt.Errorf("conv layer %s has no bias", name)
+            }
+            return append([]float32(nil), cnn.weights.Biases[convIdx]...), nil
+        }
+        convIdx++
+    }
+    return nil, fmt.Errorf("no conv layer named %q", name)
+}
+
+// ConvEpilogue returns the batch norm, nil if none, and whether ReLU follow the
+// named conv layer, the operations RunLayer fuses into its output
+func (cnn *TinyCNN) ConvEpilogue(name string) (*ops.BatchNormParams, bool, error) {
+    cnn.weightsMu.RLock()
+    defer cnn.weightsMu.RUnlock()
+    
+    convIdx := 0
+    for _, layer := range cnn.architecture.Layers {
+        if layer.Type != ConvolutionLayer {
+            continue
+        }
+        if layer.Name == name {
+            bn, applyReLU := cnn.convEpilogue(layer, convIdx)
+            return bn, applyReLU, nil
+        }
+        convIdx++
+    }
+    return nil, false, fmt.Errorf("no conv layer named %q", name)
+}
+
+// floatKernel returns conv kernel i as float32, widening stored half or int8 kernels
+// The result may share memory with the model and must not be modified.
+func (cnn *TinyCNN) floatKernel(i int) *tensor.Kernel {
+    if kernel := cnn.weights.Kernels[i]; kernel != nil {
+        return kernel
+    }
+    if cnn.halfKernels != nil {
+        return cnn.halfKernels[i].ToKernel()
+    }
+    return cnn.weights.QuantKernels[i].ToKernel()
+}
+
 // ModelInfo holds information about the model
 type ModelInfo struct {
-    Architecture      *TinyCNNArchitecture
-    TotalParameters   int64
-    TotalInferences   int64
-    AverageLayerTimes map[string]time.Duration
+    Architecture           *TinyCNNArchitecture
+    TotalParameters        int64
+    Precision              tensor.Precision     // Storage precision of the conv kernels
+    WeightQuantization     quant.Granularity    // int8 quantization of the conv kernels, if any
+    Int8ConvLayers         int                  // Conv layers running on int8 activations (see SetActivationScales)
+    ActivationQuantization quant.ActivationMode // Where those layers take their input scale from
+    WeightBytes            int64                // Memory held by all parameters
+    Engine                 ops.EngineOptions    // Convolution engine settings
+    TotalInferences        int64
+    AverageLayerTimes      map[string]time.Duration
+    Layers                 []LayerCost          // FLOPs, parameter bytes and arithmetic intensity per layer
+    TotalFLOPs             int64                // FLOPs of one inference
 }
 
 // Print displays model information in a readable format
@@ -303,6 +1434,14 @@ func (info *ModelInfo) Print() {
     fmt.Printf("  Output Classes: %d\n", info.Architecture.NumClasses)
     fmt.Printf("  Total Layers: %d\n", len(info.Architecture.Layers))
     fmt.Printf("  Total Parameters: %d\n", info.TotalParameters)
+    fmt.Printf("  Precision: %s (%.1f KB of weights)\n", info.Precision, float64(info.WeightBytes)/1024)
+    if info.WeightQuantization != quant.None {
+        fmt.Printf("  Weight Quantization: int8 %s\n", info.WeightQuantization)
+    }
+    if info.Int8ConvLayers > 0 {
+        fmt.Printf("  Int8 Activations: %d conv layers (%s scales)\n", info.Int8ConvLayers, info.ActivationQuantization)
+    }
+    fmt.Printf("  Compute: %.1f MFLOPs per inference\n", float64(info.TotalFLOPs)/1e6)
     fmt.Printf("  Total Inferences: %d\n", info.TotalInferences)
     
     if len(info.AverageLayerTimes) > 0 {
@@ -311,4 +1450,26 @@ func (info *ModelInfo) Print() {
             fmt.Printf("    %s: %v\n", layerName, avgTime)
         }
     }
+}
+
+// LogValue groups the model's shape, size and engine for a log record
+func (info *ModelInfo) LogValue() slog.Value {
+    attrs := []slog.Attr{
+        slog.String("input", fmt.Sprintf("%d×%d×%d", info.Architecture.InputHeight,
+            info.Architecture.InputWidth, info.Architecture.InputChannels)),
+        slog.Int("classes", info.Architecture.NumClasses),
+        slog.Int("layers", len(info.Architecture.Layers)),
+        slog.Int64("parameters", info.TotalParameters),
+        slog.String("precision", info.Precision.String()),
+        slog.Int64("weight_bytes", info.WeightBytes),
+        slog.String("engine", info.Engine.String()),
+    }
+    if info.WeightQuantization != quant.None {
+        attrs = append(attrs, slog.String("weight_quantization", "int8 "+info.WeightQuantization.String()))
+    }
+    if info.Int8ConvLayers > 0 {
+        attrs = append(attrs, slog.Int("int8_conv_layers", info.Int8ConvLayers),
+            slog.String("activation_scales", info.ActivationQuantization.String()))
+    }
+    return slog.GroupValue(attrs...)
 }
\ No newline at end of file
@@ -1,11 +1,24 @@
 package model
 
 import (
+	"context"
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/dump"
+	"duchm1606/gocnn/internal/errs"
+	"duchm1606/gocnn/internal/ops"
+	"duchm1606/gocnn/internal/quant"
+	"duchm1606/gocnn/internal/tensor"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // Helper function to create test weight files
@@ -30,12 +43,18 @@ func createTestWeights(t testing.TB, weightsDir string) {
     }
     
     for i, config := range layerConfigs {
+        // Each conv layer lives in its own directory, e.g. conv1/conv1_weight.bin
+        layerDir := filepath.Join(weightsDir, config.name)
+        if err := os.MkdirAll(layerDir, 0755); err != nil {
+            t.Fatalf("Failed to create layer directory: %v", err)
+        }
+        
         // Create weight file
-        weightFile := filepath.Join(weightsDir, config.name+"_weight.bin")
+        weightFile := filepath.Join(layerDir, config.name+"_weight.bin")
         createWeightFile(t, weightFile, config.size, config.channels, config.filters)
         
         // Create bias file
-        biasFile := filepath.Join(weightsDir, config.name+"_bias.bin")
+        biasFile := filepath.Join(layerDir, config.name+"_bias.bin")
         createBiasFile(t, biasFile, config.filters)
         
         // Create batch normalization files for all layers except the last one (conv7)
@@ -174,7 +193,7 @@ func TestTinyCNNPredict(t *testing.T) {
     }
     
     // Perform prediction
-    result, err := model.Predict(imageData)
+    result, err := model.Predict(context.Background(), imageData)
     if err != nil {
         t.Fatalf("Prediction failed: %v", err)
     }
@@ -209,6 +228,117 @@ func TestTinyCNNPredict(t *testing.T) {
     }
 }
 
+func TestTinyCNNPredictRepeatable(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = float32(i%17) / 17
+    }
+    
+    // Later calls run on recycled buffers and must not see stale data
+    first, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    for run := 0; run < 3; run++ {
+        result, err := model.Predict(context.Background(), imageData)
+        if err != nil {
+            t.Fatalf("Prediction failed: %v", err)
+        }
+        for i := range first.Probabilities {
+            if result.Probabilities[i] != first.Probabilities[i] {
+                t.Fatalf("Run %d: probability %d changed from %f to %f", 
+                    run, i, first.Probabilities[i], result.Probabilities[i])
+            }
+        }
+    }
+}
+
+func TestTinyCNNPredictHWC(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = float32(i%11) / 11
+    }
+    
+    expected, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    
+    model.SetLayout(tensor.LayoutHWC)
+    result, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("HWC prediction failed: %v", err)
+    }
+    
+    for i := range expected.Probabilities {
+        diff := expected.Probabilities[i] - result.Probabilities[i]
+        if diff > 1e-5 || diff < -1e-5 {
+            t.Fatalf("Probability %d: CHW %f, HWC %f", i, expected.Probabilities[i], result.Probabilities[i])
+        }
+    }
+}
+
+func TestTinyCNNPredictTopK(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = float32(i%11) / 11
+    }
+    
+    result, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    top, err := model.PredictTopK(context.Background(), imageData, 3)
+    if err != nil {
+        t.Fatalf("PredictTopK failed: %v", err)
+    }
+    if len(top) != 3 {
+        t.Fatalf("Expected 3 classes, got %d", len(top))
+    }
+    if top[0].Class != result.PredictedClass || top[0].Probability != result.Confidence {
+        t.Errorf("Expected class %d (%f) first, got %+v", result.PredictedClass, result.Confidence, top[0])
+    }
+    for i, entry := range top {
+        if entry.Probability != result.Probabilities[entry.Class] {
+            t.Errorf("Entry %d: probability %f does not match class %d's %f", 
+                i, entry.Probability, entry.Class, result.Probabilities[entry.Class])
+        }
+        if i > 0 && entry.Probability > top[i-1].Probability {
+            t.Errorf("Entry %d is more probable than entry %d", i, i-1)
+        }
+    }
+    
+    if all := result.TopK(20); len(all) != 10 {
+        t.Errorf("Expected all 10 classes for k beyond the class count, got %d", len(all))
+    }
+    if _, err := model.PredictTopK(context.Background(), imageData, 0); err == nil {
+        t.Error("Expected an error for k = 0")
+    }
+}
+
 func TestTinyCNNPredictBatch(t *testing.T) {
     // Create temporary weights directory
     tempDir := t.TempDir()
@@ -233,7 +363,7 @@ func TestTinyCNNPredictBatch(t *testing.T) {
     }
     
     // Perform batch prediction
-    results, err := model.PredictBatch(images)
+    results, err := model.PredictBatch(context.Background(), images)
     if err != nil {
         t.Fatalf("Batch prediction failed: %v", err)
     }
@@ -255,6 +385,409 @@ func TestTinyCNNPredictBatch(t *testing.T) {
     }
 }
 
+func TestTinyCNNPredictBatchMatchesPredict(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    // The batched conv layers are one GEMM; with the gemm backend Predict sums in the same order
+    if err := model.ConfigureEngine(ops.EngineOptions{Backend: ops.AlgoGEMM, Pooling: true}); err != nil {
+        t.Fatalf("ConfigureEngine failed: %v", err)
+    }
+    
+    images := make([][]float32, 5)
+    for b := range images {
+        images[b] = make([]float32, 32*32*3)
+        for i := range images[b] {
+            images[b][i] = float32((i*(b+3))%17) / 17
+        }
+    }
+    
+    compare := func(mode string) {
+        t.Helper()
+        results, err := model.PredictBatch(context.Background(), images)
+        if err != nil {
+            t.Fatalf("%s: batch prediction failed: %v", mode, err)
+        }
+        for b, image := range images {
+            expected, err := model.Predict(context.Background(), image)
+            if err != nil {
+                t.Fatalf("%s: prediction failed: %v", mode, err)
+            }
+            for c := range expected.Probabilities {
+                if results[b].Probabilities[c] != expected.Probabilities[c] {
+                    t.Fatalf("%s: image %d probability %d: expected %g, got %g", mode, b, c, 
+                        expected.Probabilities[c], results[b].Probabilities[c])
+                }
+            }
+            if results[b].TotalTime <= 0 || len(results[b].LayerTimes) == 0 {
+                t.Errorf("%s: image %d has no timings", mode, b)
+            }
+        }
+    }
+    
+    compare("float32")
+    
+    model.SetLayout(tensor.LayoutHWC)
+    compare("HWC")
+    model.SetLayout(tensor.LayoutCHW)
+    
+    // Dynamic activation scales differ per image
+    if err := model.SetWeightQuantization(quant.PerChannel); err != nil {
+        t.Fatalf("SetWeightQuantization failed: %v", err)
+    }
+    if err := model.SetActivationQuantization(quant.DynamicActivations); err != nil {
+        t.Fatalf("SetActivationQuantization failed: %v", err)
+    }
+    compare("int8")
+    
+    model.ResetPerformanceCounters()
+    if _, err := model.PredictBatch(context.Background(), images); err != nil {
+        t.Fatalf("Batch prediction failed: %v", err)
+    }
+    if info := model.GetModelInfo(); info.TotalInferences != int64(len(images)) {
+        t.Errorf("Expected %d inferences counted, got %d", len(images), info.TotalInferences)
+    }
+    
+    if _, err := model.PredictBatch(context.Background(), [][]float32{images[0], images[1][:10]}); err == nil {
+        t.Error("Expected an error for a wrongly sized image in the batch")
+    }
+}
+
+func TestTinyCNNPredictCancel(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    imageData := make([]float32, 32*32*3)
+    
+    // An already cancelled context runs no layer
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, err := model.Predict(ctx, imageData); err != context.Canceled {
+        t.Errorf("Expected context.Canceled, got %v", err)
+    }
+    if _, err := model.PredictBatch(ctx, [][]float32{imageData, imageData}); err != context.Canceled {
+        t.Errorf("Expected context.Canceled from the batch, got %v", err)
+    }
+    
+    // A context cancelled by a layer stops the prediction before the next one
+    ctx, cancel = context.WithCancel(context.Background())
+    defer cancel()
+    cancelLayer := func(input *tensor.FeatureMap, weights map[string][]float32, params map[string]float64) (*tensor.FeatureMap, error) {
+        cancel()
+        return input.Clone(), nil
+    }
+    if err := ops.RegisterCustomLayer("test_cancel", cancelLayer, ops.CustomLayerSpec{}); err != nil {
+        t.Fatalf("Failed to register custom layer: %v", err)
+    }
+    defer ops.UnregisterCustomLayer("test_cancel")
+    
+    arch := GetTinyCNNArchitecture()
+    layers := append([]LayerConfig{}, arch.Layers[:1]...)
+    layers = append(layers, LayerConfig{Type: CustomLayer, Name: "cancel1", CustomOp: "test_cancel"})
+    arch.Layers = append(layers, arch.Layers[1:]...)
+    cancelling, err := NewTinyCNNWithArchitecture(tempDir, arch)
+    if err != nil {
+        t.Fatalf("Failed to create model with custom layer: %v", err)
+    }
+    if _, err := cancelling.Predict(ctx, imageData); err != context.Canceled {
+        t.Errorf("Expected context.Canceled after the cancelling layer, got %v", err)
+    }
+    
+    // The model still predicts with a live context
+    if _, err := model.Predict(context.Background(), imageData); err != nil {
+        t.Errorf("Prediction after cancellation failed: %v", err)
+    }
+}
+
+func TestTinyCNNPredictFailureReleasesBuffers(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    // A layer that fails on the pooled output of conv1, remembering which buffer it got
+    var seen []*float32
+    failLayer := func(input *tensor.FeatureMap, weights map[string][]float32, params map[string]float64) (*tensor.FeatureMap, error) {
+        seen = append(seen, &input.Data[0])
+        return nil, fmt.Errorf("test failure")
+    }
+    if err := ops.RegisterCustomLayer("test_fail", failLayer, ops.CustomLayerSpec{}); err != nil {
+        t.Fatalf("Failed to register custom layer: %v", err)
+    }
+    defer ops.UnregisterCustomLayer("test_fail")
+    
+    arch := GetTinyCNNArchitecture()
+    layers := append([]LayerConfig{}, arch.Layers[:1]...)
+    layers = append(layers, LayerConfig{Type: CustomLayer, Name: "fail1", CustomOp: "test_fail"})
+    arch.Layers = append(layers, arch.Layers[1:]...)
+    model, err := NewTinyCNNWithArchitecture(tempDir, arch)
+    if err != nil {
+        t.Fatalf("Failed to create model with custom layer: %v", err)
+    }
+    imageData := make([]float32, 32*32*3)
+    
+    // A released conv1 output is the buffer the next prediction gets back from the pool
+    for i := 0; i < 2; i++ {
+        if _, err := model.Predict(context.Background(), imageData); err == nil {
+            t.Fatal("Expected the failing layer to fail the prediction")
+        }
+    }
+    if len(seen) != 2 || seen[0] != seen[1] {
+        t.Error("Expected a failed prediction to return its feature maps to the pool")
+    }
+}
+
+func TestTinyCNNConcurrentPredict(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = float32(i%7) / 7
+    }
+    expected, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    model.ResetPerformanceCounters()
+    
+    // One model serves several goroutines; run with -race to check the shared state
+    const goroutines, predictions = 4, 5
+    var wg sync.WaitGroup
+    failures := make(chan error, goroutines)
+    for g := 0; g < goroutines; g++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := 0; i < predictions; i++ {
+                result, err := model.Predict(context.Background(), imageData)
+                if err != nil {
+                    failures <- err
+                    return
+                }
+                if result.PredictedClass != expected.PredictedClass || result.Confidence != expected.Confidence {
+                    failures <- fmt.Errorf("concurrent prediction gave class %d (%f), expected %d (%f)",
+                        result.PredictedClass, result.Confidence, expected.PredictedClass, expected.Confidence)
+                    return
+                }
+                model.GetModelInfo()
+            }
+        }()
+    }
+    wg.Wait()
+    close(failures)
+    for err := range failures {
+        t.Error(err)
+    }
+    
+    if info := model.GetModelInfo(); info.TotalInferences != goroutines*predictions {
+        t.Errorf("Expected %d inferences counted, got %d", goroutines*predictions, info.TotalInferences)
+    }
+}
+
+func TestTinyCNNPredictStream(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    // Distinct images, plus one of the wrong size in the middle
+    const count = 6
+    images := make([][]float32, count)
+    for n := range images {
+        images[n] = make([]float32, 32*32*3)
+        for i := range images[n] {
+            images[n][i] = float32((i+n)%(n+2)) / float32(n+2)
+        }
+    }
+    images[3] = images[3][:100]
+    
+    in := make(chan []float32)
+    go func() {
+        defer close(in)
+        for _, image := range images {
+            in <- image
+        }
+    }()
+    
+    n := 0
+    for result := range model.PredictStream(context.Background(), in) {
+        if n == 3 {
+            if result.Err == nil || result.PredictedClass != -1 {
+                t.Errorf("Expected an error result for the short image, got %+v", result)
+            }
+            n++
+            continue
+        }
+        if result.Err != nil {
+            t.Fatalf("Image %d failed: %v", n, result.Err)
+        }
+        expected, err := model.Predict(context.Background(), images[n])
+        if err != nil {
+            t.Fatalf("Prediction failed: %v", err)
+        }
+        if !slicesEqual(result.Probabilities, expected.Probabilities) {
+            t.Errorf("Result %d does not match its image's prediction", n)
+        }
+        n++
+    }
+    if n != count {
+        t.Errorf("Expected %d results, got %d", count, n)
+    }
+    
+    // Cancelling closes the output even though in stays open
+    ctx, cancel := context.WithCancel(context.Background())
+    open := make(chan []float32)
+    out := model.PredictStream(ctx, open)
+    open <- images[0]
+    if result := <-out; result.Err != nil {
+        t.Fatalf("Prediction failed: %v", result.Err)
+    }
+    cancel()
+    select {
+    case _, ok := <-out:
+        if ok {
+            t.Error("Expected no results after cancellation")
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("Output was not closed after cancellation")
+    }
+}
+
+func TestTinyCNNReloadWeights(t *testing.T) {
+    oldDir, newDir := t.TempDir(), t.TempDir()
+    createTestWeights(t, oldDir)
+    createTestWeights(t, newDir)
+    
+    // Give every class its own conv7 filter in the new set, so the two sets predict differently
+    file, err := os.Create(filepath.Join(newDir, "conv7", "conv7_weight.bin"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 128*10; i++ {
+        binary.Write(file, binary.LittleEndian, float32(i%10)/100)
+    }
+    file.Close()
+    
+    model, err := NewTinyCNN(oldDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    reference, err := NewTinyCNN(newDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = float32(i%7) / 7
+    }
+    before, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    after, err := reference.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    if slicesEqual(before.Probabilities, after.Probabilities) {
+        t.Fatal("Test weight sets should predict differently")
+    }
+    
+    // Predictions running during the reloads see one set or the other, never a mix
+    const goroutines, predictions = 3, 4
+    var wg sync.WaitGroup
+    failures := make(chan error, goroutines+1)
+    for g := 0; g < goroutines; g++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := 0; i < predictions; i++ {
+                result, err := model.Predict(context.Background(), imageData)
+                if err != nil {
+                    failures <- err
+                    return
+                }
+                if !slicesEqual(result.Probabilities, before.Probabilities) && 
+                    !slicesEqual(result.Probabilities, after.Probabilities) {
+                    failures <- fmt.Errorf("prediction during reload matches neither weight set: %v", result.Probabilities)
+                    return
+                }
+            }
+        }()
+    }
+    for i := 0; i < 4; i++ {
+        dir := newDir
+        if i%2 == 1 {
+            dir = oldDir
+        }
+        if err := model.ReloadWeights(dir); err != nil {
+            failures <- err
+        }
+    }
+    wg.Wait()
+    close(failures)
+    for err := range failures {
+        t.Error(err)
+    }
+    
+    if err := model.ReloadWeights(newDir); err != nil {
+        t.Fatalf("ReloadWeights failed: %v", err)
+    }
+    result, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    if !slicesEqual(result.Probabilities, after.Probabilities) {
+        t.Errorf("Expected the new weights' prediction %v, got %v", after.Probabilities, result.Probabilities)
+    }
+    
+    // A failed reload keeps the current weights
+    if err := model.ReloadWeights(filepath.Join(oldDir, "missing")); err == nil {
+        t.Error("Expected an error reloading from a missing directory")
+    }
+    result, _ = model.Predict(context.Background(), imageData)
+    if !slicesEqual(result.Probabilities, after.Probabilities) {
+        t.Error("Failed reload changed the weights")
+    }
+    
+    // The model keeps its precision across a reload
+    if err := model.SetPrecision(tensor.PrecisionFloat16); err != nil {
+        t.Fatal(err)
+    }
+    if err := model.ReloadWeights(oldDir); err != nil {
+        t.Fatalf("ReloadWeights failed: %v", err)
+    }
+    if model.halfKernels == nil || model.weights.Kernels[0] != nil {
+        t.Error("Reloaded weights should be stored as float16")
+    }
+}
+
+// slicesEqual reports whether a and b hold exactly the same values
+func slicesEqual(a, b []float32) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
+
 func TestGetTinyCNNArchitecture(t *testing.T) {
     arch := GetTinyCNNArchitecture()
     
@@ -300,6 +833,37 @@ func TestArchitectureOutputDimensions(t *testing.T) {
     }
 }
 
+func TestArchitectureLayerCosts(t *testing.T) {
+    arch := GetTinyCNNArchitecture()
+    
+    costs, err := arch.LayerCosts()
+    if err != nil {
+        t.Fatalf("Failed to compute layer costs: %v", err)
+    }
+    if len(costs) != len(arch.Layers) {
+        t.Fatalf("Expected %d layer costs, got %d", len(arch.Layers), len(costs))
+    }
+    
+    // conv1: 3×3×3 kernel per output of 32×32×32, plus bias, batch norm and ReLU
+    conv1 := costs[0]
+    if expected := int64(2*32*32*32*3*9 + 32*32*32*4); conv1.FLOPs != expected {
+        t.Errorf("Expected %d conv1 FLOPs, got %d", expected, conv1.FLOPs)
+    }
+    if expected := int64((3*3*3*32 + 32 + 4*32) * 4); conv1.ParamBytes != expected {
+        t.Errorf("Expected %d conv1 parameter bytes, got %d", expected, conv1.ParamBytes)
+    }
+    if expected := int64((32*32*3 + 32*32*32) * 4); conv1.ActivationBytes != expected {
+        t.Errorf("Expected %d conv1 activation bytes, got %d", expected, conv1.ActivationBytes)
+    }
+    
+    // Convolutions do far more arithmetic per byte than pooling
+    for _, cost := range costs {
+        if cost.Type == MaxPoolingLayer && cost.ArithmeticIntensity >= conv1.ArithmeticIntensity {
+            t.Errorf("%s: intensity %.2f not below conv1's %.2f", cost.Name, cost.ArithmeticIntensity, conv1.ArithmeticIntensity)
+        }
+    }
+}
+
 func TestModelValidation(t *testing.T) {
     // Create temporary weights directory
     tempDir := t.TempDir()
@@ -331,13 +895,13 @@ func TestInvalidInput(t *testing.T) {
     
     // Test with wrong input size
     wrongSizeInput := make([]float32, 100) // Wrong size
-    _, err = model.Predict(wrongSizeInput)
+    _, err = model.Predict(context.Background(), wrongSizeInput)
     if err == nil {
         t.Error("Expected error for wrong input size, but got none")
     }
     
     // Test with nil input
-    _, err = model.Predict(nil)
+    _, err = model.Predict(context.Background(), nil)
     if err == nil {
         t.Error("Expected error for nil input, but got none")
     }
@@ -361,17 +925,17 @@ func BenchmarkTinyCNNPredict(b *testing.B) {
     }
     
     // Benchmark
+    b.ReportAllocs()
     b.ResetTimer()
     for i := 0; i < b.N; i++ {
-        _, err := model.Predict(imageData)
+        _, err := model.Predict(context.Background(), imageData)
         if err != nil {
             b.Fatalf("Prediction failed: %v", err)
         }
     }
 }
 
-func BenchmarkTinyCNNPredictBatch(b *testing.B) {
-    // Setup
+func BenchmarkTinyCNNPredictHWC(b *testing.B) {
     tempDir := b.TempDir()
     createTestWeights(b, tempDir)
     
@@ -379,8 +943,34 @@ func BenchmarkTinyCNNPredictBatch(b *testing.B) {
     if err != nil {
         b.Fatalf("Failed to create TinyCNN: %v", err)
     }
+    model.SetLayout(tensor.LayoutHWC)
     
-    batchSize := 10
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = 0.5
+    }
+    
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        _, err := model.Predict(context.Background(), imageData)
+        if err != nil {
+            b.Fatalf("Prediction failed: %v", err)
+        }
+    }
+}
+
+func BenchmarkTinyCNNPredictBatch(b *testing.B) {
+    // Setup
+    tempDir := b.TempDir()
+    createTestWeights(b, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        b.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    batchSize := 10
     inputSize := 32 * 32 * 3
     images := make([][]float32, batchSize)
     
@@ -394,9 +984,1264 @@ func BenchmarkTinyCNNPredictBatch(b *testing.B) {
     // Benchmark
     b.ResetTimer()
     for i := 0; i < b.N; i++ {
-        _, err := model.PredictBatch(images)
+        _, err := model.PredictBatch(context.Background(), images)
         if err != nil {
             b.Fatalf("Batch prediction failed: %v", err)
         }
     }
-}
\ No newline at end of file
+}
+
+// scaleChannelsForward multiplies each channel by a learned per-channel scale
+func scaleChannelsForward(input *tensor.FeatureMap, weights map[string][]float32, params map[string]float64) (*tensor.FeatureMap, error) {
+    scale := weights["scale"]
+    output := input.Clone()
+    plane := input.Height * input.Width
+    for c := 0; c < input.Channels; c++ {
+        for i := 0; i < plane; i++ {
+            output.Data[c*plane+i] *= scale[c] * float32(params["gain"])
+        }
+    }
+    return output, nil
+}
+
+func TestTinyCNNCustomLayer(t *testing.T) {
+    spec := ops.CustomLayerSpec{
+        Weights: []ops.CustomWeight{{Name: "scale", Size: 1, PerChannel: true}},
+    }
+    if err := ops.RegisterCustomLayer("test_scale_channels", scaleChannelsForward, spec); err != nil {
+        t.Fatalf("Failed to register custom layer: %v", err)
+    }
+    defer ops.UnregisterCustomLayer("test_scale_channels")
+    
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    // Insert the custom op between maxpool3 (128 channels) and conv7
+    arch := GetTinyCNNArchitecture()
+    layers := append([]LayerConfig{}, arch.Layers[:9]...)
+    layers = append(layers, LayerConfig{
+        Type:         CustomLayer,
+        Name:         "scale1",
+        CustomOp:     "test_scale_channels",
+        CustomParams: map[string]float64{"gain": 1},
+    })
+    arch.Layers = append(layers, arch.Layers[9:]...)
+    
+    // Missing weights are reported
+    if _, err := NewTinyCNNWithArchitecture(tempDir, arch); err == nil {
+        t.Fatal("Expected error for missing custom layer weights")
+    }
+    
+    scaleDir := filepath.Join(tempDir, "scale1")
+    if err := os.MkdirAll(scaleDir, 0755); err != nil {
+        t.Fatalf("Failed to create layer directory: %v", err)
+    }
+    createFloatArrayFile(t, filepath.Join(scaleDir, "scale1_scale.bin"), 128, 1.0)
+    
+    custom, err := NewTinyCNNWithArchitecture(tempDir, arch)
+    if err != nil {
+        t.Fatalf("Failed to create model with custom layer: %v", err)
+    }
+    baseline, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = float32(i%13) / 13
+    }
+    
+    // A unit scale must leave predictions unchanged
+    expected, err := baseline.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    result, err := custom.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction with custom layer failed: %v", err)
+    }
+    for i := range expected.Probabilities {
+        if result.Probabilities[i] != expected.Probabilities[i] {
+            t.Fatalf("Probability %d: expected %f, got %f", i, expected.Probabilities[i], result.Probabilities[i])
+        }
+    }
+}
+
+func TestArchitectureFromConfig(t *testing.T) {
+    mc := config.ModelConfig{
+        InputHeight:   8,
+        InputWidth:    8,
+        InputChannels: 1,
+        NumClasses:    2,
+        Layers: []config.LayerConfig{
+            {Name: "conv1", Type: "convolution", KernelSize: 3, Filters: 4, Stride: 1, Padding: 1},
+            {Name: "plugin", Type: "custom", Op: "my_op", Params: map[string]float64{"alpha": 0.5}},
+            {Name: "global_maxpool", Type: "global_max_pooling"},
+        },
+    }
+    
+    arch, err := ArchitectureFromConfig(mc)
+    if err != nil {
+        t.Fatalf("Failed to convert config: %v", err)
+    }
+    if arch.InputHeight != 8 || arch.NumClasses != 2 || len(arch.Layers) != 3 {
+        t.Errorf("Unexpected architecture: %+v", arch)
+    }
+    if arch.Layers[1].Type != CustomLayer || arch.Layers[1].CustomOp != "my_op" || arch.Layers[1].CustomParams["alpha"] != 0.5 {
+        t.Errorf("Custom layer not converted: %+v", arch.Layers[1])
+    }
+    
+    // The op is not registered, so validation must fail
+    if err := arch.ValidateArchitecture(); err == nil {
+        t.Error("Expected validation error for unregistered custom op")
+    }
+    
+    // A 3D input carries its depth through to the architecture
+    mc.InputDepth = 4
+    mc.Layers = []config.LayerConfig{
+        {Name: "conv3d_1", Type: "convolution_3d", KernelSize: 3, Filters: 2, Stride: 1, Padding: 1},
+        {Name: "global_avgpool", Type: "global_average_pooling"},
+    }
+    arch, err = ArchitectureFromConfig(mc)
+    if err != nil {
+        t.Fatalf("Failed to convert 3D config: %v", err)
+    }
+    if arch.InputDepth != 4 || arch.InputSize() != 4*8*8*1 {
+        t.Errorf("Expected depth 4 and input size %d, got depth %d and size %d", 4*8*8*1, arch.InputDepth, arch.InputSize())
+    }
+    if err := arch.ValidateArchitecture(); err != nil {
+        t.Errorf("3D config should validate: %v", err)
+    }
+    
+    // Layer types Predict cannot run where they are placed are rejected with a reason
+    placementTests := []struct {
+        name   string
+        layers []config.LayerConfig
+        reason string
+    }{
+        {"batch norm layer", []config.LayerConfig{
+            {Name: "conv1", Type: "convolution", KernelSize: 3, Filters: 2, Stride: 1},
+            {Name: "bn1", Type: "batch_norm"},
+            {Name: "global_maxpool", Type: "global_max_pooling"},
+        }, "apply_batch_norm"},
+        {"softmax before global pooling", []config.LayerConfig{
+            {Name: "conv1", Type: "convolution", KernelSize: 3, Filters: 2, Stride: 1},
+            {Name: "softmax", Type: "softmax"},
+            {Name: "global_maxpool", Type: "global_max_pooling"},
+        }, "after global pooling"},
+        {"softmax after conv", []config.LayerConfig{
+            {Name: "conv1", Type: "convolution", KernelSize: 3, Filters: 2, Stride: 1},
+            {Name: "softmax", Type: "softmax"},
+        }, "after global pooling"},
+    }
+    for _, tt := range placementTests {
+        _, err := ArchitectureFromConfig(config.ModelConfig{InputHeight: 8, InputWidth: 8, InputChannels: 1,
+            NumClasses: 2, Layers: tt.layers})
+        if err == nil || !strings.Contains(err.Error(), tt.reason) {
+            t.Errorf("%s: expected an error mentioning %q, got %v", tt.name, tt.reason, err)
+        }
+    }
+    
+    // A trailing softmax after global pooling is the standard head
+    mc.InputDepth = 0
+    mc.Layers = []config.LayerConfig{
+        {Name: "conv1", Type: "convolution", KernelSize: 3, Filters: 2, Stride: 1, Padding: 1},
+        {Name: "global_avgpool", Type: "global_average_pooling"},
+        {Name: "softmax", Type: "softmax"},
+    }
+    if _, err := ArchitectureFromConfig(mc); err != nil {
+        t.Errorf("Unexpected error for softmax after global pooling: %v", err)
+    }
+    
+    mc.Layers[0].Type = "deconvolution"
+    if _, err := ArchitectureFromConfig(mc); err == nil {
+        t.Error("Expected error for unknown layer type")
+    }
+}
+
+func TestArchitectureToDOT(t *testing.T) {
+    arch := GetTinyCNNArchitecture()
+    dot, err := arch.ToDOT()
+    if err != nil {
+        t.Fatalf("ToDOT failed: %v", err)
+    }
+    
+    for _, want := range []string{
+        "digraph TinyCNN {",
+        `n1 [label="{conv1|convolution 3×3, 32 filters, stride 1, pad 1|batch norm + ReLU|32×32×32|1.0K params · `,
+        `n3 [label="{maxpool1|max_pooling 2×2, stride 2|16×16×32|0 params · `,
+        `n2 -> n3 [label=" 32×32×32"];`,
+        "12 layers",
+    } {
+        if !strings.Contains(dot, want) {
+            t.Errorf("Expected %q in\n%s", want, dot)
+        }
+    }
+    if nodes := strings.Count(dot, " -> "); nodes != len(arch.Layers) {
+        t.Errorf("Expected %d edges, got %d", len(arch.Layers), nodes)
+    }
+    
+    arch.Layers[0].Name = "conv|1"
+    dot, err = arch.ToDOT()
+    if err != nil {
+        t.Fatalf("ToDOT failed: %v", err)
+    }
+    if !strings.Contains(dot, `{conv\|1|`) {
+        t.Error("Expected the record separator in a layer name escaped")
+    }
+}
+
+func TestTinyCNNAutotune(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = float32(i%7) / 7
+    }
+    expected, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    
+    results, err := model.Autotune(filepath.Join(tempDir, "tuning.json"))
+    if err != nil {
+        t.Fatalf("Autotune failed: %v", err)
+    }
+    if len(results) != 7 {
+        t.Errorf("Expected a choice for each of the 7 conv layers, got %d", len(results))
+    }
+    
+    // Whatever was chosen must compute the same result
+    result, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction after autotune failed: %v", err)
+    }
+    for i := range expected.Probabilities {
+        diff := expected.Probabilities[i] - result.Probabilities[i]
+        if diff > 1e-5 || diff < -1e-5 {
+            t.Fatalf("Probability %d changed after autotune: %f vs %f", i, expected.Probabilities[i], result.Probabilities[i])
+        }
+    }
+}
+
+func TestTinyCNNWeightLoadTimes(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    loadTimes := model.WeightLoadTimes()
+    if len(loadTimes) != 7 {
+        t.Fatalf("Expected load times for 7 conv layers, got %d", len(loadTimes))
+    }
+    
+    // conv1: 3x3x3x32 kernel, 32 biases and 4x32 batch norm values
+    if loadTimes[0].Layer != "conv1" || loadTimes[0].Bytes != (3*3*3*32+32+4*32)*4 {
+        t.Errorf("Unexpected conv1 load record: %+v", loadTimes[0])
+    }
+    // conv7 has no batch norm
+    if loadTimes[6].Bytes != (128*10+10)*4 {
+        t.Errorf("Unexpected conv7 size: %d bytes", loadTimes[6].Bytes)
+    }
+}
+
+func TestTinyCNNFloat16(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = float32(i%11) / 11
+    }
+    expected, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    fullBytes := model.GetModelInfo().WeightBytes
+    
+    if err := model.SetPrecision(tensor.PrecisionFloat16); err != nil {
+        t.Fatalf("SetPrecision failed: %v", err)
+    }
+    
+    info := model.GetModelInfo()
+    if info.Precision != tensor.PrecisionFloat16 || info.WeightBytes >= fullBytes*6/10 {
+        t.Errorf("Expected float16 weights to take about half of %d bytes, got %d", fullBytes, info.WeightBytes)
+    }
+    if err := model.ValidateModel(); err != nil {
+        t.Errorf("Float16 model failed validation: %v", err)
+    }
+    
+    result, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Float16 prediction failed: %v", err)
+    }
+    for i := range expected.Probabilities {
+        diff := expected.Probabilities[i] - result.Probabilities[i]
+        if diff > 1e-2 || diff < -1e-2 {
+            t.Errorf("Probability %d drifted too far in float16: %f vs %f", i, expected.Probabilities[i], result.Probabilities[i])
+        }
+    }
+    
+    // Back to float32 keeps working
+    if err := model.SetPrecision(tensor.PrecisionFloat32); err != nil {
+        t.Fatalf("SetPrecision failed: %v", err)
+    }
+    if _, err := model.Predict(context.Background(), imageData); err != nil {
+        t.Fatalf("Prediction after returning to float32 failed: %v", err)
+    }
+}
+
+func TestTinyCNNFloat64(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = float32(i%13) / 13
+    }
+    expected, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    input, _ := tensor.NewFeatureMapFromData(imageData, 32, 32, 3)
+    conv1, err := model.RunLayer("conv1", input)
+    if err != nil {
+        t.Fatalf("RunLayer failed: %v", err)
+    }
+    
+    if err := model.SetPrecision(tensor.PrecisionFloat64); err != nil {
+        t.Fatalf("SetPrecision failed: %v", err)
+    }
+    if model.Precision() != tensor.PrecisionFloat64 {
+        t.Errorf("Expected float64 precision, got %s", model.Precision())
+    }
+    
+    // Only accumulation error separates the two runs
+    result, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Float64 prediction failed: %v", err)
+    }
+    for i := range expected.Probabilities {
+        diff := expected.Probabilities[i] - result.Probabilities[i]
+        if diff > 1e-4 || diff < -1e-4 {
+            t.Errorf("Probability %d differs in float64: %f vs %f", i, expected.Probabilities[i], result.Probabilities[i])
+        }
+    }
+    wide, err := model.RunLayer("conv1", input)
+    if err != nil {
+        t.Fatalf("Float64 RunLayer failed: %v", err)
+    }
+    for i, v := range conv1.Data {
+        if diff := v - wide.Data[i]; diff > 1e-4 || diff < -1e-4 {
+            t.Fatalf("conv1 element %d differs in float64: %f vs %f", i, v, wide.Data[i])
+        }
+    }
+    
+    // Batches run one image at a time, giving the same results
+    results, err := model.PredictBatch(context.Background(), [][]float32{imageData, imageData})
+    if err != nil {
+        t.Fatalf("Float64 batch prediction failed: %v", err)
+    }
+    for _, batched := range results {
+        if !slicesEqual(batched.Probabilities, result.Probabilities) {
+            t.Error("Expected batched float64 predictions to match Predict")
+        }
+    }
+    
+    if err := model.SetWeightQuantization(quant.PerChannel); err == nil {
+        t.Error("int8 weight quantization should be rejected in float64 precision")
+    }
+}
+
+func TestTinyCNNWarmup(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    if model.Ready() {
+        t.Error("Model should not be ready before warm-up")
+    }
+    
+    stats, err := model.Warmup(3)
+    if err != nil {
+        t.Fatalf("Warmup failed: %v", err)
+    }
+    if !model.Ready() {
+        t.Error("Model should be ready after warm-up")
+    }
+    if stats.PagesTouched == 0 || stats.WeightBytes != model.GetModelInfo().WeightBytes {
+        t.Errorf("Expected all %d weight bytes covered, got %+v", model.GetModelInfo().WeightBytes, stats)
+    }
+    if stats.Inferences != 3 {
+        t.Errorf("Expected 3 dummy inferences, got %d", stats.Inferences)
+    }
+    if model.GetModelInfo().TotalInferences != 0 {
+        t.Error("Warm-up inference should not count in performance statistics")
+    }
+    
+    // The auto backend is tuned once; a second warm-up doesn't tune again
+    if len(stats.Autotuned) == 0 {
+        t.Error("Expected the auto backend to be autotuned")
+    }
+    again, err := model.Warmup(1)
+    if err != nil {
+        t.Fatalf("Second warm-up failed: %v", err)
+    }
+    if again.Autotuned != nil || again.Inferences != 1 {
+        t.Errorf("Expected one inference and no autotuning, got %+v", again)
+    }
+    
+    // A fixed backend is never autotuned
+    fixed, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    if err := fixed.ConfigureEngine(ops.EngineOptions{Backend: ops.AlgoGEMM, Pooling: true}); err != nil {
+        t.Fatal(err)
+    }
+    if stats, err := fixed.Warmup(0); err != nil || stats.Autotuned != nil || stats.Inferences != 1 {
+        t.Errorf("Expected one inference and no autotuning for gemm, got %+v (%v)", stats, err)
+    }
+}
+
+func TestTinyCNNRunLayer(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = float32(i%5) / 5
+    }
+    expected, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    
+    // Running every layer in turn reproduces Predict
+    current, _ := tensor.NewFeatureMapFromData(imageData, 32, 32, 3)
+    original := current.Clone()
+    for _, layer := range model.architecture.Layers {
+        next, err := model.RunLayer(layer.Name, current)
+        if err != nil {
+            t.Fatalf("RunLayer(%s) failed: %v", layer.Name, err)
+        }
+        current = next
+    }
+    if current.Height != 1 || current.Width != 1 || current.Channels != 10 {
+        t.Fatalf("Expected a 1x1x10 output, got %dx%dx%d", current.Height, current.Width, current.Channels)
+    }
+    for i, p := range expected.Probabilities {
+        if diff := p - current.Data[i]; diff > 1e-6 || diff < -1e-6 {
+            t.Errorf("Probability %d: Predict %f, layer by layer %f", i, p, current.Data[i])
+        }
+    }
+    
+    // Arbitrary spatial sizes are accepted; the input is left untouched
+    small := tensor.NewFeatureMap(5, 7, 3)
+    small.RandomFill()
+    output, err := model.RunLayer("conv1", small)
+    if err != nil {
+        t.Fatalf("RunLayer on 5x7 input failed: %v", err)
+    }
+    if output.Height != 5 || output.Width != 7 || output.Channels != 32 {
+        t.Errorf("Expected 5x7x32 output, got %dx%dx%d", output.Height, output.Width, output.Channels)
+    }
+    
+    first, _ := tensor.NewFeatureMapFromData(imageData, 32, 32, 3)
+    model.RunLayer("conv1", first)
+    for i := range original.Data {
+        if first.Data[i] != original.Data[i] {
+            t.Fatal("RunLayer modified its input")
+        }
+    }
+    
+    if _, err := model.RunLayer("conv99", small); err == nil {
+        t.Error("Expected error for unknown layer")
+    }
+    if _, err := model.RunLayer("conv2", small); err == nil {
+        t.Error("Expected error for channel mismatch")
+    }
+}
+
+func TestTinyCNNActivationDump(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    dumpDir := filepath.Join(t.TempDir(), "dump")
+    writer, err := dump.NewWriter(dump.Options{Dir: dumpDir, Layers: []string{"conv1", "maxpool1", "global_maxpool", "softmax"}})
+    if err != nil {
+        t.Fatalf("NewWriter failed: %v", err)
+    }
+    model.SetActivationDump(writer)
+    
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = float32(i%7) / 7
+    }
+    var result *PredictionResult
+    for i := 0; i < 2; i++ {
+        if result, err = model.Predict(context.Background(), imageData); err != nil {
+            t.Fatalf("Prediction failed: %v", err)
+        }
+    }
+    
+    manifest, err := writer.Close()
+    if err != nil {
+        t.Fatalf("Close failed: %v", err)
+    }
+    if len(manifest.Entries) != 8 {
+        t.Fatalf("Expected 2 samples x 4 layers, got %d entries", len(manifest.Entries))
+    }
+    
+    // The logits and the probabilities of the final layers are dumped too
+    for _, layer := range []string{"global_maxpool", "softmax"} {
+        entry, ok := manifest.Find(1, layer)
+        if !ok {
+            t.Fatalf("%s of sample 1 missing from manifest", layer)
+        }
+        if _, err := os.Stat(filepath.Join(dumpDir, filepath.FromSlash(entry.File))); err != nil {
+            t.Errorf("%s dump file: %v", layer, err)
+        }
+    }
+    entry, _ := manifest.Find(1, "softmax")
+    probabilities, err := dump.ReadActivation(dumpDir, manifest, entry)
+    if err != nil {
+        t.Fatalf("ReadActivation failed: %v", err)
+    }
+    if !slicesEqual(probabilities.Data, result.Probabilities) {
+        t.Errorf("Dumped softmax %v does not match the probabilities %v", probabilities.Data, result.Probabilities)
+    }
+    
+    // The dumped conv1 output matches running the layer directly
+    entry, ok := manifest.Find(1, "conv1")
+    if !ok {
+        t.Fatal("conv1 of sample 1 missing from manifest")
+    }
+    dumped, err := dump.ReadActivation(dumpDir, manifest, entry)
+    if err != nil {
+        t.Fatalf("ReadActivation failed: %v", err)
+    }
+    input, _ := tensor.NewFeatureMapFromData(imageData, 32, 32, 3)
+    expected, err := model.RunLayer("conv1", input)
+    if err != nil {
+        t.Fatalf("RunLayer failed: %v", err)
+    }
+    for i, v := range expected.Data {
+        if dumped.Data[i] != v {
+            t.Fatalf("Value %d: dumped %f, expected %f", i, dumped.Data[i], v)
+        }
+    }
+}
+
+func TestTinyCNNActivationStats(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    collector := dump.NewStatsCollector(16)
+    model.SetActivationStats(collector)
+    
+    images := make([][]float32, 3)
+    for b := range images {
+        images[b] = make([]float32, 32*32*3)
+        for i := range images[b] {
+            images[b][i] = float32((i+b)%7) / 7
+        }
+    }
+    if _, err := model.Predict(context.Background(), images[0]); err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    if _, err := model.PredictBatch(context.Background(), images[1:]); err != nil {
+        t.Fatalf("PredictBatch failed: %v", err)
+    }
+    model.SetActivationStats(nil)
+    
+    report := collector.Report()
+    if report.Samples != 3 {
+        t.Errorf("Expected 3 samples, got %d", report.Samples)
+    }
+    if len(report.Layers) == 0 || report.Layers[0].Layer != "conv1" {
+        t.Fatalf("Expected layers in model order starting at conv1, got %+v", report.Layers)
+    }
+    if last := report.Layers[len(report.Layers)-1]; last.Layer != "softmax" || last.Samples != 3 || last.Channels != 10 {
+        t.Errorf("Expected the last layer profiled to be softmax over 3 samples of 10 classes, got %s over %d of %d",
+            last.Layer, last.Samples, last.Channels)
+    }
+    
+    // conv1's range covers the layer's output for every image
+    conv1 := report.Layers[0]
+    for _, image := range images {
+        input, _ := tensor.NewFeatureMapFromData(image, 32, 32, 3)
+        output, err := model.RunLayer("conv1", input)
+        if err != nil {
+            t.Fatalf("RunLayer failed: %v", err)
+        }
+        for _, v := range output.Data {
+            if float64(v) < conv1.Min || float64(v) > conv1.Max {
+                t.Fatalf("conv1 value %f outside reported range [%f, %f]", v, conv1.Min, conv1.Max)
+            }
+        }
+    }
+    var counted int64
+    for _, count := range conv1.Histogram.Counts {
+        counted += count
+    }
+    if counted != conv1.Count || conv1.Count != 3*32*32*32 {
+        t.Errorf("Expected %d values in the histogram, got %d of %d", 3*32*32*32, counted, conv1.Count)
+    }
+}
+
+func TestTinyCNNExtract(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    layer, err := model.EmbeddingLayer()
+    if err != nil || layer != "maxpool3" {
+        t.Fatalf("Expected maxpool3 as the embedding layer, got %q (%v)", layer, err)
+    }
+    
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = float32(i%5) / 5
+    }
+    embedding, err := model.Extract(context.Background(), imageData, "")
+    if err != nil {
+        t.Fatalf("Extract failed: %v", err)
+    }
+    
+    // The embedding is the mean over space of running the layers up to maxpool3
+    current, _ := tensor.NewFeatureMapFromData(imageData, 32, 32, 3)
+    for _, layerConfig := range model.architecture.Layers {
+        if current, err = model.RunLayer(layerConfig.Name, current); err != nil {
+            t.Fatalf("RunLayer(%s) failed: %v", layerConfig.Name, err)
+        }
+        if layerConfig.Name == layer {
+            break
+        }
+    }
+    if len(embedding) != current.Channels {
+        t.Fatalf("Expected %d dimensions, got %d", current.Channels, len(embedding))
+    }
+    for c := range embedding {
+        var sum float64
+        for h := 0; h < current.Height; h++ {
+            for w := 0; w < current.Width; w++ {
+                sum += float64(current.Get(c, h, w))
+            }
+        }
+        mean := sum / float64(current.Height*current.Width)
+        if math.Abs(mean-float64(embedding[c])) > 1e-6*math.Max(1, math.Abs(mean)) {
+            t.Errorf("Channel %d: expected %f, got %f", c, mean, embedding[c])
+        }
+    }
+    
+    if _, err := model.Extract(context.Background(), imageData, "conv99"); err == nil {
+        t.Error("Expected error for unknown layer")
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, err := model.Extract(ctx, imageData, ""); !errors.Is(err, context.Canceled) {
+        t.Errorf("Expected context.Canceled, got %v", err)
+    }
+}
+
+func TestTinyCNNWeightQuantization(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    imageData := make([]float32, 32*32*3)
+    for i := range imageData {
+        imageData[i] = float32(i%11) / 11
+    }
+    expected, err := model.Predict(context.Background(), imageData)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    floatBytes := model.GetModelInfo().WeightBytes
+    
+    for _, granularity := range []quant.Granularity{quant.PerTensor, quant.PerChannel} {
+        if err := model.SetWeightQuantization(granularity); err != nil {
+            t.Fatalf("SetWeightQuantization(%s) failed: %v", granularity, err)
+        }
+        if model.WeightQuantization() != granularity {
+            t.Errorf("Expected %s, got %s", granularity, model.WeightQuantization())
+        }
+        if err := model.ValidateModel(); err != nil {
+            t.Errorf("Quantized model failed validation: %v", err)
+        }
+        
+        info := model.GetModelInfo()
+        if info.WeightBytes >= floatBytes {
+            t.Errorf("%s: int8 kernels should shrink weight memory (%d >= %d)", granularity, info.WeightBytes, floatBytes)
+        }
+        
+        result, err := model.Predict(context.Background(), imageData)
+        if err != nil {
+            t.Fatalf("%s prediction failed: %v", granularity, err)
+        }
+        for i, p := range expected.Probabilities {
+            if diff := p - result.Probabilities[i]; diff > 0.05 || diff < -0.05 {
+                t.Errorf("%s: probability %d is %f, float gives %f", granularity, i, result.Probabilities[i], p)
+            }
+        }
+    }
+    
+    if err := model.SetPrecision(tensor.PrecisionFloat16); err == nil {
+        t.Error("float16 precision should be rejected while weights are quantized")
+    }
+    
+    if err := model.SetWeightQuantization(quant.None); err != nil {
+        t.Fatalf("SetWeightQuantization(none) failed: %v", err)
+    }
+    if model.GetModelInfo().WeightBytes != floatBytes {
+        t.Error("Dequantizing should restore float32 kernels")
+    }
+}
+
+func TestTinyCNNConvKernel(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    kernel, err := model.ConvKernel("conv5")
+    if err != nil {
+        t.Fatalf("ConvKernel failed: %v", err)
+    }
+    if kernel.Channels != 64 || kernel.Filters != 128 {
+        t.Errorf("Expected a 64->128 kernel, got %d->%d", kernel.Channels, kernel.Filters)
+    }
+    
+    // The copy is the caller's to modify
+    kernel.Weights[0] = 1000
+    if again, _ := model.ConvKernel("conv5"); again.Weights[0] == 1000 {
+        t.Error("ConvKernel should return a copy")
+    }
+    
+    for _, name := range []string{"maxpool1", "conv99"} {
+        if _, err := model.ConvKernel(name); err == nil {
+            t.Errorf("ConvKernel(%q) should fail", name)
+        }
+    }
+}
+
+func TestTinyCNNKernelShapeHint(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    arch := GetTinyCNNArchitecture()
+    arch.Layers[0].Filters = 48
+    
+    _, err := NewTinyCNNWithArchitecture(tempDir, arch)
+    if err == nil {
+        t.Fatal("Expected an error for weights that do not match the configured filters")
+    }
+    if hint := errs.Hint(err); !strings.Contains(hint, "filters: 32") {
+        t.Errorf("Hint should suggest the filters in the weights, got %q", hint)
+    }
+}
+
+func TestTinyCNNQuantizedWeightFiles(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    reference, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    // Replace conv2's float file with its per-tensor int8 version
+    kernel, err := reference.ConvKernel("conv2")
+    if err != nil {
+        t.Fatal(err)
+    }
+    qk, err := quant.QuantizeKernel(kernel, quant.PerTensor)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(tempDir, "conv2", "conv2_weight.bin"), quant.EncodeKernelFile(qk), 0644); err != nil {
+        t.Fatal(err)
+    }
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to load quantized weights: %v", err)
+    }
+    if !model.weights.IsQuantized(1) || model.weights.IsQuantized(0) {
+        t.Fatal("Only conv2 should be stored as int8")
+    }
+    if model.WeightQuantization() != quant.PerTensor {
+        t.Errorf("Expected per-tensor quantization, got %s", model.WeightQuantization())
+    }
+    if err := model.ValidateModel(); err != nil {
+        t.Errorf("ValidateModel failed: %v", err)
+    }
+    
+    input := make([]float32, 32*32*3)
+    for i := range input {
+        input[i] = float32(i%255) / 255
+    }
+    if _, err := model.Predict(context.Background(), input); err != nil {
+        t.Fatalf("Predict failed: %v", err)
+    }
+    
+    // Back to float32, the kernel holds the dequantized values
+    if err := model.SetWeightQuantization(quant.None); err != nil {
+        t.Fatal(err)
+    }
+    if model.WeightQuantization() != quant.None || model.weights.Kernels[1] == nil {
+        t.Error("SetWeightQuantization(none) should restore a float32 kernel")
+    }
+}
+
+func TestTinyCNNInt8Activations(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    if err := model.SetWeightQuantization(quant.PerChannel); err != nil {
+        t.Fatal(err)
+    }
+    
+    input := make([]float32, 32*32*3)
+    for i := range input {
+        input[i] = float32(i%255) / 255
+    }
+    expected, err := model.Predict(context.Background(), input)
+    if err != nil {
+        t.Fatalf("Predict failed: %v", err)
+    }
+    
+    // Calibrate on the input itself, as gocnn-quantize does over its image set
+    calibrator := quant.NewCalibrator()
+    current := &tensor.FeatureMap{Height: 32, Width: 32, Channels: 3, Data: input}
+    calibrator.ObserveFeatureMap("input", current)
+    for _, layer := range model.architecture.Layers {
+        current, err = model.RunLayer(layer.Name, current)
+        if err != nil {
+            t.Fatal(err)
+        }
+        calibrator.ObserveFeatureMap(layer.Name, current)
+    }
+    
+    model.SetActivationScales(calibrator.Ranges())
+    if n := model.Int8ConvLayers(); n != 7 {
+        t.Fatalf("Expected 7 int8 conv layers, got %d", n)
+    }
+    got, err := model.Predict(context.Background(), input)
+    if err != nil {
+        t.Fatalf("Predict failed: %v", err)
+    }
+    for i, p := range expected.Probabilities {
+        if math.Abs(float64(p-got.Probabilities[i])) > 0.02 {
+            t.Errorf("Class %d: int8 probability %f, dequantized %f", i, got.Probabilities[i], p)
+        }
+    }
+    
+    // Without scales the layers dequantize their kernels again
+    model.SetActivationScales(nil)
+    if n := model.Int8ConvLayers(); n != 0 {
+        t.Errorf("Expected no int8 conv layers after clearing scales, got %d", n)
+    }
+}
+
+func TestTinyCNNDynamicActivations(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    if err := model.SetWeightQuantization(quant.PerChannel); err != nil {
+        t.Fatal(err)
+    }
+    
+    input := make([]float32, 32*32*3)
+    for i := range input {
+        input[i] = float32(i%255) / 255
+    }
+    expected, err := model.Predict(context.Background(), input)
+    if err != nil {
+        t.Fatalf("Predict failed: %v", err)
+    }
+    
+    // No calibration: every int8 kernel runs on the GEMM with scales measured per input
+    if err := model.SetActivationQuantization(quant.DynamicActivations); err != nil {
+        t.Fatal(err)
+    }
+    if n := model.Int8ConvLayers(); n != 7 {
+        t.Fatalf("Expected 7 int8 conv layers, got %d", n)
+    }
+    if mode := model.GetModelInfo().ActivationQuantization; mode != quant.DynamicActivations {
+        t.Errorf("Expected dynamic activation quantization in model info, got %s", mode)
+    }
+    got, err := model.Predict(context.Background(), input)
+    if err != nil {
+        t.Fatalf("Predict failed: %v", err)
+    }
+    for i, p := range expected.Probabilities {
+        if math.Abs(float64(p-got.Probabilities[i])) > 0.02 {
+            t.Errorf("Class %d: int8 probability %f, dequantized %f", i, got.Probabilities[i], p)
+        }
+    }
+    
+    // Float kernels stay float whatever the activation mode
+    if err := model.SetWeightQuantization(quant.None); err != nil {
+        t.Fatal(err)
+    }
+    if n := model.Int8ConvLayers(); n != 0 {
+        t.Errorf("Expected no int8 conv layers with float kernels, got %d", n)
+    }
+    if err := model.SetActivationQuantization(quant.ActivationMode(7)); err == nil {
+        t.Error("Expected an error for an unknown activation mode")
+    }
+}
+
+func TestTinyCNNLayerCosts(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    
+    info := model.GetModelInfo()
+    var flops, paramBytes int64
+    for _, cost := range info.Layers {
+        flops += cost.FLOPs
+        paramBytes += cost.ParamBytes
+    }
+    if info.TotalFLOPs == 0 || flops != info.TotalFLOPs {
+        t.Errorf("Layer FLOPs add up to %d, total is %d", flops, info.TotalFLOPs)
+    }
+    if paramBytes != info.WeightBytes {
+        t.Errorf("Layer parameters add up to %d bytes, the model holds %d", paramBytes, info.WeightBytes)
+    }
+}
+
+func TestTinyCNNMemoryTracking(t *testing.T) {
+    tempDir := t.TempDir()
+    createTestWeights(t, tempDir)
+    
+    model, err := NewTinyCNN(tempDir)
+    if err != nil {
+        t.Fatalf("Failed to create TinyCNN: %v", err)
+    }
+    image := make([]float32, 32*32*3)
+    
+    result, err := model.Predict(context.Background(), image)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    if result.LayerAllocs != nil {
+        t.Errorf("Expected no layer allocations without tracking, got %v", result.LayerAllocs)
+    }
+    
+    model.SetMemoryTracking(true)
+    result, err = model.Predict(context.Background(), image)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    results, err := model.PredictBatch(context.Background(), [][]float32{image, image})
+    if err != nil {
+        t.Fatalf("Batch prediction failed: %v", err)
+    }
+    for _, r := range append(results, result) {
+        for _, layer := range model.architecture.Layers {
+            if _, ok := r.LayerAllocs[layer.Name]; !ok {
+                t.Errorf("No allocations recorded for layer %s", layer.Name)
+            }
+        }
+        if _, ok := r.LayerAllocs["softmax"]; !ok {
+            t.Error("No allocations recorded for softmax")
+        }
+    }
+}
+
+// createConvNDFiles writes the kernel and bias files of a 1D or 3D conv layer,
+// with values that vary so a misplaced weight changes the output
+func createConvNDFiles(t testing.TB, weightsDir, name string, kernelValues, filters int) {
+    layerDir := filepath.Join(weightsDir, name)
+    if err := os.MkdirAll(layerDir, 0755); err != nil {
+        t.Fatalf("Failed to create layer directory: %v", err)
+    }
+    
+    values := make([]float32, kernelValues)
+    for i := range values {
+        values[i] = float32(i%7-3) / 10
+    }
+    bias := make([]float32, filters)
+    for i := range bias {
+        bias[i] = float32(i) / 20
+    }
+    for file, data := range map[string][]float32{name + "_weight.bin": values, name + "_bias.bin": bias} {
+        f, err := os.Create(filepath.Join(layerDir, file))
+        if err != nil {
+            t.Fatalf("Failed to create %s: %v", file, err)
+        }
+        if err := binary.Write(f, binary.LittleEndian, data); err != nil {
+            t.Fatalf("Failed to write %s: %v", file, err)
+        }
+        f.Close()
+    }
+}
+
+// checkConvNDPredict runs image through Predict and PredictBatch and compares
+// the probabilities with expected
+func checkConvNDPredict(t *testing.T, model *TinyCNN, image, expected []float32) {
+    t.Helper()
+    result, err := model.Predict(context.Background(), image)
+    if err != nil {
+        t.Fatalf("Prediction failed: %v", err)
+    }
+    for i := range expected {
+        if diff := result.Probabilities[i] - expected[i]; diff > 1e-5 || diff < -1e-5 {
+            t.Errorf("Probability %d: expected %f, got %f", i, expected[i], result.Probabilities[i])
+        }
+    }
+    
+    results, err := model.PredictBatch(context.Background(), [][]float32{image, image})
+    if err != nil {
+        t.Fatalf("Batch prediction failed: %v", err)
+    }
+    for _, batched := range results {
+        if !slicesEqual(batched.Probabilities, result.Probabilities) {
+            t.Errorf("Expected batched predictions to match Predict: %v vs %v", batched.Probabilities, result.Probabilities)
+        }
+    }
+}
+
+func TestTinyCNNConvolution1D(t *testing.T) {
+    tempDir := t.TempDir()
+    createConvNDFiles(t, tempDir, "conv1d_1", 3*2*4, 4)
+    createConvNDFiles(t, tempDir, "conv1d_2", 3*4*3, 3)
+    
+    arch := &TinyCNNArchitecture{
+        InputHeight:   1,
+        InputWidth:    16,
+        InputChannels: 2,
+        NumClasses:    3,
+        Layers: []LayerConfig{
+            {Type: Convolution1DLayer, Name: "conv1d_1", KernelSize: 3, Filters: 4, Stride: 1, Padding: 1, ApplyActivation: true},
+            {Type: Convolution1DLayer, Name: "conv1d_2", KernelSize: 3, Filters: 3, Stride: 2, Padding: 1},
+            {Type: GlobalAveragePoolingLayer, Name: "global_avgpool"},
+        },
+    }
+    model, err := NewTinyCNNWithArchitecture(tempDir, arch)
+    if err != nil {
+        t.Fatalf("Failed to create 1D model: %v", err)
+    }
+    
+    image := make([]float32, 16*2)
+    for i := range image {
+        image[i] = float32(i%5) / 5
+    }
+    
+    // Reference: the ops run directly on the loaded weights
+    loader := data.NewWeightLoader(tempDir)
+    kernel1, _ := loader.LoadKernel1D("conv1d_1/conv1d_1_weight.bin", 3, 2, 4)
+    bias1, _ := loader.LoadBias("conv1d_1/conv1d_1_bias.bin", 4)
+    kernel2, _ := loader.LoadKernel1D("conv1d_2/conv1d_2_weight.bin", 3, 4, 3)
+    bias2, _ := loader.LoadBias("conv1d_2/conv1d_2_bias.bin", 3)
+    
+    hidden := ops.Conv1D(&tensor.FeatureMap1D{Length: 16, Channels: 2, Data: image}, kernel1, bias1,
+        ops.Conv2DConfig{Padding: 1, Stride: 1})
+    ops.ReLUInPlace(hidden.Data)
+    output := ops.Conv1D(hidden, kernel2, bias2, ops.Conv2DConfig{Padding: 1, Stride: 2})
+    logits := make([]float32, output.Channels)
+    for c := range logits {
+        for l := 0; l < output.Length; l++ {
+            logits[c] += output.Get(c, l)
+        }
+        logits[c] /= float32(output.Length)
+    }
+    checkConvNDPredict(t, model, image, ops.Softmax(logits))
+    
+    // RunLayer runs one layer on a 1×L×C map, in either layout and precision
+    input, _ := tensor.NewFeatureMapFromData(image, 1, 16, 2)
+    conv1, err := model.RunLayer("conv1d_1", input)
+    if err != nil {
+        t.Fatalf("RunLayer failed: %v", err)
+    }
+    if conv1.Height != 1 || conv1.Width != 16 || conv1.Channels != 4 || !slicesEqual(conv1.Data, hidden.Data) {
+        t.Errorf("RunLayer conv1d_1: expected 1×16×4 %v, got %d×%d×%d %v", hidden.Data,
+            conv1.Height, conv1.Width, conv1.Channels, conv1.Data)
+    }
+    fromHWC, err := model.RunLayer("conv1d_1", input.ToLayout(tensor.LayoutHWC))
+    if err != nil || !slicesEqual(fromHWC.ToLayout(tensor.LayoutCHW).Data, hidden.Data) {
+        t.Errorf("RunLayer on an HWC input: got %v, %v", fromHWC, err)
+    }
+    if _, err := model.RunLayer("conv1d_2", input); err == nil || !strings.Contains(err.Error(), "expects 4 input channels") {
+        t.Errorf("Expected a channel mismatch for conv1d_2, got %v", err)
+    }
+    if err := model.SetPrecision(tensor.PrecisionFloat64); err != nil {
+        t.Fatalf("SetPrecision failed: %v", err)
+    }
+    wide, err := model.RunLayer("conv1d_1", input)
+    if err != nil {
+        t.Fatalf("Float64 RunLayer failed: %v", err)
+    }
+    for i, v := range hidden.Data {
+        if diff := v - wide.Data[i]; diff > 1e-5 || diff < -1e-5 {
+            t.Fatalf("conv1d_1 element %d differs in float64: %f vs %f", i, v, wide.Data[i])
+        }
+    }
+    checkConvNDPredict(t, model, image, ops.Softmax(logits))
+    
+    // ValidateModel checks every 1D kernel against its layer, as /readyz and ReloadWeights rely on it
+    if err := model.ValidateModel(); err != nil {
+        t.Errorf("ValidateModel failed on matching weights: %v", err)
+    }
+    good := model.weights.ConvND["conv1d_2"]
+    badKernels := map[string]*data.ConvNDWeights{
+        "missing":      nil,
+        "wrong size":   {Kernel1D: tensor.NewKernel1D(5, 4, 3), Bias: good.Bias},
+        "wrong inputs": {Kernel1D: tensor.NewKernel1D(3, 2, 3), Bias: good.Bias},
+        "short bias":   {Kernel1D: good.Kernel1D, Bias: good.Bias[:2]},
+        "NaN weight":   {Kernel1D: tensor.NewKernel1D(3, 4, 3), Bias: good.Bias},
+    }
+    badKernels["NaN weight"].Kernel1D.Weights[7] = float32(math.NaN())
+    for name, weights := range badKernels {
+        model.weights.ConvND["conv1d_2"] = weights
+        if err := model.ValidateModel(); err == nil || !strings.Contains(err.Error(), "conv1d_2") {
+            t.Errorf("%s: expected a validation error naming conv1d_2, got %v", name, err)
+        }
+    }
+    model.weights.ConvND["conv1d_2"] = good
+    
+    // Weights exported for another shape are reported at load time
+    arch.Layers[1].Filters = 5
+    arch.NumClasses = 5
+    _, err = NewTinyCNNWithArchitecture(tempDir, arch)
+    if err == nil {
+        t.Fatal("Expected error for a kernel file of the wrong size")
+    }
+    
+    // So is a missing layer
+    arch.Layers[1].Filters = 3
+    arch.NumClasses = 3
+    arch.Layers[1].Name = "conv1d_missing"
+    if _, err := NewTinyCNNWithArchitecture(tempDir, arch); err == nil || !strings.Contains(err.Error(), "conv1d_missing") {
+        t.Errorf("Expected error naming the missing layer, got %v", err)
+    }
+}
+
+func TestTinyCNNConvolution3D(t *testing.T) {
+    tempDir := t.TempDir()
+    createConvNDFiles(t, tempDir, "conv3d_1", 3*3*3*2*3, 3)
+    
+    arch := &TinyCNNArchitecture{
+        InputDepth:    4,
+        InputHeight:   5,
+        InputWidth:    5,
+        InputChannels: 2,
+        NumClasses:    3,
+        Layers: []LayerConfig{
+            {Type: Convolution3DLayer, Name: "conv3d_1", KernelSize: 3, Filters: 3, Stride: 1, Padding: 1, ApplyActivation: true},
+            {Type: GlobalMaxPoolingLayer, Name: "global_maxpool"},
+        },
+    }
+    model, err := NewTinyCNNWithArchitecture(tempDir, arch)
+    if err != nil {
+        t.Fatalf("Failed to create 3D model: %v", err)
+    }
+    if arch.InputSize() != 4*5*5*2 {
+        t.Errorf("Expected an input size of %d, got %d", 4*5*5*2, arch.InputSize())
+    }
+    
+    image := make([]float32, arch.InputSize())
+    for i := range image {
+        image[i] = float32(i%9) / 9
+    }
+    
+    loader := data.NewWeightLoader(tempDir)
+    kernel, _ := loader.LoadKernel3D("conv3d_1/conv3d_1_weight.bin", 3, 2, 3)
+    bias, _ := loader.LoadBias("conv3d_1/conv3d_1_bias.bin", 3)
+    output := ops.Conv3D(&tensor.FeatureMap3D{Depth: 4, Height: 5, Width: 5, Channels: 2, Data: image}, kernel, bias,
+        ops.Conv2DConfig{Padding: 1, Stride: 1})
+    ops.ReLUInPlace(output.Data)
+    logits := make([]float32, output.Channels)
+    for c := range logits {
+        logits[c] = output.Get(c, 0, 0, 0)
+        for d := 0; d < output.Depth; d++ {
+            for h := 0; h < output.Height; h++ {
+                for w := 0; w < output.Width; w++ {
+                    logits[c] = max(logits[c], output.Get(c, d, h, w))
+                }
+            }
+        }
+    }
+    checkConvNDPredict(t, model, image, ops.Softmax(logits))
+    
+    // RunLayer takes the frames stacked along the height
+    stacked, _ := tensor.NewFeatureMapFromData(image, 4*5, 5, 2)
+    conv, err := model.RunLayer("conv3d_1", stacked)
+    if err != nil {
+        t.Fatalf("RunLayer failed: %v", err)
+    }
+    if conv.Height != 4*5 || !slicesEqual(conv.Data, output.Data) {
+        t.Errorf("RunLayer conv3d_1: expected %d stacked rows matching Conv3D, got %d×%d×%d", 4*5, 
+            conv.Height, conv.Width, conv.Channels)
+    }
+    
+    // 2D layers would mix frames, so they are rejected until global pooling
+    arch.Layers = append([]LayerConfig{{Type: MaxPoolingLayer, Name: "maxpool1", PoolSize: 2, PoolStride: 2}}, arch.Layers...)
+    if _, err := NewTinyCNNWithArchitecture(tempDir, arch); err == nil {
+        t.Error("Expected error for max pooling over a 3D input")
+    }
+}
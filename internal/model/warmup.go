@@ -0,0 +1,184 @@
+package model
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/ops"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"time"
+)
+
+/**
+* Warm-up before serving
+
+The first predictions after loading are noticeably slower than the rest:
+weight pages that were never read since loading have to be faulted in (or
+pulled into cache), the engine's buffer pool is still empty, the Go runtime
+has not grown its heap or started the threads the convolution goroutines
+run on, and with the auto backend the per-layer algorithm is a size guess
+rather than a measurement. A server that reports ready straight after
+loading hands all of that to its first clients.
+
+Warmup pays those costs up front:
+  1. read one value from every memory page holding a weight; this matters
+     most when weights are backed by a mapped file, for heap-loaded weights
+     it mainly warms the caches and TLB
+  2. with the auto backend and no algorithms chosen yet, Autotune every conv
+     layer, so requests run the measured fastest algorithm from the start
+  3. run n dummy inferences, which fill the buffer pool with every shape and
+     start the worker threads; the last one shows the steady-state latency
+  4. collect the garbage of all that, so the first real request doesn't
+*/
+
+// WarmupStats reports what Warmup did
+type WarmupStats struct {
+    PagesTouched   int                  // Weight memory pages read
+    WeightBytes    int64                // Bytes of weight memory covered
+    TouchTime      time.Duration        // Time spent touching pages
+    Autotuned      []ops.AutotuneResult // Algorithms Warmup picked; nil if a backend or Autotune already chose them
+    AutotuneTime   time.Duration        // Time spent autotuning
+    Inferences     int                  // Dummy inferences run
+    InferenceTime  time.Duration        // Time of the first dummy inference
+    SteadyTime     time.Duration        // Time of the last dummy inference
+}
+
+// LogValue groups what the warm-up did for a log record
+func (s *WarmupStats) LogValue() slog.Value {
+    attrs := []slog.Attr{
+        slog.Int("pages", s.PagesTouched),
+        slog.Int64("weight_bytes", s.WeightBytes),
+        slog.Duration("touch_time", s.TouchTime),
+    }
+    if s.Autotuned != nil {
+        attrs = append(attrs, slog.Int("autotuned_layers", len(s.Autotuned)), slog.Duration("autotune_time", s.AutotuneTime))
+    }
+    attrs = append(attrs, slog.Int("inferences", s.Inferences),
+        slog.Duration("first_inference", s.InferenceTime), slog.Duration("last_inference", s.SteadyTime))
+    return slog.GroupValue(attrs...)
+}
+
+// Warmup touches every weight page, autotunes the conv layers if nothing chose their
+// algorithm yet, and runs n dummy inferences (at least one) so the model is ready to
+// serve without a first-request latency spike. Performance counters are reset
+// afterwards so the dummy inferences do not show up in statistics.
+func (cnn *TinyCNN) Warmup(n int) (*WarmupStats, error) {
+    stats := &WarmupStats{Inferences: max(n, 1)}
+
+    start := time.Now()
+    cnn.weightsMu.RLock()
+    cnn.touchWeightPages(stats)
+    cnn.weightsMu.RUnlock()
+    stats.TouchTime = time.Since(start)
+
+    if cnn.convEngine.Options().Backend == ops.AlgoAuto && !cnn.convEngine.Tuned() {
+        start = time.Now()
+        results, err := cnn.convEngine.Autotune(cnn.architecture)
+        if err != nil {
+            return stats, fmt.Errorf("warm-up autotuning failed: %w", err)
+        }
+        stats.Autotuned = results
+        stats.AutotuneTime = time.Since(start)
+    }
+
+    // Mid-grey input exercises every layer like a real image
+    dummy := make([]float32, cnn.architecture.InputSize())
+    for i := range dummy {
+        dummy[i] = 0.5
+    }
+
+    for i := 0; i < stats.Inferences; i++ {
+        start = time.Now()
+        if _, err := cnn.Predict(context.Background(), dummy); err != nil {
+            return stats, fmt.Errorf("warm-up inference failed: %w", err)
+        }
+        elapsed := time.Since(start)
+        if i == 0 {
+            stats.InferenceTime = elapsed
+        }
+        stats.SteadyTime = elapsed
+    }
+
+    runtime.GC()
+    cnn.ResetPerformanceCounters()
+    cnn.ready.Store(true)
+
+    return stats, nil
+}
+
+// Ready reports whether Warmup has completed
+func (cnn *TinyCNN) Ready() bool {
+    return cnn.ready.Load()
+}
+
+// touchWeightPages reads one value per memory page of every weight array
+func (cnn *TinyCNN) touchWeightPages(stats *WarmupStats) {
+    // Stride in float32 values; float16 arrays are touched twice per page, which is harmless
+    stride := os.Getpagesize() / 4
+    var sink float32
+
+    touch := func(values []float32) {
+        for i := 0; i < len(values); i += stride {
+            sink += values[i]
+            stats.PagesTouched++
+        }
+        stats.WeightBytes += int64(len(values)) * 4
+    }
+
+    for i, kernel := range cnn.weights.Kernels {
+        if kernel != nil {
+            touch(kernel.Weights)
+            continue
+        }
+
+        if cnn.weights.IsQuantized(i) {
+            qk := cnn.weights.QuantKernels[i]
+            for j := 0; j < len(qk.Weights); j += stride {
+                sink += float32(qk.Weights[j])
+                stats.PagesTouched++
+            }
+            touch(qk.Scales)
+            stats.WeightBytes += int64(len(qk.Weights))
+            continue
+        }
+
+        half := cnn.halfKernels[i].Weights
+        for j := 0; j < len(half); j += stride {
+            sink += half[j].Float32()
+            stats.PagesTouched++
+        }
+        stats.WeightBytes += int64(len(half)) * 2
+    }
+    for _, bias := range cnn.weights.Biases {
+        touch(bias)
+    }
+    for _, bn := range cnn.weights.BatchNorms {
+        if bn == nil {
+            continue
+        }
+        touch(bn.Mean)
+        touch(bn.Variance)
+        touch(bn.Scale)
+        touch(bn.Shift)
+    }
+    for _, convND := range cnn.weights.ConvND {
+        if convND.Kernel1D != nil {
+            touch(convND.Kernel1D.Weights)
+        }
+        if convND.Kernel3D != nil {
+            touch(convND.Kernel3D.Weights)
+        }
+        touch(convND.Bias)
+    }
+    for _, layerWeights := range cnn.customWeights {
+        for _, values := range layerWeights {
+            touch(values)
+        }
+    }
+
+    warmupSink = sink
+}
+
+// warmupSink keeps the page reads from being optimised away
+var warmupSink float32
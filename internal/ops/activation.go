@@ -51,66 +51,12 @@ func Tanh(x float32) float32 {
 // Softmax applies softmax activation to a slice
 // Numerically stable implementation using the log-sum-exp trick
 func Softmax(input []float32) []float32 {
-    if len(input) == 0 {
-        return []float32{}
-    }
-    
-    result := make([]float32, len(input))
-    
-    // Find maximum for numerical stability
-    maxVal := input[0]
-    for _, val := range input[1:] {
-        if val > maxVal {
-            maxVal = val
-        }
-    }
-    
-    // Compute exponentials and sum
-    var sum float32
-    for i, val := range input {
-        exp := float32(math.Exp(float64(val - maxVal)))
-        result[i] = exp
-        sum += exp
-    }
-    
-    // Normalize to get probabilities
-    if sum > 0 {
-        for i := range result {
-            result[i] /= sum
-        }
-    }
-    
-    return result
+    return SoftmaxOf(input)
 }
 
 // SoftmaxInPlace applies softmax activation in-place
 func SoftmaxInPlace(data []float32) {
-    if len(data) == 0 {
-        return
-    }
-    
-    // Find maximum for numerical stability
-    maxVal := data[0]
-    for _, val := range data[1:] {
-        if val > maxVal {
-            maxVal = val
-        }
-    }
-    
-    // Compute exponentials and sum
-    var sum float32
-    for i, val := range data {
-        exp := float32(math.Exp(float64(val - maxVal)))
-        data[i] = exp
-        sum += exp
-    }
-    
-    // Normalize
-    if sum > 0 {
-        for i := range data {
-            data[i] /= sum
-        }
-    }
+    softmaxInto(data, data)
 }
 
 // LogSoftmax applies log-softmax activation (useful for numerical stability)
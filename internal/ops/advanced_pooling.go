@@ -12,7 +12,7 @@ func StochasticPooling(input *tensor.FeatureMap, kernelSize, stride int) *tensor
     outHeight := (input.Height-kernelSize)/stride + 1
     outWidth := (input.Width-kernelSize)/stride + 1
     
-    output := tensor.NewFeatureMap(outHeight, outWidth, input.Channels)
+    output := tensor.NewFeatureMapWithLayout(outHeight, outWidth, input.Channels, input.Layout)
     
     for c := 0; c < input.Channels; c++ {
         for i := 0; i < outHeight; i++ {
@@ -65,7 +65,7 @@ func LpPooling(input *tensor.FeatureMap, kernelSize, stride int, p float64) *ten
     outHeight := (input.Height-kernelSize)/stride + 1
     outWidth := (input.Width-kernelSize)/stride + 1
     
-    output := tensor.NewFeatureMap(outHeight, outWidth, input.Channels)
+    output := tensor.NewFeatureMapWithLayout(outHeight, outWidth, input.Channels, input.Layout)
     
     for c := 0; c < input.Channels; c++ {
         for i := 0; i < outHeight; i++ {
@@ -79,10 +79,10 @@ func LpPooling(input *tensor.FeatureMap, kernelSize, stride int, p float64) *ten
                 
                 if math.IsInf(p, 1) {
                     // Max pooling (p = ∞)
-                    result = maxPoolWindow(input, c, startH, endH, startW, endW)
+                    result = poolWindow(tensor.FeatureMapView(input), c, startH, endH, startW, endW, MaxPooling)
                 } else if p == 1.0 {
                     // Average pooling (p = 1)
-                    result = avgPoolWindow(input, c, startH, endH, startW, endW)
+                    result = poolWindow(tensor.FeatureMapView(input), c, startH, endH, startW, endW, AvgPooling)
                 } else {
                     // General Lp pooling
                     var sum float64
@@ -115,7 +115,7 @@ func RankPooling(input *tensor.FeatureMap, kernelSize, stride, k int) *tensor.Fe
     outHeight := (input.Height-kernelSize)/stride + 1
     outWidth := (input.Width-kernelSize)/stride + 1
     
-    output := tensor.NewFeatureMap(outHeight, outWidth, input.Channels)
+    output := tensor.NewFeatureMapWithLayout(outHeight, outWidth, input.Channels, input.Layout)
     
     for c := 0; c < input.Channels; c++ {
         for i := 0; i < outHeight; i++ {
@@ -168,7 +168,7 @@ func FractionalMaxPooling(input *tensor.FeatureMap, poolingRatio float64) *tenso
         outWidth = 1
     }
     
-    output := tensor.NewFeatureMap(outHeight, outWidth, input.Channels)
+    output := tensor.NewFeatureMapWithLayout(outHeight, outWidth, input.Channels, input.Layout)
     
     for c := 0; c < input.Channels; c++ {
         for i := 0; i < outHeight; i++ {
@@ -0,0 +1,273 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+/**
+* Convolution auto-tuning
+
+Which convolution variant is fastest depends on the layer shape and on the
+machine: GEMM wins on layers with many input channels, the parallel direct
+kernel wins on wide layers with many cores, and tiny layers are fastest with
+no goroutine overhead at all. Instead of guessing, Autotune times every
+variant on each layer's exact shape, remembers the winner, and writes the
+choices to a JSON cache so later runs on the same machine skip the timing.
+*/
+
+// ConvAlgorithm names a convolution implementation the engine can dispatch to
+type ConvAlgorithm string
+
+const (
+    AlgoAuto     ConvAlgorithm = ""         // Size-based heuristic
+    AlgoDirect   ConvAlgorithm = "direct"   // Single-threaded direct loops
+    AlgoTiled    ConvAlgorithm = "tiled"    // Cache-blocked direct loops
+    AlgoParallel ConvAlgorithm = "parallel" // Direct loops over (filter, row band) tiles
+    AlgoGEMM     ConvAlgorithm = "gemm"     // im2col + matrix multiply
+)
+
+// ConvAlgorithms lists the concrete algorithms Autotune chooses between
+var ConvAlgorithms = []ConvAlgorithm{AlgoDirect, AlgoTiled, AlgoParallel, AlgoGEMM}
+
+// autotuneIterations is the number of timed runs per variant; the fastest is kept
+const autotuneIterations = 3
+
+// ConvShape describes one convolution layer as seen by the engine
+type ConvShape struct {
+    Name       string // Layer name, for reporting only
+    Height     int    // Input height before padding
+    Width      int    // Input width before padding
+    Channels   int
+    KernelSize int
+    Filters    int
+    Padding    int
+    Stride     int
+}
+
+// Key identifies the shape independently of the layer name
+func (s ConvShape) Key() string {
+    return fmt.Sprintf("%dx%dx%d-k%d-f%d-p%d-s%d",
+        s.Height, s.Width, s.Channels, s.KernelSize, s.Filters, s.Padding, s.Stride)
+}
+
+// key returns the allocation-free map key used on the inference path
+func (s ConvShape) key() convKey {
+    return convKey{s.Height, s.Width, s.Channels, s.KernelSize, s.Filters, s.Padding, s.Stride}
+}
+
+// convKey is the comparable form of ConvShape used for lookups during inference
+type convKey struct {
+    height, width, channels, kernelSize, filters, padding, stride int
+}
+
+// convKeyFor builds the lookup key for a convolution call
+func convKeyFor(input *tensor.FeatureMap, kernel *tensor.Kernel, config Conv2DConfig) convKey {
+    return convKey{input.Height, input.Width, input.Channels, kernel.Size, kernel.Filters, config.Padding, config.Stride}
+}
+
+// ConvShapeProvider is implemented by architectures that can list their convolution layers
+type ConvShapeProvider interface {
+    ConvShapes() ([]ConvShape, error)
+}
+
+// AutotuneResult reports the choice made for one layer
+type AutotuneResult struct {
+    Layer     string
+    Shape     string
+    Algorithm ConvAlgorithm
+    Cached    bool                            // Choice came from the cache file
+    Timings   map[ConvAlgorithm]time.Duration // Best time per variant (empty when cached)
+}
+
+// tuningCache is the on-disk format of the per-shape choices
+type tuningCache struct {
+    Machine    string                   `json:"machine"`
+    Algorithms map[string]ConvAlgorithm `json:"algorithms"`
+}
+
+// machineFingerprint identifies the hardware a tuning cache is valid for
+func machineFingerprint() string {
+    return fmt.Sprintf("%s/%s/cpus=%d/procs=%d", runtime.GOOS, runtime.GOARCH, runtime.NumCPU(), runtime.GOMAXPROCS(0))
+}
+
+// Autotune picks the fastest convolution algorithm for every convolution layer of arch
+// If TuningCachePath is set, choices recorded there for this machine are reused and
+// newly measured ones are written back
+func (ce *ConvolutionEngine) Autotune(arch ConvShapeProvider) ([]AutotuneResult, error) {
+    shapes, err := arch.ConvShapes()
+    if err != nil {
+        return nil, fmt.Errorf("failed to list convolution layers: %w", err)
+    }
+
+    cached := make(map[string]ConvAlgorithm)
+    if ce.TuningCachePath != "" {
+        cache, err := loadTuningCache(ce.TuningCachePath)
+        if err != nil {
+            return nil, err
+        }
+        if cache != nil && cache.Machine == machineFingerprint() {
+            cached = cache.Algorithms
+        }
+    }
+
+    results := make([]AutotuneResult, 0, len(shapes))
+    measured := false
+
+    for _, shape := range shapes {
+        result := AutotuneResult{Layer: shape.Name, Shape: shape.Key()}
+
+        if algo, ok := cached[shape.Key()]; ok && algo.valid() {
+            result.Algorithm = algo
+            result.Cached = true
+        } else {
+            result.Algorithm, result.Timings = ce.benchmarkShape(shape)
+            cached[shape.Key()] = result.Algorithm
+            measured = true
+        }
+
+        ce.SetAlgorithm(shape, result.Algorithm)
+        results = append(results, result)
+    }
+
+    if ce.TuningCachePath != "" && measured {
+        cache := &tuningCache{Machine: machineFingerprint(), Algorithms: cached}
+        if err := saveTuningCache(ce.TuningCachePath, cache); err != nil {
+            return results, err
+        }
+    }
+
+    return results, nil
+}
+
+// SetAlgorithm forces the algorithm used for convolutions of the given shape
+// AlgoAuto removes the override
+func (ce *ConvolutionEngine) SetAlgorithm(shape ConvShape, algo ConvAlgorithm) {
+    ce.tuningMu.Lock()
+    defer ce.tuningMu.Unlock()
+
+    if algo == AlgoAuto {
+        delete(ce.tuning, shape.key())
+        return
+    }
+    if ce.tuning == nil {
+        ce.tuning = make(map[convKey]ConvAlgorithm)
+    }
+    ce.tuning[shape.key()] = algo
+}
+
+// Tuned reports whether Autotune or SetAlgorithm has chosen an algorithm for any shape
+func (ce *ConvolutionEngine) Tuned() bool {
+    ce.tuningMu.RLock()
+    defer ce.tuningMu.RUnlock()
+    return len(ce.tuning) > 0
+}
+
+// algorithmFor returns the algorithm for a convolution call: the engine-wide Backend
+// if one is set, else the tuned choice for the shape, else AlgoAuto
+func (ce *ConvolutionEngine) algorithmFor(input *tensor.FeatureMap, kernel *tensor.Kernel, config Conv2DConfig) ConvAlgorithm {
+    if ce.Backend != AlgoAuto {
+        return ce.Backend
+    }
+    ce.tuningMu.RLock()
+    defer ce.tuningMu.RUnlock()
+    return ce.tuning[convKeyFor(input, kernel, config)]
+}
+
+// benchmarkShape times every algorithm on random data of the given shape
+func (ce *ConvolutionEngine) benchmarkShape(shape ConvShape) (ConvAlgorithm, map[ConvAlgorithm]time.Duration) {
+    input := tensor.NewFeatureMap(shape.Height, shape.Width, shape.Channels)
+    input.RandomFill()
+    kernel := tensor.NewKernel(shape.KernelSize, shape.Channels, shape.Filters)
+    kernel.RandomFill()
+    bias := make([]float32, shape.Filters)
+    config := Conv2DConfig{Padding: shape.Padding, Stride: shape.Stride}
+
+    timings := make(map[ConvAlgorithm]time.Duration, len(ConvAlgorithms))
+    best := AlgoDirect
+
+    for _, algo := range ConvAlgorithms {
+        if algo == AlgoParallel && runtime.NumCPU() < 2 {
+            continue
+        }
+
+        var fastest time.Duration
+        for i := 0; i <= autotuneIterations; i++ {
+            start := time.Now()
+            output := ce.conv(algo, input, kernel, bias, nil, false, config)
+            elapsed := time.Since(start)
+            ce.Release(output)
+
+            // The first run only warms caches and the buffer pool
+            if i > 0 && (fastest == 0 || elapsed < fastest) {
+                fastest = elapsed
+            }
+        }
+
+        timings[algo] = fastest
+        if fastest < timings[best] || timings[best] == 0 {
+            best = algo
+        }
+    }
+
+    return best, timings
+}
+
+// valid reports whether algo is one of the concrete algorithms
+func (algo ConvAlgorithm) valid() bool {
+    for _, known := range ConvAlgorithms {
+        if algo == known {
+            return true
+        }
+    }
+    return false
+}
+
+// ParseConvAlgorithm converts a name such as "gemm" to a ConvAlgorithm ("auto" or "" gives AlgoAuto)
+func ParseConvAlgorithm(name string) (ConvAlgorithm, error) {
+    if name == "" || name == "auto" {
+        return AlgoAuto, nil
+    }
+    algo := ConvAlgorithm(name)
+    if !algo.valid() {
+        return AlgoAuto, fmt.Errorf("unknown convolution algorithm %q", name)
+    }
+    return algo, nil
+}
+
+// loadTuningCache reads a tuning cache file; a missing file yields nil without error
+func loadTuningCache(path string) (*tuningCache, error) {
+    raw, err := os.ReadFile(path)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read tuning cache %s: %w", path, err)
+    }
+
+    var cache tuningCache
+    if err := json.Unmarshal(raw, &cache); err != nil {
+        return nil, fmt.Errorf("failed to parse tuning cache %s: %w", path, err)
+    }
+    if cache.Algorithms == nil {
+        cache.Algorithms = make(map[string]ConvAlgorithm)
+    }
+    return &cache, nil
+}
+
+// saveTuningCache writes a tuning cache file
+func saveTuningCache(path string, cache *tuningCache) error {
+    raw, err := json.MarshalIndent(cache, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode tuning cache: %w", err)
+    }
+    if err := os.WriteFile(path, raw, 0644); err != nil {
+        return fmt.Errorf("failed to write tuning cache %s: %w", path, err)
+    }
+    return nil
+}
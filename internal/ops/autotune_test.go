@@ -0,0 +1,158 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// staticShapes is a ConvShapeProvider over a fixed list
+type staticShapes []ConvShape
+
+func (s staticShapes) ConvShapes() ([]ConvShape, error) {
+    return s, nil
+}
+
+func TestConv2DGEMMMatchesDirect(t *testing.T) {
+    input := tensor.NewFeatureMap(9, 7, 4)
+    input.RandomFill()
+    
+    kernel := tensor.NewKernel(3, 4, 5)
+    kernel.RandomFill()
+    
+    bias := []float32{0.1, -0.2, 0.3, -0.4, 0.5}
+    
+    for _, config := range []Conv2DConfig{{Padding: 1, Stride: 1}, {Padding: 0, Stride: 2}} {
+        expected := Conv2D(input, kernel, bias, config)
+        got := Conv2DGEMM(input, kernel, bias, config)
+        for i := range expected.Data {
+            if math.Abs(float64(expected.Data[i]-got.Data[i])) > 1e-4 {
+                t.Fatalf("%+v: mismatch at index %d: expected %f, got %f", config, i, expected.Data[i], got.Data[i])
+            }
+        }
+    }
+}
+
+func TestConvolutionEngineAlgorithmsMatch(t *testing.T) {
+    input := tensor.NewFeatureMap(8, 8, 6)
+    input.RandomFill()
+    
+    kernel := tensor.NewKernel(3, 6, 4)
+    kernel.RandomFill()
+    
+    bias := []float32{0.1, -0.2, 0.3, -0.4}
+    bn := NewBatchNormParams(4)
+    for f := 0; f < 4; f++ {
+        bn.Mean[f] = 0.1
+        bn.Variance[f] = 2
+        bn.Scale[f] = 0.5 + float32(f)
+    }
+    config := Conv2DConfig{Padding: 1, Stride: 1}
+    shape := ConvShape{Height: 8, Width: 8, Channels: 6, KernelSize: 3, Filters: 4, Padding: 1, Stride: 1}
+    
+    expected := Conv2DBatchNormReLU(input, kernel, bias, bn, true, config)
+    expectedPlain := Conv2D(input, kernel, bias, config)
+    
+    engine := NewConvolutionEngine()
+    for _, algo := range ConvAlgorithms {
+        engine.SetAlgorithm(shape, algo)
+        
+        fused := engine.Conv2DFused(input, kernel, bias, bn, true, config)
+        plain := engine.Conv2DOptimized(input, kernel, bias, config)
+        for i := range expected.Data {
+            if math.Abs(float64(expected.Data[i]-fused.Data[i])) > 1e-4 {
+                t.Fatalf("%s: fused mismatch at index %d: expected %f, got %f", algo, i, expected.Data[i], fused.Data[i])
+            }
+            if math.Abs(float64(expectedPlain.Data[i]-plain.Data[i])) > 1e-4 {
+                t.Fatalf("%s: mismatch at index %d: expected %f, got %f", algo, i, expectedPlain.Data[i], plain.Data[i])
+            }
+        }
+        engine.Release(fused)
+        engine.Release(plain)
+    }
+}
+
+func TestAutotuneCache(t *testing.T) {
+    shapes := staticShapes{
+        {Name: "a", Height: 6, Width: 6, Channels: 2, KernelSize: 3, Filters: 3, Padding: 1, Stride: 1},
+        {Name: "b", Height: 4, Width: 4, Channels: 3, KernelSize: 1, Filters: 2, Padding: 0, Stride: 1},
+    }
+    cachePath := filepath.Join(t.TempDir(), "tuning.json")
+    
+    engine := NewConvolutionEngine()
+    engine.TuningCachePath = cachePath
+    results, err := engine.Autotune(shapes)
+    if err != nil {
+        t.Fatalf("Autotune failed: %v", err)
+    }
+    if len(results) != 2 {
+        t.Fatalf("Expected 2 results, got %d", len(results))
+    }
+    for _, result := range results {
+        if result.Cached || !result.Algorithm.valid() || len(result.Timings) == 0 {
+            t.Errorf("Unexpected first-run result: %+v", result)
+        }
+    }
+    if _, err := os.Stat(cachePath); err != nil {
+        t.Fatalf("Tuning cache not written: %v", err)
+    }
+    
+    // A fresh engine reuses the cached choices without timing again
+    again := NewConvolutionEngine()
+    again.TuningCachePath = cachePath
+    cached, err := again.Autotune(shapes)
+    if err != nil {
+        t.Fatalf("Cached autotune failed: %v", err)
+    }
+    for i, result := range cached {
+        if !result.Cached || result.Algorithm != results[i].Algorithm {
+            t.Errorf("Expected cached %s for %s, got %+v", results[i].Algorithm, result.Layer, result)
+        }
+    }
+    
+    // A cache from another machine is ignored
+    if err := saveTuningCache(cachePath, &tuningCache{
+        Machine:    "other",
+        Algorithms: map[string]ConvAlgorithm{shapes[0].Key(): AlgoTiled},
+    }); err != nil {
+        t.Fatalf("Failed to write cache: %v", err)
+    }
+    remeasured, err := again.Autotune(shapes[:1])
+    if err != nil {
+        t.Fatalf("Autotune failed: %v", err)
+    }
+    if remeasured[0].Cached {
+        t.Error("Cache from a different machine should not be used")
+    }
+}
+
+func TestParseConvAlgorithm(t *testing.T) {
+    if algo, err := ParseConvAlgorithm("gemm"); err != nil || algo != AlgoGEMM {
+        t.Errorf("ParseConvAlgorithm(gemm) = %q, %v", algo, err)
+    }
+    if algo, err := ParseConvAlgorithm("auto"); err != nil || algo != AlgoAuto {
+        t.Errorf("ParseConvAlgorithm(auto) = %q, %v", algo, err)
+    }
+    if _, err := ParseConvAlgorithm("fft"); err == nil {
+        t.Error("Expected error for unknown algorithm")
+    }
+}
+
+func BenchmarkConv2DGEMM(b *testing.B) {
+    input := tensor.NewFeatureMap(16, 16, 64)
+    input.RandomFill()
+    
+    kernel := tensor.NewKernel(3, 64, 64)
+    kernel.RandomFill()
+    
+    bias := make([]float32, 64)
+    config := Conv2DConfig{Padding: 1, Stride: 1}
+    
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        output := Conv2DGEMM(input, kernel, bias, config)
+        _ = output
+    }
+}
@@ -0,0 +1,298 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"math"
+)
+
+/**
+* Backward pass
+
+Inference never needs gradients, but explanations do: Grad-CAM and saliency
+maps ask how a class score changes with a layer's activations or the input
+pixels. Each function here takes the gradient of some scalar with respect
+to an operation's output and returns the gradient with respect to its input,
+so a pass back through the network chains them layer by layer:
+```
+score ← global max pool ← conv (1×1) ← max pool ← ReLU ← batch norm ← conv ← ...
+```
+Max pooling routes each gradient to the position that won the window, the
+first maximum in row-major order as in the forward pass. Gradients keep the
+layout of the feature map they belong to.
+
+Functions of layers with parameters also return the parameters' gradients,
+which training needs. Batch norm comes in two forms: with the stored moving
+statistics, as inference runs it, and with the statistics of the batch, as
+training runs it, where every input of the batch also moves the mean and
+variance the others are normalized with. Training starts the pass at the loss:
+```
+loss = cross-entropy(softmax(logits), labels)    d loss / d logits = softmax(logits) − labels
+```
+*/
+
+// Conv2DBackward computes the gradients of a convolution from outputGrad, the
+// gradient with respect to its output: with respect to the input, the kernel
+// and the bias
+func Conv2DBackward(outputGrad *tensor.FeatureMap, input *tensor.FeatureMap,
+                   kernel *tensor.Kernel, config Conv2DConfig) (*tensor.FeatureMap, *tensor.Kernel, []float32) {
+
+    inputGrad := tensor.NewFeatureMapWithLayout(input.Height, input.Width, input.Channels, input.Layout)
+    kernelGrad := tensor.NewKernel(kernel.Size, kernel.Channels, kernel.Filters)
+    biasGrad := make([]float32, kernel.Filters)
+
+    for f := 0; f < kernel.Filters; f++ {
+        for i := 0; i < outputGrad.Height; i++ {
+            for j := 0; j < outputGrad.Width; j++ {
+                grad := outputGrad.GetUnsafe(f, i, j)
+                if grad == 0 {
+                    continue
+                }
+                biasGrad[f] += grad
+
+                // Positions in the padding contribute nothing
+                for c := 0; c < kernel.Channels; c++ {
+                    for m := 0; m < kernel.Size; m++ {
+                        h := i*config.Stride + m - config.Padding
+                        if h < 0 || h >= input.Height {
+                            continue
+                        }
+                        for n := 0; n < kernel.Size; n++ {
+                            w := j*config.Stride + n - config.Padding
+                            if w < 0 || w >= input.Width {
+                                continue
+                            }
+                            idx := inputGrad.Index(c, h, w)
+                            inputGrad.Data[idx] += grad * kernel.GetWeightUnsafe(f, c, m, n)
+                            kernelGrad.Weights[((f*kernel.Channels+c)*kernel.Size+m)*kernel.Size+n] += grad * input.Data[idx]
+                        }
+                    }
+                }
+            }
+        }
+    }
+
+    return inputGrad, kernelGrad, biasGrad
+}
+
+// BatchNormReLUBackward returns the gradient with respect to a convolution's raw
+// output from outputGrad, the gradient after its epilogue: batch norm with bn,
+// if not nil, then ReLU if applyReLU. output is the epilogue's output, whose
+// positive entries mark where ReLU let the value through.
+func BatchNormReLUBackward(outputGrad, output *tensor.FeatureMap, bn *BatchNormParams, applyReLU bool) *tensor.FeatureMap {
+    grad := outputGrad.Clone()
+    for c := 0; c < grad.Channels; c++ {
+        gain := float32(1)
+        if bn != nil {
+            gain = bn.Scale[c] / float32(math.Sqrt(float64(bn.Variance[c]+bn.Epsilon)))
+        }
+        for h := 0; h < grad.Height; h++ {
+            for w := 0; w < grad.Width; w++ {
+                idx := grad.Index(c, h, w)
+                if applyReLU && output.GetUnsafe(c, h, w) <= 0 {
+                    grad.Data[idx] = 0
+                } else {
+                    grad.Data[idx] *= gain
+                }
+            }
+        }
+    }
+    return grad
+}
+
+// MaxPooling2DBackward returns the gradient with respect to the input of
+// MaxPooling2D(input, kernelSize, stride) from outputGrad
+func MaxPooling2DBackward(outputGrad, input *tensor.FeatureMap, kernelSize, stride int) *tensor.FeatureMap {
+    inputGrad := tensor.NewFeatureMapWithLayout(input.Height, input.Width, input.Channels, input.Layout)
+    for c := 0; c < outputGrad.Channels; c++ {
+        for i := 0; i < outputGrad.Height; i++ {
+            for j := 0; j < outputGrad.Width; j++ {
+                bestH, bestW := i*stride, j*stride
+                for h := i * stride; h < i*stride+kernelSize; h++ {
+                    for w := j * stride; w < j*stride+kernelSize; w++ {
+                        if input.GetUnsafe(c, h, w) > input.GetUnsafe(c, bestH, bestW) {
+                            bestH, bestW = h, w
+                        }
+                    }
+                }
+                inputGrad.Data[inputGrad.Index(c, bestH, bestW)] += outputGrad.GetUnsafe(c, i, j)
+            }
+        }
+    }
+    return inputGrad
+}
+
+// GlobalMaxPoolingBackward returns the gradient with respect to the input of
+// GlobalMaxPooling(input) from outputGrad, one value per channel
+func GlobalMaxPoolingBackward(outputGrad []float32, input *tensor.FeatureMap) *tensor.FeatureMap {
+    inputGrad := tensor.NewFeatureMapWithLayout(input.Height, input.Width, input.Channels, input.Layout)
+    for c := 0; c < input.Channels; c++ {
+        bestH, bestW := 0, 0
+        for h := 0; h < input.Height; h++ {
+            for w := 0; w < input.Width; w++ {
+                if input.GetUnsafe(c, h, w) > input.GetUnsafe(c, bestH, bestW) {
+                    bestH, bestW = h, w
+                }
+            }
+        }
+        inputGrad.Data[inputGrad.Index(c, bestH, bestW)] = outputGrad[c]
+    }
+    return inputGrad
+}
+
+// GlobalAvgPoolingBackward returns the gradient with respect to the input of
+// GlobalAvgPooling(input) from outputGrad, one value per channel: each position
+// receives an equal share
+func GlobalAvgPoolingBackward(outputGrad []float32, input *tensor.FeatureMap) *tensor.FeatureMap {
+    inputGrad := tensor.NewFeatureMapWithLayout(input.Height, input.Width, input.Channels, input.Layout)
+    area := float32(input.Height * input.Width)
+    for c := 0; c < input.Channels; c++ {
+        for h := 0; h < input.Height; h++ {
+            for w := 0; w < input.Width; w++ {
+                inputGrad.Data[inputGrad.Index(c, h, w)] = outputGrad[c] / area
+            }
+        }
+    }
+    return inputGrad
+}
+
+// ReLUBackward returns the gradient with respect to the input of a ReLU from
+// outputGrad and its output: the gradient passes where the output is positive
+func ReLUBackward(outputGrad, output *tensor.FeatureMap) *tensor.FeatureMap {
+    grad := outputGrad.Clone()
+    for c := 0; c < grad.Channels; c++ {
+        for h := 0; h < grad.Height; h++ {
+            for w := 0; w < grad.Width; w++ {
+                if output.GetUnsafe(c, h, w) <= 0 {
+                    grad.Data[grad.Index(c, h, w)] = 0
+                }
+            }
+        }
+    }
+    return grad
+}
+
+// AvgPooling2DBackward returns the gradient with respect to the input of
+// AvgPooling2D(input, kernelSize, stride) from outputGrad
+func AvgPooling2DBackward(outputGrad, input *tensor.FeatureMap, kernelSize, stride int) *tensor.FeatureMap {
+    inputGrad := tensor.NewFeatureMapWithLayout(input.Height, input.Width, input.Channels, input.Layout)
+    area := float32(kernelSize * kernelSize)
+    for c := 0; c < outputGrad.Channels; c++ {
+        for i := 0; i < outputGrad.Height; i++ {
+            for j := 0; j < outputGrad.Width; j++ {
+                share := outputGrad.GetUnsafe(c, i, j) / area
+                for h := i * stride; h < i*stride+kernelSize; h++ {
+                    for w := j * stride; w < j*stride+kernelSize; w++ {
+                        inputGrad.Data[inputGrad.Index(c, h, w)] += share
+                    }
+                }
+            }
+        }
+    }
+    return inputGrad
+}
+
+// BatchNormBackward returns the gradients of batch norm with the moving
+// statistics of bn, y = scale·(x − mean)/sqrt(variance + epsilon) + shift, from
+// outputGrad: with respect to the input, the scale and the shift
+// Unlike BatchNormalize, no ReLU is included; chain ReLUBackward for it.
+func BatchNormBackward(outputGrad, input *tensor.FeatureMap, bn *BatchNormParams) (*tensor.FeatureMap, []float32, []float32) {
+    inputGrad := tensor.NewFeatureMapWithLayout(input.Height, input.Width, input.Channels, input.Layout)
+    scaleGrad := make([]float32, input.Channels)
+    shiftGrad := make([]float32, input.Channels)
+    for c := 0; c < input.Channels; c++ {
+        std := float32(math.Sqrt(float64(bn.Variance[c] + bn.Epsilon)))
+        gain := bn.Scale[c] / std
+        for h := 0; h < input.Height; h++ {
+            for w := 0; w < input.Width; w++ {
+                grad := outputGrad.GetUnsafe(c, h, w)
+                inputGrad.Data[inputGrad.Index(c, h, w)] = grad * gain
+                scaleGrad[c] += grad * (input.GetUnsafe(c, h, w) - bn.Mean[c]) / std
+                shiftGrad[c] += grad
+            }
+        }
+    }
+    return inputGrad, scaleGrad, shiftGrad
+}
+
+// BatchNormTrainingBackward returns the gradients of batch norm in training mode,
+// normalizing inputs with their own mean and variance (ComputeBatchStatistics)
+// and then applying the scale and shift of bn, from the gradient with respect to
+// every output: with respect to every input, the scale and the shift
+// Per channel, with N values over the batch and x̂ the normalized inputs:
+// dx = scale/(N·std) · (N·dy − Σ dy − x̂·Σ dy·x̂)
+func BatchNormTrainingBackward(outputGrads, inputs []*tensor.FeatureMap, bn *BatchNormParams) ([]*tensor.FeatureMap, []float32, []float32) {
+    means, variances := ComputeBatchStatistics(inputs)
+    inputGrads := make([]*tensor.FeatureMap, len(inputs))
+    for b, input := range inputs {
+        inputGrads[b] = tensor.NewFeatureMapWithLayout(input.Height, input.Width, input.Channels, input.Layout)
+    }
+    if len(inputs) == 0 {
+        return inputGrads, nil, nil
+    }
+
+    channels := inputs[0].Channels
+    scaleGrad := make([]float32, channels)
+    shiftGrad := make([]float32, channels)
+    n := float64(len(inputs) * inputs[0].Height * inputs[0].Width)
+    for c := 0; c < channels; c++ {
+        std := math.Sqrt(float64(variances[c] + bn.Epsilon))
+        normalized := func(b, h, w int) float64 {
+            return (float64(inputs[b].GetUnsafe(c, h, w)) - float64(means[c])) / std
+        }
+
+        var sumGrad, sumGradNormalized float64
+        for b, input := range inputs {
+            for h := 0; h < input.Height; h++ {
+                for w := 0; w < input.Width; w++ {
+                    grad := float64(outputGrads[b].GetUnsafe(c, h, w))
+                    sumGrad += grad
+                    sumGradNormalized += grad * normalized(b, h, w)
+                }
+            }
+        }
+        scaleGrad[c] = float32(sumGradNormalized)
+        shiftGrad[c] = float32(sumGrad)
+
+        gain := float64(bn.Scale[c]) / (n * std)
+        for b, input := range inputs {
+            for h := 0; h < input.Height; h++ {
+                for w := 0; w < input.Width; w++ {
+                    grad := float64(outputGrads[b].GetUnsafe(c, h, w))
+                    dx := gain * (n*grad - sumGrad - normalized(b, h, w)*sumGradNormalized)
+                    inputGrads[b].Data[inputGrads[b].Index(c, h, w)] = float32(dx)
+                }
+            }
+        }
+    }
+    return inputGrads, scaleGrad, shiftGrad
+}
+
+// SoftmaxBackward returns the gradient with respect to the logits from
+// outputGrad, the gradient with respect to probabilities = Softmax(logits)
+func SoftmaxBackward(outputGrad, probabilities []float32) []float32 {
+    var dot float32
+    for i, p := range probabilities {
+        dot += outputGrad[i] * p
+    }
+    grad := make([]float32, len(probabilities))
+    for i, p := range probabilities {
+        grad[i] = p * (outputGrad[i] - dot)
+    }
+    return grad
+}
+
+// SoftmaxCrossEntropyBackward returns CrossEntropyLossFromLogits(logits, labels)
+// and its gradient with respect to the logits, softmax(logits)·Σ labels − labels
+// (softmax(logits) − labels for one-hot labels)
+func SoftmaxCrossEntropyBackward(logits, labels []float32) (float32, []float32) {
+    loss := CrossEntropyLossFromLogits(logits, labels)
+    var total float32
+    for _, label := range labels {
+        total += label
+    }
+    grad := Softmax(logits)
+    for i := range grad {
+        grad[i] = grad[i]*total - labels[i]
+    }
+    return loss, grad
+}
@@ -0,0 +1,313 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// sumWeighted returns Σ fm·weights, a scalar whose gradient with respect to fm is weights
+func sumWeighted(fm *tensor.FeatureMap, weights *tensor.FeatureMap) float64 {
+    var sum float64
+    for c := 0; c < fm.Channels; c++ {
+        for h := 0; h < fm.Height; h++ {
+            for w := 0; w < fm.Width; w++ {
+                sum += float64(fm.Get(c, h, w)) * float64(weights.Get(c, h, w))
+            }
+        }
+    }
+    return sum
+}
+
+func TestConv2DBackwardFiniteDifferences(t *testing.T) {
+    rng := rand.New(rand.NewSource(1))
+    for _, config := range []Conv2DConfig{{Padding: 1, Stride: 1}, {Padding: 0, Stride: 2}} {
+        input := tensor.NewFeatureMap(5, 5, 2)
+        for i := range input.Data {
+            input.Data[i] = rng.Float32()*2 - 1
+        }
+        kernel := tensor.NewKernel(3, 2, 3)
+        for i := range kernel.Weights {
+            kernel.Weights[i] = rng.Float32()*2 - 1
+        }
+        bias := []float32{0.1, -0.2, 0.3}
+
+        output := Conv2D(input, kernel, bias, config)
+        outputGrad := tensor.NewFeatureMap(output.Height, output.Width, output.Channels)
+        for i := range outputGrad.Data {
+            outputGrad.Data[i] = rng.Float32()*2 - 1
+        }
+        inputGrad, kernelGrad, biasGrad := Conv2DBackward(outputGrad, input, kernel, config)
+
+        const eps = 1e-2
+        for i := range input.Data {
+            saved := input.Data[i]
+            input.Data[i] = saved + eps
+            plus := sumWeighted(Conv2D(input, kernel, bias, config), outputGrad)
+            input.Data[i] = saved - eps
+            minus := sumWeighted(Conv2D(input, kernel, bias, config), outputGrad)
+            input.Data[i] = saved
+
+            expected := (plus - minus) / (2 * eps)
+            if math.Abs(expected-float64(inputGrad.Data[i])) > 1e-3 {
+                t.Errorf("%+v: input grad %d = %f, finite differences give %f", config, i, inputGrad.Data[i], expected)
+            }
+        }
+        for i := range kernel.Weights {
+            saved := kernel.Weights[i]
+            kernel.Weights[i] = saved + eps
+            plus := sumWeighted(Conv2D(input, kernel, bias, config), outputGrad)
+            kernel.Weights[i] = saved - eps
+            minus := sumWeighted(Conv2D(input, kernel, bias, config), outputGrad)
+            kernel.Weights[i] = saved
+
+            expected := (plus - minus) / (2 * eps)
+            if math.Abs(expected-float64(kernelGrad.Weights[i])) > 1e-3 {
+                t.Errorf("%+v: kernel grad %d = %f, finite differences give %f", config, i, kernelGrad.Weights[i], expected)
+            }
+        }
+        for f := range bias {
+            var expected float32
+            for h := 0; h < outputGrad.Height; h++ {
+                for w := 0; w < outputGrad.Width; w++ {
+                    expected += outputGrad.Get(f, h, w)
+                }
+            }
+            if math.Abs(float64(expected-biasGrad[f])) > 1e-5 {
+                t.Errorf("%+v: bias grad %d = %f, expected %f", config, f, biasGrad[f], expected)
+            }
+        }
+    }
+}
+
+func TestMaxPooling2DBackward(t *testing.T) {
+    input := tensor.NewFeatureMap(4, 4, 1)
+    for i := 0; i < 4; i++ {
+        for j := 0; j < 4; j++ {
+            input.Set(0, i, j, float32(i*4+j+1))
+        }
+    }
+    // A tie in the top-left window goes to the first maximum, as in the forward pass
+    input.Set(0, 0, 0, 6)
+
+    outputGrad := tensor.NewFeatureMap(2, 2, 1)
+    copy(outputGrad.Data, []float32{1, 2, 3, 4})
+    grad := MaxPooling2DBackward(outputGrad, input, 2, 2)
+
+    expected := map[[2]int]float32{{0, 0}: 1, {1, 3}: 2, {3, 1}: 3, {3, 3}: 4}
+    for i := 0; i < 4; i++ {
+        for j := 0; j < 4; j++ {
+            if got := grad.Get(0, i, j); got != expected[[2]int{i, j}] {
+                t.Errorf("Grad at (%d,%d) = %f, expected %f", i, j, got, expected[[2]int{i, j}])
+            }
+        }
+    }
+}
+
+func TestGlobalMaxPoolingBackward(t *testing.T) {
+    input := tensor.NewFeatureMap(2, 2, 2)
+    copy(input.Data, []float32{1, 5, 3, 2, 7, 0, 7, 1})
+    grad := GlobalMaxPoolingBackward([]float32{2, -1}, input)
+
+    expected := []float32{0, 2, 0, 0, -1, 0, 0, 0}
+    for i, want := range expected {
+        if grad.Data[i] != want {
+            t.Errorf("Grad %d = %f, expected %f", i, grad.Data[i], want)
+        }
+    }
+}
+
+func TestGlobalAvgPoolingBackward(t *testing.T) {
+    input := tensor.NewFeatureMap(2, 2, 2)
+    grad := GlobalAvgPoolingBackward([]float32{2, -1}, input)
+
+    expected := []float32{0.5, 0.5, 0.5, 0.5, -0.25, -0.25, -0.25, -0.25}
+    for i, want := range expected {
+        if grad.Data[i] != want {
+            t.Errorf("Grad %d = %f, expected %f", i, grad.Data[i], want)
+        }
+    }
+}
+
+func TestBatchNormReLUBackward(t *testing.T) {
+    bn := &BatchNormParams{
+        Mean:     []float32{0},
+        Variance: []float32{3},
+        Scale:    []float32{4},
+        Shift:    []float32{0},
+        Epsilon:  1,
+    }
+    output := tensor.NewFeatureMap(1, 2, 1)
+    copy(output.Data, []float32{1, -1})
+    outputGrad := tensor.NewFeatureMap(1, 2, 1)
+    outputGrad.Fill(3)
+
+    // Scale/sqrt(variance+epsilon) = 2 where ReLU passed the value, 0 where it clipped it
+    grad := BatchNormReLUBackward(outputGrad, output, bn, true)
+    if grad.Data[0] != 6 || grad.Data[1] != 0 {
+        t.Errorf("Expected grads [6 0], got %v", grad.Data)
+    }
+    if outputGrad.Data[0] != 3 {
+        t.Error("BatchNormReLUBackward modified its input")
+    }
+    if grad := BatchNormReLUBackward(outputGrad, output, nil, false); grad.Data[1] != 3 {
+        t.Errorf("Without batch norm or ReLU the grad should pass through, got %v", grad.Data)
+    }
+}
+
+// numericGrad returns the central difference of f with respect to *x
+func numericGrad(x *float32, f func() float64) float64 {
+    const eps = 1e-2
+    saved := *x
+    *x = saved + eps
+    plus := f()
+    *x = saved - eps
+    minus := f()
+    *x = saved
+    return (plus - minus) / (2 * eps)
+}
+
+// randomFeatureMap returns a feature map of values in [-1, 1)
+func randomFeatureMap(rng *rand.Rand, height, width, channels int) *tensor.FeatureMap {
+    fm := tensor.NewFeatureMap(height, width, channels)
+    for i := range fm.Data {
+        fm.Data[i] = rng.Float32()*2 - 1
+    }
+    return fm
+}
+
+// checkGrad fails t when analytic and numeric gradients differ by more than 1%,
+// or by more than 0.01 for gradients below 1
+func checkGrad(t *testing.T, what string, analytic, numeric float64) {
+    t.Helper()
+    if math.Abs(analytic-numeric) > 1e-2*math.Max(1, math.Abs(numeric)) {
+        t.Errorf("%s: analytic gradient %f, numeric %f", what, analytic, numeric)
+    }
+}
+
+func TestReLUBackward(t *testing.T) {
+    output := tensor.NewFeatureMap(1, 3, 1)
+    copy(output.Data, []float32{2, 0, -1})
+    outputGrad := tensor.NewFeatureMap(1, 3, 1)
+    outputGrad.Fill(5)
+
+    grad := ReLUBackward(outputGrad, output)
+    if grad.Data[0] != 5 || grad.Data[1] != 0 || grad.Data[2] != 0 {
+        t.Errorf("Expected grads [5 0 0], got %v", grad.Data)
+    }
+}
+
+func TestAvgPooling2DBackwardFiniteDifferences(t *testing.T) {
+    rng := rand.New(rand.NewSource(2))
+    input := randomFeatureMap(rng, 5, 5, 2)
+    weights := randomFeatureMap(rng, 2, 2, 2)
+    loss := func() float64 { return sumWeighted(AvgPooling2D(input, 3, 2), weights) }
+
+    grad := AvgPooling2DBackward(weights, input, 3, 2)
+    for i := range input.Data {
+        checkGrad(t, "input", float64(grad.Data[i]), numericGrad(&input.Data[i], loss))
+    }
+}
+
+func TestBatchNormBackwardFiniteDifferences(t *testing.T) {
+    rng := rand.New(rand.NewSource(3))
+    input := randomFeatureMap(rng, 3, 3, 2)
+    weights := randomFeatureMap(rng, 3, 3, 2)
+    bn := &BatchNormParams{
+        Mean:     []float32{0.2, -0.1},
+        Variance: []float32{0.5, 2},
+        Scale:    []float32{1.5, -0.7},
+        Shift:    []float32{0.1, 0.3},
+        Epsilon:  1e-3,
+    }
+    // BatchNormalize ends in a ReLU; the backward pass is of the affine part only
+    loss := func() float64 {
+        var sum float64
+        for c := 0; c < input.Channels; c++ {
+            std := math.Sqrt(float64(bn.Variance[c] + bn.Epsilon))
+            for h := 0; h < input.Height; h++ {
+                for w := 0; w < input.Width; w++ {
+                    y := float64(bn.Scale[c])*(float64(input.Get(c, h, w))-float64(bn.Mean[c]))/std + float64(bn.Shift[c])
+                    sum += y * float64(weights.Get(c, h, w))
+                }
+            }
+        }
+        return sum
+    }
+
+    grad, scaleGrad, shiftGrad := BatchNormBackward(weights, input, bn)
+    for i := range input.Data {
+        checkGrad(t, "input", float64(grad.Data[i]), numericGrad(&input.Data[i], loss))
+    }
+    for c := range bn.Scale {
+        checkGrad(t, "scale", float64(scaleGrad[c]), numericGrad(&bn.Scale[c], loss))
+        checkGrad(t, "shift", float64(shiftGrad[c]), numericGrad(&bn.Shift[c], loss))
+    }
+}
+
+func TestBatchNormTrainingBackwardFiniteDifferences(t *testing.T) {
+    rng := rand.New(rand.NewSource(4))
+    inputs := []*tensor.FeatureMap{randomFeatureMap(rng, 2, 3, 2), randomFeatureMap(rng, 2, 3, 2)}
+    weights := []*tensor.FeatureMap{randomFeatureMap(rng, 2, 3, 2), randomFeatureMap(rng, 2, 3, 2)}
+    bn := &BatchNormParams{Scale: []float32{1.5, -0.7}, Shift: []float32{0.1, 0.3}, Epsilon: 1e-3}
+    loss := func() float64 {
+        means, variances := ComputeBatchStatistics(inputs)
+        var sum float64
+        for b, input := range inputs {
+            for c := 0; c < input.Channels; c++ {
+                std := math.Sqrt(float64(variances[c] + bn.Epsilon))
+                for h := 0; h < input.Height; h++ {
+                    for w := 0; w < input.Width; w++ {
+                        y := float64(bn.Scale[c])*(float64(input.Get(c, h, w))-float64(means[c]))/std + float64(bn.Shift[c])
+                        sum += y * float64(weights[b].Get(c, h, w))
+                    }
+                }
+            }
+        }
+        return sum
+    }
+
+    grads, scaleGrad, shiftGrad := BatchNormTrainingBackward(weights, inputs, bn)
+    for b, input := range inputs {
+        for i := range input.Data {
+            checkGrad(t, "input", float64(grads[b].Data[i]), numericGrad(&input.Data[i], loss))
+        }
+    }
+    for c := range bn.Scale {
+        checkGrad(t, "scale", float64(scaleGrad[c]), numericGrad(&bn.Scale[c], loss))
+        checkGrad(t, "shift", float64(shiftGrad[c]), numericGrad(&bn.Shift[c], loss))
+    }
+}
+
+func TestSoftmaxBackwardFiniteDifferences(t *testing.T) {
+    logits := []float32{0.5, -1, 2, 0.1}
+    weights := []float32{1, -2, 0.5, 3}
+    loss := func() float64 {
+        var sum float64
+        for i, p := range Softmax(logits) {
+            sum += float64(p * weights[i])
+        }
+        return sum
+    }
+
+    grad := SoftmaxBackward(weights, Softmax(logits))
+    for i := range logits {
+        checkGrad(t, "logit", float64(grad[i]), numericGrad(&logits[i], loss))
+    }
+}
+
+func TestSoftmaxCrossEntropyBackward(t *testing.T) {
+    logits := []float32{0.5, -1, 2, 0.1}
+    labels := []float32{0, 1, 0, 0}
+    loss, grad := SoftmaxCrossEntropyBackward(logits, labels)
+    if want := CrossEntropyLossFromLogits(logits, labels); loss != want {
+        t.Errorf("Expected loss %f, got %f", want, loss)
+    }
+    for i := range logits {
+        numeric := numericGrad(&logits[i], func() float64 {
+            return float64(CrossEntropyLossFromLogits(logits, labels))
+        })
+        checkGrad(t, "logit", float64(grad[i]), numeric)
+    }
+}
@@ -0,0 +1,198 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"sync"
+)
+
+/**
+* Buffer pooling
+
+A single TinyCNN inference allocates a padded input and an output feature map
+for every convolution, which adds up to a few hundred kilobytes of short-lived
+garbage per image. Layer shapes are fixed by the architecture, so the same
+shapes come back on every call: keeping released buffers in per-shape free
+lists lets repeated inferences reuse them instead of allocating.
+
+Pooled buffers are handed out dirty. Callers must overwrite every element
+(convolution outputs do) or clear the buffer first (padding does).
+*/
+
+// maxPooledPerShape bounds how many idle buffers are kept for one shape so a
+// burst of concurrent inferences doesn't pin memory forever
+const maxPooledPerShape = 8
+
+// featureMapShape is the pool key for a feature map
+type featureMapShape struct {
+    height   int
+    width    int
+    channels int
+}
+
+// FeatureMapPool recycles feature maps and scratch slices by shape
+// It is safe for concurrent use
+type FeatureMapPool struct {
+    mu          sync.Mutex
+    featureMaps map[featureMapShape][]*tensor.FeatureMap
+    slices      map[int][][]float32
+    int8Slices  map[int][][]int8  // Scratch of the int8 convolution path
+    int32Slices map[int][][]int32
+    limit       int // Idle buffers kept per shape; 0 disables pooling
+}
+
+// NewFeatureMapPool creates an empty pool
+func NewFeatureMapPool() *FeatureMapPool {
+    return &FeatureMapPool{
+        featureMaps: make(map[featureMapShape][]*tensor.FeatureMap),
+        slices:      make(map[int][][]float32),
+        int8Slices:  make(map[int][][]int8),
+        int32Slices: make(map[int][][]int32),
+        limit:       maxPooledPerShape,
+    }
+}
+
+// SetLimit changes how many idle buffers are kept per shape
+// A limit of 0 turns the pool into a plain allocator and drops any idle buffers
+func (p *FeatureMapPool) SetLimit(limit int) {
+    if limit < 0 {
+        limit = 0
+    }
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    p.limit = limit
+    for key, free := range p.featureMaps {
+        if len(free) > limit {
+            clear(free[limit:])
+            p.featureMaps[key] = free[:limit]
+        }
+    }
+    trimSlices(p.slices, limit)
+    trimSlices(p.int8Slices, limit)
+    trimSlices(p.int32Slices, limit)
+}
+
+// trimSlices drops idle slices beyond limit from every free list of lists
+func trimSlices[T any](lists map[int][][]T, limit int) {
+    for n, free := range lists {
+        if len(free) > limit {
+            clear(free[limit:])
+            lists[n] = free[:limit]
+        }
+    }
+}
+
+// Limit returns the number of idle buffers kept per shape
+func (p *FeatureMapPool) Limit() int {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.limit
+}
+
+// Get returns a feature map of the given shape, reusing a released one if available
+// The contents of a reused feature map are unspecified
+func (p *FeatureMapPool) Get(height, width, channels int) *tensor.FeatureMap {
+    key := featureMapShape{height, width, channels}
+
+    p.mu.Lock()
+    free := p.featureMaps[key]
+    if n := len(free); n > 0 {
+        fm := free[n-1]
+        free[n-1] = nil
+        p.featureMaps[key] = free[:n-1]
+        p.mu.Unlock()
+        return fm
+    }
+    p.mu.Unlock()
+
+    return tensor.NewFeatureMap(height, width, channels)
+}
+
+// Put returns a feature map to the pool. The caller must not use it afterwards.
+func (p *FeatureMapPool) Put(fm *tensor.FeatureMap) {
+    if fm == nil || len(fm.Data) != fm.Height*fm.Width*fm.Channels {
+        return
+    }
+
+    key := featureMapShape{fm.Height, fm.Width, fm.Channels}
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    if len(p.featureMaps[key]) < p.limit {
+        p.featureMaps[key] = append(p.featureMaps[key], fm)
+    }
+}
+
+// GetSlice returns a float32 slice of length n with unspecified contents
+func (p *FeatureMapPool) GetSlice(n int) []float32 {
+    return getPooled(p, p.slices, n)
+}
+
+// PutSlice returns a slice obtained from GetSlice to the pool
+func (p *FeatureMapPool) PutSlice(s []float32) {
+    putPooled(p, p.slices, s)
+}
+
+// GetInt8Slice returns an int8 slice of length n with unspecified contents
+func (p *FeatureMapPool) GetInt8Slice(n int) []int8 {
+    return getPooled(p, p.int8Slices, n)
+}
+
+// PutInt8Slice returns a slice obtained from GetInt8Slice to the pool
+func (p *FeatureMapPool) PutInt8Slice(s []int8) {
+    putPooled(p, p.int8Slices, s)
+}
+
+// GetInt32Slice returns an int32 slice of length n with unspecified contents
+func (p *FeatureMapPool) GetInt32Slice(n int) []int32 {
+    return getPooled(p, p.int32Slices, n)
+}
+
+// PutInt32Slice returns a slice obtained from GetInt32Slice to the pool
+func (p *FeatureMapPool) PutInt32Slice(s []int32) {
+    putPooled(p, p.int32Slices, s)
+}
+
+// getPooled takes a slice of length n from one of the pool's free lists or allocates it
+func getPooled[T any](p *FeatureMapPool, lists map[int][][]T, n int) []T {
+    p.mu.Lock()
+    free := lists[n]
+    if k := len(free); k > 0 {
+        s := free[k-1]
+        free[k-1] = nil
+        lists[n] = free[:k-1]
+        p.mu.Unlock()
+        return s
+    }
+    p.mu.Unlock()
+
+    return make([]T, n)
+}
+
+// putPooled keeps s on its free list unless the list is full
+func putPooled[T any](p *FeatureMapPool, lists map[int][][]T, s []T) {
+    if s == nil {
+        return
+    }
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    if len(lists[len(s)]) < p.limit {
+        lists[len(s)] = append(lists[len(s)], s)
+    }
+}
+
+// Len returns the number of idle feature maps currently held by the pool
+func (p *FeatureMapPool) Len() int {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    total := 0
+    for _, free := range p.featureMaps {
+        total += len(free)
+    }
+    return total
+}
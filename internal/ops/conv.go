@@ -51,20 +51,40 @@ func Conv2D(input *tensor.FeatureMap, kernel *tensor.Kernel, bias []float32, con
     outWidth := (paddedInput.Width-kernel.Size)/config.Stride + 1
     
     // Create output feature map
-    output := tensor.NewFeatureMap(outHeight, outWidth, kernel.Filters)
+    output := tensor.NewFeatureMapWithLayout(outHeight, outWidth, kernel.Filters, input.Layout)
     
-    // Perform convolution for each output filter
-    for f := 0; f < kernel.Filters; f++ {
-        convolveFilter(paddedInput, kernel, output, f, bias[f], config)
-    }
+    conv2DSerial(paddedInput, kernel, bias, config, output)
     
     return output
 }
 
+// conv2DSerial convolves an already padded input into a preallocated output
+func conv2DSerial(paddedInput *tensor.FeatureMap, kernel *tensor.Kernel, bias []float32, 
+	config Conv2DConfig, output *tensor.FeatureMap) {
+	// HWC inputs take the channel-contiguous path with an identity epilogue
+	if paddedInput.Layout == tensor.LayoutHWC {
+		scale, shift := foldBatchNorm(bias, nil)
+		fusedConvSerial(paddedInput, kernel, output, scale, shift, false, config)
+		return
+	}
+
+	// Perform convolution for each output filter
+	for f := 0; f < kernel.Filters; f++ {
+		convolveFilter(paddedInput, kernel, output, f, bias[f], config)
+	}
+}
+
 // convolveFilter performs convolution for a single output filter
 func convolveFilter(input *tensor.FeatureMap, kernel *tensor.Kernel, output *tensor.FeatureMap, 
 	filterIdx int, bias float32, config Conv2DConfig) {
-	for i := 0; i < output.Height; i++ {
+	convolveFilterRows(input, kernel, output, filterIdx, bias, config, 0, output.Height)
+}
+
+// convolveFilterRows performs convolution for a single output filter over
+// output rows [rowStart, rowEnd)
+func convolveFilterRows(input *tensor.FeatureMap, kernel *tensor.Kernel, output *tensor.FeatureMap, 
+	filterIdx int, bias float32, config Conv2DConfig, rowStart, rowEnd int) {
+	for i := rowStart; i < rowEnd; i++ {
 		for j := 0; j < output.Width; j++ {
 		// Compute convolution at position (i, j)
 		var sum float32
@@ -97,11 +117,27 @@ func convolveFilter(input *tensor.FeatureMap, kernel *tensor.Kernel, output *ten
 /**
 * Parallel convolution - Why parallel convolution?
 - Each output filter can be computed independently
+- Each output row within a filter is independent as well
 - Modern CPUs have multiple cores that can work simultaneously
 - Go's goroutines make parallelization easy and efficient
 - Typical speedup: 2-4x on quad-core systems
+
+Splitting work only across filters starves cores on narrow layers
+(e.g. the 10-filter conv7), so work is handed out as (filter, row band)
+tiles. Each filter is cut into enough row bands that the total number of
+tiles covers every worker a few times over.
 */
 
+// convTile is a unit of work for Conv2DParallel: one filter over a band of output rows
+type convTile struct {
+    filter   int
+    rowStart int
+    rowEnd   int
+}
+
+// tilesPerWorker controls how many tiles each worker receives on average,
+// which smooths out load imbalance between bands
+const tilesPerWorker = 4
 
 // Conv2DParallel performs parallel convolution using goroutines
 // This provides significant speedup on multi-core systems
@@ -122,16 +158,31 @@ func Conv2DParallel(input *tensor.FeatureMap, kernel *tensor.Kernel, bias []floa
     outWidth := (paddedInput.Width-kernel.Size)/config.Stride + 1
     
     // Create output feature map
-    output := tensor.NewFeatureMap(outHeight, outWidth, kernel.Filters)
+    output := tensor.NewFeatureMapWithLayout(outHeight, outWidth, kernel.Filters, input.Layout)
+    
+    conv2DParallel(paddedInput, kernel, bias, config, output)
+    
+    return output
+}
+
+// conv2DParallel is the tiled worker loop behind Conv2DParallel, writing into a preallocated output
+func conv2DParallel(paddedInput *tensor.FeatureMap, kernel *tensor.Kernel, bias []float32, 
+    config Conv2DConfig, output *tensor.FeatureMap) {
+    if paddedInput.Layout == tensor.LayoutHWC {
+        scale, shift := foldBatchNorm(bias, nil)
+        fusedConvParallel(paddedInput, kernel, output, scale, shift, false, config, 0)
+        return
+    }
     
-    // Determine number of workers (use all CPU cores)
+    // Partition the output into (filter, row band) tiles
     numWorkers := runtime.NumCPU()
-    if numWorkers > kernel.Filters {
-        numWorkers = kernel.Filters
+    tiles := partitionConvTiles(kernel.Filters, output.Height, numWorkers)
+    if numWorkers > len(tiles) {
+        numWorkers = len(tiles)
     }
     
     // Create work channels
-    jobs := make(chan int, kernel.Filters)
+    jobs := make(chan convTile, len(tiles))
     var wg sync.WaitGroup
     
     // Start worker goroutines
@@ -139,22 +190,54 @@ func Conv2DParallel(input *tensor.FeatureMap, kernel *tensor.Kernel, bias []floa
         wg.Add(1)
         go func() {
             defer wg.Done()
-            for filterIdx := range jobs {
-                convolveFilter(paddedInput, kernel, output, filterIdx, bias[filterIdx], config)
+            for tile := range jobs {
+                convolveFilterRows(paddedInput, kernel, output, tile.filter, bias[tile.filter], config,
+                    tile.rowStart, tile.rowEnd)
             }
         }()
     }
     
-    // Send filter indices to workers
-    for f := 0; f < kernel.Filters; f++ {
-        jobs <- f
+    // Send tiles to workers
+    for _, tile := range tiles {
+        jobs <- tile
     }
     close(jobs)
     
     // Wait for all workers to complete
     wg.Wait()
+}
+
+// partitionConvTiles splits filters × output rows into tiles so that there are
+// at least tilesPerWorker tiles per worker whenever the output is large enough
+func partitionConvTiles(filters, outHeight, numWorkers int) []convTile {
+    if numWorkers < 1 {
+        numWorkers = 1
+    }
     
-    return output
+    // Number of row bands per filter needed to reach the target tile count
+    target := numWorkers * tilesPerWorker
+    bands := (target + filters - 1) / filters
+    if bands > outHeight {
+        bands = outHeight
+    }
+    if bands < 1 {
+        bands = 1
+    }
+    
+    rowsPerBand := (outHeight + bands - 1) / bands
+    
+    tiles := make([]convTile, 0, filters*bands)
+    for f := 0; f < filters; f++ {
+        for rowStart := 0; rowStart < outHeight; rowStart += rowsPerBand {
+            rowEnd := rowStart + rowsPerBand
+            if rowEnd > outHeight {
+                rowEnd = outHeight
+            }
+            tiles = append(tiles, convTile{filter: f, rowStart: rowStart, rowEnd: rowEnd})
+        }
+    }
+    
+    return tiles
 }
 
 // validateConv2DInputs validates the inputs for convolution operation
@@ -0,0 +1,166 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+/**
+* Batched convolution
+
+Convolving the images of a batch one after the other streams every filter's
+weights through the cache once per image. In the im2col formulation of
+conv_gemm.go the images can share one matrix product instead: their patch
+matrices are laid side by side,
+```
+cols[k][b*P + p] = patch value k of output pixel p of image b     (P = outH*outW)
+Output_b[f][p]   = Σ_k Kernel[f][k] * cols[k][b*P + p]
+```
+so each weight is loaded once and applied to N*P columns, and the inner loop
+runs over rows N times longer. The price is N times the im2col scratch
+(1152 × 64 floats per image for TinyCNN's deepest layer).
+
+HWC inputs have no im2col path; they are convolved one image at a time with
+Conv2DFused. Conv2DInt8Batch is the int8 counterpart, and Conv2DFusedBatch4D
+runs the same product on a tensor.FeatureMapBatch, writing every image's
+output into one output batch.
+*/
+
+// Conv2DFusedBatch is Conv2DFused over a batch of inputs of the same shape and layout,
+// with one matrix product for the whole batch. Every output is pooled and may be
+// handed back with Release.
+func (ce *ConvolutionEngine) Conv2DFusedBatch(inputs []*tensor.FeatureMap, kernel *tensor.Kernel,
+    bias []float32, bn *BatchNormParams, applyReLU bool, config Conv2DConfig) []*tensor.FeatureMap {
+
+    validateBatchInputs(inputs, kernel, bias, bn, config)
+    outputs := make([]*tensor.FeatureMap, len(inputs))
+    if len(inputs) <= 1 || inputs[0].Layout == tensor.LayoutHWC {
+        for b, input := range inputs {
+            outputs[b] = ce.Conv2DFused(input, kernel, bias, bn, applyReLU, config)
+        }
+        return outputs
+    }
+
+    first := inputs[0]
+    outHeight, outWidth := GetConvOutputDims(first.Height, first.Width, kernel.Size, config.Padding, config.Stride)
+    for b := range outputs {
+        outputs[b] = ce.Buffers().Get(outHeight, outWidth, kernel.Filters)
+        outputs[b].Layout = first.Layout
+    }
+    ce.conv2DGemmBatch(inputs, outputs, kernel, bias, bn, applyReLU, config)
+    return outputs
+}
+
+// Conv2DFusedBatch4D is Conv2DFusedBatch over the images of a batch, writing the
+// outputs into a new batch in the input's layout
+func (ce *ConvolutionEngine) Conv2DFusedBatch4D(input *tensor.FeatureMapBatch, kernel *tensor.Kernel,
+    bias []float32, bn *BatchNormParams, applyReLU bool, config Conv2DConfig) *tensor.FeatureMapBatch {
+
+    inputs := input.Images()
+    validateBatchInputs(inputs, kernel, bias, bn, config)
+    outHeight, outWidth := GetConvOutputDims(input.Height, input.Width, kernel.Size, config.Padding, config.Stride)
+    output := tensor.NewFeatureMapBatchWithLayout(input.N, outHeight, outWidth, kernel.Filters, input.Layout)
+    outputs := output.Images()
+    if input.N <= 1 || input.Layout == tensor.LayoutHWC {
+        for b, image := range inputs {
+            result := ce.Conv2DFused(image, kernel, bias, bn, applyReLU, config)
+            copy(outputs[b].Data, result.Data)
+            ce.Release(result)
+        }
+        return output
+    }
+
+    ce.conv2DGemmBatch(inputs, outputs, kernel, bias, bn, applyReLU, config)
+    return output
+}
+
+// conv2DGemmBatch convolves CHW inputs with one matrix product into outputs of
+// the output shape, which may be views of one FeatureMapBatch
+func (ce *ConvolutionEngine) conv2DGemmBatch(inputs, outputs []*tensor.FeatureMap, kernel *tensor.Kernel,
+    bias []float32, bn *BatchNormParams, applyReLU bool, config Conv2DConfig) {
+
+    buffers := ce.Buffers()
+    outHeight, outWidth := outputs[0].Height, outputs[0].Width
+    pixels := outHeight * outWidth
+    columns := len(inputs) * pixels
+    patch := kernel.Channels * kernel.Size * kernel.Size
+
+    // Image b's patches are columns [b*pixels, (b+1)*pixels) of every row
+    cols := buffers.GetSlice(patch * columns)
+    for b, input := range inputs {
+        paddedInput, pooledPad := ce.padPooled(input, config.Padding)
+        im2colRowsInto(paddedInput, kernel.Size, config.Stride, outHeight, outWidth, cols, columns, b*pixels)
+        if pooledPad {
+            ce.Release(paddedInput)
+        }
+    }
+
+    scale := buffers.GetSlice(kernel.Filters)
+    shift := buffers.GetSlice(kernel.Filters)
+    foldBatchNormInto(scale, shift, bias, bn)
+
+    // Filters are split into bands; each worker accumulates one filter row at a time
+    numWorkers := 1
+    if ce.UseParallel {
+        numWorkers = ce.NumWorkers
+        if numWorkers <= 0 {
+            numWorkers = runtime.NumCPU()
+        }
+    }
+    band := (kernel.Filters + numWorkers - 1) / numWorkers
+
+    var wg sync.WaitGroup
+    for start := 0; start < kernel.Filters; start += band {
+        end := min(start+band, kernel.Filters)
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            row := buffers.GetSlice(columns)
+            defer buffers.PutSlice(row)
+
+            for f := start; f < end; f++ {
+                clear(row)
+                weights := kernel.Weights[f*patch : (f+1)*patch]
+                for k, w := range weights {
+                    src := cols[k*columns : (k+1)*columns]
+                    for p, v := range src {
+                        row[p] += w * v
+                    }
+                }
+
+                // Epilogue while the row is still in cache
+                for b, output := range outputs {
+                    out := output.Data[f*pixels : (f+1)*pixels]
+                    for p, v := range row[b*pixels : (b+1)*pixels] {
+                        out[p] = epilogue(v, scale[f], shift[f], applyReLU)
+                    }
+                }
+            }
+        }()
+    }
+    wg.Wait()
+
+    buffers.PutSlice(cols)
+    buffers.PutSlice(scale)
+    buffers.PutSlice(shift)
+}
+
+// validateBatchInputs panics if an input of a batched convolution doesn't fit the
+// kernel or differs from the first input in shape or layout
+func validateBatchInputs(inputs []*tensor.FeatureMap, kernel *tensor.Kernel, bias []float32,
+    bn *BatchNormParams, config Conv2DConfig) {
+
+    for b, input := range inputs {
+        validateFusedConvInputs(input, kernel, bias, bn, config)
+
+        first := inputs[0]
+        if input.Height != first.Height || input.Width != first.Width || input.Channels != first.Channels {
+            panic(fmt.Sprintf("Conv2D validation failed: batch input %d is %s, input 0 is %s", b, input, first))
+        }
+        if input.Layout != first.Layout {
+            panic(fmt.Sprintf("Conv2D validation failed: batch input %d is %s, input 0 is %s", b, input.Layout, first.Layout))
+        }
+    }
+}
@@ -0,0 +1,237 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+)
+
+/**
+* Fused Conv + BatchNorm + ReLU
+
+Running convolution, batch normalization and ReLU as separate passes reads and
+writes the whole output tensor three times. Batch norm at inference time is a
+per-channel affine transform, so it can be folded into the convolution epilogue:
+```
+y = gamma * ((conv + bias) - mean) / sqrt(var + eps) + beta
+  = a[f] * conv + b[f]
+a[f] = gamma / sqrt(var + eps)
+b[f] = a[f] * (bias - mean) + beta
+```
+Each output value is then computed, scaled, shifted and clamped while it is still
+in a register, in a single pass over the output.
+*/
+
+// foldBatchNorm computes the per-filter multiplier and offset applied to the raw
+// convolution sum. A nil bn yields the identity transform plus bias.
+func foldBatchNorm(bias []float32, bn *BatchNormParams) ([]float32, []float32) {
+    scale := make([]float32, len(bias))
+    shift := make([]float32, len(bias))
+    foldBatchNormInto(scale, shift, bias, bn)
+    return scale, shift
+}
+
+// foldBatchNormInto is foldBatchNorm writing into caller-provided slices
+func foldBatchNormInto(scale, shift, bias []float32, bn *BatchNormParams) {
+    for f := range bias {
+        scale[f], shift[f] = foldFilter[float32](bias, bn, f)
+    }
+}
+
+// foldFilter computes the multiplier and offset of filter f in T; every
+// fused convolution, float32 or generic, folds batch norm through it
+func foldFilter[T tensor.Float](bias []float32, bn *BatchNormParams, f int) (T, T) {
+    if bn == nil {
+        return 1, T(bias[f])
+    }
+    scale := T(bn.Scale[f]) / T(math.Sqrt(float64(T(bn.Variance[f])+T(bn.Epsilon))))
+    return scale, scale*(T(bias[f])-T(bn.Mean[f])) + T(bn.Shift[f])
+}
+
+// epilogue scales and shifts one raw convolution sum and applies the optional ReLU
+func epilogue[T tensor.Float](sum, scale, shift T, applyReLU bool) T {
+    value := scale*sum + shift
+    if applyReLU && value < 0 {
+        return 0
+    }
+    return value
+}
+
+// Conv2DBatchNormReLU performs convolution, batch normalization and optional ReLU in one pass
+// bn may be nil, in which case only the bias (and ReLU if requested) is applied
+func Conv2DBatchNormReLU(input *tensor.FeatureMap, kernel *tensor.Kernel, bias []float32, 
+    bn *BatchNormParams, applyReLU bool, config Conv2DConfig) *tensor.FeatureMap {
+    
+    paddedInput, output, scale, shift := prepareFusedConv(input, kernel, bias, bn, config)
+    fusedConvSerial(paddedInput, kernel, output, scale, shift, applyReLU, config)
+    return output
+}
+
+// Conv2DBatchNormReLUParallel is the parallel version of Conv2DBatchNormReLU
+// Work is partitioned into (filter, row band) tiles like Conv2DParallel
+func Conv2DBatchNormReLUParallel(input *tensor.FeatureMap, kernel *tensor.Kernel, bias []float32, 
+    bn *BatchNormParams, applyReLU bool, config Conv2DConfig) *tensor.FeatureMap {
+    
+    paddedInput, output, scale, shift := prepareFusedConv(input, kernel, bias, bn, config)
+    fusedConvParallel(paddedInput, kernel, output, scale, shift, applyReLU, config, 0)
+    return output
+}
+
+// fusedConvSerial runs the fused convolution over an already padded input into output
+func fusedConvSerial(paddedInput *tensor.FeatureMap, kernel *tensor.Kernel, output *tensor.FeatureMap, 
+    scale, shift []float32, applyReLU bool, config Conv2DConfig) {
+    
+    if paddedInput.Layout == tensor.LayoutHWC {
+        convolveRowsFusedHWC(paddedInput, kernel.WeightsHWC(), kernel, output, scale, shift, applyReLU, 
+            config, 0, output.Height)
+        return
+    }
+    
+    for f := 0; f < kernel.Filters; f++ {
+        convolveFilterRowsFused(paddedInput, kernel, output, f, scale[f], shift[f], applyReLU, 
+            config, 0, output.Height)
+    }
+}
+
+// fusedConvParallel is fusedConvSerial spread over (filter, row band) tiles
+// workers bounds the goroutines used (0 = one per CPU)
+func fusedConvParallel(paddedInput *tensor.FeatureMap, kernel *tensor.Kernel, output *tensor.FeatureMap, 
+    scale, shift []float32, applyReLU bool, config Conv2DConfig, workers int) {
+    
+    // HWC computes every filter of a pixel together, so it is split by row band only
+    hwc := paddedInput.Layout == tensor.LayoutHWC
+    var weightsHWC []float32
+    
+    numWorkers := workers
+    if numWorkers <= 0 {
+        numWorkers = runtime.NumCPU()
+    }
+    var tiles []convTile
+    if hwc {
+        weightsHWC = kernel.WeightsHWC()
+        tiles = partitionConvTiles(1, output.Height, numWorkers)
+    } else {
+        tiles = partitionConvTiles(kernel.Filters, output.Height, numWorkers)
+    }
+    if numWorkers > len(tiles) {
+        numWorkers = len(tiles)
+    }
+    
+    jobs := make(chan convTile, len(tiles))
+    var wg sync.WaitGroup
+    
+    for w := 0; w < numWorkers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for tile := range jobs {
+                if hwc {
+                    convolveRowsFusedHWC(paddedInput, weightsHWC, kernel, output, scale, shift, applyReLU, 
+                        config, tile.rowStart, tile.rowEnd)
+                    continue
+                }
+                convolveFilterRowsFused(paddedInput, kernel, output, tile.filter, 
+                    scale[tile.filter], shift[tile.filter], applyReLU, config, tile.rowStart, tile.rowEnd)
+            }
+        }()
+    }
+    
+    for _, tile := range tiles {
+        jobs <- tile
+    }
+    close(jobs)
+    
+    wg.Wait()
+}
+
+// prepareFusedConv validates inputs, pads the input, allocates the output and folds batch norm
+func prepareFusedConv(input *tensor.FeatureMap, kernel *tensor.Kernel, bias []float32, 
+    bn *BatchNormParams, config Conv2DConfig) (*tensor.FeatureMap, *tensor.FeatureMap, []float32, []float32) {
+    
+    validateFusedConvInputs(input, kernel, bias, bn, config)
+    
+    paddedInput := input
+    if config.Padding > 0 {
+        paddedInput = tensor.PadFeatureMap(input, config.Padding)
+    }
+    
+    outHeight := (paddedInput.Height-kernel.Size)/config.Stride + 1
+    outWidth := (paddedInput.Width-kernel.Size)/config.Stride + 1
+    output := tensor.NewFeatureMapWithLayout(outHeight, outWidth, kernel.Filters, input.Layout)
+    
+    scale, shift := foldBatchNorm(bias, bn)
+    
+    return paddedInput, output, scale, shift
+}
+
+// validateFusedConvInputs panics if the convolution or batch norm parameters are inconsistent
+func validateFusedConvInputs(input *tensor.FeatureMap, kernel *tensor.Kernel, bias []float32, 
+    bn *BatchNormParams, config Conv2DConfig) {
+    
+    if err := validateConv2DInputs(input, kernel, bias, config); err != nil {
+        panic(fmt.Sprintf("Conv2D validation failed: %v", err))
+    }
+    
+    if bn != nil && len(bn.Mean) != kernel.Filters {
+        panic("BatchNorm parameters don't match kernel filters")
+    }
+}
+
+// convolveFilterRowsFused computes one filter over output rows [rowStart, rowEnd)
+// and applies the folded batch norm and ReLU before storing each value
+func convolveFilterRowsFused(input *tensor.FeatureMap, kernel *tensor.Kernel, output *tensor.FeatureMap, 
+    filterIdx int, scale, shift float32, applyReLU bool, config Conv2DConfig, rowStart, rowEnd int) {
+    
+    for i := rowStart; i < rowEnd; i++ {
+        for j := 0; j < output.Width; j++ {
+            var sum float32
+            
+            for c := 0; c < kernel.Channels; c++ {
+                for m := 0; m < kernel.Size; m++ {
+                    for n := 0; n < kernel.Size; n++ {
+                        inputVal := input.GetUnsafe(c, i*config.Stride+m, j*config.Stride+n)
+                        sum += inputVal * kernel.GetWeightUnsafe(filterIdx, c, m, n)
+                    }
+                }
+            }
+            
+            output.SetUnsafe(filterIdx, i, j, epilogue(sum, scale, shift, applyReLU))
+        }
+    }
+}
+
+// convolveRowsFusedHWC computes every filter over output rows [rowStart, rowEnd) for HWC
+// input and output. weightsHWC is kernel.WeightsHWC(), so for each kernel tap the inner
+// loop is a dot product of two contiguous runs of kernel.Channels values.
+func convolveRowsFusedHWC(input *tensor.FeatureMap, weightsHWC []float32, kernel *tensor.Kernel, 
+    output *tensor.FeatureMap, scale, shift []float32, applyReLU bool, config Conv2DConfig, rowStart, rowEnd int) {
+    
+    channels := kernel.Channels
+    size := kernel.Size
+    filterStride := size * size * channels
+    
+    for i := rowStart; i < rowEnd; i++ {
+        for j := 0; j < output.Width; j++ {
+            outBase := (i*output.Width + j) * output.Channels
+            
+            for f := 0; f < kernel.Filters; f++ {
+                filterWeights := weightsHWC[f*filterStride : (f+1)*filterStride]
+                var sum float32
+                
+                for m := 0; m < size; m++ {
+                    rowBase := ((i*config.Stride+m)*input.Width + j*config.Stride) * channels
+                    pixels := input.Data[rowBase : rowBase+size*channels]
+                    taps := filterWeights[m*size*channels : (m+1)*size*channels]
+                    
+                    for k, w := range taps {
+                        sum += pixels[k] * w
+                    }
+                }
+                
+                output.Data[outBase+f] = epilogue(sum, scale[f], shift[f], applyReLU)
+            }
+        }
+    }
+}
@@ -0,0 +1,117 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+)
+
+/**
+* Convolution as matrix multiplication (im2col + GEMM)
+
+Every output pixel is a dot product between one filter and one input patch.
+Unrolling all patches into the columns of a matrix turns the whole layer into
+a single matrix product:
+```
+cols[c*K*K + m*K + n][i*outW + j] = Input[c][i*stride+m][j*stride+n]
+Output[f][p] = Σ_k Kernel[f][k] * cols[k][p]     (k over C*K*K, p over outH*outW)
+```
+The kernel's [f][c][h][w] storage already has each filter as a contiguous row
+of length C*K*K, so no weight reordering is needed. The inner loop becomes a
+scaled vector add over a contiguous row of cols, which is friendly to the
+compiler's bounds-check elimination and to the cache, at the cost of a
+C*K*K × outH*outW scratch matrix.
+*/
+
+// Conv2DGEMM performs convolution via im2col followed by a matrix multiply
+func Conv2DGEMM(input *tensor.FeatureMap, kernel *tensor.Kernel, bias []float32, config Conv2DConfig) *tensor.FeatureMap {
+    if err := validateConv2DInputs(input, kernel, bias, config); err != nil {
+        panic(fmt.Sprintf("Conv2D validation failed: %v", err))
+    }
+
+    paddedInput := input
+    if config.Padding > 0 {
+        paddedInput = tensor.PadFeatureMap(input, config.Padding)
+    }
+
+    outHeight := (paddedInput.Height-kernel.Size)/config.Stride + 1
+    outWidth := (paddedInput.Width-kernel.Size)/config.Stride + 1
+    output := tensor.NewFeatureMapWithLayout(outHeight, outWidth, kernel.Filters, input.Layout)
+
+    scale, shift := foldBatchNorm(bias, nil)
+    cols := make([]float32, gemmColsSize(kernel, output))
+    convGEMM(paddedInput, kernel, output, scale, shift, false, config, cols)
+
+    return output
+}
+
+// gemmColsSize returns the number of elements of the im2col scratch matrix
+func gemmColsSize(kernel *tensor.Kernel, output *tensor.FeatureMap) int {
+    return kernel.Channels * kernel.Size * kernel.Size * output.Height * output.Width
+}
+
+// convGEMM computes a convolution of an already padded input into output with the
+// folded batch norm epilogue. cols is scratch space of gemmColsSize elements.
+// HWC inputs are handed to the channel-contiguous direct path instead.
+func convGEMM(paddedInput *tensor.FeatureMap, kernel *tensor.Kernel, output *tensor.FeatureMap,
+    scale, shift []float32, applyReLU bool, config Conv2DConfig, cols []float32) {
+
+    if paddedInput.Layout == tensor.LayoutHWC {
+        fusedConvSerial(paddedInput, kernel, output, scale, shift, applyReLU, config)
+        return
+    }
+
+    im2colInto(paddedInput, kernel.Size, config.Stride, output.Height, output.Width, cols)
+
+    pixels := output.Height * output.Width
+    patch := kernel.Channels * kernel.Size * kernel.Size
+
+    for f := 0; f < kernel.Filters; f++ {
+        out := output.Data[f*pixels : (f+1)*pixels]
+        clear(out)
+
+        weights := kernel.Weights[f*patch : (f+1)*patch]
+        for k, w := range weights {
+            row := cols[k*pixels : (k+1)*pixels]
+            for p, v := range row {
+                out[p] += w * v
+            }
+        }
+
+        // Epilogue while the row is still in cache
+        for p, v := range out {
+            out[p] = epilogue(v, scale[f], shift[f], applyReLU)
+        }
+    }
+}
+
+// im2colInto unrolls the patches of a padded CHW input into cols, one row per (c, m, n)
+func im2colInto(input *tensor.FeatureMap, size, stride, outHeight, outWidth int, cols []float32) {
+    pixels := outHeight * outWidth
+    im2colRowsInto(input, size, stride, outHeight, outWidth, cols, pixels, 0)
+}
+
+// im2colRowsInto is im2colInto into rows of rowLength values, starting offset values into
+// each row, so the patches of several images can sit side by side in one matrix
+func im2colRowsInto(input *tensor.FeatureMap, size, stride, outHeight, outWidth int, cols []float32,
+    rowLength, offset int) {
+
+    pixels := outHeight * outWidth
+    row := 0
+
+    for c := 0; c < input.Channels; c++ {
+        plane := input.Data[c*input.Height*input.Width : (c+1)*input.Height*input.Width]
+        for m := 0; m < size; m++ {
+            for n := 0; n < size; n++ {
+                dst := cols[row*rowLength+offset : row*rowLength+offset+pixels]
+                for i := 0; i < outHeight; i++ {
+                    src := plane[(i*stride+m)*input.Width+n:]
+                    line := dst[i*outWidth : (i+1)*outWidth]
+                    for j := range line {
+                        line[j] = src[j*stride]
+                    }
+                }
+                row++
+            }
+        }
+    }
+}
@@ -0,0 +1,189 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/quant"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+/**
+* Int8 convolution
+
+Conv2DInt8 is the im2col formulation of conv_gemm.go with both operands in
+int8. For an input quantized at activation scale sx and filter f quantized
+at weight scale sw[f]:
+```
+x ≈ qx * sx,   w ≈ qw * sw[f]
+Σ w*x ≈ sx * sw[f] * Σ qw*qx          (the sum runs in int32, see GemmInt8)
+```
+1. the input is quantized once at sx; zero padding stays exactly 0
+2. the patches are unrolled into one row of C*K*K int8 values per output
+   pixel, in the kernel's [c][h][w] order, so kernel rows need no reordering
+3. GemmInt8 multiplies the filters with the patches
+4. every sum is scaled back by sx * sw[f] and goes through the same folded
+   batch norm and ReLU epilogue as the float paths
+
+The bias stays float32 and is added in the epilogue. The result is float32,
+so layers that don't run in int8 (pooling, custom ops, the classifier) see
+ordinary feature maps.
+
+Conv2DInt8Batch stacks the patches of several images into one B matrix of
+N*outH*outW rows, so each filter row of A is read once for the whole batch
+instead of once per image. Every image keeps its own input scale.
+*/
+
+// Conv2DInt8 performs a convolution with int8 weights, quantizing the input at
+// inputScale and accumulating in int32. Batch norm and ReLU are applied as in
+// Conv2DFused; bn may be nil. The output is float32, pooled, and may be handed
+// back with Release.
+func (ce *ConvolutionEngine) Conv2DInt8(input *tensor.FeatureMap, inputScale float32, kernel *quant.QuantizedKernel,
+    bias []float32, bn *BatchNormParams, applyReLU bool, config Conv2DConfig) *tensor.FeatureMap {
+
+    return ce.Conv2DInt8Batch([]*tensor.FeatureMap{input}, []float32{inputScale}, kernel, bias, bn, applyReLU, config)[0]
+}
+
+// Conv2DInt8Batch is Conv2DInt8 over a batch of inputs of the same shape and layout,
+// inputs[b] being quantized at inputScales[b], with one GEMM for the whole batch.
+// Every output is pooled and may be handed back with Release.
+func (ce *ConvolutionEngine) Conv2DInt8Batch(inputs []*tensor.FeatureMap, inputScales []float32,
+    kernel *quant.QuantizedKernel, bias []float32, bn *BatchNormParams, applyReLU bool,
+    config Conv2DConfig) []*tensor.FeatureMap {
+
+    if kernel == nil {
+        panic("Conv2D validation failed: kernel is nil")
+    }
+    if len(inputScales) != len(inputs) {
+        panic(fmt.Sprintf("Conv2D validation failed: %d input scales for %d inputs", len(inputScales), len(inputs)))
+    }
+    shape := &tensor.Kernel{Size: kernel.Size, Channels: kernel.Channels, Filters: kernel.Filters}
+    validateBatchInputs(inputs, shape, bias, bn, config)
+    for _, inputScale := range inputScales {
+        if !(inputScale > 0) {
+            panic(fmt.Sprintf("Conv2D validation failed: input scale must be positive, got %g", inputScale))
+        }
+    }
+    depth := kernel.FilterSize()
+    if depth > maxInt8GemmDepth {
+        panic(fmt.Sprintf("Conv2D validation failed: %d values per filter overflow int32 accumulation", depth))
+    }
+    if len(inputs) == 0 {
+        return nil
+    }
+
+    buffers := ce.Buffers()
+    first := inputs[0]
+    outHeight, outWidth := GetConvOutputDims(first.Height, first.Width, kernel.Size, config.Padding, config.Stride)
+    pixels := outHeight * outWidth
+    columns := len(inputs) * pixels
+
+    // Image b's patches are rows [b*pixels, (b+1)*pixels) of the shared B matrix
+    patches := buffers.GetInt8Slice(columns * depth)
+    quantized := buffers.GetInt8Slice(len(first.Data))
+    outputs := make([]*tensor.FeatureMap, len(inputs))
+    for b, input := range inputs {
+        quant.QuantizeSlice(quantized, input.Data, inputScales[b])
+        im2colInt8(input, quantized, kernel.Size, config, outHeight, outWidth, patches[b*pixels*depth:(b+1)*pixels*depth])
+
+        outputs[b] = buffers.Get(outHeight, outWidth, kernel.Filters)
+        outputs[b].Layout = input.Layout
+    }
+    buffers.PutInt8Slice(quantized)
+
+    acc := buffers.GetInt32Slice(kernel.Filters * columns)
+    scale := buffers.GetSlice(kernel.Filters)
+    shift := buffers.GetSlice(kernel.Filters)
+    foldBatchNormInto(scale, shift, bias, bn)
+
+    // Filters are split into bands; each worker runs its GEMM rows and their epilogue
+    numWorkers := 1
+    if ce.UseParallel {
+        numWorkers = ce.NumWorkers
+        if numWorkers <= 0 {
+            numWorkers = runtime.NumCPU()
+        }
+    }
+    band := (kernel.Filters + numWorkers - 1) / numWorkers
+
+    var wg sync.WaitGroup
+    for start := 0; start < kernel.Filters; start += band {
+        end := min(start+band, kernel.Filters)
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            gemmInt8Rows(start, end, columns, depth, kernel.Weights, patches, acc)
+            for b, output := range outputs {
+                int8Epilogue(acc, columns, b*pixels, output, kernel, inputScales[b], scale, shift, applyReLU, start, end)
+            }
+        }()
+    }
+    wg.Wait()
+
+    buffers.PutInt8Slice(patches)
+    buffers.PutInt32Slice(acc)
+    buffers.PutSlice(scale)
+    buffers.PutSlice(shift)
+
+    return outputs
+}
+
+// im2colInt8 unrolls the patches of the unpadded quantized input q, stored in
+// input's layout, into patches: one row of C*K*K values per output pixel
+// Positions in the padding read as 0.
+func im2colInt8(input *tensor.FeatureMap, q []int8, size int, config Conv2DConfig,
+    outHeight, outWidth int, patches []int8) {
+
+    height, width, channels := input.Height, input.Width, input.Channels
+    depth := channels * size * size
+    hwc := input.Layout == tensor.LayoutHWC
+
+    for i := 0; i < outHeight; i++ {
+        for j := 0; j < outWidth; j++ {
+            row := patches[(i*outWidth+j)*depth : (i*outWidth+j+1)*depth]
+            idx := 0
+            for c := 0; c < channels; c++ {
+                for m := 0; m < size; m++ {
+                    y := i*config.Stride + m - config.Padding
+                    for n := 0; n < size; n++ {
+                        x := j*config.Stride + n - config.Padding
+                        switch {
+                        case y < 0 || y >= height || x < 0 || x >= width:
+                            row[idx] = 0
+                        case hwc:
+                            row[idx] = q[(y*width+x)*channels+c]
+                        default:
+                            row[idx] = q[(c*height+y)*width+x]
+                        }
+                        idx++
+                    }
+                }
+            }
+        }
+    }
+}
+
+// int8Epilogue rescales the int32 sums of filters [filterStart, filterEnd) to float,
+// applies the folded batch norm and ReLU, and stores them in output. Filter f's sums
+// for output start at acc[f*accRow+accOffset].
+func int8Epilogue(acc []int32, accRow, accOffset int, output *tensor.FeatureMap, kernel *quant.QuantizedKernel,
+    inputScale float32, scale, shift []float32, applyReLU bool, filterStart, filterEnd int) {
+
+    pixels := output.Height * output.Width
+    hwc := output.Layout == tensor.LayoutHWC
+
+    for f := filterStart; f < filterEnd; f++ {
+        // One multiplier folds the activation scale, weight scale and batch norm scale
+        multiplier := scale[f] * inputScale * kernel.Scale(f)
+        sums := acc[f*accRow+accOffset : f*accRow+accOffset+pixels]
+
+        for p, sum := range sums {
+            v := epilogue(float32(sum), multiplier, shift[f], applyReLU)
+            if hwc {
+                output.Data[p*output.Channels+f] = v
+            } else {
+                output.Data[f*pixels+p] = v
+            }
+        }
+    }
+}
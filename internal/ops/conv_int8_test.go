@@ -0,0 +1,139 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/quant"
+	"duchm1606/gocnn/internal/tensor"
+	"math"
+	"testing"
+)
+
+func TestGemmInt8MatchesNaive(t *testing.T) {
+    // Odd sizes exercise the 4-column blocks and their remainder
+    m, n, k := 5, 11, 37
+    a := make([]int8, m*k)
+    b := make([]int8, n*k)
+    for i := range a {
+        a[i] = int8(i*7%255 - 127)
+    }
+    for i := range b {
+        b[i] = int8(i*13%255 - 127)
+    }
+
+    c := make([]int32, m*n)
+    GemmInt8(m, n, k, a, b, c)
+
+    for i := 0; i < m; i++ {
+        for j := 0; j < n; j++ {
+            var expected int32
+            for kk := 0; kk < k; kk++ {
+                expected += int32(a[i*k+kk]) * int32(b[j*k+kk])
+            }
+            if c[i*n+j] != expected {
+                t.Fatalf("c[%d][%d] = %d, expected %d", i, j, c[i*n+j], expected)
+            }
+        }
+    }
+}
+
+func TestGemmInt8Extremes(t *testing.T) {
+    // The largest products of TinyCNN's deepest layer must not overflow
+    k := 128 * 9
+    a := make([]int8, k)
+    b := make([]int8, k)
+    for i := range a {
+        a[i], b[i] = -quant.QMax, -quant.QMax
+    }
+    c := make([]int32, 1)
+    GemmInt8(1, 1, k, a, b, c)
+    if expected := int32(k * quant.QMax * quant.QMax); c[0] != expected {
+        t.Errorf("Got %d, expected %d", c[0], expected)
+    }
+}
+
+func TestConv2DInt8MatchesDequantized(t *testing.T) {
+    input := tensor.NewFeatureMap(9, 7, 5)
+    input.RandomFill()
+
+    kernel := tensor.NewKernel(3, 5, 6)
+    kernel.RandomFill()
+    qk, err := quant.QuantizeKernel(kernel, quant.PerChannel)
+    if err != nil {
+        t.Fatalf("QuantizeKernel failed: %v", err)
+    }
+
+    bias := []float32{0.1, -0.2, 0.3, -0.4, 0.5, -0.6}
+    bn := NewBatchNormParams(6)
+    for f := 0; f < 6; f++ {
+        bn.Mean[f] = float32(f) * 0.1
+        bn.Variance[f] = 1 + float32(f)*0.5
+        bn.Scale[f] = 1 - float32(f)*0.3
+    }
+
+    // The int8 path must equal float arithmetic on the dequantized operands
+    var bound float32
+    for _, v := range input.Data {
+        bound = max(bound, float32(math.Abs(float64(v))))
+    }
+    inputScale := quant.ScaleFor(bound)
+    dequantized := input.Clone()
+    for i, v := range input.Data {
+        dequantized.Data[i] = float32(quant.Quantize(v, inputScale)) * inputScale
+    }
+    floatKernel := qk.ToKernel()
+
+    engine := NewConvolutionEngine()
+    for _, layout := range []tensor.Layout{tensor.LayoutCHW, tensor.LayoutHWC} {
+        for _, config := range []Conv2DConfig{{Padding: 1, Stride: 1}, {Padding: 0, Stride: 2}} {
+            expected := Conv2DBatchNormReLU(dequantized, floatKernel, bias, bn, true, config)
+            got := engine.Conv2DInt8(input.ToLayout(layout), inputScale, qk, bias, bn, true, config)
+
+            if got.Layout != layout {
+                t.Fatalf("%s: expected %s output, got %s", layout, layout, got.Layout)
+            }
+            for c := 0; c < expected.Channels; c++ {
+                for h := 0; h < expected.Height; h++ {
+                    for w := 0; w < expected.Width; w++ {
+                        if math.Abs(float64(expected.Get(c, h, w)-got.Get(c, h, w))) > 1e-4 {
+                            t.Fatalf("%s %+v: mismatch at (%d,%d,%d): expected %f, got %f",
+                                layout, config, c, h, w, expected.Get(c, h, w), got.Get(c, h, w))
+                        }
+                    }
+                }
+            }
+            engine.Release(got)
+        }
+    }
+}
+
+func TestConv2DInt8BatchMatchesSingle(t *testing.T) {
+    kernel := tensor.NewKernel(3, 5, 6)
+    kernel.RandomFill()
+    qk, err := quant.QuantizeKernel(kernel, quant.PerChannel)
+    if err != nil {
+        t.Fatalf("QuantizeKernel failed: %v", err)
+    }
+    bias := []float32{0.1, -0.2, 0.3, -0.4, 0.5, -0.6}
+    config := Conv2DConfig{Padding: 1, Stride: 1}
+
+    // Every image keeps its own input scale
+    inputs := make([]*tensor.FeatureMap, 3)
+    scales := make([]float32, len(inputs))
+    for b := range inputs {
+        inputs[b] = tensor.NewFeatureMap(9, 7, 5)
+        inputs[b].RandomFill()
+        scales[b] = quant.ScaleFor(float32(b + 1))
+    }
+
+    engine := NewConvolutionEngine()
+    outputs := engine.Conv2DInt8Batch(inputs, scales, qk, bias, nil, true, config)
+    for b, input := range inputs {
+        expected := engine.Conv2DInt8(input, scales[b], qk, bias, nil, true, config)
+        for i := range expected.Data {
+            if expected.Data[i] != outputs[b].Data[i] {
+                t.Fatalf("Image %d: mismatch at index %d: expected %f, got %f", b, i, expected.Data[i], outputs[b].Data[i])
+            }
+        }
+        engine.Release(expected)
+        engine.Release(outputs[b])
+    }
+}
@@ -0,0 +1,210 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+)
+
+/**
+* 1D and 3D convolution
+
+The same sliding-window sum as Conv2D, with one fewer or one more spatial axis:
+```
+1D: Output[f][i]       = Σ(Input[c][i*s+k] * Kernel[f][c][k]) + Bias[f]
+3D: Output[f][t][i][j] = Σ(Input[c][t*s+a][i*s+m][j*s+n] * Kernel[f][c][a][m][n]) + Bias[f]
+```
+
+1D convolutions suit audio and spectrogram frames (channels = frequency bins),
+3D convolutions suit short video clips (depth = time). Conv1DOf and Conv3DOf
+hold the loops over any element type; Conv1D and Conv3D are their float32
+instances with validation.
+*/
+
+// Conv1D performs 1D convolution operation
+func Conv1D(input *tensor.FeatureMap1D, kernel *tensor.Kernel1D, bias []float32, config Conv2DConfig) *tensor.FeatureMap1D {
+    if err := validateConv1DInputs(input, kernel, bias, config); err != nil {
+        panic(fmt.Sprintf("Conv1D validation failed: %v", err))
+    }
+    
+    data, outLength := Conv1DOf(input.Data, input.Length, kernel, bias, config)
+    return &tensor.FeatureMap1D{Length: outLength, Channels: kernel.Filters, Data: data}
+}
+
+// Conv1DOf is Conv1D over the [channel][length] values of T, summing in T
+// Weights and biases are widened as they are read; it returns the output values and length.
+func Conv1DOf[T tensor.Float](input []T, length int, kernel *tensor.Kernel1D, bias []float32,
+    config Conv2DConfig) ([]T, int) {
+    
+    outLength := GetConv1DOutputLength(length, kernel.Size, config.Padding, config.Stride)
+    output := make([]T, outLength*kernel.Filters)
+    
+    for f := 0; f < kernel.Filters; f++ {
+        for i := 0; i < outLength; i++ {
+            var sum T
+            
+            for c := 0; c < kernel.Channels; c++ {
+                for k := 0; k < kernel.Size; k++ {
+                    l := i*config.Stride + k - config.Padding
+                    if l < 0 || l >= length {
+                        continue
+                    }
+                    sum += input[c*length+l] * T(kernel.GetWeightUnsafe(f, c, k))
+                }
+            }
+            
+            output[f*outLength+i] = sum + T(bias[f])
+        }
+    }
+    
+    return output, outLength
+}
+
+// Conv3D performs 3D convolution operation
+func Conv3D(input *tensor.FeatureMap3D, kernel *tensor.Kernel3D, bias []float32, config Conv2DConfig) *tensor.FeatureMap3D {
+    if err := validateConv3DInputs(input, kernel, bias, config); err != nil {
+        panic(fmt.Sprintf("Conv3D validation failed: %v", err))
+    }
+    
+    data, outDepth, outHeight, outWidth := Conv3DOf(input.Data, input.Depth, input.Height, input.Width, 
+        kernel, bias, config)
+    return &tensor.FeatureMap3D{
+        Depth:    outDepth,
+        Height:   outHeight,
+        Width:    outWidth,
+        Channels: kernel.Filters,
+        Data:     data,
+    }
+}
+
+// Conv3DOf is Conv3D over the [channel][depth][height][width] values of T, summing in T
+// Weights and biases are widened as they are read; it returns the output values and shape.
+func Conv3DOf[T tensor.Float](input []T, depth, height, width int, kernel *tensor.Kernel3D, bias []float32,
+    config Conv2DConfig) ([]T, int, int, int) {
+    
+    outDepth := GetConv1DOutputLength(depth, kernel.Size, config.Padding, config.Stride)
+    outHeight := GetConv1DOutputLength(height, kernel.Size, config.Padding, config.Stride)
+    outWidth := GetConv1DOutputLength(width, kernel.Size, config.Padding, config.Stride)
+    output := make([]T, outDepth*outHeight*outWidth*kernel.Filters)
+    
+    // in reports whether a padded coordinate lies inside an axis of size n
+    in := func(x, n int) bool { return x >= 0 && x < n }
+    
+    idx := 0
+    for f := 0; f < kernel.Filters; f++ {
+        for t := 0; t < outDepth; t++ {
+            for i := 0; i < outHeight; i++ {
+                for j := 0; j < outWidth; j++ {
+                    var sum T
+                    
+                    for c := 0; c < kernel.Channels; c++ {
+                        for a := 0; a < kernel.Size; a++ {
+                            d := t*config.Stride + a - config.Padding
+                            if !in(d, depth) {
+                                continue
+                            }
+                            for m := 0; m < kernel.Size; m++ {
+                                h := i*config.Stride + m - config.Padding
+                                if !in(h, height) {
+                                    continue
+                                }
+                                for n := 0; n < kernel.Size; n++ {
+                                    w := j*config.Stride + n - config.Padding
+                                    if !in(w, width) {
+                                        continue
+                                    }
+                                    inputVal := input[((c*depth+d)*height+h)*width+w]
+                                    sum += inputVal * T(kernel.GetWeightUnsafe(f, c, a, m, n))
+                                }
+                            }
+                        }
+                    }
+                    
+                    output[idx] = sum + T(bias[f])
+                    idx++
+                }
+            }
+        }
+    }
+    
+    return output, outDepth, outHeight, outWidth
+}
+
+// validateConv1DInputs validates the inputs for 1D convolution
+func validateConv1DInputs(input *tensor.FeatureMap1D, kernel *tensor.Kernel1D, bias []float32, config Conv2DConfig) error {
+    if input == nil {
+        return fmt.Errorf("input feature map is nil")
+    }
+    
+    if kernel == nil {
+        return fmt.Errorf("kernel is nil")
+    }
+    
+    if len(bias) != kernel.Filters {
+        return fmt.Errorf("bias length (%d) doesn't match kernel filters (%d)", len(bias), kernel.Filters)
+    }
+    
+    if input.Channels != kernel.Channels {
+        return fmt.Errorf("input channels (%d) don't match kernel channels (%d)", 
+            input.Channels, kernel.Channels)
+    }
+    
+    if config.Stride <= 0 {
+        return fmt.Errorf("stride must be positive, got %d", config.Stride)
+    }
+    
+    if config.Padding < 0 {
+        return fmt.Errorf("padding must be non-negative, got %d", config.Padding)
+    }
+    
+    if input.Length+2*config.Padding < kernel.Size {
+        return fmt.Errorf("input too small for kernel size after padding")
+    }
+    
+    return nil
+}
+
+// validateConv3DInputs validates the inputs for 3D convolution
+func validateConv3DInputs(input *tensor.FeatureMap3D, kernel *tensor.Kernel3D, bias []float32, config Conv2DConfig) error {
+    if input == nil {
+        return fmt.Errorf("input feature map is nil")
+    }
+    
+    if kernel == nil {
+        return fmt.Errorf("kernel is nil")
+    }
+    
+    if len(bias) != kernel.Filters {
+        return fmt.Errorf("bias length (%d) doesn't match kernel filters (%d)", len(bias), kernel.Filters)
+    }
+    
+    if input.Channels != kernel.Channels {
+        return fmt.Errorf("input channels (%d) don't match kernel channels (%d)", 
+            input.Channels, kernel.Channels)
+    }
+    
+    if config.Stride <= 0 {
+        return fmt.Errorf("stride must be positive, got %d", config.Stride)
+    }
+    
+    if config.Padding < 0 {
+        return fmt.Errorf("padding must be non-negative, got %d", config.Padding)
+    }
+    
+    minSide := input.Depth
+    if input.Height < minSide {
+        minSide = input.Height
+    }
+    if input.Width < minSide {
+        minSide = input.Width
+    }
+    if minSide+2*config.Padding < kernel.Size {
+        return fmt.Errorf("input too small for kernel size after padding")
+    }
+    
+    return nil
+}
+
+// GetConv1DOutputLength calculates the output length for a 1D convolution
+func GetConv1DOutputLength(inputLength, kernelSize, padding, stride int) int {
+    return (inputLength+2*padding-kernelSize)/stride + 1
+}
@@ -0,0 +1,98 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"math"
+	"testing"
+)
+
+func TestConv1DBasic(t *testing.T) {
+    // Input: single channel [1, 2, 3, 4, 5]
+    input := tensor.NewFeatureMap1D(5, 1)
+    for i := 0; i < 5; i++ {
+        input.Set(0, i, float32(i+1))
+    }
+    
+    // Kernel [1, 0, -1] detects a decreasing slope
+    kernel := tensor.NewKernel1D(3, 1, 1)
+    kernel.SetWeightUnsafe(0, 0, 0, 1)
+    kernel.SetWeightUnsafe(0, 0, 2, -1)
+    
+    bias := []float32{0.5}
+    output := Conv1D(input, kernel, bias, Conv2DConfig{Padding: 0, Stride: 1})
+    
+    if output.Length != 3 || output.Channels != 1 {
+        t.Fatalf("Expected output shape (3,1), got (%d,%d)", output.Length, output.Channels)
+    }
+    
+    for i := 0; i < output.Length; i++ {
+        expected := float32(-2 + 0.5)
+        if math.Abs(float64(output.Get(0, i)-expected)) > 1e-6 {
+            t.Errorf("At %d: expected %f, got %f", i, expected, output.Get(0, i))
+        }
+    }
+}
+
+func TestConv1DPaddingAndStride(t *testing.T) {
+    input := tensor.NewFeatureMap1D(8, 2)
+    input.RandomFill()
+    
+    kernel := tensor.NewKernel1D(3, 2, 4)
+    kernel.RandomFill()
+    
+    output := Conv1D(input, kernel, make([]float32, 4), Conv2DConfig{Padding: 1, Stride: 2})
+    
+    expectedLength := GetConv1DOutputLength(8, 3, 1, 2)
+    if output.Length != expectedLength || output.Channels != 4 {
+        t.Errorf("Expected output shape (%d,4), got (%d,%d)", expectedLength, output.Length, output.Channels)
+    }
+}
+
+func TestConv3DMatchesConv2DForSingleFrame(t *testing.T) {
+    // A depth-1 clip convolved with a kernel of size 1 along depth reduces to Conv2D,
+    // so use a 1x1x1 kernel and compare frame by frame
+    input := tensor.NewFeatureMap3D(1, 6, 6, 3)
+    input.RandomFill()
+    
+    kernel3D := tensor.NewKernel3D(1, 3, 4)
+    kernel3D.RandomFill()
+    
+    kernel2D := tensor.NewKernel(1, 3, 4)
+    for f := 0; f < 4; f++ {
+        for c := 0; c < 3; c++ {
+            kernel2D.SetWeight(f, c, 0, 0, kernel3D.GetWeightUnsafe(f, c, 0, 0, 0))
+        }
+    }
+    
+    bias := []float32{0.1, 0.2, 0.3, 0.4}
+    config := Conv2DConfig{Padding: 0, Stride: 1}
+    
+    output3D := Conv3D(input, kernel3D, bias, config)
+    output2D := Conv2D(input.Frame(0), kernel2D, bias, config)
+    
+    frame := output3D.Frame(0)
+    for i := range frame.Data {
+        if math.Abs(float64(frame.Data[i]-output2D.Data[i])) > 1e-5 {
+            t.Fatalf("Mismatch at %d: %f vs %f", i, frame.Data[i], output2D.Data[i])
+        }
+    }
+}
+
+func TestConv3DAllOnes(t *testing.T) {
+    input := tensor.NewFeatureMap3D(3, 3, 3, 2)
+    for i := range input.Data {
+        input.Data[i] = 1
+    }
+    
+    kernel := tensor.NewKernel3D(3, 2, 1)
+    for i := range kernel.Weights {
+        kernel.Weights[i] = 1
+    }
+    
+    output := Conv3D(input, kernel, []float32{0}, Conv2DConfig{Padding: 0, Stride: 1})
+    
+    // 3*3*3 positions * 2 channels
+    if got := output.Get(0, 0, 0, 0); got != 54 {
+        t.Errorf("Expected 54, got %f", got)
+    }
+}
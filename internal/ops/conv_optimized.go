@@ -2,7 +2,9 @@ package ops
 
 import (
 	"duchm1606/gocnn/internal/tensor"
+	"fmt"
 	"runtime"
+	"sync"
 )
 
 // ConvolutionEngine manages different convolution implementations
@@ -10,6 +12,13 @@ type ConvolutionEngine struct {
     UseParallel   bool // Whether to use parallel processing
     NumWorkers    int  // Number of worker goroutines (0 = auto)
     BlockSize     int  // Block size for tiled convolution (0 = auto)
+    Backend       ConvAlgorithm // Algorithm used for every layer (AlgoAuto = per-layer choice)
+    
+    TuningCachePath string // JSON file of per-shape algorithm choices used by Autotune (empty = none)
+    
+    buffers       *FeatureMapPool // Recycled padded inputs and outputs, keyed by shape
+    tuning        map[convKey]ConvAlgorithm // Per-shape algorithm overrides from Autotune
+    tuningMu      sync.RWMutex
 }
 
 // NewConvolutionEngine creates a new convolution engine with optimal settings
@@ -18,43 +27,161 @@ func NewConvolutionEngine() *ConvolutionEngine {
         UseParallel: true,
         NumWorkers:  0, // Auto-detect
         BlockSize:   0, // Auto-detect
+        buffers:     NewFeatureMapPool(),
     }
 }
 
+// Buffers returns the engine's buffer pool, creating it on first use
+// so that a zero-value ConvolutionEngine still works
+func (ce *ConvolutionEngine) Buffers() *FeatureMapPool {
+    if ce.buffers == nil {
+        ce.buffers = NewFeatureMapPool()
+    }
+    return ce.buffers
+}
+
+// Release hands a feature map returned by Conv2DOptimized or Conv2DFused back to
+// the engine for reuse. The caller must not touch fm afterwards.
+func (ce *ConvolutionEngine) Release(fm *tensor.FeatureMap) {
+    ce.Buffers().Put(fm)
+}
+
+// padPooled returns input padded by config.Padding using a pooled buffer, or input
+// itself when no padding is needed. The bool reports whether the result must be released.
+func (ce *ConvolutionEngine) padPooled(input *tensor.FeatureMap, padding int) (*tensor.FeatureMap, bool) {
+    if padding <= 0 {
+        return input, false
+    }
+    
+    padded := ce.Buffers().Get(input.Height+2*padding, input.Width+2*padding, input.Channels)
+    tensor.PadFeatureMapInto(padded, input, padding)
+    return padded, true
+}
+
+// outputPooled returns a pooled output buffer sized for convolving paddedInput with kernel
+func (ce *ConvolutionEngine) outputPooled(paddedInput *tensor.FeatureMap, kernel *tensor.Kernel, 
+    config Conv2DConfig) *tensor.FeatureMap {
+    
+    outHeight := (paddedInput.Height-kernel.Size)/config.Stride + 1
+    outWidth := (paddedInput.Width-kernel.Size)/config.Stride + 1
+    output := ce.Buffers().Get(outHeight, outWidth, kernel.Filters)
+    output.Layout = paddedInput.Layout
+    return output
+}
+
 // Conv2DOptimized performs optimized convolution with multiple strategies
+// The padded input and the output come from the engine's buffer pool; pass the
+// output to Release once it is no longer needed to recycle it
 func (ce *ConvolutionEngine) Conv2DOptimized(input *tensor.FeatureMap, kernel *tensor.Kernel, 
 	bias []float32, config Conv2DConfig) *tensor.FeatureMap {
 
-	// Choose algorithm based on problem size
-	totalOps := int64(kernel.Filters) * int64(kernel.Channels) * int64(kernel.Size) * int64(kernel.Size)
-
-	if totalOps < 10000 {
-		// Small convolutions: use simple implementation
-		return Conv2D(input, kernel, bias, config)
-	} else if ce.UseParallel && runtime.NumCPU() > 1 {
-		// Large convolutions: use parallel implementation
-		return Conv2DParallel(input, kernel, bias, config)
-	} else {
-		// Medium convolutions: use tiled implementation
-		return ce.conv2DTiled(input, kernel, bias, config)
+	if err := validateConv2DInputs(input, kernel, bias, config); err != nil {
+		panic(fmt.Sprintf("Conv2D validation failed: %v", err))
 	}
+
+	algo := ce.algorithmFor(input, kernel, config)
+	if algo == AlgoAuto {
+		// Choose algorithm based on problem size
+		totalOps := int64(kernel.Filters) * int64(kernel.Channels) * int64(kernel.Size) * int64(kernel.Size)
+
+		if totalOps < 10000 {
+			// Small convolutions: use simple implementation
+			algo = AlgoDirect
+		} else if ce.UseParallel && runtime.NumCPU() > 1 {
+			// Large convolutions: use parallel implementation
+			algo = AlgoParallel
+		} else {
+			// Medium convolutions: use tiled implementation
+			algo = AlgoTiled
+		}
+	}
+
+	return ce.conv(algo, input, kernel, bias, nil, false, config)
 }
 
-// conv2DTiled performs tiled convolution for better cache performance
-func (ce *ConvolutionEngine) conv2DTiled(input *tensor.FeatureMap, kernel *tensor.Kernel, 
-	bias []float32, config Conv2DConfig) *tensor.FeatureMap {
+// Conv2DFused performs convolution with batch norm and ReLU folded into a single pass
+// bn may be nil to skip normalization; the serial/parallel choice mirrors Conv2DOptimized
+// unless a Backend is set or Autotune picked an algorithm for this shape.
+// Like Conv2DOptimized, the output is pooled and may be handed back with Release
+func (ce *ConvolutionEngine) Conv2DFused(input *tensor.FeatureMap, kernel *tensor.Kernel, 
+	bias []float32, bn *BatchNormParams, applyReLU bool, config Conv2DConfig) *tensor.FeatureMap {
+
+	validateFusedConvInputs(input, kernel, bias, bn, config)
+
+	algo := ce.algorithmFor(input, kernel, config)
+	if algo == AlgoAuto {
+		totalOps := int64(kernel.Filters) * int64(kernel.Channels) * int64(kernel.Size) * int64(kernel.Size)
 
-	// Apply padding if needed
-	paddedInput := input
-	if config.Padding > 0 {
-		paddedInput = tensor.PadFeatureMap(input, config.Padding)
+		algo = AlgoDirect
+		if totalOps >= 10000 && ce.UseParallel && runtime.NumCPU() > 1 {
+			algo = AlgoParallel
+		}
 	}
 
-	// Calculate output dimensions
-	outHeight := (paddedInput.Height-kernel.Size)/config.Stride + 1
-	outWidth := (paddedInput.Width-kernel.Size)/config.Stride + 1
+	return ce.conv(algo, input, kernel, bias, bn, applyReLU, config)
+}
 
-	output := tensor.NewFeatureMap(outHeight, outWidth, kernel.Filters)
+// conv runs one convolution with the given algorithm on pooled buffers
+// Inputs must already be validated
+func (ce *ConvolutionEngine) conv(algo ConvAlgorithm, input *tensor.FeatureMap, kernel *tensor.Kernel, 
+	bias []float32, bn *BatchNormParams, applyReLU bool, config Conv2DConfig) *tensor.FeatureMap {
+
+	buffers := ce.Buffers()
+	paddedInput, pooledPad := ce.padPooled(input, config.Padding)
+	output := ce.outputPooled(paddedInput, kernel, config)
+
+	scale := buffers.GetSlice(kernel.Filters)
+	shift := buffers.GetSlice(kernel.Filters)
+	foldBatchNormInto(scale, shift, bias, bn)
+
+	switch algo {
+	case AlgoParallel:
+		fusedConvParallel(paddedInput, kernel, output, scale, shift, applyReLU, config, ce.NumWorkers)
+
+	case AlgoTiled:
+		// The tiled loops add a plain bias; the epilogue is applied afterwards
+		zeroBias := buffers.GetSlice(kernel.Filters)
+		clear(zeroBias)
+		ce.conv2DTiled(paddedInput, kernel, zeroBias, config, output)
+		applyFusedEpilogue(output, scale, shift, applyReLU)
+		buffers.PutSlice(zeroBias)
+
+	case AlgoGEMM:
+		cols := buffers.GetSlice(gemmColsSize(kernel, output))
+		convGEMM(paddedInput, kernel, output, scale, shift, applyReLU, config, cols)
+		buffers.PutSlice(cols)
+
+	default:
+		fusedConvSerial(paddedInput, kernel, output, scale, shift, applyReLU, config)
+	}
+
+	buffers.PutSlice(scale)
+	buffers.PutSlice(shift)
+	if pooledPad {
+		ce.Release(paddedInput)
+	}
+
+	return output
+}
+
+// applyFusedEpilogue applies the folded batch norm and optional ReLU to a raw convolution output
+func applyFusedEpilogue(output *tensor.FeatureMap, scale, shift []float32, applyReLU bool) {
+	for c := 0; c < output.Channels; c++ {
+		for h := 0; h < output.Height; h++ {
+			for w := 0; w < output.Width; w++ {
+				output.SetUnsafe(c, h, w, epilogue(output.GetUnsafe(c, h, w), scale[c], shift[c], applyReLU))
+			}
+		}
+	}
+}
+
+// conv2DTiled performs tiled convolution for better cache performance
+// The input must already be padded and output preallocated
+func (ce *ConvolutionEngine) conv2DTiled(paddedInput *tensor.FeatureMap, kernel *tensor.Kernel, 
+	bias []float32, config Conv2DConfig, output *tensor.FeatureMap) {
+
+	outHeight := output.Height
+	outWidth := output.Width
 
 	// Determine tile size for cache efficiency
 	tileSize := ce.BlockSize
@@ -89,8 +216,6 @@ func (ce *ConvolutionEngine) conv2DTiled(input *tensor.FeatureMap, kernel *tenso
 		}
 	}
 }
-
-return output
 }
 
 // processTile processes a single tile of the output
@@ -171,11 +296,7 @@ func EstimateConvolutionTime(inputHeight, inputWidth, inputChannels int,
 							padding, stride int) float64 {
 
 	// Calculate number of operations
-	outHeight := (inputHeight+2*padding-kernelSize)/stride + 1
-	outWidth := (inputWidth+2*padding-kernelSize)/stride + 1
-
-	totalOps := int64(outHeight) * int64(outWidth) * int64(kernelFilters) * 
-				int64(inputChannels) * int64(kernelSize) * int64(kernelSize)
+	totalOps := ConvolutionMACs(inputHeight, inputWidth, inputChannels, kernelSize, kernelFilters, padding, stride)
 
 	// Rough estimate: 1 GFLOP = 1 billion operations per second on modern CPU
 	estimatedGFLOPS := 1.0
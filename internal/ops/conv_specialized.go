@@ -20,7 +20,7 @@ func DepthwiseConv2D(input *tensor.FeatureMap, kernel *tensor.Kernel, bias []flo
     outHeight := (paddedInput.Height-kernel.Size)/config.Stride + 1
     outWidth := (paddedInput.Width-kernel.Size)/config.Stride + 1
     
-    output := tensor.NewFeatureMap(outHeight, outWidth, kernel.Filters)
+    output := tensor.NewFeatureMapWithLayout(outHeight, outWidth, kernel.Filters, input.Layout)
     
     // For each channel, convolve with corresponding filter
     for c := 0; c < input.Channels; c++ {
@@ -86,7 +86,7 @@ func GroupConv2D(input *tensor.FeatureMap, kernel *tensor.Kernel, bias []float32
     outHeight := (paddedInput.Height-kernel.Size)/config.Stride + 1
     outWidth := (paddedInput.Width-kernel.Size)/config.Stride + 1
     
-    output := tensor.NewFeatureMap(outHeight, outWidth, kernel.Filters)
+    output := tensor.NewFeatureMapWithLayout(outHeight, outWidth, kernel.Filters, input.Layout)
     
     // Process each group separately
     for g := 0; g < groups; g++ {
@@ -169,26 +169,3 @@ func Im2Col(input *tensor.FeatureMap, kernelSize, padding, stride int) [][]float
     
     return result
 }
-
-// Conv2DBackward computes gradients for convolution (used in training)
-// Not needed for inference, but useful for understanding and testing
-func Conv2DBackward(outputGrad *tensor.FeatureMap, input *tensor.FeatureMap, 
-                   kernel *tensor.Kernel, config Conv2DConfig) (*tensor.FeatureMap, *tensor.Kernel, []float32) {
-    
-    // This is a simplified version - full backpropagation is more complex
-    // For inference-only CNN, this is not needed but good for completeness
-    
-    // Compute input gradients (for chaining backward pass)
-    inputGrad := tensor.NewFeatureMap(input.Height, input.Width, input.Channels)
-    
-    // Compute kernel gradients
-    kernelGrad := tensor.NewKernel(kernel.Size, kernel.Channels, kernel.Filters)
-    
-    // Compute bias gradients
-    biasGrad := make([]float32, kernel.Filters)
-    
-    // Implementation would go here...
-    // (Omitted for brevity - full implementation is quite complex)
-    
-    return inputGrad, kernelGrad, biasGrad
-}
\ No newline at end of file
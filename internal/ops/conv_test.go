@@ -184,6 +184,341 @@ func TestConv2DParallelVsSerial(t *testing.T) {
     }
 }
 
+func TestConv2DParallelFewFilters(t *testing.T) {
+    // Fewer filters than CPUs: work must be split across output rows too
+    input := tensor.NewFeatureMap(4, 4, 128)
+    input.RandomFill()
+    
+    kernel := tensor.NewKernel(1, 128, 10)
+    kernel.RandomFill()
+    
+    bias := make([]float32, 10)
+    for i := range bias {
+        bias[i] = float32(i) * 0.1
+    }
+    config := Conv2DConfig{Padding: 0, Stride: 1}
+    
+    outputSerial := Conv2D(input, kernel, bias, config)
+    outputParallel := Conv2DParallel(input, kernel, bias, config)
+    
+    for i := range outputSerial.Data {
+        if math.Abs(float64(outputSerial.Data[i]-outputParallel.Data[i])) > 1e-5 {
+            t.Fatalf("Parallel/serial mismatch at index %d: %f vs %f", 
+                i, outputSerial.Data[i], outputParallel.Data[i])
+        }
+    }
+}
+
+func TestPartitionConvTiles(t *testing.T) {
+    testCases := []struct {
+        filters, outHeight, workers int
+    }{
+        {10, 4, 16},
+        {128, 32, 4},
+        {1, 7, 8},
+        {3, 1, 8},
+    }
+    
+    for _, tc := range testCases {
+        tiles := partitionConvTiles(tc.filters, tc.outHeight, tc.workers)
+        
+        // Every (filter, row) pair must be covered exactly once
+        covered := make([]int, tc.filters*tc.outHeight)
+        for _, tile := range tiles {
+            for r := tile.rowStart; r < tile.rowEnd; r++ {
+                covered[tile.filter*tc.outHeight+r]++
+            }
+        }
+        for i, count := range covered {
+            if count != 1 {
+                t.Errorf("filters=%d height=%d: cell %d covered %d times", 
+                    tc.filters, tc.outHeight, i, count)
+            }
+        }
+        
+        // Narrow layers should still produce enough tiles to feed every worker
+        maxTiles := tc.filters * tc.outHeight
+        if len(tiles) < tc.workers && len(tiles) < maxTiles {
+            t.Errorf("filters=%d height=%d: only %d tiles for %d workers", 
+                tc.filters, tc.outHeight, len(tiles), tc.workers)
+        }
+    }
+}
+
+func TestConv2DBatchNormReLUMatchesUnfused(t *testing.T) {
+    input := tensor.NewFeatureMap(8, 8, 4)
+    input.RandomFill()
+    
+    kernel := tensor.NewKernel(3, 4, 6)
+    kernel.RandomFill()
+    
+    bias := []float32{0.1, -0.2, 0.3, -0.4, 0.5, -0.6}
+    bn := NewBatchNormParams(6)
+    for f := 0; f < 6; f++ {
+        bn.Mean[f] = float32(f) * 0.1
+        bn.Variance[f] = 1 + float32(f)*0.5
+        bn.Scale[f] = 1 - float32(f)*0.3
+        bn.Shift[f] = float32(f) * 0.05
+    }
+    config := Conv2DConfig{Padding: 1, Stride: 1}
+    
+    // Reference: separate convolution and batch norm (which includes ReLU)
+    expected := Conv2D(input, kernel, bias, config)
+    BatchNormalizeInPlace(expected, bn)
+    
+    for _, fused := range []*tensor.FeatureMap{
+        Conv2DBatchNormReLU(input, kernel, bias, bn, true, config),
+        Conv2DBatchNormReLUParallel(input, kernel, bias, bn, true, config),
+    } {
+        for i := range expected.Data {
+            if math.Abs(float64(expected.Data[i]-fused.Data[i])) > 1e-4 {
+                t.Fatalf("Fused mismatch at index %d: expected %f, got %f", i, expected.Data[i], fused.Data[i])
+            }
+        }
+    }
+}
+
+func TestConv2DBatchNormReLUWithoutBatchNorm(t *testing.T) {
+    input := tensor.NewFeatureMap(5, 5, 2)
+    input.RandomFill()
+    
+    kernel := tensor.NewKernel(3, 2, 3)
+    kernel.RandomFill()
+    
+    bias := []float32{-0.5, 0, 0.5}
+    config := Conv2DConfig{Padding: 0, Stride: 1}
+    
+    // Without batch norm or ReLU the fused op is a plain convolution
+    expected := Conv2D(input, kernel, bias, config)
+    fused := Conv2DBatchNormReLU(input, kernel, bias, nil, false, config)
+    for i := range expected.Data {
+        if math.Abs(float64(expected.Data[i]-fused.Data[i])) > 1e-6 {
+            t.Fatalf("Mismatch at index %d: expected %f, got %f", i, expected.Data[i], fused.Data[i])
+        }
+    }
+    
+    // With ReLU only, negatives are clamped
+    ReLUInPlace(expected.Data)
+    fused = Conv2DBatchNormReLU(input, kernel, bias, nil, true, config)
+    for i := range expected.Data {
+        if math.Abs(float64(expected.Data[i]-fused.Data[i])) > 1e-6 {
+            t.Fatalf("ReLU mismatch at index %d: expected %f, got %f", i, expected.Data[i], fused.Data[i])
+        }
+    }
+}
+
+func TestConvolutionEngineReusesBuffers(t *testing.T) {
+    engine := NewConvolutionEngine()
+    
+    input := tensor.NewFeatureMap(8, 8, 4)
+    input.RandomFill()
+    
+    kernel := tensor.NewKernel(3, 4, 6)
+    kernel.RandomFill()
+    
+    bias := []float32{0.1, -0.2, 0.3, -0.4, 0.5, -0.6}
+    config := Conv2DConfig{Padding: 1, Stride: 1}
+    expected := Conv2D(input, kernel, bias, config)
+    
+    first := engine.Conv2DFused(input, kernel, bias, nil, false, config)
+    first.Fill(42) // Dirty the buffer before handing it back
+    engine.Release(first)
+    
+    // The next call of the same shape must get the recycled buffer and fully overwrite it
+    second := engine.Conv2DFused(input, kernel, bias, nil, false, config)
+    if second != first {
+        t.Error("Expected output buffer to be reused")
+    }
+    for i := range expected.Data {
+        if math.Abs(float64(expected.Data[i]-second.Data[i])) > 1e-6 {
+            t.Fatalf("Mismatch at index %d: expected %f, got %f", i, expected.Data[i], second.Data[i])
+        }
+    }
+    engine.Release(second)
+    
+    optimized := engine.Conv2DOptimized(input, kernel, bias, config)
+    for i := range expected.Data {
+        if math.Abs(float64(expected.Data[i]-optimized.Data[i])) > 1e-6 {
+            t.Fatalf("Conv2DOptimized mismatch at index %d: expected %f, got %f", i, expected.Data[i], optimized.Data[i])
+        }
+    }
+}
+
+func TestFeatureMapPoolBounded(t *testing.T) {
+    pool := NewFeatureMapPool()
+    
+    for i := 0; i < maxPooledPerShape+3; i++ {
+        pool.Put(tensor.NewFeatureMap(2, 2, 1))
+    }
+    if pool.Len() != maxPooledPerShape {
+        t.Errorf("Expected pool to hold %d buffers, got %d", maxPooledPerShape, pool.Len())
+    }
+    
+    fm := pool.Get(3, 3, 1)
+    if fm.Height != 3 || fm.Width != 3 || len(fm.Data) != 9 {
+        t.Errorf("Get returned wrong shape (%d,%d,%d)", fm.Height, fm.Width, fm.Channels)
+    }
+}
+
+func TestConv2DHWCMatchesCHW(t *testing.T) {
+    input := tensor.NewFeatureMap(9, 7, 5)
+    input.RandomFill()
+    hwcInput := input.ToLayout(tensor.LayoutHWC)
+    
+    kernel := tensor.NewKernel(3, 5, 6)
+    kernel.RandomFill()
+    
+    bias := []float32{0.1, -0.2, 0.3, -0.4, 0.5, -0.6}
+    bn := NewBatchNormParams(6)
+    for f := 0; f < 6; f++ {
+        bn.Variance[f] = 1
+        bn.Scale[f] = 1 + float32(f)*0.1
+    }
+    engine := NewConvolutionEngine()
+    
+    for _, config := range []Conv2DConfig{{Padding: 1, Stride: 1}, {Padding: 0, Stride: 2}} {
+        expected := Conv2D(input, kernel, bias, config)
+        expectedFused := Conv2DBatchNormReLU(input, kernel, bias, bn, true, config)
+        
+        cases := map[string]struct {
+            got  *tensor.FeatureMap
+            want *tensor.FeatureMap
+        }{
+            "Conv2D":                      {Conv2D(hwcInput, kernel, bias, config), expected},
+            "Conv2DParallel":              {Conv2DParallel(hwcInput, kernel, bias, config), expected},
+            "Conv2DOptimized":             {engine.Conv2DOptimized(hwcInput, kernel, bias, config), expected},
+            "Conv2DBatchNormReLU":         {Conv2DBatchNormReLU(hwcInput, kernel, bias, bn, true, config), expectedFused},
+            "Conv2DBatchNormReLUParallel": {Conv2DBatchNormReLUParallel(hwcInput, kernel, bias, bn, true, config), expectedFused},
+            "Conv2DFused":                 {engine.Conv2DFused(hwcInput, kernel, bias, bn, true, config), expectedFused},
+        }
+        
+        for name, tc := range cases {
+            if tc.got.Layout != tensor.LayoutHWC {
+                t.Errorf("%s: expected HWC output, got %s", name, tc.got.Layout)
+                continue
+            }
+            for c := 0; c < tc.want.Channels; c++ {
+                for h := 0; h < tc.want.Height; h++ {
+                    for w := 0; w < tc.want.Width; w++ {
+                        if math.Abs(float64(tc.want.Get(c, h, w)-tc.got.Get(c, h, w))) > 1e-4 {
+                            t.Fatalf("%s %+v: mismatch at (%d,%d,%d): expected %f, got %f", 
+                                name, config, c, h, w, tc.want.Get(c, h, w), tc.got.Get(c, h, w))
+                        }
+                    }
+                }
+            }
+        }
+    }
+}
+
+func TestConv2DFusedBatchMatchesSingle(t *testing.T) {
+    kernel := tensor.NewKernel(3, 4, 6)
+    kernel.RandomFill()
+    bias := []float32{0.1, -0.2, 0.3, -0.4, 0.5, -0.6}
+    bn := NewBatchNormParams(6)
+    for f := 0; f < 6; f++ {
+        bn.Variance[f] = 1 + float32(f)*0.5
+        bn.Shift[f] = float32(f) * 0.05
+    }
+    
+    inputs := make([]*tensor.FeatureMap, 3)
+    for b := range inputs {
+        inputs[b] = tensor.NewFeatureMap(9, 7, 4)
+        inputs[b].RandomFill()
+    }
+    
+    engine := NewConvolutionEngine()
+    for _, layout := range []tensor.Layout{tensor.LayoutCHW, tensor.LayoutHWC} {
+        for _, config := range []Conv2DConfig{{Padding: 1, Stride: 1}, {Padding: 0, Stride: 2}} {
+            batch := make([]*tensor.FeatureMap, len(inputs))
+            for b, input := range inputs {
+                batch[b] = input.ToLayout(layout)
+            }
+            outputs := engine.Conv2DFusedBatch(batch, kernel, bias, bn, true, config)
+            if len(outputs) != len(inputs) {
+                t.Fatalf("Expected %d outputs, got %d", len(inputs), len(outputs))
+            }
+            
+            for b, input := range inputs {
+                expected := Conv2DBatchNormReLU(input, kernel, bias, bn, true, config)
+                if outputs[b].Layout != layout {
+                    t.Fatalf("%s: expected %s output, got %s", layout, layout, outputs[b].Layout)
+                }
+                for c := 0; c < expected.Channels; c++ {
+                    for h := 0; h < expected.Height; h++ {
+                        for w := 0; w < expected.Width; w++ {
+                            if math.Abs(float64(expected.Get(c, h, w)-outputs[b].Get(c, h, w))) > 1e-4 {
+                                t.Fatalf("%s %+v image %d: mismatch at (%d,%d,%d): expected %f, got %f", layout, config,
+                                    b, c, h, w, expected.Get(c, h, w), outputs[b].Get(c, h, w))
+                            }
+                        }
+                    }
+                }
+                engine.Release(outputs[b])
+            }
+        }
+    }
+    
+    if outputs := engine.Conv2DFusedBatch(nil, kernel, bias, bn, true, Conv2DConfig{Stride: 1}); len(outputs) != 0 {
+        t.Errorf("Expected no outputs for an empty batch, got %d", len(outputs))
+    }
+}
+
+func TestConv2DFusedBatch4D(t *testing.T) {
+    kernel := tensor.NewKernel(3, 4, 5)
+    kernel.RandomFill()
+    bias := []float32{0.1, -0.2, 0.3, -0.4, 0.5}
+    bn := NewBatchNormParams(5)
+    for f := 0; f < 5; f++ {
+        bn.Variance[f] = 1 + float32(f)*0.5
+        bn.Shift[f] = float32(f) * 0.05
+    }
+    
+    inputs := make([]*tensor.FeatureMap, 3)
+    for b := range inputs {
+        inputs[b] = tensor.NewFeatureMap(8, 6, 4)
+        inputs[b].RandomFill()
+    }
+    
+    engine := NewConvolutionEngine()
+    config := Conv2DConfig{Padding: 1, Stride: 1}
+    for _, layout := range []tensor.Layout{tensor.LayoutCHW, tensor.LayoutHWC} {
+        converted := make([]*tensor.FeatureMap, len(inputs))
+        for b, input := range inputs {
+            converted[b] = input.ToLayout(layout)
+        }
+        batch, err := tensor.StackFeatureMaps(converted)
+        if err != nil {
+            t.Fatal(err)
+        }
+        
+        output := engine.Conv2DFusedBatch4D(batch, kernel, bias, bn, true, config)
+        if output.N != 3 || output.Height != 8 || output.Width != 6 || output.Channels != 5 || output.Layout != layout {
+            t.Fatalf("%s: unexpected output %s", layout, output)
+        }
+        
+        // Conv2DFused without BN, then BN and ReLU over the whole batch
+        unfused := engine.Conv2DFusedBatch4D(batch, kernel, bias, nil, false, config)
+        BatchNormalizeBatch4DInPlace(unfused, bn)
+        
+        for b, input := range inputs {
+            expected := Conv2DBatchNormReLU(input, kernel, bias, bn, true, config)
+            for c := 0; c < expected.Channels; c++ {
+                for h := 0; h < expected.Height; h++ {
+                    for w := 0; w < expected.Width; w++ {
+                        want := expected.Get(c, h, w)
+                        if math.Abs(float64(want-output.Get(b, c, h, w))) > 1e-4 ||
+                            math.Abs(float64(want-unfused.Get(b, c, h, w))) > 1e-4 {
+                            t.Fatalf("%s image %d: mismatch at (%d,%d,%d): expected %f, got %f and %f", layout, b,
+                                c, h, w, want, output.Get(b, c, h, w), unfused.Get(b, c, h, w))
+                        }
+                    }
+                }
+            }
+        }
+    }
+}
+
 func TestGetConvOutputDims(t *testing.T) {
     testCases := []struct {
         inputH, inputW, kernelSize, padding, stride int
@@ -255,4 +590,31 @@ func BenchmarkConv2DLarge(b *testing.B) {
         output := Conv2DParallel(input, kernel, bias, config)
         _ = output
     }
-}
\ No newline at end of file
+}
+
+// benchmarkConv2DLayout runs a 3×3 same-padded convolution on a small image in the given layout
+func benchmarkConv2DLayout(b *testing.B, layout tensor.Layout, channels, filters int) {
+    input := tensor.NewFeatureMap(16, 16, channels)
+    input.RandomFill()
+    input = input.ToLayout(layout)
+    
+    kernel := tensor.NewKernel(3, channels, filters)
+    kernel.RandomFill()
+    
+    bias := make([]float32, filters)
+    config := Conv2DConfig{Padding: 1, Stride: 1}
+    
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        output := Conv2D(input, kernel, bias, config)
+        _ = output
+    }
+}
+
+func BenchmarkConv2DLayoutCHW(b *testing.B) {
+    benchmarkConv2DLayout(b, tensor.LayoutCHW, 64, 64)
+}
+
+func BenchmarkConv2DLayoutHWC(b *testing.B) {
+    benchmarkConv2DLayout(b, tensor.LayoutHWC, 64, 64)
+}
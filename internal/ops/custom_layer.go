@@ -0,0 +1,128 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+/**
+* Custom layers
+
+The forward executor only knows the built-in layer types. Custom layers let
+downstream code plug in its own operation by name: the op is registered once
+(typically from an init function) and an architecture refers to it with
+`type: custom` and `op: <name>`. The executor loads the weights named in the
+spec from the layer's directory and calls the forward function with them.
+*/
+
+// CustomForwardFunc computes a custom layer's output
+// weights holds the arrays declared in the layer's CustomLayerSpec, keyed by name;
+// params holds the numeric parameters given in the architecture.
+// The function must not modify input; it may return it unchanged. input may be in
+// either tensor layout, so use its accessors (or check Layout) rather than raw indexing.
+type CustomForwardFunc func(input *tensor.FeatureMap, weights map[string][]float32,
+    params map[string]float64) (*tensor.FeatureMap, error)
+
+// CustomWeight declares one weight array of a custom layer
+// It is loaded from <layer>/<layer>_<Name>.bin
+type CustomWeight struct {
+    Name       string // Array name, also used as the file suffix
+    Size       int    // Number of float32 elements
+    PerChannel bool   // If set, the array holds Size elements per input channel
+}
+
+// Elements returns the number of float32 values to load for the given input channels
+func (cw CustomWeight) Elements(inputChannels int) int {
+    if cw.PerChannel {
+        return cw.Size * inputChannels
+    }
+    return cw.Size
+}
+
+// CustomLayerSpec describes the weights and output shape of a custom layer
+type CustomLayerSpec struct {
+    Weights []CustomWeight
+
+    // OutputShape maps the input (height, width, channels) to the output shape.
+    // nil means the layer preserves its input shape.
+    OutputShape func(height, width, channels int, params map[string]float64) (int, int, int)
+}
+
+// CustomLayer is a registered custom operation
+type CustomLayer struct {
+    Name    string
+    Forward CustomForwardFunc
+    Spec    CustomLayerSpec
+}
+
+// OutputDims returns the output shape of the layer for the given input shape
+func (cl *CustomLayer) OutputDims(height, width, channels int, params map[string]float64) (int, int, int) {
+    if cl.Spec.OutputShape == nil {
+        return height, width, channels
+    }
+    return cl.Spec.OutputShape(height, width, channels, params)
+}
+
+var (
+    customLayersMu sync.RWMutex
+    customLayers   = make(map[string]*CustomLayer)
+)
+
+// RegisterCustomLayer makes a custom operation available to architectures under name
+func RegisterCustomLayer(name string, forward CustomForwardFunc, weightSpec CustomLayerSpec) error {
+    if name == "" {
+        return fmt.Errorf("custom layer name is required")
+    }
+    if forward == nil {
+        return fmt.Errorf("custom layer %q has no forward function", name)
+    }
+    for _, w := range weightSpec.Weights {
+        if w.Name == "" || w.Size <= 0 {
+            return fmt.Errorf("custom layer %q has invalid weight %q of size %d", name, w.Name, w.Size)
+        }
+    }
+
+    customLayersMu.Lock()
+    defer customLayersMu.Unlock()
+
+    if _, exists := customLayers[name]; exists {
+        return fmt.Errorf("custom layer %q is already registered", name)
+    }
+
+    customLayers[name] = &CustomLayer{
+        Name:    name,
+        Forward: forward,
+        Spec:    weightSpec,
+    }
+    return nil
+}
+
+// UnregisterCustomLayer removes a custom operation, mainly for tests
+func UnregisterCustomLayer(name string) {
+    customLayersMu.Lock()
+    defer customLayersMu.Unlock()
+    delete(customLayers, name)
+}
+
+// LookupCustomLayer returns the custom operation registered under name
+func LookupCustomLayer(name string) (*CustomLayer, bool) {
+    customLayersMu.RLock()
+    defer customLayersMu.RUnlock()
+    layer, ok := customLayers[name]
+    return layer, ok
+}
+
+// RegisteredCustomLayers returns the names of all registered custom operations, sorted
+func RegisteredCustomLayers() []string {
+    customLayersMu.RLock()
+    defer customLayersMu.RUnlock()
+
+    names := make([]string, 0, len(customLayers))
+    for name := range customLayers {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
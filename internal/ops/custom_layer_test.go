@@ -0,0 +1,64 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"testing"
+)
+
+func identityForward(input *tensor.FeatureMap, weights map[string][]float32, params map[string]float64) (*tensor.FeatureMap, error) {
+    return input, nil
+}
+
+func TestRegisterCustomLayer(t *testing.T) {
+    spec := CustomLayerSpec{
+        Weights: []CustomWeight{{Name: "scale", Size: 1, PerChannel: true}},
+    }
+    
+    if err := RegisterCustomLayer("test_identity", identityForward, spec); err != nil {
+        t.Fatalf("Failed to register custom layer: %v", err)
+    }
+    defer UnregisterCustomLayer("test_identity")
+    
+    // Duplicate names are rejected
+    if err := RegisterCustomLayer("test_identity", identityForward, spec); err == nil {
+        t.Error("Expected error when registering a duplicate name")
+    }
+    
+    layer, ok := LookupCustomLayer("test_identity")
+    if !ok {
+        t.Fatal("Registered layer not found")
+    }
+    if layer.Spec.Weights[0].Elements(16) != 16 {
+        t.Errorf("Expected 16 per-channel elements, got %d", layer.Spec.Weights[0].Elements(16))
+    }
+    
+    // Without OutputShape the layer preserves its input shape
+    h, w, c := layer.OutputDims(4, 5, 6, nil)
+    if h != 4 || w != 5 || c != 6 {
+        t.Errorf("Expected shape (4,5,6), got (%d,%d,%d)", h, w, c)
+    }
+    
+    found := false
+    for _, name := range RegisteredCustomLayers() {
+        if name == "test_identity" {
+            found = true
+        }
+    }
+    if !found {
+        t.Error("RegisteredCustomLayers does not list the registered layer")
+    }
+}
+
+func TestRegisterCustomLayerInvalid(t *testing.T) {
+    if err := RegisterCustomLayer("", identityForward, CustomLayerSpec{}); err == nil {
+        t.Error("Expected error for empty name")
+    }
+    if err := RegisterCustomLayer("test_nil", nil, CustomLayerSpec{}); err == nil {
+        t.Error("Expected error for nil forward function")
+    }
+    
+    spec := CustomLayerSpec{Weights: []CustomWeight{{Name: "w", Size: 0}}}
+    if err := RegisterCustomLayer("test_bad_weight", identityForward, spec); err == nil {
+        t.Error("Expected error for zero-sized weight")
+    }
+}
@@ -0,0 +1,170 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+)
+
+/**
+* Element-wise arithmetic with broadcasting
+
+Residual connections add two feature maps of the same shape, a
+squeeze-and-excitation block multiplies every channel by its own gate, and
+a spatial attention mask scales every channel alike. All of them are one
+element-wise op once the smaller operand is broadcast: along each of
+height, width and channels, the two sizes must be equal or one of them 1,
+and the size-1 operand repeats its single value along that axis:
+```
+a (H, W, C)  +  b (H, W, C)   residual            → (H, W, C)
+a (H, W, C)  *  b (1, 1, C)   per-channel gate    → (H, W, C)
+a (H, W, C)  *  b (H, W, 1)   spatial mask        → (H, W, C)
+a (H, W, C)  +  b (1, 1, 1)   scalar              → (H, W, C)
+```
+Results take the layout of a. The in-place variants write into a, so b
+must broadcast to a's shape. Mismatched shapes are programming errors and
+panic, as they do in BatchNormalize.
+*/
+
+// Add returns a + b, broadcasting dimensions of size 1
+func Add(a, b *tensor.FeatureMap) *tensor.FeatureMap {
+    return elementwise(a, b, func(x, y float32) float32 { return x + y })
+}
+
+// Sub returns a - b, broadcasting dimensions of size 1
+func Sub(a, b *tensor.FeatureMap) *tensor.FeatureMap {
+    return elementwise(a, b, func(x, y float32) float32 { return x - y })
+}
+
+// Mul returns the element-wise product of a and b, broadcasting dimensions of size 1
+func Mul(a, b *tensor.FeatureMap) *tensor.FeatureMap {
+    return elementwise(a, b, func(x, y float32) float32 { return x * y })
+}
+
+// AddInPlace adds b to a, e.g. a residual branch to its shortcut
+func AddInPlace(a, b *tensor.FeatureMap) {
+    elementwiseInPlace(a, b, func(x, y float32) float32 { return x + y })
+}
+
+// MulInPlace multiplies a by b element-wise, e.g. by per-channel gates
+func MulInPlace(a, b *tensor.FeatureMap) {
+    elementwiseInPlace(a, b, func(x, y float32) float32 { return x * y })
+}
+
+// Scale returns fm with every element multiplied by factor
+func Scale(fm *tensor.FeatureMap, factor float32) *tensor.FeatureMap {
+    result := fm.Clone()
+    ScaleInPlace(result, factor)
+    return result
+}
+
+// ScaleInPlace multiplies every element of fm by factor
+func ScaleInPlace(fm *tensor.FeatureMap, factor float32) {
+    for i := range fm.Data {
+        fm.Data[i] *= factor
+    }
+}
+
+// AddBias returns fm with bias[c] added to every element of channel c
+func AddBias(fm *tensor.FeatureMap, bias []float32) *tensor.FeatureMap {
+    result := fm.Clone()
+    AddBiasInPlace(result, bias)
+    return result
+}
+
+// AddBiasInPlace adds bias[c] to every element of channel c of fm
+func AddBiasInPlace(fm *tensor.FeatureMap, bias []float32) {
+    if len(bias) != fm.Channels {
+        panic(fmt.Sprintf("bias has %d values, feature map %d channels", len(bias), fm.Channels))
+    }
+    if fm.Layout == tensor.LayoutHWC {
+        for i := range fm.Data {
+            fm.Data[i] += bias[i%fm.Channels]
+        }
+        return
+    }
+    plane := fm.Height * fm.Width
+    for c, b := range bias {
+        channel := fm.Data[c*plane : (c+1)*plane]
+        for i := range channel {
+            channel[i] += b
+        }
+    }
+}
+
+// broadcastDim returns the size two broadcast dimensions combine to
+func broadcastDim(x, y int) (int, bool) {
+    switch {
+    case x == y || y == 1:
+        return x, true
+    case x == 1:
+        return y, true
+    default:
+        return 0, false
+    }
+}
+
+// broadcastShape returns the (height, width, channels) a and b broadcast to
+func broadcastShape(a, b *tensor.FeatureMap) (int, int, int) {
+    height, okH := broadcastDim(a.Height, b.Height)
+    width, okW := broadcastDim(a.Width, b.Width)
+    channels, okC := broadcastDim(a.Channels, b.Channels)
+    if !okH || !okW || !okC {
+        panic(fmt.Sprintf("cannot broadcast shapes (%d,%d,%d) and (%d,%d,%d)",
+            a.Height, a.Width, a.Channels, b.Height, b.Width, b.Channels))
+    }
+    return height, width, channels
+}
+
+// broadcastAt returns the element of fm at (c, h, w) of the broadcast shape:
+// along a dimension of size 1, always its only element
+func broadcastAt(fm *tensor.FeatureMap, c, h, w int) float32 {
+    return fm.GetUnsafe(min(c, fm.Channels-1), min(h, fm.Height-1), min(w, fm.Width-1))
+}
+
+// elementwise returns op applied to the broadcast elements of a and b
+func elementwise(a, b *tensor.FeatureMap, op func(x, y float32) float32) *tensor.FeatureMap {
+    height, width, channels := broadcastShape(a, b)
+    result := tensor.NewFeatureMapWithLayout(height, width, channels, a.Layout)
+    if sameShape(a, b) && a.Layout == b.Layout {
+        for i, x := range a.Data {
+            result.Data[i] = op(x, b.Data[i])
+        }
+        return result
+    }
+
+    for c := 0; c < channels; c++ {
+        for h := 0; h < height; h++ {
+            for w := 0; w < width; w++ {
+                result.SetUnsafe(c, h, w, op(broadcastAt(a, c, h, w), broadcastAt(b, c, h, w)))
+            }
+        }
+    }
+    return result
+}
+
+// elementwiseInPlace stores op applied to the elements of a and the broadcast elements of b in a
+func elementwiseInPlace(a, b *tensor.FeatureMap, op func(x, y float32) float32) {
+    if height, width, channels := broadcastShape(a, b); height != a.Height || width != a.Width || channels != a.Channels {
+        panic(fmt.Sprintf("cannot broadcast shape (%d,%d,%d) into (%d,%d,%d) in place",
+            b.Height, b.Width, b.Channels, a.Height, a.Width, a.Channels))
+    }
+    if sameShape(a, b) && a.Layout == b.Layout {
+        for i, y := range b.Data {
+            a.Data[i] = op(a.Data[i], y)
+        }
+        return
+    }
+
+    for c := 0; c < a.Channels; c++ {
+        for h := 0; h < a.Height; h++ {
+            for w := 0; w < a.Width; w++ {
+                a.SetUnsafe(c, h, w, op(a.GetUnsafe(c, h, w), broadcastAt(b, c, h, w)))
+            }
+        }
+    }
+}
+
+// sameShape reports whether a and b have the same height, width and channels
+func sameShape(a, b *tensor.FeatureMap) bool {
+    return a.Height == b.Height && a.Width == b.Width && a.Channels == b.Channels
+}
@@ -0,0 +1,114 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"testing"
+)
+
+// indexedMap returns a feature map whose element (c, h, w) is 100c + 10h + w
+func indexedMap(height, width, channels int, layout tensor.Layout) *tensor.FeatureMap {
+    fm := tensor.NewFeatureMapWithLayout(height, width, channels, layout)
+    for c := 0; c < channels; c++ {
+        for h := 0; h < height; h++ {
+            for w := 0; w < width; w++ {
+                fm.Set(c, h, w, float32(100*c+10*h+w))
+            }
+        }
+    }
+    return fm
+}
+
+func TestElementwiseSameShape(t *testing.T) {
+    a := indexedMap(2, 3, 2, tensor.LayoutCHW)
+    b := indexedMap(2, 3, 2, tensor.LayoutHWC)
+    b.Fill(2)
+
+    sum, diff, product := Add(a, b), Sub(a, b), Mul(a, b)
+    if sum.Get(1, 1, 2) != 114 || diff.Get(1, 1, 2) != 110 || product.Get(1, 1, 2) != 224 {
+        t.Errorf("Unexpected results at (1,1,2): %f, %f, %f", sum.Get(1, 1, 2), diff.Get(1, 1, 2), product.Get(1, 1, 2))
+    }
+    if sum.Layout != tensor.LayoutCHW || a.Get(1, 1, 2) != 112 {
+        t.Error("Expected a result in the layout of a, with a left unchanged")
+    }
+
+    AddInPlace(a, b)
+    if a.Get(0, 1, 0) != 12 {
+        t.Errorf("Expected AddInPlace to add 2, got %f", a.Get(0, 1, 0))
+    }
+}
+
+func TestElementwiseBroadcast(t *testing.T) {
+    for _, layout := range []tensor.Layout{tensor.LayoutCHW, tensor.LayoutHWC} {
+        a := indexedMap(2, 3, 4, layout)
+
+        // A (1, 1, C) gate scales every channel by its own value
+        gate := tensor.NewFeatureMap(1, 1, 4)
+        copy(gate.Data, []float32{0, 1, 2, 3})
+        gated := Mul(a, gate)
+        if gated.Get(3, 1, 2) != 3*312 || gated.Get(0, 1, 2) != 0 {
+            t.Errorf("%s: unexpected gated values %f, %f", layout, gated.Get(3, 1, 2), gated.Get(0, 1, 2))
+        }
+
+        // An (H, W, 1) mask scales every channel alike, in either operand order
+        mask := tensor.NewFeatureMap(2, 3, 1)
+        mask.Set(0, 1, 2, 0.5)
+        for _, masked := range []*tensor.FeatureMap{Mul(a, mask), Mul(mask, a)} {
+            if masked.Channels != 4 || masked.Get(2, 1, 2) != 106 || masked.Get(2, 0, 0) != 0 {
+                t.Errorf("%s: unexpected masked values %s", layout, masked)
+            }
+        }
+
+        // A scalar map offsets every element
+        scalar := tensor.NewFeatureMap(1, 1, 1)
+        scalar.Fill(1)
+        MulInPlace(a, gate)
+        AddInPlace(a, scalar)
+        if a.Get(2, 0, 1) != 2*201+1 {
+            t.Errorf("%s: expected %d, got %f", layout, 2*201+1, a.Get(2, 0, 1))
+        }
+    }
+
+    // Outer broadcasting of (H, 1, C) and (1, W, C)
+    column := indexedMap(3, 1, 2, tensor.LayoutCHW)
+    row := indexedMap(1, 4, 2, tensor.LayoutCHW)
+    grid := Add(column, row)
+    if grid.Height != 3 || grid.Width != 4 || grid.Get(1, 2, 3) != 120+103 {
+        t.Errorf("Expected a 3x4 grid, got %s with %f at (1,2,3)", grid, grid.Get(1, 2, 3))
+    }
+
+    for _, bad := range []func(){
+        func() { Add(indexedMap(2, 3, 4, tensor.LayoutCHW), indexedMap(2, 2, 4, tensor.LayoutCHW)) },
+        func() { AddInPlace(tensor.NewFeatureMap(1, 1, 4), indexedMap(2, 3, 4, tensor.LayoutCHW)) },
+    } {
+        func() {
+            defer func() {
+                if recover() == nil {
+                    t.Error("Expected a panic for shapes that do not broadcast")
+                }
+            }()
+            bad()
+        }()
+    }
+}
+
+func TestScaleAndAddBias(t *testing.T) {
+    for _, layout := range []tensor.Layout{tensor.LayoutCHW, tensor.LayoutHWC} {
+        fm := indexedMap(2, 2, 3, layout)
+        scaled := Scale(fm, 0.5)
+        if scaled.Get(2, 1, 1) != 105.5 || fm.Get(2, 1, 1) != 211 {
+            t.Errorf("%s: expected 105.5 in a copy, got %f (original %f)", layout, scaled.Get(2, 1, 1), fm.Get(2, 1, 1))
+        }
+
+        biased := AddBias(fm, []float32{1, 2, 3})
+        if biased.Get(0, 1, 0) != 11 || biased.Get(2, 1, 0) != 213 || biased.Layout != layout {
+            t.Errorf("%s: unexpected biased values %f, %f", layout, biased.Get(0, 1, 0), biased.Get(2, 1, 0))
+        }
+    }
+
+    defer func() {
+        if recover() == nil {
+            t.Error("Expected a panic for a bias of the wrong length")
+        }
+    }()
+    AddBias(tensor.NewFeatureMap(2, 2, 3), []float32{1, 2})
+}
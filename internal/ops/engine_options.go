@@ -0,0 +1,170 @@
+package ops
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+/**
+* Runtime engine selection
+
+Performance numbers are only comparable when it is known which convolution
+backend produced them. EngineOptions gathers the knobs that change speed but
+not results (backend, worker goroutines, buffer pooling) so they can be set
+the same way from flags or the environment, and reported next to timings.
+
+Backend names accepted on the command line:
+```
+auto      size-based heuristic (default), or Autotune choices
+naive     single-threaded direct loops (alias: direct)
+tiled     cache-blocked direct loops
+parallel  direct loops spread over goroutines
+gemm      im2col + matrix multiply
+winograd, blas, gpu   recognised, but not built into this binary
+```
+*/
+
+// Environment variables read by EngineOptionsFromEnv
+const (
+    EnvEngine        = "GOCNN_ENGINE"         // Backend name
+    EnvEngineWorkers = "GOCNN_ENGINE_WORKERS" // Worker goroutines for the parallel backend
+    EnvEnginePool    = "GOCNN_ENGINE_POOL"    // Buffer pooling: on/off
+)
+
+// unavailableBackends are backend names users may ask for that this build lacks
+var unavailableBackends = map[string]bool{
+    "winograd": true,
+    "blas":     true,
+    "gpu":      true,
+}
+
+// EngineOptions describes how a ConvolutionEngine executes convolutions
+type EngineOptions struct {
+    Backend ConvAlgorithm `json:"backend"` // AlgoAuto lets the engine (or Autotune) decide per layer
+    Workers int           `json:"workers"` // Goroutines for the parallel backend (0 = one per CPU)
+    Pooling bool          `json:"pooling"` // Recycle padded inputs and outputs between calls
+}
+
+// DefaultEngineOptions returns the options of a freshly created engine
+func DefaultEngineOptions() EngineOptions {
+    return EngineOptions{
+        Backend: AlgoAuto,
+        Workers: 0,
+        Pooling: true,
+    }
+}
+
+// String formats the options for logs, e.g. "backend=gemm workers=8 pooling=on"
+// Automatic settings are shown resolved, so the line is enough to reproduce a run
+func (o EngineOptions) String() string {
+    backend := string(o.Backend)
+    if o.Backend == AlgoAuto {
+        backend = "auto"
+    }
+    pooling := "off"
+    if o.Pooling {
+        pooling = "on"
+    }
+    return fmt.Sprintf("backend=%s workers=%d pooling=%s", backend, o.EffectiveWorkers(), pooling)
+}
+
+// EffectiveWorkers returns the number of goroutines the parallel backend will use
+func (o EngineOptions) EffectiveWorkers() int {
+    if o.Workers > 0 {
+        return o.Workers
+    }
+    return runtime.NumCPU()
+}
+
+// ParseBackend converts a backend name such as "gemm" or "naive" to a ConvAlgorithm
+func ParseBackend(name string) (ConvAlgorithm, error) {
+    name = strings.ToLower(strings.TrimSpace(name))
+    if name == "naive" {
+        name = string(AlgoDirect)
+    }
+    if unavailableBackends[name] {
+        return AlgoAuto, fmt.Errorf("backend %q is not available in this build (available: auto, naive, tiled, parallel, gemm)", name)
+    }
+    algo, err := ParseConvAlgorithm(name)
+    if err != nil {
+        return AlgoAuto, fmt.Errorf("unknown backend %q (available: auto, naive, tiled, parallel, gemm)", name)
+    }
+    return algo, nil
+}
+
+// ParsePooling converts on/off style values to a bool
+func ParsePooling(value string) (bool, error) {
+    switch strings.ToLower(strings.TrimSpace(value)) {
+    case "on", "true", "1", "yes":
+        return true, nil
+    case "off", "false", "0", "no":
+        return false, nil
+    }
+    return false, fmt.Errorf("invalid pooling setting %q (use on or off)", value)
+}
+
+// EngineOptionsFromEnv starts from base and applies any GOCNN_ENGINE* variables that are set
+func EngineOptionsFromEnv(base EngineOptions) (EngineOptions, error) {
+    opts := base
+
+    if value, ok := os.LookupEnv(EnvEngine); ok && value != "" {
+        backend, err := ParseBackend(value)
+        if err != nil {
+            return base, fmt.Errorf("%s: %w", EnvEngine, err)
+        }
+        opts.Backend = backend
+    }
+
+    if value, ok := os.LookupEnv(EnvEngineWorkers); ok && value != "" {
+        workers, err := strconv.Atoi(value)
+        if err != nil || workers < 0 {
+            return base, fmt.Errorf("%s: invalid worker count %q", EnvEngineWorkers, value)
+        }
+        opts.Workers = workers
+    }
+
+    if value, ok := os.LookupEnv(EnvEnginePool); ok && value != "" {
+        pooling, err := ParsePooling(value)
+        if err != nil {
+            return base, fmt.Errorf("%s: %w", EnvEnginePool, err)
+        }
+        opts.Pooling = pooling
+    }
+
+    return opts, nil
+}
+
+// Configure applies opts to the engine
+func (ce *ConvolutionEngine) Configure(opts EngineOptions) error {
+    if opts.Backend != AlgoAuto && !opts.Backend.valid() {
+        return fmt.Errorf("unknown backend %q", opts.Backend)
+    }
+    if opts.Workers < 0 {
+        return fmt.Errorf("worker count must not be negative, got %d", opts.Workers)
+    }
+
+    ce.Backend = opts.Backend
+    ce.NumWorkers = opts.Workers
+
+    if opts.Pooling {
+        ce.Buffers().SetLimit(maxPooledPerShape)
+    } else {
+        ce.Buffers().SetLimit(0)
+    }
+    return nil
+}
+
+// Options reports the engine's current settings, with an automatic worker count
+// resolved to the number actually used so recorded results are attributable
+func (ce *ConvolutionEngine) Options() EngineOptions {
+    opts := EngineOptions{
+        Backend: ce.Backend,
+        Workers: ce.NumWorkers,
+        Pooling: ce.Buffers().Limit() > 0,
+    }
+    opts.Workers = opts.EffectiveWorkers()
+    return opts
+}
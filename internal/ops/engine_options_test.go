@@ -0,0 +1,90 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"testing"
+)
+
+func TestParseBackend(t *testing.T) {
+    cases := map[string]ConvAlgorithm{
+        "auto":     AlgoAuto,
+        "naive":    AlgoDirect,
+        "Tiled":    AlgoTiled,
+        "parallel": AlgoParallel,
+        "gemm":     AlgoGEMM,
+    }
+    for name, expected := range cases {
+        algo, err := ParseBackend(name)
+        if err != nil || algo != expected {
+            t.Errorf("ParseBackend(%q) = %q, %v; expected %q", name, algo, err, expected)
+        }
+    }
+    
+    for _, name := range []string{"winograd", "blas", "gpu", "fft"} {
+        if _, err := ParseBackend(name); err == nil {
+            t.Errorf("Expected error for backend %q", name)
+        }
+    }
+}
+
+func TestEngineOptionsFromEnv(t *testing.T) {
+    t.Setenv(EnvEngine, "gemm")
+    t.Setenv(EnvEngineWorkers, "3")
+    t.Setenv(EnvEnginePool, "off")
+    
+    opts, err := EngineOptionsFromEnv(DefaultEngineOptions())
+    if err != nil {
+        t.Fatalf("EngineOptionsFromEnv failed: %v", err)
+    }
+    expected := EngineOptions{Backend: AlgoGEMM, Workers: 3, Pooling: false}
+    if opts != expected {
+        t.Errorf("Expected %+v, got %+v", expected, opts)
+    }
+    if opts.String() != "backend=gemm workers=3 pooling=off" {
+        t.Errorf("Unexpected string form %q", opts.String())
+    }
+    
+    t.Setenv(EnvEngineWorkers, "many")
+    if _, err := EngineOptionsFromEnv(DefaultEngineOptions()); err == nil {
+        t.Error("Expected error for invalid worker count")
+    }
+}
+
+func TestConvolutionEngineConfigure(t *testing.T) {
+    input := tensor.NewFeatureMap(6, 6, 2)
+    input.RandomFill()
+    
+    kernel := tensor.NewKernel(3, 2, 3)
+    kernel.RandomFill()
+    
+    bias := []float32{0.1, 0.2, 0.3}
+    config := Conv2DConfig{Padding: 1, Stride: 1}
+    expected := Conv2D(input, kernel, bias, config)
+    
+    engine := NewConvolutionEngine()
+    if err := engine.Configure(EngineOptions{Backend: AlgoGEMM, Workers: 2, Pooling: false}); err != nil {
+        t.Fatalf("Configure failed: %v", err)
+    }
+    
+    opts := engine.Options()
+    if opts.Backend != AlgoGEMM || opts.Workers != 2 || opts.Pooling {
+        t.Errorf("Options do not reflect configuration: %+v", opts)
+    }
+    
+    output := engine.Conv2DOptimized(input, kernel, bias, config)
+    for i := range expected.Data {
+        if diff := expected.Data[i] - output.Data[i]; diff > 1e-4 || diff < -1e-4 {
+            t.Fatalf("Mismatch at index %d: expected %f, got %f", i, expected.Data[i], output.Data[i])
+        }
+    }
+    
+    // With pooling off, released buffers are dropped
+    engine.Release(output)
+    if engine.Buffers().Len() != 0 {
+        t.Errorf("Expected no pooled buffers with pooling off, got %d", engine.Buffers().Len())
+    }
+    
+    if err := engine.Configure(EngineOptions{Backend: "fft"}); err == nil {
+        t.Error("Expected error for unknown backend")
+    }
+}
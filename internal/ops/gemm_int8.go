@@ -0,0 +1,86 @@
+package ops
+
+/**
+* Int8 matrix multiplication
+
+With int8 weights and int8 activations a dot product is a sum of int8×int8
+products. Each product fits in 16 bits (|q| ≤ 127, so at most 16129) and the
+sum is kept in int32, which is exact for any depth below
+maxInt8GemmDepth ≈ 133 000 — far beyond the C*K*K ≤ 1152 of TinyCNN. Float
+accumulation can't express this: it rounds after every addition and needs
+four times the memory traffic per operand.
+
+The matrices are multiplied in "NT" form, both operands row-major with the
+shared dimension k contiguous:
+```
+C[i][j] = Σ_k A[i][k] * B[j][k]      A: m×k (filters),  B: n×k (patches)
+```
+so every output is one contiguous dot product. The kernel computes four
+columns of C per pass over a row of A, reusing each loaded weight four times.
+
+dotInt8x4 is the only routine that touches the data; it is the place for a
+hand-written kernel (VNNI's VPDPBUSD on x86, SDOT on arm64) behind a build
+tag. The pure Go version keeps results bit-identical across platforms.
+*/
+
+// maxInt8GemmDepth is the largest k for which an int32 accumulator can't overflow
+const maxInt8GemmDepth = (1<<31 - 1) / (127 * 127)
+
+// GemmInt8 computes c[i*n+j] = Σ a[i*k+kk] * b[j*k+kk] for an m×k matrix a and an
+// n×k matrix b, accumulating in int32. c must hold m*n values.
+func GemmInt8(m, n, k int, a, b []int8, c []int32) {
+    gemmInt8Rows(0, m, n, k, a, b, c)
+}
+
+// gemmInt8Rows computes rows [rowStart, rowEnd) of GemmInt8
+func gemmInt8Rows(rowStart, rowEnd, n, k int, a, b []int8, c []int32) {
+    for i := rowStart; i < rowEnd; i++ {
+        row := a[i*k : (i+1)*k]
+        out := c[i*n : (i+1)*n]
+
+        j := 0
+        for ; j+4 <= n; j += 4 {
+            out[j], out[j+1], out[j+2], out[j+3] = dotInt8x4(row,
+                b[j*k:(j+1)*k], b[(j+1)*k:(j+2)*k], b[(j+2)*k:(j+3)*k], b[(j+3)*k:(j+4)*k])
+        }
+        for ; j < n; j++ {
+            out[j] = dotInt8(row, b[j*k:(j+1)*k])
+        }
+    }
+}
+
+// dotInt8x4 returns the dot products of a with b0..b3, which all have len(a) values
+func dotInt8x4(a, b0, b1, b2, b3 []int8) (int32, int32, int32, int32) {
+    b0 = b0[:len(a)]
+    b1 = b1[:len(a)]
+    b2 = b2[:len(a)]
+    b3 = b3[:len(a)]
+
+    var s0, s1, s2, s3 int32
+    for i, v := range a {
+        w := int32(v)
+        s0 += w * int32(b0[i])
+        s1 += w * int32(b1[i])
+        s2 += w * int32(b2[i])
+        s3 += w * int32(b3[i])
+    }
+    return s0, s1, s2, s3
+}
+
+// dotInt8 returns the dot product of a and b, which has len(a) values
+func dotInt8(a, b []int8) int32 {
+    b = b[:len(a)]
+
+    var s0, s1, s2, s3 int32
+    i := 0
+    for ; i+4 <= len(a); i += 4 {
+        s0 += int32(a[i]) * int32(b[i])
+        s1 += int32(a[i+1]) * int32(b[i+1])
+        s2 += int32(a[i+2]) * int32(b[i+2])
+        s3 += int32(a[i+3]) * int32(b[i+3])
+    }
+    for ; i < len(a); i++ {
+        s0 += int32(a[i]) * int32(b[i])
+    }
+    return s0 + s1 + s2 + s3
+}
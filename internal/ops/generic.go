@@ -0,0 +1,192 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"math"
+)
+
+/**
+* Reference ops over any element type
+
+The engine's convolutions (direct, tiled, GEMM, int8) are float32 only.
+The ops below are straightforward loops over tensor.FeatureMapOf[T], so the
+same code runs at float32 and at float64 and the model can run a whole
+forward pass in double precision (see tensor.PrecisionFloat64):
+```
+Conv2DFusedOf[float64]   sum, batch norm and ReLU all in float64
+Pooling2DOf, GlobalMaxPoolingOf, GlobalAvgPoolingOf, SoftmaxOf
+```
+Weights, biases and batch norm parameters stay float32 and are widened as
+they are read, which is exact. Only the convolution loop is a reference
+copy: it folds batch norm and applies ReLU through the same foldFilter and
+epilogue as the engine. Pooling2D, GlobalMaxPooling, GlobalAvgPooling and
+Softmax are the float32 instances of the ops below, run on a
+tensor.FeatureMapView of their input.
+*/
+
+// Conv2DFusedOf is Conv2DBatchNormReLU computed in T, with the batch norm
+// folded in T as well; bn may be nil
+func Conv2DFusedOf[T tensor.Float](input *tensor.FeatureMapOf[T], kernel *tensor.KernelOf[T], bias []float32,
+    bn *BatchNormParams, applyReLU bool, config Conv2DConfig) *tensor.FeatureMapOf[T] {
+
+    // The shapes are all validation looks at
+    validateFusedConvInputs(
+        &tensor.FeatureMap{Height: input.Height, Width: input.Width, Channels: input.Channels},
+        &tensor.Kernel{Size: kernel.Size, Channels: kernel.Channels, Filters: kernel.Filters},
+        bias, bn, config)
+
+    outHeight, outWidth := GetConvOutputDims(input.Height, input.Width, kernel.Size, config.Padding, config.Stride)
+    output := tensor.NewFeatureMapOf[T](outHeight, outWidth, kernel.Filters, input.Layout)
+    for f := 0; f < kernel.Filters; f++ {
+        scale, shift := foldFilter[T](bias, bn, f)
+
+        for i := 0; i < outHeight; i++ {
+            for j := 0; j < outWidth; j++ {
+                var sum T
+                for c := 0; c < kernel.Channels; c++ {
+                    for m := 0; m < kernel.Size; m++ {
+                        h := i*config.Stride + m - config.Padding
+                        if h < 0 || h >= input.Height {
+                            continue
+                        }
+                        for n := 0; n < kernel.Size; n++ {
+                            w := j*config.Stride + n - config.Padding
+                            if w < 0 || w >= input.Width {
+                                continue
+                            }
+                            sum += input.Data[input.Index(c, h, w)] * kernel.GetWeight(f, c, m, n)
+                        }
+                    }
+                }
+
+                output.Data[output.Index(f, i, j)] = epilogue(sum, scale, shift, applyReLU)
+            }
+        }
+    }
+    return output
+}
+
+// Pooling2DOf is Pooling2D over a feature map of T
+func Pooling2DOf[T tensor.Float](input *tensor.FeatureMapOf[T], config PoolingConfig) *tensor.FeatureMapOf[T] {
+    shape := &tensor.FeatureMap{Height: input.Height, Width: input.Width, Channels: input.Channels}
+    if err := validatePoolingInputs(shape, config); err != nil {
+        panic(fmt.Sprintf("Pooling validation failed: %v", err))
+    }
+
+    outHeight, outWidth := GetPoolingOutputDims(input.Height, input.Width, config.KernelSize, config.Stride)
+    output := tensor.NewFeatureMapOf[T](outHeight, outWidth, input.Channels, input.Layout)
+    pool2DIntoOf(input, output, config)
+    return output
+}
+
+// pool2DIntoOf pools input into output, which has the output dimensions
+func pool2DIntoOf[T tensor.Float](input, output *tensor.FeatureMapOf[T], config PoolingConfig) {
+    for c := 0; c < input.Channels; c++ {
+        for i := 0; i < output.Height; i++ {
+            for j := 0; j < output.Width; j++ {
+                startH, startW := i*config.Stride, j*config.Stride
+                output.Data[output.Index(c, i, j)] = poolWindow(input, c, startH, startH+config.KernelSize,
+                    startW, startW+config.KernelSize, config.Type)
+            }
+        }
+    }
+}
+
+// poolWindow reduces rows [startH, endH) and columns [startW, endW) of one channel;
+// the average of an empty window is 0
+func poolWindow[T tensor.Float](input *tensor.FeatureMapOf[T], channel, startH, endH, startW, endW int,
+    poolType PoolingType) T {
+
+    result := input.Data[input.Index(channel, startH, startW)]
+    var sum T
+    for h := startH; h < endH; h++ {
+        for w := startW; w < endW; w++ {
+            val := input.Data[input.Index(channel, h, w)]
+            switch poolType {
+            case MaxPooling:
+                if val > result {
+                    result = val
+                }
+            case MinPooling:
+                if val < result {
+                    result = val
+                }
+            case AvgPooling:
+                sum += val
+            default:
+                panic(fmt.Sprintf("Unknown pooling type: %d", poolType))
+            }
+        }
+    }
+    if poolType == AvgPooling {
+        if count := (endH - startH) * (endW - startW); count > 0 {
+            return sum / T(count)
+        }
+        return 0
+    }
+    return result
+}
+
+// GlobalMaxPoolingOf is GlobalMaxPooling over a feature map of T
+func GlobalMaxPoolingOf[T tensor.Float](input *tensor.FeatureMapOf[T]) []T {
+    result := make([]T, input.Channels)
+    for c := range result {
+        maxVal := input.Data[input.Index(c, 0, 0)]
+        for h := 0; h < input.Height; h++ {
+            for w := 0; w < input.Width; w++ {
+                if val := input.Data[input.Index(c, h, w)]; val > maxVal {
+                    maxVal = val
+                }
+            }
+        }
+        result[c] = maxVal
+    }
+    return result
+}
+
+// GlobalAvgPoolingOf is GlobalAvgPooling over a feature map of T, summing in T
+func GlobalAvgPoolingOf[T tensor.Float](input *tensor.FeatureMapOf[T]) []T {
+    result := make([]T, input.Channels)
+    for c := range result {
+        var sum T
+        for h := 0; h < input.Height; h++ {
+            for w := 0; w < input.Width; w++ {
+                sum += input.Data[input.Index(c, h, w)]
+            }
+        }
+        result[c] = sum / T(input.Height*input.Width)
+    }
+    return result
+}
+
+// SoftmaxOf is Softmax over values of T, normalizing in T
+func SoftmaxOf[T tensor.Float](input []T) []T {
+    result := make([]T, len(input))
+    softmaxInto(result, input)
+    return result
+}
+
+// softmaxInto writes the softmax of src to dst, which may be src itself
+func softmaxInto[T tensor.Float](dst, src []T) {
+    if len(src) == 0 {
+        return
+    }
+
+    maxVal := src[0]
+    for _, val := range src[1:] {
+        if val > maxVal {
+            maxVal = val
+        }
+    }
+    var sum T
+    for i, val := range src {
+        dst[i] = T(math.Exp(float64(val - maxVal)))
+        sum += dst[i]
+    }
+    if sum > 0 {
+        for i := range dst {
+            dst[i] /= sum
+        }
+    }
+}
@@ -0,0 +1,67 @@
+package ops
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"math"
+	"testing"
+)
+
+func TestGenericOpsMatchFloat32(t *testing.T) {
+    kernel := tensor.NewKernel(3, 4, 6)
+    kernel.RandomFill()
+    bias := []float32{0.1, -0.2, 0.3, -0.4, 0.5, -0.6}
+    bn := NewBatchNormParams(6)
+    for f := 0; f < 6; f++ {
+        bn.Variance[f] = 1 + float32(f)*0.5
+        bn.Shift[f] = float32(f)*0.05 - 0.1
+    }
+    input := tensor.NewFeatureMap(9, 7, 4)
+    input.RandomFill()
+
+    for _, layout := range []tensor.Layout{tensor.LayoutCHW, tensor.LayoutHWC} {
+        converted := input.ToLayout(layout)
+        for _, config := range []Conv2DConfig{{Padding: 1, Stride: 1}, {Padding: 0, Stride: 2}} {
+            expected := Conv2DBatchNormReLU(converted, kernel, bias, bn, true, config)
+            got := Conv2DFusedOf(tensor.FeatureMapAs[float64](converted), tensor.KernelAs[float64](kernel),
+                bias, bn, true, config)
+            if got.Height != expected.Height || got.Width != expected.Width || got.Layout != layout {
+                t.Fatalf("%s %+v: expected %s, got %s", layout, config, expected, got)
+            }
+            for c := 0; c < expected.Channels; c++ {
+                for h := 0; h < expected.Height; h++ {
+                    for w := 0; w < expected.Width; w++ {
+                        if math.Abs(float64(expected.Get(c, h, w))-got.Get(c, h, w)) > 1e-4 {
+                            t.Fatalf("%s %+v: mismatch at (%d,%d,%d): expected %f, got %f", layout, config,
+                                c, h, w, expected.Get(c, h, w), got.Get(c, h, w))
+                        }
+                    }
+                }
+            }
+        }
+    }
+
+    wide := tensor.FeatureMapAs[float64](input)
+    for _, poolType := range []PoolingType{MaxPooling, AvgPooling, MinPooling} {
+        config := PoolingConfig{KernelSize: 2, Stride: 2, Type: poolType}
+        expected, got := Pooling2D(input, config), Pooling2DOf(wide, config).FeatureMap()
+        for i, v := range expected.Data {
+            if math.Abs(float64(v-got.Data[i])) > 1e-6 {
+                t.Fatalf("Pooling type %d: element %d is %f, expected %f", poolType, i, got.Data[i], v)
+            }
+        }
+    }
+
+    avg, maxes := GlobalAvgPooling(input), GlobalMaxPooling(input)
+    for c, v := range GlobalAvgPoolingOf(wide) {
+        if math.Abs(float64(avg[c])-v) > 1e-6 || float64(maxes[c]) != GlobalMaxPoolingOf(wide)[c] {
+            t.Errorf("Channel %d: unexpected global pooling %f", c, v)
+        }
+    }
+
+    probabilities := SoftmaxOf([]float64{1, 2, 3})
+    for i, p := range Softmax([]float32{1, 2, 3}) {
+        if math.Abs(float64(p)-probabilities[i]) > 1e-6 {
+            t.Errorf("Softmax %d: expected %f, got %f", i, p, probabilities[i])
+        }
+    }
+}
@@ -8,44 +8,12 @@ import (
 // GlobalMaxPooling reduces each feature map to a single maximum value
 // Input: (H, W, C) -> Output: (1, 1, C) -> flattened to [C]
 func GlobalMaxPooling(input *tensor.FeatureMap) []float32 {
-    result := make([]float32, input.Channels)
-    
-    for c := 0; c < input.Channels; c++ {
-        maxVal := input.GetUnsafe(c, 0, 0)
-        
-        for h := 0; h < input.Height; h++ {
-            for w := 0; w < input.Width; w++ {
-                val := input.GetUnsafe(c, h, w)
-                if val > maxVal {
-                    maxVal = val
-                }
-            }
-        }
-        
-        result[c] = maxVal
-    }
-    
-    return result
+    return GlobalMaxPoolingOf(tensor.FeatureMapView(input))
 }
 
 // GlobalAvgPooling reduces each feature map to a single average value
 func GlobalAvgPooling(input *tensor.FeatureMap) []float32 {
-    result := make([]float32, input.Channels)
-    totalPixels := float32(input.Height * input.Width)
-    
-    for c := 0; c < input.Channels; c++ {
-        var sum float32
-        
-        for h := 0; h < input.Height; h++ {
-            for w := 0; w < input.Width; w++ {
-                sum += input.GetUnsafe(c, h, w)
-            }
-        }
-        
-        result[c] = sum / totalPixels
-    }
-    
-    return result
+    return GlobalAvgPoolingOf(tensor.FeatureMapView(input))
 }
 
 // GlobalMinPooling reduces each feature map to a single minimum value
@@ -163,7 +131,7 @@ func AdaptiveMaxPooling(input *tensor.FeatureMap, outputHeight, outputWidth int)
         panic("Output dimensions must be positive")
     }
     
-    output := tensor.NewFeatureMap(outputHeight, outputWidth, input.Channels)
+    output := tensor.NewFeatureMapWithLayout(outputHeight, outputWidth, input.Channels, input.Layout)
     
     for c := 0; c < input.Channels; c++ {
         for i := 0; i < outputHeight; i++ {
@@ -207,7 +175,7 @@ func AdaptiveAvgPooling(input *tensor.FeatureMap, outputHeight, outputWidth int)
         panic("Output dimensions must be positive")
     }
     
-    output := tensor.NewFeatureMap(outputHeight, outputWidth, input.Channels)
+    output := tensor.NewFeatureMapWithLayout(outputHeight, outputWidth, input.Channels, input.Layout)
     
     for c := 0; c < input.Channels; c++ {
         for i := 0; i < outputHeight; i++ {
@@ -101,7 +101,7 @@ func Top5Accuracy(predictions, labels []float32) float32 {
         panic("predictions and labels must have same length")
     }
     
-    top5Indices := ArgmaxTop5(predictions)
+    top5Indices := ArgmaxTopK(predictions, 5)
     trueClass := Argmax(labels)
     
     for _, predictedClass := range top5Indices {
@@ -100,6 +100,20 @@ func BatchNormalizeInPlace(fm *tensor.FeatureMap, params *BatchNormParams) {
     }
 }
 
+// BatchNormalizeBatch4D applies BatchNormalize to every image of a batch, into a new batch
+func BatchNormalizeBatch4D(batch *tensor.FeatureMapBatch, params *BatchNormParams) *tensor.FeatureMapBatch {
+    result := batch.Clone()
+    BatchNormalizeBatch4DInPlace(result, params)
+    return result
+}
+
+// BatchNormalizeBatch4DInPlace applies BatchNormalizeInPlace to every image of a batch
+func BatchNormalizeBatch4DInPlace(batch *tensor.FeatureMapBatch, params *BatchNormParams) {
+    for _, image := range batch.Images() {
+        BatchNormalizeInPlace(image, params)
+    }
+}
+
 // ComputeBatchStatistics computes mean and variance from a batch of feature maps
 // Useful for training (not needed for inference, but good for testing)
 func ComputeBatchStatistics(batch []*tensor.FeatureMap) ([]float32, []float32) {
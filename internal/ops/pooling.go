@@ -55,90 +55,31 @@ func Pooling2D(input *tensor.FeatureMap, config PoolingConfig) *tensor.FeatureMa
     outWidth := (input.Width-config.KernelSize)/config.Stride + 1
     
     // Create output feature map (same number of channels)
-    output := tensor.NewFeatureMap(outHeight, outWidth, input.Channels)
-    
-    // Perform pooling for each channel independently
-    for c := 0; c < input.Channels; c++ {
-        for i := 0; i < outHeight; i++ {
-            for j := 0; j < outWidth; j++ {
-                // Define pooling window boundaries
-                startH := i * config.Stride
-                endH := startH + config.KernelSize
-                startW := j * config.Stride
-                endW := startW + config.KernelSize
-                
-                // Apply pooling operation to this window
-                value := poolWindow(input, c, startH, endH, startW, endW, config.Type)
-                output.SetUnsafe(c, i, j, value)
-            }
-        }
-    }
-    
+    output := tensor.NewFeatureMapWithLayout(outHeight, outWidth, input.Channels, input.Layout)
+    pool2DInto(input, output, config)
     return output
 }
 
-// poolWindow applies pooling operation to a specific window
-func poolWindow(input *tensor.FeatureMap, channel, startH, endH, startW, endW int, poolType PoolingType) float32 {
-    switch poolType {
-    case MaxPooling:
-        return maxPoolWindow(input, channel, startH, endH, startW, endW)
-    case AvgPooling:
-        return avgPoolWindow(input, channel, startH, endH, startW, endW)
-    case MinPooling:
-        return minPoolWindow(input, channel, startH, endH, startW, endW)
-    default:
-        panic(fmt.Sprintf("Unknown pooling type: %d", poolType))
-    }
-}
-
-// maxPoolWindow finds the maximum value in a window
-func maxPoolWindow(input *tensor.FeatureMap, channel, startH, endH, startW, endW int) float32 {
-    maxVal := input.GetUnsafe(channel, startH, startW)
-    
-    for h := startH; h < endH; h++ {
-        for w := startW; w < endW; w++ {
-            val := input.GetUnsafe(channel, h, w)
-            if val > maxVal {
-                maxVal = val
-            }
-        }
-    }
-    
-    return maxVal
-}
-
-// avgPoolWindow calculates the average value in a window
-func avgPoolWindow(input *tensor.FeatureMap, channel, startH, endH, startW, endW int) float32 {
-    var sum float32
-    count := 0
-    
-    for h := startH; h < endH; h++ {
-        for w := startW; w < endW; w++ {
-            sum += input.GetUnsafe(channel, h, w)
-            count++
-        }
+// Pooling2DBatch4D performs Pooling2D on every image of a batch, into a new batch
+func Pooling2DBatch4D(input *tensor.FeatureMapBatch, config PoolingConfig) *tensor.FeatureMapBatch {
+    // Every image has the batch's shape
+    shape := &tensor.FeatureMap{Height: input.Height, Width: input.Width, Channels: input.Channels}
+    if err := validatePoolingInputs(shape, config); err != nil {
+        panic(fmt.Sprintf("Pooling validation failed: %v", err))
     }
     
-    if count > 0 {
-        return sum / float32(count)
+    outHeight := (input.Height-config.KernelSize)/config.Stride + 1
+    outWidth := (input.Width-config.KernelSize)/config.Stride + 1
+    output := tensor.NewFeatureMapBatchWithLayout(input.N, outHeight, outWidth, input.Channels, input.Layout)
+    for b, image := range input.Images() {
+        pool2DInto(image, output.Image(b), config)
     }
-    return 0
+    return output
 }
 
-// minPoolWindow finds the minimum value in a window
-func minPoolWindow(input *tensor.FeatureMap, channel, startH, endH, startW, endW int) float32 {
-    minVal := input.GetUnsafe(channel, startH, startW)
-    
-    for h := startH; h < endH; h++ {
-        for w := startW; w < endW; w++ {
-            val := input.GetUnsafe(channel, h, w)
-            if val < minVal {
-                minVal = val
-            }
-        }
-    }
-    
-    return minVal
+// pool2DInto pools input into output, which has the output dimensions
+func pool2DInto(input, output *tensor.FeatureMap, config PoolingConfig) {
+    pool2DIntoOf(tensor.FeatureMapView(input), tensor.FeatureMapView(output), config)
 }
 
 // validatePoolingInputs validates inputs for pooling operations
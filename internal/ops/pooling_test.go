@@ -205,6 +205,70 @@ func TestAdaptiveMaxPooling(t *testing.T) {
     }
 }
 
+func TestMaxPoolingHWC(t *testing.T) {
+    input := tensor.NewFeatureMap(6, 6, 3)
+    input.RandomFill()
+    
+    expected := MaxPooling2D(input, 2, 2)
+    output := MaxPooling2D(input.ToLayout(tensor.LayoutHWC), 2, 2)
+    
+    if output.Layout != tensor.LayoutHWC {
+        t.Fatalf("Expected HWC output, got %s", output.Layout)
+    }
+    for c := 0; c < 3; c++ {
+        for h := 0; h < 3; h++ {
+            for w := 0; w < 3; w++ {
+                if output.Get(c, h, w) != expected.Get(c, h, w) {
+                    t.Fatalf("Mismatch at (%d,%d,%d)", c, h, w)
+                }
+            }
+        }
+    }
+    
+    // GlobalMaxPooling reads through the accessors and is layout independent
+    global := GlobalMaxPooling(output)
+    reference := GlobalMaxPooling(expected)
+    for c := range reference {
+        if global[c] != reference[c] {
+            t.Errorf("Global max mismatch for channel %d", c)
+        }
+    }
+}
+
+func TestPooling2DBatch4D(t *testing.T) {
+    inputs := make([]*tensor.FeatureMap, 2)
+    for b := range inputs {
+        inputs[b] = tensor.NewFeatureMap(4, 6, 3)
+        inputs[b].RandomFill()
+    }
+    config := PoolingConfig{Type: MaxPooling, KernelSize: 2, Stride: 2}
+    
+    for _, layout := range []tensor.Layout{tensor.LayoutCHW, tensor.LayoutHWC} {
+        converted := make([]*tensor.FeatureMap, len(inputs))
+        for b, input := range inputs {
+            converted[b] = input.ToLayout(layout)
+        }
+        batch, _ := tensor.StackFeatureMaps(converted)
+        output := Pooling2DBatch4D(batch, config)
+        if output.N != 2 || output.Height != 2 || output.Width != 3 || output.Layout != layout {
+            t.Fatalf("%s: unexpected output %s", layout, output)
+        }
+        for b, input := range inputs {
+            expected := Pooling2D(input, config)
+            for c := 0; c < 3; c++ {
+                for h := 0; h < 2; h++ {
+                    for w := 0; w < 3; w++ {
+                        if output.Get(b, c, h, w) != expected.Get(c, h, w) {
+                            t.Fatalf("%s image %d: mismatch at (%d,%d,%d): expected %f, got %f", layout, b, c, h, w,
+                                expected.Get(c, h, w), output.Get(b, c, h, w))
+                        }
+                    }
+                }
+            }
+        }
+    }
+}
+
 func TestGetPoolingOutputDims(t *testing.T) {
     testCases := []struct {
         inputH, inputW, kernelSize, stride int
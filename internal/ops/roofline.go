@@ -0,0 +1,115 @@
+package ops
+
+import (
+	"time"
+)
+
+/**
+* Roofline model
+
+A layer's speed is capped either by arithmetic or by moving its data: a
+3×3 conv with many channels does hundreds of FLOPs per byte it reads and is
+compute-bound, a pooling layer does one comparison per value and waits on
+memory. The roofline puts both limits on one chart:
+```
+GFLOP/s
+  peak ┤            ┌──────────────────────  compute-bound
+       │          ╱ ·
+       │        ╱   ·
+       │      ╱     ·                       attainable = min(peak, intensity × bandwidth)
+       │    ╱  memory-bound
+       └──┴─────────┴──────────────────────  arithmetic intensity (FLOP/byte)
+                  ridge = peak / bandwidth
+```
+MeasurePeak finds both limits on this machine: the compute peak is the
+fastest algorithm on a large, cache-friendly convolution, and the bandwidth
+is a large copy that misses every cache. Both are what this code achieves,
+not the hardware's datasheet numbers, so layers can come close to them.
+*/
+
+// Shape whose convolution measures the compute peak: 75.5 MFLOPs on 256 KB of input
+var peakShape = ConvShape{Name: "peak", Height: 32, Width: 32, Channels: 64, KernelSize: 3, Filters: 64, Padding: 1, Stride: 1}
+
+// Size of the buffers copied to measure bandwidth, well beyond any cache
+const bandwidthBytes = 64 << 20
+
+// MachinePeak is the measured compute and memory limit of this machine
+type MachinePeak struct {
+    GFLOPS       float64 `json:"gflops"`        // Fastest convolution throughput
+    BandwidthGBs float64 `json:"bandwidth_gbs"` // Memory copy throughput, bytes read plus written
+}
+
+// RidgePoint returns the arithmetic intensity, in FLOPs per byte, above which a
+// layer is compute-bound rather than memory-bound
+func (p MachinePeak) RidgePoint() float64 {
+    if p.BandwidthGBs <= 0 {
+        return 0
+    }
+    return p.GFLOPS / p.BandwidthGBs
+}
+
+// Attainable returns the throughput the roofline allows at an arithmetic intensity
+func (p MachinePeak) Attainable(intensity float64) float64 {
+    return min(p.GFLOPS, intensity*p.BandwidthGBs)
+}
+
+// ConvolutionMACs returns the multiply-accumulates of a convolution: one per kernel
+// weight per output value
+func ConvolutionMACs(inputHeight, inputWidth, inputChannels int, kernelSize, kernelFilters int,
+    padding, stride int) int64 {
+
+    outHeight := (inputHeight+2*padding-kernelSize)/stride + 1
+    outWidth := (inputWidth+2*padding-kernelSize)/stride + 1
+    return int64(outHeight) * int64(outWidth) * int64(kernelFilters) *
+        int64(inputChannels) * int64(kernelSize) * int64(kernelSize)
+}
+
+// ConvolutionFLOPs returns the floating-point operations of a convolution, counting
+// a multiply-accumulate as two
+func ConvolutionFLOPs(inputHeight, inputWidth, inputChannels int, kernelSize, kernelFilters int,
+    padding, stride int) int64 {
+
+    return 2 * ConvolutionMACs(inputHeight, inputWidth, inputChannels, kernelSize, kernelFilters, padding, stride)
+}
+
+// MeasurePeak measures the compute peak of the engine's fastest algorithm and the
+// memory bandwidth of a large copy; it takes well under a second
+func (ce *ConvolutionEngine) MeasurePeak() MachinePeak {
+    _, timings := ce.benchmarkShape(peakShape)
+    var fastest time.Duration
+    for _, t := range timings {
+        if t > 0 && (fastest == 0 || t < fastest) {
+            fastest = t
+        }
+    }
+
+    peak := MachinePeak{BandwidthGBs: measureBandwidth()}
+    if fastest > 0 {
+        flops := ConvolutionFLOPs(peakShape.Height, peakShape.Width, peakShape.Channels,
+            peakShape.KernelSize, peakShape.Filters, peakShape.Padding, peakShape.Stride)
+        peak.GFLOPS = float64(flops) / fastest.Seconds() / 1e9
+    }
+    return peak
+}
+
+// measureBandwidth returns the best copy throughput over a few copies, in GB/s
+func measureBandwidth() float64 {
+    src := make([]float32, bandwidthBytes/4)
+    dst := make([]float32, bandwidthBytes/4)
+    for i := range src {
+        src[i] = float32(i)
+    }
+
+    var fastest time.Duration
+    for i := 0; i <= autotuneIterations; i++ {
+        start := time.Now()
+        copy(dst, src)
+        elapsed := time.Since(start)
+
+        // The first copy only faults the destination pages in
+        if i > 0 && (fastest == 0 || elapsed < fastest) {
+            fastest = elapsed
+        }
+    }
+    return 2 * bandwidthBytes / fastest.Seconds() / 1e9
+}
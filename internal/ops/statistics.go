@@ -2,7 +2,6 @@ package ops
 
 import (
 	"math"
-	"sort"
 )
 
 // Argmax returns the index of the maximum value
@@ -25,40 +24,33 @@ func Argmax(slice []float32) int {
     return maxIdx
 }
 
-// ArgmaxTop5 returns indices of top 5 maximum values
-func ArgmaxTop5(slice []float32) []int {
-    if len(slice) == 0 {
+// ArgmaxTopK returns the indices of the k largest values, largest first
+// Equal values keep their index order; a k beyond the slice returns every index.
+// Only the k best are kept while scanning, so a small k costs O(n·k), not a sort.
+func ArgmaxTopK(slice []float32, k int) []int {
+    k = min(max(k, 0), len(slice))
+    if k == 0 {
         return []int{}
     }
     
-    // Create pairs of (value, index)
-    type valueIndex struct {
-        value float32
-        index int
-    }
-    
-    pairs := make([]valueIndex, len(slice))
+    top := make([]int, 0, k)
     for i, val := range slice {
-        pairs[i] = valueIndex{val, i}
-    }
-    
-    // Sort by value (descending)
-    sort.Slice(pairs, func(i, j int) bool {
-        return pairs[i].value > pairs[j].value
-    })
-    
-    // Return top 5 indices
-    topK := 5
-    if len(pairs) < topK {
-        topK = len(pairs)
-    }
-    
-    result := make([]int, topK)
-    for i := 0; i < topK; i++ {
-        result[i] = pairs[i].index
+        if len(top) == k && val <= slice[top[k-1]] {
+            continue
+        }
+        // Insert after every kept value at least as large, so ties stay in index order
+        pos := len(top)
+        for pos > 0 && slice[top[pos-1]] < val {
+            pos--
+        }
+        if len(top) < k {
+            top = append(top, 0)
+        }
+        copy(top[pos+1:], top[pos:len(top)-1])
+        top[pos] = i
     }
     
-    return result
+    return top
 }
 
 // Max returns the maximum value in a slice
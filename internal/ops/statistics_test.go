@@ -25,6 +25,35 @@ func TestMean(t *testing.T) {
     }
 }
 
+func TestArgmaxTopK(t *testing.T) {
+    testCases := []struct {
+        input    []float32
+        k        int
+        expected []int
+    }{
+        {[]float32{0.1, 0.5, 0.2, 0.9}, 2, []int{3, 1}},
+        {[]float32{0.3, 0.3, 0.1, 0.3}, 3, []int{0, 1, 3}}, // Ties keep index order
+        {[]float32{0.3, 0.5, 0.3, 0.7, 0.3}, 3, []int{3, 1, 0}},
+        {[]float32{0.2, 0.8}, 5, []int{1, 0}},
+        {[]float32{0.2, 0.8}, 0, []int{}},
+        {[]float32{}, 3, []int{}},
+    }
+    
+    for _, tc := range testCases {
+        result := ArgmaxTopK(tc.input, tc.k)
+        if len(result) != len(tc.expected) {
+            t.Errorf("ArgmaxTopK(%v, %d) = %v, expected %v", tc.input, tc.k, result, tc.expected)
+            continue
+        }
+        for i := range result {
+            if result[i] != tc.expected[i] {
+                t.Errorf("ArgmaxTopK(%v, %d) = %v, expected %v", tc.input, tc.k, result, tc.expected)
+                break
+            }
+        }
+    }
+}
+
 func TestVariance(t *testing.T) {
     input := []float32{1.0, 2.0, 3.0, 4.0, 5.0}
     result := Variance(input)
@@ -0,0 +1,114 @@
+package porcelain
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+* Porcelain output
+
+The human-readable output of the CLIs changes whenever someone improves a
+message, lines up a column or adds a unit. Scripts that grep it break. With
+-porcelain every command prints only tab-separated records instead:
+
+    porcelain	1	gocnn-inference	1.0.0
+    prediction	airplane.bin	9	truck	0.95492357	1834521
+    probability	0	airplane	0.0001239
+
+The first field names the record, the rest are its values in a fixed order.
+New record kinds and new trailing fields may be added within a version;
+renaming, removing or reordering fields bumps Version, which the first
+record announces.
+
+Values are formatted the same way everywhere, independent of locale and of
+the human-readable formatting:
+  - floats use '.' and the shortest representation that round-trips
+  - durations are whole nanoseconds
+  - booleans are true/false
+  - tabs, newlines and backslashes in strings are escaped as \t, \n and \\
+*/
+
+// Version of the record format, printed in the first record
+const Version = 1
+
+// Writer prints porcelain records
+type Writer struct {
+    w   *bufio.Writer
+    err error
+}
+
+// NewWriter creates a writer printing records to w
+func NewWriter(w io.Writer) *Writer {
+    return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Begin prints the header record naming the format version and the tool
+func (pw *Writer) Begin(tool, version string) {
+    pw.Record("porcelain", Version, tool, version)
+}
+
+// Record prints one record; fields are formatted with Format
+func (pw *Writer) Record(kind string, fields ...any) {
+    if pw.err != nil {
+        return
+    }
+
+    var line strings.Builder
+    line.WriteString(Escape(kind))
+    for _, field := range fields {
+        line.WriteByte('\t')
+        line.WriteString(Format(field))
+    }
+    line.WriteByte('\n')
+
+    _, pw.err = pw.w.WriteString(line.String())
+}
+
+// Flush writes any buffered records and returns the first error seen
+func (pw *Writer) Flush() error {
+    if pw.err != nil {
+        return pw.err
+    }
+    return pw.w.Flush()
+}
+
+// Format converts a field value to its porcelain text
+func Format(v any) string {
+    switch value := v.(type) {
+    case nil:
+        return ""
+    case string:
+        return Escape(value)
+    case bool:
+        return strconv.FormatBool(value)
+    case int:
+        return strconv.Itoa(value)
+    case int64:
+        return strconv.FormatInt(value, 10)
+    case uint64:
+        return strconv.FormatUint(value, 10)
+    case float32:
+        return strconv.FormatFloat(float64(value), 'g', -1, 32)
+    case float64:
+        return strconv.FormatFloat(value, 'g', -1, 64)
+    case time.Duration:
+        return strconv.FormatInt(int64(value), 10)
+    case fmt.Stringer:
+        return Escape(value.String())
+    default:
+        return Escape(fmt.Sprint(value))
+    }
+}
+
+// escaper replaces the characters that would break a record
+var escaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// Escape makes s safe to use as a single field
+func Escape(s string) string {
+    return escaper.Replace(s)
+}
@@ -0,0 +1,48 @@
+package porcelain
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+    cases := []struct {
+        value    any
+        expected string
+    }{
+        {nil, ""},
+        {"truck", "truck"},
+        {"a\tb\nc\\d", `a\tb\nc\\d`},
+        {true, "true"},
+        {42, "42"},
+        {int64(-7), "-7"},
+        {float32(0.95492357), "0.95492357"},
+        {0.1, "0.1"},
+        {1e-9, "1e-09"},
+        {1500 * time.Microsecond, "1500000"},
+    }
+    for _, c := range cases {
+        if got := Format(c.value); got != c.expected {
+            t.Errorf("Format(%#v) = %q, expected %q", c.value, got, c.expected)
+        }
+    }
+}
+
+func TestWriter(t *testing.T) {
+    var buf bytes.Buffer
+    pw := NewWriter(&buf)
+    pw.Begin("gocnn-test", "1.0.0")
+    pw.Record("prediction", "my image.bin", 9, "truck", float32(0.5), 2*time.Millisecond)
+    pw.Record("empty")
+    if err := pw.Flush(); err != nil {
+        t.Fatalf("Flush failed: %v", err)
+    }
+
+    expected := "porcelain\t1\tgocnn-test\t1.0.0\n" +
+        "prediction\tmy image.bin\t9\ttruck\t0.5\t2000000\n" +
+        "empty\n"
+    if buf.String() != expected {
+        t.Errorf("Got %q, expected %q", buf.String(), expected)
+    }
+}
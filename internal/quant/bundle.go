@@ -0,0 +1,148 @@
+package quant
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// BundleManifestName is the file describing a quantized weight bundle
+const BundleManifestName = "quantization.json"
+
+// bundleVersion is bumped whenever the bundle layout changes
+// Version 2 stores kernels as quantized weight files in place of the float32
+// kernels, so a bundle directory loads like any weights directory.
+const bundleVersion = 2
+
+// LayerScales describes one quantized conv kernel in a bundle
+type LayerScales struct {
+    Layer       string    `json:"layer"`
+    Size        int       `json:"size"`
+    Channels    int       `json:"channels"`
+    Filters     int       `json:"filters"`
+    Granularity string    `json:"granularity"`
+    WeightFile  string    `json:"weight_file"` // Quantized weight file (see EncodeKernelFile)
+    Scales      []float32 `json:"scales"`
+    MSE         float64   `json:"mse"`       // Mean squared quantization error of the weights
+    MaxError    float32   `json:"max_error"` // Largest absolute quantization error
+}
+
+// Bundle is the manifest of a quantized weight bundle
+// The bundle directory holds the int8 kernels next to the float32 biases and
+// batch norm parameters copied from the source weights, in the layout of the
+// source weights directory.
+type Bundle struct {
+    Version           int               `json:"version"`
+    Granularity       string            `json:"granularity"`
+    SourceWeights     string            `json:"source_weights"` // Hash of the float weights that were quantized
+    CalibrationImages int               `json:"calibration_images"`
+    Layers            []LayerScales     `json:"layers"`
+    Activations       []ActivationRange `json:"activations"`
+}
+
+// NewBundle starts a bundle manifest
+func NewBundle(granularity Granularity) *Bundle {
+    return &Bundle{Version: bundleVersion, Granularity: granularity.String()}
+}
+
+// KernelError returns the mean squared and largest absolute error of qk against kernel
+func KernelError(kernel *tensor.Kernel, qk *QuantizedKernel) (float64, float32) {
+    restored := qk.ToKernel()
+
+    var sum float64
+    var maxErr float32
+    for i, w := range kernel.Weights {
+        diff := w - restored.Weights[i]
+        sum += float64(diff) * float64(diff)
+        if diff < 0 {
+            diff = -diff
+        }
+        if diff > maxErr {
+            maxErr = diff
+        }
+    }
+    if len(kernel.Weights) == 0 {
+        return 0, 0
+    }
+    return sum / float64(len(kernel.Weights)), maxErr
+}
+
+// AddKernel quantizes a conv layer's kernel, writes it into the bundle directory and records its scales
+func (b *Bundle) AddKernel(dir, layer string, kernel *tensor.Kernel) (*LayerScales, error) {
+    granularity, err := ParseGranularity(b.Granularity)
+    if err != nil {
+        return nil, err
+    }
+    qk, err := QuantizeKernel(kernel, granularity)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", layer, err)
+    }
+
+    entry := LayerScales{
+        Layer:       layer,
+        Size:        qk.Size,
+        Channels:    qk.Channels,
+        Filters:     qk.Filters,
+        Granularity: b.Granularity,
+        WeightFile:  layer + "/" + layer + "_weight.bin",
+        Scales:      qk.Scales,
+    }
+    entry.MSE, entry.MaxError = KernelError(kernel, qk)
+
+    if err := os.MkdirAll(filepath.Join(dir, layer), 0755); err != nil {
+        return nil, err
+    }
+    if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(entry.WeightFile)), EncodeKernelFile(qk), 0644); err != nil {
+        return nil, fmt.Errorf("failed to write %s: %w", entry.WeightFile, err)
+    }
+
+    b.Layers = append(b.Layers, entry)
+    return &b.Layers[len(b.Layers)-1], nil
+}
+
+// Write saves the manifest into the bundle directory
+func (b *Bundle) Write(dir string) error {
+    encoded, err := json.MarshalIndent(b, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.WriteFile(filepath.Join(dir, BundleManifestName), append(encoded, '\n'), 0644); err != nil {
+        return fmt.Errorf("failed to write bundle manifest: %w", err)
+    }
+    return nil
+}
+
+// ReadBundle loads the manifest of a bundle directory
+func ReadBundle(dir string) (*Bundle, error) {
+    encoded, err := os.ReadFile(filepath.Join(dir, BundleManifestName))
+    if err != nil {
+        return nil, fmt.Errorf("failed to read bundle manifest: %w", err)
+    }
+
+    var b Bundle
+    if err := json.Unmarshal(encoded, &b); err != nil {
+        return nil, fmt.Errorf("failed to parse bundle manifest: %w", err)
+    }
+    if b.Version != bundleVersion {
+        return nil, fmt.Errorf("unsupported bundle version %d", b.Version)
+    }
+    return &b, nil
+}
+
+// ReadKernel loads one quantized kernel of a bundle
+func ReadKernel(dir string, entry LayerScales) (*QuantizedKernel, error) {
+    raw, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(entry.WeightFile)))
+    if err != nil {
+        return nil, err
+    }
+    qk, err := DecodeKernelFile(raw, entry.Size, entry.Channels, entry.Filters)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", entry.WeightFile, err)
+    }
+    if qk.Granularity.String() != entry.Granularity {
+        return nil, fmt.Errorf("%s: file is %s, manifest says %s", entry.WeightFile, qk.Granularity, entry.Granularity)
+    }
+    return qk, nil
+}
@@ -0,0 +1,91 @@
+package quant
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+)
+
+/**
+* Calibration
+
+Weights are known ahead of time, but the range of each layer's activations
+depends on the inputs. Calibration runs the float model over a set of
+representative images and records the smallest and largest value every
+layer produced; the activation scale is then chosen so that range fits int8.
+
+Min/max is the simplest calibration rule. It never clips, so a single
+outlier image can stretch the range and waste precision; a few hundred
+ordinary images are usually enough to avoid that.
+*/
+
+// ActivationRange is the observed output range of one layer
+type ActivationRange struct {
+    Layer string  `json:"layer"`
+    Min   float32 `json:"min"`
+    Max   float32 `json:"max"`
+    Scale float32 `json:"scale"` // Symmetric int8 scale covering [Min, Max]
+}
+
+// Calibrator accumulates activation ranges over calibration images
+type Calibrator struct {
+    order   []string
+    ranges  map[string]*ActivationRange
+    samples int
+}
+
+// NewCalibrator creates an empty calibrator
+func NewCalibrator() *Calibrator {
+    return &Calibrator{ranges: make(map[string]*ActivationRange)}
+}
+
+// Observe widens the named layer's range to cover values
+// Layers are reported in the order they were first observed.
+func (c *Calibrator) Observe(layer string, values []float32) {
+    if len(values) == 0 {
+        return
+    }
+
+    r, ok := c.ranges[layer]
+    if !ok {
+        r = &ActivationRange{Layer: layer, Min: values[0], Max: values[0]}
+        c.ranges[layer] = r
+        c.order = append(c.order, layer)
+    }
+    for _, v := range values {
+        if v < r.Min {
+            r.Min = v
+        }
+        if v > r.Max {
+            r.Max = v
+        }
+    }
+}
+
+// ObserveFeatureMap widens the named layer's range to cover fm
+func (c *Calibrator) ObserveFeatureMap(layer string, fm *tensor.FeatureMap) {
+    c.Observe(layer, fm.Data)
+}
+
+// AddSample counts one calibration image
+func (c *Calibrator) AddSample() {
+    c.samples++
+}
+
+// Samples returns the number of calibration images seen
+func (c *Calibrator) Samples() int {
+    return c.samples
+}
+
+// Ranges returns the observed range and int8 scale of every layer
+func (c *Calibrator) Ranges() []ActivationRange {
+    ranges := make([]ActivationRange, len(c.order))
+    for i, layer := range c.order {
+        r := *c.ranges[layer]
+        bound := r.Max
+        if -r.Min > bound {
+            bound = -r.Min
+        }
+        r.Scale = ScaleFor(bound)
+        ranges[i] = r
+    }
+    return ranges
+}
@@ -0,0 +1,61 @@
+package quant
+
+import (
+	"fmt"
+	"strings"
+)
+
+/**
+* Dynamic activation quantization
+
+Static calibration fixes every layer's activation scale ahead of time from
+a set of representative images (see Calibrator). Without such a set, the
+scale can instead be measured on the fly: before an int8 conv layer runs,
+one pass over its input finds the min and max, and the symmetric scale that
+covers them is used for that input only.
+```
+scale = max(|min|, |max|) / 127     (per layer, per inference)
+```
+Every input uses its full int8 range, so nothing is clipped and no
+calibration data is needed. The price is the extra pass over each conv
+input (small next to the convolution itself) and outputs that depend on
+the input's own extremes: a single bright pixel coarsens the steps for the
+whole image, where a calibrated scale would have clipped it.
+*/
+
+// ActivationMode selects where int8 conv layers take their input scale from
+type ActivationMode int
+
+const (
+    StaticActivations  ActivationMode = iota // Calibrated scales; layers without one dequantize their kernel
+    DynamicActivations                       // Scale measured on every input at inference time
+)
+
+// String returns the config name of the mode
+func (m ActivationMode) String() string {
+    switch m {
+    case StaticActivations:
+        return "static"
+    case DynamicActivations:
+        return "dynamic"
+    default:
+        return fmt.Sprintf("ActivationMode(%d)", int(m))
+    }
+}
+
+// ParseActivationMode converts a config name such as "dynamic" to an ActivationMode
+// An empty name selects StaticActivations
+func ParseActivationMode(name string) (ActivationMode, error) {
+    switch strings.ToLower(strings.TrimSpace(name)) {
+    case "", "static", "calibrated":
+        return StaticActivations, nil
+    case "dynamic":
+        return DynamicActivations, nil
+    }
+    return StaticActivations, fmt.Errorf("unknown activation quantization %q (use static or dynamic)", name)
+}
+
+// DynamicScale returns the symmetric int8 scale covering the min and max of values
+func DynamicScale(values []float32) float32 {
+    return ScaleFor(maxAbs(values))
+}
@@ -0,0 +1,179 @@
+package quant
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+/**
+* Quantized weight files
+
+A quantized kernel is stored in the same place as its float32 file
+(convN/convN_weight.bin) so that a weights directory can mix both and the
+loader picks the decoding per file. Float files are bare arrays, so a
+quantized file starts with a magic number that no float32 export produces
+by accident, followed by a fixed header and the scales:
+
+    offset  size  field
+    0       4     magic "GQNT"
+    4       1     format version (1)
+    5       1     dtype (1 = int8)
+    6       1     granularity (1 = per-tensor, 2 = per-channel)
+    7       1     reserved, 0
+    8       4     number of values (uint32)
+    12      4     number of scales (uint32): 1, or one per filter
+    16      8*n   per scale: scale (float32), zero point (int32)
+    16+8n   ...   values, one byte each
+
+Everything is little-endian. Values are in the same [height][width]
+[channels][filters] order as float32 weight files, so an exporter only
+changes the element type. The zero point is part of the format for
+exporters that quantize asymmetrically; gocnn quantizes symmetrically and
+only reads files whose zero points are 0.
+*/
+
+// FileMagic starts every quantized weight file
+const FileMagic = "GQNT"
+
+// fileVersion is bumped whenever the file layout changes
+const fileVersion = 1
+
+// fileHeaderSize is the size of the fixed part of the header
+const fileHeaderSize = 16
+
+// DType is the element type of a quantized weight file
+type DType uint8
+
+const (
+    DTypeInt8 DType = 1 // Signed 8-bit values
+)
+
+// String returns the name of the dtype
+func (d DType) String() string {
+    switch d {
+    case DTypeInt8:
+        return "int8"
+    default:
+        return fmt.Sprintf("DType(%d)", int(d))
+    }
+}
+
+// IsQuantizedFile reports whether raw starts with the quantized weight file magic
+func IsQuantizedFile(raw []byte) bool {
+    return bytes.HasPrefix(raw, []byte(FileMagic))
+}
+
+// EncodeKernelFile serializes a quantized kernel, reordering its weights to file order
+func EncodeKernelFile(qk *QuantizedKernel) []byte {
+    scales := len(qk.Scales)
+    out := make([]byte, fileHeaderSize+8*scales+len(qk.Weights))
+
+    copy(out, FileMagic)
+    out[4] = fileVersion
+    out[5] = byte(DTypeInt8)
+    out[6] = byte(qk.Granularity)
+    binary.LittleEndian.PutUint32(out[8:], uint32(len(qk.Weights)))
+    binary.LittleEndian.PutUint32(out[12:], uint32(scales))
+
+    offset := fileHeaderSize
+    for _, s := range qk.Scales {
+        binary.LittleEndian.PutUint32(out[offset:], math.Float32bits(s))
+        binary.LittleEndian.PutUint32(out[offset+4:], 0) // Symmetric: zero point is always 0
+        offset += 8
+    }
+
+    // Kernel order [f][c][h][w] to file order [h][w][c][f]
+    values := out[offset:]
+    idx := 0
+    for h := 0; h < qk.Size; h++ {
+        for w := 0; w < qk.Size; w++ {
+            for c := 0; c < qk.Channels; c++ {
+                for f := 0; f < qk.Filters; f++ {
+                    values[idx] = byte(qk.Weights[qk.index(f, c, h, w)])
+                    idx++
+                }
+            }
+        }
+    }
+    return out
+}
+
+// DecodeKernelFile parses a quantized weight file holding a size×size kernel
+// with the given channels and filters
+func DecodeKernelFile(raw []byte, size, channels, filters int) (*QuantizedKernel, error) {
+    if !IsQuantizedFile(raw) {
+        return nil, fmt.Errorf("not a quantized weight file")
+    }
+    if len(raw) < fileHeaderSize {
+        return nil, fmt.Errorf("quantized weight file header is truncated (%d bytes)", len(raw))
+    }
+    if raw[4] != fileVersion {
+        return nil, fmt.Errorf("unsupported quantized weight file version %d", raw[4])
+    }
+    if dtype := DType(raw[5]); dtype != DTypeInt8 {
+        return nil, fmt.Errorf("unsupported quantized dtype %s", dtype)
+    }
+
+    granularity := Granularity(raw[6])
+    expectedScales := 0
+    switch granularity {
+    case PerTensor:
+        expectedScales = 1
+    case PerChannel:
+        expectedScales = filters
+    default:
+        return nil, fmt.Errorf("unsupported quantization granularity %s", granularity)
+    }
+
+    count := int(binary.LittleEndian.Uint32(raw[8:]))
+    scales := int(binary.LittleEndian.Uint32(raw[12:]))
+    if expected := size * size * channels * filters; count != expected {
+        return nil, fmt.Errorf("quantized weight file holds %d values, expected %d", count, expected)
+    }
+    if scales != expectedScales {
+        return nil, fmt.Errorf("%s quantized weight file has %d scales, expected %d", granularity, scales, expectedScales)
+    }
+    if expected := fileHeaderSize + 8*scales + count; len(raw) != expected {
+        return nil, fmt.Errorf("quantized weight file is %d bytes, expected %d", len(raw), expected)
+    }
+
+    qk := &QuantizedKernel{
+        Size:        size,
+        Channels:    channels,
+        Filters:     filters,
+        Granularity: granularity,
+        Weights:     make([]int8, count),
+        Scales:      make([]float32, scales),
+    }
+
+    offset := fileHeaderSize
+    for i := range qk.Scales {
+        scale := math.Float32frombits(binary.LittleEndian.Uint32(raw[offset:]))
+        zeroPoint := int32(binary.LittleEndian.Uint32(raw[offset+4:]))
+        if !(scale > 0) || math.IsInf(float64(scale), 0) {
+            return nil, fmt.Errorf("scale %d is %g, expected a positive finite value", i, scale)
+        }
+        if zeroPoint != 0 {
+            return nil, fmt.Errorf("scale %d has zero point %d; only symmetric quantization (zero point 0) is supported", i, zeroPoint)
+        }
+        qk.Scales[i] = scale
+        offset += 8
+    }
+
+    // File order [h][w][c][f] to kernel order [f][c][h][w]
+    values := raw[offset:]
+    idx := 0
+    for h := 0; h < size; h++ {
+        for w := 0; w < size; w++ {
+            for c := 0; c < channels; c++ {
+                for f := 0; f < filters; f++ {
+                    qk.Weights[qk.index(f, c, h, w)] = int8(values[idx])
+                    idx++
+                }
+            }
+        }
+    }
+    return qk, nil
+}
@@ -0,0 +1,308 @@
+package quant
+
+import (
+	"duchm1606/gocnn/internal/tensor"
+	"testing"
+)
+
+// skewedKernel returns a kernel whose first filter is 100x wider than the rest,
+// like a deep layer with a few large filters
+func skewedKernel() *tensor.Kernel {
+    kernel := tensor.NewKernel(3, 8, 16)
+    perFilter := 8 * 3 * 3
+    for f := 0; f < 16; f++ {
+        magnitude := float32(0.01)
+        if f == 0 {
+            magnitude = 1
+        }
+        for i := 0; i < perFilter; i++ {
+            kernel.Weights[f*perFilter+i] = magnitude * float32(i%13-6) / 6
+        }
+    }
+    return kernel
+}
+
+// squaredError returns the summed squared difference of two kernels
+func squaredError(a, b *tensor.Kernel) float64 {
+    var sum float64
+    for i := range a.Weights {
+        d := float64(a.Weights[i] - b.Weights[i])
+        sum += d * d
+    }
+    return sum
+}
+
+func TestQuantizeKernelPerTensor(t *testing.T) {
+    kernel := skewedKernel()
+    qk, err := QuantizeKernel(kernel, PerTensor)
+    if err != nil {
+        t.Fatalf("QuantizeKernel failed: %v", err)
+    }
+    if len(qk.Scales) != 1 {
+        t.Fatalf("Expected 1 scale, got %d", len(qk.Scales))
+    }
+    if qk.Scale(0) != qk.Scale(15) {
+        t.Error("All filters should share the per-tensor scale")
+    }
+    
+    // The largest weight maps onto QMax and error stays within half a step
+    restored := qk.ToKernel()
+    for i, w := range kernel.Weights {
+        if diff := w - restored.Weights[i]; diff > qk.Scales[0]/2 || diff < -qk.Scales[0]/2 {
+            t.Fatalf("Weight %d: %f restored as %f (scale %f)", i, w, restored.Weights[i], qk.Scales[0])
+        }
+    }
+}
+
+func TestQuantizeKernelPerChannel(t *testing.T) {
+    kernel := skewedKernel()
+    perTensor, _ := QuantizeKernel(kernel, PerTensor)
+    perChannel, err := QuantizeKernel(kernel, PerChannel)
+    if err != nil {
+        t.Fatalf("QuantizeKernel failed: %v", err)
+    }
+    if len(perChannel.Scales) != 16 {
+        t.Fatalf("Expected 16 scales, got %d", len(perChannel.Scales))
+    }
+    
+    // Every filter uses its full int8 range
+    perFilter := perChannel.FilterSize()
+    for f := 0; f < 16; f++ {
+        var peak int8
+        for _, q := range perChannel.Weights[f*perFilter : (f+1)*perFilter] {
+            if q > peak {
+                peak = q
+            }
+        }
+        if peak != QMax {
+            t.Errorf("Filter %d peaks at %d, expected %d", f, peak, QMax)
+        }
+    }
+    
+    // Small filters collapse to a few levels with a shared scale
+    tensorErr := squaredError(kernel, perTensor.ToKernel())
+    channelErr := squaredError(kernel, perChannel.ToKernel())
+    if channelErr >= tensorErr {
+        t.Errorf("Per-channel error %g should be below per-tensor error %g", channelErr, tensorErr)
+    }
+    
+    if perChannel.Bytes() != int64(kernel.TotalWeights())+16*4 {
+        t.Errorf("Unexpected storage size %d", perChannel.Bytes())
+    }
+}
+
+func TestQuantizeZeroFilter(t *testing.T) {
+    kernel := tensor.NewKernel(1, 1, 2)
+    kernel.Weights[1] = 0.5
+    
+    qk, err := QuantizeKernel(kernel, PerChannel)
+    if err != nil {
+        t.Fatalf("QuantizeKernel failed: %v", err)
+    }
+    restored := qk.ToKernel()
+    if restored.Weights[0] != 0 || restored.Weights[1] != 0.5 {
+        t.Errorf("Expected [0 0.5], got %v", restored.Weights)
+    }
+    
+    if _, err := QuantizeKernel(kernel, None); err == nil {
+        t.Error("Quantizing with granularity none should fail")
+    }
+}
+
+func TestQuantizeClamps(t *testing.T) {
+    if q := Quantize(10, 0.01); q != QMax {
+        t.Errorf("Expected clamp to %d, got %d", QMax, q)
+    }
+    if q := Quantize(-10, 0.01); q != -QMax {
+        t.Errorf("Expected clamp to %d, got %d", -QMax, q)
+    }
+}
+
+func TestQuantizeSliceMatchesQuantize(t *testing.T) {
+    values := []float32{0, 0.004, -0.004, 0.005, -0.005, 0.3, -0.77, 1.27, 5, -5}
+    got := make([]int8, len(values))
+    QuantizeSlice(got, values, 0.01)
+    for i, v := range values {
+        if expected := Quantize(v, 0.01); got[i] != expected {
+            t.Errorf("QuantizeSlice(%g) = %d, expected %d", v, got[i], expected)
+        }
+    }
+}
+
+func TestParseGranularity(t *testing.T) {
+    for name, expected := range map[string]Granularity{"": None, "none": None, "per-tensor": PerTensor, "Per-Channel": PerChannel} {
+        got, err := ParseGranularity(name)
+        if err != nil || got != expected {
+            t.Errorf("ParseGranularity(%q) = %v, %v; want %v", name, got, err, expected)
+        }
+    }
+    if _, err := ParseGranularity("per-row"); err == nil {
+        t.Error("ParseGranularity should reject unknown names")
+    }
+}
+
+func TestCalibratorRanges(t *testing.T) {
+    c := NewCalibrator()
+    c.Observe("conv1", []float32{0, 2, 1})
+    c.Observe("conv7", []float32{-4, 1})
+    c.AddSample()
+    c.Observe("conv1", []float32{3, 0.5})
+    c.AddSample()
+    
+    ranges := c.Ranges()
+    if c.Samples() != 2 || len(ranges) != 2 {
+        t.Fatalf("Expected 2 samples and 2 layers, got %d and %d", c.Samples(), len(ranges))
+    }
+    if ranges[0].Layer != "conv1" || ranges[0].Min != 0 || ranges[0].Max != 3 {
+        t.Errorf("Unexpected conv1 range %+v", ranges[0])
+    }
+    if ranges[0].Scale != 3.0/QMax {
+        t.Errorf("conv1 scale should map 3 onto %d, got %g", QMax, ranges[0].Scale)
+    }
+    
+    // The negative bound sets the scale when it is larger
+    if ranges[1].Scale != 4.0/QMax {
+        t.Errorf("conv7 scale should map -4 onto %d, got %g", -QMax, ranges[1].Scale)
+    }
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+    dir := t.TempDir()
+    kernel := skewedKernel()
+    
+    bundle := NewBundle(PerChannel)
+    bundle.Activations = []ActivationRange{{Layer: "conv1", Max: 2, Scale: 2.0 / QMax}}
+    entry, err := bundle.AddKernel(dir, "conv1", kernel)
+    if err != nil {
+        t.Fatalf("AddKernel failed: %v", err)
+    }
+    if entry.MSE <= 0 || entry.MaxError <= 0 {
+        t.Errorf("Expected a nonzero quantization error, got %+v", entry)
+    }
+    if err := bundle.Write(dir); err != nil {
+        t.Fatalf("Write failed: %v", err)
+    }
+    
+    loaded, err := ReadBundle(dir)
+    if err != nil {
+        t.Fatalf("ReadBundle failed: %v", err)
+    }
+    if len(loaded.Layers) != 1 || len(loaded.Activations) != 1 {
+        t.Fatalf("Unexpected bundle contents %+v", loaded)
+    }
+    
+    qk, err := ReadKernel(dir, loaded.Layers[0])
+    if err != nil {
+        t.Fatalf("ReadKernel failed: %v", err)
+    }
+    expected, _ := QuantizeKernel(kernel, PerChannel)
+    for i, q := range expected.Weights {
+        if qk.Weights[i] != q {
+            t.Fatalf("Weight %d: expected %d, got %d", i, q, qk.Weights[i])
+        }
+    }
+    for f, s := range expected.Scales {
+        if qk.Scales[f] != s {
+            t.Fatalf("Scale %d: expected %g, got %g", f, s, qk.Scales[f])
+        }
+    }
+}
+
+func TestKernelFileRoundTrip(t *testing.T) {
+    for _, granularity := range []Granularity{PerTensor, PerChannel} {
+        qk, err := QuantizeKernel(skewedKernel(), granularity)
+        if err != nil {
+            t.Fatalf("QuantizeKernel failed: %v", err)
+        }
+        
+        raw := EncodeKernelFile(qk)
+        if !IsQuantizedFile(raw) {
+            t.Fatal("Encoded file should start with the magic")
+        }
+        
+        decoded, err := DecodeKernelFile(raw, qk.Size, qk.Channels, qk.Filters)
+        if err != nil {
+            t.Fatalf("DecodeKernelFile(%s) failed: %v", granularity, err)
+        }
+        if decoded.Granularity != granularity || len(decoded.Scales) != len(qk.Scales) {
+            t.Fatalf("Decoded %s kernel has granularity %s and %d scales", granularity, decoded.Granularity, len(decoded.Scales))
+        }
+        for i, q := range qk.Weights {
+            if decoded.Weights[i] != q {
+                t.Fatalf("%s weight %d: expected %d, got %d", granularity, i, q, decoded.Weights[i])
+            }
+        }
+        if err := decoded.Validate(); err != nil {
+            t.Errorf("Decoded kernel failed validation: %v", err)
+        }
+    }
+}
+
+func TestKernelFileFileOrder(t *testing.T) {
+    // Values follow float32 weight files: [height][width][channels][filters], filters fastest
+    kernel := tensor.NewKernel(1, 1, 2)
+    kernel.Weights[0], kernel.Weights[1] = 1, -1
+    qk, _ := QuantizeKernel(kernel, PerTensor)
+    
+    raw := EncodeKernelFile(qk)
+    values := raw[len(raw)-2:]
+    if int8(values[0]) != QMax || int8(values[1]) != -QMax {
+        t.Errorf("Expected filter 0 then filter 1, got %v", values)
+    }
+}
+
+func TestDecodeKernelFileErrors(t *testing.T) {
+    qk, _ := QuantizeKernel(skewedKernel(), PerTensor)
+    raw := EncodeKernelFile(qk)
+    
+    if _, err := DecodeKernelFile(raw, 3, 8, 8); err == nil {
+        t.Error("Expected an error for the wrong shape")
+    }
+    if _, err := DecodeKernelFile(raw[:len(raw)-1], 3, 8, 16); err == nil {
+        t.Error("Expected an error for a truncated file")
+    }
+    if _, err := DecodeKernelFile([]byte{1, 2, 3, 4}, 3, 8, 16); err == nil {
+        t.Error("Expected an error for a file without the magic")
+    }
+    
+    asymmetric := append([]byte(nil), raw...)
+    asymmetric[fileHeaderSize+4] = 3 // Zero point of the only scale
+    if _, err := DecodeKernelFile(asymmetric, 3, 8, 16); err == nil {
+        t.Error("Expected an error for a nonzero zero point")
+    }
+    
+    wrongType := append([]byte(nil), raw...)
+    wrongType[5] = 7
+    if _, err := DecodeKernelFile(wrongType, 3, 8, 16); err == nil {
+        t.Error("Expected an error for an unknown dtype")
+    }
+}
+
+func TestParseActivationMode(t *testing.T) {
+    cases := map[string]ActivationMode{
+        "":        StaticActivations,
+        "static":  StaticActivations,
+        "Dynamic": DynamicActivations,
+    }
+    for name, expected := range cases {
+        mode, err := ParseActivationMode(name)
+        if err != nil || mode != expected {
+            t.Errorf("ParseActivationMode(%q) = %s, %v; expected %s", name, mode, err, expected)
+        }
+    }
+    if _, err := ParseActivationMode("per-image"); err == nil {
+        t.Error("Expected an error for an unknown mode")
+    }
+}
+
+func TestDynamicScale(t *testing.T) {
+    if scale := DynamicScale([]float32{-0.5, 0.25, 1.27}); scale != 1.27/QMax {
+        t.Errorf("Expected the scale to cover the largest magnitude, got %g", scale)
+    }
+    if scale := DynamicScale([]float32{-2.54, 1}); scale != 2.54/QMax {
+        t.Errorf("Expected a negative minimum to set the scale, got %g", scale)
+    }
+    if scale := DynamicScale(make([]float32, 4)); scale != 1 {
+        t.Errorf("Expected scale 1 for an all-zero input, got %g", scale)
+    }
+}
@@ -0,0 +1,286 @@
+package runinfo
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/ops"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+/**
+* Run manifests
+
+A benchmark number is only useful if it can be reproduced, which means
+knowing exactly what produced it: which binary (version and git commit),
+which config and weights (by content hash, not path - "weights/" on two
+machines is rarely the same data), which engine settings, and on what host.
+
+A Manifest gathers that once per run. The CLIs write it to run.json on
+request and embed it in every report they save, so a result file found
+months later still says how to reproduce it.
+
+The weights hash covers the sorted list of files under the weights directory
+together with each file's size and SHA-256, so renaming, adding or changing
+any file changes the hash.
+*/
+
+// Host describes the machine a run executed on
+type Host struct {
+    Hostname   string `json:"hostname"`
+    OS         string `json:"os"`
+    Arch       string `json:"arch"`
+    CPUModel   string `json:"cpu_model,omitempty"`
+    NumCPU     int    `json:"num_cpu"`
+    GOMAXPROCS int    `json:"gomaxprocs"`
+}
+
+// Manifest records everything needed to reproduce a CLI run
+type Manifest struct {
+    Tool      string    `json:"tool"`
+    Version   string    `json:"version"`
+    GitCommit string    `json:"git_commit"`          // "unknown" when the binary has no VCS stamp
+    GitDirty  bool      `json:"git_dirty,omitempty"` // Built from a tree with uncommitted changes
+    GoVersion string    `json:"go_version"`
+    Args      []string  `json:"args"`
+    StartedAt time.Time `json:"started_at"`
+
+    ConfigPath  string `json:"config_path,omitempty"`
+    ConfigHash  string `json:"config_hash,omitempty"`
+    WeightsPath string `json:"weights_path,omitempty"`
+    WeightsHash string `json:"weights_hash,omitempty"`
+    WeightFiles int    `json:"weight_files,omitempty"`
+
+    Engine                 ops.EngineOptions `json:"engine"`
+    Precision              string            `json:"precision,omitempty"`
+    WeightQuantization     string            `json:"weight_quantization,omitempty"`
+    Int8ConvLayers         int               `json:"int8_conv_layers,omitempty"`        // Conv layers on int8 activations
+    ActivationQuantization string            `json:"activation_quantization,omitempty"` // static or dynamic; set with Int8ConvLayers
+
+    Host  Host              `json:"host"`
+    Seeds map[string]uint64 `json:"seeds"` // Random seeds by purpose; empty when the run drew no random numbers
+}
+
+// New starts a manifest for the named tool, filling in build, host and command line details
+func New(tool, version string) *Manifest {
+    m := &Manifest{
+        Tool:      tool,
+        Version:   version,
+        GitCommit: "unknown",
+        GoVersion: runtime.Version(),
+        Args:      os.Args[1:],
+        StartedAt: time.Now(),
+        Host:      currentHost(),
+        Seeds:     make(map[string]uint64),
+    }
+
+    if info, ok := debug.ReadBuildInfo(); ok {
+        for _, setting := range info.Settings {
+            switch setting.Key {
+            case "vcs.revision":
+                m.GitCommit = setting.Value
+            case "vcs.modified":
+                m.GitDirty = setting.Value == "true"
+            }
+        }
+    }
+    return m
+}
+
+// SetConfig records the config file and its content hash
+func (m *Manifest) SetConfig(path string) error {
+    hash, err := HashFile(path)
+    if err != nil {
+        return fmt.Errorf("failed to hash config: %w", err)
+    }
+    m.ConfigPath = path
+    m.ConfigHash = hash
+    return nil
+}
+
+// SetWeights records the weights directory and the hash of its file manifest
+func (m *Manifest) SetWeights(dir string) error {
+    hash, files, err := HashWeights(dir)
+    if err != nil {
+        return fmt.Errorf("failed to hash weights: %w", err)
+    }
+    m.WeightsPath = dir
+    m.WeightsHash = hash
+    m.WeightFiles = files
+    return nil
+}
+
+// SetModel records the engine, precision and quantization a loaded model runs with
+func (m *Manifest) SetModel(info *model.ModelInfo) {
+    m.Engine = info.Engine
+    m.Precision = info.Precision.String()
+    m.WeightQuantization = info.WeightQuantization.String()
+    m.Int8ConvLayers = info.Int8ConvLayers
+    if info.Int8ConvLayers > 0 {
+        m.ActivationQuantization = info.ActivationQuantization.String()
+    }
+}
+
+// SetSeed records the seed used for one source of randomness
+func (m *Manifest) SetSeed(purpose string, seed uint64) {
+    m.Seeds[purpose] = seed
+}
+
+// ShortCommit returns the first 12 characters of the git commit, with "+dirty" if modified
+func (m *Manifest) ShortCommit() string {
+    commit := m.GitCommit
+    if len(commit) > 12 {
+        commit = commit[:12]
+    }
+    if m.GitDirty {
+        commit += "+dirty"
+    }
+    return commit
+}
+
+// Summary returns a one-line description for text reports
+func (m *Manifest) Summary() string {
+    return fmt.Sprintf("%s %s (commit %s, %s), config %s, weights %s",
+        m.Tool, m.Version, m.ShortCommit(), m.GoVersion, shortHash(m.ConfigHash), shortHash(m.WeightsHash))
+}
+
+// HostSummary returns a one-line description of the host
+func (m *Manifest) HostSummary() string {
+    cpu := m.Host.CPUModel
+    if cpu == "" {
+        cpu = "unknown CPU"
+    }
+    return fmt.Sprintf("%s (%s/%s, %s, %d CPUs, GOMAXPROCS=%d)",
+        m.Host.Hostname, m.Host.OS, m.Host.Arch, cpu, m.Host.NumCPU, m.Host.GOMAXPROCS)
+}
+
+// Write saves the manifest as indented JSON
+func (m *Manifest) Write(path string) error {
+    encoded, err := json.MarshalIndent(m, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.WriteFile(path, append(encoded, '\n'), 0644); err != nil {
+        return fmt.Errorf("failed to write run manifest: %w", err)
+    }
+    return nil
+}
+
+// Read loads a manifest written by Write
+func Read(path string) (*Manifest, error) {
+    encoded, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read run manifest: %w", err)
+    }
+
+    var m Manifest
+    if err := json.Unmarshal(encoded, &m); err != nil {
+        return nil, fmt.Errorf("failed to parse run manifest: %w", err)
+    }
+    return &m, nil
+}
+
+// HashFile returns "sha256:<hex>" of a file's contents
+func HashFile(path string) (string, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer file.Close()
+
+    h := sha256.New()
+    if _, err := io.Copy(h, file); err != nil {
+        return "", err
+    }
+    return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashWeights hashes the file manifest of a weights directory and returns it with the file count
+// Each regular file contributes "path size sha256" (path relative to dir, slash separated).
+func HashWeights(dir string) (string, int, error) {
+    var files []string
+    err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if d.Type().IsRegular() {
+            files = append(files, path)
+        }
+        return nil
+    })
+    if err != nil {
+        return "", 0, err
+    }
+    sort.Strings(files)
+
+    manifest := sha256.New()
+    for _, path := range files {
+        info, err := os.Stat(path)
+        if err != nil {
+            return "", 0, err
+        }
+        hash, err := HashFile(path)
+        if err != nil {
+            return "", 0, err
+        }
+        rel, err := filepath.Rel(dir, path)
+        if err != nil {
+            return "", 0, err
+        }
+        fmt.Fprintf(manifest, "%s %d %s\n", filepath.ToSlash(rel), info.Size(), hash)
+    }
+    return "sha256:" + hex.EncodeToString(manifest.Sum(nil)), len(files), nil
+}
+
+// shortHash abbreviates "sha256:<hex>" for display
+func shortHash(hash string) string {
+    if hash == "" {
+        return "-"
+    }
+    if len(hash) > len("sha256:")+12 {
+        return hash[:len("sha256:")+12]
+    }
+    return hash
+}
+
+// currentHost describes the machine this process runs on
+func currentHost() Host {
+    hostname, _ := os.Hostname()
+    return Host{
+        Hostname:   hostname,
+        OS:         runtime.GOOS,
+        Arch:       runtime.GOARCH,
+        CPUModel:   cpuModel(),
+        NumCPU:     runtime.NumCPU(),
+        GOMAXPROCS: runtime.GOMAXPROCS(0),
+    }
+}
+
+// cpuModel reads the CPU model name from /proc/cpuinfo; it is empty on other systems
+func cpuModel() string {
+    file, err := os.Open("/proc/cpuinfo")
+    if err != nil {
+        return ""
+    }
+    defer file.Close()
+
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        key, value, found := strings.Cut(scanner.Text(), ":")
+        if found && strings.TrimSpace(key) == "model name" {
+            return strings.TrimSpace(value)
+        }
+    }
+    return ""
+}
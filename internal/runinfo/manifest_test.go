@@ -0,0 +1,108 @@
+package runinfo
+
+import (
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/quant"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+    t.Helper()
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestHashWeights(t *testing.T) {
+    dir := t.TempDir()
+    writeFile(t, filepath.Join(dir, "conv1", "kernel.bin"), "abcd")
+    writeFile(t, filepath.Join(dir, "conv1", "bias.bin"), "ef")
+    
+    first, files, err := HashWeights(dir)
+    if err != nil {
+        t.Fatalf("HashWeights failed: %v", err)
+    }
+    if files != 2 || !strings.HasPrefix(first, "sha256:") {
+        t.Fatalf("Unexpected result %s over %d files", first, files)
+    }
+    
+    // The same content elsewhere hashes the same
+    other := t.TempDir()
+    writeFile(t, filepath.Join(other, "conv1", "bias.bin"), "ef")
+    writeFile(t, filepath.Join(other, "conv1", "kernel.bin"), "abcd")
+    if copied, _, _ := HashWeights(other); copied != first {
+        t.Error("Identical weight directories should hash the same")
+    }
+    
+    // Changing content or names changes the hash
+    writeFile(t, filepath.Join(other, "conv1", "bias.bin"), "eg")
+    if changed, _, _ := HashWeights(other); changed == first {
+        t.Error("Changed file content should change the hash")
+    }
+    os.Rename(filepath.Join(dir, "conv1", "bias.bin"), filepath.Join(dir, "conv1", "bias2.bin"))
+    if renamed, _, _ := HashWeights(dir); renamed == first {
+        t.Error("Renamed file should change the hash")
+    }
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+    dir := t.TempDir()
+    configPath := filepath.Join(dir, "model.yaml")
+    writeFile(t, configPath, "model: {}\n")
+    writeFile(t, filepath.Join(dir, "weights", "conv1", "kernel.bin"), "1234")
+    
+    m := New("gocnn-test", "1.2.3")
+    if err := m.SetConfig(configPath); err != nil {
+        t.Fatalf("SetConfig failed: %v", err)
+    }
+    if err := m.SetWeights(filepath.Join(dir, "weights")); err != nil {
+        t.Fatalf("SetWeights failed: %v", err)
+    }
+    m.SetSeed("shuffle", 42)
+    
+    if m.Host.NumCPU == 0 || m.GoVersion == "" || m.GitCommit == "" {
+        t.Errorf("Build and host details missing: %+v", m)
+    }
+    if !strings.Contains(m.Summary(), "gocnn-test 1.2.3") {
+        t.Errorf("Summary should name the tool and version: %s", m.Summary())
+    }
+    
+    path := filepath.Join(dir, "run.json")
+    if err := m.Write(path); err != nil {
+        t.Fatalf("Write failed: %v", err)
+    }
+    loaded, err := Read(path)
+    if err != nil {
+        t.Fatalf("Read failed: %v", err)
+    }
+    if loaded.ConfigHash != m.ConfigHash || loaded.WeightsHash != m.WeightsHash || loaded.WeightFiles != 1 {
+        t.Errorf("Hashes not preserved: %+v", loaded)
+    }
+    if loaded.Seeds["shuffle"] != 42 {
+        t.Errorf("Seed not preserved: %v", loaded.Seeds)
+    }
+    
+    if err := m.SetConfig(filepath.Join(dir, "missing.yaml")); err == nil {
+        t.Error("SetConfig should fail for a missing file")
+    }
+}
+
+func TestManifestSetModel(t *testing.T) {
+    m := New("gocnn-test", "1.2.3")
+    m.SetModel(&model.ModelInfo{WeightQuantization: quant.PerChannel})
+    if m.Precision != "float32" || m.WeightQuantization != quant.PerChannel.String() || m.ActivationQuantization != "" {
+        t.Errorf("Unexpected model details: %+v", m)
+    }
+
+    m.SetModel(&model.ModelInfo{WeightQuantization: quant.PerChannel, Int8ConvLayers: 2,
+        ActivationQuantization: quant.DynamicActivations})
+    if m.Int8ConvLayers != 2 || m.ActivationQuantization != quant.DynamicActivations.String() {
+        t.Errorf("Int8 activation details missing: %+v", m)
+    }
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+/**
+* Request audit log
+
+To analyze production predictions offline - drift in the predicted classes,
+confidence over time, slow requests, the same image sent again and again -
+every prediction is appended as one JSON line:
+```
+{"time":"2026-10-16T09:12:03.52Z","request_id":"9f2c41d07ab35e18","endpoint":"predict",
+ "model":"cifar10","client":"10.0.0.7","input_sha256":"3b9e...","input_bytes":12288,
+ "status":200,"predicted_class":6,"class_name":"frog","confidence":0.9998,"latency_ms":3.41}
+```
+The request ID comes from the client's X-Request-ID header when it sends a
+usable one, so its logs join with ours, and is generated otherwise; either
+way it is echoed in the response's X-Request-ID. Images are not stored,
+only their SHA-256, which identifies repeats without keeping user data.
+Stream frames are logged one by one as "<connection id>-<frame>".
+
+The file is rotated by size, like logrotate's numbered scheme:
+```
+audit.jsonl  ──(exceeds MaxBytes)──>  audit.jsonl.1 ──> audit.jsonl.2 ──> ... audit.jsonl.<MaxFiles> (deleted)
+```
+*/
+
+// Audit log defaults
+const (
+    defaultAuditMaxBytes = 100 << 20
+    defaultAuditMaxFiles = 5
+    maxRequestIDLength   = 128
+)
+
+// AuditOptions configures an AuditLog
+type AuditOptions struct {
+    Path     string // File the entries are appended to
+    MaxBytes int64  // Size at which the file is rotated (default 100 MB)
+    MaxFiles int    // Rotated files kept besides the current one (default 5)
+}
+
+// AuditEntry is one line of the audit log
+type AuditEntry struct {
+    Time           time.Time `json:"time"`
+    RequestID      string    `json:"request_id"`
+    Endpoint       string    `json:"endpoint"`
+    Model          string    `json:"model,omitempty"`
+    Client         string    `json:"client"`
+    InputSHA256    string    `json:"input_sha256,omitempty"`
+    InputBytes     int       `json:"input_bytes"`
+    Status         int       `json:"status"`
+    PredictedClass int       `json:"predicted_class"` // -1 when no prediction was made
+    ClassName      string    `json:"class_name,omitempty"`
+    Confidence     float32   `json:"confidence"`
+    LatencyMs      float64   `json:"latency_ms"`
+    Error          string    `json:"error,omitempty"`
+}
+
+// newAuditEntry starts the entry of a request to endpoint, taking or making its request ID
+func newAuditEntry(r *http.Request, endpoint string) *AuditEntry {
+    id := r.Header.Get("X-Request-ID")
+    if !validRequestID(id) {
+        id = newRequestID()
+    }
+    return &AuditEntry{
+        Time:           time.Now(),
+        RequestID:      id,
+        Endpoint:       endpoint,
+        Client:         clientID(r),
+        PredictedClass: -1,
+    }
+}
+
+// validRequestID reports whether a client's request ID is short printable ASCII
+func validRequestID(id string) bool {
+    if id == "" || len(id) > maxRequestIDLength {
+        return false
+    }
+    for i := 0; i < len(id); i++ {
+        if id[i] < 0x21 || id[i] > 0x7e {
+            return false
+        }
+    }
+    return true
+}
+
+// newRequestID returns 16 random hex digits
+func newRequestID() string {
+    var id [8]byte
+    rand.Read(id[:])
+    return hex.EncodeToString(id[:])
+}
+
+// setInput records the hash and size of the request's image
+func (e *AuditEntry) setInput(raw []byte) {
+    digest := sha256.Sum256(raw)
+    e.InputSHA256 = hex.EncodeToString(digest[:])
+    e.InputBytes = len(raw)
+}
+
+// setResult records the answer sent for the request
+func (e *AuditEntry) setResult(response *predictResponse) {
+    e.PredictedClass = response.PredictedClass
+    e.ClassName = response.ClassName
+    e.Confidence = response.Confidence
+}
+
+// auditWriter is a ResponseWriter that records the status and error of a response
+type auditWriter struct {
+    http.ResponseWriter
+    entry *AuditEntry
+}
+
+func (w *auditWriter) WriteHeader(status int) {
+    w.entry.Status = status
+    w.ResponseWriter.WriteHeader(status)
+}
+
+// AuditLog appends AuditEntry lines to a size-rotated file
+// It is safe for concurrent use.
+type AuditLog struct {
+    options AuditOptions
+    metrics *Metrics // Optional; counts entries that could not be written
+    mu      sync.Mutex
+    file    *os.File
+    size    int64
+}
+
+// NewAuditLog opens (or creates) the audit log at options.Path for appending,
+// counting failed writes in metrics if not nil
+func NewAuditLog(options AuditOptions, metrics *Metrics) (*AuditLog, error) {
+    if options.Path == "" {
+        return nil, fmt.Errorf("audit log path is required")
+    }
+    if options.MaxBytes <= 0 {
+        options.MaxBytes = defaultAuditMaxBytes
+    }
+    if options.MaxFiles <= 0 {
+        options.MaxFiles = defaultAuditMaxFiles
+    }
+
+    l := &AuditLog{options: options, metrics: metrics}
+    if err := l.open(); err != nil {
+        return nil, err
+    }
+    return l, nil
+}
+
+// open opens the current file and notes its size
+func (l *AuditLog) open() error {
+    file, err := os.OpenFile(l.options.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+    if err != nil {
+        return fmt.Errorf("failed to open audit log: %w", err)
+    }
+    info, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return fmt.Errorf("failed to open audit log: %w", err)
+    }
+    l.file = file
+    l.size = info.Size()
+    return nil
+}
+
+// Record appends entry, finishing its latency, rotating the file first if it would
+// grow past MaxBytes; l may be nil, which records nothing. Failures are counted
+// in the metrics.
+func (l *AuditLog) Record(entry *AuditEntry) error {
+    if l == nil {
+        return nil
+    }
+    err := l.record(entry)
+    if err != nil {
+        l.metrics.countAuditError()
+    }
+    return err
+}
+
+// record appends entry to the file
+func (l *AuditLog) record(entry *AuditEntry) error {
+    if entry.LatencyMs == 0 {
+        entry.LatencyMs = float64(time.Since(entry.Time).Microseconds()) / 1000
+    }
+    line, err := json.Marshal(entry)
+    if err != nil {
+        return err
+    }
+    line = append(line, '\n')
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.file == nil {
+        return fmt.Errorf("audit log is closed")
+    }
+    if l.size > 0 && l.size+int64(len(line)) > l.options.MaxBytes {
+        if err := l.rotate(); err != nil {
+            return err
+        }
+    }
+    n, err := l.file.Write(line)
+    l.size += int64(n)
+    return err
+}
+
+// rotate shifts path.i to path.i+1, dropping the oldest, moves the current file to
+// path.1 and starts a new one
+func (l *AuditLog) rotate() error {
+    if err := l.file.Close(); err != nil {
+        return fmt.Errorf("failed to rotate audit log: %w", err)
+    }
+    l.file = nil
+
+    path := l.options.Path
+    os.Remove(fmt.Sprintf("%s.%d", path, l.options.MaxFiles))
+    for i := l.options.MaxFiles - 1; i >= 1; i-- {
+        os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+    }
+    if err := os.Rename(path, path+".1"); err != nil {
+        return fmt.Errorf("failed to rotate audit log: %w", err)
+    }
+    return l.open()
+}
+
+// Close flushes and closes the file; later Records fail
+func (l *AuditLog) Close() error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.file == nil {
+        return nil
+    }
+    err := l.file.Close()
+    l.file = nil
+    return err
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readAudit returns the entries of the audit log at path
+func readAudit(t *testing.T, path string) []AuditEntry {
+    t.Helper()
+    file, err := os.Open(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer file.Close()
+
+    var entries []AuditEntry
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        var entry AuditEntry
+        if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+            t.Fatalf("Invalid audit line %q: %v", scanner.Text(), err)
+        }
+        entries = append(entries, entry)
+    }
+    return entries
+}
+
+func TestAPIAuditLog(t *testing.T) {
+    registry := NewRegistry()
+    servedModel(t, registry, "only", 2)
+    path := filepath.Join(t.TempDir(), "audit.jsonl")
+    audit, err := NewAuditLog(AuditOptions{Path: path}, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    api := NewAPI(registry)
+    api.SetAuditLog(audit)
+    mux := http.NewServeMux()
+    api.Register(mux)
+
+    image := binaryImage()
+    request := httptest.NewRequest("POST", "/v1/predict", bytes.NewReader(image))
+    request.Header.Set("X-Request-ID", "client-42")
+    recorder := httptest.NewRecorder()
+    mux.ServeHTTP(recorder, request)
+    if recorder.Code != http.StatusOK || recorder.Header().Get("X-Request-ID") != "client-42" {
+        t.Fatalf("Expected 200 echoing the request ID, got %d %q", recorder.Code, recorder.Header().Get("X-Request-ID"))
+    }
+
+    // A malformed request ID is replaced by a generated one
+    request = httptest.NewRequest("POST", "/v1/predict", bytes.NewReader(image[:10]))
+    request.Header.Set("X-Request-ID", "has space")
+    recorder = httptest.NewRecorder()
+    mux.ServeHTTP(recorder, request)
+    generated := recorder.Header().Get("X-Request-ID")
+    if recorder.Code != http.StatusBadRequest || len(generated) != 16 {
+        t.Fatalf("Expected 400 with a generated request ID, got %d %q", recorder.Code, generated)
+    }
+
+    server := httptest.NewServer(mux)
+    client := dialStream(t, server, "/v1/stream")
+    client.send(t, true, opBinary, image)
+    client.receive(t)
+    client.send(t, true, opClose, nil)
+    client.receive(t)
+    server.Close()
+    if err := audit.Close(); err != nil {
+        t.Fatal(err)
+    }
+
+    entries := readAudit(t, path)
+    if len(entries) != 3 {
+        t.Fatalf("Expected 3 audit entries, got %d: %+v", len(entries), entries)
+    }
+    digest := sha256.Sum256(image)
+    ok := entries[0]
+    if ok.RequestID != "client-42" || ok.Endpoint != "predict" || ok.Model != "only" || ok.Status != http.StatusOK ||
+        ok.InputSHA256 != hex.EncodeToString(digest[:]) || ok.InputBytes != len(image) ||
+        ok.PredictedClass != 2 || ok.ClassName != "frog" || ok.Confidence != 0.8 || ok.LatencyMs <= 0 {
+        t.Errorf("Unexpected entry of a prediction: %+v", ok)
+    }
+    failed := entries[1]
+    if failed.RequestID != generated || failed.Status != http.StatusBadRequest || failed.PredictedClass != -1 || failed.Error == "" {
+        t.Errorf("Unexpected entry of a failed request: %+v", failed)
+    }
+    frame := entries[2]
+    if frame.Endpoint != "stream" || len(frame.RequestID) != 18 || frame.RequestID[16:] != "-0" ||
+        frame.Status != http.StatusOK || frame.PredictedClass != 2 {
+        t.Errorf("Unexpected entry of a stream frame: %+v", frame)
+    }
+}
+
+func TestAuditLogRotation(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "audit.jsonl")
+    audit, err := NewAuditLog(AuditOptions{Path: path, MaxBytes: 300, MaxFiles: 2}, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer audit.Close()
+
+    // Each entry is about 200 bytes, so every one after the first rotates
+    for i := 0; i < 4; i++ {
+        entry := &AuditEntry{Time: time.Now(), RequestID: string(rune('a' + i)), Endpoint: "predict", PredictedClass: -1, LatencyMs: 1}
+        if err := audit.Record(entry); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    for suffix, id := range map[string]string{"": "d", ".1": "c", ".2": "b"} {
+        entries := readAudit(t, path+suffix)
+        if len(entries) != 1 || entries[0].RequestID != id {
+            t.Errorf("Expected %s to hold entry %q, got %+v", path+suffix, id, entries)
+        }
+    }
+    if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+        t.Errorf("Only 2 rotated files should be kept, stat of a third: %v", err)
+    }
+}
+
+func TestAuditLogWriteErrors(t *testing.T) {
+    registry := NewRegistry()
+    servedModel(t, registry, "only", 2)
+    metrics := NewMetrics()
+    audit, err := NewAuditLog(AuditOptions{Path: filepath.Join(t.TempDir(), "audit.jsonl")}, metrics)
+    if err != nil {
+        t.Fatal(err)
+    }
+    api := NewAPI(registry)
+    api.SetAuditLog(audit)
+    mux := http.NewServeMux()
+    api.Register(mux)
+
+    // A closed log fails every write; the prediction is still answered
+    audit.Close()
+    recorder := httptest.NewRecorder()
+    mux.ServeHTTP(recorder, httptest.NewRequest("POST", "/v1/predict", bytes.NewReader(binaryImage())))
+    if recorder.Code != http.StatusOK {
+        t.Fatalf("Expected 200, got %d", recorder.Code)
+    }
+
+    var out bytes.Buffer
+    metrics.WriteTo(&out)
+    if !bytes.Contains(out.Bytes(), []byte("gocnn_audit_errors_total 1\n")) {
+        t.Errorf("Expected one audit error in the metrics, got:\n%s", out.String())
+    }
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+/**
+* API-key authentication
+
+Once the server listens beyond localhost anyone who can reach it can use
+the CPU it runs on. APIKeys admits only requests that present one of the
+configured keys, in either of the usual headers:
+```
+Authorization: Bearer <key>
+X-API-Key: <key>
+```
+Browsers can't set headers on a WebSocket, so an upgrade request may pass
+the key as ?access_token=<key> instead. Keys travel in clear text without
+TLS; serve with a certificate when they guard anything of value.
+
+Keys are compared as SHA-256 digests in constant time, so neither the
+comparison time nor an early exit tells an attacker how much of a guess
+was right. The probe paths stay open, since an orchestrator checking
+liveness holds no key.
+*/
+
+// APIKeys authenticates requests by API key
+type APIKeys struct {
+    digests [][sha256.Size]byte
+    open    map[string]bool // Paths served without a key
+}
+
+// NewAPIKeys accepts the given keys; blank keys are ignored
+func NewAPIKeys(keys []string) (*APIKeys, error) {
+    a := &APIKeys{open: map[string]bool{"/healthz": true, "/readyz": true}}
+    for _, key := range keys {
+        key = strings.TrimSpace(key)
+        if key == "" {
+            continue
+        }
+        a.digests = append(a.digests, sha256.Sum256([]byte(key)))
+    }
+    if len(a.digests) == 0 {
+        return nil, fmt.Errorf("no API keys given")
+    }
+    return a, nil
+}
+
+// ReadAPIKeys reads one key per line from path, skipping blank lines and # comments
+func ReadAPIKeys(path string) ([]string, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read API keys: %w", err)
+    }
+    defer file.Close()
+
+    var keys []string
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        keys = append(keys, line)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("failed to read API keys: %w", err)
+    }
+    return keys, nil
+}
+
+// Wrap returns a handler that answers 401 to requests without a valid key and
+// passes the others to next
+func (a *APIKeys) Wrap(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if a.open[r.URL.Path] {
+            next.ServeHTTP(w, r)
+            return
+        }
+        if key := requestKey(r); a.Valid(key) {
+            next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authenticatedKey{}, key)))
+            return
+        }
+        w.Header().Set("WWW-Authenticate", `Bearer realm="gocnn"`)
+        writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid API key"))
+    })
+}
+
+// authenticatedKey is the context key under which Wrap stores a validated key
+type authenticatedKey struct{}
+
+// validatedKey returns the key Wrap accepted for a request, or "" when auth
+// is off or the path is open; only such a key may name a rate-limit bucket
+func validatedKey(r *http.Request) string {
+    key, _ := r.Context().Value(authenticatedKey{}).(string)
+    return key
+}
+
+// Valid reports whether key is one of the accepted keys
+func (a *APIKeys) Valid(key string) bool {
+    if key == "" {
+        return false
+    }
+    digest := sha256.Sum256([]byte(key))
+    match := 0
+    for _, accepted := range a.digests {
+        match |= subtle.ConstantTimeCompare(digest[:], accepted[:])
+    }
+    return match == 1
+}
+
+// requestKey returns the key a request presents, or ""
+func requestKey(r *http.Request) string {
+    if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+        return strings.TrimSpace(token)
+    }
+    if key := r.Header.Get("X-API-Key"); key != "" {
+        return key
+    }
+    if headerContainsToken(r.Header, "Upgrade", "websocket") {
+        return r.URL.Query().Get("access_token")
+    }
+    return ""
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAPIKeys(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "keys")
+    if err := os.WriteFile(path, []byte("# clients\nalpha-key\n\n  beta-key  \n"), 0600); err != nil {
+        t.Fatal(err)
+    }
+    keys, err := ReadAPIKeys(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    auth, err := NewAPIKeys(keys)
+    if err != nil {
+        t.Fatal(err)
+    }
+    handler := auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+    for _, tc := range []struct {
+        name   string
+        path   string
+        header map[string]string
+        status int
+    }{
+        {"no key", "/v1/predict", nil, http.StatusUnauthorized},
+        {"bearer", "/v1/predict", map[string]string{"Authorization": "Bearer alpha-key"}, http.StatusOK},
+        {"lower-case scheme", "/v1/predict", map[string]string{"Authorization": "bearer beta-key"}, http.StatusOK},
+        {"header", "/v1/models", map[string]string{"X-API-Key": "beta-key"}, http.StatusOK},
+        {"wrong key", "/v1/predict", map[string]string{"Authorization": "Bearer alpha"}, http.StatusUnauthorized},
+        {"basic auth", "/v1/predict", map[string]string{"Authorization": "Basic alpha-key"}, http.StatusUnauthorized},
+        {"comment is no key", "/v1/predict", map[string]string{"X-API-Key": "# clients"}, http.StatusUnauthorized},
+        {"query without upgrade", "/v1/stream?access_token=alpha-key", nil, http.StatusUnauthorized},
+        {"query on upgrade", "/v1/stream?access_token=alpha-key", map[string]string{"Upgrade": "websocket"}, http.StatusOK},
+        {"probe", "/readyz", nil, http.StatusOK},
+    } {
+        request := httptest.NewRequest("GET", tc.path, nil)
+        for name, value := range tc.header {
+            request.Header.Set(name, value)
+        }
+        recorder := httptest.NewRecorder()
+        handler.ServeHTTP(recorder, request)
+        if recorder.Code != tc.status {
+            t.Errorf("%s: expected %d, got %d", tc.name, tc.status, recorder.Code)
+        }
+        if recorder.Code == http.StatusUnauthorized && recorder.Header().Get("WWW-Authenticate") == "" {
+            t.Errorf("%s: 401 without a WWW-Authenticate challenge", tc.name)
+        }
+    }
+
+    if _, err := NewAPIKeys([]string{" ", ""}); err == nil {
+        t.Error("Blank keys only should be rejected")
+    }
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/model"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/**
+* Dynamic batching
+
+A service answers each request as it comes, but PredictBatch reads every
+conv layer's weights once for the whole batch, so eight concurrent
+single-image requests cost far less as one batch than as eight forward
+passes. The Batcher coalesces them:
+```
+Predict ──┐
+Predict ──┼──> requests ──> loop: first request opens a window ──> PredictBatch ──> replies
+Predict ──┘                       (closes after Window or MaxBatch requests)
+```
+The first request of a batch waits at most Window for company; a full batch
+runs at once. While a batch runs, new requests queue up and form the next
+one, so under load batches fill without waiting and the window only costs
+latency when traffic is light.
+
+Requests whose context is done before their batch runs are answered with
+ctx.Err() and left out. The batch itself runs to completion even if every
+caller gives up, since its images are shared. Images of the wrong size are
+rejected before joining a batch, so one bad request can't fail the others.
+*/
+
+// ErrBatcherClosed is returned for requests made after Close
+var ErrBatcherClosed = errors.New("batcher is closed")
+
+// BatcherOptions configures how requests are coalesced
+type BatcherOptions struct {
+    Window   time.Duration // How long the first request of a batch waits for others
+    MaxBatch int           // Requests that run at once without waiting for the window
+}
+
+// DefaultBatcherOptions waits up to 2 ms for up to 16 requests
+func DefaultBatcherOptions() BatcherOptions {
+    return BatcherOptions{Window: 2 * time.Millisecond, MaxBatch: 16}
+}
+
+// batchRequest is one image waiting for its batch
+type batchRequest struct {
+    ctx   context.Context
+    image []float32
+    reply chan batchReply
+}
+
+// batchReply is the outcome of one batched request
+type batchReply struct {
+    result *model.PredictionResult
+    err    error
+}
+
+// Batcher is a Predictor that runs concurrent Predict calls as batches on another Predictor
+type Batcher struct {
+    predictor model.Predictor
+    options   BatcherOptions
+    inputSize int
+    requests  chan *batchRequest
+    done      chan struct{}
+    stopped   chan struct{}
+    closeOnce sync.Once
+}
+
+// Batcher is a Predictor
+var _ model.Predictor = (*Batcher)(nil)
+
+// NewBatcher starts coalescing Predict calls into PredictBatch calls on predictor
+// Close stops it.
+func NewBatcher(predictor model.Predictor, options BatcherOptions) (*Batcher, error) {
+    if options.Window < 0 {
+        return nil, fmt.Errorf("batch window must not be negative, got %v", options.Window)
+    }
+    if options.MaxBatch <= 0 {
+        return nil, fmt.Errorf("max batch must be positive, got %d", options.MaxBatch)
+    }
+
+    arch := predictor.Info().Architecture
+    b := &Batcher{
+        predictor: predictor,
+        options:   options,
+        inputSize: arch.InputSize(),
+        requests:  make(chan *batchRequest),
+        done:      make(chan struct{}),
+        stopped:   make(chan struct{}),
+    }
+    go b.loop()
+    return b, nil
+}
+
+// Predict classifies one image as part of the next batch
+func (b *Batcher) Predict(ctx context.Context, imageData []float32) (*model.PredictionResult, error) {
+    if len(imageData) != b.inputSize {
+        return nil, fmt.Errorf("input size mismatch: expected %d, got %d", b.inputSize, len(imageData))
+    }
+
+    request := &batchRequest{ctx: ctx, image: imageData, reply: make(chan batchReply, 1)}
+    select {
+    case b.requests <- request:
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    case <-b.done:
+        return nil, ErrBatcherClosed
+    }
+
+    select {
+    case reply := <-request.reply:
+        return reply.result, reply.err
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    }
+}
+
+// PredictBatch runs images, already a batch, directly on the underlying predictor
+func (b *Batcher) PredictBatch(ctx context.Context, images [][]float32) ([]*model.PredictionResult, error) {
+    return b.predictor.PredictBatch(ctx, images)
+}
+
+// Info describes the underlying predictor
+func (b *Batcher) Info() *model.ModelInfo {
+    return b.predictor.Info()
+}
+
+// Close stops batching once the running batch is answered; later Predict calls fail
+// with ErrBatcherClosed
+func (b *Batcher) Close() error {
+    b.closeOnce.Do(func() { close(b.done) })
+    <-b.stopped
+    return nil
+}
+
+// loop gathers requests into batches and runs them one after the other
+func (b *Batcher) loop() {
+    defer close(b.stopped)
+    for {
+        var batch []*batchRequest
+        select {
+        case request := <-b.requests:
+            batch = append(batch, request)
+        case <-b.done:
+            return
+        }
+
+        window := time.NewTimer(b.options.Window)
+    gather:
+        for len(batch) < b.options.MaxBatch {
+            select {
+            case request := <-b.requests:
+                batch = append(batch, request)
+            case <-window.C:
+                break gather
+            case <-b.done:
+                break gather
+            }
+        }
+        window.Stop()
+
+        b.run(batch)
+    }
+}
+
+// run predicts the images of the requests still wanted and answers every request
+func (b *Batcher) run(batch []*batchRequest) {
+    live := batch[:0]
+    for _, request := range batch {
+        if err := request.ctx.Err(); err != nil {
+            request.reply <- batchReply{err: err}
+            continue
+        }
+        live = append(live, request)
+    }
+    if len(live) == 0 {
+        return
+    }
+
+    images := make([][]float32, len(live))
+    for i, request := range live {
+        images[i] = request.image
+    }
+    results, err := b.predictor.PredictBatch(context.Background(), images)
+    for i, request := range live {
+        if err != nil {
+            request.reply <- batchReply{err: err}
+            continue
+        }
+        request.reply <- batchReply{result: results[i]}
+    }
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingPredictor classifies an image as its first value and records the batch sizes it ran
+type recordingPredictor struct {
+    mu      sync.Mutex
+    batches []int
+}
+
+func (p *recordingPredictor) Predict(ctx context.Context, imageData []float32) (*model.PredictionResult, error) {
+    results, err := p.PredictBatch(ctx, [][]float32{imageData})
+    if err != nil {
+        return nil, err
+    }
+    return results[0], nil
+}
+
+func (p *recordingPredictor) PredictBatch(ctx context.Context, images [][]float32) ([]*model.PredictionResult, error) {
+    p.mu.Lock()
+    p.batches = append(p.batches, len(images))
+    p.mu.Unlock()
+
+    results := make([]*model.PredictionResult, len(images))
+    for i, image := range images {
+        results[i] = &model.PredictionResult{PredictedClass: int(image[0])}
+    }
+    return results, nil
+}
+
+func (p *recordingPredictor) Info() *model.ModelInfo {
+    return &model.ModelInfo{Architecture: model.GetTinyCNNArchitecture()}
+}
+
+// predictConcurrently sends count images, image i starting with value i, through b at once
+// and checks that every caller gets its own result
+func predictConcurrently(t *testing.T, b *Batcher, count int) {
+    var wg sync.WaitGroup
+    for i := 0; i < count; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            image := make([]float32, 32*32*3)
+            image[0] = float32(i)
+            result, err := b.Predict(context.Background(), image)
+            if err != nil {
+                t.Errorf("Request %d failed: %v", i, err)
+                return
+            }
+            if result.PredictedClass != i {
+                t.Errorf("Request %d got the result of request %d", i, result.PredictedClass)
+            }
+        }()
+    }
+    wg.Wait()
+}
+
+func TestBatcherCoalescesRequests(t *testing.T) {
+    predictor := &recordingPredictor{}
+    // A long window: the batch runs as soon as all eight requests are in
+    b, err := NewBatcher(predictor, BatcherOptions{Window: time.Minute, MaxBatch: 8})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer b.Close()
+
+    predictConcurrently(t, b, 8)
+    if len(predictor.batches) != 1 || predictor.batches[0] != 8 {
+        t.Errorf("Expected one batch of 8, got %v", predictor.batches)
+    }
+}
+
+func TestBatcherLimits(t *testing.T) {
+    predictor := &recordingPredictor{}
+    b, err := NewBatcher(predictor, BatcherOptions{Window: time.Millisecond, MaxBatch: 3})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    predictConcurrently(t, b, 10)
+    total := 0
+    for _, size := range predictor.batches {
+        if size > 3 {
+            t.Errorf("Batch of %d exceeds the maximum of 3", size)
+        }
+        total += size
+    }
+    if total != 10 {
+        t.Errorf("Expected 10 images predicted, got %d", total)
+    }
+
+    // A wrong-sized image is rejected without running a batch
+    runs := len(predictor.batches)
+    if _, err := b.Predict(context.Background(), make([]float32, 5)); err == nil {
+        t.Error("Expected an error for a short image")
+    }
+    if len(predictor.batches) != runs {
+        t.Error("Short image reached the predictor")
+    }
+
+    b.Close()
+    if _, err := b.Predict(context.Background(), make([]float32, 32*32*3)); err != ErrBatcherClosed {
+        t.Errorf("Expected ErrBatcherClosed after Close, got %v", err)
+    }
+
+    for _, options := range []BatcherOptions{{Window: -1, MaxBatch: 1}, {Window: 0, MaxBatch: 0}} {
+        if _, err := NewBatcher(predictor, options); err == nil {
+            t.Errorf("Expected an error for %+v", options)
+        }
+    }
+}
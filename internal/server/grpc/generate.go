@@ -0,0 +1,4 @@
+package grpc
+
+// The generated code is committed; regenerate it after editing inference.proto
+//go:generate protoc --go_out=. --go_opt=module=duchm1606/gocnn/internal/server/grpc --go-grpc_out=. --go-grpc_opt=module=duchm1606/gocnn/internal/server/grpc inference.proto
@@ -0,0 +1,841 @@
+// Inference service for gocnn models.
+//
+// The messages mirror model.Predictor: Predict and PredictBatch take CHW
+// float32 images and return model.PredictionResult, GetModelInfo returns
+// model.ModelInfo. PredictStream is the counterpart of
+// (*TinyCNN).PredictStream: results come back in request order, and a failed
+// image yields a result with error set instead of ending the stream.
+//
+// Like the HTTP API, every request may name one of the served models; an
+// empty model is the server's default model.
+//
+// The Go code in inferencepb is generated from this file with protoc-gen-go
+// and protoc-gen-go-grpc (see generate.go); package grpc serves it.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: inference.proto
+
+package inferencepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// An image in CHW order, height*width*channels values
+type Image struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []float32              `protobuf:"fixed32,1,rep,packed,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Image) Reset() {
+	*x = Image{}
+	mi := &file_inference_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Image) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Image) ProtoMessage() {}
+
+func (x *Image) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Image.ProtoReflect.Descriptor instead.
+func (*Image) Descriptor() ([]byte, []int) {
+	return file_inference_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Image) GetData() []float32 {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type PredictRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Image *Image                 `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
+	// Number of most probable classes to return in top_k; 0 for none
+	TopK int32 `protobuf:"varint,2,opt,name=top_k,json=topK,proto3" json:"top_k,omitempty"`
+	// Served model to classify with; empty for the default
+	Model         string `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PredictRequest) Reset() {
+	*x = PredictRequest{}
+	mi := &file_inference_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PredictRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PredictRequest) ProtoMessage() {}
+
+func (x *PredictRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PredictRequest.ProtoReflect.Descriptor instead.
+func (*PredictRequest) Descriptor() ([]byte, []int) {
+	return file_inference_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PredictRequest) GetImage() *Image {
+	if x != nil {
+		return x.Image
+	}
+	return nil
+}
+
+func (x *PredictRequest) GetTopK() int32 {
+	if x != nil {
+		return x.TopK
+	}
+	return 0
+}
+
+func (x *PredictRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+type ClassProbability struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Class         int32                  `protobuf:"varint,1,opt,name=class,proto3" json:"class,omitempty"`
+	Probability   float32                `protobuf:"fixed32,2,opt,name=probability,proto3" json:"probability,omitempty"`
+	ClassName     string                 `protobuf:"bytes,3,opt,name=class_name,json=className,proto3" json:"class_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClassProbability) Reset() {
+	*x = ClassProbability{}
+	mi := &file_inference_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClassProbability) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClassProbability) ProtoMessage() {}
+
+func (x *ClassProbability) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClassProbability.ProtoReflect.Descriptor instead.
+func (*ClassProbability) Descriptor() ([]byte, []int) {
+	return file_inference_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ClassProbability) GetClass() int32 {
+	if x != nil {
+		return x.Class
+	}
+	return 0
+}
+
+func (x *ClassProbability) GetProbability() float32 {
+	if x != nil {
+		return x.Probability
+	}
+	return 0
+}
+
+func (x *ClassProbability) GetClassName() string {
+	if x != nil {
+		return x.ClassName
+	}
+	return ""
+}
+
+type PredictResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Probabilities  []float32              `protobuf:"fixed32,1,rep,packed,name=probabilities,proto3" json:"probabilities,omitempty"`
+	PredictedClass int32                  `protobuf:"varint,2,opt,name=predicted_class,json=predictedClass,proto3" json:"predicted_class,omitempty"` // -1 when error is set
+	Confidence     float32                `protobuf:"fixed32,3,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	TopK           []*ClassProbability    `protobuf:"bytes,4,rep,name=top_k,json=topK,proto3" json:"top_k,omitempty"`
+	LayerTimesNs   map[string]int64       `protobuf:"bytes,5,rep,name=layer_times_ns,json=layerTimesNs,proto3" json:"layer_times_ns,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	TotalTimeNs    int64                  `protobuf:"varint,6,opt,name=total_time_ns,json=totalTimeNs,proto3" json:"total_time_ns,omitempty"`
+	Engine         *EngineOptions         `protobuf:"bytes,7,opt,name=engine,proto3" json:"engine,omitempty"`
+	Error          string                 `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`                           // Why the image could not be classified (streaming only)
+	Model          string                 `protobuf:"bytes,9,opt,name=model,proto3" json:"model,omitempty"`                           // Served model that answered
+	ClassName      string                 `protobuf:"bytes,10,opt,name=class_name,json=className,proto3" json:"class_name,omitempty"` // Name of predicted_class, if the model has class names
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *PredictResponse) Reset() {
+	*x = PredictResponse{}
+	mi := &file_inference_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PredictResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PredictResponse) ProtoMessage() {}
+
+func (x *PredictResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PredictResponse.ProtoReflect.Descriptor instead.
+func (*PredictResponse) Descriptor() ([]byte, []int) {
+	return file_inference_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PredictResponse) GetProbabilities() []float32 {
+	if x != nil {
+		return x.Probabilities
+	}
+	return nil
+}
+
+func (x *PredictResponse) GetPredictedClass() int32 {
+	if x != nil {
+		return x.PredictedClass
+	}
+	return 0
+}
+
+func (x *PredictResponse) GetConfidence() float32 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *PredictResponse) GetTopK() []*ClassProbability {
+	if x != nil {
+		return x.TopK
+	}
+	return nil
+}
+
+func (x *PredictResponse) GetLayerTimesNs() map[string]int64 {
+	if x != nil {
+		return x.LayerTimesNs
+	}
+	return nil
+}
+
+func (x *PredictResponse) GetTotalTimeNs() int64 {
+	if x != nil {
+		return x.TotalTimeNs
+	}
+	return 0
+}
+
+func (x *PredictResponse) GetEngine() *EngineOptions {
+	if x != nil {
+		return x.Engine
+	}
+	return nil
+}
+
+func (x *PredictResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *PredictResponse) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *PredictResponse) GetClassName() string {
+	if x != nil {
+		return x.ClassName
+	}
+	return ""
+}
+
+type PredictBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Images        []*Image               `protobuf:"bytes,1,rep,name=images,proto3" json:"images,omitempty"`
+	TopK          int32                  `protobuf:"varint,2,opt,name=top_k,json=topK,proto3" json:"top_k,omitempty"`
+	Model         string                 `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PredictBatchRequest) Reset() {
+	*x = PredictBatchRequest{}
+	mi := &file_inference_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PredictBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PredictBatchRequest) ProtoMessage() {}
+
+func (x *PredictBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PredictBatchRequest.ProtoReflect.Descriptor instead.
+func (*PredictBatchRequest) Descriptor() ([]byte, []int) {
+	return file_inference_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PredictBatchRequest) GetImages() []*Image {
+	if x != nil {
+		return x.Images
+	}
+	return nil
+}
+
+func (x *PredictBatchRequest) GetTopK() int32 {
+	if x != nil {
+		return x.TopK
+	}
+	return 0
+}
+
+func (x *PredictBatchRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+type PredictBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*PredictResponse     `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PredictBatchResponse) Reset() {
+	*x = PredictBatchResponse{}
+	mi := &file_inference_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PredictBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PredictBatchResponse) ProtoMessage() {}
+
+func (x *PredictBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PredictBatchResponse.ProtoReflect.Descriptor instead.
+func (*PredictBatchResponse) Descriptor() ([]byte, []int) {
+	return file_inference_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PredictBatchResponse) GetResults() []*PredictResponse {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type GetModelInfoRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Model         string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetModelInfoRequest) Reset() {
+	*x = GetModelInfoRequest{}
+	mi := &file_inference_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetModelInfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetModelInfoRequest) ProtoMessage() {}
+
+func (x *GetModelInfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetModelInfoRequest.ProtoReflect.Descriptor instead.
+func (*GetModelInfoRequest) Descriptor() ([]byte, []int) {
+	return file_inference_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetModelInfoRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+type EngineOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Backend       string                 `protobuf:"bytes,1,opt,name=backend,proto3" json:"backend,omitempty"` // auto, naive, tiled, parallel or gemm
+	Workers       int32                  `protobuf:"varint,2,opt,name=workers,proto3" json:"workers,omitempty"`
+	Pooling       bool                   `protobuf:"varint,3,opt,name=pooling,proto3" json:"pooling,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EngineOptions) Reset() {
+	*x = EngineOptions{}
+	mi := &file_inference_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EngineOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EngineOptions) ProtoMessage() {}
+
+func (x *EngineOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EngineOptions.ProtoReflect.Descriptor instead.
+func (*EngineOptions) Descriptor() ([]byte, []int) {
+	return file_inference_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *EngineOptions) GetBackend() string {
+	if x != nil {
+		return x.Backend
+	}
+	return ""
+}
+
+func (x *EngineOptions) GetWorkers() int32 {
+	if x != nil {
+		return x.Workers
+	}
+	return 0
+}
+
+func (x *EngineOptions) GetPooling() bool {
+	if x != nil {
+		return x.Pooling
+	}
+	return false
+}
+
+type ModelInfo struct {
+	state                  protoimpl.MessageState `protogen:"open.v1"`
+	InputHeight            int32                  `protobuf:"varint,1,opt,name=input_height,json=inputHeight,proto3" json:"input_height,omitempty"`
+	InputWidth             int32                  `protobuf:"varint,2,opt,name=input_width,json=inputWidth,proto3" json:"input_width,omitempty"`
+	InputChannels          int32                  `protobuf:"varint,3,opt,name=input_channels,json=inputChannels,proto3" json:"input_channels,omitempty"`
+	NumClasses             int32                  `protobuf:"varint,4,opt,name=num_classes,json=numClasses,proto3" json:"num_classes,omitempty"`
+	Layers                 []string               `protobuf:"bytes,5,rep,name=layers,proto3" json:"layers,omitempty"`
+	TotalParameters        int64                  `protobuf:"varint,6,opt,name=total_parameters,json=totalParameters,proto3" json:"total_parameters,omitempty"`
+	Precision              string                 `protobuf:"bytes,7,opt,name=precision,proto3" json:"precision,omitempty"`
+	WeightQuantization     string                 `protobuf:"bytes,8,opt,name=weight_quantization,json=weightQuantization,proto3" json:"weight_quantization,omitempty"`
+	Int8ConvLayers         int32                  `protobuf:"varint,9,opt,name=int8_conv_layers,json=int8ConvLayers,proto3" json:"int8_conv_layers,omitempty"`
+	ActivationQuantization string                 `protobuf:"bytes,10,opt,name=activation_quantization,json=activationQuantization,proto3" json:"activation_quantization,omitempty"`
+	WeightBytes            int64                  `protobuf:"varint,11,opt,name=weight_bytes,json=weightBytes,proto3" json:"weight_bytes,omitempty"`
+	Engine                 *EngineOptions         `protobuf:"bytes,12,opt,name=engine,proto3" json:"engine,omitempty"`
+	TotalInferences        int64                  `protobuf:"varint,13,opt,name=total_inferences,json=totalInferences,proto3" json:"total_inferences,omitempty"`
+	AverageLayerTimesNs    map[string]int64       `protobuf:"bytes,14,rep,name=average_layer_times_ns,json=averageLayerTimesNs,proto3" json:"average_layer_times_ns,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Model                  string                 `protobuf:"bytes,15,opt,name=model,proto3" json:"model,omitempty"`
+	ClassNames             []string               `protobuf:"bytes,16,rep,name=class_names,json=classNames,proto3" json:"class_names,omitempty"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
+}
+
+func (x *ModelInfo) Reset() {
+	*x = ModelInfo{}
+	mi := &file_inference_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModelInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModelInfo) ProtoMessage() {}
+
+func (x *ModelInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_inference_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModelInfo.ProtoReflect.Descriptor instead.
+func (*ModelInfo) Descriptor() ([]byte, []int) {
+	return file_inference_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ModelInfo) GetInputHeight() int32 {
+	if x != nil {
+		return x.InputHeight
+	}
+	return 0
+}
+
+func (x *ModelInfo) GetInputWidth() int32 {
+	if x != nil {
+		return x.InputWidth
+	}
+	return 0
+}
+
+func (x *ModelInfo) GetInputChannels() int32 {
+	if x != nil {
+		return x.InputChannels
+	}
+	return 0
+}
+
+func (x *ModelInfo) GetNumClasses() int32 {
+	if x != nil {
+		return x.NumClasses
+	}
+	return 0
+}
+
+func (x *ModelInfo) GetLayers() []string {
+	if x != nil {
+		return x.Layers
+	}
+	return nil
+}
+
+func (x *ModelInfo) GetTotalParameters() int64 {
+	if x != nil {
+		return x.TotalParameters
+	}
+	return 0
+}
+
+func (x *ModelInfo) GetPrecision() string {
+	if x != nil {
+		return x.Precision
+	}
+	return ""
+}
+
+func (x *ModelInfo) GetWeightQuantization() string {
+	if x != nil {
+		return x.WeightQuantization
+	}
+	return ""
+}
+
+func (x *ModelInfo) GetInt8ConvLayers() int32 {
+	if x != nil {
+		return x.Int8ConvLayers
+	}
+	return 0
+}
+
+func (x *ModelInfo) GetActivationQuantization() string {
+	if x != nil {
+		return x.ActivationQuantization
+	}
+	return ""
+}
+
+func (x *ModelInfo) GetWeightBytes() int64 {
+	if x != nil {
+		return x.WeightBytes
+	}
+	return 0
+}
+
+func (x *ModelInfo) GetEngine() *EngineOptions {
+	if x != nil {
+		return x.Engine
+	}
+	return nil
+}
+
+func (x *ModelInfo) GetTotalInferences() int64 {
+	if x != nil {
+		return x.TotalInferences
+	}
+	return 0
+}
+
+func (x *ModelInfo) GetAverageLayerTimesNs() map[string]int64 {
+	if x != nil {
+		return x.AverageLayerTimesNs
+	}
+	return nil
+}
+
+func (x *ModelInfo) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *ModelInfo) GetClassNames() []string {
+	if x != nil {
+		return x.ClassNames
+	}
+	return nil
+}
+
+var File_inference_proto protoreflect.FileDescriptor
+
+const file_inference_proto_rawDesc = "" +
+	"\n" +
+	"\x0finference.proto\x12\x12gocnn.inference.v1\"\x1f\n" +
+	"\x05Image\x12\x16\n" +
+	"\x04data\x18\x01 \x03(\x02B\x02\x10\x01R\x04data\"l\n" +
+	"\x0ePredictRequest\x12/\n" +
+	"\x05image\x18\x01 \x01(\v2\x19.gocnn.inference.v1.ImageR\x05image\x12\x13\n" +
+	"\x05top_k\x18\x02 \x01(\x05R\x04topK\x12\x14\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\"i\n" +
+	"\x10ClassProbability\x12\x14\n" +
+	"\x05class\x18\x01 \x01(\x05R\x05class\x12 \n" +
+	"\vprobability\x18\x02 \x01(\x02R\vprobability\x12\x1d\n" +
+	"\n" +
+	"class_name\x18\x03 \x01(\tR\tclassName\"\x87\x04\n" +
+	"\x0fPredictResponse\x12(\n" +
+	"\rprobabilities\x18\x01 \x03(\x02B\x02\x10\x01R\rprobabilities\x12'\n" +
+	"\x0fpredicted_class\x18\x02 \x01(\x05R\x0epredictedClass\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x03 \x01(\x02R\n" +
+	"confidence\x129\n" +
+	"\x05top_k\x18\x04 \x03(\v2$.gocnn.inference.v1.ClassProbabilityR\x04topK\x12[\n" +
+	"\x0elayer_times_ns\x18\x05 \x03(\v25.gocnn.inference.v1.PredictResponse.LayerTimesNsEntryR\flayerTimesNs\x12\"\n" +
+	"\rtotal_time_ns\x18\x06 \x01(\x03R\vtotalTimeNs\x129\n" +
+	"\x06engine\x18\a \x01(\v2!.gocnn.inference.v1.EngineOptionsR\x06engine\x12\x14\n" +
+	"\x05error\x18\b \x01(\tR\x05error\x12\x14\n" +
+	"\x05model\x18\t \x01(\tR\x05model\x12\x1d\n" +
+	"\n" +
+	"class_name\x18\n" +
+	" \x01(\tR\tclassName\x1a?\n" +
+	"\x11LayerTimesNsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"s\n" +
+	"\x13PredictBatchRequest\x121\n" +
+	"\x06images\x18\x01 \x03(\v2\x19.gocnn.inference.v1.ImageR\x06images\x12\x13\n" +
+	"\x05top_k\x18\x02 \x01(\x05R\x04topK\x12\x14\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\"U\n" +
+	"\x14PredictBatchResponse\x12=\n" +
+	"\aresults\x18\x01 \x03(\v2#.gocnn.inference.v1.PredictResponseR\aresults\"+\n" +
+	"\x13GetModelInfoRequest\x12\x14\n" +
+	"\x05model\x18\x01 \x01(\tR\x05model\"]\n" +
+	"\rEngineOptions\x12\x18\n" +
+	"\abackend\x18\x01 \x01(\tR\abackend\x12\x18\n" +
+	"\aworkers\x18\x02 \x01(\x05R\aworkers\x12\x18\n" +
+	"\apooling\x18\x03 \x01(\bR\apooling\"\x81\x06\n" +
+	"\tModelInfo\x12!\n" +
+	"\finput_height\x18\x01 \x01(\x05R\vinputHeight\x12\x1f\n" +
+	"\vinput_width\x18\x02 \x01(\x05R\n" +
+	"inputWidth\x12%\n" +
+	"\x0einput_channels\x18\x03 \x01(\x05R\rinputChannels\x12\x1f\n" +
+	"\vnum_classes\x18\x04 \x01(\x05R\n" +
+	"numClasses\x12\x16\n" +
+	"\x06layers\x18\x05 \x03(\tR\x06layers\x12)\n" +
+	"\x10total_parameters\x18\x06 \x01(\x03R\x0ftotalParameters\x12\x1c\n" +
+	"\tprecision\x18\a \x01(\tR\tprecision\x12/\n" +
+	"\x13weight_quantization\x18\b \x01(\tR\x12weightQuantization\x12(\n" +
+	"\x10int8_conv_layers\x18\t \x01(\x05R\x0eint8ConvLayers\x127\n" +
+	"\x17activation_quantization\x18\n" +
+	" \x01(\tR\x16activationQuantization\x12!\n" +
+	"\fweight_bytes\x18\v \x01(\x03R\vweightBytes\x129\n" +
+	"\x06engine\x18\f \x01(\v2!.gocnn.inference.v1.EngineOptionsR\x06engine\x12)\n" +
+	"\x10total_inferences\x18\r \x01(\x03R\x0ftotalInferences\x12k\n" +
+	"\x16average_layer_times_ns\x18\x0e \x03(\v26.gocnn.inference.v1.ModelInfo.AverageLayerTimesNsEntryR\x13averageLayerTimesNs\x12\x14\n" +
+	"\x05model\x18\x0f \x01(\tR\x05model\x12\x1f\n" +
+	"\vclass_names\x18\x10 \x03(\tR\n" +
+	"classNames\x1aF\n" +
+	"\x18AverageLayerTimesNsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x012\xf8\x02\n" +
+	"\tInference\x12R\n" +
+	"\aPredict\x12\".gocnn.inference.v1.PredictRequest\x1a#.gocnn.inference.v1.PredictResponse\x12a\n" +
+	"\fPredictBatch\x12'.gocnn.inference.v1.PredictBatchRequest\x1a(.gocnn.inference.v1.PredictBatchResponse\x12\\\n" +
+	"\rPredictStream\x12\".gocnn.inference.v1.PredictRequest\x1a#.gocnn.inference.v1.PredictResponse(\x010\x01\x12V\n" +
+	"\fGetModelInfo\x12'.gocnn.inference.v1.GetModelInfoRequest\x1a\x1d.gocnn.inference.v1.ModelInfoB2Z0duchm1606/gocnn/internal/server/grpc/inferencepbb\x06proto3"
+
+var (
+	file_inference_proto_rawDescOnce sync.Once
+	file_inference_proto_rawDescData []byte
+)
+
+func file_inference_proto_rawDescGZIP() []byte {
+	file_inference_proto_rawDescOnce.Do(func() {
+		file_inference_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_inference_proto_rawDesc), len(file_inference_proto_rawDesc)))
+	})
+	return file_inference_proto_rawDescData
+}
+
+var file_inference_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_inference_proto_goTypes = []any{
+	(*Image)(nil),                // 0: gocnn.inference.v1.Image
+	(*PredictRequest)(nil),       // 1: gocnn.inference.v1.PredictRequest
+	(*ClassProbability)(nil),     // 2: gocnn.inference.v1.ClassProbability
+	(*PredictResponse)(nil),      // 3: gocnn.inference.v1.PredictResponse
+	(*PredictBatchRequest)(nil),  // 4: gocnn.inference.v1.PredictBatchRequest
+	(*PredictBatchResponse)(nil), // 5: gocnn.inference.v1.PredictBatchResponse
+	(*GetModelInfoRequest)(nil),  // 6: gocnn.inference.v1.GetModelInfoRequest
+	(*EngineOptions)(nil),        // 7: gocnn.inference.v1.EngineOptions
+	(*ModelInfo)(nil),            // 8: gocnn.inference.v1.ModelInfo
+	nil,                          // 9: gocnn.inference.v1.PredictResponse.LayerTimesNsEntry
+	nil,                          // 10: gocnn.inference.v1.ModelInfo.AverageLayerTimesNsEntry
+}
+var file_inference_proto_depIdxs = []int32{
+	0,  // 0: gocnn.inference.v1.PredictRequest.image:type_name -> gocnn.inference.v1.Image
+	2,  // 1: gocnn.inference.v1.PredictResponse.top_k:type_name -> gocnn.inference.v1.ClassProbability
+	9,  // 2: gocnn.inference.v1.PredictResponse.layer_times_ns:type_name -> gocnn.inference.v1.PredictResponse.LayerTimesNsEntry
+	7,  // 3: gocnn.inference.v1.PredictResponse.engine:type_name -> gocnn.inference.v1.EngineOptions
+	0,  // 4: gocnn.inference.v1.PredictBatchRequest.images:type_name -> gocnn.inference.v1.Image
+	3,  // 5: gocnn.inference.v1.PredictBatchResponse.results:type_name -> gocnn.inference.v1.PredictResponse
+	7,  // 6: gocnn.inference.v1.ModelInfo.engine:type_name -> gocnn.inference.v1.EngineOptions
+	10, // 7: gocnn.inference.v1.ModelInfo.average_layer_times_ns:type_name -> gocnn.inference.v1.ModelInfo.AverageLayerTimesNsEntry
+	1,  // 8: gocnn.inference.v1.Inference.Predict:input_type -> gocnn.inference.v1.PredictRequest
+	4,  // 9: gocnn.inference.v1.Inference.PredictBatch:input_type -> gocnn.inference.v1.PredictBatchRequest
+	1,  // 10: gocnn.inference.v1.Inference.PredictStream:input_type -> gocnn.inference.v1.PredictRequest
+	6,  // 11: gocnn.inference.v1.Inference.GetModelInfo:input_type -> gocnn.inference.v1.GetModelInfoRequest
+	3,  // 12: gocnn.inference.v1.Inference.Predict:output_type -> gocnn.inference.v1.PredictResponse
+	5,  // 13: gocnn.inference.v1.Inference.PredictBatch:output_type -> gocnn.inference.v1.PredictBatchResponse
+	3,  // 14: gocnn.inference.v1.Inference.PredictStream:output_type -> gocnn.inference.v1.PredictResponse
+	8,  // 15: gocnn.inference.v1.Inference.GetModelInfo:output_type -> gocnn.inference.v1.ModelInfo
+	12, // [12:16] is the sub-list for method output_type
+	8,  // [8:12] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_inference_proto_init() }
+func file_inference_proto_init() {
+	if File_inference_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_inference_proto_rawDesc), len(file_inference_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_inference_proto_goTypes,
+		DependencyIndexes: file_inference_proto_depIdxs,
+		MessageInfos:      file_inference_proto_msgTypes,
+	}.Build()
+	File_inference_proto = out.File
+	file_inference_proto_goTypes = nil
+	file_inference_proto_depIdxs = nil
+}
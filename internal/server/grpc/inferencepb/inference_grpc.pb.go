@@ -0,0 +1,252 @@
+// Inference service for gocnn models.
+//
+// The messages mirror model.Predictor: Predict and PredictBatch take CHW
+// float32 images and return model.PredictionResult, GetModelInfo returns
+// model.ModelInfo. PredictStream is the counterpart of
+// (*TinyCNN).PredictStream: results come back in request order, and a failed
+// image yields a result with error set instead of ending the stream.
+//
+// Like the HTTP API, every request may name one of the served models; an
+// empty model is the server's default model.
+//
+// The Go code in inferencepb is generated from this file with protoc-gen-go
+// and protoc-gen-go-grpc (see generate.go); package grpc serves it.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: inference.proto
+
+package inferencepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Inference_Predict_FullMethodName       = "/gocnn.inference.v1.Inference/Predict"
+	Inference_PredictBatch_FullMethodName  = "/gocnn.inference.v1.Inference/PredictBatch"
+	Inference_PredictStream_FullMethodName = "/gocnn.inference.v1.Inference/PredictStream"
+	Inference_GetModelInfo_FullMethodName  = "/gocnn.inference.v1.Inference/GetModelInfo"
+)
+
+// InferenceClient is the client API for Inference service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type InferenceClient interface {
+	// Classify one image
+	Predict(ctx context.Context, in *PredictRequest, opts ...grpc.CallOption) (*PredictResponse, error)
+	// Classify several images together (one GEMM per conv layer)
+	PredictBatch(ctx context.Context, in *PredictBatchRequest, opts ...grpc.CallOption) (*PredictBatchResponse, error)
+	// Classify a stream of images, answering in request order
+	PredictStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[PredictRequest, PredictResponse], error)
+	// Describe the loaded model and its performance counters
+	GetModelInfo(ctx context.Context, in *GetModelInfoRequest, opts ...grpc.CallOption) (*ModelInfo, error)
+}
+
+type inferenceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewInferenceClient(cc grpc.ClientConnInterface) InferenceClient {
+	return &inferenceClient{cc}
+}
+
+func (c *inferenceClient) Predict(ctx context.Context, in *PredictRequest, opts ...grpc.CallOption) (*PredictResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PredictResponse)
+	err := c.cc.Invoke(ctx, Inference_Predict_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceClient) PredictBatch(ctx context.Context, in *PredictBatchRequest, opts ...grpc.CallOption) (*PredictBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PredictBatchResponse)
+	err := c.cc.Invoke(ctx, Inference_PredictBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceClient) PredictStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[PredictRequest, PredictResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Inference_ServiceDesc.Streams[0], Inference_PredictStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PredictRequest, PredictResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Inference_PredictStreamClient = grpc.BidiStreamingClient[PredictRequest, PredictResponse]
+
+func (c *inferenceClient) GetModelInfo(ctx context.Context, in *GetModelInfoRequest, opts ...grpc.CallOption) (*ModelInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ModelInfo)
+	err := c.cc.Invoke(ctx, Inference_GetModelInfo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// InferenceServer is the server API for Inference service.
+// All implementations must embed UnimplementedInferenceServer
+// for forward compatibility.
+type InferenceServer interface {
+	// Classify one image
+	Predict(context.Context, *PredictRequest) (*PredictResponse, error)
+	// Classify several images together (one GEMM per conv layer)
+	PredictBatch(context.Context, *PredictBatchRequest) (*PredictBatchResponse, error)
+	// Classify a stream of images, answering in request order
+	PredictStream(grpc.BidiStreamingServer[PredictRequest, PredictResponse]) error
+	// Describe the loaded model and its performance counters
+	GetModelInfo(context.Context, *GetModelInfoRequest) (*ModelInfo, error)
+	mustEmbedUnimplementedInferenceServer()
+}
+
+// UnimplementedInferenceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedInferenceServer struct{}
+
+func (UnimplementedInferenceServer) Predict(context.Context, *PredictRequest) (*PredictResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Predict not implemented")
+}
+func (UnimplementedInferenceServer) PredictBatch(context.Context, *PredictBatchRequest) (*PredictBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PredictBatch not implemented")
+}
+func (UnimplementedInferenceServer) PredictStream(grpc.BidiStreamingServer[PredictRequest, PredictResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method PredictStream not implemented")
+}
+func (UnimplementedInferenceServer) GetModelInfo(context.Context, *GetModelInfoRequest) (*ModelInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetModelInfo not implemented")
+}
+func (UnimplementedInferenceServer) mustEmbedUnimplementedInferenceServer() {}
+func (UnimplementedInferenceServer) testEmbeddedByValue()                   {}
+
+// UnsafeInferenceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to InferenceServer will
+// result in compilation errors.
+type UnsafeInferenceServer interface {
+	mustEmbedUnimplementedInferenceServer()
+}
+
+func RegisterInferenceServer(s grpc.ServiceRegistrar, srv InferenceServer) {
+	// If the following call pancis, it indicates UnimplementedInferenceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Inference_ServiceDesc, srv)
+}
+
+func _Inference_Predict_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PredictRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServer).Predict(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Inference_Predict_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServer).Predict(ctx, req.(*PredictRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Inference_PredictBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PredictBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServer).PredictBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Inference_PredictBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServer).PredictBatch(ctx, req.(*PredictBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Inference_PredictStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(InferenceServer).PredictStream(&grpc.GenericServerStream[PredictRequest, PredictResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Inference_PredictStreamServer = grpc.BidiStreamingServer[PredictRequest, PredictResponse]
+
+func _Inference_GetModelInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetModelInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServer).GetModelInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Inference_GetModelInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServer).GetModelInfo(ctx, req.(*GetModelInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Inference_ServiceDesc is the grpc.ServiceDesc for Inference service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Inference_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gocnn.inference.v1.Inference",
+	HandlerType: (*InferenceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Predict",
+			Handler:    _Inference_Predict_Handler,
+		},
+		{
+			MethodName: "PredictBatch",
+			Handler:    _Inference_PredictBatch_Handler,
+		},
+		{
+			MethodName: "GetModelInfo",
+			Handler:    _Inference_GetModelInfo_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PredictStream",
+			Handler:       _Inference_PredictStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "inference.proto",
+}
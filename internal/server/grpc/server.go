@@ -0,0 +1,374 @@
+package grpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/ops"
+	"duchm1606/gocnn/internal/server"
+	"duchm1606/gocnn/internal/server/grpc/inferencepb"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+/**
+* gRPC inference service
+
+Server answers the Inference service of inference.proto for the models of a
+server.Registry, the same registry the HTTP API serves:
+```
+Predict        one CHW float32 image      ──> served.Predictor.Predict
+PredictBatch   several images at once     ──> served.Predictor.PredictBatch
+PredictStream  images in, results out     ──> Predict per message, in order
+GetModelInfo   model description          ──> served.Model.Info
+```
+Unlike the HTTP API, images arrive already preprocessed: height*width*channels
+values in CHW order, as model.Predictor takes them. A wrong-sized image is
+InvalidArgument and an unknown model NotFound.
+
+With SetAPIKeys every call needs a key in the "authorization: Bearer <key>"
+or "x-api-key" metadata, or fails with Unauthenticated. With SetLimiter calls
+share the HTTP API's in-flight slots and per-client rate; a turned-away call
+is ResourceExhausted. Clients are told apart by validated key, else by peer
+address, as on HTTP.
+*/
+
+// Server implements inferencepb.InferenceServer over a registry
+type Server struct {
+    inferencepb.UnimplementedInferenceServer
+    registry *server.Registry
+    limiter  *server.Limiter // Optional concurrency and rate limits
+    keys     *server.APIKeys // Optional API-key authentication
+}
+
+// NewServer returns the gRPC inference service of registry
+func NewServer(registry *server.Registry) *Server {
+    return &Server{registry: registry}
+}
+
+// SetLimiter bounds concurrent predictions and each client's call rate; nil removes the limits
+func (s *Server) SetLimiter(limiter *server.Limiter) {
+    s.limiter = limiter
+}
+
+// SetAPIKeys requires one of keys on every call; nil accepts all calls
+func (s *Server) SetAPIKeys(keys *server.APIKeys) {
+    s.keys = keys
+}
+
+// Predict classifies one image
+func (s *Server) Predict(ctx context.Context, request *inferencepb.PredictRequest) (*inferencepb.PredictResponse, error) {
+    served, err := s.admit(ctx, request.GetModel(), request.GetTopK())
+    if err != nil {
+        return nil, err
+    }
+    image, err := imageData(served, request.GetImage())
+    if err != nil {
+        return nil, err
+    }
+
+    release, err := s.acquire(ctx)
+    if err != nil {
+        return nil, err
+    }
+    result, err := served.Predictor.Predict(ctx, image)
+    release()
+    if err != nil {
+        return nil, predictError(err)
+    }
+    return newPredictResponse(served, result, int(request.GetTopK())), nil
+}
+
+// PredictBatch classifies several images in one forward pass
+func (s *Server) PredictBatch(ctx context.Context, request *inferencepb.PredictBatchRequest) (*inferencepb.PredictBatchResponse, error) {
+    served, err := s.admit(ctx, request.GetModel(), request.GetTopK())
+    if err != nil {
+        return nil, err
+    }
+    if len(request.GetImages()) == 0 {
+        return nil, status.Error(codes.InvalidArgument, "batch holds no images")
+    }
+    images := make([][]float32, len(request.GetImages()))
+    for i, image := range request.GetImages() {
+        if images[i], err = imageData(served, image); err != nil {
+            return nil, status.Errorf(codes.InvalidArgument, "image %d: %s", i, status.Convert(err).Message())
+        }
+    }
+
+    release, err := s.acquire(ctx)
+    if err != nil {
+        return nil, err
+    }
+    results, err := served.Predictor.PredictBatch(ctx, images)
+    release()
+    if err != nil {
+        return nil, predictError(err)
+    }
+
+    response := &inferencepb.PredictBatchResponse{Results: make([]*inferencepb.PredictResponse, len(results))}
+    for i, result := range results {
+        response.Results[i] = newPredictResponse(served, result, int(request.GetTopK()))
+    }
+    return response, nil
+}
+
+// PredictStream classifies each image received, answering in request order
+// An image that can't be classified gets a response with error set; only
+// authentication or a broken stream end the call.
+func (s *Server) PredictStream(stream inferencepb.Inference_PredictStreamServer) error {
+    ctx := stream.Context()
+    client, err := s.authenticate(ctx)
+    if err != nil {
+        return err
+    }
+
+    for {
+        request, err := stream.Recv()
+        if errors.Is(err, io.EOF) {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        if err := stream.Send(s.predictMessage(ctx, client, request)); err != nil {
+            return err
+        }
+    }
+}
+
+// predictMessage answers one message of a stream
+func (s *Server) predictMessage(ctx context.Context, client string, request *inferencepb.PredictRequest) *inferencepb.PredictResponse {
+    failed := func(err error) *inferencepb.PredictResponse {
+        return &inferencepb.PredictResponse{Model: request.GetModel(), PredictedClass: -1, Error: status.Convert(err).Message()}
+    }
+    served, err := s.registry.Get(request.GetModel())
+    if err != nil {
+        return failed(err)
+    }
+    if request.GetTopK() < 0 {
+        return failed(fmt.Errorf("top_k must be non-negative, got %d", request.GetTopK()))
+    }
+    if err := s.limiter.Allow(client); err != nil {
+        return failed(err)
+    }
+    image, err := imageData(served, request.GetImage())
+    if err != nil {
+        return failed(err)
+    }
+    release, err := s.acquire(ctx)
+    if err != nil {
+        return failed(err)
+    }
+    result, err := served.Predictor.Predict(ctx, image)
+    release()
+    if err != nil {
+        return failed(fmt.Errorf("inference failed: %w", err))
+    }
+    return newPredictResponse(served, result, int(request.GetTopK()))
+}
+
+// GetModelInfo describes a served model and its performance counters
+func (s *Server) GetModelInfo(ctx context.Context, request *inferencepb.GetModelInfoRequest) (*inferencepb.ModelInfo, error) {
+    if _, err := s.authenticate(ctx); err != nil {
+        return nil, err
+    }
+    served, err := s.registry.Get(request.GetModel())
+    if err != nil {
+        return nil, modelError(err)
+    }
+    return newModelInfo(served, served.Model.Info()), nil
+}
+
+// admit authenticates and rate-limits a call, and returns the model it names
+func (s *Server) admit(ctx context.Context, name string, topK int32) (*server.ServedModel, error) {
+    client, err := s.authenticate(ctx)
+    if err != nil {
+        return nil, err
+    }
+    served, err := s.registry.Get(name)
+    if err != nil {
+        return nil, modelError(err)
+    }
+    if topK < 0 {
+        return nil, status.Errorf(codes.InvalidArgument, "top_k must be non-negative, got %d", topK)
+    }
+    if err := s.limiter.Allow(client); err != nil {
+        return nil, limitError(err)
+    }
+    return served, nil
+}
+
+// authenticate checks the call's API key, when keys are required, and returns
+// the client it comes from: the hashed key when validated, else the peer address
+func (s *Server) authenticate(ctx context.Context) (string, error) {
+    if s.keys == nil {
+        return peerAddress(ctx), nil
+    }
+    key := callKey(ctx)
+    if !s.keys.Valid(key) {
+        return "", status.Error(codes.Unauthenticated, "missing or invalid API key")
+    }
+    digest := sha256.Sum256([]byte(key))
+    return "key:" + hex.EncodeToString(digest[:8]), nil
+}
+
+// acquire waits for an in-flight slot, turning limiter errors into statuses
+func (s *Server) acquire(ctx context.Context) (func(), error) {
+    release, err := s.limiter.Acquire(ctx, "grpc")
+    if err != nil {
+        return nil, limitError(err)
+    }
+    return release, nil
+}
+
+// callKey returns the API key in the call's metadata, or ""
+func callKey(ctx context.Context) string {
+    md, _ := metadata.FromIncomingContext(ctx)
+    for _, value := range md.Get("authorization") {
+        if scheme, token, ok := strings.Cut(value, " "); ok && strings.EqualFold(scheme, "Bearer") {
+            return strings.TrimSpace(token)
+        }
+    }
+    if values := md.Get("x-api-key"); len(values) > 0 {
+        return values[0]
+    }
+    return ""
+}
+
+// peerAddress returns the host the call comes from, or "" when unknown
+func peerAddress(ctx context.Context) string {
+    p, ok := peer.FromContext(ctx)
+    if !ok || p.Addr == nil {
+        return ""
+    }
+    host, _, err := net.SplitHostPort(p.Addr.String())
+    if err != nil {
+        return p.Addr.String()
+    }
+    return host
+}
+
+// imageData returns the values of image after checking they fit served's input
+func imageData(served *server.ServedModel, image *inferencepb.Image) ([]float32, error) {
+    arch := served.Model.Info().Architecture
+    if got, want := len(image.GetData()), arch.InputSize(); got != want {
+        return nil, status.Errorf(codes.InvalidArgument, "image has %d values, model %q takes %d",
+            got, served.Name, want)
+    }
+    return image.GetData(), nil
+}
+
+// modelError is the status of a failed registry lookup
+func modelError(err error) error {
+    if errors.Is(err, server.ErrUnknownModel) {
+        return status.Error(codes.NotFound, err.Error())
+    }
+    return status.Error(codes.Unavailable, err.Error())
+}
+
+// limitError is the status of a call the limiter turned away or whose wait ended
+func limitError(err error) error {
+    var limitErr *server.LimitError
+    if errors.As(err, &limitErr) {
+        return status.Error(codes.ResourceExhausted, err.Error())
+    }
+    return status.FromContextError(err).Err()
+}
+
+// predictError is the status of a failed prediction
+func predictError(err error) error {
+    if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+        return status.FromContextError(err).Err()
+    }
+    return status.Errorf(codes.Internal, "inference failed: %v", err)
+}
+
+// newPredictResponse describes result, with its topK most probable classes
+func newPredictResponse(served *server.ServedModel, result *model.PredictionResult, topK int) *inferencepb.PredictResponse {
+    response := &inferencepb.PredictResponse{
+        Model:          served.Name,
+        Probabilities:  result.Probabilities,
+        PredictedClass: int32(result.PredictedClass),
+        ClassName:      className(served.ClassNames, result.PredictedClass),
+        Confidence:     result.Confidence,
+        TotalTimeNs:    result.TotalTime.Nanoseconds(),
+        Engine:         engineOptions(result.Engine),
+    }
+    if len(result.LayerTimes) > 0 {
+        response.LayerTimesNs = make(map[string]int64, len(result.LayerTimes))
+        for layer, elapsed := range result.LayerTimes {
+            response.LayerTimesNs[layer] = elapsed.Nanoseconds()
+        }
+    }
+    if topK > 0 {
+        for _, c := range result.TopK(topK) {
+            response.TopK = append(response.TopK, &inferencepb.ClassProbability{
+                Class:       int32(c.Class),
+                Probability: c.Probability,
+                ClassName:   className(served.ClassNames, c.Class),
+            })
+        }
+    }
+    return response
+}
+
+// newModelInfo describes served from its model information
+func newModelInfo(served *server.ServedModel, info *model.ModelInfo) *inferencepb.ModelInfo {
+    arch := info.Architecture
+    response := &inferencepb.ModelInfo{
+        Model:                  served.Name,
+        ClassNames:             served.ClassNames,
+        InputHeight:            int32(arch.InputHeight),
+        InputWidth:             int32(arch.InputWidth),
+        InputChannels:          int32(arch.InputChannels),
+        NumClasses:             int32(arch.NumClasses),
+        TotalParameters:        info.TotalParameters,
+        Precision:              info.Precision.String(),
+        WeightQuantization:     info.WeightQuantization.String(),
+        Int8ConvLayers:         int32(info.Int8ConvLayers),
+        ActivationQuantization: info.ActivationQuantization.String(),
+        WeightBytes:            info.WeightBytes,
+        Engine:                 engineOptions(info.Engine),
+        TotalInferences:        info.TotalInferences,
+    }
+    for _, layer := range arch.Layers {
+        response.Layers = append(response.Layers, layer.Name)
+    }
+    if len(info.AverageLayerTimes) > 0 {
+        response.AverageLayerTimesNs = make(map[string]int64, len(info.AverageLayerTimes))
+        for layer, elapsed := range info.AverageLayerTimes {
+            response.AverageLayerTimesNs[layer] = elapsed.Nanoseconds()
+        }
+    }
+    return response
+}
+
+// engineOptions describes convolution engine settings, naming AlgoAuto "auto"
+func engineOptions(options ops.EngineOptions) *inferencepb.EngineOptions {
+    backend := string(options.Backend)
+    if options.Backend == ops.AlgoAuto {
+        backend = "auto"
+    }
+    return &inferencepb.EngineOptions{
+        Backend: backend,
+        Workers: int32(options.Workers),
+        Pooling: options.Pooling,
+    }
+}
+
+// className returns the name of class, or "" when it has none
+func className(names []string, class int) string {
+    if class < 0 || class >= len(names) {
+        return ""
+    }
+    return names[class]
+}
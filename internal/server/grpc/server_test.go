@@ -0,0 +1,280 @@
+package grpc
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/server"
+	"duchm1606/gocnn/internal/server/grpc/inferencepb"
+	"io"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// classPredictor classifies every image as class
+type classPredictor struct {
+    class int
+}
+
+func (p *classPredictor) Predict(ctx context.Context, imageData []float32) (*model.PredictionResult, error) {
+    probabilities := []float32{0.1, 0.1, 0.1}
+    probabilities[p.class] = 0.8
+    return &model.PredictionResult{Probabilities: probabilities, PredictedClass: p.class, Confidence: 0.8}, nil
+}
+
+func (p *classPredictor) PredictBatch(ctx context.Context, images [][]float32) ([]*model.PredictionResult, error) {
+    results := make([]*model.PredictionResult, len(images))
+    for i, image := range images {
+        results[i], _ = p.Predict(ctx, image)
+    }
+    return results, nil
+}
+
+func (p *classPredictor) Info() *model.ModelInfo {
+    return &model.ModelInfo{Architecture: model.GetTinyCNNArchitecture()}
+}
+
+// servedRegistry holds classPredictors for 32x32x3 images: "first" answers
+// class 1, "second" class 2
+func servedRegistry(t *testing.T) *server.Registry {
+    t.Helper()
+    mc := config.ModelConfig{InputHeight: 32, InputWidth: 32, InputChannels: 3}
+    preprocessor, err := data.NewPreprocessor(data.BinaryFloat32, config.DataConfig{}, mc)
+    if err != nil {
+        t.Fatal(err)
+    }
+    registry := server.NewRegistry()
+    for class, name := range []string{"first", "second"} {
+        err := registry.Add(&server.ServedModel{
+            Name:         name,
+            Model:        &classPredictor{class: class + 1},
+            Preprocessor: preprocessor,
+            ClassNames:   []string{"cat", "dog", "frog"},
+        })
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+    return registry
+}
+
+// dial serves srv over an in-memory listener and returns a client of it
+func dial(t *testing.T, srv *Server) inferencepb.InferenceClient {
+    t.Helper()
+    listener := bufconn.Listen(1 << 20)
+    grpcServer := grpc.NewServer()
+    inferencepb.RegisterInferenceServer(grpcServer, srv)
+    go grpcServer.Serve(listener)
+    t.Cleanup(grpcServer.Stop)
+
+    conn, err := grpc.NewClient("passthrough:///bufconn",
+        grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+            return listener.DialContext(ctx)
+        }),
+        grpc.WithTransportCredentials(insecure.NewCredentials()))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { conn.Close() })
+    return inferencepb.NewInferenceClient(conn)
+}
+
+// greyImage returns a 32x32x3 image of mid-grey pixels
+func greyImage() *inferencepb.Image {
+    data := make([]float32, 32*32*3)
+    for i := range data {
+        data[i] = 0.5
+    }
+    return &inferencepb.Image{Data: data}
+}
+
+func TestServerPredict(t *testing.T) {
+    client := dial(t, NewServer(servedRegistry(t)))
+    ctx := context.Background()
+
+    response, err := client.Predict(ctx, &inferencepb.PredictRequest{Image: greyImage(), TopK: 2})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if response.Model != "first" || response.PredictedClass != 1 || response.ClassName != "dog" {
+        t.Errorf("Default model: expected first predicting 1 (dog), got %s predicting %d (%s)",
+            response.Model, response.PredictedClass, response.ClassName)
+    }
+    if len(response.TopK) != 2 || response.TopK[0].ClassName != "dog" {
+        t.Errorf("Expected top 2 led by dog, got %v", response.TopK)
+    }
+    if response.Engine.GetBackend() != "auto" {
+        t.Errorf("Expected the auto backend, got %q", response.Engine.GetBackend())
+    }
+
+    response, err = client.Predict(ctx, &inferencepb.PredictRequest{Image: greyImage(), Model: "second"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if response.PredictedClass != 2 {
+        t.Errorf("Model second: expected class 2, got %d", response.PredictedClass)
+    }
+
+    tests := []struct {
+        name    string
+        request *inferencepb.PredictRequest
+        code    codes.Code
+    }{
+        {"unknown model", &inferencepb.PredictRequest{Image: greyImage(), Model: "missing"}, codes.NotFound},
+        {"wrong size", &inferencepb.PredictRequest{Image: &inferencepb.Image{Data: make([]float32, 10)}}, codes.InvalidArgument},
+        {"no image", &inferencepb.PredictRequest{}, codes.InvalidArgument},
+        {"negative top_k", &inferencepb.PredictRequest{Image: greyImage(), TopK: -1}, codes.InvalidArgument},
+    }
+    for _, tt := range tests {
+        _, err := client.Predict(ctx, tt.request)
+        if status.Code(err) != tt.code {
+            t.Errorf("%s: expected %v, got %v", tt.name, tt.code, err)
+        }
+    }
+}
+
+func TestServerPredictBatch(t *testing.T) {
+    client := dial(t, NewServer(servedRegistry(t)))
+    ctx := context.Background()
+
+    response, err := client.PredictBatch(ctx, &inferencepb.PredictBatchRequest{
+        Images: []*inferencepb.Image{greyImage(), greyImage(), greyImage()},
+        Model:  "second",
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(response.Results) != 3 {
+        t.Fatalf("Expected 3 results, got %d", len(response.Results))
+    }
+    for i, result := range response.Results {
+        if result.PredictedClass != 2 || result.ClassName != "frog" {
+            t.Errorf("Result %d: expected class 2 (frog), got %d (%s)", i, result.PredictedClass, result.ClassName)
+        }
+    }
+
+    _, err = client.PredictBatch(ctx, &inferencepb.PredictBatchRequest{
+        Images: []*inferencepb.Image{greyImage(), {Data: make([]float32, 3)}},
+    })
+    if status.Code(err) != codes.InvalidArgument {
+        t.Errorf("Wrong-sized image: expected InvalidArgument, got %v", err)
+    }
+    if _, err := client.PredictBatch(ctx, &inferencepb.PredictBatchRequest{}); status.Code(err) != codes.InvalidArgument {
+        t.Errorf("Empty batch: expected InvalidArgument, got %v", err)
+    }
+}
+
+func TestServerPredictStream(t *testing.T) {
+    client := dial(t, NewServer(servedRegistry(t)))
+    stream, err := client.PredictStream(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    requests := []*inferencepb.PredictRequest{
+        {Image: greyImage()},
+        {Image: &inferencepb.Image{Data: make([]float32, 3)}},
+        {Image: greyImage(), Model: "missing"},
+        {Image: greyImage(), Model: "second"},
+    }
+    for _, request := range requests {
+        if err := stream.Send(request); err != nil {
+            t.Fatal(err)
+        }
+    }
+    stream.CloseSend()
+
+    var responses []*inferencepb.PredictResponse
+    for {
+        response, err := stream.Recv()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            t.Fatal(err)
+        }
+        responses = append(responses, response)
+    }
+
+    if len(responses) != len(requests) {
+        t.Fatalf("Expected %d responses, got %d", len(requests), len(responses))
+    }
+    if responses[0].PredictedClass != 1 || responses[0].Error != "" {
+        t.Errorf("Response 0: expected class 1, got %d (error %q)", responses[0].PredictedClass, responses[0].Error)
+    }
+    for _, i := range []int{1, 2} {
+        if responses[i].PredictedClass != -1 || responses[i].Error == "" {
+            t.Errorf("Response %d: expected an error, got class %d", i, responses[i].PredictedClass)
+        }
+    }
+    if responses[3].PredictedClass != 2 {
+        t.Errorf("Response 3: a failed image must not end the stream, got class %d (error %q)",
+            responses[3].PredictedClass, responses[3].Error)
+    }
+}
+
+func TestServerGetModelInfo(t *testing.T) {
+    client := dial(t, NewServer(servedRegistry(t)))
+    info, err := client.GetModelInfo(context.Background(), &inferencepb.GetModelInfoRequest{Model: "second"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    arch := model.GetTinyCNNArchitecture()
+    if info.Model != "second" || int(info.InputHeight) != arch.InputHeight || int(info.NumClasses) != arch.NumClasses {
+        t.Errorf("Expected second with %dx%d input and %d classes, got %s with %dx%d and %d",
+            arch.InputHeight, arch.InputWidth, arch.NumClasses, info.Model, info.InputHeight, info.InputWidth, info.NumClasses)
+    }
+    if len(info.Layers) != len(arch.Layers) || len(info.ClassNames) != 3 {
+        t.Errorf("Expected %d layers and 3 class names, got %v and %v", len(arch.Layers), info.Layers, info.ClassNames)
+    }
+
+    _, err = client.GetModelInfo(context.Background(), &inferencepb.GetModelInfoRequest{Model: "missing"})
+    if status.Code(err) != codes.NotFound {
+        t.Errorf("Unknown model: expected NotFound, got %v", err)
+    }
+}
+
+func TestServerAuthAndLimits(t *testing.T) {
+    srv := NewServer(servedRegistry(t))
+    keys, err := server.NewAPIKeys([]string{"key-a", "key-b"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    srv.SetAPIKeys(keys)
+    limiter, err := server.NewLimiter(server.LimitOptions{Rate: 0.5}, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    srv.SetLimiter(limiter)
+    client := dial(t, srv)
+
+    predict := func(md ...string) error {
+        ctx := metadata.AppendToOutgoingContext(context.Background(), md...)
+        _, err := client.Predict(ctx, &inferencepb.PredictRequest{Image: greyImage()})
+        return err
+    }
+
+    if err := predict(); status.Code(err) != codes.Unauthenticated {
+        t.Errorf("No key: expected Unauthenticated, got %v", err)
+    }
+    if err := predict("x-api-key", "wrong"); status.Code(err) != codes.Unauthenticated {
+        t.Errorf("Wrong key: expected Unauthenticated, got %v", err)
+    }
+    if err := predict("authorization", "Bearer key-a"); err != nil {
+        t.Errorf("Bearer key: expected success, got %v", err)
+    }
+    if err := predict("authorization", "Bearer key-a"); status.Code(err) != codes.ResourceExhausted {
+        t.Errorf("Second call with key-a: expected ResourceExhausted, got %v", err)
+    }
+    if err := predict("x-api-key", "key-b"); err != nil {
+        t.Errorf("key-b has its own bucket: expected success, got %v", err)
+    }
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/model"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+/**
+* Health and readiness probes
+
+An orchestrator such as Kubernetes restarts a process whose liveness probe
+fails and routes traffic only to instances whose readiness probe passes.
+Health answers both from the predictor itself:
+```
+/healthz  model loaded      Info() describes an architecture
+          weights valid     ValidateModel(), when the predictor has it
+/readyz   everything above
+          sentinel          a mid-grey image classifies to finite probabilities summing to 1
+```
+Each probe answers 200 when every check passes and 503 otherwise, with one
+line per check in the style of the Kubernetes API server:
+```
+[+]model ok
+[-]sentinel failed: inference produced NaN probabilities
+```
+Probes of a Registry run the checks for every model, prefixed with its name
+(`[+]cifar10/model ok`), so one broken model fails the whole instance.
+
+The sentinel inference costs one Predict per readiness probe; it runs with
+the request's context, bounded by the Health timeout.
+*/
+
+// defaultProbeTimeout bounds the sentinel inference of a readiness probe
+const defaultProbeTimeout = 5 * time.Second
+
+// healthCheck is one named probe check
+type healthCheck struct {
+    name  string
+    check func(ctx context.Context) error
+}
+
+// probeTarget is one predictor checked by the probes; prefix starts its check names
+type probeTarget struct {
+    prefix    string
+    predictor model.Predictor
+}
+
+// Health serves liveness and readiness probes for one or more predictors
+type Health struct {
+    targets func() []probeTarget
+    timeout time.Duration
+}
+
+// NewHealth returns probes for predictor; a zero timeout uses 5 seconds
+// Pass the model itself rather than a wrapper such as Metrics.Instrument, so the
+// weight validation can reach it and sentinel inferences stay out of the metrics.
+func NewHealth(predictor model.Predictor, timeout time.Duration) *Health {
+    targets := []probeTarget{{predictor: predictor}}
+    return newHealth(func() []probeTarget { return targets }, timeout)
+}
+
+// NewRegistryHealth returns probes for every model of registry, checking each
+// ServedModel.Model; a zero timeout uses 5 seconds
+func NewRegistryHealth(registry *Registry, timeout time.Duration) *Health {
+    return newHealth(func() []probeTarget {
+        var targets []probeTarget
+        for _, m := range registry.Models() {
+            targets = append(targets, probeTarget{prefix: m.Name + "/", predictor: m.Model})
+        }
+        return targets
+    }, timeout)
+}
+
+// newHealth returns probes for the predictors targets lists at each probe
+func newHealth(targets func() []probeTarget, timeout time.Duration) *Health {
+    if timeout <= 0 {
+        timeout = defaultProbeTimeout
+    }
+    return &Health{targets: targets, timeout: timeout}
+}
+
+// Register serves the probes on /healthz and /readyz of mux
+func (h *Health) Register(mux *http.ServeMux) {
+    mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+        h.serve(w, r, h.liveChecks())
+    })
+    mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+        h.serve(w, r, h.readyChecks())
+    })
+}
+
+// Live runs the liveness checks and returns the first failure
+func (h *Health) Live(ctx context.Context) error {
+    return runChecks(ctx, h.liveChecks())
+}
+
+// Ready runs the readiness checks and returns the first failure
+func (h *Health) Ready(ctx context.Context) error {
+    ctx, cancel := context.WithTimeout(ctx, h.timeout)
+    defer cancel()
+    return runChecks(ctx, h.readyChecks())
+}
+
+// liveChecks are the checks of /healthz
+func (h *Health) liveChecks() []healthCheck {
+    var checks []healthCheck
+    for _, target := range h.targets() {
+        checks = append(checks, liveChecks(target)...)
+    }
+    return checks
+}
+
+// readyChecks are the checks of /readyz
+func (h *Health) readyChecks() []healthCheck {
+    var checks []healthCheck
+    for _, target := range h.targets() {
+        checks = append(checks, liveChecks(target)...)
+        checks = append(checks, healthCheck{name: target.prefix + "sentinel", check: func(ctx context.Context) error {
+            return checkSentinel(ctx, target.predictor)
+        }})
+    }
+    return checks
+}
+
+// liveChecks are the liveness checks of one predictor
+func liveChecks(target probeTarget) []healthCheck {
+    return []healthCheck{
+        {name: target.prefix + "model", check: func(ctx context.Context) error { return checkLoaded(target.predictor) }},
+        {name: target.prefix + "weights", check: func(ctx context.Context) error { return checkWeights(target.predictor) }},
+    }
+}
+
+// runChecks returns the first failing check's error
+func runChecks(ctx context.Context, checks []healthCheck) error {
+    if len(checks) == 0 {
+        return fmt.Errorf("no models are loaded")
+    }
+    for _, c := range checks {
+        if err := c.check(ctx); err != nil {
+            return fmt.Errorf("%s: %w", c.name, err)
+        }
+    }
+    return nil
+}
+
+// serve runs every check and writes one line per check
+func (h *Health) serve(w http.ResponseWriter, r *http.Request, checks []healthCheck) {
+    ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+    defer cancel()
+
+    var body []byte
+    healthy := len(checks) > 0
+    if !healthy {
+        body = fmt.Appendf(body, "[-]models failed: no models are loaded\n")
+    }
+    for _, c := range checks {
+        if err := c.check(ctx); err != nil {
+            healthy = false
+            body = fmt.Appendf(body, "[-]%s failed: %v\n", c.name, err)
+            continue
+        }
+        body = fmt.Appendf(body, "[+]%s ok\n", c.name)
+    }
+
+    w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+    w.Header().Set("Cache-Control", "no-store")
+    if !healthy {
+        w.WriteHeader(http.StatusServiceUnavailable)
+    }
+    w.Write(body)
+}
+
+// checkLoaded verifies the predictor describes a usable architecture
+func checkLoaded(predictor model.Predictor) error {
+    info := predictor.Info()
+    if info == nil || info.Architecture == nil {
+        return fmt.Errorf("no model loaded")
+    }
+    if len(info.Architecture.Layers) == 0 {
+        return fmt.Errorf("model has no layers")
+    }
+    return nil
+}
+
+// checkWeights runs the predictor's own weight validation, if it has one
+func checkWeights(predictor model.Predictor) error {
+    validator, ok := predictor.(interface{ ValidateModel() error })
+    if !ok {
+        return nil
+    }
+    return validator.ValidateModel()
+}
+
+// checkSentinel classifies a mid-grey image and checks the probabilities are sane
+func checkSentinel(ctx context.Context, predictor model.Predictor) error {
+    arch := predictor.Info().Architecture
+    if arch == nil {
+        return fmt.Errorf("no model loaded")
+    }
+    sentinel := make([]float32, arch.InputSize())
+    for i := range sentinel {
+        sentinel[i] = 0.5
+    }
+
+    result, err := predictor.Predict(ctx, sentinel)
+    if err != nil {
+        return err
+    }
+
+    var sum float64
+    for _, p := range result.Probabilities {
+        if math.IsNaN(float64(p)) || math.IsInf(float64(p), 0) {
+            return fmt.Errorf("inference produced %v probabilities", p)
+        }
+        sum += float64(p)
+    }
+    if len(result.Probabilities) == 0 || math.Abs(sum-1) > 1e-3 {
+        return fmt.Errorf("probabilities sum to %g over %d classes", sum, len(result.Probabilities))
+    }
+    return nil
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/model"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// probePredictor answers with fixed probabilities and validates with validateErr
+type probePredictor struct {
+    probabilities []float32
+    validateErr   error
+}
+
+func (p *probePredictor) Predict(ctx context.Context, imageData []float32) (*model.PredictionResult, error) {
+    return &model.PredictionResult{Probabilities: p.probabilities}, nil
+}
+
+func (p *probePredictor) PredictBatch(ctx context.Context, images [][]float32) ([]*model.PredictionResult, error) {
+    return nil, errors.New("not used")
+}
+
+func (p *probePredictor) Info() *model.ModelInfo {
+    return &model.ModelInfo{Architecture: model.GetTinyCNNArchitecture()}
+}
+
+func (p *probePredictor) ValidateModel() error {
+    return p.validateErr
+}
+
+// probe requests path from h's handlers and returns the status and body
+func probe(h *Health, path string) (int, string) {
+    mux := http.NewServeMux()
+    h.Register(mux)
+    recorder := httptest.NewRecorder()
+    mux.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+    return recorder.Code, recorder.Body.String()
+}
+
+func TestHealthProbes(t *testing.T) {
+    healthy := NewHealth(&probePredictor{probabilities: []float32{0.25, 0.75}}, 0)
+    for _, path := range []string{"/healthz", "/readyz"} {
+        if code, body := probe(healthy, path); code != http.StatusOK {
+            t.Errorf("%s: expected 200, got %d:\n%s", path, code, body)
+        }
+    }
+    if _, body := probe(healthy, "/readyz"); !strings.Contains(body, "[+]sentinel ok") {
+        t.Errorf("Readiness should report the sentinel check:\n%s", body)
+    }
+
+    // Invalid weights fail both probes
+    invalid := NewHealth(&probePredictor{probabilities: []float32{1}, validateErr: errors.New("kernel 0 is empty")}, 0)
+    code, body := probe(invalid, "/healthz")
+    if code != http.StatusServiceUnavailable || !strings.Contains(body, "[-]weights failed: kernel 0 is empty") {
+        t.Errorf("Expected a failed weights check, got %d:\n%s", code, body)
+    }
+    if err := invalid.Ready(context.Background()); err == nil {
+        t.Error("Expected Ready to fail with invalid weights")
+    }
+
+    // A broken inference leaves the process live but not ready
+    broken := NewHealth(&probePredictor{probabilities: []float32{float32(math.NaN()), 1}}, 0)
+    if code, _ := probe(broken, "/healthz"); code != http.StatusOK {
+        t.Errorf("Expected live despite a broken inference, got %d", code)
+    }
+    code, body = probe(broken, "/readyz")
+    if code != http.StatusServiceUnavailable || !strings.Contains(body, "[-]sentinel failed") {
+        t.Errorf("Expected a failed sentinel check, got %d:\n%s", code, body)
+    }
+}
@@ -0,0 +1,314 @@
+package server
+
+import (
+	"crypto/sha256"
+	"duchm1606/gocnn/internal/model"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+/**
+* HTTP prediction API
+
+The API answers for every model of a Registry. A request names its model in
+the path or the query, or gets the default model:
+```
+GET  /v1/models                       list the served models
+POST /v1/models/{name}/predict        classify the body with model name
+POST /v1/predict?model=name           the same; without ?model= the default model
+GET  /v1/models/{name}/stream         WebSocket of frames in, predictions out (see stream.go)
+GET  /v1/stream?model=name
+```
+The body is the image itself: PNG or JPEG data, or a binary image in the
+server's -image-format. It goes through the model's Preprocessor exactly as
+an image file would in the CLIs, so resizing and normalization match. ?topk=N
+adds the N most probable classes to the response:
+```
+{"model":"cifar10","predicted_class":6,"class_name":"frog","confidence":0.9998,
+ "probabilities":[...],"top_k":[{"class":6,"class_name":"frog","probability":0.9998}],
+ "inference_time_ns":812345}
+```
+Errors are JSON too, {"error": "..."}, with 404 for an unknown model, 400 for
+a body that isn't an image of the right size, 413 for an oversized body, 429
+with Retry-After when a Limiter turns the request away (see limit.go) and
+500 when inference fails.
+*/
+
+// DefaultMaxRequestBytes bounds request bodies; a 224x224x3 float32 image is under 1 MB
+const DefaultMaxRequestBytes = 8 << 20
+
+// API serves predictions for the models of a registry
+type API struct {
+    registry        *Registry
+    maxRequestBytes int64
+    limiter         *Limiter  // Optional concurrency and rate limits
+    audit           *AuditLog // Optional record of every prediction
+}
+
+// NewAPI returns the prediction API of registry
+func NewAPI(registry *Registry) *API {
+    return &API{registry: registry, maxRequestBytes: DefaultMaxRequestBytes}
+}
+
+// SetMaxRequestBytes bounds request bodies to n bytes
+func (a *API) SetMaxRequestBytes(n int64) {
+    a.maxRequestBytes = n
+}
+
+// SetLimiter bounds concurrent predictions and each client's request rate; nil removes the limits
+func (a *API) SetLimiter(limiter *Limiter) {
+    a.limiter = limiter
+}
+
+// SetAuditLog records every prediction in log; nil stops recording
+func (a *API) SetAuditLog(log *AuditLog) {
+    a.audit = log
+}
+
+// recordAudit appends entry to the audit log, if any; an entry that cannot be
+// written is logged, since the response has already gone out
+func (a *API) recordAudit(entry *AuditEntry) {
+    if err := a.audit.Record(entry); err != nil {
+        slog.Error("failed to record audit entry", "request_id", entry.RequestID, "error", err)
+    }
+}
+
+// Register serves the API on mux
+func (a *API) Register(mux *http.ServeMux) {
+    mux.HandleFunc("GET /v1/models", a.listModels)
+    mux.HandleFunc("POST /v1/models/{name}/predict", func(w http.ResponseWriter, r *http.Request) {
+        a.predict(w, r, r.PathValue("name"))
+    })
+    mux.HandleFunc("POST /v1/predict", func(w http.ResponseWriter, r *http.Request) {
+        a.predict(w, r, r.URL.Query().Get("model"))
+    })
+    mux.HandleFunc("GET /v1/models/{name}/stream", func(w http.ResponseWriter, r *http.Request) {
+        a.stream(w, r, r.PathValue("name"))
+    })
+    mux.HandleFunc("GET /v1/stream", func(w http.ResponseWriter, r *http.Request) {
+        a.stream(w, r, r.URL.Query().Get("model"))
+    })
+}
+
+// modelResponse describes one served model
+type modelResponse struct {
+    Name          string   `json:"name"`
+    Default       bool     `json:"default"`
+    InputHeight   int      `json:"input_height"`
+    InputWidth    int      `json:"input_width"`
+    InputChannels int      `json:"input_channels"`
+    NumClasses    int      `json:"num_classes"`
+    ClassNames    []string `json:"class_names,omitempty"`
+    Precision     string   `json:"precision"`
+    Quantization  string   `json:"weight_quantization"`
+}
+
+// classResponse is one entry of a prediction's top-k list
+type classResponse struct {
+    Class       int     `json:"class"`
+    ClassName   string  `json:"class_name,omitempty"`
+    Probability float32 `json:"probability"`
+}
+
+// predictResponse is the answer to a prediction request
+type predictResponse struct {
+    Model           string          `json:"model"`
+    PredictedClass  int             `json:"predicted_class"`
+    ClassName       string          `json:"class_name,omitempty"`
+    Confidence      float32         `json:"confidence"`
+    Probabilities   []float32       `json:"probabilities"`
+    TopK            []classResponse `json:"top_k,omitempty"`
+    InferenceTimeNs int64           `json:"inference_time_ns"`
+}
+
+// errorResponse is the body of every failed request
+type errorResponse struct {
+    Error string `json:"error"`
+}
+
+// listModels answers GET /v1/models
+func (a *API) listModels(w http.ResponseWriter, r *http.Request) {
+    models := []modelResponse{}
+    for i, m := range a.registry.Models() {
+        info := m.Model.Info()
+        models = append(models, modelResponse{
+            Name:          m.Name,
+            Default:       i == 0,
+            InputHeight:   info.Architecture.InputHeight,
+            InputWidth:    info.Architecture.InputWidth,
+            InputChannels: info.Architecture.InputChannels,
+            NumClasses:    info.Architecture.NumClasses,
+            ClassNames:    m.ClassNames,
+            Precision:     info.Precision.String(),
+            Quantization:  info.WeightQuantization.String(),
+        })
+    }
+    writeJSON(w, http.StatusOK, map[string]any{"models": models})
+}
+
+// predict classifies the request body with the model called name
+func (a *API) predict(w http.ResponseWriter, r *http.Request, name string) {
+    entry := newAuditEntry(r, "predict")
+    w.Header().Set("X-Request-ID", entry.RequestID)
+    if a.audit != nil {
+        w = &auditWriter{ResponseWriter: w, entry: entry}
+        defer a.recordAudit(entry)
+    }
+
+    served, err := a.registry.Get(name)
+    if err != nil {
+        writeModelError(w, err)
+        return
+    }
+    entry.Model = served.Name
+    topK, err := parseTopK(r)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+    if err := a.limiter.Allow(entry.Client); err != nil {
+        writeLimitError(w, err)
+        return
+    }
+
+    raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, a.maxRequestBytes))
+    if err != nil {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", tooLarge.Limit))
+            return
+        }
+        writeError(w, http.StatusBadRequest, fmt.Errorf("failed to read request body: %w", err))
+        return
+    }
+    if len(raw) == 0 {
+        writeError(w, http.StatusBadRequest, fmt.Errorf("request body is empty; send the image as the body"))
+        return
+    }
+
+    entry.setInput(raw)
+
+    fm, err := served.Preprocessor.Decode(raw)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    release, err := a.limiter.Acquire(r.Context(), "predict")
+    if err != nil {
+        writeLimitError(w, err)
+        return
+    }
+    result, err := served.Predictor.Predict(r.Context(), fm.Data)
+    release()
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, fmt.Errorf("inference failed: %w", err))
+        return
+    }
+
+    response := newPredictResponse(served, result, topK)
+    entry.setResult(response)
+    writeJSON(w, http.StatusOK, response)
+}
+
+// parseTopK returns the ?topk= of r, 0 when absent
+func parseTopK(r *http.Request) (int, error) {
+    value := r.URL.Query().Get("topk")
+    if value == "" {
+        return 0, nil
+    }
+    topK, err := strconv.Atoi(value)
+    if err != nil || topK < 0 {
+        return 0, fmt.Errorf("topk must be a non-negative integer, got %q", value)
+    }
+    return topK, nil
+}
+
+// newPredictResponse describes result, with its topK most probable classes
+func newPredictResponse(served *ServedModel, result *model.PredictionResult, topK int) *predictResponse {
+    response := &predictResponse{
+        Model:           served.Name,
+        PredictedClass:  result.PredictedClass,
+        ClassName:       className(served.ClassNames, result.PredictedClass),
+        Confidence:      result.Confidence,
+        Probabilities:   result.Probabilities,
+        InferenceTimeNs: result.TotalTime.Nanoseconds(),
+    }
+    if topK > 0 {
+        for _, c := range result.TopK(topK) {
+            response.TopK = append(response.TopK, classResponse{
+                Class:       c.Class,
+                ClassName:   className(served.ClassNames, c.Class),
+                Probability: c.Probability,
+            })
+        }
+    }
+    return response
+}
+
+// className returns the name of class, or "" when it has none
+func className(names []string, class int) string {
+    if class < 0 || class >= len(names) {
+        return ""
+    }
+    return names[class]
+}
+
+// writeJSON writes value as the JSON body of a response with status
+func writeJSON(w http.ResponseWriter, status int, value any) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(value)
+}
+
+// clientID tells clients apart for rate limiting: by API key once APIKeys has
+// validated it, else by address. An unchecked key would let a client mint a
+// fresh bucket per request just by changing a header
+func clientID(r *http.Request) string {
+    if key := validatedKey(r); key != "" {
+        digest := sha256.Sum256([]byte(key))
+        return "key:" + hex.EncodeToString(digest[:8])
+    }
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        return r.RemoteAddr
+    }
+    return host
+}
+
+// writeLimitError answers a request the limiter turned away, or whose wait was cancelled
+func writeLimitError(w http.ResponseWriter, err error) {
+    var limitErr *LimitError
+    if !errors.As(err, &limitErr) {
+        writeError(w, http.StatusServiceUnavailable, fmt.Errorf("request abandoned while waiting: %w", err))
+        return
+    }
+    seconds := int(math.Ceil(limitErr.RetryAfter.Seconds()))
+    w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+    writeError(w, http.StatusTooManyRequests, err)
+}
+
+// writeModelError writes the error of a failed Registry.Get
+func writeModelError(w http.ResponseWriter, err error) {
+    status := http.StatusInternalServerError
+    if errors.Is(err, ErrUnknownModel) {
+        status = http.StatusNotFound
+    }
+    writeError(w, status, err)
+}
+
+// writeError writes err as a JSON error response with status
+func writeError(w http.ResponseWriter, status int, err error) {
+    if audited, ok := w.(*auditWriter); ok {
+        audited.entry.Error = err.Error()
+    }
+    writeJSON(w, status, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"duchm1606/gocnn/internal/config"
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/model"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// classPredictor classifies every image as class, recording the input size it saw
+type classPredictor struct {
+    class     int
+    inputSize atomic.Int64
+}
+
+func (p *classPredictor) Predict(ctx context.Context, imageData []float32) (*model.PredictionResult, error) {
+    p.inputSize.Store(int64(len(imageData)))
+    probabilities := []float32{0.1, 0.1, 0.1}
+    probabilities[p.class] = 0.8
+    return &model.PredictionResult{Probabilities: probabilities, PredictedClass: p.class, Confidence: 0.8}, nil
+}
+
+func (p *classPredictor) PredictBatch(ctx context.Context, images [][]float32) ([]*model.PredictionResult, error) {
+    results := make([]*model.PredictionResult, len(images))
+    for i, image := range images {
+        results[i], _ = p.Predict(ctx, image)
+    }
+    return results, nil
+}
+
+func (p *classPredictor) Info() *model.ModelInfo {
+    return &model.ModelInfo{Architecture: model.GetTinyCNNArchitecture()}
+}
+
+// servedModel registers a classPredictor for 32x32x3 float32 images under name
+func servedModel(t *testing.T, registry *Registry, name string, class int) *classPredictor {
+    t.Helper()
+    mc := config.ModelConfig{InputHeight: 32, InputWidth: 32, InputChannels: 3}
+    preprocessor, err := data.NewPreprocessor(data.BinaryFloat32, config.DataConfig{}, mc)
+    if err != nil {
+        t.Fatal(err)
+    }
+    predictor := &classPredictor{class: class}
+    err = registry.Add(&ServedModel{
+        Name:         name,
+        Model:        predictor,
+        Preprocessor: preprocessor,
+        ClassNames:   []string{"cat", "dog", "frog"},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    return predictor
+}
+
+// binaryImage returns a 32x32x3 float32 image file of mid-grey pixels
+func binaryImage() []byte {
+    var raw []byte
+    for i := 0; i < 32*32*3; i++ {
+        raw = binary.LittleEndian.AppendUint32(raw, math.Float32bits(0.5))
+    }
+    return raw
+}
+
+// request sends a request to api and returns the status and body
+func request(api *API, method, target string, body []byte) (int, string) {
+    mux := http.NewServeMux()
+    api.Register(mux)
+    recorder := httptest.NewRecorder()
+    mux.ServeHTTP(recorder, httptest.NewRequest(method, target, bytes.NewReader(body)))
+    return recorder.Code, recorder.Body.String()
+}
+
+func TestAPIRoutesByModel(t *testing.T) {
+    registry := NewRegistry()
+    servedModel(t, registry, "first", 0)
+    second := servedModel(t, registry, "second", 2)
+    api := NewAPI(registry)
+
+    if err := registry.Add(&ServedModel{Name: "first", Model: second, Preprocessor: &data.Preprocessor{}}); err == nil {
+        t.Error("Adding a second model with the same name should fail")
+    }
+
+    for _, tc := range []struct {
+        target string
+        model  string
+        class  int
+    }{
+        {"/v1/predict", "first", 0},
+        {"/v1/predict?model=second", "second", 2},
+        {"/v1/models/second/predict?topk=2", "second", 2},
+    } {
+        code, body := request(api, "POST", tc.target, binaryImage())
+        if code != http.StatusOK {
+            t.Fatalf("%s: expected 200, got %d: %s", tc.target, code, body)
+        }
+        var response predictResponse
+        if err := json.Unmarshal([]byte(body), &response); err != nil {
+            t.Fatalf("%s: invalid JSON: %v", tc.target, err)
+        }
+        if response.Model != tc.model || response.PredictedClass != tc.class {
+            t.Errorf("%s: expected %s class %d, got %s class %d", tc.target, tc.model, tc.class,
+                response.Model, response.PredictedClass)
+        }
+        if strings.Contains(tc.target, "topk=2") && (len(response.TopK) != 2 || response.TopK[0].ClassName != "frog") {
+            t.Errorf("%s: expected frog first of 2 classes, got %+v", tc.target, response.TopK)
+        }
+    }
+
+    // PNG data of another size is decoded and resized to the model input
+    img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+    for i := range img.Pix {
+        img.Pix[i] = 200
+    }
+    img.Set(0, 0, color.RGBA{R: 255, A: 255})
+    var encoded bytes.Buffer
+    if err := png.Encode(&encoded, img); err != nil {
+        t.Fatal(err)
+    }
+    if code, body := request(api, "POST", "/v1/models/second/predict", encoded.Bytes()); code != http.StatusOK {
+        t.Fatalf("PNG: expected 200, got %d: %s", code, body)
+    }
+    if size := second.inputSize.Load(); size != 32*32*3 {
+        t.Errorf("Expected a 32x32x3 model input, got %d values", size)
+    }
+
+    code, body := request(api, "GET", "/v1/models", nil)
+    if code != http.StatusOK || !strings.Contains(body, `"name":"first","default":true`) || !strings.Contains(body, `"name":"second","default":false`) {
+        t.Errorf("Unexpected model list %d: %s", code, body)
+    }
+}
+
+func TestAPIErrors(t *testing.T) {
+    registry := NewRegistry()
+    servedModel(t, registry, "only", 1)
+    api := NewAPI(registry)
+    api.SetMaxRequestBytes(32 * 32 * 3 * 4)
+
+    for _, tc := range []struct {
+        name   string
+        target string
+        body   []byte
+        status int
+        want   string
+    }{
+        {"unknown model", "/v1/models/missing/predict", binaryImage(), http.StatusNotFound, "unknown model"},
+        {"unknown query model", "/v1/predict?model=missing", binaryImage(), http.StatusNotFound, "unknown model"},
+        {"empty body", "/v1/predict", nil, http.StatusBadRequest, "empty"},
+        {"wrong size", "/v1/predict", binaryImage()[:400], http.StatusBadRequest, "wrong size"},
+        {"bad topk", "/v1/predict?topk=x", binaryImage(), http.StatusBadRequest, "topk"},
+        {"too large", "/v1/predict", append(binaryImage(), 0), http.StatusRequestEntityTooLarge, "exceeds"},
+    } {
+        code, body := request(api, "POST", tc.target, tc.body)
+        if code != tc.status || !strings.Contains(body, tc.want) {
+            t.Errorf("%s: expected %d with %q, got %d: %s", tc.name, tc.status, tc.want, code, body)
+        }
+        if !strings.HasPrefix(body, `{"error":`) {
+            t.Errorf("%s: expected a JSON error, got %s", tc.name, body)
+        }
+    }
+}
+
+func TestRegistryHealth(t *testing.T) {
+    registry := NewRegistry()
+    registry.Add(&ServedModel{Name: "good", Model: &probePredictor{probabilities: []float32{1}}, Preprocessor: &data.Preprocessor{}})
+    registry.Add(&ServedModel{Name: "bad", Model: &probePredictor{probabilities: []float32{0.5}}, Preprocessor: &data.Preprocessor{}})
+
+    code, body := probe(NewRegistryHealth(registry, 0), "/readyz")
+    if code != http.StatusServiceUnavailable {
+        t.Errorf("One failing model should fail readiness, got %d", code)
+    }
+    if !strings.Contains(body, "[+]good/sentinel ok") || !strings.Contains(body, "[-]bad/sentinel failed") {
+        t.Errorf("Expected checks per model:\n%s", body)
+    }
+
+    if code, body := probe(NewRegistryHealth(NewRegistry(), 0), "/healthz"); code != http.StatusServiceUnavailable {
+        t.Errorf("An empty registry should not be healthy, got %d:\n%s", code, body)
+    }
+}
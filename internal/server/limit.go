@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+/**
+* Concurrency limiting and per-client rate limits
+
+The model is CPU-bound: with more requests running than cores, every one
+of them gets slower, and past some point no request finishes within its
+client's timeout. The Limiter keeps the server on the good side of that
+curve with two checks:
+```
+request ──> rate: the client's token bucket has a token? ──no──> 429 rate_limited
+               │ yes
+               v
+            a free in-flight slot? ──yes──> predict
+               │ no
+               v
+            fewer than MaxQueue waiting? ──no──> 429 overloaded
+               │ yes
+               v
+            wait up to QueueTimeout for a slot ──timeout──> 429 queue_timeout
+```
+A token bucket holds up to Burst tokens and refills at Rate per second;
+each request takes one, so a client may burst briefly but averages at
+most Rate requests per second. Clients are told apart by validated API key, or by
+address when auth is off. 429 answers carry Retry-After.
+
+Waiting in the queue is observed as gocnn_request_queue_seconds, and turned
+away requests as gocnn_requests_rejected_total{reason}, so a dashboard shows
+how close the server runs to its limits.
+*/
+
+// idleBucketTTL is how long an untouched client bucket is kept
+const idleBucketTTL = 10 * time.Minute
+
+// LimitOptions configures a Limiter; zero values disable each limit
+type LimitOptions struct {
+    MaxInFlight  int           // Predictions running at once; 0 is unlimited
+    MaxQueue     int           // Requests waiting for a slot before new ones are turned away
+    QueueTimeout time.Duration // Longest wait for a slot; 0 waits as long as the request lives
+    Rate         float64       // Requests per second per client; 0 is unlimited
+    Burst        int           // Requests a client may make at once (default: Rate rounded up)
+}
+
+// LimitError is a request turned away by a Limiter
+type LimitError struct {
+    Reason     string        // rate_limited, overloaded or queue_timeout
+    RetryAfter time.Duration // When trying again may succeed
+}
+
+func (e *LimitError) Error() string {
+    switch e.Reason {
+    case "rate_limited":
+        return "rate limit exceeded"
+    case "queue_timeout":
+        return "server is busy: timed out waiting for a free slot"
+    default:
+        return "server is overloaded: too many requests waiting"
+    }
+}
+
+// tokenBucket is one client's rate limit state
+type tokenBucket struct {
+    tokens float64
+    last   time.Time
+}
+
+// Limiter bounds concurrent predictions and the request rate of each client
+// It is safe for concurrent use.
+type Limiter struct {
+    options LimitOptions
+    metrics *Metrics // Optional; receives queue times and rejections
+    slots   chan struct{}
+
+    mu        sync.Mutex
+    waiting   int
+    clients   map[string]*tokenBucket
+    lastSweep time.Time
+    now       func() time.Time
+}
+
+// NewLimiter returns a limiter with options, recording into metrics if not nil
+func NewLimiter(options LimitOptions, metrics *Metrics) (*Limiter, error) {
+    if options.MaxInFlight < 0 || options.MaxQueue < 0 || options.QueueTimeout < 0 || options.Rate < 0 || options.Burst < 0 {
+        return nil, fmt.Errorf("limits must not be negative: %+v", options)
+    }
+    if options.Rate > 0 && options.Burst == 0 {
+        options.Burst = int(math.Ceil(options.Rate))
+    }
+
+    l := &Limiter{options: options, metrics: metrics, clients: make(map[string]*tokenBucket), now: time.Now}
+    if options.MaxInFlight > 0 {
+        l.slots = make(chan struct{}, options.MaxInFlight)
+    }
+    return l, nil
+}
+
+// Allow takes one token from client's bucket, or returns a *LimitError when it is empty
+func (l *Limiter) Allow(client string) error {
+    if l == nil || l.options.Rate == 0 {
+        return nil
+    }
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    now := l.now()
+    l.sweep(now)
+
+    bucket := l.clients[client]
+    if bucket == nil {
+        bucket = &tokenBucket{tokens: float64(l.options.Burst), last: now}
+        l.clients[client] = bucket
+    }
+    bucket.tokens = math.Min(float64(l.options.Burst), bucket.tokens+now.Sub(bucket.last).Seconds()*l.options.Rate)
+    bucket.last = now
+
+    if bucket.tokens < 1 {
+        wait := time.Duration((1 - bucket.tokens) / l.options.Rate * float64(time.Second))
+        return l.reject(&LimitError{Reason: "rate_limited", RetryAfter: wait})
+    }
+    bucket.tokens--
+    return nil
+}
+
+// sweep drops buckets idle long enough to have refilled, at most once per TTL
+func (l *Limiter) sweep(now time.Time) {
+    if now.Sub(l.lastSweep) < idleBucketTTL {
+        return
+    }
+    l.lastSweep = now
+    for client, bucket := range l.clients {
+        if now.Sub(bucket.last) > idleBucketTTL {
+            delete(l.clients, client)
+        }
+    }
+}
+
+// Acquire waits for an in-flight slot and returns the function that frees it
+// It returns a *LimitError when the queue is full or the wait times out, and
+// ctx.Err() when ctx ends first. endpoint labels the queue time metric.
+func (l *Limiter) Acquire(ctx context.Context, endpoint string) (release func(), err error) {
+    if l == nil || l.slots == nil {
+        return func() {}, nil
+    }
+
+    start := time.Now()
+    select {
+    case l.slots <- struct{}{}:
+        l.metrics.observeQueueTime(endpoint, 0)
+        return l.release, nil
+    default:
+    }
+
+    l.mu.Lock()
+    if l.waiting >= l.options.MaxQueue {
+        l.mu.Unlock()
+        return nil, l.reject(&LimitError{Reason: "overloaded", RetryAfter: time.Second})
+    }
+    l.waiting++
+    l.mu.Unlock()
+    l.metrics.addWaiting(1)
+    defer func() {
+        l.mu.Lock()
+        l.waiting--
+        l.mu.Unlock()
+        l.metrics.addWaiting(-1)
+    }()
+
+    var timeout <-chan time.Time
+    if l.options.QueueTimeout > 0 {
+        timer := time.NewTimer(l.options.QueueTimeout)
+        defer timer.Stop()
+        timeout = timer.C
+    }
+
+    select {
+    case l.slots <- struct{}{}:
+        l.metrics.observeQueueTime(endpoint, time.Since(start))
+        return l.release, nil
+    case <-timeout:
+        return nil, l.reject(&LimitError{Reason: "queue_timeout", RetryAfter: time.Second})
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    }
+}
+
+// release frees a slot taken by Acquire
+func (l *Limiter) release() {
+    <-l.slots
+}
+
+// reject records err in the metrics and returns it
+func (l *Limiter) reject(err *LimitError) error {
+    l.metrics.countRejected(err.Reason)
+    return err
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// limitReason returns the reason of a *LimitError, or "" for any other error
+func limitReason(err error) string {
+    var limitErr *LimitError
+    if errors.As(err, &limitErr) {
+        return limitErr.Reason
+    }
+    return ""
+}
+
+func TestLimiterRate(t *testing.T) {
+    limiter, err := NewLimiter(LimitOptions{Rate: 2, Burst: 2}, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    now := time.Unix(0, 0)
+    limiter.now = func() time.Time { return now }
+
+    for i := 0; i < 2; i++ {
+        if err := limiter.Allow("a"); err != nil {
+            t.Fatalf("Request %d within the burst was refused: %v", i, err)
+        }
+    }
+    err = limiter.Allow("a")
+    if limitReason(err) != "rate_limited" {
+        t.Fatalf("Expected rate_limited after the burst, got %v", err)
+    }
+    if retry := err.(*LimitError).RetryAfter; retry != 500*time.Millisecond {
+        t.Errorf("Expected a retry after 500ms at 2 req/s, got %v", retry)
+    }
+    if err := limiter.Allow("b"); err != nil {
+        t.Errorf("Another client should have its own bucket: %v", err)
+    }
+
+    now = now.Add(500 * time.Millisecond)
+    if err := limiter.Allow("a"); err != nil {
+        t.Errorf("A token should have refilled after 500ms: %v", err)
+    }
+}
+
+func TestLimiterQueue(t *testing.T) {
+    metrics := NewMetrics()
+    limiter, err := NewLimiter(LimitOptions{MaxInFlight: 1, MaxQueue: 1, QueueTimeout: 50 * time.Millisecond}, metrics)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    release, err := limiter.Acquire(context.Background(), "predict")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // The second request queues; the third finds the queue full
+    queued := make(chan error)
+    go func() {
+        _, err := limiter.Acquire(context.Background(), "predict")
+        queued <- err
+    }()
+    for deadline := time.Now().Add(time.Second); ; {
+        limiter.mu.Lock()
+        waiting := limiter.waiting
+        limiter.mu.Unlock()
+        if waiting == 1 || time.Now().After(deadline) {
+            break
+        }
+        time.Sleep(time.Millisecond)
+    }
+    if _, err := limiter.Acquire(context.Background(), "predict"); limitReason(err) != "overloaded" {
+        t.Errorf("Expected overloaded with a full queue, got %v", err)
+    }
+    if err := <-queued; limitReason(err) != "queue_timeout" {
+        t.Errorf("Expected queue_timeout while the slot is held, got %v", err)
+    }
+
+    // A freed slot is taken again at once
+    release()
+    release, err = limiter.Acquire(context.Background(), "predict")
+    if err != nil {
+        t.Fatalf("Free slot was refused: %v", err)
+    }
+    release()
+
+    // A cancelled wait returns the context's error
+    release, _ = limiter.Acquire(context.Background(), "predict")
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, err := limiter.Acquire(ctx, "predict"); !errors.Is(err, context.Canceled) {
+        t.Errorf("Expected context.Canceled, got %v", err)
+    }
+    release()
+
+    recorder := httptest.NewRecorder()
+    metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+    for _, want := range []string{
+        `gocnn_requests_rejected_total{reason="overloaded"} 1`,
+        `gocnn_requests_rejected_total{reason="queue_timeout"} 1`,
+        `gocnn_request_queue_seconds_count{endpoint="predict"} 3`,
+        `gocnn_requests_waiting 0`,
+    } {
+        if !strings.Contains(recorder.Body.String(), want) {
+            t.Errorf("Exposition lacks %q:\n%s", want, recorder.Body.String())
+        }
+    }
+}
+
+func TestAPIRateLimit(t *testing.T) {
+    registry := NewRegistry()
+    servedModel(t, registry, "only", 1)
+    api := NewAPI(registry)
+    limiter, err := NewLimiter(LimitOptions{Rate: 0.5, MaxInFlight: 2}, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    api.SetLimiter(limiter)
+
+    mux := http.NewServeMux()
+    api.Register(mux)
+    send := func() *httptest.ResponseRecorder {
+        recorder := httptest.NewRecorder()
+        mux.ServeHTTP(recorder, httptest.NewRequest("POST", "/v1/predict", strings.NewReader(string(binaryImage()))))
+        return recorder
+    }
+
+    if recorder := send(); recorder.Code != http.StatusOK {
+        t.Fatalf("First request: expected 200, got %d: %s", recorder.Code, recorder.Body)
+    }
+    recorder := send()
+    if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") != "2" {
+        t.Errorf("Second request: expected 429 retrying after 2s, got %d %q: %s",
+            recorder.Code, recorder.Header().Get("Retry-After"), recorder.Body)
+    }
+}
+
+func TestAPIRateLimitKeys(t *testing.T) {
+    registry := NewRegistry()
+    servedModel(t, registry, "only", 1)
+    api := NewAPI(registry)
+    limiter, err := NewLimiter(LimitOptions{Rate: 0.5, MaxInFlight: 2}, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    api.SetLimiter(limiter)
+
+    mux := http.NewServeMux()
+    api.Register(mux)
+    keys, err := NewAPIKeys([]string{"key-a", "key-b"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    authed := keys.Wrap(mux)
+    send := func(handler http.Handler, key string) int {
+        request := httptest.NewRequest("POST", "/v1/predict", strings.NewReader(string(binaryImage())))
+        request.Header.Set("X-API-Key", key)
+        recorder := httptest.NewRecorder()
+        handler.ServeHTTP(recorder, request)
+        return recorder.Code
+    }
+
+    // Without auth a made-up key is just a header: the address decides
+    if code := send(mux, "fake-1"); code != http.StatusOK {
+        t.Fatalf("First unauthenticated request: expected 200, got %d", code)
+    }
+    if code := send(mux, "fake-2"); code != http.StatusTooManyRequests {
+        t.Errorf("Rotated fake key: expected 429, got %d", code)
+    }
+
+    // Validated keys each get their own bucket, apart from the address's
+    for _, key := range []string{"key-a", "key-b"} {
+        if code := send(authed, key); code != http.StatusOK {
+            t.Errorf("First request with %s: expected 200, got %d", key, code)
+        }
+    }
+    if code := send(authed, "key-a"); code != http.StatusTooManyRequests {
+        t.Errorf("Second request with key-a: expected 429, got %d", code)
+    }
+}
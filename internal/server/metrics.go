@@ -0,0 +1,313 @@
+package server
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/model"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/**
+* Prometheus metrics
+
+A service built on a Predictor is monitored from outside by scraping
+/metrics. Metrics wraps the predictor, counts every call and writes the
+counts in the Prometheus text exposition format, which needs no client
+library:
+```
+# TYPE gocnn_request_duration_seconds histogram
+gocnn_request_duration_seconds_bucket{method="predict",le="0.005"} 12
+...
+gocnn_request_duration_seconds_sum{method="predict"} 0.213
+gocnn_request_duration_seconds_count{method="predict"} 40
+```
+Exported series:
+  gocnn_requests_total{method}                 calls of Predict / PredictBatch
+  gocnn_images_total                           images classified or attempted
+  gocnn_request_errors_total{method,reason}    failed calls: canceled, deadline_exceeded, failed
+  gocnn_queue_depth                            images accepted but not yet answered
+  gocnn_request_duration_seconds{method}       call latency histogram
+  gocnn_layer_duration_seconds{layer}          per-layer latency from PredictionResult.LayerTimes
+  gocnn_request_queue_seconds{endpoint}        time spent waiting for a Limiter slot
+  gocnn_requests_waiting                       requests waiting for a Limiter slot
+  gocnn_requests_rejected_total{reason}        requests turned away by a Limiter
+  gocnn_audit_errors_total                     audit entries that could not be written
+
+Histogram buckets are cumulative, as Prometheus expects: the bucket le=x
+counts every observation <= x, and le="+Inf" equals _count.
+*/
+
+// latencyBuckets are the upper bounds, in seconds, of the latency histograms
+var latencyBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// histogram counts observations into latencyBuckets
+type histogram struct {
+    buckets []int64 // Non-cumulative count per bucket; the last one is +Inf
+    sum     float64
+    count   int64
+}
+
+// observe records one duration
+func (h *histogram) observe(d time.Duration) {
+    if h.buckets == nil {
+        h.buckets = make([]int64, len(latencyBuckets)+1)
+    }
+    seconds := d.Seconds()
+    i := sort.SearchFloat64s(latencyBuckets, seconds)
+    h.buckets[i]++
+    h.sum += seconds
+    h.count++
+}
+
+// errorKey identifies a series of gocnn_request_errors_total
+type errorKey struct {
+    method string
+    reason string
+}
+
+// Metrics collects request statistics of the predictors it instruments
+// It is safe for concurrent use.
+type Metrics struct {
+    mu          sync.Mutex
+    requests    map[string]int64
+    images      int64
+    errors      map[errorKey]int64
+    queueDepth  int64
+    durations   map[string]*histogram
+    layers      map[string]*histogram
+    queueTimes  map[string]*histogram
+    waiting     int64
+    rejected    map[string]int64
+    auditErrors int64
+}
+
+// NewMetrics returns an empty collector
+func NewMetrics() *Metrics {
+    return &Metrics{
+        requests:   make(map[string]int64),
+        errors:     make(map[errorKey]int64),
+        durations:  make(map[string]*histogram),
+        layers:     make(map[string]*histogram),
+        queueTimes: make(map[string]*histogram),
+        rejected:   make(map[string]int64),
+    }
+}
+
+// Instrument returns a Predictor that forwards to p and records every call in m
+func (m *Metrics) Instrument(p model.Predictor) model.Predictor {
+    return &instrumented{predictor: p, metrics: m}
+}
+
+// begin records that a call with images images was accepted
+func (m *Metrics) begin(method string, images int) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.requests[method]++
+    m.images += int64(images)
+    m.queueDepth += int64(images)
+}
+
+// end records the outcome of a call begun with begin
+func (m *Metrics) end(method string, images int, elapsed time.Duration, results []*model.PredictionResult, err error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.queueDepth -= int64(images)
+
+    if m.durations[method] == nil {
+        m.durations[method] = &histogram{}
+    }
+    m.durations[method].observe(elapsed)
+
+    if err != nil {
+        m.errors[errorKey{method: method, reason: errorReason(err)}]++
+        return
+    }
+    for _, result := range results {
+        for layer, layerTime := range result.LayerTimes {
+            if m.layers[layer] == nil {
+                m.layers[layer] = &histogram{}
+            }
+            m.layers[layer].observe(layerTime)
+        }
+    }
+}
+
+// observeQueueTime records how long a request waited for a Limiter slot; m may be nil
+func (m *Metrics) observeQueueTime(endpoint string, waited time.Duration) {
+    if m == nil {
+        return
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if m.queueTimes[endpoint] == nil {
+        m.queueTimes[endpoint] = &histogram{}
+    }
+    m.queueTimes[endpoint].observe(waited)
+}
+
+// addWaiting changes the number of requests waiting for a Limiter slot; m may be nil
+func (m *Metrics) addWaiting(delta int64) {
+    if m == nil {
+        return
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.waiting += delta
+}
+
+// countRejected records a request turned away by a Limiter; m may be nil
+func (m *Metrics) countRejected(reason string) {
+    if m == nil {
+        return
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.rejected[reason]++
+}
+
+// countAuditError records an audit entry that could not be written; m may be nil
+func (m *Metrics) countAuditError() {
+    if m == nil {
+        return
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.auditErrors++
+}
+
+// errorReason names the kind of a prediction error
+func errorReason(err error) string {
+    switch {
+    case errors.Is(err, context.Canceled):
+        return "canceled"
+    case errors.Is(err, context.DeadlineExceeded):
+        return "deadline_exceeded"
+    default:
+        return "failed"
+    }
+}
+
+// ServeHTTP writes every metric in the Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+    m.WriteTo(w)
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var out []byte
+    out = fmt.Appendf(out, "# HELP gocnn_requests_total Prediction calls received.\n")
+    out = fmt.Appendf(out, "# TYPE gocnn_requests_total counter\n")
+    for _, method := range sortedKeys(m.requests) {
+        out = fmt.Appendf(out, "gocnn_requests_total{method=%q} %d\n", method, m.requests[method])
+    }
+
+    out = fmt.Appendf(out, "# HELP gocnn_images_total Images received for classification.\n")
+    out = fmt.Appendf(out, "# TYPE gocnn_images_total counter\n")
+    out = fmt.Appendf(out, "gocnn_images_total %d\n", m.images)
+
+    out = fmt.Appendf(out, "# HELP gocnn_request_errors_total Prediction calls that failed.\n")
+    out = fmt.Appendf(out, "# TYPE gocnn_request_errors_total counter\n")
+    keys := make([]errorKey, 0, len(m.errors))
+    for key := range m.errors {
+        keys = append(keys, key)
+    }
+    sort.Slice(keys, func(i, j int) bool {
+        if keys[i].method != keys[j].method {
+            return keys[i].method < keys[j].method
+        }
+        return keys[i].reason < keys[j].reason
+    })
+    for _, key := range keys {
+        out = fmt.Appendf(out, "gocnn_request_errors_total{method=%q,reason=%q} %d\n", key.method, key.reason, m.errors[key])
+    }
+
+    out = fmt.Appendf(out, "# HELP gocnn_queue_depth Images accepted but not yet answered.\n")
+    out = fmt.Appendf(out, "# TYPE gocnn_queue_depth gauge\n")
+    out = fmt.Appendf(out, "gocnn_queue_depth %d\n", m.queueDepth)
+
+    out = appendHistograms(out, "gocnn_request_duration_seconds", "Prediction call latency.", "method", m.durations)
+    out = appendHistograms(out, "gocnn_layer_duration_seconds", "Time spent in each layer per image.", "layer", m.layers)
+    out = appendHistograms(out, "gocnn_request_queue_seconds", "Time spent waiting for a free inference slot.", "endpoint", m.queueTimes)
+
+    out = fmt.Appendf(out, "# HELP gocnn_requests_waiting Requests waiting for a free inference slot.\n")
+    out = fmt.Appendf(out, "# TYPE gocnn_requests_waiting gauge\n")
+    out = fmt.Appendf(out, "gocnn_requests_waiting %d\n", m.waiting)
+
+    out = fmt.Appendf(out, "# HELP gocnn_requests_rejected_total Requests turned away by concurrency or rate limits.\n")
+    out = fmt.Appendf(out, "# TYPE gocnn_requests_rejected_total counter\n")
+    for _, reason := range sortedKeys(m.rejected) {
+        out = fmt.Appendf(out, "gocnn_requests_rejected_total{reason=%q} %d\n", reason, m.rejected[reason])
+    }
+
+    out = fmt.Appendf(out, "# HELP gocnn_audit_errors_total Audit entries that could not be written.\n")
+    out = fmt.Appendf(out, "# TYPE gocnn_audit_errors_total counter\n")
+    out = fmt.Appendf(out, "gocnn_audit_errors_total %d\n", m.auditErrors)
+
+    n, err := w.Write(out)
+    return int64(n), err
+}
+
+// appendHistograms appends one histogram family, one series per label value
+func appendHistograms(out []byte, name, help, label string, histograms map[string]*histogram) []byte {
+    out = fmt.Appendf(out, "# HELP %s %s\n", name, help)
+    out = fmt.Appendf(out, "# TYPE %s histogram\n", name)
+    for _, value := range sortedKeys(histograms) {
+        h := histograms[value]
+        var cumulative int64
+        for i, bound := range latencyBuckets {
+            cumulative += h.buckets[i]
+            out = fmt.Appendf(out, "%s_bucket{%s=%q,le=%q} %d\n", name, label, value,
+                strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+        }
+        out = fmt.Appendf(out, "%s_bucket{%s=%q,le=\"+Inf\"} %d\n", name, label, value, h.count)
+        out = fmt.Appendf(out, "%s_sum{%s=%q} %s\n", name, label, value, strconv.FormatFloat(h.sum, 'g', -1, 64))
+        out = fmt.Appendf(out, "%s_count{%s=%q} %d\n", name, label, value, h.count)
+    }
+    return out
+}
+
+// sortedKeys returns the keys of a map in order, for a stable exposition
+func sortedKeys[V any](values map[string]V) []string {
+    keys := make([]string, 0, len(values))
+    for key := range values {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// instrumented is a Predictor that records its calls in a Metrics
+type instrumented struct {
+    predictor model.Predictor
+    metrics   *Metrics
+}
+
+func (p *instrumented) Predict(ctx context.Context, imageData []float32) (*model.PredictionResult, error) {
+    p.metrics.begin("predict", 1)
+    start := time.Now()
+    result, err := p.predictor.Predict(ctx, imageData)
+    p.metrics.end("predict", 1, time.Since(start), []*model.PredictionResult{result}, err)
+    return result, err
+}
+
+func (p *instrumented) PredictBatch(ctx context.Context, images [][]float32) ([]*model.PredictionResult, error) {
+    p.metrics.begin("predict_batch", len(images))
+    start := time.Now()
+    results, err := p.predictor.PredictBatch(ctx, images)
+    p.metrics.end("predict_batch", len(images), time.Since(start), results, err)
+    return results, err
+}
+
+func (p *instrumented) Info() *model.ModelInfo {
+    return p.predictor.Info()
+}
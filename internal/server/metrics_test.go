@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"duchm1606/gocnn/internal/model"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePredictor answers every image with fixed layer times, or fails with err
+type fakePredictor struct {
+    err error
+}
+
+func (p *fakePredictor) Predict(ctx context.Context, imageData []float32) (*model.PredictionResult, error) {
+    if p.err != nil {
+        return nil, p.err
+    }
+    return &model.PredictionResult{
+        LayerTimes: map[string]time.Duration{"conv1": 2 * time.Millisecond, "conv2": 300 * time.Microsecond},
+    }, nil
+}
+
+func (p *fakePredictor) PredictBatch(ctx context.Context, images [][]float32) ([]*model.PredictionResult, error) {
+    results := make([]*model.PredictionResult, len(images))
+    for i, image := range images {
+        result, err := p.Predict(ctx, image)
+        if err != nil {
+            return nil, err
+        }
+        results[i] = result
+    }
+    return results, nil
+}
+
+func (p *fakePredictor) Info() *model.ModelInfo {
+    return &model.ModelInfo{}
+}
+
+func TestMetricsExposition(t *testing.T) {
+    metrics := NewMetrics()
+    ok := metrics.Instrument(&fakePredictor{})
+    cancelled := metrics.Instrument(&fakePredictor{err: context.Canceled})
+    failing := metrics.Instrument(&fakePredictor{err: errors.New("broken")})
+
+    ok.Predict(context.Background(), nil)
+    ok.PredictBatch(context.Background(), make([][]float32, 3))
+    cancelled.Predict(context.Background(), nil)
+    failing.PredictBatch(context.Background(), make([][]float32, 2))
+
+    recorder := httptest.NewRecorder()
+    metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+    if ct := recorder.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+        t.Errorf("Unexpected content type %q", ct)
+    }
+    body := recorder.Body.String()
+
+    for _, want := range []string{
+        `gocnn_requests_total{method="predict"} 2`,
+        `gocnn_requests_total{method="predict_batch"} 2`,
+        `gocnn_images_total 7`,
+        `gocnn_request_errors_total{method="predict",reason="canceled"} 1`,
+        `gocnn_request_errors_total{method="predict_batch",reason="failed"} 1`,
+        `gocnn_queue_depth 0`,
+        `gocnn_request_duration_seconds_count{method="predict"} 2`,
+        // Four successful images; conv1's 2ms lands above the 1ms bucket
+        `gocnn_layer_duration_seconds_bucket{layer="conv1",le="0.001"} 0`,
+        `gocnn_layer_duration_seconds_bucket{layer="conv1",le="0.0025"} 4`,
+        `gocnn_layer_duration_seconds_bucket{layer="conv1",le="+Inf"} 4`,
+        `gocnn_layer_duration_seconds_bucket{layer="conv2",le="0.0005"} 4`,
+        `gocnn_layer_duration_seconds_count{layer="conv2"} 4`,
+        "# TYPE gocnn_layer_duration_seconds histogram",
+    } {
+        if !strings.Contains(body, want) {
+            t.Errorf("Exposition lacks %q:\n%s", want, body)
+        }
+    }
+}
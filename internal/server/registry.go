@@ -0,0 +1,107 @@
+package server
+
+import (
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/model"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+/**
+* Model registry
+
+One server can answer for several models - a float32 and an int8 build of
+the same network, or networks for different datasets. The Registry maps a
+name to everything needed to answer a request for that model:
+```
+"cifar10"      ──> Preprocessor ─┬─> Predictor (batched, instrumented) ──> TinyCNN
+"cifar10-int8" ──> Preprocessor ─┘   Predictor (batched, instrumented) ──> TinyCNN
+"grey"         ──> Preprocessor  ──> Predictor                         ──> TinyCNN
+```
+Models configured with the same data section share one Preprocessor, since
+it holds no per-request state. The first model added is the default, used
+when a request names none.
+*/
+
+// ErrUnknownModel is returned for a model name the registry doesn't hold
+var ErrUnknownModel = errors.New("unknown model")
+
+// ServedModel is one named model and what it needs to answer requests
+type ServedModel struct {
+    Name         string
+    Model        model.Predictor    // The model itself, for health checks
+    Predictor    model.Predictor    // What requests run on, e.g. a Batcher over Model; nil uses Model
+    Preprocessor *data.Preprocessor // Turns request bodies into model inputs
+    ClassNames   []string           // Optional name of each class
+}
+
+// Registry holds the served models by name
+// It is safe for concurrent use.
+type Registry struct {
+    mu     sync.RWMutex
+    models map[string]*ServedModel
+    names  []string // In the order added; the first is the default
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+    return &Registry{models: make(map[string]*ServedModel)}
+}
+
+// Add registers m under m.Name
+func (r *Registry) Add(m *ServedModel) error {
+    if m.Name == "" {
+        return fmt.Errorf("served model needs a name")
+    }
+    if m.Model == nil || m.Preprocessor == nil {
+        return fmt.Errorf("served model %q needs a model and a preprocessor", m.Name)
+    }
+    if m.Predictor == nil {
+        m.Predictor = m.Model
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if _, exists := r.models[m.Name]; exists {
+        return fmt.Errorf("model %q is already registered", m.Name)
+    }
+    r.models[m.Name] = m
+    r.names = append(r.names, m.Name)
+    return nil
+}
+
+// Get returns the model called name, or the default model when name is empty
+func (r *Registry) Get(name string) (*ServedModel, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    if name == "" {
+        if len(r.names) == 0 {
+            return nil, fmt.Errorf("no models are registered")
+        }
+        name = r.names[0]
+    }
+    m, ok := r.models[name]
+    if !ok {
+        return nil, fmt.Errorf("%w %q (serving %v)", ErrUnknownModel, name, r.names)
+    }
+    return m, nil
+}
+
+// Names returns the names of the registered models, the default first
+func (r *Registry) Names() []string {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    return append([]string(nil), r.names...)
+}
+
+// Models returns the registered models, the default first
+func (r *Registry) Models() []*ServedModel {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    models := make([]*ServedModel, len(r.names))
+    for i, name := range r.names {
+        models[i] = r.models[name]
+    }
+    return models
+}
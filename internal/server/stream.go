@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/**
+* WebSocket prediction stream
+
+A webcam or video demo sends frames faster than one request per frame
+allows. The stream endpoint keeps one WebSocket open and classifies every
+binary message on it:
+```
+GET /v1/models/{name}/stream   (or /v1/stream?model=name, ?topk=N as for predict)
+
+client ── binary frame 0 ──>                    <── {"frame":0,"model":...,"predicted_class":6,...}
+       ── binary frame 1 ──>                    <── {"frame":2,...}
+       ── binary frame 2 ──>                    <── {"frame":1,...}
+```
+Each message is one image in any format the predict endpoint accepts.
+Up to maxStreamInFlight frames of a connection are predicted at once, so
+answers arrive asynchronously and may overtake each other; "frame" counts
+the client's messages from 0 and says which one an answer belongs to. A
+frame that can't be classified, or that the API's Limiter turns away,
+gets {"frame":n,"error":"..."} and the stream goes on. When the client has
+that many frames in flight the server stops reading, and TCP flow control
+slows the client down.
+
+Closing the connection lets the frames in flight finish and be answered
+before the server's close frame.
+*/
+
+// maxStreamInFlight bounds the frames of one stream being predicted at once
+const maxStreamInFlight = 4
+
+// streamResponse is the answer to one frame of a stream
+type streamResponse struct {
+    Frame int `json:"frame"`
+    *predictResponse
+    Error string `json:"error,omitempty"`
+    entry *AuditEntry // Audit record of the frame
+}
+
+// stream classifies the binary messages of a WebSocket with the model called name
+func (a *API) stream(w http.ResponseWriter, r *http.Request, name string) {
+    served, err := a.registry.Get(name)
+    if err != nil {
+        writeModelError(w, err)
+        return
+    }
+    topK, err := parseTopK(r)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    connection := newAuditEntry(r, "stream")
+    connection.Model = served.Name
+    w.Header().Set("X-Request-ID", connection.RequestID)
+    conn, err := upgradeWebSocket(w, r, a.maxRequestBytes)
+    if err != nil {
+        return
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    // One writer sends every answer; after a failed write it only drains
+    responses := make(chan *streamResponse, maxStreamInFlight)
+    written := make(chan struct{})
+    go func() {
+        defer close(written)
+        failed := false
+        for response := range responses {
+            a.recordAudit(response.entry)
+            if failed {
+                continue
+            }
+            message, _ := json.Marshal(response)
+            if err := conn.writeMessage(opText, message); err != nil {
+                // The client is gone: stop predicting and unblock the reader
+                failed = true
+                cancel()
+                conn.conn.Close()
+            }
+        }
+    }()
+
+    slots := make(chan struct{}, maxStreamInFlight)
+    var inFlight sync.WaitGroup
+    var readErr error
+read:
+    for frame := 0; ; frame++ {
+        opcode, payload, err := conn.readMessage()
+        if err != nil {
+            readErr = err
+            break
+        }
+        entry := connection.frame(frame, payload)
+        if opcode != opBinary {
+            responses <- frameError(frame, entry, http.StatusBadRequest, fmt.Errorf("frames must be binary messages holding an image"))
+            continue
+        }
+        if err := a.limiter.Allow(connection.Client); err != nil {
+            responses <- frameError(frame, entry, http.StatusTooManyRequests, err)
+            continue
+        }
+
+        select {
+        case slots <- struct{}{}:
+        case <-ctx.Done():
+            break read
+        }
+        inFlight.Add(1)
+        go func(frame int, payload []byte) {
+            defer inFlight.Done()
+            defer func() { <-slots }()
+            responses <- a.predictFrame(ctx, served, frame, payload, topK, entry)
+        }(frame, payload)
+    }
+
+    inFlight.Wait()
+    close(responses)
+    <-written
+    conn.close(readErr)
+}
+
+// frame starts the audit entry of one frame of the connection e describes
+func (e *AuditEntry) frame(frame int, payload []byte) *AuditEntry {
+    entry := *e
+    entry.Time = time.Now()
+    entry.RequestID = e.RequestID + "-" + strconv.Itoa(frame)
+    entry.setInput(payload)
+    return &entry
+}
+
+// frameError is the answer to a frame that failed with status
+func frameError(frame int, entry *AuditEntry, status int, err error) *streamResponse {
+    entry.Status = status
+    entry.Error = err.Error()
+    return &streamResponse{Frame: frame, Error: err.Error(), entry: entry}
+}
+
+// predictFrame classifies one frame of a stream
+func (a *API) predictFrame(ctx context.Context, served *ServedModel, frame int, payload []byte, topK int, entry *AuditEntry) *streamResponse {
+    fm, err := served.Preprocessor.Decode(payload)
+    if err != nil {
+        return frameError(frame, entry, http.StatusBadRequest, err)
+    }
+    release, err := a.limiter.Acquire(ctx, "stream")
+    if err != nil {
+        status := http.StatusServiceUnavailable
+        var limitErr *LimitError
+        if errors.As(err, &limitErr) {
+            status = http.StatusTooManyRequests
+        }
+        return frameError(frame, entry, status, err)
+    }
+    result, err := served.Predictor.Predict(ctx, fm.Data)
+    release()
+    if err != nil {
+        return frameError(frame, entry, http.StatusInternalServerError, fmt.Errorf("inference failed: %w", err))
+    }
+
+    response := newPredictResponse(served, result, topK)
+    entry.Status = http.StatusOK
+    entry.setResult(response)
+    return &streamResponse{Frame: frame, predictResponse: response, entry: entry}
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsClient is a minimal WebSocket client for tests
+type wsClient struct {
+    conn   net.Conn
+    reader *bufio.Reader
+}
+
+// dialStream opens a WebSocket to path on server
+func dialStream(t *testing.T, server *httptest.Server, path string) *wsClient {
+    t.Helper()
+    conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+    request := "GET " + path + " HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+        "Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"
+    if _, err := conn.Write([]byte(request)); err != nil {
+        t.Fatal(err)
+    }
+    reader := bufio.NewReader(conn)
+    response, err := http.ReadResponse(reader, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // The accept value of the RFC 6455 example key
+    if response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+        t.Fatalf("Unexpected handshake response %d %v", response.StatusCode, response.Header)
+    }
+    return &wsClient{conn: conn, reader: reader}
+}
+
+// send writes one masked frame
+func (c *wsClient) send(t *testing.T, fin bool, opcode int, payload []byte) {
+    t.Helper()
+    first := byte(opcode)
+    if fin {
+        first |= 0x80
+    }
+    frame := []byte{first}
+    switch n := len(payload); {
+    case n <= 125:
+        frame = append(frame, 0x80|byte(n))
+    case n <= 0xffff:
+        frame = append(frame, 0x80|126)
+        frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+    default:
+        frame = append(frame, 0x80|127)
+        frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+    }
+    mask := []byte{1, 2, 3, 4}
+    frame = append(frame, mask...)
+    for i, b := range payload {
+        frame = append(frame, b^mask[i%4])
+    }
+    if _, err := c.conn.Write(frame); err != nil {
+        t.Fatal(err)
+    }
+}
+
+// receive reads one unmasked, unfragmented frame
+func (c *wsClient) receive(t *testing.T) (int, []byte) {
+    t.Helper()
+    var header [2]byte
+    if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+        t.Fatal(err)
+    }
+    length := int(header[1] & 0x7f)
+    switch length {
+    case 126:
+        var extended [2]byte
+        io.ReadFull(c.reader, extended[:])
+        length = int(binary.BigEndian.Uint16(extended[:]))
+    case 127:
+        var extended [8]byte
+        io.ReadFull(c.reader, extended[:])
+        length = int(binary.BigEndian.Uint64(extended[:]))
+    }
+    payload := make([]byte, length)
+    if _, err := io.ReadFull(c.reader, payload); err != nil {
+        t.Fatal(err)
+    }
+    return int(header[0] & 0x0f), payload
+}
+
+func TestAPIStream(t *testing.T) {
+    registry := NewRegistry()
+    servedModel(t, registry, "first", 0)
+    servedModel(t, registry, "second", 2)
+    mux := http.NewServeMux()
+    NewAPI(registry).Register(mux)
+    server := httptest.NewServer(mux)
+    defer server.Close()
+
+    client := dialStream(t, server, "/v1/models/second/stream?topk=1")
+    image := binaryImage()
+    client.send(t, true, opBinary, image)
+    // A fragmented frame with a ping in between
+    client.send(t, false, opBinary, image[:1000])
+    client.send(t, true, opPing, []byte("hi"))
+    client.send(t, true, opContinuation, image[1000:])
+    client.send(t, true, opBinary, image[:10])
+    client.send(t, true, opText, []byte("hello"))
+
+    // streamResponse embeds an unexported pointer, which JSON can't decode into
+    type answer struct {
+        Frame          int             `json:"frame"`
+        PredictedClass int             `json:"predicted_class"`
+        TopK           []classResponse `json:"top_k"`
+        Error          string          `json:"error"`
+    }
+    answers := map[int]answer{}
+    pong := false
+    for len(answers) < 4 {
+        opcode, payload := client.receive(t)
+        if opcode == opPong {
+            pong = string(payload) == "hi"
+            continue
+        }
+        if opcode != opText {
+            t.Fatalf("Unexpected opcode %d: %q", opcode, payload)
+        }
+        var response answer
+        if err := json.Unmarshal(payload, &response); err != nil {
+            t.Fatalf("Invalid JSON %q: %v", payload, err)
+        }
+        answers[response.Frame] = response
+    }
+    if !pong {
+        t.Error("Ping was not answered")
+    }
+    for frame := 0; frame < 2; frame++ {
+        answer := answers[frame]
+        if answer.Error != "" || answer.PredictedClass != 2 || len(answer.TopK) != 1 {
+            t.Errorf("Frame %d: unexpected answer %+v", frame, answer)
+        }
+    }
+    if !strings.Contains(answers[2].Error, "wrong size") || !strings.Contains(answers[3].Error, "binary") {
+        t.Errorf("Expected errors for frames 2 and 3, got %q and %q", answers[2].Error, answers[3].Error)
+    }
+
+    client.send(t, true, opClose, binary.BigEndian.AppendUint16(nil, closeNormal))
+    opcode, payload := client.receive(t)
+    if opcode != opClose || binary.BigEndian.Uint16(payload) != closeNormal {
+        t.Errorf("Expected a normal close, got opcode %d %v", opcode, payload)
+    }
+}
+
+func TestAPIStreamRejects(t *testing.T) {
+    registry := NewRegistry()
+    servedModel(t, registry, "only", 1)
+    mux := http.NewServeMux()
+    NewAPI(registry).Register(mux)
+    server := httptest.NewServer(mux)
+    defer server.Close()
+
+    // Plain GETs and unknown models are answered before the upgrade
+    response, err := http.Get(server.URL + "/v1/stream")
+    if err != nil {
+        t.Fatal(err)
+    }
+    response.Body.Close()
+    if response.StatusCode != http.StatusUpgradeRequired {
+        t.Errorf("Expected 426 without an upgrade, got %d", response.StatusCode)
+    }
+    response, err = http.Get(server.URL + "/v1/stream?model=missing")
+    if err != nil {
+        t.Fatal(err)
+    }
+    response.Body.Close()
+    if response.StatusCode != http.StatusNotFound {
+        t.Errorf("Expected 404 for an unknown model, got %d", response.StatusCode)
+    }
+
+    // Unmasked client frames break the protocol
+    client := dialStream(t, server, "/v1/stream")
+    client.conn.Write([]byte{0x82, 0x01, 0x00})
+    opcode, payload := client.receive(t)
+    if opcode != opClose || binary.BigEndian.Uint16(payload) != closeProtocol {
+        t.Errorf("Expected a protocol error close, got opcode %d %q", opcode, payload)
+    }
+}
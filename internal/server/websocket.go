@@ -0,0 +1,293 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+* WebSocket protocol (RFC 6455), server side
+
+A WebSocket starts as an HTTP GET that asks to upgrade the connection; the
+server proves it understood by hashing the client's key with a fixed GUID:
+```
+GET /v1/stream                         HTTP/1.1 101 Switching Protocols
+Upgrade: websocket               ──>   Upgrade: websocket
+Connection: Upgrade                    Connection: Upgrade
+Sec-WebSocket-Key: <16 random bytes>   Sec-WebSocket-Accept: base64(sha1(key + GUID))
+Sec-WebSocket-Version: 13
+```
+After that both sides exchange frames over the raw TCP connection:
+```
+byte 0   FIN | RSV1-3 | opcode (0 continuation, 1 text, 2 binary, 8 close, 9 ping, 10 pong)
+byte 1   MASK | payload length (0-125, 126: 16-bit length follows, 127: 64-bit length follows)
+         [extended length] [4-byte masking key, client frames only] payload
+```
+Frames from the client are masked (XORed with the key); frames from the
+server are not. A message may be split into a first frame and continuation
+frames; control frames (close, ping, pong) may arrive between them and are
+never fragmented. Only what the streaming endpoint needs is implemented:
+no extensions, no subprotocols.
+*/
+
+// websocketGUID is the fixed suffix hashed into Sec-WebSocket-Accept
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+    opContinuation = 0x0
+    opText         = 0x1
+    opBinary       = 0x2
+    opClose        = 0x8
+    opPing         = 0x9
+    opPong         = 0xA
+)
+
+// WebSocket close status codes
+const (
+    closeNormal   = 1000
+    closeProtocol = 1002
+    closeTooBig   = 1009
+    closeInternal = 1011
+)
+
+// messageWriteTimeout bounds writing one message to a client that stopped reading
+const messageWriteTimeout = 10 * time.Second
+
+// errWebSocketClosed is returned by readMessage once the client sent a close frame
+var errWebSocketClosed = errors.New("websocket closed by client")
+
+// wsProtocolError is a frame that breaks RFC 6455; code is the close status to answer with
+type wsProtocolError struct {
+    code int
+    msg  string
+}
+
+func (e *wsProtocolError) Error() string {
+    return e.msg
+}
+
+// wsConn is the server side of an upgraded WebSocket connection
+// readMessage must be called from one goroutine; writes may come from any.
+type wsConn struct {
+    conn       net.Conn
+    reader     *bufio.Reader
+    writeMu    sync.Mutex
+    maxMessage int64 // Largest message accepted from the client
+}
+
+// upgradeWebSocket answers the opening handshake of r and takes over its connection
+// On failure it has already written an HTTP error response.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, maxMessage int64) (*wsConn, error) {
+    if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+        err := fmt.Errorf("expected a WebSocket upgrade request")
+        w.Header().Set("Upgrade", "websocket")
+        writeError(w, http.StatusUpgradeRequired, err)
+        return nil, err
+    }
+    if r.Header.Get("Sec-WebSocket-Version") != "13" {
+        err := fmt.Errorf("unsupported WebSocket version %q", r.Header.Get("Sec-WebSocket-Version"))
+        w.Header().Set("Sec-WebSocket-Version", "13")
+        writeError(w, http.StatusBadRequest, err)
+        return nil, err
+    }
+    key := r.Header.Get("Sec-WebSocket-Key")
+    if key == "" {
+        err := fmt.Errorf("missing Sec-WebSocket-Key")
+        writeError(w, http.StatusBadRequest, err)
+        return nil, err
+    }
+
+    hijacker, ok := w.(http.Hijacker)
+    if !ok {
+        err := fmt.Errorf("connection cannot be upgraded")
+        writeError(w, http.StatusInternalServerError, err)
+        return nil, err
+    }
+    conn, rw, err := hijacker.Hijack()
+    if err != nil {
+        return nil, fmt.Errorf("failed to take over connection: %w", err)
+    }
+
+    hash := sha1.Sum([]byte(key + websocketGUID))
+    response := "HTTP/1.1 101 Switching Protocols\r\n" +
+        "Upgrade: websocket\r\n" +
+        "Connection: Upgrade\r\n" +
+        "Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(hash[:]) + "\r\n\r\n"
+    if _, err := conn.Write([]byte(response)); err != nil {
+        conn.Close()
+        return nil, fmt.Errorf("failed to complete handshake: %w", err)
+    }
+    // The server's deadlines no longer apply to a hijacked connection
+    conn.SetDeadline(time.Time{})
+
+    return &wsConn{conn: conn, reader: rw.Reader, maxMessage: maxMessage}, nil
+}
+
+// headerContainsToken reports whether the comma-separated header name lists token
+func headerContainsToken(header http.Header, name, token string) bool {
+    for _, value := range header.Values(name) {
+        for _, part := range strings.Split(value, ",") {
+            if strings.EqualFold(strings.TrimSpace(part), token) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// readMessage returns the next text or binary message, answering pings on the way
+// It returns errWebSocketClosed once the client sends a close frame; messages may
+// still be written until close answers it.
+func (c *wsConn) readMessage() (opcode int, payload []byte, err error) {
+    for {
+        fin, op, data, err := c.readFrame()
+        if err != nil {
+            return 0, nil, err
+        }
+
+        switch op {
+        case opPing:
+            if err := c.writeFrame(opPong, data); err != nil {
+                return 0, nil, err
+            }
+            continue
+        case opPong:
+            continue
+        case opClose:
+            return 0, nil, errWebSocketClosed
+        case opContinuation:
+            if opcode == 0 {
+                return 0, nil, &wsProtocolError{closeProtocol, "continuation frame without a message"}
+            }
+        case opText, opBinary:
+            if opcode != 0 {
+                return 0, nil, &wsProtocolError{closeProtocol, "new message before the last one ended"}
+            }
+            opcode = op
+        default:
+            return 0, nil, &wsProtocolError{closeProtocol, fmt.Sprintf("unknown opcode %d", op)}
+        }
+
+        if int64(len(payload))+int64(len(data)) > c.maxMessage {
+            return 0, nil, &wsProtocolError{closeTooBig, fmt.Sprintf("message exceeds %d bytes", c.maxMessage)}
+        }
+        payload = append(payload, data...)
+        if fin {
+            return opcode, payload, nil
+        }
+    }
+}
+
+// readFrame reads and unmasks one frame
+func (c *wsConn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+    var header [2]byte
+    if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+        return false, 0, nil, err
+    }
+    fin = header[0]&0x80 != 0
+    opcode = int(header[0] & 0x0f)
+    if header[0]&0x70 != 0 {
+        return false, 0, nil, &wsProtocolError{closeProtocol, "reserved bits set without an extension"}
+    }
+    if header[1]&0x80 == 0 {
+        return false, 0, nil, &wsProtocolError{closeProtocol, "client frames must be masked"}
+    }
+
+    length := uint64(header[1] & 0x7f)
+    switch length {
+    case 126:
+        var extended [2]byte
+        if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+            return false, 0, nil, err
+        }
+        length = uint64(binary.BigEndian.Uint16(extended[:]))
+    case 127:
+        var extended [8]byte
+        if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+            return false, 0, nil, err
+        }
+        length = binary.BigEndian.Uint64(extended[:])
+    }
+    if opcode >= opClose && (length > 125 || !fin) {
+        return false, 0, nil, &wsProtocolError{closeProtocol, "control frames must be short and unfragmented"}
+    }
+    if length > uint64(c.maxMessage) {
+        return false, 0, nil, &wsProtocolError{closeTooBig, fmt.Sprintf("message exceeds %d bytes", c.maxMessage)}
+    }
+
+    var mask [4]byte
+    if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+        return false, 0, nil, err
+    }
+    payload = make([]byte, length)
+    if _, err := io.ReadFull(c.reader, payload); err != nil {
+        return false, 0, nil, err
+    }
+    for i := range payload {
+        payload[i] ^= mask[i%4]
+    }
+    return fin, opcode, payload, nil
+}
+
+// writeMessage sends payload as one unfragmented text or binary message
+func (c *wsConn) writeMessage(opcode int, payload []byte) error {
+    return c.writeFrame(opcode, payload)
+}
+
+// writeFrame sends one unmasked frame with FIN set, failing if the client doesn't
+// take it within messageWriteTimeout
+func (c *wsConn) writeFrame(opcode int, payload []byte) error {
+    frame := []byte{0x80 | byte(opcode)}
+    switch n := len(payload); {
+    case n <= 125:
+        frame = append(frame, byte(n))
+    case n <= 0xffff:
+        frame = append(frame, 126)
+        frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+    default:
+        frame = append(frame, 127)
+        frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+    }
+    frame = append(frame, payload...)
+
+    c.writeMu.Lock()
+    defer c.writeMu.Unlock()
+    c.conn.SetWriteDeadline(time.Now().Add(messageWriteTimeout))
+    _, err := c.conn.Write(frame)
+    return err
+}
+
+// writeClose sends a close frame with status code and a short reason
+func (c *wsConn) writeClose(code int, reason string) error {
+    if len(reason) > 123 {
+        reason = reason[:123]
+    }
+    payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+    payload = append(payload, reason...)
+    return c.writeFrame(opClose, payload)
+}
+
+// close ends the connection, telling the client why err stopped it
+func (c *wsConn) close(err error) error {
+    var protocolErr *wsProtocolError
+    switch {
+    case errors.Is(err, errWebSocketClosed):
+        c.writeClose(closeNormal, "")
+    case errors.As(err, &protocolErr):
+        c.writeClose(protocolErr.code, protocolErr.msg)
+    case err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed):
+        c.writeClose(closeInternal, err.Error())
+    }
+    return c.conn.Close()
+}
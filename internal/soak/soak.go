@@ -0,0 +1,251 @@
+package soak
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+* Soak testing
+
+A leak that costs a few bytes per inference is invisible in a benchmark of a
+hundred images and fatal after a week in a server. A soak run keeps the
+model busy for hours and periodically samples the process:
+  - live heap bytes and objects, measured right after a forced GC so that
+    garbage waiting for collection doesn't hide or fake a trend
+  - the number of goroutines (a worker that never exits)
+  - the resident set size from /proc/self/statm (memory the Go heap
+    doesn't see, such as fragmentation or cgo); unavailable off Linux
+
+A healthy process plateaus once its buffer pools and caches are warm. A
+leaking one keeps climbing. Single samples are noisy (a pool may keep one
+more buffer this minute than the last), so growth is judged on windows:
+after the warm-up samples, the run is split into Windows equal parts, and a
+metric is flagged when the minimum of every window is above the minimum of
+the window before it and the total rise exceeds the metric's tolerance.
+The least-squares slope is reported alongside as an easy-to-read rate.
+*/
+
+// Windows is the number of parts the post-warm-up samples are split into
+const Windows = 4
+
+// MinSamples is the number of post-warm-up samples needed to judge growth
+const MinSamples = 2 * Windows
+
+// Sample is one snapshot of the process during a soak run
+type Sample struct {
+    Elapsed     time.Duration // Time since the run started
+    Inferences  int64         // Inferences completed so far
+    HeapAlloc   uint64        // Live heap bytes after a forced GC
+    HeapObjects uint64        // Live heap objects after a forced GC
+    Goroutines  int
+    RSS         uint64 // Resident set size in bytes; 0 when unavailable
+    NumGC       uint32 // Garbage collections so far
+}
+
+// TakeSample forces a garbage collection and records the state of the process
+func TakeSample(elapsed time.Duration, inferences int64) Sample {
+    runtime.GC()
+    var stats runtime.MemStats
+    runtime.ReadMemStats(&stats)
+
+    rss, _ := ReadRSS()
+    return Sample{
+        Elapsed:     elapsed,
+        Inferences:  inferences,
+        HeapAlloc:   stats.HeapAlloc,
+        HeapObjects: stats.HeapObjects,
+        Goroutines:  runtime.NumGoroutine(),
+        RSS:         rss,
+        NumGC:       stats.NumGC,
+    }
+}
+
+// ReadRSS returns the resident set size of the process in bytes
+// It reads /proc/self/statm and fails on systems without it.
+func ReadRSS() (uint64, error) {
+    raw, err := os.ReadFile("/proc/self/statm")
+    if err != nil {
+        return 0, err
+    }
+
+    // statm holds sizes in pages: total, resident, shared, ...
+    fields := strings.Fields(string(raw))
+    if len(fields) < 2 {
+        return 0, fmt.Errorf("unexpected /proc/self/statm contents %q", raw)
+    }
+    pages, err := strconv.ParseUint(fields[1], 10, 64)
+    if err != nil {
+        return 0, fmt.Errorf("unexpected /proc/self/statm contents %q: %w", raw, err)
+    }
+    return pages * uint64(os.Getpagesize()), nil
+}
+
+// Metric is one sampled quantity watched for growth
+type Metric struct {
+    Name      string
+    Unit      string
+    MinGrowth float64 // Rise below this is never flagged
+    RelGrowth float64 // Nor is rise below this fraction of the first window's minimum
+    Value     func(Sample) float64
+}
+
+// Metrics are the quantities a soak run watches
+var Metrics = []Metric{
+    {"heap", "bytes", 1 << 20, 0.10, func(s Sample) float64 { return float64(s.HeapAlloc) }},
+    {"heap_objects", "objects", 1000, 0.10, func(s Sample) float64 { return float64(s.HeapObjects) }},
+    {"goroutines", "goroutines", 1, 0, func(s Sample) float64 { return float64(s.Goroutines) }},
+    {"rss", "bytes", 4 << 20, 0.10, func(s Sample) float64 { return float64(s.RSS) }},
+}
+
+// Trend is the analysis of one metric over the post-warm-up samples
+type Trend struct {
+    Metric       string
+    Unit         string
+    Available    bool      // False when the metric could not be sampled (RSS off Linux)
+    First        float64   // First post-warm-up value
+    Last         float64   // Last value
+    Min          float64
+    Max          float64
+    WindowMinima []float64 // Minimum of each of the Windows parts
+    Growth       float64   // Last window minimum minus first window minimum
+    SlopePerHour float64   // Least-squares rate of change
+    Growing      bool      // Flagged as monotonic growth
+}
+
+// Report is the outcome of a soak run
+type Report struct {
+    Duration      time.Duration
+    Inferences    int64
+    Samples       int
+    WarmupSamples int  // Leading samples left out of the analysis
+    Judged        bool // False when too few samples remained to judge growth
+    Trends        []Trend
+}
+
+// Analyze judges every metric over samples, skipping the first warmup of them
+func Analyze(samples []Sample, warmup int) *Report {
+    report := &Report{Samples: len(samples)}
+    if len(samples) > 0 {
+        last := samples[len(samples)-1]
+        report.Duration = last.Elapsed
+        report.Inferences = last.Inferences
+    }
+
+    warmup = min(max(warmup, 0), len(samples))
+    report.WarmupSamples = warmup
+    steady := samples[warmup:]
+    report.Judged = len(steady) >= MinSamples
+
+    for _, metric := range Metrics {
+        report.Trends = append(report.Trends, analyzeMetric(metric, steady, report.Judged))
+    }
+    return report
+}
+
+// analyzeMetric computes the trend of one metric; judge enables the growth check
+func analyzeMetric(metric Metric, samples []Sample, judge bool) Trend {
+    trend := Trend{Metric: metric.Name, Unit: metric.Unit}
+    if len(samples) == 0 {
+        return trend
+    }
+
+    values := make([]float64, len(samples))
+    hours := make([]float64, len(samples))
+    for i, s := range samples {
+        values[i] = metric.Value(s)
+        hours[i] = s.Elapsed.Hours()
+    }
+
+    // A metric that reads 0 throughout was not sampled
+    for _, v := range values {
+        if v != 0 {
+            trend.Available = true
+            break
+        }
+    }
+    if !trend.Available {
+        return trend
+    }
+
+    trend.First, trend.Last = values[0], values[len(values)-1]
+    trend.Min, trend.Max = values[0], values[0]
+    for _, v := range values {
+        trend.Min = min(trend.Min, v)
+        trend.Max = max(trend.Max, v)
+    }
+    trend.SlopePerHour = slope(hours, values)
+
+    if !judge {
+        return trend
+    }
+
+    trend.WindowMinima = make([]float64, Windows)
+    for w := 0; w < Windows; w++ {
+        part := values[w*len(values)/Windows : (w+1)*len(values)/Windows]
+        trend.WindowMinima[w] = part[0]
+        for _, v := range part {
+            trend.WindowMinima[w] = min(trend.WindowMinima[w], v)
+        }
+    }
+    trend.Growth = trend.WindowMinima[Windows-1] - trend.WindowMinima[0]
+
+    monotonic := true
+    for w := 1; w < Windows; w++ {
+        if trend.WindowMinima[w] <= trend.WindowMinima[w-1] {
+            monotonic = false
+        }
+    }
+    tolerance := max(metric.MinGrowth, metric.RelGrowth*trend.WindowMinima[0])
+    trend.Growing = monotonic && trend.Growth >= tolerance
+    return trend
+}
+
+// slope returns the least-squares slope of y over x, or 0 when x doesn't vary
+func slope(x, y []float64) float64 {
+    n := float64(len(x))
+    var sumX, sumY, sumXY, sumXX float64
+    for i := range x {
+        sumX += x[i]
+        sumY += y[i]
+        sumXY += x[i] * y[i]
+        sumXX += x[i] * x[i]
+    }
+    denominator := n*sumXX - sumX*sumX
+    if denominator == 0 {
+        return 0
+    }
+    return (n*sumXY - sumX*sumY) / denominator
+}
+
+// Growing returns the names of the metrics flagged as growing
+func (r *Report) Growing() []string {
+    var names []string
+    for _, trend := range r.Trends {
+        if trend.Growing {
+            names = append(names, trend.Metric)
+        }
+    }
+    return names
+}
+
+// Stable reports whether the run was long enough to judge and no metric grew
+func (r *Report) Stable() bool {
+    return r.Judged && len(r.Growing()) == 0
+}
+
+// Verdict summarizes the report in one word: stable, growing or inconclusive
+func (r *Report) Verdict() string {
+    switch {
+    case !r.Judged:
+        return "inconclusive"
+    case len(r.Growing()) > 0:
+        return "growing"
+    default:
+        return "stable"
+    }
+}
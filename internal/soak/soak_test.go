@@ -0,0 +1,89 @@
+package soak
+
+import (
+	"testing"
+	"time"
+)
+
+// samplesWith builds n samples a minute apart whose heap follows heap(i)
+func samplesWith(n int, heap func(i int) uint64) []Sample {
+    samples := make([]Sample, n)
+    for i := range samples {
+        samples[i] = Sample{
+            Elapsed:     time.Duration(i+1) * time.Minute,
+            Inferences:  int64(i+1) * 1000,
+            HeapAlloc:   heap(i),
+            HeapObjects: 5000,
+            Goroutines:  2,
+        }
+    }
+    return samples
+}
+
+// trendOf returns the named metric's trend
+func trendOf(t *testing.T, report *Report, name string) Trend {
+    for _, trend := range report.Trends {
+        if trend.Metric == name {
+            return trend
+        }
+    }
+    t.Fatalf("No trend for %s", name)
+    return Trend{}
+}
+
+func TestAnalyzeStable(t *testing.T) {
+    // A sawtooth around a plateau is what a healthy pool looks like
+    report := Analyze(samplesWith(40, func(i int) uint64 { return 8<<20 + uint64(i%3)<<20 }), 4)
+    if !report.Judged || !report.Stable() || report.Verdict() != "stable" {
+        t.Fatalf("Expected a stable verdict, got %s (growing: %v)", report.Verdict(), report.Growing())
+    }
+    if report.Inferences != 40000 || report.Duration != 40*time.Minute || report.WarmupSamples != 4 {
+        t.Errorf("Unexpected summary %+v", report)
+    }
+    if trendOf(t, report, "rss").Available {
+        t.Error("RSS was never sampled and should be unavailable")
+    }
+}
+
+func TestAnalyzeGrowing(t *testing.T) {
+    // 256 KB per sample adds up to several MB over the run
+    report := Analyze(samplesWith(40, func(i int) uint64 { return 8<<20 + uint64(i)<<18 }), 4)
+    if report.Verdict() != "growing" {
+        t.Fatalf("Expected a growing verdict, got %s", report.Verdict())
+    }
+    heap := trendOf(t, report, "heap")
+    if !heap.Growing || heap.Growth <= 0 || heap.SlopePerHour <= 0 {
+        t.Errorf("Heap should be flagged with a positive slope: %+v", heap)
+    }
+    if trendOf(t, report, "goroutines").Growing {
+        t.Error("Goroutines are constant and should not be flagged")
+    }
+}
+
+func TestAnalyzeSmallGrowthTolerated(t *testing.T) {
+    // Monotonic but below both the absolute and relative tolerance
+    report := Analyze(samplesWith(40, func(i int) uint64 { return 64<<20 + uint64(i)<<10 }), 0)
+    if !report.Stable() {
+        t.Errorf("Growth of a few KB should be tolerated, flagged %v", report.Growing())
+    }
+}
+
+func TestAnalyzeTooFewSamples(t *testing.T) {
+    report := Analyze(samplesWith(MinSamples+1, func(i int) uint64 { return uint64(i) << 30 }), 2)
+    if report.Judged || report.Stable() || report.Verdict() != "inconclusive" {
+        t.Errorf("Expected an inconclusive verdict, got %s", report.Verdict())
+    }
+    if len(report.Growing()) != 0 {
+        t.Errorf("Unjudged runs should not flag metrics, got %v", report.Growing())
+    }
+}
+
+func TestReadRSS(t *testing.T) {
+    rss, err := ReadRSS()
+    if err != nil {
+        t.Skipf("RSS unavailable: %v", err)
+    }
+    if rss == 0 {
+        t.Error("Expected a nonzero resident set size")
+    }
+}
@@ -0,0 +1,120 @@
+package startup
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+/**
+* Cold-start report
+
+For one-shot CLI runs the time before the first prediction is printed often
+dominates the inference itself. A Report splits that time into consecutive
+phases (binary init, config parse, weight load, first inference, ...) so
+that work on lazy loading or bulk reads can be aimed at the phase that
+actually costs the most.
+
+Phases are recorded with Mark, which charges everything since the previous
+mark to the named phase, so the phases add up to the wall time since the
+report was created. Binary init is measured from this package's
+initialisation, which happens before main but after the Go runtime itself
+has started; runtime bootstrap is therefore not included.
+*/
+
+// processStart is taken while packages are initialised, as close to process start as Go allows
+var processStart = time.Now()
+
+// ProcessStart returns the earliest time the program recorded
+func ProcessStart() time.Time {
+    return processStart
+}
+
+// Phase is one timed step of start-up
+type Phase struct {
+    Name     string
+    Duration time.Duration
+    Details  []Phase // Optional breakdown, e.g. per-layer weight loading
+}
+
+// Report collects consecutive start-up phases
+type Report struct {
+    Phases []Phase
+    last   time.Time
+}
+
+// NewReport starts a report; call it first thing in main
+// The time between package initialisation and this call is recorded as "binary init"
+func NewReport() *Report {
+    now := time.Now()
+    return &Report{
+        Phases: []Phase{{Name: "binary init", Duration: now.Sub(processStart)}},
+        last:   now,
+    }
+}
+
+// Mark records everything since the previous mark as the named phase and returns its duration
+func (r *Report) Mark(name string, details ...Phase) time.Duration {
+    now := time.Now()
+    elapsed := now.Sub(r.last)
+    r.last = now
+
+    r.Phases = append(r.Phases, Phase{Name: name, Duration: elapsed, Details: details})
+    return elapsed
+}
+
+// Skip discards the time since the previous mark, e.g. time spent printing output
+func (r *Report) Skip() {
+    r.last = time.Now()
+}
+
+// Total returns the sum of all phases
+func (r *Report) Total() time.Duration {
+    var total time.Duration
+    for _, phase := range r.Phases {
+        total += phase.Duration
+    }
+    return total
+}
+
+// Slowest returns the phase that took the longest
+func (r *Report) Slowest() Phase {
+    var slowest Phase
+    for _, phase := range r.Phases {
+        if phase.Duration > slowest.Duration {
+            slowest = phase
+        }
+    }
+    return slowest
+}
+
+// Write prints the phases as a table with each phase's share of the total
+func (r *Report) Write(w io.Writer) {
+    total := r.Total()
+
+    fmt.Fprintf(w, "Startup Report:\n")
+    for _, phase := range r.Phases {
+        writePhase(w, phase, total, 1)
+    }
+    fmt.Fprintf(w, "  %s\n", strings.Repeat("-", 48))
+    fmt.Fprintf(w, "  %-28s %12v\n", "Total", total)
+    if slowest := r.Slowest(); slowest.Duration > 0 {
+        fmt.Fprintf(w, "  Slowest phase: %s\n", slowest.Name)
+    }
+}
+
+// writePhase prints one phase and, indented below it, its details
+func writePhase(w io.Writer, phase Phase, total time.Duration, depth int) {
+    share := 0.0
+    if total > 0 {
+        share = float64(phase.Duration) / float64(total) * 100
+    }
+
+    indent := strings.Repeat("  ", depth)
+    fmt.Fprintf(w, "%s%-*s %12v %6.1f%%\n", indent, 30-2*depth, phase.Name, phase.Duration, share)
+
+    for _, detail := range phase.Details {
+        writePhase(w, detail, total, depth+1)
+    }
+}
@@ -0,0 +1,54 @@
+package startup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportPhases(t *testing.T) {
+    report := NewReport()
+    if len(report.Phases) != 1 || report.Phases[0].Name != "binary init" {
+        t.Fatalf("Expected the report to start with binary init, got %+v", report.Phases)
+    }
+    
+    time.Sleep(2 * time.Millisecond)
+    report.Mark("config parse")
+    
+    time.Sleep(time.Millisecond)
+    report.Skip()
+    
+    report.Mark("weight load",
+        Phase{Name: "conv1", Duration: time.Millisecond},
+        Phase{Name: "conv2", Duration: 2 * time.Millisecond})
+    
+    if len(report.Phases) != 3 {
+        t.Fatalf("Expected 3 phases, got %d", len(report.Phases))
+    }
+    if report.Phases[1].Duration < 2*time.Millisecond {
+        t.Errorf("config parse should cover the sleep, got %v", report.Phases[1].Duration)
+    }
+    if report.Phases[2].Duration >= time.Millisecond {
+        t.Errorf("Skipped time should not be charged to weight load, got %v", report.Phases[2].Duration)
+    }
+    if report.Slowest().Name != "config parse" {
+        t.Errorf("Expected config parse to be slowest, got %s", report.Slowest().Name)
+    }
+    
+    var total time.Duration
+    for _, phase := range report.Phases {
+        total += phase.Duration
+    }
+    if report.Total() != total {
+        t.Errorf("Total %v does not match sum of phases %v", report.Total(), total)
+    }
+    
+    var out bytes.Buffer
+    report.Write(&out)
+    for _, want := range []string{"Startup Report:", "binary init", "config parse", "    conv2", "Total", "Slowest phase: config parse"} {
+        if !strings.Contains(out.String(), want) {
+            t.Errorf("Report output missing %q:\n%s", want, out.String())
+        }
+    }
+}
@@ -14,12 +14,14 @@ import (
 */
 
 // FeatureMap represents a 3D tensor for CNN feature maps
-// Data layout: [channel][height][width] in row-major order
+// Data layout: [channel][height][width] in row-major order, or
+// [height][width][channel] when Layout is LayoutHWC
 type FeatureMap struct {
     Height   int       // Height dimension
     Width    int       // Width dimension  
     Channels int       // Number of channels
     Data     []float32 // Flat array: len = height * width * channels
+    Layout   Layout    // Element order of Data (zero value is LayoutCHW)
 }
 
 // NewFeatureMap creates a new feature map with given dimensions
@@ -39,25 +41,20 @@ func NewFeatureMapFromData(data []float32, height, width, channels int) (*Featur
         return nil, fmt.Errorf("data size mismatch: expected %d, got %d", expectedSize, len(data))
     }
     
-    fm := &FeatureMap{
-        Height:   height,
-        Width:    width,
-        Channels: channels,
-        Data:     make([]float32, expectedSize),
-    }
+    fm := NewFeatureMap(height, width, channels)
     
     copy(fm.Data, data)
     return fm, nil
 }
 
 // Get returns the value at the specified position
-// Formula: index = c*height*width + h*width + w
+// Formula: index = c*height*width + h*width + w (CHW) or (h*width + w)*channels + c (HWC)
 func (fm *FeatureMap) Get(c, h, w int) float32 {
     if !fm.isValidIndex(c, h, w) {
         panic(fmt.Sprintf("index out of bounds: (%d,%d,%d) for shape (%d,%d,%d)", 
             c, h, w, fm.Channels, fm.Height, fm.Width))
     }
-    return fm.Data[c*fm.Height*fm.Width + h*fm.Width + w]
+    return fm.Data[fm.Index(c, h, w)]
 }
 
 // Set sets the value at the specified position
@@ -66,17 +63,17 @@ func (fm *FeatureMap) Set(c, h, w int, value float32) {
         panic(fmt.Sprintf("index out of bounds: (%d,%d,%d) for shape (%d,%d,%d)", 
             c, h, w, fm.Channels, fm.Height, fm.Width))
     }
-    fm.Data[c*fm.Height*fm.Width + h*fm.Width + w] = value
+    fm.Data[fm.Index(c, h, w)] = value
 }
 
 // GetUnsafe returns the value without bounds checking (for performance)
 func (fm *FeatureMap) GetUnsafe(c, h, w int) float32 {
-    return fm.Data[c*fm.Height*fm.Width + h*fm.Width + w]
+    return fm.Data[fm.Index(c, h, w)]
 }
 
 // SetUnsafe sets the value without bounds checking (for performance)
 func (fm *FeatureMap) SetUnsafe(c, h, w int, value float32) {
-    fm.Data[c*fm.Height*fm.Width + h*fm.Width + w] = value
+    fm.Data[fm.Index(c, h, w)] = value
 }
 
 // isValidIndex checks if the given indices are within bounds
@@ -88,7 +85,7 @@ func (fm *FeatureMap) isValidIndex(c, h, w int) bool {
 
 // Clone creates a deep copy of the feature map
 func (fm *FeatureMap) Clone() *FeatureMap {
-    clone := NewFeatureMap(fm.Height, fm.Width, fm.Channels)
+    clone := NewFeatureMapWithLayout(fm.Height, fm.Width, fm.Channels, fm.Layout)
     copy(clone.Data, fm.Data)
     return clone
 }
@@ -126,6 +123,6 @@ func (fm *FeatureMap) Size() int {
 
 // String provides a string representation (for debugging)
 func (fm *FeatureMap) String() string {
-    return fmt.Sprintf("FeatureMap{Height: %d, Width: %d, Channels: %d, Size: %d}", 
-        fm.Height, fm.Width, fm.Channels, fm.Size())
+    return fmt.Sprintf("FeatureMap{Height: %d, Width: %d, Channels: %d, Size: %d, Layout: %s}", 
+        fm.Height, fm.Width, fm.Channels, fm.Size(), fm.Layout)
 }
\ No newline at end of file
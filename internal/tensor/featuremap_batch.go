@@ -0,0 +1,161 @@
+package tensor
+
+import (
+	"fmt"
+)
+
+/**
+* Batches of feature maps
+
+Batched inference runs every layer over N images of the same shape. A
+FeatureMapBatch stores them back to back in one slice, each image in the
+batch's Layout, so the batch is an N×C×H×W (NCHW) or N×H×W×C (NHWC) tensor:
+```
+Data   [image 0 ............][image 1 ............] ... [image N-1 .........]
+        C·H·W values each, in CHW or HWC order
+```
+Image(i) is a FeatureMap sharing image i's part of Data, so every
+single-image op works on a batch image without copying, and the batched
+ops (ops.ConvolutionEngine.Conv2DFusedBatch4D, ops.Pooling2DBatch4D,
+ops.BatchNormalizeBatch4D) write their results straight into the output
+batch.
+*/
+
+// FeatureMapBatch is a 4D tensor of N feature maps of the same shape and layout
+type FeatureMapBatch struct {
+    N        int       // Number of images
+    Height   int       // Height of every image
+    Width    int       // Width of every image
+    Channels int       // Channels of every image
+    Data     []float32 // Flat array: len = n * height * width * channels, image after image
+    Layout   Layout    // Element order of every image (zero value is LayoutCHW)
+}
+
+// NewFeatureMapBatch creates a zeroed batch of n CHW feature maps
+func NewFeatureMapBatch(n, height, width, channels int) *FeatureMapBatch {
+    return NewFeatureMapBatchWithLayout(n, height, width, channels, LayoutCHW)
+}
+
+// NewFeatureMapBatchWithLayout creates a zeroed batch of n feature maps with the given element order
+func NewFeatureMapBatchWithLayout(n, height, width, channels int, layout Layout) *FeatureMapBatch {
+    return &FeatureMapBatch{
+        N:        n,
+        Height:   height,
+        Width:    width,
+        Channels: channels,
+        Data:     make([]float32, n*height*width*channels),
+        Layout:   layout,
+    }
+}
+
+// StackFeatureMaps copies feature maps of the same shape and layout into one batch
+func StackFeatureMaps(maps []*FeatureMap) (*FeatureMapBatch, error) {
+    if len(maps) == 0 {
+        return nil, fmt.Errorf("no feature maps to stack")
+    }
+    first := maps[0]
+    batch := NewFeatureMapBatchWithLayout(len(maps), first.Height, first.Width, first.Channels, first.Layout)
+    for i, fm := range maps {
+        if fm.Height != first.Height || fm.Width != first.Width || fm.Channels != first.Channels || fm.Layout != first.Layout {
+            return nil, fmt.Errorf("feature map %d is %s, feature map 0 is %s", i, fm, first)
+        }
+        copy(batch.Image(i).Data, fm.Data)
+    }
+    return batch, nil
+}
+
+// ImageSize returns the number of elements of one image
+func (b *FeatureMapBatch) ImageSize() int {
+    return b.Height * b.Width * b.Channels
+}
+
+// Image returns image i as a feature map sharing the batch's data
+func (b *FeatureMapBatch) Image(i int) *FeatureMap {
+    if i < 0 || i >= b.N {
+        panic(fmt.Sprintf("image %d out of bounds for a batch of %d", i, b.N))
+    }
+    size := b.ImageSize()
+    return &FeatureMap{
+        Height:   b.Height,
+        Width:    b.Width,
+        Channels: b.Channels,
+        Data:     b.Data[i*size : (i+1)*size : (i+1)*size],
+        Layout:   b.Layout,
+    }
+}
+
+// Images returns every image as a feature map sharing the batch's data
+func (b *FeatureMapBatch) Images() []*FeatureMap {
+    images := make([]*FeatureMap, b.N)
+    for i := range images {
+        images[i] = b.Image(i)
+    }
+    return images
+}
+
+// Get returns the value of image n at (c, h, w)
+func (b *FeatureMapBatch) Get(n, c, h, w int) float32 {
+    return b.Image(n).Get(c, h, w)
+}
+
+// Set sets the value of image n at (c, h, w)
+func (b *FeatureMapBatch) Set(n, c, h, w int, value float32) {
+    b.Image(n).Set(c, h, w, value)
+}
+
+// Clone creates a deep copy of the batch
+func (b *FeatureMapBatch) Clone() *FeatureMapBatch {
+    clone := NewFeatureMapBatchWithLayout(b.N, b.Height, b.Width, b.Channels, b.Layout)
+    copy(clone.Data, b.Data)
+    return clone
+}
+
+// Shape returns the dimensions as a slice [n, height, width, channels]
+func (b *FeatureMapBatch) Shape() []int {
+    return []int{b.N, b.Height, b.Width, b.Channels}
+}
+
+// Size returns the total number of elements
+func (b *FeatureMapBatch) Size() int {
+    return len(b.Data)
+}
+
+// Tensor returns an [n][channels][height][width] view of the batch's data,
+// whatever its layout
+func (b *FeatureMapBatch) Tensor() *Tensor {
+    shape := []int{b.N, b.Channels, b.Height, b.Width}
+    if b.Layout == LayoutHWC {
+        return &Tensor{data: b.Data, shape: shape,
+            strides: []int{b.ImageSize(), 1, b.Width * b.Channels, b.Channels}}
+    }
+    return &Tensor{data: b.Data, shape: shape, strides: rowMajorStrides(shape)}
+}
+
+// FeatureMapBatch returns a rank-4 tensor of shape [n][channels][height][width]
+// as a batch, sharing its data when it is stored in NCHW or NHWC order
+func (t *Tensor) FeatureMapBatch() (*FeatureMapBatch, error) {
+    if len(t.shape) != 4 {
+        return nil, fmt.Errorf("a feature map batch needs an [n][channels][height][width] tensor, got shape %v", t.shape)
+    }
+    n, channels, height, width := t.shape[0], t.shape[1], t.shape[2], t.shape[3]
+    batch := &FeatureMapBatch{N: n, Height: height, Width: width, Channels: channels}
+
+    image := channels * height * width
+    nhwc := &Tensor{shape: t.shape, strides: []int{image, 1, width * channels, channels}}
+    size := t.Size()
+    switch {
+    case t.IsContiguous():
+        batch.Data = t.data[t.offset : t.offset+size]
+    case sameSteps(t, nhwc):
+        batch.Data, batch.Layout = t.data[t.offset:t.offset+size], LayoutHWC
+    default:
+        batch.Data = t.Values()
+    }
+    return batch, nil
+}
+
+// String provides a string representation (for debugging)
+func (b *FeatureMapBatch) String() string {
+    return fmt.Sprintf("FeatureMapBatch{N: %d, Height: %d, Width: %d, Channels: %d, Size: %d, Layout: %s}",
+        b.N, b.Height, b.Width, b.Channels, b.Size(), b.Layout)
+}
@@ -0,0 +1,59 @@
+package tensor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFeatureMapBatch(t *testing.T) {
+    maps := make([]*FeatureMap, 3)
+    for i := range maps {
+        maps[i] = NewFeatureMap(2, 3, 4)
+        maps[i].RandomFill()
+    }
+    batch, err := StackFeatureMaps(maps)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !slices.Equal(batch.Shape(), []int{3, 2, 3, 4}) || batch.Size() != 72 {
+        t.Fatalf("Unexpected batch %s", batch)
+    }
+
+    // Images are views of the batch's data
+    image := batch.Image(2)
+    if image.Get(3, 1, 2) != maps[2].Get(3, 1, 2) || batch.Get(2, 3, 1, 2) != maps[2].Get(3, 1, 2) {
+        t.Error("Expected image 2 to hold the third feature map")
+    }
+    image.Set(0, 0, 0, -1)
+    if batch.Data[48] != -1 {
+        t.Error("Expected a write to an image to reach the batch")
+    }
+    if grown := append(batch.Image(0).Data, 5); &grown[0] == &batch.Data[0] {
+        t.Error("Expected appending to an image not to overwrite the next one")
+    }
+
+    // The tensor view reads [n][c][h][w] in either layout, and converts back
+    for _, layout := range []Layout{LayoutCHW, LayoutHWC} {
+        converted := make([]*FeatureMap, len(maps))
+        for i, fm := range maps {
+            converted[i] = fm.ToLayout(layout)
+        }
+        stacked, _ := StackFeatureMaps(converted)
+        view := stacked.Tensor()
+        if view.At(1, 3, 1, 2) != maps[1].Get(3, 1, 2) {
+            t.Errorf("%s: expected the view to read image 1", layout)
+        }
+        back, err := view.FeatureMapBatch()
+        if err != nil || back.Layout != layout || &back.Data[0] != &stacked.Data[0] {
+            t.Errorf("%s: expected the batch back sharing its data, got %v (%v)", layout, back, err)
+        }
+    }
+
+    mixed := []*FeatureMap{maps[0], NewFeatureMap(2, 3, 5)}
+    if _, err := StackFeatureMaps(mixed); err == nil {
+        t.Error("Expected an error stacking feature maps of different shapes")
+    }
+    if _, err := StackFeatureMaps(nil); err == nil {
+        t.Error("Expected an error stacking no feature maps")
+    }
+}
@@ -0,0 +1,197 @@
+package tensor
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// FeatureMap1D represents a 2D tensor for 1D convolutions (audio, spectrogram frames)
+// Data layout: [channel][length] in row-major order
+type FeatureMap1D struct {
+    Length   int       // Sequence length
+    Channels int       // Number of channels
+    Data     []float32 // Flat array: len = length * channels
+}
+
+// NewFeatureMap1D creates a new 1D feature map with given dimensions
+func NewFeatureMap1D(length, channels int) *FeatureMap1D {
+    return &FeatureMap1D{
+        Length:   length,
+        Channels: channels,
+        Data:     make([]float32, length*channels),
+    }
+}
+
+// NewFeatureMap1DFromData creates a 1D feature map from existing data
+func NewFeatureMap1DFromData(data []float32, length, channels int) (*FeatureMap1D, error) {
+    expectedSize := length * channels
+    if len(data) != expectedSize {
+        return nil, fmt.Errorf("data size mismatch: expected %d, got %d", expectedSize, len(data))
+    }
+    
+    fm := NewFeatureMap1D(length, channels)
+    copy(fm.Data, data)
+    return fm, nil
+}
+
+// Get returns the value at the specified position
+// Formula: index = c*length + l
+func (fm *FeatureMap1D) Get(c, l int) float32 {
+    if c < 0 || c >= fm.Channels || l < 0 || l >= fm.Length {
+        panic(fmt.Sprintf("index out of bounds: (%d,%d) for shape (%d,%d)", 
+            c, l, fm.Channels, fm.Length))
+    }
+    return fm.Data[c*fm.Length + l]
+}
+
+// Set sets the value at the specified position
+func (fm *FeatureMap1D) Set(c, l int, value float32) {
+    if c < 0 || c >= fm.Channels || l < 0 || l >= fm.Length {
+        panic(fmt.Sprintf("index out of bounds: (%d,%d) for shape (%d,%d)", 
+            c, l, fm.Channels, fm.Length))
+    }
+    fm.Data[c*fm.Length + l] = value
+}
+
+// GetUnsafe returns the value without bounds checking (for performance)
+func (fm *FeatureMap1D) GetUnsafe(c, l int) float32 {
+    return fm.Data[c*fm.Length + l]
+}
+
+// SetUnsafe sets the value without bounds checking (for performance)
+func (fm *FeatureMap1D) SetUnsafe(c, l int, value float32) {
+    fm.Data[c*fm.Length + l] = value
+}
+
+// Clone creates a deep copy of the 1D feature map
+func (fm *FeatureMap1D) Clone() *FeatureMap1D {
+    clone := NewFeatureMap1D(fm.Length, fm.Channels)
+    copy(clone.Data, fm.Data)
+    return clone
+}
+
+// RandomFill fills the 1D feature map with random values for testing
+func (fm *FeatureMap1D) RandomFill() {
+    for i := range fm.Data {
+        fm.Data[i] = rand.Float32()
+    }
+}
+
+// Shape returns the dimensions as a slice [length, channels]
+func (fm *FeatureMap1D) Shape() []int {
+    return []int{fm.Length, fm.Channels}
+}
+
+// String provides a string representation (for debugging)
+func (fm *FeatureMap1D) String() string {
+    return fmt.Sprintf("FeatureMap1D{Length: %d, Channels: %d, Size: %d}", 
+        fm.Length, fm.Channels, len(fm.Data))
+}
+
+// FeatureMap3D represents a 4D tensor for 3D convolutions (video clips, volumes)
+// Data layout: [channel][depth][height][width] in row-major order
+type FeatureMap3D struct {
+    Depth    int       // Depth (time) dimension
+    Height   int       // Height dimension
+    Width    int       // Width dimension
+    Channels int       // Number of channels
+    Data     []float32 // Flat array: len = depth * height * width * channels
+}
+
+// NewFeatureMap3D creates a new 3D feature map with given dimensions
+func NewFeatureMap3D(depth, height, width, channels int) *FeatureMap3D {
+    return &FeatureMap3D{
+        Depth:    depth,
+        Height:   height,
+        Width:    width,
+        Channels: channels,
+        Data:     make([]float32, depth*height*width*channels),
+    }
+}
+
+// NewFeatureMap3DFromData creates a 3D feature map from existing data
+func NewFeatureMap3DFromData(data []float32, depth, height, width, channels int) (*FeatureMap3D, error) {
+    expectedSize := depth * height * width * channels
+    if len(data) != expectedSize {
+        return nil, fmt.Errorf("data size mismatch: expected %d, got %d", expectedSize, len(data))
+    }
+    
+    fm := NewFeatureMap3D(depth, height, width, channels)
+    copy(fm.Data, data)
+    return fm, nil
+}
+
+// Get returns the value at the specified position
+// Formula: index = ((c*depth + d)*height + h)*width + w
+func (fm *FeatureMap3D) Get(c, d, h, w int) float32 {
+    if !fm.isValidIndex(c, d, h, w) {
+        panic(fmt.Sprintf("index out of bounds: (%d,%d,%d,%d) for shape (%d,%d,%d,%d)", 
+            c, d, h, w, fm.Channels, fm.Depth, fm.Height, fm.Width))
+    }
+    return fm.GetUnsafe(c, d, h, w)
+}
+
+// Set sets the value at the specified position
+func (fm *FeatureMap3D) Set(c, d, h, w int, value float32) {
+    if !fm.isValidIndex(c, d, h, w) {
+        panic(fmt.Sprintf("index out of bounds: (%d,%d,%d,%d) for shape (%d,%d,%d,%d)", 
+            c, d, h, w, fm.Channels, fm.Depth, fm.Height, fm.Width))
+    }
+    fm.SetUnsafe(c, d, h, w, value)
+}
+
+// GetUnsafe returns the value without bounds checking (for performance)
+func (fm *FeatureMap3D) GetUnsafe(c, d, h, w int) float32 {
+    return fm.Data[((c*fm.Depth + d)*fm.Height + h)*fm.Width + w]
+}
+
+// SetUnsafe sets the value without bounds checking (for performance)
+func (fm *FeatureMap3D) SetUnsafe(c, d, h, w int, value float32) {
+    fm.Data[((c*fm.Depth + d)*fm.Height + h)*fm.Width + w] = value
+}
+
+// isValidIndex checks if the given indices are within bounds
+func (fm *FeatureMap3D) isValidIndex(c, d, h, w int) bool {
+    return c >= 0 && c < fm.Channels &&
+           d >= 0 && d < fm.Depth &&
+           h >= 0 && h < fm.Height &&
+           w >= 0 && w < fm.Width
+}
+
+// Clone creates a deep copy of the 3D feature map
+func (fm *FeatureMap3D) Clone() *FeatureMap3D {
+    clone := NewFeatureMap3D(fm.Depth, fm.Height, fm.Width, fm.Channels)
+    copy(clone.Data, fm.Data)
+    return clone
+}
+
+// RandomFill fills the 3D feature map with random values for testing
+func (fm *FeatureMap3D) RandomFill() {
+    for i := range fm.Data {
+        fm.Data[i] = rand.Float32()
+    }
+}
+
+// Frame returns a copy of a single depth slice as a 2D feature map
+func (fm *FeatureMap3D) Frame(d int) *FeatureMap {
+    frame := NewFeatureMap(fm.Height, fm.Width, fm.Channels)
+    for c := 0; c < fm.Channels; c++ {
+        for h := 0; h < fm.Height; h++ {
+            for w := 0; w < fm.Width; w++ {
+                frame.SetUnsafe(c, h, w, fm.GetUnsafe(c, d, h, w))
+            }
+        }
+    }
+    return frame
+}
+
+// Shape returns the dimensions as a slice [depth, height, width, channels]
+func (fm *FeatureMap3D) Shape() []int {
+    return []int{fm.Depth, fm.Height, fm.Width, fm.Channels}
+}
+
+// String provides a string representation (for debugging)
+func (fm *FeatureMap3D) String() string {
+    return fmt.Sprintf("FeatureMap3D{Depth: %d, Height: %d, Width: %d, Channels: %d, Size: %d}", 
+        fm.Depth, fm.Height, fm.Width, fm.Channels, len(fm.Data))
+}
@@ -135,6 +135,84 @@ func TestPadFeatureMap(t *testing.T) {
     }
 }
 
+func TestPadFeatureMapInto(t *testing.T) {
+    original := NewFeatureMap(3, 2, 2)
+    original.RandomFill()
+    
+    // Start from a dirty buffer, as a pooled one would be
+    dst := NewFeatureMap(5, 4, 2)
+    dst.Fill(7.0)
+    
+    PadFeatureMapInto(dst, original, 1)
+    
+    expected := PadFeatureMap(original, 1)
+    for i := range expected.Data {
+        if dst.Data[i] != expected.Data[i] {
+            t.Fatalf("Mismatch at index %d: expected %f, got %f", i, expected.Data[i], dst.Data[i])
+        }
+    }
+}
+
+func TestFeatureMapLayoutHWC(t *testing.T) {
+    chw := NewFeatureMap(3, 4, 2)
+    chw.RandomFill()
+    
+    hwc := chw.ToLayout(LayoutHWC)
+    if hwc.Layout != LayoutHWC {
+        t.Fatalf("Expected HWC layout, got %s", hwc.Layout)
+    }
+    
+    // Same logical values, different physical order
+    for c := 0; c < 2; c++ {
+        for h := 0; h < 3; h++ {
+            for w := 0; w < 4; w++ {
+                if hwc.Get(c, h, w) != chw.Get(c, h, w) {
+                    t.Fatalf("Value mismatch at (%d,%d,%d)", c, h, w)
+                }
+                if hwc.Data[(h*4+w)*2+c] != chw.Get(c, h, w) {
+                    t.Fatalf("HWC element (%d,%d,%d) stored at wrong index", c, h, w)
+                }
+            }
+        }
+    }
+    
+    back := hwc.ToLayout(LayoutCHW)
+    for i := range chw.Data {
+        if back.Data[i] != chw.Data[i] {
+            t.Fatalf("Round trip mismatch at index %d", i)
+        }
+    }
+    
+    // Padding keeps the layout and zero border
+    padded := PadFeatureMap(hwc, 1)
+    dst := NewFeatureMap(5, 6, 2)
+    dst.Fill(3)
+    PadFeatureMapInto(dst, hwc, 1)
+    if padded.Layout != LayoutHWC || dst.Layout != LayoutHWC {
+        t.Fatal("Padding did not preserve HWC layout")
+    }
+    for i := range padded.Data {
+        if dst.Data[i] != padded.Data[i] {
+            t.Fatalf("PadFeatureMapInto mismatch at index %d: expected %f, got %f", i, padded.Data[i], dst.Data[i])
+        }
+    }
+    if padded.Get(1, 0, 0) != 0 || padded.Get(1, 2, 3) != chw.Get(1, 1, 2) {
+        t.Error("Padded HWC feature map has wrong contents")
+    }
+}
+
+func TestParseLayout(t *testing.T) {
+    for name, expected := range map[string]Layout{"chw": LayoutCHW, "NCHW": LayoutCHW, "hwc": LayoutHWC, "NHWC": LayoutHWC} {
+        layout, err := ParseLayout(name)
+        if err != nil || layout != expected {
+            t.Errorf("ParseLayout(%q) = %s, %v; expected %s", name, layout, err, expected)
+        }
+    }
+    if _, err := ParseLayout("whc"); err == nil {
+        t.Error("Expected error for unknown layout")
+    }
+}
+
 // Benchmark tests
 func BenchmarkFeatureMapGet(b *testing.B) {
     fm := NewFeatureMap(32, 32, 3)
@@ -0,0 +1,154 @@
+package tensor
+
+import (
+	"fmt"
+)
+
+/**
+* Generic element types
+
+The engine's containers hold float32, which is what every optimized op is
+written for. FeatureMapOf and KernelOf are the same containers over any
+Float element type, with the same fields and index order, so reference ops
+can be written once and instantiated at float64 as well:
+```
+float32 model  →  FeatureMapAs[float64]  →  ops.*Of[float64]  →  FeatureMap()  →  compare
+                  (exact widening)          (double accumulation)  (rounds once)
+```
+Widening float32 to float64 is exact, so a double-precision run sees the same
+weights and inputs and differs from the float32 run only by the rounding of
+its arithmetic. That isolates accumulation error when debugging numerics.
+*/
+
+// Float is the set of element types the generic containers and ops accept
+type Float interface {
+    ~float32 | ~float64
+}
+
+// FeatureMapOf is a FeatureMap whose elements are of type T
+type FeatureMapOf[T Float] struct {
+    Height   int    // Height dimension
+    Width    int    // Width dimension
+    Channels int    // Number of channels
+    Data     []T    // Flat array: len = height * width * channels
+    Layout   Layout // Element order of Data (zero value is LayoutCHW)
+}
+
+// NewFeatureMapOf creates a zeroed feature map of T with the given element order
+func NewFeatureMapOf[T Float](height, width, channels int, layout Layout) *FeatureMapOf[T] {
+    return &FeatureMapOf[T]{
+        Height:   height,
+        Width:    width,
+        Channels: channels,
+        Data:     make([]T, height*width*channels),
+        Layout:   layout,
+    }
+}
+
+// FeatureMapAs converts a float32 feature map to one of T, keeping its layout
+func FeatureMapAs[T Float](fm *FeatureMap) *FeatureMapOf[T] {
+    return &FeatureMapOf[T]{
+        Height:   fm.Height,
+        Width:    fm.Width,
+        Channels: fm.Channels,
+        Data:     ConvertSlice[T](fm.Data),
+        Layout:   fm.Layout,
+    }
+}
+
+// FeatureMapView returns fm as a FeatureMapOf[float32] sharing its data, so
+// generic code runs on float32 maps without copying them
+func FeatureMapView(fm *FeatureMap) *FeatureMapOf[float32] {
+    return &FeatureMapOf[float32]{
+        Height:   fm.Height,
+        Width:    fm.Width,
+        Channels: fm.Channels,
+        Data:     fm.Data,
+        Layout:   fm.Layout,
+    }
+}
+
+// FeatureMap converts the feature map to float32, rounding each element once
+func (fm *FeatureMapOf[T]) FeatureMap() *FeatureMap {
+    return &FeatureMap{
+        Height:   fm.Height,
+        Width:    fm.Width,
+        Channels: fm.Channels,
+        Data:     ConvertSlice[float32](fm.Data),
+        Layout:   fm.Layout,
+    }
+}
+
+// Index returns the position of (c, h, w) in Data for the feature map's layout
+func (fm *FeatureMapOf[T]) Index(c, h, w int) int {
+    if fm.Layout == LayoutHWC {
+        return (h*fm.Width+w)*fm.Channels + c
+    }
+    return (c*fm.Height+h)*fm.Width + w
+}
+
+// Get returns the value at the specified position
+func (fm *FeatureMapOf[T]) Get(c, h, w int) T {
+    if c < 0 || c >= fm.Channels || h < 0 || h >= fm.Height || w < 0 || w >= fm.Width {
+        panic(fmt.Sprintf("index out of bounds: (%d,%d,%d) for shape (%d,%d,%d)",
+            c, h, w, fm.Channels, fm.Height, fm.Width))
+    }
+    return fm.Data[fm.Index(c, h, w)]
+}
+
+// Set sets the value at the specified position
+func (fm *FeatureMapOf[T]) Set(c, h, w int, value T) {
+    if c < 0 || c >= fm.Channels || h < 0 || h >= fm.Height || w < 0 || w >= fm.Width {
+        panic(fmt.Sprintf("index out of bounds: (%d,%d,%d) for shape (%d,%d,%d)",
+            c, h, w, fm.Channels, fm.Height, fm.Width))
+    }
+    fm.Data[fm.Index(c, h, w)] = value
+}
+
+// Size returns the total number of elements
+func (fm *FeatureMapOf[T]) Size() int {
+    return len(fm.Data)
+}
+
+// String provides a string representation (for debugging)
+func (fm *FeatureMapOf[T]) String() string {
+    return fmt.Sprintf("FeatureMapOf[%T]{Height: %d, Width: %d, Channels: %d, Size: %d, Layout: %s}",
+        T(0), fm.Height, fm.Width, fm.Channels, fm.Size(), fm.Layout)
+}
+
+// KernelOf is a Kernel whose weights are of type T
+// Weights keep the [filter][channel][height][width] order of Kernel
+type KernelOf[T Float] struct {
+    Size     int
+    Channels int
+    Filters  int
+    Weights  []T
+}
+
+// KernelAs converts a float32 kernel to one of T
+func KernelAs[T Float](kernel *Kernel) *KernelOf[T] {
+    return &KernelOf[T]{
+        Size:     kernel.Size,
+        Channels: kernel.Channels,
+        Filters:  kernel.Filters,
+        Weights:  ConvertSlice[T](kernel.Weights),
+    }
+}
+
+// GetWeight returns the weight at the specified position
+func (k *KernelOf[T]) GetWeight(f, c, h, w int) T {
+    if f < 0 || f >= k.Filters || c < 0 || c >= k.Channels || h < 0 || h >= k.Size || w < 0 || w >= k.Size {
+        panic(fmt.Sprintf("kernel index out of bounds: (%d,%d,%d,%d) for shape (%d,%d,%d,%d)",
+            f, c, h, w, k.Filters, k.Channels, k.Size, k.Size))
+    }
+    return k.Weights[((f*k.Channels+c)*k.Size+h)*k.Size+w]
+}
+
+// ConvertSlice returns a copy of src with every element converted to To
+func ConvertSlice[To, From Float](src []From) []To {
+    dst := make([]To, len(src))
+    for i, v := range src {
+        dst[i] = To(v)
+    }
+    return dst
+}
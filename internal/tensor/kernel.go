@@ -114,4 +114,22 @@ func (k *Kernel) TotalWeights() int {
 func (k *Kernel) String() string {
     return fmt.Sprintf("Kernel{Size: %d, Channels: %d, Filters: %d, Weights: %d}", 
         k.Size, k.Channels, k.Filters, k.TotalWeights())
-}
\ No newline at end of file
+}
+
+// WeightsHWC returns a copy of the weights reordered to [filter][height][width][channel],
+// the order in which an HWC convolution reads them
+func (k *Kernel) WeightsHWC() []float32 {
+    out := make([]float32, len(k.Weights))
+    idx := 0
+    for f := 0; f < k.Filters; f++ {
+        for h := 0; h < k.Size; h++ {
+            for w := 0; w < k.Size; w++ {
+                for c := 0; c < k.Channels; c++ {
+                    out[idx] = k.GetWeightUnsafe(f, c, h, w)
+                    idx++
+                }
+            }
+        }
+    }
+    return out
+}
@@ -0,0 +1,102 @@
+package tensor
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// Kernel1D represents 1D convolution weights in 3D format
+// Data layout: [filter][channel][k] in row-major order
+type Kernel1D struct {
+    Size     int       // Kernel length (e.g., 3)
+    Channels int       // Number of input channels
+    Filters  int       // Number of output filters
+    Weights  []float32 // Flat array: len = size * channels * filters
+}
+
+// NewKernel1D creates a new 1D kernel with given dimensions
+func NewKernel1D(size, channels, filters int) *Kernel1D {
+    return &Kernel1D{
+        Size:     size,
+        Channels: channels,
+        Filters:  filters,
+        Weights:  make([]float32, size*channels*filters),
+    }
+}
+
+// GetWeightUnsafe returns the weight without bounds checking
+// Formula: index = (f*channels + c)*size + k
+func (k *Kernel1D) GetWeightUnsafe(f, c, i int) float32 {
+    return k.Weights[(f*k.Channels + c)*k.Size + i]
+}
+
+// SetWeightUnsafe sets the weight without bounds checking
+func (k *Kernel1D) SetWeightUnsafe(f, c, i int, value float32) {
+    k.Weights[(f*k.Channels + c)*k.Size + i] = value
+}
+
+// RandomFill fills the kernel with random values for testing
+func (k *Kernel1D) RandomFill() {
+    for i := range k.Weights {
+        k.Weights[i] = rand.Float32()*2 - 1
+    }
+}
+
+// Shape returns the dimensions as a slice [filters, channels, size]
+func (k *Kernel1D) Shape() []int {
+    return []int{k.Filters, k.Channels, k.Size}
+}
+
+// String provides a string representation
+func (k *Kernel1D) String() string {
+    return fmt.Sprintf("Kernel1D{Size: %d, Channels: %d, Filters: %d, Weights: %d}", 
+        k.Size, k.Channels, k.Filters, len(k.Weights))
+}
+
+// Kernel3D represents 3D convolution weights in 5D format
+// Data layout: [filter][channel][depth][height][width] in row-major order
+type Kernel3D struct {
+    Size     int       // Kernel size along every axis (e.g., 3 for 3x3x3)
+    Channels int       // Number of input channels
+    Filters  int       // Number of output filters
+    Weights  []float32 // Flat array: len = size^3 * channels * filters
+}
+
+// NewKernel3D creates a new 3D kernel with given dimensions
+func NewKernel3D(size, channels, filters int) *Kernel3D {
+    return &Kernel3D{
+        Size:     size,
+        Channels: channels,
+        Filters:  filters,
+        Weights:  make([]float32, size*size*size*channels*filters),
+    }
+}
+
+// GetWeightUnsafe returns the weight without bounds checking
+// Formula: index = (((f*channels + c)*size + d)*size + h)*size + w
+func (k *Kernel3D) GetWeightUnsafe(f, c, d, h, w int) float32 {
+    return k.Weights[(((f*k.Channels + c)*k.Size + d)*k.Size + h)*k.Size + w]
+}
+
+// SetWeightUnsafe sets the weight without bounds checking
+func (k *Kernel3D) SetWeightUnsafe(f, c, d, h, w int, value float32) {
+    k.Weights[(((f*k.Channels + c)*k.Size + d)*k.Size + h)*k.Size + w] = value
+}
+
+// RandomFill fills the kernel with random values for testing
+func (k *Kernel3D) RandomFill() {
+    for i := range k.Weights {
+        k.Weights[i] = rand.Float32()*2 - 1
+    }
+}
+
+// Shape returns the dimensions as a slice [filters, channels, size, size, size]
+func (k *Kernel3D) Shape() []int {
+    return []int{k.Filters, k.Channels, k.Size, k.Size, k.Size}
+}
+
+// String provides a string representation
+func (k *Kernel3D) String() string {
+    return fmt.Sprintf("Kernel3D{Size: %d, Channels: %d, Filters: %d, Weights: %d}", 
+        k.Size, k.Channels, k.Filters, len(k.Weights))
+}
@@ -0,0 +1,96 @@
+package tensor
+
+import (
+    "fmt"
+    "strings"
+)
+
+/**
+* **Learning Note**: CHW vs HWC
+- **CHW** (channel-major) keeps each channel plane contiguous, which suits
+  per-channel ops such as batch norm and pooling
+- **HWC** (channel-minor) keeps all channels of a pixel contiguous, so the inner
+  loop of a 3×3 convolution walks a dense run of `channels` values for every
+  kernel tap, which vectorizes better on small images with many channels
+- Ops honor the layout tag of their input and produce outputs in the same layout
+*/
+
+// Layout identifies the element order of a FeatureMap's Data slice
+type Layout int
+
+const (
+    LayoutCHW Layout = iota // [channel][height][width], the default
+    LayoutHWC               // [height][width][channel], a.k.a. NHWC per image
+)
+
+// String returns the conventional name of the layout
+func (l Layout) String() string {
+    switch l {
+    case LayoutCHW:
+        return "CHW"
+    case LayoutHWC:
+        return "HWC"
+    default:
+        return fmt.Sprintf("Layout(%d)", int(l))
+    }
+}
+
+// ParseLayout converts "chw"/"nchw" or "hwc"/"nhwc" (any case) to a Layout
+func ParseLayout(name string) (Layout, error) {
+    switch strings.ToLower(name) {
+    case "", "chw", "nchw":
+        return LayoutCHW, nil
+    case "hwc", "nhwc":
+        return LayoutHWC, nil
+    default:
+        return LayoutCHW, fmt.Errorf("unknown layout %q (valid: chw, hwc)", name)
+    }
+}
+
+// NewFeatureMapWithLayout creates a new zeroed feature map with the given element order
+func NewFeatureMapWithLayout(height, width, channels int, layout Layout) *FeatureMap {
+    fm := NewFeatureMap(height, width, channels)
+    fm.Layout = layout
+    return fm
+}
+
+// Index returns the position of (c, h, w) in Data for the feature map's layout
+func (fm *FeatureMap) Index(c, h, w int) int {
+    if fm.Layout == LayoutHWC {
+        return (h*fm.Width+w)*fm.Channels + c
+    }
+    return (c*fm.Height+h)*fm.Width + w
+}
+
+// ToLayout returns a copy of the feature map with its data reordered to layout
+func (fm *FeatureMap) ToLayout(layout Layout) *FeatureMap {
+    if fm.Layout == layout {
+        return fm.Clone()
+    }
+
+    out := NewFeatureMapWithLayout(fm.Height, fm.Width, fm.Channels, layout)
+    CopyFeatureMapLayout(out, fm)
+    return out
+}
+
+// CopyFeatureMapLayout copies src into dst, converting between their layouts
+// Both must have the same shape
+func CopyFeatureMapLayout(dst, src *FeatureMap) {
+    if dst.Height != src.Height || dst.Width != src.Width || dst.Channels != src.Channels {
+        panic(fmt.Sprintf("shape mismatch: (%d,%d,%d) vs (%d,%d,%d)",
+            dst.Height, dst.Width, dst.Channels, src.Height, src.Width, src.Channels))
+    }
+
+    if dst.Layout == src.Layout {
+        copy(dst.Data, src.Data)
+        return
+    }
+
+    for c := 0; c < src.Channels; c++ {
+        for h := 0; h < src.Height; h++ {
+            for w := 0; w < src.Width; w++ {
+                dst.SetUnsafe(c, h, w, src.GetUnsafe(c, h, w))
+            }
+        }
+    }
+}
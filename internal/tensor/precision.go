@@ -0,0 +1,235 @@
+package tensor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+/**
+* Reduced-precision storage
+
+IEEE 754 half precision (float16) keeps 1 sign bit, 5 exponent bits and 10
+mantissa bits, i.e. about 3 significant decimal digits over ±65504. That is
+enough for trained CNN weights and post-ReLU activations. Weights are stored
+as Float16 (HalfKernel), which halves their memory; activations stay in
+float32 slices and are only rounded to half precision with Round, so they
+see float16 accuracy without any memory saving.
+
+Weights are widened back to float32 before any arithmetic, so every dot
+product still accumulates in float32. Conversion
+rounds to nearest, ties to even; values beyond the range become ±Inf and
+tiny values flush through the subnormals to zero.
+
+Float64 goes the other way: weights stay stored in float32, but inference
+widens them and runs every layer in double precision (see FeatureMapOf), so
+a numerical-debugging run can be compared against the float32 one to tell
+accumulation error from everything else.
+*/
+
+// Precision selects how weights and activations are stored between operations
+type Precision int
+
+const (
+    PrecisionFloat32 Precision = iota // Full precision (default)
+    PrecisionFloat16                  // Half precision weights, activations rounded to it, float32 accumulation
+    PrecisionFloat64                  // float32 storage, double precision arithmetic (for debugging)
+)
+
+// String returns the config name of the precision
+func (p Precision) String() string {
+    switch p {
+    case PrecisionFloat32:
+        return "float32"
+    case PrecisionFloat16:
+        return "float16"
+    case PrecisionFloat64:
+        return "float64"
+    default:
+        return fmt.Sprintf("Precision(%d)", int(p))
+    }
+}
+
+// BytesPerValue returns the size of one value at the precision
+func (p Precision) BytesPerValue() int {
+    switch p {
+    case PrecisionFloat16:
+        return 2
+    case PrecisionFloat64:
+        return 8
+    }
+    return 4
+}
+
+// ParsePrecision converts a config name such as "float16" to a Precision
+// An empty name selects float32
+func ParsePrecision(name string) (Precision, error) {
+    switch strings.ToLower(strings.TrimSpace(name)) {
+    case "", "float32", "fp32":
+        return PrecisionFloat32, nil
+    case "float16", "fp16", "half":
+        return PrecisionFloat16, nil
+    case "float64", "fp64", "double":
+        return PrecisionFloat64, nil
+    }
+    return PrecisionFloat32, fmt.Errorf("unknown precision %q (use float32, float16 or float64)", name)
+}
+
+// Round rounds data in place to the values representable at precision p
+// float32 data is already representable in float64, so only float16 changes it
+func (p Precision) Round(data []float32) {
+    if p == PrecisionFloat16 {
+        for i, v := range data {
+            data[i] = Float16FromFloat32(v).Float32()
+        }
+    }
+}
+
+// Float16 is an IEEE 754 half precision value stored as its bit pattern
+type Float16 uint16
+
+// Float16FromFloat32 converts f to half precision, rounding to nearest even
+func Float16FromFloat32(f float32) Float16 {
+    bits := math.Float32bits(f)
+    sign := uint16(bits>>16) & 0x8000
+    exp := int((bits >> 23) & 0xff)
+    mant := bits & 0x7fffff
+
+    // Inf and NaN (keeping NaN quiet)
+    if exp == 0xff {
+        if mant != 0 {
+            return Float16(sign | 0x7e00)
+        }
+        return Float16(sign | 0x7c00)
+    }
+
+    e := exp - 127 + 15
+    if e >= 0x1f {
+        return Float16(sign | 0x7c00) // Overflow to infinity
+    }
+
+    if e <= 0 {
+        // Subnormal half, or zero if below half the smallest subnormal
+        if e < -10 {
+            return Float16(sign)
+        }
+        mant |= 0x800000
+        shift := uint(14 - e)
+        half := mant >> shift
+        rem := mant & (1<<shift - 1)
+        halfway := uint32(1) << (shift - 1)
+        if rem > halfway || (rem == halfway && half&1 == 1) {
+            half++
+        }
+        return Float16(sign | uint16(half))
+    }
+
+    // A carry out of the mantissa correctly bumps the exponent (up to infinity)
+    half := uint32(e)<<10 | mant>>13
+    rem := mant & 0x1fff
+    if rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+        half++
+    }
+    return Float16(sign | uint16(half))
+}
+
+// Float32 widens h to float32 exactly
+func (h Float16) Float32() float32 {
+    sign := uint32(h&0x8000) << 16
+    exp := uint32(h>>10) & 0x1f
+    mant := uint32(h) & 0x3ff
+
+    switch {
+    case exp == 0x1f:
+        return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+    case exp == 0:
+        if mant == 0 {
+            return math.Float32frombits(sign)
+        }
+        // Normalise the subnormal
+        e := uint32(127 - 15 + 1)
+        for mant&0x400 == 0 {
+            mant <<= 1
+            e--
+        }
+        mant &= 0x3ff
+        return math.Float32frombits(sign | e<<23 | mant<<13)
+    }
+    return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+// EncodeFloat16 converts src to half precision into dst (len(dst) >= len(src))
+func EncodeFloat16(dst []Float16, src []float32) {
+    for i, v := range src {
+        dst[i] = Float16FromFloat32(v)
+    }
+}
+
+// DecodeFloat16 widens src into dst (len(dst) >= len(src))
+func DecodeFloat16(dst []float32, src []Float16) {
+    for i, h := range src {
+        dst[i] = h.Float32()
+    }
+}
+
+// HalfKernel is a Kernel whose weights are stored at half precision
+// Weights keep the [filter][channel][height][width] order of Kernel
+type HalfKernel struct {
+    Size     int
+    Channels int
+    Filters  int
+    Weights  []Float16
+}
+
+// NewHalfKernel converts a kernel to half precision storage
+func NewHalfKernel(kernel *Kernel) *HalfKernel {
+    hk := &HalfKernel{
+        Size:     kernel.Size,
+        Channels: kernel.Channels,
+        Filters:  kernel.Filters,
+        Weights:  make([]Float16, len(kernel.Weights)),
+    }
+    EncodeFloat16(hk.Weights, kernel.Weights)
+    return hk
+}
+
+// TotalWeights returns the number of weights in the kernel
+func (hk *HalfKernel) TotalWeights() int {
+    return len(hk.Weights)
+}
+
+// ExpandInto widens the weights into dst, which must hold TotalWeights values,
+// and returns a Kernel that uses dst as its weight storage
+func (hk *HalfKernel) ExpandInto(dst []float32) *Kernel {
+    DecodeFloat16(dst, hk.Weights)
+    return &Kernel{
+        Size:     hk.Size,
+        Channels: hk.Channels,
+        Filters:  hk.Filters,
+        Weights:  dst[:len(hk.Weights)],
+    }
+}
+
+// ToKernel returns a float32 copy of the kernel
+func (hk *HalfKernel) ToKernel() *Kernel {
+    return hk.ExpandInto(make([]float32, len(hk.Weights)))
+}
+
+// BFloat16 is a brain floating point value (the top 16 bits of a float32) stored as its bit pattern
+// It keeps float32's 8-bit exponent and range but only 7 mantissa bits
+type BFloat16 uint16
+
+// BFloat16FromFloat32 converts f to bfloat16, rounding to nearest even
+func BFloat16FromFloat32(f float32) BFloat16 {
+    bits := math.Float32bits(f)
+    if bits&0x7fffffff > 0x7f800000 {
+        return BFloat16(bits>>16 | 0x40) // Keep NaN quiet after truncation
+    }
+    rounding := uint32(0x7fff) + (bits>>16)&1
+    return BFloat16((bits + rounding) >> 16)
+}
+
+// Float32 widens b to float32 exactly
+func (b BFloat16) Float32() float32 {
+    return math.Float32frombits(uint32(b) << 16)
+}
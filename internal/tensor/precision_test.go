@@ -0,0 +1,130 @@
+package tensor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFloat16Conversion(t *testing.T) {
+    cases := []struct {
+        in   float32
+        bits Float16
+    }{
+        {0, 0x0000},
+        {1, 0x3c00},
+        {-2, 0xc000},
+        {0.5, 0x3800},
+        {65504, 0x7bff},                            // Largest finite half
+        {65520, 0x7c00},                            // Rounds up to infinity
+        {float32(math.Inf(-1)), 0xfc00},
+        {float32(math.Ldexp(1, -24)), 0x0001},      // Smallest subnormal
+        {float32(math.Ldexp(1, -26)), 0x0000},      // Below half the smallest subnormal
+        {1 + float32(math.Ldexp(1, -11)), 0x3c00},  // Tie rounds to even (down)
+        {1 + 3*float32(math.Ldexp(1, -11)), 0x3c02}, // Tie rounds to even (up)
+    }
+    
+    for _, c := range cases {
+        if got := Float16FromFloat32(c.in); got != c.bits {
+            t.Errorf("Float16FromFloat32(%g) = %#04x, expected %#04x", c.in, uint16(got), uint16(c.bits))
+        }
+    }
+    
+    if !math.IsNaN(float64(Float16FromFloat32(float32(math.NaN())).Float32())) {
+        t.Error("NaN should survive conversion")
+    }
+}
+
+func TestFloat16RoundTrip(t *testing.T) {
+    // Every finite half value widens exactly and converts back to itself
+    for bits := 0; bits < 1<<16; bits++ {
+        h := Float16(bits)
+        if h&0x7c00 == 0x7c00 && h&0x3ff != 0 {
+            continue // NaN payloads are not preserved
+        }
+        if back := Float16FromFloat32(h.Float32()); back != h {
+            t.Fatalf("Round trip of %#04x gave %#04x (%g)", bits, uint16(back), h.Float32())
+        }
+    }
+}
+
+func TestHalfKernel(t *testing.T) {
+    kernel := NewKernel(3, 2, 4)
+    kernel.RandomFill()
+    
+    half := NewHalfKernel(kernel)
+    if half.TotalWeights() != kernel.TotalWeights() {
+        t.Fatalf("Expected %d weights, got %d", kernel.TotalWeights(), half.TotalWeights())
+    }
+    
+    widened := half.ToKernel()
+    for i, w := range kernel.Weights {
+        // 10 mantissa bits: relative error at most 2^-11
+        if diff := math.Abs(float64(w - widened.Weights[i])); diff > math.Abs(float64(w))/2048+1e-7 {
+            t.Fatalf("Weight %d: %g widened to %g", i, w, widened.Weights[i])
+        }
+    }
+}
+
+func TestParsePrecision(t *testing.T) {
+    for name, expected := range map[string]Precision{"": PrecisionFloat32, "fp32": PrecisionFloat32, "Float16": PrecisionFloat16,
+        "double": PrecisionFloat64} {
+        if p, err := ParsePrecision(name); err != nil || p != expected {
+            t.Errorf("ParsePrecision(%q) = %v, %v", name, p, err)
+        }
+    }
+    if _, err := ParsePrecision("int4"); err == nil {
+        t.Error("Expected error for unknown precision")
+    }
+}
+
+func TestBFloat16Conversion(t *testing.T) {
+    cases := []struct {
+        in   float32
+        bits BFloat16
+    }{
+        {0, 0x0000},
+        {1, 0x3f80},
+        {-2, 0xc000},
+        {float32(math.Inf(1)), 0x7f80},
+        {1 + float32(math.Ldexp(1, -8)), 0x3f80},   // Tie rounds to even (down)
+        {1 + 3*float32(math.Ldexp(1, -8)), 0x3f82}, // Tie rounds to even (up)
+        {3.0e38, 0x7f62},
+    }
+    
+    for _, c := range cases {
+        if got := BFloat16FromFloat32(c.in); got != c.bits {
+            t.Errorf("BFloat16FromFloat32(%g) = %#04x, expected %#04x", c.in, uint16(got), uint16(c.bits))
+        }
+        if c.in == c.bits.Float32() {
+            continue
+        }
+        if back := c.bits.Float32(); math.Abs(float64(back-c.in)) > math.Abs(float64(c.in))/128 {
+            t.Errorf("%#04x widened to %g, too far from %g", uint16(c.bits), back, c.in)
+        }
+    }
+    
+    if !math.IsNaN(float64(BFloat16FromFloat32(float32(math.NaN())).Float32())) {
+        t.Error("NaN should survive conversion")
+    }
+}
+
+func TestFeatureMapOf(t *testing.T) {
+    fm := NewFeatureMapWithLayout(2, 3, 4, LayoutHWC)
+    fm.RandomFill()
+    wide := FeatureMapAs[float64](fm)
+    if wide.Layout != LayoutHWC || wide.Get(3, 1, 2) != float64(fm.Get(3, 1, 2)) {
+        t.Errorf("Expected an exact float64 copy in HWC, got %s", wide)
+    }
+
+    // Rounding back gives the nearest float32
+    wide.Set(1, 0, 1, 1.0/3)
+    if narrow := wide.FeatureMap(); narrow.Get(1, 0, 1) != float32(1.0/3) || narrow.Get(0, 1, 1) != fm.Get(0, 1, 1) {
+        t.Errorf("Unexpected float32 values %f, %f", narrow.Get(1, 0, 1), narrow.Get(0, 1, 1))
+    }
+
+    kernel := NewKernel(3, 2, 4)
+    kernel.RandomFill()
+    if wideKernel := KernelAs[float64](kernel); wideKernel.GetWeight(3, 1, 2, 0) != float64(kernel.GetWeight(3, 1, 2, 0)) {
+        t.Error("Expected the float64 kernel to hold the same weights")
+    }
+}
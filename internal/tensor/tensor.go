@@ -0,0 +1,438 @@
+package tensor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+/**
+* N-dimensional tensors
+
+A Tensor is a flat float32 slice read through a shape and strides: the
+element at index (i0, i1, ..., in) sits at
+```
+offset + i0*strides[0] + i1*strides[1] + ... + in*strides[n]
+```
+A freshly allocated tensor is row-major (the last axis is contiguous), but
+Transpose only permutes the strides, Squeeze only drops axes and Slice and
+Select only move the offset, so all of them return views of the same data
+without copying. Reshape needs the elements in row-major order and copies
+only when the tensor is not.
+
+FeatureMap.ChannelView and FeatureMap.Region use them to hand out one
+channel or a window of every channel without copying, e.g. a group of a
+grouped convolution or a tile of sliding-window inference:
+```
+Region(1, 1, 3, 4) of a CHW map with W = 6:   offset 1*6 + 1 = 7
+    strides [H*W, W, 1], shape [C, 2, 3]      writes through the view
+                                              change the map
+```
+
+FeatureMap, Kernel and their 1D/3D variants are not built on Tensor: they
+keep their own named fields, which the ops index directly in their inner
+loops, and migrating them to thin wrappers over Tensor has not been done.
+What they have instead is Tensor(), an N-D view of the same data, and
+Tensor.FeatureMap / Tensor.Kernel turn a tensor of the right rank back into
+one, so a new layer type can work on tensors of any rank and convert at its
+edges instead of needing a container of its own:
+```
+FeatureMap     [channels][height][width]           (an HWC map only changes strides)
+Kernel         [filters][channels][size][size]
+FeatureMap1D   [channels][length]
+FeatureMap3D   [channels][depth][height][width]
+Kernel1D       [filters][channels][size]
+Kernel3D       [filters][channels][size][size][size]
+```
+*/
+
+// Tensor is an N-dimensional view of float32 data
+type Tensor struct {
+    data    []float32
+    shape   []int
+    strides []int
+    offset  int
+}
+
+// NewTensor creates a zeroed row-major tensor with the given shape
+func NewTensor(shape ...int) *Tensor {
+    t, err := WrapTensor(make([]float32, shapeSize(shape)), shape...)
+    if err != nil {
+        panic(err)
+    }
+    return t
+}
+
+// NewTensorFromData creates a tensor of the given shape holding a copy of data
+func NewTensorFromData(data []float32, shape ...int) (*Tensor, error) {
+    return WrapTensor(slices.Clone(data), shape...)
+}
+
+// WrapTensor views data as a row-major tensor of the given shape, without copying
+func WrapTensor(data []float32, shape ...int) (*Tensor, error) {
+    for _, dim := range shape {
+        if dim < 0 {
+            return nil, fmt.Errorf("invalid tensor shape %v: negative dimension", shape)
+        }
+    }
+    if size := shapeSize(shape); len(data) != size {
+        return nil, fmt.Errorf("data size mismatch: shape %v holds %d values, got %d", shape, size, len(data))
+    }
+    return &Tensor{data: data, shape: slices.Clone(shape), strides: rowMajorStrides(shape)}, nil
+}
+
+// shapeSize returns the number of elements of shape
+func shapeSize(shape []int) int {
+    size := 1
+    for _, dim := range shape {
+        size *= dim
+    }
+    return size
+}
+
+// rowMajorStrides returns the strides of a contiguous row-major tensor of shape
+func rowMajorStrides(shape []int) []int {
+    strides := make([]int, len(shape))
+    stride := 1
+    for i := len(shape) - 1; i >= 0; i-- {
+        strides[i] = stride
+        stride *= shape[i]
+    }
+    return strides
+}
+
+// Rank returns the number of axes
+func (t *Tensor) Rank() int {
+    return len(t.shape)
+}
+
+// Shape returns a copy of the size of every axis
+func (t *Tensor) Shape() []int {
+    return slices.Clone(t.shape)
+}
+
+// Strides returns a copy of the step in the data along every axis
+func (t *Tensor) Strides() []int {
+    return slices.Clone(t.strides)
+}
+
+// Size returns the number of elements
+func (t *Tensor) Size() int {
+    return shapeSize(t.shape)
+}
+
+// index returns the position of an element in the data, panicking when it is out of bounds
+func (t *Tensor) index(index []int) int {
+    if len(index) != len(t.shape) {
+        panic(fmt.Sprintf("index %v has %d axes, tensor of shape %v has %d", index, len(index), t.shape, len(t.shape)))
+    }
+    pos := t.offset
+    for axis, i := range index {
+        if i < 0 || i >= t.shape[axis] {
+            panic(fmt.Sprintf("index out of bounds: %v for shape %v", index, t.shape))
+        }
+        pos += i * t.strides[axis]
+    }
+    return pos
+}
+
+// At returns the element at index
+func (t *Tensor) At(index ...int) float32 {
+    return t.data[t.index(index)]
+}
+
+// Set sets the element at index
+func (t *Tensor) Set(value float32, index ...int) {
+    t.data[t.index(index)] = value
+}
+
+// IsContiguous reports whether the elements are stored in row-major order without gaps
+func (t *Tensor) IsContiguous() bool {
+    stride := 1
+    for i := len(t.shape) - 1; i >= 0; i-- {
+        if t.shape[i] != 1 && t.strides[i] != stride {
+            return false
+        }
+        stride *= t.shape[i]
+    }
+    return true
+}
+
+// forEach calls fn with the data position of every element, in row-major order
+func (t *Tensor) forEach(fn func(pos int)) {
+    if t.Size() == 0 {
+        return
+    }
+    index := make([]int, len(t.shape))
+    pos := t.offset
+    for {
+        fn(pos)
+        axis := len(index) - 1
+        for ; axis >= 0; axis-- {
+            index[axis]++
+            pos += t.strides[axis]
+            if index[axis] < t.shape[axis] {
+                break
+            }
+            pos -= index[axis] * t.strides[axis]
+            index[axis] = 0
+        }
+        if axis < 0 {
+            return
+        }
+    }
+}
+
+// Values returns the elements in row-major order: the tensor's own data when
+// it is contiguous, a copy otherwise
+func (t *Tensor) Values() []float32 {
+    if t.IsContiguous() {
+        return t.data[t.offset : t.offset+t.Size()]
+    }
+    values := make([]float32, 0, t.Size())
+    t.forEach(func(pos int) { values = append(values, t.data[pos]) })
+    return values
+}
+
+// Contiguous returns t when it is contiguous, and a row-major copy otherwise
+func (t *Tensor) Contiguous() *Tensor {
+    if t.IsContiguous() {
+        return t
+    }
+    return t.Clone()
+}
+
+// Clone returns a row-major deep copy
+func (t *Tensor) Clone() *Tensor {
+    values := t.Values()
+    if t.IsContiguous() {
+        values = slices.Clone(values)
+    }
+    return &Tensor{data: values, shape: slices.Clone(t.shape), strides: rowMajorStrides(t.shape)}
+}
+
+// Reshape returns the elements with a new shape of the same size; one dimension
+// may be -1 to take whatever size is left. The result shares the data of a
+// contiguous tensor and copies that of any other.
+func (t *Tensor) Reshape(shape ...int) (*Tensor, error) {
+    shape = slices.Clone(shape)
+    inferred, known := -1, 1
+    for i, dim := range shape {
+        switch {
+        case dim == -1 && inferred < 0:
+            inferred = i
+        case dim < 0:
+            return nil, fmt.Errorf("cannot reshape %v to %v: invalid dimension %d", t.shape, shape, dim)
+        default:
+            known *= dim
+        }
+    }
+    if inferred >= 0 {
+        if known == 0 || t.Size()%known != 0 {
+            return nil, fmt.Errorf("cannot reshape %v to %v: %d elements do not divide evenly", t.shape, shape, t.Size())
+        }
+        shape[inferred] = t.Size() / known
+    }
+    if shapeSize(shape) != t.Size() {
+        return nil, fmt.Errorf("cannot reshape %v (%d elements) to %v (%d elements)", t.shape, t.Size(),
+            shape, shapeSize(shape))
+    }
+
+    contiguous := t.Contiguous()
+    return &Tensor{data: contiguous.data, shape: shape, strides: rowMajorStrides(shape), offset: contiguous.offset}, nil
+}
+
+// Transpose returns a view with the axes in the order axes gives, e.g. (2, 0, 1)
+// turns [C][H][W] into [W][C][H]; without axes it reverses them
+func (t *Tensor) Transpose(axes ...int) (*Tensor, error) {
+    if len(axes) == 0 {
+        for axis := len(t.shape) - 1; axis >= 0; axis-- {
+            axes = append(axes, axis)
+        }
+    }
+    if len(axes) != len(t.shape) {
+        return nil, fmt.Errorf("cannot transpose %v by %v: need a permutation of %d axes", t.shape, axes, len(t.shape))
+    }
+
+    view := &Tensor{data: t.data, shape: make([]int, len(axes)), strides: make([]int, len(axes)), offset: t.offset}
+    seen := make([]bool, len(axes))
+    for i, axis := range axes {
+        if axis < 0 || axis >= len(t.shape) || seen[axis] {
+            return nil, fmt.Errorf("cannot transpose %v by %v: need a permutation of %d axes", t.shape, axes, len(t.shape))
+        }
+        seen[axis] = true
+        view.shape[i], view.strides[i] = t.shape[axis], t.strides[axis]
+    }
+    return view, nil
+}
+
+// Squeeze returns a view without the given axes of size 1, or without every
+// axis of size 1 when none are given
+func (t *Tensor) Squeeze(axes ...int) (*Tensor, error) {
+    drop := make([]bool, len(t.shape))
+    if len(axes) == 0 {
+        for axis, dim := range t.shape {
+            drop[axis] = dim == 1
+        }
+    }
+    for _, axis := range axes {
+        if axis < 0 || axis >= len(t.shape) || t.shape[axis] != 1 {
+            return nil, fmt.Errorf("cannot squeeze axis %d of shape %v: only axes of size 1 can be", axis, t.shape)
+        }
+        drop[axis] = true
+    }
+
+    view := &Tensor{data: t.data, offset: t.offset}
+    for axis := range t.shape {
+        if !drop[axis] {
+            view.shape = append(view.shape, t.shape[axis])
+            view.strides = append(view.strides, t.strides[axis])
+        }
+    }
+    return view, nil
+}
+
+// Slice returns a view of the indices start to end (exclusive) along axis
+func (t *Tensor) Slice(axis, start, end int) (*Tensor, error) {
+    if axis < 0 || axis >= len(t.shape) {
+        return nil, fmt.Errorf("cannot slice axis %d of shape %v", axis, t.shape)
+    }
+    if start < 0 || start > end || end > t.shape[axis] {
+        return nil, fmt.Errorf("cannot slice [%d, %d) of axis %d, of size %d", start, end, axis, t.shape[axis])
+    }
+    view := &Tensor{data: t.data, shape: slices.Clone(t.shape), strides: slices.Clone(t.strides), offset: t.offset}
+    view.shape[axis] = end - start
+    if end > start {
+        view.offset += start * t.strides[axis]
+    }
+    return view, nil
+}
+
+// Select returns a view of index i along axis, with that axis removed
+func (t *Tensor) Select(axis, i int) (*Tensor, error) {
+    view, err := t.Slice(axis, i, i+1)
+    if err != nil {
+        return nil, err
+    }
+    return view.Squeeze(axis)
+}
+
+// FeatureMap returns a rank-3 tensor of shape [channels][height][width] as a
+// feature map, sharing its data when it is stored in CHW or HWC order
+func (t *Tensor) FeatureMap() (*FeatureMap, error) {
+    if len(t.shape) != 3 {
+        return nil, fmt.Errorf("a feature map needs a [channels][height][width] tensor, got shape %v", t.shape)
+    }
+    channels, height, width := t.shape[0], t.shape[1], t.shape[2]
+    fm := &FeatureMap{Height: height, Width: width, Channels: channels}
+
+    hwc := &Tensor{shape: t.shape, strides: []int{1, width * channels, channels}}
+    size := t.Size()
+    switch {
+    case t.IsContiguous():
+        fm.Data = t.data[t.offset : t.offset+size]
+    case sameSteps(t, hwc):
+        fm.Data, fm.Layout = t.data[t.offset:t.offset+size], LayoutHWC
+    default:
+        fm.Data = t.Values()
+    }
+    return fm, nil
+}
+
+// Kernel returns a rank-4 tensor of shape [filters][channels][size][size] as a
+// kernel, sharing its data when it is contiguous
+func (t *Tensor) Kernel() (*Kernel, error) {
+    if len(t.shape) != 4 || t.shape[2] != t.shape[3] {
+        return nil, fmt.Errorf("a kernel needs a [filters][channels][size][size] tensor, got shape %v", t.shape)
+    }
+    return &Kernel{Size: t.shape[2], Channels: t.shape[1], Filters: t.shape[0], Weights: t.Values()}, nil
+}
+
+// sameSteps reports whether a and b step through their data alike along every
+// axis longer than 1
+func sameSteps(a, b *Tensor) bool {
+    if !slices.Equal(a.shape, b.shape) {
+        return false
+    }
+    for axis, dim := range a.shape {
+        if dim > 1 && a.strides[axis] != b.strides[axis] {
+            return false
+        }
+    }
+    return true
+}
+
+// String provides a string representation (for debugging)
+func (t *Tensor) String() string {
+    dims := make([]string, len(t.shape))
+    for i, dim := range t.shape {
+        dims[i] = fmt.Sprint(dim)
+    }
+    return fmt.Sprintf("Tensor{Shape: [%s], Strides: %v, Contiguous: %t}", strings.Join(dims, "x"), t.strides,
+        t.IsContiguous())
+}
+
+// Tensor returns a [channels][height][width] view of the feature map's data,
+// whatever its layout
+func (fm *FeatureMap) Tensor() *Tensor {
+    shape := []int{fm.Channels, fm.Height, fm.Width}
+    if fm.Layout == LayoutHWC {
+        return &Tensor{data: fm.Data, shape: shape, strides: []int{1, fm.Width * fm.Channels, fm.Channels}}
+    }
+    return &Tensor{data: fm.Data, shape: shape, strides: rowMajorStrides(shape)}
+}
+
+// ChannelView returns a [height][width] view of channel c, sharing the feature
+// map's data in either layout
+func (fm *FeatureMap) ChannelView(c int) *Tensor {
+    view, err := fm.Tensor().Select(0, c)
+    if err != nil {
+        panic(fmt.Sprintf("channel %d out of bounds for %d channels", c, fm.Channels))
+    }
+    return view
+}
+
+// Region returns a [channels][h1-h0][w1-w0] view of rows h0 to h1 and columns
+// w0 to w1 (both exclusive), sharing the feature map's data in either layout
+func (fm *FeatureMap) Region(h0, w0, h1, w1 int) *Tensor {
+    view, err := fm.Tensor().Slice(1, h0, h1)
+    if err == nil {
+        view, err = view.Slice(2, w0, w1)
+    }
+    if err != nil {
+        panic(fmt.Sprintf("region (%d,%d)-(%d,%d) out of bounds for shape (%d,%d)", h0, w0, h1, w1,
+            fm.Height, fm.Width))
+    }
+    return view
+}
+
+// Tensor returns a [filters][channels][size][size] view of the kernel's weights
+func (k *Kernel) Tensor() *Tensor {
+    return wrapShape(k.Weights, k.Filters, k.Channels, k.Size, k.Size)
+}
+
+// Tensor returns a [channels][length] view of the feature map's data
+func (fm *FeatureMap1D) Tensor() *Tensor {
+    return wrapShape(fm.Data, fm.Channels, fm.Length)
+}
+
+// Tensor returns a [channels][depth][height][width] view of the feature map's data
+func (fm *FeatureMap3D) Tensor() *Tensor {
+    return wrapShape(fm.Data, fm.Channels, fm.Depth, fm.Height, fm.Width)
+}
+
+// Tensor returns a [filters][channels][size] view of the kernel's weights
+func (k *Kernel1D) Tensor() *Tensor {
+    return wrapShape(k.Weights, k.Filters, k.Channels, k.Size)
+}
+
+// Tensor returns a [filters][channels][size][size][size] view of the kernel's weights
+func (k *Kernel3D) Tensor() *Tensor {
+    return wrapShape(k.Weights, k.Filters, k.Channels, k.Size, k.Size, k.Size)
+}
+
+// wrapShape views the data of a container as a row-major tensor; the
+// container's fields guarantee the sizes match
+func wrapShape(data []float32, shape ...int) *Tensor {
+    return &Tensor{data: data, shape: shape, strides: rowMajorStrides(shape)}
+}
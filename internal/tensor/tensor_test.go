@@ -0,0 +1,223 @@
+package tensor
+
+import (
+	"slices"
+	"testing"
+)
+
+// rangeTensor returns a row-major tensor of shape holding 0, 1, 2, ...
+func rangeTensor(shape ...int) *Tensor {
+    t := NewTensor(shape...)
+    for i := range t.data {
+        t.data[i] = float32(i)
+    }
+    return t
+}
+
+func TestTensorIndexing(t *testing.T) {
+    tensor := rangeTensor(2, 3, 4)
+    if tensor.Rank() != 3 || tensor.Size() != 24 || !slices.Equal(tensor.Strides(), []int{12, 4, 1}) {
+        t.Fatalf("Unexpected tensor %s", tensor)
+    }
+    if got := tensor.At(1, 2, 3); got != 23 {
+        t.Errorf("Expected 23 at (1,2,3), got %f", got)
+    }
+    tensor.Set(-1, 0, 1, 2)
+    if tensor.data[6] != -1 {
+        t.Errorf("Expected Set to write element 6, got %v", tensor.data[:8])
+    }
+
+    if _, err := NewTensorFromData(make([]float32, 5), 2, 3); err == nil {
+        t.Error("Expected an error for 5 values in a 2x3 tensor")
+    }
+    defer func() {
+        if recover() == nil {
+            t.Error("Expected a panic for an index out of bounds")
+        }
+    }()
+    tensor.At(2, 0, 0)
+}
+
+func TestTensorTranspose(t *testing.T) {
+    tensor := rangeTensor(2, 3, 4)
+    view, err := tensor.Transpose(2, 0, 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !slices.Equal(view.Shape(), []int{4, 2, 3}) || view.IsContiguous() {
+        t.Fatalf("Expected a non-contiguous 4x2x3 view, got %s", view)
+    }
+    if view.At(3, 1, 2) != tensor.At(1, 2, 3) {
+        t.Errorf("Expected the view to read the same element, got %f and %f", view.At(3, 1, 2), tensor.At(1, 2, 3))
+    }
+
+    // The view shares the data
+    view.Set(100, 0, 0, 1)
+    if tensor.At(0, 1, 0) != 100 {
+        t.Error("Expected a write through the view to reach the tensor")
+    }
+
+    // Reversing the axes of a matrix twice gives it back
+    matrix := rangeTensor(2, 3)
+    transposed, _ := matrix.Transpose()
+    if got := transposed.Values(); !slices.Equal(got, []float32{0, 3, 1, 4, 2, 5}) {
+        t.Errorf("Expected the transposed values in row-major order, got %v", got)
+    }
+    back, _ := transposed.Transpose()
+    if !back.IsContiguous() || !slices.Equal(back.Values(), matrix.Values()) {
+        t.Error("Expected transposing twice to restore the matrix")
+    }
+
+    for _, axes := range [][]int{{0, 1}, {0, 0, 1}, {0, 1, 3}} {
+        if _, err := tensor.Transpose(axes...); err == nil {
+            t.Errorf("Expected an error for axes %v", axes)
+        }
+    }
+}
+
+func TestTensorReshape(t *testing.T) {
+    tensor := rangeTensor(2, 3, 4)
+    flat, err := tensor.Reshape(6, -1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !slices.Equal(flat.Shape(), []int{6, 4}) || flat.At(5, 3) != 23 {
+        t.Errorf("Expected a 6x4 tensor ending in 23, got %s", flat)
+    }
+    flat.Set(-5, 0, 0)
+    if tensor.At(0, 0, 0) != -5 {
+        t.Error("Expected reshaping a contiguous tensor to share its data")
+    }
+
+    // A transposed view is copied into row-major order first
+    view, _ := rangeTensor(2, 3).Transpose()
+    reshaped, err := view.Reshape(6)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !slices.Equal(reshaped.Values(), []float32{0, 3, 1, 4, 2, 5}) {
+        t.Errorf("Unexpected values %v", reshaped.Values())
+    }
+
+    for _, shape := range [][]int{{5, 5}, {-1, -1}, {7, -1}, {-2, 12}} {
+        if _, err := tensor.Reshape(shape...); err == nil {
+            t.Errorf("Expected an error reshaping %v to %v", tensor.Shape(), shape)
+        }
+    }
+}
+
+func TestTensorSqueeze(t *testing.T) {
+    tensor := rangeTensor(1, 3, 1, 2)
+    all, err := tensor.Squeeze()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !slices.Equal(all.Shape(), []int{3, 2}) || all.At(2, 1) != 5 {
+        t.Errorf("Expected a 3x2 view, got %s", all)
+    }
+    one, err := tensor.Squeeze(2)
+    if err != nil || !slices.Equal(one.Shape(), []int{1, 3, 2}) {
+        t.Errorf("Expected a 1x3x2 view, got %v (%v)", one, err)
+    }
+    if _, err := tensor.Squeeze(1); err == nil {
+        t.Error("Expected an error squeezing an axis of size 3")
+    }
+}
+
+func TestTensorWrappers(t *testing.T) {
+    // A feature map reads the same through its tensor view in either layout
+    fm := NewFeatureMap(3, 4, 2)
+    fm.RandomFill()
+    for _, layout := range []Layout{LayoutCHW, LayoutHWC} {
+        converted := fm.ToLayout(layout)
+        view := converted.Tensor()
+        if !slices.Equal(view.Shape(), []int{2, 3, 4}) || view.At(1, 2, 3) != fm.Get(1, 2, 3) {
+            t.Errorf("%s: unexpected view %s", layout, view)
+        }
+
+        // And back, sharing the data
+        back, err := view.FeatureMap()
+        if err != nil {
+            t.Fatal(err)
+        }
+        if back.Layout != layout || &back.Data[0] != &converted.Data[0] || back.Get(1, 2, 3) != fm.Get(1, 2, 3) {
+            t.Errorf("%s: expected the feature map back, got %s", layout, back)
+        }
+    }
+
+    // A transposed view of another order is copied
+    view, _ := fm.Tensor().Transpose(0, 2, 1)
+    swapped, err := view.FeatureMap()
+    if err != nil || swapped.Height != 4 || swapped.Width != 3 || swapped.Get(1, 3, 2) != fm.Get(1, 2, 3) {
+        t.Errorf("Expected a 4x3 copy with height and width swapped, got %v (%v)", swapped, err)
+    }
+    if _, err := NewTensor(2, 3).FeatureMap(); err == nil {
+        t.Error("Expected an error for a rank-2 feature map")
+    }
+
+    kernel := NewKernel(3, 2, 4)
+    kernel.RandomFill()
+    back, err := kernel.Tensor().Kernel()
+    if err != nil || back.GetWeight(3, 1, 2, 0) != kernel.GetWeight(3, 1, 2, 0) {
+        t.Errorf("Expected the kernel back, got %v (%v)", back, err)
+    }
+    if _, err := NewTensor(4, 2, 3, 2).Kernel(); err == nil {
+        t.Error("Expected an error for a non-square kernel")
+    }
+
+    volume := NewFeatureMap3D(2, 3, 4, 5)
+    volume.RandomFill()
+    if volume.Tensor().At(4, 1, 2, 3) != volume.Get(4, 1, 2, 3) {
+        t.Error("Expected the 3D feature map's view to read the same element")
+    }
+}
+
+func TestFeatureMapViews(t *testing.T) {
+    fm := NewFeatureMap(4, 6, 3)
+    fm.RandomFill()
+    for _, layout := range []Layout{LayoutCHW, LayoutHWC} {
+        converted := fm.ToLayout(layout)
+
+        channel := converted.ChannelView(2)
+        if !slices.Equal(channel.Shape(), []int{4, 6}) || channel.At(3, 5) != fm.Get(2, 3, 5) {
+            t.Errorf("%s: unexpected channel view %s", layout, channel)
+        }
+        region := converted.Region(1, 1, 3, 4)
+        if !slices.Equal(region.Shape(), []int{3, 2, 3}) || region.At(2, 1, 2) != fm.Get(2, 2, 3) {
+            t.Errorf("%s: unexpected region %s", layout, region)
+        }
+
+        // Writes through either view reach the feature map
+        channel.Set(-1, 0, 0)
+        region.Set(-2, 0, 0, 0)
+        if converted.Get(2, 0, 0) != -1 || converted.Get(0, 1, 1) != -2 {
+            t.Errorf("%s: expected the views to share the feature map's data", layout)
+        }
+    }
+
+    // Whole rows of several channels are not contiguous, but one channel of a
+    // CHW map converts back to a feature map without a copy
+    if rows := fm.Region(1, 0, 3, 6); rows.IsContiguous() {
+        t.Error("Expected rows of several channels not to be contiguous")
+    }
+    plane, _ := fm.ChannelView(1).Reshape(1, 4, 6)
+    single, err := plane.FeatureMap()
+    if err != nil || &single.Data[0] != &fm.Data[24] {
+        t.Errorf("Expected a 1-channel feature map sharing the data, got %v (%v)", single, err)
+    }
+
+    for _, bad := range []func(){
+        func() { fm.ChannelView(3) },
+        func() { fm.Region(0, 0, 5, 6) },
+        func() { fm.Region(2, 0, 1, 6) },
+    } {
+        func() {
+            defer func() {
+                if recover() == nil {
+                    t.Error("Expected a panic for a view out of bounds")
+                }
+            }()
+            bad()
+        }()
+    }
+}
@@ -67,7 +67,7 @@ func PadFeatureMap(input *FeatureMap, padding int) *FeatureMap {
     newHeight := input.Height + 2*padding
     newWidth := input.Width + 2*padding
     
-    padded := NewFeatureMap(newHeight, newWidth, input.Channels)
+    padded := NewFeatureMapWithLayout(newHeight, newWidth, input.Channels, input.Layout)
     
     // Copy original data to center of padded feature map
     for c := 0; c < input.Channels; c++ {
@@ -82,6 +82,75 @@ func PadFeatureMap(input *FeatureMap, padding int) *FeatureMap {
     return padded
 }
 
+// PadFeatureMapInto writes a zero-padded copy of input into dst, which must already
+// have shape (Height+2*padding, Width+2*padding, Channels). Used with pooled buffers
+// whose previous contents are arbitrary.
+func PadFeatureMapInto(dst, input *FeatureMap, padding int) {
+    if dst.Height != input.Height+2*padding || dst.Width != input.Width+2*padding ||
+        dst.Channels != input.Channels {
+        panic(fmt.Sprintf("padded buffer shape (%d,%d,%d) does not match input (%d,%d,%d) with padding %d",
+            dst.Height, dst.Width, dst.Channels, input.Height, input.Width, input.Channels, padding))
+    }
+
+    clear(dst.Data)
+    dst.Layout = input.Layout
+
+    // In HWC a whole input row (all channels) is one contiguous run
+    if input.Layout == LayoutHWC {
+        rowLen := input.Width * input.Channels
+        for h := 0; h < input.Height; h++ {
+            dstStart := ((h+padding)*dst.Width + padding) * dst.Channels
+            copy(dst.Data[dstStart:dstStart+rowLen], input.Data[h*rowLen:(h+1)*rowLen])
+        }
+        return
+    }
+
+    // Copy each input row into the centre of the corresponding padded row
+    for c := 0; c < input.Channels; c++ {
+        for h := 0; h < input.Height; h++ {
+            src := input.Data[(c*input.Height+h)*input.Width:][:input.Width]
+            dstStart := (c*dst.Height+h+padding)*dst.Width + padding
+            copy(dst.Data[dstStart:dstStart+input.Width], src)
+        }
+    }
+}
+
+// PadFeatureMap1D creates a new 1D feature map with zero padding on both ends
+func PadFeatureMap1D(input *FeatureMap1D, padding int) *FeatureMap1D {
+    if padding <= 0 {
+        return input.Clone()
+    }
+    
+    padded := NewFeatureMap1D(input.Length+2*padding, input.Channels)
+    for c := 0; c < input.Channels; c++ {
+        copy(padded.Data[c*padded.Length+padding:], input.Data[c*input.Length:(c+1)*input.Length])
+    }
+    
+    return padded
+}
+
+// PadFeatureMap3D creates a new 3D feature map with zero padding on every spatial axis
+func PadFeatureMap3D(input *FeatureMap3D, padding int) *FeatureMap3D {
+    if padding <= 0 {
+        return input.Clone()
+    }
+    
+    padded := NewFeatureMap3D(input.Depth+2*padding, input.Height+2*padding, 
+        input.Width+2*padding, input.Channels)
+    
+    for c := 0; c < input.Channels; c++ {
+        for d := 0; d < input.Depth; d++ {
+            for h := 0; h < input.Height; h++ {
+                for w := 0; w < input.Width; w++ {
+                    padded.SetUnsafe(c, d+padding, h+padding, w+padding, input.GetUnsafe(c, d, h, w))
+                }
+            }
+        }
+    }
+    
+    return padded
+}
+
 // ValidateFeatureMap checks if a feature map has valid dimensions and data
 func ValidateFeatureMap(fm *FeatureMap) error {
     if fm == nil {
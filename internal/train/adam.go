@@ -0,0 +1,145 @@
+package train
+
+import (
+	"duchm1606/gocnn/internal/data"
+	"fmt"
+	"math"
+)
+
+/**
+* Adam and AdamW
+
+Adam keeps running averages of every value's gradient and squared gradient
+and steps each value by their ratio, so every value gets its own step size.
+For every trainable value w with gradient g, at step t (from 1):
+```
+g ← g + decay·w                 Adam only; kernels only
+m ← β1·m + (1−β1)·g             first moment, starts at 0
+v ← β2·v + (1−β2)·g²            second moment, starts at 0
+m̂ = m/(1−β1^t)   v̂ = v/(1−β2^t) bias correction of the zero start
+w ← w − lr·m̂/(√v̂ + ε)
+```
+Adam's L2 decay is divided by √v̂ like the gradient, so values with large
+gradients are barely decayed. AdamW (Loshchilov & Hutter) decouples it and
+shrinks the kernels directly before the update, w ← w − lr·decay·w, which
+regularizes every value alike.
+*/
+
+// AdamOptions configures an Adam or AdamW optimizer
+type AdamOptions struct {
+    LearningRate float32
+    Beta1        float32 // Decay of the first moment, in [0, 1)
+    Beta2        float32 // Decay of the second moment, in [0, 1)
+    Epsilon      float32 // Added to √v̂ to keep the step finite
+    WeightDecay  float32 // Decay of the conv kernels
+    Decoupled    bool    // AdamW: decay the weights directly, not through the gradient
+}
+
+// DefaultAdamOptions returns the usual Adam hyperparameters with a learning rate of 0.001
+func DefaultAdamOptions() AdamOptions {
+    return AdamOptions{LearningRate: 0.001, Beta1: 0.9, Beta2: 0.999, Epsilon: 1e-8}
+}
+
+// Adam is the Adam optimizer, or AdamW with Decoupled set
+type Adam struct {
+    opts    AdamOptions
+    buffers map[string][]float32 // First and second moments of every trainable array
+    steps   int
+}
+
+// NewAdam creates an Adam optimizer
+func NewAdam(opts AdamOptions) (*Adam, error) {
+    switch {
+    case opts.LearningRate <= 0:
+        return nil, fmt.Errorf("learning rate must be positive, got %g", opts.LearningRate)
+    case opts.Beta1 < 0 || opts.Beta1 >= 1:
+        return nil, fmt.Errorf("beta1 must be in [0, 1), got %g", opts.Beta1)
+    case opts.Beta2 < 0 || opts.Beta2 >= 1:
+        return nil, fmt.Errorf("beta2 must be in [0, 1), got %g", opts.Beta2)
+    case opts.Epsilon <= 0:
+        return nil, fmt.Errorf("epsilon must be positive, got %g", opts.Epsilon)
+    case opts.WeightDecay < 0:
+        return nil, fmt.Errorf("weight decay must not be negative, got %g", opts.WeightDecay)
+    }
+    return &Adam{opts: opts, buffers: make(map[string][]float32)}, nil
+}
+
+// Name returns "adamw" for decoupled weight decay and "adam" otherwise
+func (a *Adam) Name() string {
+    if a.opts.Decoupled {
+        return "adamw"
+    }
+    return "adam"
+}
+
+// LearningRate returns the current learning rate
+func (a *Adam) LearningRate() float32 {
+    return a.opts.LearningRate
+}
+
+// SetLearningRate changes the learning rate of later steps, for schedules
+func (a *Adam) SetLearningRate(lr float32) {
+    a.opts.LearningRate = lr
+}
+
+// Steps returns the number of updates applied
+func (a *Adam) Steps() int {
+    return a.steps
+}
+
+// Step applies one update to weights, in place, from grads
+// grads is left unmodified.
+func (a *Adam) Step(weights *data.ModelWeights, grads *Gradients) error {
+    ps, err := params(weights, grads)
+    if err != nil {
+        return err
+    }
+
+    if err := checkBuffers(a.buffers, ps, "m", "v"); err != nil {
+        return err
+    }
+
+    t := float64(a.steps + 1)
+    beta1, beta2 := a.opts.Beta1, a.opts.Beta2
+    correction1 := float32(1 - math.Pow(float64(beta1), t))
+    correction2 := float32(1 - math.Pow(float64(beta2), t))
+    lr, eps := a.opts.LearningRate, a.opts.Epsilon
+    for _, p := range ps {
+        decay := float32(0)
+        if p.decay {
+            decay = a.opts.WeightDecay
+        }
+        m, v := buffer(a.buffers, "m", p), buffer(a.buffers, "v", p)
+
+        for i, w := range p.values {
+            g := p.grad[i]
+            if a.opts.Decoupled {
+                w -= lr * decay * w
+            } else {
+                g += decay * w
+            }
+            m[i] = beta1*m[i] + (1-beta1)*g
+            v[i] = beta2*v[i] + (1-beta2)*g*g
+            mHat, vHat := m[i]/correction1, v[i]/correction2
+            p.values[i] = w - lr*mHat/(float32(math.Sqrt(float64(vHat)))+eps)
+        }
+    }
+    a.steps++
+    return nil
+}
+
+// State returns a copy of the moments, step count and learning rate
+func (a *Adam) State() *State {
+    return &State{Optimizer: a.Name(), Steps: a.steps, LearningRate: a.opts.LearningRate, Buffers: copyBuffers(a.buffers)}
+}
+
+// Restore resumes from a state saved by an optimizer of the same name,
+// including its learning rate
+// The step count matters: it sets the bias correction of the next step.
+func (a *Adam) Restore(state *State) error {
+    if state.Optimizer != a.Name() {
+        return fmt.Errorf("cannot restore %s from %s optimizer state", a.Name(), state.Optimizer)
+    }
+    a.steps, a.opts.LearningRate, a.buffers = state.Steps, state.LearningRate, copyBuffers(state.Buffers)
+    return nil
+}
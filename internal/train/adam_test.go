@@ -0,0 +1,184 @@
+package train
+
+import (
+	"errors"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestAdamStep(t *testing.T) {
+    weights := testWeights()
+    opts := DefaultAdamOptions()
+    opts.LearningRate = 0.1
+    adam, err := NewAdam(opts)
+    if err != nil {
+        t.Fatalf("NewAdam failed: %v", err)
+    }
+    grads := NewGradients(weights)
+    grads.Kernels[0][0] = 4
+    grads.Biases[0][0] = -0.001
+
+    // With bias correction the first step is lr·sign(g), whatever the gradient's size
+    if err := adam.Step(weights, grads); err != nil {
+        t.Fatalf("Step failed: %v", err)
+    }
+    if got := weights.Kernels[0].Weights[0]; math.Abs(float64(got-0.9)) > 1e-5 {
+        t.Errorf("Kernel: expected 0.9, got %f", got)
+    }
+    if got := weights.Biases[0][0]; math.Abs(float64(got-0.2)) > 1e-4 {
+        t.Errorf("Bias: expected 0.2, got %f", got)
+    }
+    if got := weights.Kernels[1].Weights[1]; got != 3 {
+        t.Errorf("A zero gradient must not move the value, got %f", got)
+    }
+
+    // Second step with the same gradient: m̂ = g and v̂ = g², so again lr·sign(g)
+    if err := adam.Step(weights, grads); err != nil {
+        t.Fatalf("Step failed: %v", err)
+    }
+    if got := weights.Kernels[0].Weights[0]; math.Abs(float64(got-0.8)) > 1e-5 {
+        t.Errorf("Second step: expected 0.8, got %f", got)
+    }
+    if adam.Steps() != 2 || adam.Name() != "adam" {
+        t.Errorf("Expected adam after 2 steps, got %s after %d", adam.Name(), adam.Steps())
+    }
+}
+
+func TestAdamWeightDecay(t *testing.T) {
+    // Without a gradient, coupled decay is normalized to a full lr step,
+    // decoupled decay shrinks the kernel by lr·decay only
+    for _, tc := range []struct {
+        decoupled bool
+        want      float32
+    }{
+        {false, 3 - 0.1},
+        {true, 3 * (1 - 0.1*0.01)},
+    } {
+        weights := testWeights()
+        opts := DefaultAdamOptions()
+        opts.LearningRate, opts.WeightDecay, opts.Decoupled = 0.1, 0.01, tc.decoupled
+        adam, err := NewAdam(opts)
+        if err != nil {
+            t.Fatalf("NewAdam failed: %v", err)
+        }
+        if err := adam.Step(weights, NewGradients(weights)); err != nil {
+            t.Fatalf("Step failed: %v", err)
+        }
+        if got := weights.Kernels[1].Weights[1]; math.Abs(float64(got-tc.want)) > 1e-5 {
+            t.Errorf("%s: expected %f, got %f", adam.Name(), tc.want, got)
+        }
+        if got := weights.Biases[1][0]; got != -1 {
+            t.Errorf("%s: bias is not decayed, expected -1, got %f", adam.Name(), got)
+        }
+    }
+}
+
+func TestNewAdamValidation(t *testing.T) {
+    for _, modify := range []func(*AdamOptions){
+        func(o *AdamOptions) { o.LearningRate = 0 },
+        func(o *AdamOptions) { o.Beta1 = 1 },
+        func(o *AdamOptions) { o.Beta2 = -0.1 },
+        func(o *AdamOptions) { o.Epsilon = 0 },
+        func(o *AdamOptions) { o.WeightDecay = -1 },
+    } {
+        opts := DefaultAdamOptions()
+        modify(&opts)
+        if _, err := NewAdam(opts); err == nil {
+            t.Errorf("Expected an error for %+v", opts)
+        }
+    }
+}
+
+// TestOptimizerStateResume checks that saving and restoring the state in the
+// middle of training gives the same weights as training straight through
+func TestOptimizerStateResume(t *testing.T) {
+    newAdam := func() Stateful {
+        opts := DefaultAdamOptions()
+        opts.WeightDecay, opts.Decoupled = 0.01, true
+        adam, _ := NewAdam(opts)
+        return adam
+    }
+    newSGD := func() Stateful {
+        sgd, _ := NewSGD(SGDOptions{LearningRate: 0.05, Momentum: 0.9})
+        return sgd
+    }
+
+    for _, newOptimizer := range []func() Stateful{newAdam, newSGD} {
+        straight, resumed := testWeights(), testWeights()
+        grads := NewGradients(straight)
+        grads.Kernels[0][0], grads.Kernels[1][1], grads.Scales[0][1] = 1, -2, 0.5
+
+        opt := newOptimizer()
+        for i := 0; i < 4; i++ {
+            if err := opt.Step(straight, grads); err != nil {
+                t.Fatalf("Step failed: %v", err)
+            }
+        }
+
+        first := newOptimizer()
+        for i := 0; i < 2; i++ {
+            first.Step(resumed, grads)
+        }
+        dir := t.TempDir()
+        if err := SaveOptimizer(dir, first); err != nil {
+            t.Fatalf("SaveOptimizer failed: %v", err)
+        }
+        second := newOptimizer()
+        if err := RestoreOptimizer(dir, second); err != nil {
+            t.Fatalf("RestoreOptimizer failed: %v", err)
+        }
+        for i := 0; i < 2; i++ {
+            second.Step(resumed, grads)
+        }
+
+        for i := range straight.Kernels {
+            for j, w := range straight.Kernels[i].Weights {
+                if got := resumed.Kernels[i].Weights[j]; got != w {
+                    t.Errorf("%s: kernel %d[%d] is %f after resuming, %f straight through", opt.Name(), i, j, got, w)
+                }
+            }
+        }
+        if got, want := resumed.BatchNorms[0].Scale[1], straight.BatchNorms[0].Scale[1]; got != want {
+            t.Errorf("%s: scale is %f after resuming, %f straight through", opt.Name(), got, want)
+        }
+    }
+}
+
+func TestOptimizerStateErrors(t *testing.T) {
+    sgd, _ := NewSGD(SGDOptions{LearningRate: 0.1, Momentum: 0.9})
+    adam, _ := NewAdam(DefaultAdamOptions())
+
+    if err := RestoreOptimizer(t.TempDir(), adam); !errors.Is(err, fs.ErrNotExist) {
+        t.Errorf("Expected fs.ErrNotExist without a state file, got %v", err)
+    }
+    if err := adam.Restore(sgd.State()); err == nil {
+        t.Error("Expected an error restoring Adam from SGD state")
+    }
+
+    // A buffer of the wrong length is caught before any weight moves
+    weights := testWeights()
+    state := adam.State()
+    state.Buffers["m/0/kernel"] = []float32{1, 2, 3}
+    path := filepath.Join(t.TempDir(), StateFileName)
+    if err := SaveState(path, state); err != nil {
+        t.Fatalf("SaveState failed: %v", err)
+    }
+    loaded, err := LoadState(path)
+    if err != nil {
+        t.Fatalf("LoadState failed: %v", err)
+    }
+    if len(loaded.Buffers["m/0/kernel"]) != 3 || loaded.Optimizer != "adam" {
+        t.Fatalf("Unexpected state after loading: %+v", loaded)
+    }
+    if err := adam.Restore(loaded); err != nil {
+        t.Fatalf("Restore failed: %v", err)
+    }
+    if err := adam.Step(weights, NewGradients(weights)); err == nil {
+        t.Error("Expected an error for a buffer of the wrong length")
+    }
+    if weights.Kernels[0].Weights[0] != 1 {
+        t.Errorf("A failed step must leave the weights untouched, got %f", weights.Kernels[0].Weights[0])
+    }
+}
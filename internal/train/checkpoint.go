@@ -0,0 +1,183 @@
+package train
+
+import (
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"strings"
+)
+
+/**
+* Training checkpoints
+
+A run that stops, on purpose or not, continues from a checkpoint: one file
+holding everything the next step depends on, so the resumed run takes
+exactly the steps the uninterrupted run would have:
+```
+header   epoch, steps into it, seed, random source state, optimizer, steps,
+         learning rate, and the kernel shape and batch norm epsilon per conv layer
+arrays   weights/<conv layer>/{kernel,bias,scale,shift,mean,variance}
+         optimizer/<buffer>       the optimizer's State buffers, e.g. optimizer/m/3/kernel
+```
+The file has the layout of an optimizer state file, with magic "GCKP".
+The shuffle order is not stored: it follows from the seed and the epoch.
+*/
+
+// CheckpointFileName is the conventional file name of a checkpoint in a weights directory
+const CheckpointFileName = "training.ckpt"
+
+// checkpointMagic starts every checkpoint file
+const checkpointMagic = "GCKP"
+
+// checkpointVersion is bumped whenever the file layout changes
+const checkpointVersion = 1
+
+// Checkpoint is the state of a training run between two steps
+type Checkpoint struct {
+    Epoch     int    // Epochs completed
+    EpochStep int    // Steps taken into the epoch in progress
+    Seed      uint64 // Seed the run shuffles and augments with
+    Random    []byte // State of the augmentation random source; nil without one
+    Weights   *data.ModelWeights
+    Optimizer *State
+}
+
+// checkpointHeader is the JSON header of a checkpoint file
+type checkpointHeader struct {
+    Epoch        int               `json:"epoch"`
+    EpochStep    int               `json:"epoch_step"`
+    Seed         uint64            `json:"seed"`
+    Random       []byte            `json:"random,omitempty"`
+    Optimizer    string            `json:"optimizer"`
+    Steps        int               `json:"steps"`
+    LearningRate float32           `json:"learning_rate"`
+    Layers       []checkpointLayer `json:"layers"`
+    Arrays       []stateBuffer     `json:"arrays"`
+}
+
+// checkpointLayer describes the weights of one conv layer
+type checkpointLayer struct {
+    KernelSize int      `json:"kernel_size"`
+    Channels   int      `json:"channels"`
+    Filters    int      `json:"filters"`
+    Epsilon    *float32 `json:"batch_norm_epsilon,omitempty"` // nil without batch norm
+}
+
+// SaveCheckpoint writes c to path
+// The weights must be float32, as training leaves them.
+func SaveCheckpoint(path string, c *Checkpoint) error {
+    header := checkpointHeader{
+        Epoch:        c.Epoch,
+        EpochStep:    c.EpochStep,
+        Seed:         c.Seed,
+        Random:       c.Random,
+        Optimizer:    c.Optimizer.Optimizer,
+        Steps:        c.Optimizer.Steps,
+        LearningRate: c.Optimizer.LearningRate,
+    }
+    var arrays [][]float32
+    add := func(name string, values []float32) {
+        header.Arrays = append(header.Arrays, stateBuffer{Name: name, Length: len(values)})
+        arrays = append(arrays, values)
+    }
+
+    weights := c.Weights
+    for i, kernel := range weights.Kernels {
+        if weights.IsQuantized(i) || kernel == nil {
+            return fmt.Errorf("conv layer %d has no float32 kernel to checkpoint", i)
+        }
+        layer := checkpointLayer{KernelSize: kernel.Size, Channels: kernel.Channels, Filters: kernel.Filters}
+        prefix := fmt.Sprintf("weights/%d/", i)
+        add(prefix+"kernel", kernel.Weights)
+        if i < len(weights.Biases) {
+            add(prefix+"bias", weights.Biases[i])
+        }
+        if i < len(weights.BatchNorms) && weights.BatchNorms[i] != nil {
+            bn := weights.BatchNorms[i]
+            layer.Epsilon = &bn.Epsilon
+            add(prefix+"scale", bn.Scale)
+            add(prefix+"shift", bn.Shift)
+            add(prefix+"mean", bn.Mean)
+            add(prefix+"variance", bn.Variance)
+        }
+        header.Layers = append(header.Layers, layer)
+    }
+    for _, name := range sortedNames(c.Optimizer.Buffers) {
+        add("optimizer/"+name, c.Optimizer.Buffers[name])
+    }
+
+    return writeArrayFile(path, checkpointMagic, checkpointVersion, "training checkpoint", header, arrays)
+}
+
+// LoadCheckpoint reads a checkpoint written by SaveCheckpoint
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+    var header checkpointHeader
+    arrays, err := readArrayFile(path, checkpointMagic, checkpointVersion, "training checkpoint", &header,
+        func() []stateBuffer { return header.Arrays })
+    if err != nil {
+        return nil, err
+    }
+
+    c := &Checkpoint{
+        Epoch:     header.Epoch,
+        EpochStep: header.EpochStep,
+        Seed:      header.Seed,
+        Random:    header.Random,
+        Weights:   &data.ModelWeights{},
+        Optimizer: &State{
+            Optimizer:    header.Optimizer,
+            Steps:        header.Steps,
+            LearningRate: header.LearningRate,
+            Buffers:      make(map[string][]float32),
+        },
+    }
+    byName := make(map[string][]float32, len(arrays))
+    for i, array := range header.Arrays {
+        if name, ok := strings.CutPrefix(array.Name, "optimizer/"); ok {
+            c.Optimizer.Buffers[name] = arrays[i]
+        } else {
+            byName[array.Name] = arrays[i]
+        }
+    }
+
+    // Every array of a layer must be there, with the length its shape gives
+    take := func(layer int, name string, length int) ([]float32, error) {
+        values, ok := byName[fmt.Sprintf("weights/%d/%s", layer, name)]
+        if !ok || len(values) != length {
+            return nil, fmt.Errorf("%s: conv layer %d has no %s of %d values", path, layer, name, length)
+        }
+        return values, nil
+    }
+    weights := c.Weights
+    for i, layer := range header.Layers {
+        values, err := take(i, "kernel", layer.KernelSize*layer.KernelSize*layer.Channels*layer.Filters)
+        if err != nil {
+            return nil, err
+        }
+        kernel, err := tensor.NewKernelFromData(values, layer.KernelSize, layer.Channels, layer.Filters)
+        if err != nil {
+            return nil, fmt.Errorf("%s: conv layer %d: %w", path, i, err)
+        }
+        bias, err := take(i, "bias", layer.Filters)
+        if err != nil {
+            return nil, err
+        }
+        weights.Kernels = append(weights.Kernels, kernel)
+        weights.Biases = append(weights.Biases, bias)
+
+        var bn *data.BatchNormParams
+        if layer.Epsilon != nil {
+            bn = &data.BatchNormParams{Epsilon: *layer.Epsilon}
+            for _, array := range []struct {
+                name   string
+                values *[]float32
+            }{{"scale", &bn.Scale}, {"shift", &bn.Shift}, {"mean", &bn.Mean}, {"variance", &bn.Variance}} {
+                if *array.values, err = take(i, array.name, layer.Filters); err != nil {
+                    return nil, err
+                }
+            }
+        }
+        weights.BatchNorms = append(weights.BatchNorms, bn)
+    }
+    return c, nil
+}
@@ -0,0 +1,114 @@
+package train
+
+import (
+	"duchm1606/gocnn/internal/data"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// trainSteps runs steps Adam steps of the tiny network on a fixed batch
+func trainSteps(t *testing.T, weights *data.ModelWeights, adam *Adam, steps int) {
+    t.Helper()
+    network, err := NewNetwork(tinyArchitecture(), weights, NetworkOptions{Workers: 3})
+    if err != nil {
+        t.Fatalf("NewNetwork failed: %v", err)
+    }
+    images, labels := tinyBatch(6, 4)
+    grads := network.NewGradients()
+    for step := 0; step < steps; step++ {
+        grads.Zero()
+        if _, err := network.TrainBatch(images, labels, grads); err != nil {
+            t.Fatalf("TrainBatch failed: %v", err)
+        }
+        if err := adam.Step(weights, grads); err != nil {
+            t.Fatalf("Step failed: %v", err)
+        }
+    }
+}
+
+// sameWeights reports whether a and b hold identical float32 arrays
+func sameWeights(a, b *data.ModelWeights) bool {
+    if len(a.Kernels) != len(b.Kernels) {
+        return false
+    }
+    for i := range a.Kernels {
+        if !slices.Equal(a.Kernels[i].Weights, b.Kernels[i].Weights) || !slices.Equal(a.Biases[i], b.Biases[i]) {
+            return false
+        }
+        bnA, bnB := a.BatchNorms[i], b.BatchNorms[i]
+        if (bnA == nil) != (bnB == nil) {
+            return false
+        }
+        if bnA != nil && (!slices.Equal(bnA.Scale, bnB.Scale) || !slices.Equal(bnA.Shift, bnB.Shift) ||
+            !slices.Equal(bnA.Mean, bnB.Mean) || !slices.Equal(bnA.Variance, bnB.Variance) || bnA.Epsilon != bnB.Epsilon) {
+            return false
+        }
+    }
+    return true
+}
+
+func TestCheckpointResume(t *testing.T) {
+    // Four steps in one go
+    straight, _ := InitWeights(tinyArchitecture(), 3)
+    adam, _ := NewAdam(DefaultAdamOptions())
+    trainSteps(t, straight, adam, 4)
+
+    // Two steps, a checkpoint, and two more from what it holds
+    weights, _ := InitWeights(tinyArchitecture(), 3)
+    adam, _ = NewAdam(DefaultAdamOptions())
+    trainSteps(t, weights, adam, 2)
+    path := filepath.Join(t.TempDir(), CheckpointFileName)
+    saved := &Checkpoint{Epoch: 1, EpochStep: 2, Seed: 3, Random: []byte("pcg:state"), Weights: weights, Optimizer: adam.State()}
+    if err := SaveCheckpoint(path, saved); err != nil {
+        t.Fatalf("SaveCheckpoint failed: %v", err)
+    }
+
+    loaded, err := LoadCheckpoint(path)
+    if err != nil {
+        t.Fatalf("LoadCheckpoint failed: %v", err)
+    }
+    if loaded.Epoch != 1 || loaded.EpochStep != 2 || loaded.Seed != 3 || string(loaded.Random) != "pcg:state" {
+        t.Errorf("Expected the run's position back, got epoch %d, step %d, seed %d, random %q",
+            loaded.Epoch, loaded.EpochStep, loaded.Seed, loaded.Random)
+    }
+    if !sameWeights(loaded.Weights, weights) {
+        t.Fatal("Expected the checkpointed weights back unchanged")
+    }
+    resumed, _ := NewAdam(DefaultAdamOptions())
+    if err := resumed.Restore(loaded.Optimizer); err != nil {
+        t.Fatalf("Restore failed: %v", err)
+    }
+    trainSteps(t, loaded.Weights, resumed, 2)
+
+    if !sameWeights(loaded.Weights, straight) {
+        t.Error("Expected the resumed run to end with the weights of the uninterrupted one")
+    }
+}
+
+func TestCheckpointErrors(t *testing.T) {
+    dir := t.TempDir()
+    weights, _ := InitWeights(tinyArchitecture(), 1)
+    path := filepath.Join(dir, CheckpointFileName)
+    if err := SaveCheckpoint(path, &Checkpoint{Weights: weights, Optimizer: &State{Optimizer: "sgd"}}); err != nil {
+        t.Fatalf("SaveCheckpoint failed: %v", err)
+    }
+    if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+        t.Error("Expected the temporary file to be renamed")
+    }
+
+    raw, _ := os.ReadFile(path)
+    truncated := filepath.Join(dir, "truncated.ckpt")
+    os.WriteFile(truncated, raw[:len(raw)-4], 0644)
+    if _, err := LoadCheckpoint(truncated); err == nil {
+        t.Error("Expected an error for a truncated checkpoint")
+    }
+
+    // An optimizer state file is not a checkpoint
+    state := filepath.Join(dir, StateFileName)
+    SaveState(state, &State{Optimizer: "sgd"})
+    if _, err := LoadCheckpoint(state); err == nil {
+        t.Error("Expected an error for an optimizer state file")
+    }
+}
@@ -0,0 +1,504 @@
+package train
+
+import (
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/ops"
+	"duchm1606/gocnn/internal/tensor"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+)
+
+/**
+* Training passes
+
+A Network runs a TinyCNN architecture over a batch of images the way
+training needs it: every layer's input and output kept for the backward
+pass, and batch norm normalizing with the statistics of the batch instead
+of the moving ones:
+```
+forward   conv → batch norm (batch mean, variance) → ReLU → max pool ... → global pool → logits
+loss      mean over the batch of cross-entropy(softmax(logits), labels)
+backward  ∂loss/∂logits → ... → ∂loss/∂kernel, bias, scale, shift   (accumulated into Gradients)
+```
+Every batch also moves the moving statistics towards its own, as Keras does:
+moving = momentum·moving + (1 − momentum)·batch. Those are what inference
+normalizes with, so a checkpoint of the weights is ready to run as is.
+
+Fine-tuning on small batches may keep batch norm frozen instead: the moving
+statistics normalize and are left alone, as at inference time.
+
+Fine-tuning may also freeze whole conv layers, typically every one but the
+classifier to repurpose pretrained features for a new label set. A frozen
+layer keeps its kernel and bias, normalizes with its moving statistics and,
+unless its batch norm scale and shift stay trainable, gets no gradients at
+all; the backward pass stops below the first layer that still learns:
+```
+conv1 ... conv6 (frozen, forward only) → maxpool3 → conv7 (trained) → global pool → logits
+```
+
+Conv, max pooling and global max or average pooling layers can be trained;
+the network ends in the global pooling layer, optionally followed by
+softmax. Convolutions run forward on the engine's batched GEMM; the backward
+passes of the images of a batch run in parallel.
+*/
+
+// DefaultBatchNormMomentum is the Keras default weight of the old moving statistics
+const DefaultBatchNormMomentum = 0.99
+
+// NetworkOptions configures a Network
+type NetworkOptions struct {
+    BatchNormMomentum float32 // Weight of the old moving statistics per batch, in [0, 1); 0 selects the default
+    FreezeBatchNorm   bool    // Normalize with the moving statistics and leave them unchanged
+    Frozen            []string // Conv layers whose kernel, bias and batch norm are not trained
+    TrainFrozenAffine bool     // Train the batch norm scale and shift of the Frozen layers all the same
+    Workers           int      // Images whose backward pass runs at once (0 = one per CPU)
+}
+
+// Network runs training passes of an architecture over weights, which it
+// updates in place (the moving statistics; optimizers update the rest)
+type Network struct {
+    arch    *model.TinyCNNArchitecture
+    weights *data.ModelWeights
+    opts    NetworkOptions
+    convIdx []int  // Conv layer index into weights of every layer, -1 for other layers
+    frozen  []bool // Per conv layer, whether it is in opts.Frozen
+    logits  int    // Index of the layer whose output holds the logits
+    engine  *ops.ConvolutionEngine
+}
+
+// BatchResult summarizes one training pass
+type BatchResult struct {
+    Loss    float64 // Mean cross-entropy over the batch
+    Correct int     // Images whose largest logit is their label
+    Size    int
+}
+
+// NewNetwork checks that arch can be trained and that weights hold a float32
+// kernel of the right shape for every conv layer
+func NewNetwork(arch *model.TinyCNNArchitecture, weights *data.ModelWeights, opts NetworkOptions) (*Network, error) {
+    if opts.BatchNormMomentum == 0 {
+        opts.BatchNormMomentum = DefaultBatchNormMomentum
+    }
+    if opts.BatchNormMomentum < 0 || opts.BatchNormMomentum >= 1 {
+        return nil, fmt.Errorf("batch norm momentum must be in [0, 1), got %g", opts.BatchNormMomentum)
+    }
+    if opts.Workers <= 0 {
+        opts.Workers = runtime.NumCPU()
+    }
+    if arch.InputDepth > 0 {
+        return nil, fmt.Errorf("training supports 2D images only, the model takes %d frames", arch.InputDepth)
+    }
+    dims, err := arch.GetOutputDimensions()
+    if err != nil {
+        return nil, fmt.Errorf("invalid architecture: %w", err)
+    }
+
+    n := &Network{arch: arch, weights: weights, opts: opts, convIdx: make([]int, len(arch.Layers)),
+        logits: len(arch.Layers) - 1, engine: ops.NewConvolutionEngine()}
+    if n.logits >= 0 && arch.Layers[n.logits].Type == model.SoftmaxLayer {
+        n.logits--
+    }
+    if n.logits < 0 {
+        return nil, fmt.Errorf("model has no layer before softmax")
+    }
+    frozen := make(map[string]bool, len(opts.Frozen))
+    for _, name := range opts.Frozen {
+        frozen[name] = true
+    }
+
+    convs := 0
+    for i, layer := range arch.Layers[:n.logits+1] {
+        n.convIdx[i] = -1
+        switch layer.Type {
+        case model.ConvolutionLayer:
+            if convs >= len(weights.Kernels) {
+                return nil, fmt.Errorf("layer %s has no weights: the weights hold %d conv layers", layer.Name, len(weights.Kernels))
+            }
+            if weights.IsQuantized(convs) || weights.Kernels[convs] == nil {
+                return nil, fmt.Errorf("layer %s has no float32 kernel to train; load the weights as float32", layer.Name)
+            }
+            kernel, channels := weights.Kernels[convs], dims[i][2]
+            if kernel.Size != layer.KernelSize || kernel.Channels != channels || kernel.Filters != layer.Filters {
+                return nil, fmt.Errorf("layer %s takes a %dx%dx%dx%d kernel, the weights have %dx%dx%dx%d", layer.Name,
+                    layer.KernelSize, layer.KernelSize, channels, layer.Filters,
+                    kernel.Size, kernel.Size, kernel.Channels, kernel.Filters)
+            }
+            if convs >= len(weights.Biases) || len(weights.Biases[convs]) != layer.Filters {
+                return nil, fmt.Errorf("layer %s needs a bias of %d values", layer.Name, layer.Filters)
+            }
+            n.convIdx[i] = convs
+            n.frozen = append(n.frozen, frozen[layer.Name])
+            delete(frozen, layer.Name)
+            convs++
+        case model.MaxPoolingLayer, model.GlobalMaxPoolingLayer, model.GlobalAveragePoolingLayer:
+        default:
+            return nil, fmt.Errorf("layer %s: layers of type %d cannot be trained", layer.Name, layer.Type)
+        }
+    }
+    if out := dims[n.logits+1]; out[0] != 1 || out[1] != 1 {
+        return nil, fmt.Errorf("layer %s gives %dx%d maps, not one logit per class",
+            arch.Layers[n.logits].Name, out[0], out[1])
+    }
+    for _, name := range opts.Frozen {
+        if frozen[name] {
+            return nil, fmt.Errorf("no conv layer named %q to freeze", name)
+        }
+    }
+    if n.trainableFrom() < 0 {
+        return nil, fmt.Errorf("every conv layer is frozen, nothing is left to train")
+    }
+    return n, nil
+}
+
+// NewGradients returns zero gradients for the arrays the network trains
+// The arrays of frozen layers are nil, so optimizers leave them alone, weight
+// decay included.
+func (n *Network) NewGradients() *Gradients {
+    grads := NewGradients(n.weights)
+    for idx, frozen := range n.frozen {
+        if !frozen {
+            continue
+        }
+        grads.Kernels[idx], grads.Biases[idx] = nil, nil
+        if !n.opts.TrainFrozenAffine {
+            grads.Scales[idx], grads.Shifts[idx] = nil, nil
+        }
+    }
+    return grads
+}
+
+// trainableFrom returns the index of the first layer with arrays to train, or -1
+// The backward pass stops there.
+func (n *Network) trainableFrom() int {
+    for i, idx := range n.convIdx {
+        if idx < 0 {
+            continue
+        }
+        if !n.frozen[idx] || (n.opts.TrainFrozenAffine && n.batchNorm(n.arch.Layers[i], idx) != nil) {
+            return i
+        }
+    }
+    return -1
+}
+
+// frozenStatistics reports whether conv layer idx normalizes with its moving statistics
+func (n *Network) frozenStatistics(idx int) bool {
+    return n.opts.FreezeBatchNorm || n.frozen[idx]
+}
+
+// TrainBatch runs a training pass over images and their one-hot labels: it adds
+// the gradients of the mean loss to grads and moves the batch norm moving
+// statistics towards the batch's. grads is not zeroed first; nil arrays in it,
+// as NewGradients leaves for frozen layers, are skipped.
+func (n *Network) TrainBatch(images []*tensor.FeatureMap, labels [][]int, grads *Gradients) (BatchResult, error) {
+    return n.pass(images, labels, grads, true)
+}
+
+// Loss runs the forward pass of TrainBatch only, normalizing with the batch's
+// statistics but leaving the weights unchanged
+func (n *Network) Loss(images []*tensor.FeatureMap, labels [][]int) (BatchResult, error) {
+    return n.pass(images, labels, nil, false)
+}
+
+// layerPass is what the forward pass of one layer keeps for the backward pass
+type layerPass struct {
+    inputs  []*tensor.FeatureMap
+    raw     []*tensor.FeatureMap  // Conv outputs before batch norm and ReLU
+    outputs []*tensor.FeatureMap
+    bn      *ops.BatchNormParams  // Statistics and parameters the conv outputs were normalized with
+    relu    bool
+}
+
+// pass runs the forward pass and, with grads, the backward pass
+func (n *Network) pass(images []*tensor.FeatureMap, labels [][]int, grads *Gradients, update bool) (BatchResult, error) {
+    if len(images) == 0 || len(images) != len(labels) {
+        return BatchResult{}, fmt.Errorf("%d images for %d labels", len(images), len(labels))
+    }
+    arch := n.arch
+    for b, image := range images {
+        if image.Height != arch.InputHeight || image.Width != arch.InputWidth || image.Channels != arch.InputChannels {
+            return BatchResult{}, fmt.Errorf("image %d is %dx%dx%d, the model takes %dx%dx%d", b,
+                image.Height, image.Width, image.Channels, arch.InputHeight, arch.InputWidth, arch.InputChannels)
+        }
+        if len(labels[b]) != arch.NumClasses {
+            return BatchResult{}, fmt.Errorf("label %d has %d classes, the model %d", b, len(labels[b]), arch.NumClasses)
+        }
+    }
+
+    passes := make([]layerPass, n.logits+1)
+    current := images
+    for i, layer := range arch.Layers[:n.logits+1] {
+        p := &passes[i]
+        p.inputs = current
+        switch layer.Type {
+        case model.ConvolutionLayer:
+            n.convForward(p, layer, n.convIdx[i], update)
+        case model.MaxPoolingLayer:
+            p.outputs = n.each(current, func(input *tensor.FeatureMap) *tensor.FeatureMap {
+                return ops.MaxPooling2D(input, layer.PoolSize, layer.PoolStride)
+            })
+        case model.GlobalMaxPoolingLayer, model.GlobalAveragePoolingLayer:
+            p.outputs = n.each(current, func(input *tensor.FeatureMap) *tensor.FeatureMap {
+                pooled := ops.GlobalMaxPooling(input)
+                if layer.Type == model.GlobalAveragePoolingLayer {
+                    pooled = ops.GlobalAvgPooling(input)
+                }
+                fm, _ := tensor.NewFeatureMapFromData(pooled, 1, 1, input.Channels)
+                return fm
+            })
+        }
+        current = p.outputs
+    }
+
+    // Loss of every image and its gradient with respect to the logits
+    result := BatchResult{Size: len(images)}
+    logitGrads := make([]*tensor.FeatureMap, len(images))
+    scale := 1 / float32(len(images))
+    for b, logits := range current {
+        target := make([]float32, len(labels[b]))
+        best := 0
+        for class, v := range labels[b] {
+            target[class] = float32(v)
+            if logits.Data[class] > logits.Data[best] {
+                best = class
+            }
+        }
+        loss, grad := ops.SoftmaxCrossEntropyBackward(logits.Data, target)
+        result.Loss += float64(loss)
+        if labels[b][best] > 0 {
+            result.Correct++
+        }
+        for i := range grad {
+            grad[i] *= scale
+        }
+        logitGrads[b], _ = tensor.NewFeatureMapFromData(grad, 1, 1, len(grad))
+    }
+    result.Loss /= float64(len(images))
+    if math.IsNaN(result.Loss) || math.IsInf(result.Loss, 0) {
+        return result, fmt.Errorf("loss is %g; lower the learning rate", result.Loss)
+    }
+    if grads == nil {
+        return result, nil
+    }
+
+    current = logitGrads
+    first := n.trainableFrom()
+    for i := n.logits; i >= first; i-- {
+        layer, p := arch.Layers[i], &passes[i]
+        switch layer.Type {
+        case model.ConvolutionLayer:
+            current = n.convBackward(p, layer, n.convIdx[i], current, grads, i > first)
+        case model.MaxPoolingLayer:
+            current = n.eachPair(current, p.inputs, func(grad, input *tensor.FeatureMap) *tensor.FeatureMap {
+                return ops.MaxPooling2DBackward(grad, input, layer.PoolSize, layer.PoolStride)
+            })
+        case model.GlobalMaxPoolingLayer:
+            current = n.eachPair(current, p.inputs, func(grad, input *tensor.FeatureMap) *tensor.FeatureMap {
+                return ops.GlobalMaxPoolingBackward(grad.Data, input)
+            })
+        case model.GlobalAveragePoolingLayer:
+            current = n.eachPair(current, p.inputs, func(grad, input *tensor.FeatureMap) *tensor.FeatureMap {
+                return ops.GlobalAvgPoolingBackward(grad.Data, input)
+            })
+        }
+    }
+    return result, nil
+}
+
+// convForward runs conv layer idx over p.inputs, followed by its batch norm and ReLU
+func (n *Network) convForward(p *layerPass, layer model.LayerConfig, idx int, update bool) {
+    kernel, bias := n.weights.Kernels[idx], n.weights.Biases[idx]
+    config := ops.Conv2DConfig{Padding: layer.Padding, Stride: layer.Stride}
+    p.raw = n.engine.Conv2DFusedBatch(p.inputs, kernel, bias, nil, false, config)
+
+    stored := n.batchNorm(layer, idx)
+    p.relu = stored != nil || layer.ApplyActivation
+    if stored != nil {
+        p.bn = &ops.BatchNormParams{Mean: stored.Mean, Variance: stored.Variance,
+            Scale: stored.Scale, Shift: stored.Shift, Epsilon: stored.Epsilon}
+        if !n.frozenStatistics(idx) {
+            p.bn.Mean, p.bn.Variance = ops.ComputeBatchStatistics(p.raw)
+            if update {
+                momentum := n.opts.BatchNormMomentum
+                for c := range stored.Mean {
+                    stored.Mean[c] = momentum*stored.Mean[c] + (1-momentum)*p.bn.Mean[c]
+                    stored.Variance[c] = momentum*stored.Variance[c] + (1-momentum)*p.bn.Variance[c]
+                }
+            }
+        }
+        // BatchNormalize ends in ReLU, as batch norm always does here
+        p.outputs = n.each(p.raw, func(raw *tensor.FeatureMap) *tensor.FeatureMap {
+            return ops.BatchNormalize(raw, p.bn)
+        })
+        return
+    }
+
+    p.outputs = n.each(p.raw, func(raw *tensor.FeatureMap) *tensor.FeatureMap {
+        output := raw.Clone()
+        if p.relu {
+            ops.ReLUInPlace(output.Data)
+        }
+        return output
+    })
+}
+
+// convBackward returns the gradients with respect to the inputs of conv layer
+// idx, if inputGrads, from the gradients of its outputs and adds the gradients
+// of its parameters to grads
+func (n *Network) convBackward(p *layerPass, layer model.LayerConfig, idx int, outputGrads []*tensor.FeatureMap,
+    grads *Gradients, inputGrads bool) []*tensor.FeatureMap {
+
+    rawGrads := outputGrads
+    if p.relu {
+        rawGrads = n.eachPair(outputGrads, p.outputs, ops.ReLUBackward)
+    }
+    if p.bn != nil {
+        var scaleGrad, shiftGrad []float32
+        if n.frozenStatistics(idx) {
+            scaleGrad, shiftGrad = make([]float32, len(p.bn.Scale)), make([]float32, len(p.bn.Shift))
+            dScales, dShifts := make([][]float32, len(rawGrads)), make([][]float32, len(rawGrads))
+            inGrads := make([]*tensor.FeatureMap, len(rawGrads))
+            n.parallel(len(rawGrads), func(b int) {
+                inGrads[b], dScales[b], dShifts[b] = ops.BatchNormBackward(rawGrads[b], p.raw[b], p.bn)
+            })
+            for b := range rawGrads {
+                addTo(scaleGrad, dScales[b])
+                addTo(shiftGrad, dShifts[b])
+            }
+            rawGrads = inGrads
+        } else {
+            rawGrads, scaleGrad, shiftGrad = ops.BatchNormTrainingBackward(rawGrads, p.raw, p.bn)
+        }
+        addTo(grads.Scales[idx], scaleGrad)
+        addTo(grads.Shifts[idx], shiftGrad)
+    }
+    if grads.Kernels[idx] == nil && grads.Biases[idx] == nil && !inputGrads {
+        return nil
+    }
+
+    kernel := n.weights.Kernels[idx]
+    config := ops.Conv2DConfig{Padding: layer.Padding, Stride: layer.Stride}
+    inGrads := make([]*tensor.FeatureMap, len(rawGrads))
+    kernelGrads, biasGrads := make([][]float32, len(rawGrads)), make([][]float32, len(rawGrads))
+    n.parallel(len(rawGrads), func(b int) {
+        inGrad, kernelGrad, biasGrad := ops.Conv2DBackward(rawGrads[b], p.inputs[b], kernel, config)
+        kernelGrads[b], biasGrads[b] = kernelGrad.Weights, biasGrad
+        if inputGrads {
+            inGrads[b] = inGrad
+        }
+    })
+    for b := range rawGrads {
+        addTo(grads.Kernels[idx], kernelGrads[b])
+        addTo(grads.Biases[idx], biasGrads[b])
+    }
+    if !inputGrads {
+        return nil
+    }
+    return inGrads
+}
+
+// addTo adds values to sum, element by element; a nil sum is the gradient of a
+// frozen array and is left nil
+func addTo(sum, values []float32) {
+    if sum == nil {
+        return
+    }
+    for i, v := range values {
+        sum[i] += v
+    }
+}
+
+// batchNorm returns the batch norm following conv layer idx, as inference picks it
+func (n *Network) batchNorm(layer model.LayerConfig, idx int) *data.BatchNormParams {
+    if layer.ApplyBatchNorm && idx < len(n.weights.BatchNorms) {
+        return n.weights.BatchNorms[idx]
+    }
+    return nil
+}
+
+// each applies fn to every feature map of a batch, on up to Workers goroutines
+func (n *Network) each(batch []*tensor.FeatureMap, fn func(*tensor.FeatureMap) *tensor.FeatureMap) []*tensor.FeatureMap {
+    return n.eachPair(batch, batch, func(fm, _ *tensor.FeatureMap) *tensor.FeatureMap { return fn(fm) })
+}
+
+// eachPair applies fn to the feature maps of two batches pairwise, on up to
+// Workers goroutines
+func (n *Network) eachPair(a, b []*tensor.FeatureMap, fn func(x, y *tensor.FeatureMap) *tensor.FeatureMap) []*tensor.FeatureMap {
+    results := make([]*tensor.FeatureMap, len(a))
+    n.parallel(len(a), func(i int) {
+        results[i] = fn(a[i], b[i])
+    })
+    return results
+}
+
+// parallel calls fn for every index below count, on up to Workers goroutines
+// Callers keep per-index results and sum them in index order afterwards, so a
+// batch gives the same gradients however its images were scheduled.
+func (n *Network) parallel(count int, fn func(i int)) {
+    next := make(chan int, count)
+    for i := 0; i < count; i++ {
+        next <- i
+    }
+    close(next)
+
+    var wg sync.WaitGroup
+    for w := 0; w < min(n.opts.Workers, count); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range next {
+                fn(i)
+            }
+        }()
+    }
+    wg.Wait()
+}
+
+// InitWeights returns freshly initialized weights for arch, to train from
+// scratch: He-normal kernels, zero biases, and batch norm with scale 1, shift
+// 0 and moving statistics of a unit normal. The same seed gives the same weights.
+func InitWeights(arch *model.TinyCNNArchitecture, seed uint64) (*data.ModelWeights, error) {
+    dims, err := arch.GetOutputDimensions()
+    if err != nil {
+        return nil, fmt.Errorf("invalid architecture: %w", err)
+    }
+
+    rng := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+    weights := &data.ModelWeights{}
+    for i, layer := range arch.Layers {
+        if layer.Type != model.ConvolutionLayer {
+            continue
+        }
+        channels := dims[i][2]
+        kernel := tensor.NewKernel(layer.KernelSize, channels, layer.Filters)
+        std := math.Sqrt(2 / float64(layer.KernelSize*layer.KernelSize*channels))
+        for j := range kernel.Weights {
+            kernel.Weights[j] = float32(rng.NormFloat64() * std)
+        }
+        weights.Kernels = append(weights.Kernels, kernel)
+        weights.Biases = append(weights.Biases, make([]float32, layer.Filters))
+
+        var bn *data.BatchNormParams
+        if layer.ApplyBatchNorm {
+            bn = &data.BatchNormParams{
+                Mean:     make([]float32, layer.Filters),
+                Variance: make([]float32, layer.Filters),
+                Scale:    make([]float32, layer.Filters),
+                Shift:    make([]float32, layer.Filters),
+                Epsilon:  1e-5, // As the weight loader sets it
+            }
+            for c := 0; c < layer.Filters; c++ {
+                bn.Variance[c], bn.Scale[c] = 1, 1
+            }
+        }
+        weights.BatchNorms = append(weights.BatchNorms, bn)
+    }
+    if len(weights.Kernels) == 0 {
+        return nil, fmt.Errorf("architecture has no conv layers to train")
+    }
+    return weights, nil
+}
@@ -0,0 +1,207 @@
+package train
+
+import (
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/model"
+	"duchm1606/gocnn/internal/tensor"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// tinyArchitecture is conv (batch norm, ReLU) → max pool → 1×1 conv → global
+// average pool → softmax on 4×4×2 images with 3 classes
+func tinyArchitecture() *model.TinyCNNArchitecture {
+    return &model.TinyCNNArchitecture{
+        InputHeight: 4, InputWidth: 4, InputChannels: 2, NumClasses: 3,
+        Layers: []model.LayerConfig{
+            {Type: model.ConvolutionLayer, Name: "conv1", KernelSize: 3, Filters: 4, Stride: 1, Padding: 1,
+                ApplyBatchNorm: true, ApplyActivation: true},
+            {Type: model.MaxPoolingLayer, Name: "pool1", PoolSize: 2, PoolStride: 2},
+            {Type: model.ConvolutionLayer, Name: "conv2", KernelSize: 1, Filters: 3, Stride: 1},
+            {Type: model.GlobalAveragePoolingLayer, Name: "gap"},
+            {Type: model.SoftmaxLayer, Name: "softmax"},
+        },
+    }
+}
+
+// tinyBatch returns n random images with labels cycling through the classes
+func tinyBatch(n int, seed uint64) ([]*tensor.FeatureMap, [][]int) {
+    rng := rand.New(rand.NewPCG(seed, 1))
+    images := make([]*tensor.FeatureMap, n)
+    labels := make([][]int, n)
+    for b := range images {
+        images[b] = tensor.NewFeatureMap(4, 4, 2)
+        for i := range images[b].Data {
+            images[b].Data[i] = rng.Float32()
+        }
+        labels[b] = data.ConvertClassIndexToOneHot(b%3, 3)
+    }
+    return images, labels
+}
+
+func TestNetworkGradients(t *testing.T) {
+    for _, frozen := range []bool{false, true} {
+        arch := tinyArchitecture()
+        weights, err := InitWeights(arch, 7)
+        if err != nil {
+            t.Fatalf("InitWeights failed: %v", err)
+        }
+        // Moving statistics away from the batch's, so frozen batch norm differs
+        for c := range weights.BatchNorms[0].Mean {
+            weights.BatchNorms[0].Mean[c], weights.BatchNorms[0].Variance[c] = 0.1, 0.5
+            weights.BatchNorms[0].Scale[c], weights.BatchNorms[0].Shift[c] = 1.5, 0.2
+        }
+        network, err := NewNetwork(arch, weights, NetworkOptions{FreezeBatchNorm: frozen, Workers: 2})
+        if err != nil {
+            t.Fatalf("NewNetwork failed: %v", err)
+        }
+        images, labels := tinyBatch(4, 3)
+
+        grads := NewGradients(weights)
+        if _, err := network.TrainBatch(images, labels, grads); err != nil {
+            t.Fatalf("TrainBatch failed: %v", err)
+        }
+
+        // Central differences of the loss against every array of both layers
+        arrays := []struct {
+            name   string
+            values []float32
+            grad   []float32
+        }{
+            {"conv1 kernel", weights.Kernels[0].Weights, grads.Kernels[0]},
+            {"conv1 bias", weights.Biases[0], grads.Biases[0]},
+            {"conv1 scale", weights.BatchNorms[0].Scale, grads.Scales[0]},
+            {"conv1 shift", weights.BatchNorms[0].Shift, grads.Shifts[0]},
+            {"conv2 kernel", weights.Kernels[1].Weights, grads.Kernels[1]},
+            {"conv2 bias", weights.Biases[1], grads.Biases[1]},
+        }
+        const eps = 1e-3
+        for _, array := range arrays {
+            for i := 0; i < len(array.values); i += 3 {
+                saved := array.values[i]
+                array.values[i] = saved + eps
+                plus, _ := network.Loss(images, labels)
+                array.values[i] = saved - eps
+                minus, _ := network.Loss(images, labels)
+                array.values[i] = saved
+
+                numeric := (plus.Loss - minus.Loss) / (2 * eps)
+                if diff := math.Abs(numeric - float64(array.grad[i])); diff > 1e-3+0.02*math.Abs(numeric) {
+                    t.Errorf("frozen=%v %s[%d]: gradient %g, numeric %g", frozen, array.name, i, array.grad[i], numeric)
+                }
+            }
+        }
+    }
+}
+
+func TestNetworkTraining(t *testing.T) {
+    arch := tinyArchitecture()
+    weights, _ := InitWeights(arch, 1)
+    network, err := NewNetwork(arch, weights, NetworkOptions{})
+    if err != nil {
+        t.Fatalf("NewNetwork failed: %v", err)
+    }
+    adam, _ := NewAdam(AdamOptions{LearningRate: 0.05, Beta1: 0.9, Beta2: 0.999, Epsilon: 1e-8})
+    images, labels := tinyBatch(6, 5)
+    grads := NewGradients(weights)
+
+    first, last := BatchResult{}, BatchResult{}
+    for step := 0; step < 60; step++ {
+        grads.Zero()
+        result, err := network.TrainBatch(images, labels, grads)
+        if err != nil {
+            t.Fatalf("TrainBatch failed: %v", err)
+        }
+        if err := adam.Step(weights, grads); err != nil {
+            t.Fatalf("Step failed: %v", err)
+        }
+        if step == 0 {
+            first = result
+        }
+        last = result
+    }
+    if last.Loss >= first.Loss/2 || last.Correct != 6 {
+        t.Errorf("Expected training to fit 6 images: loss %.4f → %.4f, %d correct", first.Loss, last.Loss, last.Correct)
+    }
+    if weights.BatchNorms[0].Variance[0] == 1 || weights.BatchNorms[0].Mean[0] == 0 {
+        t.Error("Expected the moving statistics to follow the batches")
+    }
+
+    // The trained weights run through inference as they are
+    cnn, err := model.NewTinyCNNWithWeights(arch, weights)
+    if err != nil {
+        t.Fatalf("NewTinyCNNWithWeights failed: %v", err)
+    }
+    if _, err := cnn.Predict(t.Context(), images[0].Data); err != nil {
+        t.Errorf("Predict failed: %v", err)
+    }
+}
+
+func TestNetworkFrozenLayers(t *testing.T) {
+    for _, affine := range []bool{false, true} {
+        arch := tinyArchitecture()
+        weights, _ := InitWeights(arch, 2)
+        network, err := NewNetwork(arch, weights, NetworkOptions{Frozen: []string{"conv1"}, TrainFrozenAffine: affine})
+        if err != nil {
+            t.Fatalf("NewNetwork failed: %v", err)
+        }
+        grads := network.NewGradients()
+        if grads.Kernels[0] != nil || grads.Biases[0] != nil || (grads.Scales[0] != nil) != affine {
+            t.Fatalf("affine=%v: expected nil gradients for the frozen arrays of conv1", affine)
+        }
+
+        // Weight decay and momentum must not reach the frozen arrays either
+        sgd, _ := NewSGD(SGDOptions{LearningRate: 0.1, Momentum: 0.9, WeightDecay: 0.1})
+        kernel := append([]float32(nil), weights.Kernels[0].Weights...)
+        mean := append([]float32(nil), weights.BatchNorms[0].Mean...)
+        scale := append([]float32(nil), weights.BatchNorms[0].Scale...)
+        head := append([]float32(nil), weights.Kernels[1].Weights...)
+        images, labels := tinyBatch(4, 3)
+        for step := 0; step < 3; step++ {
+            grads.Zero()
+            if _, err := network.TrainBatch(images, labels, grads); err != nil {
+                t.Fatalf("TrainBatch failed: %v", err)
+            }
+            if err := sgd.Step(weights, grads); err != nil {
+                t.Fatalf("Step failed: %v", err)
+            }
+        }
+
+        if !slices.Equal(kernel, weights.Kernels[0].Weights) || !slices.Equal(mean, weights.BatchNorms[0].Mean) {
+            t.Errorf("affine=%v: expected the kernel and moving statistics of conv1 to stay", affine)
+        }
+        if slices.Equal(scale, weights.BatchNorms[0].Scale) == affine {
+            t.Errorf("affine=%v: expected the scale of conv1 to change only when trained", affine)
+        }
+        if slices.Equal(head, weights.Kernels[1].Weights) {
+            t.Errorf("affine=%v: expected conv2 to train", affine)
+        }
+    }
+}
+
+func TestNewNetworkErrors(t *testing.T) {
+    arch := tinyArchitecture()
+    weights, _ := InitWeights(arch, 1)
+
+    weights.Kernels[1] = tensor.NewKernel(1, 4, 5)
+    if _, err := NewNetwork(arch, weights, NetworkOptions{}); err == nil {
+        t.Error("Expected an error for a kernel of the wrong shape")
+    }
+
+    weights, _ = InitWeights(arch, 1)
+    arch.Layers = arch.Layers[:2]
+    if _, err := NewNetwork(arch, weights, NetworkOptions{}); err == nil {
+        t.Error("Expected an error for logits with a spatial size")
+    }
+    if _, err := NewNetwork(tinyArchitecture(), weights, NetworkOptions{BatchNormMomentum: 1}); err == nil {
+        t.Error("Expected an error for batch norm momentum 1")
+    }
+    if _, err := NewNetwork(tinyArchitecture(), weights, NetworkOptions{Frozen: []string{"pool1"}}); err == nil {
+        t.Error("Expected an error for freezing a layer that is not a conv layer")
+    }
+    if _, err := NewNetwork(tinyArchitecture(), weights, NetworkOptions{Frozen: []string{"conv1", "conv2"}}); err == nil {
+        t.Error("Expected an error for freezing every conv layer")
+    }
+}
@@ -0,0 +1,148 @@
+package train
+
+import (
+	"duchm1606/gocnn/internal/data"
+	"fmt"
+)
+
+/**
+* Training
+
+The rest of the module runs models trained elsewhere; this package holds the
+pieces to train them in Go. The backward passes of internal/ops produce the
+gradient of the loss with respect to every trainable array; an Optimizer
+turns those gradients into updates of the weights in place:
+```
+forward → loss → backward (ops.*Backward) → Gradients → Optimizer.Step → ModelWeights
+```
+The trainable arrays are the float32 conv kernels and biases and the batch
+norm scale and shift. The batch norm moving mean and variance are statistics,
+not parameters: training updates them from batch statistics, not gradients.
+Int8 and float16 kernels cannot be trained; load the weights as float32.
+*/
+
+// Gradients holds the gradient of every trainable array of a ModelWeights,
+// with the same shapes: per conv layer, the kernel weights (in Kernel.Weights
+// order), the bias and, for layers with batch norm, its scale and shift.
+// A nil gradient freezes its array: optimizers do not update it.
+type Gradients struct {
+    Kernels [][]float32
+    Biases  [][]float32
+    Scales  [][]float32 // nil for layers without batch norm
+    Shifts  [][]float32 // nil for layers without batch norm
+}
+
+// NewGradients returns zero gradients shaped like the trainable arrays of weights
+func NewGradients(weights *data.ModelWeights) *Gradients {
+    layers := len(weights.Kernels)
+    g := &Gradients{
+        Kernels: make([][]float32, layers),
+        Biases:  make([][]float32, layers),
+        Scales:  make([][]float32, layers),
+        Shifts:  make([][]float32, layers),
+    }
+    for i := 0; i < layers; i++ {
+        if kernel := weights.Kernels[i]; kernel != nil {
+            g.Kernels[i] = make([]float32, len(kernel.Weights))
+        }
+        if i < len(weights.Biases) {
+            g.Biases[i] = make([]float32, len(weights.Biases[i]))
+        }
+        if i < len(weights.BatchNorms) && weights.BatchNorms[i] != nil {
+            g.Scales[i] = make([]float32, len(weights.BatchNorms[i].Scale))
+            g.Shifts[i] = make([]float32, len(weights.BatchNorms[i].Shift))
+        }
+    }
+    return g
+}
+
+// arrays returns every gradient array, in the order of params
+func (g *Gradients) arrays() [][]float32 {
+    var all [][]float32
+    for _, group := range [][][]float32{g.Kernels, g.Biases, g.Scales, g.Shifts} {
+        all = append(all, group...)
+    }
+    return all
+}
+
+// Zero sets every gradient to 0, before accumulating the next batch
+func (g *Gradients) Zero() {
+    for _, grad := range g.arrays() {
+        clear(grad)
+    }
+}
+
+// Scale multiplies every gradient by factor, e.g. 1/batch size to average
+// gradients summed over a batch
+func (g *Gradients) Scale(factor float32) {
+    for _, grad := range g.arrays() {
+        for i := range grad {
+            grad[i] *= factor
+        }
+    }
+}
+
+// Optimizer updates weights from the gradients of the loss
+type Optimizer interface {
+    // Step applies one update to weights, in place, from grads
+    Step(weights *data.ModelWeights, grads *Gradients) error
+}
+
+// param is one trainable array with its gradient
+type param struct {
+    name   string
+    values []float32
+    grad   []float32
+    decay  bool // Whether weight decay applies
+}
+
+// params pairs every trainable array of weights with its gradient, in a fixed
+// order, and checks that their shapes match
+func params(weights *data.ModelWeights, grads *Gradients) ([]param, error) {
+    layers := len(weights.Kernels)
+    if len(grads.Kernels) != layers || len(grads.Biases) != layers ||
+        len(grads.Scales) != layers || len(grads.Shifts) != layers {
+        return nil, fmt.Errorf("gradients cover %d conv layers, the weights have %d", len(grads.Kernels), layers)
+    }
+
+    var ps []param
+    add := func(name string, layer int, values, grad []float32, decay bool) error {
+        if grad == nil {
+            return nil // Frozen
+        }
+        if len(values) != len(grad) {
+            return fmt.Errorf("conv layer %d %s: %d gradients for %d values", layer, name, len(grad), len(values))
+        }
+        if len(values) > 0 {
+            ps = append(ps, param{name: fmt.Sprintf("%d/%s", layer, name), values: values, grad: grad, decay: decay})
+        }
+        return nil
+    }
+    for i := 0; i < layers; i++ {
+        if weights.IsQuantized(i) {
+            return nil, fmt.Errorf("conv layer %d is int8 and cannot be trained", i)
+        }
+        if weights.Kernels[i] == nil {
+            return nil, fmt.Errorf("conv layer %d has no float32 kernel to train", i)
+        }
+        var bias []float32
+        if i < len(weights.Biases) {
+            bias = weights.Biases[i]
+        }
+        var scale, shift []float32
+        if i < len(weights.BatchNorms) && weights.BatchNorms[i] != nil {
+            scale, shift = weights.BatchNorms[i].Scale, weights.BatchNorms[i].Shift
+        }
+        for _, err := range []error{
+            add("kernel", i, weights.Kernels[i].Weights, grads.Kernels[i], true),
+            add("bias", i, bias, grads.Biases[i], false),
+            add("scale", i, scale, grads.Scales[i], false),
+            add("shift", i, shift, grads.Shifts[i], false),
+        } {
+            if err != nil {
+                return nil, err
+            }
+        }
+    }
+    return ps, nil
+}
@@ -0,0 +1,125 @@
+package train
+
+import (
+	"duchm1606/gocnn/internal/data"
+	"fmt"
+)
+
+/**
+* SGD with momentum
+
+Stochastic gradient descent with momentum and weight decay, as in PyTorch's
+torch.optim.SGD, for every trainable value w with gradient g:
+```
+g ← g + decay·w          kernels only; biases and batch norm are not decayed
+v ← momentum·v + g       v starts at 0
+w ← w − lr·v             or w − lr·(g + momentum·v) with Nesterov momentum
+```
+Momentum averages gradients over recent steps, which speeds training up along
+directions the gradients agree on and damps the noise of small batches.
+*/
+
+// SGDOptions configures an SGD optimizer
+type SGDOptions struct {
+    LearningRate float32
+    Momentum     float32 // In [0, 1); 0 is plain SGD
+    WeightDecay  float32 // L2 penalty on the conv kernels
+    Nesterov     bool    // Use Nesterov momentum (requires Momentum > 0)
+}
+
+// SGD is stochastic gradient descent with momentum and weight decay
+type SGD struct {
+    opts    SGDOptions
+    buffers map[string][]float32 // Momentum buffer of every trainable array
+    steps   int
+}
+
+// NewSGD creates an SGD optimizer
+func NewSGD(opts SGDOptions) (*SGD, error) {
+    switch {
+    case opts.LearningRate <= 0:
+        return nil, fmt.Errorf("learning rate must be positive, got %g", opts.LearningRate)
+    case opts.Momentum < 0 || opts.Momentum >= 1:
+        return nil, fmt.Errorf("momentum must be in [0, 1), got %g", opts.Momentum)
+    case opts.WeightDecay < 0:
+        return nil, fmt.Errorf("weight decay must not be negative, got %g", opts.WeightDecay)
+    case opts.Nesterov && opts.Momentum == 0:
+        return nil, fmt.Errorf("Nesterov momentum needs a positive momentum")
+    }
+    return &SGD{opts: opts, buffers: make(map[string][]float32)}, nil
+}
+
+// Name returns "sgd"
+func (s *SGD) Name() string {
+    return "sgd"
+}
+
+// LearningRate returns the current learning rate
+func (s *SGD) LearningRate() float32 {
+    return s.opts.LearningRate
+}
+
+// SetLearningRate changes the learning rate of later steps, for schedules
+func (s *SGD) SetLearningRate(lr float32) {
+    s.opts.LearningRate = lr
+}
+
+// Steps returns the number of updates applied
+func (s *SGD) Steps() int {
+    return s.steps
+}
+
+// Step applies one update to weights, in place, from grads
+// grads is left unmodified.
+func (s *SGD) Step(weights *data.ModelWeights, grads *Gradients) error {
+    ps, err := params(weights, grads)
+    if err != nil {
+        return err
+    }
+
+    if err := checkBuffers(s.buffers, ps, "velocity"); err != nil {
+        return err
+    }
+
+    lr, momentum := s.opts.LearningRate, s.opts.Momentum
+    for _, p := range ps {
+        decay := float32(0)
+        if p.decay {
+            decay = s.opts.WeightDecay
+        }
+        var velocity []float32
+        if momentum > 0 {
+            velocity = buffer(s.buffers, "velocity", p)
+        }
+
+        for i, w := range p.values {
+            g := p.grad[i] + decay*w
+            if momentum > 0 {
+                velocity[i] = momentum*velocity[i] + g
+                if s.opts.Nesterov {
+                    g += momentum * velocity[i]
+                } else {
+                    g = velocity[i]
+                }
+            }
+            p.values[i] = w - lr*g
+        }
+    }
+    s.steps++
+    return nil
+}
+
+// State returns a copy of the momentum buffers, step count and learning rate
+func (s *SGD) State() *State {
+    return &State{Optimizer: s.Name(), Steps: s.steps, LearningRate: s.opts.LearningRate, Buffers: copyBuffers(s.buffers)}
+}
+
+// Restore resumes from a state saved by an SGD optimizer, including its
+// learning rate
+func (s *SGD) Restore(state *State) error {
+    if state.Optimizer != "sgd" {
+        return fmt.Errorf("cannot restore SGD from %s optimizer state", state.Optimizer)
+    }
+    s.steps, s.opts.LearningRate, s.buffers = state.Steps, state.LearningRate, copyBuffers(state.Buffers)
+    return nil
+}
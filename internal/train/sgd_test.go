@@ -0,0 +1,147 @@
+package train
+
+import (
+	"duchm1606/gocnn/internal/data"
+	"duchm1606/gocnn/internal/quant"
+	"duchm1606/gocnn/internal/tensor"
+	"math"
+	"testing"
+)
+
+// testWeights returns two conv layers, the first with batch norm
+func testWeights() *data.ModelWeights {
+    first := tensor.NewKernel(1, 1, 2)
+    copy(first.Weights, []float32{1, -2})
+    second := tensor.NewKernel(1, 2, 1)
+    copy(second.Weights, []float32{0.5, 3})
+    return &data.ModelWeights{
+        Kernels: []*tensor.Kernel{first, second},
+        Biases:  [][]float32{{0.1, 0.2}, {-1}},
+        BatchNorms: []*data.BatchNormParams{
+            {Mean: []float32{0, 0}, Variance: []float32{1, 1}, Scale: []float32{1, 1}, Shift: []float32{0, 0}},
+            nil,
+        },
+    }
+}
+
+func TestNewGradients(t *testing.T) {
+    grads := NewGradients(testWeights())
+    if len(grads.Kernels[0]) != 2 || len(grads.Kernels[1]) != 2 || len(grads.Biases[1]) != 1 {
+        t.Errorf("Unexpected kernel or bias gradient shapes: %v %v", grads.Kernels, grads.Biases)
+    }
+    if len(grads.Scales[0]) != 2 || grads.Scales[1] != nil || grads.Shifts[1] != nil {
+        t.Errorf("Expected batch norm gradients for the first layer only, got %v %v", grads.Scales, grads.Shifts)
+    }
+
+    grads.Kernels[0][1] = 4
+    grads.Scale(0.5)
+    if grads.Kernels[0][1] != 2 {
+        t.Errorf("Scale: expected 2, got %f", grads.Kernels[0][1])
+    }
+    grads.Zero()
+    if grads.Kernels[0][1] != 0 {
+        t.Errorf("Zero: expected 0, got %f", grads.Kernels[0][1])
+    }
+}
+
+func TestSGDStep(t *testing.T) {
+    weights := testWeights()
+    sgd, err := NewSGD(SGDOptions{LearningRate: 0.1, Momentum: 0.9, WeightDecay: 0.01})
+    if err != nil {
+        t.Fatalf("NewSGD failed: %v", err)
+    }
+    grads := NewGradients(weights)
+    grads.Kernels[0][0] = 1
+    grads.Biases[0][0] = 2
+    grads.Shifts[0][1] = -1
+
+    // First step: v = g + decay·w, w -= lr·v
+    if err := sgd.Step(weights, grads); err != nil {
+        t.Fatalf("Step failed: %v", err)
+    }
+    v := float32(1 + 0.01*1)
+    w := 1 - 0.1*v
+    if got := weights.Kernels[0].Weights[0]; math.Abs(float64(got-w)) > 1e-6 {
+        t.Errorf("Kernel: expected %f, got %f", w, got)
+    }
+    if got := weights.Biases[0][0]; math.Abs(float64(got-(0.1-0.1*2))) > 1e-6 {
+        t.Errorf("Bias is not decayed: expected %f, got %f", 0.1-0.1*2, got)
+    }
+    if got := weights.BatchNorms[0].Shift[1]; math.Abs(float64(got-0.1)) > 1e-6 {
+        t.Errorf("Shift: expected 0.1, got %f", got)
+    }
+    // Weight decay alone moves kernels without a gradient
+    if got := weights.Kernels[1].Weights[1]; math.Abs(float64(got-(3-0.1*0.01*3))) > 1e-6 {
+        t.Errorf("Decayed kernel: expected %f, got %f", 3-0.1*0.01*3, got)
+    }
+
+    // Second step: the velocity carries on
+    if err := sgd.Step(weights, grads); err != nil {
+        t.Fatalf("Step failed: %v", err)
+    }
+    v = 0.9*v + (1 + 0.01*w)
+    w -= 0.1 * v
+    if got := weights.Kernels[0].Weights[0]; math.Abs(float64(got-w)) > 1e-6 {
+        t.Errorf("Kernel after 2 steps: expected %f, got %f", w, got)
+    }
+    if sgd.Steps() != 2 {
+        t.Errorf("Expected 2 steps, got %d", sgd.Steps())
+    }
+}
+
+func TestSGDConverges(t *testing.T) {
+    // Minimize Σ (w − target)² over the kernel of one layer
+    target := []float32{3, -1}
+    for _, opts := range []SGDOptions{
+        {LearningRate: 0.1},
+        {LearningRate: 0.05, Momentum: 0.9},
+        {LearningRate: 0.05, Momentum: 0.9, Nesterov: true},
+    } {
+        kernel := tensor.NewKernel(1, 1, 2)
+        weights := &data.ModelWeights{Kernels: []*tensor.Kernel{kernel}, Biases: [][]float32{{0, 0}}}
+        sgd, err := NewSGD(opts)
+        if err != nil {
+            t.Fatalf("NewSGD(%+v) failed: %v", opts, err)
+        }
+        grads := NewGradients(weights)
+        for step := 0; step < 200; step++ {
+            for i, w := range kernel.Weights {
+                grads.Kernels[0][i] = 2 * (w - target[i])
+            }
+            if err := sgd.Step(weights, grads); err != nil {
+                t.Fatalf("Step failed: %v", err)
+            }
+        }
+        for i, w := range kernel.Weights {
+            if math.Abs(float64(w-target[i])) > 1e-3 {
+                t.Errorf("%+v: weight %d is %f, expected %f", opts, i, w, target[i])
+            }
+        }
+    }
+}
+
+func TestSGDErrors(t *testing.T) {
+    for _, opts := range []SGDOptions{
+        {LearningRate: 0},
+        {LearningRate: 0.1, Momentum: 1},
+        {LearningRate: 0.1, WeightDecay: -1},
+        {LearningRate: 0.1, Nesterov: true},
+    } {
+        if _, err := NewSGD(opts); err == nil {
+            t.Errorf("Expected error for %+v", opts)
+        }
+    }
+
+    sgd, _ := NewSGD(SGDOptions{LearningRate: 0.1, Momentum: 0.5})
+    weights := testWeights()
+    grads := NewGradients(weights)
+    grads.Biases[1] = grads.Biases[1][:len(grads.Biases[1])-1]
+    if err := sgd.Step(weights, grads); err == nil {
+        t.Error("Expected error for a gradient of the wrong shape")
+    }
+
+    weights.SetQuantKernel(1, &quant.QuantizedKernel{})
+    if err := sgd.Step(weights, NewGradients(weights)); err == nil {
+        t.Error("Expected error for an int8 kernel")
+    }
+}
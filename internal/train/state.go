@@ -0,0 +1,244 @@
+package train
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+/**
+* Optimizer state
+
+Momentum and Adam keep buffers the size of the weights that build up over
+steps; resuming training from saved weights alone restarts them from zero.
+An optimizer's State holds every buffer, by name, with the step count and
+learning rate, and is saved next to the weights it belongs to
+(<weights dir>/optimizer.state by convention) in one file:
+
+    offset  size  field
+    0       4     magic "GOPT"
+    4       1     format version (1)
+    5       3     reserved, 0
+    8       4     header length n (uint32)
+    12      n     JSON header: optimizer, steps, learning rate and the name
+                  and length of every buffer, in file order
+    12+n    ...   buffers, float32 values
+
+Everything is little-endian. Buffers are named "<buffer>/<conv layer>/<array>",
+e.g. "m/3/kernel" for Adam's first moment of the fourth conv kernel.
+*/
+
+// StateFileName is the conventional file name of optimizer state in a weights directory
+const StateFileName = "optimizer.state"
+
+// stateMagic starts every optimizer state file
+const stateMagic = "GOPT"
+
+// stateVersion is bumped whenever the file layout changes
+const stateVersion = 1
+
+// State is the state an optimizer needs to resume training
+type State struct {
+    Optimizer    string               // Name of the optimizer that wrote it: sgd, adam or adamw
+    Steps        int                  // Updates applied so far
+    LearningRate float32              // Learning rate of the last step
+    Buffers      map[string][]float32 // Per-array buffers by name
+}
+
+// Stateful is implemented by optimizers whose updates depend on earlier steps
+type Stateful interface {
+    Optimizer
+    // Name identifies the optimizer in its state: sgd, adam or adamw
+    Name() string
+    // State returns a copy of the optimizer's state
+    State() *State
+    // Restore replaces the optimizer's state with a copy of state
+    Restore(state *State) error
+}
+
+// stateHeader is the JSON header of a state file
+type stateHeader struct {
+    Optimizer    string        `json:"optimizer"`
+    Steps        int           `json:"steps"`
+    LearningRate float32       `json:"learning_rate"`
+    Buffers      []stateBuffer `json:"buffers"`
+}
+
+// stateBuffer names one buffer of a state file
+type stateBuffer struct {
+    Name   string `json:"name"`
+    Length int    `json:"length"`
+}
+
+// SaveState writes state to path
+func SaveState(path string, state *State) error {
+    header := stateHeader{Optimizer: state.Optimizer, Steps: state.Steps, LearningRate: state.LearningRate}
+    var arrays [][]float32
+    for _, name := range sortedNames(state.Buffers) {
+        header.Buffers = append(header.Buffers, stateBuffer{Name: name, Length: len(state.Buffers[name])})
+        arrays = append(arrays, state.Buffers[name])
+    }
+    return writeArrayFile(path, stateMagic, stateVersion, "optimizer state", header, arrays)
+}
+
+// LoadState reads a state file written by SaveState
+func LoadState(path string) (*State, error) {
+    var header stateHeader
+    arrays, err := readArrayFile(path, stateMagic, stateVersion, "optimizer state", &header,
+        func() []stateBuffer { return header.Buffers })
+    if err != nil {
+        return nil, err
+    }
+
+    state := &State{
+        Optimizer:    header.Optimizer,
+        Steps:        header.Steps,
+        LearningRate: header.LearningRate,
+        Buffers:      make(map[string][]float32, len(header.Buffers)),
+    }
+    for i, buf := range header.Buffers {
+        state.Buffers[buf.Name] = arrays[i]
+    }
+    return state, nil
+}
+
+// sortedNames returns the names of buffers in file order
+func sortedNames(buffers map[string][]float32) []string {
+    names := make([]string, 0, len(buffers))
+    for name := range buffers {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// writeArrayFile writes the layout shared by optimizer state and checkpoint
+// files (magic, version, JSON header, float32 arrays) to path, describing the
+// contents as what in errors. The file is written under a temporary name and
+// renamed, so an interrupted write leaves an earlier file intact.
+func writeArrayFile(path, magic string, version byte, what string, header any, arrays [][]float32) error {
+    encoded, err := json.Marshal(header)
+    if err != nil {
+        return fmt.Errorf("failed to encode %s: %w", what, err)
+    }
+
+    var out bytes.Buffer
+    out.WriteString(magic)
+    out.Write([]byte{version, 0, 0, 0})
+    binary.Write(&out, binary.LittleEndian, uint32(len(encoded)))
+    out.Write(encoded)
+    for _, values := range arrays {
+        binary.Write(&out, binary.LittleEndian, values)
+    }
+
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, out.Bytes(), 0644); err != nil {
+        return fmt.Errorf("failed to write %s: %w", what, err)
+    }
+    if err := os.Rename(tmp, path); err != nil {
+        os.Remove(tmp)
+        return fmt.Errorf("failed to write %s: %w", what, err)
+    }
+    return nil
+}
+
+// readArrayFile reads a file written by writeArrayFile: it decodes the JSON
+// header into header and returns the arrays that arrays, called once the
+// header is decoded, lists
+func readArrayFile(path, magic string, version byte, what string, header any, arrays func() []stateBuffer) ([][]float32, error) {
+    raw, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read %s: %w", what, err)
+    }
+    if len(raw) < 12 || string(raw[:4]) != magic {
+        return nil, fmt.Errorf("%s is not a valid %s file", path, what)
+    }
+    if raw[4] != version {
+        return nil, fmt.Errorf("%s: unsupported %s version %d", path, what, raw[4])
+    }
+    headerEnd := 12 + int(binary.LittleEndian.Uint32(raw[8:]))
+    if headerEnd > len(raw) {
+        return nil, fmt.Errorf("%s: truncated %s header", path, what)
+    }
+    if err := json.Unmarshal(raw[12:headerEnd], header); err != nil {
+        return nil, fmt.Errorf("%s: invalid %s header: %w", path, what, err)
+    }
+
+    var values [][]float32
+    offset := headerEnd
+    for _, array := range arrays() {
+        end := offset + 4*array.Length
+        if array.Length < 0 || end > len(raw) {
+            return nil, fmt.Errorf("%s: array %s is truncated", path, array.Name)
+        }
+        decoded := make([]float32, array.Length)
+        for i := range decoded {
+            decoded[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[offset+4*i:]))
+        }
+        values = append(values, decoded)
+        offset = end
+    }
+    if offset != len(raw) {
+        return nil, fmt.Errorf("%s: %d unexpected bytes after the arrays", path, len(raw)-offset)
+    }
+    return values, nil
+}
+
+// SaveOptimizer saves the state of opt next to the weights in dir, as StateFileName
+func SaveOptimizer(dir string, opt Stateful) error {
+    return SaveState(filepath.Join(dir, StateFileName), opt.State())
+}
+
+// RestoreOptimizer restores opt from the StateFileName in the weights directory dir
+// A directory without one leaves opt untouched and returns an error wrapping
+// fs.ErrNotExist, so training can start fresh from weights trained elsewhere.
+func RestoreOptimizer(dir string, opt Stateful) error {
+    state, err := LoadState(filepath.Join(dir, StateFileName))
+    if err != nil {
+        return err
+    }
+    return opt.Restore(state)
+}
+
+// copyBuffers returns a deep copy of buffers
+func copyBuffers(buffers map[string][]float32) map[string][]float32 {
+    copied := make(map[string][]float32, len(buffers))
+    for name, values := range buffers {
+        copied[name] = append([]float32(nil), values...)
+    }
+    return copied
+}
+
+// bufferName returns the name of buffer kind of p, e.g. "m/3/kernel"
+func bufferName(kind string, p param) string {
+    return kind + "/" + p.name
+}
+
+// checkBuffers verifies that the existing buffers of every kind have the length
+// of their array, so a mismatch is found before any weight is updated
+func checkBuffers(buffers map[string][]float32, ps []param, kinds ...string) error {
+    for _, p := range ps {
+        for _, kind := range kinds {
+            if values, ok := buffers[bufferName(kind, p)]; ok && len(values) != len(p.values) {
+                return fmt.Errorf("conv layer %s has %d values, its %s buffer %d", p.name, len(p.values), kind, len(values))
+            }
+        }
+    }
+    return nil
+}
+
+// buffer returns buffer kind of p, allocating it with zeros on first use
+func buffer(buffers map[string][]float32, kind string, p param) []float32 {
+    name := bufferName(kind, p)
+    values, ok := buffers[name]
+    if !ok {
+        values = make([]float32, len(p.values))
+        buffers[name] = values
+    }
+    return values
+}